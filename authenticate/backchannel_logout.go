@@ -0,0 +1,132 @@
+package authenticate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pomerium/csrf"
+
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/internal/identity/oidc"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+const backChannelLogoutPath = "/.pomerium/backchannel_logout"
+
+// skipCSRFForBackChannelLogout disables the csrf check for the back-channel logout endpoint, which
+// is called directly by the identity provider.
+func skipCSRFForBackChannelLogout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == backChannelLogoutPath {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BackChannelLogout handles logout requests sent by the identity provider when a user logs out.
+// The sessions identified by the logout token are deleted from the databroker, so subsequent
+// requests using them are denied.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (a *Authenticate) BackChannelLogout(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	state := a.state.Load()
+	options := a.options.Load()
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	idpID := a.getIdentityProviderIDForRequest(r)
+	authenticator, err := a.cfg.getIdentityProvider(options, idpID)
+	if err != nil {
+		return err
+	}
+	logoutAuthenticator, ok := authenticator.(identity.BackChannelLogoutAuthenticator)
+	if !ok {
+		return httputil.NewError(http.StatusNotImplemented,
+			fmt.Errorf("identity provider %s does not support back-channel logout", authenticator.Name()))
+	}
+
+	logoutToken, err := logoutAuthenticator.VerifyLogoutToken(ctx, r.FormValue("logout_token"))
+	if err != nil {
+		log.FromRequest(r).Info().Err(err).Str("idp_id", idpID).Msg("authenticate: invalid logout token")
+		httputil.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "invalid_request",
+		})
+		return nil
+	}
+
+	sessions, err := getLogoutTokenSessions(ctx, state.dataBrokerClient, logoutToken)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	for _, s := range sessions {
+		if err := session.Delete(ctx, state.dataBrokerClient, s.GetId()); err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+		audit.Record(ctx, audit.Event{
+			Type:      audit.EventSignOut,
+			UserID:    s.GetUserId(),
+			SessionID: s.GetId(),
+			Details:   map[string]string{"idp_id": idpID, "method": "back_channel_logout"},
+		})
+	}
+
+	log.FromRequest(r).Info().
+		Str("idp_id", idpID).
+		Str("subject", logoutToken.Subject).
+		Str("sid", logoutToken.SessionID).
+		Int("sessions", len(sessions)).
+		Msg("authenticate: back-channel logout")
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getLogoutTokenSessions returns the sessions identified by the logout token. If the token has a
+// sid, only the sessions created from that identity provider session are returned, otherwise all
+// of the sessions of the subject are.
+func getLogoutTokenSessions(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	logoutToken *oidc.LogoutToken,
+) ([]*session.Session, error) {
+	var sessions []*session.Session
+	var err error
+	if logoutToken.SessionID != "" {
+		sessions, err = session.GetClaimSessions(ctx, client, "sid", logoutToken.SessionID)
+	} else {
+		sessions, err = session.GetClaimSessions(ctx, client, "sub", logoutToken.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*session.Session
+	for _, s := range sessions {
+		if logoutToken.Subject != "" && !hasClaim(s, "sub", logoutToken.Subject) {
+			continue
+		}
+		// sessions from other identity providers may have the same sid or sub
+		if logoutToken.Issuer != "" && len(s.GetClaims()["iss"].GetValues()) > 0 &&
+			!hasClaim(s, "iss", logoutToken.Issuer) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	return matched, nil
+}
+
+func hasClaim(s *session.Session, claim, value string) bool {
+	for _, v := range s.GetClaims()[claim].GetValues() {
+		if v.GetStringValue() == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package authenticate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/internal/identity/oidc"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+type mockBackChannelLogoutProvider struct {
+	identity.MockProvider
+}
+
+func (p mockBackChannelLogoutProvider) VerifyLogoutToken(_ context.Context, rawLogoutToken string) (*oidc.LogoutToken, error) {
+	switch rawLogoutToken {
+	case "SID_TOKEN":
+		return &oidc.LogoutToken{Issuer: "https://idp.example.com", Subject: "USER1", SessionID: "SID1"}, nil
+	case "SUB_TOKEN":
+		return &oidc.LogoutToken{Issuer: "https://idp.example.com", Subject: "USER1"}, nil
+	}
+	return nil, errors.New("invalid logout token")
+}
+
+func TestAuthenticate_BackChannelLogout(t *testing.T) {
+	t.Parallel()
+
+	newSession := func(id string, claims identity.FlattenedClaims) *session.Session {
+		s := &session.Session{Id: id}
+		s.AddClaims(claims)
+		return s
+	}
+	records := []*databroker.Record{
+		databroker.NewRecord(newSession("S1", identity.FlattenedClaims{
+			"iss": {"https://idp.example.com"}, "sub": {"USER1"}, "sid": {"SID1"},
+		})),
+		databroker.NewRecord(newSession("S2", identity.FlattenedClaims{
+			"iss": {"https://idp.example.com"}, "sub": {"USER1"}, "sid": {"SID2"},
+		})),
+		// same subject from another identity provider
+		databroker.NewRecord(newSession("S3", identity.FlattenedClaims{
+			"iss": {"https://other.example.com"}, "sub": {"USER1"}, "sid": {"SID1"},
+		})),
+		databroker.NewRecord(newSession("S4", identity.FlattenedClaims{
+			"iss": {"https://idp.example.com"}, "sub": {"USER2"}, "sid": {"SID3"},
+		})),
+	}
+
+	newAuthenticate := func(deleted *[]string, provider identity.Authenticator) *Authenticate {
+		return &Authenticate{
+			cfg: getAuthenticateConfig(WithGetIdentityProvider(func(options *config.Options, idpID string) (identity.Authenticator, error) {
+				return provider, nil
+			})),
+			state: atomicutil.NewValue(&authenticateState{
+				dataBrokerClient: mockDataBrokerServiceClient{
+					query: func(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+						var matched []*databroker.Record
+						for _, record := range records {
+							if strings.Contains(record.GetData().String(), in.GetQuery()) {
+								matched = append(matched, record)
+							}
+						}
+						return &databroker.QueryResponse{Records: matched, TotalCount: int64(len(matched))}, nil
+					},
+					put: func(ctx context.Context, in *databroker.PutRequest, opts ...grpc.CallOption) (*databroker.PutResponse, error) {
+						for _, record := range in.GetRecords() {
+							assert.NotNil(t, record.GetDeletedAt())
+							*deleted = append(*deleted, record.GetId())
+						}
+						return &databroker.PutResponse{}, nil
+					},
+				},
+			}),
+			options: config.NewAtomicOptions(),
+		}
+	}
+
+	backChannelLogout := func(a *Authenticate, logoutToken string) *httptest.ResponseRecorder {
+		body := url.Values{"logout_token": {logoutToken}}.Encode()
+		r := httptest.NewRequest(http.MethodPost, "https://authenticate.example.com"+backChannelLogoutPath, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		httputil.HandlerFunc(a.BackChannelLogout).ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("sid", func(t *testing.T) {
+		var deleted []string
+		w := backChannelLogout(newAuthenticate(&deleted, mockBackChannelLogoutProvider{}), "SID_TOKEN")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Equal(t, []string{"S1"}, deleted)
+	})
+	t.Run("sub", func(t *testing.T) {
+		var deleted []string
+		w := backChannelLogout(newAuthenticate(&deleted, mockBackChannelLogoutProvider{}), "SUB_TOKEN")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, []string{"S1", "S2"}, deleted)
+	})
+	t.Run("invalid token", func(t *testing.T) {
+		var deleted []string
+		w := backChannelLogout(newAuthenticate(&deleted, mockBackChannelLogoutProvider{}), "INVALID")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error":"invalid_request"}`, w.Body.String())
+		assert.Empty(t, deleted)
+	})
+	t.Run("not supported", func(t *testing.T) {
+		var deleted []string
+		w := backChannelLogout(newAuthenticate(&deleted, identity.MockProvider{}), "SID_TOKEN")
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+		assert.Empty(t, deleted)
+	})
+}
@@ -0,0 +1,140 @@
+package authenticate
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/botchallenge"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/ui"
+)
+
+const (
+	// botChallengeCookieName is the cookie proving the browser solved a bot challenge.
+	botChallengeCookieName = "_pomerium_challenge"
+	// botChallengeSolutionField is the form field the challenge page submits the solution in.
+	botChallengeSolutionField = "pomerium_challenge_solution"
+	// botChallengeRedirectField is the form field with the url to return to once the challenge
+	// is solved.
+	botChallengeRedirectField = "pomerium_challenge_redirect"
+)
+
+// serveBotChallenge serves the bot challenge page if bot challenges are enabled and the browser
+// hasn't solved one recently, returning true if it did.
+func (a *Authenticate) serveBotChallenge(w http.ResponseWriter, r *http.Request) (bool, error) {
+	options := a.options.Load()
+	settings := &options.BotChallenge
+	if !settings.IsEnabled() {
+		return false, nil
+	}
+
+	key := a.getBotChallengeKey()
+	if cookie, err := r.Cookie(botChallengeCookieName); err == nil &&
+		botchallenge.NewPassIssuer(key, settings.GetTTL()).Verify(cookie.Value) {
+		return false, nil
+	}
+
+	data := map[string]any{
+		"provider":     settings.Provider,
+		"siteKey":      settings.SiteKey,
+		"action":       "/.pomerium/challenge",
+		"solutionName": botChallengeSolutionField,
+		"redirectName": botChallengeRedirectField,
+		"redirect":     r.URL.RequestURI(),
+	}
+	if settings.Provider == config.BotChallengeProviderProofOfWork {
+		pow := botchallenge.NewProofOfWork(key, settings.GetDifficulty())
+		data["challenge"] = pow.NewChallenge()
+		data["difficulty"] = pow.Difficulty()
+	}
+	httputil.AddBrandingOptionsToMap(data, &options.Theme)
+
+	w.Header().Set("Content-Security-Policy", getBotChallengeContentSecurityPolicy(settings.Provider))
+	return true, ui.ServePage(w, r, "BotChallenge", data)
+}
+
+// botChallenge verifies the solution of a bot challenge and returns the browser to where it was.
+func (a *Authenticate) botChallenge(w http.ResponseWriter, r *http.Request) error {
+	options := a.options.Load()
+	settings := &options.BotChallenge
+	if !settings.IsEnabled() {
+		return httputil.NewError(http.StatusNotFound, fmt.Errorf("bot challenges are disabled"))
+	}
+
+	verifier, err := a.getBotChallengeVerifier(settings)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	err = verifier.Verify(r.Context(), r.FormValue(botChallengeSolutionField), httputil.GetClientIPAddress(r))
+	if err != nil {
+		log.FromRequest(r).Info().Err(err).Msg("authenticate: bot challenge failed")
+		return httputil.NewError(http.StatusForbidden, err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     botChallengeCookieName,
+		Value:    botchallenge.NewPassIssuer(a.getBotChallengeKey(), settings.GetTTL()).Issue(),
+		Path:     "/",
+		MaxAge:   int(settings.GetTTL().Seconds()),
+		Secure:   options.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// only redirect within the authenticate service
+	redirect := r.FormValue(botChallengeRedirectField)
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = "/.pomerium/"
+	}
+	httputil.Redirect(w, r, redirect, http.StatusFound)
+	return nil
+}
+
+func (a *Authenticate) getBotChallengeVerifier(settings *config.BotChallengeSettings) (botchallenge.Verifier, error) {
+	switch settings.Provider {
+	case config.BotChallengeProviderTurnstile, config.BotChallengeProviderHCaptcha:
+		secretKey, err := settings.GetSecretKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid bot challenge secret key: %w", err)
+		}
+		verifyURL := botchallenge.TurnstileVerifyURL
+		if settings.Provider == config.BotChallengeProviderHCaptcha {
+			verifyURL = botchallenge.HCaptchaVerifyURL
+		}
+		return &botchallenge.SiteVerifyVerifier{URL: verifyURL, SecretKey: secretKey}, nil
+	case config.BotChallengeProviderProofOfWork:
+		return botchallenge.NewProofOfWork(a.getBotChallengeKey(), settings.GetDifficulty()), nil
+	}
+	return nil, fmt.Errorf("unknown bot challenge provider: %s", settings.Provider)
+}
+
+// getBotChallengeKey returns the key used to sign passes and proof of work challenges, derived
+// from the cookie secret.
+func (a *Authenticate) getBotChallengeKey() []byte {
+	return cryptutil.GenerateHMAC([]byte("pomerium-bot-challenge"), a.state.Load().cookieSecret)
+}
+
+// getBotChallengeContentSecurityPolicy returns the content security policy of the challenge page,
+// which has to load the widget of the CAPTCHA service.
+func getBotChallengeContentSecurityPolicy(provider string) string {
+	var origins string
+	switch provider {
+	case config.BotChallengeProviderTurnstile:
+		origins = "https://challenges.cloudflare.com"
+	case config.BotChallengeProviderHCaptcha:
+		origins = "https://hcaptcha.com https://*.hcaptcha.com"
+	default:
+		return httputil.HeadersContentSecurityPolicy["Content-Security-Policy"]
+	}
+	return "default-src 'none'; " +
+		"style-src 'self' 'unsafe-inline' data: " + origins + "; " +
+		"img-src * data:; " +
+		"script-src 'self' 'unsafe-inline' " + origins + "; " +
+		"frame-src " + origins + "; " +
+		"connect-src " + origins + "; " +
+		"font-src data:"
+}
@@ -0,0 +1,185 @@
+package authenticate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pomerium/csrf"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	identitypb "github.com/pomerium/pomerium/pkg/grpc/identity"
+	"github.com/pomerium/pomerium/pkg/hpke"
+)
+
+const (
+	deviceAuthPath      = "/.pomerium/device_auth"
+	deviceAuthTokenPath = "/.pomerium/device_auth/token"
+)
+
+// deviceAuthState is encrypted into the device code returned to the client, so that polling for
+// the access token doesn't require any server side state.
+type deviceAuthState struct {
+	DeviceCode     string `json:"device_code"`
+	ProxyPublicKey []byte `json:"proxy_public_key"`
+	RequestParams  string `json:"request_params"`
+}
+
+// skipCSRFForDeviceAuth disables the csrf check for the device authorization endpoints, which are
+// called by clients without a browser, and so without a csrf cookie.
+func skipCSRFForDeviceAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == deviceAuthPath || r.URL.Path == deviceAuthTokenPath {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DeviceAuth starts an OAuth 2.0 device authorization grant with the identity provider. It's
+// called with an HPKE encrypted query string created by the proxy.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.1
+func (a *Authenticate) DeviceAuth(w http.ResponseWriter, r *http.Request) error {
+	state := a.state.Load()
+	options := a.options.Load()
+
+	if err := r.ParseForm(); err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+	proxyPublicKey, requestParams, err := hpke.DecryptURLValues(state.hpkePrivateKey, r.Form)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+	if err := urlutil.ValidateTimeParameters(requestParams); err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	authenticator, err := a.getDeviceAuthenticator(options, requestParams.Get(urlutil.QueryIdentityProviderID))
+	if err != nil {
+		return err
+	}
+
+	a.logAuthenticateEvent(r, nil)
+
+	res, err := authenticator.DeviceAuth(r.Context())
+	if err != nil {
+		return httputil.NewError(http.StatusBadGateway, err)
+	}
+
+	rawState, err := json.Marshal(deviceAuthState{
+		DeviceCode:     res.DeviceCode,
+		ProxyPublicKey: proxyPublicKey.Bytes(),
+		RequestParams:  requestParams.Encode(),
+	})
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	res.DeviceCode = base64.RawURLEncoding.EncodeToString(cryptutil.Encrypt(state.cookieCipher, rawState, nil))
+
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// DeviceAuthToken polls the identity provider for the access token of a device code returned by
+// DeviceAuth. Until the user authorizes the device, an OAuth 2.0 error response is returned. Once
+// authorized, the response contains the callback url which creates the session on the proxy,
+// like the callback url of the programmatic login flow.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.4
+func (a *Authenticate) DeviceAuthToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	state := a.state.Load()
+	options := a.options.Load()
+
+	rawState, err := base64.RawURLEncoding.DecodeString(r.FormValue("device_code"))
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid device code: %w", err))
+	}
+	rawState, err = cryptutil.Decrypt(state.cookieCipher, rawState, nil)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid device code: %w", err))
+	}
+	var deviceState deviceAuthState
+	if err := json.Unmarshal(rawState, &deviceState); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid device code: %w", err))
+	}
+	proxyPublicKey, err := hpke.PublicKeyFromBytes(deviceState.ProxyPublicKey)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid device code: %w", err))
+	}
+	requestParams, err := url.ParseQuery(deviceState.RequestParams)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid device code: %w", err))
+	}
+
+	idpID := requestParams.Get(urlutil.QueryIdentityProviderID)
+	authenticator, err := a.getDeviceAuthenticator(options, idpID)
+	if err != nil {
+		return err
+	}
+
+	var claims identity.SessionClaims
+	oauthToken, err := authenticator.DeviceAccessToken(ctx, deviceState.DeviceCode, &claims)
+	var deviceAuthErr *oauth.DeviceAuthError
+	if errors.As(err, &deviceAuthErr) {
+		httputil.RenderJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             deviceAuthErr.Code,
+			"error_description": deviceAuthErr.Description,
+		})
+		return nil
+	} else if err != nil {
+		return httputil.NewError(http.StatusBadGateway, err)
+	}
+
+	rawOAuthToken, err := json.Marshal(oauthToken)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	rawClaims, err := structpb.NewStruct(claims.Claims)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	profile := &identitypb.Profile{
+		ProviderId: idpID,
+		IdToken:    []byte(claims.RawIDToken),
+		OauthToken: rawOAuthToken,
+		Claims:     rawClaims,
+	}
+	if a.cfg.profileTrimFn != nil {
+		a.cfg.profileTrimFn(profile)
+	}
+
+	callbackURL, err := urlutil.CallbackURL(state.hpkePrivateKey, proxyPublicKey, requestParams, profile, hpke.EncryptURLValuesV2)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	httputil.RenderJSON(w, http.StatusOK, map[string]string{
+		"callback_url": callbackURL,
+	})
+	return nil
+}
+
+func (a *Authenticate) getDeviceAuthenticator(options *config.Options, idpID string) (identity.DeviceAuthenticator, error) {
+	authenticator, err := a.cfg.getIdentityProvider(options, idpID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceAuthenticator, ok := authenticator.(identity.DeviceAuthenticator)
+	if !ok {
+		return nil, httputil.NewError(http.StatusNotImplemented,
+			fmt.Errorf("identity provider %s does not support device authorization", authenticator.Name()))
+	}
+	return deviceAuthenticator, nil
+}
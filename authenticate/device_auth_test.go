@@ -0,0 +1,150 @@
+package authenticate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/oauth2"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity"
+	identitystate "github.com/pomerium/pomerium/internal/identity/identity"
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	identitypb "github.com/pomerium/pomerium/pkg/grpc/identity"
+	"github.com/pomerium/pomerium/pkg/hpke"
+)
+
+type mockDeviceProvider struct {
+	identity.MockProvider
+	authorized bool
+}
+
+func (p *mockDeviceProvider) DeviceAuth(_ context.Context) (*oauth.DeviceAuthResponse, error) {
+	return &oauth.DeviceAuthResponse{
+		DeviceCode:      "DEVICE_CODE",
+		UserCode:        "USER-CODE",
+		VerificationURI: "https://idp.example.com/device",
+		ExpiresIn:       600,
+		Interval:        5,
+	}, nil
+}
+
+func (p *mockDeviceProvider) DeviceAccessToken(_ context.Context, deviceCode string, v identitystate.State) (*oauth2.Token, error) {
+	if deviceCode != "DEVICE_CODE" {
+		return nil, &oauth.DeviceAuthError{Code: oauth.DeviceAuthErrorExpiredToken}
+	}
+	if !p.authorized {
+		return nil, &oauth.DeviceAuthError{Code: oauth.DeviceAuthErrorAuthorizationPending}
+	}
+	if err := json.Unmarshal([]byte(`{"sub":"USER_ID","email":"user@example.com"}`), v); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: "ACCESS_TOKEN"}, nil
+}
+
+func TestAuthenticate_DeviceAuth(t *testing.T) {
+	t.Parallel()
+
+	proxyKey, err := hpke.GeneratePrivateKey()
+	require.NoError(t, err)
+	authenticateKey, err := hpke.GeneratePrivateKey()
+	require.NoError(t, err)
+	aead, err := chacha20poly1305.NewX(cryptutil.NewKey())
+	require.NoError(t, err)
+
+	provider := &mockDeviceProvider{}
+	a := &Authenticate{
+		cfg: getAuthenticateConfig(WithGetIdentityProvider(func(options *config.Options, idpID string) (identity.Authenticator, error) {
+			return provider, nil
+		})),
+		state: atomicutil.NewValue(&authenticateState{
+			hpkePrivateKey: authenticateKey,
+			cookieCipher:   aead,
+		}),
+		options: config.NewAtomicOptions(),
+	}
+
+	deviceAuthURL := url.URL{
+		Scheme:   "https",
+		Host:     "authenticate.example.com",
+		RawQuery: url.Values{urlutil.QueryIsProgrammatic: {"true"}}.Encode(),
+	}
+	rawURL, err := urlutil.DeviceAuthURL(proxyKey, authenticateKey.PublicKey(), &deviceAuthURL,
+		&url.URL{Scheme: "https", Host: "app.example.com", Path: "/"}, "IDP")
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, rawURL, nil)
+	w := httptest.NewRecorder()
+	httputil.HandlerFunc(a.DeviceAuth).ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var deviceAuthResponse oauth.DeviceAuthResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deviceAuthResponse))
+	assert.Equal(t, "USER-CODE", deviceAuthResponse.UserCode)
+	assert.NotEqual(t, "DEVICE_CODE", deviceAuthResponse.DeviceCode, "should encrypt the device code")
+
+	pollToken := func(deviceCode string) (int, map[string]string) {
+		body := url.Values{"device_code": {deviceCode}}.Encode()
+		r := httptest.NewRequest(http.MethodPost, "https://authenticate.example.com/.pomerium/device_auth/token", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		httputil.HandlerFunc(a.DeviceAuthToken).ServeHTTP(w, r)
+
+		var res map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+
+	code, _ := pollToken("INVALID")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, res := pollToken(deviceAuthResponse.DeviceCode)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, oauth.DeviceAuthErrorAuthorizationPending, res["error"])
+
+	provider.authorized = true
+	code, res = pollToken(deviceAuthResponse.DeviceCode)
+	require.Equal(t, http.StatusOK, code, res)
+
+	callbackURL, err := url.Parse(res["callback_url"])
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", callbackURL.Host)
+
+	senderKey, values, err := hpke.DecryptURLValues(proxyKey, callbackURL.Query())
+	require.NoError(t, err)
+	assert.True(t, senderKey.Equals(authenticateKey.PublicKey()))
+	assert.Equal(t, "true", values.Get(urlutil.QueryIsProgrammatic))
+
+	var profile identitypb.Profile
+	require.NoError(t, protojson.Unmarshal([]byte(values.Get(urlutil.QueryIdentityProfile)), &profile))
+	assert.Equal(t, "IDP", profile.GetProviderId())
+	assert.Equal(t, "USER_ID", profile.GetClaims().AsMap()["sub"])
+}
+
+func TestAuthenticate_DeviceAuthNotSupported(t *testing.T) {
+	t.Parallel()
+
+	a := &Authenticate{
+		cfg: getAuthenticateConfig(WithGetIdentityProvider(func(options *config.Options, idpID string) (identity.Authenticator, error) {
+			return identity.MockProvider{}, nil
+		})),
+	}
+	_, err := a.getDeviceAuthenticator(config.NewDefaultOptions(), "IDP")
+	var httpErr *httputil.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotImplemented, httpErr.Status)
+}
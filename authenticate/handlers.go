@@ -17,6 +17,7 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/pomerium/csrf"
+	"github.com/pomerium/pomerium/internal/audit"
 	"github.com/pomerium/pomerium/internal/handlers"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/identity"
@@ -25,6 +26,7 @@ import (
 	"github.com/pomerium/pomerium/internal/middleware"
 	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/internal/throttle"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/hpke"
@@ -40,7 +42,13 @@ func (a *Authenticate) Handler() http.Handler {
 // Mount mounts the authenticate routes to the given router.
 func (a *Authenticate) Mount(r *mux.Router) {
 	r.StrictSlash(true)
-	r.Use(middleware.SetHeaders(httputil.HeadersContentSecurityPolicy))
+	r.Use(func(h http.Handler) http.Handler {
+		return middleware.SetSecurityHeaders(a.options.Load().SecurityHeaders.GetHeaders())(h)
+	})
+	r.Use(samlCallbackMiddleware)
+	r.Use(skipCSRFForDeviceAuth)
+	r.Use(skipCSRFForBackChannelLogout)
+	r.Use(skipCSRFForOIDCProvider)
 	r.Use(func(h http.Handler) http.Handler {
 		options := a.options.Load()
 		state := a.state.Load()
@@ -89,6 +97,14 @@ func (a *Authenticate) mountDashboard(r *mux.Router) {
 
 	// routes that don't need a session:
 	sr.Path("/sign_out").Handler(httputil.HandlerFunc(a.SignOut))
+	sr.Path("/saml/metadata").Handler(httputil.HandlerFunc(a.samlMetadata)).Methods(http.MethodGet)
+	sr.Path("/device_auth").Handler(httputil.HandlerFunc(a.DeviceAuth)).Methods(http.MethodPost)
+	sr.Path("/device_auth/token").Handler(httputil.HandlerFunc(a.DeviceAuthToken)).Methods(http.MethodPost)
+	sr.Path("/backchannel_logout").Handler(httputil.HandlerFunc(a.BackChannelLogout)).Methods(http.MethodPost)
+	sr.Path("/challenge").Handler(httputil.HandlerFunc(a.botChallenge)).Methods(http.MethodPost)
+	sr.Path("/oidc/.well-known/openid-configuration").Handler(httputil.HandlerFunc(a.oidcDiscovery)).Methods(http.MethodGet)
+	sr.Path("/oidc/token").Handler(httputil.HandlerFunc(a.oidcToken)).Methods(http.MethodPost)
+	sr.Path("/oidc/userinfo").Handler(httputil.HandlerFunc(a.oidcUserInfo)).Methods(http.MethodGet, http.MethodPost)
 
 	// routes that need a session:
 	sr = sr.NewRoute().Subrouter()
@@ -96,6 +112,7 @@ func (a *Authenticate) mountDashboard(r *mux.Router) {
 	sr.Use(a.VerifySession)
 	sr.Path("/").Handler(a.requireValidSignatureOnRedirect(a.userInfo))
 	sr.Path("/sign_in").Handler(httputil.HandlerFunc(a.SignIn))
+	sr.Path("/oidc/authorize").Handler(httputil.HandlerFunc(a.oidcAuthorize)).Methods(http.MethodGet)
 	sr.Path("/device-enrolled").Handler(httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
 		userInfoData, err := a.getUserInfoData(r)
 		if err != nil {
@@ -215,6 +232,11 @@ func (a *Authenticate) SignIn(w http.ResponseWriter, r *http.Request) error {
 		a.cfg.profileTrimFn(profile)
 	}
 
+	// the session is only issued once the user has gone through the interstitial pages
+	if served, err := a.serveInterstitials(w, r, s.UserID()); err != nil || served {
+		return err
+	}
+
 	a.logAuthenticateEvent(r, profile)
 
 	encryptURLValues := hpke.EncryptURLValuesV1
@@ -243,7 +265,8 @@ func (a *Authenticate) SignOut(w http.ResponseWriter, r *http.Request) error {
 		}
 
 		handlers.SignOutConfirm(handlers.SignOutConfirmData{
-			URL: urlutil.SignOutURL(r, authenticateURL, a.state.Load().sharedKey),
+			URL:             urlutil.SignOutURL(r, authenticateURL, a.state.Load().sharedKey),
+			BrandingOptions: &a.options.Load().Theme,
 		}).ServeHTTP(w, r)
 		return nil
 	}
@@ -264,8 +287,20 @@ func (a *Authenticate) signOutRedirect(w http.ResponseWriter, r *http.Request) e
 		return err
 	}
 
+	// the session is cleared when it's revoked
+	sessionState, _ := a.getSessionFromCtx(ctx)
+
 	rawIDToken := a.revokeSession(ctx, w, r)
 
+	evt := audit.Event{
+		Type:    audit.EventSignOut,
+		Details: map[string]string{"idp_id": idpID},
+	}
+	if sessionState != nil {
+		evt.UserID, evt.SessionID = sessionState.UserID(), sessionState.ID
+	}
+	audit.Record(ctx, evt)
+
 	redirectString := ""
 	signOutURL, err := options.GetSignOutRedirectURL()
 	if err != nil {
@@ -312,6 +347,11 @@ func (a *Authenticate) reauthenticateOrFail(w http.ResponseWriter, r *http.Reque
 		return httputil.NewError(http.StatusUnauthorized, err)
 	}
 
+	// browsers have to solve the bot challenge before they're sent to the identity provider
+	if served, err := a.serveBotChallenge(w, r); err != nil || served {
+		return err
+	}
+
 	state := a.state.Load()
 	options := a.options.Load()
 	idpID := a.getIdentityProviderIDForRequest(r)
@@ -345,8 +385,16 @@ func (a *Authenticate) reauthenticateOrFail(w http.ResponseWriter, r *http.Reque
 // https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowSteps
 // https://openid.net/specs/openid-connect-core-1_0.html#AuthResponse
 func (a *Authenticate) OAuthCallback(w http.ResponseWriter, r *http.Request) error {
+	// failed sign ins are throttled per client IP
+	th := throttle.New(a.state.Load().dataBrokerClient, a.options.Load().AuthThrottle)
+	ipKey := throttle.IPKey(httputil.GetClientIPAddress(r))
+	if err := th.CheckRequest(w, r, ipKey); err != nil {
+		return err
+	}
+
 	redirect, err := a.getOAuthCallback(w, r)
 	if err != nil {
+		th.RecordResult(r, err, ipKey)
 		return fmt.Errorf("authenticate.OAuthCallback: %w", err)
 	}
 	httputil.Redirect(w, r, redirect.String(), http.StatusFound)
@@ -460,6 +508,15 @@ Or contact your administrator.
 	if err := state.sessionStore.SaveSession(w, r, &newState); err != nil {
 		return nil, fmt.Errorf("failed saving new session: %w", err)
 	}
+
+	email, _ := claims.Claims["email"].(string)
+	audit.Record(ctx, audit.Event{
+		Type:      audit.EventSignIn,
+		UserID:    newState.UserID(),
+		SessionID: newState.ID,
+		Email:     email,
+		Details:   map[string]string{"idp_id": idpID},
+	})
 	return redirectURL, nil
 }
 
@@ -522,7 +579,7 @@ func (a *Authenticate) getUserInfoData(r *http.Request) (handlers.UserInfoData,
 		CSRFToken: csrf.Token(r),
 		Profile:   profile,
 
-		BrandingOptions: a.options.Load().BrandingOptions,
+		BrandingOptions: &a.options.Load().Theme,
 	}
 	return data, nil
 }
@@ -23,6 +23,12 @@ func defaultGetIdentityProvider(options *config.Options, idpID string) (identity
 	if err != nil {
 		return nil, err
 	}
+
+	samlOptions, err := options.GetSAMLOptions(idp.GetType())
+	if err != nil {
+		return nil, err
+	}
+
 	return identity.NewAuthenticator(oauth.Options{
 		RedirectURL:     redirectURL,
 		ProviderName:    idp.GetType(),
@@ -31,5 +37,6 @@ func defaultGetIdentityProvider(options *config.Options, idpID string) (identity
 		ClientSecret:    idp.GetClientSecret(),
 		Scopes:          idp.GetScopes(),
 		AuthCodeOptions: idp.GetRequestParams(),
+		SAML:            samlOptions,
 	})
 }
@@ -0,0 +1,36 @@
+package authenticate
+
+import (
+	"net/http"
+)
+
+// An interstitial is a page shown to users while signing in, before their session is issued.
+// Interstitial pages submit their form back to the sign in endpoint, so the sign in continues once
+// none of the interstitials need to be shown anymore.
+type interstitial interface {
+	// serve serves the page if the user needs to see it, returning true if it did.
+	serve(w http.ResponseWriter, r *http.Request, userID string) (served bool, err error)
+}
+
+func (a *Authenticate) getInterstitials() []interstitial {
+	options := a.options.Load()
+	return []interstitial{
+		&termsOfUseInterstitial{
+			settings:         &options.TermsOfUse,
+			theme:            &options.Theme,
+			dataBrokerClient: a.state.Load().dataBrokerClient,
+		},
+	}
+}
+
+// serveInterstitials serves the first interstitial page the user needs to see, returning true if
+// one was served.
+func (a *Authenticate) serveInterstitials(w http.ResponseWriter, r *http.Request, userID string) (bool, error) {
+	for _, i := range a.getInterstitials() {
+		served, err := i.serve(w, r, userID)
+		if err != nil || served {
+			return served, err
+		}
+	}
+	return false, nil
+}
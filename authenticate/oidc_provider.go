@@ -0,0 +1,248 @@
+package authenticate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pomerium/csrf"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/oidcprovider"
+)
+
+const (
+	oidcProviderTokenPath    = oidcprovider.Path + "/token"
+	oidcProviderUserInfoPath = oidcprovider.Path + "/userinfo"
+)
+
+var errOIDCProviderDisabled = errors.New("oidc provider is disabled")
+
+// skipCSRFForOIDCProvider disables the csrf check for the token and userinfo endpoints of the OIDC
+// provider, which are called by clients, not browsers.
+func skipCSRFForOIDCProvider(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == oidcProviderTokenPath || r.URL.Path == oidcProviderUserInfoPath {
+			r = csrf.UnsafeSkipCheck(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// oidcDiscovery serves the OpenID Provider metadata of the OIDC provider.
+//
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+func (a *Authenticate) oidcDiscovery(w http.ResponseWriter, _ *http.Request) error {
+	state := a.state.Load()
+	options := a.options.Load()
+	if state.oidcIssuer == nil {
+		return httputil.NewError(http.StatusNotFound, errOIDCProviderDisabled)
+	}
+
+	authenticateURL, err := options.GetAuthenticateURL()
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	issuer := state.oidcIssuer.URL()
+	httputil.RenderJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"jwks_uri":                              authenticateURL.ResolveReference(&url.URL{Path: "/.well-known/pomerium/jwks.json"}).String(),
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{state.oidcIssuer.SigningAlgorithm()},
+		"scopes_supported":                      oidcprovider.SupportedScopes(),
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{oidcprovider.CodeChallengeMethodS256},
+	})
+	return nil
+}
+
+// oidcAuthorize handles authorization requests of OIDC provider clients. It's only reached with a
+// valid session, so users sign in to Pomerium first.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (a *Authenticate) oidcAuthorize(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	state := a.state.Load()
+	options := a.options.Load()
+	if state.oidcIssuer == nil {
+		return httputil.NewError(http.StatusNotFound, errOIDCProviderDisabled)
+	}
+
+	q := r.URL.Query()
+	client, err := oidcprovider.GetClient(ctx, state.dataBrokerClient, &options.OIDCProvider, q.Get("client_id"))
+	if errors.Is(err, oidcprovider.ErrUnknownClient) {
+		return httputil.NewError(http.StatusBadRequest, err)
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	// an invalid redirect uri is never redirected to, errors after this point are returned to the
+	// client
+	redirectURI := q.Get("redirect_uri")
+	if !client.HasRedirectURI(redirectURI) {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid redirect_uri: %q", redirectURI))
+	}
+
+	scopes := strings.Fields(q.Get("scope"))
+	codeChallenge := q.Get("code_challenge")
+	switch {
+	case q.Get("response_type") != "code":
+		return oidcAuthorizeError(w, r, redirectURI, "unsupported_response_type", "only the code response type is supported")
+	case !oidcprovider.HasScope(scopes, oidcprovider.ScopeOpenID):
+		return oidcAuthorizeError(w, r, redirectURI, "invalid_scope", "the openid scope is required")
+	case codeChallenge != "" && q.Get("code_challenge_method") != oidcprovider.CodeChallengeMethodS256:
+		return oidcAuthorizeError(w, r, redirectURI, "invalid_request", "only the S256 code challenge method is supported")
+	case codeChallenge == "" && client.IsPublic():
+		return oidcAuthorizeError(w, r, redirectURI, "invalid_request", "public clients must use PKCE")
+	}
+
+	s, err := a.getSessionFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+	profile, err := a.loadIdentityProfile(r, state.cookieCipher)
+	if err != nil {
+		return httputil.NewError(http.StatusUnauthorized, err)
+	}
+
+	code := oidcprovider.NewCode(client.ID, redirectURI, scopes, q.Get("nonce"), codeChallenge,
+		s.UserID(), profile.GetClaims().AsMap(), time.Now())
+	rawCode, err := oidcprovider.EncryptCode(state.cookieCipher, code)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	log.FromRequest(r).Info().
+		Str("client_id", client.ID).
+		Str("user_id", s.UserID()).
+		Msg("authenticate: issued oidc provider code")
+
+	return oidcAuthorizeRedirect(w, r, redirectURI, url.Values{"code": {rawCode}})
+}
+
+// oidcToken redeems a code for the ID and access tokens.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#TokenEndpoint
+func (a *Authenticate) oidcToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	state := a.state.Load()
+	options := a.options.Load()
+	if state.oidcIssuer == nil {
+		return httputil.NewError(http.StatusNotFound, errOIDCProviderDisabled)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		return oidcTokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		return oidcTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only the authorization_code grant type is supported")
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	client, err := oidcprovider.GetClient(ctx, state.dataBrokerClient, &options.OIDCProvider, clientID)
+	if errors.Is(err, oidcprovider.ErrUnknownClient) {
+		return oidcTokenError(w, http.StatusUnauthorized, "invalid_client", "unknown client")
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if !client.IsPublic() && !client.CheckSecret(clientSecret) {
+		return oidcTokenError(w, http.StatusUnauthorized, "invalid_client", "invalid client secret")
+	}
+
+	code, err := oidcprovider.DecryptCode(state.cookieCipher, r.PostForm.Get("code"), time.Now())
+	if err != nil {
+		return oidcTokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+	}
+	if code.ClientID != client.ID || code.RedirectURI != r.PostForm.Get("redirect_uri") {
+		return oidcTokenError(w, http.StatusBadRequest, "invalid_grant", "code was issued to another client or redirect_uri")
+	}
+	if err := code.VerifyCodeVerifier(r.PostForm.Get("code_verifier")); err != nil {
+		return oidcTokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+	}
+
+	res, err := state.oidcIssuer.IssueTokens(code)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// oidcUserInfo returns the claims of the user an access token was issued for.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+func (a *Authenticate) oidcUserInfo(w http.ResponseWriter, r *http.Request) error {
+	state := a.state.Load()
+	if state.oidcIssuer == nil {
+		return httputil.NewError(http.StatusNotFound, errOIDCProviderDisabled)
+	}
+
+	rawToken, ok := strings.CutPrefix(r.Header.Get(httputil.HeaderAuthorization), "Bearer ")
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+		return httputil.NewError(http.StatusUnauthorized, errors.New("missing access token"))
+	}
+	userInfo, err := state.oidcIssuer.VerifyAccessToken(rawToken)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return httputil.NewError(http.StatusUnauthorized, err)
+	}
+	httputil.RenderJSON(w, http.StatusOK, userInfo)
+	return nil
+}
+
+// oidcAuthorizeError redirects to the client with an error.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+func oidcAuthorizeError(w http.ResponseWriter, r *http.Request, redirectURI, code, description string) error {
+	return oidcAuthorizeRedirect(w, r, redirectURI, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+func oidcAuthorizeRedirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+	q := u.Query()
+	for k, vs := range params {
+		q[k] = vs
+	}
+	if state := r.URL.Query().Get("state"); state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	httputil.Redirect(w, r, u.String(), http.StatusFound)
+	return nil
+}
+
+// oidcTokenError renders an OAuth 2.0 error response.
+//
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+func oidcTokenError(w http.ResponseWriter, status int, code, description string) error {
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pomerium"`)
+	}
+	httputil.RenderJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+	return nil
+}
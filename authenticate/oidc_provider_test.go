@@ -0,0 +1,110 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/oidcprovider"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestAuthenticate_OIDCProviderToken(t *testing.T) {
+	t.Parallel()
+
+	aead, err := chacha20poly1305.NewX(cryptutil.NewKey())
+	require.NoError(t, err)
+	key, err := cryptutil.NewSigningKey()
+	require.NoError(t, err)
+	signingKey, err := cryptutil.EncodePrivateKey(key)
+	require.NoError(t, err)
+	issuer, err := oidcprovider.NewIssuer("https://authenticate.example.com/.pomerium/oidc", signingKey, time.Hour)
+	require.NoError(t, err)
+
+	options := config.NewDefaultOptions()
+	options.OIDCProvider = config.OIDCProviderSettings{
+		Enabled: true,
+		Clients: []config.OIDCProviderClient{
+			{ID: "app", Secret: "secret", RedirectURIs: []string{"https://app.example.com/callback"}},
+		},
+	}
+	a := &Authenticate{
+		state: atomicutil.NewValue(&authenticateState{
+			cookieCipher: aead,
+			oidcIssuer:   issuer,
+		}),
+		options: config.NewAtomicOptions(),
+	}
+	a.options.Store(options)
+
+	code := oidcprovider.NewCode("app", "https://app.example.com/callback", []string{"openid", "email"}, "NONCE", "",
+		"USER_ID", map[string]any{"email": "user@example.com"}, time.Now())
+	rawCode, err := oidcprovider.EncryptCode(aead, code)
+	require.NoError(t, err)
+
+	redeem := func(clientSecret, redirectURI string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {rawCode},
+			"redirect_uri": {redirectURI},
+		}
+		r := httptest.NewRequest(http.MethodPost, oidcProviderTokenPath, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth("app", clientSecret)
+		w := httptest.NewRecorder()
+		require.NoError(t, a.oidcToken(w, r))
+		return w
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		w := redeem("wrong", "https://app.example.com/callback")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_client")
+	})
+	t.Run("other redirect uri", func(t *testing.T) {
+		w := redeem("secret", "https://other.example.com/callback")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_grant")
+	})
+	t.Run("ok", func(t *testing.T) {
+		w := redeem("secret", "https://app.example.com/callback")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var res oidcprovider.TokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.NotEmpty(t, res.IDToken)
+
+		r := httptest.NewRequest(http.MethodGet, oidcProviderUserInfoPath, nil)
+		r.Header.Set(httputil.HeaderAuthorization, "Bearer "+res.AccessToken)
+		w = httptest.NewRecorder()
+		require.NoError(t, a.oidcUserInfo(w, r))
+		assert.JSONEq(t, `{"sub":"USER_ID","email":"user@example.com"}`, w.Body.String())
+	})
+}
+
+func TestAuthenticate_OIDCProviderDisabled(t *testing.T) {
+	t.Parallel()
+
+	a := &Authenticate{
+		state:   atomicutil.NewValue(&authenticateState{}),
+		options: config.NewAtomicOptions(),
+	}
+	r := httptest.NewRequest(http.MethodPost, oidcProviderTokenPath, nil)
+	err := a.oidcToken(httptest.NewRecorder(), r)
+	var httpErr *httputil.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusNotFound, httpErr.Status)
+	}
+}
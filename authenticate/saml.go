@@ -0,0 +1,50 @@
+package authenticate
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity/saml"
+)
+
+// samlCallbackMiddleware maps a SAML response received through the HTTP-POST binding to the form
+// values of an OAuth callback. The relay state is the state, which includes the csrf nonce, and the
+// response is the code.
+func samlCallbackMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			samlResponse, relayState := r.PostFormValue("SAMLResponse"), r.PostFormValue("RelayState")
+			if samlResponse != "" && relayState != "" {
+				code := saml.NewCode(relayState, samlResponse)
+				for _, vs := range []map[string][]string{r.Form, r.PostForm} {
+					vs["state"] = []string{relayState}
+					vs["code"] = []string{code}
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// samlMetadata serves the service provider metadata of the SAML identity provider.
+func (a *Authenticate) samlMetadata(w http.ResponseWriter, r *http.Request) error {
+	authenticator, err := a.cfg.getIdentityProvider(a.options.Load(), a.getIdentityProviderIDForRequest(r))
+	if err != nil {
+		return err
+	}
+
+	provider, ok := authenticator.(*saml.Provider)
+	if !ok {
+		return httputil.NewError(http.StatusNotFound, fmt.Errorf("identity provider is not a SAML provider"))
+	}
+
+	metadata, err := provider.Metadata()
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	_, _ = w.Write(metadata)
+	return nil
+}
@@ -0,0 +1,34 @@
+package authenticate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/identity/saml"
+)
+
+func TestSAMLCallbackMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var state, code string
+	h := samlCallbackMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, code = r.FormValue("state"), r.FormValue("code")
+	}))
+
+	body := url.Values{"SAMLResponse": {"RESPONSE"}, "RelayState": {"STATE"}}.Encode()
+	r := httptest.NewRequest(http.MethodPost, "https://authenticate.example.com/oauth2/callback", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "STATE", state)
+	assert.Equal(t, saml.NewCode("STATE", "RESPONSE"), code)
+
+	r = httptest.NewRequest(http.MethodGet, "https://authenticate.example.com/oauth2/callback?state=STATE2&code=CODE", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "STATE2", state)
+	assert.Equal(t, "CODE", code)
+}
@@ -1,6 +1,7 @@
 package authenticate
 
 import (
+	"context"
 	"crypto/cipher"
 	"fmt"
 	"net/url"
@@ -10,13 +11,18 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/oidcprovider"
 	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/sessions/cookie"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/hpke"
 )
 
+var outboundGRPCConnection = new(grpc.CachedOutboundGRPClientConn)
+
 type authenticateState struct {
 	redirectURL *url.URL
 	// sharedEncoder is the encoder to use to serialize data to be consumed
@@ -38,6 +44,10 @@ type authenticateState struct {
 	hpkePrivateKey *hpke.PrivateKey
 
 	jwk *jose.JSONWebKeySet
+	// oidcIssuer signs the tokens of the built-in OIDC provider, if it's enabled
+	oidcIssuer *oidcprovider.Issuer
+
+	dataBrokerClient databroker.DataBrokerServiceClient
 }
 
 func newAuthenticateState() *authenticateState {
@@ -125,6 +135,15 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 		}
 	}
 
+	if cfg.Options.OIDCProvider.Enabled {
+		issuerURL := authenticateURL.ResolveReference(&url.URL{Path: oidcprovider.Path})
+		state.oidcIssuer, err = oidcprovider.NewIssuer(issuerURL.String(), signingKey,
+			cfg.Options.OIDCProvider.GetTokenLifetime())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	sharedKey, err := cfg.Options.GetSharedKey()
 	if err != nil {
 		return nil, err
@@ -132,5 +151,17 @@ func newAuthenticateStateFromConfig(cfg *config.Config) (*authenticateState, err
 
 	state.hpkePrivateKey = hpke.DerivePrivateKey(sharedKey)
 
+	dataBrokerConn, err := outboundGRPCConnection.Get(context.Background(), &grpc.OutboundOptions{
+		OutboundPort:   cfg.OutboundPort,
+		InstallationID: cfg.Options.InstallationID,
+		ServiceName:    cfg.Options.Services,
+		SignedJWTKey:   sharedKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	state.dataBrokerClient = databroker.NewDataBrokerServiceClient(dataBrokerConn)
+
 	return state, nil
 }
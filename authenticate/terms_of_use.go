@@ -0,0 +1,86 @@
+package authenticate
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/ui"
+)
+
+// TermsOfUseAcceptanceRecordType is the databroker record type of the terms of use accepted by a
+// user.
+const TermsOfUseAcceptanceRecordType = "pomerium.io/TermsOfUseAcceptance"
+
+// termsOfUseVersionField is the form field the terms of use page submits the accepted version in.
+const termsOfUseVersionField = "pomerium_terms_of_use_version"
+
+// A TermsOfUseAcceptance records the version of the terms of use a user accepted.
+type TermsOfUseAcceptance struct {
+	UserID     string    `json:"user_id"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+type termsOfUseInterstitial struct {
+	settings         *config.TermsOfUseSettings
+	theme            httputil.BrandingOptions
+	dataBrokerClient databroker.DataBrokerServiceClient
+}
+
+func (i *termsOfUseInterstitial) serve(w http.ResponseWriter, r *http.Request, userID string) (bool, error) {
+	if !i.settings.IsEnabled() {
+		return false, nil
+	}
+	ctx := r.Context()
+
+	if userID != "" {
+		acceptance, err := databroker.GetViaJSON[TermsOfUseAcceptance](ctx, i.dataBrokerClient, TermsOfUseAcceptanceRecordType, userID)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return false, fmt.Errorf("authenticate: error getting terms of use acceptance: %w", err)
+		}
+		if err == nil && acceptance.Version == i.settings.Version {
+			return false, nil
+		}
+	}
+
+	if r.Method == http.MethodPost && r.FormValue(termsOfUseVersionField) == i.settings.Version {
+		if userID != "" {
+			_, err := databroker.PutViaJSON(ctx, i.dataBrokerClient, TermsOfUseAcceptanceRecordType, userID, TermsOfUseAcceptance{
+				UserID:     userID,
+				Version:    i.settings.Version,
+				AcceptedAt: time.Now(),
+			})
+			if err != nil {
+				return false, fmt.Errorf("authenticate: error storing terms of use acceptance: %w", err)
+			}
+		}
+		audit.Record(ctx, audit.Event{
+			Type:    audit.EventTermsAccepted,
+			UserID:  userID,
+			Details: map[string]string{"version": i.settings.Version},
+		})
+		return false, nil
+	}
+
+	text := i.settings.GetLocalizedText(httputil.PreferredLanguages(r.Header.Get("Accept-Language")))
+	data := map[string]any{
+		"title":       text.Title,
+		"text":        text.Text,
+		"acceptLabel": text.AcceptLabel,
+		"termsUrl":    i.settings.URL,
+		"version":     i.settings.Version,
+		"versionName": termsOfUseVersionField,
+		// the form is submitted to the same url, so the sign in parameters are kept
+		"action": r.URL.RequestURI(),
+	}
+	httputil.AddBrandingOptionsToMap(data, i.theme)
+	return true, ui.ServePage(w, r, "TermsOfUse", data)
+}
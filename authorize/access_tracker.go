@@ -20,6 +20,8 @@ const (
 	accessTrackerMaxSize        = 1_000
 	accessTrackerDebouncePeriod = 10 * time.Second
 	accessTrackerUpdateTimeout  = 3 * time.Second
+	// the number of recent access decisions kept for each user
+	accessTrackerMaxUserAccessDecisions = 20
 )
 
 // A AccessTrackerProvider provides the databroker service client for tracking session access.
@@ -32,6 +34,7 @@ type AccessTracker struct {
 	provider               AccessTrackerProvider
 	sessionAccesses        chan string
 	serviceAccountAccesses chan string
+	accessDecisions        chan userAccessDecision
 	maxSize                int
 	debouncePeriod         time.Duration
 
@@ -48,6 +51,7 @@ func NewAccessTracker(
 		provider:               provider,
 		sessionAccesses:        make(chan string, maxSize),
 		serviceAccountAccesses: make(chan string, maxSize),
+		accessDecisions:        make(chan userAccessDecision, maxSize),
 		maxSize:                maxSize,
 		debouncePeriod:         debouncePeriod,
 	}
@@ -60,12 +64,25 @@ func (tracker *AccessTracker) Run(ctx context.Context) {
 
 	sessionAccesses := sets.NewSizeLimited[string](tracker.maxSize)
 	serviceAccountAccesses := sets.NewSizeLimited[string](tracker.maxSize)
+	accessDecisions := map[string][]user.AccessDecision{}
 	runTrackSessionAccess := func(sessionID string) {
 		sessionAccesses.Add(sessionID)
 	}
 	runTrackServiceAccountAccess := func(serviceAccountID string) {
 		serviceAccountAccesses.Add(serviceAccountID)
 	}
+	runTrackAccessDecision := func(d userAccessDecision) {
+		ds, ok := accessDecisions[d.userID]
+		if !ok && len(accessDecisions) >= tracker.maxSize {
+			atomic.AddInt64(&tracker.droppedAccesses, 1)
+			return
+		}
+		ds = append(ds, d.decision)
+		if len(ds) > accessTrackerMaxUserAccessDecisions {
+			ds = ds[len(ds)-accessTrackerMaxUserAccessDecisions:]
+		}
+		accessDecisions[d.userID] = ds
+	}
 	runSubmit := func() {
 		if dropped := atomic.SwapInt64(&tracker.droppedAccesses, 0); dropped > 0 {
 			log.Error(ctx).
@@ -95,8 +112,20 @@ func (tracker *AccessTracker) Run(ctx context.Context) {
 			return
 		}
 
+		for userID, ds := range accessDecisions {
+			err = tracker.updateAccessDecisions(ctx, client, userID, ds)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("authorize: error updating user access decisions")
+			return
+		}
+
 		sessionAccesses = sets.NewSizeLimited[string](tracker.maxSize)
 		serviceAccountAccesses = sets.NewSizeLimited[string](tracker.maxSize)
+		accessDecisions = map[string][]user.AccessDecision{}
 	}
 
 	for {
@@ -107,6 +136,8 @@ func (tracker *AccessTracker) Run(ctx context.Context) {
 			runTrackSessionAccess(id)
 		case id := <-tracker.serviceAccountAccesses:
 			runTrackServiceAccountAccess(id)
+		case d := <-tracker.accessDecisions:
+			runTrackAccessDecision(d)
 		case <-ticker.C:
 			runSubmit()
 		}
@@ -131,6 +162,32 @@ func (tracker *AccessTracker) TrackSessionAccess(sessionID string) {
 	}
 }
 
+// TrackAccessDecision tracks an access decision for a user, so it can be shown on the user's dashboard.
+func (tracker *AccessTracker) TrackAccessDecision(userID string, decision user.AccessDecision) {
+	select {
+	case tracker.accessDecisions <- userAccessDecision{userID: userID, decision: decision}:
+	default:
+		atomic.AddInt64(&tracker.droppedAccesses, 1)
+	}
+}
+
+type userAccessDecision struct {
+	userID   string
+	decision user.AccessDecision
+}
+
+func (tracker *AccessTracker) updateAccessDecisions(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	userID string,
+	decisions []user.AccessDecision,
+) error {
+	ctx, clearTimeout := context.WithTimeout(ctx, accessTrackerUpdateTimeout)
+	defer clearTimeout()
+
+	return user.AddAccessDecisions(ctx, client, userID, accessTrackerMaxUserAccessDecisions, decisions...)
+}
+
 func (tracker *AccessTracker) updateServiceAccount(
 	ctx context.Context,
 	client databroker.DataBrokerServiceClient,
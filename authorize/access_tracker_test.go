@@ -140,3 +140,55 @@ func TestAccessTracker(t *testing.T) {
 			serviceAccounts["service-account-2"].GetAccessedAt().IsValid()
 	}, time.Second*10, time.Millisecond*100)
 }
+
+func TestAccessTracker_AccessDecisions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	records := map[string]*databroker.Record{}
+	tracker := NewAccessTracker(&testAccessTrackerProvider{
+		dataBrokerServiceClient: &mockDataBrokerServiceClient{
+			get: func(ctx context.Context, in *databroker.GetRequest, opts ...grpc.CallOption) (*databroker.GetResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				record, ok := records[in.GetType()+"/"+in.GetId()]
+				if !ok {
+					return nil, status.Errorf(codes.NotFound, "unknown record")
+				}
+				return &databroker.GetResponse{Record: record}, nil
+			},
+			put: func(ctx context.Context, in *databroker.PutRequest, opts ...grpc.CallOption) (*databroker.PutResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				for _, record := range in.GetRecords() {
+					records[record.GetType()+"/"+record.GetId()] = record
+				}
+				return &databroker.PutResponse{Records: in.GetRecords()}, nil
+			},
+		},
+	}, 200, time.Second)
+	go tracker.Run(ctx)
+
+	for i := 0; i < 100; i++ {
+		tracker.TrackAccessDecision(fmt.Sprintf("user-%d", i%2), user.AccessDecision{
+			URL:   fmt.Sprintf("https://www.example.com/%d", i),
+			Allow: true,
+		})
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(records) == 2
+	}, time.Second*10, time.Millisecond*100)
+
+	ds, err := user.GetAccessDecisions(ctx, tracker.provider.GetDataBrokerServiceClient(), "user-1")
+	assert.NoError(t, err)
+	if assert.Len(t, ds, accessTrackerMaxUserAccessDecisions) {
+		assert.Equal(t, "https://www.example.com/99", ds[0].URL)
+	}
+}
@@ -0,0 +1,188 @@
+package authorize
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/anomaly"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+// An anomalyDetector detects anomalous authorization patterns, as configured by the anomaly
+// detection settings.
+type anomalyDetector struct {
+	provider AccessTrackerProvider
+
+	settings *atomicutil.Value[*config.AnomalyDetectionSettings]
+	analyzer *atomicutil.Value[*anomaly.Analyzer]
+	updated  chan struct{}
+}
+
+func newAnomalyDetector(provider AccessTrackerProvider, settings *config.AnomalyDetectionSettings) *anomalyDetector {
+	return &anomalyDetector{
+		provider: provider,
+		settings: atomicutil.NewValue(settings),
+		analyzer: atomicutil.NewValue[*anomaly.Analyzer](nil),
+		updated:  make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the anomaly detector with the new settings. The detectors are only
+// re-created if the settings changed, so their state is kept otherwise.
+func (ad *anomalyDetector) UpdateConfig(settings *config.AnomalyDetectionSettings) {
+	if cmp.Equal(ad.settings.Load(), settings) {
+		return
+	}
+
+	ad.settings.Store(settings)
+	select {
+	case ad.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the anomaly detection.
+func (ad *anomalyDetector) Run(ctx context.Context) error {
+	for {
+		settings := ad.settings.Load()
+
+		var geoIP *anomaly.MaxMindGeoIP
+		var analyzer *anomaly.Analyzer
+		if settings.IsEnabled() {
+			var err error
+			if settings.GeoIPDatabase != "" {
+				geoIP, err = anomaly.OpenMaxMindGeoIP(settings.GeoIPDatabase)
+				if err != nil {
+					log.Error(ctx).Err(err).Msg("authorize: error opening anomaly detection geoip database")
+				}
+			}
+			analyzer = ad.newAnalyzer(settings, geoIP)
+		}
+		ad.analyzer.Store(analyzer)
+
+		runCtx, runCancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if analyzer != nil {
+				analyzer.Run(runCtx)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+		case <-ad.updated:
+		}
+		runCancel()
+		<-done
+		if geoIP != nil {
+			_ = geoIP.Close()
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (ad *anomalyDetector) newAnalyzer(settings *config.AnomalyDetectionSettings, geoIP *anomaly.MaxMindGeoIP) *anomaly.Analyzer {
+	var detectors []anomaly.Detector
+	if settings.ImpossibleTravel != nil {
+		detectors = append(detectors, anomaly.NewImpossibleTravel(settings.ImpossibleTravel.GetMaxSpeed()))
+	}
+	if settings.NewCountry {
+		detectors = append(detectors, anomaly.NewNewCountry())
+	}
+	if settings.MassDenials != nil {
+		detectors = append(detectors, anomaly.NewMassDenials(settings.MassDenials.GetThreshold(), settings.MassDenials.GetWindow()))
+	}
+
+	handlers := []anomaly.Handler{ad.recordAnomaly}
+	if settings.SetRiskScore {
+		handlers = append(handlers, ad.raiseRiskScore)
+	}
+
+	// a nil *MaxMindGeoIP must not be passed as a non-nil interface
+	if geoIP == nil {
+		return anomaly.NewAnalyzer(nil, detectors, handlers...)
+	}
+	return anomaly.NewAnalyzer(geoIP, detectors, handlers...)
+}
+
+// recordAnomaly records the anomaly in the audit log.
+func (ad *anomalyDetector) recordAnomaly(ctx context.Context, a anomaly.Anomaly) {
+	log.Warn(ctx).
+		Str("anomaly-type", a.Type).
+		Str("user-id", a.UserID).
+		Str("route-id", a.RouteID).
+		Int("score", a.Score).
+		Msg("authorize: anomaly detected")
+
+	details := map[string]string{
+		"anomaly_type": a.Type,
+		"score":        strconv.Itoa(a.Score),
+	}
+	if a.RouteID != "" {
+		details["route_id"] = a.RouteID
+	}
+	for k, v := range a.Details {
+		details[k] = v
+	}
+	audit.Record(ctx, audit.Event{
+		Type:    audit.EventAnomaly,
+		UserID:  a.UserID,
+		Email:   a.Email,
+		Details: details,
+	})
+}
+
+// raiseRiskScore raises the risk score of the user of the anomaly.
+func (ad *anomalyDetector) raiseRiskScore(ctx context.Context, a anomaly.Anomaly) {
+	if a.UserID == "" {
+		return
+	}
+	err := user.RaiseRiskScore(ctx, ad.provider.GetDataBrokerServiceClient(), a.UserID, a.Score, a.Type)
+	if err != nil {
+		log.Error(ctx).Err(err).Str("user-id", a.UserID).Msg("authorize: error raising user risk score")
+	}
+}
+
+// observe passes the decision to the anomaly detection, if enabled.
+func (ad *anomalyDetector) observe(
+	in *envoy_service_auth_v3.CheckRequest,
+	req *evaluator.Request,
+	res *evaluator.Result,
+	s sessionOrServiceAccount,
+	u *user.User,
+) {
+	analyzer := ad.analyzer.Load()
+	if analyzer == nil || req.IsInternal {
+		return
+	}
+
+	// anonymous requests are observed too, so that mass denials of a route are detected
+	obs := &anomaly.Observation{
+		Time:  time.Now(),
+		Email: u.GetEmail(),
+		IP:    in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress(),
+		Allow: res.Allow.Value && !res.Deny.Value,
+	}
+	if s != nil {
+		obs.UserID = s.GetUserId()
+	}
+	if req.Policy != nil {
+		if id, err := req.Policy.RouteID(); err == nil {
+			obs.RouteID = strconv.FormatUint(id, 10)
+		}
+	}
+	analyzer.Observe(obs)
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -14,9 +15,13 @@ import (
 	"github.com/pomerium/pomerium/authorize/internal/store"
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/cidrset"
+	"github.com/pomerium/pomerium/internal/decisiontail"
+	"github.com/pomerium/pomerium/internal/health"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/internal/waf"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/grpc"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
@@ -25,11 +30,18 @@ import (
 
 // Authorize struct holds
 type Authorize struct {
-	state          *atomicutil.Value[*authorizeState]
-	store          *store.Store
-	currentOptions *atomicutil.Value[*config.Options]
-	accessTracker  *AccessTracker
-	globalCache    storage.Cache
+	state             *atomicutil.Value[*authorizeState]
+	store             *store.Store
+	currentOptions    *atomicutil.Value[*config.Options]
+	routes            atomic.Pointer[routeIndex]
+	accessTracker     *AccessTracker
+	decisionPublisher *decisiontail.Publisher
+	revocations       *revocationIndex
+	replicas          *replicaSet
+	globalCache       storage.Cache
+	cidrSets          *cidrset.Manager
+	impersonations    *impersonateAuditor
+	anomalies         *anomalyDetector
 
 	// The stateLock prevents updating the evaluator store simultaneously with an evaluation.
 	// This should provide a consistent view of the data at a given server/record version and
@@ -43,14 +55,26 @@ func New(cfg *config.Config) (*Authorize, error) {
 		currentOptions: config.NewAtomicOptions(),
 		store:          store.New(),
 		globalCache:    storage.NewGlobalCache(time.Minute),
+		cidrSets:       cidrset.NewManager(context.Background()),
+		impersonations: newImpersonateAuditor(),
 	}
 	a.accessTracker = NewAccessTracker(a, accessTrackerMaxSize, accessTrackerDebouncePeriod)
+	a.decisionPublisher = decisiontail.NewPublisher(a)
+	a.revocations = newRevocationIndex(a)
+	a.replicas = newReplicaSet(a, &cfg.Options.AuthorizeReplica)
+	a.anomalies = newAnomalyDetector(a, &cfg.Options.AnomalyDetection)
+	a.cidrSets.OnConfigChange(context.Background(), cfg)
 
-	state, err := newAuthorizeStateFromConfig(cfg, a.store)
+	state, err := newAuthorizeStateFromConfig(cfg, a.store, a.cidrSets, nil)
 	if err != nil {
 		return nil, err
 	}
 	a.state = atomicutil.NewValue(state)
+	health.Set(health.CheckPolicyEvaluator, health.OK())
+	state.evaluator.StartWarmup()
+	metrics.AddPolicyCompileQueueCallback("pomerium-authorize", func() int64 {
+		return a.state.Load().evaluator.PendingPolicyEvaluators()
+	})
 
 	return a, nil
 }
@@ -67,6 +91,19 @@ func (a *Authorize) Run(ctx context.Context) error {
 		a.accessTracker.Run(ctx)
 		return nil
 	})
+	eg.Go(func() error {
+		a.decisionPublisher.Run(ctx)
+		return nil
+	})
+	eg.Go(func() error {
+		return a.revocations.Run(ctx)
+	})
+	eg.Go(func() error {
+		return a.replicas.Run(ctx)
+	})
+	eg.Go(func() error {
+		return a.anomalies.Run(ctx)
+	})
 	eg.Go(func() error {
 		_ = grpc.WaitForReady(ctx, a.state.Load().dataBrokerClientConnection, time.Second*10)
 		return nil
@@ -85,8 +122,18 @@ func validateOptions(o *config.Options) error {
 	return nil
 }
 
-// newPolicyEvaluator returns an policy evaluator.
-func newPolicyEvaluator(opts *config.Options, store *store.Store) (*evaluator.Evaluator, error) {
+// lazyPolicyEvaluatorsMinPolicies is the number of policies from which policy evaluators are
+// created lazily, so that large configs don't block startup and reloads while compiling.
+const lazyPolicyEvaluatorsMinPolicies = 1000
+
+// newPolicyEvaluator returns an policy evaluator. The unchanged policies of the previous evaluator
+// aren't compiled again.
+func newPolicyEvaluator(
+	opts *config.Options,
+	store *store.Store,
+	cidrSets cidrset.Getter,
+	previous *evaluator.Evaluator,
+) (*evaluator.Evaluator, error) {
 	metrics.AddPolicyCountCallback("pomerium-authorize", func() int64 {
 		return int64(len(opts.GetAllPolicies()))
 	})
@@ -126,8 +173,19 @@ func newPolicyEvaluator(opts *config.Options, store *store.Store) (*evaluator.Ev
 			"authorize: internal error: couldn't build client cert constraints: %w", err)
 	}
 
-	return evaluator.New(ctx, store,
-		evaluator.WithPolicies(opts.GetAllPolicies()),
+	// loading the core rule set is expensive, so only do it if a route needs it
+	var w *waf.WAF
+	if opts.HasAnyWAFPolicy() {
+		w, err = waf.New(&opts.WAF)
+		if err != nil {
+			return nil, fmt.Errorf("authorize: invalid waf: %w", err)
+		}
+	}
+
+	policies := getAllPolicies(opts)
+	return evaluator.New(ctx, store, previous,
+		evaluator.WithPolicies(policies),
+		evaluator.WithLazyPolicyEvaluators(len(policies) >= lazyPolicyEvaluatorsMinPolicies),
 		evaluator.WithClientCA(clientCA),
 		evaluator.WithAddDefaultClientCertificateRule(addDefaultClientCertificateRule),
 		evaluator.WithClientCRL(clientCRL),
@@ -136,15 +194,26 @@ func newPolicyEvaluator(opts *config.Options, store *store.Store) (*evaluator.Ev
 		evaluator.WithAuthenticateURL(authenticateURL.String()),
 		evaluator.WithGoogleCloudServerlessAuthenticationServiceAccount(opts.GetGoogleCloudServerlessAuthenticationServiceAccount()),
 		evaluator.WithJWTClaimsHeaders(opts.JWTClaimsHeaders),
+		evaluator.WithCIDRSets(cidrSets),
+		evaluator.WithWAF(w),
+		evaluator.WithMeshIdentity(opts.MeshIdentity),
 	)
 }
 
 // OnConfigChange updates internal structures based on config.Options
 func (a *Authorize) OnConfigChange(ctx context.Context, cfg *config.Config) {
 	a.currentOptions.Store(cfg.Options)
-	if state, err := newAuthorizeStateFromConfig(cfg, a.store); err != nil {
+	a.routes.Store(newRouteIndex(cfg.Options))
+	a.cidrSets.OnConfigChange(ctx, cfg)
+	a.replicas.UpdateConfig(&cfg.Options.AuthorizeReplica)
+	a.anomalies.UpdateConfig(&cfg.Options.AnomalyDetection)
+	// the previous state keeps being used, but the failure is reported by the readiness check
+	if state, err := newAuthorizeStateFromConfig(cfg, a.store, a.cidrSets, a.state.Load()); err != nil {
 		log.Error(ctx).Err(err).Msg("authorize: error updating state")
+		health.Set(health.CheckPolicyEvaluator, health.Error(err))
 	} else {
 		a.state.Store(state)
+		health.Set(health.CheckPolicyEvaluator, health.OK())
+		state.evaluator.StartWarmup()
 	}
 }
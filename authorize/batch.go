@@ -0,0 +1,66 @@
+package authorize
+
+import (
+	"context"
+	"fmt"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const (
+	// MaxBatchCheckRequests is the maximum number of requests in a batch.
+	MaxBatchCheckRequests = 1_000
+
+	batchCheckConcurrency = 32
+)
+
+// CheckBatch authorizes multiple requests, like bursts of ext_authz requests or samples of traffic
+// replayed to test policies. The requests are evaluated concurrently and share a cache, so a session
+// used by several requests is only loaded once. The responses are in the order of the requests.
+//
+// A request which can't be evaluated gets a response with an internal error status, so one bad
+// request doesn't fail the whole batch.
+func (a *Authorize) CheckBatch(
+	ctx context.Context,
+	ins []*envoy_service_auth_v3.CheckRequest,
+) ([]*envoy_service_auth_v3.CheckResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "authorize.grpc.CheckBatch")
+	defer span.End()
+
+	if len(ins) > MaxBatchCheckRequests {
+		return nil, fmt.Errorf("authorize: too many requests in batch: %d > %d", len(ins), MaxBatchCheckRequests)
+	}
+
+	localCache := storage.NewLocalCache()
+	outs := make([]*envoy_service_auth_v3.CheckResponse, len(ins))
+
+	var eg errgroup.Group
+	eg.SetLimit(batchCheckConcurrency)
+	for i := range ins {
+		i := i
+		eg.Go(func() error {
+			out, err := a.check(ctx, ins[i], localCache)
+			if out == nil {
+				if err == nil {
+					err = fmt.Errorf("authorize: empty check response")
+				}
+				log.Error(ctx).Err(err).Int("index", i).Msg("authorize: error checking batch request")
+				out = &envoy_service_auth_v3.CheckResponse{
+					Status: &status.Status{Code: int32(codes.Internal), Message: err.Error()},
+				}
+			}
+			outs[i] = out
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	return outs, ctx.Err()
+}
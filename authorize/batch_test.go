@@ -0,0 +1,21 @@
+package authorize
+
+import (
+	"context"
+	"testing"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize_CheckBatch(t *testing.T) {
+	t.Parallel()
+
+	a := &Authorize{}
+	_, err := a.CheckBatch(context.Background(), make([]*envoy_service_auth_v3.CheckRequest, MaxBatchCheckRequests+1))
+	assert.Error(t, err, "should reject batches which are too large")
+
+	res, err := a.CheckBatch(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, res)
+}
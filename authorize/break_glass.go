@@ -0,0 +1,108 @@
+package authorize
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"time"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	auditlog "github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// getBreakGlassCredential returns the break-glass credential whose pinned key matches the client
+// certificate presented with the request, if the route allows break-glass access. The certificate
+// itself is verified by checkBreakGlass.
+func getBreakGlassCredential(
+	options *config.Options,
+	policy *config.Policy,
+	cert evaluator.ClientCertificateInfo,
+	now time.Time,
+) *config.BreakGlassCredential {
+	if policy == nil || !policy.AllowBreakGlass || !options.BreakGlass.Enabled {
+		return nil
+	}
+	return options.BreakGlass.GetCredential(getSPKIHash(cert.Leaf), now)
+}
+
+// getSPKIHash returns the base64-encoded SHA-256 hash of the subject public key info of the PEM
+// encoded certificate, or "" if the certificate is invalid.
+func getSPKIHash(leaf string) string {
+	block, _ := pem.Decode([]byte(leaf))
+	if block == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// checkBreakGlass authorizes a request made with a break-glass credential. The client certificate
+// must be valid for the route's client CA, and the route's ip lists and waf still apply.
+func (a *Authorize) checkBreakGlass(
+	ctx context.Context,
+	in *envoy_service_auth_v3.CheckRequest,
+	hreq *http.Request,
+	policy *config.Policy,
+	credential *config.BreakGlassCredential,
+) (*envoy_service_auth_v3.CheckResponse, error) {
+	req, err := a.getEvaluatorRequestFromCheckRequest(ctx, in, nil)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("error building evaluator request")
+		return nil, err
+	}
+	defer evaluator.ReleaseRequest(req)
+
+	a.stateLock.RLock()
+	res, err := a.state.Load().evaluator.EvaluateBreakGlass(ctx, req)
+	a.stateLock.RUnlock()
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("error evaluating break-glass request")
+		return nil, err
+	}
+
+	if res.Deny.Value || !res.Allow.Value {
+		log.Warn(ctx).
+			Str("credential", credential.ID).
+			Str("route", policy.From).
+			Strs("deny-why-true", res.Deny.Reasons.Strings()).
+			Msg("authorize: denied request with break-glass credential")
+		return a.deniedResponse(ctx, in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+
+	recordBreakGlassAccess(ctx, hreq, policy, credential)
+	return a.okResponse(nil), nil
+}
+
+// recordBreakGlassAccess records a request allowed by a break-glass credential. Every request is
+// recorded, not just the first, since the credential bypasses authentication.
+func recordBreakGlassAccess(ctx context.Context, hreq *http.Request, policy *config.Policy, credential *config.BreakGlassCredential) {
+	log.Warn(ctx).
+		Str("credential", credential.ID).
+		Str("expires-at", credential.ExpiresAt).
+		Str("route", policy.From).
+		Str("method", hreq.Method).
+		Str("path", hreq.URL.Path).
+		Msg("authorize: allowed request with break-glass credential")
+
+	auditlog.Record(ctx, auditlog.Event{
+		Type: auditlog.EventBreakGlass,
+		Details: map[string]string{
+			"credential_id": credential.ID,
+			"expires_at":    credential.ExpiresAt,
+			"route":         policy.From,
+			"method":        hreq.Method,
+			"path":          hreq.URL.Path,
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package authorize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestGetBreakGlassCredential(t *testing.T) {
+	t.Parallel()
+
+	const spkiHash = "pnduMqbUZ/sQyDW1o4kP6c1u1PoB2gRQu8KEV38guAM="
+	assert.Equal(t, spkiHash, getSPKIHash(certPEM))
+	assert.Empty(t, getSPKIHash("not a certificate"))
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	options := config.NewDefaultOptions()
+	options.BreakGlass = config.BreakGlassSettings{
+		Enabled: true,
+		Credentials: []config.BreakGlassCredential{
+			{ID: "expired", SPKIHash: spkiHash, ExpiresAt: "2025-12-31T00:00:00Z"},
+			{ID: "emergency", SPKIHash: spkiHash, ExpiresAt: "2026-01-02T00:00:00Z"},
+		},
+	}
+	policy := &config.Policy{From: "https://from.example.com", AllowBreakGlass: true}
+	cert := evaluator.ClientCertificateInfo{Presented: true, Leaf: certPEM}
+
+	if c := getBreakGlassCredential(options, policy, cert, now); assert.NotNil(t, c) {
+		assert.Equal(t, "emergency", c.ID)
+	}
+	assert.Nil(t, getBreakGlassCredential(options, policy, cert, now.Add(48*time.Hour)),
+		"should not allow expired credentials")
+	assert.Nil(t, getBreakGlassCredential(options, policy, evaluator.ClientCertificateInfo{}, now),
+		"should require a client certificate")
+	assert.Nil(t, getBreakGlassCredential(options, &config.Policy{From: "https://from.example.com"}, cert, now),
+		"should require the route to allow break-glass access")
+
+	options.BreakGlass.Enabled = false
+	assert.Nil(t, getBreakGlassCredential(options, policy, cert, now),
+		"should not allow access when break-glass access is disabled")
+}
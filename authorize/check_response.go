@@ -18,8 +18,11 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/middleware"
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/policy/criteria"
@@ -129,12 +132,33 @@ func (a *Authorize) deniedResponse(
 	ctx context.Context,
 	in *envoy_service_auth_v3.CheckRequest,
 	code int32, reason string, headers map[string]string,
+) (*envoy_service_auth_v3.CheckResponse, error) {
+	return a.errorResponse(ctx, in, code, reason, nil, headers)
+}
+
+// unauthenticatedResponse returns a 401 response with the url to sign in at, for requests which
+// aren't redirected to sign in.
+func (a *Authorize) unauthenticatedResponse(
+	ctx context.Context,
+	in *envoy_service_auth_v3.CheckRequest,
+	authURL *url.URL,
+) (*envoy_service_auth_v3.CheckResponse, error) {
+	return a.errorResponse(ctx, in, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), authURL, nil)
+}
+
+func (a *Authorize) errorResponse(
+	ctx context.Context,
+	in *envoy_service_auth_v3.CheckRequest,
+	code int32, reason string, authURL *url.URL, headers map[string]string,
 ) (*envoy_service_auth_v3.CheckResponse, error) {
 	respHeader := []*envoy_config_core_v3.HeaderValueOption{}
 
 	// create a http response writer recorder
 	w := httptest.NewRecorder()
 	r := getHTTPRequestFromCheckRequest(in)
+	if policy := a.getMatchingPolicy(envoyconfig.ExtAuthzContextExtensionsRouteID(in.GetAttributes().GetContextExtensions())); policy != nil && policy.JSONErrors {
+		r.Header.Set("Accept", "application/json")
+	}
 
 	// build the user info / debug endpoint
 	debugEndpoint, _ := a.userInfoEndpointURL(in) // if there's an error, we just wont display it
@@ -144,10 +168,14 @@ func (a *Authorize) deniedResponse(
 		Status:          int(code),
 		Err:             errors.New(reason),
 		DebugURL:        debugEndpoint,
+		AuthURL:         authURL,
 		RequestID:       requestid.FromContext(ctx),
-		BrandingOptions: a.currentOptions.Load().BrandingOptions,
+		BrandingOptions: a.currentOptions.Load().GetThemeForRequestURL(getCheckRequestURL(in)),
 	}
-	httpErr.ErrorResponse(ctx, w, r)
+	headersHandler := middleware.SetSecurityHeaders(a.currentOptions.Load().SecurityHeaders.GetHeaders())
+	headersHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpErr.ErrorResponse(r.Context(), w, r)
+	})).ServeHTTP(w, r.WithContext(ctx))
 
 	// transpose the go http response writer into a envoy response
 	resp := w.Result()
@@ -188,10 +216,6 @@ func (a *Authorize) requireLoginResponse(
 	options := a.currentOptions.Load()
 	state := a.state.Load()
 
-	if !a.shouldRedirect(in) {
-		return a.deniedResponse(ctx, in, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
-	}
-
 	authenticateURL, err := options.GetAuthenticateURL()
 	if err != nil {
 		return nil, err
@@ -211,17 +235,32 @@ func (a *Authorize) requireLoginResponse(
 	checkRequestURL := getCheckRequestURL(in)
 	checkRequestURL.Scheme = "https"
 
+	redirect := a.shouldRedirect(in, request.Policy)
+	returnURL := &checkRequestURL
+	if !redirect {
+		// API requests usually come from a page on the same route, which the user should return to
+		returnURL = getSameHostReferrer(in, &checkRequestURL)
+	}
+
 	redirectTo, err := urlutil.SignInURL(
 		state.hpkePrivateKey,
 		authenticateHPKEPublicKey,
 		authenticateURL,
-		&checkRequestURL,
+		returnURL,
 		idp.GetId(),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if !redirect {
+		authURL, err := url.Parse(redirectTo)
+		if err != nil {
+			return nil, err
+		}
+		return a.unauthenticatedResponse(ctx, in, authURL)
+	}
+
 	return a.deniedResponse(ctx, in, http.StatusFound, "Login", map[string]string{
 		"Location": redirectTo,
 	})
@@ -246,9 +285,7 @@ func (a *Authorize) requireWebAuthnResponse(
 		return a.okResponse(result.Headers), nil
 	}
 
-	if !a.shouldRedirect(in) {
-		return a.deniedResponse(ctx, in, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
-	}
+	redirect := a.shouldRedirect(in, request.Policy)
 
 	q := url.Values{}
 	if deviceType, ok := result.Allow.AdditionalData["device_type"].(string); ok {
@@ -265,6 +302,13 @@ func (a *Authorize) requireWebAuthnResponse(
 	}
 	q.Set(urlutil.QueryIdentityProviderID, idp.GetId())
 	signinURL := urlutil.WebAuthnURL(getHTTPRequestFromCheckRequest(in), &checkRequestURL, state.sharedKey, q)
+	if !redirect {
+		authURL, err := url.Parse(signinURL)
+		if err != nil {
+			return nil, err
+		}
+		return a.unauthenticatedResponse(ctx, in, authURL)
+	}
 	return a.deniedResponse(ctx, in, http.StatusFound, "Login", map[string]string{
 		"Location": signinURL,
 	})
@@ -319,7 +363,13 @@ func (a *Authorize) userInfoEndpointURL(in *envoy_service_auth_v3.CheckRequest)
 	return urlutil.NewSignedURL(a.state.Load().sharedKey, debugEndpoint).Sign(), nil
 }
 
-func (a *Authorize) shouldRedirect(in *envoy_service_auth_v3.CheckRequest) bool {
+// shouldRedirect returns true if an unauthenticated request should be redirected to sign in, rather
+// than get a 401 response, which is the case for browsers on routes without JSON errors.
+func (a *Authorize) shouldRedirect(in *envoy_service_auth_v3.CheckRequest, policy *config.Policy) bool {
+	if policy != nil && policy.JSONErrors {
+		return false
+	}
+
 	requestHeaders := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
 	if requestHeaders == nil {
 		return true
@@ -348,3 +398,13 @@ func (a *Authorize) shouldRedirect(in *envoy_service_auth_v3.CheckRequest) bool
 
 	return mediaType == "text/html"
 }
+
+// getSameHostReferrer returns the request's Referer if it's on the same host as the request, or
+// else the request url.
+func getSameHostReferrer(in *envoy_service_auth_v3.CheckRequest, requestURL *url.URL) *url.URL {
+	ref, err := url.Parse(getCheckRequestHeaders(in)[httputil.HeaderReferrer])
+	if err != nil || ref.Scheme != "https" || ref.Host != requestURL.Host {
+		return requestURL
+	}
+	return ref
+}
@@ -2,8 +2,10 @@ package authorize
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -19,6 +21,7 @@ import (
 	"github.com/pomerium/pomerium/authorize/internal/store"
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/testutil"
 	hpke_handlers "github.com/pomerium/pomerium/pkg/hpke/handlers"
 	"github.com/pomerium/pomerium/pkg/policy/criteria"
@@ -131,7 +134,7 @@ func TestAuthorize_okResponse(t *testing.T) {
 	a := &Authorize{currentOptions: config.NewAtomicOptions(), state: atomicutil.NewValue(new(authorizeState))}
 	a.currentOptions.Store(opt)
 	a.store = store.New()
-	pe, err := newPolicyEvaluator(opt, a.store)
+	pe, err := newPolicyEvaluator(opt, a.store, nil, nil)
 	require.NoError(t, err)
 	a.state.Load().evaluator = pe
 
@@ -214,7 +217,9 @@ func TestAuthorize_deniedResponse(t *testing.T) {
 							Code: envoy_type_v3.StatusCode(codes.InvalidArgument),
 						},
 						Headers: []*envoy_config_core_v3.HeaderValueOption{
+							mkHeader("Content-Security-Policy", httputil.HeadersContentSecurityPolicy["Content-Security-Policy"]),
 							mkHeader("Content-Type", "text/html; charset=UTF-8"),
+							mkHeader("Referrer-Policy", httputil.HeadersContentSecurityPolicy["Referrer-Policy"]),
 							mkHeader("X-Pomerium-Intercepted-Response", "true"),
 						},
 						Body: "Access Denied",
@@ -300,5 +305,38 @@ func TestRequireLogin(t *testing.T) {
 			&evaluator.Request{})
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusUnauthorized, int(res.GetDeniedResponse().GetStatus().GetCode()))
+
+		var body struct {
+			AuthURL string
+		}
+		require.NoError(t, json.Unmarshal([]byte(res.GetDeniedResponse().GetBody()), &body))
+		assert.True(t, strings.HasPrefix(body.AuthURL, authnSrv.URL+"/.pomerium/sign_in?"), body.AuthURL)
 	})
+	t.Run("json errors", func(t *testing.T) {
+		res, err := a.requireLoginResponse(context.Background(),
+			&envoy_service_auth_v3.CheckRequest{
+				Attributes: &envoy_service_auth_v3.AttributeContext{
+					Request: &envoy_service_auth_v3.AttributeContext_Request{
+						Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{
+							Headers: map[string]string{
+								"accept": "*/*",
+							},
+						},
+					},
+				},
+			},
+			&evaluator.Request{Policy: &config.Policy{JSONErrors: true}})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		assert.Empty(t, getHeader(res.GetDeniedResponse().GetHeaders(), "Location"))
+	})
+}
+
+func getHeader(hdrs []*envoy_config_core_v3.HeaderValueOption, key string) string {
+	for _, h := range hdrs {
+		if h.GetHeader().GetKey() == key {
+			return h.GetHeader().GetValue()
+		}
+	}
+	return ""
 }
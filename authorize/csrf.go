@@ -0,0 +1,105 @@
+package authorize
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// csrfSetCookieHeader is moved to the response as a set-cookie header by the ext-authz-set-cookie
+// lua script.
+const csrfSetCookieHeader = "X-Pomerium-Set-Cookie"
+
+// csrfTokenSize is the number of random bytes in a CSRF token.
+const csrfTokenSize = 32
+
+// checkCSRF reports whether the request passes the CSRF protection of the policy.
+func checkCSRF(policy *config.Policy, hreq *http.Request) bool {
+	if !csrfApplies(policy, hreq) {
+		return true
+	}
+
+	switch policy.CSRF.Mode {
+	case config.CSRFModeOrigin:
+		return checkCSRFOrigin(policy.CSRF, hreq)
+	case config.CSRFModeDoubleSubmitCookie:
+		return checkCSRFDoubleSubmitCookie(policy.CSRF, hreq)
+	}
+	return true
+}
+
+// csrfApplies reports whether the CSRF protection of the policy applies to the request.
+func csrfApplies(policy *config.Policy, hreq *http.Request) bool {
+	if policy == nil || policy.CSRF == nil {
+		return false
+	}
+
+	switch hreq.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+
+	// browsers never add these headers on their own, so requests using them, like requests with
+	// API tokens, can't be forged
+	if hreq.Header.Get(httputil.HeaderAuthorization) != "" ||
+		hreq.Header.Get(httputil.HeaderPomeriumAuthorization) != "" {
+		return false
+	}
+
+	return true
+}
+
+func checkCSRFOrigin(csrf *config.PolicyCSRF, hreq *http.Request) bool {
+	origin := hreq.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		// fall back to the origin of the referer
+		referer, err := url.Parse(hreq.Header.Get("Referer"))
+		if err != nil || referer.Scheme == "" || referer.Host == "" {
+			return false
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	if origin == hreq.URL.Scheme+"://"+hreq.URL.Host {
+		return true
+	}
+	for _, trusted := range csrf.TrustedOrigins {
+		if origin == trusted {
+			return true
+		}
+	}
+	return false
+}
+
+func checkCSRFDoubleSubmitCookie(csrf *config.PolicyCSRF, hreq *http.Request) bool {
+	cookie, err := hreq.Cookie(csrf.GetCookieName())
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	token := hreq.Header.Get(csrf.GetHeaderName())
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) == 1
+}
+
+// getCSRFCookie returns a new CSRF cookie to set on the response if the policy uses double submit
+// cookies and the request doesn't have one yet.
+func getCSRFCookie(policy *config.Policy, hreq *http.Request) *http.Cookie {
+	if policy == nil || policy.CSRF == nil || policy.CSRF.Mode != config.CSRFModeDoubleSubmitCookie {
+		return nil
+	}
+	if cookie, err := hreq.Cookie(policy.CSRF.GetCookieName()); err == nil && cookie.Value != "" {
+		return nil
+	}
+
+	// the cookie isn't http only so scripts can copy it into the CSRF header
+	return &http.Cookie{
+		Name:     policy.CSRF.GetCookieName(),
+		Value:    cryptutil.NewRandomStringN(csrfTokenSize),
+		Path:     "/",
+		Secure:   hreq.URL.Scheme == "https",
+		SameSite: http.SameSiteStrictMode,
+	}
+}
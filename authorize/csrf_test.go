@@ -0,0 +1,72 @@
+package authorize
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func Test_checkCSRF(t *testing.T) {
+	t.Parallel()
+
+	origin := &config.Policy{CSRF: &config.PolicyCSRF{Mode: config.CSRFModeOrigin, TrustedOrigins: []string{"https://app.example.com"}}}
+	doubleSubmit := &config.Policy{CSRF: &config.PolicyCSRF{Mode: config.CSRFModeDoubleSubmitCookie}}
+	request := func(method string, headers map[string]string) *http.Request {
+		hreq := &http.Request{
+			Method: method,
+			URL:    &url.URL{Scheme: "https", Host: "from.example.com", Path: "/"},
+			Header: make(http.Header),
+		}
+		for k, v := range headers {
+			hreq.Header.Set(k, v)
+		}
+		return hreq
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy *config.Policy
+		req    *http.Request
+		expect bool
+	}{
+		{"no policy", nil, request("POST", nil), true},
+		{"no csrf", &config.Policy{}, request("POST", nil), true},
+		{"idempotent", origin, request("GET", nil), true},
+		{"authorization header", origin, request("POST", map[string]string{"Authorization": "Bearer Pomerium-token"}), true},
+		{"same origin", origin, request("POST", map[string]string{"Origin": "https://from.example.com"}), true},
+		{"trusted origin", origin, request("POST", map[string]string{"Origin": "https://app.example.com"}), true},
+		{"cross origin", origin, request("POST", map[string]string{"Origin": "https://evil.example.com"}), false},
+		{"same origin referer", origin, request("POST", map[string]string{"Referer": "https://from.example.com/page"}), true},
+		{"cross origin referer", origin, request("POST", map[string]string{"Referer": "https://evil.example.com/page"}), false},
+		{"no origin", origin, request("POST", nil), false},
+		{"matching token", doubleSubmit, request("POST", map[string]string{"Cookie": "_pomerium_csrf=abc", "X-Pomerium-CSRF-Token": "abc"}), true},
+		{"mismatched token", doubleSubmit, request("POST", map[string]string{"Cookie": "_pomerium_csrf=abc", "X-Pomerium-CSRF-Token": "xyz"}), false},
+		{"missing token", doubleSubmit, request("POST", map[string]string{"Cookie": "_pomerium_csrf=abc"}), false},
+		{"missing cookie", doubleSubmit, request("POST", map[string]string{"X-Pomerium-CSRF-Token": "abc"}), false},
+	} {
+		assert.Equal(t, tc.expect, checkCSRF(tc.policy, tc.req), tc.name)
+	}
+}
+
+func Test_getCSRFCookie(t *testing.T) {
+	t.Parallel()
+
+	policy := &config.Policy{CSRF: &config.PolicyCSRF{Mode: config.CSRFModeDoubleSubmitCookie, CookieName: "csrf"}}
+	hreq := &http.Request{URL: &url.URL{Scheme: "https", Host: "from.example.com"}, Header: make(http.Header)}
+
+	cookie := getCSRFCookie(policy, hreq)
+	if assert.NotNil(t, cookie) {
+		assert.Equal(t, "csrf", cookie.Name)
+		assert.NotEmpty(t, cookie.Value)
+		assert.True(t, cookie.Secure)
+		assert.False(t, cookie.HttpOnly)
+	}
+
+	hreq.Header.Set("Cookie", "csrf=abc")
+	assert.Nil(t, getCSRFCookie(policy, hreq), "should not replace an existing cookie")
+	assert.Nil(t, getCSRFCookie(&config.Policy{CSRF: &config.PolicyCSRF{Mode: config.CSRFModeOrigin}}, hreq))
+}
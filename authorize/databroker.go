@@ -2,6 +2,7 @@ package authorize
 
 import (
 	"context"
+	"time"
 
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
@@ -79,11 +80,24 @@ func (a *Authorize) getDataBrokerSessionOrServiceAccount(
 	}
 	s = msg.(sessionOrServiceAccount)
 	if err := s.Validate(); err != nil {
-		return nil, err
+		// sessions can't be refreshed while the identity provider is unreachable, so they're
+		// accepted for a grace period after their tokens expire
+		sess, ok := s.(*session.Session)
+		if !ok || !sess.IsInGracePeriod(a.currentOptions.Load().IdPOutageGracePeriod, time.Now()) {
+			return nil, err
+		}
 	}
 
-	if _, ok := s.(*session.Session); ok {
-		a.accessTracker.TrackSessionAccess(sessionID)
+	if sess, ok := s.(*session.Session); ok {
+		options := a.currentOptions.Load()
+		// the access must be checked before tracking it, which would reset the idle time
+		if err := sess.ValidateIdleTimeout(options.SessionIdleTimeout); err != nil {
+			return nil, err
+		}
+		// stateless sessions aren't stored in the databroker, so there's nothing to update
+		if !options.StatelessSessions {
+			a.accessTracker.TrackSessionAccess(sessionID)
+		}
 	}
 	if _, ok := s.(*user.ServiceAccount); ok {
 		a.accessTracker.TrackServiceAccountAccess(sessionID)
@@ -74,3 +74,27 @@ func TestAuthorize_getDataBrokerSessionOrServiceAccount(t *testing.T) {
 	_, err = a.getDataBrokerSessionOrServiceAccount(qctx, "s1", 0)
 	assert.ErrorIs(t, err, session.ErrSessionExpired)
 }
+
+func TestAuthorize_getDataBrokerSessionOrServiceAccount_idleTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(clearTimeout)
+
+	opt := config.NewDefaultOptions()
+	opt.SessionIdleTimeout = time.Minute
+	a, err := New(&config.Config{Options: opt})
+	require.NoError(t, err)
+
+	sq := storage.NewStaticQuerier(
+		&session.Session{Id: "s1", AccessedAt: timestamppb.New(time.Now().Add(-time.Second))},
+		&session.Session{Id: "s2", AccessedAt: timestamppb.New(time.Now().Add(-time.Hour))},
+	)
+	qctx := storage.WithQuerier(ctx, sq)
+
+	_, err = a.getDataBrokerSessionOrServiceAccount(qctx, "s1", 0)
+	assert.NoError(t, err)
+
+	_, err = a.getDataBrokerSessionOrServiceAccount(qctx, "s2", 0)
+	assert.ErrorIs(t, err, session.ErrSessionExpired)
+}
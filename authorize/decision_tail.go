@@ -0,0 +1,55 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/internal/decisiontail"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+// publishDecision publishes the decision, if enabled, so it can be tailed with `pomerium tail`.
+func (a *Authorize) publishDecision(
+	ctx context.Context,
+	hreq *http.Request,
+	in *envoy_service_auth_v3.CheckRequest,
+	req *evaluator.Request,
+	res *evaluator.Result,
+	s sessionOrServiceAccount,
+	u *user.User,
+) {
+	if req.IsInternal || !a.currentOptions.Load().AuthorizeDecisionTail {
+		return
+	}
+
+	// query strings may contain secrets, so they are not published
+	d := &decisiontail.Decision{
+		Time:      time.Now(),
+		RequestID: requestid.FromContext(ctx),
+		Method:    hreq.Method,
+		Host:      hreq.Host,
+		Path:      hreq.URL.Path,
+		IP:        in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress(),
+		Email:     u.GetEmail(),
+		Allow:     res.Allow.Value && !res.Deny.Value,
+		Reasons:   res.Allow.Reasons.Strings(),
+	}
+	if res.Deny.Value {
+		d.Reasons = res.Deny.Reasons.Strings()
+	}
+	if req.Policy != nil {
+		if id, err := req.Policy.RouteID(); err == nil {
+			d.RouteID = strconv.FormatUint(id, 10)
+		}
+	}
+	if s != nil {
+		d.UserID = s.GetUserId()
+	}
+	a.decisionPublisher.Publish(d)
+}
@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pomerium/pomerium/authorize/internal/store"
+	"github.com/pomerium/pomerium/config"
+)
+
+// A BenchmarkStage is the timing of a stage of the evaluation over every iteration of a benchmark.
+type BenchmarkStage struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+func newBenchmarkStage(durations []time.Duration) BenchmarkStage {
+	if len(durations) == 0 {
+		return BenchmarkStage{}
+	}
+
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	n := len(sorted)
+	return BenchmarkStage{
+		Min:  sorted[0],
+		Mean: total / time.Duration(n),
+		P50:  sorted[(n-1)*50/100],
+		P99:  sorted[(n-1)*99/100],
+		Max:  sorted[n-1],
+	}
+}
+
+// A BenchmarkResult is the result of benchmarking the evaluator.
+type BenchmarkResult struct {
+	Iterations int
+
+	ClientCertificateValidation BenchmarkStage
+	PolicyEvaluation            BenchmarkStage
+	HeadersEvaluation           BenchmarkStage
+	Total                       BenchmarkStage
+
+	// the policy response of the last iteration
+	Allow RuleResult
+	Deny  RuleResult
+}
+
+// Benchmark creates an evaluator for the route of the request, evaluates the request the given
+// number of times and returns the timing of each stage of the evaluation. Unlike Evaluate, the
+// stages run one after the other, so they can be timed separately. Databroker records are loaded
+// using the querier in the context.
+func Benchmark(ctx context.Context, req *Request, iterations int, options ...Option) (*BenchmarkResult, error) {
+	if req.Policy == nil {
+		return nil, errors.New("authorize: a route is required to benchmark the evaluator")
+	}
+	if iterations <= 0 {
+		return nil, fmt.Errorf("authorize: invalid number of iterations: %d", iterations)
+	}
+
+	e, err := New(ctx, store.New(), nil, append(options[:len(options):len(options)], WithPolicies([]config.Policy{*req.Policy}))...)
+	if err != nil {
+		return nil, err
+	}
+	return e.benchmark(ctx, req, iterations)
+}
+
+func (e *Evaluator) benchmark(ctx context.Context, req *Request, iterations int) (*BenchmarkResult, error) {
+	if req.Policy == nil {
+		return nil, errors.New("authorize: a route is required to benchmark the evaluator")
+	}
+	if iterations <= 0 {
+		return nil, fmt.Errorf("authorize: invalid number of iterations: %d", iterations)
+	}
+
+	id, err := req.Policy.RouteID()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error computing policy route id: %w", err)
+	}
+	lazyPolicyEvaluator, ok := e.policyEvaluators[id]
+	if !ok {
+		return nil, errors.New("authorize: route not found")
+	}
+	policyEvaluator, err := lazyPolicyEvaluator.get()
+	if err != nil {
+		return nil, err
+	}
+	clientCA, err := e.getClientCA(req.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BenchmarkResult{Iterations: iterations}
+	var certDurations, policyDurations, headersDurations, totalDurations []time.Duration
+	for i := 0; i < iterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		isValidClientCertificate, err := isValidClientCertificate(
+			clientCA, string(e.clientCRL), req.HTTP.ClientCertificate, e.clientCertConstraints)
+		if err != nil {
+			return nil, fmt.Errorf("authorize: error validating client certificate: %w", err)
+		}
+		certDone := time.Now()
+
+		res, err := policyEvaluator.Evaluate(ctx, e.newPolicyRequest(req, isValidClientCertificate, nil))
+		if err != nil {
+			return nil, err
+		}
+		policyDone := time.Now()
+
+		if _, err := e.evaluateHeaders(ctx, req); err != nil {
+			return nil, err
+		}
+		headersDone := time.Now()
+
+		certDurations = append(certDurations, certDone.Sub(start))
+		policyDurations = append(policyDurations, policyDone.Sub(certDone))
+		headersDurations = append(headersDurations, headersDone.Sub(policyDone))
+		totalDurations = append(totalDurations, headersDone.Sub(start))
+		result.Allow, result.Deny = res.Allow, res.Deny
+	}
+
+	result.ClientCertificateValidation = newBenchmarkStage(certDurations)
+	result.PolicyEvaluation = newBenchmarkStage(policyDurations)
+	result.HeadersEvaluation = newBenchmarkStage(headersDurations)
+	result.Total = newBenchmarkStage(totalDurations)
+	return result, nil
+}
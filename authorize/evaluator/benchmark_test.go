@@ -0,0 +1,27 @@
+package evaluator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBenchmarkStage(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, BenchmarkStage{}, newBenchmarkStage(nil))
+
+	var durations []time.Duration
+	for i := 100; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, BenchmarkStage{
+		Min:  time.Millisecond,
+		Mean: 50500 * time.Microsecond,
+		P50:  50 * time.Millisecond,
+		P99:  99 * time.Millisecond,
+		Max:  100 * time.Millisecond,
+	}, newBenchmarkStage(durations))
+	assert.Equal(t, 100*time.Millisecond, durations[0], "should not sort the durations in place")
+}
@@ -2,6 +2,8 @@ package evaluator
 
 import (
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cidrset"
+	"github.com/pomerium/pomerium/internal/waf"
 )
 
 type evaluatorConfig struct {
@@ -14,6 +16,10 @@ type evaluatorConfig struct {
 	authenticateURL                                   string
 	googleCloudServerlessAuthenticationServiceAccount string
 	jwtClaimsHeaders                                  config.JWTClaimHeaders
+	cidrSets                                          cidrset.Getter
+	waf                                               *waf.WAF
+	meshIdentity                                      *config.MeshIdentity
+	lazyPolicyEvaluators                              bool
 }
 
 // An Option customizes the evaluator config.
@@ -91,3 +97,33 @@ func WithJWTClaimsHeaders(headers config.JWTClaimHeaders) Option {
 		cfg.jwtClaimsHeaders = headers
 	}
 }
+
+// WithCIDRSets sets the source of the named CIDR sets referenced by policy ip lists.
+func WithCIDRSets(sets cidrset.Getter) Option {
+	return func(cfg *evaluatorConfig) {
+		cfg.cidrSets = sets
+	}
+}
+
+// WithWAF sets the web application firewall used by policies with a waf.
+func WithWAF(w *waf.WAF) Option {
+	return func(cfg *evaluatorConfig) {
+		cfg.waf = w
+	}
+}
+
+// WithMeshIdentity sets how the SPIFFE identities of service mesh workloads are accepted.
+func WithMeshIdentity(meshIdentity *config.MeshIdentity) Option {
+	return func(cfg *evaluatorConfig) {
+		cfg.meshIdentity = meshIdentity
+	}
+}
+
+// WithLazyPolicyEvaluators sets whether policy evaluators are created on first use and by a
+// background warmup instead of when the evaluator is created. Errors in policies are then only
+// reported when they're evaluated.
+func WithLazyPolicyEvaluators(lazy bool) Option {
+	return func(cfg *evaluatorConfig) {
+		cfg.lazyPolicyEvaluators = lazy
+	}
+}
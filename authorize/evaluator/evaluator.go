@@ -6,17 +6,26 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-jose/go-jose/v3"
 	"github.com/open-policy-agent/opa/rego"
+	octrace "go.opencensus.io/trace"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/pomerium/pomerium/authorize/internal/store"
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cidrset"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/internal/waf"
 	"github.com/pomerium/pomerium/pkg/contextutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/policy/criteria"
@@ -60,6 +69,36 @@ func NewRequestHTTP(
 	}
 }
 
+// RequestGRPC is the gRPC field in the request. It is only set for gRPC requests.
+type RequestGRPC struct {
+	Service string `json:"service"`
+	Method  string `json:"method"`
+}
+
+// NewRequestGRPC creates a new RequestGRPC from an HTTP request. gRPC requests are
+// identified by their content type and have a path of the form `/package.Service/Method`.
+func NewRequestGRPC(req RequestHTTP) RequestGRPC {
+	if !isGRPCContentType(req.Headers["Content-Type"]) {
+		return RequestGRPC{}
+	}
+
+	service, method, ok := strings.Cut(strings.TrimPrefix(req.Path, "/"), "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return RequestGRPC{}
+	}
+	return RequestGRPC{Service: service, Method: method}
+}
+
+func isGRPCContentType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "application/grpc" ||
+		strings.HasPrefix(mediaType, "application/grpc+") ||
+		mediaType == "application/grpc-web" ||
+		strings.HasPrefix(mediaType, "application/grpc-web+") ||
+		strings.HasPrefix(mediaType, "application/grpc-web-text")
+}
+
 // ClientCertificateInfo contains information about the certificate presented
 // by the client (if any).
 type ClientCertificateInfo struct {
@@ -85,21 +124,48 @@ type Result struct {
 	Deny    RuleResult
 	Headers http.Header
 	Traces  []contextutil.PolicyEvaluationTrace
+	WAF     *waf.Verdict
 }
 
 // An Evaluator evaluates policies.
 type Evaluator struct {
 	store                 *store.Store
-	policyEvaluators      map[uint64]*PolicyEvaluator
+	policyEvaluators      map[uint64]*lazyPolicyEvaluator
+	preparedQueries       *preparedQueries
+	pending               atomic.Int64
+	warmupMu              sync.Mutex
+	warmupIDs             []uint64
+	replaced              *Evaluator
+	cancelWarmup          context.CancelFunc
 	headersEvaluators     *HeadersEvaluator
+	headerTemplates       map[uint64]headerTemplates
 	clientCA              []byte
 	clientCRL             []byte
 	clientCertConstraints ClientCertConstraints
+	ipLists               map[uint64]ipLists
+	cidrSets              cidrset.Getter
+	waf                   *waf.WAF
+	meshIdentity          *config.MeshIdentity
+}
+
+type ipLists struct {
+	allow, deny *cidrset.List
+	// the entries of the lists, so the next evaluator can reuse the lists if they didn't change
+	allowEntries, denyEntries []string
 }
 
-// New creates a new Evaluator.
-func New(ctx context.Context, store *store.Store, options ...Option) (*Evaluator, error) {
-	e := &Evaluator{store: store}
+// New creates a new Evaluator. If previous isn't nil, the rego queries it compiled for the same
+// store are reused, so only the policies of added or modified routes are compiled, and the
+// background warmup of the previous one is stopped by StartWarmup, once the new one is in use.
+//
+// When policy evaluators are created lazily, the rego of every policy is still parsed and compiled,
+// so an invalid policy is rejected, but the queries are only prepared when they're first needed or
+// by the warmup started with StartWarmup.
+func New(ctx context.Context, store *store.Store, previous *Evaluator, options ...Option) (*Evaluator, error) {
+	e := &Evaluator{store: store, replaced: previous}
+	if previous != nil && previous.store != store {
+		previous = nil
+	}
 
 	cfg := getConfig(options...)
 
@@ -108,28 +174,90 @@ func New(ctx context.Context, store *store.Store, options ...Option) (*Evaluator
 		return nil, err
 	}
 
-	e.headersEvaluators, err = NewHeadersEvaluator(ctx, store)
-	if err != nil {
-		return nil, err
+	if previous != nil {
+		e.headersEvaluators = previous.headersEvaluators
+	} else {
+		e.headersEvaluators, err = NewHeadersEvaluator(ctx, store)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	e.clientCA = cfg.clientCA
 	e.clientCRL = cfg.clientCRL
 	e.clientCertConstraints = cfg.clientCertConstraints
-
-	e.policyEvaluators = make(map[uint64]*PolicyEvaluator)
+	e.cidrSets = cfg.cidrSets
+	e.waf = cfg.waf
+	e.meshIdentity = cfg.meshIdentity
+
+	e.policyEvaluators = make(map[uint64]*lazyPolicyEvaluator)
+	e.headerTemplates = make(map[uint64]headerTemplates)
+	e.ipLists = make(map[uint64]ipLists)
+	var previousQueries map[string]rego.PreparedEvalQuery
+	if previous != nil {
+		previousQueries = previous.preparedQueries.snapshot()
+	}
+	e.preparedQueries = newPreparedQueries(store, previousQueries)
+	ids := make([]uint64, 0, len(cfg.policies))
 	for i := range cfg.policies {
 		configPolicy := cfg.policies[i]
 		id, err := configPolicy.RouteID()
 		if err != nil {
 			return nil, fmt.Errorf("authorize: error computing policy route id: %w", err)
 		}
-		policyEvaluator, err :=
-			NewPolicyEvaluator(ctx, store, &configPolicy, cfg.addDefaultClientCertificateRule)
+		queries, err := newPolicyQueries(&configPolicy, cfg.addDefaultClientCertificateRule)
 		if err != nil {
 			return nil, err
 		}
+		if cfg.lazyPolicyEvaluators {
+			for _, q := range queries {
+				if err := e.preparedQueries.check(q.script); err != nil {
+					return nil, fmt.Errorf("authorize: invalid policy for %s: %w", configPolicy.From, err)
+				}
+			}
+		}
+		policyEvaluator := newLazyPolicyEvaluator(e.preparedQueries, &configPolicy, queries, &e.pending)
+		if !cfg.lazyPolicyEvaluators {
+			if _, err := policyEvaluator.get(); err != nil {
+				return nil, err
+			}
+		}
+		if duplicate, ok := e.policyEvaluators[id]; ok {
+			// the last policy with a route id is used
+			duplicate.discard()
+		} else {
+			ids = append(ids, id)
+		}
 		e.policyEvaluators[id] = policyEvaluator
+		if len(configPolicy.SetRequestHeaders) > 0 {
+			e.headerTemplates[id] = compileHeaderTemplates(configPolicy.SetRequestHeaders)
+		}
+		if len(configPolicy.IPAllowList) > 0 || len(configPolicy.IPDenyList) > 0 {
+			lists, ok := ipLists{}, false
+			if e.replaced != nil {
+				lists, ok = e.replaced.ipLists[id]
+			}
+			if !ok || !slices.Equal(lists.allowEntries, configPolicy.IPAllowList) ||
+				!slices.Equal(lists.denyEntries, configPolicy.IPDenyList) {
+				lists = ipLists{
+					allow:        cidrset.NewList(configPolicy.IPAllowList),
+					deny:         cidrset.NewList(configPolicy.IPDenyList),
+					allowEntries: configPolicy.IPAllowList,
+					denyEntries:  configPolicy.IPDenyList,
+				}
+			}
+			e.ipLists[id] = lists
+		}
+	}
+
+	if cfg.lazyPolicyEvaluators {
+		e.warmupIDs = ids
+	} else {
+		log.Debug(ctx).
+			Int("policies", len(cfg.policies)).
+			Int("compiled", e.preparedQueries.compiled).
+			Int("reused", e.preparedQueries.reused).
+			Msg("authorize: prepared policy queries")
 	}
 
 	return e, nil
@@ -170,10 +298,33 @@ func (e *Evaluator) Evaluate(ctx context.Context, req *Request) (*Result, error)
 		Deny:    policyOutput.Deny,
 		Headers: headersOutput.Headers,
 		Traces:  policyOutput.Traces,
+		WAF:     policyOutput.WAF,
 	}
+	if e.meshIdentity.GetForwardBearerToken() && res.Headers.Get(httputil.HeaderAuthorization) == "" {
+		if jwt := res.Headers.Get(httputil.HeaderPomeriumJWTAssertion); jwt != "" {
+			res.Headers.Set(httputil.HeaderAuthorization, "Bearer "+jwt)
+		}
+	}
+	addResultSpanAttributes(span, req, res)
 	return res, nil
 }
 
+// addResultSpanAttributes adds the evaluated policy and the result to the evaluation span, so
+// traces show why a request was allowed or denied.
+func addResultSpanAttributes(span *octrace.Span, req *Request, res *Result) {
+	if req.Policy != nil {
+		if id, err := req.Policy.RouteID(); err == nil {
+			span.AddAttributes(octrace.StringAttribute("pomerium.policy_id", strconv.FormatUint(id, 10)))
+		}
+	}
+	span.AddAttributes(
+		octrace.BoolAttribute("pomerium.allow", res.Allow.Value),
+		octrace.StringAttribute("pomerium.allow_reasons", strings.Join(res.Allow.Reasons.Strings(), ",")),
+		octrace.BoolAttribute("pomerium.deny", res.Deny.Value),
+		octrace.StringAttribute("pomerium.deny_reasons", strings.Join(res.Deny.Reasons.Strings(), ",")),
+	)
+}
+
 func (e *Evaluator) evaluateInternal(_ context.Context, req *Request) (*PolicyResponse, error) {
 	// these endpoints require a logged-in user
 	if req.HTTP.Path == "/.pomerium/webauthn" || req.HTTP.Path == "/.pomerium/jwt" {
@@ -201,12 +352,21 @@ func (e *Evaluator) evaluatePolicy(ctx context.Context, req *Request) (*PolicyRe
 		return nil, fmt.Errorf("authorize: error computing policy route id: %w", err)
 	}
 
-	policyEvaluator, ok := e.policyEvaluators[id]
+	lazyPolicyEvaluator, ok := e.policyEvaluators[id]
 	if !ok {
 		return &PolicyResponse{
 			Deny: NewRuleResult(true, criteria.ReasonRouteNotFound),
 		}, nil
 	}
+	policyEvaluator, err := lazyPolicyEvaluator.get()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error creating policy evaluator: %w", err)
+	}
+
+	// ip lists are checked outside of rego so that large cidr sets don't slow down evaluation
+	if res := e.evaluateIPLists(id, req.HTTP.IP); res != nil {
+		return res, nil
+	}
 
 	clientCA, err := e.getClientCA(req.Policy)
 	if err != nil {
@@ -219,16 +379,143 @@ func (e *Evaluator) evaluatePolicy(ctx context.Context, req *Request) (*PolicyRe
 		return nil, fmt.Errorf("authorize: error validating client certificate: %w", err)
 	}
 
-	return policyEvaluator.Evaluate(ctx, &PolicyRequest{
+	wafVerdict, err := e.inspectRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if wafVerdict != nil && wafVerdict.Interrupted && req.Policy.WAF.GetMode() == config.WAFModeBlocking {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonWAFBlocked),
+			WAF:  wafVerdict,
+		}, nil
+	}
+
+	res, err := policyEvaluator.Evaluate(ctx, e.newPolicyRequest(req, isValidClientCertificate, wafVerdict))
+	if err != nil {
+		return nil, err
+	}
+	res.WAF = wafVerdict
+	return res, nil
+}
+
+// EvaluateBreakGlass evaluates a request made with a break-glass credential. The route's policy is
+// skipped, but the ip lists and the web application firewall are still enforced, and the client
+// certificate must chain to the route's client CA, be unexpired and be usable for client
+// authentication. Unlike policy evaluation, a client CA is required.
+func (e *Evaluator) EvaluateBreakGlass(ctx context.Context, req *Request) (*PolicyResponse, error) {
+	_, span := trace.StartSpan(ctx, "authorize.Evaluator.EvaluateBreakGlass")
+	defer span.End()
+
+	if req.Policy == nil {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonRouteNotFound),
+		}, nil
+	}
+
+	id, err := req.Policy.RouteID()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error computing policy route id: %w", err)
+	}
+	if res := e.evaluateIPLists(id, req.HTTP.IP); res != nil {
+		return res, nil
+	}
+
+	clientCA, err := e.getClientCA(req.Policy)
+	if err != nil {
+		return nil, err
+	}
+	if clientCA == "" {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonClientCertificateRequired),
+		}, nil
+	}
+	isValidClientCertificate, err := isValidClientCertificate(
+		clientCA, string(e.clientCRL), req.HTTP.ClientCertificate, e.clientCertConstraints)
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error validating client certificate: %w", err)
+	}
+	if !isValidClientCertificate {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonInvalidClientCertificate),
+		}, nil
+	}
+
+	wafVerdict, err := e.inspectRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if wafVerdict != nil && wafVerdict.Interrupted && req.Policy.WAF.GetMode() == config.WAFModeBlocking {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonWAFBlocked),
+			WAF:  wafVerdict,
+		}, nil
+	}
+
+	return &PolicyResponse{
+		Allow: NewRuleResult(true, criteria.ReasonValidClientCertificate),
+		WAF:   wafVerdict,
+	}, nil
+}
+
+func (e *Evaluator) newPolicyRequest(req *Request, isValidClientCertificate bool, wafVerdict *waf.Verdict) *PolicyRequest {
+	return &PolicyRequest{
 		HTTP:                     req.HTTP,
+		GRPC:                     NewRequestGRPC(req.HTTP),
+		Mesh:                     NewRequestMesh(e.meshIdentity, req.HTTP, isValidClientCertificate),
 		Session:                  req.Session,
 		IsValidClientCertificate: isValidClientCertificate,
+		WAF:                      wafVerdict,
+	}
+}
+
+// inspectRequest inspects the request with the web application firewall if the policy has a waf.
+func (e *Evaluator) inspectRequest(req *Request) (*waf.Verdict, error) {
+	if req.Policy.WAF == nil || e.waf == nil {
+		return nil, nil
+	}
+
+	verdict, err := e.waf.Inspect(&waf.Request{
+		Method:   req.HTTP.Method,
+		URL:      req.HTTP.URL,
+		Headers:  req.HTTP.Headers,
+		ClientIP: req.HTTP.IP,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error inspecting request: %w", err)
+	}
+	return verdict, nil
+}
+
+// evaluateIPLists returns a deny response if the client IP is in the policy's ip_denylist, or the
+// policy has an ip_allowlist and the client IP is not in it.
+func (e *Evaluator) evaluateIPLists(id uint64, ip string) *PolicyResponse {
+	lists, ok := e.ipLists[id]
+	if !ok {
+		return nil
+	}
+
+	addr, _ := netip.ParseAddr(ip)
+	if lists.deny.Contains(e.cidrSets, addr) {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonIPDenied),
+		}
+	}
+	if !lists.allow.IsEmpty() && !lists.allow.Contains(e.cidrSets, addr) {
+		return &PolicyResponse{
+			Deny: NewRuleResult(true, criteria.ReasonIPUnauthorized),
+		}
+	}
+	return nil
 }
 
 func (e *Evaluator) evaluateHeaders(ctx context.Context, req *Request) (*HeadersResponse, error) {
 	headersReq := NewHeadersRequestFromPolicy(req.Policy, req.HTTP)
 	headersReq.Session = req.Session
+	if req.Policy != nil && len(req.Policy.SetRequestHeaders) > 0 {
+		if id, err := req.Policy.RouteID(); err == nil {
+			headersReq.setRequestHeaderTemplates = e.headerTemplates[id]
+		}
+	}
 	res, err := e.headersEvaluators.Evaluate(ctx, headersReq)
 	if err != nil {
 		return nil, err
@@ -3,9 +3,12 @@ package evaluator
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,7 +16,9 @@ import (
 
 	"github.com/pomerium/pomerium/authorize/internal/store"
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cidrset"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/waf"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
 	"github.com/pomerium/pomerium/pkg/grpc/user"
@@ -36,7 +41,7 @@ func TestEvaluator(t *testing.T) {
 		store := store.New()
 		store.UpdateJWTClaimHeaders(config.NewJWTClaimHeaders("email", "groups", "user", "CUSTOM_KEY"))
 		store.UpdateSigningKey(privateJWK)
-		e, err := New(ctx, store, options...)
+		e, err := New(ctx, store, nil, options...)
 		require.NoError(t, err)
 		return e.Evaluate(ctx, req)
 	}
@@ -552,8 +557,144 @@ func TestEvaluator(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, res.Allow.Value)
 	})
+	t.Run("ip lists", func(t *testing.T) {
+		ipPolicies := []config.Policy{
+			{
+				To:                               config.WeightedURLs{{URL: *mustParseURL("https://to1.example.com")}},
+				AllowPublicUnauthenticatedAccess: true,
+				IPDenyList:                       []string{"192.0.2.1", "blocklist"},
+			},
+			{
+				To:                               config.WeightedURLs{{URL: *mustParseURL("https://to2.example.com")}},
+				AllowPublicUnauthenticatedAccess: true,
+				IPAllowList:                      []string{"10.0.0.0/8", "missing"},
+			},
+		}
+		ipOptions := []Option{
+			WithAuthenticateURL("https://authn.example.com"),
+			WithPolicies(ipPolicies),
+			WithCIDRSets(cidrSetsFunc(func(name string) *cidrset.Set {
+				if name == "blocklist" {
+					return cidrset.New(netip.MustParsePrefix("198.51.100.0/24"))
+				}
+				return nil
+			})),
+		}
+		for _, tc := range []struct {
+			policy *config.Policy
+			ip     string
+			allow  bool
+			reason criteria.Reason
+		}{
+			{&ipPolicies[0], "203.0.113.1", true, ""},
+			{&ipPolicies[0], "192.0.2.1", false, criteria.ReasonIPDenied},
+			{&ipPolicies[0], "198.51.100.1", false, criteria.ReasonIPDenied},
+			{&ipPolicies[1], "10.1.2.3", true, ""},
+			{&ipPolicies[1], "203.0.113.1", false, criteria.ReasonIPUnauthorized},
+			{&ipPolicies[1], "", false, criteria.ReasonIPUnauthorized},
+		} {
+			res, err := eval(t, ipOptions, []proto.Message{}, &Request{
+				Policy: tc.policy,
+				HTTP: NewRequestHTTP(
+					http.MethodGet,
+					*mustParseURL("https://from.example.com/"),
+					nil,
+					ClientCertificateInfo{},
+					tc.ip,
+				),
+			})
+			require.NoError(t, err)
+			if tc.allow {
+				assert.True(t, res.Allow.Value, tc.ip)
+				assert.False(t, res.Deny.Value, tc.ip)
+			} else {
+				assert.True(t, res.Deny.Value, tc.ip)
+				assert.True(t, res.Deny.Reasons.Has(tc.reason), tc.ip)
+			}
+		}
+	})
+	t.Run("waf", func(t *testing.T) {
+		w, err := waf.New(&config.WAFSettings{
+			Directives: `SecRule ARGS:id "@eq 0" "id:1,phase:1,deny,status:403,msg:'invalid id'"`,
+		})
+		require.NoError(t, err)
+
+		wafPolicies := []config.Policy{
+			{
+				To:                               config.WeightedURLs{{URL: *mustParseURL("https://to1.example.com")}},
+				AllowPublicUnauthenticatedAccess: true,
+				WAF:                              &config.PolicyWAF{Mode: config.WAFModeBlocking},
+			},
+			{
+				To:                               config.WeightedURLs{{URL: *mustParseURL("https://to2.example.com")}},
+				AllowPublicUnauthenticatedAccess: true,
+				WAF:                              &config.PolicyWAF{Mode: config.WAFModeDetection},
+			},
+		}
+		wafOptions := []Option{
+			WithAuthenticateURL("https://authn.example.com"),
+			WithPolicies(wafPolicies),
+			WithWAF(w),
+		}
+		evalURL := func(t *testing.T, policy *config.Policy, rawURL string) *Result {
+			res, err := eval(t, wafOptions, []proto.Message{}, &Request{
+				Policy: policy,
+				HTTP: NewRequestHTTP(
+					http.MethodGet,
+					*mustParseURL(rawURL),
+					nil,
+					ClientCertificateInfo{},
+					"",
+				),
+			})
+			require.NoError(t, err)
+			return res
+		}
+
+		res := evalURL(t, &wafPolicies[0], "https://from.example.com/?id=1")
+		assert.True(t, res.Allow.Value)
+		assert.False(t, res.Deny.Value)
+		assert.False(t, res.WAF.Interrupted)
+
+		res = evalURL(t, &wafPolicies[0], "https://from.example.com/?id=0")
+		assert.True(t, res.Deny.Value)
+		assert.True(t, res.Deny.Reasons.Has(criteria.ReasonWAFBlocked))
+		assert.Equal(t, 1, res.WAF.RuleID)
+
+		res = evalURL(t, &wafPolicies[1], "https://from.example.com/?id=0")
+		assert.True(t, res.Allow.Value, "detection mode should not block")
+		assert.False(t, res.Deny.Value)
+		assert.True(t, res.WAF.Interrupted)
+	})
+	t.Run("benchmark", func(t *testing.T) {
+		ctx := storage.WithQuerier(context.Background(), storage.NewStaticQuerier())
+		store := store.New()
+		store.UpdateSigningKey(privateJWK)
+		e, err := New(ctx, store, nil, options...)
+		require.NoError(t, err)
+
+		req := &Request{
+			Policy: &policies[0],
+			HTTP:   NewRequestHTTP(http.MethodGet, *mustParseURL("https://from.example.com/"), nil, ClientCertificateInfo{}, ""),
+		}
+		res, err := e.benchmark(ctx, req, 3)
+		require.NoError(t, err)
+		assert.Equal(t, 3, res.Iterations)
+		assert.True(t, res.Allow.Value)
+		assert.LessOrEqual(t, res.PolicyEvaluation.Min, res.PolicyEvaluation.Max)
+		assert.GreaterOrEqual(t, res.Total.Max, res.HeadersEvaluation.Max)
+
+		_, err = Benchmark(ctx, &Request{HTTP: req.HTTP}, 3)
+		assert.Error(t, err, "should require a route")
+		_, err = e.benchmark(ctx, req, 0)
+		assert.Error(t, err, "should require at least one iteration")
+	})
 }
 
+type cidrSetsFunc func(name string) *cidrset.Set
+
+func (f cidrSetsFunc) Get(name string) *cidrset.Set { return f(name) }
+
 func mustParseURL(str string) *url.URL {
 	u, err := url.Parse(str)
 	if err != nil {
@@ -561,3 +702,155 @@ func mustParseURL(str string) *url.URL {
 	}
 	return u
 }
+
+func TestNewRequestGRPC(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		contentType string
+		path        string
+		expect      RequestGRPC
+	}{
+		{"application/grpc", "/example.v1.UserService/GetUser", RequestGRPC{Service: "example.v1.UserService", Method: "GetUser"}},
+		{"application/grpc+proto", "/example.v1.UserService/GetUser", RequestGRPC{Service: "example.v1.UserService", Method: "GetUser"}},
+		{"application/grpc-web-text; charset=utf-8", "/example.v1.UserService/GetUser", RequestGRPC{Service: "example.v1.UserService", Method: "GetUser"}},
+		{"application/json", "/example.v1.UserService/GetUser", RequestGRPC{}},
+		{"application/grpc", "/example.v1.UserService", RequestGRPC{}},
+		{"application/grpc", "/a/b/c", RequestGRPC{}},
+	} {
+		actual := NewRequestGRPC(RequestHTTP{
+			Path:    tc.path,
+			Headers: map[string]string{"Content-Type": tc.contentType},
+		})
+		assert.Equal(t, tc.expect, actual, "%s %s", tc.contentType, tc.path)
+	}
+}
+
+func TestNew_previous(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := store.New()
+	to := config.WeightedURLs{{URL: *mustParseURL("https://to.example.com")}}
+	public := config.Policy{From: "https://a.example.com", To: to, AllowPublicUnauthenticatedAccess: true}
+	authenticated := config.Policy{From: "https://b.example.com", To: to, AllowAnyAuthenticatedUser: true}
+	users := config.Policy{From: "https://c.example.com", To: to, AllowedUsers: []string{"user-1"}}
+	public.IPDenyList = []string{"192.0.2.0/24"}
+	publicID, err := public.RouteID()
+	require.NoError(t, err)
+
+	e1, err := New(ctx, s, nil, WithPolicies([]config.Policy{public, authenticated}))
+	require.NoError(t, err)
+	assert.Len(t, e1.preparedQueries.current, 2)
+
+	prepared := newPreparedQueries(s, e1.preparedQueries.snapshot())
+	for _, p := range []config.Policy{public, users} {
+		p := p
+		_, err := newPolicyEvaluator(ctx, prepared, &p, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, prepared.reused, "the unchanged policy should be reused")
+	assert.Equal(t, 1, prepared.compiled, "the added policy should be compiled")
+
+	e2, err := New(ctx, s, e1, WithPolicies([]config.Policy{public, users}))
+	require.NoError(t, err)
+	assert.Len(t, e2.preparedQueries.current, 2, "queries of removed policies should be dropped")
+	assert.Same(t, e1.headersEvaluators, e2.headersEvaluators)
+	assert.Same(t, e1.ipLists[publicID].deny, e2.ipLists[publicID].deny, "unchanged ip lists should be reused")
+
+	e3, err := New(ctx, store.New(), e2, WithPolicies([]config.Policy{public}))
+	require.NoError(t, err)
+	assert.NotSame(t, e2.headersEvaluators, e3.headersEvaluators, "evaluators of another store should not be reused")
+}
+
+func TestNew_lazyPolicyEvaluators(t *testing.T) {
+	t.Parallel()
+
+	ctx := storage.WithQuerier(context.Background(), storage.NewStaticQuerier())
+	policies := make([]config.Policy, 10)
+	for i := range policies {
+		policies[i] = config.Policy{
+			From:         fmt.Sprintf("https://from-%d.example.com", i),
+			To:           config.WeightedURLs{{URL: *mustParseURL("https://to.example.com")}},
+			AllowedUsers: []string{fmt.Sprintf("user-%d", i)},
+		}
+	}
+
+	e, err := New(ctx, store.New(), nil, WithPolicies(policies), WithLazyPolicyEvaluators(true))
+	require.NoError(t, err)
+
+	res, err := e.evaluatePolicy(ctx, &Request{
+		Policy: &policies[9],
+		HTTP:   NewRequestHTTP(http.MethodGet, *mustParseURL("https://from-9.example.com/"), nil, ClientCertificateInfo{}, ""),
+	})
+	require.NoError(t, err, "policy evaluators should be created on first use")
+	assert.False(t, res.Allow.Value)
+
+	invalid := append([]config.Policy{}, policies...)
+	invalid[0].SubPolicies = []config.SubPolicy{{Rego: []string{"allow = {"}}}
+	_, err = New(ctx, e.store, e, WithPolicies(invalid), WithLazyPolicyEvaluators(true))
+	assert.Error(t, err, "invalid rego should be rejected, even when policy evaluators are created lazily")
+
+	e.StartWarmup()
+	t.Cleanup(e.stopWarmup)
+	assert.Eventually(t, func() bool {
+		return e.PendingPolicyEvaluators() == 0
+	}, 10*time.Second, 10*time.Millisecond, "the warmup should create every policy evaluator")
+}
+
+func TestEvaluator_EvaluateBreakGlass(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	w, err := waf.New(&config.WAFSettings{
+		Directives: `SecRule ARGS:id "@eq 0" "id:1,phase:1,deny,status:403,msg:'invalid id'"`,
+	})
+	require.NoError(t, err)
+	policy := config.Policy{
+		From:            "https://from.example.com",
+		To:              config.WeightedURLs{{URL: *mustParseURL("https://to.example.com")}},
+		AllowedUsers:    []string{"user-1"},
+		AllowBreakGlass: true,
+		IPDenyList:      []string{"192.0.2.0/24"},
+		WAF:             &config.PolicyWAF{Mode: config.WAFModeBlocking},
+	}
+	options := []Option{WithPolicies([]config.Policy{policy}), WithWAF(w)}
+
+	evaluateBreakGlass := func(t *testing.T, e *Evaluator, rawURL, leaf, ip string) *PolicyResponse {
+		t.Helper()
+		res, err := e.EvaluateBreakGlass(ctx, &Request{
+			Policy: &policy,
+			HTTP: NewRequestHTTP(http.MethodGet, *mustParseURL(rawURL), nil,
+				ClientCertificateInfo{Presented: leaf != "", Leaf: leaf}, ip),
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	e, err := New(ctx, store.New(), nil, append(options, WithClientCA([]byte(testCA)))...)
+	require.NoError(t, err)
+
+	res := evaluateBreakGlass(t, e, "https://from.example.com/", testValidCert, "203.0.113.1")
+	assert.True(t, res.Allow.Value, "the route's policy should be skipped")
+	assert.False(t, res.Deny.Value)
+
+	for _, tc := range []struct {
+		rawURL, leaf, ip string
+		reason           criteria.Reason
+	}{
+		{"https://from.example.com/", testUntrustedCert, "203.0.113.1", criteria.ReasonInvalidClientCertificate},
+		{"https://from.example.com/", "", "203.0.113.1", criteria.ReasonInvalidClientCertificate},
+		{"https://from.example.com/", testValidCert, "192.0.2.1", criteria.ReasonIPDenied},
+		{"https://from.example.com/?id=0", testValidCert, "203.0.113.1", criteria.ReasonWAFBlocked},
+	} {
+		res := evaluateBreakGlass(t, e, tc.rawURL, tc.leaf, tc.ip)
+		assert.True(t, res.Deny.Value, tc.reason)
+		assert.True(t, res.Deny.Reasons.Has(tc.reason), tc.reason)
+	}
+
+	e, err = New(ctx, store.New(), nil, options...)
+	require.NoError(t, err)
+	res = evaluateBreakGlass(t, e, "https://from.example.com/", testValidCert, "203.0.113.1")
+	assert.True(t, res.Deny.Reasons.Has(criteria.ReasonClientCertificateRequired),
+		"a client CA should be required")
+}
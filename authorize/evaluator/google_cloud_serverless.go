@@ -28,13 +28,14 @@ var (
 	GCPIdentityNow                   = time.Now
 	GCPIdentityMaxBodySize     int64 = 1024 * 1024 * 10
 
-	getGoogleCloudServerlessHeadersRegoOption = rego.Function2(&rego.Function{
+	getGoogleCloudServerlessHeadersRegoFunction = &rego.Function{
 		Name: "get_google_cloud_serverless_headers",
 		Decl: types.NewFunction(
 			types.Args(types.S, types.S),
 			types.NewObject(nil, types.NewDynamicProperty(types.S, types.S)),
 		),
-	}, func(bctx rego.BuiltinContext, op1 *ast.Term, op2 *ast.Term) (*ast.Term, error) {
+	}
+	getGoogleCloudServerlessHeadersRegoOption = rego.Function2(getGoogleCloudServerlessHeadersRegoFunction, func(bctx rego.BuiltinContext, op1 *ast.Term, op2 *ast.Term) (*ast.Term, error) {
 		serviceAccount, ok := op1.Value.(ast.String)
 		if !ok {
 			return nil, fmt.Errorf("invalid service account type: %T", op1)
@@ -2,6 +2,7 @@ package evaluator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -23,10 +24,14 @@ type HeadersRequest struct {
 	EnableRoutingKey                          bool                  `json:"enable_routing_key"`
 	Issuer                                    string                `json:"issuer"`
 	KubernetesServiceAccountToken             string                `json:"kubernetes_service_account_token"`
+	KubernetesGroupMappings                   map[string][]string   `json:"kubernetes_group_mappings"`
 	ToAudience                                string                `json:"to_audience"`
 	Session                                   RequestSession        `json:"session"`
 	ClientCertificate                         ClientCertificateInfo `json:"client_certificate"`
 	SetRequestHeaders                         map[string]string     `json:"set_request_headers"`
+
+	// the precompiled set_request_headers of the route, compiled on demand if not set
+	setRequestHeaderTemplates headerTemplates
 }
 
 // NewHeadersRequestFromPolicy creates a new HeadersRequest from a policy.
@@ -38,6 +43,7 @@ func NewHeadersRequestFromPolicy(policy *config.Policy, http RequestHTTP) *Heade
 		input.EnableRoutingKey = policy.EnvoyOpts.GetLbPolicy() == envoy_config_cluster_v3.Cluster_RING_HASH ||
 			policy.EnvoyOpts.GetLbPolicy() == envoy_config_cluster_v3.Cluster_MAGLEV
 		input.KubernetesServiceAccountToken = policy.KubernetesServiceAccountToken
+		input.KubernetesGroupMappings = policy.KubernetesGroupMappings
 		for _, wu := range policy.To {
 			input.ToAudience = "https://" + wu.URL.Hostname()
 		}
@@ -94,9 +100,11 @@ var variableSubstitutionFunctionRegoOption = rego.Function2(&rego.Function{
 	return ast.StringTerm(output), nil
 })
 
-// A HeadersEvaluator evaluates the headers.rego script.
+// A HeadersEvaluator evaluates the headers.rego script. The headers are built in go when possible,
+// and the rego script is only evaluated for requests the go implementation doesn't support.
 type HeadersEvaluator struct {
-	q rego.PreparedEvalQuery
+	q      rego.PreparedEvalQuery
+	native *nativeHeadersEvaluator
 }
 
 // NewHeadersEvaluator creates a new HeadersEvaluator.
@@ -116,7 +124,8 @@ func NewHeadersEvaluator(ctx context.Context, store *store.Store) (*HeadersEvalu
 	}
 
 	return &HeadersEvaluator{
-		q: q,
+		q:      q,
+		native: &nativeHeadersEvaluator{store: store},
 	}, nil
 }
 
@@ -124,6 +133,20 @@ func NewHeadersEvaluator(ctx context.Context, store *store.Store) (*HeadersEvalu
 func (e *HeadersEvaluator) Evaluate(ctx context.Context, req *HeadersRequest) (*HeadersResponse, error) {
 	ctx, span := trace.StartSpan(ctx, "authorize.HeadersEvaluator.Evaluate")
 	defer span.End()
+
+	h, err := e.native.evaluate(ctx, req)
+	if errors.Is(err, errHeadersNeedRego) {
+		return e.evaluateRego(ctx, req)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &HeadersResponse{
+		Headers: h,
+	}, nil
+}
+
+func (e *HeadersEvaluator) evaluateRego(ctx context.Context, req *HeadersRequest) (*HeadersResponse, error) {
 	rs, err := safeEval(ctx, e.q, rego.EvalInput(req))
 	if err != nil {
 		return nil, fmt.Errorf("authorize: error evaluating headers.rego: %w", err)
@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"testing"
@@ -78,7 +79,14 @@ func TestHeadersEvaluator(t *testing.T) {
 		store.UpdateSigningKey(privateJWK)
 		e, err := NewHeadersEvaluator(ctx, store)
 		require.NoError(t, err)
-		return e.Evaluate(ctx, input)
+		output, err := e.Evaluate(ctx, input)
+		if err == nil {
+			// the headers built in go should match the headers of the rego script
+			regoOutput, err := e.evaluateRego(ctx, input)
+			require.NoError(t, err)
+			assertEquivalentHeaders(t, regoOutput.Headers, output.Headers)
+		}
+		return output, err
 	}
 
 	iat := time.Unix(1686870680, 0)
@@ -231,6 +239,58 @@ func TestHeadersEvaluator(t *testing.T) {
 
 		assert.Equal(t, "", output.Headers.Get("fingerprint"))
 	})
+
+	t.Run("kubernetes group mappings", func(t *testing.T) {
+		data := []proto.Message{
+			&session.Session{Id: "s1", UserId: "u1", Claims: map[string]*structpb.ListValue{
+				"email": {Values: []*structpb.Value{structpb.NewStringValue("u1@example.com")}},
+				"groups": {Values: []*structpb.Value{
+					structpb.NewStringValue("admins"),
+					structpb.NewStringValue("developers"),
+					structpb.NewStringValue("other"),
+				}},
+			}},
+		}
+		input := &HeadersRequest{
+			Issuer:                        "from.example.com",
+			ToAudience:                    "to.example.com",
+			Session:                       RequestSession{ID: "s1"},
+			KubernetesServiceAccountToken: "TOKEN",
+		}
+
+		output, err := eval(t, data, input)
+		require.NoError(t, err)
+		assert.Equal(t, "admins,developers,other", output.Headers.Get("Impersonate-Group"),
+			"should pass every group without mappings")
+
+		input.KubernetesGroupMappings = map[string][]string{
+			"admins":     {"system:masters", "view"},
+			"developers": {"view"},
+		}
+		output, err = eval(t, data, input)
+		require.NoError(t, err)
+		assert.Equal(t, "system:masters,view", output.Headers.Get("Impersonate-Group"),
+			"should only pass mapped groups")
+	})
+}
+
+func assertEquivalentHeaders(t *testing.T, expected, actual http.Header) {
+	t.Helper()
+
+	expected, actual = expected.Clone(), actual.Clone()
+	expectedJWT, actualJWT := expected.Get("X-Pomerium-Jwt-Assertion"), actual.Get("X-Pomerium-Jwt-Assertion")
+	expected.Del("X-Pomerium-Jwt-Assertion")
+	actual.Del("X-Pomerium-Jwt-Assertion")
+	assert.Equal(t, expected, actual)
+
+	var expectedClaims, actualClaims map[string]any
+	require.NoError(t, json.Unmarshal(decodeJWSPayload(t, expectedJWT), &expectedClaims))
+	require.NoError(t, json.Unmarshal(decodeJWSPayload(t, actualJWT), &actualClaims))
+	// the expiry depends on the time of evaluation
+	assert.InDelta(t, expectedClaims["exp"], actualClaims["exp"], 1)
+	delete(expectedClaims, "exp")
+	delete(actualClaims, "exp")
+	assert.Equal(t, expectedClaims, actualClaims)
 }
 
 func decodeJWSPayload(t *testing.T, jws string) []byte {
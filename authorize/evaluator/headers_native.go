@@ -0,0 +1,506 @@
+package evaluator
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+
+	"github.com/pomerium/pomerium/authorize/internal/store"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// errHeadersNeedRego is returned by the native headers evaluation when the request relies on
+// behavior only the headers.rego script implements, like claims which aren't strings.
+var errHeadersNeedRego = errors.New("authorize: headers require rego evaluation")
+
+// A headerTemplate is a precompiled set_request_headers value. Variables are only substituted
+// once, so replacements containing variables aren't expanded again.
+type headerTemplate struct {
+	literals  []string
+	variables []string
+}
+
+// compileHeaderTemplate compiles a header value with the same syntax as os.Expand.
+func compileHeaderTemplate(value string) *headerTemplate {
+	// a NUL byte marks the variables, so values which contain one can't be precompiled
+	if strings.Contains(value, "\x00") {
+		return nil
+	}
+
+	t := new(headerTemplate)
+	expanded := os.Expand(value, func(key string) string {
+		if key == "$" {
+			return "$" // allow a dollar sign to be escaped using $$
+		}
+		t.variables = append(t.variables, key)
+		return "\x00"
+	})
+	t.literals = strings.Split(expanded, "\x00")
+	return t
+}
+
+func (t *headerTemplate) execute(replacements map[string]string) string {
+	var sb strings.Builder
+	for i, literal := range t.literals {
+		sb.WriteString(literal)
+		if i < len(t.variables) {
+			sb.WriteString(replacements[t.variables[i]])
+		}
+	}
+	return sb.String()
+}
+
+// headerTemplates are the precompiled set_request_headers of a route.
+type headerTemplates map[string]*headerTemplate
+
+func compileHeaderTemplates(setRequestHeaders map[string]string) headerTemplates {
+	templates := make(headerTemplates, len(setRequestHeaders))
+	for name, value := range setRequestHeaders {
+		templates[name] = compileHeaderTemplate(value)
+	}
+	return templates
+}
+
+// nativeHeadersEvaluator evaluates the headers of the headers.rego script in go.
+type nativeHeadersEvaluator struct {
+	store *store.Store
+
+	mu         sync.Mutex
+	signingKey *jose.JSONWebKey
+	signer     jose.Signer
+}
+
+func (e *nativeHeadersEvaluator) getSigner(signingKey *jose.JSONWebKey) (jose.Signer, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.signer != nil && e.signingKey == signingKey {
+		return e.signer, nil
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.SignatureAlgorithm(signingKey.Algorithm),
+		Key:       signingKey,
+	}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return nil, err
+	}
+	e.signingKey, e.signer = signingKey, signer
+	return signer, nil
+}
+
+// headerPair is a header name and value. The pairs are added in the same order as the
+// identity_headers of the rego script.
+type headerPair struct {
+	name, value string
+}
+
+// nativeHeadersData is the data the headers are built from, like the session and user records.
+type nativeHeadersData struct {
+	session       map[string]any
+	user          map[string]any
+	directoryUser map[string]any
+	groupIDs      []any
+}
+
+func (e *nativeHeadersEvaluator) evaluate(ctx context.Context, req *HeadersRequest) (http.Header, error) {
+	signingKey := e.store.GetSigningKey()
+	if signingKey == nil {
+		return nil, errHeadersNeedRego
+	}
+
+	data := e.getData(ctx, req)
+	now := time.Now()
+
+	claims, err := e.getJWTClaims(ctx, req, data, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []headerPair
+
+	signer, err := e.getSigner(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error creating jwt signer: %w", err)
+	}
+	payload := make(map[string]any, len(claims))
+	for _, c := range claims {
+		if c.value != nil {
+			payload[c.key] = c.value
+		}
+	}
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error marshaling jwt payload: %w", err)
+	}
+	jws, err := signer.Sign(rawPayload)
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error signing jwt: %w", err)
+	}
+	signedJWT, err := jws.CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error serializing jwt: %w", err)
+	}
+	pairs = append(pairs, headerPair{"x-pomerium-jwt-assertion", signedJWT})
+
+	// jwt claim headers
+	jwtClaimHeaders := e.store.GetJWTClaimHeaders()
+	for _, headerName := range sortedKeys(jwtClaimHeaders) {
+		var raw any = ""
+		for _, c := range claims {
+			if c.key == jwtClaimHeaders[headerName] {
+				raw = c.value
+				break
+			}
+		}
+		// like the rego script, claims which can't be converted to a header value are skipped
+		if value, ok := getHeaderStringValue(raw); ok {
+			pairs = append(pairs, headerPair{headerName, value})
+		}
+	}
+
+	// kubernetes headers
+	if req.KubernetesServiceAccountToken != "" {
+		email, _ := claims.get("email").(string)
+		groups, ok := getKubernetesGroups(req.KubernetesGroupMappings, claims.get("groups"))
+		if !ok {
+			return nil, errHeadersNeedRego
+		}
+		pairs = append(pairs,
+			headerPair{"Authorization", "Bearer " + req.KubernetesServiceAccountToken},
+			headerPair{"Impersonate-User", email},
+			headerPair{"Impersonate-Group", groups},
+		)
+	}
+
+	// google cloud serverless headers
+	if req.EnableGoogleCloudServerlessAuthentication {
+		headers, err := getGoogleCloudServerlessHeaders(e.store.GetGoogleCloudServerlessAuthenticationServiceAccount(), req.ToAudience)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("error retrieving google cloud serverless headers")
+		}
+		for _, k := range sortedKeys(headers) {
+			pairs = append(pairs, headerPair{k, headers[k]})
+		}
+	}
+
+	// routing key headers
+	if req.EnableRoutingKey {
+		h := sha256.Sum256([]byte(req.Session.ID))
+		pairs = append(pairs, headerPair{"x-pomerium-routing-key", hex.EncodeToString(h[:])})
+	}
+
+	// set request headers
+	if len(req.SetRequestHeaders) > 0 {
+		fingerprint, ok := getClientCertFingerprint(req.ClientCertificate.Leaf)
+		if !ok {
+			return nil, errHeadersNeedRego
+		}
+		idToken, ok1 := getOptionalString(getPath(data.session, "id_token", "raw"))
+		accessToken, ok2 := getOptionalString(getPath(data.session, "oauth_token", "access_token"))
+		if !ok1 || !ok2 {
+			return nil, errHeadersNeedRego
+		}
+		replacements := map[string]string{
+			"pomerium.id_token":                idToken,
+			"pomerium.access_token":            accessToken,
+			"pomerium.client_cert_fingerprint": fingerprint,
+		}
+
+		templates := req.setRequestHeaderTemplates
+		if templates == nil {
+			templates = compileHeaderTemplates(req.SetRequestHeaders)
+		}
+		for _, headerName := range sortedKeys(req.SetRequestHeaders) {
+			t := templates[headerName]
+			if t == nil {
+				return nil, errHeadersNeedRego
+			}
+			pairs = append(pairs, headerPair{headerName, t.execute(replacements)})
+		}
+	}
+
+	h := make(http.Header)
+	for _, p := range pairs {
+		h.Add(p.name, p.value)
+	}
+	return h, nil
+}
+
+func (e *nativeHeadersEvaluator) getData(ctx context.Context, req *HeadersRequest) *nativeHeadersData {
+	data := new(nativeHeadersData)
+
+	data.session = store.GetDataBrokerRecord(ctx, "type.googleapis.com/user.ServiceAccount", req.Session.ID)
+	if data.session == nil {
+		data.session = store.GetDataBrokerRecord(ctx, "type.googleapis.com/session.Session", req.Session.ID)
+		if impersonateSessionID, _ := data.session["impersonate_session_id"].(string); impersonateSessionID != "" {
+			data.session = store.GetDataBrokerRecord(ctx, "type.googleapis.com/session.Session", impersonateSessionID)
+		}
+	}
+	if data.session == nil {
+		data.session = map[string]any{}
+	}
+
+	data.user = map[string]any{}
+	data.directoryUser = map[string]any{}
+	if userID, ok := data.session["user_id"].(string); ok {
+		if u := store.GetDataBrokerRecord(ctx, "type.googleapis.com/user.User", userID); u != nil {
+			data.user = u
+		}
+		if du := store.GetDataBrokerRecord(ctx, "pomerium.io/DirectoryUser", userID); du != nil {
+			data.directoryUser = du
+		}
+	}
+
+	data.groupIDs = []any{}
+	if groupIDs, ok := data.directoryUser["group_ids"].([]any); ok {
+		data.groupIDs = groupIDs
+	}
+
+	return data
+}
+
+type jwtClaim struct {
+	key   string
+	value any
+}
+
+type jwtClaims []jwtClaim
+
+func (claims jwtClaims) get(key string) any {
+	for _, c := range claims {
+		if c.key == key {
+			return c.value
+		}
+	}
+	return nil
+}
+
+func (e *nativeHeadersEvaluator) getJWTClaims(ctx context.Context, req *HeadersRequest, data *nativeHeadersData, now time.Time) (jwtClaims, error) {
+	fiveMinutes := int64(math.Round(float64(now.UnixNano())/1e9 + 60*5))
+
+	var jti any = ""
+	if v, ok := data.session["id"]; ok {
+		jti = v
+	}
+
+	var exp any = fiveMinutes
+	if seconds, ok := getPath(data.session, "expires_at", "seconds").(float64); ok {
+		exp = min64(fiveMinutes, int64(math.Round(seconds)))
+	}
+
+	var iat any
+	if seconds, ok := getPath(data.session, "id_token", "issued_at", "seconds").(float64); ok {
+		iat = int64(math.Round(seconds))
+	} else if seconds, ok := getPath(data.session, "issued_at", "seconds").(float64); ok {
+		iat = int64(math.Round(seconds))
+	}
+
+	var sub any = ""
+	if v, ok := data.session["user_id"]; ok {
+		sub = v
+	}
+
+	var email any = ""
+	if v, ok := data.directoryUser["email"]; ok {
+		email = v
+	} else if v, ok := data.user["email"]; ok {
+		email = v
+	}
+
+	var groups any = []any{}
+	if v := append(append([]any{}, data.groupIDs...), getDataBrokerGroupFields(ctx, data.groupIDs, "name")...); len(v) > 0 {
+		groups = v
+	} else if v := getPath(data.session, "claims", "groups"); v != nil {
+		groups = v
+	}
+
+	var name any = ""
+	if v, ok := getHeaderStringValue(getPath(data.session, "claims", "name")); ok {
+		name = v
+	} else if v, ok := getHeaderStringValue(getPath(data.user, "claims", "name")); ok {
+		name = v
+	}
+
+	claims := jwtClaims{
+		{"iss", req.Issuer},
+		{"aud", req.Issuer},
+		{"jti", jti},
+		{"exp", exp},
+		{"iat", iat},
+		{"sub", sub},
+		{"user", sub},
+		{"email", email},
+		{"groups", groups},
+		{"sid", req.Session.ID},
+		{"name", name},
+	}
+	for _, v := range []any{jti, sub, email, groups} {
+		if !isStringOrStrings(v) {
+			return nil, errHeadersNeedRego
+		}
+	}
+
+	// the claim values of the additional jwt claims come from session claims or user claims
+	sessionClaims, hasSessionClaims := data.session["claims"].(map[string]any)
+	userClaims, hasUserClaims := data.user["claims"].(map[string]any)
+	if hasSessionClaims && hasUserClaims {
+		jwtClaimHeaders := e.store.GetJWTClaimHeaders()
+		for _, headerName := range sortedKeys(jwtClaimHeaders) {
+			claimKey := jwtClaimHeaders[headerName]
+			if isBaseJWTClaim(claimKey) {
+				continue
+			}
+			claimValue, ok := sessionClaims[claimKey]
+			if !ok {
+				claimValue = userClaims[claimKey]
+			}
+			if v, ok := getHeaderStringValue(claimValue); ok {
+				claims = append(claims, jwtClaim{claimKey, v})
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+func isBaseJWTClaim(key string) bool {
+	switch key {
+	case "iss", "aud", "jti", "exp", "iat", "sub", "user", "email", "groups", "sid", "name":
+		return true
+	}
+	return false
+}
+
+func getDataBrokerGroupFields(ctx context.Context, groupIDs []any, field string) []any {
+	var values []any
+	for _, id := range groupIDs {
+		groupID, ok := id.(string)
+		if !ok {
+			continue
+		}
+		group := store.GetDataBrokerRecord(ctx, "pomerium.io/DirectoryGroup", groupID)
+		if v, ok := group[field]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getKubernetesGroups returns the Impersonate-Group header value. When group mappings are set,
+// only the kubernetes groups mapped from the user's groups are used.
+func getKubernetesGroups(mappings map[string][]string, groups any) (string, bool) {
+	if len(mappings) == 0 {
+		return getHeaderStringValue(groups)
+	}
+
+	gs, ok := groups.([]any)
+	if !ok {
+		return "", false
+	}
+	set := map[string]struct{}{}
+	for _, g := range gs {
+		s, ok := g.(string)
+		if !ok {
+			return "", false
+		}
+		for _, mapped := range mappings[s] {
+			set[mapped] = struct{}{}
+		}
+	}
+	return strings.Join(sortedKeys(set), ","), true
+}
+
+// getClientCertFingerprint returns the sha256 fingerprint of the leaf client certificate, or an
+// empty string if there isn't one.
+func getClientCertFingerprint(leaf string) (string, bool) {
+	leaf = strings.TrimSpace(leaf)
+	if leaf == "" {
+		return "", true
+	}
+	block, _ := pem.Decode([]byte(leaf))
+	if block == nil {
+		return "", false
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return "", false
+	}
+	h := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(h[:]), true
+}
+
+// getHeaderStringValue joins arrays of strings with commas, like get_header_string_value in the
+// rego script. It returns false for any other value.
+func getHeaderStringValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", false
+			}
+			ss = append(ss, s)
+		}
+		return strings.Join(ss, ","), true
+	}
+	return "", false
+}
+
+// getOptionalString returns the string value of v, or an empty string if v isn't set.
+func getOptionalString(v any) (string, bool) {
+	if v == nil {
+		return "", true
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+func isStringOrStrings(v any) bool {
+	_, ok := getHeaderStringValue(v)
+	return ok
+}
+
+func getPath(obj map[string]any, path ...string) any {
+	var v any = obj
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
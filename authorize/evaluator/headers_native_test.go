@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/authorize/internal/store"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+func TestHeaderTemplate(t *testing.T) {
+	t.Parallel()
+
+	replacements := map[string]string{
+		"pomerium.id_token":     "ID_TOKEN",
+		"pomerium.access_token": "$pomerium.id_token",
+	}
+	for _, tc := range []struct {
+		value, expect string
+	}{
+		{"", ""},
+		{"CUSTOM_VALUE", "CUSTOM_VALUE"},
+		{"Bearer ${pomerium.id_token}", "Bearer ID_TOKEN"},
+		{"${pomerium.id_token},${pomerium.id_token}", "ID_TOKEN,ID_TOKEN"},
+		{"$pomerium.id_token", ".id_token"},
+		{"${pomerium.access_token}", "$pomerium.id_token"},
+		{"${unknown}", ""},
+		{"escaped $$dollar sign", "escaped $dollar sign"},
+	} {
+		template := compileHeaderTemplate(tc.value)
+		if assert.NotNil(t, template, tc.value) {
+			assert.Equal(t, tc.expect, template.execute(replacements), tc.value)
+		}
+	}
+
+	assert.Nil(t, compileHeaderTemplate("a\x00b"), "values with a NUL byte shouldn't be precompiled")
+}
+
+func TestNativeHeadersEvaluator(t *testing.T) {
+	t.Parallel()
+
+	ctx := storage.WithQuerier(context.Background(), storage.NewStaticQuerier())
+	signingKey, err := cryptutil.NewSigningKey()
+	require.NoError(t, err)
+	encodedSigningKey, err := cryptutil.EncodePrivateKey(signingKey)
+	require.NoError(t, err)
+	privateJWK, err := cryptutil.PrivateJWKFromBytes(encodedSigningKey)
+	require.NoError(t, err)
+
+	s := store.New()
+	e := &nativeHeadersEvaluator{store: s}
+
+	_, err = e.evaluate(ctx, &HeadersRequest{Issuer: "from.example.com"})
+	assert.ErrorIs(t, err, errHeadersNeedRego, "should use rego without a signing key")
+
+	s.UpdateSigningKey(privateJWK)
+	_, err = e.evaluate(ctx, &HeadersRequest{
+		Issuer:            "from.example.com",
+		SetRequestHeaders: map[string]string{"X-Fingerprint": "${pomerium.client_cert_fingerprint}"},
+		ClientCertificate: ClientCertificateInfo{Leaf: "--- FAKE CERTIFICATE ---"},
+	})
+	assert.ErrorIs(t, err, errHeadersNeedRego, "should use rego for client certificates which aren't PEM encoded")
+
+	h, err := e.evaluate(ctx, &HeadersRequest{
+		Issuer:            "from.example.com",
+		Session:           RequestSession{ID: "s1"},
+		EnableRoutingKey:  true,
+		SetRequestHeaders: map[string]string{"X-Custom": "VALUE"},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, h.Get("X-Pomerium-Jwt-Assertion"))
+	assert.Equal(t, "VALUE", h.Get("X-Custom"))
+	assert.Len(t, h.Get("X-Pomerium-Routing-Key"), 64)
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/health"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/trace"
+)
+
+// A lazyPolicyEvaluator creates the PolicyEvaluator of a route the first time it's needed, either
+// by a request or by the background warmup of the Evaluator.
+type lazyPolicyEvaluator struct {
+	once    sync.Once
+	create  func(ctx context.Context) (*PolicyEvaluator, error)
+	pending *atomic.Int64
+
+	evaluator *PolicyEvaluator
+	err       error
+}
+
+func newLazyPolicyEvaluator(
+	prepared *preparedQueries,
+	configPolicy *config.Policy,
+	queries []policyQuery,
+	pending *atomic.Int64,
+) *lazyPolicyEvaluator {
+	pending.Add(1)
+	return &lazyPolicyEvaluator{
+		create: func(ctx context.Context) (*PolicyEvaluator, error) {
+			return preparePolicyEvaluator(ctx, prepared, configPolicy, queries)
+		},
+		pending: pending,
+	}
+}
+
+// get returns the policy evaluator, creating it if needed. It's created with a new context rather
+// than the context of the request which needs it first, as a canceled request would otherwise fail
+// its creation for every later request.
+func (l *lazyPolicyEvaluator) get() (*PolicyEvaluator, error) {
+	l.once.Do(func() {
+		ctx, span := trace.StartSpan(context.Background(), "authorize.lazyPolicyEvaluator.create")
+		defer span.End()
+
+		l.evaluator, l.err = l.create(ctx)
+		l.create = nil
+		l.pending.Add(-1)
+	})
+	return l.evaluator, l.err
+}
+
+// discard drops a lazy policy evaluator which won't be used, so it isn't counted as pending.
+func (l *lazyPolicyEvaluator) discard() {
+	l.once.Do(func() {
+		l.create = nil
+		l.pending.Add(-1)
+	})
+}
+
+// StartWarmup stops the warmup of the Evaluator this one replaces, and creates the lazy policy
+// evaluators in the background, in configuration order, until they are all created or a new
+// Evaluator replaces this one. Errors are reported to the policy evaluator health check, so it
+// should be called once the Evaluator is in use.
+func (e *Evaluator) StartWarmup() {
+	e.warmupMu.Lock()
+	defer e.warmupMu.Unlock()
+
+	if e.replaced != nil {
+		e.replaced.stopWarmup()
+		e.replaced = nil
+	}
+
+	if len(e.warmupIDs) == 0 || e.cancelWarmup != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelWarmup = cancel
+	go e.warmup(ctx, e.warmupIDs)
+}
+
+func (e *Evaluator) warmup(ctx context.Context, ids []uint64) {
+	ctx, span := trace.StartSpan(ctx, "authorize.Evaluator.warmup")
+	defer span.End()
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := e.policyEvaluators[id].get(); err != nil {
+			log.Error(ctx).Err(err).Uint64("route-id", id).Msg("authorize: error creating policy evaluator")
+			health.Set(health.CheckPolicyEvaluator, health.Error(err))
+		}
+	}
+	log.Debug(ctx).Int("policies", len(ids)).Msg("authorize: policy evaluator warmup complete")
+}
+
+// PendingPolicyEvaluators returns the number of policy evaluators which haven't been created yet.
+func (e *Evaluator) PendingPolicyEvaluators() int64 {
+	return e.pending.Load()
+}
+
+func (e *Evaluator) stopWarmup() {
+	e.warmupMu.Lock()
+	defer e.warmupMu.Unlock()
+
+	if e.cancelWarmup != nil {
+		e.cancelWarmup()
+	}
+	// a stopped warmup isn't started again
+	e.warmupIDs = nil
+}
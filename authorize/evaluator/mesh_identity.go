@@ -0,0 +1,99 @@
+package evaluator
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// RequestMesh is the mesh field in the request. It is only set for requests from trusted service
+// mesh workloads.
+type RequestMesh struct {
+	SPIFFEID string `json:"spiffe_id"`
+}
+
+// NewRequestMesh creates a new RequestMesh from an HTTP request, using the source of mesh
+// identities in the config. Client certificates are only used once validated, and the
+// x-forwarded-client-cert header is only used when it comes from a trusted peer, so a client
+// can't set the header itself.
+func NewRequestMesh(meshIdentity *config.MeshIdentity, req RequestHTTP, isValidClientCertificate bool) RequestMesh {
+	var id string
+	switch meshIdentity.GetSource() {
+	case config.MeshIdentitySourceXFCC:
+		if req.ClientCertificate.Presented && isValidClientCertificate &&
+			meshIdentity.IsTrustedPeer(getClientCertificateSPIFFEID(req.ClientCertificate.Leaf)) {
+			id = getXFCCURI(req.Headers["X-Forwarded-Client-Cert"])
+		}
+	case config.MeshIdentitySourceClientCertificate:
+		if isValidClientCertificate {
+			id = getClientCertificateSPIFFEID(req.ClientCertificate.Leaf)
+		}
+	}
+	if !meshIdentity.IsTrustedSPIFFEID(id) {
+		return RequestMesh{}
+	}
+	return RequestMesh{SPIFFEID: id}
+}
+
+// getXFCCURI returns the URI of the last element of an x-forwarded-client-cert header, which is
+// the element added by the proxy directly in front of pomerium.
+//
+// See https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#x-forwarded-client-cert
+func getXFCCURI(xfcc string) string {
+	elements := splitQuoted(xfcc, ',')
+	if len(elements) == 0 {
+		return ""
+	}
+	for _, pair := range splitQuoted(elements[len(elements)-1], ';') {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(k, "URI") {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// splitQuoted splits s by sep, ignoring separators in double-quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// getClientCertificateSPIFFEID returns the SPIFFE id of a client certificate, which is its URI SAN
+// with the spiffe scheme.
+func getClientCertificateSPIFFEID(leaf string) string {
+	p, _ := pem.Decode([]byte(leaf))
+	if p == nil {
+		return ""
+	}
+	cert, err := x509.ParseCertificate(p.Bytes)
+	if err != nil {
+		return ""
+	}
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestNewRequestMesh(t *testing.T) {
+	t.Parallel()
+
+	xfcc := &config.MeshIdentity{
+		Source:       config.MeshIdentitySourceXFCC,
+		TrustDomains: []string{"cluster.local"},
+		TrustedPeers: []string{"spiffe://example.com/foo/bar"},
+	}
+	xfccOtherPeer := &config.MeshIdentity{
+		Source:       config.MeshIdentitySourceXFCC,
+		TrustDomains: []string{"cluster.local"},
+		TrustedPeers: []string{"spiffe://example.com/other"},
+	}
+	peer := ClientCertificateInfo{Presented: true, Leaf: testValidCertWithURISAN}
+	clientCertificate := &config.MeshIdentity{Source: config.MeshIdentitySourceClientCertificate, TrustDomains: []string{"example.com"}}
+
+	for _, tc := range []struct {
+		name         string
+		meshIdentity *config.MeshIdentity
+		req          RequestHTTP
+		valid        bool
+		expect       string
+	}{
+		{"disabled", nil, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/default/sa/client",
+		}}, false, ""},
+		{"xfcc", xfcc, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": `By=spiffe://cluster.local/ns/pomerium/sa/pomerium;Hash=abc;Subject="";URI=spiffe://cluster.local/ns/default/sa/client`,
+		}, ClientCertificate: peer}, true, "spiffe://cluster.local/ns/default/sa/client"},
+		{"xfcc last element", xfcc, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": `URI=spiffe://cluster.local/ns/a/sa/a;Subject="CN=a,O=b",By=x;URI=spiffe://cluster.local/ns/b/sa/b`,
+		}, ClientCertificate: peer}, true, "spiffe://cluster.local/ns/b/sa/b"},
+		{"xfcc untrusted domain", xfcc, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": "URI=spiffe://evil.local/ns/default/sa/client",
+		}, ClientCertificate: peer}, true, ""},
+		{"xfcc forged on plain connection", xfcc, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/default/sa/client",
+		}}, true, ""},
+		{"xfcc invalid peer certificate", xfcc, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/default/sa/client",
+		}, ClientCertificate: peer}, false, ""},
+		{"xfcc untrusted peer", xfccOtherPeer, RequestHTTP{Headers: map[string]string{
+			"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/default/sa/client",
+		}, ClientCertificate: peer}, true, ""},
+		{"client certificate", clientCertificate, RequestHTTP{
+			ClientCertificate: ClientCertificateInfo{Presented: true, Leaf: testValidCertWithURISAN},
+		}, true, "spiffe://example.com/foo/bar"},
+		{"invalid client certificate", clientCertificate, RequestHTTP{
+			ClientCertificate: ClientCertificateInfo{Presented: true, Leaf: testValidCertWithURISAN},
+		}, false, ""},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, RequestMesh{SPIFFEID: tc.expect}, NewRequestMesh(tc.meshIdentity, tc.req, tc.valid))
+		})
+	}
+}
@@ -7,6 +7,7 @@ package pomerium.headers
 #     leaf: string
 #   issuer: string
 #   kubernetes_service_account_token: string
+#   kubernetes_group_mappings: map[string][]string
 #   session:
 #     id: string
 #   to_audience: string
@@ -170,12 +171,21 @@ jwt_payload = {key: value |
 
 signed_jwt = io.jwt.encode_sign(jwt_headers, jwt_payload, data.signing_key)
 
+# when group mappings are set, only the kubernetes groups mapped from the user's groups are used
+kubernetes_groups = v {
+	mappings := input.kubernetes_group_mappings
+	is_object(mappings)
+	count(mappings) > 0
+	groups := {g | g := mappings[jwt_payload_groups[_]][_]}
+	v := sort([g | g := groups[_]])
+} else = jwt_payload_groups
+
 kubernetes_headers = h {
 	input.kubernetes_service_account_token != ""
 	h := [
 		["Authorization", concat(" ", ["Bearer", input.kubernetes_service_account_token])],
 		["Impersonate-User", jwt_payload_email],
-		["Impersonate-Group", get_header_string_value(jwt_payload_groups)],
+		["Impersonate-Group", get_header_string_value(kubernetes_groups)],
 	]
 } else = []
 
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 	octrace "go.opencensus.io/trace"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/internal/waf"
 	"github.com/pomerium/pomerium/pkg/contextutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/policy"
@@ -21,14 +24,18 @@ import (
 // PolicyRequest is the input to policy evaluation.
 type PolicyRequest struct {
 	HTTP                     RequestHTTP    `json:"http"`
+	GRPC                     RequestGRPC    `json:"grpc"`
+	Mesh                     RequestMesh    `json:"mesh"`
 	Session                  RequestSession `json:"session"`
 	IsValidClientCertificate bool           `json:"is_valid_client_certificate"`
+	WAF                      *waf.Verdict   `json:"waf,omitempty"`
 }
 
 // PolicyResponse is the result of evaluating a policy.
 type PolicyResponse struct {
 	Allow, Deny RuleResult
 	Traces      []contextutil.PolicyEvaluationTrace
+	WAF         *waf.Verdict
 }
 
 // NewPolicyResponse creates a new PolicyResponse.
@@ -111,8 +118,23 @@ func NewPolicyEvaluator(
 	ctx context.Context, store *store.Store, configPolicy *config.Policy,
 	addDefaultClientCertificateRule bool,
 ) (*PolicyEvaluator, error) {
-	e := new(PolicyEvaluator)
+	return newPolicyEvaluator(ctx, newPreparedQueries(store, nil), configPolicy, addDefaultClientCertificateRule)
+}
 
+func newPolicyEvaluator(
+	ctx context.Context, prepared *preparedQueries, configPolicy *config.Policy,
+	addDefaultClientCertificateRule bool,
+) (*PolicyEvaluator, error) {
+	queries, err := newPolicyQueries(configPolicy, addDefaultClientCertificateRule)
+	if err != nil {
+		return nil, err
+	}
+	return preparePolicyEvaluator(ctx, prepared, configPolicy, queries)
+}
+
+// newPolicyQueries returns the unprepared queries of a policy: the rego generated from its PPL,
+// followed by its custom rego.
+func newPolicyQueries(configPolicy *config.Policy, addDefaultClientCertificateRule bool) ([]policyQuery, error) {
 	// generate the base rego script for the policy
 	ppl := configPolicy.ToPPL()
 	if addDefaultClientCertificateRule {
@@ -123,7 +145,7 @@ func NewPolicyEvaluator(
 		return nil, err
 	}
 
-	e.queries = []policyQuery{{
+	queries := []policyQuery{{
 		script: base,
 	}}
 
@@ -134,7 +156,7 @@ func NewPolicyEvaluator(
 				continue
 			}
 
-			e.queries = append(e.queries, policyQuery{
+			queries = append(queries, policyQuery{
 				script:      src,
 				id:          sp.ID,
 				explanation: sp.Explanation,
@@ -142,8 +164,15 @@ func NewPolicyEvaluator(
 			})
 		}
 	}
+	return queries, nil
+}
 
-	// for each script, create a rego and prepare a query.
+func preparePolicyEvaluator(
+	ctx context.Context, prepared *preparedQueries, configPolicy *config.Policy, queries []policyQuery,
+) (*PolicyEvaluator, error) {
+	e := &PolicyEvaluator{queries: queries}
+
+	// for each script, get the prepared query
 	for i := range e.queries {
 		log.Debug(ctx).
 			Str("script", e.queries[i].script).
@@ -151,42 +180,157 @@ func NewPolicyEvaluator(
 			Interface("to", configPolicy.To).
 			Msg("authorize: rego script for policy evaluation")
 
+		q, err := prepared.get(ctx, e.queries[i].script)
+		if err != nil {
+			return nil, err
+		}
+
+		e.queries[i].PreparedEvalQuery = q
+	}
+
+	return e, nil
+}
+
+// preparedQueries prepares the queries of policy rego scripts. Compiling a script is expensive, so
+// a script used by several routes is only compiled once, and the queries of a previous evaluator
+// with the same store are reused, so that a config change only compiles the scripts of the routes
+// which were added or modified.
+type preparedQueries struct {
+	store    *store.Store
+	previous map[string]rego.PreparedEvalQuery
+
+	mu               sync.Mutex
+	current          map[string]rego.PreparedEvalQuery
+	compiled, reused int
+}
+
+func newPreparedQueries(store *store.Store, previous map[string]rego.PreparedEvalQuery) *preparedQueries {
+	return &preparedQueries{
+		store:    store,
+		previous: previous,
+		current:  make(map[string]rego.PreparedEvalQuery),
+	}
+}
+
+func (pq *preparedQueries) get(ctx context.Context, script string) (rego.PreparedEvalQuery, error) {
+	pq.mu.Lock()
+	q, ok := pq.current[script]
+	if !ok {
+		q, ok = pq.previous[script]
+		if ok {
+			pq.current[script] = q
+			pq.reused++
+		}
+	}
+	pq.mu.Unlock()
+	if ok {
+		return q, nil
+	}
+
+	// the lock isn't held while compiling, so scripts are compiled concurrently
+	q, err := prepareQuery(ctx, pq.store, script)
+	if err != nil {
+		return q, err
+	}
+
+	pq.mu.Lock()
+	pq.current[script] = q
+	pq.compiled++
+	pq.mu.Unlock()
+	return q, nil
+}
+
+// check parses and compiles a script, without preparing its query, which is much more expensive.
+// Scripts which were already prepared aren't checked again.
+func (pq *preparedQueries) check(script string) error {
+	pq.mu.Lock()
+	_, ok := pq.current[script]
+	if !ok {
+		_, ok = pq.previous[script]
+	}
+	pq.mu.Unlock()
+	if ok {
+		return nil
+	}
+	return checkPolicyScript(script)
+}
+
+// snapshot returns the prepared queries so far.
+func (pq *preparedQueries) snapshot() map[string]rego.PreparedEvalQuery {
+	pq.mu.Lock()
+	defer pq.mu.Unlock()
+
+	m := make(map[string]rego.PreparedEvalQuery, len(pq.current))
+	for script, q := range pq.current {
+		m[script] = q
+	}
+	return m
+}
+
+func prepareQuery(ctx context.Context, store *store.Store, script string) (rego.PreparedEvalQuery, error) {
+	r := rego.New(
+		rego.Store(store),
+		rego.Module("pomerium.policy", script),
+		rego.Query("result = data.pomerium.policy"),
+		getGoogleCloudServerlessHeadersRegoOption,
+		store.GetDataBrokerRecordOption(),
+	)
+
+	q, err := r.PrepareForEval(ctx)
+	// if no package is in the src, add it
+	if err != nil && strings.Contains(err.Error(), "package expected") {
 		r := rego.New(
 			rego.Store(store),
-			rego.Module("pomerium.policy", e.queries[i].script),
+			rego.Module("pomerium.policy", "package pomerium.policy\n\n"+script),
 			rego.Query("result = data.pomerium.policy"),
 			getGoogleCloudServerlessHeadersRegoOption,
 			store.GetDataBrokerRecordOption(),
 		)
+		q, err = r.PrepareForEval(ctx)
+	}
+	return q, err
+}
 
-		q, err := r.PrepareForEval(ctx)
-		// if no package is in the src, add it
-		if err != nil && strings.Contains(err.Error(), "package expected") {
-			r := rego.New(
-				rego.Store(store),
-				rego.Module("pomerium.policy", "package pomerium.policy\n\n"+e.queries[i].script),
-				rego.Query("result = data.pomerium.policy"),
-				getGoogleCloudServerlessHeadersRegoOption,
-				store.GetDataBrokerRecordOption(),
-			)
-			q, err = r.PrepareForEval(ctx)
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		e.queries[i].PreparedEvalQuery = q
+// policyCapabilities are the capabilities of the policy rego scripts, which include the custom
+// functions added when their queries are prepared.
+var policyCapabilities = func() *ast.Capabilities {
+	c := ast.CapabilitiesForThisVersion()
+	for _, f := range []*rego.Function{getGoogleCloudServerlessHeadersRegoFunction, store.GetDataBrokerRecordFunction} {
+		c.Builtins = append(c.Builtins, &ast.Builtin{Name: f.Name, Decl: f.Decl})
+	}
+	return c
+}()
+
+// checkPolicyScript parses and compiles a policy rego script, the same way as prepareQuery.
+func checkPolicyScript(script string) error {
+	module, err := ast.ParseModule("pomerium.policy", script)
+	// if no package is in the src, add it
+	if err != nil && strings.Contains(err.Error(), "package expected") {
+		module, err = ast.ParseModule("pomerium.policy", "package pomerium.policy\n\n"+script)
+	}
+	if err != nil {
+		return err
 	}
 
-	return e, nil
+	compiler := ast.NewCompiler().WithCapabilities(policyCapabilities)
+	compiler.Compile(map[string]*ast.Module{"pomerium.policy": module})
+	if compiler.Failed() {
+		return compiler.Errors
+	}
+	return nil
 }
 
 // Evaluate evaluates the policy rego scripts.
 func (e *PolicyEvaluator) Evaluate(ctx context.Context, req *PolicyRequest) (*PolicyResponse, error) {
+	input, err := req.regoValue()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error converting policy input: %w", err)
+	}
+
 	res := NewPolicyResponse()
 	// run each query and merge the results
 	for _, query := range e.queries {
-		o, err := e.evaluateQuery(ctx, req, query)
+		o, err := e.evaluateQuery(ctx, input, query)
 		if err != nil {
 			return nil, err
 		}
@@ -203,12 +347,23 @@ func (e *PolicyEvaluator) Evaluate(ctx context.Context, req *PolicyRequest) (*Po
 	return res, nil
 }
 
-func (e *PolicyEvaluator) evaluateQuery(ctx context.Context, req *PolicyRequest, query policyQuery) (*PolicyResponse, error) {
+// EvaluatePolicy evaluates a single policy for a request, without any of the other checks done by
+// the Evaluator. It's used to test policies against hypothetical requests. Databroker records are
+// loaded using the querier in the context.
+func EvaluatePolicy(ctx context.Context, configPolicy *config.Policy, req *PolicyRequest) (*PolicyResponse, error) {
+	e, err := NewPolicyEvaluator(ctx, store.New(), configPolicy, false)
+	if err != nil {
+		return nil, err
+	}
+	return e.Evaluate(ctx, req)
+}
+
+func (e *PolicyEvaluator) evaluateQuery(ctx context.Context, input ast.Value, query policyQuery) (*PolicyResponse, error) {
 	ctx, span := trace.StartSpan(ctx, "authorize.PolicyEvaluator.evaluateQuery")
 	defer span.End()
 	span.AddAttributes(octrace.StringAttribute("script_checksum", query.checksum()))
 
-	rs, err := safeEval(ctx, query.PreparedEvalQuery, rego.EvalInput(req))
+	rs, err := safeEval(ctx, query.PreparedEvalQuery, rego.EvalParsedInput(input))
 	if err != nil {
 		return nil, fmt.Errorf("authorize: error evaluating policy.rego: %w", err)
 	}
@@ -353,3 +353,28 @@ func TestPolicyEvaluator(t *testing.T) {
 		}, output)
 	})
 }
+
+func TestEvaluatePolicy(t *testing.T) {
+	ctx := storage.WithQuerier(context.Background(), storage.NewStaticQuerier(
+		&session.Session{Id: "s1", UserId: "u1"},
+		&user.User{Id: "u1", Email: "u1@example.com"},
+	))
+	p := &config.Policy{
+		From:         "https://from.example.com",
+		To:           config.WeightedURLs{{URL: *mustParseURL("https://to.example.com")}},
+		AllowedUsers: []string{"u1@example.com"},
+	}
+
+	res, err := EvaluatePolicy(ctx, p, &PolicyRequest{
+		HTTP:    RequestHTTP{Method: http.MethodGet, URL: "https://from.example.com/path"},
+		Session: RequestSession{ID: "s1"},
+	})
+	require.NoError(t, err)
+	assert.True(t, res.Allow.Value)
+
+	res, err = EvaluatePolicy(ctx, p, &PolicyRequest{
+		HTTP: RequestHTTP{Method: http.MethodGet, URL: "https://from.example.com/path"},
+	})
+	require.NoError(t, err)
+	assert.False(t, res.Allow.Value)
+}
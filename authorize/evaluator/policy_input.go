@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+)
+
+// regoValue returns the request as the input of the policy rego scripts. rego.EvalInput converts
+// its input by encoding it as JSON and decoding it again, so the value is built directly instead.
+// It must be the same as the JSON encoding of the request.
+func (req *PolicyRequest) regoValue() (ast.Value, error) {
+	input := ast.NewObject(
+		ast.Item(ast.StringTerm("http"), ast.NewTerm(req.HTTP.regoValue())),
+		ast.Item(ast.StringTerm("grpc"), ast.ObjectTerm(
+			ast.Item(ast.StringTerm("service"), ast.StringTerm(req.GRPC.Service)),
+			ast.Item(ast.StringTerm("method"), ast.StringTerm(req.GRPC.Method)),
+		)),
+		ast.Item(ast.StringTerm("mesh"), ast.ObjectTerm(
+			ast.Item(ast.StringTerm("spiffe_id"), ast.StringTerm(req.Mesh.SPIFFEID)),
+		)),
+		ast.Item(ast.StringTerm("session"), ast.ObjectTerm(
+			ast.Item(ast.StringTerm("id"), ast.StringTerm(req.Session.ID)),
+		)),
+		ast.Item(ast.StringTerm("is_valid_client_certificate"), ast.BooleanTerm(req.IsValidClientCertificate)),
+	)
+
+	// the waf verdict is rarely set, so it's converted as usual
+	if req.WAF != nil {
+		v, err := ast.InterfaceToValue(req.WAF)
+		if err != nil {
+			return nil, err
+		}
+		input.Insert(ast.StringTerm("waf"), ast.NewTerm(v))
+	}
+
+	return input, nil
+}
+
+func (req *RequestHTTP) regoValue() ast.Value {
+	var headers ast.Value = ast.Null{}
+	if req.Headers != nil {
+		items := make([][2]*ast.Term, 0, len(req.Headers))
+		for k, v := range req.Headers {
+			items = append(items, ast.Item(ast.StringTerm(k), ast.StringTerm(v)))
+		}
+		headers = ast.NewObject(items...)
+	}
+
+	clientCertificate := ast.NewObject(
+		ast.Item(ast.StringTerm("presented"), ast.BooleanTerm(req.ClientCertificate.Presented)),
+	)
+	if req.ClientCertificate.Leaf != "" {
+		clientCertificate.Insert(ast.StringTerm("leaf"), ast.StringTerm(req.ClientCertificate.Leaf))
+	}
+	if req.ClientCertificate.Intermediates != "" {
+		clientCertificate.Insert(ast.StringTerm("intermediates"), ast.StringTerm(req.ClientCertificate.Intermediates))
+	}
+
+	return ast.NewObject(
+		ast.Item(ast.StringTerm("method"), ast.StringTerm(req.Method)),
+		ast.Item(ast.StringTerm("hostname"), ast.StringTerm(req.Hostname)),
+		ast.Item(ast.StringTerm("path"), ast.StringTerm(req.Path)),
+		ast.Item(ast.StringTerm("url"), ast.StringTerm(req.URL)),
+		ast.Item(ast.StringTerm("headers"), ast.NewTerm(headers)),
+		ast.Item(ast.StringTerm("client_certificate"), ast.NewTerm(clientCertificate)),
+		ast.Item(ast.StringTerm("ip"), ast.StringTerm(req.IP)),
+	)
+}
@@ -0,0 +1,68 @@
+package evaluator
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/waf"
+)
+
+func TestPolicyRequest_regoValue(t *testing.T) {
+	t.Parallel()
+
+	for _, req := range []*PolicyRequest{
+		{},
+		{
+			HTTP: NewRequestHTTP(http.MethodPost, *mustParseURL("https://from.example.com/some/path?x=y"),
+				map[string]string{"Content-Type": "application/grpc", "X-Forwarded-For": "192.0.2.1"},
+				ClientCertificateInfo{Presented: true, Leaf: testValidCert}, "192.0.2.1"),
+			GRPC:                     RequestGRPC{Service: "pkg.Service", Method: "Method"},
+			Mesh:                     RequestMesh{SPIFFEID: "spiffe://example.com/workload"},
+			Session:                  RequestSession{ID: "SESSION_ID"},
+			IsValidClientCertificate: true,
+			WAF:                      &waf.Verdict{Interrupted: true, Status: 403, RuleID: 1, MatchedRules: []int{1, 2}},
+		},
+		{
+			HTTP: RequestHTTP{Headers: map[string]string{}, ClientCertificate: ClientCertificateInfo{Intermediates: "INTERMEDIATES"}},
+			WAF:  &waf.Verdict{},
+		},
+	} {
+		expect, err := ast.InterfaceToValue(req)
+		require.NoError(t, err)
+		actual, err := req.regoValue()
+		require.NoError(t, err)
+		assert.Equal(t, 0, expect.Compare(actual), "expected %v, got %v", expect, actual)
+	}
+}
+
+func BenchmarkPolicyRequest_regoValue(b *testing.B) {
+	req := &PolicyRequest{
+		HTTP: NewRequestHTTP(http.MethodGet, *mustParseURL("https://from.example.com/some/path"),
+			map[string]string{
+				"Accept":          "text/html",
+				"Accept-Encoding": "gzip, deflate, br",
+				"Accept-Language": "en-US,en;q=0.9",
+				"Cookie":          "_pomerium=SESSION",
+				"User-Agent":      "Mozilla/5.0",
+			},
+			ClientCertificateInfo{}, "192.0.2.1"),
+		Session: RequestSession{ID: "SESSION_ID"},
+	}
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = ast.InterfaceToValue(req)
+		}
+	})
+	b.Run("direct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = req.regoValue()
+		}
+	})
+}
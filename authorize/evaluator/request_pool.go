@@ -0,0 +1,39 @@
+package evaluator
+
+import "sync"
+
+// maxPooledHeaders is the maximum number of headers of a request put back in the pool, so that
+// a few requests with unusually many headers don't keep large maps alive.
+const maxPooledHeaders = 64
+
+var requestPool = sync.Pool{
+	New: func() any {
+		return &Request{HTTP: RequestHTTP{Headers: make(map[string]string)}}
+	},
+}
+
+// AcquireRequest returns an empty Request from a pool, with an empty map for its HTTP headers. The
+// request should be released with ReleaseRequest once it's no longer used.
+func AcquireRequest() *Request {
+	req := requestPool.Get().(*Request)
+	if req.HTTP.Headers == nil {
+		req.HTTP.Headers = make(map[string]string)
+	}
+	return req
+}
+
+// ReleaseRequest puts a Request acquired with AcquireRequest back in the pool. Neither the request
+// nor the map of its HTTP headers may be used afterwards.
+func ReleaseRequest(req *Request) {
+	headers := req.HTTP.Headers
+	if len(headers) > maxPooledHeaders {
+		headers = nil
+	}
+	for k := range headers {
+		delete(headers, k)
+	}
+
+	*req = Request{}
+	req.HTTP.Headers = headers
+	requestPool.Put(req)
+}
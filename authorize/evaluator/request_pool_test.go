@@ -0,0 +1,65 @@
+package evaluator
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPool(t *testing.T) {
+	req := AcquireRequest()
+	req.IsInternal = true
+	req.HTTP.Method = "GET"
+	req.HTTP.Headers["Accept"] = "text/html"
+	req.Session.ID = "SESSION_ID"
+	ReleaseRequest(req)
+
+	assert.Equal(t, &Request{HTTP: RequestHTTP{Headers: map[string]string{}}}, req,
+		"released requests should be reset")
+
+	req = AcquireRequest()
+	assert.NotNil(t, req.HTTP.Headers)
+	assert.Empty(t, req.HTTP.Headers)
+	for i := 0; i <= maxPooledHeaders; i++ {
+		req.HTTP.Headers[strconv.Itoa(i)] = "VALUE"
+	}
+	ReleaseRequest(req)
+	assert.Nil(t, req.HTTP.Headers, "large header maps shouldn't be pooled")
+	assert.NotNil(t, AcquireRequest().HTTP.Headers)
+}
+
+func BenchmarkRequestPool(b *testing.B) {
+	headers := map[string]string{
+		"Accept":          "text/html",
+		"Accept-Encoding": "gzip, deflate, br",
+		"Accept-Language": "en-US,en;q=0.9",
+		"Cookie":          "_pomerium=SESSION",
+		"User-Agent":      "Mozilla/5.0",
+	}
+	fill := func(req *Request) {
+		for k, v := range headers {
+			req.HTTP.Headers[k] = v
+		}
+	}
+
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := &Request{HTTP: RequestHTTP{Headers: make(map[string]string)}}
+			fill(req)
+			benchmarkRequestSink = req
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			req := AcquireRequest()
+			fill(req)
+			benchmarkRequestSink = req
+			ReleaseRequest(req)
+		}
+	})
+}
+
+var benchmarkRequestSink *Request
@@ -0,0 +1,26 @@
+package authorize
+
+import (
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// experimentHeader is sent upstream with the experiment variants the user is assigned to.
+const experimentHeader = "X-Pomerium-Experiment"
+
+// addExperimentHeader adds the experiment variants of the user to allowed requests to routes with
+// experiments. Anonymous users aren't assigned to variants, and the header is removed from their
+// requests so clients can't choose a variant themselves.
+func addExperimentHeader(resp *envoy_service_auth_v3.CheckResponse, policy *config.Policy, userID string) {
+	ok := resp.GetOkResponse()
+	if ok == nil || policy == nil || len(policy.Experiments) == 0 {
+		return
+	}
+
+	if value := policy.GetExperimentHeader(userID); userID != "" && value != "" {
+		ok.Headers = append(ok.Headers, mkHeader(experimentHeader, value))
+		return
+	}
+	ok.HeadersToRemove = append(ok.HeadersToRemove, experimentHeader)
+}
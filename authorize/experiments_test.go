@@ -0,0 +1,34 @@
+package authorize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestAddExperimentHeader(t *testing.T) {
+	t.Parallel()
+
+	a := &Authorize{}
+	policy := &config.Policy{Experiments: []config.PolicyExperiment{
+		{Name: "search", Variants: []config.PolicyExperimentVariant{{Name: "new"}}},
+	}}
+
+	resp := a.okResponse(nil)
+	addExperimentHeader(resp, policy, "user-1")
+	assert.Equal(t, "X-Pomerium-Experiment", resp.GetOkResponse().GetHeaders()[0].GetHeader().GetKey())
+	assert.Equal(t, "search=new", resp.GetOkResponse().GetHeaders()[0].GetHeader().GetValue())
+
+	resp = a.okResponse(nil)
+	addExperimentHeader(resp, policy, "")
+	assert.Empty(t, resp.GetOkResponse().GetHeaders())
+	assert.Equal(t, []string{"X-Pomerium-Experiment"}, resp.GetOkResponse().GetHeadersToRemove(),
+		"should remove the header from anonymous requests")
+
+	resp = a.okResponse(nil)
+	addExperimentHeader(resp, &config.Policy{}, "user-1")
+	assert.Empty(t, resp.GetOkResponse().GetHeaders())
+	assert.Empty(t, resp.GetOkResponse().GetHeadersToRemove())
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	"google.golang.org/protobuf/types/known/structpb"
@@ -17,10 +18,12 @@ import (
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/contextutil"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
 	"github.com/pomerium/pomerium/pkg/grpc/user"
 	"github.com/pomerium/pomerium/pkg/storage"
 )
@@ -30,13 +33,26 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v3.CheckRe
 	ctx, span := trace.StartSpan(ctx, "authorize.grpc.Check")
 	defer span.End()
 
+	return a.check(ctx, in, storage.NewLocalCache())
+}
+
+// check authorizes a request. Records are cached in the local cache, which is only shared by the
+// requests of a batch.
+func (a *Authorize) check(
+	ctx context.Context,
+	in *envoy_service_auth_v3.CheckRequest,
+	localCache storage.Cache,
+) (*envoy_service_auth_v3.CheckResponse, error) {
 	querier := storage.NewTracingQuerier(
-		storage.NewCachingQuerier(
+		newReplicaQuerier(
+			a.replicas,
 			storage.NewCachingQuerier(
-				storage.NewQuerier(a.state.Load().dataBrokerClient),
-				a.globalCache,
+				storage.NewCachingQuerier(
+					storage.NewQuerier(a.state.Load().dataBrokerClient),
+					a.globalCache,
+				),
+				localCache,
 			),
-			storage.NewLocalCache(),
 		),
 	)
 	ctx = storage.WithQuerier(ctx, querier)
@@ -47,13 +63,49 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v3.CheckRe
 	hreq := getHTTPRequestFromCheckRequest(in)
 	ctx = requestid.WithValue(ctx, requestid.FromHTTPHeader(hreq.Header))
 
+	// request limits are enforced before loading the session or evaluating the policy
+	policy := a.getMatchingPolicy(envoyconfig.ExtAuthzContextExtensionsRouteID(in.GetAttributes().GetContextExtensions()))
+	limit, code, exceeded := getExceededRequestLimit(policy, in.GetAttributes().GetRequest().GetHttp())
+	if exceeded {
+		metrics.RecordRequestLimitExceeded(ctx, hreq.Host, limit)
+		return a.deniedResponse(ctx, in, code, httputil.DetailsText(int(code)), nil)
+	}
+	if !checkCSRF(policy, hreq) {
+		log.Info(ctx).Str("method", hreq.Method).Msg("rejecting request which failed csrf protection")
+		return a.deniedResponse(ctx, in, http.StatusForbidden, "CSRF check failed", nil)
+	}
+
+	// break-glass credentials work without the identity provider, so they're checked before loading
+	// the session. The route's policy is skipped, but the ip lists and the waf are still enforced.
+	clientCertMetadata := in.GetAttributes().GetMetadataContext().GetFilterMetadata()["com.pomerium.client-certificate-info"]
+	if credential := getBreakGlassCredential(a.currentOptions.Load(), policy,
+		getClientCertificateInfo(ctx, clientCertMetadata), time.Now()); credential != nil {
+		return a.checkBreakGlass(ctx, in, hreq, policy, credential)
+	}
+
 	sessionState, _ := state.sessionStore.LoadSessionState(hreq)
+	if sessionState != nil && !sessionState.AllowsRoute(hreq.URL.Hostname()) {
+		log.Info(ctx).Str("session-id", sessionState.ID).Msg("clearing session restricted to other routes")
+		sessionState = nil
+	}
+	if sessionState != nil && sessionState.IsExpired(time.Now()) {
+		log.Info(ctx).Str("session-id", sessionState.ID).Msg("clearing expired session token")
+		sessionState = nil
+	}
 
 	var s sessionOrServiceAccount
 	var u *user.User
 	var err error
 	if sessionState != nil {
-		s, err = a.getDataBrokerSessionOrServiceAccount(ctx, sessionState.ID, sessionState.DatabrokerRecordVersion)
+		recordVersion := sessionState.DatabrokerRecordVersion
+		if a.currentOptions.Load().StatelessSessions && len(sessionState.StatelessRecords) > 0 {
+			ctx, err = withStatelessRecords(ctx, state.sharedCipher, sessionState)
+			// the records don't come from the databroker, so there's no record version to wait for
+			recordVersion = 0
+		}
+		if err == nil {
+			s, err = a.getDataBrokerSessionOrServiceAccount(ctx, sessionState.ID, recordVersion)
+		}
 		if err != nil {
 			log.Warn(ctx).Err(err).Msg("clearing session due to missing or invalid session or service account")
 			sessionState = nil
@@ -62,12 +114,20 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v3.CheckRe
 	if sessionState != nil && s != nil {
 		u, _ = a.getDataBrokerUser(ctx, s.GetUserId()) // ignore any missing user error
 	}
+	if sess, ok := s.(*session.Session); ok && sessionState != nil &&
+		a.revocations.IsRevoked(sess, u, sessionState.IdentityProviderID) {
+		log.Info(ctx).Str("session-id", sess.GetId()).Msg("clearing revoked session")
+		sessionState = nil
+		s, u = nil, nil
+	}
 
 	req, err := a.getEvaluatorRequestFromCheckRequest(ctx, in, sessionState)
 	if err != nil {
 		log.Warn(ctx).Err(err).Msg("error building evaluator request")
 		return nil, err
 	}
+	// the request is only used while handling the check, so it's reused for other checks
+	defer evaluator.ReleaseRequest(req)
 
 	// take the state lock here so we don't update while evaluating
 	a.stateLock.RLock()
@@ -83,14 +143,73 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v3.CheckRe
 		ctx = contextutil.WithPolicyEvaluationTraces(ctx, res.Traces)
 	}
 
+	// issue the double submit cookie with the first allowed response
+	if cookie := getCSRFCookie(policy, hreq); cookie != nil && res.Allow.Value && !res.Deny.Value {
+		if res.Headers == nil {
+			res.Headers = make(http.Header)
+		}
+		res.Headers.Set(csrfSetCookieHeader, cookie.String())
+	}
+
 	resp, err := a.handleResult(ctx, in, req, res)
 	if err != nil {
 		log.Error(ctx).Err(err).Str("request-id", requestid.FromContext(ctx)).Msg("grpc check ext_authz_error")
 	}
+	if sess, ok := s.(*session.Session); ok && sess.Validate() != nil {
+		addDegradedSessionHeader(resp)
+	}
+	var userID string
+	if s != nil {
+		userID = s.GetUserId()
+	}
+	addExperimentHeader(resp, policy, userID)
 	a.logAuthorizeCheck(ctx, in, resp, res, s, u)
+	a.trackAccessDecision(hreq, req, res, s)
+	a.publishDecision(ctx, hreq, in, req, res, s, u)
+	a.anomalies.observe(in, req, res, s, u)
 	return resp, err
 }
 
+// degradedHeader is added to the responses of requests made with a session accepted during the
+// identity provider outage grace period, so applications can warn their users.
+const degradedHeader = "X-Pomerium-Degraded"
+
+func addDegradedSessionHeader(resp *envoy_service_auth_v3.CheckResponse) {
+	ok := resp.GetOkResponse()
+	if ok == nil {
+		return
+	}
+	ok.ResponseHeadersToAdd = append(ok.ResponseHeadersToAdd, mkHeader(degradedHeader, "idp-unreachable"))
+}
+
+// trackAccessDecision records the access decision so users can review it on their dashboard.
+func (a *Authorize) trackAccessDecision(
+	hreq *http.Request,
+	req *evaluator.Request,
+	res *evaluator.Result,
+	s sessionOrServiceAccount,
+) {
+	if req.IsInternal || s == nil || s.GetUserId() == "" {
+		return
+	}
+
+	// query strings may contain secrets, so they are not stored
+	u := *hreq.URL
+	u.RawQuery = ""
+
+	decision := user.AccessDecision{
+		Time:    time.Now(),
+		Method:  hreq.Method,
+		URL:     u.String(),
+		Allow:   res.Allow.Value && !res.Deny.Value,
+		Reasons: res.Allow.Reasons.Strings(),
+	}
+	if res.Deny.Value {
+		decision.Reasons = res.Deny.Reasons.Strings()
+	}
+	a.accessTracker.TrackAccessDecision(s.GetUserId(), decision)
+}
+
 func (a *Authorize) getEvaluatorRequestFromCheckRequest(
 	ctx context.Context,
 	in *envoy_service_auth_v3.CheckRequest,
@@ -99,16 +218,15 @@ func (a *Authorize) getEvaluatorRequestFromCheckRequest(
 	requestURL := getCheckRequestURL(in)
 	attrs := in.GetAttributes()
 	clientCertMetadata := attrs.GetMetadataContext().GetFilterMetadata()["com.pomerium.client-certificate-info"]
-	req := &evaluator.Request{
-		IsInternal: envoyconfig.ExtAuthzContextExtensionsIsInternal(attrs.GetContextExtensions()),
-		HTTP: evaluator.NewRequestHTTP(
-			attrs.GetRequest().GetHttp().GetMethod(),
-			requestURL,
-			getCheckRequestHeaders(in),
-			getClientCertificateInfo(ctx, clientCertMetadata),
-			attrs.GetSource().GetAddress().GetSocketAddress().GetAddress(),
-		),
-	}
+	req := evaluator.AcquireRequest()
+	req.IsInternal = envoyconfig.ExtAuthzContextExtensionsIsInternal(attrs.GetContextExtensions())
+	req.HTTP = evaluator.NewRequestHTTP(
+		attrs.GetRequest().GetHttp().GetMethod(),
+		requestURL,
+		fillCheckRequestHeaders(req.HTTP.Headers, in),
+		getClientCertificateInfo(ctx, clientCertMetadata),
+		attrs.GetSource().GetAddress().GetSocketAddress().GetAddress(),
+	)
 	if sessionState != nil {
 		req.Session = evaluator.RequestSession{
 			ID: sessionState.ID,
@@ -121,14 +239,50 @@ func (a *Authorize) getEvaluatorRequestFromCheckRequest(
 func (a *Authorize) getMatchingPolicy(routeID uint64) *config.Policy {
 	options := a.currentOptions.Load()
 
-	for _, p := range options.GetAllPolicies() {
-		id, _ := p.RouteID()
-		if id == routeID {
-			return &p
+	routes := a.routes.Load()
+	if routes == nil || routes.options != options {
+		routes = newRouteIndex(options)
+		a.routes.Store(routes)
+	}
+	return routes.byID[routeID]
+}
+
+// A routeIndex maps the route ids of the policies of some options to the policies, so a request's
+// policy is found without hashing every policy.
+type routeIndex struct {
+	options *config.Options
+	byID    map[uint64]*config.Policy
+}
+
+func newRouteIndex(options *config.Options) *routeIndex {
+	policies := getAllPolicies(options)
+	idx := &routeIndex{
+		options: options,
+		byID:    make(map[uint64]*config.Policy, len(policies)),
+	}
+	for i := range policies {
+		id, err := policies[i].RouteID()
+		if err != nil {
+			continue
+		}
+		// the first policy with an id is used
+		if _, ok := idx.byID[id]; !ok {
+			idx.byID[id] = &policies[i]
 		}
 	}
+	return idx
+}
 
-	return nil
+// getAllPolicies returns the route policies, the forward proxy egress policies and the admin console policy.
+func getAllPolicies(options *config.Options) []config.Policy {
+	if options == nil {
+		return nil
+	}
+	policies := append(options.GetAllPolicies(), options.EgressPolicies...)
+	if p := options.GetAdminConsolePolicy(); p != nil {
+		policies = append(policies, *p)
+	}
+	return policies
 }
 
 func getHTTPRequestFromCheckRequest(req *envoy_service_auth_v3.CheckRequest) *http.Request {
@@ -149,7 +303,11 @@ func getHTTPRequestFromCheckRequest(req *envoy_service_auth_v3.CheckRequest) *ht
 }
 
 func getCheckRequestHeaders(req *envoy_service_auth_v3.CheckRequest) map[string]string {
-	hdrs := make(map[string]string)
+	return fillCheckRequestHeaders(make(map[string]string), req)
+}
+
+// fillCheckRequestHeaders adds the headers of the check request to hdrs, which is returned.
+func fillCheckRequestHeaders(hdrs map[string]string, req *envoy_service_auth_v3.CheckRequest) map[string]string {
 	ch := req.GetAttributes().GetRequest().GetHttp().GetHeaders()
 	for k, v := range ch {
 		hdrs[httputil.CanonicalHeaderKey(k)] = v
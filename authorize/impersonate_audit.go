@@ -0,0 +1,46 @@
+package authorize
+
+import (
+	"context"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+
+	auditlog "github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+// impersonateAuditCacheSize is the number of impersonating sessions remembered so impersonation is
+// only recorded in the audit log once per session rather than for every request.
+const impersonateAuditCacheSize = 10000
+
+type impersonateAuditor struct {
+	seen *lru.Cache[string, struct{}]
+}
+
+func newImpersonateAuditor() *impersonateAuditor {
+	seen, _ := lru.New[string, struct{}](impersonateAuditCacheSize) // only errors if size <= 0
+	return &impersonateAuditor{seen: seen}
+}
+
+// record records the impersonation of a session in the audit log, unless it was already recorded.
+func (ia *impersonateAuditor) record(ctx context.Context, s *session.Session, details *impersonateDetails) {
+	if ia == nil || details == nil {
+		return
+	}
+
+	key := s.GetId() + "|" + details.sessionID
+	if ok, _ := ia.seen.ContainsOrAdd(key, struct{}{}); ok {
+		return
+	}
+
+	auditlog.Record(ctx, auditlog.Event{
+		Type:      auditlog.EventImpersonate,
+		UserID:    s.GetUserId(),
+		SessionID: s.GetId(),
+		Details: map[string]string{
+			"impersonate_session_id": details.sessionID,
+			"impersonate_user_id":    details.userID,
+			"impersonate_email":      details.email,
+		},
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-jose/go-jose/v3"
@@ -27,6 +28,13 @@ import (
 // A Store stores data for the OPA rego policy evaluation.
 type Store struct {
 	opastorage.Store
+
+	// the data used by the headers evaluator is also kept as go values, so headers can be
+	// evaluated without rego
+	mu                                                sync.RWMutex
+	googleCloudServerlessAuthenticationServiceAccount string
+	jwtClaimHeaders                                   map[string]string
+	signingKey                                        *jose.JSONWebKey
 }
 
 // New creates a new Store.
@@ -39,11 +47,17 @@ func New() *Store {
 // UpdateGoogleCloudServerlessAuthenticationServiceAccount updates the google cloud serverless authentication
 // service account in the store.
 func (s *Store) UpdateGoogleCloudServerlessAuthenticationServiceAccount(serviceAccount string) {
+	s.mu.Lock()
+	s.googleCloudServerlessAuthenticationServiceAccount = serviceAccount
+	s.mu.Unlock()
 	s.write("/google_cloud_serverless_authentication_service_account", serviceAccount)
 }
 
 // UpdateJWTClaimHeaders updates the jwt claim headers in the store.
 func (s *Store) UpdateJWTClaimHeaders(jwtClaimHeaders map[string]string) {
+	s.mu.Lock()
+	s.jwtClaimHeaders = jwtClaimHeaders
+	s.mu.Unlock()
 	s.write("/jwt_claim_headers", jwtClaimHeaders)
 }
 
@@ -55,9 +69,34 @@ func (s *Store) UpdateRoutePolicies(routePolicies []config.Policy) {
 // UpdateSigningKey updates the signing key stored in the database. Signing operations
 // in rego use JWKs, so we take in that format.
 func (s *Store) UpdateSigningKey(signingKey *jose.JSONWebKey) {
+	s.mu.Lock()
+	s.signingKey = signingKey
+	s.mu.Unlock()
 	s.write("/signing_key", signingKey)
 }
 
+// GetGoogleCloudServerlessAuthenticationServiceAccount returns the google cloud serverless
+// authentication service account.
+func (s *Store) GetGoogleCloudServerlessAuthenticationServiceAccount() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.googleCloudServerlessAuthenticationServiceAccount
+}
+
+// GetJWTClaimHeaders returns the jwt claim headers.
+func (s *Store) GetJWTClaimHeaders() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jwtClaimHeaders
+}
+
+// GetSigningKey returns the signing key.
+func (s *Store) GetSigningKey() *jose.JSONWebKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signingKey
+}
+
 func (s *Store) write(rawPath string, value interface{}) {
 	ctx := context.TODO()
 	err := opastorage.Txn(ctx, s.Store, opastorage.WriteParams, func(txn opastorage.Transaction) error {
@@ -93,15 +132,18 @@ func (s *Store) writeTxn(txn opastorage.Transaction, rawPath string, value inter
 	return s.Write(context.Background(), txn, op, p, value)
 }
 
+// GetDataBrokerRecordFunction is the declaration of the get_databroker_record rego function.
+var GetDataBrokerRecordFunction = &rego.Function{
+	Name: "get_databroker_record",
+	Decl: types.NewFunction(
+		types.Args(types.S, types.S),
+		types.NewObject(nil, types.NewDynamicProperty(types.S, types.S)),
+	),
+}
+
 // GetDataBrokerRecordOption returns a function option that can retrieve databroker data.
 func (s *Store) GetDataBrokerRecordOption() func(*rego.Rego) {
-	return rego.Function2(&rego.Function{
-		Name: "get_databroker_record",
-		Decl: types.NewFunction(
-			types.Args(types.S, types.S),
-			types.NewObject(nil, types.NewDynamicProperty(types.S, types.S)),
-		),
-	}, func(bctx rego.BuiltinContext, op1 *ast.Term, op2 *ast.Term) (*ast.Term, error) {
+	return rego.Function2(GetDataBrokerRecordFunction, func(bctx rego.BuiltinContext, op1 *ast.Term, op2 *ast.Term) (*ast.Term, error) {
 		ctx, span := trace.StartSpan(bctx.Context, "rego.get_databroker_record")
 		defer span.End()
 
@@ -117,44 +159,53 @@ func (s *Store) GetDataBrokerRecordOption() func(*rego.Rego) {
 		}
 		span.AddAttributes(octrace.StringAttribute("record_id", value.String()))
 
-		req := &databroker.QueryRequest{
-			Type:  string(recordType),
-			Limit: 1,
+		obj := GetDataBrokerRecord(ctx, string(recordType), string(value))
+		if obj == nil {
+			return ast.NullTerm(), nil
 		}
-		req.SetFilterByIDOrIndex(string(value))
 
-		res, err := storage.GetQuerier(ctx).Query(ctx, req)
+		regoValue, err := ast.InterfaceToValue(obj)
 		if err != nil {
-			log.Error(ctx).Err(err).Msg("authorize/store: error retrieving record")
+			log.Error(ctx).Err(err).Msg("authorize/store: error converting object to rego")
 			return ast.NullTerm(), nil
 		}
 
-		if len(res.GetRecords()) == 0 {
-			return ast.NullTerm(), nil
-		}
+		return ast.NewTerm(regoValue), nil
+	})
+}
 
-		msg, _ := res.GetRecords()[0].GetData().UnmarshalNew()
-		if msg == nil {
-			return ast.NullTerm(), nil
-		}
+// GetDataBrokerRecord returns the record with the given type and id, or index, from the querier of
+// the context, as it's seen by rego. If there's no such record, or it has expired, nil is returned.
+func GetDataBrokerRecord(ctx context.Context, recordType, recordIDOrIndex string) map[string]interface{} {
+	req := &databroker.QueryRequest{
+		Type:  recordType,
+		Limit: 1,
+	}
+	req.SetFilterByIDOrIndex(recordIDOrIndex)
 
-		// exclude expired records
-		if hasExpiresAt, ok := msg.(interface{ GetExpiresAt() *timestamppb.Timestamp }); ok && hasExpiresAt.GetExpiresAt() != nil {
-			if hasExpiresAt.GetExpiresAt().AsTime().Before(time.Now()) {
-				return ast.NullTerm(), nil
-			}
-		}
+	res, err := storage.GetQuerier(ctx).Query(ctx, req)
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("authorize/store: error retrieving record")
+		return nil
+	}
 
-		obj := toMap(msg)
+	if len(res.GetRecords()) == 0 {
+		return nil
+	}
 
-		regoValue, err := ast.InterfaceToValue(obj)
-		if err != nil {
-			log.Error(ctx).Err(err).Msg("authorize/store: error converting object to rego")
-			return ast.NullTerm(), nil
+	msg, _ := res.GetRecords()[0].GetData().UnmarshalNew()
+	if msg == nil {
+		return nil
+	}
+
+	// exclude expired records
+	if hasExpiresAt, ok := msg.(interface{ GetExpiresAt() *timestamppb.Timestamp }); ok && hasExpiresAt.GetExpiresAt() != nil {
+		if hasExpiresAt.GetExpiresAt().AsTime().Before(time.Now()) {
+			return nil
 		}
+	}
 
-		return ast.NewTerm(regoValue), nil
-	})
+	return toMap(msg)
 }
 
 func toMap(msg proto.Message) map[string]interface{} {
@@ -2,11 +2,13 @@ package authorize
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 
 	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
 	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
 	"github.com/pomerium/pomerium/internal/log"
@@ -31,12 +33,15 @@ func (a *Authorize) logAuthorizeCheck(
 	hdrs := getCheckRequestHeaders(in)
 	impersonateDetails := a.getImpersonateDetails(ctx, s)
 
-	evt := log.Info(ctx).Str("service", "authorize")
-	fields := a.currentOptions.Load().GetAuthorizeLogFields()
+	opts := a.currentOptions.Load()
+	redactor := a.state.Load().logRedactor
+
+	evt := log.FormatLogger(ctx, opts.AuthorizeLogFormat).Info().Str("service", "authorize")
+	fields := opts.GetAuthorizeLogFields()
 	for _, field := range fields {
-		evt = populateLogEvent(ctx, field, evt, in, s, u, hdrs, impersonateDetails)
+		evt = populateLogEvent(ctx, field, evt, in, s, u, hdrs, impersonateDetails, redactor)
 	}
-	evt = log.HTTPHeaders(evt, fields, hdrs)
+	evt = log.HTTPHeaders(evt, fields, redactor.RedactHeaders(hdrs))
 
 	// result
 	if res != nil {
@@ -52,6 +57,9 @@ func (a *Authorize) logAuthorizeCheck(
 		} else {
 			evt = evt.Strs("deny-why-false", res.Deny.Reasons.Strings())
 		}
+		if res.WAF != nil {
+			evt = evt.Interface("waf", res.WAF)
+		}
 	}
 
 	evt.Msg("authorize check")
@@ -136,11 +144,13 @@ func (a *Authorize) getImpersonateDetails(
 	}
 	email := impersonatedUser.GetEmail()
 
-	return &impersonateDetails{
+	details := &impersonateDetails{
 		sessionID: sessionID,
 		userID:    userID,
 		email:     email,
 	}
+	a.impersonations.record(ctx, s.(*session.Session), details)
+	return details
 }
 
 func populateLogEvent(
@@ -152,19 +162,23 @@ func populateLogEvent(
 	u *user.User,
 	hdrs map[string]string,
 	impersonateDetails *impersonateDetails,
+	redactor *log.Redactor,
 ) *zerolog.Event {
 	path, query, _ := strings.Cut(in.GetAttributes().GetRequest().GetHttp().GetPath(), "?")
+	str := func(value string) *zerolog.Event {
+		return evt.Str(string(field), redactor.Redact(string(field), value))
+	}
 
 	switch field {
 	case log.AuthorizeLogFieldCheckRequestID:
-		return evt.Str(string(field), hdrs["X-Request-Id"])
+		return str(hdrs["X-Request-Id"])
 	case log.AuthorizeLogFieldEmail:
-		return evt.Str(string(field), u.GetEmail())
+		return str(u.GetEmail())
 	case log.AuthorizeLogFieldHost:
-		return evt.Str(string(field), in.GetAttributes().GetRequest().GetHttp().GetHost())
+		return str(in.GetAttributes().GetRequest().GetHttp().GetHost())
 	case log.AuthorizeLogFieldIDToken:
 		if s, ok := s.(*session.Session); ok {
-			evt = evt.Str(string(field), s.GetIdToken().GetRaw())
+			evt = str(s.GetIdToken().GetRaw())
 		}
 		return evt
 	case log.AuthorizeLogFieldIDTokenClaims:
@@ -178,42 +192,79 @@ func populateLogEvent(
 		return evt
 	case log.AuthorizeLogFieldImpersonateEmail:
 		if impersonateDetails != nil {
-			evt = evt.Str(string(field), impersonateDetails.email)
+			evt = str(impersonateDetails.email)
 		}
 		return evt
 	case log.AuthorizeLogFieldImpersonateSessionID:
 		if impersonateDetails != nil {
-			evt = evt.Str(string(field), impersonateDetails.sessionID)
+			evt = str(impersonateDetails.sessionID)
 		}
 		return evt
 	case log.AuthorizeLogFieldImpersonateUserID:
 		if impersonateDetails != nil {
-			evt = evt.Str(string(field), impersonateDetails.userID)
+			evt = str(impersonateDetails.userID)
 		}
 		return evt
 	case log.AuthorizeLogFieldIP:
-		return evt.Str(string(field), in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+		return str(in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
 	case log.AuthorizeLogFieldMethod:
-		return evt.Str(string(field), in.GetAttributes().GetRequest().GetHttp().GetMethod())
+		return str(in.GetAttributes().GetRequest().GetHttp().GetMethod())
 	case log.AuthorizeLogFieldPath:
-		return evt.Str(string(field), path)
+		return str(path)
 	case log.AuthorizeLogFieldQuery:
-		return evt.Str(string(field), query)
+		return str(query)
 	case log.AuthorizeLogFieldRequestID:
-		return evt.Str(string(field), requestid.FromContext(ctx))
+		return str(requestid.FromContext(ctx))
 	case log.AuthorizeLogFieldServiceAccountID:
 		if sa, ok := s.(*user.ServiceAccount); ok {
-			evt = evt.Str(string(field), sa.GetId())
+			evt = str(sa.GetId())
 		}
 		return evt
 	case log.AuthorizeLogFieldSessionID:
 		if s, ok := s.(*session.Session); ok {
-			evt = evt.Str(string(field), s.GetId())
+			evt = str(s.GetId())
 		}
 		return evt
 	case log.AuthorizeLogFieldUser:
-		return evt.Str(string(field), u.GetId())
+		return str(u.GetId())
 	default:
+		if claim, ok := log.GetClaimField(field); ok {
+			values := getClaimValues(s, u, claim)
+			for i := range values {
+				values[i] = redactor.Redact(string(field), values[i])
+			}
+			switch len(values) {
+			case 0:
+			case 1:
+				evt = evt.Str(string(field), values[0])
+			default:
+				evt = evt.Strs(string(field), values)
+			}
+		}
 		return evt
 	}
 }
+
+// getClaimValues returns the values of a session claim, or of a user claim if the session doesn't
+// have the claim.
+func getClaimValues(s sessionOrServiceAccount, u *user.User, claim string) []string {
+	var lv *structpb.ListValue
+	if s, ok := s.(*session.Session); ok {
+		lv = s.GetClaims()[claim]
+	}
+	if lv == nil {
+		lv = u.GetClaims()[claim]
+	}
+
+	var values []string
+	for _, v := range lv.GetValues() {
+		switch v := v.AsInterface().(type) {
+		case string:
+			values = append(values, v)
+		default:
+			bs, _ := json.Marshal(v)
+			values = append(values, string(bs))
+		}
+	}
+	return values
+}
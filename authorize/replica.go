@@ -0,0 +1,364 @@
+package authorize
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+// A replicaSet keeps an in-memory replica of the databroker records of the configured record
+// types, so that authorization decisions don't wait on databroker queries.
+type replicaSet struct {
+	provider AccessTrackerProvider
+
+	settings *atomicutil.Value[*config.AuthorizeReplicaSettings]
+	replicas *atomicutil.Value[map[string]*replica]
+	updated  chan struct{}
+}
+
+func newReplicaSet(provider AccessTrackerProvider, settings *config.AuthorizeReplicaSettings) *replicaSet {
+	return &replicaSet{
+		provider: provider,
+		settings: atomicutil.NewValue(settings),
+		replicas: atomicutil.NewValue(map[string]*replica{}),
+		updated:  make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the replica set with the new settings. The replicas are only re-created if
+// the settings changed.
+func (rs *replicaSet) UpdateConfig(settings *config.AuthorizeReplicaSettings) {
+	if cmp.Equal(rs.settings.Load(), settings) {
+		return
+	}
+
+	rs.settings.Store(settings)
+	select {
+	case rs.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run syncs the replicas from the databroker.
+func (rs *replicaSet) Run(ctx context.Context) error {
+	for {
+		settings := rs.settings.Load()
+
+		replicas := map[string]*replica{}
+		if settings.IsEnabled() {
+			for _, recordType := range settings.GetRecordTypes() {
+				r := newReplica(rs.provider, recordType, settings.GetMaxStaleness())
+				metrics.AddAuthorizeReplicaCallbacks(recordType, r.staleness, r.count)
+				replicas[recordType] = r
+			}
+		}
+		rs.replicas.Store(replicas)
+
+		runCtx, runCancel := context.WithCancel(ctx)
+		eg, egCtx := errgroup.WithContext(runCtx)
+		for _, r := range replicas {
+			r := r
+			eg.Go(func() error {
+				return r.Run(egCtx, settings.GetResyncInterval())
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-rs.updated:
+		}
+		runCancel()
+		_ = eg.Wait()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// getReplica returns the replica of a record type, or nil if the record type isn't replicated.
+func (rs *replicaSet) getReplica(recordType string) *replica {
+	if rs == nil {
+		return nil
+	}
+	return rs.replicas.Load()[recordType]
+}
+
+// A replica keeps the records of a record type in memory, by following the databroker Sync stream.
+type replica struct {
+	provider     AccessTrackerProvider
+	recordType   string
+	maxStaleness time.Duration
+
+	mu sync.RWMutex
+	// records is nil until the initial sync completes
+	records map[string]*databroker.Record
+	// pending collects the records of a re-sync, so that queries see the previous records until
+	// the re-sync completes
+	pending map[string]*databroker.Record
+	// connected is true while the Sync stream is connected, otherwise the records are as of
+	// disconnectedAt
+	connected      bool
+	disconnectedAt time.Time
+}
+
+func newReplica(provider AccessTrackerProvider, recordType string, maxStaleness time.Duration) *replica {
+	return &replica{
+		provider:       provider,
+		recordType:     recordType,
+		maxStaleness:   maxStaleness,
+		disconnectedAt: time.Now(),
+	}
+}
+
+// Run syncs the replica from the databroker, fully re-syncing it every resync interval.
+func (r *replica) Run(ctx context.Context, resyncInterval time.Duration) error {
+	for {
+		syncer := databroker.NewSyncer("authorize_replica", r,
+			databroker.WithTypeURL(r.recordType))
+		syncCtx, syncCancel := context.WithTimeout(ctx, jitter(resyncInterval))
+		_ = syncer.Run(syncCtx)
+		syncCancel()
+		_ = syncer.Close()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// GetDataBrokerServiceClient returns the databroker client used to sync the replica. The client
+// tracks whether the Sync stream is connected.
+func (r *replica) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return replicaClient{DataBrokerServiceClient: r.provider.GetDataBrokerServiceClient(), r: r}
+}
+
+// ClearRecords starts a re-sync of the replica.
+func (r *replica) ClearRecords(_ context.Context) {
+	r.mu.Lock()
+	r.pending = map[string]*databroker.Record{}
+	r.mu.Unlock()
+}
+
+// UpdateRecords updates the records of the replica.
+func (r *replica) UpdateRecords(_ context.Context, _ uint64, records []*databroker.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending != nil {
+		// the initial records of a re-sync
+		for _, record := range records {
+			r.pending[record.GetId()] = record
+		}
+		r.records, r.pending = r.pending, nil
+		if !r.connected {
+			r.disconnectedAt = time.Now()
+		}
+		return
+	}
+
+	for _, record := range records {
+		if record.GetDeletedAt() != nil {
+			delete(r.records, record.GetId())
+		} else {
+			r.records[record.GetId()] = record
+		}
+	}
+}
+
+func (r *replica) setConnected(connected bool) {
+	r.mu.Lock()
+	if r.connected && !connected {
+		r.disconnectedAt = time.Now()
+	}
+	r.connected = connected
+	r.mu.Unlock()
+}
+
+// staleness returns how many seconds the replica has been out of sync with the databroker.
+func (r *replica) staleness() float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.records != nil && r.connected {
+		return 0
+	}
+	return time.Since(r.disconnectedAt).Seconds()
+}
+
+// count returns the number of records in the replica.
+func (r *replica) count() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.records))
+}
+
+// isFresh returns true if the replica was in sync with the databroker within the max staleness.
+func (r *replica) isFresh() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.records != nil && (r.connected || time.Since(r.disconnectedAt) <= r.maxStaleness)
+}
+
+// Query queries for records in the replica. Lookups by id are served from the index of records by
+// id, other queries scan all the records.
+func (r *replica) Query(in *databroker.QueryRequest) (*databroker.QueryResponse, error) {
+	expr, err := storage.FilterExpressionFromStruct(in.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := storage.RecordStreamFilterFromFilterExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	var records []*databroker.Record
+	id, byID := getFilterID(expr)
+	if byID {
+		if record, ok := r.records[id]; ok {
+			records = append(records, record)
+		}
+	}
+	// a filter by id alone has no other matches
+	if _, onlyID := expr.(storage.EqualsFilterExpression); records == nil && !(byID && onlyID) {
+		for _, record := range r.records {
+			if filter(record) {
+				records = append(records, record)
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	res := new(databroker.QueryResponse)
+	for _, record := range records {
+		if in.GetQuery() != "" && !storage.MatchAny(record.GetData(), in.GetQuery()) {
+			continue
+		}
+		res.Records = append(res.Records, record)
+	}
+	sort.Slice(res.Records, func(i, j int) bool {
+		return res.Records[i].GetId() < res.Records[j].GetId()
+	})
+
+	var total int
+	res.Records, total = databroker.ApplyOffsetAndLimit(
+		res.Records,
+		int(in.GetOffset()),
+		int(in.GetLimit()),
+	)
+	res.TotalCount = int64(total)
+	return res, nil
+}
+
+// getFilterID returns the id of a filter by id, or by id or index. Records matching the id take
+// precedence over records matching the index.
+func getFilterID(expr storage.FilterExpression) (string, bool) {
+	switch expr := expr.(type) {
+	case storage.EqualsFilterExpression:
+		if len(expr.Fields) == 1 && expr.Fields[0] == "id" {
+			return expr.Value, true
+		}
+	case storage.OrFilterExpression:
+		for _, e := range expr {
+			if id, ok := getFilterID(e); ok {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// A replicaClient tracks whether the Sync stream of a replica is connected.
+type replicaClient struct {
+	databroker.DataBrokerServiceClient
+	r *replica
+}
+
+func (c replicaClient) Sync(ctx context.Context, in *databroker.SyncRequest, opts ...grpc.CallOption) (databroker.DataBrokerService_SyncClient, error) {
+	stream, err := c.DataBrokerServiceClient.Sync(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.r.setConnected(true)
+	return replicaSyncClient{DataBrokerService_SyncClient: stream, r: c.r}, nil
+}
+
+type replicaSyncClient struct {
+	databroker.DataBrokerService_SyncClient
+	r *replica
+}
+
+func (s replicaSyncClient) Recv() (*databroker.SyncResponse, error) {
+	res, err := s.DataBrokerService_SyncClient.Recv()
+	if err != nil {
+		s.r.setConnected(false)
+	}
+	return res, err
+}
+
+// A replicaQuerier queries the replicas of the replicated record types, and the fallback querier
+// for other record types, records missing from the replicas and replicas which are stale.
+type replicaQuerier struct {
+	replicas *replicaSet
+	fallback storage.Querier
+
+	mu          sync.Mutex
+	invalidated map[string]struct{}
+}
+
+func newReplicaQuerier(replicas *replicaSet, fallback storage.Querier) storage.Querier {
+	return &replicaQuerier{
+		replicas:    replicas,
+		fallback:    fallback,
+		invalidated: map[string]struct{}{},
+	}
+}
+
+// InvalidateCache invalidates the cache of the fallback querier. The replica is bypassed for the
+// record type of the request from then on, as it may not have caught up with a newer record.
+func (q *replicaQuerier) InvalidateCache(ctx context.Context, in *databroker.QueryRequest) {
+	q.mu.Lock()
+	q.invalidated[in.GetType()] = struct{}{}
+	q.mu.Unlock()
+	q.fallback.InvalidateCache(ctx, in)
+}
+
+// Query queries for records.
+func (q *replicaQuerier) Query(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+	q.mu.Lock()
+	_, invalidated := q.invalidated[in.GetType()]
+	q.mu.Unlock()
+
+	if r := q.replicas.getReplica(in.GetType()); r != nil && !invalidated && r.isFresh() {
+		res, err := r.Query(in)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.GetRecords()) > 0 {
+			return res, nil
+		}
+	}
+
+	return q.fallback.Query(ctx, in, opts...)
+}
+
+// jitter returns the duration less a random jitter of up to 10%.
+func jitter(d time.Duration) time.Duration {
+	return d - time.Duration(rand.Int63n(int64(d)/10+1))
+}
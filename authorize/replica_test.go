@@ -0,0 +1,144 @@
+package authorize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+func TestReplica(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sessionType := grpcutil.GetTypeURL(new(session.Session))
+	newRecord := func(id, userID string, version uint64) *databroker.Record {
+		return &databroker.Record{
+			Type:    sessionType,
+			Id:      id,
+			Version: version,
+			Data:    protoutil.NewAny(&session.Session{Id: id, UserId: userID}),
+		}
+	}
+	query := func(r *replica, id string) []string {
+		req := &databroker.QueryRequest{Type: sessionType, Limit: 1}
+		req.SetFilterByIDOrIndex(id)
+		res, err := r.Query(req)
+		require.NoError(t, err)
+		var ids []string
+		for _, record := range res.GetRecords() {
+			ids = append(ids, record.GetId())
+		}
+		return ids
+	}
+
+	r := newReplica(nil, sessionType, time.Minute)
+	assert.False(t, r.isFresh(), "should not be fresh before the initial sync")
+
+	r.ClearRecords(ctx)
+	r.UpdateRecords(ctx, 1, []*databroker.Record{
+		newRecord("s1", "u1", 1),
+		newRecord("s2", "u2", 2),
+	})
+	assert.True(t, r.isFresh())
+	assert.Equal(t, int64(2), r.count())
+	assert.Equal(t, []string{"s1"}, query(r, "s1"))
+	assert.Nil(t, query(r, "s3"))
+
+	r.UpdateRecords(ctx, 1, []*databroker.Record{
+		newRecord("s3", "u3", 3),
+		{Type: sessionType, Id: "s1", Version: 4, DeletedAt: timestamppb.Now()},
+	})
+	assert.Nil(t, query(r, "s1"))
+	assert.Equal(t, []string{"s3"}, query(r, "s3"))
+
+	t.Run("query", func(t *testing.T) {
+		res, err := r.Query(&databroker.QueryRequest{Type: sessionType, Query: "u2"})
+		require.NoError(t, err)
+		if assert.Len(t, res.GetRecords(), 1) {
+			assert.Equal(t, "s2", res.GetRecords()[0].GetId())
+		}
+	})
+
+	t.Run("resync", func(t *testing.T) {
+		r.ClearRecords(ctx)
+		// the previous records are served until the re-sync completes
+		assert.Equal(t, []string{"s2"}, query(r, "s2"))
+		r.UpdateRecords(ctx, 1, []*databroker.Record{newRecord("s4", "u4", 5)})
+		assert.Nil(t, query(r, "s2"))
+		assert.Equal(t, []string{"s4"}, query(r, "s4"))
+	})
+
+	t.Run("staleness", func(t *testing.T) {
+		r.setConnected(true)
+		assert.Equal(t, float64(0), r.staleness())
+		r.setConnected(false)
+		assert.True(t, r.isFresh())
+		r.mu.Lock()
+		r.disconnectedAt = time.Now().Add(-2 * time.Minute)
+		r.mu.Unlock()
+		assert.False(t, r.isFresh())
+		assert.Greater(t, r.staleness(), float64(60))
+	})
+}
+
+func TestReplicaQuerier(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sessionType := grpcutil.GetTypeURL(new(session.Session))
+
+	rs := newReplicaSet(nil, &config.AuthorizeReplicaSettings{Enabled: true})
+	r := newReplica(nil, sessionType, time.Minute)
+	r.ClearRecords(ctx)
+	r.UpdateRecords(ctx, 1, []*databroker.Record{{
+		Type:    sessionType,
+		Id:      "s1",
+		Version: 1,
+		Data:    protoutil.NewAny(&session.Session{Id: "s1", UserId: "replica"}),
+	}})
+	rs.replicas.Store(map[string]*replica{sessionType: r})
+
+	fallback := storage.NewStaticQuerier(
+		&session.Session{Id: "s1", UserId: "databroker"},
+		&session.Session{Id: "s2", UserId: "databroker"},
+	)
+
+	getUserID := func(q storage.Querier, id string) string {
+		req := &databroker.QueryRequest{Type: sessionType, Limit: 1}
+		req.SetFilterByIDOrIndex(id)
+		res, err := q.Query(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, res.GetRecords(), 1)
+		var s session.Session
+		require.NoError(t, res.GetRecords()[0].GetData().UnmarshalTo(&s))
+		return s.GetUserId()
+	}
+
+	q := newReplicaQuerier(rs, fallback)
+	assert.Equal(t, "replica", getUserID(q, "s1"))
+	assert.Equal(t, "databroker", getUserID(q, "s2"),
+		"should query the databroker for records missing from the replica")
+
+	req := &databroker.QueryRequest{Type: sessionType}
+	req.SetFilterByIDOrIndex("s1")
+	q.InvalidateCache(ctx, req)
+	assert.Equal(t, "databroker", getUserID(q, "s1"),
+		"should query the databroker after the cache is invalidated")
+
+	r.mu.Lock()
+	r.disconnectedAt = time.Now().Add(-2 * time.Minute)
+	r.mu.Unlock()
+	assert.Equal(t, "databroker", getUserID(newReplicaQuerier(rs, fallback), "s1"),
+		"should query the databroker if the replica is stale")
+}
@@ -0,0 +1,56 @@
+package authorize
+
+import (
+	"net/http"
+	"strings"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// The request limits reported in metrics.
+const (
+	requestLimitBodyBytes    = "body_bytes"
+	requestLimitHeaders      = "headers"
+	requestLimitHeadersBytes = "headers_bytes"
+	requestLimitURLLength    = "url_length"
+)
+
+// getExceededRequestLimit returns the policy request limit exceeded by the request, if any, and
+// the status code to reject the request with.
+func getExceededRequestLimit(
+	policy *config.Policy,
+	hattrs *envoy_service_auth_v3.AttributeContext_HttpRequest,
+) (limit string, code int32, exceeded bool) {
+	if policy == nil || !policy.HasRequestLimits() {
+		return "", 0, false
+	}
+
+	if policy.MaxURLLength > 0 && len(hattrs.GetPath()) > policy.MaxURLLength {
+		return requestLimitURLLength, http.StatusRequestURITooLong, true
+	}
+
+	var headers, headersBytes int
+	for k, v := range hattrs.GetHeaders() {
+		// pseudo-headers are not counted
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		headers++
+		headersBytes += len(k) + len(v)
+	}
+	if policy.MaxRequestHeaders > 0 && headers > policy.MaxRequestHeaders {
+		return requestLimitHeaders, http.StatusRequestHeaderFieldsTooLarge, true
+	}
+	if policy.MaxRequestHeadersBytes > 0 && headersBytes > policy.MaxRequestHeadersBytes {
+		return requestLimitHeadersBytes, http.StatusRequestHeaderFieldsTooLarge, true
+	}
+
+	// the size is -1 when there is no content length
+	if policy.MaxRequestBytes > 0 && hattrs.GetSize() > policy.MaxRequestBytes {
+		return requestLimitBodyBytes, http.StatusRequestEntityTooLarge, true
+	}
+
+	return "", 0, false
+}
@@ -0,0 +1,50 @@
+package authorize
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func Test_getExceededRequestLimit(t *testing.T) {
+	t.Parallel()
+
+	policy := &config.Policy{
+		MaxRequestBytes:        1024,
+		MaxRequestHeaders:      2,
+		MaxRequestHeadersBytes: 64,
+		MaxURLLength:           32,
+	}
+	request := func(path string, size int64, headers map[string]string) *envoy_service_auth_v3.AttributeContext_HttpRequest {
+		headers[":path"] = path
+		headers[":authority"] = "from.example.com"
+		return &envoy_service_auth_v3.AttributeContext_HttpRequest{Path: path, Size: size, Headers: headers}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		policy *config.Policy
+		req    *envoy_service_auth_v3.AttributeContext_HttpRequest
+		limit  string
+		code   int32
+	}{
+		{"no policy", nil, request("/"+strings.Repeat("a", 100), -1, map[string]string{}), "", 0},
+		{"no limits", &config.Policy{}, request("/"+strings.Repeat("a", 100), -1, map[string]string{}), "", 0},
+		{"ok", policy, request("/", 1024, map[string]string{"accept": "*/*"}), "", 0},
+		{"no content length", policy, request("/", -1, map[string]string{}), "", 0},
+		{"url", policy, request("/"+strings.Repeat("a", 32), -1, map[string]string{}), requestLimitURLLength, http.StatusRequestURITooLong},
+		{"headers", policy, request("/", -1, map[string]string{"a": "1", "b": "2", "c": "3"}), requestLimitHeaders, http.StatusRequestHeaderFieldsTooLarge},
+		{"headers bytes", policy, request("/", -1, map[string]string{"cookie": strings.Repeat("a", 64)}), requestLimitHeadersBytes, http.StatusRequestHeaderFieldsTooLarge},
+		{"body", policy, request("/", 1025, map[string]string{}), requestLimitBodyBytes, http.StatusRequestEntityTooLarge},
+	} {
+		limit, code, exceeded := getExceededRequestLimit(tc.policy, tc.req)
+		assert.Equal(t, tc.limit != "", exceeded, tc.name)
+		assert.Equal(t, tc.limit, limit, tc.name)
+		assert.Equal(t, tc.code, code, tc.name)
+	}
+}
@@ -0,0 +1,98 @@
+package authorize
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+// A revocationIndex keeps the session revocations in memory, so they can be checked for every
+// request without the delay of the cached databroker queries used to load sessions.
+type revocationIndex struct {
+	provider AccessTrackerProvider
+
+	mu        sync.RWMutex
+	revokedAt map[string]time.Time
+}
+
+func newRevocationIndex(provider AccessTrackerProvider) *revocationIndex {
+	return &revocationIndex{
+		provider:  provider,
+		revokedAt: map[string]time.Time{},
+	}
+}
+
+// Run syncs the revocations from the databroker.
+func (idx *revocationIndex) Run(ctx context.Context) error {
+	syncer := databroker.NewSyncer("authorize_revocations", idx,
+		databroker.WithTypeURL(session.RevocationRecordType))
+	return syncer.Run(ctx)
+}
+
+// GetDataBrokerServiceClient returns the databroker client used to sync revocations.
+func (idx *revocationIndex) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return idx.provider.GetDataBrokerServiceClient()
+}
+
+// ClearRecords clears all the revocations.
+func (idx *revocationIndex) ClearRecords(_ context.Context) {
+	idx.mu.Lock()
+	idx.revokedAt = map[string]time.Time{}
+	idx.mu.Unlock()
+}
+
+// UpdateRecords updates the revocations.
+func (idx *revocationIndex) UpdateRecords(ctx context.Context, _ uint64, records []*databroker.Record) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, record := range records {
+		if record.GetDeletedAt() != nil {
+			delete(idx.revokedAt, record.GetId())
+			continue
+		}
+
+		r, err := session.RevocationFromRecord(record)
+		if err != nil {
+			log.Warn(ctx).Err(err).Str("id", record.GetId()).Msg("authorize: invalid session revocation")
+			continue
+		}
+		idx.revokedAt[record.GetId()] = r.RevokedAt
+	}
+}
+
+// IsRevoked returns true if the session was issued before a revocation of its identity provider,
+// or of the email or groups of the session or its user.
+func (idx *revocationIndex) IsRevoked(s *session.Session, u *user.User, idpID string) bool {
+	issuedAt := s.GetIssuedAt().AsTime()
+
+	ids := []string{session.RevocationID("idp", idpID)}
+	for _, claims := range []map[string]*structpb.ListValue{s.GetClaims(), u.GetClaims()} {
+		for _, v := range claims["email"].GetValues() {
+			ids = append(ids, session.RevocationID("email", v.GetStringValue()))
+		}
+		for _, v := range claims["groups"].GetValues() {
+			ids = append(ids, session.RevocationID("group", v.GetStringValue()))
+		}
+	}
+	if email := u.GetEmail(); email != "" {
+		ids = append(ids, session.RevocationID("email", email))
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for _, id := range ids {
+		if revokedAt, ok := idx.revokedAt[id]; ok && !issuedAt.After(revokedAt) {
+			return true
+		}
+	}
+	return false
+}
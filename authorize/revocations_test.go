@@ -0,0 +1,73 @@
+package authorize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+func TestRevocationIndex(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	newRecord := func(r *session.Revocation) *databroker.Record {
+		data, err := structpb.NewStruct(map[string]any{
+			"email":     r.Email,
+			"group":     r.Group,
+			"idpId":     r.IdentityProviderID,
+			"revokedAt": r.RevokedAt.Format(time.RFC3339Nano),
+		})
+		require.NoError(t, err)
+		return &databroker.Record{
+			Type: session.RevocationRecordType,
+			Id:   r.ID(),
+			Data: protoutil.NewAny(data),
+		}
+	}
+
+	idx := newRevocationIndex(nil)
+	idx.UpdateRecords(context.Background(), 0, []*databroker.Record{
+		newRecord(&session.Revocation{Email: "revoked@example.com", RevokedAt: now}),
+		newRecord(&session.Revocation{Group: "revoked-group", RevokedAt: now}),
+		newRecord(&session.Revocation{IdentityProviderID: "REVOKED_IDP", RevokedAt: now}),
+	})
+
+	newSession := func(issuedAt time.Time, claims identity.FlattenedClaims) *session.Session {
+		s := &session.Session{IssuedAt: timestamppb.New(issuedAt)}
+		s.AddClaims(claims)
+		return s
+	}
+	before, after := now.Add(-time.Minute), now.Add(time.Minute)
+
+	for _, tc := range []struct {
+		name   string
+		s      *session.Session
+		u      *user.User
+		idpID  string
+		expect bool
+	}{
+		{"not revoked", newSession(before, identity.FlattenedClaims{"email": {"user@example.com"}}), nil, "IDP", false},
+		{"email", newSession(before, identity.FlattenedClaims{"email": {"Revoked@Example.com"}}), nil, "IDP", true},
+		{"user email", newSession(before, nil), &user.User{Email: "revoked@example.com"}, "IDP", true},
+		{"group", newSession(before, identity.FlattenedClaims{"groups": {"group", "revoked-group"}}), nil, "IDP", true},
+		{"idp", newSession(before, nil), nil, "REVOKED_IDP", true},
+		{"issued after revocation", newSession(after, identity.FlattenedClaims{"email": {"revoked@example.com"}}), nil, "REVOKED_IDP", false},
+	} {
+		assert.Equal(t, tc.expect, idx.IsRevoked(tc.s, tc.u, tc.idpID), tc.name)
+	}
+
+	idx.ClearRecords(context.Background())
+	assert.False(t, idx.IsRevoked(newSession(before, nil), nil, "REVOKED_IDP"))
+}
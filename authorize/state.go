@@ -2,6 +2,7 @@ package authorize
 
 import (
 	"context"
+	"crypto/cipher"
 	"fmt"
 
 	googlegrpc "google.golang.org/grpc"
@@ -9,6 +10,9 @@ import (
 	"github.com/pomerium/pomerium/authorize/evaluator"
 	"github.com/pomerium/pomerium/authorize/internal/store"
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cidrset"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/grpc"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/hpke"
@@ -19,16 +23,23 @@ var outboundGRPCConnection = new(grpc.CachedOutboundGRPClientConn)
 
 type authorizeState struct {
 	sharedKey                  []byte
+	sharedCipher               cipher.AEAD
 	evaluator                  *evaluator.Evaluator
 	dataBrokerClientConnection *googlegrpc.ClientConn
 	dataBrokerClient           databroker.DataBrokerServiceClient
 	auditEncryptor             *protoutil.Encryptor
+	logRedactor                *log.Redactor
 	sessionStore               *config.SessionStore
 	hpkePrivateKey             *hpke.PrivateKey
 	authenticateKeyFetcher     hpke.KeyFetcher
 }
 
-func newAuthorizeStateFromConfig(cfg *config.Config, store *store.Store) (*authorizeState, error) {
+func newAuthorizeStateFromConfig(
+	cfg *config.Config,
+	store *store.Store,
+	cidrSets cidrset.Getter,
+	previous *authorizeState,
+) (*authorizeState, error) {
 	if err := validateOptions(cfg.Options); err != nil {
 		return nil, fmt.Errorf("authorize: bad options: %w", err)
 	}
@@ -37,7 +48,11 @@ func newAuthorizeStateFromConfig(cfg *config.Config, store *store.Store) (*autho
 
 	var err error
 
-	state.evaluator, err = newPolicyEvaluator(cfg.Options, store)
+	var previousEvaluator *evaluator.Evaluator
+	if previous != nil {
+		previousEvaluator = previous.evaluator
+	}
+	state.evaluator, err = newPolicyEvaluator(cfg.Options, store, cidrSets, previousEvaluator)
 	if err != nil {
 		return nil, fmt.Errorf("authorize: failed to update policy with options: %w", err)
 	}
@@ -47,6 +62,11 @@ func newAuthorizeStateFromConfig(cfg *config.Config, store *store.Store) (*autho
 		return nil, err
 	}
 
+	state.sharedCipher, err = cryptutil.NewAEADCipher(state.sharedKey)
+	if err != nil {
+		return nil, err
+	}
+
 	sharedKey, err := cfg.Options.GetSharedKey()
 	if err != nil {
 		return nil, err
@@ -72,6 +92,11 @@ func newAuthorizeStateFromConfig(cfg *config.Config, store *store.Store) (*autho
 		state.auditEncryptor = protoutil.NewEncryptor(auditKey)
 	}
 
+	state.logRedactor, err = cfg.Options.GetLogRedactor()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: invalid log redactions: %w", err)
+	}
+
 	state.sessionStore, err = config.NewSessionStore(cfg.Options)
 	if err != nil {
 		return nil, fmt.Errorf("authorize: invalid session store: %w", err)
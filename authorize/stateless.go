@@ -0,0 +1,24 @@
+package authorize
+
+import (
+	"context"
+	"crypto/cipher"
+
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+// withStatelessRecords returns a context whose querier returns the records stored in a stateless
+// session, like the session and user records, instead of fetching them from the databroker.
+// Other records are still queried from the databroker.
+func withStatelessRecords(ctx context.Context, aead cipher.AEAD, sessionState *sessions.State) (context.Context, error) {
+	msgs, err := sessionState.GetStatelessRecords(aead)
+	if err != nil {
+		return ctx, err
+	}
+
+	return storage.WithQuerier(ctx, storage.NewFallbackQuerier(
+		storage.NewStaticQuerier(msgs...),
+		storage.GetQuerier(ctx),
+	)), nil
+}
@@ -0,0 +1,60 @@
+package authorize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+func TestAuthorize_withStatelessRecords(t *testing.T) {
+	t.Parallel()
+
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(clearTimeout)
+
+	opt := config.NewDefaultOptions()
+	opt.StatelessSessions = true
+	a, err := New(&config.Config{Options: opt})
+	require.NoError(t, err)
+
+	aead, err := cryptutil.NewAEADCipher(cryptutil.NewKey())
+	require.NoError(t, err)
+
+	expiresAt := timestamppb.New(time.Now().Add(time.Hour))
+	sessionState := &sessions.State{ID: "s1"}
+	require.NoError(t, sessionState.SetStatelessRecords(aead,
+		&session.Session{Id: "s1", UserId: "u1", ExpiresAt: expiresAt},
+		&user.User{Id: "u1", Email: "user@example.com"},
+	))
+
+	// the databroker has an outdated copy of the session
+	ctx = storage.WithQuerier(ctx, storage.NewStaticQuerier(
+		&session.Session{Id: "s1", UserId: "u1", ExpiresAt: timestamppb.New(time.Now().Add(-time.Hour))},
+		&session.Session{Id: "s2", UserId: "u2", ExpiresAt: expiresAt},
+	))
+	ctx, err = withStatelessRecords(ctx, aead, sessionState)
+	require.NoError(t, err)
+
+	s, err := a.getDataBrokerSessionOrServiceAccount(ctx, "s1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", s.GetUserId())
+	assert.Empty(t, a.accessTracker.sessionAccesses, "should not track access to stateless sessions")
+
+	u, err := a.getDataBrokerUser(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", u.GetEmail())
+
+	_, err = a.getDataBrokerSessionOrServiceAccount(ctx, "s2", 0)
+	assert.NoError(t, err, "should fallback to the databroker for other records")
+}
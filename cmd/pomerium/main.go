@@ -6,10 +6,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rs/zerolog"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/decisiontail"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/version"
 	"github.com/pomerium/pomerium/pkg/cmd/pomerium"
@@ -22,6 +26,34 @@ var (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		os.Exit(runMigrateStorage(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "databroker" {
+		os.Exit(runDataBroker(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit-log" {
+		os.Exit(runVerifyAuditLog(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "tail" {
+		os.Exit(runTail(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "ssh-cert" {
+		os.Exit(runSSHCert(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "k8s-exec-credential" {
+		os.Exit(runK8sExecCredential(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "aws-lambda-authorizer" {
+		os.Exit(runAWSLambdaAuthorizer(os.Args[2:]))
+	}
+
 	flag.Parse()
 	if *versionFlag {
 		fmt.Println("pomerium:", version.FullVersion())
@@ -50,3 +82,160 @@ func run(ctx context.Context) error {
 
 	return pomerium.Run(ctx, src)
 }
+
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFile := fs.String("config", "", "Specify configuration file location")
+	format := fs.String("format", pomerium.ValidateFormatText, "Output format, either text or json")
+	_ = fs.Parse(args)
+
+	ok, err := pomerium.Validate(os.Stdout, *configFile, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pomerium validate:", err)
+		return 2
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+func runMigrateStorage(args []string) int {
+	fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	configFile := fs.String("config", "", "Specify configuration file location")
+	toType := fs.String("to-type", "", "The destination databroker storage type")
+	toConnectionString := fs.String("to-connection-string", "", "The destination databroker storage connection string")
+	_ = fs.Parse(args)
+
+	err := pomerium.MigrateStorage(context.Background(), os.Stdout, *configFile, *toType, *toConnectionString)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pomerium migrate-storage:", err)
+		return 1
+	}
+	return 0
+}
+
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "The format of the config file, either nginx, oauth2-proxy or traefik")
+	file := fs.String("file", "", "The config file to convert")
+	_ = fs.Parse(args)
+
+	if err := pomerium.MigrateConfig(os.Stdout, *from, *file); err != nil {
+		fmt.Fprintln(os.Stderr, "pomerium migrate:", err)
+		return 1
+	}
+	return 0
+}
+
+func runDataBroker(args []string) int {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fmt.Fprintln(os.Stderr, "usage: pomerium databroker export|import [flags]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("databroker "+args[0], flag.ExitOnError)
+	configFile := fs.String("config", "", "Specify configuration file location")
+	file := fs.String("file", "-", "The snapshot file, or - for stdout/stdin")
+	_ = fs.Parse(args[1:])
+
+	var err error
+	if args[0] == "export" {
+		err = pomerium.ExportDataBroker(context.Background(), os.Stderr, *configFile, *file)
+	} else {
+		err = pomerium.ImportDataBroker(context.Background(), os.Stderr, *configFile, *file)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pomerium databroker "+args[0]+":", err)
+		return 1
+	}
+	return 0
+}
+
+func runVerifyAuditLog(args []string) int {
+	fs := flag.NewFlagSet("verify-audit-log", flag.ExitOnError)
+	file := fs.String("file", "", "The audit log file")
+	_ = fs.Parse(args)
+
+	if err := pomerium.VerifyAuditLog(os.Stdout, *file); err != nil {
+		fmt.Fprintln(os.Stderr, "pomerium verify-audit-log:", err)
+		return 1
+	}
+	return 0
+}
+
+func runTail(args []string) int {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	configFile := fs.String("config", "", "Specify configuration file location")
+	route := fs.String("route", "", "Only show decisions for this route id or host")
+	user := fs.String("user", "", "Only show decisions for this user id or email")
+	denyOnly := fs.Bool("deny-only", false, "Only show denied requests")
+	format := fs.String("format", pomerium.TailFormatText, "Output format, either text or json")
+	_ = fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := pomerium.Tail(ctx, os.Stdout, *configFile, *format, decisiontail.Filter{
+		Route:    *route,
+		User:     *user,
+		DenyOnly: *denyOnly,
+	})
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "pomerium tail:", err)
+		return 1
+	}
+	return 0
+}
+
+func runSSHCert(args []string) int {
+	fs := flag.NewFlagSet("ssh-cert", flag.ExitOnError)
+	route := fs.String("route", "", "The ssh route to issue the certificate, such as bastion.example.com:22")
+	identityFile := fs.String("identity-file", "", "The private key to certify, its public key is read from the .pub file")
+	keyFile := fs.String("key-file", "", "Where to write an ephemeral private key, if no identity file is set")
+	certFile := fs.String("cert-file", "", "Where to write the certificate, by default next to the key")
+	tunnel := fs.String("tunnel", "", "A tcp route to tunnel stdin and stdout to, for use as an ssh ProxyCommand")
+	_ = fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := pomerium.SSHCert(ctx, os.Stdin, os.Stdout, *route, *identityFile, *keyFile, *certFile, *tunnel)
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "pomerium ssh-cert:", err)
+		return 1
+	}
+	return 0
+}
+
+func runK8sExecCredential(args []string) int {
+	fs := flag.NewFlagSet("k8s-exec-credential", flag.ExitOnError)
+	server := fs.String("server", "", "The kubernetes route, such as https://k8s.example.com, by default the cluster server from KUBERNETES_EXEC_INFO")
+	_ = fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := pomerium.KubernetesExecCredential(ctx, os.Stdout, *server)
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "pomerium k8s-exec-credential:", err)
+		return 1
+	}
+	return 0
+}
+
+func runAWSLambdaAuthorizer(args []string) int {
+	fs := flag.NewFlagSet("aws-lambda-authorizer", flag.ExitOnError)
+	extAuthzURL := fs.String("ext-authz-url", os.Getenv("POMERIUM_EXT_AUTHZ_URL"), "The url of pomerium's ext_authz server, such as http://pomerium.internal:9191")
+	_ = fs.Parse(args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	err := pomerium.AWSLambdaAuthorizer(ctx, *extAuthzURL)
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "pomerium aws-lambda-authorizer:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"net/url"
+)
+
+// AdminConsolePath is the path prefix of the admin console.
+const AdminConsolePath = "/.pomerium/admin/"
+
+// AdminConsoleSettings configure the admin console, which is used to browse the configured routes,
+// view and revoke sessions and test policies.
+type AdminConsoleSettings struct {
+	// Policy determines who can access the admin console. The admin console is disabled if no
+	// policy is set.
+	Policy *PPLPolicy `mapstructure:"policy" yaml:"policy,omitempty"`
+}
+
+// IsEnabled returns true if the admin console is enabled.
+func (s *AdminConsoleSettings) IsEnabled() bool {
+	return s.Policy != nil && s.Policy.Policy != nil
+}
+
+// GetAdminConsolePolicy returns the policy for the admin console route, or nil if the admin console
+// is disabled.
+//
+// The route is served on every host by the proxy service, so the from and to urls only serve to
+// give it a stable route id.
+func (o *Options) GetAdminConsolePolicy() *Policy {
+	if !o.AdminConsole.IsEnabled() {
+		return nil
+	}
+	return &Policy{
+		From:   "https://admin-console.pomerium",
+		To:     WeightedURLs{{URL: url.URL{Scheme: "https", Host: "admin-console.pomerium"}}},
+		Prefix: AdminConsolePath,
+		Policy: o.AdminConsole.Policy,
+	}
+}
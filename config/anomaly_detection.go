@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults of the anomaly detection settings.
+const (
+	DefaultAnomalyMaxTravelSpeed      = 1000 // km/h
+	DefaultAnomalyMassDenialsWindow   = time.Minute
+	DefaultAnomalyMassDenialThreshold = 20
+)
+
+// AnomalyDetectionSettings configure detecting anomalous authorization patterns. Anomalies are
+// recorded in the audit log.
+type AnomalyDetectionSettings struct {
+	// GeoIPDatabase is the path of a MaxMind GeoIP2 or GeoLite2 City database, which is used to
+	// locate client IPs.
+	GeoIPDatabase string `mapstructure:"geoip_database" yaml:"geoip_database,omitempty"`
+	// ImpossibleTravel detects users making requests from locations too far apart to travel
+	// between in the time between the requests. Requires a GeoIP database.
+	ImpossibleTravel *AnomalyImpossibleTravelSettings `mapstructure:"impossible_travel" yaml:"impossible_travel,omitempty"`
+	// NewCountry detects users making requests from a country they haven't made requests from
+	// before. Requires a GeoIP database.
+	NewCountry bool `mapstructure:"new_country" yaml:"new_country,omitempty"`
+	// MassDenials detects a sudden number of denied requests of a user or to a route.
+	MassDenials *AnomalyMassDenialsSettings `mapstructure:"mass_denials" yaml:"mass_denials,omitempty"`
+	// SetRiskScore raises the risk score of a user, stored in the databroker, when an anomaly is
+	// detected.
+	SetRiskScore bool `mapstructure:"set_risk_score" yaml:"set_risk_score,omitempty"`
+}
+
+// AnomalyImpossibleTravelSettings configure the detection of impossible travel.
+type AnomalyImpossibleTravelSettings struct {
+	// MaxSpeed is the maximum plausible travel speed in km/h. Defaults to 1000.
+	MaxSpeed float64 `mapstructure:"max_speed" yaml:"max_speed,omitempty"`
+}
+
+// AnomalyMassDenialsSettings configure the detection of mass denials.
+type AnomalyMassDenialsSettings struct {
+	// Threshold is the number of denied requests within the window which is an anomaly. Defaults
+	// to 20.
+	Threshold int `mapstructure:"threshold" yaml:"threshold,omitempty"`
+	// Window is the duration denied requests are counted over. Defaults to 1 minute.
+	Window time.Duration `mapstructure:"window" yaml:"window,omitempty"`
+}
+
+// IsEnabled returns true if any anomaly detection is enabled.
+func (s *AnomalyDetectionSettings) IsEnabled() bool {
+	return s.ImpossibleTravel != nil || s.NewCountry || s.MassDenials != nil
+}
+
+// GetMaxSpeed returns the max speed, or the default max speed if none is set.
+func (s *AnomalyImpossibleTravelSettings) GetMaxSpeed() float64 {
+	if s.MaxSpeed <= 0 {
+		return DefaultAnomalyMaxTravelSpeed
+	}
+	return s.MaxSpeed
+}
+
+// GetThreshold returns the threshold, or the default threshold if none is set.
+func (s *AnomalyMassDenialsSettings) GetThreshold() int {
+	if s.Threshold <= 0 {
+		return DefaultAnomalyMassDenialThreshold
+	}
+	return s.Threshold
+}
+
+// GetWindow returns the window, or the default window if none is set.
+func (s *AnomalyMassDenialsSettings) GetWindow() time.Duration {
+	if s.Window <= 0 {
+		return DefaultAnomalyMassDenialsWindow
+	}
+	return s.Window
+}
+
+// Validate validates the anomaly detection settings.
+func (s *AnomalyDetectionSettings) Validate() error {
+	if (s.ImpossibleTravel != nil || s.NewCountry) && s.GeoIPDatabase == "" {
+		return fmt.Errorf("config: anomaly_detection impossible_travel and new_country require a geoip_database")
+	}
+	if s.ImpossibleTravel != nil && s.ImpossibleTravel.MaxSpeed < 0 {
+		return fmt.Errorf("config: anomaly_detection impossible_travel max_speed must not be negative")
+	}
+	if s.MassDenials != nil && (s.MassDenials.Threshold < 0 || s.MassDenials.Window < 0) {
+		return fmt.Errorf("config: anomaly_detection mass_denials threshold and window must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyDetectionSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings AnomalyDetectionSettings
+		valid    bool
+	}{
+		{"disabled", AnomalyDetectionSettings{}, true},
+		{"valid", AnomalyDetectionSettings{
+			GeoIPDatabase:    "GeoLite2-City.mmdb",
+			ImpossibleTravel: &AnomalyImpossibleTravelSettings{MaxSpeed: 800},
+			NewCountry:       true,
+			MassDenials:      &AnomalyMassDenialsSettings{Threshold: 10, Window: time.Minute},
+			SetRiskScore:     true,
+		}, true},
+		{"mass denials without geoip", AnomalyDetectionSettings{MassDenials: &AnomalyMassDenialsSettings{}}, true},
+		{"impossible travel without geoip", AnomalyDetectionSettings{ImpossibleTravel: &AnomalyImpossibleTravelSettings{}}, false},
+		{"new country without geoip", AnomalyDetectionSettings{NewCountry: true}, false},
+		{"negative max speed", AnomalyDetectionSettings{
+			GeoIPDatabase:    "GeoLite2-City.mmdb",
+			ImpossibleTravel: &AnomalyImpossibleTravelSettings{MaxSpeed: -1},
+		}, false},
+		{"negative threshold", AnomalyDetectionSettings{MassDenials: &AnomalyMassDenialsSettings{Threshold: -1}}, false},
+		{"negative window", AnomalyDetectionSettings{MassDenials: &AnomalyMassDenialsSettings{Window: -time.Minute}}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/syslog"
+)
+
+// AuditLogSettings configure the audit log, which records sign-ins, sign-outs, impersonations,
+// device enrollments, session revocations, config reloads and detected anomalies. Entries are
+// hash-chained, so tampering with them can be detected.
+type AuditLogSettings struct {
+	// File is the path of a file the entries are appended to as newline delimited JSON.
+	File string `mapstructure:"file" yaml:"file,omitempty"`
+	// Syslog writes the entries to syslog.
+	Syslog *AuditLogSyslogSettings `mapstructure:"syslog" yaml:"syslog,omitempty"`
+	// HTTPURL receives a POST request with each entry as JSON.
+	HTTPURL string `mapstructure:"http_url" yaml:"http_url,omitempty"`
+	// HTTPHeaders are additional headers set on the requests to the http url, such as an
+	// authorization header.
+	HTTPHeaders map[string]string `mapstructure:"http_headers" yaml:"http_headers,omitempty"`
+}
+
+// AuditLogSyslogSettings configure writing audit log entries to syslog.
+type AuditLogSyslogSettings = SyslogSettings
+
+// IsEnabled returns true if the audit log is enabled.
+func (s *AuditLogSettings) IsEnabled() bool {
+	return s.File != "" || s.Syslog != nil || s.HTTPURL != ""
+}
+
+// Validate validates the audit log settings.
+func (s *AuditLogSettings) Validate() error {
+	if s.HTTPURL != "" {
+		u, err := url.Parse(s.HTTPURL)
+		if err != nil {
+			return fmt.Errorf("config: invalid audit_log http_url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: invalid audit_log http_url: %s", s.HTTPURL)
+		}
+	}
+	if s.Syslog != nil {
+		if err := s.Syslog.Validate(); err != nil {
+			return fmt.Errorf("config: invalid audit_log syslog: %w", err)
+		}
+		if s.Syslog.Format == log.FormatApacheCombined {
+			return fmt.Errorf("config: invalid audit_log syslog: %s is only supported for access logs", s.Syslog.Format)
+		}
+	}
+	return nil
+}
+
+func (s *AuditLogSettings) newSinks() (last *audit.Entry, sinks []audit.Sink, err error) {
+	closeSinks := func() {
+		for _, sink := range sinks {
+			_ = sink.Close()
+		}
+	}
+
+	if s.File != "" {
+		// continue the hash chain of an existing file
+		last, err = audit.ReadLastEntry(s.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink, err := audit.NewFileSink(s.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if s.Syslog != nil {
+		w, err := s.Syslog.NewWriter(syslog.FacilityAuth)
+		if err != nil {
+			closeSinks()
+			return nil, nil, err
+		}
+		sinks = append(sinks, audit.NewSyslogSink(w, s.Syslog.Format))
+	}
+	if s.HTTPURL != "" {
+		sinks = append(sinks, audit.NewHTTPSink(s.HTTPURL, s.HTTPHeaders))
+	}
+	return last, sinks, nil
+}
+
+// The AuditLogManager configures the audit log based on options, and records config reloads.
+type AuditLogManager struct {
+	mu       sync.Mutex
+	settings *AuditLogSettings
+	checksum uint64
+}
+
+// NewAuditLogManager creates a new AuditLogManager.
+func NewAuditLogManager(ctx context.Context, src Source) *AuditLogManager {
+	mgr := &AuditLogManager{}
+	src.OnConfigChange(ctx, mgr.OnConfigChange)
+	mgr.OnConfigChange(ctx, src.GetConfig())
+	return mgr
+}
+
+// Close closes the audit log manager.
+func (mgr *AuditLogManager) Close() error {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	for _, sink := range audit.SetSinks(nil) {
+		_ = sink.Close()
+	}
+	mgr.settings = nil
+	return nil
+}
+
+// OnConfigChange is called whenever configuration changes.
+func (mgr *AuditLogManager) OnConfigChange(ctx context.Context, cfg *Config) {
+	if cfg == nil || cfg.Options == nil {
+		return
+	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	settings := cfg.Options.AuditLog
+	if mgr.settings == nil || !cmp.Equal(*mgr.settings, settings) {
+		last, sinks, err := settings.newSinks()
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("config: failed to configure audit log")
+		} else {
+			for _, sink := range audit.SetSinks(last, sinks...) {
+				_ = sink.Close()
+			}
+			mgr.settings = &settings
+		}
+	}
+
+	// the first config is the initial config, not a reload
+	checksum := cfg.Checksum()
+	if mgr.checksum != 0 && mgr.checksum != checksum {
+		audit.Record(ctx, audit.Event{
+			Type: audit.EventConfigReloaded,
+			Details: map[string]string{
+				"checksum":          strconv.FormatUint(checksum, 16),
+				"previous_checksum": strconv.FormatUint(mgr.checksum, 16),
+			},
+		})
+	}
+	mgr.checksum = checksum
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+func TestAuditLogSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings AuditLogSettings
+		valid    bool
+	}{
+		{"disabled", AuditLogSettings{}, true},
+		{"file", AuditLogSettings{File: "/var/log/pomerium/audit.log"}, true},
+		{"local syslog", AuditLogSettings{Syslog: &AuditLogSyslogSettings{}}, true},
+		{"remote syslog", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Network: "udp",
+			Address: "syslog.example.com:514",
+		}}, true},
+		{"syslog network without address", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Network: "udp",
+		}}, false},
+		{"tls syslog", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Network: "tls",
+			Address: "syslog.example.com:6514",
+			Format:  log.FormatCEF,
+		}}, true},
+		{"syslog unsupported network", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Network: "unix",
+			Address: "/dev/log",
+		}}, false},
+		{"syslog ca without tls", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Network: "tcp",
+			Address: "syslog.example.com:514",
+			CAFile:  "ca.pem",
+		}}, false},
+		{"syslog apache combined", AuditLogSettings{Syslog: &AuditLogSyslogSettings{
+			Format: log.FormatApacheCombined,
+		}}, false},
+		{"http", AuditLogSettings{HTTPURL: "https://audit.example.com/events"}, true},
+		{"http without host", AuditLogSettings{HTTPURL: "https:///events"}, false},
+		{"http invalid scheme", AuditLogSettings{HTTPURL: "ftp://audit.example.com"}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
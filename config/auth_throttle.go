@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Defaults for throttling failed authentication attempts.
+const (
+	DefaultAuthThrottleMaxAttempts = 5
+	DefaultAuthThrottleBaseDelay   = time.Second
+	DefaultAuthThrottleMaxDelay    = 15 * time.Minute
+)
+
+// AuthThrottleSettings throttle failed attempts on the token, webauthn and sign in endpoints, per
+// client IP and per identity. Once the free attempts are used up, every further failure doubles
+// the time until the next attempt is allowed. Counters are kept in the databroker, so the limits
+// hold across replicas, and are forgotten once no attempt failed for the maximum delay.
+type AuthThrottleSettings struct {
+	// Enabled turns on throttling.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// MaxAttempts is the number of failed attempts allowed before attempts are delayed. Defaults
+	// to 5.
+	MaxAttempts int `mapstructure:"max_attempts" yaml:"max_attempts,omitempty"`
+	// BaseDelay is the delay after the first failed attempt beyond the free attempts. Defaults to
+	// a second.
+	BaseDelay time.Duration `mapstructure:"base_delay" yaml:"base_delay,omitempty"`
+	// MaxDelay caps the delay between attempts. Defaults to 15 minutes.
+	MaxDelay time.Duration `mapstructure:"max_delay" yaml:"max_delay,omitempty"`
+}
+
+// GetMaxAttempts returns the number of failed attempts allowed before attempts are delayed.
+func (s *AuthThrottleSettings) GetMaxAttempts() int {
+	if s.MaxAttempts <= 0 {
+		return DefaultAuthThrottleMaxAttempts
+	}
+	return s.MaxAttempts
+}
+
+// GetBaseDelay returns the delay after the first failed attempt beyond the free attempts.
+func (s *AuthThrottleSettings) GetBaseDelay() time.Duration {
+	if s.BaseDelay <= 0 {
+		return DefaultAuthThrottleBaseDelay
+	}
+	return s.BaseDelay
+}
+
+// GetMaxDelay returns the maximum delay between attempts.
+func (s *AuthThrottleSettings) GetMaxDelay() time.Duration {
+	if s.MaxDelay <= 0 {
+		return DefaultAuthThrottleMaxDelay
+	}
+	return s.MaxDelay
+}
+
+// Validate validates the throttle settings.
+func (s *AuthThrottleSettings) Validate() error {
+	if s.MaxAttempts < 0 {
+		return fmt.Errorf("config: auth_throttle max_attempts must not be negative")
+	}
+	if s.BaseDelay < 0 || s.MaxDelay < 0 {
+		return fmt.Errorf("config: auth_throttle delays must not be negative")
+	}
+	if s.GetBaseDelay() > s.GetMaxDelay() {
+		return fmt.Errorf("config: auth_throttle base_delay must not exceed max_delay")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+)
+
+// Authorize replica defaults.
+const (
+	DefaultAuthorizeReplicaResyncInterval = 10 * time.Minute
+	DefaultAuthorizeReplicaMaxStaleness   = 30 * time.Second
+)
+
+// DefaultAuthorizeReplicaRecordTypes are the record types replicated if none are configured.
+var DefaultAuthorizeReplicaRecordTypes = []string{
+	grpcutil.GetTypeURL(new(session.Session)),
+	grpcutil.GetTypeURL(new(user.User)),
+	grpcutil.GetTypeURL(new(user.ServiceAccount)),
+	"pomerium.io/DirectoryUser",
+	"pomerium.io/DirectoryGroup",
+}
+
+// AuthorizeReplicaSettings configure the in-memory replica of databroker records kept by the
+// authorize service, so that authorization decisions don't wait on the databroker.
+type AuthorizeReplicaSettings struct {
+	// Enabled enables the replica.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// RecordTypes are the record types to replicate. Defaults to sessions, users, service accounts
+	// and directory users and groups.
+	RecordTypes []string `mapstructure:"record_types" yaml:"record_types,omitempty"`
+	// ResyncInterval is how often the replica is fully re-synced with the databroker, in addition
+	// to following its changes. A random jitter of up to 10% is subtracted from the interval, so
+	// that authorize instances don't re-sync at the same time. Defaults to 10 minutes.
+	ResyncInterval time.Duration `mapstructure:"resync_interval" yaml:"resync_interval,omitempty"`
+	// MaxStaleness is how long a replica may be disconnected from the databroker before queries
+	// go to the databroker instead. Defaults to 30 seconds.
+	MaxStaleness time.Duration `mapstructure:"max_staleness" yaml:"max_staleness,omitempty"`
+}
+
+// IsEnabled returns true if the authorize replica is enabled.
+func (s *AuthorizeReplicaSettings) IsEnabled() bool {
+	return s.Enabled
+}
+
+// GetRecordTypes returns the record types to replicate, or the default record types if none are set.
+func (s *AuthorizeReplicaSettings) GetRecordTypes() []string {
+	if len(s.RecordTypes) == 0 {
+		return DefaultAuthorizeReplicaRecordTypes
+	}
+	return s.RecordTypes
+}
+
+// GetResyncInterval returns the resync interval, or the default interval if none is set.
+func (s *AuthorizeReplicaSettings) GetResyncInterval() time.Duration {
+	if s.ResyncInterval <= 0 {
+		return DefaultAuthorizeReplicaResyncInterval
+	}
+	return s.ResyncInterval
+}
+
+// GetMaxStaleness returns the max staleness, or the default max staleness if none is set.
+func (s *AuthorizeReplicaSettings) GetMaxStaleness() time.Duration {
+	if s.MaxStaleness <= 0 {
+		return DefaultAuthorizeReplicaMaxStaleness
+	}
+	return s.MaxStaleness
+}
+
+// Validate validates the authorize replica settings.
+func (s *AuthorizeReplicaSettings) Validate() error {
+	if s.ResyncInterval < 0 {
+		return fmt.Errorf("config: authorize_replica resync_interval must not be negative")
+	}
+	if s.MaxStaleness < 0 {
+		return fmt.Errorf("config: authorize_replica max_staleness must not be negative")
+	}
+	seen := map[string]struct{}{}
+	for _, recordType := range s.RecordTypes {
+		if recordType == "" {
+			return fmt.Errorf("config: authorize_replica record_types must not be empty")
+		}
+		if _, ok := seen[recordType]; ok {
+			return fmt.Errorf("config: duplicate authorize_replica record type: %s", recordType)
+		}
+		seen[recordType] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizeReplicaSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings AuthorizeReplicaSettings
+		valid    bool
+	}{
+		{"disabled", AuthorizeReplicaSettings{}, true},
+		{"defaults", AuthorizeReplicaSettings{Enabled: true}, true},
+		{"valid", AuthorizeReplicaSettings{
+			Enabled:        true,
+			RecordTypes:    []string{"type.googleapis.com/session.Session"},
+			ResyncInterval: time.Minute,
+			MaxStaleness:   5 * time.Minute,
+		}, true},
+		{"negative resync interval", AuthorizeReplicaSettings{Enabled: true, ResyncInterval: -time.Second}, false},
+		{"negative max staleness", AuthorizeReplicaSettings{Enabled: true, MaxStaleness: -time.Second}, false},
+		{"empty record type", AuthorizeReplicaSettings{Enabled: true, RecordTypes: []string{""}}, false},
+		{"duplicate record type", AuthorizeReplicaSettings{Enabled: true, RecordTypes: []string{"TYPE", "TYPE"}}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bot challenge providers.
+const (
+	BotChallengeProviderTurnstile   = "turnstile"
+	BotChallengeProviderHCaptcha    = "hcaptcha"
+	BotChallengeProviderProofOfWork = "proof_of_work"
+)
+
+// Defaults for bot challenges.
+const (
+	DefaultBotChallengeDifficulty = 18
+	DefaultBotChallengeTTL        = time.Hour
+)
+
+// BotChallengeSettings configure a bot-mitigation challenge browsers must solve before they're
+// sent to the identity provider to sign in. It slows down credential stuffing and scraping
+// against the authenticate service.
+type BotChallengeSettings struct {
+	// Provider is turnstile, hcaptcha or proof_of_work. Challenges are disabled if no provider is
+	// set.
+	Provider string `mapstructure:"provider" yaml:"provider,omitempty"`
+	// SiteKey and SecretKey are the keys of the turnstile or hcaptcha site.
+	SiteKey       string `mapstructure:"site_key" yaml:"site_key,omitempty"`
+	SecretKey     string `mapstructure:"secret_key" yaml:"secret_key,omitempty"`
+	SecretKeyFile string `mapstructure:"secret_key_file" yaml:"secret_key_file,omitempty"`
+	// Difficulty is the number of leading zero bits a proof of work must have. Every additional
+	// bit doubles the work. Defaults to 18.
+	Difficulty int `mapstructure:"difficulty" yaml:"difficulty,omitempty"`
+	// TTL is how long a browser which solved a challenge isn't challenged again. Defaults to an
+	// hour.
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl,omitempty"`
+}
+
+// IsEnabled returns true if bot challenges are enabled.
+func (s *BotChallengeSettings) IsEnabled() bool {
+	return s.Provider != ""
+}
+
+// GetDifficulty returns the proof of work difficulty.
+func (s *BotChallengeSettings) GetDifficulty() int {
+	if s.Difficulty <= 0 {
+		return DefaultBotChallengeDifficulty
+	}
+	return s.Difficulty
+}
+
+// GetTTL returns how long a solved challenge is valid.
+func (s *BotChallengeSettings) GetTTL() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultBotChallengeTTL
+	}
+	return s.TTL
+}
+
+// GetSecretKey gets the turnstile or hcaptcha secret key.
+func (s *BotChallengeSettings) GetSecretKey() (string, error) {
+	return readSecret(s.SecretKey, s.SecretKeyFile)
+}
+
+// Validate validates the bot challenge settings.
+func (s *BotChallengeSettings) Validate() error {
+	switch s.Provider {
+	case "":
+	case BotChallengeProviderTurnstile, BotChallengeProviderHCaptcha:
+		if s.SiteKey == "" || (s.SecretKey == "" && s.SecretKeyFile == "") {
+			return fmt.Errorf("config: bot_challenge %s requires site_key and secret_key", s.Provider)
+		}
+	case BotChallengeProviderProofOfWork:
+		if s.Difficulty < 0 || s.Difficulty > 32 {
+			return fmt.Errorf("config: bot_challenge difficulty must be between 0 and 32")
+		}
+	default:
+		return fmt.Errorf("config: unknown bot_challenge provider: %s", s.Provider)
+	}
+	if s.TTL < 0 {
+		return fmt.Errorf("config: bot_challenge ttl must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// BreakGlassSettings configure emergency access to routes for when the identity provider is down.
+//
+// A break-glass credential is a client certificate, issued by the downstream mTLS certificate
+// authority, whose private key is kept on a hardware token. Credentials are pinned by the hash of
+// their public key, and the certificate must also chain to the route's client CA, be unexpired and
+// be usable for client authentication. Requests presenting the certificate are allowed on routes
+// with allow_break_glass set without a session and without evaluating the route's policy, though
+// the route's ip lists and waf still apply. Every such request is recorded in the audit log.
+type BreakGlassSettings struct {
+	// Enabled enables break-glass access. Turning it off disables all the credentials.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// Credentials are the pre-provisioned break-glass credentials.
+	Credentials []BreakGlassCredential `mapstructure:"credentials" yaml:"credentials,omitempty"`
+}
+
+// A BreakGlassCredential is a client certificate which grants emergency access.
+type BreakGlassCredential struct {
+	// ID identifies the credential in the audit log.
+	ID string `mapstructure:"id" yaml:"id"`
+	// SPKIHash is the base64-encoded SHA-256 hash of the certificate's subject public key info.
+	SPKIHash string `mapstructure:"spki_hash" yaml:"spki_hash"`
+	// ExpiresAt is when the credential stops working, in RFC 3339 format. It is required so that
+	// forgotten credentials don't grant access forever.
+	ExpiresAt string `mapstructure:"expires_at" yaml:"expires_at"`
+}
+
+// Validate validates the break-glass settings.
+func (s *BreakGlassSettings) Validate() error {
+	seen := map[string]struct{}{}
+	for _, c := range s.Credentials {
+		if c.ID == "" {
+			return fmt.Errorf("config: break_glass credential id is required")
+		}
+		if _, ok := seen[c.ID]; ok {
+			return fmt.Errorf("config: duplicate break_glass credential: %s", c.ID)
+		}
+		seen[c.ID] = struct{}{}
+
+		if bs, err := base64.StdEncoding.DecodeString(c.SPKIHash); err != nil || len(bs) != sha256.Size {
+			return fmt.Errorf("config: break_glass credential %s: spki_hash must be a base64-encoded SHA-256 hash", c.ID)
+		}
+		if _, err := time.Parse(time.RFC3339, c.ExpiresAt); err != nil {
+			return fmt.Errorf("config: break_glass credential %s: invalid expires_at: %w", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetCredential returns the unexpired credential with the given SPKI hash, or nil if break-glass
+// access is disabled or there is no such credential.
+func (s *BreakGlassSettings) GetCredential(spkiHash string, now time.Time) *BreakGlassCredential {
+	if !s.Enabled || spkiHash == "" {
+		return nil
+	}
+	for i := range s.Credentials {
+		c := &s.Credentials[i]
+		if c.SPKIHash == spkiHash && now.Before(c.GetExpiresAt()) {
+			return c
+		}
+	}
+	return nil
+}
+
+// GetExpiresAt returns when the credential expires. Credentials with an invalid expiry are
+// treated as already expired.
+func (c *BreakGlassCredential) GetExpiresAt() time.Time {
+	t, err := time.Parse(time.RFC3339, c.ExpiresAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A ConfiguredCertificate is an x509 certificate referenced by the config.
+type ConfiguredCertificate struct {
+	// Source is the option the certificate was configured by, e.g. "certificate_authority".
+	Source string
+	// Route is the from URL of the route the certificate was configured by, if any.
+	Route string
+	*x509.Certificate
+}
+
+// GetConfiguredCertificates returns every x509 certificate referenced by the config:
+// server certificates, certificate authorities, downstream client CAs, the signing key,
+// the metrics and databroker storage certificates and the upstream TLS certificates of
+// routes. Certificates may be supplied inline as base64 or as files. Certificates that
+// fail to load are skipped and reported in the returned error.
+func (cfg *Config) GetConfiguredCertificates() ([]ConfiguredCertificate, error) {
+	var c configuredCertificateCollector
+	o := cfg.Options
+
+	c.addBase64(o.Cert, "certificate", "")
+	c.addFile(o.CertFile, "certificate_file", "")
+	for i, pair := range o.CertificateFiles {
+		source := fmt.Sprintf("certificates[%d]", i)
+		if raw, err := base64.StdEncoding.DecodeString(pair.CertFile); err == nil {
+			c.addPEM(raw, source, "")
+		} else {
+			c.addFile(pair.CertFile, source, "")
+		}
+	}
+	c.addTLS(cfg.AutoCertificates, "autocert", "")
+	c.addTLS(cfg.DerivedCertificates, "derived", "")
+
+	c.addBase64(o.CA, "certificate_authority", "")
+	c.addFile(o.CAFile, "certificate_authority_file", "")
+	c.addBase64(o.DownstreamMTLS.CA, "downstream_mtls.ca", "")
+	c.addFile(o.DownstreamMTLS.CAFile, "downstream_mtls.ca_file", "")
+
+	c.addBase64(o.MetricsCertificate, "metrics_certificate", "")
+	c.addFile(o.MetricsCertificateFile, "metrics_certificate_file", "")
+	c.addBase64(o.MetricsClientCA, "metrics_client_ca", "")
+	c.addFile(o.MetricsClientCAFile, "metrics_client_ca_file", "")
+	c.addFile(o.DataBrokerStorageCertFile, "databroker_storage_cert_file", "")
+	c.addFile(o.DataBrokerStorageCAFile, "databroker_storage_ca_file", "")
+
+	// signing keys are usually bare private keys, but may be bundled with a certificate
+	if signingKey, err := o.GetSigningKey(); err != nil {
+		c.errs = append(c.errs, fmt.Errorf("signing_key: %w", err))
+	} else {
+		c.addPEM(signingKey, "signing_key", "")
+	}
+
+	for _, p := range o.GetAllPolicies() {
+		route := p.From
+		c.addBase64(p.TLSCustomCA, "tls_custom_ca", route)
+		c.addFile(p.TLSCustomCAFile, "tls_custom_ca_file", route)
+		c.addBase64(p.TLSClientCert, "tls_client_cert", route)
+		c.addFile(p.TLSClientCertFile, "tls_client_cert_file", route)
+		c.addBase64(p.TLSDownstreamClientCA, "tls_downstream_client_ca", route)
+	}
+
+	return c.certs, errors.Join(c.errs...)
+}
+
+type configuredCertificateCollector struct {
+	certs []ConfiguredCertificate
+	errs  []error
+}
+
+func (c *configuredCertificateCollector) addBase64(encoded, source, route string) {
+	if encoded == "" {
+		return
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		c.addError(source, route, err)
+		return
+	}
+	c.addPEM(raw, source, route)
+}
+
+func (c *configuredCertificateCollector) addFile(file, source, route string) {
+	if file == "" {
+		return
+	}
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		c.addError(source, route, err)
+		return
+	}
+	c.addPEM(raw, source, route)
+}
+
+func (c *configuredCertificateCollector) addPEM(raw []byte, source, route string) {
+	for {
+		var block *pem.Block
+		block, raw = pem.Decode(raw)
+		if block == nil {
+			return
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			c.addError(source, route, err)
+			continue
+		}
+		c.certs = append(c.certs, ConfiguredCertificate{Source: source, Route: route, Certificate: cert})
+	}
+}
+
+func (c *configuredCertificateCollector) addTLS(certs []tls.Certificate, source, route string) {
+	for _, tlsCert := range certs {
+		if len(tlsCert.Certificate) == 0 {
+			continue
+		}
+		cert, err := x509.ParseCertificate(tlsCert.Certificate[0])
+		if err != nil {
+			c.addError(source, route, err)
+			continue
+		}
+		c.certs = append(c.certs, ConfiguredCertificate{Source: source, Route: route, Certificate: cert})
+	}
+}
+
+func (c *configuredCertificateCollector) addError(source, route string, err error) {
+	if route != "" {
+		err = fmt.Errorf("%s (%s): %w", source, route, err)
+	} else {
+		err = fmt.Errorf("%s: %w", source, err)
+	}
+	c.errs = append(c.errs, err)
+}
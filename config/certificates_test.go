@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_GetConfiguredCertificates(t *testing.T) {
+	t.Parallel()
+
+	ca, err := os.ReadFile("./testdata/ca.pem")
+	require.NoError(t, err)
+
+	o := NewDefaultOptions()
+	o.CertFile = "./testdata/example-cert.pem"
+	o.KeyFile = "./testdata/example-key.pem"
+	o.DownstreamMTLS.CA = base64.StdEncoding.EncodeToString(ca)
+	o.MetricsClientCAFile = "./testdata/missing.pem"
+	o.Policies = []Policy{{
+		From:        "https://from.example.com",
+		TLSCustomCA: base64.StdEncoding.EncodeToString(ca),
+	}}
+
+	certs, err := (&Config{Options: o}).GetConfiguredCertificates()
+	assert.ErrorContains(t, err, "metrics_client_ca_file")
+
+	type entry struct{ source, route, subject string }
+	var got []entry
+	for _, cert := range certs {
+		got = append(got, entry{cert.Source, cert.Route, cert.Subject.String()})
+	}
+	assert.Equal(t, []entry{
+		{"certificate_file", "", "O=Acme Co"},
+		{"downstream_mtls.ca", "", "CN=good-ca"},
+		{"tls_custom_ca", "https://from.example.com", "CN=good-ca"},
+	}, got)
+}
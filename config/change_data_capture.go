@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ChangeDataCaptureSettings configure publishing the changes of databroker records, such as sessions,
+// devices and directory entries, so that external systems can react to identity events.
+type ChangeDataCaptureSettings struct {
+	// WebhookURL receives a POST request with a JSON event for every change. Change data capture is
+	// disabled if no webhook url is set.
+	WebhookURL string `mapstructure:"webhook_url" yaml:"webhook_url,omitempty"`
+	// WebhookSecret is used to sign the events, the signature is sent in the X-Pomerium-Signature
+	// header as sha256=<hex encoded HMAC-SHA256 of the body>.
+	WebhookSecret     string `mapstructure:"webhook_secret" yaml:"webhook_secret,omitempty"`
+	WebhookSecretFile string `mapstructure:"webhook_secret_file" yaml:"webhook_secret_file,omitempty"`
+	// RecordTypes are the record types to publish. Defaults to sessions, users, devices, session
+	// revocations and directory users and groups.
+	RecordTypes []string `mapstructure:"record_types" yaml:"record_types,omitempty"`
+}
+
+// IsEnabled returns true if change data capture is enabled.
+func (s *ChangeDataCaptureSettings) IsEnabled() bool {
+	return s.WebhookURL != ""
+}
+
+// GetWebhookSecret returns the webhook secret, reading it from the secret file if set.
+func (s *ChangeDataCaptureSettings) GetWebhookSecret() (string, error) {
+	return readSecret(s.WebhookSecret, s.WebhookSecretFile)
+}
+
+// Validate validates the change data capture settings.
+func (s *ChangeDataCaptureSettings) Validate() error {
+	if !s.IsEnabled() {
+		return nil
+	}
+
+	u, err := url.Parse(s.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("config: invalid change_data_capture webhook_url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("config: invalid change_data_capture webhook_url: %s", s.WebhookURL)
+	}
+	if s.WebhookSecret != "" && s.WebhookSecretFile != "" {
+		return fmt.Errorf("config: change_data_capture webhook_secret and webhook_secret_file are mutually exclusive")
+	}
+	if _, err := s.GetWebhookSecret(); err != nil {
+		return fmt.Errorf("config: invalid change_data_capture webhook_secret_file: %w", err)
+	}
+	return nil
+}
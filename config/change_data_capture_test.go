@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeDataCaptureSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings ChangeDataCaptureSettings
+		valid    bool
+	}{
+		{"disabled", ChangeDataCaptureSettings{}, true},
+		{"valid", ChangeDataCaptureSettings{WebhookURL: "https://siem.example.com/events", WebhookSecret: "SECRET"}, true},
+		{"invalid url", ChangeDataCaptureSettings{WebhookURL: "siem.example.com/events"}, false},
+		{"both secrets", ChangeDataCaptureSettings{
+			WebhookURL:        "https://siem.example.com/events",
+			WebhookSecret:     "SECRET",
+			WebhookSecretFile: "/etc/pomerium/webhook_secret",
+		}, false},
+		{"missing secret file", ChangeDataCaptureSettings{
+			WebhookURL:        "https://siem.example.com/events",
+			WebhookSecretFile: "/does/not/exist",
+		}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+// DefaultCIDRSetRefreshInterval is the default interval for reloading CIDR sets from files or URLs.
+const DefaultCIDRSetRefreshInterval = 10 * time.Minute
+
+// A CIDRSet is a named collection of IP ranges that can be referenced by a policy's
+// ip_allowlist or ip_denylist.
+type CIDRSet struct {
+	// Name is used to refer to the set from policies.
+	Name string `mapstructure:"name" yaml:"name"`
+	// CIDRs are IP ranges or addresses defined inline.
+	CIDRs []string `mapstructure:"cidrs" yaml:"cidrs,omitempty"`
+	// File is the path to a file containing one IP range or address per line.
+	File string `mapstructure:"file" yaml:"file,omitempty"`
+	// URL is an http or https URL returning one IP range or address per line.
+	URL string `mapstructure:"url" yaml:"url,omitempty"`
+	// RefreshInterval is how often the File or URL is reloaded.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval" yaml:"refresh_interval,omitempty"`
+}
+
+// GetRefreshInterval returns the refresh interval, or the default if none is set.
+func (s *CIDRSet) GetRefreshInterval() time.Duration {
+	if s.RefreshInterval > 0 {
+		return s.RefreshInterval
+	}
+	return DefaultCIDRSetRefreshInterval
+}
+
+// Validate validates the CIDR set.
+func (s *CIDRSet) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("config: cidr set name is required")
+	}
+	if _, ok := ParseIPOrCIDR(s.Name); ok {
+		return fmt.Errorf("config: cidr set name must not be an ip range: %s", s.Name)
+	}
+
+	sources := 0
+	if len(s.CIDRs) > 0 {
+		sources++
+	}
+	if s.File != "" {
+		sources++
+	}
+	if s.URL != "" {
+		sources++
+	}
+	if sources != 1 {
+		return fmt.Errorf("config: cidr set %s must have exactly one of cidrs, file or url", s.Name)
+	}
+
+	for _, cidr := range s.CIDRs {
+		if _, ok := ParseIPOrCIDR(cidr); !ok {
+			return fmt.Errorf("config: cidr set %s has an invalid ip range: %s", s.Name, cidr)
+		}
+	}
+	if s.URL != "" {
+		if _, err := urlutil.ParseAndValidateURL(s.URL); err != nil {
+			return fmt.Errorf("config: cidr set %s has an invalid url: %w", s.Name, err)
+		}
+	}
+	if s.RefreshInterval < 0 {
+		return fmt.Errorf("config: cidr set %s refresh_interval must not be negative", s.Name)
+	}
+	return nil
+}
+
+// ParseIPOrCIDR parses an IP range in CIDR notation or a single IP address, which is treated
+// as a range containing only that address.
+func ParseIPOrCIDR(raw string) (netip.Prefix, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.Contains(raw, "/") {
+		prefix, err := netip.ParsePrefix(raw)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		return prefix.Masked(), true
+	}
+
+	addr, err := netip.ParseAddr(raw)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), true
+}
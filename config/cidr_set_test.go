@@ -0,0 +1,54 @@
+package config
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPOrCIDR(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		raw    string
+		expect netip.Prefix
+		ok     bool
+	}{
+		{"10.0.0.0/8", netip.MustParsePrefix("10.0.0.0/8"), true},
+		{"10.1.2.3/8", netip.MustParsePrefix("10.0.0.0/8"), true},
+		{"192.0.2.1", netip.MustParsePrefix("192.0.2.1/32"), true},
+		{"2001:db8::1", netip.MustParsePrefix("2001:db8::1/128"), true},
+		{" 2001:db8::/32 ", netip.MustParsePrefix("2001:db8::/32"), true},
+		{"10.0.0.0/33", netip.Prefix{}, false},
+		{"blocklist", netip.Prefix{}, false},
+	} {
+		actual, ok := ParseIPOrCIDR(tc.raw)
+		assert.Equal(t, tc.ok, ok, tc.raw)
+		assert.Equal(t, tc.expect, actual, tc.raw)
+	}
+}
+
+func TestCIDRSet_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name    string
+		set     CIDRSet
+		wantErr bool
+	}{
+		{"inline", CIDRSet{Name: "a", CIDRs: []string{"10.0.0.0/8"}}, false},
+		{"file", CIDRSet{Name: "a", File: "blocklist.txt"}, false},
+		{"url", CIDRSet{Name: "a", URL: "https://example.com/blocklist.txt", RefreshInterval: DefaultCIDRSetRefreshInterval}, false},
+		{"missing name", CIDRSet{CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"ip range name", CIDRSet{Name: "10.0.0.0/8", CIDRs: []string{"10.0.0.0/8"}}, true},
+		{"missing source", CIDRSet{Name: "a"}, true},
+		{"multiple sources", CIDRSet{Name: "a", File: "blocklist.txt", URL: "https://example.com/blocklist.txt"}, true},
+		{"invalid range", CIDRSet{Name: "a", CIDRs: []string{"10.0.0.0/33"}}, true},
+		{"invalid url", CIDRSet{Name: "a", URL: "blocklist.txt"}, true},
+		{"negative refresh interval", CIDRSet{Name: "a", File: "blocklist.txt", RefreshInterval: -1}, true},
+	} {
+		err := tc.set.Validate()
+		assert.Equal(t, tc.wantErr, err != nil, "%s: %v", tc.name, err)
+	}
+}
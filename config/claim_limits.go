@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/identity"
+)
+
+// ClaimGroupsHash is the claim which replaces the groups claim when groups are hashed.
+const ClaimGroupsHash = "groups_hash"
+
+// ClaimLimits drops or truncates identity provider claims before they're stored in sessions and
+// users, and so before they end up in session cookies and JWTs. Some identity providers return
+// claims, like groups, large enough to exceed cookie and header size limits.
+type ClaimLimits struct {
+	// Drop are the names of claims to drop.
+	Drop []string `mapstructure:"drop" yaml:"drop,omitempty" json:"drop,omitempty"`
+	// MaxValues is the maximum number of values kept for a claim.
+	MaxValues int `mapstructure:"max_values" yaml:"max_values,omitempty" json:"max_values,omitempty"`
+	// MaxValueLength is the maximum length of a string claim value. Longer values are truncated.
+	MaxValueLength int `mapstructure:"max_value_length" yaml:"max_value_length,omitempty" json:"max_value_length,omitempty"`
+	// HashGroups replaces the groups claim with a groups_hash claim. The full groups can be looked
+	// up with the /.pomerium/groups endpoint.
+	HashGroups bool `mapstructure:"hash_groups" yaml:"hash_groups,omitempty" json:"hash_groups,omitempty"`
+}
+
+// Validate checks the validity of the claim limits.
+func (l *ClaimLimits) Validate() error {
+	for _, name := range l.Drop {
+		if name == "" {
+			return fmt.Errorf("dropped claim names must not be empty")
+		}
+	}
+	if l.MaxValues < 0 {
+		return fmt.Errorf("max_values must not be negative")
+	}
+	if l.MaxValueLength < 0 {
+		return fmt.Errorf("max_value_length must not be negative")
+	}
+	return nil
+}
+
+// Apply drops and truncates the claims in place. If the groups claim is replaced by a hash, the
+// full groups are returned.
+func (l *ClaimLimits) Apply(claims identity.FlattenedClaims) (groups []string) {
+	for _, name := range l.Drop {
+		delete(claims, name)
+	}
+
+	if vs, ok := claims["groups"]; ok && l.HashGroups {
+		groups = make([]string, len(vs))
+		for i, v := range vs {
+			groups[i] = fmt.Sprint(v)
+		}
+		delete(claims, "groups")
+		claims[ClaimGroupsHash] = []interface{}{identity.HashGroups(groups)}
+	}
+
+	for k, vs := range claims {
+		if l.MaxValues > 0 && len(vs) > l.MaxValues {
+			vs = vs[:l.MaxValues]
+		}
+		if l.MaxValueLength > 0 {
+			for i, v := range vs {
+				if s, ok := v.(string); ok && len(s) > l.MaxValueLength {
+					// don't leave a partial utf-8 sequence at the end
+					vs[i] = strings.ToValidUTF8(s[:l.MaxValueLength], "")
+				}
+			}
+		}
+		claims[k] = vs
+	}
+
+	return groups
+}
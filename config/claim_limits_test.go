@@ -0,0 +1,45 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/identity"
+)
+
+func TestClaimLimits_Apply(t *testing.T) {
+	t.Parallel()
+
+	t.Run("drop and truncate", func(t *testing.T) {
+		t.Parallel()
+
+		l := &ClaimLimits{Drop: []string{"picture"}, MaxValues: 2, MaxValueLength: 5}
+		claims := identity.FlattenedClaims{
+			"picture": {"data:image/png;base64,..."},
+			"roles":   {"admin", "dev", "ops"},
+			"name":    {"John Smith"},
+			"age":     {42.0},
+		}
+		groups := l.Apply(claims)
+		assert.Nil(t, groups)
+		assert.Equal(t, identity.FlattenedClaims{
+			"roles": {"admin", "dev"},
+			"name":  {"John "},
+			"age":   {42.0},
+		}, claims)
+	})
+	t.Run("hash groups", func(t *testing.T) {
+		t.Parallel()
+
+		l := &ClaimLimits{HashGroups: true}
+		claims := identity.FlattenedClaims{
+			"groups": {"b", "a"},
+		}
+		groups := l.Apply(claims)
+		assert.Equal(t, []string{"b", "a"}, groups)
+		assert.Equal(t, identity.FlattenedClaims{
+			ClaimGroupsHash: {identity.HashGroups([]string{"a", "b"})},
+		}, claims)
+	})
+}
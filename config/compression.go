@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+)
+
+// CompressionAlgorithm is an algorithm used to compress responses.
+type CompressionAlgorithm string
+
+// Supported compression algorithms.
+const (
+	CompressionAlgorithmBrotli CompressionAlgorithm = "brotli"
+	CompressionAlgorithmGzip   CompressionAlgorithm = "gzip"
+	CompressionAlgorithmZstd   CompressionAlgorithm = "zstd"
+)
+
+// Defaults for response compression.
+var (
+	DefaultCompressionAlgorithms   = []CompressionAlgorithm{CompressionAlgorithmGzip}
+	DefaultCompressionContentTypes = []string{
+		"application/javascript",
+		"application/json",
+		"application/xml",
+		"image/svg+xml",
+		"text/css",
+		"text/html",
+		"text/javascript",
+		"text/plain",
+		"text/xml",
+	}
+	DefaultCompressionMinSize = 1024
+)
+
+// PolicyCompression compresses upstream responses for a route.
+//
+// Only responses which aren't already compressed, with a matching content type and a size of at
+// least the minimum size, are compressed, using the first algorithm accepted by the client.
+type PolicyCompression struct {
+	// Algorithms are the algorithms to use, in order of preference. Defaults to gzip.
+	Algorithms []CompressionAlgorithm `mapstructure:"algorithms" yaml:"algorithms,omitempty" json:"algorithms,omitempty"`
+	// ContentTypes are the media types of responses to compress.
+	ContentTypes []string `mapstructure:"content_types" yaml:"content_types,omitempty" json:"content_types,omitempty"`
+	// MinSize is the minimum size of a response body to compress, in bytes.
+	MinSize int `mapstructure:"min_size" yaml:"min_size,omitempty" json:"min_size,omitempty"`
+}
+
+// Validate checks the validity of the compression options.
+func (c *PolicyCompression) Validate() error {
+	seen := make(map[CompressionAlgorithm]struct{}, len(c.Algorithms))
+	for _, a := range c.Algorithms {
+		switch a {
+		case CompressionAlgorithmBrotli, CompressionAlgorithmGzip, CompressionAlgorithmZstd:
+		default:
+			return fmt.Errorf("unknown algorithm: %s", a)
+		}
+		if _, ok := seen[a]; ok {
+			return fmt.Errorf("duplicate algorithm: %s", a)
+		}
+		seen[a] = struct{}{}
+	}
+	for _, ct := range c.ContentTypes {
+		if ct == "" {
+			return fmt.Errorf("content types must not be empty")
+		}
+	}
+	if c.MinSize < 0 {
+		return fmt.Errorf("min_size must not be negative")
+	}
+	return nil
+}
+
+// GetAlgorithms returns the algorithms to use, in order of preference.
+func (c *PolicyCompression) GetAlgorithms() []CompressionAlgorithm {
+	if len(c.Algorithms) == 0 {
+		return DefaultCompressionAlgorithms
+	}
+	return c.Algorithms
+}
+
+// GetContentTypes returns the content types to compress.
+func (c *PolicyCompression) GetContentTypes() []string {
+	if len(c.ContentTypes) == 0 {
+		return DefaultCompressionContentTypes
+	}
+	return c.ContentTypes
+}
+
+// GetMinSize returns the minimum size of a response body to compress.
+func (c *PolicyCompression) GetMinSize() int {
+	if c.MinSize == 0 {
+		return DefaultCompressionMinSize
+	}
+	return c.MinSize
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/google/uuid"
@@ -13,6 +15,7 @@ import (
 	"github.com/pomerium/pomerium/internal/fileutil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/netutil"
 )
 
@@ -87,6 +90,11 @@ func (src *StaticSource) OnConfigChange(_ context.Context, li ChangeListener) {
 	src.lis = append(src.lis, li)
 }
 
+// A Reloader is a Source whose configuration can be reloaded on demand.
+type Reloader interface {
+	Reload(ctx context.Context)
+}
+
 // A FileOrEnvironmentSource retrieves config options from a file or the environment.
 type FileOrEnvironmentSource struct {
 	configFile string
@@ -134,22 +142,28 @@ func NewFileOrEnvironmentSource(
 	ch := src.watcher.Bind()
 	go func() {
 		for range ch {
-			src.check(ctx)
+			src.check(ctx, "config: file updated, reconfiguring...")
 		}
 	}()
 
 	return src, nil
 }
 
-func (src *FileOrEnvironmentSource) check(ctx context.Context) {
+// Reload reloads the config file, even if it hasn't changed.
+func (src *FileOrEnvironmentSource) Reload(ctx context.Context) {
+	src.check(ctx, "config: reload requested, reconfiguring...")
+}
+
+func (src *FileOrEnvironmentSource) check(ctx context.Context, msg string) {
 	ctx = log.WithContext(ctx, func(c zerolog.Context) zerolog.Context {
 		return c.Str("config_change_id", uuid.New().String())
 	})
-	log.Info(ctx).Msg("config: file updated, reconfiguring...")
+	log.Info(ctx).Msg(msg)
 	src.mu.Lock()
 	cfg := src.config
 	options, err := newOptionsFromConfig(src.configFile)
 	if err == nil {
+		sharePolicies(options, cfg.Options)
 		cfg = cfg.Clone()
 		cfg.Options = options
 		metrics.SetConfigInfo(ctx, cfg.Options.Services, "local", cfg.Checksum(), true)
@@ -239,13 +253,15 @@ func (src *FileWatcherSource) check(ctx context.Context, cfg *Config) {
 		cfg.Options.PolicyFile,
 		cfg.Options.SharedSecretFile,
 		cfg.Options.SigningKeyFile,
+		cfg.Options.UpstreamMTLS.CAFile,
+		cfg.Options.UpstreamMTLS.CAKeyFile,
 	}
 
 	for _, pair := range cfg.Options.CertificateFiles {
 		fs = append(fs, pair.CertFile, pair.KeyFile)
 	}
 
-	for _, policy := range cfg.Options.Policies {
+	for _, policy := range cfg.Options.GetAllPolicies() {
 		fs = append(fs,
 			policy.KubernetesServiceAccountTokenFile,
 			policy.TLSClientCertFile,
@@ -255,6 +271,13 @@ func (src *FileWatcherSource) check(ctx context.Context, cfg *Config) {
 		)
 	}
 
+	// reload the custom templates when they change
+	if dir := cfg.Options.Theme.TemplatesDirectory; dir != "" {
+		templateFiles, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+		fs = append(fs, templateFiles...)
+		src.watcher.Add(dir)
+	}
+
 	for _, f := range fs {
 		_, _ = h.Write([]byte{0})
 		bs, err := os.ReadFile(f)
@@ -266,7 +289,87 @@ func (src *FileWatcherSource) check(ctx context.Context, cfg *Config) {
 
 	// update the computed config
 	src.computedConfig = cfg.Clone()
+	reloadPolicyFiles(ctx, src.computedConfig.Options)
 
 	// trigger a change
 	src.Trigger(ctx, src.computedConfig)
 }
+
+// sharePolicies replaces the policies which didn't change since the previous options with the
+// previous ones, so that the slices, maps and compiled regexes of unchanged routes are shared with
+// the previous config instead of being kept twice while both are in use.
+func sharePolicies(o, previous *Options) {
+	if previous == nil {
+		return
+	}
+	unchanged := make(map[uint64]*Policy, len(previous.Policies)+len(previous.Routes))
+	for _, policies := range [][]Policy{previous.Policies, previous.Routes} {
+		for i := range policies {
+			unchanged[policies[i].Checksum()] = &policies[i]
+		}
+	}
+	share := func(policies []Policy) {
+		for i := range policies {
+			if p, ok := unchanged[policies[i].Checksum()]; ok {
+				// the client certificate isn't part of the checksum, as it's read from files
+				clientCertificate := policies[i].ClientCertificate
+				policies[i] = *p
+				policies[i].ClientCertificate = clientCertificate
+			}
+		}
+	}
+	share(o.Policies)
+	share(o.Routes)
+}
+
+// reloadPolicyFiles reloads the client certificates and downstream client CAs of policies from
+// their files, as these are only read when the policies are validated.
+func reloadPolicyFiles(ctx context.Context, o *Options) {
+	hasFiles := func(p *Policy) bool {
+		return (p.TLSClientCertFile != "" && p.TLSClientKeyFile != "") || p.TLSDownstreamClientCAFile != ""
+	}
+	reloadPolicy := func(p Policy) Policy {
+		if p.TLSClientCertFile != "" && p.TLSClientKeyFile != "" {
+			cert, err := cryptutil.CertificateFromFile(p.TLSClientCertFile, p.TLSClientKeyFile)
+			if err != nil {
+				log.Error(ctx).Err(err).Str("file", p.TLSClientCertFile).Msg("config: failed to reload client certificate")
+			} else {
+				p.ClientCertificate = cert
+			}
+		}
+		if p.TLSDownstreamClientCAFile != "" {
+			bs, err := os.ReadFile(p.TLSDownstreamClientCAFile)
+			if err != nil {
+				log.Error(ctx).Err(err).Str("file", p.TLSDownstreamClientCAFile).Msg("config: failed to reload downstream client ca")
+			} else {
+				p.TLSDownstreamClientCA = base64.StdEncoding.EncodeToString(bs)
+			}
+		}
+		return p
+	}
+	reload := func(policies []Policy) []Policy {
+		if policies == nil {
+			return nil
+		}
+		reloaded := make([]Policy, len(policies))
+		for i, p := range policies {
+			reloaded[i] = reloadPolicy(p)
+		}
+		return reloaded
+	}
+	o.Policies = reload(o.Policies)
+	o.Routes = reload(o.Routes)
+
+	// additional policies are shared, so only the ones with files are copied
+	if o.AdditionalPolicies != nil {
+		reloaded := make([]*Policy, len(o.AdditionalPolicies))
+		for i, p := range o.AdditionalPolicies {
+			if hasFiles(p) {
+				r := reloadPolicy(*p)
+				p = &r
+			}
+			reloaded[i] = p
+		}
+		o.AdditionalPolicies = reloaded
+	}
+}
@@ -78,3 +78,43 @@ func TestFileWatcherSource(t *testing.T) {
 		t.Error("expected OnConfigChange to be fired after triggering a change to the underlying source")
 	}
 }
+
+func TestFileOrEnvironmentSource_Reload(t *testing.T) {
+	ctx := context.Background()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(configFile, []byte("authenticate_service_url: https://authenticate1.example.com\n"), 0o600)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	src, err := NewFileOrEnvironmentSource(configFile, "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "https://authenticate1.example.com", src.GetConfig().Options.AuthenticateURLString)
+
+	var reloader Reloader = src
+	err = os.WriteFile(configFile, []byte("authenticate_service_url: https://authenticate2.example.com\n"), 0o600)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reloader.Reload(ctx)
+	assert.Equal(t, "https://authenticate2.example.com", src.GetConfig().Options.AuthenticateURLString)
+}
+
+func TestSharePolicies(t *testing.T) {
+	previous := &Options{Routes: []Policy{
+		{From: "https://a.example.com", AllowedUsers: []string{"a"}},
+		{From: "https://b.example.com", AllowedUsers: []string{"b"}},
+	}}
+	o := &Options{Routes: []Policy{
+		{From: "https://a.example.com", AllowedUsers: []string{"a"}},
+		{From: "https://b.example.com", AllowedUsers: []string{"c"}},
+	}}
+	sharePolicies(o, previous)
+
+	assert.Same(t, &previous.Routes[0].AllowedUsers[0], &o.Routes[0].AllowedUsers[0],
+		"the unchanged route should be shared")
+	assert.Equal(t, []string{"c"}, o.Routes[1].AllowedUsers, "the changed route should be kept")
+}
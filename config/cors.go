@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultCORSAllowMethods are the methods allowed for cross-origin requests if none are set.
+var DefaultCORSAllowMethods = []string{"GET", "HEAD", "POST"}
+
+// PolicyCORS is the cross-origin resource sharing policy of a route.
+//
+// The policy is applied before authorization: preflight requests from allowed origins are answered
+// directly, since browsers don't send credentials with preflights, and responses to requests from
+// allowed origins get the CORS headers.
+type PolicyCORS struct {
+	// AllowOrigins are the origins allowed to make cross-origin requests, such as
+	// https://app.example.com. A * matches any part of a host name or port, like
+	// https://*.example.com, and * alone matches any origin.
+	AllowOrigins []string `mapstructure:"allow_origins" yaml:"allow_origins,omitempty" json:"allow_origins,omitempty"`
+	// AllowMethods are the methods allowed for cross-origin requests. Defaults to GET, HEAD and POST.
+	AllowMethods []string `mapstructure:"allow_methods" yaml:"allow_methods,omitempty" json:"allow_methods,omitempty"`
+	// AllowHeaders are the request headers allowed for cross-origin requests.
+	AllowHeaders []string `mapstructure:"allow_headers" yaml:"allow_headers,omitempty" json:"allow_headers,omitempty"`
+	// ExposeHeaders are the response headers exposed to cross-origin requests.
+	ExposeHeaders []string `mapstructure:"expose_headers" yaml:"expose_headers,omitempty" json:"expose_headers,omitempty"`
+	// AllowCredentials allows cross-origin requests with credentials, such as cookies.
+	AllowCredentials bool `mapstructure:"allow_credentials" yaml:"allow_credentials,omitempty" json:"allow_credentials,omitempty"`
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration `mapstructure:"max_age" yaml:"max_age,omitempty" json:"max_age,omitempty"`
+}
+
+// Validate checks the validity of the CORS options.
+func (c *PolicyCORS) Validate() error {
+	if len(c.AllowOrigins) == 0 {
+		return errors.New("allow_origins must not be empty")
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return errors.New("allow_credentials can't be used with the * origin")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.ReplaceAll(origin, "*", "0"))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid origin: %q", origin)
+		}
+	}
+	for _, m := range c.AllowMethods {
+		if m == "" || strings.ContainsAny(m, ", ") {
+			return fmt.Errorf("invalid method: %q", m)
+		}
+	}
+	for _, hs := range [][]string{c.AllowHeaders, c.ExposeHeaders} {
+		for _, h := range hs {
+			if h == "" || strings.ContainsAny(h, ", ") {
+				return fmt.Errorf("invalid header: %q", h)
+			}
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("max_age must not be negative")
+	}
+	return nil
+}
+
+// GetAllowMethods returns the methods allowed for cross-origin requests.
+func (c *PolicyCORS) GetAllowMethods() []string {
+	if len(c.AllowMethods) == 0 {
+		return DefaultCORSAllowMethods
+	}
+	return c.AllowMethods
+}
+
+// GetAllowOriginRegexes returns regular expressions matching the allowed origins.
+func (c *PolicyCORS) GetAllowOriginRegexes() []string {
+	regexes := make([]string, 0, len(c.AllowOrigins))
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			regexes = append(regexes, ".*")
+			continue
+		}
+		parts := strings.Split(strings.ToLower(origin), "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		regexes = append(regexes, "^"+strings.Join(parts, "[a-z0-9.-]*")+"$")
+	}
+	return regexes
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyCORS_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		cors   PolicyCORS
+		expect bool
+	}{
+		{"valid", PolicyCORS{AllowOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:*"}}, true},
+		{"any origin", PolicyCORS{AllowOrigins: []string{"*"}}, true},
+		{"no origins", PolicyCORS{}, false},
+		{"origin with path", PolicyCORS{AllowOrigins: []string{"https://app.example.com/"}}, false},
+		{"origin without scheme", PolicyCORS{AllowOrigins: []string{"app.example.com"}}, false},
+		{"any origin with credentials", PolicyCORS{AllowOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"invalid method", PolicyCORS{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET, POST"}}, false},
+		{"invalid header", PolicyCORS{AllowOrigins: []string{"*"}, AllowHeaders: []string{""}}, false},
+		{"negative max age", PolicyCORS{AllowOrigins: []string{"*"}, MaxAge: -time.Second}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.cors.Validate()
+			if tc.expect {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicyCORS_GetAllowOriginRegexes(t *testing.T) {
+	t.Parallel()
+
+	c := &PolicyCORS{AllowOrigins: []string{"https://*.example.com", "http://localhost:*"}}
+	matches := func(origin string) bool {
+		for _, re := range c.GetAllowOriginRegexes() {
+			if regexp.MustCompile(re).MatchString(origin) {
+				return true
+			}
+		}
+		return false
+	}
+	assert.True(t, matches("https://app.example.com"))
+	assert.True(t, matches("http://localhost:3000"))
+	assert.False(t, matches("https://example.com.evil.com"))
+	assert.False(t, matches("https://app.example.com.evil.com"))
+	assert.False(t, matches("http://app.example.com"))
+	assert.False(t, matches("http://localhost.evil.com:3000"))
+}
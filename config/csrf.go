@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRFMode is a method of protecting a route from cross-site request forgery.
+type CSRFMode string
+
+// Supported CSRF protection modes.
+const (
+	// CSRFModeOrigin requires the Origin, or Referer, of non-idempotent requests to match the route
+	// or one of the trusted origins.
+	CSRFModeOrigin CSRFMode = "origin"
+	// CSRFModeDoubleSubmitCookie requires non-idempotent requests to send the value of the CSRF
+	// cookie in the CSRF header.
+	CSRFModeDoubleSubmitCookie CSRFMode = "double_submit_cookie"
+)
+
+// Defaults for CSRF protection.
+const (
+	DefaultCSRFCookieName = "_pomerium_csrf"
+	DefaultCSRFHeaderName = "X-Pomerium-CSRF-Token"
+)
+
+// PolicyCSRF protects a route from cross-site request forgery.
+//
+// Protection only applies to non-idempotent requests made with a browser session cookie. Requests
+// authenticated with an Authorization header, like API tokens, are exempt since browsers don't
+// send those automatically.
+type PolicyCSRF struct {
+	// Mode is the protection mode, either origin or double_submit_cookie.
+	Mode CSRFMode `mapstructure:"mode" yaml:"mode,omitempty" json:"mode,omitempty"`
+	// TrustedOrigins are additional origins, like https://app.example.com, allowed in origin mode.
+	TrustedOrigins []string `mapstructure:"trusted_origins" yaml:"trusted_origins,omitempty" json:"trusted_origins,omitempty"`
+	// CookieName is the name of the CSRF cookie in double_submit_cookie mode.
+	CookieName string `mapstructure:"cookie_name" yaml:"cookie_name,omitempty" json:"cookie_name,omitempty"`
+	// HeaderName is the name of the CSRF header in double_submit_cookie mode.
+	HeaderName string `mapstructure:"header_name" yaml:"header_name,omitempty" json:"header_name,omitempty"`
+}
+
+// Validate checks the validity of the CSRF options.
+func (c *PolicyCSRF) Validate() error {
+	switch c.Mode {
+	case CSRFModeOrigin, CSRFModeDoubleSubmitCookie:
+	default:
+		return fmt.Errorf("unknown mode: %q", c.Mode)
+	}
+	for _, origin := range c.TrustedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid trusted origin: %q", origin)
+		}
+	}
+	if c.CookieName != "" && (&http.Cookie{Name: c.CookieName, Value: "x"}).Valid() != nil {
+		return fmt.Errorf("invalid cookie name: %q", c.CookieName)
+	}
+	if strings.ContainsAny(c.HeaderName, " :\r\n") {
+		return fmt.Errorf("invalid header name: %q", c.HeaderName)
+	}
+	return nil
+}
+
+// GetCookieName returns the name of the CSRF cookie.
+func (c *PolicyCSRF) GetCookieName() string {
+	if c.CookieName == "" {
+		return DefaultCSRFCookieName
+	}
+	return c.CookieName
+}
+
+// GetHeaderName returns the name of the CSRF header.
+func (c *PolicyCSRF) GetHeaderName() string {
+	if c.HeaderName == "" {
+		return DefaultCSRFHeaderName
+	}
+	return c.HeaderName
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyCSRF_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		csrf   PolicyCSRF
+		expect bool
+	}{
+		{"origin", PolicyCSRF{Mode: CSRFModeOrigin, TrustedOrigins: []string{"https://app.example.com"}}, true},
+		{"double submit cookie", PolicyCSRF{Mode: CSRFModeDoubleSubmitCookie, CookieName: "csrf", HeaderName: "X-CSRF-Token"}, true},
+		{"no mode", PolicyCSRF{}, false},
+		{"unknown mode", PolicyCSRF{Mode: "token"}, false},
+		{"trusted origin with path", PolicyCSRF{Mode: CSRFModeOrigin, TrustedOrigins: []string{"https://app.example.com/"}}, false},
+		{"trusted origin without scheme", PolicyCSRF{Mode: CSRFModeOrigin, TrustedOrigins: []string{"app.example.com"}}, false},
+		{"invalid cookie name", PolicyCSRF{Mode: CSRFModeDoubleSubmitCookie, CookieName: "a;b"}, false},
+		{"invalid header name", PolicyCSRF{Mode: CSRFModeDoubleSubmitCookie, HeaderName: "X CSRF"}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.csrf.Validate()
+			if tc.expect {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
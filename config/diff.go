@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/hashutil"
+	"github.com/pomerium/pomerium/internal/sets"
+)
+
+// listenerOptions are the options that affect how downstream listeners are built.
+var listenerOptions = map[string]struct{}{
+	"address":                          {},
+	"client_ca":                        {},
+	"client_ca_file":                   {},
+	"codec_type":                       {},
+	"downstream_mtls":                  {},
+	"grpc_address":                     {},
+	"grpc_insecure":                    {},
+	"http_redirect_addr":               {},
+	"insecure_server":                  {},
+	"metrics_address":                  {},
+	"metrics_client_ca":                {},
+	"metrics_client_ca_file":           {},
+	"skip_xff_append":                  {},
+	"use_proxy_protocol":               {},
+	"xff_num_trusted_hops":             {},
+	"autocert":                         {},
+	"autocert_must_staple":             {},
+	"autocert_use_staging":             {},
+	"envoy_admin_address":              {},
+	"envoy_bind_config_freebind":       {},
+	"envoy_bind_config_source_address": {},
+	"fips_mode":                        {},
+}
+
+// certificateOptions are the options that set the certificates presented by listeners. The
+// certificates are sent to envoy using secret discovery, so they're reloaded without updating
+// the listeners.
+var certificateOptions = map[string]struct{}{
+	"certificate":                  {},
+	"certificate_file":             {},
+	"certificate_key":              {},
+	"certificate_key_file":         {},
+	"certificates":                 {},
+	"metrics_certificate":          {},
+	"metrics_certificate_file":     {},
+	"metrics_certificate_key":      {},
+	"metrics_certificate_key_file": {},
+}
+
+// restartOptions are the options that cannot be applied to running listeners. Changing
+// them requires listeners to be recreated or pomerium to be restarted.
+var restartOptions = map[string]struct{}{
+	"address":                          {},
+	"grpc_address":                     {},
+	"http_redirect_addr":               {},
+	"metrics_address":                  {},
+	"envoy_admin_address":              {},
+	"envoy_admin_access_log_path":      {},
+	"envoy_admin_profile_path":         {},
+	"envoy_bind_config_freebind":       {},
+	"envoy_bind_config_source_address": {},
+	"fips_mode":                        {},
+}
+
+// A ConfigDiff describes what would change if a candidate config replaced the current config.
+type ConfigDiff struct {
+	// AddedRoutes, RemovedRoutes and ChangedRoutes are the routes, identified by
+	// `from → to`, that differ between the configs.
+	AddedRoutes   []string `json:"added_routes"`
+	RemovedRoutes []string `json:"removed_routes"`
+	ChangedRoutes []string `json:"changed_routes"`
+	// ChangedPolicies are the changed routes whose authorization policy differs.
+	ChangedPolicies []string `json:"changed_policies"`
+	// ChangedOptions are the global options, by config key, that differ.
+	ChangedOptions []string `json:"changed_options"`
+	// ChangedListeners are the changed options which affect listeners.
+	ChangedListeners []string `json:"changed_listeners"`
+	// ChangedCertificates are the changed options which set listener certificates. They're
+	// reloaded without updating the listeners.
+	ChangedCertificates []string `json:"changed_certificates"`
+	// RequiresListenerRestart is true if applying the candidate config would
+	// require listeners to be restarted.
+	RequiresListenerRestart bool `json:"requires_listener_restart"`
+}
+
+// IsEmpty returns true if there are no differences.
+func (d *ConfigDiff) IsEmpty() bool {
+	return len(d.AddedRoutes) == 0 &&
+		len(d.RemovedRoutes) == 0 &&
+		len(d.ChangedRoutes) == 0 &&
+		len(d.ChangedOptions) == 0
+}
+
+// NewCandidateConfig returns the config which would result from replacing the options of the
+// current config with the candidate options, so that it can be diffed against the current config.
+// The candidate options are validated, like options loaded from a config file, and the changes
+// the other config sources make to the options are carried over from the current config: the
+// routes added by the databroker and the endpoints found by service discovery.
+func NewCandidateConfig(current *Config, options *Options) (*Config, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[uint64]WeightedURLs)
+	for _, policies := range [][]Policy{current.Options.Policies, current.Options.Routes} {
+		for i := range policies {
+			if id, err := policies[i].RouteID(); err == nil && policies[i].ResolvedTo != nil {
+				resolved[id] = policies[i].ResolvedTo
+			}
+		}
+	}
+	for _, policies := range [][]Policy{options.Policies, options.Routes} {
+		for i := range policies {
+			if id, err := policies[i].RouteID(); err == nil {
+				if urls, ok := resolved[id]; ok {
+					policies[i].ResolvedTo = urls
+				}
+			}
+		}
+	}
+	options.AdditionalPolicies = current.Options.AdditionalPolicies
+
+	candidate := current.Clone()
+	candidate.Options = options
+	return candidate, nil
+}
+
+// DiffConfigs computes the differences between the current and the candidate config without
+// applying either of them. The candidate config should be built with NewCandidateConfig, so that
+// it's processed like the current config.
+func DiffConfigs(current, candidate *Config) *ConfigDiff {
+	diff := &ConfigDiff{
+		AddedRoutes:         []string{},
+		RemovedRoutes:       []string{},
+		ChangedRoutes:       []string{},
+		ChangedPolicies:     []string{},
+		ChangedOptions:      []string{},
+		ChangedListeners:    []string{},
+		ChangedCertificates: []string{},
+	}
+
+	currentRoutes := indexPoliciesByRouteID(current.Options)
+	candidateRoutes := indexPoliciesByRouteID(candidate.Options)
+	for id, p := range candidateRoutes {
+		prev, ok := currentRoutes[id]
+		switch {
+		case !ok:
+			diff.AddedRoutes = append(diff.AddedRoutes, p.String())
+		case prev.Checksum() != p.Checksum():
+			diff.ChangedRoutes = append(diff.ChangedRoutes, p.String())
+			if hashutil.MustHash(prev.ToPPL()) != hashutil.MustHash(p.ToPPL()) {
+				diff.ChangedPolicies = append(diff.ChangedPolicies, p.String())
+			}
+		}
+	}
+	for id, p := range currentRoutes {
+		if _, ok := candidateRoutes[id]; !ok {
+			diff.RemovedRoutes = append(diff.RemovedRoutes, p.String())
+		}
+	}
+
+	for _, key := range diffOptions(current.Options, candidate.Options) {
+		diff.ChangedOptions = append(diff.ChangedOptions, key)
+		if _, ok := listenerOptions[key]; ok {
+			diff.ChangedListeners = append(diff.ChangedListeners, key)
+		}
+		if _, ok := certificateOptions[key]; ok {
+			diff.ChangedCertificates = append(diff.ChangedCertificates, key)
+		}
+		if _, ok := restartOptions[key]; ok {
+			diff.RequiresListenerRestart = true
+		}
+	}
+
+	for _, slc := range [][]string{diff.AddedRoutes, diff.RemovedRoutes, diff.ChangedRoutes, diff.ChangedPolicies} {
+		sort.Strings(slc)
+	}
+	return diff
+}
+
+func indexPoliciesByRouteID(o *Options) map[string]*Policy {
+	m := make(map[string]*Policy)
+	for _, p := range o.GetAllPolicies() {
+		p := p
+		id, err := p.RouteID()
+		if err != nil {
+			continue
+		}
+		m[fmt.Sprint(id)] = &p
+	}
+	return m
+}
+
+// diffOptions returns the config keys of all the global options which differ.
+// Routes are compared separately.
+func diffOptions(current, candidate *Options) []string {
+	if current == nil {
+		current = new(Options)
+	}
+	if candidate == nil {
+		candidate = new(Options)
+	}
+
+	keys := sets.NewSorted[string]()
+	diffOptionFields(keys, reflect.ValueOf(current).Elem(), reflect.ValueOf(candidate).Elem())
+	return keys.ToSlice()
+}
+
+func diffOptionFields(keys *sets.Sorted[string], current, candidate reflect.Value) {
+	t := current.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch field.Name {
+		case "Policies", "Routes", "AdditionalPolicies":
+			continue
+		}
+
+		tag, hasTag := field.Tag.Lookup("mapstructure")
+		key, opts, _ := strings.Cut(tag, ",")
+		if opts == "squash" && field.Type.Kind() == reflect.Struct {
+			diffOptionFields(keys, current.Field(i), candidate.Field(i))
+			continue
+		}
+		if !hasTag || key == "" || key == "-" {
+			key = field.Name
+		}
+
+		if hashutil.MustHash(current.Field(i).Interface()) != hashutil.MustHash(candidate.Field(i).Interface()) {
+			keys.Add(key)
+		}
+	}
+}
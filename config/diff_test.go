@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	t.Parallel()
+
+	load := func(t *testing.T, raw string) *Config {
+		t.Helper()
+		o, err := NewOptionsFromReader(strings.NewReader(raw), "yaml")
+		require.NoError(t, err)
+		return &Config{Options: o}
+	}
+
+	current := load(t, `
+insecure_server: true
+address: ":8080"
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+    allowed_users: [user1@example.com]
+  - from: https://b.example.com
+    to: https://b.internal
+  - from: https://c.example.com
+    to: https://c.internal
+`)
+
+	t.Run("no changes", func(t *testing.T) {
+		t.Parallel()
+
+		diff := DiffConfigs(current, current)
+		assert.True(t, diff.IsEmpty())
+		assert.False(t, diff.RequiresListenerRestart)
+	})
+	t.Run("route changes", func(t *testing.T) {
+		t.Parallel()
+
+		candidate := load(t, `
+insecure_server: true
+address: ":8080"
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+    allowed_users: [user2@example.com]
+  - from: https://b.example.com
+    to: https://b.internal
+    preserve_host_header: true
+  - from: https://d.example.com
+    to: https://d.internal
+`)
+		diff := DiffConfigs(current, candidate)
+		assert.Equal(t, []string{"https://d.example.com → https://d.internal"}, diff.AddedRoutes)
+		assert.Equal(t, []string{"https://c.example.com → https://c.internal"}, diff.RemovedRoutes)
+		assert.Equal(t, []string{
+			"https://a.example.com → https://a.internal",
+			"https://b.example.com → https://b.internal",
+		}, diff.ChangedRoutes)
+		assert.Equal(t, []string{"https://a.example.com → https://a.internal"}, diff.ChangedPolicies)
+		assert.Empty(t, diff.ChangedOptions)
+		assert.False(t, diff.RequiresListenerRestart)
+	})
+	t.Run("listener changes", func(t *testing.T) {
+		t.Parallel()
+
+		candidate := load(t, `
+insecure_server: true
+address: ":9090"
+use_proxy_protocol: true
+cookie_name: _other
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+    allowed_users: [user1@example.com]
+  - from: https://b.example.com
+    to: https://b.internal
+  - from: https://c.example.com
+    to: https://c.internal
+`)
+		diff := DiffConfigs(current, candidate)
+		assert.Equal(t, []string{"address", "cookie_name", "use_proxy_protocol"}, diff.ChangedOptions)
+		assert.Equal(t, []string{"address", "use_proxy_protocol"}, diff.ChangedListeners)
+		assert.Empty(t, diff.ChangedCertificates)
+		assert.True(t, diff.RequiresListenerRestart)
+	})
+	t.Run("certificate changes", func(t *testing.T) {
+		t.Parallel()
+
+		candidate := load(t, `
+insecure_server: true
+address: ":8080"
+certificate_file: cert.pem
+certificate_key_file: key.pem
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+    allowed_users: [user1@example.com]
+  - from: https://b.example.com
+    to: https://b.internal
+  - from: https://c.example.com
+    to: https://c.internal
+`)
+		diff := DiffConfigs(current, candidate)
+		assert.Equal(t, []string{"certificate_file", "certificate_key_file"}, diff.ChangedOptions)
+		assert.Equal(t, []string{"certificate_file", "certificate_key_file"}, diff.ChangedCertificates)
+		assert.Empty(t, diff.ChangedListeners, "certificates should be reloaded without updating listeners")
+		assert.False(t, diff.RequiresListenerRestart)
+	})
+}
+
+func TestDiffConfigs_reload(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte(`
+insecure_server: true
+address: ":8080"
+shared_secret: UYgnt8bxxK5G2sFaNzyqi5Z+OgF8m2akNc0xdQx718w=
+cookie_secret: UYgnt8bxxK5G2sFaNzyqi5Z+OgF8m2akNc0xdQx718w=
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+    allowed_users: [user1@example.com]
+  - from: https://b.example.com
+    to: https://b.internal
+    tls_downstream_client_ca_file: ./testdata/ca.pem
+`)
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, raw, 0o600))
+
+	o, err := optionsFromViper(configFile)
+	require.NoError(t, err)
+	// simulate the changes made by the other config sources
+	o.Routes[0].ResolvedTo = mustParseWeightedURLs(t, "https://10.0.0.1")
+	o.AdditionalPolicies = []*Policy{{From: "https://c.example.com", To: mustParseWeightedURLs(t, "https://c.internal")}}
+	current := &Config{Options: o}
+
+	options, err := NewOptionsFromReader(strings.NewReader(string(raw)), "yaml")
+	require.NoError(t, err)
+	candidate, err := NewCandidateConfig(current, options)
+	require.NoError(t, err)
+
+	diff := DiffConfigs(current, candidate)
+	assert.True(t, diff.IsEmpty(), "a reload of the same file should not change anything: %+v", diff)
+	assert.Empty(t, diff.ChangedPolicies)
+	assert.False(t, diff.RequiresListenerRestart)
+}
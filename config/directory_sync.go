@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Directory sync providers.
+const (
+	DirectorySyncProviderKeycloak = "keycloak"
+	DirectorySyncProviderLDAP     = "ldap"
+)
+
+// DefaultDirectorySyncInterval is how often the directory is synced if no interval is set.
+const DefaultDirectorySyncInterval = 10 * time.Minute
+
+// DefaultDirectorySyncNestedGroupDepth is how many levels of nested groups are resolved if no depth
+// is set.
+const DefaultDirectorySyncNestedGroupDepth = 10
+
+// DirectorySyncSettings configure syncing users and groups from a directory into the databroker,
+// where they are used by the groups policy criterion.
+type DirectorySyncSettings struct {
+	// Provider is either keycloak or ldap. Directory sync is disabled if no provider is set.
+	Provider string `mapstructure:"provider" yaml:"provider,omitempty"`
+	// Interval is how often the directory is synced. Defaults to 10 minutes.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval,omitempty"`
+	// NestedGroupDepth is how many levels of nested groups are resolved, so that members of a
+	// subgroup are also members of its parent groups. 0 disables nested groups. Defaults to 10.
+	NestedGroupDepth *int `mapstructure:"nested_group_depth" yaml:"nested_group_depth,omitempty"`
+
+	Keycloak KeycloakDirectorySyncSettings `mapstructure:"keycloak" yaml:"keycloak,omitempty"`
+	LDAP     LDAPDirectorySyncSettings     `mapstructure:"ldap" yaml:"ldap,omitempty"`
+}
+
+// KeycloakDirectorySyncSettings configure syncing users and groups using the Keycloak admin API. The
+// client must have a service account with the view-users and query-groups roles.
+type KeycloakDirectorySyncSettings struct {
+	// URL is the base url of the Keycloak server, e.g. https://keycloak.example.com.
+	URL              string `mapstructure:"url" yaml:"url,omitempty"`
+	Realm            string `mapstructure:"realm" yaml:"realm,omitempty"`
+	ClientID         string `mapstructure:"client_id" yaml:"client_id,omitempty"`
+	ClientSecret     string `mapstructure:"client_secret" yaml:"client_secret,omitempty"`
+	ClientSecretFile string `mapstructure:"client_secret_file" yaml:"client_secret_file,omitempty"`
+}
+
+// LDAPDirectorySyncSettings configure syncing users and groups from an LDAP server, such as Active
+// Directory or OpenLDAP.
+type LDAPDirectorySyncSettings struct {
+	// URL is the url of the LDAP server, e.g. ldaps://ldap.example.com:636.
+	URL string `mapstructure:"url" yaml:"url,omitempty"`
+	// StartTLS upgrades an ldap:// connection to TLS.
+	StartTLS bool `mapstructure:"start_tls" yaml:"start_tls,omitempty"`
+	// CAFile is a bundle of certificate authorities used to verify the LDAP server. Defaults to
+	// the system roots.
+	CAFile string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+
+	BindDN           string `mapstructure:"bind_dn" yaml:"bind_dn,omitempty"`
+	BindPassword     string `mapstructure:"bind_password" yaml:"bind_password,omitempty"`
+	BindPasswordFile string `mapstructure:"bind_password_file" yaml:"bind_password_file,omitempty"`
+
+	// BaseDN is where users and groups are searched for.
+	BaseDN string `mapstructure:"base_dn" yaml:"base_dn,omitempty"`
+	// UserFilter selects the users. Defaults to (objectClass=person).
+	UserFilter string `mapstructure:"user_filter" yaml:"user_filter,omitempty"`
+	// GroupFilter selects the groups. Defaults to groups, groupOfNames and groupOfUniqueNames.
+	GroupFilter string `mapstructure:"group_filter" yaml:"group_filter,omitempty"`
+
+	// UserIDAttribute is the attribute which matches the user id of the identity provider, i.e.
+	// the sub claim. Defaults to uid.
+	UserIDAttribute string `mapstructure:"user_id_attribute" yaml:"user_id_attribute,omitempty"`
+	// UserEmailAttribute defaults to mail.
+	UserEmailAttribute string `mapstructure:"user_email_attribute" yaml:"user_email_attribute,omitempty"`
+	// UserDisplayNameAttribute defaults to displayName.
+	UserDisplayNameAttribute string `mapstructure:"user_display_name_attribute" yaml:"user_display_name_attribute,omitempty"`
+	// GroupNameAttribute defaults to cn.
+	GroupNameAttribute string `mapstructure:"group_name_attribute" yaml:"group_name_attribute,omitempty"`
+	// GroupMemberAttribute lists the DNs of the members of a group, which may be other groups.
+	// Defaults to member.
+	GroupMemberAttribute string `mapstructure:"group_member_attribute" yaml:"group_member_attribute,omitempty"`
+
+	// PageSize is the number of entries requested per page of search results. Defaults to 500.
+	PageSize uint32 `mapstructure:"page_size" yaml:"page_size,omitempty"`
+}
+
+// IsEnabled returns true if directory sync is enabled.
+func (s *DirectorySyncSettings) IsEnabled() bool {
+	return s.Provider != ""
+}
+
+// GetInterval returns the sync interval, or the default if none is set.
+func (s *DirectorySyncSettings) GetInterval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultDirectorySyncInterval
+	}
+	return s.Interval
+}
+
+// GetNestedGroupDepth returns how many levels of nested groups are resolved.
+func (s *DirectorySyncSettings) GetNestedGroupDepth() int {
+	if s.NestedGroupDepth == nil {
+		return DefaultDirectorySyncNestedGroupDepth
+	}
+	return *s.NestedGroupDepth
+}
+
+// Validate validates the directory sync settings.
+func (s *DirectorySyncSettings) Validate() error {
+	if s.NestedGroupDepth != nil && *s.NestedGroupDepth < 0 {
+		return fmt.Errorf("config: directory_sync nested_group_depth must not be negative")
+	}
+	switch s.Provider {
+	case "":
+	case DirectorySyncProviderKeycloak:
+		if s.Keycloak.URL == "" || s.Keycloak.Realm == "" || s.Keycloak.ClientID == "" {
+			return fmt.Errorf("config: directory_sync keycloak requires url, realm and client_id")
+		}
+	case DirectorySyncProviderLDAP:
+		if s.LDAP.URL == "" || s.LDAP.BaseDN == "" {
+			return fmt.Errorf("config: directory_sync ldap requires url and base_dn")
+		}
+	default:
+		return fmt.Errorf("config: unknown directory_sync provider: %s", s.Provider)
+	}
+	return nil
+}
+
+// GetClientSecret gets the Keycloak client secret.
+func (s *KeycloakDirectorySyncSettings) GetClientSecret() (string, error) {
+	return readSecret(s.ClientSecret, s.ClientSecretFile)
+}
+
+// GetBindPassword gets the LDAP bind password.
+func (s *LDAPDirectorySyncSettings) GetBindPassword() (string, error) {
+	return readSecret(s.BindPassword, s.BindPasswordFile)
+}
+
+func readSecret(value, file string) (string, error) {
+	if file != "" {
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(bs)), nil
+	}
+	return value, nil
+}
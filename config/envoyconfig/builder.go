@@ -12,6 +12,7 @@ type Builder struct {
 	localMetricsAddress string
 	filemgr             *filemgr.Manager
 	reproxy             *reproxy.Handler
+	secretDiscovery     bool
 }
 
 // New creates a new Builder.
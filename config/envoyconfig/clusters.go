@@ -12,8 +12,11 @@ import (
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_extensions_network_dns_resolver_cares_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/network/dns_resolver/cares/v3"
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -106,19 +109,52 @@ func (b *Builder) BuildClusters(ctx context.Context, cfg *config.Config) ([]*env
 		clusters = append(clusters, tracingCluster)
 	}
 
+	otlpTracingCluster, err := b.buildOTLPTracingCluster(ctx, cfg)
+	if err != nil {
+		return nil, err
+	} else if otlpTracingCluster != nil {
+		clusters = append(clusters, otlpTracingCluster)
+	}
+
+	externalProcessorClusters, err := b.buildExternalProcessorClusters(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	clusters = append(clusters, externalProcessorClusters...)
+
 	if config.IsProxy(cfg.Options.Services) {
 		for i, p := range cfg.Options.GetAllPolicies() {
 			policy := p
 			if policy.EnvoyOpts == nil {
 				policy.EnvoyOpts = newDefaultEnvoyClusterConfig()
 			}
-			if len(policy.To) > 0 {
+			// udp and ssh routes are terminated by the control plane
+			if len(policy.To) > 0 && !policy.IsUDP() && !policy.IsSSH() {
+				// requests to the route fail until discovery has found the upstream endpoints
+				if policy.ResolvedTo != nil && len(policy.ResolvedTo) == 0 {
+					log.Warn(ctx).Str("from", policy.From).Msg("envoyconfig: skipping cluster for route with no discovered upstreams")
+					continue
+				}
 				cluster, err := b.buildPolicyCluster(ctx, cfg, &policy)
 				if err != nil {
 					return nil, fmt.Errorf("policy #%d: %w", i, err)
 				}
 				clusters = append(clusters, cluster)
 			}
+			if policy.MirrorTo != "" {
+				cluster, err := b.buildPolicyMirrorCluster(ctx, cfg, &policy)
+				if err != nil {
+					return nil, fmt.Errorf("policy #%d: %w", i, err)
+				}
+				clusters = append(clusters, cluster)
+			}
+			if hasWebsocketRoute(&policy) {
+				cluster, err := b.buildPolicyWebsocketCluster(ctx, cfg, &policy)
+				if err != nil {
+					return nil, fmt.Errorf("policy #%d: %w", i, err)
+				}
+				clusters = append(clusters, cluster)
+			}
 		}
 	}
 
@@ -137,7 +173,6 @@ func (b *Builder) buildInternalCluster(
 	upstreamProtocol upstreamProtocolConfig,
 ) (*envoy_config_cluster_v3.Cluster, error) {
 	cluster := newDefaultEnvoyClusterConfig()
-	cluster.DnsLookupFamily = config.GetEnvoyDNSLookupFamily(cfg.Options.DNSLookupFamily)
 	var endpoints []Endpoint
 	for _, dst := range dsts {
 		ts, err := b.buildInternalTransportSocket(ctx, cfg, dst)
@@ -149,6 +184,7 @@ func (b *Builder) buildInternalCluster(
 	if err := b.buildCluster(cluster, name, endpoints, upstreamProtocol); err != nil {
 		return nil, err
 	}
+	applyDNSOptions(cluster, cfg.Options)
 
 	return cluster, nil
 }
@@ -190,15 +226,66 @@ func (b *Builder) buildPolicyCluster(ctx context.Context, cfg *config.Config, po
 		return nil, err
 	}
 
-	cluster.DnsLookupFamily = config.GetEnvoyDNSLookupFamily(options.DNSLookupFamily)
+	// envoy-native health checks take precedence over the simplified health check
+	if policy.HealthCheck != nil && len(cluster.HealthChecks) == 0 {
+		cluster.HealthChecks = buildPolicyHealthChecks(policy.HealthCheck)
+	}
+	if policy.OutlierDetection != nil && cluster.OutlierDetection == nil {
+		cluster.OutlierDetection = buildPolicyOutlierDetection(policy.OutlierDetection)
+	}
+
+	// sticky sessions rely on the route hash policy, which is only used by hashing load balancers
+	if policy.StickySession != nil && cluster.LbPolicy == envoy_config_cluster_v3.Cluster_ROUND_ROBIN {
+		cluster.LbPolicy = envoy_config_cluster_v3.Cluster_RING_HASH
+	}
+
+	if err := b.buildCluster(cluster, name, endpoints, upstreamProtocol); err != nil {
+		return nil, err
+	}
+	applyDNSOptions(cluster, options)
 	if policy.EnableGoogleCloudServerlessAuthentication {
 		cluster.DnsLookupFamily = envoy_config_cluster_v3.Cluster_V4_ONLY
 	}
+	if policy.ConnectionPool != nil {
+		applyPolicyConnectionPool(cluster, policy.ConnectionPool, endpoints, upstreamProtocol)
+	}
 
-	if err := b.buildCluster(cluster, name, endpoints, upstreamProtocol); err != nil {
+	return cluster, nil
+}
+
+// buildPolicyMirrorCluster builds the cluster which receives mirrored requests for a policy.
+func (b *Builder) buildPolicyMirrorCluster(ctx context.Context, cfg *config.Config, policy *config.Policy) (*envoy_config_cluster_v3.Cluster, error) {
+	dst, err := config.ParseWeightedURL(policy.MirrorTo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mirror_to: %w", err)
+	}
+
+	mirror := *policy
+	mirror.To = config.WeightedURLs{*dst}
+	mirror.ResolvedTo = nil
+	mirror.HealthCheck = nil
+	mirror.OutlierDetection = nil
+	mirror.StickySession = nil
+	cluster, err := b.buildPolicyCluster(ctx, cfg, &mirror)
+	if err != nil {
 		return nil, err
 	}
+	cluster.Name = getMirrorClusterID(policy)
+	cluster.LoadAssignment.ClusterName = cluster.Name
+	return cluster, nil
+}
 
+// buildPolicyWebsocketCluster builds a copy of the policy cluster used only for websocket connections.
+func (b *Builder) buildPolicyWebsocketCluster(ctx context.Context, cfg *config.Config, policy *config.Policy) (*envoy_config_cluster_v3.Cluster, error) {
+	cluster, err := b.buildPolicyCluster(ctx, cfg, policy)
+	if err != nil {
+		return nil, err
+	}
+	cluster.Name = getWebsocketClusterID(policy)
+	cluster.LoadAssignment.ClusterName = cluster.Name
+	if cluster.AltStatName != "" {
+		cluster.AltStatName += "-websocket"
+	}
 	return cluster, nil
 }
 
@@ -208,7 +295,7 @@ func (b *Builder) buildPolicyEndpoints(
 	policy *config.Policy,
 ) ([]Endpoint, error) {
 	var endpoints []Endpoint
-	for _, dst := range policy.To {
+	for _, dst := range policy.GetUpstreams() {
 		ts, err := b.buildPolicyTransportSocket(ctx, cfg, policy, dst.URL)
 		if err != nil {
 			return nil, err
@@ -315,8 +402,7 @@ func (b *Builder) buildPolicyTransportSocket(
 		AllowRenegotiation: policy.TLSUpstreamAllowRenegotiation,
 	}
 	if policy.ClientCertificate != nil {
-		tlsContext.CommonTlsContext.TlsCertificates = append(tlsContext.CommonTlsContext.TlsCertificates,
-			b.envoyTLSCertificateFromGoTLSCertificate(ctx, policy.ClientCertificate))
+		b.setTLSCertificate(ctx, tlsContext.CommonTlsContext, upstreamClientCertificateSecretName(policy), policy.ClientCertificate)
 	}
 
 	tlsConfig := marshalAny(tlsContext)
@@ -454,6 +540,149 @@ func grpcHealthChecks(name string) []*envoy_config_core_v3.HealthCheck {
 	}}
 }
 
+const (
+	defaultHealthCheckInterval           = 10 * time.Second
+	defaultHealthCheckTimeout            = 5 * time.Second
+	defaultHealthCheckHealthyThreshold   = 1
+	defaultHealthCheckUnhealthyThreshold = 3
+)
+
+func buildPolicyHealthChecks(hc *config.PolicyHealthCheck) []*envoy_config_core_v3.HealthCheck {
+	interval, timeout := hc.Interval, hc.Timeout
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+		if timeout > interval {
+			timeout = interval
+		}
+	}
+	healthyThreshold, unhealthyThreshold := hc.HealthyThreshold, hc.UnhealthyThreshold
+	if healthyThreshold == 0 {
+		healthyThreshold = defaultHealthCheckHealthyThreshold
+	}
+	if unhealthyThreshold == 0 {
+		unhealthyThreshold = defaultHealthCheckUnhealthyThreshold
+	}
+
+	check := &envoy_config_core_v3.HealthCheck{
+		Timeout:            durationpb.New(timeout),
+		Interval:           durationpb.New(interval),
+		HealthyThreshold:   wrapperspb.UInt32(healthyThreshold),
+		UnhealthyThreshold: wrapperspb.UInt32(unhealthyThreshold),
+	}
+	if hc.Path == "" {
+		check.HealthChecker = &envoy_config_core_v3.HealthCheck_TcpHealthCheck_{
+			TcpHealthCheck: &envoy_config_core_v3.HealthCheck_TcpHealthCheck{},
+		}
+	} else {
+		httpCheck := &envoy_config_core_v3.HealthCheck_HttpHealthCheck{
+			Host: hc.Host,
+			Path: hc.Path,
+		}
+		for _, status := range hc.ExpectedStatuses {
+			// ranges are half-open
+			httpCheck.ExpectedStatuses = append(httpCheck.ExpectedStatuses, &envoy_type_v3.Int64Range{
+				Start: int64(status),
+				End:   int64(status) + 1,
+			})
+		}
+		check.HealthChecker = &envoy_config_core_v3.HealthCheck_HttpHealthCheck_{
+			HttpHealthCheck: httpCheck,
+		}
+	}
+	return []*envoy_config_core_v3.HealthCheck{check}
+}
+
+func buildPolicyOutlierDetection(od *config.PolicyOutlierDetection) *envoy_config_cluster_v3.OutlierDetection {
+	outlierDetection := new(envoy_config_cluster_v3.OutlierDetection)
+	if od.Consecutive5xx > 0 {
+		outlierDetection.Consecutive_5Xx = wrapperspb.UInt32(od.Consecutive5xx)
+	}
+	if od.ConsecutiveGatewayFailure > 0 {
+		outlierDetection.ConsecutiveGatewayFailure = wrapperspb.UInt32(od.ConsecutiveGatewayFailure)
+		// gateway failure ejections are not enforced by default
+		outlierDetection.EnforcingConsecutiveGatewayFailure = wrapperspb.UInt32(100)
+	}
+	if od.Interval > 0 {
+		outlierDetection.Interval = durationpb.New(od.Interval)
+	}
+	if od.BaseEjectionTime > 0 {
+		outlierDetection.BaseEjectionTime = durationpb.New(od.BaseEjectionTime)
+	}
+	if od.MaxEjectionPercent > 0 {
+		outlierDetection.MaxEjectionPercent = wrapperspb.UInt32(od.MaxEjectionPercent)
+	}
+	return outlierDetection
+}
+
+// applyDNSOptions applies the upstream DNS options to a cluster.
+func applyDNSOptions(cluster *envoy_config_cluster_v3.Cluster, options *config.Options) {
+	cluster.DnsLookupFamily = config.GetEnvoyDNSLookupFamily(options.DNSLookupFamily)
+	cluster.RespectDnsTtl = options.GetDNSRespectTTL()
+	if options.DNSRefreshRate > 0 {
+		cluster.DnsRefreshRate = durationpb.New(options.DNSRefreshRate)
+	}
+	if len(options.DNSResolvers) > 0 {
+		resolvers := make([]*envoy_config_core_v3.Address, 0, len(options.DNSResolvers))
+		for _, resolver := range options.DNSResolvers {
+			resolvers = append(resolvers, buildAddress(resolver, 53))
+		}
+		cluster.TypedDnsResolverConfig = &envoy_config_core_v3.TypedExtensionConfig{
+			Name: "envoy.network.dns_resolver.cares",
+			TypedConfig: marshalAny(&envoy_extensions_network_dns_resolver_cares_v3.CaresDnsResolverConfig{
+				Resolvers:          resolvers,
+				DnsResolverOptions: &envoy_config_core_v3.DnsResolverOptions{},
+			}),
+		}
+	}
+}
+
+// applyPolicyConnectionPool applies the connection pool settings of a policy to its cluster. The
+// envoy cluster options take precedence.
+func applyPolicyConnectionPool(
+	cluster *envoy_config_cluster_v3.Cluster,
+	cp *config.PolicyConnectionPool,
+	endpoints []Endpoint,
+	upstreamProtocol upstreamProtocolConfig,
+) {
+	if cp.MaxConnections > 0 && cluster.CircuitBreakers == nil {
+		cluster.CircuitBreakers = &envoy_config_cluster_v3.CircuitBreakers{
+			Thresholds: []*envoy_config_cluster_v3.CircuitBreakers_Thresholds{{
+				Priority:       envoy_config_core_v3.RoutingPriority_DEFAULT,
+				MaxConnections: wrapperspb.UInt32(cp.MaxConnections),
+			}},
+		}
+	}
+
+	if cp.MaxRequestsPerConnection > 0 {
+		protocolOptions := buildUpstreamProtocolOptions(endpoints, upstreamProtocol)
+		protocolOptions.CommonHttpProtocolOptions = &envoy_config_core_v3.HttpProtocolOptions{
+			MaxRequestsPerConnection: wrapperspb.UInt32(cp.MaxRequestsPerConnection),
+		}
+		cluster.TypedExtensionProtocolOptions = map[string]*anypb.Any{
+			"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": marshalAny(protocolOptions),
+		}
+	}
+
+	if ka := cp.TCPKeepalive; ka != nil && cluster.UpstreamConnectionOptions == nil {
+		tcpKeepalive := new(envoy_config_core_v3.TcpKeepalive)
+		if ka.Probes > 0 {
+			tcpKeepalive.KeepaliveProbes = wrapperspb.UInt32(ka.Probes)
+		}
+		if ka.Time > 0 {
+			tcpKeepalive.KeepaliveTime = wrapperspb.UInt32(uint32(ka.Time / time.Second))
+		}
+		if ka.Interval > 0 {
+			tcpKeepalive.KeepaliveInterval = wrapperspb.UInt32(uint32(ka.Interval / time.Second))
+		}
+		cluster.UpstreamConnectionOptions = &envoy_config_cluster_v3.UpstreamConnectionOptions{
+			TcpKeepalive: tcpKeepalive,
+		}
+	}
+}
+
 func (b *Builder) buildLbEndpoints(endpoints []Endpoint) ([]*envoy_config_endpoint_v3.LbEndpoint, error) {
 	var lbes []*envoy_config_endpoint_v3.LbEndpoint
 	for _, e := range endpoints {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_extensions_upstreams_http_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/volatiletech/null/v9"
@@ -1012,3 +1013,293 @@ func mustParseWeightedURLs(t *testing.T, urls ...string) []config.WeightedURL {
 	require.NoError(t, err)
 	return wu
 }
+
+func Test_buildPolicyClusterStickySession(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	t.Run("defaults to ring hash", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:          "https://from.example.com",
+			To:            mustParseWeightedURLs(t, "https://to1.example.com,90", "https://to2.example.com,10"),
+			StickySession: &config.StickySession{},
+			EnvoyOpts:     newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_RING_HASH, cluster.LbPolicy)
+	})
+	t.Run("keeps explicit lb policy", func(t *testing.T) {
+		envoyOpts := newDefaultEnvoyClusterConfig()
+		envoyOpts.LbPolicy = envoy_config_cluster_v3.Cluster_MAGLEV
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:          "https://from.example.com",
+			To:            mustParseWeightedURLs(t, "https://to1.example.com,90", "https://to2.example.com,10"),
+			StickySession: &config.StickySession{},
+			EnvoyOpts:     envoyOpts,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, envoy_config_cluster_v3.Cluster_MAGLEV, cluster.LbPolicy)
+	})
+}
+
+func Test_buildPolicyClusterHealthCheck(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	t.Run("http", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From: "https://from.example.com",
+			To:   mustParseWeightedURLs(t, "https://to1.example.com", "https://to2.example.com"),
+			HealthCheck: &config.PolicyHealthCheck{
+				Path:               "/healthz",
+				Interval:           30 * time.Second,
+				UnhealthyThreshold: 5,
+				ExpectedStatuses:   []int{200, 204},
+			},
+			EnvoyOpts: newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		testutil.AssertProtoJSONEqual(t, `[{
+			"timeout": "5s",
+			"interval": "30s",
+			"healthyThreshold": 1,
+			"unhealthyThreshold": 5,
+			"httpHealthCheck": {
+				"path": "/healthz",
+				"expectedStatuses": [
+					{ "start": "200", "end": "201" },
+					{ "start": "204", "end": "205" }
+				]
+			}
+		}]`, cluster.HealthChecks)
+	})
+	t.Run("tcp", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:        "https://from.example.com",
+			To:          mustParseWeightedURLs(t, "https://to1.example.com"),
+			HealthCheck: &config.PolicyHealthCheck{Interval: 2 * time.Second},
+			EnvoyOpts:   newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		testutil.AssertProtoJSONEqual(t, `[{
+			"timeout": "2s",
+			"interval": "2s",
+			"healthyThreshold": 1,
+			"unhealthyThreshold": 3,
+			"tcpHealthCheck": {}
+		}]`, cluster.HealthChecks)
+	})
+	t.Run("envoy health checks take precedence", func(t *testing.T) {
+		envoyOpts := newDefaultEnvoyClusterConfig()
+		envoyOpts.HealthChecks = grpcHealthChecks("example")
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:        "https://from.example.com",
+			To:          mustParseWeightedURLs(t, "https://to1.example.com"),
+			HealthCheck: &config.PolicyHealthCheck{Path: "/healthz"},
+			EnvoyOpts:   envoyOpts,
+		})
+		require.NoError(t, err)
+		require.Len(t, cluster.HealthChecks, 1)
+		assert.NotNil(t, cluster.HealthChecks[0].GetGrpcHealthCheck())
+	})
+}
+
+func Test_buildPolicyClusterOutlierDetection(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	t.Run("outlier detection", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From: "https://from.example.com",
+			To:   mustParseWeightedURLs(t, "https://to1.example.com", "https://to2.example.com"),
+			OutlierDetection: &config.PolicyOutlierDetection{
+				Consecutive5xx:            3,
+				ConsecutiveGatewayFailure: 2,
+				BaseEjectionTime:          time.Minute,
+				MaxEjectionPercent:        50,
+			},
+			EnvoyOpts: newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		testutil.AssertProtoJSONEqual(t, `{
+			"consecutive5xx": 3,
+			"consecutiveGatewayFailure": 2,
+			"enforcingConsecutiveGatewayFailure": 100,
+			"baseEjectionTime": "60s",
+			"maxEjectionPercent": 50
+		}`, cluster.OutlierDetection)
+	})
+	t.Run("envoy outlier detection takes precedence", func(t *testing.T) {
+		envoyOpts := newDefaultEnvoyClusterConfig()
+		envoyOpts.OutlierDetection = grpcOutlierDetection()
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:             "https://from.example.com",
+			To:               mustParseWeightedURLs(t, "https://to1.example.com"),
+			OutlierDetection: &config.PolicyOutlierDetection{Consecutive5xx: 1},
+			EnvoyOpts:        envoyOpts,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint32(5), cluster.OutlierDetection.GetConsecutive_5Xx().GetValue())
+	})
+}
+
+func Test_buildPolicyClusterResolvedTo(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	policy := &config.Policy{
+		From:       "https://from.example.com",
+		To:         mustParseWeightedURLs(t, "consul+http://web"),
+		ResolvedTo: mustParseWeightedURLs(t, "http://10.0.0.1:8080"),
+		EnvoyOpts:  newDefaultEnvoyClusterConfig(),
+	}
+	cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, policy)
+	require.NoError(t, err)
+	assert.Equal(t, getClusterID(policy), cluster.Name)
+	testutil.AssertProtoJSONEqual(t, `{
+		"clusterName": "`+getClusterID(policy)+`",
+		"endpoints": [{
+			"lbEndpoints": [{
+				"endpoint": {
+					"address": {
+						"socketAddress": {
+							"address": "10.0.0.1",
+							"portValue": 8080
+						}
+					}
+				}
+			}]
+		}]
+	}`, cluster.LoadAssignment)
+}
+
+func Test_buildPolicyMirrorCluster(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	policy := &config.Policy{
+		From:      "https://from.example.com",
+		To:        mustParseWeightedURLs(t, "http://to.example.com"),
+		MirrorTo:  "http://shadow.example.com:8080",
+		EnvoyOpts: newDefaultEnvoyClusterConfig(),
+	}
+	cluster, err := b.buildPolicyMirrorCluster(ctx, &config.Config{Options: &config.Options{}}, policy)
+	require.NoError(t, err)
+	assert.Equal(t, getClusterID(policy)+"-mirror", cluster.Name)
+	testutil.AssertProtoJSONEqual(t, `{
+		"clusterName": "`+getClusterID(policy)+`-mirror",
+		"endpoints": [{
+			"lbEndpoints": [{
+				"endpoint": {
+					"address": {
+						"socketAddress": {
+							"address": "shadow.example.com",
+							"portValue": 8080
+						}
+					}
+				}
+			}]
+		}]
+	}`, cluster.LoadAssignment)
+}
+
+func Test_buildPolicyWebsocketCluster(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	policy := &config.Policy{
+		From:      "https://from.example.com",
+		To:        mustParseWeightedURLs(t, "http://to.example.com"),
+		EnvoyOpts: newDefaultEnvoyClusterConfig(),
+	}
+	policy.EnvoyOpts.Name = "example"
+	cluster, err := b.buildPolicyWebsocketCluster(ctx, &config.Config{Options: &config.Options{}}, policy)
+	require.NoError(t, err)
+	assert.Equal(t, getClusterID(policy)+"-websocket", cluster.Name)
+	assert.Equal(t, cluster.Name, cluster.LoadAssignment.ClusterName)
+	assert.Equal(t, "example-websocket", cluster.AltStatName)
+}
+
+func Test_buildPolicyClusterConnectionPool(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	t.Run("connection pool", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From: "https://from.example.com",
+			To:   mustParseWeightedURLs(t, "http://to.example.com"),
+			ConnectionPool: &config.PolicyConnectionPool{
+				MaxConnections:           4096,
+				MaxRequestsPerConnection: 100,
+				Protocol:                 config.UpstreamProtocolHTTP2,
+				TCPKeepalive: &config.PolicyTCPKeepalive{
+					Probes:   3,
+					Time:     time.Minute,
+					Interval: 10 * time.Second,
+				},
+			},
+			EnvoyOpts: newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		testutil.AssertProtoJSONEqual(t, `{
+			"thresholds": [{ "maxConnections": 4096 }]
+		}`, cluster.CircuitBreakers)
+		testutil.AssertProtoJSONEqual(t, `{
+			"tcpKeepalive": {
+				"keepaliveProbes": 3,
+				"keepaliveTime": 60,
+				"keepaliveInterval": 10
+			}
+		}`, cluster.UpstreamConnectionOptions)
+
+		var protocolOptions envoy_extensions_upstreams_http_v3.HttpProtocolOptions
+		require.NoError(t, cluster.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(&protocolOptions))
+		assert.Equal(t, uint32(100), protocolOptions.GetCommonHttpProtocolOptions().GetMaxRequestsPerConnection().GetValue())
+		assert.NotNil(t, protocolOptions.GetExplicitHttpConfig().GetHttp2ProtocolOptions(), "should use http/2")
+	})
+	t.Run("websockets use http/1.1", func(t *testing.T) {
+		cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{}}, &config.Policy{
+			From:            "https://from.example.com",
+			To:              mustParseWeightedURLs(t, "http://to.example.com"),
+			AllowWebsockets: true,
+			ConnectionPool:  &config.PolicyConnectionPool{Protocol: config.UpstreamProtocolHTTP2},
+			EnvoyOpts:       newDefaultEnvoyClusterConfig(),
+		})
+		require.NoError(t, err)
+		var protocolOptions envoy_extensions_upstreams_http_v3.HttpProtocolOptions
+		require.NoError(t, cluster.TypedExtensionProtocolOptions["envoy.extensions.upstreams.http.v3.HttpProtocolOptions"].UnmarshalTo(&protocolOptions))
+		assert.NotNil(t, protocolOptions.GetExplicitHttpConfig().GetHttpProtocolOptions())
+	})
+}
+
+func Test_buildPolicyClusterDNS(t *testing.T) {
+	ctx := context.Background()
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	respectTTL := false
+	cluster, err := b.buildPolicyCluster(ctx, &config.Config{Options: &config.Options{
+		DNSLookupFamily: config.DNSLookupFamilyV6Only,
+		DNSResolvers:    []string{"10.0.0.2", "[fd00::2]:5353"},
+		DNSRefreshRate:  30 * time.Second,
+		DNSRespectTTL:   &respectTTL,
+	}}, &config.Policy{
+		From:      "https://from.example.com",
+		To:        mustParseWeightedURLs(t, "http://to.example.com"),
+		EnvoyOpts: newDefaultEnvoyClusterConfig(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, envoy_config_cluster_v3.Cluster_V6_ONLY, cluster.DnsLookupFamily)
+	assert.False(t, cluster.RespectDnsTtl)
+	assert.Equal(t, 30*time.Second, cluster.DnsRefreshRate.AsDuration())
+	testutil.AssertProtoJSONEqual(t, `{
+		"name": "envoy.network.dns_resolver.cares",
+		"typedConfig": {
+			"@type": "type.googleapis.com/envoy.extensions.network.dns_resolver.cares.v3.CaresDnsResolverConfig",
+			"dnsResolverOptions": {},
+			"resolvers": [
+				{ "socketAddress": { "address": "10.0.0.2", "portValue": 53 } },
+				{ "socketAddress": { "address": "fd00::2", "portValue": 5353 } }
+			]
+		}
+	}`, cluster.TypedDnsResolverConfig)
+}
@@ -0,0 +1,116 @@
+package envoyconfig
+
+import (
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_compression_brotli_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/brotli/compressor/v3"
+	envoy_extensions_compression_gzip_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/gzip/compressor/v3"
+	envoy_extensions_compression_zstd_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/compression/zstd/compressor/v3"
+	envoy_extensions_filters_http_compressor_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/compressor/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// getCompressionFilterName returns the name of the compressor filter for the given compression
+// options and algorithm. Policies with the same compression options share their filters.
+func getCompressionFilterName(c *config.PolicyCompression, algorithm config.CompressionAlgorithm) string {
+	key := fmt.Sprintf("%q %q %d", c.GetAlgorithms(), c.GetContentTypes(), c.GetMinSize())
+	return fmt.Sprintf("envoy.filters.http.compressor.%s.%x", algorithm, xxhash.Sum64String(key))
+}
+
+// buildCompressionFilters builds compressor filters for the distinct compression options used by
+// policies. Routes disable every compressor filter except their own.
+func buildCompressionFilters(options *config.Options) []*envoy_http_connection_manager.HttpFilter {
+	var filters []*envoy_http_connection_manager.HttpFilter
+	seen := make(map[string]struct{})
+	for _, p := range options.GetAllPolicies() {
+		if p.Compression == nil {
+			continue
+		}
+
+		for i, algorithm := range p.Compression.GetAlgorithms() {
+			name := getCompressionFilterName(p.Compression, algorithm)
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+
+			filters = append(filters, &envoy_http_connection_manager.HttpFilter{
+				Name: name,
+				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+					TypedConfig: protoutil.NewAny(&envoy_extensions_filters_http_compressor_v3.Compressor{
+						CompressorLibrary: getCompressorLibrary(algorithm),
+						ResponseDirectionConfig: &envoy_extensions_filters_http_compressor_v3.Compressor_ResponseDirectionConfig{
+							CommonConfig: &envoy_extensions_filters_http_compressor_v3.Compressor_CommonDirectionConfig{
+								MinContentLength: wrapperspb.UInt32(uint32(p.Compression.GetMinSize())),
+								ContentType:      p.Compression.GetContentTypes(),
+							},
+						},
+						// prefer the algorithms in the configured order when the client accepts several equally
+						ChooseFirst: i == 0,
+					}),
+				},
+			})
+		}
+	}
+	return filters
+}
+
+func getCompressorLibrary(algorithm config.CompressionAlgorithm) *envoy_config_core_v3.TypedExtensionConfig {
+	switch algorithm {
+	case config.CompressionAlgorithmBrotli:
+		return &envoy_config_core_v3.TypedExtensionConfig{
+			Name:        "envoy.compression.brotli.compressor",
+			TypedConfig: protoutil.NewAny(&envoy_extensions_compression_brotli_compressor_v3.Brotli{}),
+		}
+	case config.CompressionAlgorithmZstd:
+		return &envoy_config_core_v3.TypedExtensionConfig{
+			Name:        "envoy.compression.zstd.compressor",
+			TypedConfig: protoutil.NewAny(&envoy_extensions_compression_zstd_compressor_v3.Zstd{}),
+		}
+	default:
+		return &envoy_config_core_v3.TypedExtensionConfig{
+			Name:        "envoy.compression.gzip.compressor",
+			TypedConfig: protoutil.NewAny(&envoy_extensions_compression_gzip_compressor_v3.Gzip{}),
+		}
+	}
+}
+
+// setCompressionPerFilterConfig disables every compressor filter on the route except the ones for
+// the policy's compression options. The policy may be nil for routes which are never compressed.
+func setCompressionPerFilterConfig(
+	options *config.Options,
+	policy *config.Policy,
+	typedPerFilterConfig map[string]*any.Any,
+) {
+	enabled := make(map[string]struct{})
+	if policy != nil && policy.Compression != nil {
+		for _, algorithm := range policy.Compression.GetAlgorithms() {
+			enabled[getCompressionFilterName(policy.Compression, algorithm)] = struct{}{}
+		}
+	}
+
+	for _, p := range options.GetAllPolicies() {
+		if p.Compression == nil {
+			continue
+		}
+
+		for _, algorithm := range p.Compression.GetAlgorithms() {
+			name := getCompressionFilterName(p.Compression, algorithm)
+			if _, ok := enabled[name]; ok {
+				continue
+			}
+			typedPerFilterConfig[name] = marshalAny(&envoy_extensions_filters_http_compressor_v3.CompressorPerRoute{
+				Override: &envoy_extensions_filters_http_compressor_v3.CompressorPerRoute_Disabled{
+					Disabled: true,
+				},
+			})
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package envoyconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
+	"github.com/pomerium/pomerium/internal/testutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func Test_buildCompressionFilters(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, buildCompressionFilters(&config.Options{}))
+
+	brotliAndGzip := &config.PolicyCompression{
+		Algorithms:   []config.CompressionAlgorithm{config.CompressionAlgorithmBrotli, config.CompressionAlgorithmGzip},
+		ContentTypes: []string{"text/css"},
+		MinSize:      256,
+	}
+	options := &config.Options{
+		Policies: []config.Policy{
+			{From: "https://a.example.com", Compression: brotliAndGzip},
+			{From: "https://b.example.com", Compression: brotliAndGzip},
+			{From: "https://c.example.com", Compression: &config.PolicyCompression{}},
+		},
+	}
+	filters := buildCompressionFilters(options)
+	require.Len(t, filters, 3, "policies with the same options should share filters")
+
+	assert.Equal(t, getCompressionFilterName(brotliAndGzip, config.CompressionAlgorithmBrotli), filters[0].GetName())
+	testutil.AssertProtoJSONEqual(t, `{
+		"@type": "type.googleapis.com/envoy.extensions.filters.http.compressor.v3.Compressor",
+		"chooseFirst": true,
+		"compressorLibrary": {
+			"name": "envoy.compression.brotli.compressor",
+			"typedConfig": {
+				"@type": "type.googleapis.com/envoy.extensions.compression.brotli.compressor.v3.Brotli"
+			}
+		},
+		"responseDirectionConfig": {
+			"commonConfig": {
+				"contentType": ["text/css"],
+				"minContentLength": 256
+			}
+		}
+	}`, filters[0].GetTypedConfig())
+	assert.Equal(t, getCompressionFilterName(brotliAndGzip, config.CompressionAlgorithmGzip), filters[1].GetName())
+
+	assert.Equal(t, getCompressionFilterName(&config.PolicyCompression{}, config.CompressionAlgorithmGzip), filters[2].GetName())
+	assert.Equal(t, getCompressionFilterName(&config.PolicyCompression{}, config.CompressionAlgorithmGzip),
+		getCompressionFilterName(&config.PolicyCompression{Algorithms: config.DefaultCompressionAlgorithms}, config.CompressionAlgorithmGzip),
+		"default options should share filters with explicit options")
+}
+
+func Test_buildRoutesForPolicyCompression(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	policy := config.Policy{
+		From:            "https://example.com",
+		To:              mustParseWeightedURLs(t, "https://to.example.com"),
+		AllowWebsockets: true,
+		Compression:     &config.PolicyCompression{},
+	}
+	other := &config.PolicyCompression{Algorithms: []config.CompressionAlgorithm{config.CompressionAlgorithmZstd}}
+	options := &config.Options{
+		DefaultUpstreamTimeout: time.Second * 3,
+		SharedKey:              cryptutil.NewBase64Key(),
+		Policies: []config.Policy{
+			policy,
+			{From: "https://other.example.com", Compression: other},
+		},
+	}
+	gzipName := getCompressionFilterName(policy.Compression, config.CompressionAlgorithmGzip)
+	zstdName := getCompressionFilterName(other, config.CompressionAlgorithmZstd)
+
+	b := &Builder{filemgr: filemgr.NewManager()}
+	routes, err := b.buildRoutesForPolicy(&config.Config{Options: options}, &policy, "policy-0")
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+
+	disabled := `{
+		"@type": "type.googleapis.com/envoy.extensions.filters.http.compressor.v3.CompressorPerRoute",
+		"disabled": true
+	}`
+	// websocket upgrades are never compressed
+	testutil.AssertProtoJSONEqual(t, disabled, routes[0].GetTypedPerFilterConfig()[gzipName])
+	testutil.AssertProtoJSONEqual(t, disabled, routes[0].GetTypedPerFilterConfig()[zstdName])
+
+	assert.NotContains(t, routes[1].GetTypedPerFilterConfig(), gzipName)
+	testutil.AssertProtoJSONEqual(t, disabled, routes[1].GetTypedPerFilterConfig()[zstdName])
+
+	route := b.buildControlPlanePathRoute(options, "/.pomerium")
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()[gzipName])
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()[zstdName])
+}
@@ -0,0 +1,61 @@
+package envoyconfig
+
+import (
+	"strconv"
+	"strings"
+
+	envoy_extensions_filters_http_cors_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cors/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+const corsFilterName = "envoy.filters.http.cors"
+
+// buildCORSFilters builds the CORS filter if any policy has a CORS policy. The filter only applies
+// to routes with a CORS policy.
+func buildCORSFilters(options *config.Options) []*envoy_http_connection_manager.HttpFilter {
+	for _, p := range options.GetAllPolicies() {
+		if p.CORS != nil {
+			return []*envoy_http_connection_manager.HttpFilter{{
+				Name: corsFilterName,
+				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+					TypedConfig: protoutil.NewAny(&envoy_extensions_filters_http_cors_v3.Cors{}),
+				},
+			}}
+		}
+	}
+	return nil
+}
+
+// setCORSPerFilterConfig sets the CORS policy of the route to the policy's CORS policy.
+func setCORSPerFilterConfig(policy *config.Policy, typedPerFilterConfig map[string]*any.Any) {
+	if policy == nil || policy.CORS == nil {
+		return
+	}
+
+	var origins []*envoy_type_matcher_v3.StringMatcher
+	for _, re := range policy.CORS.GetAllowOriginRegexes() {
+		origins = append(origins, &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_SafeRegex{
+				SafeRegex: &envoy_type_matcher_v3.RegexMatcher{Regex: re},
+			},
+		})
+	}
+
+	cors := &envoy_extensions_filters_http_cors_v3.CorsPolicy{
+		AllowOriginStringMatch: origins,
+		AllowMethods:           strings.Join(policy.CORS.GetAllowMethods(), ","),
+		AllowHeaders:           strings.Join(policy.CORS.AllowHeaders, ","),
+		ExposeHeaders:          strings.Join(policy.CORS.ExposeHeaders, ","),
+		AllowCredentials:       wrapperspb.Bool(policy.CORS.AllowCredentials),
+	}
+	if policy.CORS.MaxAge > 0 {
+		cors.MaxAge = strconv.FormatInt(int64(policy.CORS.MaxAge.Seconds()), 10)
+	}
+	typedPerFilterConfig[corsFilterName] = marshalAny(cors)
+}
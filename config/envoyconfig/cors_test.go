@@ -0,0 +1,75 @@
+package envoyconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
+	"github.com/pomerium/pomerium/internal/testutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func Test_buildCORSFilters(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, buildCORSFilters(&config.Options{}))
+
+	filters := buildCORSFilters(&config.Options{
+		Policies: []config.Policy{
+			{From: "https://a.example.com", CORS: &config.PolicyCORS{AllowOrigins: []string{"*"}}},
+			{From: "https://b.example.com", CORS: &config.PolicyCORS{AllowOrigins: []string{"https://app.example.com"}}},
+		},
+	})
+	require.Len(t, filters, 1, "routes should share the filter")
+	assert.Equal(t, corsFilterName, filters[0].GetName())
+}
+
+func Test_buildRoutesForPolicyCORS(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	policy := config.Policy{
+		From: "https://example.com",
+		To:   mustParseWeightedURLs(t, "https://to.example.com"),
+		CORS: &config.PolicyCORS{
+			AllowOrigins:     []string{"https://*.example.com"},
+			AllowHeaders:     []string{"Authorization", "Content-Type"},
+			ExposeHeaders:    []string{"X-Request-Id"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		},
+	}
+	options := &config.Options{
+		DefaultUpstreamTimeout: time.Second * 3,
+		SharedKey:              cryptutil.NewBase64Key(),
+		Policies: []config.Policy{
+			policy,
+			{From: "https://other.example.com", To: mustParseWeightedURLs(t, "https://to.example.com")},
+		},
+	}
+
+	b := &Builder{filemgr: filemgr.NewManager()}
+	routes, err := b.buildRoutesForPolicy(&config.Config{Options: options}, &policy, "policy-0")
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	testutil.AssertProtoJSONEqual(t, `{
+		"@type": "type.googleapis.com/envoy.extensions.filters.http.cors.v3.CorsPolicy",
+		"allowOriginStringMatch": [{"safeRegex": {"regex": "^https://[a-z0-9.-]*\\.example\\.com$"}}],
+		"allowMethods": "GET,HEAD,POST",
+		"allowHeaders": "Authorization,Content-Type",
+		"exposeHeaders": "X-Request-Id",
+		"allowCredentials": true,
+		"maxAge": "600"
+	}`, routes[0].GetTypedPerFilterConfig()[corsFilterName])
+
+	routes, err = b.buildRoutesForPolicy(&config.Config{Options: options}, &options.Policies[1], "policy-1")
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+	assert.NotContains(t, routes[0].GetTypedPerFilterConfig(), corsFilterName)
+}
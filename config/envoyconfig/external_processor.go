@@ -0,0 +1,152 @@
+package envoyconfig
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/cespare/xxhash/v2"
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_filters_http_ext_proc_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// getExternalProcessorFilterName returns the name of the ext_proc filter for the given external
+// processor options. Policies with the same options share their filters.
+func getExternalProcessorFilterName(p *config.PolicyExternalProcessor) string {
+	key := fmt.Sprintf("%q %q %q %d %t", p.URL, p.RequestBodyMode, p.ResponseBodyMode, p.Timeout, p.FailOpen)
+	return fmt.Sprintf("envoy.filters.http.ext_proc.%x", xxhash.Sum64String(key))
+}
+
+// getExternalProcessorClusterName returns the name of the cluster for the external processor url.
+func getExternalProcessorClusterName(u *url.URL) string {
+	return fmt.Sprintf("pomerium-external-processor-%x", xxhash.Sum64String(u.String()))
+}
+
+// buildExternalProcessorFilters builds ext_proc filters for the distinct external processor options
+// used by policies. Routes disable every ext_proc filter except their own.
+//
+// The filters are placed after ext_authz, so only authorized requests are sent to an external
+// processor.
+func buildExternalProcessorFilters(options *config.Options) []*envoy_http_connection_manager.HttpFilter {
+	var filters []*envoy_http_connection_manager.HttpFilter
+	seen := make(map[string]struct{})
+	for _, p := range options.GetAllPolicies() {
+		if p.ExternalProcessor == nil {
+			continue
+		}
+		u, err := p.ExternalProcessor.GetURL()
+		if err != nil {
+			continue
+		}
+
+		name := getExternalProcessorFilterName(p.ExternalProcessor)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		var messageTimeout *durationpb.Duration
+		if p.ExternalProcessor.Timeout > 0 {
+			messageTimeout = durationpb.New(p.ExternalProcessor.Timeout)
+		}
+		filters = append(filters, &envoy_http_connection_manager.HttpFilter{
+			Name: name,
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: protoutil.NewAny(&envoy_extensions_filters_http_ext_proc_v3.ExternalProcessor{
+					GrpcService: &envoy_config_core_v3.GrpcService{
+						TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: getExternalProcessorClusterName(u),
+							},
+						},
+					},
+					FailureModeAllow: p.ExternalProcessor.FailOpen,
+					ProcessingMode: &envoy_extensions_filters_http_ext_proc_v3.ProcessingMode{
+						RequestHeaderMode:  envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_SEND,
+						ResponseHeaderMode: envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_SEND,
+						RequestBodyMode:    getExternalProcessorBodySendMode(p.ExternalProcessor.RequestBodyMode),
+						ResponseBodyMode:   getExternalProcessorBodySendMode(p.ExternalProcessor.ResponseBodyMode),
+					},
+					MessageTimeout: messageTimeout,
+					StatPrefix:     "pomerium",
+				}),
+			},
+		})
+	}
+	return filters
+}
+
+func getExternalProcessorBodySendMode(mode config.ExternalProcessorBodyMode) envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_BodySendMode {
+	switch mode {
+	case config.ExternalProcessorBodyModeBuffered:
+		return envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_BUFFERED
+	case config.ExternalProcessorBodyModeStreamed:
+		return envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_STREAMED
+	default:
+		return envoy_extensions_filters_http_ext_proc_v3.ProcessingMode_NONE
+	}
+}
+
+// setExternalProcessorPerFilterConfig disables every ext_proc filter on the route except the one
+// for the policy's external processor. The policy may be nil for routes which are never processed.
+func setExternalProcessorPerFilterConfig(
+	options *config.Options,
+	policy *config.Policy,
+	typedPerFilterConfig map[string]*any.Any,
+) {
+	var enabled string
+	if policy != nil && policy.ExternalProcessor != nil {
+		enabled = getExternalProcessorFilterName(policy.ExternalProcessor)
+	}
+
+	for _, p := range options.GetAllPolicies() {
+		if p.ExternalProcessor == nil {
+			continue
+		}
+
+		name := getExternalProcessorFilterName(p.ExternalProcessor)
+		if name == enabled {
+			continue
+		}
+		typedPerFilterConfig[name] = marshalAny(&envoy_extensions_filters_http_ext_proc_v3.ExtProcPerRoute{
+			Override: &envoy_extensions_filters_http_ext_proc_v3.ExtProcPerRoute_Disabled{
+				Disabled: true,
+			},
+		})
+	}
+}
+
+// buildExternalProcessorClusters builds a cluster for each distinct external processor url.
+func (b *Builder) buildExternalProcessorClusters(ctx context.Context, cfg *config.Config) ([]*envoy_config_cluster_v3.Cluster, error) {
+	var clusters []*envoy_config_cluster_v3.Cluster
+	seen := make(map[string]struct{})
+	for _, p := range cfg.Options.GetAllPolicies() {
+		if p.ExternalProcessor == nil {
+			continue
+		}
+		u, err := p.ExternalProcessor.GetURL()
+		if err != nil {
+			return nil, fmt.Errorf("invalid external processor: %w", err)
+		}
+
+		name := getExternalProcessorClusterName(u)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		cluster, err := b.buildInternalCluster(ctx, cfg, name, []*url.URL{u}, upstreamProtocolHTTP2)
+		if err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
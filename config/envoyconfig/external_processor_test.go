@@ -0,0 +1,101 @@
+package envoyconfig
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
+	"github.com/pomerium/pomerium/internal/testutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func Test_buildExternalProcessorFilters(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, buildExternalProcessorFilters(&config.Options{}))
+
+	processor := &config.PolicyExternalProcessor{
+		URL:             "http://ext-proc.example.com:9000",
+		RequestBodyMode: config.ExternalProcessorBodyModeBuffered,
+		Timeout:         time.Second,
+		FailOpen:        true,
+	}
+	options := &config.Options{
+		Policies: []config.Policy{
+			{From: "https://a.example.com", ExternalProcessor: processor},
+			{From: "https://b.example.com", ExternalProcessor: processor},
+			{From: "https://c.example.com"},
+		},
+	}
+	filters := buildExternalProcessorFilters(options)
+	require.Len(t, filters, 1, "policies with the same options should share filters")
+
+	assert.Equal(t, getExternalProcessorFilterName(processor), filters[0].GetName())
+	testutil.AssertProtoJSONEqual(t, `{
+		"@type": "type.googleapis.com/envoy.extensions.filters.http.ext_proc.v3.ExternalProcessor",
+		"failureModeAllow": true,
+		"grpcService": {
+			"envoyGrpc": {
+				"clusterName": "`+getExternalProcessorClusterName(&url.URL{Scheme: "http", Host: "ext-proc.example.com:9000"})+`"
+			}
+		},
+		"messageTimeout": "1s",
+		"processingMode": {
+			"requestBodyMode": "BUFFERED",
+			"requestHeaderMode": "SEND",
+			"responseHeaderMode": "SEND"
+		},
+		"statPrefix": "pomerium"
+	}`, filters[0].GetTypedConfig())
+}
+
+func Test_buildRoutesForPolicyExternalProcessor(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	policy := config.Policy{
+		From:              "https://example.com",
+		To:                mustParseWeightedURLs(t, "https://to.example.com"),
+		ExternalProcessor: &config.PolicyExternalProcessor{URL: "http://ext-proc.example.com:9000"},
+	}
+	other := &config.PolicyExternalProcessor{URL: "https://other-ext-proc.example.com"}
+	options := &config.Options{
+		DefaultUpstreamTimeout: time.Second * 3,
+		SharedKey:              cryptutil.NewBase64Key(),
+		Policies: []config.Policy{
+			policy,
+			{From: "https://other.example.com", ExternalProcessor: other},
+		},
+	}
+	name := getExternalProcessorFilterName(policy.ExternalProcessor)
+	otherName := getExternalProcessorFilterName(other)
+
+	b := &Builder{filemgr: filemgr.NewManager()}
+	routes, err := b.buildRoutesForPolicy(&config.Config{Options: options}, &policy, "policy-0")
+	require.NoError(t, err)
+	require.Len(t, routes, 1)
+
+	disabled := `{
+		"@type": "type.googleapis.com/envoy.extensions.filters.http.ext_proc.v3.ExtProcPerRoute",
+		"disabled": true
+	}`
+	assert.NotContains(t, routes[0].GetTypedPerFilterConfig(), name)
+	testutil.AssertProtoJSONEqual(t, disabled, routes[0].GetTypedPerFilterConfig()[otherName])
+
+	route := b.buildControlPlanePathRoute(options, "/.pomerium")
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()[name])
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()[otherName])
+
+	clusters, err := b.buildExternalProcessorClusters(context.Background(), &config.Config{Options: options})
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, getExternalProcessorClusterName(&url.URL{Scheme: "http", Host: "ext-proc.example.com:9000"}), clusters[0].GetName())
+}
@@ -15,9 +15,11 @@ import (
 	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_transport_sockets_quic_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/quic/v3"
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
@@ -43,6 +45,64 @@ var tlsParams = &envoy_extensions_transport_sockets_tls_v3.TlsParameters{
 	TlsMinimumProtocolVersion: envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_2,
 }
 
+// postQuantumECDHCurves prefers the hybrid X25519+Kyber768 key exchange, falling back to envoy's
+// default curves for clients which don't support it. The hybrid key exchange is only negotiated
+// over TLS 1.3.
+var postQuantumECDHCurves = []string{"X25519Kyber768Draft00", "X25519", "P-256"}
+
+// getDownstreamTLSParams returns the TLS parameters of a downstream listener, overridden by the
+// listener's TLS settings. In FIPS mode the defaults are restricted to FIPS-approved cipher suites
+// and curves.
+func getDownstreamTLSParams(
+	options *config.Options,
+	settings *config.TLSSettings,
+) *envoy_extensions_transport_sockets_tls_v3.TlsParameters {
+	params := proto.Clone(tlsParams).(*envoy_extensions_transport_sockets_tls_v3.TlsParameters)
+	if options.TLSPostQuantumKeyExchange {
+		params.EcdhCurves = postQuantumECDHCurves
+	}
+	if options.FIPSMode {
+		params.CipherSuites = config.FIPSCipherSuites
+		params.EcdhCurves = config.FIPSCurves
+	}
+	if settings.MinVersion != "" {
+		params.TlsMinimumProtocolVersion = envoyTLSVersion(settings.MinVersion)
+	}
+	if settings.MaxVersion != "" {
+		params.TlsMaximumProtocolVersion = envoyTLSVersion(settings.MaxVersion)
+	}
+	if len(settings.CipherSuites) > 0 {
+		params.CipherSuites = settings.CipherSuites
+	}
+	if len(settings.Curves) > 0 {
+		params.EcdhCurves = settings.Curves
+	}
+	return params
+}
+
+func envoyTLSVersion(version string) envoy_extensions_transport_sockets_tls_v3.TlsParameters_TlsProtocol {
+	switch version {
+	case config.TLSVersion10:
+		return envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_0
+	case config.TLSVersion11:
+		return envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_1
+	case config.TLSVersion12:
+		return envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_2
+	case config.TLSVersion13:
+		return envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_3
+	default:
+		return envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLS_AUTO
+	}
+}
+
+// getDownstreamALPNProtos returns the listener's ALPN protocols if set, or the defaults.
+func getDownstreamALPNProtos(settings *config.TLSSettings, defaults []string) []string {
+	if len(settings.ALPN) > 0 {
+		return settings.ALPN
+	}
+	return defaults
+}
+
 // BuildListeners builds envoy listeners from the given config.
 func (b *Builder) BuildListeners(
 	ctx context.Context,
@@ -59,6 +119,14 @@ func (b *Builder) BuildListeners(
 		listeners = append(listeners, li)
 	}
 
+	if (config.IsAuthenticate(cfg.Options.Services) || config.IsProxy(cfg.Options.Services)) && cfg.Options.HTTP3 {
+		li, err := b.buildMainQUICListener(ctx, cfg, fullyStatic)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, li)
+	}
+
 	if config.IsAuthorize(cfg.Options.Services) || config.IsDataBroker(cfg.Options.Services) {
 		li, err := b.buildGRPCListener(ctx, cfg)
 		if err != nil {
@@ -169,6 +237,66 @@ func (b *Builder) buildMainListener(
 	return li, nil
 }
 
+// buildMainQUICListener builds a listener which serves the main routes over HTTP/3.
+func (b *Builder) buildMainQUICListener(
+	ctx context.Context,
+	cfg *config.Config,
+	fullyStatic bool,
+) (*envoy_config_listener_v3.Listener, error) {
+	li := newEnvoyListener("quic-ingress")
+	li.Address = buildAddress(cfg.Options.GetHTTP3Addr(), 443)
+	li.Address.GetSocketAddress().Protocol = envoy_config_core_v3.SocketAddress_UDP
+	li.UdpListenerConfig = &envoy_config_listener_v3.UdpListenerConfig{
+		QuicOptions: &envoy_config_listener_v3.QuicProtocolOptions{},
+		DownstreamSocketConfig: &envoy_config_core_v3.UdpSocketConfig{
+			PreferGro: wrapperspb.Bool(true),
+		},
+	}
+
+	mgr, err := b.buildMainHTTPConnectionManager(ctx, cfg, fullyStatic)
+	if err != nil {
+		return nil, err
+	}
+	mgr.CodecType = envoy_http_connection_manager.HttpConnectionManager_HTTP3
+	mgr.HttpProtocolOptions = nil
+	mgr.Http3ProtocolOptions = &envoy_config_core_v3.Http3ProtocolOptions{}
+
+	allCertificates, err := getAllCertificates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsContext, err := b.buildDownstreamTLSContextMulti(ctx, cfg, allCertificates)
+	if err != nil {
+		return nil, err
+	}
+	tlsContext.CommonTlsContext.AlpnProtocols = []string{"h3"}
+	// QUIC always uses TLS 1.3, so the main listener's protocol versions don't apply
+	tlsContext.CommonTlsContext.TlsParams.TlsMinimumProtocolVersion = tlsParams.TlsMinimumProtocolVersion
+	tlsContext.CommonTlsContext.TlsParams.TlsMaximumProtocolVersion = tlsParams.TlsMaximumProtocolVersion
+
+	li.FilterChains = []*envoy_config_listener_v3.FilterChain{{
+		Filters: []*envoy_config_listener_v3.Filter{HTTPConnectionManagerFilter(mgr)},
+		TransportSocket: &envoy_config_core_v3.TransportSocket{
+			Name: "envoy.transport_sockets.quic",
+			ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{
+				TypedConfig: marshalAny(&envoy_extensions_transport_sockets_quic_v3.QuicDownstreamTransport{
+					DownstreamTlsContext: tlsContext,
+				}),
+			},
+		},
+	}}
+	return li, nil
+}
+
+// getAltSvcHeaderValue returns the Alt-Svc header value used to advertise HTTP/3.
+func getAltSvcHeaderValue(options *config.Options) string {
+	port := "443"
+	if _, p, err := net.SplitHostPort(options.GetHTTP3Addr()); err == nil && p != "" {
+		port = p
+	}
+	return fmt.Sprintf(`h3=":%s"; ma=86400`, port)
+}
+
 func (b *Builder) buildMetricsListener(cfg *config.Config) (*envoy_config_listener_v3.Listener, error) {
 	filter, err := b.buildMetricsHTTPConnectionManagerFilter()
 	if err != nil {
@@ -188,13 +316,11 @@ func (b *Builder) buildMetricsListener(cfg *config.Config) (*envoy_config_listen
 	if cert != nil {
 		dtc := &envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext{
 			CommonTlsContext: &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{
-				TlsParams: tlsParams,
-				TlsCertificates: []*envoy_extensions_transport_sockets_tls_v3.TlsCertificate{
-					b.envoyTLSCertificateFromGoTLSCertificate(context.TODO(), cert),
-				},
-				AlpnProtocols: []string{"h2", "http/1.1"},
+				TlsParams:     getDownstreamTLSParams(cfg.Options, &cfg.Options.ListenerTLS.Metrics),
+				AlpnProtocols: getDownstreamALPNProtos(&cfg.Options.ListenerTLS.Metrics, []string{"h2", "http/1.1"}),
 			},
 		}
+		b.setTLSCertificate(context.TODO(), dtc.CommonTlsContext, metricsCertificateSecretName, cert)
 
 		if cfg.Options.MetricsClientCA != "" {
 			bs, err := base64.StdEncoding.DecodeString(cfg.Options.MetricsClientCA)
@@ -253,6 +379,18 @@ func (b *Builder) buildMainHTTPConnectionManagerFilter(
 	cfg *config.Config,
 	fullyStatic bool,
 ) (*envoy_config_listener_v3.Filter, error) {
+	mgr, err := b.buildMainHTTPConnectionManager(ctx, cfg, fullyStatic)
+	if err != nil {
+		return nil, err
+	}
+	return HTTPConnectionManagerFilter(mgr), nil
+}
+
+func (b *Builder) buildMainHTTPConnectionManager(
+	ctx context.Context,
+	cfg *config.Config,
+	fullyStatic bool,
+) (*envoy_http_connection_manager.HttpConnectionManager, error) {
 	var grpcClientTimeout *durationpb.Duration
 	if cfg.Options.GRPCClientTimeout != 0 {
 		grpcClientTimeout = durationpb.New(cfg.Options.GRPCClientTimeout)
@@ -260,14 +398,23 @@ func (b *Builder) buildMainHTTPConnectionManagerFilter(
 		grpcClientTimeout = durationpb.New(30 * time.Second)
 	}
 
-	filters := []*envoy_http_connection_manager.HttpFilter{
+	// preflight requests are answered before authorization
+	filters := buildCORSFilters(cfg.Options)
+	filters = append(filters,
 		LuaFilter(luascripts.RemoveImpersonateHeaders),
 		LuaFilter(luascripts.SetClientCertificateMetadata),
 		ExtAuthzFilter(grpcClientTimeout),
 		LuaFilter(luascripts.ExtAuthzSetCookie),
 		LuaFilter(luascripts.CleanUpstream),
 		LuaFilter(luascripts.RewriteHeaders),
-	}
+	)
+	// responses are compressed after their bodies are rewritten
+	filters = append(filters, buildCompressionFilters(cfg.Options)...)
+	filters = append(filters, LuaFilter(luascripts.RewriteResponseBody))
+	// external processors see responses before they are rewritten and compressed, and cached
+	// responses are processed too
+	filters = append(filters, buildExternalProcessorFilters(cfg.Options)...)
+	filters = append(filters, buildResponseCacheFilters(cfg.Options)...)
 	filters = append(filters, HTTPRouterFilter())
 
 	var maxStreamDuration *durationpb.Duration
@@ -303,6 +450,12 @@ func (b *Builder) buildMainHTTPConnectionManagerFilter(
 		LocalReplyConfig:  b.buildLocalReplyConfig(cfg.Options),
 		NormalizePath:     wrapperspb.Bool(true),
 	}
+	if cfg.Options.MeshIdentity.GetSource() == config.MeshIdentitySourceXFCC {
+		// keep the x-forwarded-client-cert header set by the mesh, which is stripped by default.
+		// Envoy still strips it from connections without a client certificate, and authorize only
+		// trusts it from the mesh identity's trusted peers.
+		mgr.ForwardClientCertDetails = envoy_http_connection_manager.HttpConnectionManager_FORWARD_ONLY
+	}
 
 	if fullyStatic {
 		routeConfiguration, err := b.buildMainRouteConfiguration(ctx, cfg)
@@ -324,7 +477,7 @@ func (b *Builder) buildMainHTTPConnectionManagerFilter(
 		}
 	}
 
-	return HTTPConnectionManagerFilter(mgr), nil
+	return mgr, nil
 }
 
 func (b *Builder) buildMetricsHTTPConnectionManagerFilter() (*envoy_config_listener_v3.Filter, error) {
@@ -404,17 +557,16 @@ func (b *Builder) buildGRPCListener(ctx context.Context, cfg *config.Config) (*e
 	if err != nil {
 		return nil, err
 	}
-	envoyCerts, err := b.envoyCertificates(ctx, allCertificates)
-	if err != nil {
-		return nil, err
-	}
 	tlsContext := &envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext{
 		CommonTlsContext: &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{
-			TlsParams:       tlsParams,
-			TlsCertificates: envoyCerts,
-			AlpnProtocols:   []string{"h2"}, // gRPC requires HTTP/2
+			TlsParams: getDownstreamTLSParams(cfg.Options, &cfg.Options.ListenerTLS.GRPC),
+			// gRPC requires HTTP/2
+			AlpnProtocols: getDownstreamALPNProtos(&cfg.Options.ListenerTLS.GRPC, []string{"h2"}),
 		},
 	}
+	if err := b.setDownstreamTLSCertificates(ctx, tlsContext.CommonTlsContext, allCertificates); err != nil {
+		return nil, err
+	}
 	filterChain.TransportSocket = &envoy_config_core_v3.TransportSocket{
 		Name: "tls",
 		ConfigType: &envoy_config_core_v3.TransportSocket_TypedConfig{
@@ -513,17 +665,15 @@ func (b *Builder) buildDownstreamTLSContextMulti(
 	*envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext,
 	error,
 ) {
-	envoyCerts, err := b.envoyCertificates(ctx, certs)
-	if err != nil {
-		return nil, err
-	}
 	dtc := &envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext{
 		CommonTlsContext: &envoy_extensions_transport_sockets_tls_v3.CommonTlsContext{
-			TlsParams:       tlsParams,
-			TlsCertificates: envoyCerts,
-			AlpnProtocols:   getALPNProtos(cfg.Options),
+			TlsParams:     getDownstreamTLSParams(cfg.Options, &cfg.Options.ListenerTLS.Main),
+			AlpnProtocols: getDownstreamALPNProtos(&cfg.Options.ListenerTLS.Main, getALPNProtos(cfg.Options)),
 		},
 	}
+	if err := b.setDownstreamTLSCertificates(ctx, dtc.CommonTlsContext, certs); err != nil {
+		return nil, err
+	}
 	b.buildDownstreamValidationContext(ctx, dtc, cfg)
 	return dtc, nil
 }
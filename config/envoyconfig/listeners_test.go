@@ -11,8 +11,12 @@ import (
 	"text/template"
 
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_transport_sockets_quic_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/quic/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
@@ -68,6 +72,109 @@ func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 	filter, err := b.buildMainHTTPConnectionManagerFilter(context.Background(), &config.Config{Options: options}, false)
 	require.NoError(t, err)
 	testutil.AssertProtoJSONEqual(t, testData(t, "main_http_connection_manager_filter.json", nil), filter)
+
+	t.Run("mesh identity", func(t *testing.T) {
+		options := config.NewDefaultOptions()
+		options.AuthenticateURLString = "https://authenticate.example.com"
+		options.MeshIdentity = &config.MeshIdentity{
+			Source:       config.MeshIdentitySourceXFCC,
+			TrustDomains: []string{"cluster.local"},
+			TrustedPeers: []string{"spiffe://cluster.local/ns/istio-system/sa/ingressgateway"},
+		}
+		filter, err := b.buildMainHTTPConnectionManagerFilter(context.Background(), &config.Config{Options: options}, false)
+		require.NoError(t, err)
+
+		mgr := new(envoy_http_connection_manager.HttpConnectionManager)
+		require.NoError(t, filter.GetTypedConfig().UnmarshalTo(mgr))
+		assert.Equal(t, envoy_http_connection_manager.HttpConnectionManager_FORWARD_ONLY, mgr.GetForwardClientCertDetails())
+	})
+}
+
+func Test_buildMainQUICListener(t *testing.T) {
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	options := config.NewDefaultOptions()
+	options.Cert = aExampleComCert
+	options.Key = aExampleComKey
+	options.HTTP3 = true
+	options.HTTP3Addr = "127.0.0.1:8443"
+	li, err := b.buildMainQUICListener(context.Background(), &config.Config{Options: options}, false)
+	require.NoError(t, err)
+
+	testutil.AssertProtoJSONEqual(t, `{
+		"socketAddress": {
+			"address": "127.0.0.1",
+			"portValue": 8443,
+			"protocol": "UDP"
+		}
+	}`, li.Address)
+	assert.NotNil(t, li.UdpListenerConfig.GetQuicOptions())
+	require.Len(t, li.FilterChains, 1)
+	assert.Equal(t, "envoy.transport_sockets.quic", li.FilterChains[0].GetTransportSocket().GetName())
+
+	transport := new(envoy_extensions_transport_sockets_quic_v3.QuicDownstreamTransport)
+	require.NoError(t, li.FilterChains[0].GetTransportSocket().GetTypedConfig().UnmarshalTo(transport))
+	assert.Equal(t, []string{"h3"}, transport.GetDownstreamTlsContext().GetCommonTlsContext().GetAlpnProtocols())
+
+	mgr := new(envoy_http_connection_manager.HttpConnectionManager)
+	require.NoError(t, li.FilterChains[0].GetFilters()[0].GetTypedConfig().UnmarshalTo(mgr))
+	assert.Equal(t, envoy_http_connection_manager.HttpConnectionManager_HTTP3, mgr.GetCodecType())
+}
+
+func Test_getAltSvcHeaderValue(t *testing.T) {
+	assert.Equal(t, `h3=":443"; ma=86400`, getAltSvcHeaderValue(&config.Options{}))
+	assert.Equal(t, `h3=":8443"; ma=86400`, getAltSvcHeaderValue(&config.Options{Addr: ":8443"}))
+	assert.Equal(t, `h3=":9443"; ma=86400`, getAltSvcHeaderValue(&config.Options{Addr: ":8443", HTTP3Addr: "0.0.0.0:9443"}))
+}
+
+func Test_getDownstreamTLSParams(t *testing.T) {
+	assert.Empty(t, getDownstreamTLSParams(&config.Options{}, &config.TLSSettings{}).GetEcdhCurves())
+
+	params := getDownstreamTLSParams(&config.Options{TLSPostQuantumKeyExchange: true}, &config.TLSSettings{})
+	assert.Equal(t, []string{"X25519Kyber768Draft00", "X25519", "P-256"}, params.GetEcdhCurves())
+	assert.Equal(t, tlsParams.GetCipherSuites(), params.GetCipherSuites())
+	assert.Empty(t, tlsParams.GetEcdhCurves(), "should not modify the default parameters")
+
+	params = getDownstreamTLSParams(&config.Options{TLSPostQuantumKeyExchange: true}, &config.TLSSettings{
+		MinVersion:   "1.3",
+		MaxVersion:   "1.3",
+		CipherSuites: []string{"ECDHE-ECDSA-AES256-GCM-SHA384"},
+		Curves:       []string{"P-384"},
+	})
+	testutil.AssertProtoJSONEqual(t, `{
+		"cipherSuites": ["ECDHE-ECDSA-AES256-GCM-SHA384"],
+		"ecdhCurves": ["P-384"],
+		"tlsMinimumProtocolVersion": "TLSv1_3",
+		"tlsMaximumProtocolVersion": "TLSv1_3"
+	}`, params)
+}
+
+func Test_listenerTLSSettings(t *testing.T) {
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+
+	options := config.NewDefaultOptions()
+	options.Cert = aExampleComCert
+	options.Key = aExampleComKey
+	options.GRPCInsecure = proto.Bool(false)
+	options.ListenerTLS.Main = config.TLSSettings{MinVersion: "1.3", ALPN: []string{"http/1.1"}}
+	cfg := &config.Config{Options: options}
+
+	allCertificates, err := getAllCertificates(cfg)
+	require.NoError(t, err)
+	dtc, err := b.buildDownstreamTLSContextMulti(context.Background(), cfg, allCertificates)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http/1.1"}, dtc.GetCommonTlsContext().GetAlpnProtocols())
+	assert.Equal(t, envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_3,
+		dtc.GetCommonTlsContext().GetTlsParams().GetTlsMinimumProtocolVersion())
+
+	li, err := b.buildGRPCListener(context.Background(), cfg)
+	require.NoError(t, err)
+	grpcDTC := new(envoy_extensions_transport_sockets_tls_v3.DownstreamTlsContext)
+	require.NoError(t, li.GetFilterChains()[0].GetTransportSocket().GetTypedConfig().UnmarshalTo(grpcDTC))
+	assert.Equal(t, []string{"h2"}, grpcDTC.GetCommonTlsContext().GetAlpnProtocols(),
+		"should not apply the main listener settings to the grpc listener")
+	assert.Equal(t, envoy_extensions_transport_sockets_tls_v3.TlsParameters_TLSv1_2,
+		grpcDTC.GetCommonTlsContext().GetTlsParams().GetTlsMinimumProtocolVersion())
 }
 
 func Test_buildDownstreamTLSContext(t *testing.T) {
@@ -13,6 +13,7 @@ var luascripts struct {
 	CleanUpstream                string
 	RemoveImpersonateHeaders     string
 	RewriteHeaders               string
+	RewriteResponseBody          string
 	SetClientCertificateMetadata string
 }
 
@@ -22,6 +23,7 @@ func init() {
 		"luascripts/ext-authz-set-cookie.lua":            &luascripts.ExtAuthzSetCookie,
 		"luascripts/remove-impersonate-headers.lua":      &luascripts.RemoveImpersonateHeaders,
 		"luascripts/rewrite-headers.lua":                 &luascripts.RewriteHeaders,
+		"luascripts/rewrite-response-body.lua":           &luascripts.RewriteResponseBody,
 		"luascripts/set-client-certificate-metadata.lua": &luascripts.SetClientCertificateMetadata,
 	}
 
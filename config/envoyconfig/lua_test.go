@@ -3,6 +3,7 @@ package envoyconfig
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -82,6 +83,179 @@ func TestLuaRewriteHeaders(t *testing.T) {
 	assert.Equal(t, "https://frontend/one/some/uri/", headers["Location"])
 }
 
+func TestLuaRewriteResponseBody(t *testing.T) {
+	bs, err := luaFS.ReadFile("luascripts/rewrite-response-body.lua")
+	require.NoError(t, err)
+
+	metadata := map[string]interface{}{
+		"rewrite_response_body": map[string]interface{}{
+			"content_types": []interface{}{"text/html"},
+			"max_size":      1024,
+			"rules": []interface{}{
+				map[string]interface{}{"from": "http://internal.local:8080/", "to": "https://app.example.com/"},
+				map[string]interface{}{"from": "%.", "to": "!"},
+			},
+		},
+	}
+
+	run := func(t *testing.T, headers map[string]string, body string) string {
+		t.Helper()
+
+		L := lua.NewState()
+		defer L.Close()
+
+		require.NoError(t, L.DoString(string(bs)))
+
+		handle := newLuaResponseHandle(L, headers, metadata, nil)
+		newBuffer := func(get func() string, set func(string)) lua.LValue {
+			return newLuaType(L, map[string]lua.LGFunction{
+				"length": func(L *lua.LState) int {
+					L.Push(lua.LNumber(len(get())))
+					return 1
+				},
+				"getBytes": func(L *lua.LState) int {
+					L.Push(lua.LString(get()[L.CheckInt(2):L.CheckInt(3)]))
+					return 1
+				},
+				"setBytes": func(L *lua.LState) int {
+					set(L.CheckString(2))
+					return 0
+				},
+			})
+		}
+		L.SetField(handle, "body", L.NewFunction(func(L *lua.LState) int {
+			L.Push(newBuffer(func() string { return body }, func(s string) { body = s }))
+			return 1
+		}))
+		// stream the body in two chunks
+		L.SetField(handle, "bodyChunks", L.NewFunction(func(L *lua.LState) int {
+			chunks := []string{body[:len(body)/2], body[len(body)/2:]}
+			i := 0
+			L.Push(L.NewFunction(func(L *lua.LState) int {
+				if i > 0 {
+					body = strings.Join(chunks, "")
+				}
+				if i >= len(chunks) {
+					L.Push(lua.LNil)
+					return 1
+				}
+				idx := i
+				i++
+				L.Push(newBuffer(func() string { return chunks[idx] }, func(s string) { chunks[idx] = s }))
+				return 1
+			}))
+			return 1
+		}))
+
+		err := L.CallByParam(lua.P{
+			Fn:      L.GetGlobal("envoy_on_response"),
+			NRet:    0,
+			Protect: true,
+		}, handle)
+		require.NoError(t, err)
+		return body
+	}
+
+	body := `<a href="http://internal.local:8080/path">%.</a> <img src="http://internal.local:8080/img.png">`
+	t.Run("rewrite", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type":   "text/html; charset=utf-8",
+			"content-length": fmt.Sprint(len(body)),
+		}
+		newBody := run(t, headers, body)
+		assert.Equal(t, `<a href="https://app.example.com/path">!</a> <img src="https://app.example.com/img.png">`, newBody)
+		assert.Equal(t, fmt.Sprint(len(newBody)), headers["content-length"])
+	})
+	t.Run("content type", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type":   "image/png",
+			"content-length": fmt.Sprint(len(body)),
+		}
+		assert.Equal(t, body, run(t, headers, body))
+	})
+	t.Run("too large", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type":   "text/html",
+			"content-length": "2048",
+		}
+		assert.Equal(t, `<a href="https://app.example.com/path">!</a> <img src="https://app.example.com/img.png">`,
+			run(t, headers, body), "should stream large responses")
+		assert.NotContains(t, headers, "content-length")
+	})
+	t.Run("unknown length", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type": "text/html",
+		}
+		assert.Equal(t, `<a href="https://app.example.com/path">!</a> <img src="https://app.example.com/img.png">`,
+			run(t, headers, body), "should stream chunked responses")
+	})
+	t.Run("chunk boundary", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type": "text/html",
+		}
+		body := "http://internal.local:8080/"
+		assert.Equal(t, body, run(t, headers, body), "should not rewrite matches which span chunks")
+	})
+	t.Run("compressed", func(t *testing.T) {
+		headers := map[string]string{
+			"content-type":     "text/html",
+			"content-encoding": "gzip",
+			"content-length":   fmt.Sprint(len(body)),
+		}
+		assert.Equal(t, body, run(t, headers, body))
+	})
+}
+
+func TestLuaRewriteResponseBodyRequest(t *testing.T) {
+	bs, err := luaFS.ReadFile("luascripts/rewrite-response-body.lua")
+	require.NoError(t, err)
+
+	metadata := map[string]interface{}{
+		"rewrite_response_body": map[string]interface{}{
+			"content_types": []interface{}{"text/html", "application/json"},
+			"max_size":      1024,
+			"rules": []interface{}{
+				map[string]interface{}{"from": "http://internal.local:8080/", "to": "https://app.example.com/"},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		strip   bool
+	}{
+		{"no accept", map[string]string{}, true},
+		{"html", map[string]string{"accept": "text/html,application/xhtml+xml;q=0.9"}, true},
+		{"wildcard", map[string]string{"accept": "image/webp, */*;q=0.8"}, true},
+		{"type wildcard", map[string]string{"accept": "application/*"}, true},
+		{"image", map[string]string{"accept": "image/avif,image/webp"}, false},
+		{"head", map[string]string{":method": "HEAD", "accept": "text/html"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			L := lua.NewState()
+			defer L.Close()
+
+			require.NoError(t, L.DoString(string(bs)))
+
+			tc.headers["accept-encoding"] = "gzip"
+			handle := newLuaResponseHandle(L, tc.headers, metadata, nil)
+			err := L.CallByParam(lua.P{
+				Fn:      L.GetGlobal("envoy_on_request"),
+				NRet:    0,
+				Protect: true,
+			}, handle)
+			require.NoError(t, err)
+
+			if tc.strip {
+				assert.NotContains(t, tc.headers, "accept-encoding")
+			} else {
+				assert.Contains(t, tc.headers, "accept-encoding")
+			}
+		})
+	}
+}
+
 func newLuaResponseHandle(L *lua.LState,
 	headers map[string]string,
 	metadata map[string]interface{},
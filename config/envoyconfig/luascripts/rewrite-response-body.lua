@@ -0,0 +1,135 @@
+function replace_all(str, from, to)
+    local parts = {}
+    local start = 1
+    while true do
+        local i, j = str:find(from, start, true)
+        if i == nil then
+            break
+        end
+        table.insert(parts, str:sub(start, i - 1))
+        table.insert(parts, to)
+        start = j + 1
+    end
+    if start == 1 then
+        return str
+    end
+    table.insert(parts, str:sub(start))
+    return table.concat(parts)
+end
+
+function rewrite(str, rules)
+    for _, rule in ipairs(rules) do
+        str = replace_all(str, rule.from, rule.to)
+    end
+    return str
+end
+
+function media_type(value)
+    return value:lower():match("^%s*([^;%s]+)")
+end
+
+function has_content_type(content_types, content_type)
+    if content_type == nil then
+        return false
+    end
+    content_type = media_type(content_type)
+    for _, ct in ipairs(content_types) do
+        if ct == content_type then
+            return true
+        end
+    end
+    return false
+end
+
+-- accepts_content_type returns true if the accept header allows any of the content types
+function accepts_content_type(content_types, accept)
+    if accept == nil or accept == "" then
+        return true
+    end
+    for media_range in accept:gmatch("[^,]+") do
+        local mt = media_type(media_range)
+        if mt == "*/*" then
+            return true
+        end
+        for _, ct in ipairs(content_types) do
+            if mt == ct or (mt:sub(-2) == "/*" and ct:sub(1, mt:len() - 1) == mt:sub(1, -2)) then
+                return true
+            end
+        end
+    end
+    return false
+end
+
+function envoy_on_request(request_handle)
+    local headers = request_handle:headers()
+    local metadata = request_handle:metadata()
+
+    local rewrite_response_body = metadata:get("rewrite_response_body")
+    if not rewrite_response_body or headers:get(":method") == "HEAD" then
+        return
+    end
+
+    -- compressed responses can't be rewritten, so only ask for an uncompressed response
+    -- when the client accepts one of the content types which are rewritten
+    if accepts_content_type(rewrite_response_body.content_types, headers:get("accept")) then
+        headers:remove("accept-encoding")
+    end
+end
+
+function envoy_on_response(response_handle)
+    local headers = response_handle:headers()
+    local metadata = response_handle:metadata()
+
+    -- should be in the form:
+    -- {
+    --   "content_types": ["text/html"],
+    --   "max_size": 1048576,
+    --   "rules": [{
+    --     "from": "http://internal.local:8080/",
+    --     "to": "https://app.example.com/"
+    --   }]
+    -- }
+    local rewrite_response_body = metadata:get("rewrite_response_body")
+    if not rewrite_response_body then
+        return
+    end
+
+    if not has_content_type(rewrite_response_body.content_types, headers:get("content-type")) then
+        return
+    end
+
+    local content_encoding = headers:get("content-encoding")
+    if content_encoding ~= nil and content_encoding ~= "identity" then
+        return
+    end
+
+    local rules = rewrite_response_body.rules
+
+    -- responses with a known size up to max_size are buffered and rewritten as a whole
+    local content_length = tonumber(headers:get("content-length") or "")
+    if content_length ~= nil and content_length <= rewrite_response_body.max_size then
+        local body = response_handle:body()
+        if body == nil then
+            return
+        end
+
+        local str = body:getBytes(0, body:length())
+        local newstr = rewrite(str, rules)
+        if newstr ~= str then
+            body:setBytes(newstr)
+            headers:replace("content-length", tostring(newstr:len()))
+        end
+        return
+    end
+
+    -- all other responses are streamed and each chunk is rewritten separately, so
+    -- a match which spans two chunks is left as is
+    headers:remove("content-length")
+    for chunk in response_handle:bodyChunks() do
+        local str = chunk:getBytes(0, chunk:length())
+        local newstr = rewrite(str, rules)
+        if newstr ~= str then
+            chunk:setBytes(newstr)
+        end
+    end
+end
@@ -118,6 +118,14 @@ func buildUpstreamALPN(upstreamProtocol upstreamProtocolConfig) []string {
 
 func getUpstreamProtocolForPolicy(_ context.Context, policy *config.Policy) upstreamProtocolConfig {
 	upstreamProtocol := upstreamProtocolAuto
+	if policy.ConnectionPool != nil {
+		switch policy.ConnectionPool.Protocol {
+		case config.UpstreamProtocolHTTP1:
+			upstreamProtocol = upstreamProtocolHTTP1
+		case config.UpstreamProtocolHTTP2:
+			upstreamProtocol = upstreamProtocolHTTP2
+		}
+	}
 	if policy.AllowWebsockets {
 		// #2388, force http/1 when using web sockets
 		log.WarnWebSocketHTTP1_1(getClusterID(policy))
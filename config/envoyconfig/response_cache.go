@@ -0,0 +1,102 @@
+package envoyconfig
+
+import (
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_common_async_files_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/async_files/v3"
+	envoy_extensions_filters_http_cache_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/cache/v3"
+	envoy_http_connection_manager "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_http_cache_file_system_http_cache_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/http/cache/file_system_http_cache/v3"
+	envoy_extensions_http_cache_simple_http_cache_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/http/cache/simple_http_cache/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// responseCacheBackends are the response cache backends, in the order their filters are added.
+var responseCacheBackends = []config.ResponseCacheBackend{
+	config.ResponseCacheBackendMemory,
+	config.ResponseCacheBackendDisk,
+}
+
+// getResponseCacheFilterName returns the name of the response cache filter for the given backend.
+func getResponseCacheFilterName(backend config.ResponseCacheBackend) string {
+	return "envoy.filters.http.cache." + string(backend)
+}
+
+// buildResponseCacheFilters builds a cache filter for each backend used by a policy.
+//
+// The cache filters are placed after ext_authz, so requests are always authorized before a cached
+// response is returned, and routes which don't use a backend disable its filter.
+func buildResponseCacheFilters(options *config.Options) []*envoy_http_connection_manager.HttpFilter {
+	var filters []*envoy_http_connection_manager.HttpFilter
+	for _, backend := range responseCacheBackends {
+		if !options.HasAnyResponseCachePolicy(backend) {
+			continue
+		}
+
+		var typedConfig proto.Message
+		switch backend {
+		case config.ResponseCacheBackendDisk:
+			diskConfig := &envoy_extensions_http_cache_file_system_http_cache_v3.FileSystemHttpCacheConfig{
+				ManagerConfig: &envoy_extensions_common_async_files_v3.AsyncFileManagerConfig{
+					Id: "pomerium-response-cache",
+					ManagerType: &envoy_extensions_common_async_files_v3.AsyncFileManagerConfig_ThreadPool_{
+						ThreadPool: &envoy_extensions_common_async_files_v3.AsyncFileManagerConfig_ThreadPool{},
+					},
+				},
+				CachePath:       options.ResponseCache.Directory,
+				CreateCachePath: true,
+			}
+			if options.ResponseCache.MaxSizeBytes > 0 {
+				diskConfig.MaxCacheSizeBytes = wrapperspb.UInt64(options.ResponseCache.MaxSizeBytes)
+			}
+			typedConfig = diskConfig
+		default:
+			typedConfig = &envoy_extensions_http_cache_simple_http_cache_v3.SimpleHttpCacheConfig{}
+		}
+
+		var allowedVaryHeaders []*envoy_type_matcher_v3.StringMatcher
+		for _, h := range options.ResponseCache.GetAllowedVaryHeaders() {
+			allowedVaryHeaders = append(allowedVaryHeaders, &envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: h},
+				IgnoreCase:   true,
+			})
+		}
+
+		filters = append(filters, &envoy_http_connection_manager.HttpFilter{
+			Name: getResponseCacheFilterName(backend),
+			ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+				TypedConfig: protoutil.NewAny(&envoy_extensions_filters_http_cache_v3.CacheConfig{
+					TypedConfig:        protoutil.NewAny(typedConfig),
+					AllowedVaryHeaders: allowedVaryHeaders,
+					MaxBodyBytes:       options.ResponseCache.MaxBodyBytes,
+				}),
+			},
+		})
+	}
+	return filters
+}
+
+// setResponseCachePerFilterConfig disables every response cache filter on the route except the
+// one for the policy's backend. The policy may be nil for routes which are never cached.
+func setResponseCachePerFilterConfig(
+	options *config.Options,
+	policy *config.Policy,
+	typedPerFilterConfig map[string]*any.Any,
+) {
+	for _, backend := range responseCacheBackends {
+		if !options.HasAnyResponseCachePolicy(backend) {
+			continue
+		}
+		if policy != nil && policy.ResponseCache != nil && policy.ResponseCache.GetBackend() == backend {
+			continue
+		}
+		typedPerFilterConfig[getResponseCacheFilterName(backend)] = marshalAny(&envoy_config_route_v3.FilterConfig{
+			Disabled: true,
+		})
+	}
+}
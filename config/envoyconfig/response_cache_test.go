@@ -0,0 +1,106 @@
+package envoyconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
+	"github.com/pomerium/pomerium/internal/testutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func Test_buildResponseCacheFilters(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, buildResponseCacheFilters(&config.Options{}))
+
+	options := &config.Options{
+		ResponseCache: config.ResponseCacheSettings{
+			MaxBodyBytes: 1 << 20,
+			Directory:    "/var/cache/pomerium",
+			MaxSizeBytes: 1 << 30,
+		},
+		Policies: []config.Policy{
+			{From: "https://a.example.com", ResponseCache: &config.PolicyResponseCache{}},
+			{From: "https://b.example.com", ResponseCache: &config.PolicyResponseCache{Backend: config.ResponseCacheBackendDisk}},
+		},
+	}
+	filters := buildResponseCacheFilters(options)
+	require.Len(t, filters, 2)
+	testutil.AssertProtoJSONEqual(t, `{
+		"name": "envoy.filters.http.cache.memory",
+		"typedConfig": {
+			"@type": "type.googleapis.com/envoy.extensions.filters.http.cache.v3.CacheConfig",
+			"allowedVaryHeaders": [{ "exact": "accept-encoding", "ignoreCase": true }],
+			"maxBodyBytes": 1048576,
+			"typedConfig": {
+				"@type": "type.googleapis.com/envoy.extensions.http.cache.simple_http_cache.v3.SimpleHttpCacheConfig"
+			}
+		}
+	}`, filters[0])
+	testutil.AssertProtoJSONEqual(t, `{
+		"name": "envoy.filters.http.cache.disk",
+		"typedConfig": {
+			"@type": "type.googleapis.com/envoy.extensions.filters.http.cache.v3.CacheConfig",
+			"allowedVaryHeaders": [{ "exact": "accept-encoding", "ignoreCase": true }],
+			"maxBodyBytes": 1048576,
+			"typedConfig": {
+				"@type": "type.googleapis.com/envoy.extensions.http.cache.file_system_http_cache.v3.FileSystemHttpCacheConfig",
+				"cachePath": "/var/cache/pomerium",
+				"createCachePath": true,
+				"managerConfig": {
+					"id": "pomerium-response-cache",
+					"threadPool": {}
+				},
+				"maxCacheSizeBytes": "1073741824"
+			}
+		}
+	}`, filters[1])
+}
+
+func Test_buildRoutesForPolicyResponseCache(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	policy := config.Policy{
+		From:            "https://example.com",
+		To:              mustParseWeightedURLs(t, "https://to.example.com"),
+		AllowWebsockets: true,
+		ResponseCache:   &config.PolicyResponseCache{},
+	}
+	options := &config.Options{
+		DefaultUpstreamTimeout: time.Second * 3,
+		SharedKey:              cryptutil.NewBase64Key(),
+		ResponseCache:          config.ResponseCacheSettings{Directory: "/var/cache/pomerium"},
+		Policies: []config.Policy{
+			policy,
+			{From: "https://disk.example.com", ResponseCache: &config.PolicyResponseCache{Backend: config.ResponseCacheBackendDisk}},
+		},
+	}
+
+	b := &Builder{filemgr: filemgr.NewManager()}
+	routes, err := b.buildRoutesForPolicy(&config.Config{Options: options}, &policy, "policy-0")
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+
+	disabled := `{
+		"@type": "type.googleapis.com/envoy.config.route.v3.FilterConfig",
+		"disabled": true
+	}`
+	// websocket upgrades are never cached
+	testutil.AssertProtoJSONEqual(t, disabled, routes[0].GetTypedPerFilterConfig()["envoy.filters.http.cache.memory"])
+	testutil.AssertProtoJSONEqual(t, disabled, routes[0].GetTypedPerFilterConfig()["envoy.filters.http.cache.disk"])
+
+	assert.NotContains(t, routes[1].GetTypedPerFilterConfig(), "envoy.filters.http.cache.memory")
+	testutil.AssertProtoJSONEqual(t, disabled, routes[1].GetTypedPerFilterConfig()["envoy.filters.http.cache.disk"])
+
+	route := b.buildControlPlanePathRoute(options, "/.pomerium")
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()["envoy.filters.http.cache.memory"])
+	testutil.AssertProtoJSONEqual(t, disabled, route.GetTypedPerFilterConfig()["envoy.filters.http.cache.disk"])
+}
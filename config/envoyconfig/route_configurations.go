@@ -56,7 +56,7 @@ func (b *Builder) buildMainRouteConfiguration(
 			// if this is a gRPC service domain and we're supposed to handle that, add those routes
 			if (config.IsAuthorize(cfg.Options.Services) && urlsMatchHost(authorizeURLs, host)) ||
 				(config.IsDataBroker(cfg.Options.Services) && urlsMatchHost(dataBrokerURLs, host)) {
-				rs, err := b.buildGRPCRoutes()
+				rs, err := b.buildGRPCRoutes(cfg.Options)
 				if err != nil {
 					return nil, err
 				}
@@ -96,6 +96,10 @@ func (b *Builder) buildMainRouteConfiguration(
 	if err != nil {
 		return nil, err
 	}
+	if cfg.Options.HTTP3 {
+		// advertise the http/3 listener to clients
+		rc.ResponseHeadersToAdd = append(rc.ResponseHeadersToAdd, mkEnvoyHeader("Alt-Svc", getAltSvcHeaderValue(cfg.Options)))
+	}
 
 	return rc, nil
 }
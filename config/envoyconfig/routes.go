@@ -3,6 +3,8 @@ package envoyconfig
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 	"net/url"
 	"sort"
 	"strings"
@@ -10,14 +12,17 @@ import (
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/sets"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
@@ -25,7 +30,7 @@ const (
 	httpCluster = "pomerium-control-plane-http"
 )
 
-func (b *Builder) buildGRPCRoutes() ([]*envoy_config_route_v3.Route, error) {
+func (b *Builder) buildGRPCRoutes(options *config.Options) ([]*envoy_config_route_v3.Route, error) {
 	action := &envoy_config_route_v3.Route_Route{
 		Route: &envoy_config_route_v3.RouteAction{
 			ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{
@@ -33,7 +38,7 @@ func (b *Builder) buildGRPCRoutes() ([]*envoy_config_route_v3.Route, error) {
 			},
 		},
 	}
-	return []*envoy_config_route_v3.Route{{
+	r := &envoy_config_route_v3.Route{
 		Name: "pomerium-grpc",
 		Match: &envoy_config_route_v3.RouteMatch{
 			PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{
@@ -45,7 +50,9 @@ func (b *Builder) buildGRPCRoutes() ([]*envoy_config_route_v3.Route, error) {
 		TypedPerFilterConfig: map[string]*any.Any{
 			PerFilterConfigExtAuthzName: PerFilterConfigExtAuthzDisabled(),
 		},
-	}}, nil
+	}
+	setExternalProcessorPerFilterConfig(options, nil, r.TypedPerFilterConfig)
+	return []*envoy_config_route_v3.Route{r}, nil
 }
 
 func (b *Builder) buildPomeriumHTTPRoutes(
@@ -61,9 +68,18 @@ func (b *Builder) buildPomeriumHTTPRoutes(
 		return nil, err
 	}
 	if !isFrontingAuthenticate {
+		// the admin console route must come before the other dashboard routes
+		if policy := options.GetAdminConsolePolicy(); policy != nil {
+			r, err := b.buildAdminConsoleRoute(options, policy)
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, r)
+		}
 		routes = append(routes,
 			b.buildControlPlanePathRoute(options, "/ping"),
 			b.buildControlPlanePathRoute(options, "/healthz"),
+			b.buildControlPlanePathRoute(options, "/readyz"),
 			b.buildControlPlanePathRoute(options, "/.pomerium"),
 			b.buildControlPlanePrefixRoute(options, "/.pomerium/"),
 			b.buildControlPlanePathRoute(options, "/.well-known/pomerium"),
@@ -130,6 +146,9 @@ func (b *Builder) buildControlPlanePathRoute(
 			PerFilterConfigExtAuthzName: PerFilterConfigExtAuthzContextExtensions(MakeExtAuthzContextExtensions(true, 0)),
 		},
 	}
+	setResponseCachePerFilterConfig(options, nil, r.TypedPerFilterConfig)
+	setCompressionPerFilterConfig(options, nil, r.TypedPerFilterConfig)
+	setExternalProcessorPerFilterConfig(options, nil, r.TypedPerFilterConfig)
 	return r
 }
 
@@ -154,9 +173,29 @@ func (b *Builder) buildControlPlanePrefixRoute(
 			PerFilterConfigExtAuthzName: PerFilterConfigExtAuthzContextExtensions(MakeExtAuthzContextExtensions(true, 0)),
 		},
 	}
+	setResponseCachePerFilterConfig(options, nil, r.TypedPerFilterConfig)
+	setCompressionPerFilterConfig(options, nil, r.TypedPerFilterConfig)
+	setExternalProcessorPerFilterConfig(options, nil, r.TypedPerFilterConfig)
 	return r
 }
 
+// buildAdminConsoleRoute builds the route for the admin console. Unlike the other control plane
+// routes it isn't internal, so requests are authorized using the admin console policy.
+func (b *Builder) buildAdminConsoleRoute(
+	options *config.Options,
+	policy *config.Policy,
+) (*envoy_config_route_v3.Route, error) {
+	routeID, err := policy.RouteID()
+	if err != nil {
+		return nil, err
+	}
+
+	r := b.buildControlPlanePrefixRoute(options, policy.Prefix)
+	r.Name = "pomerium-admin-console"
+	r.TypedPerFilterConfig[PerFilterConfigExtAuthzName] = PerFilterConfigExtAuthzContextExtensions(MakeExtAuthzContextExtensions(false, routeID))
+	return r, nil
+}
+
 // getClusterID returns a cluster ID
 var getClusterID = func(policy *config.Policy) string {
 	prefix := getClusterStatsName(policy)
@@ -168,6 +207,24 @@ var getClusterID = func(policy *config.Policy) string {
 	return fmt.Sprintf("%s-%x", prefix, id)
 }
 
+// getMirrorClusterID returns the ID of the cluster which receives mirrored requests
+func getMirrorClusterID(policy *config.Policy) string {
+	return getClusterID(policy) + "-mirror"
+}
+
+// getWebsocketClusterID returns the ID of the cluster which receives websocket connections. Having a
+// separate cluster means the number of active websocket connections for a route is reported by the
+// cluster's upstream_rq_active stat.
+func getWebsocketClusterID(policy *config.Policy) string {
+	return getClusterID(policy) + "-websocket"
+}
+
+// hasWebsocketRoute returns true if websocket connections for the policy use a separate route and cluster.
+func hasWebsocketRoute(policy *config.Policy) bool {
+	return policy.AllowWebsockets && policy.HasWebsocketLimits() && policy.Redirect == nil && len(policy.To) > 0 &&
+		!shouldReproxy(policy)
+}
+
 // getClusterStatsName returns human readable name that would be used by envoy to emit statistics, available as envoy_cluster_name label
 func getClusterStatsName(policy *config.Policy) string {
 	if policy.EnvoyOpts != nil && policy.EnvoyOpts.Name != "" {
@@ -237,18 +294,31 @@ func (b *Builder) buildRoutesForPolicy(
 		return nil, err
 	}
 
-	var routes []*envoy_config_route_v3.Route
+	var matches []*envoy_config_route_v3.RouteMatch
 	if strings.Contains(fromURL.Host, "*") {
 		// we have to match '*.example.com' and '*.example.com:443', so there are two routes
 		for _, host := range urlutil.GetDomainsForURL(fromURL) {
-			route, err := b.buildRouteForPolicyAndMatch(cfg, policy, name, mkRouteMatchForHost(policy, host))
+			matches = append(matches, mkRouteMatchForHost(policy, host))
+		}
+	} else {
+		matches = append(matches, mkRouteMatch(policy))
+		if policy.IsSSH() {
+			// certificates are issued to ssh clients with POST requests
+			matches = append(matches, mkSSHCertificateRouteMatch())
+		}
+	}
+
+	var routes []*envoy_config_route_v3.Route
+	for _, match := range matches {
+		if hasWebsocketRoute(policy) {
+			route, err := b.buildWebsocketRouteForPolicyAndMatch(cfg, policy, name, match)
 			if err != nil {
 				return nil, err
 			}
 			routes = append(routes, route)
 		}
-	} else {
-		route, err := b.buildRouteForPolicyAndMatch(cfg, policy, name, mkRouteMatch(policy))
+
+		route, err := b.buildRouteForPolicyAndMatch(cfg, policy, name, match)
 		if err != nil {
 			return nil, err
 		}
@@ -257,6 +327,49 @@ func (b *Builder) buildRoutesForPolicy(
 	return routes, nil
 }
 
+// buildWebsocketRouteForPolicyAndMatch builds a route for websocket upgrade requests which applies the
+// policy's websocket limits and sends requests to the policy's websocket cluster.
+func (b *Builder) buildWebsocketRouteForPolicyAndMatch(
+	cfg *config.Config,
+	policy *config.Policy,
+	name string,
+	match *envoy_config_route_v3.RouteMatch,
+) (*envoy_config_route_v3.Route, error) {
+	match = proto.Clone(match).(*envoy_config_route_v3.RouteMatch)
+	match.Headers = append(match.Headers, &envoy_config_route_v3.HeaderMatcher{
+		Name: "upgrade",
+		HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{
+			StringMatch: &envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: "websocket"},
+				IgnoreCase:   true,
+			},
+		},
+	})
+
+	route, err := b.buildRouteForPolicyAndMatch(cfg, policy, name, match)
+	if err != nil {
+		return nil, err
+	}
+
+	// upgraded connections are never cached or compressed
+	setResponseCachePerFilterConfig(cfg.Options, nil, route.TypedPerFilterConfig)
+	setCompressionPerFilterConfig(cfg.Options, nil, route.TypedPerFilterConfig)
+
+	action := route.GetRoute()
+	action.ClusterSpecifier = &envoy_config_route_v3.RouteAction_Cluster{
+		Cluster: getWebsocketClusterID(policy),
+	}
+	if policy.WebsocketIdleTimeout != nil {
+		action.IdleTimeout = durationpb.New(*policy.WebsocketIdleTimeout)
+	}
+	if policy.WebsocketMaxDuration != nil {
+		action.MaxStreamDuration = &envoy_config_route_v3.RouteAction_MaxStreamDuration{
+			MaxStreamDuration: durationpb.New(*policy.WebsocketMaxDuration),
+		}
+	}
+	return route, nil
+}
+
 func (b *Builder) buildRouteForPolicyAndMatch(
 	cfg *config.Config,
 	policy *config.Policy,
@@ -297,6 +410,9 @@ func (b *Builder) buildRouteForPolicyAndMatch(
 	luaMetadata := map[string]*structpb.Value{
 		"rewrite_response_headers": getRewriteHeadersMetadata(policy.RewriteResponseHeaders),
 	}
+	if policy.RewriteResponseBody != nil {
+		luaMetadata["rewrite_response_body"] = getRewriteResponseBodyMetadata(policy.RewriteResponseBody)
+	}
 
 	// disable authentication entirely when the proxy is fronting authenticate
 	isFrontingAuthenticate, err := isProxyFrontingAuthenticate(cfg.Options, fromURL.Hostname())
@@ -307,13 +423,19 @@ func (b *Builder) buildRouteForPolicyAndMatch(
 		route.TypedPerFilterConfig = map[string]*any.Any{
 			PerFilterConfigExtAuthzName: PerFilterConfigExtAuthzDisabled(),
 		}
+		// requests aren't authorized, so responses must never be cached, and requests are never
+		// sent to an external processor
+		setResponseCachePerFilterConfig(cfg.Options, nil, route.TypedPerFilterConfig)
+		setExternalProcessorPerFilterConfig(cfg.Options, nil, route.TypedPerFilterConfig)
 	} else {
 		route.TypedPerFilterConfig = map[string]*any.Any{
 			PerFilterConfigExtAuthzName: PerFilterConfigExtAuthzContextExtensions(MakeExtAuthzContextExtensions(false, routeID)),
 		}
+		setResponseCachePerFilterConfig(cfg.Options, policy, route.TypedPerFilterConfig)
+		setExternalProcessorPerFilterConfig(cfg.Options, policy, route.TypedPerFilterConfig)
 		luaMetadata["remove_pomerium_cookie"] = &structpb.Value{
 			Kind: &structpb.Value_StringValue{
-				StringValue: cfg.Options.CookieName,
+				StringValue: cfg.Options.GetSessionCookieOptionsForPolicy(policy).Name,
 			},
 		}
 		luaMetadata["remove_pomerium_authorization"] = &structpb.Value{
@@ -328,7 +450,10 @@ func (b *Builder) buildRouteForPolicyAndMatch(
 		}
 	}
 
-	if policy.IsForKubernetes() {
+	setCompressionPerFilterConfig(cfg.Options, policy, route.TypedPerFilterConfig)
+	setCORSPerFilterConfig(policy, route.TypedPerFilterConfig)
+
+	if shouldReproxy(policy) {
 		for _, hdr := range b.reproxy.GetPolicyIDHeaders(routeID) {
 			route.RequestHeadersToAdd = append(route.RequestHeadersToAdd,
 				&envoy_config_core_v3.HeaderValueOption{
@@ -386,21 +511,22 @@ func (b *Builder) buildPolicyRouteRedirectAction(r *config.PolicyRedirect) (*env
 
 func (b *Builder) buildPolicyRouteRouteAction(options *config.Options, policy *config.Policy) (*envoy_config_route_v3.RouteAction, error) {
 	clusterName := getClusterID(policy)
-	// kubernetes requests are sent to the http control plane to be reproxied
-	if policy.IsForKubernetes() {
+	if shouldReproxy(policy) {
 		clusterName = httpCluster
 	}
 	routeTimeout := getRouteTimeout(options, policy)
 	idleTimeout := getRouteIdleTimeout(policy)
 	prefixRewrite, regexRewrite := getRewriteOptions(policy)
+	// kubectl exec, attach and port-forward upgrade to websocket or SPDY streams, so both are always
+	// allowed for kubernetes routes
 	upgradeConfigs := []*envoy_config_route_v3.RouteAction_UpgradeConfig{
 		{
 			UpgradeType: "websocket",
-			Enabled:     &wrappers.BoolValue{Value: policy.AllowWebsockets},
+			Enabled:     &wrappers.BoolValue{Value: policy.AllowWebsockets || policy.IsForKubernetes()},
 		},
 		{
 			UpgradeType: "spdy/3.1",
-			Enabled:     &wrappers.BoolValue{Value: policy.AllowSPDY},
+			Enabled:     &wrappers.BoolValue{Value: policy.AllowSPDY || policy.IsForKubernetes()},
 		},
 	}
 
@@ -410,6 +536,12 @@ func (b *Builder) buildPolicyRouteRouteAction(options *config.Options, policy *c
 			Enabled:       &wrappers.BoolValue{Value: true},
 			ConnectConfig: &envoy_config_route_v3.RouteAction_UpgradeConfig_ConnectConfig{},
 		})
+	} else if policy.IsUDP() || policy.IsSSH() {
+		// the CONNECT request is forwarded to the control plane, which terminates the tunnel
+		upgradeConfigs = append(upgradeConfigs, &envoy_config_route_v3.RouteAction_UpgradeConfig{
+			UpgradeType: "CONNECT",
+			Enabled:     &wrappers.BoolValue{Value: true},
+		})
 	}
 	action := &envoy_config_route_v3.RouteAction{
 		ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{
@@ -419,10 +551,13 @@ func (b *Builder) buildPolicyRouteRouteAction(options *config.Options, policy *c
 		HostRewriteSpecifier: &envoy_config_route_v3.RouteAction_AutoHostRewrite{
 			AutoHostRewrite: &wrappers.BoolValue{Value: !policy.PreserveHostHeader},
 		},
-		Timeout:       routeTimeout,
-		IdleTimeout:   idleTimeout,
-		PrefixRewrite: prefixRewrite,
-		RegexRewrite:  regexRewrite,
+		Timeout:               routeTimeout,
+		IdleTimeout:           idleTimeout,
+		MaxStreamDuration:     getRouteMaxStreamDuration(policy),
+		RetryPolicy:           getRouteRetryPolicy(policy),
+		RequestMirrorPolicies: getRouteRequestMirrorPolicies(policy),
+		PrefixRewrite:         prefixRewrite,
+		RegexRewrite:          regexRewrite,
 		HashPolicy: []*envoy_config_route_v3.RouteAction_HashPolicy{
 			// hash by the routing key, which is added by authorize.
 			{
@@ -444,6 +579,19 @@ func (b *Builder) buildPolicyRouteRouteAction(options *config.Options, policy *c
 			},
 		},
 	}
+	if policy.StickySession != nil && policy.StickySession.CookieName != "" {
+		action.HashPolicy = append([]*envoy_config_route_v3.RouteAction_HashPolicy{{
+			PolicySpecifier: &envoy_config_route_v3.RouteAction_HashPolicy_Cookie_{
+				Cookie: &envoy_config_route_v3.RouteAction_HashPolicy_Cookie{
+					Name: policy.StickySession.CookieName,
+					Path: policy.StickySession.CookiePath,
+					// a ttl is required for envoy to generate the cookie, zero results in a session cookie
+					Ttl: durationpb.New(policy.StickySession.CookieTTL),
+				},
+			},
+			Terminal: true,
+		}}, action.HashPolicy...)
+	}
 	setHostRewriteOptions(policy, action)
 
 	return action, nil
@@ -476,7 +624,7 @@ func toEnvoyHeaders(headers map[string]string) []*envoy_config_core_v3.HeaderVal
 func mkRouteMatch(policy *config.Policy) *envoy_config_route_v3.RouteMatch {
 	match := &envoy_config_route_v3.RouteMatch{}
 	switch {
-	case policy.IsTCP():
+	case policy.IsTCP(), policy.IsUDP(), policy.IsSSH():
 		match.PathSpecifier = &envoy_config_route_v3.RouteMatch_ConnectMatcher_{
 			ConnectMatcher: &envoy_config_route_v3.RouteMatch_ConnectMatcher{},
 		}
@@ -496,6 +644,20 @@ func mkRouteMatch(policy *config.Policy) *envoy_config_route_v3.RouteMatch {
 	return match
 }
 
+func mkSSHCertificateRouteMatch() *envoy_config_route_v3.RouteMatch {
+	return &envoy_config_route_v3.RouteMatch{
+		PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{Path: "/"},
+		Headers: []*envoy_config_route_v3.HeaderMatcher{{
+			Name: ":method",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_StringMatch{
+				StringMatch: &envoy_type_matcher_v3.StringMatcher{
+					MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: http.MethodPost},
+				},
+			},
+		}},
+	}
+}
+
 func mkRouteMatchForHost(
 	policy *config.Policy,
 	host string,
@@ -518,7 +680,8 @@ func mkRouteMatchForHost(
 
 func getRequestHeadersToRemove(options *config.Options, policy *config.Policy) []string {
 	requestHeadersToRemove := policy.RemoveRequestHeaders
-	if !policy.PassIdentityHeaders {
+	// ssh routes are terminated by the control plane, which needs the user's identity to mint certificates
+	if !policy.PassIdentityHeaders && !policy.IsSSH() {
 		requestHeadersToRemove = append(requestHeadersToRemove,
 			httputil.HeaderPomeriumJWTAssertion,
 			httputil.HeaderPomeriumJWTAssertionFor)
@@ -534,6 +697,11 @@ func getRequestHeadersToRemove(options *config.Options, policy *config.Policy) [
 	return requestHeadersToRemove
 }
 
+// shouldReproxy returns true if requests for the policy are sent to the http control plane to be reproxied.
+func shouldReproxy(policy *config.Policy) bool {
+	return policy.IsForKubernetes() || policy.IsUDP() || policy.IsSSH()
+}
+
 func getRouteTimeout(options *config.Options, policy *config.Policy) *durationpb.Duration {
 	var routeTimeout *durationpb.Duration
 	if policy.UpstreamTimeout != nil {
@@ -557,9 +725,75 @@ func getRouteIdleTimeout(policy *config.Policy) *durationpb.Duration {
 	return idleTimeout
 }
 
+func getRouteRequestMirrorPolicies(policy *config.Policy) []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy {
+	if policy.MirrorTo == "" {
+		return nil
+	}
+
+	mirrorPolicy := &envoy_config_route_v3.RouteAction_RequestMirrorPolicy{
+		Cluster: getMirrorClusterID(policy),
+	}
+	if policy.MirrorPercent != nil && *policy.MirrorPercent < 100 {
+		mirrorPolicy.RuntimeFraction = &envoy_config_core_v3.RuntimeFractionalPercent{
+			DefaultValue: &envoy_type_v3.FractionalPercent{
+				Numerator:   uint32(math.Round(*policy.MirrorPercent * 10000)),
+				Denominator: envoy_type_v3.FractionalPercent_MILLION,
+			},
+		}
+	}
+	return []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy{mirrorPolicy}
+}
+
+func getRouteMaxStreamDuration(policy *config.Policy) *envoy_config_route_v3.RouteAction_MaxStreamDuration {
+	if policy.MaxStreamDuration == nil {
+		return nil
+	}
+	return &envoy_config_route_v3.RouteAction_MaxStreamDuration{
+		MaxStreamDuration: durationpb.New(*policy.MaxStreamDuration),
+	}
+}
+
+func getRouteRetryPolicy(policy *config.Policy) *envoy_config_route_v3.RetryPolicy {
+	rp := policy.RetryPolicy
+	if rp == nil {
+		return nil
+	}
+
+	retryOn := sets.NewSorted[string]()
+	retryOn.Add(rp.RetryOn...)
+	if retryOn.Size() == 0 {
+		retryOn.Add("connect-failure", "refused-stream", "reset")
+	}
+	if len(rp.RetriableStatusCodes) > 0 {
+		retryOn.Add("retriable-status-codes")
+	}
+
+	retryPolicy := &envoy_config_route_v3.RetryPolicy{
+		RetryOn:              strings.Join(retryOn.ToSlice(), ","),
+		RetriableStatusCodes: rp.RetriableStatusCodes,
+	}
+	if rp.NumRetries > 0 {
+		retryPolicy.NumRetries = wrapperspb.UInt32(rp.NumRetries)
+	}
+	if rp.PerTryTimeout > 0 {
+		retryPolicy.PerTryTimeout = durationpb.New(rp.PerTryTimeout)
+	}
+	if rp.BackoffBaseInterval > 0 {
+		retryPolicy.RetryBackOff = &envoy_config_route_v3.RetryPolicy_RetryBackOff{
+			BaseInterval: durationpb.New(rp.BackoffBaseInterval),
+		}
+		if rp.BackoffMaxInterval > 0 {
+			retryPolicy.RetryBackOff.MaxInterval = durationpb.New(rp.BackoffMaxInterval)
+		}
+	}
+	return retryPolicy
+}
+
 func shouldDisableStreamIdleTimeout(policy *config.Policy) bool {
 	return policy.AllowWebsockets ||
 		policy.IsTCP() ||
+		policy.IsUDP() ||
+		policy.IsSSH() ||
 		policy.IsForKubernetes() // disable for kubernetes so that tailing logs works (#2182)
 }
 
@@ -632,6 +866,29 @@ func isProxyFrontingAuthenticate(options *config.Options, host string) (bool, er
 	return false, nil
 }
 
+func getRewriteResponseBodyMetadata(rewrite *config.RewriteResponseBody) *structpb.Value {
+	contentTypes := make([]*structpb.Value, 0, len(rewrite.GetContentTypes()))
+	for _, contentType := range rewrite.GetContentTypes() {
+		contentTypes = append(contentTypes, structpb.NewStringValue(strings.ToLower(contentType)))
+	}
+	rules := make([]*structpb.Value, 0, len(rewrite.Rules))
+	for _, rule := range rewrite.Rules {
+		rules = append(rules, structpb.NewStructValue(&structpb.Struct{
+			Fields: map[string]*structpb.Value{
+				"from": structpb.NewStringValue(rule.From),
+				"to":   structpb.NewStringValue(rule.To),
+			},
+		}))
+	}
+	return structpb.NewStructValue(&structpb.Struct{
+		Fields: map[string]*structpb.Value{
+			"content_types": structpb.NewListValue(&structpb.ListValue{Values: contentTypes}),
+			"max_size":      structpb.NewNumberValue(float64(rewrite.GetMaxSize())),
+			"rules":         structpb.NewListValue(&structpb.ListValue{Values: rules}),
+		},
+	})
+}
+
 func getRewriteHeadersMetadata(headers []config.RewriteHeader) *structpb.Value {
 	if len(headers) == 0 {
 		return &structpb.Value{
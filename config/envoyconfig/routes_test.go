@@ -17,6 +17,7 @@ import (
 	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
 	"github.com/pomerium/pomerium/internal/testutil"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
 )
 
 func policyNameFunc() func(*config.Policy) string {
@@ -29,7 +30,7 @@ func policyNameFunc() func(*config.Policy) string {
 
 func Test_buildGRPCRoutes(t *testing.T) {
 	b := &Builder{filemgr: filemgr.NewManager()}
-	routes, err := b.buildGRPCRoutes()
+	routes, err := b.buildGRPCRoutes(&config.Options{})
 	require.NoError(t, err)
 	testutil.AssertProtoJSONEqual(t, `
 		[
@@ -106,6 +107,7 @@ func Test_buildPomeriumHTTPRoutes(t *testing.T) {
 		testutil.AssertProtoJSONEqual(t, `[
 			`+routeString("path", "/ping")+`,
 			`+routeString("path", "/healthz")+`,
+			`+routeString("path", "/readyz")+`,
 			`+routeString("path", "/.pomerium")+`,
 			`+routeString("prefix", "/.pomerium/")+`,
 			`+routeString("path", "/.well-known/pomerium")+`,
@@ -143,6 +145,7 @@ func Test_buildPomeriumHTTPRoutes(t *testing.T) {
 		testutil.AssertProtoJSONEqual(t, `[
 			`+routeString("path", "/ping")+`,
 			`+routeString("path", "/healthz")+`,
+			`+routeString("path", "/readyz")+`,
 			`+routeString("path", "/.pomerium")+`,
 			`+routeString("prefix", "/.pomerium/")+`,
 			`+routeString("path", "/.well-known/pomerium")+`,
@@ -169,12 +172,50 @@ func Test_buildPomeriumHTTPRoutes(t *testing.T) {
 		testutil.AssertProtoJSONEqual(t, `[
 			`+routeString("path", "/ping")+`,
 			`+routeString("path", "/healthz")+`,
+			`+routeString("path", "/readyz")+`,
 			`+routeString("path", "/.pomerium")+`,
 			`+routeString("prefix", "/.pomerium/")+`,
 			`+routeString("path", "/.well-known/pomerium")+`,
 			`+routeString("prefix", "/.well-known/pomerium/")+`
 		]`, routes)
 	})
+	t.Run("with admin console", func(t *testing.T) {
+		options := &config.Options{
+			Services:                 "all",
+			AuthenticateURLString:    "https://authenticate.example.com",
+			AuthenticateCallbackPath: "/oauth2/callback",
+			AdminConsole: config.AdminConsoleSettings{
+				Policy: &config.PPLPolicy{Policy: &parser.Policy{
+					Rules: []parser.Rule{{
+						Action: parser.ActionAllow,
+						Or: []parser.Criterion{{
+							Name: "email", Data: parser.Object{"is": parser.String("admin@example.com")},
+						}},
+					}},
+				}},
+			},
+		}
+		routes, err := b.buildPomeriumHTTPRoutes(options, "from.example.com")
+		require.NoError(t, err)
+
+		routeID, err := options.GetAdminConsolePolicy().RouteID()
+		require.NoError(t, err)
+		adminRoute := strings.Replace(routeString("prefix", "/.pomerium/admin/"), "pomerium-prefix-/.pomerium/admin/", "pomerium-admin-console", 1)
+		adminRoute = strings.Replace(adminRoute, `"internal": "true"`, `"internal": "false"`, 1)
+		adminRoute = strings.Replace(adminRoute, `"route_id": "0"`, fmt.Sprintf(`"route_id": "%d"`, routeID), 1)
+
+		testutil.AssertProtoJSONEqual(t, `[
+			`+adminRoute+`,
+			`+routeString("path", "/ping")+`,
+			`+routeString("path", "/healthz")+`,
+			`+routeString("path", "/readyz")+`,
+			`+routeString("path", "/.pomerium")+`,
+			`+routeString("prefix", "/.pomerium/")+`,
+			`+routeString("path", "/.well-known/pomerium")+`,
+			`+routeString("prefix", "/.well-known/pomerium/")+`,
+			`+routeString("path", "/robots.txt")+`
+		]`, routes)
+	})
 }
 
 func Test_buildControlPlanePathRoute(t *testing.T) {
@@ -1957,3 +1998,173 @@ func mustParseURL(t *testing.T, str string) *url.URL {
 	require.NoError(t, err, str)
 	return u
 }
+
+func Test_buildPolicyRouteRouteActionStickySession(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	b := &Builder{filemgr: filemgr.NewManager()}
+	action, err := b.buildPolicyRouteRouteAction(&config.Options{DefaultUpstreamTimeout: time.Second * 3}, &config.Policy{
+		From: "https://example.com",
+		To:   mustParseWeightedURLs(t, "https://to1.example.com,90", "https://to2.example.com,10"),
+		StickySession: &config.StickySession{
+			CookieName: "_sticky",
+			CookieTTL:  time.Hour,
+		},
+	})
+	require.NoError(t, err)
+	testutil.AssertProtoJSONEqual(t, `[
+		{
+			"cookie": {
+				"name": "_sticky",
+				"ttl": "3600s"
+			},
+			"terminal": true
+		},
+		{
+			"header": {
+				"headerName": "x-pomerium-routing-key"
+			},
+			"terminal": true
+		},
+		{
+			"connectionProperties": {
+				"sourceIp": true
+			},
+			"terminal": true
+		}
+	]`, action.GetHashPolicy())
+}
+
+func Test_buildPolicyRouteRouteActionKubernetesUpgrades(t *testing.T) {
+	b := &Builder{filemgr: filemgr.NewManager()}
+	action, err := b.buildPolicyRouteRouteAction(&config.Options{DefaultUpstreamTimeout: time.Second * 3}, &config.Policy{
+		From:                          "https://k8s.example.com",
+		To:                            mustParseWeightedURLs(t, "https://kubernetes.default.svc"),
+		KubernetesServiceAccountToken: "TOKEN",
+	})
+	require.NoError(t, err)
+	testutil.AssertProtoJSONEqual(t, `[
+		{ "enabled": true, "upgradeType": "websocket" },
+		{ "enabled": true, "upgradeType": "spdy/3.1" }
+	]`, action.GetUpgradeConfigs())
+	assert.Equal(t, httpCluster, action.GetCluster())
+}
+
+func Test_buildRoutesForPolicyWebsocketLimits(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	idleTimeout, maxDuration := time.Minute, time.Hour
+	b := &Builder{filemgr: filemgr.NewManager()}
+	routes, err := b.buildRoutesForPolicy(&config.Config{Options: &config.Options{
+		DefaultUpstreamTimeout: time.Second * 3,
+		SharedKey:              cryptutil.NewBase64Key(),
+	}}, &config.Policy{
+		From:                 "https://example.com",
+		To:                   mustParseWeightedURLs(t, "https://to.example.com"),
+		AllowWebsockets:      true,
+		WebsocketIdleTimeout: &idleTimeout,
+		WebsocketMaxDuration: &maxDuration,
+	}, "policy-0")
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+
+	testutil.AssertProtoJSONEqual(t, `{
+		"headers": [{
+			"name": "upgrade",
+			"stringMatch": { "exact": "websocket", "ignoreCase": true }
+		}],
+		"prefix": "/"
+	}`, routes[0].GetMatch())
+	assert.Equal(t, "policy-websocket", routes[0].GetRoute().GetCluster())
+	assert.Equal(t, time.Minute, routes[0].GetRoute().GetIdleTimeout().AsDuration())
+	assert.Equal(t, time.Hour, routes[0].GetRoute().GetMaxStreamDuration().GetMaxStreamDuration().AsDuration())
+
+	assert.Empty(t, routes[1].GetMatch().GetHeaders())
+	assert.Equal(t, "policy", routes[1].GetRoute().GetCluster())
+	assert.Equal(t, time.Duration(0), routes[1].GetRoute().GetIdleTimeout().AsDuration())
+	assert.Nil(t, routes[1].GetRoute().GetMaxStreamDuration())
+}
+
+func Test_getRouteRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getRouteRetryPolicy(&config.Policy{}))
+	testutil.AssertProtoJSONEqual(t, `{
+		"retryOn": "connect-failure,refused-stream,reset"
+	}`, getRouteRetryPolicy(&config.Policy{RetryPolicy: &config.PolicyRetryPolicy{}}))
+	testutil.AssertProtoJSONEqual(t, `{
+		"retryOn": "5xx,retriable-status-codes",
+		"retriableStatusCodes": [409, 429],
+		"numRetries": 3,
+		"perTryTimeout": "2s",
+		"retryBackOff": {
+			"baseInterval": "0.100s",
+			"maxInterval": "1s"
+		}
+	}`, getRouteRetryPolicy(&config.Policy{RetryPolicy: &config.PolicyRetryPolicy{
+		RetryOn:              []string{"5xx"},
+		RetriableStatusCodes: []uint32{409, 429},
+		NumRetries:           3,
+		PerTryTimeout:        2 * time.Second,
+		BackoffBaseInterval:  100 * time.Millisecond,
+		BackoffMaxInterval:   time.Second,
+	}}))
+}
+
+func Test_getRouteMaxStreamDuration(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, getRouteMaxStreamDuration(&config.Policy{}))
+	d := time.Hour
+	testutil.AssertProtoJSONEqual(t, `{
+		"maxStreamDuration": "3600s"
+	}`, getRouteMaxStreamDuration(&config.Policy{MaxStreamDuration: &d}))
+}
+
+func Test_getRouteRequestMirrorPolicies(t *testing.T) {
+	defer func(f func(*config.Policy) string) {
+		getClusterID = f
+	}(getClusterID)
+	getClusterID = func(*config.Policy) string { return "policy" }
+
+	assert.Nil(t, getRouteRequestMirrorPolicies(&config.Policy{}))
+	testutil.AssertProtoJSONEqual(t, `[{
+		"cluster": "policy-mirror"
+	}]`, getRouteRequestMirrorPolicies(&config.Policy{MirrorTo: "https://shadow.example.com"}))
+	percent := 12.5
+	testutil.AssertProtoJSONEqual(t, `[{
+		"cluster": "policy-mirror",
+		"runtimeFraction": {
+			"defaultValue": {
+				"numerator": 125000,
+				"denominator": "MILLION"
+			}
+		}
+	}]`, getRouteRequestMirrorPolicies(&config.Policy{MirrorTo: "https://shadow.example.com", MirrorPercent: &percent}))
+}
+
+func Test_getRewriteResponseBodyMetadata(t *testing.T) {
+	t.Parallel()
+
+	testutil.AssertProtoJSONEqual(t, `{
+		"content_types": ["text/html", "text/css", "application/javascript", "application/json"],
+		"max_size": 1048576,
+		"rules": [{ "from": "http://internal/", "to": "https://external/" }]
+	}`, getRewriteResponseBodyMetadata(&config.RewriteResponseBody{
+		Rules: []config.RewriteResponseBodyRule{{From: "http://internal/", To: "https://external/"}},
+	}))
+	testutil.AssertProtoJSONEqual(t, `{
+		"content_types": ["text/plain"],
+		"max_size": 10,
+		"rules": []
+	}`, getRewriteResponseBodyMetadata(&config.RewriteResponseBody{
+		ContentTypes: []string{"Text/Plain"},
+		MaxSize:      10,
+	}))
+}
@@ -0,0 +1,167 @@
+package envoyconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/hashutil"
+)
+
+const metricsCertificateSecretName = "pomerium-metrics-certificate"
+
+// EnableSecretDiscovery makes listeners and clusters reference their TLS certificates as secrets
+// served over the aggregated discovery service, which are built by BuildSecrets. Secrets are
+// named after the certificate's identity rather than its contents, so when a certificate
+// changes only the secret is updated, and envoy swaps it in without draining listeners or
+// connection pools.
+func (b *Builder) EnableSecretDiscovery() {
+	b.secretDiscovery = true
+}
+
+// BuildSecrets builds the TLS certificate secrets referenced by listeners and clusters when
+// secret discovery is enabled.
+func (b *Builder) BuildSecrets(ctx context.Context, cfg *config.Config) ([]*envoy_extensions_transport_sockets_tls_v3.Secret, error) {
+	var secrets []*envoy_extensions_transport_sockets_tls_v3.Secret
+	add := func(name string, cert *tls.Certificate) {
+		secrets = append(secrets, &envoy_extensions_transport_sockets_tls_v3.Secret{
+			Name: name,
+			Type: &envoy_extensions_transport_sockets_tls_v3.Secret_TlsCertificate{
+				TlsCertificate: b.envoyTLSCertificateFromGoTLSCertificate(ctx, cert),
+			},
+		})
+	}
+
+	allCertificates, err := getAllCertificates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range downstreamCertificateSecretNames(allCertificates) {
+		add(name, &allCertificates[i])
+	}
+
+	metricsCertificate, err := cfg.Options.GetMetricsCertificate()
+	if err != nil {
+		return nil, err
+	}
+	if metricsCertificate != nil {
+		add(metricsCertificateSecretName, metricsCertificate)
+	}
+
+	seen := make(map[string]struct{})
+	for _, policy := range cfg.Options.GetAllPolicies() {
+		if policy.ClientCertificate == nil {
+			continue
+		}
+		name := upstreamClientCertificateSecretName(&policy)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		add(name, policy.ClientCertificate)
+	}
+
+	return secrets, nil
+}
+
+// setDownstreamTLSCertificates sets the certificates presented by a listener, either inline or
+// as references to secrets.
+func (b *Builder) setDownstreamTLSCertificates(
+	ctx context.Context,
+	tlsContext *envoy_extensions_transport_sockets_tls_v3.CommonTlsContext,
+	certs []tls.Certificate,
+) error {
+	if !b.secretDiscovery {
+		envoyCerts, err := b.envoyCertificates(ctx, certs)
+		if err != nil {
+			return err
+		}
+		tlsContext.TlsCertificates = envoyCerts
+		return nil
+	}
+
+	for i := range certs {
+		if err := validateCertificate(&certs[i]); err != nil {
+			return fmt.Errorf("invalid certificate for domain %s: %w",
+				certs[i].Leaf.Subject.CommonName, err)
+		}
+	}
+	for _, name := range downstreamCertificateSecretNames(certs) {
+		tlsContext.TlsCertificateSdsSecretConfigs = append(tlsContext.TlsCertificateSdsSecretConfigs,
+			sdsSecretConfig(name))
+	}
+	return nil
+}
+
+// setTLSCertificate sets a single certificate presented by a listener or cluster, either inline
+// or as a reference to the secret with the given name.
+func (b *Builder) setTLSCertificate(
+	ctx context.Context,
+	tlsContext *envoy_extensions_transport_sockets_tls_v3.CommonTlsContext,
+	name string,
+	cert *tls.Certificate,
+) {
+	if !b.secretDiscovery {
+		tlsContext.TlsCertificates = append(tlsContext.TlsCertificates,
+			b.envoyTLSCertificateFromGoTLSCertificate(ctx, cert))
+		return
+	}
+	tlsContext.TlsCertificateSdsSecretConfigs = append(tlsContext.TlsCertificateSdsSecretConfigs,
+		sdsSecretConfig(name))
+}
+
+func sdsSecretConfig(name string) *envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig {
+	return &envoy_extensions_transport_sockets_tls_v3.SdsSecretConfig{
+		Name: name,
+		SdsConfig: &envoy_config_core_v3.ConfigSource{
+			ResourceApiVersion:    envoy_config_core_v3.ApiVersion_V3,
+			ConfigSourceSpecifier: &envoy_config_core_v3.ConfigSource_Ads{},
+		},
+	}
+}
+
+// downstreamCertificateSecretNames returns the secret names of downstream certificates. A
+// certificate is named after the names it is valid for, so a renewed certificate keeps its
+// secret name.
+func downstreamCertificateSecretNames(certs []tls.Certificate) []string {
+	names := make([]string, len(certs))
+	counts := make(map[string]int)
+	for i := range certs {
+		var identity []string
+		if len(certs[i].Certificate) > 0 {
+			if leaf, err := x509.ParseCertificate(certs[i].Certificate[0]); err == nil {
+				identity = certificateIdentity(leaf)
+			}
+		}
+		name := fmt.Sprintf("pomerium-downstream-certificate-%x", hashutil.MustHash(identity))
+		counts[name]++
+		if n := counts[name]; n > 1 {
+			name += "-" + strconv.Itoa(n)
+		}
+		names[i] = name
+	}
+	return names
+}
+
+func certificateIdentity(cert *x509.Certificate) []string {
+	identity := []string{cert.Subject.CommonName}
+	identity = append(identity, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		identity = append(identity, ip.String())
+	}
+	sort.Strings(identity[1:])
+	return identity
+}
+
+// upstreamClientCertificateSecretName returns the secret name of the client certificate of a route.
+// The name is based on the route match id, so changing the route's upstreams keeps the name.
+func upstreamClientCertificateSecretName(policy *config.Policy) string {
+	return fmt.Sprintf("pomerium-upstream-client-certificate-%d", policy.RouteMatchID())
+}
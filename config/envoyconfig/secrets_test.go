@@ -0,0 +1,65 @@
+package envoyconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig/filemgr"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestSecretDiscovery(t *testing.T) {
+	b := New("local-grpc", "local-http", "local-metrics", filemgr.NewManager(), nil)
+	b.EnableSecretDiscovery()
+
+	cert, err := cryptutil.GenerateCertificate([]byte("0123456789abcdef0123456789abcdef"), "a.example.com")
+	require.NoError(t, err)
+
+	certPEM, keyPEM, err := cryptutil.EncodeCertificate(cert)
+	require.NoError(t, err)
+	cfg := &config.Config{Options: config.NewDefaultOptions()}
+	cfg.Options.SharedKey = cryptutil.NewBase64Key()
+	cfg.Options.Cert = base64.StdEncoding.EncodeToString(certPEM)
+	cfg.Options.Key = base64.StdEncoding.EncodeToString(keyPEM)
+
+	downstreamTLSContext, err := b.buildDownstreamTLSContextMulti(context.Background(), cfg, []tls.Certificate{*cert})
+	require.NoError(t, err)
+	commonTLSContext := downstreamTLSContext.GetCommonTlsContext()
+	assert.Empty(t, commonTLSContext.GetTlsCertificates(), "should not inline certificates")
+	require.Len(t, commonTLSContext.GetTlsCertificateSdsSecretConfigs(), 1)
+	name := commonTLSContext.GetTlsCertificateSdsSecretConfigs()[0].GetName()
+
+	secrets, err := b.BuildSecrets(context.Background(), cfg)
+	require.NoError(t, err)
+	var names []string
+	for _, secret := range secrets {
+		names = append(names, secret.GetName())
+	}
+	assert.Contains(t, names, name, "should build the referenced secret")
+
+	renewed, err := cryptutil.GenerateCertificate([]byte("fedcba9876543210fedcba9876543210"), "a.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, downstreamCertificateSecretNames([]tls.Certificate{*cert}),
+		downstreamCertificateSecretNames([]tls.Certificate{*renewed}),
+		"a renewed certificate should keep its secret name")
+
+	other, err := cryptutil.GenerateCertificate([]byte("0123456789abcdef0123456789abcdef"), "b.example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, downstreamCertificateSecretNames([]tls.Certificate{*cert}),
+		downstreamCertificateSecretNames([]tls.Certificate{*other}))
+}
+
+func TestUpstreamClientCertificateSecretName(t *testing.T) {
+	p1 := &config.Policy{From: "https://a.example.com", To: mustParseWeightedURLs(t, "https://a.internal")}
+	p2 := &config.Policy{From: "https://a.example.com", To: mustParseWeightedURLs(t, "https://a2.internal")}
+	p3 := &config.Policy{From: "https://b.example.com", To: mustParseWeightedURLs(t, "https://a.internal")}
+	assert.Equal(t, upstreamClientCertificateSecretName(p1), upstreamClientCertificateSecretName(p2),
+		"changing the upstreams should keep the secret name")
+	assert.NotEqual(t, upstreamClientCertificateSecretName(p1), upstreamClientCertificateSecretName(p3))
+}
@@ -1,15 +1,19 @@
 package envoyconfig
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/url"
 
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	envoy_config_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/telemetry/otlp"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/pkg/protoutil"
 )
@@ -94,6 +98,24 @@ func buildTracingCluster(options *config.Options) (*envoy_config_cluster_v3.Clus
 	}
 }
 
+const otlpTracingClusterName = "otlp-collector"
+
+// buildOTLPTracingCluster builds the cluster envoy exports traces to when using the otlp tracing
+// provider. Envoy only supports exporting traces using grpc, so there's no cluster for other
+// protocols and only pomerium's own spans are exported.
+func (b *Builder) buildOTLPTracingCluster(ctx context.Context, cfg *config.Config) (*envoy_config_cluster_v3.Cluster, error) {
+	tracingOptions, err := config.NewTracingOptions(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("envoyconfig: invalid tracing config: %w", err)
+	}
+	if tracingOptions.Provider != trace.OTLPTracingProviderName || tracingOptions.OTLPProtocol != otlp.ProtocolGRPC {
+		return nil, nil
+	}
+
+	return b.buildInternalCluster(ctx, cfg, otlpTracingClusterName,
+		[]*url.URL{tracingOptions.OTLPEndpoint}, upstreamProtocolHTTP2)
+}
+
 func buildTracingHTTP(options *config.Options) (*envoy_config_trace_v3.Tracing_Http, error) {
 	tracingOptions, err := config.NewTracingOptions(options)
 	if err != nil {
@@ -112,6 +134,26 @@ func buildTracingHTTP(options *config.Options) (*envoy_config_trace_v3.Tracing_H
 				TypedConfig: tracingTC,
 			},
 		}, nil
+	case trace.OTLPTracingProviderName:
+		if tracingOptions.OTLPProtocol != otlp.ProtocolGRPC {
+			return nil, nil
+		}
+		tracingTC := protoutil.NewAny(&envoy_config_trace_v3.OpenTelemetryConfig{
+			GrpcService: &envoy_config_core_v3.GrpcService{
+				TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+						ClusterName: otlpTracingClusterName,
+					},
+				},
+			},
+			ServiceName: tracingOptions.Service,
+		})
+		return &envoy_config_trace_v3.Tracing_Http{
+			Name: "envoy.tracers.opentelemetry",
+			ConfigType: &envoy_config_trace_v3.Tracing_Http_TypedConfig{
+				TypedConfig: tracingTC,
+			},
+		}, nil
 	case trace.ZipkinTracingProviderName:
 		path := tracingOptions.ZipkinEndpoint.Path
 		if path == "" {
@@ -98,6 +98,34 @@ func TestBuildTracingCluster(t *testing.T) {
 }
 
 func TestBuildTracingHTTP(t *testing.T) {
+	t.Run("otlp", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+		h, err := buildTracingHTTP(&config.Options{
+			TracingProvider: "otlp",
+		})
+		require.NoError(t, err)
+		testutil.AssertProtoJSONEqual(t, `
+			{
+				"name": "envoy.tracers.opentelemetry",
+				"typedConfig": {
+					"@type": "type.googleapis.com/envoy.config.trace.v3.OpenTelemetryConfig",
+					"grpcService": {
+						"envoyGrpc": {
+							"clusterName": "otlp-collector"
+						}
+					},
+					"serviceName": "pomerium"
+				}
+			}
+		`, h)
+
+		t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf")
+		h, err = buildTracingHTTP(&config.Options{
+			TracingProvider: "otlp",
+		})
+		require.NoError(t, err)
+		require.Nil(t, h, "envoy only supports exporting traces using grpc")
+	})
 	t.Run("datadog", func(t *testing.T) {
 		h, err := buildTracingHTTP(&config.Options{
 			TracingProvider: "datadog",
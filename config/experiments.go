@@ -0,0 +1,98 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// A PolicyExperiment assigns the users of a route to variants of an experiment. Users are
+// assigned by a stable hash of their user id, so a user always gets the same variant, and the
+// variant is sent upstream in the X-Pomerium-Experiment header.
+type PolicyExperiment struct {
+	// Name is the name of the experiment. Renaming an experiment reassigns its users.
+	Name string `mapstructure:"name" yaml:"name" json:"name"`
+	// Variants are the variants users are assigned to.
+	Variants []PolicyExperimentVariant `mapstructure:"variants" yaml:"variants" json:"variants"`
+}
+
+// A PolicyExperimentVariant is a variant of an experiment.
+type PolicyExperimentVariant struct {
+	// Name is the name of the variant.
+	Name string `mapstructure:"name" yaml:"name" json:"name"`
+	// Weight is the share of users assigned to the variant, relative to the weights of the other
+	// variants. Defaults to 1.
+	Weight int `mapstructure:"weight" yaml:"weight,omitempty" json:"weight,omitempty"`
+}
+
+func (v *PolicyExperimentVariant) getWeight() int {
+	if v.Weight == 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// Validate checks the validity of the experiment.
+func (e *PolicyExperiment) Validate() error {
+	if !isExperimentToken(e.Name) {
+		return fmt.Errorf("invalid name: %q", e.Name)
+	}
+	if len(e.Variants) == 0 {
+		return fmt.Errorf("experiment %s has no variants", e.Name)
+	}
+	seen := make(map[string]bool, len(e.Variants))
+	for _, v := range e.Variants {
+		if !isExperimentToken(v.Name) {
+			return fmt.Errorf("experiment %s has an invalid variant name: %q", e.Name, v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("experiment %s has a duplicate variant: %s", e.Name, v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight < 0 {
+			return fmt.Errorf("experiment %s has a negative weight for variant %s", e.Name, v.Name)
+		}
+	}
+	return nil
+}
+
+// GetVariant returns the name of the variant the user is assigned to.
+func (e *PolicyExperiment) GetVariant(userID string) string {
+	total := 0
+	for i := range e.Variants {
+		total += e.Variants[i].getWeight()
+	}
+	if total == 0 {
+		return ""
+	}
+
+	h := sha256.Sum256([]byte(e.Name + "\x00" + userID))
+	n := int(binary.BigEndian.Uint64(h[:8]) % uint64(total))
+	for i := range e.Variants {
+		n -= e.Variants[i].getWeight()
+		if n < 0 {
+			return e.Variants[i].Name
+		}
+	}
+	return ""
+}
+
+// GetExperimentHeader returns the value of the X-Pomerium-Experiment header for the user, listing
+// the variant of each experiment of the route, like "checkout=b, search=control".
+func (p *Policy) GetExperimentHeader(userID string) string {
+	assignments := make([]string, 0, len(p.Experiments))
+	for i := range p.Experiments {
+		if variant := p.Experiments[i].GetVariant(userID); variant != "" {
+			assignments = append(assignments, p.Experiments[i].Name+"="+variant)
+		}
+	}
+	return strings.Join(assignments, ", ")
+}
+
+// isExperimentToken reports whether s can be used as a name in the experiment header.
+func isExperimentToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, "=,; \t\r\n\"") &&
+		(&http.Cookie{Name: s, Value: "x"}).Valid() == nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyExperiment_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name       string
+		experiment PolicyExperiment
+		expect     bool
+	}{
+		{"valid", PolicyExperiment{Name: "checkout", Variants: []PolicyExperimentVariant{{Name: "a"}, {Name: "b", Weight: 3}}}, true},
+		{"no name", PolicyExperiment{Variants: []PolicyExperimentVariant{{Name: "a"}}}, false},
+		{"invalid name", PolicyExperiment{Name: "a=b", Variants: []PolicyExperimentVariant{{Name: "a"}}}, false},
+		{"no variants", PolicyExperiment{Name: "checkout"}, false},
+		{"invalid variant name", PolicyExperiment{Name: "checkout", Variants: []PolicyExperimentVariant{{Name: "a,b"}}}, false},
+		{"duplicate variant", PolicyExperiment{Name: "checkout", Variants: []PolicyExperimentVariant{{Name: "a"}, {Name: "a"}}}, false},
+		{"negative weight", PolicyExperiment{Name: "checkout", Variants: []PolicyExperimentVariant{{Name: "a", Weight: -1}}}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.experiment.Validate()
+			if tc.expect {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicyExperiment_GetVariant(t *testing.T) {
+	t.Parallel()
+
+	experiment := PolicyExperiment{Name: "checkout", Variants: []PolicyExperimentVariant{
+		{Name: "control", Weight: 3},
+		{Name: "new"},
+	}}
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		variant := experiment.GetVariant(userID)
+		assert.Equal(t, variant, experiment.GetVariant(userID), "should be stable")
+		counts[variant]++
+	}
+	assert.Len(t, counts, 2)
+	assert.InDelta(t, 3000, counts["control"], 200)
+	assert.InDelta(t, 1000, counts["new"], 200)
+
+	p := Policy{Experiments: []PolicyExperiment{
+		experiment,
+		{Name: "search", Variants: []PolicyExperimentVariant{{Name: "only"}}},
+	}}
+	assert.Equal(t, "checkout="+experiment.GetVariant("user-1")+", search=only", p.GetExperimentHeader("user-1"))
+}
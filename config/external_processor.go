@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+// ExternalProcessorBodyMode is how request or response bodies are sent to an external processor.
+type ExternalProcessorBodyMode string
+
+// Supported external processor body modes.
+const (
+	ExternalProcessorBodyModeNone     ExternalProcessorBodyMode = "none"
+	ExternalProcessorBodyModeBuffered ExternalProcessorBodyMode = "buffered"
+	ExternalProcessorBodyModeStreamed ExternalProcessorBodyMode = "streamed"
+)
+
+// PolicyExternalProcessor sends the requests and responses of a route to an external processing
+// service, which implements the envoy ext_proc gRPC api, so it can transform them.
+//
+// Requests are only sent to the processor after pomerium has authorized them, and without the
+// pomerium session cookie or authorization header.
+type PolicyExternalProcessor struct {
+	// URL is the url of the gRPC service, using http for plaintext or https for TLS.
+	URL string `mapstructure:"url" yaml:"url,omitempty" json:"url,omitempty"`
+	// RequestBodyMode is how request bodies are sent to the processor. Defaults to none.
+	RequestBodyMode ExternalProcessorBodyMode `mapstructure:"request_body_mode" yaml:"request_body_mode,omitempty" json:"request_body_mode,omitempty"`
+	// ResponseBodyMode is how response bodies are sent to the processor. Defaults to none.
+	ResponseBodyMode ExternalProcessorBodyMode `mapstructure:"response_body_mode" yaml:"response_body_mode,omitempty" json:"response_body_mode,omitempty"`
+	// Timeout is how long to wait for each reply of the processor. Defaults to 200ms.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// FailOpen continues processing requests without the processor when it can't be reached,
+	// instead of failing them.
+	FailOpen bool `mapstructure:"fail_open" yaml:"fail_open,omitempty" json:"fail_open,omitempty"`
+}
+
+// Validate checks the validity of the external processor options.
+func (p *PolicyExternalProcessor) Validate() error {
+	if _, err := p.GetURL(); err != nil {
+		return err
+	}
+	for _, mode := range []ExternalProcessorBodyMode{p.RequestBodyMode, p.ResponseBodyMode} {
+		switch mode {
+		case "", ExternalProcessorBodyModeNone, ExternalProcessorBodyModeBuffered, ExternalProcessorBodyModeStreamed:
+		default:
+			return fmt.Errorf("unknown body mode: %s", mode)
+		}
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// GetURL returns the url of the external processor.
+func (p *PolicyExternalProcessor) GetURL() (*url.URL, error) {
+	if p.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	u, err := urlutil.ParseAndValidateURL(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme: %s", u.Scheme)
+	}
+	return u, nil
+}
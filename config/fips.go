@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// FIPSCipherSuites are the FIPS-approved TLS 1.2 cipher suites, in order of preference.
+var FIPSCipherSuites = []string{
+	"ECDHE-ECDSA-AES256-GCM-SHA384",
+	"ECDHE-RSA-AES256-GCM-SHA384",
+	"ECDHE-ECDSA-AES128-GCM-SHA256",
+	"ECDHE-RSA-AES128-GCM-SHA256",
+}
+
+// FIPSCurves are the FIPS-approved key exchange curves, in order of preference.
+var FIPSCurves = []string{"P-256", "P-384", "P-521"}
+
+// validateFIPSMode returns an error if any of the options aren't compliant with FIPS mode.
+func (o *Options) validateFIPSMode() error {
+	if o.TLSPostQuantumKeyExchange {
+		return fmt.Errorf("config: tls_post_quantum_key_exchange cannot be used with fips_mode")
+	}
+	for _, listener := range []struct {
+		name     string
+		settings *TLSSettings
+	}{
+		{"main", &o.ListenerTLS.Main},
+		{"grpc", &o.ListenerTLS.GRPC},
+		{"metrics", &o.ListenerTLS.Metrics},
+	} {
+		if err := listener.settings.validateFIPSMode(); err != nil {
+			return fmt.Errorf("config: invalid listener_tls %s settings for fips_mode: %w", listener.name, err)
+		}
+	}
+
+	if o.DataBrokerStorageEncryption.Provider == StorageEncryptionProviderLocal {
+		return fmt.Errorf("config: databroker_storage_encryption with local keys cannot be used with fips_mode")
+	}
+	if o.AuditKey != nil {
+		return fmt.Errorf("config: audit_key cannot be used with fips_mode")
+	}
+
+	signingKey, err := o.GetSigningKey()
+	if err != nil {
+		return fmt.Errorf("config: invalid signing_key: %w", err)
+	}
+	if len(signingKey) > 0 {
+		jwks, err := cryptutil.PrivateJWKsFromBytes(signingKey)
+		if err != nil {
+			return fmt.Errorf("config: invalid signing_key: %w", err)
+		}
+		for _, jwk := range jwks {
+			if err := cryptutil.CheckFIPSKey(jwk.Key); err != nil {
+				return fmt.Errorf("config: invalid signing_key for fips_mode: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *TLSSettings) validateFIPSMode() error {
+	if s.MinVersion == TLSVersion10 || s.MinVersion == TLSVersion11 {
+		return fmt.Errorf("min_version must be at least 1.2")
+	}
+	for _, suite := range s.CipherSuites {
+		if !isFIPSApproved(FIPSCipherSuites, suite) {
+			return fmt.Errorf("cipher suite %s is not fips-approved", suite)
+		}
+	}
+	for _, curve := range s.Curves {
+		if !isFIPSApproved(FIPSCurves, curve) {
+			return fmt.Errorf("curve %s is not fips-approved", curve)
+		}
+	}
+	return nil
+}
+
+func isFIPSApproved(approved []string, value string) bool {
+	for _, v := range approved {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -17,6 +17,8 @@ const (
 	StorageRedisName = "redis"
 	// StoragePostgresName is the name of the Postgres storage backend
 	StoragePostgresName = "postgres"
+	// StorageDynamoDBName is the name of the DynamoDB storage backend
+	StorageDynamoDBName = "dynamodb"
 	// StorageInMemoryName is the name of the in-memory storage backend
 	StorageInMemoryName = "memory"
 )
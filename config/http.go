@@ -3,8 +3,11 @@ package config
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"sync"
 
 	"github.com/pomerium/pomerium/internal/log"
@@ -13,13 +16,18 @@ import (
 )
 
 // NewHTTPTransport creates a new http transport. If CA or CAFile is set, the transport will
-// add the CA to system cert pool.
+// add the CA to system cert pool. Requests are sent through the outbound proxy, if one is set.
 func NewHTTPTransport(src Source) *http.Transport {
 	var (
 		lock      sync.Mutex
 		tlsConfig *tls.Config
+		proxyFunc = http.ProxyFromEnvironment
 	)
 	update := func(ctx context.Context, cfg *Config) {
+		lock.Lock()
+		proxyFunc = cfg.Options.OutboundProxy.GetProxyFunc()
+		lock.Unlock()
+
 		rootCAs, err := cryptutil.GetCertPool(cfg.Options.CA, cfg.Options.CAFile)
 		if err == nil {
 			lock.Lock()
@@ -36,6 +44,12 @@ func NewHTTPTransport(src Source) *http.Transport {
 	update(context.Background(), src.GetConfig())
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+		lock.Lock()
+		f := proxyFunc
+		lock.Unlock()
+		return f(r)
+	}
 	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
 		lock.Lock()
 		d := &tls.Dialer{
@@ -44,10 +58,42 @@ func NewHTTPTransport(src Source) *http.Transport {
 		lock.Unlock()
 		return d.DialContext(ctx, network, addr)
 	}
+	// DialTLSContext isn't used for requests sent through a proxy, so those verify the server
+	// certificate against the current root CAs themselves
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			lock.Lock()
+			cfg := tlsConfig
+			lock.Unlock()
+			var rootCAs *x509.CertPool
+			if cfg != nil {
+				rootCAs = cfg.RootCAs
+			}
+			return verifyServerCertificate(cs, rootCAs)
+		},
+	}
 	transport.ForceAttemptHTTP2 = true
 	return transport
 }
 
+func verifyServerCertificate(cs tls.ConnectionState, rootCAs *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("config: no server certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         rootCAs,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
 // NewPolicyHTTPTransport creates a new http RoundTripper for a policy.
 func NewPolicyHTTPTransport(options *Options, policy *Policy, disableHTTP2 bool) http.RoundTripper {
 	transport := http.DefaultTransport.(interface {
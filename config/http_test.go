@@ -36,6 +36,38 @@ func TestHTTPTransport(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHTTPTransportOutboundProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	src := NewStaticSource(&Config{
+		Options: &Options{
+			OutboundProxy: OutboundProxySettings{
+				HTTPProxy: proxy.URL,
+				NoProxy:   []string{"internal.example.com"},
+			},
+		},
+	})
+	transport := NewHTTPTransport(src)
+	client := &http.Client{
+		Transport: transport,
+	}
+	res, err := client.Get("http://idp.example.com/.well-known/openid-configuration")
+	if assert.NoError(t, err) {
+		res.Body.Close()
+	}
+	assert.Equal(t, []string{"http://idp.example.com/.well-known/openid-configuration"}, proxied)
+
+	req := httptest.NewRequest(http.MethodGet, "http://internal.example.com/", nil)
+	u, err := transport.Proxy(req)
+	assert.NoError(t, err)
+	assert.Nil(t, u, "no_proxy hosts should be connected to directly")
+}
+
 func TestPolicyHTTPTransport(t *testing.T) {
 	originalTransport := http.DefaultTransport
 	defer func() {
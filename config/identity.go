@@ -22,8 +22,9 @@ func (o *Options) GetIdentityProviderForID(idpID string) (*identity.Provider, er
 	return o.GetIdentityProviderForPolicy(nil)
 }
 
-// GetIdentityProviderForPolicy gets the identity provider associated with the given policy.
-// If policy is nil, or changes none of the default settings, the default provider is returned.
+// GetIdentityProviderForPolicy gets the identity provider associated with the given policy, taking
+// the settings of the policy's namespace into account. If policy is nil, or changes none of the
+// default settings, the default provider is returned.
 func (o *Options) GetIdentityProviderForPolicy(policy *Policy) (*identity.Provider, error) {
 	clientSecret, err := o.GetClientSecret()
 	if err != nil {
@@ -39,6 +40,23 @@ func (o *Options) GetIdentityProviderForPolicy(policy *Policy) (*identity.Provid
 		RequestParams: o.RequestParams,
 	}
 	if policy != nil {
+		if ns := o.GetNamespace(policy.Namespace); ns != nil {
+			if ns.IDPProvider != "" {
+				idp.Type = ns.IDPProvider
+			}
+			if ns.IDPProviderURL != "" {
+				idp.Url = ns.IDPProviderURL
+			}
+			if ns.IDPClientID != "" {
+				idp.ClientId = ns.IDPClientID
+			}
+			if ns.IDPClientSecret != "" {
+				idp.ClientSecret = ns.IDPClientSecret
+			}
+			if len(ns.IDPScopes) > 0 {
+				idp.Scopes = ns.IDPScopes
+			}
+		}
 		if policy.IDPClientID != "" {
 			idp.ClientId = policy.IDPClientID
 		}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+)
+
+// IdentityAliasSettings assign stable ids and aliases to the users and groups of the identity
+// provider. The user and groups policy criteria match the stable ids and aliases, so policies keep
+// working when the identity provider ids change, like after migrating to a new identity provider.
+//
+// Aliases may also be managed through the databroker API by writing pomerium.io/UserAlias and
+// pomerium.io/GroupAlias records.
+type IdentityAliasSettings struct {
+	Users  []IdentityAlias `mapstructure:"users" yaml:"users,omitempty"`
+	Groups []IdentityAlias `mapstructure:"groups" yaml:"groups,omitempty"`
+}
+
+// An IdentityAlias assigns a stable id and aliases to one or more identity provider ids.
+type IdentityAlias struct {
+	// ID is the stable id of the user or group.
+	ID string `mapstructure:"id" yaml:"id"`
+	// IdPIDs are the ids of the user or group in the identity provider, like the sub claim of a
+	// user.
+	IdPIDs []string `mapstructure:"idp_ids" yaml:"idp_ids"`
+	// Aliases are friendly names for the user or group.
+	Aliases []string `mapstructure:"aliases" yaml:"aliases,omitempty"`
+}
+
+// Validate validates the identity alias settings.
+func (s *IdentityAliasSettings) Validate() error {
+	if err := validateIdentityAliases(s.Users); err != nil {
+		return fmt.Errorf("config: invalid identity_aliases users: %w", err)
+	}
+	if err := validateIdentityAliases(s.Groups); err != nil {
+		return fmt.Errorf("config: invalid identity_aliases groups: %w", err)
+	}
+	return nil
+}
+
+func validateIdentityAliases(aliases []IdentityAlias) error {
+	seen := map[string]string{}
+	for _, alias := range aliases {
+		if alias.ID == "" {
+			return fmt.Errorf("id is required")
+		}
+		if len(alias.IdPIDs) == 0 {
+			return fmt.Errorf("%s: idp_ids is required", alias.ID)
+		}
+		for _, idpID := range alias.IdPIDs {
+			if idpID == "" {
+				return fmt.Errorf("%s: idp_ids must not be empty", alias.ID)
+			}
+			// an identity provider id can only have a single stable id
+			if other, ok := seen[idpID]; ok && other != alias.ID {
+				return fmt.Errorf("%s: idp id %q is already assigned to %s", alias.ID, idpID, other)
+			}
+			seen[idpID] = alias.ID
+		}
+	}
+	return nil
+}
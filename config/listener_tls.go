@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+)
+
+// TLS protocol versions which can be set as the minimum or maximum version of a listener.
+const (
+	TLSVersion10 = "1.0"
+	TLSVersion11 = "1.1"
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+var tlsVersionOrder = map[string]int{
+	TLSVersion10: 0,
+	TLSVersion11: 1,
+	TLSVersion12: 2,
+	TLSVersion13: 3,
+}
+
+// ListenerTLSSettings override the default TLS parameters of each downstream listener, so that
+// internet-facing and internal listeners can have different TLS postures.
+type ListenerTLSSettings struct {
+	// Main applies to the main listener, and the HTTP/3 listener if enabled.
+	Main TLSSettings `mapstructure:"main" yaml:"main,omitempty"`
+	// GRPC applies to the gRPC listener.
+	GRPC TLSSettings `mapstructure:"grpc" yaml:"grpc,omitempty"`
+	// Metrics applies to the metrics listener, if it is served over TLS.
+	Metrics TLSSettings `mapstructure:"metrics" yaml:"metrics,omitempty"`
+}
+
+// TLSSettings are the TLS parameters of a listener. Unset fields use the defaults.
+type TLSSettings struct {
+	// MinVersion is the minimum TLS protocol version, one of 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string `mapstructure:"min_version" yaml:"min_version,omitempty"`
+	// MaxVersion is the maximum TLS protocol version, one of 1.0, 1.1, 1.2 or 1.3.
+	MaxVersion string `mapstructure:"max_version" yaml:"max_version,omitempty"`
+	// CipherSuites are the cipher suites used with TLS 1.2 and earlier, in order of preference,
+	// using the OpenSSL names, e.g. ECDHE-ECDSA-AES256-GCM-SHA384.
+	CipherSuites []string `mapstructure:"cipher_suites" yaml:"cipher_suites,omitempty"`
+	// Curves are the key exchange curves, in order of preference, e.g. X25519 or P-256. They
+	// take precedence over tls_post_quantum_key_exchange.
+	Curves []string `mapstructure:"curves" yaml:"curves,omitempty"`
+	// ALPN are the application protocols negotiated with clients, e.g. h2 or http/1.1.
+	ALPN []string `mapstructure:"alpn" yaml:"alpn,omitempty"`
+}
+
+// Validate validates the listener TLS settings.
+func (s *ListenerTLSSettings) Validate() error {
+	for _, listener := range []struct {
+		name     string
+		settings *TLSSettings
+	}{
+		{"main", &s.Main},
+		{"grpc", &s.GRPC},
+		{"metrics", &s.Metrics},
+	} {
+		if err := listener.settings.validate(); err != nil {
+			return fmt.Errorf("config: invalid listener_tls %s settings: %w", listener.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *TLSSettings) validate() error {
+	for _, v := range []string{s.MinVersion, s.MaxVersion} {
+		if _, ok := tlsVersionOrder[v]; v != "" && !ok {
+			return fmt.Errorf("unsupported tls version %q, must be one of 1.0, 1.1, 1.2 or 1.3", v)
+		}
+	}
+	if s.MinVersion != "" && s.MaxVersion != "" &&
+		tlsVersionOrder[s.MinVersion] > tlsVersionOrder[s.MaxVersion] {
+		return fmt.Errorf("min_version %s is greater than max_version %s", s.MinVersion, s.MaxVersion)
+	}
+	for _, lst := range [][]string{s.CipherSuites, s.Curves, s.ALPN} {
+		for _, v := range lst {
+			if v == "" {
+				return fmt.Errorf("cipher_suites, curves and alpn must not contain empty values")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerTLSSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings ListenerTLSSettings
+		err      string
+	}{
+		{"empty", ListenerTLSSettings{}, ""},
+		{"versions", ListenerTLSSettings{Main: TLSSettings{MinVersion: "1.2", MaxVersion: "1.3"}}, ""},
+		{"invalid version", ListenerTLSSettings{GRPC: TLSSettings{MinVersion: "1.4"}}, `invalid listener_tls grpc settings: unsupported tls version "1.4"`},
+		{"min greater than max", ListenerTLSSettings{Metrics: TLSSettings{MinVersion: "1.3", MaxVersion: "1.2"}}, "min_version 1.3 is greater than max_version 1.2"},
+		{"empty alpn", ListenerTLSSettings{Main: TLSSettings{ALPN: []string{""}}}, "must not contain empty values"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
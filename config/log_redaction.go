@@ -0,0 +1,30 @@
+package config
+
+import (
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A LogRedaction redacts the value of an access or authorize log field.
+type LogRedaction struct {
+	// Field is the log field to redact, such as query, headers.Authorization or claims.email.
+	Field string `mapstructure:"field" yaml:"field"`
+	// Pattern is a regular expression matching the parts of the value to redact. If empty, the
+	// whole value is redacted.
+	Pattern string `mapstructure:"pattern" yaml:"pattern,omitempty"`
+	// Replacement replaces the redacted value. Defaults to [REDACTED].
+	Replacement string `mapstructure:"replacement" yaml:"replacement,omitempty"`
+}
+
+// GetLogRedactor returns a redactor for the log redactions. If there are no log redactions, nil is
+// returned, which doesn't redact anything.
+func (o *Options) GetLogRedactor() (*log.Redactor, error) {
+	redactions := make([]log.Redaction, 0, len(o.LogRedactions))
+	for _, r := range o.LogRedactions {
+		redactions = append(redactions, log.Redaction{
+			Field:       r.Field,
+			Pattern:     r.Pattern,
+			Replacement: r.Replacement,
+		})
+	}
+	return log.NewRedactor(redactions...)
+}
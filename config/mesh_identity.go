@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MeshIdentitySource is where the mesh identity of a request comes from.
+type MeshIdentitySource string
+
+// Supported mesh identity sources.
+const (
+	// MeshIdentitySourceXFCC trusts the URI of the last element of the x-forwarded-client-cert
+	// header, which is set by the mesh's sidecar or gateway in front of pomerium. The header is only
+	// trusted from trusted peers, which connect with a client certificate validated by the
+	// downstream mTLS CA.
+	MeshIdentitySourceXFCC MeshIdentitySource = "xfcc"
+	// MeshIdentitySourceClientCertificate uses the URI SAN of the validated client certificate, such
+	// as a SPIRE-issued x509-SVID, which requires downstream mTLS with the mesh's trust bundle as the
+	// client CA.
+	MeshIdentitySourceClientCertificate MeshIdentitySource = "client_certificate"
+)
+
+// MeshIdentity configures how pomerium accepts the SPIFFE identities of workloads in a service mesh,
+// such as istio or SPIRE, which can be used in policies with the spiffe_id criterion, and how the
+// identity of users is forwarded into the mesh.
+type MeshIdentity struct {
+	// Source is where the SPIFFE id of a request comes from.
+	Source MeshIdentitySource `mapstructure:"source" yaml:"source,omitempty" json:"source,omitempty"`
+	// TrustDomains are the SPIFFE trust domains to accept identities from, such as cluster.local.
+	TrustDomains []string `mapstructure:"trust_domains" yaml:"trust_domains,omitempty" json:"trust_domains,omitempty"`
+	// TrustedPeers are the SPIFFE ids of the sidecars or gateways which are allowed to set the
+	// x-forwarded-client-cert header, when the source is xfcc.
+	TrustedPeers []string `mapstructure:"trusted_peers" yaml:"trusted_peers,omitempty" json:"trusted_peers,omitempty"`
+	// ForwardBearerToken sends the pomerium JWT assertion to upstreams as a bearer token in the
+	// Authorization header too, which istio's RequestAuthentication reads by default, so
+	// AuthorizationPolicies can use the claims of the user with request.auth.claims.
+	ForwardBearerToken bool `mapstructure:"forward_bearer_token" yaml:"forward_bearer_token,omitempty" json:"forward_bearer_token,omitempty"`
+}
+
+// Validate checks the validity of the mesh identity options.
+func (m *MeshIdentity) Validate() error {
+	switch m.Source {
+	case MeshIdentitySourceXFCC, MeshIdentitySourceClientCertificate:
+	default:
+		return fmt.Errorf("unknown source: %q", m.Source)
+	}
+	if len(m.TrustDomains) == 0 {
+		return errors.New("trust_domains must not be empty")
+	}
+	for _, td := range m.TrustDomains {
+		if td == "" || td != strings.ToLower(td) || strings.ContainsAny(td, ":/") {
+			return fmt.Errorf("invalid trust domain: %q", td)
+		}
+	}
+	if m.Source == MeshIdentitySourceXFCC && len(m.TrustedPeers) == 0 {
+		return errors.New("trusted_peers must not be empty when the source is xfcc")
+	}
+	for _, id := range m.TrustedPeers {
+		if !isSPIFFEID(id) {
+			return fmt.Errorf("invalid trusted peer: %q", id)
+		}
+	}
+	return nil
+}
+
+// GetSource returns where the SPIFFE id of a request comes from. If m is nil, an empty source is returned.
+func (m *MeshIdentity) GetSource() MeshIdentitySource {
+	if m == nil {
+		return ""
+	}
+	return m.Source
+}
+
+// GetForwardBearerToken returns whether to send the JWT assertion as a bearer token.
+func (m *MeshIdentity) GetForwardBearerToken() bool {
+	return m != nil && m.ForwardBearerToken
+}
+
+// IsTrustedSPIFFEID returns true if the id is a SPIFFE id in one of the trust domains.
+func (m *MeshIdentity) IsTrustedSPIFFEID(id string) bool {
+	if m == nil || !isSPIFFEID(id) {
+		return false
+	}
+	u, _ := url.Parse(id)
+	for _, td := range m.TrustDomains {
+		if u.Host == td {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTrustedPeer returns true if id is the SPIFFE id of a peer allowed to set the
+// x-forwarded-client-cert header.
+func (m *MeshIdentity) IsTrustedPeer(id string) bool {
+	if m == nil || id == "" {
+		return false
+	}
+	for _, peer := range m.TrustedPeers {
+		if id == peer {
+			return true
+		}
+	}
+	return false
+}
+
+func isSPIFFEID(id string) bool {
+	u, err := url.Parse(id)
+	return err == nil && u.Scheme == "spiffe" && u.Host != "" && u.User == nil && u.RawQuery == "" && u.Fragment == ""
+}
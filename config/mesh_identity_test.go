@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeshIdentity(t *testing.T) {
+	t.Parallel()
+
+	m := &MeshIdentity{
+		Source:       MeshIdentitySourceXFCC,
+		TrustDomains: []string{"cluster.local"},
+		TrustedPeers: []string{"spiffe://cluster.local/ns/istio-system/sa/ingressgateway"},
+	}
+	assert.NoError(t, m.Validate())
+	assert.Error(t, (&MeshIdentity{Source: MeshIdentitySourceXFCC, TrustDomains: []string{"cluster.local"}}).Validate())
+	assert.Error(t, (&MeshIdentity{Source: MeshIdentitySourceXFCC, TrustDomains: []string{"cluster.local"}, TrustedPeers: []string{"cluster.local"}}).Validate())
+	assert.NoError(t, (&MeshIdentity{Source: MeshIdentitySourceClientCertificate, TrustDomains: []string{"cluster.local"}}).Validate())
+	assert.Error(t, (&MeshIdentity{Source: "unknown", TrustDomains: []string{"cluster.local"}}).Validate())
+	assert.Error(t, (&MeshIdentity{Source: MeshIdentitySourceXFCC}).Validate())
+	assert.Error(t, (&MeshIdentity{Source: MeshIdentitySourceXFCC, TrustDomains: []string{"spiffe://cluster.local"}}).Validate())
+
+	assert.True(t, m.IsTrustedSPIFFEID("spiffe://cluster.local/ns/default/sa/client"))
+	assert.False(t, m.IsTrustedSPIFFEID("spiffe://other.local/ns/default/sa/client"))
+	assert.False(t, m.IsTrustedSPIFFEID("https://cluster.local/ns/default/sa/client"))
+	assert.False(t, m.IsTrustedSPIFFEID("spiffe://user@cluster.local/ns/default/sa/client"))
+
+	assert.True(t, m.IsTrustedPeer("spiffe://cluster.local/ns/istio-system/sa/ingressgateway"))
+	assert.False(t, m.IsTrustedPeer("spiffe://cluster.local/ns/default/sa/client"))
+	assert.False(t, m.IsTrustedPeer(""))
+
+	var nilMesh *MeshIdentity
+	assert.False(t, nilMesh.IsTrustedSPIFFEID("spiffe://cluster.local/ns/default/sa/client"))
+	assert.Equal(t, MeshIdentitySource(""), nilMesh.GetSource())
+	assert.False(t, nilMesh.GetForwardBearerToken())
+	assert.False(t, nilMesh.IsTrustedPeer("spiffe://cluster.local/ns/istio-system/sa/ingressgateway"))
+}
@@ -29,8 +29,10 @@ type MetricsManager struct {
 	serviceName    string
 	addr           string
 	basicAuth      string
+	exemplars      bool
 	handler        http.Handler
 	endpoints      []MetricsScrapeEndpoint
+	otlpExporter   *metrics.OTLPExporter
 }
 
 // NewMetricsManager creates a new MetricsManager.
@@ -42,11 +44,15 @@ func NewMetricsManager(ctx context.Context, src Source) *MetricsManager {
 	metrics.RegisterInfoMetrics()
 	src.OnConfigChange(ctx, mgr.OnConfigChange)
 	mgr.OnConfigChange(ctx, src.GetConfig())
+	mgr.startOTLPExporter(ctx, src.GetConfig())
 	return mgr
 }
 
-// Close closes any underlying http server.
+// Close closes any underlying http server and OTLP exporter.
 func (mgr *MetricsManager) Close() error {
+	if mgr.otlpExporter != nil {
+		return mgr.otlpExporter.Shutdown(context.Background())
+	}
 	return nil
 }
 
@@ -56,6 +62,7 @@ func (mgr *MetricsManager) OnConfigChange(ctx context.Context, cfg *Config) {
 	defer mgr.mu.Unlock()
 
 	mgr.updateInfo(ctx, cfg)
+	metrics.SetLabelLimits(cfg.Options.MetricsCardinality.GetLabelLimits())
 	mgr.updateServer(ctx, cfg)
 }
 
@@ -93,6 +100,7 @@ func (mgr *MetricsManager) updateServer(ctx context.Context, cfg *Config) {
 
 	mgr.addr = cfg.Options.MetricsAddr
 	mgr.basicAuth = cfg.Options.MetricsBasicAuth
+	mgr.exemplars = cfg.Options.MetricsExemplars
 	mgr.installationID = cfg.Options.InstallationID
 	mgr.handler = nil
 
@@ -106,7 +114,7 @@ func (mgr *MetricsManager) updateServer(ctx context.Context, cfg *Config) {
 			Name: "envoy",
 			URL:  url.URL{Scheme: "http", Host: cfg.Options.MetricsAddr, Path: "/metrics/envoy"},
 		})
-	handler, err := metrics.PrometheusHandler(toInternalEndpoints(mgr.endpoints), mgr.installationID, defaultMetricsTimeout)
+	handler, err := metrics.PrometheusHandler(toInternalEndpoints(mgr.endpoints), mgr.installationID, defaultMetricsTimeout, mgr.exemplars)
 	if err != nil {
 		log.Error(ctx).Err(err).Msg("metrics: failed to create prometheus handler")
 		return
@@ -119,9 +127,25 @@ func (mgr *MetricsManager) updateServer(ctx context.Context, cfg *Config) {
 	mgr.handler = handler
 }
 
+// startOTLPExporter starts exporting metrics using OTLP if enabled by the OTEL_METRICS_EXPORTER
+// environment variable. As the exporter is configured from the environment, it's only started once.
+func (mgr *MetricsManager) startOTLPExporter(ctx context.Context, cfg *Config) {
+	if !metrics.OTLPEnabled() {
+		return
+	}
+
+	exporter, err := metrics.NewOTLPExporter(ctx, telemetry.ServiceName(cfg.Options.Services))
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("metrics: failed to create otlp exporter")
+		return
+	}
+	mgr.otlpExporter = exporter
+}
+
 func (mgr *MetricsManager) configUnchanged(cfg *Config) bool {
 	return cfg.Options.MetricsAddr == mgr.addr &&
 		cfg.Options.MetricsBasicAuth == mgr.basicAuth &&
+		cfg.Options.MetricsExemplars == mgr.exemplars &&
 		cfg.Options.InstallationID == mgr.installationID &&
 		reflect.DeepEqual(mgr.endpoints, cfg.MetricsScrapeEndpoints)
 }
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+)
+
+// MetricsCardinalitySettings bound the cardinality of the labels of pomerium's metrics, so that
+// labels such as routes or user ids can't create an unbounded number of time series.
+type MetricsCardinalitySettings struct {
+	// MaxLabelValues is the maximum number of distinct values recorded for each label. Further
+	// values are recorded as __overflow__. 0 means no limit.
+	MaxLabelValues int `mapstructure:"max_label_values" yaml:"max_label_values,omitempty"`
+	// HashedLabels are the labels whose values are replaced by a hash, e.g. route_id.
+	HashedLabels []string `mapstructure:"hashed_labels" yaml:"hashed_labels,omitempty"`
+	// HashBuckets is the number of distinct hashes of hashed labels. Defaults to 64.
+	HashBuckets int `mapstructure:"hash_buckets" yaml:"hash_buckets,omitempty"`
+}
+
+// GetLabelLimits returns the label limits of the metrics package.
+func (s *MetricsCardinalitySettings) GetLabelLimits() metrics.LabelLimits {
+	return metrics.LabelLimits{
+		MaxValues:    s.MaxLabelValues,
+		HashedLabels: s.HashedLabels,
+		HashBuckets:  s.HashBuckets,
+	}
+}
+
+// Validate validates the metrics cardinality settings.
+func (s *MetricsCardinalitySettings) Validate() error {
+	if s.MaxLabelValues < 0 {
+		return fmt.Errorf("config: metrics_cardinality max_label_values must not be negative")
+	}
+	if s.HashBuckets < 0 {
+		return fmt.Errorf("config: metrics_cardinality hash_buckets must not be negative")
+	}
+	for _, label := range s.HashedLabels {
+		if label == "" {
+			return fmt.Errorf("config: metrics_cardinality hashed_labels must not be empty")
+		}
+	}
+	return nil
+}
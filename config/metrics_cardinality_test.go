@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCardinalitySettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings MetricsCardinalitySettings
+		valid    bool
+	}{
+		{"disabled", MetricsCardinalitySettings{}, true},
+		{"valid", MetricsCardinalitySettings{MaxLabelValues: 100, HashedLabels: []string{"route_id"}, HashBuckets: 16}, true},
+		{"negative max label values", MetricsCardinalitySettings{MaxLabelValues: -1}, false},
+		{"negative hash buckets", MetricsCardinalitySettings{HashBuckets: -1}, false},
+		{"empty hashed label", MetricsCardinalitySettings{HashedLabels: []string{""}}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
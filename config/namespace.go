@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+var namespaceNameRE = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// A Namespace is a tenant hosted by the deployment, with its own routes, identity provider and
+// branding. Sessions are bound to the identity provider they were created with, so a session
+// created for the routes of one namespace can't be used for the routes of another namespace
+// with a different identity provider.
+type Namespace struct {
+	// Name identifies the namespace. It must be a lowercase DNS label.
+	Name string `mapstructure:"name" yaml:"name"`
+	// Routes are the routes of the namespace.
+	Routes []Policy `mapstructure:"routes" yaml:"routes,omitempty"`
+
+	// The identity provider settings override the global identity provider settings for the
+	// routes of the namespace. Routes can still override the client id and secret.
+	IDPProvider     string   `mapstructure:"idp_provider" yaml:"idp_provider,omitempty"`
+	IDPProviderURL  string   `mapstructure:"idp_provider_url" yaml:"idp_provider_url,omitempty"`
+	IDPClientID     string   `mapstructure:"idp_client_id" yaml:"idp_client_id,omitempty"`
+	IDPClientSecret string   `mapstructure:"idp_client_secret" yaml:"idp_client_secret,omitempty"`
+	IDPScopes       []string `mapstructure:"idp_scopes" yaml:"idp_scopes,omitempty"`
+
+	// Theme replaces the global theme on the error pages of the namespace's routes.
+	Theme *ThemeSettings `mapstructure:"theme" yaml:"theme,omitempty"`
+}
+
+// Validate validates the namespace.
+func (ns *Namespace) Validate() error {
+	if !namespaceNameRE.MatchString(ns.Name) {
+		return fmt.Errorf("config: invalid namespace name %q, must be a lowercase dns label", ns.Name)
+	}
+	if ns.IDPProviderURL != "" {
+		if _, err := urlutil.ParseAndValidateURL(ns.IDPProviderURL); err != nil {
+			return fmt.Errorf("config: namespace %s has an invalid idp_provider_url: %w", ns.Name, err)
+		}
+	}
+	if ns.Theme != nil {
+		if ns.Theme.TemplatesDirectory != "" {
+			return fmt.Errorf("config: namespace %s theme cannot set templates_directory", ns.Name)
+		}
+		if err := ns.Theme.Validate(); err != nil {
+			return fmt.Errorf("config: namespace %s has an invalid theme: %w", ns.Name, err)
+		}
+	}
+	for i := range ns.Routes {
+		if ns.Routes[i].IsEgress() {
+			return fmt.Errorf("config: namespace %s cannot have egress routes: %s", ns.Name, ns.Routes[i].From)
+		}
+	}
+	return nil
+}
+
+// GetNamespace returns the namespace with the given name, or nil if there is none.
+func (o *Options) GetNamespace(name string) *Namespace {
+	if name == "" {
+		return nil
+	}
+	for i := range o.Namespaces {
+		if o.Namespaces[i].Name == name {
+			return &o.Namespaces[i]
+		}
+	}
+	return nil
+}
+
+// GetThemeForPolicy returns the theme of the policy's namespace, or the global theme.
+func (o *Options) GetThemeForPolicy(policy *Policy) *ThemeSettings {
+	if policy != nil {
+		if ns := o.GetNamespace(policy.Namespace); ns != nil && ns.Theme != nil {
+			return ns.Theme
+		}
+	}
+	return &o.Theme
+}
+
+// GetThemeForRequestURL returns the theme of the namespace of the route matching the request URL,
+// or the global theme.
+func (o *Options) GetThemeForRequestURL(requestURL url.URL) *ThemeSettings {
+	for _, p := range o.GetAllPolicies() {
+		p := p
+		if p.Matches(requestURL) {
+			return o.GetThemeForPolicy(&p)
+		}
+	}
+	return &o.Theme
+}
+
+// getNamespacePolicies returns the routes of all the namespaces, with their namespace set.
+func (o *Options) getNamespacePolicies() []Policy {
+	var policies []Policy
+	for _, ns := range o.Namespaces {
+		for _, p := range ns.Routes {
+			p.Namespace = ns.Name
+			policies = append(policies, p)
+		}
+	}
+	return policies
+}
+
+func (o *Options) validateNamespaces() error {
+	names := make(map[string]struct{}, len(o.Namespaces))
+	for i := range o.Namespaces {
+		ns := &o.Namespaces[i]
+		if err := ns.Validate(); err != nil {
+			return err
+		}
+		if _, ok := names[ns.Name]; ok {
+			return fmt.Errorf("config: duplicate namespace name: %s", ns.Name)
+		}
+		names[ns.Name] = struct{}{}
+	}
+
+	// a route can only belong to one namespace, otherwise requests could be authorized with the
+	// identity provider of another namespace
+	namespaceForFrom := make(map[string]string)
+	for _, p := range o.GetAllPolicies() {
+		if other, ok := namespaceForFrom[p.From]; ok && other != p.Namespace {
+			return fmt.Errorf("config: %s is used by routes in different namespaces", p.From)
+		}
+		namespaceForFrom[p.From] = p.Namespace
+	}
+	return nil
+}
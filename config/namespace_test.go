@@ -0,0 +1,74 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaces(t *testing.T) {
+	t.Parallel()
+
+	newRoute := func(from string) Policy {
+		p := Policy{From: from, To: mustParseWeightedURLs(t, "https://upstream.example.com")}
+		require.NoError(t, p.Validate())
+		return p
+	}
+
+	o := NewDefaultOptions()
+	o.ClientID = "GLOBAL_CLIENT_ID"
+	o.ProviderURL = "https://idp.example.com"
+	o.Routes = []Policy{newRoute("https://global.example.com")}
+	o.Namespaces = []Namespace{{
+		Name:           "sales",
+		Routes:         []Policy{newRoute("https://sales.example.com")},
+		IDPProviderURL: "https://sales-idp.example.com",
+		IDPClientID:    "SALES_CLIENT_ID",
+		Theme:          &ThemeSettings{PrimaryColor: "#ff0000"},
+	}}
+	require.NoError(t, o.validateNamespaces())
+
+	policies := o.GetAllPolicies()
+	require.Len(t, policies, 2)
+	assert.Equal(t, "", policies[0].Namespace)
+	assert.Equal(t, "sales", policies[1].Namespace)
+
+	t.Run("identity provider", func(t *testing.T) {
+		global, err := o.GetIdentityProviderForPolicy(&policies[0])
+		require.NoError(t, err)
+		assert.Equal(t, "GLOBAL_CLIENT_ID", global.GetClientId())
+
+		sales, err := o.GetIdentityProviderForPolicy(&policies[1])
+		require.NoError(t, err)
+		assert.Equal(t, "SALES_CLIENT_ID", sales.GetClientId())
+		assert.Equal(t, "https://sales-idp.example.com", sales.GetUrl())
+		assert.NotEqual(t, global.GetId(), sales.GetId())
+	})
+	t.Run("theme", func(t *testing.T) {
+		assert.Equal(t, "#ff0000", o.GetThemeForRequestURL(*mustParseURL(t, "https://sales.example.com/")).PrimaryColor)
+		assert.Same(t, &o.Theme, o.GetThemeForRequestURL(*mustParseURL(t, "https://global.example.com/")))
+	})
+	t.Run("validate", func(t *testing.T) {
+		invalid := *o
+		invalid.Namespaces = []Namespace{{Name: "Sales"}}
+		assert.Error(t, invalid.validateNamespaces(), "invalid name")
+
+		invalid.Namespaces = []Namespace{{Name: "sales"}, {Name: "sales"}}
+		assert.Error(t, invalid.validateNamespaces(), "duplicate name")
+
+		invalid.Namespaces = []Namespace{{Name: "sales", Routes: []Policy{newRoute("https://global.example.com")}}}
+		assert.Error(t, invalid.validateNamespaces(), "route in different namespaces")
+
+		invalid.Namespaces = []Namespace{{Name: "sales", Theme: &ThemeSettings{TemplatesDirectory: "/tmp"}}}
+		assert.Error(t, invalid.validateNamespaces(), "templates directory")
+	})
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	return u
+}
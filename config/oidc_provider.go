@@ -0,0 +1,112 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// DefaultOIDCProviderTokenLifetime is the default lifetime of the tokens issued by the OIDC
+// provider.
+const DefaultOIDCProviderTokenLifetime = time.Hour
+
+// OIDCProviderSettings configure the built-in OpenID Connect provider, which lets downstream
+// applications use Pomerium as their identity provider. Tokens are issued for the user's existing
+// Pomerium session and signed with the signing key.
+//
+// Redirect URIs of clients should be served behind Pomerium routes, so the policies of those
+// routes apply to users signing in to the client.
+type OIDCProviderSettings struct {
+	// Enabled turns on the OIDC provider endpoints of the authenticate service.
+	Enabled bool `mapstructure:"enabled" yaml:"enabled,omitempty"`
+	// Clients are the registered clients. More clients can be registered with databroker records.
+	Clients []OIDCProviderClient `mapstructure:"clients" yaml:"clients,omitempty"`
+	// TokenLifetime is the lifetime of the issued ID and access tokens. Defaults to an hour.
+	TokenLifetime time.Duration `mapstructure:"token_lifetime" yaml:"token_lifetime,omitempty"`
+}
+
+// OIDCProviderClient is a client of the OIDC provider.
+type OIDCProviderClient struct {
+	// ID is the client id.
+	ID string `mapstructure:"client_id" yaml:"client_id,omitempty" json:"client_id"`
+	// Secret is the client secret. Clients without a secret are public clients and must use
+	// PKCE.
+	Secret string `mapstructure:"client_secret" yaml:"client_secret,omitempty" json:"client_secret,omitempty"`
+	// RedirectURIs are the allowed redirect URIs of the client.
+	RedirectURIs []string `mapstructure:"redirect_uris" yaml:"redirect_uris,omitempty" json:"redirect_uris"`
+}
+
+// GetTokenLifetime returns the lifetime of the issued tokens.
+func (s *OIDCProviderSettings) GetTokenLifetime() time.Duration {
+	if s.TokenLifetime <= 0 {
+		return DefaultOIDCProviderTokenLifetime
+	}
+	return s.TokenLifetime
+}
+
+// GetClient returns the client with the given id, or nil if there isn't one.
+func (s *OIDCProviderSettings) GetClient(id string) *OIDCProviderClient {
+	for i := range s.Clients {
+		if s.Clients[i].ID == id {
+			return &s.Clients[i]
+		}
+	}
+	return nil
+}
+
+// Validate validates the OIDC provider settings.
+func (s *OIDCProviderSettings) Validate() error {
+	if s.TokenLifetime < 0 {
+		return fmt.Errorf("config: oidc_provider token_lifetime must not be negative")
+	}
+	seen := make(map[string]struct{}, len(s.Clients))
+	for i := range s.Clients {
+		if err := s.Clients[i].Validate(); err != nil {
+			return fmt.Errorf("config: invalid oidc_provider client: %w", err)
+		}
+		if _, ok := seen[s.Clients[i].ID]; ok {
+			return fmt.Errorf("config: duplicate oidc_provider client_id: %q", s.Clients[i].ID)
+		}
+		seen[s.Clients[i].ID] = struct{}{}
+	}
+	return nil
+}
+
+// Validate validates the client.
+func (c *OIDCProviderClient) Validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("client_id is required")
+	}
+	if len(c.RedirectURIs) == 0 {
+		return fmt.Errorf("client %q has no redirect_uris", c.ID)
+	}
+	for _, rawURI := range c.RedirectURIs {
+		u, err := url.Parse(rawURI)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Fragment != "" {
+			return fmt.Errorf("client %q has an invalid redirect_uri: %q", c.ID, rawURI)
+		}
+	}
+	return nil
+}
+
+// IsPublic returns true if the client has no secret.
+func (c *OIDCProviderClient) IsPublic() bool {
+	return c.Secret == ""
+}
+
+// CheckSecret reports whether secret is the secret of the client.
+func (c *OIDCProviderClient) CheckSecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) == 1
+}
+
+// HasRedirectURI reports whether uri is one of the redirect URIs of the client. URIs must match
+// exactly.
+func (c *OIDCProviderClient) HasRedirectURI(uri string) bool {
+	for _, redirectURI := range c.RedirectURIs {
+		if redirectURI == uri {
+			return true
+		}
+	}
+	return false
+}
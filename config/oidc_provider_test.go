@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCProviderSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	client := OIDCProviderClient{ID: "app", Secret: "secret", RedirectURIs: []string{"https://app.example.com/callback"}}
+	for _, tc := range []struct {
+		name     string
+		settings OIDCProviderSettings
+		expect   bool
+	}{
+		{"valid", OIDCProviderSettings{Enabled: true, Clients: []OIDCProviderClient{client}}, true},
+		{"public client", OIDCProviderSettings{Clients: []OIDCProviderClient{{ID: "cli", RedirectURIs: []string{"http://127.0.0.1:8000/"}}}}, true},
+		{"no client id", OIDCProviderSettings{Clients: []OIDCProviderClient{{RedirectURIs: []string{"https://app.example.com/callback"}}}}, false},
+		{"no redirect uris", OIDCProviderSettings{Clients: []OIDCProviderClient{{ID: "app"}}}, false},
+		{"relative redirect uri", OIDCProviderSettings{Clients: []OIDCProviderClient{{ID: "app", RedirectURIs: []string{"/callback"}}}}, false},
+		{"redirect uri with fragment", OIDCProviderSettings{Clients: []OIDCProviderClient{{ID: "app", RedirectURIs: []string{"https://app.example.com/#x"}}}}, false},
+		{"duplicate client", OIDCProviderSettings{Clients: []OIDCProviderClient{client, client}}, false},
+		{"negative token lifetime", OIDCProviderSettings{TokenLifetime: -1}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.settings.Validate()
+			if tc.expect {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderClient(t *testing.T) {
+	t.Parallel()
+
+	settings := OIDCProviderSettings{Clients: []OIDCProviderClient{
+		{ID: "app", Secret: "secret", RedirectURIs: []string{"https://app.example.com/callback"}},
+	}}
+	client := settings.GetClient("app")
+	if assert.NotNil(t, client) {
+		assert.False(t, client.IsPublic())
+		assert.True(t, client.CheckSecret("secret"))
+		assert.False(t, client.CheckSecret("wrong"))
+		assert.True(t, client.HasRedirectURI("https://app.example.com/callback"))
+		assert.False(t, client.HasRedirectURI("https://app.example.com/callback/"))
+	}
+	assert.Nil(t, settings.GetClient("other"))
+	assert.Equal(t, DefaultOIDCProviderTokenLifetime, settings.GetTokenLifetime())
+}
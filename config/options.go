@@ -3,11 +3,15 @@ package config
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -21,14 +25,16 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 	"github.com/volatiletech/null/v9"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/pomerium/csrf"
 	"github.com/pomerium/pomerium/internal/atomicutil"
 	"github.com/pomerium/pomerium/internal/hashutil"
-	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/identity/oauth"
 	"github.com/pomerium/pomerium/internal/identity/oauth/apple"
+	"github.com/pomerium/pomerium/internal/identity/saml"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sets"
 	"github.com/pomerium/pomerium/internal/telemetry"
@@ -74,6 +80,24 @@ type Options struct {
 	// AuthorizeLogFields are the fields to log in authorize logs.
 	AuthorizeLogFields []log.AuthorizeLogField `mapstructure:"authorize_log_fields" yaml:"authorize_log_fields,omitempty"`
 
+	// AccessLogFormat is the output format of access logs: json, logfmt, apache-combined, cef or
+	// leef. Defaults to json.
+	AccessLogFormat log.Format `mapstructure:"access_log_format" yaml:"access_log_format,omitempty"`
+
+	// AccessLogSyslog additionally writes access logs to syslog.
+	AccessLogSyslog *SyslogSettings `mapstructure:"access_log_syslog" yaml:"access_log_syslog,omitempty"`
+
+	// AuthorizeLogFormat is the output format of authorize logs: json, logfmt, cef or leef.
+	// Defaults to json.
+	AuthorizeLogFormat log.Format `mapstructure:"authorize_log_format" yaml:"authorize_log_format,omitempty"`
+
+	// AuthorizeDecisionTail publishes authorize decisions to the databroker, so they can be
+	// followed live with `pomerium tail`.
+	AuthorizeDecisionTail bool `mapstructure:"authorize_decision_tail" yaml:"authorize_decision_tail,omitempty"`
+
+	// LogRedactions redact the values of access and authorize log fields.
+	LogRedactions []LogRedaction `mapstructure:"log_redactions" yaml:"log_redactions,omitempty"`
+
 	// SharedKey is the shared secret authorization key used to mutually authenticate
 	// requests between services.
 	SharedKey        string `mapstructure:"shared_secret" yaml:"shared_secret,omitempty"`
@@ -95,6 +119,21 @@ type Options struct {
 	// DNSLookupFamily is the DNS IP address resolution policy.
 	// If this setting is not specified, the value defaults to V4_PREFERRED.
 	DNSLookupFamily string `mapstructure:"dns_lookup_family" yaml:"dns_lookup_family,omitempty"`
+	// DNSResolvers are the addresses, like 10.0.0.2 or 10.0.0.2:53, of the DNS servers used to
+	// resolve upstream hostnames in place of the system resolvers, such as the internal view of a
+	// split-horizon DNS.
+	DNSResolvers []string `mapstructure:"dns_resolvers" yaml:"dns_resolvers,omitempty"`
+	// DNSRefreshRate is how often upstream hostnames are resolved again. It's only used if
+	// DNSRespectTTL is disabled, or records have no TTL. Defaults to 5s.
+	DNSRefreshRate time.Duration `mapstructure:"dns_refresh_rate" yaml:"dns_refresh_rate,omitempty"`
+	// DNSRespectTTL resolves upstream hostnames again when their records expire. Defaults to true.
+	DNSRespectTTL *bool `mapstructure:"dns_respect_ttl" yaml:"dns_respect_ttl,omitempty"`
+
+	// ConsulAddress is the address of the Consul agent used to discover `consul+` route upstreams.
+	// If this setting is not specified, the value defaults to http://127.0.0.1:8500.
+	ConsulAddress string `mapstructure:"consul_address" yaml:"consul_address,omitempty"`
+	// ConsulToken is the ACL token sent with Consul catalog requests.
+	ConsulToken string `mapstructure:"consul_token" yaml:"consul_token,omitempty"`
 
 	CertificateFiles []certificateFilePair `mapstructure:"certificates" yaml:"certificates,omitempty"`
 
@@ -115,13 +154,53 @@ type Options struct {
 	WriteTimeout time.Duration `mapstructure:"timeout_write" yaml:"timeout_write,omitempty"`
 	IdleTimeout  time.Duration `mapstructure:"timeout_idle" yaml:"timeout_idle,omitempty"`
 
+	// DrainTimeout is how long connections to a listener which is removed or changed by a config
+	// reload, or to the previous envoy process on a restart, are drained before they're closed.
+	// Clients are asked to close the connection immediately, connections still open at the
+	// deadline are force-closed. Envoy reports drained connections in the
+	// http.<prefix>.downstream_cx_drain_close metric and connections force-closed with an active
+	// request in http.<prefix>.downstream_cx_destroy_local_active_rq. Defaults to 60 seconds.
+	DrainTimeout time.Duration `mapstructure:"drain_timeout" yaml:"drain_timeout,omitempty"`
+
+	// HotRestart lets a new pomerium process take over the envoy listeners of a running pomerium
+	// process on the same host without dropping connections, so the binary can be upgraded in
+	// place. The previous process drains its connections for the drain timeout and then exits.
+	// It's only supported on linux.
+	HotRestart bool `mapstructure:"hot_restart" yaml:"hot_restart,omitempty"`
+
 	// Policies define per-route configuration and access control policies.
 	Policies   []Policy `mapstructure:"policy"`
 	PolicyFile string   `mapstructure:"policy_file" yaml:"policy_file,omitempty"`
 	Routes     []Policy `mapstructure:"routes"`
 
-	// AdditionalPolicies are any additional policies added to the options.
-	AdditionalPolicies []Policy `yaml:"-"`
+	// AdditionalPolicies are any additional policies added to the options. They're pointers so that
+	// unchanged policies are shared between configs, and must not be modified once added.
+	AdditionalPolicies []*Policy `yaml:"-"`
+
+	// Namespaces are the tenants hosted by the deployment, each with their own routes, identity
+	// provider and branding.
+	Namespaces []Namespace `mapstructure:"namespaces" yaml:"namespaces,omitempty"`
+
+	// ForwardProxyAddr, if set, specifies the host and port to run the SOCKS5 and HTTP forward proxy on.
+	// If empty, no forward proxy is started.
+	ForwardProxyAddr string `mapstructure:"forward_proxy_address" yaml:"forward_proxy_address,omitempty"`
+	// EgressPolicies define the destinations reachable through the forward proxy and who may access them.
+	// They are not routed by the proxy service.
+	EgressPolicies []Policy `mapstructure:"egress_policies" yaml:"egress_policies,omitempty"`
+
+	// ExtAuthzAddr, if set, specifies the host and port to run the standalone ext_authz server on,
+	// which lets proxies other than pomerium's own envoy authorize requests against the routes. If
+	// empty, no ext_authz server is started.
+	ExtAuthzAddr string `mapstructure:"ext_authz_address" yaml:"ext_authz_address,omitempty"`
+	// ExtAuthzSigningKey, if set, is a base64 encoded key of at least 32 bytes used to sign the responses
+	// of the ext_authz server's http endpoints, so forward authentication proxies can verify them.
+	ExtAuthzSigningKey string `mapstructure:"ext_authz_signing_key" yaml:"ext_authz_signing_key,omitempty"`
+
+	// MeshIdentity, if set, accepts the SPIFFE identities of service mesh workloads.
+	MeshIdentity *MeshIdentity `mapstructure:"mesh_identity" yaml:"mesh_identity,omitempty"`
+
+	// CIDRSets are named collections of IP ranges which can be referenced by policy ip allow and deny lists.
+	CIDRSets []CIDRSet `mapstructure:"cidr_sets" yaml:"cidr_sets,omitempty"`
 
 	// AuthenticateURL represents the externally accessible http endpoints
 	// used for authentication requests and callbacks
@@ -147,6 +226,47 @@ type Options struct {
 	CookieExpire     time.Duration `mapstructure:"cookie_expire" yaml:"cookie_expire,omitempty"`
 	CookieSameSite   string        `mapstructure:"cookie_same_site" yaml:"cookie_same_site,omitempty"`
 
+	// SessionIdleTimeout is how long a session may go unused before it's no longer accepted, even
+	// if cookie_expire hasn't elapsed yet. If unset, sessions don't expire due to inactivity.
+	SessionIdleTimeout time.Duration `mapstructure:"session_idle_timeout" yaml:"session_idle_timeout,omitempty"`
+
+	// IdPOutageGracePeriod is how long sessions are still accepted after their tokens expire when
+	// they can't be refreshed because the identity provider is unreachable. Requests made during the
+	// grace period get an X-Pomerium-Degraded response header. If unset, sessions stop working as
+	// soon as their tokens expire.
+	IdPOutageGracePeriod time.Duration `mapstructure:"idp_outage_grace_period" yaml:"idp_outage_grace_period,omitempty"`
+
+	// StatelessSessions encrypts the session and user records into the session cookie, so the
+	// authorize service doesn't need to fetch them from the databroker. Sessions are only updated
+	// on sign in, so changes to them, like refreshed claims or deleting a session, aren't seen
+	// until the session cookie expires, and session_idle_timeout can't be used. Revocations using
+	// the management api still apply.
+	StatelessSessions bool `mapstructure:"stateless_sessions" yaml:"stateless_sessions,omitempty"`
+
+	// SessionTransport is how sessions are handed to clients after signing in. With cookie, the
+	// default, the session is saved in a cookie, split across several cookies if it's too large
+	// for one. With header, the session is added to the redirect url as the pomerium_jwt query
+	// param, for clients which can't store large cookies to send in an Authorization: Pomerium
+	// header instead.
+	SessionTransport string `mapstructure:"session_transport" yaml:"session_transport,omitempty"`
+
+	// ClaimLimits drops or truncates identity provider claims before they're stored in sessions.
+	ClaimLimits *ClaimLimits `mapstructure:"claim_limits" yaml:"claim_limits,omitempty"`
+
+	// AgentSessionTTL is how long a desktop agent session can keep refreshing the session it was
+	// created from. If unset, DefaultAgentSessionTTL is used.
+	AgentSessionTTL time.Duration `mapstructure:"agent_session_ttl" yaml:"agent_session_ttl,omitempty"`
+
+	// ProgrammaticRefreshTokenTTL is how long a refresh token issued by the programmatic login api
+	// can keep refreshing its session. If unset, DefaultProgrammaticRefreshTokenTTL is used.
+	ProgrammaticRefreshTokenTTL time.Duration `mapstructure:"programmatic_refresh_token_ttl" yaml:"programmatic_refresh_token_ttl,omitempty"`
+
+	// KubernetesReauthorizeInterval is how often the session of a long-lived kubernetes connection,
+	// like kubectl exec, port-forward or logs -f, is checked again. The connection is closed once
+	// the session is signed out, expired or revoked. If unset, DefaultKubernetesReauthorizeInterval
+	// is used.
+	KubernetesReauthorizeInterval time.Duration `mapstructure:"kubernetes_reauthorize_interval" yaml:"kubernetes_reauthorize_interval,omitempty"`
+
 	// Identity provider configuration variables as specified by RFC6749
 	// https://openid.net/specs/openid-connect-basic-1_0.html#RFC6749
 	ClientID         string   `mapstructure:"idp_client_id" yaml:"idp_client_id,omitempty"`
@@ -163,6 +283,11 @@ type Options struct {
 	// https://openid.net/specs/openid-connect-basic-1_0.html#RequestParameters
 	RequestParams map[string]string `mapstructure:"idp_request_params" yaml:"idp_request_params,omitempty"`
 
+	// ClaimsRefreshInterval is how often user and session claims, like group membership, are
+	// re-fetched from the identity provider, independently of refreshing the oauth tokens.
+	// If unset, the identity manager default is used.
+	ClaimsRefreshInterval time.Duration `mapstructure:"idp_claims_refresh_interval" yaml:"idp_claims_refresh_interval,omitempty"`
+
 	// AuthorizeURLString is the routable destination of the authorize service's
 	// gRPC endpoint. NOTE: As many load balancers do not support
 	// externally routed gRPC so this may be an internal location.
@@ -184,6 +309,18 @@ type Options struct {
 	SigningKey     string `mapstructure:"signing_key" yaml:"signing_key,omitempty"`
 	SigningKeyFile string `mapstructure:"signing_key_file" yaml:"signing_key_file,omitempty"`
 
+	// SSHUserCAKey is the private key used to sign the short-lived user certificates minted for ssh routes.
+	SSHUserCAKey     string `mapstructure:"ssh_user_ca_key" yaml:"ssh_user_ca_key,omitempty"`
+	SSHUserCAKeyFile string `mapstructure:"ssh_user_ca_key_file" yaml:"ssh_user_ca_key_file,omitempty"`
+	// SSHHostKey is the private key presented to ssh clients. If not set, one is derived from the shared secret.
+	SSHHostKey     string `mapstructure:"ssh_host_key" yaml:"ssh_host_key,omitempty"`
+	SSHHostKeyFile string `mapstructure:"ssh_host_key_file" yaml:"ssh_host_key_file,omitempty"`
+	// SSHCertificateTTL is how long the certificates minted for ssh routes are valid for.
+	// If this setting is not specified, the value defaults to 5 minutes.
+	SSHCertificateTTL time.Duration `mapstructure:"ssh_certificate_ttl" yaml:"ssh_certificate_ttl,omitempty"`
+	// SSHRecordingDir is the directory ssh sessions are recorded to. If not set, sessions are not recorded.
+	SSHRecordingDir string `mapstructure:"ssh_recording_dir" yaml:"ssh_recording_dir,omitempty"`
+
 	HeadersEnv string `yaml:",omitempty"`
 	// SetResponseHeaders to set on all proxied requests. Add a 'disable' key map to turn off.
 	SetResponseHeaders map[string]string `yaml:",omitempty"`
@@ -204,8 +341,15 @@ type Options struct {
 	MetricsCertificateKeyFile string `mapstructure:"metrics_certificate_key_file" yaml:"metrics_certificate_key_file,omitempty"`
 	MetricsClientCA           string `mapstructure:"metrics_client_ca" yaml:"metrics_client_ca,omitempty"`
 	MetricsClientCAFile       string `mapstructure:"metrics_client_ca_file" yaml:"metrics_client_ca_file,omitempty"`
+	// MetricsExemplars adds the trace ids of sampled requests to latency histograms when metrics are
+	// scraped in the OpenMetrics format.
+	MetricsExemplars bool `mapstructure:"metrics_exemplars" yaml:"metrics_exemplars,omitempty"`
+	// MetricsCardinality bounds the cardinality of metric labels.
+	MetricsCardinality MetricsCardinalitySettings `mapstructure:"metrics_cardinality" yaml:"metrics_cardinality,omitempty"`
 
 	// Tracing shared settings
+	//
+	// The otlp tracing provider is configured with the standard OTEL_* environment variables.
 	TracingProvider   string  `mapstructure:"tracing_provider" yaml:"tracing_provider,omitempty"`
 	TracingSampleRate float64 `mapstructure:"tracing_sample_rate" yaml:"tracing_sample_rate,omitempty"`
 
@@ -244,6 +388,17 @@ type Options struct {
 	DataBrokerURLString         string   `mapstructure:"databroker_service_url" yaml:"databroker_service_url,omitempty"`
 	DataBrokerURLStrings        []string `mapstructure:"databroker_service_urls" yaml:"databroker_service_urls,omitempty"`
 	DataBrokerInternalURLString string   `mapstructure:"databroker_internal_service_url" yaml:"databroker_internal_service_url,omitempty"`
+
+	// PeerDiscovery registers the authorize and databroker services of this instance in the
+	// databroker's service registry, and adds the instances registered by other replicas to the
+	// authorize and databroker service urls so they don't have to be listed by hand. Instances
+	// which stop reporting expire from the registry, and envoy health checks the rest. The
+	// configured databroker service url is still needed to reach the registry.
+	PeerDiscovery bool `mapstructure:"peer_discovery" yaml:"peer_discovery,omitempty"`
+	// PeerAdvertiseAddress is the host and port other replicas use to reach the gRPC services of
+	// this instance. Defaults to the hostname and the port of the grpc address.
+	PeerAdvertiseAddress string `mapstructure:"peer_advertise_address" yaml:"peer_advertise_address,omitempty"`
+
 	// DataBrokerStorageType is the storage backend type that databroker will use.
 	// Supported type: memory, redis
 	DataBrokerStorageType string `mapstructure:"databroker_storage_type" yaml:"databroker_storage_type,omitempty"`
@@ -254,6 +409,11 @@ type Options struct {
 	DataBrokerStorageCAFile           string `mapstructure:"databroker_storage_ca_file" yaml:"databroker_storage_ca_file,omitempty"`
 	DataBrokerStorageCertSkipVerify   bool   `mapstructure:"databroker_storage_tls_skip_verify" yaml:"databroker_storage_tls_skip_verify,omitempty"`
 
+	// DataBrokerStorageEncryption configures envelope encryption of databroker records at rest.
+	DataBrokerStorageEncryption DataBrokerStorageEncryptionSettings `mapstructure:"databroker_storage_encryption" yaml:"databroker_storage_encryption,omitempty"`
+	// DataBrokerSharding configures spreading databroker records across multiple storage backends.
+	DataBrokerSharding DataBrokerShardingSettings `mapstructure:"databroker_sharding" yaml:"databroker_sharding,omitempty"`
+
 	// ClientCA is the base64-encoded certificate authority to validate client mTLS certificates against.
 	//
 	// Deprecated: Use DownstreamMTLS.CA instead.
@@ -266,6 +426,30 @@ type Options struct {
 	// DownstreamMTLS holds all downstream mTLS settings.
 	DownstreamMTLS DownstreamMTLSSettings `mapstructure:"downstream_mtls" yaml:"downstream_mtls,omitempty"`
 
+	// TermsOfUse configures a terms of use page users must accept when signing in.
+	TermsOfUse TermsOfUseSettings `mapstructure:"terms_of_use" yaml:"terms_of_use,omitempty"`
+
+	// BotChallenge configures a bot-mitigation challenge before signing in.
+	BotChallenge BotChallengeSettings `mapstructure:"bot_challenge" yaml:"bot_challenge,omitempty"`
+
+	// AuthThrottle throttles failed attempts on the authentication endpoints.
+	AuthThrottle AuthThrottleSettings `mapstructure:"auth_throttle" yaml:"auth_throttle,omitempty"`
+
+	// OIDCProvider configures the built-in OIDC provider for downstream applications.
+	OIDCProvider OIDCProviderSettings `mapstructure:"oidc_provider" yaml:"oidc_provider,omitempty"`
+
+	// OutboundProxy configures the proxy used by Pomerium's own outbound calls.
+	OutboundProxy OutboundProxySettings `mapstructure:"outbound_proxy" yaml:"outbound_proxy,omitempty"`
+
+	// BreakGlass configures emergency access with client certificates kept on hardware tokens.
+	BreakGlass BreakGlassSettings `mapstructure:"break_glass" yaml:"break_glass,omitempty"`
+
+	// WAF holds the web application firewall rules used by routes with a waf policy.
+	WAF WAFSettings `mapstructure:"waf" yaml:"waf,omitempty"`
+
+	// ResponseCache configures the response caches used by routes with a response_cache policy.
+	ResponseCache ResponseCacheSettings `mapstructure:"response_cache" yaml:"response_cache,omitempty"`
+
 	// GoogleCloudServerlessAuthenticationServiceAccount is the service account to use for GCP serverless authentication.
 	// If unset, the GCP metadata server will be used to query for identity tokens.
 	GoogleCloudServerlessAuthenticationServiceAccount string `mapstructure:"google_cloud_serverless_authentication_service_account" yaml:"google_cloud_serverless_authentication_service_account,omitempty"` //nolint
@@ -297,9 +481,71 @@ type Options struct {
 	// CodecType is the codec to use for downstream connections.
 	CodecType CodecType `mapstructure:"codec_type" yaml:"codec_type"`
 
+	// HTTP3 enables serving downstream traffic over HTTP/3 (QUIC) on a UDP listener alongside the main
+	// listener. Responses advertise it with an Alt-Svc header, clients which can't use it keep using
+	// HTTP/2 or HTTP/1.1 over TCP.
+	HTTP3 bool `mapstructure:"http3" yaml:"http3,omitempty"`
+	// HTTP3Addr specifies the host and UDP port to serve HTTP/3 on. If empty, the address of the main
+	// listener is used.
+	HTTP3Addr string `mapstructure:"http3_address" yaml:"http3_address,omitempty"`
+
+	// TLSPostQuantumKeyExchange enables the hybrid X25519+Kyber768 key exchange on downstream
+	// listeners. It is negotiated with TLS 1.3 clients which support it, other clients use X25519
+	// or P-256. It requires an envoy build whose TLS stack supports the hybrid key exchange.
+	TLSPostQuantumKeyExchange bool `mapstructure:"tls_post_quantum_key_exchange" yaml:"tls_post_quantum_key_exchange,omitempty"`
+
+	// FIPSMode restricts cryptography to FIPS-approved algorithms and rejects configuration which
+	// isn't compliant. Changing it requires a restart.
+	FIPSMode bool `mapstructure:"fips_mode" yaml:"fips_mode,omitempty"`
+
+	// ListenerTLS overrides the TLS parameters of each downstream listener.
+	ListenerTLS ListenerTLSSettings `mapstructure:"listener_tls" yaml:"listener_tls,omitempty"`
+
 	AuditKey *PublicKeyEncryptionKeyOptions `mapstructure:"audit_key"`
 
-	BrandingOptions httputil.BrandingOptions
+	// Theme customizes the user facing pages.
+	Theme ThemeSettings `mapstructure:"theme" yaml:"theme,omitempty"`
+
+	// SecurityHeaders configures the security headers of the pages served by Pomerium.
+	SecurityHeaders SecurityHeadersSettings `mapstructure:"security_headers" yaml:"security_headers,omitempty"`
+
+	// AdminConsole configures the admin console served at /.pomerium/admin/.
+	AdminConsole AdminConsoleSettings `mapstructure:"admin_console" yaml:"admin_console,omitempty"`
+
+	// SCIM configures the SCIM 2.0 endpoint served at /.pomerium/scim/v2/.
+	SCIM SCIMSettings `mapstructure:"scim" yaml:"scim,omitempty"`
+
+	// DirectorySync configures syncing users and groups from a directory.
+	DirectorySync DirectorySyncSettings `mapstructure:"directory_sync" yaml:"directory_sync,omitempty"`
+
+	// IdentityAliases assigns stable ids and aliases to identity provider users and groups.
+	IdentityAliases IdentityAliasSettings `mapstructure:"identity_aliases" yaml:"identity_aliases,omitempty"`
+
+	// DataBrokerReplication configures replicating databroker records to other regions.
+	DataBrokerReplication DataBrokerReplicationSettings `mapstructure:"databroker_replication" yaml:"databroker_replication,omitempty"`
+
+	// ChangeDataCapture configures publishing the changes of databroker records.
+	ChangeDataCapture ChangeDataCaptureSettings `mapstructure:"change_data_capture" yaml:"change_data_capture,omitempty"`
+
+	// DataBrokerRetention configures deleting old databroker records.
+	DataBrokerRetention DataBrokerRetentionSettings `mapstructure:"databroker_retention" yaml:"databroker_retention,omitempty"`
+
+	// AnomalyDetection configures detecting anomalous authorization patterns.
+	AnomalyDetection AnomalyDetectionSettings `mapstructure:"anomaly_detection" yaml:"anomaly_detection,omitempty"`
+
+	// AuditLog configures the audit log.
+	AuditLog AuditLogSettings `mapstructure:"audit_log" yaml:"audit_log,omitempty"`
+
+	// AuthorizeReplica configures the in-memory replica of databroker records kept by the authorize
+	// service.
+	AuthorizeReplica AuthorizeReplicaSettings `mapstructure:"authorize_replica" yaml:"authorize_replica,omitempty"`
+
+	// UpstreamMTLS configures the internal certificate authority which issues upstream client
+	// certificates.
+	UpstreamMTLS UpstreamMTLSSettings `mapstructure:"upstream_mtls" yaml:"upstream_mtls,omitempty"`
+
+	// SAML configures the SAML identity provider.
+	SAML SAMLSettings `mapstructure:"saml" yaml:"saml,omitempty"`
 }
 
 type certificateFilePair struct {
@@ -308,6 +554,20 @@ type certificateFilePair struct {
 	KeyFile  string `mapstructure:"key" yaml:"key,omitempty"`
 }
 
+// DefaultDrainTimeout is how long connections are drained if no drain timeout is set.
+const DefaultDrainTimeout = time.Minute
+
+// DefaultAgentSessionTTL is how long desktop agent sessions last if no agent session ttl is set.
+const DefaultAgentSessionTTL = 30 * 24 * time.Hour
+
+// DefaultProgrammaticRefreshTokenTTL is how long programmatic login refresh tokens last if no
+// programmatic refresh token ttl is set.
+const DefaultProgrammaticRefreshTokenTTL = 7 * 24 * time.Hour
+
+// DefaultKubernetesReauthorizeInterval is how often long-lived kubernetes connections are
+// re-authorized if no kubernetes reauthorize interval is set.
+const DefaultKubernetesReauthorizeInterval = time.Minute
+
 // DefaultOptions are the default configuration options for pomerium
 var defaultOptions = Options{
 	Debug:                    false,
@@ -370,6 +630,34 @@ func newOptionsFromConfig(configFile string) (*Options, error) {
 }
 
 func optionsFromViper(configFile string) (*Options, error) {
+	o, err := loadOptions(configFile, func(v *viper.Viper) error {
+		if configFile == "" {
+			return nil
+		}
+		v.SetConfigFile(configFile)
+		return v.ReadInConfig()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, fmt.Errorf("validation error %w", err)
+	}
+	return o, nil
+}
+
+// NewOptionsFromReader builds configuration options from raw config data. The config type is
+// the format of the data, for example "yaml" or "json". Environment variables are applied
+// the same way they are when reading a config file. The options are not validated.
+func NewOptionsFromReader(r io.Reader, configType string) (*Options, error) {
+	return loadOptions("", func(v *viper.Viper) error {
+		v.SetConfigType(configType)
+		return v.ReadConfig(r)
+	})
+}
+
+func loadOptions(configFile string, readConfig func(v *viper.Viper) error) (*Options, error) {
 	// start a copy of the default options
 	o := NewDefaultOptions()
 	v := o.viper
@@ -379,11 +667,8 @@ func optionsFromViper(configFile string) (*Options, error) {
 		return nil, fmt.Errorf("failed to bind options to env vars: %w", err)
 	}
 
-	if configFile != "" {
-		v.SetConfigFile(configFile)
-		if err := v.ReadInConfig(); err != nil {
-			return nil, fmt.Errorf("failed to read config: %w", err)
-		}
+	if err := readConfig(v); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
 	var metadata mapstructure.Metadata
@@ -396,10 +681,6 @@ func optionsFromViper(configFile string) (*Options, error) {
 
 	// This is necessary because v.Unmarshal will overwrite .viper field.
 	o.viper = v
-
-	if err := o.Validate(); err != nil {
-		return nil, fmt.Errorf("validation error %w", err)
-	}
 	return o, nil
 }
 
@@ -446,6 +727,14 @@ func (o *Options) parsePolicy() error {
 		o.Routes = routes
 	}
 
+	var egressPolicies []Policy
+	if err := o.viper.UnmarshalKey("egress_policies", &egressPolicies, ViperPolicyHooks); err != nil {
+		return err
+	}
+	if len(egressPolicies) != 0 {
+		o.EgressPolicies = egressPolicies
+	}
+
 	// Finish initializing policies
 	for i := range o.Policies {
 		p := &o.Policies[i]
@@ -459,8 +748,24 @@ func (o *Options) parsePolicy() error {
 			return err
 		}
 	}
-	for i := range o.AdditionalPolicies {
-		p := &o.AdditionalPolicies[i]
+	for _, p := range o.AdditionalPolicies {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+	for i := range o.Namespaces {
+		for j := range o.Namespaces[i].Routes {
+			p := &o.Namespaces[i].Routes[j]
+			if err := p.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range o.EgressPolicies {
+		p := &o.EgressPolicies[i]
+		if !p.IsEgress() {
+			return fmt.Errorf("config: egress policies must use egress:// source urls: %s", p.From)
+		}
 		if err := p.Validate(); err != nil {
 			return err
 		}
@@ -584,7 +889,7 @@ func (o *Options) Validate() error {
 
 	switch o.DataBrokerStorageType {
 	case StorageInMemoryName:
-	case StorageRedisName, StoragePostgresName:
+	case StorageRedisName, StoragePostgresName, StorageDynamoDBName:
 		if o.DataBrokerStorageConnectionString == "" {
 			return errors.New("config: missing databroker storage backend dsn")
 		}
@@ -649,6 +954,11 @@ func (o *Options) Validate() error {
 	if err := o.parsePolicy(); err != nil {
 		return fmt.Errorf("config: failed to parse policy: %w", err)
 	}
+	for _, p := range o.GetAllPolicies() {
+		if p.IsEgress() {
+			return fmt.Errorf("config: %s must be configured in egress_policies", p.From)
+		}
+	}
 
 	if err := o.parseHeaders(ctx); err != nil {
 		return fmt.Errorf("config: failed to parse headers: %w", err)
@@ -726,6 +1036,212 @@ func (o *Options) Validate() error {
 	if err := ValidateDNSLookupFamily(o.DNSLookupFamily); err != nil {
 		return fmt.Errorf("config: %w", err)
 	}
+	for _, resolver := range o.DNSResolvers {
+		if err := ValidateDNSResolver(resolver); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if o.DNSRefreshRate < 0 || (o.DNSRefreshRate > 0 && o.DNSRefreshRate < time.Millisecond) {
+		return fmt.Errorf("config: dns_refresh_rate must be at least 1ms")
+	}
+
+	if _, err := o.GetSSHUserCAKey(); err != nil {
+		return fmt.Errorf("config: invalid ssh_user_ca_key: %w", err)
+	}
+	if _, err := o.GetSSHHostKey(); err != nil {
+		return fmt.Errorf("config: invalid ssh_host_key: %w", err)
+	}
+	if o.SSHCertificateTTL < 0 {
+		return fmt.Errorf("config: ssh_certificate_ttl must not be negative")
+	}
+	if o.DrainTimeout < 0 {
+		return fmt.Errorf("config: drain_timeout must not be negative")
+	}
+	if o.SessionIdleTimeout < 0 {
+		return fmt.Errorf("config: session_idle_timeout must not be negative")
+	}
+	if o.StatelessSessions && o.SessionIdleTimeout > 0 {
+		return fmt.Errorf("config: session_idle_timeout cannot be used with stateless_sessions")
+	}
+	if o.IdPOutageGracePeriod < 0 {
+		return fmt.Errorf("config: idp_outage_grace_period must not be negative")
+	}
+	if err := ValidateSessionTransport(o.SessionTransport); err != nil {
+		return fmt.Errorf("config: invalid session_transport: %w", err)
+	}
+	if o.ClaimLimits != nil {
+		if err := o.ClaimLimits.Validate(); err != nil {
+			return fmt.Errorf("config: invalid claim_limits: %w", err)
+		}
+	}
+	if o.AgentSessionTTL < 0 {
+		return fmt.Errorf("config: agent_session_ttl must not be negative")
+	}
+	if o.ProgrammaticRefreshTokenTTL < 0 {
+		return fmt.Errorf("config: programmatic_refresh_token_ttl must not be negative")
+	}
+	if o.KubernetesReauthorizeInterval < 0 {
+		return fmt.Errorf("config: kubernetes_reauthorize_interval must not be negative")
+	}
+	if o.ClaimsRefreshInterval < 0 {
+		return fmt.Errorf("config: idp_claims_refresh_interval must not be negative")
+	}
+
+	if o.HTTP3 && o.InsecureServer {
+		return fmt.Errorf("config: http3 requires tls and cannot be used with insecure_server")
+	}
+	if o.TLSPostQuantumKeyExchange && o.InsecureServer {
+		return fmt.Errorf("config: tls_post_quantum_key_exchange requires tls and cannot be used with insecure_server")
+	}
+	if o.HTTP3Addr != "" {
+		if _, _, err := net.SplitHostPort(o.HTTP3Addr); err != nil {
+			return fmt.Errorf("config: invalid http3_address: %w", err)
+		}
+	}
+
+	if err := o.validateNamespaces(); err != nil {
+		return err
+	}
+
+	if o.PeerAdvertiseAddress != "" {
+		if _, _, err := net.SplitHostPort(o.PeerAdvertiseAddress); err != nil {
+			return fmt.Errorf("config: invalid peer_advertise_address: %w", err)
+		}
+	}
+
+	if o.ForwardProxyAddr != "" {
+		if _, _, err := net.SplitHostPort(o.ForwardProxyAddr); err != nil {
+			return fmt.Errorf("config: invalid forward_proxy_address: %w", err)
+		}
+	}
+
+	if o.ExtAuthzAddr != "" {
+		if _, _, err := net.SplitHostPort(o.ExtAuthzAddr); err != nil {
+			return fmt.Errorf("config: invalid ext_authz_address: %w", err)
+		}
+	}
+
+	if _, err := o.GetExtAuthzSigningKey(); err != nil {
+		return fmt.Errorf("config: invalid ext_authz_signing_key: %w", err)
+	}
+
+	if o.MeshIdentity != nil {
+		if err := o.MeshIdentity.Validate(); err != nil {
+			return fmt.Errorf("config: invalid mesh_identity: %w", err)
+		}
+		// the x-forwarded-client-cert header is only trusted from peers authenticated with mTLS
+		if ca, _ := o.DownstreamMTLS.GetCA(); o.MeshIdentity.Source == MeshIdentitySourceXFCC && len(ca) == 0 {
+			return errors.New("config: mesh_identity source xfcc requires a downstream_mtls ca")
+		}
+	}
+
+	cidrSetNames := make(map[string]struct{}, len(o.CIDRSets))
+	for i := range o.CIDRSets {
+		s := &o.CIDRSets[i]
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if _, ok := cidrSetNames[s.Name]; ok {
+			return fmt.Errorf("config: duplicate cidr set name: %s", s.Name)
+		}
+		cidrSetNames[s.Name] = struct{}{}
+	}
+	if o.WAF.IsEmpty() && o.HasAnyWAFPolicy() {
+		return fmt.Errorf("config: routes with a waf require waf.crs_directory or waf.directives")
+	}
+	if err := o.ResponseCache.Validate(); err != nil {
+		return err
+	}
+	if err := o.Theme.Validate(); err != nil {
+		return err
+	}
+	if err := o.SecurityHeaders.Validate(); err != nil {
+		return err
+	}
+	if err := o.DirectorySync.Validate(); err != nil {
+		return err
+	}
+	if err := o.IdentityAliases.Validate(); err != nil {
+		return err
+	}
+	if err := o.BreakGlass.Validate(); err != nil {
+		return err
+	}
+	if err := o.TermsOfUse.Validate(); err != nil {
+		return err
+	}
+	if err := o.BotChallenge.Validate(); err != nil {
+		return err
+	}
+	if err := o.AuthThrottle.Validate(); err != nil {
+		return err
+	}
+	if err := o.OutboundProxy.Validate(); err != nil {
+		return err
+	}
+	if err := o.OIDCProvider.Validate(); err != nil {
+		return err
+	}
+	if o.OIDCProvider.Enabled && o.SigningKey == "" && o.SigningKeyFile == "" {
+		return fmt.Errorf("config: oidc_provider requires a signing_key")
+	}
+	if err := o.DataBrokerReplication.Validate(); err != nil {
+		return err
+	}
+	if err := o.DataBrokerRetention.Validate(); err != nil {
+		return err
+	}
+	if err := o.ChangeDataCapture.Validate(); err != nil {
+		return err
+	}
+	if err := o.DataBrokerStorageEncryption.Validate(); err != nil {
+		return err
+	}
+	if err := o.AuthorizeReplica.Validate(); err != nil {
+		return err
+	}
+	if err := o.UpstreamMTLS.Validate(); err != nil {
+		return err
+	}
+	if err := o.ListenerTLS.Validate(); err != nil {
+		return err
+	}
+	if err := o.AuditLog.Validate(); err != nil {
+		return err
+	}
+	if err := o.AnomalyDetection.Validate(); err != nil {
+		return err
+	}
+	if err := o.DataBrokerSharding.Validate(); err != nil {
+		return err
+	}
+	if o.DataBrokerSharding.IsEnabled() && o.DataBrokerStorageType == StorageInMemoryName {
+		return fmt.Errorf("config: databroker_sharding requires a persistent databroker_storage_type")
+	}
+	if err := o.SAML.Validate(); err != nil {
+		return err
+	}
+	if o.ResponseCache.Directory == "" && o.HasAnyResponseCachePolicy(ResponseCacheBackendDisk) {
+		return fmt.Errorf("config: routes with a disk response_cache require response_cache.directory")
+	}
+
+	for _, p := range o.GetAllPolicies() {
+		for _, name := range p.GetCIDRSetNames() {
+			if _, ok := cidrSetNames[name]; !ok {
+				return fmt.Errorf("config: policy %s references unknown cidr set: %s", p.From, name)
+			}
+		}
+	}
+
+	if o.ConsulAddress != "" {
+		if _, err := urlutil.ParseAndValidateURL(o.ConsulAddress); err != nil {
+			return fmt.Errorf("config: invalid consul_address: %w", err)
+		}
+	}
+
+	if err := o.MetricsCardinality.Validate(); err != nil {
+		return err
+	}
 
 	if o.MetricsAddr != "" {
 		if err := ValidateMetricsAddress(o.MetricsAddr); err != nil {
@@ -791,6 +1307,32 @@ func (o *Options) Validate() error {
 		}
 	}
 
+	if err := o.AccessLogFormat.Validate(); err != nil {
+		return fmt.Errorf("config: invalid access_log_format: %w", err)
+	}
+
+	if o.AccessLogSyslog != nil {
+		if err := o.AccessLogSyslog.Validate(); err != nil {
+			return fmt.Errorf("config: invalid access_log_syslog: %w", err)
+		}
+	}
+
+	if err := o.AuthorizeLogFormat.Validate(); err != nil {
+		return fmt.Errorf("config: invalid authorize_log_format: %w", err)
+	} else if o.AuthorizeLogFormat == log.FormatApacheCombined {
+		return fmt.Errorf("config: invalid authorize_log_format: %s is only supported for access logs", o.AuthorizeLogFormat)
+	}
+
+	if _, err := o.GetLogRedactor(); err != nil {
+		return fmt.Errorf("config: invalid log_redactions: %w", err)
+	}
+
+	if o.FIPSMode {
+		if err := o.validateFIPSMode(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -892,6 +1434,15 @@ func (o *Options) GetGRPCAddr() string {
 	return o.GRPCAddr
 }
 
+// GetDNSRespectTTL returns whether upstream hostnames are resolved again when their records
+// expire.
+func (o *Options) GetDNSRespectTTL() bool {
+	if o.DNSRespectTTL != nil {
+		return *o.DNSRespectTTL
+	}
+	return true
+}
+
 // GetGRPCInsecure gets whether or not gRPC is insecure.
 func (o *Options) GetGRPCInsecure() bool {
 	if o.GRPCInsecure != nil {
@@ -937,6 +1488,10 @@ func (o *Options) GetOauthOptions() (oauth.Options, error) {
 	if err != nil {
 		return oauth.Options{}, err
 	}
+	samlOptions, err := o.GetSAMLOptions(o.Provider)
+	if err != nil {
+		return oauth.Options{}, err
+	}
 	return oauth.Options{
 		RedirectURL:  redirectURL,
 		ProviderName: o.Provider,
@@ -944,6 +1499,7 @@ func (o *Options) GetOauthOptions() (oauth.Options, error) {
 		ClientID:     o.ClientID,
 		ClientSecret: clientSecret,
 		Scopes:       o.Scopes,
+		SAML:         samlOptions,
 	}, nil
 }
 
@@ -952,10 +1508,14 @@ func (o *Options) GetAllPolicies() []Policy {
 	if o == nil {
 		return nil
 	}
-	policies := make([]Policy, 0, len(o.Policies)+len(o.Routes)+len(o.AdditionalPolicies))
+	namespacePolicies := o.getNamespacePolicies()
+	policies := make([]Policy, 0, len(o.Policies)+len(o.Routes)+len(o.AdditionalPolicies)+len(namespacePolicies))
 	policies = append(policies, o.Policies...)
 	policies = append(policies, o.Routes...)
-	policies = append(policies, o.AdditionalPolicies...)
+	for _, p := range o.AdditionalPolicies {
+		policies = append(policies, *p)
+	}
+	policies = append(policies, namespacePolicies...)
 	return policies
 }
 
@@ -978,6 +1538,26 @@ func (o *Options) GetMetricsBasicAuth() (username, password string, ok bool) {
 	return string(bs[:idx]), string(bs[idx+1:]), true
 }
 
+// HasAnyWAFPolicy returns true if any policy uses the web application firewall.
+func (o *Options) HasAnyWAFPolicy() bool {
+	for _, p := range o.GetAllPolicies() {
+		if p.WAF != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyResponseCachePolicy returns true if any policy caches responses using the given backend.
+func (o *Options) HasAnyResponseCachePolicy(backend ResponseCacheBackend) bool {
+	for _, p := range o.GetAllPolicies() {
+		if p.ResponseCache != nil && p.ResponseCache.GetBackend() == backend {
+			return true
+		}
+	}
+	return false
+}
+
 // HasAnyDownstreamMTLSClientCA returns true if there is a global downstream
 // client CA or there are any per-route downstream client CAs.
 func (o *Options) HasAnyDownstreamMTLSClientCA() bool {
@@ -1099,6 +1679,21 @@ func (o *Options) GetSharedKey() ([]byte, error) {
 	return base64.StdEncoding.DecodeString(sharedKey)
 }
 
+// GetExtAuthzSigningKey gets the decoded ext_authz signing key. If unset, nil is returned.
+func (o *Options) GetExtAuthzSigningKey() ([]byte, error) {
+	if o.ExtAuthzSigningKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(o.ExtAuthzSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < 32 {
+		return nil, errors.New("key must be at least 32 bytes")
+	}
+	return key, nil
+}
+
 // GetHPKEPrivateKey gets the hpke.PrivateKey dervived from the shared key.
 func (o *Options) GetHPKEPrivateKey() (*hpke.PrivateKey, error) {
 	sharedKey, err := o.GetSharedKey()
@@ -1148,6 +1743,14 @@ func (o *Options) GetSetResponseHeadersForPolicy(policy *Policy) map[string]stri
 	return hdrs
 }
 
+// GetHTTP3Addr returns the address to serve HTTP/3 on.
+func (o *Options) GetHTTP3Addr() string {
+	if o.HTTP3Addr != "" {
+		return o.HTTP3Addr
+	}
+	return o.Addr
+}
+
 // GetCodecType gets a codec type.
 func (o *Options) GetCodecType() CodecType {
 	if o.CodecType == CodecTypeUnset {
@@ -1290,10 +1893,10 @@ func (o *Options) GetCookieSameSite() http.SameSite {
 
 // GetCSRFSameSite gets the csrf same site option.
 func (o *Options) GetCSRFSameSite() csrf.SameSiteMode {
-	if o.Provider == apple.Name {
+	if o.Provider == apple.Name || o.Provider == saml.Name {
 		// csrf.SameSiteLaxMode will cause browsers to reset
-		// the session on POST. This breaks Appleid being able
-		// to verify the csrf token.
+		// the session on POST. This breaks Appleid and SAML
+		// identity providers being able to verify the csrf token.
 		return csrf.SameSiteNoneMode
 	}
 
@@ -1333,6 +1936,92 @@ func (o *Options) GetSigningKey() ([]byte, error) {
 	return []byte(rawSigningKey), nil
 }
 
+// GetDrainTimeout returns the drain timeout, or the default drain timeout if none is set.
+func (o *Options) GetDrainTimeout() time.Duration {
+	if o == nil || o.DrainTimeout <= 0 {
+		return DefaultDrainTimeout
+	}
+	return o.DrainTimeout
+}
+
+// GetAgentSessionTTL returns the agent session ttl, or the default agent session ttl if none is
+// set.
+func (o *Options) GetAgentSessionTTL() time.Duration {
+	if o == nil || o.AgentSessionTTL <= 0 {
+		return DefaultAgentSessionTTL
+	}
+	return o.AgentSessionTTL
+}
+
+// GetProgrammaticRefreshTokenTTL returns the programmatic refresh token ttl, or the default
+// programmatic refresh token ttl if none is set.
+func (o *Options) GetProgrammaticRefreshTokenTTL() time.Duration {
+	if o == nil || o.ProgrammaticRefreshTokenTTL <= 0 {
+		return DefaultProgrammaticRefreshTokenTTL
+	}
+	return o.ProgrammaticRefreshTokenTTL
+}
+
+// GetKubernetesReauthorizeInterval returns the kubernetes reauthorize interval, or the default
+// kubernetes reauthorize interval if none is set.
+func (o *Options) GetKubernetesReauthorizeInterval() time.Duration {
+	if o == nil || o.KubernetesReauthorizeInterval <= 0 {
+		return DefaultKubernetesReauthorizeInterval
+	}
+	return o.KubernetesReauthorizeInterval
+}
+
+// GetSSHUserCAKey gets the ssh user certificate authority key. If none is set, nil is returned.
+func (o *Options) GetSSHUserCAKey() (ssh.Signer, error) {
+	if o == nil {
+		return nil, nil
+	}
+	return getSSHPrivateKey(o.SSHUserCAKey, o.SSHUserCAKeyFile)
+}
+
+// GetSSHHostKey gets the ssh host key. If none is set, a key is derived from the shared secret.
+func (o *Options) GetSSHHostKey() (ssh.Signer, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	signer, err := getSSHPrivateKey(o.SSHHostKey, o.SSHHostKeyFile)
+	if err != nil || signer != nil {
+		return signer, err
+	}
+
+	sharedKey, err := o.GetSharedKey()
+	if err != nil {
+		return nil, err
+	}
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedKey, nil, []byte("ssh host key")), seed); err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(seed))
+}
+
+func getSSHPrivateKey(rawKey, keyFile string) (ssh.Signer, error) {
+	if keyFile != "" {
+		bs, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		rawKey = string(bs)
+	}
+
+	rawKey = strings.TrimSpace(rawKey)
+	if rawKey == "" {
+		return nil, nil
+	}
+
+	if bs, err := base64.StdEncoding.DecodeString(rawKey); err == nil {
+		rawKey = string(bs)
+	}
+
+	return ssh.ParsePrivateKey([]byte(rawKey))
+}
+
 // GetAccessLogFields returns the access log fields. If none are set, the default fields are returned.
 func (o *Options) GetAccessLogFields() []log.AccessLogField {
 	if o.AccessLogFields == nil {
@@ -1470,7 +2159,13 @@ func (o *Options) ApplySettings(ctx context.Context, certsIndex *cryptutil.Certi
 	setSlice(&o.ProgrammaticRedirectDomainWhitelist, settings.ProgrammaticRedirectDomainWhitelist)
 	setAuditKey(&o.AuditKey, settings.AuditKey)
 	setCodecType(&o.CodecType, settings.CodecType)
-	o.BrandingOptions = settings
+	set(&o.Theme.PrimaryColor, settings.PrimaryColor)
+	set(&o.Theme.SecondaryColor, settings.SecondaryColor)
+	set(&o.Theme.DarkmodePrimaryColor, settings.DarkmodePrimaryColor)
+	set(&o.Theme.DarkmodeSecondaryColor, settings.DarkmodeSecondaryColor)
+	set(&o.Theme.LogoURL, settings.LogoUrl)
+	set(&o.Theme.FaviconURL, settings.FaviconUrl)
+	set(&o.Theme.ErrorMessageFirstParagraph, settings.ErrorMessageFirstParagraph)
 }
 
 func dataDir() string {
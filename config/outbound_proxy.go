@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// OutboundProxySettings configure the proxy used by Pomerium's own outbound calls, like OIDC
+// discovery, JWKS, userinfo and directory sync requests. Traffic to upstreams isn't affected.
+//
+// If no proxy is set, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+type OutboundProxySettings struct {
+	// HTTPProxy is the URL of the proxy used for http requests.
+	HTTPProxy string `mapstructure:"http_proxy" yaml:"http_proxy,omitempty"`
+	// HTTPSProxy is the URL of the proxy used for https requests.
+	HTTPSProxy string `mapstructure:"https_proxy" yaml:"https_proxy,omitempty"`
+	// NoProxy are the hosts, domains (like .example.com), IPs and CIDRs which are connected to
+	// directly.
+	NoProxy []string `mapstructure:"no_proxy" yaml:"no_proxy,omitempty"`
+}
+
+// IsSet returns true if a proxy is configured.
+func (s *OutboundProxySettings) IsSet() bool {
+	return s.HTTPProxy != "" || s.HTTPSProxy != ""
+}
+
+// GetProxyFunc returns the function used by http transports to pick the proxy for a request.
+func (s *OutboundProxySettings) GetProxyFunc() func(*http.Request) (*url.URL, error) {
+	if !s.IsSet() {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  s.HTTPProxy,
+		HTTPSProxy: s.HTTPSProxy,
+		NoProxy:    strings.Join(s.NoProxy, ","),
+	}).ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return proxyFunc(r.URL)
+	}
+}
+
+// Validate validates the outbound proxy settings.
+func (s *OutboundProxySettings) Validate() error {
+	if err := validateOutboundProxyURL(s.HTTPProxy); err != nil {
+		return fmt.Errorf("config: invalid outbound_proxy http_proxy: %w", err)
+	}
+	if err := validateOutboundProxyURL(s.HTTPSProxy); err != nil {
+		return fmt.Errorf("config: invalid outbound_proxy https_proxy: %w", err)
+	}
+	for _, host := range s.NoProxy {
+		if _, _, err := net.ParseCIDR(host); err == nil {
+			continue
+		}
+		if host == "" || strings.ContainsAny(host, " ,/\r\n") {
+			return fmt.Errorf("config: invalid outbound_proxy no_proxy entry: %q", host)
+		}
+	}
+	return nil
+}
+
+func validateOutboundProxyURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported scheme: %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	return nil
+}
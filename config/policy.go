@@ -12,6 +12,7 @@ import (
 	"time"
 
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	"golang.org/x/crypto/ssh"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 
@@ -28,10 +29,35 @@ type Policy struct {
 	From string       `mapstructure:"from" yaml:"from"`
 	To   WeightedURLs `mapstructure:"to" yaml:"to"`
 
+	// ResolvedTo holds the endpoints discovered for service discovery upstreams in To. It is set
+	// by the discovery config source and is used in place of To when building the upstream cluster.
+	// A non-nil empty ResolvedTo means no endpoints have been discovered yet.
+	ResolvedTo WeightedURLs `mapstructure:"-" yaml:"-" json:"-"`
+
 	// LbWeights are optional load balancing weights applied to endpoints specified in To
 	// this field exists for compatibility with mapstructure
 	LbWeights []uint32 `mapstructure:"_to_weights,omitempty" json:"-" yaml:"-"`
 
+	// StickySession pins clients to a single upstream endpoint when a route has multiple
+	// weighted `to` targets.
+	StickySession *StickySession `mapstructure:"sticky_session" yaml:"sticky_session,omitempty" json:"sticky_session,omitempty"`
+
+	// MirrorTo is an upstream URL which receives a copy of requests sent to the route. Responses
+	// from the mirror are discarded. Mirrored requests include the headers added by pomerium,
+	// such as the identity headers, and have "-shadow" appended to the Host header.
+	MirrorTo string `mapstructure:"mirror_to" yaml:"mirror_to,omitempty" json:"mirror_to,omitempty"`
+	// MirrorPercent is the percentage of requests to mirror. Defaults to 100.
+	MirrorPercent *float64 `mapstructure:"mirror_percent" yaml:"mirror_percent,omitempty" json:"mirror_percent,omitempty"`
+
+	// HealthCheck configures active health checking of the upstream endpoints. Unhealthy
+	// endpoints are removed from load balancing until they pass health checks again.
+	HealthCheck *PolicyHealthCheck `mapstructure:"health_check" yaml:"health_check,omitempty" json:"health_check,omitempty"`
+	// OutlierDetection configures passive health checking of the upstream endpoints. Endpoints
+	// which return consecutive errors are temporarily ejected from load balancing.
+	OutlierDetection *PolicyOutlierDetection `mapstructure:"outlier_detection" yaml:"outlier_detection,omitempty" json:"outlier_detection,omitempty"`
+	// ConnectionPool tunes the connection pool to the upstream endpoints.
+	ConnectionPool *PolicyConnectionPool `mapstructure:"connection_pool" yaml:"connection_pool,omitempty" json:"connection_pool,omitempty"`
+
 	// Redirect is used for a redirect action instead of `To`
 	Redirect *PolicyRedirect `mapstructure:"redirect" yaml:"redirect"`
 
@@ -40,6 +66,14 @@ type Policy struct {
 	AllowedDomains   []string                 `mapstructure:"allowed_domains" yaml:"allowed_domains,omitempty" json:"allowed_domains,omitempty"`
 	AllowedIDPClaims identity.FlattenedClaims `mapstructure:"allowed_idp_claims" yaml:"allowed_idp_claims,omitempty" json:"allowed_idp_claims,omitempty"`
 
+	// IPAllowList and IPDenyList restrict access by client IP address before any other policy is evaluated.
+	// Entries are IP ranges in CIDR notation, single IP addresses, or the names of cidr_sets.
+	IPAllowList []string `mapstructure:"ip_allowlist" yaml:"ip_allowlist,omitempty" json:"ip_allowlist,omitempty"`
+	IPDenyList  []string `mapstructure:"ip_denylist" yaml:"ip_denylist,omitempty" json:"ip_denylist,omitempty"`
+
+	// WAF inspects requests with the web application firewall before the policy is evaluated.
+	WAF *PolicyWAF `mapstructure:"waf" yaml:"waf,omitempty" json:"waf,omitempty"`
+
 	// Additional route matching options
 	Prefix        string `mapstructure:"prefix" yaml:"prefix,omitempty" json:"prefix,omitempty"`
 	Path          string `mapstructure:"path" yaml:"path,omitempty" json:"path,omitempty"`
@@ -67,6 +101,9 @@ type Policy struct {
 	// Allow any authenticated user
 	AllowAnyAuthenticatedUser bool `mapstructure:"allow_any_authenticated_user" yaml:"allow_any_authenticated_user,omitempty"`
 
+	// AllowBreakGlass allows requests with a break-glass credential, bypassing the policy.
+	AllowBreakGlass bool `mapstructure:"allow_break_glass" yaml:"allow_break_glass,omitempty" json:"allow_break_glass,omitempty"`
+
 	// UpstreamTimeout is the route specific timeout. Must be less than the global
 	// timeout. If unset, route will fallback to the proxy's DefaultUpstreamTimeout.
 	UpstreamTimeout *time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
@@ -76,10 +113,32 @@ type Policy struct {
 	// see https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/route/v3/route_components.proto#envoy-v3-api-field-config-route-v3-routeaction-idle-timeout
 	IdleTimeout *time.Duration `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty"`
 
+	// MaxStreamDuration is the maximum duration of a request stream, including the time taken to
+	// send the request body. Unlike the UpstreamTimeout it is not reset by activity on the stream.
+	MaxStreamDuration *time.Duration `mapstructure:"max_stream_duration" yaml:"max_stream_duration,omitempty"`
+
+	// Request limits are enforced before authorization. Requests exceeding them are rejected with a
+	// 413, 414 or 431. A value of 0 means no limit. The body size is taken from the request's
+	// Content-Length, so requests without one are not limited by MaxRequestBytes.
+	MaxRequestBytes        int64 `mapstructure:"max_request_bytes" yaml:"max_request_bytes,omitempty" json:"max_request_bytes,omitempty"`
+	MaxRequestHeaders      int   `mapstructure:"max_request_headers" yaml:"max_request_headers,omitempty" json:"max_request_headers,omitempty"`
+	MaxRequestHeadersBytes int   `mapstructure:"max_request_headers_bytes" yaml:"max_request_headers_bytes,omitempty" json:"max_request_headers_bytes,omitempty"`
+	MaxURLLength           int   `mapstructure:"max_url_length" yaml:"max_url_length,omitempty" json:"max_url_length,omitempty"`
+
+	// RetryPolicy configures how failed upstream requests are retried.
+	RetryPolicy *PolicyRetryPolicy `mapstructure:"retry_policy" yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+
 	// Enable proxying of websocket connections by removing the default timeout handler.
 	// Caution: Enabling this feature could result in abuse via DOS attacks.
 	AllowWebsockets bool `mapstructure:"allow_websockets"  yaml:"allow_websockets,omitempty"`
 
+	// WebsocketIdleTimeout overrides the IdleTimeout for websocket connections. Websocket connections with
+	// no data sent in either direction for this long are closed.
+	WebsocketIdleTimeout *time.Duration `mapstructure:"websocket_idle_timeout" yaml:"websocket_idle_timeout,omitempty"`
+
+	// WebsocketMaxDuration is the maximum lifetime of a websocket connection, regardless of activity.
+	WebsocketMaxDuration *time.Duration `mapstructure:"websocket_max_duration" yaml:"websocket_max_duration,omitempty"`
+
 	// AllowSPDY enables proxying of SPDY upgrade requests
 	AllowSPDY bool `mapstructure:"allow_spdy" yaml:"allow_spdy,omitempty"`
 
@@ -111,6 +170,11 @@ type Policy struct {
 	TLSClientKeyFile  string           `mapstructure:"tls_client_key_file" yaml:"tls_client_key_file,omitempty"`
 	ClientCertificate *tls.Certificate `yaml:",omitempty" hash:"ignore"`
 
+	// TLSIssueClientCert presents a short-lived client certificate issued by the upstream_mtls
+	// certificate authority to the upstream host. The certificate identifies the route and is
+	// rotated automatically.
+	TLSIssueClientCert bool `mapstructure:"tls_issue_client_cert" yaml:"tls_issue_client_cert,omitempty"`
+
 	// TLSDownstreamClientCA defines the root certificate to use with a given route to verify
 	// downstream client certificates (e.g. from a user's browser).
 	TLSDownstreamClientCA     string `mapstructure:"tls_downstream_client_ca" yaml:"tls_downstream_client_ca,omitempty"`
@@ -149,6 +213,16 @@ type Policy struct {
 	KubernetesServiceAccountToken string `mapstructure:"kubernetes_service_account_token" yaml:"kubernetes_service_account_token,omitempty"`
 	// KubernetesServiceAccountTokenFile contains the kubernetes token to use for upstream requests.
 	KubernetesServiceAccountTokenFile string `mapstructure:"kubernetes_service_account_token_file" yaml:"kubernetes_service_account_token_file,omitempty"`
+	// KubernetesGroupMappings maps the groups of users, by id or name, to the kubernetes groups they're
+	// impersonated as. If set, groups without a mapping aren't passed to kubernetes.
+	KubernetesGroupMappings map[string][]string `mapstructure:"kubernetes_group_mappings" yaml:"kubernetes_group_mappings,omitempty" json:"kubernetes_group_mappings,omitempty"`
+
+	// SSHPrincipals are the usernames a user may log in as on an ssh route. If empty, users may only log in
+	// as the local part of their email address.
+	SSHPrincipals []string `mapstructure:"ssh_principals" yaml:"ssh_principals,omitempty" json:"ssh_principals,omitempty"`
+	// SSHUpstreamHostKeys are the public keys, in authorized_keys format, accepted from the upstream ssh server.
+	// If empty, the upstream host key is not verified.
+	SSHUpstreamHostKeys []string `mapstructure:"ssh_upstream_host_keys" yaml:"ssh_upstream_host_keys,omitempty" json:"ssh_upstream_host_keys,omitempty"`
 
 	// EnableGoogleCloudServerlessAuthentication adds "Authorization: Bearer ID_TOKEN" headers
 	// to upstream requests.
@@ -161,17 +235,56 @@ type Policy struct {
 	// RewriteResponseHeaders rewrites response headers. This can be used to change the Location header.
 	RewriteResponseHeaders []RewriteHeader `mapstructure:"rewrite_response_headers" yaml:"rewrite_response_headers,omitempty" json:"rewrite_response_headers,omitempty"` //nolint
 
+	// RewriteResponseBody rewrites strings, such as absolute upstream URLs, in response bodies.
+	RewriteResponseBody *RewriteResponseBody `mapstructure:"rewrite_response_body" yaml:"rewrite_response_body,omitempty" json:"rewrite_response_body,omitempty"`
+
 	// SetResponseHeaders sets response headers.
 	SetResponseHeaders map[string]string `mapstructure:"set_response_headers" yaml:"set_response_headers,omitempty"`
 
+	// CORS is the cross-origin resource sharing policy of the route.
+	CORS *PolicyCORS `mapstructure:"cors" yaml:"cors,omitempty" json:"cors,omitempty"`
+
+	// CSRF protects browser sessions from cross-site request forgery.
+	CSRF *PolicyCSRF `mapstructure:"csrf" yaml:"csrf,omitempty" json:"csrf,omitempty"`
+
+	// SessionCookie overrides the session cookie settings so the route's sessions aren't shared
+	// with other routes.
+	SessionCookie *PolicySessionCookie `mapstructure:"session_cookie" yaml:"session_cookie,omitempty" json:"session_cookie,omitempty"`
+
+	// Compression compresses upstream responses which aren't already compressed.
+	Compression *PolicyCompression `mapstructure:"compression" yaml:"compression,omitempty" json:"compression,omitempty"`
+
+	// ResponseCache caches cacheable upstream responses, such as static assets.
+	ResponseCache *PolicyResponseCache `mapstructure:"response_cache" yaml:"response_cache,omitempty" json:"response_cache,omitempty"`
+
+	// ExternalProcessor sends authorized requests and their responses to an external processing
+	// service, which can transform them.
+	ExternalProcessor *PolicyExternalProcessor `mapstructure:"external_processor" yaml:"external_processor,omitempty" json:"external_processor,omitempty"`
+
+	// Experiments assign the route's users to experiment variants, which are sent upstream in the
+	// X-Pomerium-Experiment header.
+	Experiments []PolicyExperiment `mapstructure:"experiments" yaml:"experiments,omitempty" json:"experiments,omitempty"`
+
+	// SLO sets the service level objectives of the route.
+	SLO *PolicySLO `mapstructure:"slo" yaml:"slo,omitempty" json:"slo,omitempty"`
+
 	// IDPClientID is the client id used for the identity provider.
 	IDPClientID string `mapstructure:"idp_client_id" yaml:"idp_client_id,omitempty"`
 	// IDPClientSecret is the client secret used for the identity provider.
 	IDPClientSecret string `mapstructure:"idp_client_secret" yaml:"idp_client_secret,omitempty"`
 
+	// Namespace is the name of the namespace the route belongs to, if any. It's set for the routes
+	// of a namespace and can't be configured on a route directly.
+	Namespace string `mapstructure:"-" yaml:"-" json:"-"`
+
 	// ShowErrorDetails indicates whether or not additional error details should be displayed.
 	ShowErrorDetails bool `mapstructure:"show_error_details" yaml:"show_error_details" json:"show_error_details"`
 
+	// JSONErrors returns errors as JSON instead of HTML pages, and unauthenticated requests get a 401
+	// response with the url to sign in at instead of a redirect, for single-page apps and API clients.
+	// Requests which prefer JSON in their Accept header get these responses on every route.
+	JSONErrors bool `mapstructure:"json_errors" yaml:"json_errors,omitempty" json:"json_errors,omitempty"`
+
 	Policy *PPLPolicy `mapstructure:"policy" yaml:"policy,omitempty" json:"policy,omitempty"`
 }
 
@@ -182,6 +295,65 @@ type RewriteHeader struct {
 	Value  string `mapstructure:"value" yaml:"value,omitempty" json:"value,omitempty"`
 }
 
+// Defaults for response body rewriting.
+var (
+	DefaultRewriteResponseBodyContentTypes = []string{"text/html", "text/css", "application/javascript", "application/json"}
+	DefaultRewriteResponseBodyMaxSize      = 1 << 20
+)
+
+// RewriteResponseBody is a policy configuration option to rewrite response bodies.
+//
+// Only uncompressed responses with a matching content type are rewritten. Responses with
+// a content length up to the maximum size are buffered and rewritten as a whole. Larger
+// responses and responses without a content length are streamed and each chunk is
+// rewritten separately, so an occurrence which spans two chunks is not replaced.
+type RewriteResponseBody struct {
+	// ContentTypes are the media types of responses to rewrite.
+	ContentTypes []string `mapstructure:"content_types" yaml:"content_types,omitempty" json:"content_types,omitempty"`
+	// MaxSize is the maximum size of a response body to buffer, in bytes.
+	MaxSize int `mapstructure:"max_size" yaml:"max_size,omitempty" json:"max_size,omitempty"`
+	// Rules are the substitutions to perform, in order.
+	Rules []RewriteResponseBodyRule `mapstructure:"rules" yaml:"rules" json:"rules"`
+}
+
+// A RewriteResponseBodyRule replaces every occurrence of From with To.
+type RewriteResponseBodyRule struct {
+	From string `mapstructure:"from" yaml:"from" json:"from"`
+	To   string `mapstructure:"to" yaml:"to" json:"to"`
+}
+
+// Validate checks the validity of the response body rewrite options.
+func (r *RewriteResponseBody) Validate() error {
+	if len(r.Rules) == 0 {
+		return fmt.Errorf("at least one rule is required")
+	}
+	for _, rule := range r.Rules {
+		if rule.From == "" {
+			return fmt.Errorf("rule from must not be empty")
+		}
+	}
+	if r.MaxSize < 0 {
+		return fmt.Errorf("max_size must not be negative")
+	}
+	return nil
+}
+
+// GetContentTypes returns the content types to rewrite.
+func (r *RewriteResponseBody) GetContentTypes() []string {
+	if len(r.ContentTypes) == 0 {
+		return DefaultRewriteResponseBodyContentTypes
+	}
+	return r.ContentTypes
+}
+
+// GetMaxSize returns the maximum size of a response body to buffer.
+func (r *RewriteResponseBody) GetMaxSize() int {
+	if r.MaxSize == 0 {
+		return DefaultRewriteResponseBodyMaxSize
+	}
+	return r.MaxSize
+}
+
 // A SubPolicy is a protobuf Policy within a protobuf Route.
 type SubPolicy struct {
 	ID               string                   `mapstructure:"id" yaml:"id" json:"id"`
@@ -197,6 +369,197 @@ type SubPolicy struct {
 	Remediation string `mapstructure:"remediation" yaml:"remediation" json:"remediation,omitempty"`
 }
 
+// StickySession configures session affinity for a route.
+//
+// By default clients are pinned using their Pomerium session. If a CookieName is set, a cookie
+// with the given TTL is issued instead, which also pins unauthenticated clients.
+type StickySession struct {
+	CookieName string        `mapstructure:"cookie_name" yaml:"cookie_name,omitempty" json:"cookie_name,omitempty"`
+	CookiePath string        `mapstructure:"cookie_path" yaml:"cookie_path,omitempty" json:"cookie_path,omitempty"`
+	CookieTTL  time.Duration `mapstructure:"cookie_ttl" yaml:"cookie_ttl,omitempty" json:"cookie_ttl,omitempty"`
+}
+
+// PolicyHealthCheck is a simplified active health check for a route's upstream endpoints.
+// If Path is empty a TCP connect check is used, otherwise an HTTP GET request is sent to Path.
+//
+// For full control, use the envoy `health_checks` cluster option instead.
+type PolicyHealthCheck struct {
+	Path               string        `mapstructure:"path" yaml:"path,omitempty" json:"path,omitempty"`
+	Host               string        `mapstructure:"host" yaml:"host,omitempty" json:"host,omitempty"`
+	Interval           time.Duration `mapstructure:"interval" yaml:"interval,omitempty" json:"interval,omitempty"`
+	Timeout            time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	HealthyThreshold   uint32        `mapstructure:"healthy_threshold" yaml:"healthy_threshold,omitempty" json:"healthy_threshold,omitempty"`
+	UnhealthyThreshold uint32        `mapstructure:"unhealthy_threshold" yaml:"unhealthy_threshold,omitempty" json:"unhealthy_threshold,omitempty"`
+	// ExpectedStatuses are the HTTP status codes considered healthy. Defaults to 200.
+	ExpectedStatuses []int `mapstructure:"expected_statuses" yaml:"expected_statuses,omitempty" json:"expected_statuses,omitempty"`
+}
+
+// Validate checks the validity of the health check settings.
+func (hc *PolicyHealthCheck) Validate() error {
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if hc.Interval < 0 || hc.Timeout < 0 {
+		return fmt.Errorf("interval and timeout must not be negative")
+	}
+	if hc.Interval > 0 && hc.Timeout > hc.Interval {
+		return fmt.Errorf("timeout must not be greater than the interval")
+	}
+	for _, status := range hc.ExpectedStatuses {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("invalid expected status: %d", status)
+		}
+	}
+	return nil
+}
+
+// PolicyOutlierDetection is a simplified outlier detection for a route's upstream endpoints.
+// Unset fields use the envoy defaults.
+//
+// For full control, use the envoy `outlier_detection` cluster option instead.
+type PolicyOutlierDetection struct {
+	// Consecutive5xx is the number of consecutive 5xx responses after which an endpoint is
+	// ejected. Defaults to 5.
+	Consecutive5xx uint32 `mapstructure:"consecutive_5xx" yaml:"consecutive_5xx,omitempty" json:"consecutive_5xx,omitempty"`
+	// ConsecutiveGatewayFailure is the number of consecutive 502, 503 or 504 responses after
+	// which an endpoint is ejected. Disabled if not set.
+	ConsecutiveGatewayFailure uint32 `mapstructure:"consecutive_gateway_failure" yaml:"consecutive_gateway_failure,omitempty" json:"consecutive_gateway_failure,omitempty"`
+	// Interval is the time between ejection analysis sweeps. Defaults to 10s.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval,omitempty" json:"interval,omitempty"`
+	// BaseEjectionTime is how long an endpoint is ejected for, multiplied by the number of
+	// times it has been ejected. Defaults to 30s.
+	BaseEjectionTime time.Duration `mapstructure:"base_ejection_time" yaml:"base_ejection_time,omitempty" json:"base_ejection_time,omitempty"`
+	// MaxEjectionPercent is the maximum percentage of endpoints which can be ejected at once.
+	// Defaults to 10.
+	MaxEjectionPercent uint32 `mapstructure:"max_ejection_percent" yaml:"max_ejection_percent,omitempty" json:"max_ejection_percent,omitempty"`
+}
+
+// Validate checks the validity of the outlier detection settings.
+func (od *PolicyOutlierDetection) Validate() error {
+	if od.Interval < 0 || od.BaseEjectionTime < 0 {
+		return fmt.Errorf("interval and base_ejection_time must not be negative")
+	}
+	if od.MaxEjectionPercent > 100 {
+		return fmt.Errorf("max_ejection_percent must not be greater than 100")
+	}
+	return nil
+}
+
+// Upstream protocols of a connection pool.
+const (
+	UpstreamProtocolAuto  = "auto"
+	UpstreamProtocolHTTP1 = "http1"
+	UpstreamProtocolHTTP2 = "http2"
+)
+
+// PolicyConnectionPool tunes the connection pool to a route's upstream endpoints. Unset fields use
+// the envoy defaults.
+//
+// For full control, use the envoy `circuit_breakers` and `upstream_connection_options` cluster
+// options instead.
+type PolicyConnectionPool struct {
+	// MaxConnections is the maximum number of connections to the upstream endpoints. Defaults to
+	// 1024.
+	MaxConnections uint32 `mapstructure:"max_connections" yaml:"max_connections,omitempty" json:"max_connections,omitempty"`
+	// MaxRequestsPerConnection is the maximum number of requests sent over a connection before it's
+	// closed. Unlimited if not set.
+	MaxRequestsPerConnection uint32 `mapstructure:"max_requests_per_connection" yaml:"max_requests_per_connection,omitempty" json:"max_requests_per_connection,omitempty"`
+	// Protocol is the protocol used to connect to the upstream endpoints: auto, http1 or http2.
+	// With auto, HTTP/2 is negotiated with TLS endpoints. Routes allowing websockets always use
+	// HTTP/1.1.
+	Protocol string `mapstructure:"protocol" yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	// TCPKeepalive enables TCP keepalive on the upstream connections.
+	TCPKeepalive *PolicyTCPKeepalive `mapstructure:"tcp_keepalive" yaml:"tcp_keepalive,omitempty" json:"tcp_keepalive,omitempty"`
+}
+
+// PolicyTCPKeepalive configures TCP keepalive. Unset fields use the operating system defaults.
+type PolicyTCPKeepalive struct {
+	// Probes is the number of unanswered probes after which the connection is dropped.
+	Probes uint32 `mapstructure:"probes" yaml:"probes,omitempty" json:"probes,omitempty"`
+	// Time is how long a connection is idle before probes are sent.
+	Time time.Duration `mapstructure:"time" yaml:"time,omitempty" json:"time,omitempty"`
+	// Interval is the time between probes.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval,omitempty" json:"interval,omitempty"`
+}
+
+// Validate checks the validity of the connection pool settings.
+func (cp *PolicyConnectionPool) Validate() error {
+	switch cp.Protocol {
+	case "", UpstreamProtocolAuto, UpstreamProtocolHTTP1, UpstreamProtocolHTTP2:
+	default:
+		return fmt.Errorf("unknown protocol: %q", cp.Protocol)
+	}
+	if ka := cp.TCPKeepalive; ka != nil {
+		if ka.Time < 0 || ka.Interval < 0 {
+			return fmt.Errorf("tcp_keepalive time and interval must not be negative")
+		}
+		if ka.Time%time.Second != 0 || ka.Interval%time.Second != 0 {
+			return fmt.Errorf("tcp_keepalive time and interval must be whole seconds")
+		}
+	}
+	return nil
+}
+
+// retryOnConditions are the envoy retry conditions which may be used in a retry policy.
+// see https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_filters/router_filter#x-envoy-retry-on
+var retryOnConditions = map[string]struct{}{
+	"5xx":                        {},
+	"gateway-error":              {},
+	"reset":                      {},
+	"connect-failure":            {},
+	"envoy-ratelimited":          {},
+	"retriable-4xx":              {},
+	"refused-stream":             {},
+	"retriable-status-codes":     {},
+	"retriable-headers":          {},
+	"http3-post-connect-failure": {},
+	"cancelled":                  {},
+	"deadline-exceeded":          {},
+	"internal":                   {},
+	"resource-exhausted":         {},
+	"unavailable":                {},
+}
+
+// PolicyRetryPolicy configures retries of failed upstream requests.
+type PolicyRetryPolicy struct {
+	// RetryOn are the conditions under which a request is retried. If unset, requests are
+	// retried on connection failures and resets.
+	RetryOn []string `mapstructure:"retry_on" yaml:"retry_on,omitempty" json:"retry_on,omitempty"`
+	// RetriableStatusCodes are HTTP status codes which trigger a retry.
+	RetriableStatusCodes []uint32 `mapstructure:"retriable_status_codes" yaml:"retriable_status_codes,omitempty" json:"retriable_status_codes,omitempty"`
+	// NumRetries is the maximum number of retries. Defaults to 1.
+	NumRetries uint32 `mapstructure:"num_retries" yaml:"num_retries,omitempty" json:"num_retries,omitempty"`
+	// PerTryTimeout is the timeout for each attempt, including the first.
+	PerTryTimeout time.Duration `mapstructure:"per_try_timeout" yaml:"per_try_timeout,omitempty" json:"per_try_timeout,omitempty"`
+	// BackoffBaseInterval and BackoffMaxInterval control the exponential backoff between retries.
+	BackoffBaseInterval time.Duration `mapstructure:"backoff_base_interval" yaml:"backoff_base_interval,omitempty" json:"backoff_base_interval,omitempty"`
+	BackoffMaxInterval  time.Duration `mapstructure:"backoff_max_interval" yaml:"backoff_max_interval,omitempty" json:"backoff_max_interval,omitempty"`
+}
+
+// Validate checks the validity of the retry policy.
+func (rp *PolicyRetryPolicy) Validate() error {
+	for _, condition := range rp.RetryOn {
+		if _, ok := retryOnConditions[condition]; !ok {
+			return fmt.Errorf("unknown retry_on condition: %s", condition)
+		}
+	}
+	for _, code := range rp.RetriableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid retriable status code: %d", code)
+		}
+	}
+	if rp.PerTryTimeout < 0 || rp.BackoffBaseInterval < 0 || rp.BackoffMaxInterval < 0 {
+		return fmt.Errorf("timeouts and intervals must not be negative")
+	}
+	if rp.BackoffMaxInterval > 0 && rp.BackoffBaseInterval == 0 {
+		return fmt.Errorf("backoff_max_interval requires backoff_base_interval")
+	}
+	if rp.BackoffMaxInterval > 0 && rp.BackoffMaxInterval < rp.BackoffBaseInterval {
+		return fmt.Errorf("backoff_max_interval must not be less than backoff_base_interval")
+	}
+	return nil
+}
+
 // PolicyRedirect is a route redirect action.
 type PolicyRedirect struct {
 	HTTPSRedirect  *bool   `mapstructure:"https_redirect" yaml:"https_redirect,omitempty" json:"https_redirect,omitempty"`
@@ -441,7 +804,11 @@ func (p *Policy) Validate() error {
 			source.String())
 	}
 
-	if len(p.To) == 0 && p.Redirect == nil {
+	if p.IsEgress() {
+		if len(p.To) > 0 || p.Redirect != nil {
+			return fmt.Errorf("config: egress policies cannot have a to or redirect")
+		}
+	} else if len(p.To) == 0 && p.Redirect == nil {
 		return errEitherToOrRedirectRequired
 	}
 
@@ -451,6 +818,30 @@ func (p *Policy) Validate() error {
 		}
 	}
 
+	if p.IsUDP() {
+		for _, u := range p.To {
+			if u.URL.Scheme != "udp" || u.URL.Port() == "" {
+				return fmt.Errorf("config: udp routes must have udp://host:port destinations: %s", u.URL.String())
+			}
+		}
+	}
+
+	if p.IsSSH() {
+		if p.AllowPublicUnauthenticatedAccess {
+			return fmt.Errorf("config: ssh routes cannot allow public unauthenticated access")
+		}
+		for _, u := range p.To {
+			if u.URL.Scheme != "ssh" {
+				return fmt.Errorf("config: ssh routes must have ssh:// destinations: %s", u.URL.String())
+			}
+		}
+		for _, k := range p.SSHUpstreamHostKeys {
+			if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k)); err != nil {
+				return fmt.Errorf("config: invalid ssh_upstream_host_keys: %w", err)
+			}
+		}
+	}
+
 	// Only allow public access if no other whitelists are in place
 	if p.AllowPublicUnauthenticatedAccess && (p.AllowAnyAuthenticatedUser || p.AllowedDomains != nil || p.AllowedUsers != nil) {
 		return fmt.Errorf("config: policy route marked as public but contains whitelists")
@@ -466,6 +857,10 @@ func (p *Policy) Validate() error {
 		return fmt.Errorf("config: client certificate key and cert both must be non-empty")
 	}
 
+	if p.TLSIssueClientCert && (p.TLSClientCert != "" || p.TLSClientCertFile != "") {
+		return fmt.Errorf("config: tls_issue_client_cert cannot be used with tls_client_cert or tls_client_cert_file")
+	}
+
 	if p.TLSClientCert != "" && p.TLSClientKey != "" {
 		p.ClientCertificate, err = cryptutil.CertificateFromBase64(p.TLSClientCert, p.TLSClientKey)
 		if err != nil {
@@ -521,6 +916,201 @@ func (p *Policy) Validate() error {
 		}
 		p.KubernetesServiceAccountToken = string(token)
 	}
+	if len(p.KubernetesGroupMappings) > 0 && !p.IsForKubernetes() {
+		return fmt.Errorf("config: kubernetes_group_mappings requires a kubernetes service account token")
+	}
+
+	if p.RewriteResponseBody != nil {
+		if err := p.RewriteResponseBody.Validate(); err != nil {
+			return fmt.Errorf("config: invalid rewrite_response_body: %w", err)
+		}
+	}
+
+	if p.MirrorTo != "" {
+		if p.Redirect != nil {
+			return fmt.Errorf("config: mirror_to cannot be used with redirect")
+		}
+		u, err := ParseWeightedURL(p.MirrorTo)
+		if err != nil {
+			return fmt.Errorf("config: invalid mirror_to: %w", err)
+		}
+		if u.LbWeight != 0 {
+			return fmt.Errorf("config: mirror_to must not specify a weight")
+		}
+	}
+	if p.MirrorPercent != nil {
+		if p.MirrorTo == "" {
+			return fmt.Errorf("config: mirror_percent requires mirror_to")
+		}
+		if *p.MirrorPercent < 0 || *p.MirrorPercent > 100 {
+			return fmt.Errorf("config: mirror_percent must be between 0 and 100")
+		}
+	}
+
+	if p.MaxStreamDuration != nil && *p.MaxStreamDuration < 0 {
+		return fmt.Errorf("config: max_stream_duration must not be negative")
+	}
+
+	if p.HasRequestLimits() {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() {
+			return fmt.Errorf("config: request limits are not supported for this route type")
+		}
+		if p.MaxRequestBytes < 0 || p.MaxRequestHeaders < 0 || p.MaxRequestHeadersBytes < 0 || p.MaxURLLength < 0 {
+			return fmt.Errorf("config: request limits must not be negative")
+		}
+	}
+
+	if p.HasWebsocketLimits() {
+		if !p.AllowWebsockets {
+			return fmt.Errorf("config: websocket_idle_timeout and websocket_max_duration require allow_websockets")
+		}
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsForKubernetes() {
+			return fmt.Errorf("config: websocket_idle_timeout and websocket_max_duration are not supported for this route type")
+		}
+		if p.WebsocketIdleTimeout != nil && *p.WebsocketIdleTimeout < 0 {
+			return fmt.Errorf("config: websocket_idle_timeout must not be negative")
+		}
+		if p.WebsocketMaxDuration != nil && *p.WebsocketMaxDuration < 0 {
+			return fmt.Errorf("config: websocket_max_duration must not be negative")
+		}
+	}
+
+	for _, entries := range [][]string{p.IPAllowList, p.IPDenyList} {
+		for _, entry := range entries {
+			if entry == "" {
+				return fmt.Errorf("config: ip_allowlist and ip_denylist entries must not be empty")
+			}
+			// anything that isn't an ip range is a cidr set name, which is checked by the options
+			if _, ok := ParseIPOrCIDR(entry); !ok && strings.ContainsAny(entry, "/:") {
+				return fmt.Errorf("config: invalid ip range: %s", entry)
+			}
+		}
+	}
+
+	if p.RetryPolicy != nil {
+		if err := p.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("config: invalid retry_policy: %w", err)
+		}
+	}
+
+	if p.HealthCheck != nil {
+		if err := p.HealthCheck.Validate(); err != nil {
+			return fmt.Errorf("config: invalid health_check: %w", err)
+		}
+	}
+
+	if p.OutlierDetection != nil {
+		if err := p.OutlierDetection.Validate(); err != nil {
+			return fmt.Errorf("config: invalid outlier_detection: %w", err)
+		}
+	}
+
+	if p.ConnectionPool != nil {
+		if err := p.ConnectionPool.Validate(); err != nil {
+			return fmt.Errorf("config: invalid connection_pool: %w", err)
+		}
+	}
+
+	if p.WAF != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() {
+			return fmt.Errorf("config: waf is not supported for this route type")
+		}
+		if err := p.WAF.Validate(); err != nil {
+			return fmt.Errorf("config: invalid waf: %w", err)
+		}
+	}
+
+	if p.CORS != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: cors is not supported for this route type")
+		}
+		if err := p.CORS.Validate(); err != nil {
+			return fmt.Errorf("config: invalid cors: %w", err)
+		}
+	}
+
+	if p.CSRF != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: csrf is not supported for this route type")
+		}
+		if err := p.CSRF.Validate(); err != nil {
+			return fmt.Errorf("config: invalid csrf: %w", err)
+		}
+	}
+
+	if p.SessionCookie != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: session_cookie is not supported for this route type")
+		}
+		if err := p.SessionCookie.Validate(); err != nil {
+			return fmt.Errorf("config: invalid session_cookie: %w", err)
+		}
+		if !p.SessionCookie.matchesHost(source.Hostname()) {
+			return fmt.Errorf("config: invalid session_cookie: domain %s doesn't contain %s",
+				p.SessionCookie.Domain, source.Hostname())
+		}
+	}
+
+	if p.Compression != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: compression is not supported for this route type")
+		}
+		if err := p.Compression.Validate(); err != nil {
+			return fmt.Errorf("config: invalid compression: %w", err)
+		}
+	}
+
+	if p.ResponseCache != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: response_cache is not supported for this route type")
+		}
+		if err := p.ResponseCache.Validate(); err != nil {
+			return fmt.Errorf("config: invalid response_cache: %w", err)
+		}
+	}
+
+	if p.ExternalProcessor != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: external_processor is not supported for this route type")
+		}
+		if err := p.ExternalProcessor.Validate(); err != nil {
+			return fmt.Errorf("config: invalid external_processor: %w", err)
+		}
+	}
+
+	if len(p.Experiments) > 0 {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.IsEgress() || p.Redirect != nil {
+			return fmt.Errorf("config: experiments are not supported for this route type")
+		}
+		names := make(map[string]bool, len(p.Experiments))
+		for i := range p.Experiments {
+			if err := p.Experiments[i].Validate(); err != nil {
+				return fmt.Errorf("config: invalid experiment: %w", err)
+			}
+			if names[p.Experiments[i].Name] {
+				return fmt.Errorf("config: duplicate experiment: %s", p.Experiments[i].Name)
+			}
+			names[p.Experiments[i].Name] = true
+		}
+	}
+
+	if p.SLO != nil {
+		if p.IsTCP() || p.IsUDP() || p.IsSSH() || p.Redirect != nil {
+			return fmt.Errorf("config: slo is not supported for this route type")
+		}
+		if err := p.SLO.Validate(); err != nil {
+			return fmt.Errorf("config: invalid slo: %w", err)
+		}
+	}
+
+	if p.StickySession != nil {
+		if p.Redirect != nil {
+			return fmt.Errorf("config: sticky_session cannot be used with redirect")
+		}
+		if p.StickySession.CookieTTL < 0 {
+			return fmt.Errorf("config: sticky_session cookie_ttl must not be negative")
+		}
+	}
 
 	if p.PrefixRewrite != "" && p.RegexRewritePattern != "" {
 		return fmt.Errorf("config: only prefix_rewrite or regex_rewrite_pattern can be specified, but not both")
@@ -562,13 +1152,24 @@ func (p *Policy) RouteID() (uint64, error) {
 		id.To = dst
 	} else if p.Redirect != nil {
 		id.Redirect = p.Redirect
-	} else {
+	} else if !p.IsEgress() {
 		return 0, errEitherToOrRedirectRequired
 	}
 
 	return hashutil.Hash(id)
 }
 
+// RouteMatchID returns an identifier for the requests a route matches. Unlike RouteID it doesn't
+// depend on the route's upstreams, so it stays the same when they change.
+func (p *Policy) RouteMatchID() uint64 {
+	return hashutil.MustHash(routeID{
+		From:   p.From,
+		Prefix: p.Prefix,
+		Path:   p.Path,
+		Regex:  p.Regex,
+	})
+}
+
 func (p *Policy) String() string {
 	to := "?"
 	if len(p.To) > 0 {
@@ -594,20 +1195,25 @@ func (p *Policy) Matches(requestURL url.URL) bool {
 		return false
 	}
 
+	return p.matchesPath(requestURL.Path)
+}
+
+// matchesPath returns true if the prefix, path and regex of the policy match the given path.
+func (p *Policy) matchesPath(path string) bool {
 	if p.Prefix != "" {
-		if !strings.HasPrefix(requestURL.Path, p.Prefix) {
+		if !strings.HasPrefix(path, p.Prefix) {
 			return false
 		}
 	}
 
 	if p.Path != "" {
-		if requestURL.Path != p.Path {
+		if path != p.Path {
 			return false
 		}
 	}
 
 	if p.compiledRegex != nil {
-		if !p.compiledRegex.MatchString(requestURL.Path) {
+		if !p.compiledRegex.MatchString(path) {
 			return false
 		}
 	}
@@ -625,6 +1231,54 @@ func (p *Policy) IsTCP() bool {
 	return strings.HasPrefix(p.From, "tcp")
 }
 
+// GetUpstreams returns the upstream endpoints for the route. If discovery has resolved the
+// upstreams, the resolved endpoints are returned instead of To.
+func (p *Policy) GetUpstreams() WeightedURLs {
+	if p.ResolvedTo != nil {
+		return p.ResolvedTo
+	}
+	return p.To
+}
+
+// IsUDP returns true if the route is for UDP.
+func (p *Policy) IsUDP() bool {
+	return strings.HasPrefix(p.From, "udp+")
+}
+
+// IsSSH returns true if the route is for SSH.
+func (p *Policy) IsSSH() bool {
+	return strings.HasPrefix(p.From, "ssh://")
+}
+
+// HasRequestLimits returns true if the policy limits the request body, header or URL size.
+func (p *Policy) HasRequestLimits() bool {
+	return p.MaxRequestBytes != 0 || p.MaxRequestHeaders != 0 || p.MaxRequestHeadersBytes != 0 || p.MaxURLLength != 0
+}
+
+// HasWebsocketLimits returns true if the policy sets an idle timeout or maximum duration for websocket
+// connections.
+func (p *Policy) HasWebsocketLimits() bool {
+	return p.WebsocketIdleTimeout != nil || p.WebsocketMaxDuration != nil
+}
+
+// GetCIDRSetNames returns the names of the cidr sets referenced by the ip allow and deny lists.
+func (p *Policy) GetCIDRSetNames() []string {
+	var names []string
+	for _, entries := range [][]string{p.IPAllowList, p.IPDenyList} {
+		for _, entry := range entries {
+			if _, ok := ParseIPOrCIDR(entry); !ok {
+				names = append(names, entry)
+			}
+		}
+	}
+	return names
+}
+
+// IsEgress returns true if the policy is a forward proxy egress policy.
+func (p *Policy) IsEgress() bool {
+	return strings.HasPrefix(p.From, "egress://")
+}
+
 // AllAllowedDomains returns all the allowed domains.
 func (p *Policy) AllAllowedDomains() []string {
 	var ads []string
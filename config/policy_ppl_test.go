@@ -228,7 +228,8 @@ else = [false, {"user-unauthenticated"}]
 
 user_0 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user1"
 }
 
@@ -255,7 +256,8 @@ else = [false, {"user-unauthenticated"}]
 
 user_1 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user2"
 }
 
@@ -282,7 +284,8 @@ else = [false, {"user-unauthenticated"}]
 
 user_2 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user3"
 }
 
@@ -309,7 +312,8 @@ else = [false, {"user-unauthenticated"}]
 
 user_3 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user4"
 }
 
@@ -336,7 +340,8 @@ else = [false, {"user-unauthenticated"}]
 
 user_4 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user5"
 }
 
@@ -369,7 +374,8 @@ or_0 = v {
 
 user_5 = [true, {"user-ok"}] {
 	session := get_session(input.session.id)
-	user_id := session.user_id
+	user_ids := get_user_ids(session)
+	user_id := user_ids[_]
 	user_id == "user6"
 }
 
@@ -473,6 +479,21 @@ else = v {
 
 else = {}
 
+get_user_ids(session) = v {
+	alias := get_databroker_record("pomerium.io/UserAlias", session.user_id)
+	alias != null
+	v = array.concat([session.user_id], get_alias_ids(alias))
+}
+
+else = v {
+	v = [session.user_id]
+}
+
+get_alias_ids(alias) = v {
+	ids := [id | id := object.get(alias, "id", ""); id != ""]
+	v = array.concat(ids, array.concat(object.get(alias, "idp_ids", []), object.get(alias, "aliases", [])))
+}
+
 get_user(session) = v {
 	v = get_databroker_record("type.googleapis.com/user.User", session.user_id)
 	v != null
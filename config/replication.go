@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DataBrokerReplicationSettings configure asynchronous replication of databroker records between
+// pomerium clusters in different regions, so that sessions are shared without a single global
+// storage backend. Conflicting writes are resolved by last-writer-wins, so the clocks of the
+// regions must be synchronized. All the regions must use the same shared secret.
+type DataBrokerReplicationSettings struct {
+	// Region uniquely names this cluster, e.g. us-east. Replication is disabled if no region is set.
+	Region string `mapstructure:"region" yaml:"region,omitempty"`
+	// Peers maps the region names of the other regions to their databroker urls, e.g.
+	// eu-west: https://databroker.eu.example.com:5443. The names must match the peers' regions.
+	Peers map[string]string `mapstructure:"peers" yaml:"peers,omitempty"`
+	// RecordTypes are the record types to replicate. Defaults to sessions, users, devices, session
+	// revocations and databroker config.
+	RecordTypes []string `mapstructure:"record_types" yaml:"record_types,omitempty"`
+	// CAFile is a bundle of certificate authorities used to verify the peers. Defaults to the
+	// system roots.
+	CAFile string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+}
+
+// IsEnabled returns true if databroker replication is enabled.
+func (s *DataBrokerReplicationSettings) IsEnabled() bool {
+	return s.Region != "" && len(s.Peers) > 0
+}
+
+// Validate validates the databroker replication settings.
+func (s *DataBrokerReplicationSettings) Validate() error {
+	if s.Region == "" && len(s.Peers) > 0 {
+		return fmt.Errorf("config: databroker_replication peers require a region")
+	}
+	for region, peer := range s.Peers {
+		if region == "" || region == s.Region {
+			return fmt.Errorf("config: invalid databroker_replication peer region: %q", region)
+		}
+		u, err := url.Parse(peer)
+		if err != nil {
+			return fmt.Errorf("config: invalid databroker_replication peer: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("config: invalid databroker_replication peer: %s", peer)
+		}
+	}
+	return nil
+}
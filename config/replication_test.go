@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataBrokerReplicationSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings DataBrokerReplicationSettings
+		valid    bool
+	}{
+		{"disabled", DataBrokerReplicationSettings{}, true},
+		{"valid", DataBrokerReplicationSettings{
+			Region: "us-east",
+			Peers:  map[string]string{"eu-west": "https://databroker.eu.example.com:5443"},
+		}, true},
+		{"missing region", DataBrokerReplicationSettings{
+			Peers: map[string]string{"eu-west": "https://databroker.eu.example.com:5443"},
+		}, false},
+		{"same region", DataBrokerReplicationSettings{
+			Region: "us-east",
+			Peers:  map[string]string{"us-east": "https://databroker.us.example.com:5443"},
+		}, false},
+		{"invalid url", DataBrokerReplicationSettings{
+			Region: "us-east",
+			Peers:  map[string]string{"eu-west": "databroker.eu.example.com:5443"},
+		}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
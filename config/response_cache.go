@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ResponseCacheBackend is where a route's cached responses are stored.
+type ResponseCacheBackend string
+
+const (
+	// ResponseCacheBackendMemory stores cached responses in memory.
+	ResponseCacheBackendMemory ResponseCacheBackend = "memory"
+	// ResponseCacheBackendDisk stores cached responses in response_cache.directory.
+	ResponseCacheBackendDisk ResponseCacheBackend = "disk"
+)
+
+// DefaultResponseCacheAllowedVaryHeaders are the request headers a cached response may vary on
+// if none are configured.
+var DefaultResponseCacheAllowedVaryHeaders = []string{"accept-encoding"}
+
+// ResponseCacheSettings configure the response caches used by routes with a response_cache policy.
+type ResponseCacheSettings struct {
+	// AllowedVaryHeaders are the request headers a response may vary on and still be cached.
+	// Responses which vary on any other header are not cached. Defaults to accept-encoding.
+	AllowedVaryHeaders []string `mapstructure:"allowed_vary_headers" yaml:"allowed_vary_headers,omitempty"`
+	// MaxBodyBytes is the size of the largest response body which will be cached. 0 means no limit.
+	MaxBodyBytes uint32 `mapstructure:"max_body_bytes" yaml:"max_body_bytes,omitempty"`
+	// Directory is where the disk backend stores cached responses.
+	Directory string `mapstructure:"directory" yaml:"directory,omitempty"`
+	// MaxSizeBytes is the maximum size of the disk backend. 0 means no limit.
+	MaxSizeBytes uint64 `mapstructure:"max_size_bytes" yaml:"max_size_bytes,omitempty"`
+}
+
+// GetAllowedVaryHeaders returns the allowed vary headers, or the defaults if none are set.
+func (s *ResponseCacheSettings) GetAllowedVaryHeaders() []string {
+	if len(s.AllowedVaryHeaders) == 0 {
+		return DefaultResponseCacheAllowedVaryHeaders
+	}
+	return s.AllowedVaryHeaders
+}
+
+// Validate validates the response cache settings.
+func (s *ResponseCacheSettings) Validate() error {
+	for _, h := range s.AllowedVaryHeaders {
+		if h == "" {
+			return fmt.Errorf("config: response_cache allowed_vary_headers must not be empty")
+		}
+	}
+	if s.Directory != "" && !filepath.IsAbs(s.Directory) {
+		return fmt.Errorf("config: response_cache directory must be an absolute path")
+	}
+	return nil
+}
+
+// PolicyResponseCache enables caching of upstream responses for a route.
+//
+// Requests are still authorized before a cached response is returned. Responses are cached
+// according to their Cache-Control headers and are keyed on the request URL and the allowed vary
+// headers, so upstreams must mark per-user responses as private or no-store.
+type PolicyResponseCache struct {
+	// Backend is either memory or disk. Defaults to memory.
+	Backend ResponseCacheBackend `mapstructure:"backend" yaml:"backend,omitempty" json:"backend,omitempty"`
+}
+
+// GetBackend returns the backend, or memory if none is set.
+func (c *PolicyResponseCache) GetBackend() ResponseCacheBackend {
+	if c.Backend == "" {
+		return ResponseCacheBackendMemory
+	}
+	return c.Backend
+}
+
+// Validate validates the response cache settings.
+func (c *PolicyResponseCache) Validate() error {
+	switch c.GetBackend() {
+	case ResponseCacheBackendMemory, ResponseCacheBackendDisk:
+	default:
+		return fmt.Errorf("unknown backend: %s", c.Backend)
+	}
+	return nil
+}
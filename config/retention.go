@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultDataBrokerRetentionInterval is how often expired records are deleted if no interval is set.
+const DefaultDataBrokerRetentionInterval = time.Hour
+
+// DataBrokerRetentionSettings configure deleting old databroker records, so that storage backends
+// don't grow unbounded.
+type DataBrokerRetentionSettings struct {
+	// Interval is how often expired records are deleted. Defaults to 1 hour.
+	Interval time.Duration `mapstructure:"interval" yaml:"interval,omitempty"`
+	// Policies are the retention policies of the record types. Records of other types are kept.
+	Policies []DataBrokerRetentionPolicy `mapstructure:"policies" yaml:"policies,omitempty"`
+}
+
+// A DataBrokerRetentionPolicy configures how long the records of a type are kept.
+type DataBrokerRetentionPolicy struct {
+	// RecordType is the type of the records, e.g. type.googleapis.com/session.Session.
+	RecordType string `mapstructure:"record_type" yaml:"record_type,omitempty"`
+	// MaxAge deletes records which haven't been modified for this long.
+	MaxAge time.Duration `mapstructure:"max_age" yaml:"max_age,omitempty"`
+	// ExpiryGracePeriod deletes records this long after their expires_at time, for records which
+	// have one, such as sessions.
+	ExpiryGracePeriod *time.Duration `mapstructure:"expiry_grace_period" yaml:"expiry_grace_period,omitempty"`
+}
+
+// IsEnabled returns true if any retention policies are configured.
+func (s *DataBrokerRetentionSettings) IsEnabled() bool {
+	return len(s.Policies) > 0
+}
+
+// GetInterval returns the interval, or the default interval if none is set.
+func (s *DataBrokerRetentionSettings) GetInterval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultDataBrokerRetentionInterval
+	}
+	return s.Interval
+}
+
+// Validate validates the databroker retention settings.
+func (s *DataBrokerRetentionSettings) Validate() error {
+	if s.Interval < 0 {
+		return fmt.Errorf("config: databroker_retention interval must not be negative")
+	}
+
+	seen := make(map[string]struct{}, len(s.Policies))
+	for _, p := range s.Policies {
+		if p.RecordType == "" {
+			return fmt.Errorf("config: databroker_retention policies require a record_type")
+		}
+		if _, ok := seen[p.RecordType]; ok {
+			return fmt.Errorf("config: duplicate databroker_retention policy for %s", p.RecordType)
+		}
+		seen[p.RecordType] = struct{}{}
+
+		if p.MaxAge < 0 || (p.ExpiryGracePeriod != nil && *p.ExpiryGracePeriod < 0) {
+			return fmt.Errorf("config: databroker_retention policy for %s must not be negative", p.RecordType)
+		}
+		if p.MaxAge == 0 && p.ExpiryGracePeriod == nil {
+			return fmt.Errorf("config: databroker_retention policy for %s requires a max_age or expiry_grace_period", p.RecordType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataBrokerRetentionSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	grace := time.Hour
+	negative := -time.Hour
+	for _, tc := range []struct {
+		name     string
+		settings DataBrokerRetentionSettings
+		valid    bool
+	}{
+		{"disabled", DataBrokerRetentionSettings{}, true},
+		{"valid", DataBrokerRetentionSettings{Policies: []DataBrokerRetentionPolicy{
+			{RecordType: "pomerium.io/DirectoryUser", MaxAge: 30 * 24 * time.Hour},
+			{RecordType: "type.googleapis.com/session.Session", ExpiryGracePeriod: &grace},
+		}}, true},
+		{"missing record type", DataBrokerRetentionSettings{Policies: []DataBrokerRetentionPolicy{
+			{MaxAge: time.Hour},
+		}}, false},
+		{"duplicate record type", DataBrokerRetentionSettings{Policies: []DataBrokerRetentionPolicy{
+			{RecordType: "TYPE", MaxAge: time.Hour},
+			{RecordType: "TYPE", MaxAge: 2 * time.Hour},
+		}}, false},
+		{"no retention", DataBrokerRetentionSettings{Policies: []DataBrokerRetentionPolicy{
+			{RecordType: "TYPE"},
+		}}, false},
+		{"negative grace period", DataBrokerRetentionSettings{Policies: []DataBrokerRetentionPolicy{
+			{RecordType: "TYPE", ExpiryGracePeriod: &negative},
+		}}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+// A RouteMatcher finds the policy matching a request URL without checking every policy.
+//
+// Policies are indexed by the domains of their from URLs: exact domains in a map and wildcard
+// domains in a radix tree of their reversed literal suffixes. Within a domain, policies are indexed
+// by their path: exact paths in a map and prefixes in a radix tree. Only the policies found through
+// these indexes are checked, and the first one in configuration order is returned, so the result
+// is the same as calling Matches on each policy in order.
+type RouteMatcher struct {
+	policies      []*Policy
+	domains       map[string]*routePathIndex
+	wildcards     *radixNode
+	wildcardHosts []wildcardHost
+}
+
+type wildcardHost struct {
+	re    *regexp.Regexp
+	paths *routePathIndex
+}
+
+// NewRouteMatcher creates a new RouteMatcher for the policies. The policies must not be modified
+// while the RouteMatcher is in use.
+func NewRouteMatcher(policies []Policy) *RouteMatcher {
+	m := &RouteMatcher{
+		policies:  make([]*Policy, len(policies)),
+		domains:   make(map[string]*routePathIndex),
+		wildcards: new(radixNode),
+	}
+	wildcardIndexes := make(map[string]int)
+	for i := range policies {
+		p := &policies[i]
+		m.policies[i] = p
+
+		// an invalid from URL should not match anything
+		fromURL, err := urlutil.ParseAndValidateURL(p.From)
+		if err != nil {
+			continue
+		}
+
+		for _, domain := range urlutil.GetDomainsForURL(fromURL) {
+			if !strings.Contains(domain, "*") {
+				idx, ok := m.domains[domain]
+				if !ok {
+					idx = newRoutePathIndex()
+					m.domains[domain] = idx
+				}
+				idx.add(p, i)
+				continue
+			}
+
+			j, ok := wildcardIndexes[domain]
+			if !ok {
+				j = len(m.wildcardHosts)
+				wildcardIndexes[domain] = j
+				m.wildcardHosts = append(m.wildcardHosts, wildcardHost{
+					re:    regexp.MustCompile(WildcardToRegex(domain)),
+					paths: newRoutePathIndex(),
+				})
+				suffix := domain[strings.LastIndexByte(domain, '*')+1:]
+				m.wildcards.insert(reverseString(suffix), j)
+			}
+			m.wildcardHosts[j].paths.add(p, i)
+		}
+	}
+	return m
+}
+
+// Match returns the first policy matching the request URL, or nil if no policy matches.
+func (m *RouteMatcher) Match(requestURL url.URL) *Policy {
+	best := -1
+	if idx, ok := m.domains[requestURL.Host]; ok {
+		best = idx.match(m.policies, requestURL.Path, best)
+	}
+	m.wildcards.walk(reverseString(requestURL.Host), func(values []int) {
+		for _, j := range values {
+			w := &m.wildcardHosts[j]
+			if w.re.MatchString(requestURL.Host) {
+				best = w.paths.match(m.policies, requestURL.Path, best)
+			}
+		}
+	})
+	if best < 0 {
+		return nil
+	}
+	return m.policies[best]
+}
+
+// A routePathIndex indexes the policies of a domain by their path.
+type routePathIndex struct {
+	paths    map[string][]int
+	prefixes *radixNode
+	other    []int
+}
+
+func newRoutePathIndex() *routePathIndex {
+	return &routePathIndex{
+		paths:    make(map[string][]int),
+		prefixes: new(radixNode),
+	}
+}
+
+func (idx *routePathIndex) add(p *Policy, i int) {
+	switch {
+	case p.Path != "":
+		idx.paths[p.Path] = append(idx.paths[p.Path], i)
+	case p.Prefix != "":
+		idx.prefixes.insert(p.Prefix, i)
+	default:
+		idx.other = append(idx.other, i)
+	}
+}
+
+// match returns the index of the first policy matching the path, if it comes before best.
+// Otherwise best is returned.
+func (idx *routePathIndex) match(policies []*Policy, path string, best int) int {
+	check := func(values []int) {
+		for _, i := range values {
+			if best >= 0 && i >= best {
+				// values are in configuration order
+				return
+			}
+			if policies[i].matchesPath(path) {
+				best = i
+				return
+			}
+		}
+	}
+	check(idx.paths[path])
+	idx.prefixes.walk(path, check)
+	check(idx.other)
+	return best
+}
+
+// A radixNode is a node of a radix tree mapping string keys to the indexes added for them.
+type radixNode struct {
+	label    string
+	values   []int
+	children map[byte]*radixNode
+}
+
+func (n *radixNode) insert(key string, value int) {
+	for {
+		if key == "" {
+			n.values = append(n.values, value)
+			return
+		}
+
+		child, ok := n.children[key[0]]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[byte]*radixNode)
+			}
+			n.children[key[0]] = &radixNode{label: key, values: []int{value}}
+			return
+		}
+
+		common := commonPrefixLength(key, child.label)
+		if common < len(child.label) {
+			// split the child at the end of the common prefix
+			split := &radixNode{
+				label:    child.label[:common],
+				children: map[byte]*radixNode{child.label[common]: child},
+			}
+			child.label = child.label[common:]
+			n.children[key[0]] = split
+			child = split
+		}
+		n, key = child, key[common:]
+	}
+}
+
+// walk calls fn with the values of every key which is a prefix of s, shortest first.
+func (n *radixNode) walk(s string, fn func(values []int)) {
+	for {
+		if len(n.values) > 0 {
+			fn(n.values)
+		}
+		if s == "" {
+			return
+		}
+		child, ok := n.children[s[0]]
+		if !ok || !strings.HasPrefix(s, child.label) {
+			return
+		}
+		n, s = child, s[len(child.label):]
+	}
+}
+
+func commonPrefixLength(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func reverseString(s string) string {
+	bs := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		bs[len(s)-1-i] = s[i]
+	}
+	return string(bs)
+}
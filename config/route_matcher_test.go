@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+func TestRouteMatcher(t *testing.T) {
+	t.Parallel()
+
+	policies := []Policy{
+		{From: "https://a.example.com", Path: "/exact"},
+		{From: "https://a.example.com", Prefix: "/api/v1"},
+		{From: "https://a.example.com", Prefix: "/api"},
+		{From: "https://a.example.com", Regex: `/regex/[0-9]+`},
+		{From: "https://*.example.com", Prefix: "/wildcard"},
+		{From: "https://a.example.com"},
+		{From: "https://*.example.com"},
+		{From: "https://a.*.example.com"},
+		{From: "https://b.example.com:8443"},
+		{From: "https://*"},
+	}
+	for i := range policies {
+		policies[i].To = mustParseWeightedURLs(t, "https://localhost")
+		require.NoError(t, policies[i].Validate())
+	}
+
+	m := NewRouteMatcher(policies)
+	for _, rawURL := range []string{
+		"https://a.example.com/exact",
+		"https://a.example.com/exact/",
+		"https://a.example.com/api/v1/users",
+		"https://a.example.com/api/v2",
+		"https://a.example.com/regex/123",
+		"https://a.example.com/regex/abc",
+		"https://a.example.com/wildcard",
+		"https://a.example.com",
+		"https://a.example.com:443/api",
+		"https://b.example.com/wildcard/x",
+		"https://b.example.com/",
+		"https://a.b.example.com/",
+		"https://b.example.com:8443/",
+		"https://example.com/",
+		"https://other.test/",
+	} {
+		u := urlutil.MustParseAndValidateURL(rawURL)
+		var expect *Policy
+		for i := range policies {
+			if policies[i].Matches(u) {
+				expect = &policies[i]
+				break
+			}
+		}
+		assert.Same(t, expect, m.Match(u), rawURL)
+	}
+
+	assert.Nil(t, NewRouteMatcher(nil).Match(url.URL{Scheme: "https", Host: "a.example.com"}))
+}
+
+func TestRadixNode(t *testing.T) {
+	t.Parallel()
+
+	var n radixNode
+	for i, key := range []string{"/api", "/api/v1", "/app", "/", "/api", "/b"} {
+		n.insert(key, i)
+	}
+
+	var values []int
+	n.walk("/api/v1/users", func(vs []int) { values = append(values, vs...) })
+	assert.Equal(t, []int{3, 0, 4, 1}, values)
+
+	values = nil
+	n.walk("/ap", func(vs []int) { values = append(values, vs...) })
+	assert.Equal(t, []int{3}, values)
+}
+
+func BenchmarkRouteMatcher(b *testing.B) {
+	policies := make([]Policy, 5000)
+	for i := range policies {
+		policies[i] = Policy{
+			From:   fmt.Sprintf("https://app-%d.example.com", i/10),
+			Prefix: fmt.Sprintf("/service-%d", i%10),
+		}
+	}
+	u := urlutil.MustParseAndValidateURL("https://app-499.example.com/service-9/resource")
+
+	b.Run("linear", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range policies {
+				if policies[j].Matches(u) {
+					break
+				}
+			}
+		}
+	})
+	b.Run("matcher", func(b *testing.B) {
+		m := NewRouteMatcher(policies)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Match(u)
+		}
+	})
+}
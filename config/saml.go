@@ -0,0 +1,93 @@
+package config
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+	"github.com/pomerium/pomerium/internal/identity/saml"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// SAMLSettings configure the SAML identity provider, which is used when idp_provider is saml. The
+// identity provider metadata is fetched from idp_provider_url unless it's set here, and
+// idp_client_id is used as the service provider entity id.
+type SAMLSettings struct {
+	// IdPMetadata is the base64-encoded identity provider metadata XML.
+	IdPMetadata     string `mapstructure:"idp_metadata" yaml:"idp_metadata,omitempty"`
+	IdPMetadataFile string `mapstructure:"idp_metadata_file" yaml:"idp_metadata_file,omitempty"`
+
+	// Certificate and Key are the base64-encoded RSA certificate and key used to sign
+	// authentication requests. Requests are unsigned if no certificate is set.
+	Certificate     string `mapstructure:"certificate" yaml:"certificate,omitempty"`
+	Key             string `mapstructure:"certificate_key" yaml:"certificate_key,omitempty"`
+	CertificateFile string `mapstructure:"certificate_file" yaml:"certificate_file,omitempty"`
+	KeyFile         string `mapstructure:"certificate_key_file" yaml:"certificate_key_file,omitempty"`
+
+	// AttributeMapping maps claim names to assertion attribute names, overriding the defaults for
+	// email, name, given_name, family_name and groups. The subject name id is always the sub claim.
+	AttributeMapping map[string]string `mapstructure:"attribute_mapping" yaml:"attribute_mapping,omitempty"`
+}
+
+// Validate validates the SAML settings.
+func (s *SAMLSettings) Validate() error {
+	if s.IdPMetadata != "" {
+		if _, err := base64.StdEncoding.DecodeString(s.IdPMetadata); err != nil {
+			return fmt.Errorf("config: invalid saml idp_metadata: %w", err)
+		}
+	}
+	if _, err := s.GetCertificate(); err != nil {
+		return fmt.Errorf("config: invalid saml certificate: %w", err)
+	}
+	return nil
+}
+
+// GetIdPMetadata gets the identity provider metadata, or nil if it should be fetched.
+func (s *SAMLSettings) GetIdPMetadata() ([]byte, error) {
+	if s.IdPMetadataFile != "" {
+		return os.ReadFile(s.IdPMetadataFile)
+	}
+	if s.IdPMetadata != "" {
+		return base64.StdEncoding.DecodeString(s.IdPMetadata)
+	}
+	return nil, nil
+}
+
+// GetCertificate gets the certificate used to sign authentication requests, or nil if none is set.
+func (s *SAMLSettings) GetCertificate() (*tls.Certificate, error) {
+	switch {
+	case s.Certificate != "" || s.Key != "":
+		return cryptutil.CertificateFromBase64(s.Certificate, s.Key)
+	case s.CertificateFile != "" || s.KeyFile != "":
+		return cryptutil.CertificateFromFile(s.CertificateFile, s.KeyFile)
+	}
+	return nil, nil
+}
+
+// GetSAMLOptions gets the SAML options for the given identity provider type, which are only set
+// for the SAML provider.
+func (o *Options) GetSAMLOptions(providerType string) (oauth.SAMLOptions, error) {
+	if providerType != saml.Name {
+		return oauth.SAMLOptions{}, nil
+	}
+	if o.ProviderURL == "" && o.SAML.IdPMetadata == "" && o.SAML.IdPMetadataFile == "" {
+		return oauth.SAMLOptions{}, errors.New("config: saml requires idp_provider_url or saml.idp_metadata")
+	}
+
+	idpMetadata, err := o.SAML.GetIdPMetadata()
+	if err != nil {
+		return oauth.SAMLOptions{}, err
+	}
+	cert, err := o.SAML.GetCertificate()
+	if err != nil {
+		return oauth.SAMLOptions{}, err
+	}
+	return oauth.SAMLOptions{
+		IdPMetadata:      idpMetadata,
+		Certificate:      cert,
+		AttributeMapping: o.SAML.AttributeMapping,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_GetSAMLOptions(t *testing.T) {
+	t.Parallel()
+
+	o := NewDefaultOptions()
+	samlOptions, err := o.GetSAMLOptions("oidc")
+	assert.NoError(t, err)
+	assert.Empty(t, samlOptions)
+
+	_, err = o.GetSAMLOptions("saml")
+	assert.Error(t, err, "should require metadata")
+
+	o.SAML.IdPMetadata = base64.StdEncoding.EncodeToString([]byte("<EntityDescriptor/>"))
+	o.SAML.AttributeMapping = map[string]string{"email": "mail"}
+	samlOptions, err = o.GetSAMLOptions("saml")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("<EntityDescriptor/>"), samlOptions.IdPMetadata)
+	assert.Nil(t, samlOptions.Certificate)
+	assert.Equal(t, map[string]string{"email": "mail"}, samlOptions.AttributeMapping)
+}
+
+func TestSAMLSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, (&SAMLSettings{}).Validate())
+	assert.Error(t, (&SAMLSettings{IdPMetadata: "<not base64>"}).Validate())
+	assert.Error(t, (&SAMLSettings{Certificate: "Zm9v"}).Validate())
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// SCIMPath is the path prefix of the SCIM 2.0 endpoint.
+const SCIMPath = "/.pomerium/scim/v2/"
+
+// SCIMSettings configure the SCIM 2.0 endpoint, which identity providers use to provision users and
+// groups directly into the databroker.
+type SCIMSettings struct {
+	// BearerToken is the token identity providers must use to authenticate to the SCIM endpoint. The
+	// SCIM endpoint is disabled if no bearer token is set.
+	BearerToken     string `mapstructure:"bearer_token" yaml:"bearer_token,omitempty"`
+	BearerTokenFile string `mapstructure:"bearer_token_file" yaml:"bearer_token_file,omitempty"`
+}
+
+// IsEnabled returns true if the SCIM endpoint is enabled.
+func (s *SCIMSettings) IsEnabled() bool {
+	return s.BearerToken != "" || s.BearerTokenFile != ""
+}
+
+// GetBearerToken gets the SCIM bearer token.
+func (s *SCIMSettings) GetBearerToken() (string, error) {
+	if s.BearerTokenFile != "" {
+		bs, err := os.ReadFile(s.BearerTokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(bs)), nil
+	}
+	return s.BearerToken, nil
+}
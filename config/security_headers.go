@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+// SecurityHeadersSettings configure the security headers of the pages served by Pomerium itself:
+// the dashboard, error and authenticate pages. Headers which aren't set use the defaults, and
+// X-Frame-Options and Permissions-Policy are only added if they're set.
+//
+// A {nonce} in a header is replaced with a new random nonce for each response, which the built-in
+// pages add to their scripts, so a policy like "script-src 'nonce-{nonce}'" doesn't need
+// 'unsafe-inline'.
+type SecurityHeadersSettings struct {
+	// ContentSecurityPolicy is the Content-Security-Policy header.
+	ContentSecurityPolicy string `mapstructure:"content_security_policy" yaml:"content_security_policy,omitempty"`
+	// FrameOptions is the X-Frame-Options header, like DENY or SAMEORIGIN.
+	FrameOptions string `mapstructure:"frame_options" yaml:"frame_options,omitempty"`
+	// ReferrerPolicy is the Referrer-Policy header.
+	ReferrerPolicy string `mapstructure:"referrer_policy" yaml:"referrer_policy,omitempty"`
+	// PermissionsPolicy is the Permissions-Policy header, like "camera=(), microphone=()".
+	PermissionsPolicy string `mapstructure:"permissions_policy" yaml:"permissions_policy,omitempty"`
+}
+
+// GetHeaders returns the security headers to add to responses.
+func (s *SecurityHeadersSettings) GetHeaders() map[string]string {
+	headers := make(map[string]string, len(httputil.HeadersContentSecurityPolicy)+2)
+	for k, v := range httputil.HeadersContentSecurityPolicy {
+		headers[k] = v
+	}
+	set := func(k, v string) {
+		if v != "" {
+			headers[k] = v
+		}
+	}
+	set("Content-Security-Policy", s.ContentSecurityPolicy)
+	set("X-Frame-Options", s.FrameOptions)
+	set("Referrer-Policy", s.ReferrerPolicy)
+	set("Permissions-Policy", s.PermissionsPolicy)
+	return headers
+}
+
+// Validate validates the security headers settings.
+func (s *SecurityHeadersSettings) Validate() error {
+	for name, value := range map[string]string{
+		"content_security_policy": s.ContentSecurityPolicy,
+		"frame_options":           s.FrameOptions,
+		"referrer_policy":         s.ReferrerPolicy,
+		"permissions_policy":      s.PermissionsPolicy,
+	} {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("config: security_headers %s must not contain line breaks", name)
+		}
+	}
+	switch strings.ToUpper(s.FrameOptions) {
+	case "", "DENY", "SAMEORIGIN":
+	default:
+		return fmt.Errorf("config: security_headers frame_options must be DENY or SAMEORIGIN")
+	}
+	return nil
+}
@@ -36,16 +36,7 @@ func NewSessionStore(options *Options) (*SessionStore, error) {
 		return nil, fmt.Errorf("config/sessions: invalid session encoder: %w", err)
 	}
 
-	cookieStore, err := cookie.NewStore(func() cookie.Options {
-		return cookie.Options{
-			Name:     options.CookieName,
-			Domain:   options.CookieDomain,
-			Secure:   options.CookieSecure,
-			HTTPOnly: options.CookieHTTPOnly,
-			Expire:   options.CookieExpire,
-			SameSite: options.GetCookieSameSite(),
-		}
-	}, store.encoder)
+	cookieStore, err := cookie.NewRequestStore(NewSessionCookieOptionsGetter(options), store.encoder)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/sessions/cookie"
+)
+
+// PolicySessionCookie overrides the session cookie settings for a route.
+//
+// By default sessions are shared by all routes within the cookie domain. A route with its own
+// cookie name or a narrower domain or path gets a session which isn't sent to other routes. Routes
+// which share a host should use the same overrides, since the proxy's endpoints on that host are
+// not specific to a route.
+type PolicySessionCookie struct {
+	// Name is the name of the session cookie.
+	Name string `mapstructure:"name" yaml:"name,omitempty" json:"name,omitempty"`
+	// Domain is the domain of the session cookie. It must contain the route's host.
+	Domain string `mapstructure:"domain" yaml:"domain,omitempty" json:"domain,omitempty"`
+	// Path is the path of the session cookie.
+	Path string `mapstructure:"path" yaml:"path,omitempty" json:"path,omitempty"`
+	// SameSite is the SameSite attribute of the session cookie: strict, lax or none.
+	SameSite string `mapstructure:"same_site" yaml:"same_site,omitempty" json:"same_site,omitempty"`
+}
+
+// Validate checks the validity of the session cookie options.
+func (c *PolicySessionCookie) Validate() error {
+	if c.Name != "" && (&http.Cookie{Name: c.Name, Value: "x"}).Valid() != nil {
+		return fmt.Errorf("invalid name: %q", c.Name)
+	}
+	if c.Domain != "" && (&http.Cookie{Name: "x", Value: "x", Domain: c.Domain}).Valid() != nil {
+		return fmt.Errorf("invalid domain: %q", c.Domain)
+	}
+	if c.Path != "" && (!strings.HasPrefix(c.Path, "/") || strings.ContainsAny(c.Path, ";\r\n")) {
+		return fmt.Errorf("invalid path: %q", c.Path)
+	}
+	if err := ValidateCookieSameSite(c.SameSite); err != nil {
+		return err
+	}
+	return nil
+}
+
+// matchesHost reports whether the cookie domain contains the host.
+func (c *PolicySessionCookie) matchesHost(host string) bool {
+	if c.Domain == "" {
+		return true
+	}
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	host = strings.ToLower(host)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// GetSessionCookieOptionsForPolicy returns the session cookie options for the given policy, taking
+// the session cookie overrides of the policy into account. If policy is nil, the default options
+// are returned.
+func (o *Options) GetSessionCookieOptionsForPolicy(policy *Policy) cookie.Options {
+	opts := cookie.Options{
+		Name:     o.CookieName,
+		Domain:   o.CookieDomain,
+		Secure:   o.CookieSecure,
+		HTTPOnly: o.CookieHTTPOnly,
+		Expire:   o.CookieExpire,
+		SameSite: o.GetCookieSameSite(),
+	}
+	if policy == nil || policy.SessionCookie == nil {
+		return opts
+	}
+
+	if policy.SessionCookie.Name != "" {
+		opts.Name = policy.SessionCookie.Name
+	}
+	if policy.SessionCookie.Domain != "" {
+		opts.Domain = policy.SessionCookie.Domain
+	}
+	if policy.SessionCookie.Path != "" {
+		opts.Path = policy.SessionCookie.Path
+	}
+	if policy.SessionCookie.SameSite != "" {
+		opts.SameSite = (&Options{CookieSameSite: policy.SessionCookie.SameSite}).GetCookieSameSite()
+	}
+	return opts
+}
+
+// NewSessionCookieOptionsGetter returns a getter for the session cookie options of a request,
+// using the overrides of the first policy which matches the request.
+func NewSessionCookieOptionsGetter(options *Options) cookie.GetRequestOptionsFunc {
+	defaults := options.GetSessionCookieOptionsForPolicy(nil)
+
+	var routes *RouteMatcher
+	for _, p := range options.GetAllPolicies() {
+		if p.SessionCookie != nil {
+			routes = NewRouteMatcher(options.GetAllPolicies())
+			break
+		}
+	}
+	if routes == nil {
+		return func(_ *http.Request) cookie.Options { return defaults }
+	}
+
+	return func(r *http.Request) cookie.Options {
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = r.Host
+		if p := routes.Match(u); p != nil {
+			return options.GetSessionCookieOptionsForPolicy(p)
+		}
+		return defaults
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicySessionCookie_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		cookie PolicySessionCookie
+		expect bool
+	}{
+		{"empty", PolicySessionCookie{}, true},
+		{"valid", PolicySessionCookie{Name: "_app", Domain: "app.example.com", Path: "/app", SameSite: "strict"}, true},
+		{"invalid name", PolicySessionCookie{Name: "a;b"}, false},
+		{"invalid domain", PolicySessionCookie{Domain: "app example.com"}, false},
+		{"invalid path", PolicySessionCookie{Path: "app"}, false},
+		{"invalid same site", PolicySessionCookie{SameSite: "sometimes"}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.cookie.Validate()
+			if tc.expect {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestNewSessionCookieOptionsGetter(t *testing.T) {
+	t.Parallel()
+
+	options := NewDefaultOptions()
+	options.CookieDomain = "example.com"
+	options.Policies = []Policy{
+		{From: "https://admin.example.com", SessionCookie: &PolicySessionCookie{Name: "_admin", Domain: "admin.example.com", SameSite: "strict"}},
+		{From: "https://www.example.com"},
+	}
+	getOptions := NewSessionCookieOptionsGetter(options)
+
+	opts := getOptions(httptest.NewRequest(http.MethodGet, "https://admin.example.com/", nil))
+	assert.Equal(t, "_admin", opts.Name)
+	assert.Equal(t, "admin.example.com", opts.Domain)
+	assert.Equal(t, http.SameSiteStrictMode, opts.SameSite)
+	assert.Equal(t, options.CookieExpire, opts.Expire)
+
+	opts = getOptions(httptest.NewRequest(http.MethodGet, "https://www.example.com/", nil))
+	assert.Equal(t, options.CookieName, opts.Name)
+	assert.Equal(t, "example.com", opts.Domain)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+// DefaultDataBrokerShardingHashedRecordTypes are the record types hashed across the shards if none
+// are configured.
+var DefaultDataBrokerShardingHashedRecordTypes = []string{
+	grpcutil.GetTypeURL(new(session.Session)),
+}
+
+// DataBrokerShardingSettings configure spreading databroker records across multiple storage
+// backends of the databroker storage type, so that a single backend doesn't limit the number of
+// writes.
+type DataBrokerShardingSettings struct {
+	// ConnectionStrings are the connection strings of the additional shards. The first shard is
+	// the databroker storage connection string. Sharding is disabled if no connection strings are
+	// set.
+	ConnectionStrings []string `mapstructure:"connection_strings" yaml:"connection_strings,omitempty"`
+	// RecordTypeShards assign record types to shards by index. Record types which aren't assigned
+	// or hashed are stored in the first shard.
+	RecordTypeShards map[string]int `mapstructure:"record_type_shards" yaml:"record_type_shards,omitempty"`
+	// HashedRecordTypes are spread across all the shards by the hash of the record id. Defaults to
+	// sessions.
+	HashedRecordTypes []string `mapstructure:"hashed_record_types" yaml:"hashed_record_types,omitempty"`
+	// ChangeLogSize is the number of changes kept for clients resuming a sync. Clients which fall
+	// further behind sync the latest records again. Defaults to 10000.
+	ChangeLogSize int `mapstructure:"change_log_size" yaml:"change_log_size,omitempty"`
+}
+
+// IsEnabled returns true if databroker sharding is enabled.
+func (s *DataBrokerShardingSettings) IsEnabled() bool {
+	return len(s.ConnectionStrings) > 0
+}
+
+// GetShardCount returns the number of shards, including the first shard.
+func (s *DataBrokerShardingSettings) GetShardCount() int {
+	return 1 + len(s.ConnectionStrings)
+}
+
+// GetHashedRecordTypes returns the hashed record types, or the default record types if none are set.
+func (s *DataBrokerShardingSettings) GetHashedRecordTypes() []string {
+	if len(s.HashedRecordTypes) == 0 {
+		return DefaultDataBrokerShardingHashedRecordTypes
+	}
+	return s.HashedRecordTypes
+}
+
+// GetChangeLogSize returns the change log size, or the default size if none is set.
+func (s *DataBrokerShardingSettings) GetChangeLogSize() int {
+	if s.ChangeLogSize <= 0 {
+		return storage.DefaultShardedChangeLogSize
+	}
+	return s.ChangeLogSize
+}
+
+// Validate validates the databroker sharding settings.
+func (s *DataBrokerShardingSettings) Validate() error {
+	if s.ChangeLogSize < 0 {
+		return fmt.Errorf("config: databroker_sharding change_log_size must not be negative")
+	}
+	for _, connectionString := range s.ConnectionStrings {
+		if connectionString == "" {
+			return fmt.Errorf("config: databroker_sharding connection_strings must not be empty")
+		}
+	}
+
+	hashed := map[string]struct{}{}
+	for _, recordType := range s.GetHashedRecordTypes() {
+		hashed[recordType] = struct{}{}
+	}
+	for recordType, shard := range s.RecordTypeShards {
+		if shard < 0 || shard >= s.GetShardCount() {
+			return fmt.Errorf("config: invalid databroker_sharding shard for %s: %d", recordType, shard)
+		}
+		if _, ok := hashed[recordType]; ok {
+			return fmt.Errorf("config: databroker_sharding record type %s is both assigned to a shard and hashed", recordType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataBrokerShardingSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings DataBrokerShardingSettings
+		valid    bool
+	}{
+		{"disabled", DataBrokerShardingSettings{}, true},
+		{"valid", DataBrokerShardingSettings{
+			ConnectionStrings: []string{"postgres://shard-1", "postgres://shard-2"},
+			RecordTypeShards:  map[string]int{"pomerium.io/DirectoryUser": 2},
+		}, true},
+		{"empty connection string", DataBrokerShardingSettings{
+			ConnectionStrings: []string{""},
+		}, false},
+		{"invalid shard", DataBrokerShardingSettings{
+			ConnectionStrings: []string{"postgres://shard-1"},
+			RecordTypeShards:  map[string]int{"pomerium.io/DirectoryUser": 2},
+		}, false},
+		{"assigned and hashed", DataBrokerShardingSettings{
+			ConnectionStrings: []string{"postgres://shard-1"},
+			RecordTypeShards:  map[string]int{"TYPE": 1},
+			HashedRecordTypes: []string{"TYPE"},
+		}, false},
+		{"negative change log size", DataBrokerShardingSettings{ChangeLogSize: -1}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultSLOWindow is the rolling window of a route's error budget if none is set.
+const DefaultSLOWindow = 24 * time.Hour
+
+// PolicySLO sets the service level objectives of a route. The error budgets of the objectives
+// are reported by the /metrics/slo endpoint of the metrics listener.
+//
+// Only authorized requests count towards the objectives, so denied requests don't use up the
+// error budget.
+type PolicySLO struct {
+	// Availability is the target fraction of requests which don't fail with a 5xx response,
+	// such as 0.999.
+	Availability float64 `mapstructure:"availability" yaml:"availability,omitempty" json:"availability,omitempty"`
+	// LatencyThreshold is the duration within which a request must complete to count as fast.
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold" yaml:"latency_threshold,omitempty" json:"latency_threshold,omitempty"`
+	// LatencyTarget is the target fraction of requests which complete within the latency
+	// threshold, such as 0.99.
+	LatencyTarget float64 `mapstructure:"latency_target" yaml:"latency_target,omitempty" json:"latency_target,omitempty"`
+	// Window is the rolling window the error budget is computed over. Defaults to 24 hours.
+	// Budgets are kept in memory, so they are reset when pomerium restarts.
+	Window time.Duration `mapstructure:"window" yaml:"window,omitempty" json:"window,omitempty"`
+}
+
+// GetWindow returns the window, or the default window if none is set.
+func (slo *PolicySLO) GetWindow() time.Duration {
+	if slo.Window == 0 {
+		return DefaultSLOWindow
+	}
+	return slo.Window
+}
+
+// Validate validates the service level objectives.
+func (slo *PolicySLO) Validate() error {
+	if slo.Availability == 0 && slo.LatencyTarget == 0 {
+		return fmt.Errorf("availability or latency_target is required")
+	}
+	if slo.Availability < 0 || slo.Availability >= 1 {
+		return fmt.Errorf("availability must be between 0 and 1")
+	}
+	if slo.LatencyTarget < 0 || slo.LatencyTarget >= 1 {
+		return fmt.Errorf("latency_target must be between 0 and 1")
+	}
+	if (slo.LatencyTarget == 0) != (slo.LatencyThreshold == 0) {
+		return fmt.Errorf("latency_target and latency_threshold must be set together")
+	}
+	if slo.LatencyThreshold < 0 {
+		return fmt.Errorf("latency_threshold must not be negative")
+	}
+	if slo.Window != 0 && slo.Window < time.Minute {
+		return fmt.Errorf("window must be at least one minute")
+	}
+	return nil
+}
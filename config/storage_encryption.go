@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+)
+
+// Storage encryption key providers.
+const (
+	StorageEncryptionProviderAWSKMS = "aws_kms"
+	StorageEncryptionProviderLocal  = "local"
+)
+
+// DefaultStorageEncryptionDataKeyRotationInterval is how often a new data key is generated if no
+// interval is set.
+const DefaultStorageEncryptionDataKeyRotationInterval = 24 * time.Hour
+
+// DefaultStorageEncryptionRecordTypes are the record types encrypted if none are configured.
+var DefaultStorageEncryptionRecordTypes = []string{
+	grpcutil.GetTypeURL(new(session.Session)),
+	grpcutil.GetTypeURL(new(user.User)),
+	grpcutil.GetTypeURL(new(user.ServiceAccount)),
+}
+
+// DataBrokerStorageEncryptionSettings configure envelope encryption of databroker records at rest.
+// Record payloads are encrypted with a data key, which is stored alongside them wrapped by a key
+// encryption key, so a dump of the storage backend alone doesn't leak identity data.
+type DataBrokerStorageEncryptionSettings struct {
+	// Provider wraps the data keys, either aws_kms or local. Encryption is disabled if no provider
+	// is set.
+	Provider string `mapstructure:"provider" yaml:"provider,omitempty"`
+	// KeyIDs are the AWS KMS key ids or ARNs. The first key wraps new data keys, the others are
+	// used to unwrap data keys wrapped before a key rotation.
+	KeyIDs []string `mapstructure:"key_ids" yaml:"key_ids,omitempty"`
+	// Region is the AWS region. Defaults to the region of the AWS environment.
+	Region string `mapstructure:"region" yaml:"region,omitempty"`
+	// Keys are base64 encoded Curve25519 private keys used by the local provider. The first key
+	// wraps new data keys, the others are used to unwrap data keys wrapped before a key rotation.
+	Keys []string `mapstructure:"keys" yaml:"keys,omitempty"`
+	// RecordTypes are the record types to encrypt. Defaults to sessions, users and service accounts.
+	RecordTypes []string `mapstructure:"record_types" yaml:"record_types,omitempty"`
+	// DataKeyRotationInterval is how often a new data key is generated. Defaults to 24 hours.
+	DataKeyRotationInterval time.Duration `mapstructure:"data_key_rotation_interval" yaml:"data_key_rotation_interval,omitempty"`
+}
+
+// IsEnabled returns true if databroker storage encryption is enabled.
+func (s *DataBrokerStorageEncryptionSettings) IsEnabled() bool {
+	return s.Provider != ""
+}
+
+// GetRecordTypes returns the record types to encrypt, or the default record types if none are set.
+func (s *DataBrokerStorageEncryptionSettings) GetRecordTypes() []string {
+	if len(s.RecordTypes) == 0 {
+		return DefaultStorageEncryptionRecordTypes
+	}
+	return s.RecordTypes
+}
+
+// GetDataKeyRotationInterval returns the data key rotation interval, or the default interval if
+// none is set.
+func (s *DataBrokerStorageEncryptionSettings) GetDataKeyRotationInterval() time.Duration {
+	if s.DataKeyRotationInterval <= 0 {
+		return DefaultStorageEncryptionDataKeyRotationInterval
+	}
+	return s.DataKeyRotationInterval
+}
+
+// GetKeys returns the decoded keys of the local provider.
+func (s *DataBrokerStorageEncryptionSettings) GetKeys() ([]*cryptutil.PrivateKeyEncryptionKey, error) {
+	keys := make([]*cryptutil.PrivateKeyEncryptionKey, 0, len(s.Keys))
+	for _, raw := range s.Keys {
+		bs, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid databroker_storage_encryption key: %w", err)
+		}
+		key, err := cryptutil.NewPrivateKeyEncryptionKey(bs)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid databroker_storage_encryption key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Validate validates the databroker storage encryption settings.
+func (s *DataBrokerStorageEncryptionSettings) Validate() error {
+	if s.DataKeyRotationInterval < 0 {
+		return fmt.Errorf("config: databroker_storage_encryption data_key_rotation_interval must not be negative")
+	}
+
+	switch s.Provider {
+	case "":
+	case StorageEncryptionProviderAWSKMS:
+		if len(s.KeyIDs) == 0 {
+			return fmt.Errorf("config: databroker_storage_encryption with aws_kms requires key_ids")
+		}
+	case StorageEncryptionProviderLocal:
+		if len(s.Keys) == 0 {
+			return fmt.Errorf("config: databroker_storage_encryption with local keys requires keys")
+		}
+		if _, err := s.GetKeys(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("config: unknown databroker_storage_encryption provider: %s", s.Provider)
+	}
+	return nil
+}
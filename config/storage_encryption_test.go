@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestDataBrokerStorageEncryptionSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	kek, err := cryptutil.GenerateKeyEncryptionKey()
+	if !assert.NoError(t, err) {
+		return
+	}
+	key := base64.StdEncoding.EncodeToString(kek.KeyBytes())
+
+	for _, tc := range []struct {
+		name     string
+		settings DataBrokerStorageEncryptionSettings
+		valid    bool
+	}{
+		{"disabled", DataBrokerStorageEncryptionSettings{}, true},
+		{"aws kms", DataBrokerStorageEncryptionSettings{
+			Provider: StorageEncryptionProviderAWSKMS,
+			KeyIDs:   []string{"arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"},
+		}, true},
+		{"aws kms without keys", DataBrokerStorageEncryptionSettings{
+			Provider: StorageEncryptionProviderAWSKMS,
+		}, false},
+		{"local", DataBrokerStorageEncryptionSettings{
+			Provider: StorageEncryptionProviderLocal,
+			Keys:     []string{key},
+		}, true},
+		{"local with invalid key", DataBrokerStorageEncryptionSettings{
+			Provider: StorageEncryptionProviderLocal,
+			Keys:     []string{base64.StdEncoding.EncodeToString([]byte("short"))},
+		}, false},
+		{"unknown provider", DataBrokerStorageEncryptionSettings{Provider: "vault"}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
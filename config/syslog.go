@@ -0,0 +1,72 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/syslog"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// SyslogSettings configure writing to syslog. Remote syslog servers receive messages in the
+// RFC 5424 format.
+type SyslogSettings struct {
+	// Network and Address are the syslog server to connect to, such as tls and
+	// syslog.example.com:6514. The network is udp, tcp or tls. Defaults to the local syslog server.
+	Network string `mapstructure:"network" yaml:"network,omitempty"`
+	Address string `mapstructure:"address" yaml:"address,omitempty"`
+	// Tag is the syslog app name. Defaults to pomerium.
+	Tag string `mapstructure:"tag" yaml:"tag,omitempty"`
+	// Format is the format of the messages: json, logfmt, cef or leef. Audit log entries default
+	// to json and access logs to the access_log_format.
+	Format log.Format `mapstructure:"format" yaml:"format,omitempty"`
+	// CA and CAFile are the certificate authorities used to verify a tls syslog server. Defaults
+	// to the system roots.
+	CA     string `mapstructure:"ca" yaml:"ca,omitempty"`
+	CAFile string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+}
+
+// Validate validates the syslog settings.
+func (s *SyslogSettings) Validate() error {
+	if err := syslog.ValidateNetwork(s.Network); err != nil {
+		return err
+	}
+	if (s.Network == "") != (s.Address == "") {
+		return fmt.Errorf("network and address must be set together")
+	}
+	if err := s.Format.Validate(); err != nil {
+		return err
+	}
+	if s.CA != "" || s.CAFile != "" {
+		if s.Network != "tls" {
+			return fmt.Errorf("ca and ca_file require the tls network")
+		}
+		if _, err := cryptutil.GetCertPool(s.CA, s.CAFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewWriter returns a writer which writes each Write as a syslog message.
+func (s *SyslogSettings) NewWriter(facility syslog.Facility) (io.WriteCloser, error) {
+	opts := syslog.Options{
+		Network:  s.Network,
+		Address:  s.Address,
+		AppName:  s.Tag,
+		Facility: facility,
+	}
+	if s.Network == "tls" {
+		rootCAs, err := cryptutil.GetCertPool(s.CA, s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid syslog ca: %w", err)
+		}
+		opts.TLSConfig = &tls.Config{
+			RootCAs:    rootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return syslog.Dial(opts)
+}
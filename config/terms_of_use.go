@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TermsOfUseSettings configure a terms of use page users must accept while signing in, before
+// their session is issued. Acceptance is stored per user and users are asked again whenever the
+// version changes.
+type TermsOfUseSettings struct {
+	// Version identifies the current terms. The page is disabled if no version is set.
+	Version string `mapstructure:"version" yaml:"version,omitempty"`
+	// URL links to the full terms.
+	URL string `mapstructure:"url" yaml:"url,omitempty"`
+
+	TermsOfUseText `mapstructure:",squash" yaml:",inline"`
+
+	// Translations are localized versions of the text keyed by language tag, such as de or
+	// pt-br. The translation is picked using the Accept-Language header of the browser.
+	Translations map[string]TermsOfUseText `mapstructure:"translations" yaml:"translations,omitempty"`
+}
+
+// TermsOfUseText is the text of the terms of use page.
+type TermsOfUseText struct {
+	Title       string `mapstructure:"title" yaml:"title,omitempty"`
+	Text        string `mapstructure:"text" yaml:"text,omitempty"`
+	AcceptLabel string `mapstructure:"accept_label" yaml:"accept_label,omitempty"`
+}
+
+// defaultTermsOfUseText are the default titles and accept labels of the terms of use page.
+var defaultTermsOfUseText = map[string]TermsOfUseText{
+	"en": {Title: "Terms of Use", AcceptLabel: "Accept"},
+	"de": {Title: "Nutzungsbedingungen", AcceptLabel: "Akzeptieren"},
+	"es": {Title: "Términos de uso", AcceptLabel: "Aceptar"},
+	"fr": {Title: "Conditions d'utilisation", AcceptLabel: "Accepter"},
+	"ja": {Title: "利用規約", AcceptLabel: "同意する"},
+}
+
+// IsEnabled returns true if users must accept the terms of use.
+func (s *TermsOfUseSettings) IsEnabled() bool {
+	return s.Version != ""
+}
+
+// Validate validates the terms of use settings.
+func (s *TermsOfUseSettings) Validate() error {
+	if !s.IsEnabled() {
+		return nil
+	}
+	if s.Text == "" && s.URL == "" {
+		return fmt.Errorf("config: terms_of_use requires text or a url")
+	}
+	if s.URL != "" {
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("config: invalid terms_of_use url: %q", s.URL)
+		}
+	}
+	for tag := range s.Translations {
+		if tag == "" || tag != strings.ToLower(tag) {
+			return fmt.Errorf("config: invalid terms_of_use translation language, must be a lowercase language tag: %q", tag)
+		}
+	}
+	return nil
+}
+
+// GetLocalizedText returns the text of the terms of use in the first of the languages which has a
+// translation. Anything missing from the translation is filled in with the default labels of the
+// language, then with the untranslated text.
+func (s *TermsOfUseSettings) GetLocalizedText(languages []string) TermsOfUseText {
+	text := s.TermsOfUseText
+	for _, language := range getLanguageFallbacks(languages) {
+		if translation, ok := s.Translations[language]; ok {
+			text = mergeTermsOfUseText(mergeTermsOfUseText(translation, defaultTermsOfUseText[language]), text)
+			break
+		}
+	}
+	return mergeTermsOfUseText(text, defaultTermsOfUseText["en"])
+}
+
+// getLanguageFallbacks returns the languages followed by their base language, so de-ch matches a
+// de translation.
+func getLanguageFallbacks(languages []string) []string {
+	var fallbacks []string
+	for _, language := range languages {
+		fallbacks = append(fallbacks, language)
+		if base, _, ok := strings.Cut(language, "-"); ok {
+			fallbacks = append(fallbacks, base)
+		}
+	}
+	return fallbacks
+}
+
+func mergeTermsOfUseText(text, defaults TermsOfUseText) TermsOfUseText {
+	if text.Title == "" {
+		text.Title = defaults.Title
+	}
+	if text.Text == "" {
+		text.Text = defaults.Text
+	}
+	if text.AcceptLabel == "" {
+		text.AcceptLabel = defaults.AcceptLabel
+	}
+	return text
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTermsOfUseSettings_GetLocalizedText(t *testing.T) {
+	t.Parallel()
+
+	s := &TermsOfUseSettings{
+		Version:        "1",
+		TermsOfUseText: TermsOfUseText{Text: "Be nice."},
+		Translations: map[string]TermsOfUseText{
+			"de":    {Text: "Sei nett."},
+			"pt-br": {Title: "Termos", Text: "Seja legal.", AcceptLabel: "Aceito"},
+		},
+	}
+	assert.Equal(t, TermsOfUseText{Title: "Terms of Use", Text: "Be nice.", AcceptLabel: "Accept"},
+		s.GetLocalizedText(nil))
+	assert.Equal(t, TermsOfUseText{Title: "Nutzungsbedingungen", Text: "Sei nett.", AcceptLabel: "Akzeptieren"},
+		s.GetLocalizedText([]string{"de-ch", "en"}))
+	assert.Equal(t, TermsOfUseText{Title: "Termos", Text: "Seja legal.", AcceptLabel: "Aceito"},
+		s.GetLocalizedText([]string{"pt-br"}))
+	assert.Equal(t, TermsOfUseText{Title: "Terms of Use", Text: "Be nice.", AcceptLabel: "Accept"},
+		s.GetLocalizedText([]string{"fr"}), "should fall back to the untranslated text")
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/ui"
+)
+
+// ThemeSettings customize the user facing pages, such as the error, sign out and user info pages.
+type ThemeSettings struct {
+	PrimaryColor           string `mapstructure:"primary_color" yaml:"primary_color,omitempty"`
+	SecondaryColor         string `mapstructure:"secondary_color" yaml:"secondary_color,omitempty"`
+	DarkmodePrimaryColor   string `mapstructure:"darkmode_primary_color" yaml:"darkmode_primary_color,omitempty"`
+	DarkmodeSecondaryColor string `mapstructure:"darkmode_secondary_color" yaml:"darkmode_secondary_color,omitempty"`
+	LogoURL                string `mapstructure:"logo_url" yaml:"logo_url,omitempty"`
+	FaviconURL             string `mapstructure:"favicon_url" yaml:"favicon_url,omitempty"`
+	// ErrorMessageFirstParagraph replaces the first paragraph of the error page.
+	ErrorMessageFirstParagraph string `mapstructure:"error_message_first_paragraph" yaml:"error_message_first_paragraph,omitempty"`
+	// FooterLinks replace the default links in the page footer.
+	FooterLinks []ThemeFooterLink `mapstructure:"footer_links" yaml:"footer_links,omitempty"`
+	// TemplatesDirectory contains custom html/template pages which replace the default pages. See
+	// ui.LoadCustomTemplates. Changes to the templates are reloaded automatically.
+	TemplatesDirectory string `mapstructure:"templates_directory" yaml:"templates_directory,omitempty"`
+}
+
+// A ThemeFooterLink is a link shown in the page footer.
+type ThemeFooterLink struct {
+	Text string `mapstructure:"text" yaml:"text"`
+	URL  string `mapstructure:"url" yaml:"url"`
+}
+
+var _ httputil.BrandingOptions = (*ThemeSettings)(nil)
+
+// GetPrimaryColor returns the primary color.
+func (t *ThemeSettings) GetPrimaryColor() string { return t.PrimaryColor }
+
+// GetSecondaryColor returns the secondary color.
+func (t *ThemeSettings) GetSecondaryColor() string { return t.SecondaryColor }
+
+// GetDarkmodePrimaryColor returns the dark mode primary color.
+func (t *ThemeSettings) GetDarkmodePrimaryColor() string { return t.DarkmodePrimaryColor }
+
+// GetDarkmodeSecondaryColor returns the dark mode secondary color.
+func (t *ThemeSettings) GetDarkmodeSecondaryColor() string { return t.DarkmodeSecondaryColor }
+
+// GetLogoUrl returns the logo url.
+func (t *ThemeSettings) GetLogoUrl() string { return t.LogoURL } //nolint:revive,stylecheck
+
+// GetFaviconUrl returns the favicon url.
+func (t *ThemeSettings) GetFaviconUrl() string { return t.FaviconURL } //nolint:revive,stylecheck
+
+// GetErrorMessageFirstParagraph returns the first paragraph of the error page.
+func (t *ThemeSettings) GetErrorMessageFirstParagraph() string { return t.ErrorMessageFirstParagraph }
+
+// GetFooterLinks returns the footer links.
+func (t *ThemeSettings) GetFooterLinks() []httputil.FooterLink {
+	var links []httputil.FooterLink
+	for _, l := range t.FooterLinks {
+		links = append(links, httputil.FooterLink{Text: l.Text, URL: l.URL})
+	}
+	return links
+}
+
+// Validate validates the theme settings.
+func (t *ThemeSettings) Validate() error {
+	for _, l := range t.FooterLinks {
+		if l.Text == "" {
+			return fmt.Errorf("config: theme footer_links text must not be empty")
+		}
+		if _, err := url.Parse(l.URL); err != nil || l.URL == "" {
+			return fmt.Errorf("config: invalid theme footer_links url: %s", l.URL)
+		}
+	}
+	return nil
+}
+
+// The ThemeManager loads the custom page templates based on options.
+type ThemeManager struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewThemeManager creates a new ThemeManager.
+func NewThemeManager(ctx context.Context, src Source) *ThemeManager {
+	mgr := &ThemeManager{}
+	src.OnConfigChange(ctx, mgr.OnConfigChange)
+	mgr.OnConfigChange(ctx, src.GetConfig())
+	return mgr
+}
+
+// Close closes the theme manager.
+func (mgr *ThemeManager) Close() error {
+	return nil
+}
+
+// OnConfigChange is called whenever configuration changes, including when a template file changes.
+func (mgr *ThemeManager) OnConfigChange(ctx context.Context, cfg *Config) {
+	if cfg == nil || cfg.Options == nil {
+		return
+	}
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	dir := cfg.Options.Theme.TemplatesDirectory
+	if dir == "" {
+		mgr.dir = ""
+		ui.SetCustomTemplates(nil)
+		return
+	}
+
+	t, err := ui.LoadCustomTemplates(dir)
+	if err != nil {
+		log.Error(ctx).Err(err).Str("directory", dir).Msg("config: failed to load custom templates")
+		// keep serving the previous templates while they're being edited
+		if mgr.dir != dir {
+			mgr.dir = ""
+			ui.SetCustomTemplates(nil)
+		}
+		return
+	}
+	mgr.dir = dir
+	ui.SetCustomTemplates(t)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/ui"
+)
+
+func TestThemeSettings(t *testing.T) {
+	t.Parallel()
+
+	theme := &ThemeSettings{
+		PrimaryColor: "#000000",
+		FooterLinks:  []ThemeFooterLink{{Text: "Help", URL: "https://help.example.com"}},
+	}
+	assert.NoError(t, theme.Validate())
+
+	m := map[string]any{}
+	httputil.AddBrandingOptionsToMap(m, theme)
+	assert.Equal(t, map[string]any{
+		"primaryColor": "#000000",
+		"footerLinks":  []httputil.FooterLink{{Text: "Help", URL: "https://help.example.com"}},
+	}, m)
+
+	assert.Error(t, (&ThemeSettings{FooterLinks: []ThemeFooterLink{{URL: "https://help.example.com"}}}).Validate())
+	assert.Error(t, (&ThemeSettings{FooterLinks: []ThemeFooterLink{{Text: "Help"}}}).Validate())
+}
+
+func TestThemeManager(t *testing.T) {
+	t.Cleanup(func() { ui.SetCustomTemplates(nil) })
+
+	dir := t.TempDir()
+	writeTemplate := func(contents string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "Error.html"), []byte(contents), 0o600))
+	}
+	render := func() string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.com", nil)
+		require.NoError(t, ui.ServePage(w, r, "Error", map[string]any{"status": 403}))
+		return w.Body.String()
+	}
+
+	writeTemplate(`<h1>{{.status}} custom</h1>`)
+	ctx := context.Background()
+	mgr := NewThemeManager(ctx, NewStaticSource(&Config{Options: &Options{
+		Theme: ThemeSettings{TemplatesDirectory: dir},
+	}}))
+	assert.Equal(t, "<h1>403 custom</h1>", render())
+
+	t.Run("reload", func(t *testing.T) {
+		writeTemplate(`<h1>{{.status}} reloaded</h1>`)
+		mgr.OnConfigChange(ctx, &Config{Options: &Options{Theme: ThemeSettings{TemplatesDirectory: dir}}})
+		assert.Equal(t, "<h1>403 reloaded</h1>", render())
+	})
+	t.Run("keeps previous templates on error", func(t *testing.T) {
+		writeTemplate(`<h1>{{.status</h1>`)
+		mgr.OnConfigChange(ctx, &Config{Options: &Options{Theme: ThemeSettings{TemplatesDirectory: dir}}})
+		assert.Equal(t, "<h1>403 reloaded</h1>", render())
+	})
+	t.Run("removed", func(t *testing.T) {
+		mgr.OnConfigChange(ctx, &Config{Options: &Options{}})
+		assert.NotContains(t, render(), "reloaded")
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry"
+	"github.com/pomerium/pomerium/internal/telemetry/otlp"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
@@ -38,6 +39,16 @@ func NewTracingOptions(o *Options) (*TracingOptions, error) {
 			tracingOpts.JaegerCollectorEndpoint = jaegerCollectorEndpoint
 			tracingOpts.JaegerAgentEndpoint = o.TracingJaegerAgentEndpoint
 		}
+	case trace.OTLPTracingProviderName:
+		var err error
+		tracingOpts.OTLPProtocol, err = otlp.GetProtocol(otlp.SignalTraces)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid otlp tracing config: %w", err)
+		}
+		tracingOpts.OTLPEndpoint, err = otlp.GetEndpoint(otlp.SignalTraces)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid otlp tracing config: %w", err)
+		}
 	case trace.ZipkinTracingProviderName:
 		zipkinEndpoint, err := urlutil.ParseAndValidateURL(o.ZipkinEndpoint)
 		if err != nil {
@@ -110,6 +121,11 @@ func (mgr *TraceManager) OnConfigChange(ctx context.Context, cfg *Config) {
 	}
 
 	log.Info(ctx).Interface("options", traceOpts).Msg("trace: starting exporter")
+	switch traceOpts.Provider {
+	case trace.JaegerTracingProviderName, trace.ZipkinTracingProviderName:
+		log.Warn(ctx).Str("provider", traceOpts.Provider).
+			Msg("trace: this tracing provider is deprecated, use the otlp tracing provider instead")
+	}
 
 	mgr.provider, err = trace.GetProvider(traceOpts)
 	if err != nil {
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/derivecert"
+)
+
+// DefaultUpstreamMTLSCertificateLifetime is the lifetime of issued upstream client certificates
+// if none is set.
+const DefaultUpstreamMTLSCertificateLifetime = 24 * time.Hour
+
+// UpstreamMTLSSettings configure the internal certificate authority which issues the client
+// certificates presented to the upstreams of routes with tls_issue_client_cert set. Issued
+// certificates are short-lived and rotated automatically, so upstreams can require mTLS from
+// pomerium by trusting the certificate authority.
+type UpstreamMTLSSettings struct {
+	// CA and CAKey are the base64-encoded certificate and private key of the certificate
+	// authority.
+	CA    string `mapstructure:"ca" yaml:"ca,omitempty"`
+	CAKey string `mapstructure:"ca_key" yaml:"ca_key,omitempty"`
+	// CAFile and CAKeyFile are the paths to the certificate and private key of the certificate
+	// authority.
+	CAFile    string `mapstructure:"ca_file" yaml:"ca_file,omitempty"`
+	CAKeyFile string `mapstructure:"ca_key_file" yaml:"ca_key_file,omitempty"`
+	// CertificateLifetime is how long issued certificates are valid. Certificates are re-issued
+	// after two thirds of their lifetime. Defaults to 24 hours.
+	CertificateLifetime time.Duration `mapstructure:"certificate_lifetime" yaml:"certificate_lifetime,omitempty"`
+}
+
+// GetCertificateLifetime returns the certificate lifetime, or the default lifetime if none is set.
+func (s *UpstreamMTLSSettings) GetCertificateLifetime() time.Duration {
+	if s.CertificateLifetime <= 0 {
+		return DefaultUpstreamMTLSCertificateLifetime
+	}
+	return s.CertificateLifetime
+}
+
+// HasCA returns true if a certificate authority is configured.
+func (s *UpstreamMTLSSettings) HasCA() bool {
+	return (s.CA != "" && s.CAKey != "") || (s.CAFile != "" && s.CAKeyFile != "")
+}
+
+// GetCA returns the certificate authority which issues upstream client certificates. If no
+// certificate authority is configured, the certificate authority derived from the shared secret
+// is returned, so upstreams can trust the same CA as the one used by tls_derive. Callers should
+// warn about the derived certificate authority, as anyone with the shared secret can use it to
+// issue client certificates.
+func (s *UpstreamMTLSSettings) GetCA(sharedKey []byte) (*tls.Certificate, error) {
+	var ca *tls.Certificate
+	var err error
+	switch {
+	case s.CA != "" && s.CAKey != "":
+		ca, err = cryptutil.CertificateFromBase64(s.CA, s.CAKey)
+	case s.CAFile != "" && s.CAKeyFile != "":
+		ca, err = cryptutil.CertificateFromFile(s.CAFile, s.CAKeyFile)
+	default:
+		var derived *derivecert.CA
+		derived, err = derivecert.NewCA(sharedKey)
+		if err != nil {
+			return nil, fmt.Errorf("derive certificate authority: %w", err)
+		}
+		var p *derivecert.PEM
+		p, err = derived.PEM()
+		if err != nil {
+			return nil, fmt.Errorf("encode derived certificate authority: %w", err)
+		}
+		var cert tls.Certificate
+		cert, err = p.TLS()
+		ca = &cert
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate authority: %w", err)
+	}
+	if !ca.Leaf.IsCA {
+		return nil, fmt.Errorf("certificate is not a certificate authority")
+	}
+	return ca, nil
+}
+
+// Validate validates the upstream mTLS settings.
+func (s *UpstreamMTLSSettings) Validate() error {
+	if (s.CA == "") != (s.CAKey == "") {
+		return fmt.Errorf("config: upstream_mtls ca and ca_key must be set together")
+	}
+	if (s.CAFile == "") != (s.CAKeyFile == "") {
+		return fmt.Errorf("config: upstream_mtls ca_file and ca_key_file must be set together")
+	}
+	if s.CA != "" && s.CAFile != "" {
+		return fmt.Errorf("config: upstream_mtls ca and ca_file are mutually exclusive")
+	}
+	if s.CertificateLifetime < 0 {
+		return fmt.Errorf("config: upstream_mtls certificate_lifetime must not be negative")
+	}
+	if s.CertificateLifetime != 0 && s.CertificateLifetime < 5*time.Minute {
+		return fmt.Errorf("config: upstream_mtls certificate_lifetime must be at least 5 minutes")
+	}
+	if s.CA != "" || s.CAFile != "" {
+		if _, err := s.GetCA(nil); err != nil {
+			return fmt.Errorf("config: invalid upstream_mtls ca: %w", err)
+		}
+	}
+	return nil
+}
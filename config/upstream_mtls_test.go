@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamMTLSSettings_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		settings UpstreamMTLSSettings
+		err      string
+	}{
+		{"empty", UpstreamMTLSSettings{}, ""},
+		{"lifetime", UpstreamMTLSSettings{CertificateLifetime: time.Hour}, ""},
+		{"short lifetime", UpstreamMTLSSettings{CertificateLifetime: time.Minute}, "certificate_lifetime must be at least 5 minutes"},
+		{"negative lifetime", UpstreamMTLSSettings{CertificateLifetime: -time.Hour}, "certificate_lifetime must not be negative"},
+		{"ca without key", UpstreamMTLSSettings{CA: "Y2E="}, "ca and ca_key must be set together"},
+		{"ca file without key", UpstreamMTLSSettings{CAFile: "ca.pem"}, "ca_file and ca_key_file must be set together"},
+		{"ca file", UpstreamMTLSSettings{
+			CAFile:    "./testdata/example-cert.pem",
+			CAKeyFile: "./testdata/example-key.pem",
+		}, ""},
+		{"missing ca file", UpstreamMTLSSettings{
+			CAFile:    "./testdata/missing-cert.pem",
+			CAKeyFile: "./testdata/missing-key.pem",
+		}, "invalid upstream_mtls ca"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.settings.Validate()
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestUpstreamMTLSSettings_GetCA(t *testing.T) {
+	t.Parallel()
+
+	ca, err := (&UpstreamMTLSSettings{}).GetCA([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Pomerium PSK CA", ca.Leaf.Subject.CommonName,
+		"should derive the certificate authority from the shared key")
+}
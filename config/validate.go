@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"strconv"
 	"strings"
 
@@ -42,6 +43,37 @@ func ValidateDNSLookupFamily(value string) error {
 	return fmt.Errorf("unknown dns_lookup_family: %s, known families are: %s", value, strings.Join(AllDNSLookupFamilies, ", "))
 }
 
+// ValidateDNSResolver validates the address of a DNS resolver, which must be an IP address with
+// an optional port.
+func ValidateDNSResolver(value string) error {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		host, port = value, "53"
+	}
+	if _, err := netip.ParseAddr(host); err != nil {
+		return fmt.Errorf("invalid dns_resolvers address: %s, expected an IP address", value)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid dns_resolvers port: %s", value)
+	}
+	return nil
+}
+
+// SessionTransport values.
+const (
+	SessionTransportCookie = "cookie"
+	SessionTransportHeader = "header"
+)
+
+// ValidateSessionTransport validates the session transport option.
+func ValidateSessionTransport(value string) error {
+	switch value {
+	case "", SessionTransportCookie, SessionTransportHeader:
+		return nil
+	}
+	return fmt.Errorf("unknown session_transport: %s", value)
+}
+
 // ValidateCookieSameSite validates the cookie same site option.
 func ValidateCookieSameSite(value string) error {
 	value = strings.ToLower(value)
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/open-policy-agent/opa/ast"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pomerium/pomerium/pkg/policy"
+)
+
+// ValidationSeverity is the severity of a ValidationError.
+type ValidationSeverity string
+
+// ValidationSeverity values.
+const (
+	ValidationSeverityError   ValidationSeverity = "error"
+	ValidationSeverityWarning ValidationSeverity = "warning"
+)
+
+// A ValidationError is a single problem found when validating a config file.
+type ValidationError struct {
+	Key      string             `json:"key,omitempty"`
+	Line     int                `json:"line,omitempty"`
+	Column   int                `json:"column,omitempty"`
+	Severity ValidationSeverity `json:"severity"`
+	Message  string             `json:"message"`
+	DocsURL  string             `json:"docs_url,omitempty"`
+}
+
+func (e ValidationError) Error() string {
+	var sb strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&sb, "line %d: ", e.Line)
+	}
+	if e.Key != "" {
+		fmt.Fprintf(&sb, "%s: ", e.Key)
+	}
+	sb.WriteString(e.Message)
+	return sb.String()
+}
+
+// ValidationReport is the result of validating a config file.
+type ValidationReport struct {
+	ConfigFile string            `json:"config_file"`
+	Errors     []ValidationError `json:"errors"`
+}
+
+// Valid returns true if the report contains no errors. Warnings are ignored.
+func (r *ValidationReport) Valid() bool {
+	for _, e := range r.Errors {
+		if e.Severity == ValidationSeverityError {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ValidationReport) add(e ValidationError) {
+	if e.Severity == "" {
+		e.Severity = ValidationSeverityError
+	}
+	r.Errors = append(r.Errors, e)
+}
+
+// ValidateFile runs the full set of configuration checks against a config file without starting
+// any services. Unlike loading the config normally, it keeps going after the first problem so that
+// every invalid route is reported. Problems are located within the file when possible.
+//
+// The returned error is only non-nil if the file could not be read at all.
+func ValidateFile(configFile string) (*ValidationReport, error) {
+	raw, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read config file: %w", err)
+	}
+
+	report := &ValidationReport{ConfigFile: configFile, Errors: []ValidationError{}}
+	positions := newYAMLPositions(raw)
+
+	o := NewDefaultOptions()
+	v := o.viper
+	if err := bindEnvs(v); err != nil {
+		return nil, fmt.Errorf("config: failed to bind options to env vars: %w", err)
+	}
+	v.SetConfigFile(configFile)
+	if err := v.ReadInConfig(); err != nil {
+		report.add(ValidationError{Line: lineFromYAMLError(err), Message: err.Error()})
+		return report, nil
+	}
+
+	var metadata mapstructure.Metadata
+	if err := v.Unmarshal(o, ViperPolicyHooks, func(c *mapstructure.DecoderConfig) { c.Metadata = &metadata }); err != nil {
+		report.add(ValidationError{Message: err.Error()})
+		return report, nil
+	}
+	o.viper = v
+
+	for _, key := range metadata.Unused {
+		for _, check := range CheckUnknownConfigFields([]string{key}) {
+			e := ValidationError{
+				Key:      check.Key,
+				Severity: ValidationSeverityWarning,
+				Message:  string(check.FieldCheckMsg),
+				DocsURL:  check.DocsURL,
+			}
+			if check.KeyAction == KeyActionError {
+				e.Severity = ValidationSeverityError
+			}
+			e.Line, e.Column = positions.lookup(key)
+			report.add(e)
+		}
+	}
+
+	for _, key := range []string{"policy", "routes", "egress_policies"} {
+		var policies []Policy
+		if err := v.UnmarshalKey(key, &policies, ViperPolicyHooks); err != nil {
+			line, col := positions.lookup(key)
+			report.add(ValidationError{Key: key, Line: line, Column: col, Message: err.Error()})
+			continue
+		}
+		for i := range policies {
+			path := fmt.Sprintf("%s[%d]", key, i)
+			line, col := positions.lookup(path)
+			for _, err := range validatePolicyForReport(&policies[i]) {
+				report.add(ValidationError{Key: path, Line: line, Column: col, Message: err.Error()})
+			}
+		}
+	}
+
+	// routes have already been validated individually, so skip them here to avoid reporting
+	// the first broken route a second time
+	v.Set("policy", nil)
+	v.Set("routes", nil)
+	v.Set("egress_policies", nil)
+	o.Policies, o.Routes, o.EgressPolicies = nil, nil, nil
+	if err := o.Validate(); err != nil {
+		report.add(ValidationError{Message: err.Error()})
+	}
+
+	return report, nil
+}
+
+// validatePolicyForReport validates a policy and compiles its authorization rules, returning
+// every error encountered.
+func validatePolicyForReport(p *Policy) []error {
+	if err := p.Validate(); err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if _, err := policy.GenerateRegoFromPolicy(p.ToPPL()); err != nil {
+		errs = append(errs, fmt.Errorf("config: invalid policy: %w", err))
+	}
+	for _, sp := range p.SubPolicies {
+		for _, src := range sp.Rego {
+			if src == "" {
+				continue
+			}
+			// mirror the authorize service, which adds a package declaration when one is missing
+			_, err := ast.ParseModule("pomerium.policy", src)
+			if err != nil && strings.Contains(err.Error(), "package expected") {
+				_, err = ast.ParseModule("pomerium.policy", "package pomerium.policy\n\n"+src)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("config: invalid rego in sub policy %q: %w", sp.Name, err))
+			}
+		}
+	}
+	return errs
+}
+
+var reYAMLErrorLine = regexp.MustCompile(`line (\d+)`)
+
+func lineFromYAMLError(err error) int {
+	m := reYAMLErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
+
+// yamlPositions maps mapstructure key paths (e.g. `routes[1].from`) to positions in a YAML document.
+type yamlPositions struct {
+	root *yaml.Node
+}
+
+func newYAMLPositions(raw []byte) yamlPositions {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(bytes.NewReader(raw)).Decode(&doc); err != nil || len(doc.Content) == 0 {
+		return yamlPositions{}
+	}
+	return yamlPositions{root: doc.Content[0]}
+}
+
+var reKeyPathIndex = regexp.MustCompile(`^([^\[]*)((?:\[\d+\])*)$`)
+
+func (p yamlPositions) lookup(path string) (line, column int) {
+	node := p.root
+	if node == nil {
+		return 0, 0
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		m := reKeyPathIndex.FindStringSubmatch(segment)
+		if m == nil {
+			return node.Line, node.Column
+		}
+
+		if m[1] != "" {
+			next := yamlMappingValue(node, m[1])
+			if next == nil {
+				return node.Line, node.Column
+			}
+			node = next
+		}
+
+		for _, idx := range strings.FieldsFunc(m[2], func(r rune) bool { return r == '[' || r == ']' }) {
+			i, _ := strconv.Atoi(idx)
+			if node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return node.Line, node.Column
+			}
+			node = node.Content[i]
+		}
+	}
+	return node.Line, node.Column
+}
+
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFile(t *testing.T) {
+	t.Parallel()
+
+	writeConfig := func(t *testing.T, contents string) string {
+		t.Helper()
+		fp := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(fp, []byte(contents), 0o600))
+		return fp
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := ValidateFile(writeConfig(t, `
+insecure_server: true
+routes:
+  - from: https://from.example.com
+    to: https://to.example.com
+    allow_any_authenticated_user: true
+`))
+		require.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Empty(t, report.Errors)
+	})
+	t.Run("invalid routes", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := ValidateFile(writeConfig(t, `
+insecure_server: true
+routes:
+  - from: https://from.example.com
+    to: https://to.example.com
+  - from: https://bad.example.com/path
+    to: https://to.example.com
+  - from: https://rego.example.com
+    to: https://to.example.com
+    sub_policies:
+      - name: custom
+        rego: ["allow = {"]
+`))
+		require.NoError(t, err)
+		assert.False(t, report.Valid())
+		require.Len(t, report.Errors, 2)
+		assert.Equal(t, "routes[1]", report.Errors[0].Key)
+		assert.Equal(t, 6, report.Errors[0].Line)
+		assert.Equal(t, "routes[2]", report.Errors[1].Key)
+		assert.Equal(t, 8, report.Errors[1].Line)
+	})
+	t.Run("removed and unknown options", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := ValidateFile(writeConfig(t, `
+insecure_server: true
+idp_qps: 5
+not_a_real_option: true
+`))
+		require.NoError(t, err)
+		assert.False(t, report.Valid())
+		assert.ElementsMatch(t, []ValidationError{
+			{Key: "idp_qps", Line: 3, Column: 10, Severity: ValidationSeverityError, Message: string(FieldCheckMsgRemoved), DocsURL: removedConfigFields["idp_qps"]},
+			{Key: "not_a_real_option", Line: 4, Column: 20, Severity: ValidationSeverityWarning, Message: string(FieldCheckMsgUnknown)},
+		}, report.Errors)
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		t.Parallel()
+
+		report, err := ValidateFile(writeConfig(t, `
+insecure_server: true
+authenticate_service_url: "not a url"
+`))
+		require.NoError(t, err)
+		assert.False(t, report.Valid())
+		require.Len(t, report.Errors, 1)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ValidateFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+)
+
+// WAFMode is the mode a route's web application firewall runs in.
+type WAFMode string
+
+const (
+	// WAFModeBlocking denies requests the web application firewall would interrupt.
+	WAFModeBlocking WAFMode = "blocking"
+	// WAFModeDetection only reports the web application firewall verdict to the policy and the logs.
+	WAFModeDetection WAFMode = "detection"
+)
+
+// WAFSettings configure the web application firewall used by routes with a waf policy.
+type WAFSettings struct {
+	// CRSDirectory is the path to an OWASP Core Rule Set release. Its crs-setup.conf (or
+	// crs-setup.conf.example) and rules/*.conf files are loaded.
+	CRSDirectory string `mapstructure:"crs_directory" yaml:"crs_directory,omitempty"`
+	// Directives are additional Coraza SecLang directives loaded after the core rule set, such as
+	// rule exclusions or custom rules.
+	Directives string `mapstructure:"directives" yaml:"directives,omitempty"`
+}
+
+// IsEmpty returns true if no rules are configured.
+func (s *WAFSettings) IsEmpty() bool {
+	return s.CRSDirectory == "" && s.Directives == ""
+}
+
+// PolicyWAF enables the web application firewall for a route.
+type PolicyWAF struct {
+	// Mode is either blocking or detection. Defaults to blocking.
+	Mode WAFMode `mapstructure:"mode" yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// GetMode returns the mode, or blocking if none is set.
+func (w *PolicyWAF) GetMode() WAFMode {
+	if w.Mode == "" {
+		return WAFModeBlocking
+	}
+	return w.Mode
+}
+
+// Validate validates the waf settings.
+func (w *PolicyWAF) Validate() error {
+	switch w.GetMode() {
+	case WAFModeBlocking, WAFModeDetection:
+	default:
+		return fmt.Errorf("unknown mode: %s", w.Mode)
+	}
+	return nil
+}
@@ -15,10 +15,14 @@ import (
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/cdc"
+	"github.com/pomerium/pomerium/internal/directory"
 	"github.com/pomerium/pomerium/internal/events"
 	"github.com/pomerium/pomerium/internal/identity"
 	"github.com/pomerium/pomerium/internal/identity/manager"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/replication"
+	"github.com/pomerium/pomerium/internal/retention"
 	"github.com/pomerium/pomerium/internal/telemetry"
 	"github.com/pomerium/pomerium/internal/version"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
@@ -31,9 +35,15 @@ import (
 // DataBroker represents the databroker service. The databroker service is a simple interface
 // for storing keyed blobs (bytes) of unstructured data.
 type DataBroker struct {
-	dataBrokerServer *dataBrokerServer
-	manager          *manager.Manager
-	eventsMgr        *events.Manager
+	dataBrokerServer   *dataBrokerServer
+	manager            *manager.Manager
+	directorySyncer    *directory.Syncer
+	replicator         *replication.Replicator
+	replicationConns   []*grpc.ClientConn
+	replicationState   replicationState
+	retentionCollector *retention.Collector
+	cdcPublisher       *cdc.Publisher
+	eventsMgr          *events.Manager
 
 	localListener       net.Listener
 	localGRPCServer     *grpc.Server
@@ -138,6 +148,18 @@ func (c *DataBroker) Run(ctx context.Context) error {
 	eg.Go(func() error {
 		return c.manager.Run(ctx)
 	})
+	eg.Go(func() error {
+		return c.directorySyncer.Run(ctx)
+	})
+	eg.Go(func() error {
+		return c.replicator.Run(ctx)
+	})
+	eg.Go(func() error {
+		return c.retentionCollector.Run(ctx)
+	})
+	eg.Go(func() error {
+		return c.cdcPublisher.Run(ctx)
+	})
 	return eg.Wait()
 }
 
@@ -163,6 +185,12 @@ func (c *DataBroker) update(ctx context.Context, cfg *config.Config) error {
 		manager.WithDataBrokerClient(dataBrokerClient),
 		manager.WithEventManager(c.eventsMgr),
 	}
+	if cfg.Options.ClaimsRefreshInterval > 0 {
+		options = append(options, manager.WithClaimsRefreshInterval(cfg.Options.ClaimsRefreshInterval))
+	}
+	if cfg.Options.ClaimLimits != nil {
+		options = append(options, manager.WithClaimsFilter(cfg.Options.ClaimLimits.Apply))
+	}
 
 	if cfg.Options.Provider != "" {
 		authenticator, err := identity.NewAuthenticator(oauthOptions)
@@ -179,6 +207,91 @@ func (c *DataBroker) update(ctx context.Context, cfg *config.Config) error {
 		c.manager.UpdateConfig(options...)
 	}
 
+	directoryOptions := []directory.Option{
+		directory.WithDataBrokerClient(dataBrokerClient),
+		directory.WithSyncInterval(cfg.Options.DirectorySync.GetInterval()),
+		directory.WithNestedGroupDepth(cfg.Options.DirectorySync.GetNestedGroupDepth()),
+		directory.WithAliases(
+			newDirectoryAliases(cfg.Options.IdentityAliases.Users),
+			newDirectoryAliases(cfg.Options.IdentityAliases.Groups),
+		),
+	}
+
+	if cfg.Options.DirectorySync.IsEnabled() {
+		provider, err := newDirectoryProvider(&cfg.Options.DirectorySync)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("databroker: failed to create directory provider")
+		} else {
+			directoryOptions = append(directoryOptions, directory.WithProvider(provider))
+		}
+	}
+
+	if c.directorySyncer == nil {
+		c.directorySyncer = directory.NewSyncer(directoryOptions...)
+	} else {
+		c.directorySyncer.UpdateConfig(directoryOptions...)
+	}
+
+	// re-dialing the peers restarts replication, so only do it if the settings changed
+	state := newReplicationState(&cfg.Options.DataBrokerReplication, sharedKey)
+	if c.replicator == nil || !state.Equal(c.replicationState) {
+		replicationOptions, replicationConns, err := newReplicationOptions(ctx, &cfg.Options.DataBrokerReplication, sharedKey)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("databroker: failed to configure replication")
+		}
+		replicationOptions = append(replicationOptions, replication.WithDataBrokerClient(dataBrokerClient))
+
+		if c.replicator == nil {
+			c.replicator = replication.New(replicationOptions...)
+		} else {
+			c.replicator.UpdateConfig(replicationOptions...)
+		}
+		closeConns(c.replicationConns)
+		c.replicationConns = replicationConns
+		c.replicationState = state
+	}
+
+	var retentionPolicies []retention.Policy
+	for _, p := range cfg.Options.DataBrokerRetention.Policies {
+		retentionPolicies = append(retentionPolicies, retention.Policy{
+			RecordType:        p.RecordType,
+			MaxAge:            p.MaxAge,
+			ExpiryGracePeriod: p.ExpiryGracePeriod,
+		})
+	}
+	retentionOptions := []retention.Option{
+		retention.WithDataBrokerClient(dataBrokerClient),
+		retention.WithInterval(cfg.Options.DataBrokerRetention.GetInterval()),
+		retention.WithPolicies(retentionPolicies...),
+	}
+
+	if c.retentionCollector == nil {
+		c.retentionCollector = retention.New(retentionOptions...)
+	} else {
+		c.retentionCollector.UpdateConfig(retentionOptions...)
+	}
+
+	cdcOptions := []cdc.Option{
+		cdc.WithDataBrokerClient(dataBrokerClient),
+	}
+	if settings := cfg.Options.ChangeDataCapture; settings.IsEnabled() {
+		secret, err := settings.GetWebhookSecret()
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("databroker: failed to read change data capture webhook secret")
+		} else {
+			cdcOptions = append(cdcOptions, cdc.WithWebhook(settings.WebhookURL, []byte(secret)))
+		}
+		if len(settings.RecordTypes) > 0 {
+			cdcOptions = append(cdcOptions, cdc.WithRecordTypes(settings.RecordTypes...))
+		}
+	}
+
+	if c.cdcPublisher == nil {
+		c.cdcPublisher = cdc.New(cdcOptions...)
+	} else {
+		c.cdcPublisher.UpdateConfig(cdcOptions...)
+	}
+
 	return nil
 }
 
@@ -45,6 +45,8 @@ func (srv *dataBrokerServer) getOptions(cfg *config.Config) []databroker.ServerO
 		databroker.WithStorageCAFile(cfg.Options.DataBrokerStorageCAFile),
 		databroker.WithStorageCertificate(cert),
 		databroker.WithStorageCertSkipVerify(cfg.Options.DataBrokerStorageCertSkipVerify),
+		databroker.WithStorageEncryption(cfg.Options.DataBrokerStorageEncryption),
+		databroker.WithStorageSharding(cfg.Options.DataBrokerSharding),
 	}
 }
 
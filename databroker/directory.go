@@ -0,0 +1,75 @@
+package databroker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/directory"
+	"github.com/pomerium/pomerium/internal/directory/keycloak"
+	"github.com/pomerium/pomerium/internal/directory/ldap"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func newDirectoryProvider(settings *config.DirectorySyncSettings) (directory.Provider, error) {
+	switch settings.Provider {
+	case config.DirectorySyncProviderKeycloak:
+		clientSecret, err := settings.Keycloak.GetClientSecret()
+		if err != nil {
+			return nil, fmt.Errorf("invalid keycloak client secret: %w", err)
+		}
+		return keycloak.New(keycloak.Options{
+			URL:          settings.Keycloak.URL,
+			Realm:        settings.Keycloak.Realm,
+			ClientID:     settings.Keycloak.ClientID,
+			ClientSecret: clientSecret,
+		})
+	case config.DirectorySyncProviderLDAP:
+		bindPassword, err := settings.LDAP.GetBindPassword()
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap bind password: %w", err)
+		}
+		u, err := url.Parse(settings.LDAP.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap url: %w", err)
+		}
+		rootCAs, err := cryptutil.GetCertPool("", settings.LDAP.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap ca: %w", err)
+		}
+		return ldap.New(ldap.Options{
+			URL:      settings.LDAP.URL,
+			StartTLS: settings.LDAP.StartTLS,
+			TLSConfig: &tls.Config{
+				ServerName: u.Hostname(),
+				RootCAs:    rootCAs,
+				MinVersion: tls.VersionTLS12,
+			},
+			BindDN:                   settings.LDAP.BindDN,
+			BindPassword:             bindPassword,
+			BaseDN:                   settings.LDAP.BaseDN,
+			UserFilter:               settings.LDAP.UserFilter,
+			GroupFilter:              settings.LDAP.GroupFilter,
+			UserIDAttribute:          settings.LDAP.UserIDAttribute,
+			UserEmailAttribute:       settings.LDAP.UserEmailAttribute,
+			UserDisplayNameAttribute: settings.LDAP.UserDisplayNameAttribute,
+			GroupNameAttribute:       settings.LDAP.GroupNameAttribute,
+			GroupMemberAttribute:     settings.LDAP.GroupMemberAttribute,
+			PageSize:                 settings.LDAP.PageSize,
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown directory provider: %s", settings.Provider)
+}
+
+func newDirectoryAliases(aliases []config.IdentityAlias) []directory.Alias {
+	var directoryAliases []directory.Alias
+	for _, alias := range aliases {
+		directoryAliases = append(directoryAliases, directory.Alias{
+			ID:      alias.ID,
+			IdPIDs:  alias.IdPIDs,
+			Aliases: alias.Aliases,
+		})
+	}
+	return directoryAliases
+}
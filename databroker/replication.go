@@ -0,0 +1,107 @@
+package databroker
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/replication"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	pomeriumgrpc "github.com/pomerium/pomerium/pkg/grpc"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// replicationState is the configuration the replicator was last created with.
+type replicationState struct {
+	settings  config.DataBrokerReplicationSettings
+	sharedKey []byte
+}
+
+func newReplicationState(settings *config.DataBrokerReplicationSettings, sharedKey []byte) replicationState {
+	return replicationState{settings: *settings, sharedKey: sharedKey}
+}
+
+// Equal returns true if the two states are the same.
+func (s replicationState) Equal(other replicationState) bool {
+	return reflect.DeepEqual(s, other)
+}
+
+// newReplicationOptions dials the databrokers of the peer regions and returns the replicator
+// options, along with the peer connections, which must be closed when they're replaced.
+func newReplicationOptions(
+	ctx context.Context,
+	settings *config.DataBrokerReplicationSettings,
+	sharedKey []byte,
+) ([]replication.Option, []*grpc.ClientConn, error) {
+	if !settings.IsEnabled() {
+		return nil, nil, nil
+	}
+
+	rootCAs, err := cryptutil.GetCertPool("", settings.CAFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid replication ca: %w", err)
+	}
+
+	options := []replication.Option{replication.WithRegion(settings.Region)}
+	if len(settings.RecordTypes) > 0 {
+		options = append(options, replication.WithRecordTypes(settings.RecordTypes...))
+	}
+
+	regions := make([]string, 0, len(settings.Peers))
+	for region := range settings.Peers {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var conns []*grpc.ClientConn
+	for _, region := range regions {
+		u, err := url.Parse(settings.Peers[region])
+		if err != nil {
+			closeConns(conns)
+			return nil, nil, fmt.Errorf("invalid replication peer: %w", err)
+		}
+
+		address := u.Host
+		var dialOptions []grpc.DialOption
+		if u.Scheme == "https" {
+			if u.Port() == "" {
+				address = net.JoinHostPort(u.Hostname(), "443")
+			}
+			dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				ServerName: u.Hostname(),
+				RootCAs:    rootCAs,
+				MinVersion: tls.VersionTLS12,
+			})))
+		} else if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), "80")
+		}
+
+		cc, err := pomeriumgrpc.NewGRPCClientConn(ctx, &pomeriumgrpc.Options{
+			Address:      address,
+			ServiceName:  "databroker_replication",
+			SignedJWTKey: sharedKey,
+		}, dialOptions...)
+		if err != nil {
+			closeConns(conns)
+			return nil, nil, fmt.Errorf("error dialing replication peer %s: %w", region, err)
+		}
+		conns = append(conns, cc)
+		options = append(options, replication.WithPeer(region, databroker.NewDataBrokerServiceClient(cc)))
+	}
+
+	return options, conns, nil
+}
+
+func closeConns(conns []*grpc.ClientConn) {
+	for _, cc := range conns {
+		_ = cc.Close()
+	}
+}
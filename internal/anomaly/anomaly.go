@@ -0,0 +1,134 @@
+// Package anomaly detects anomalous authorization patterns, such as impossible travel, sudden mass
+// denials and requests from new countries.
+//
+// Authorize decisions are observed by an Analyzer, which locates the client IP and passes the
+// observation to a pipeline of pluggable Detectors. Detected anomalies are passed to Handlers,
+// which for example record them in the audit log.
+package anomaly
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const analyzerMaxSize = 10_000
+
+// An Observation is an observed authorize decision.
+type Observation struct {
+	Time    time.Time
+	UserID  string
+	Email   string
+	RouteID string
+	IP      string
+	Allow   bool
+	// Location is the location of the IP, if known.
+	Location *Location
+}
+
+// A Location is the geographic location of an IP address.
+type Location struct {
+	// Country is the ISO 3166-1 country code.
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+// An Anomaly is an anomalous authorization pattern.
+type Anomaly struct {
+	Type    string
+	UserID  string
+	Email   string
+	RouteID string
+	// Score is the risk of the anomaly, from 0 to 100.
+	Score int
+	// Details are additional anomaly type specific details.
+	Details map[string]string
+}
+
+// A Detector detects anomalies in the observed decisions. Detectors are only called from a single
+// goroutine, so they don't need to synchronize their state.
+type Detector interface {
+	Observe(obs *Observation) []Anomaly
+}
+
+// A GeoIP looks up the location of IP addresses.
+type GeoIP interface {
+	Lookup(ip net.IP) (*Location, error)
+}
+
+// A Handler handles a detected anomaly.
+type Handler func(ctx context.Context, a Anomaly)
+
+// An Analyzer passes observed decisions to detectors and the anomalies they detect to handlers.
+type Analyzer struct {
+	geoIP        GeoIP
+	detectors    []Detector
+	handlers     []Handler
+	observations chan *Observation
+
+	dropped int64
+}
+
+// NewAnalyzer creates a new Analyzer. The geoIP may be nil, in which case observations have no
+// location.
+func NewAnalyzer(geoIP GeoIP, detectors []Detector, handlers ...Handler) *Analyzer {
+	return &Analyzer{
+		geoIP:        geoIP,
+		detectors:    detectors,
+		handlers:     handlers,
+		observations: make(chan *Observation, analyzerMaxSize),
+	}
+}
+
+// Observe observes a decision. Observations are analyzed asynchronously, so authorize checks
+// aren't slowed down. If the analyzer falls behind the observation is dropped.
+func (a *Analyzer) Observe(obs *Observation) {
+	select {
+	case a.observations <- obs:
+	default:
+		atomic.AddInt64(&a.dropped, 1)
+	}
+}
+
+// Run runs the analyzer.
+func (a *Analyzer) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case obs := <-a.observations:
+			a.analyze(ctx, obs)
+		case <-ticker.C:
+			if dropped := atomic.SwapInt64(&a.dropped, 0); dropped > 0 {
+				log.Warn(ctx).Int64("dropped", dropped).Msg("anomaly: dropped observations")
+			}
+		}
+	}
+}
+
+func (a *Analyzer) analyze(ctx context.Context, obs *Observation) {
+	if a.geoIP != nil && obs.Location == nil {
+		if ip := net.ParseIP(obs.IP); ip != nil {
+			loc, err := a.geoIP.Lookup(ip)
+			if err != nil {
+				log.Debug(ctx).Err(err).Str("ip", obs.IP).Msg("anomaly: error looking up ip location")
+			}
+			obs.Location = loc
+		}
+	}
+
+	for _, d := range a.detectors {
+		for _, anomaly := range d.Observe(obs) {
+			for _, h := range a.handlers {
+				h(ctx, anomaly)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package anomaly
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// Anomaly types.
+const (
+	TypeImpossibleTravel = "impossible_travel"
+	TypeMassDenials      = "mass_denials"
+	TypeNewCountry       = "new_country"
+)
+
+// maxTrackedKeys bounds the state of the detectors. When exceeded the state is reset.
+const maxTrackedKeys = 100_000
+
+// minTravelDistance ignores short distances, which are within the accuracy of GeoIP databases.
+const minTravelDistance = 500 // km
+
+type lastLocation struct {
+	time     time.Time
+	location Location
+}
+
+// ImpossibleTravel detects users making requests from locations too far apart to travel between
+// in the time between the requests.
+type ImpossibleTravel struct {
+	// MaxSpeed is the maximum plausible travel speed in km/h.
+	MaxSpeed float64
+
+	last map[string]lastLocation
+}
+
+// NewImpossibleTravel creates a new ImpossibleTravel detector.
+func NewImpossibleTravel(maxSpeed float64) *ImpossibleTravel {
+	return &ImpossibleTravel{MaxSpeed: maxSpeed, last: make(map[string]lastLocation)}
+}
+
+// Observe implements Detector.
+func (d *ImpossibleTravel) Observe(obs *Observation) []Anomaly {
+	if obs.UserID == "" || obs.Location == nil {
+		return nil
+	}
+
+	prev, ok := d.last[obs.UserID]
+	if len(d.last) >= maxTrackedKeys && !ok {
+		d.last = make(map[string]lastLocation)
+	}
+	d.last[obs.UserID] = lastLocation{time: obs.Time, location: *obs.Location}
+	if !ok {
+		return nil
+	}
+
+	distance := distanceKm(prev.location, *obs.Location)
+	if distance < minTravelDistance {
+		return nil
+	}
+	hours := obs.Time.Sub(prev.time).Hours()
+	if hours > 0 && distance/hours <= d.MaxSpeed {
+		return nil
+	}
+
+	return []Anomaly{{
+		Type:    TypeImpossibleTravel,
+		UserID:  obs.UserID,
+		Email:   obs.Email,
+		RouteID: obs.RouteID,
+		Score:   80,
+		Details: map[string]string{
+			"from_country": prev.location.Country,
+			"to_country":   obs.Location.Country,
+			"distance_km":  strconv.FormatFloat(distance, 'f', 0, 64),
+			"elapsed":      obs.Time.Sub(prev.time).String(),
+			"ip":           obs.IP,
+		},
+	}}
+}
+
+// NewCountry detects users making requests from a country they haven't made requests from
+// before. The first country of a user isn't an anomaly.
+type NewCountry struct {
+	seen map[string]map[string]struct{}
+}
+
+// NewNewCountry creates a new NewCountry detector.
+func NewNewCountry() *NewCountry {
+	return &NewCountry{seen: make(map[string]map[string]struct{})}
+}
+
+// Observe implements Detector.
+func (d *NewCountry) Observe(obs *Observation) []Anomaly {
+	if obs.UserID == "" || obs.Location == nil || obs.Location.Country == "" {
+		return nil
+	}
+
+	countries, ok := d.seen[obs.UserID]
+	if !ok {
+		if len(d.seen) >= maxTrackedKeys {
+			d.seen = make(map[string]map[string]struct{})
+		}
+		d.seen[obs.UserID] = map[string]struct{}{obs.Location.Country: {}}
+		return nil
+	}
+	if _, ok := countries[obs.Location.Country]; ok {
+		return nil
+	}
+	countries[obs.Location.Country] = struct{}{}
+
+	return []Anomaly{{
+		Type:    TypeNewCountry,
+		UserID:  obs.UserID,
+		Email:   obs.Email,
+		RouteID: obs.RouteID,
+		Score:   50,
+		Details: map[string]string{
+			"country": obs.Location.Country,
+			"ip":      obs.IP,
+		},
+	}}
+}
+
+type denials struct {
+	times     []time.Time
+	flaggedAt time.Time
+}
+
+// MassDenials detects a sudden number of denied requests of a user or to a route.
+type MassDenials struct {
+	// Threshold is the number of denied requests within the window which is an anomaly.
+	Threshold int
+	// Window is the duration denied requests are counted over.
+	Window time.Duration
+
+	byUser  map[string]*denials
+	byRoute map[string]*denials
+}
+
+// NewMassDenials creates a new MassDenials detector.
+func NewMassDenials(threshold int, window time.Duration) *MassDenials {
+	return &MassDenials{
+		Threshold: threshold,
+		Window:    window,
+		byUser:    make(map[string]*denials),
+		byRoute:   make(map[string]*denials),
+	}
+}
+
+// Observe implements Detector.
+func (d *MassDenials) Observe(obs *Observation) []Anomaly {
+	if obs.Allow {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	if obs.UserID != "" && d.count(d.byUser, obs.UserID, obs.Time) {
+		anomalies = append(anomalies, Anomaly{
+			Type:    TypeMassDenials,
+			UserID:  obs.UserID,
+			Email:   obs.Email,
+			Score:   60,
+			Details: d.details("user"),
+		})
+	}
+	if obs.RouteID != "" && d.count(d.byRoute, obs.RouteID, obs.Time) {
+		anomalies = append(anomalies, Anomaly{
+			Type:    TypeMassDenials,
+			RouteID: obs.RouteID,
+			Score:   60,
+			Details: d.details("route"),
+		})
+	}
+	return anomalies
+}
+
+// count counts a denial and returns true if the threshold is reached. Once reached, the key isn't
+// flagged again until a window has passed.
+func (d *MassDenials) count(m map[string]*denials, key string, now time.Time) bool {
+	ds, ok := m[key]
+	if !ok {
+		if len(m) >= maxTrackedKeys {
+			for k := range m {
+				delete(m, k)
+			}
+		}
+		ds = new(denials)
+		m[key] = ds
+	}
+
+	cutoff := now.Add(-d.Window)
+	i := 0
+	for i < len(ds.times) && !ds.times[i].After(cutoff) {
+		i++
+	}
+	ds.times = append(ds.times[i:], now)
+
+	if len(ds.times) < d.Threshold || ds.flaggedAt.After(cutoff) {
+		return false
+	}
+	ds.flaggedAt = now
+	return true
+}
+
+func (d *MassDenials) details(scope string) map[string]string {
+	return map[string]string{
+		"scope":     scope,
+		"threshold": strconv.Itoa(d.Threshold),
+		"window":    d.Window.String(),
+	}
+}
+
+// distanceKm returns the great-circle distance between two locations.
+func distanceKm(a, b Location) float64 {
+	const earthRadius = 6371 // km
+
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
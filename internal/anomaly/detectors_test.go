@@ -0,0 +1,121 @@
+package anomaly
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	newYork = &Location{Country: "US", Latitude: 40.71, Longitude: -74.01}
+	boston  = &Location{Country: "US", Latitude: 42.36, Longitude: -71.06}
+	london  = &Location{Country: "GB", Latitude: 51.51, Longitude: -0.13}
+)
+
+func TestImpossibleTravel(t *testing.T) {
+	t.Parallel()
+
+	d := NewImpossibleTravel(1000)
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, d.Observe(&Observation{Time: start, UserID: "u1", Location: newYork}))
+	assert.Empty(t, d.Observe(&Observation{Time: start.Add(time.Minute), UserID: "u1", Location: boston}),
+		"should ignore short distances")
+	assert.Empty(t, d.Observe(&Observation{Time: start.Add(10 * time.Hour), UserID: "u1", Location: london}),
+		"should allow plausible travel")
+
+	anomalies := d.Observe(&Observation{Time: start.Add(11 * time.Hour), UserID: "u1", Location: newYork, IP: "127.0.0.1"})
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, TypeImpossibleTravel, anomalies[0].Type)
+	assert.Equal(t, "u1", anomalies[0].UserID)
+	assert.Equal(t, "GB", anomalies[0].Details["from_country"])
+	assert.Equal(t, "US", anomalies[0].Details["to_country"])
+
+	assert.Empty(t, d.Observe(&Observation{Time: start.Add(12 * time.Hour), UserID: "u2", Location: london}),
+		"should track users separately")
+}
+
+func TestNewCountry(t *testing.T) {
+	t.Parallel()
+
+	d := NewNewCountry()
+
+	assert.Empty(t, d.Observe(&Observation{UserID: "u1", Location: newYork}))
+	assert.Empty(t, d.Observe(&Observation{UserID: "u1", Location: boston}))
+	anomalies := d.Observe(&Observation{UserID: "u1", Location: london})
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, TypeNewCountry, anomalies[0].Type)
+	assert.Equal(t, "GB", anomalies[0].Details["country"])
+	assert.Empty(t, d.Observe(&Observation{UserID: "u1", Location: london}))
+	assert.Empty(t, d.Observe(&Observation{UserID: "u1"}), "should ignore unknown locations")
+}
+
+func TestMassDenials(t *testing.T) {
+	t.Parallel()
+
+	d := NewMassDenials(3, time.Minute)
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	deny := func(offset time.Duration, userID, routeID string) []Anomaly {
+		return d.Observe(&Observation{Time: start.Add(offset), UserID: userID, RouteID: routeID})
+	}
+
+	assert.Empty(t, d.Observe(&Observation{Time: start, UserID: "u1", RouteID: "r1", Allow: true}))
+	assert.Empty(t, deny(0, "u1", "r1"))
+	assert.Empty(t, deny(time.Second, "u1", "r2"))
+	anomalies := deny(2*time.Second, "u1", "r3")
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, "u1", anomalies[0].UserID)
+	assert.Equal(t, "user", anomalies[0].Details["scope"])
+
+	assert.Empty(t, deny(3*time.Second, "u1", "r4"), "should only flag once per window")
+
+	assert.Empty(t, deny(10*time.Minute, "u2", "r5"), "should expire old denials")
+	assert.Empty(t, deny(10*time.Minute, "u3", "r5"))
+	anomalies = deny(10*time.Minute, "u4", "r5")
+	require.Len(t, anomalies, 1)
+	assert.Equal(t, "r5", anomalies[0].RouteID)
+	assert.Equal(t, "route", anomalies[0].Details["scope"])
+}
+
+type staticGeoIP map[string]*Location
+
+func (g staticGeoIP) Lookup(ip net.IP) (*Location, error) {
+	return g[ip.String()], nil
+}
+
+func TestAnalyzer(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	anomalies := make(chan Anomaly, 1)
+	a := NewAnalyzer(
+		staticGeoIP{"192.0.2.1": newYork, "198.51.100.1": london},
+		[]Detector{NewNewCountry()},
+		func(_ context.Context, a Anomaly) { anomalies <- a },
+	)
+	go a.Run(ctx)
+
+	a.Observe(&Observation{UserID: "u1", IP: "192.0.2.1"})
+	a.Observe(&Observation{UserID: "u1", IP: "198.51.100.1"})
+
+	select {
+	case anomaly := <-anomalies:
+		assert.Equal(t, TypeNewCountry, anomaly.Type)
+		assert.Equal(t, "198.51.100.1", anomaly.Details["ip"])
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for anomaly")
+	}
+}
+
+func TestDistanceKm(t *testing.T) {
+	t.Parallel()
+
+	assert.InDelta(t, 5570, distanceKm(*newYork, *london), 10)
+	assert.InDelta(t, 0, distanceKm(*london, *london), 0.001)
+}
@@ -0,0 +1,51 @@
+package anomaly
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// A MaxMindGeoIP looks up locations in a MaxMind GeoIP2 or GeoLite2 City database.
+type MaxMindGeoIP struct {
+	reader *maxminddb.Reader
+}
+
+// OpenMaxMindGeoIP opens a MaxMind database file.
+func OpenMaxMindGeoIP(file string) (*MaxMindGeoIP, error) {
+	reader, err := maxminddb.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("anomaly: error opening geoip database: %w", err)
+	}
+	return &MaxMindGeoIP{reader: reader}, nil
+}
+
+// Lookup implements GeoIP. It returns nil if the ip isn't in the database.
+func (g *MaxMindGeoIP) Lookup(ip net.IP) (*Location, error) {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Location struct {
+			Latitude  *float64 `maxminddb:"latitude"`
+			Longitude *float64 `maxminddb:"longitude"`
+		} `maxminddb:"location"`
+	}
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return nil, err
+	}
+	if record.Location.Latitude == nil || record.Location.Longitude == nil {
+		return nil, nil
+	}
+	return &Location{
+		Country:   record.Country.ISOCode,
+		Latitude:  *record.Location.Latitude,
+		Longitude: *record.Location.Longitude,
+	}, nil
+}
+
+// Close closes the database.
+func (g *MaxMindGeoIP) Close() error {
+	return g.reader.Close()
+}
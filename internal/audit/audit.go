@@ -0,0 +1,148 @@
+// Package audit records security relevant events, such as sign-ins and session revocations, in an
+// audit log which is separate from the access logs.
+//
+// The entries of the audit log are hash-chained: the hash of every entry covers the hash of the
+// entry before it, so modifying, removing or re-ordering entries can be detected with Verify.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// An EventType is the type of an audited event.
+type EventType string
+
+// Event types.
+const (
+	EventSignIn         EventType = "sign_in"
+	EventSignOut        EventType = "sign_out"
+	EventImpersonate    EventType = "impersonate"
+	EventDeviceEnrolled EventType = "device_enrolled"
+	EventSessionRevoked EventType = "session_revoked"
+	EventConfigReloaded EventType = "config_reloaded"
+	EventAnomaly        EventType = "anomaly"
+	EventBreakGlass     EventType = "break_glass"
+	EventTermsAccepted  EventType = "terms_accepted"
+
+	EventAgentSessionCreated EventType = "agent_session_created"
+)
+
+// An Event is an audited event.
+type Event struct {
+	Type      EventType
+	UserID    string
+	SessionID string
+	Email     string
+	// Details are additional event type specific details.
+	Details map[string]string
+}
+
+// An Entry is an entry of the audit log.
+type Entry struct {
+	Sequence  uint64            `json:"sequence"`
+	Time      time.Time         `json:"time"`
+	Type      EventType         `json:"type"`
+	UserID    string            `json:"user_id,omitempty"`
+	SessionID string            `json:"session_id,omitempty"`
+	Email     string            `json:"email,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	// PrevHash is the hash of the previous entry, or empty for the first entry.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex encoded SHA-256 hash of the entry without the hash.
+	Hash string `json:"hash"`
+}
+
+// ComputeHash computes the hash of the entry.
+func (e *Entry) ComputeHash() string {
+	cp := *e
+	cp.Hash = ""
+	// the fields of the entry are always encodable
+	bs, _ := json.Marshal(&cp)
+	h := sha256.Sum256(bs)
+	return hex.EncodeToString(h[:])
+}
+
+// A Logger records events to sinks.
+type Logger struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	sinks    []Sink
+	sequence uint64
+	prevHash string
+}
+
+// New creates a new Logger.
+func New(sinks ...Sink) *Logger {
+	return &Logger{
+		now:   time.Now,
+		sinks: sinks,
+	}
+}
+
+// SetSinks replaces the sinks of the logger, returning the previous sinks so they can be closed.
+// The hash chain continues across sinks. If no events were recorded yet, the chain continues from
+// the last entry, such as the last entry of an existing audit log file.
+func (l *Logger) SetSinks(last *Entry, sinks ...Sink) []Sink {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.sequence == 0 && last != nil {
+		l.sequence, l.prevHash = last.Sequence, last.Hash
+	}
+	prev := l.sinks
+	l.sinks = sinks
+	return prev
+}
+
+// Record records an event.
+func (l *Logger) Record(ctx context.Context, evt Event) {
+	// entries are written in order, so the chain can be verified from the written entries
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.sinks) == 0 {
+		return
+	}
+
+	entry := &Entry{
+		Sequence:  l.sequence + 1,
+		Time:      l.now().UTC(),
+		Type:      evt.Type,
+		UserID:    evt.UserID,
+		SessionID: evt.SessionID,
+		Email:     evt.Email,
+		Details:   evt.Details,
+		PrevHash:  l.prevHash,
+	}
+	entry.Hash = entry.ComputeHash()
+	l.sequence, l.prevHash = entry.Sequence, entry.Hash
+
+	for _, sink := range l.sinks {
+		if err := sink.Write(ctx, entry); err != nil {
+			log.Error(ctx).Err(err).
+				Str("audit-event", string(entry.Type)).
+				Uint64("audit-sequence", entry.Sequence).
+				Msg("audit: error writing audit log entry")
+		}
+	}
+}
+
+var global = New()
+
+// SetSinks replaces the sinks of the global logger, returning the previous sinks.
+func SetSinks(last *Entry, sinks ...Sink) []Sink {
+	return global.SetSinks(last, sinks...)
+}
+
+// Record records an event with the global logger.
+func Record(ctx context.Context, evt Event) {
+	global.Record(ctx, evt)
+}
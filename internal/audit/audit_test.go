@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	record := func(events ...Event) {
+		last, err := ReadLastEntry(path)
+		require.NoError(t, err)
+		sink, err := NewFileSink(path)
+		require.NoError(t, err)
+		defer sink.Close()
+
+		l := New()
+		l.now = func() time.Time { return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC) }
+		l.SetSinks(last, sink)
+		for _, evt := range events {
+			l.Record(ctx, evt)
+		}
+	}
+
+	record(
+		Event{Type: EventSignIn, UserID: "u1", SessionID: "s1", Email: "u1@example.com"},
+		Event{Type: EventImpersonate, UserID: "u1", SessionID: "s1", Details: map[string]string{"impersonate_user_id": "u2"}},
+	)
+	// a restart continues the chain of the existing file
+	record(
+		Event{Type: EventSignOut, UserID: "u1", SessionID: "s1"},
+	)
+
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	count, err := Verify(bytes.NewReader(bs))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	last, err := ReadLastEntry(path)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), last.Sequence)
+	assert.Equal(t, EventSignOut, last.Type)
+
+	lines := strings.SplitAfter(string(bs), "\n")
+
+	t.Run("modified", func(t *testing.T) {
+		modified := strings.Replace(string(bs), "u1@example.com", "u3@example.com", 1)
+		_, err := Verify(strings.NewReader(modified))
+		assert.ErrorContains(t, err, "entry 1 was modified")
+	})
+	t.Run("removed", func(t *testing.T) {
+		_, err := Verify(strings.NewReader(lines[0] + lines[2]))
+		assert.ErrorContains(t, err, "entries missing between 1 and 3")
+	})
+	t.Run("suffix", func(t *testing.T) {
+		count, err := Verify(strings.NewReader(lines[1] + lines[2]))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
+
+func TestLogger_NoSinks(t *testing.T) {
+	t.Parallel()
+
+	l := New()
+	l.Record(context.Background(), Event{Type: EventSignIn})
+	assert.Zero(t, l.sequence, "events should not be chained without sinks")
+}
+
+func TestFormatEntry(t *testing.T) {
+	t.Parallel()
+
+	entry := &Entry{
+		Sequence: 2,
+		Time:     time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Type:     EventAnomaly,
+		UserID:   "u1",
+		Email:    "u1@example.com",
+		Details:  map[string]string{"score": "80", "anomaly_type": "impossible_travel"},
+		PrevHash: "p",
+		Hash:     "h",
+	}
+
+	bs, err := formatEntry(entry, "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"sequence": 2, "time": "2023-01-01T00:00:00Z", "type": "anomaly",
+		"user_id": "u1", "email": "u1@example.com",
+		"details": {"anomaly_type": "impossible_travel", "score": "80"},
+		"prev_hash": "p", "hash": "h"
+	}`, string(bs))
+
+	bs, err = formatEntry(entry, log.FormatLogfmt)
+	require.NoError(t, err)
+	assert.Equal(t, `sequence=2 time=2023-01-01T00:00:00Z type=anomaly user_id=u1 email=u1@example.com `+
+		`details="{\"anomaly_type\":\"impossible_travel\",\"score\":\"80\"}" prev_hash=p hash=h`, string(bs))
+
+	bs, err = formatEntry(entry, log.FormatCEF)
+	require.NoError(t, err)
+	assert.Equal(t, "CEF:0|Pomerium|Pomerium|"+version.FullVersion()+"|anomaly|anomaly|8|"+
+		"rt=1672531200000 sequence=2 suid=u1 suser=u1@example.com anomalyType=impossible_travel score=80 prevHash=p hash=h",
+		string(bs))
+}
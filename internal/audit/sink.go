@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A Sink writes audit log entries.
+type Sink interface {
+	Write(ctx context.Context, entry *Entry) error
+	Close() error
+}
+
+type fileSink struct {
+	f *os.File
+}
+
+// NewFileSink creates a new Sink which appends entries to a file as newline delimited JSON.
+func NewFileSink(path string) (Sink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: error opening audit log file: %w", err)
+	}
+	return &fileSink{f: f}, nil
+}
+
+func (s *fileSink) Write(_ context.Context, entry *Entry) error {
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(bs, '\n'))
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+// maxEntrySize is the maximum size of an entry read from the end of an audit log file.
+const maxEntrySize = 64 * 1024
+
+// ReadLastEntry reads the last entry of an audit log file. If the file doesn't exist or is empty,
+// nil is returned.
+func ReadLastEntry(path string) (*Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := fi.Size() - maxEntrySize
+	if offset < 0 {
+		offset = 0
+	}
+	bs := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(bs, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	bs = bytes.TrimSpace(bs)
+	if len(bs) == 0 {
+		return nil, nil
+	}
+	if i := bytes.LastIndexByte(bs, '\n'); i >= 0 {
+		bs = bs[i+1:]
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(bs, &entry); err != nil {
+		return nil, fmt.Errorf("audit: invalid last audit log entry: %w", err)
+	}
+	return &entry, nil
+}
+
+const (
+	httpQueueSize    = 1000
+	httpTimeout      = 10 * time.Second
+	httpRetryTimeout = time.Minute
+)
+
+type httpSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	queue  chan *Entry
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewHTTPSink creates a new Sink which posts each entry as JSON to a url. Entries are posted in the
+// background, in order, and retried for up to a minute. Entries are dropped if the queue is full.
+func NewHTTPSink(url string, headers map[string]string) Sink {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &httpSink{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: httpTimeout},
+		queue:   make(chan *Entry, httpQueueSize),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *httpSink) Write(_ context.Context, entry *Entry) error {
+	select {
+	case s.queue <- entry:
+		return nil
+	default:
+		return fmt.Errorf("audit: http queue is full, dropping entry")
+	}
+}
+
+func (s *httpSink) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+func (s *httpSink) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		var entry *Entry
+		select {
+		case <-ctx.Done():
+			return
+		case entry = <-s.queue:
+		}
+
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = httpRetryTimeout
+		err := backoff.Retry(func() error {
+			return s.post(ctx, entry)
+		}, backoff.WithContext(bo, ctx))
+		if err != nil && ctx.Err() == nil {
+			log.Error(ctx).Err(err).
+				Uint64("audit-sequence", entry.Sequence).
+				Msg("audit: error posting audit log entry")
+		}
+	}
+}
+
+func (s *httpSink) post(ctx context.Context, entry *Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected audit log response status: %s", res.Status)
+	}
+	return nil
+}
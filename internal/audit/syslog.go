@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+type syslogSink struct {
+	w      io.WriteCloser
+	format log.Format
+}
+
+// NewSyslogSink creates a new Sink which writes each entry to a syslog writer, formatted as JSON,
+// logfmt, CEF or LEEF. An empty format is JSON.
+func NewSyslogSink(w io.WriteCloser, format log.Format) Sink {
+	return &syslogSink{w: w, format: format}
+}
+
+func (s *syslogSink) Write(_ context.Context, entry *Entry) error {
+	bs, err := formatEntry(entry, s.format)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(bs)
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+func formatEntry(entry *Entry, format log.Format) ([]byte, error) {
+	switch format {
+	case log.FormatCEF, log.FormatLEEF:
+		return []byte(format.FormatSecurityEvent(newSecurityEvent(entry))), nil
+	}
+
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if format == log.FormatLogfmt {
+		var buf bytes.Buffer
+		if _, err := log.NewLogfmtWriter(&buf).Write(bs); err != nil {
+			return nil, err
+		}
+		bs = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	}
+	return bs, nil
+}
+
+// newSecurityEvent returns the entry as a security event. The details are flattened into the
+// fields of the event.
+func newSecurityEvent(entry *Entry) *log.SecurityEvent {
+	evt := &log.SecurityEvent{
+		Name:     string(entry.Type),
+		Severity: 3,
+		Time:     entry.Time,
+	}
+	// anomalies are scored from 0 to 100
+	if entry.Type == EventAnomaly {
+		if score, err := strconv.Atoi(entry.Details["score"]); err == nil {
+			evt.Severity = score / 10
+		}
+	}
+	// break-glass access bypasses authentication, so it's always reported with the highest severity
+	if entry.Type == EventBreakGlass {
+		evt.Severity = 10
+	}
+
+	add := func(key, value string) {
+		if value != "" {
+			evt.Fields = append(evt.Fields, log.SecurityEventField{Key: key, Value: value})
+		}
+	}
+	add("sequence", strconv.FormatUint(entry.Sequence, 10))
+	add("user_id", entry.UserID)
+	add("session_id", entry.SessionID)
+	add("email", entry.Email)
+
+	keys := make([]string, 0, len(entry.Details))
+	for k := range entry.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, entry.Details[k])
+	}
+
+	add("prev_hash", entry.PrevHash)
+	add("hash", entry.Hash)
+	return evt
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Verify verifies the hash chain of the entries of an audit log, as written by a file sink. The
+// first entry is trusted to continue a chain, so a log can be verified from any entry. The number
+// of verified entries is returned.
+func Verify(r io.Reader) (count int, err error) {
+	br := bufio.NewReader(r)
+	var prev *Entry
+	for {
+		line, err := br.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var entry Entry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return count, fmt.Errorf("audit: invalid entry after sequence %d: %w", prevSequence(prev), err)
+			}
+			if entry.ComputeHash() != entry.Hash {
+				return count, fmt.Errorf("audit: entry %d was modified", entry.Sequence)
+			}
+			if prev != nil {
+				if entry.Sequence != prev.Sequence+1 {
+					return count, fmt.Errorf("audit: entries missing between %d and %d", prev.Sequence, entry.Sequence)
+				}
+				if entry.PrevHash != prev.Hash {
+					return count, fmt.Errorf("audit: entry %d doesn't follow entry %d", entry.Sequence, prev.Sequence)
+				}
+			}
+			prev = &entry
+			count++
+		}
+
+		if err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+	}
+}
+
+func prevSequence(prev *Entry) uint64 {
+	if prev == nil {
+		return 0
+	}
+	return prev.Sequence
+}
@@ -0,0 +1,206 @@
+// Package botchallenge verifies bot-mitigation challenges, either from a CAPTCHA service or a
+// built-in proof of work, and issues tokens proving a challenge was solved.
+package botchallenge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// ErrInvalidSolution indicates a challenge wasn't solved.
+var ErrInvalidSolution = errors.New("botchallenge: invalid solution")
+
+// The verify URLs of the CAPTCHA services. They're variables for tests.
+var (
+	TurnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	HCaptchaVerifyURL  = "https://api.hcaptcha.com/siteverify"
+)
+
+// A Verifier verifies the solution of a challenge.
+type Verifier interface {
+	Verify(ctx context.Context, solution, remoteIP string) error
+}
+
+// A SiteVerifyVerifier verifies CAPTCHA responses with a siteverify API, as used by Cloudflare
+// Turnstile and hCaptcha.
+type SiteVerifyVerifier struct {
+	URL       string
+	SecretKey string
+	Client    *http.Client
+}
+
+// Verify verifies the CAPTCHA response.
+func (v *SiteVerifyVerifier) Verify(ctx context.Context, solution, remoteIP string) error {
+	if solution == "" {
+		return ErrInvalidSolution
+	}
+
+	form := url.Values{
+		"secret":   {v.SecretKey},
+		"response": {solution},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("botchallenge: error creating verify request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("botchallenge: error verifying solution: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("botchallenge: error verifying solution: unexpected status code %d", res.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return fmt.Errorf("botchallenge: error decoding verify response: %w", err)
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrInvalidSolution, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// signer signs and verifies timestamped values with HMAC.
+type signer struct {
+	key []byte
+	now func() time.Time
+}
+
+func (s signer) sign(value string) string {
+	payload := value + "." + strconv.FormatInt(s.now().Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(cryptutil.GenerateHMAC([]byte(payload), s.key))
+}
+
+// verify verifies the signed value was signed less than ttl ago, returning the value.
+func (s signer) verify(signed string, ttl time.Duration) (string, bool) {
+	idx := strings.LastIndexByte(signed, '.')
+	if idx < 0 {
+		return "", false
+	}
+	payload, sig := signed[:idx], signed[idx+1:]
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, cryptutil.GenerateHMAC([]byte(payload), s.key)) {
+		return "", false
+	}
+
+	idx = strings.LastIndexByte(payload, '.')
+	if idx < 0 {
+		return "", false
+	}
+	value := payload[:idx]
+	ts, err := strconv.ParseInt(payload[idx+1:], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	issued := time.Unix(ts, 0)
+	now := s.now()
+	if issued.After(now.Add(time.Minute)) || now.Sub(issued) > ttl {
+		return "", false
+	}
+	return value, true
+}
+
+// A PassIssuer issues and verifies the tokens given to clients which solved a challenge.
+type PassIssuer struct {
+	signer signer
+	ttl    time.Duration
+}
+
+// NewPassIssuer creates a new PassIssuer. Passes are valid for the ttl.
+func NewPassIssuer(key []byte, ttl time.Duration) *PassIssuer {
+	return &PassIssuer{signer: signer{key: key, now: time.Now}, ttl: ttl}
+}
+
+// Issue issues a new pass.
+func (p *PassIssuer) Issue() string {
+	return p.signer.sign("pass")
+}
+
+// Verify reports whether the pass is valid.
+func (p *PassIssuer) Verify(pass string) bool {
+	value, ok := p.signer.verify(pass, p.ttl)
+	return ok && value == "pass"
+}
+
+// proofOfWorkChallengeTTL is how long a client has to solve a proof of work challenge.
+const proofOfWorkChallengeTTL = 5 * time.Minute
+
+// A ProofOfWork issues and verifies proof of work challenges. A challenge is solved by finding a
+// nonce such that the SHA-256 hash of "<challenge>:<nonce>" starts with the required number of
+// zero bits.
+type ProofOfWork struct {
+	signer     signer
+	difficulty int
+}
+
+// NewProofOfWork creates a new ProofOfWork requiring difficulty leading zero bits.
+func NewProofOfWork(key []byte, difficulty int) *ProofOfWork {
+	return &ProofOfWork{signer: signer{key: key, now: time.Now}, difficulty: difficulty}
+}
+
+// Difficulty returns the number of leading zero bits required.
+func (p *ProofOfWork) Difficulty() int {
+	return p.difficulty
+}
+
+// NewChallenge returns a new challenge.
+func (p *ProofOfWork) NewChallenge() string {
+	return p.signer.sign(cryptutil.NewRandomStringN(16))
+}
+
+// Verify verifies the solution, which is the challenge followed by a colon and the nonce.
+func (p *ProofOfWork) Verify(_ context.Context, solution, _ string) error {
+	challenge, _, ok := strings.Cut(solution, ":")
+	if !ok {
+		return ErrInvalidSolution
+	}
+	if _, ok := p.signer.verify(challenge, proofOfWorkChallengeTTL); !ok {
+		return ErrInvalidSolution
+	}
+	h := sha256.Sum256([]byte(solution))
+	if leadingZeroBits(h[:]) < p.difficulty {
+		return ErrInvalidSolution
+	}
+	return nil
+}
+
+func leadingZeroBits(bs []byte) int {
+	n := 0
+	for _, b := range bs {
+		if b == 0 {
+			n += 8
+			continue
+		}
+		for mask := byte(0x80); mask != 0 && b&mask == 0; mask >>= 1 {
+			n++
+		}
+		break
+	}
+	return n
+}
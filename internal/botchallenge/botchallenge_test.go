@@ -0,0 +1,76 @@
+package botchallenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteVerifyVerifier(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") == "SECRET" && r.FormValue("response") == "GOOD" && r.FormValue("remoteip") == "127.0.0.1" {
+			fmt.Fprint(w, `{"success":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"success":false,"error-codes":["invalid-input-response"]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	v := &SiteVerifyVerifier{URL: srv.URL, SecretKey: "SECRET"}
+	assert.NoError(t, v.Verify(context.Background(), "GOOD", "127.0.0.1"))
+	assert.ErrorIs(t, v.Verify(context.Background(), "BAD", "127.0.0.1"), ErrInvalidSolution)
+	assert.ErrorIs(t, v.Verify(context.Background(), "", "127.0.0.1"), ErrInvalidSolution)
+}
+
+func TestPassIssuer(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	p := NewPassIssuer([]byte("KEY"), time.Hour)
+	p.signer.now = func() time.Time { return now }
+
+	pass := p.Issue()
+	assert.True(t, p.Verify(pass))
+	assert.False(t, p.Verify(pass+"x"))
+	assert.False(t, NewPassIssuer([]byte("OTHER"), time.Hour).Verify(pass))
+
+	now = now.Add(2 * time.Hour)
+	assert.False(t, p.Verify(pass), "should expire")
+}
+
+func TestProofOfWork(t *testing.T) {
+	t.Parallel()
+
+	p := NewProofOfWork([]byte("KEY"), 8)
+	challenge := p.NewChallenge()
+
+	var solution string
+	for nonce := 0; ; nonce++ {
+		solution = fmt.Sprintf("%s:%d", challenge, nonce)
+		h := sha256.Sum256([]byte(solution))
+		if h[0] == 0 {
+			break
+		}
+	}
+	assert.NoError(t, p.Verify(context.Background(), solution, ""))
+	assert.ErrorIs(t, p.Verify(context.Background(), challenge, ""), ErrInvalidSolution)
+	assert.ErrorIs(t, p.Verify(context.Background(), "forged.0.sig:1", ""), ErrInvalidSolution)
+	assert.ErrorIs(t, NewProofOfWork([]byte("KEY"), 64).Verify(context.Background(), solution, ""), ErrInvalidSolution)
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, leadingZeroBits([]byte{0xff}))
+	assert.Equal(t, 3, leadingZeroBits([]byte{0x10}))
+	assert.Equal(t, 12, leadingZeroBits([]byte{0x00, 0x08}))
+	assert.Equal(t, 16, leadingZeroBits([]byte{0x00, 0x00}))
+}
@@ -0,0 +1,308 @@
+// Package cdc publishes the changes of databroker records to external systems (change data capture).
+//
+// The publisher follows the databroker Sync stream, which emits every put and delete of a record,
+// and delivers an event for each change of the configured record types. Its position in the stream
+// is checkpointed in the databroker, so events are delivered at least once across restarts.
+package cdc
+
+import (
+	"context"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/directory"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/device"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+)
+
+const (
+	leaseName = "change_data_capture"
+	leaseTTL  = 30 * time.Second
+
+	// CheckpointRecordType is the type of the records storing the position of the publisher.
+	CheckpointRecordType = "pomerium.io/ChangeDataCaptureCheckpoint"
+	checkpointID         = "default"
+
+	checkpointInterval = 5 * time.Second
+	checkpointEvents   = 100
+)
+
+// DefaultRecordTypes are the record types published if none are configured.
+var DefaultRecordTypes = []string{
+	grpcutil.GetTypeURL(new(session.Session)),
+	grpcutil.GetTypeURL(new(user.User)),
+	grpcutil.GetTypeURL(new(device.Credential)),
+	grpcutil.GetTypeURL(new(device.Enrollment)),
+	session.RevocationRecordType,
+	directory.UserRecordType,
+	directory.GroupRecordType,
+}
+
+// A Sink delivers events to an external system.
+type Sink interface {
+	// Deliver delivers an event. It should only return an error if the event can't be delivered.
+	Deliver(ctx context.Context, evt *Event) error
+}
+
+type config struct {
+	dataBrokerClient databroker.DataBrokerServiceClient
+	sink             Sink
+	recordTypes      []string
+}
+
+// An Option customizes the configuration used for the publisher.
+type Option func(*config)
+
+// WithDataBrokerClient sets the databroker client in the config.
+func WithDataBrokerClient(dataBrokerClient databroker.DataBrokerServiceClient) Option {
+	return func(cfg *config) {
+		cfg.dataBrokerClient = dataBrokerClient
+	}
+}
+
+// WithSink sets the sink in the config.
+func WithSink(sink Sink) Option {
+	return func(cfg *config) {
+		cfg.sink = sink
+	}
+}
+
+// WithWebhook sets the sink in the config to a webhook, which signs the events with the secret.
+func WithWebhook(url string, secret []byte) Option {
+	return WithSink(newWebhookSink(url, secret))
+}
+
+// WithRecordTypes sets the record types to publish.
+func WithRecordTypes(recordTypes ...string) Option {
+	return func(cfg *config) {
+		cfg.recordTypes = recordTypes
+	}
+}
+
+func newConfig(options ...Option) *config {
+	cfg := new(config)
+	WithRecordTypes(DefaultRecordTypes...)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+func (cfg *config) isEnabled() bool {
+	return cfg.dataBrokerClient != nil && cfg.sink != nil
+}
+
+func (cfg *config) isPublished(recordType string) bool {
+	for _, t := range cfg.recordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+type checkpoint struct {
+	ServerVersion uint64 `json:"server_version,string"`
+	RecordVersion uint64 `json:"record_version,string"`
+}
+
+// A Publisher publishes the changes of databroker records to a sink.
+type Publisher struct {
+	cfg     *atomicutil.Value[*config]
+	updated chan struct{}
+}
+
+// New creates a new Publisher.
+func New(options ...Option) *Publisher {
+	return &Publisher{
+		cfg:     atomicutil.NewValue(newConfig(options...)),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the publisher with the new options.
+func (p *Publisher) UpdateConfig(options ...Option) {
+	p.cfg.Store(newConfig(options...))
+	select {
+	case p.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the publisher. Only a single publisher runs at a time across all the databroker
+// instances.
+func (p *Publisher) Run(ctx context.Context) error {
+	leaser := databroker.NewLeaser(leaseName, leaseTTL, p)
+	return leaser.Run(ctx)
+}
+
+// RunLeased runs the publisher while the lease is held.
+func (p *Publisher) RunLeased(ctx context.Context) error {
+	ctx = log.WithContext(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("service", "change_data_capture")
+	})
+
+	for {
+		cfg := p.cfg.Load()
+
+		runCtx, runCancel := context.WithCancel(ctx)
+		eg, egCtx := errgroup.WithContext(runCtx)
+		if cfg.isEnabled() {
+			eg.Go(func() error {
+				return publish(egCtx, cfg)
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-p.updated:
+		}
+		runCancel()
+		_ = eg.Wait()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// GetDataBrokerServiceClient returns the databroker client.
+func (p *Publisher) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return p.cfg.Load().dataBrokerClient
+}
+
+// publish publishes events from the checkpoint until the context is canceled.
+func publish(ctx context.Context, cfg *config) error {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+
+	var cp *checkpoint
+	for {
+		var err error
+		if cp == nil {
+			cp, err = loadCheckpoint(ctx, cfg.dataBrokerClient)
+		}
+		if err == nil {
+			err = follow(ctx, cfg, cp)
+		}
+
+		if status.Code(err) == codes.Aborted {
+			// the storage was reset, so the changes since the checkpoint are lost
+			log.Warn(ctx).Msg("cdc: server version changed, resuming from the latest version")
+			cp, err = latestCheckpoint(ctx, cfg.dataBrokerClient)
+			if err == nil {
+				bo.Reset()
+				continue
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Error(ctx).Err(err).Msg("cdc: error publishing events")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bo.NextBackOff()):
+		}
+	}
+}
+
+// follow delivers the events of the Sync stream, advancing the checkpoint as events are delivered.
+func follow(ctx context.Context, cfg *config, cp *checkpoint) error {
+	stream, err := cfg.dataBrokerClient.Sync(ctx, &databroker.SyncRequest{
+		ServerVersion: cp.ServerVersion,
+		RecordVersion: cp.RecordVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	saved := *cp
+	lastSaved := time.Now()
+	pending := 0
+	save := func(ctx context.Context) error {
+		if saved == *cp {
+			return nil
+		}
+		if err := saveCheckpoint(ctx, cfg.dataBrokerClient, cp); err != nil {
+			return err
+		}
+		saved, lastSaved, pending = *cp, time.Now(), 0
+		return nil
+	}
+	defer func() {
+		// the context may already be canceled, so save the final checkpoint with a new one
+		saveCtx, cancel := context.WithTimeout(context.Background(), checkpointInterval)
+		defer cancel()
+		if err := save(saveCtx); err != nil {
+			log.Error(ctx).Err(err).Msg("cdc: error saving checkpoint")
+		}
+	}()
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		record := res.GetRecord()
+		if cfg.isPublished(record.GetType()) {
+			evt, err := newEvent(cp.ServerVersion, record)
+			if err != nil {
+				log.Error(ctx).Err(err).
+					Str("record-type", record.GetType()).
+					Str("record-id", record.GetId()).
+					Msg("cdc: skipping invalid record")
+			} else if err := cfg.sink.Deliver(ctx, evt); err != nil {
+				return err
+			}
+			pending++
+		}
+		cp.RecordVersion = record.GetVersion()
+
+		// only published events trigger checkpoints, as checkpoints are themselves changes
+		if pending >= checkpointEvents || (pending > 0 && time.Since(lastSaved) >= checkpointInterval) {
+			if err := save(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func loadCheckpoint(ctx context.Context, client databroker.DataBrokerServiceClient) (*checkpoint, error) {
+	cp, err := databroker.GetViaJSON[checkpoint](ctx, client, CheckpointRecordType, checkpointID)
+	if status.Code(err) == codes.NotFound {
+		// start with the changes from now on
+		return latestCheckpoint(ctx, client)
+	} else if err != nil {
+		return nil, err
+	}
+	return cp, nil
+}
+
+func latestCheckpoint(ctx context.Context, client databroker.DataBrokerServiceClient) (*checkpoint, error) {
+	_, recordVersion, serverVersion, err := databroker.InitialSync(ctx, client, &databroker.SyncLatestRequest{
+		Type: CheckpointRecordType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{ServerVersion: serverVersion, RecordVersion: recordVersion}
+	return cp, saveCheckpoint(ctx, client, cp)
+}
+
+func saveCheckpoint(ctx context.Context, client databroker.DataBrokerServiceClient, cp *checkpoint) error {
+	_, err := databroker.PutViaJSON(ctx, client, CheckpointRecordType, checkpointID, cp)
+	return err
+}
@@ -0,0 +1,149 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+func newTestClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+type channelSink chan *Event
+
+func (s channelSink) Deliver(_ context.Context, evt *Event) error {
+	s <- evt
+	return nil
+}
+
+func (s channelSink) next(t *testing.T) *Event {
+	t.Helper()
+
+	select {
+	case evt := <-s:
+		return evt
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t)
+	sink := make(channelSink, 10)
+
+	start := func() context.CancelFunc {
+		ctx, cancel := context.WithCancel(ctx)
+		p := New(WithDataBrokerClient(client), WithSink(sink), WithRecordTypes("TYPE"))
+		done := make(chan struct{})
+		go func() { _ = p.RunLeased(ctx); close(done) }()
+		// wait for the initial checkpoint
+		assert.Eventually(t, func() bool {
+			_, err := databroker.GetViaJSON[checkpoint](ctx, client, CheckpointRecordType, checkpointID)
+			return err == nil
+		}, 10*time.Second, 10*time.Millisecond)
+		return func() { cancel(); <-done }
+	}
+	put := func(recordType, id string, deleted bool) {
+		record := &databroker.Record{Type: recordType, Id: id, Data: protoutil.NewAny(protoutil.NewStructString(id))}
+		if deleted {
+			record.DeletedAt = timestamppb.Now()
+		}
+		_, err := client.Put(ctx, &databroker.PutRequest{Records: []*databroker.Record{record}})
+		require.NoError(t, err)
+	}
+
+	put("TYPE", "before", false)
+	stop := start()
+
+	put("OTHER", "1", false)
+	put("TYPE", "1", false)
+	evt := sink.next(t)
+	assert.Equal(t, OperationUpsert, evt.Operation)
+	assert.Equal(t, "TYPE", evt.RecordType)
+	assert.Equal(t, "1", evt.RecordID, "should only publish changes after starting")
+	assert.JSONEq(t, `{"@type":"type.googleapis.com/google.protobuf.Value","value":"1"}`, string(evt.Data))
+
+	put("TYPE", "1", true)
+	evt = sink.next(t)
+	assert.Equal(t, OperationDelete, evt.Operation)
+	assert.Equal(t, "1", evt.RecordID)
+	stop()
+
+	put("TYPE", "2", false)
+	stop = start()
+	defer stop()
+	evt = sink.next(t)
+	assert.Equal(t, "2", evt.RecordID, "should resume from the checkpoint")
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan *Event, 1)
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, Sign([]byte("SECRET"), body), r.Header.Get(SignatureHeader))
+		var evt Event
+		assert.NoError(t, json.Unmarshal(body, &evt))
+		received <- &evt
+	}))
+	t.Cleanup(srv.Close)
+
+	evt, err := newEvent(1, &databroker.Record{
+		Type:       "type.googleapis.com/session.Session",
+		Id:         "S1",
+		Version:    2,
+		Data:       protoutil.NewAny(&session.Session{Id: "S1", UserId: "U1"}),
+		ModifiedAt: timestamppb.Now(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1-2", evt.ID)
+
+	err = newWebhookSink(srv.URL, []byte("SECRET")).Deliver(context.Background(), evt)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), attempts.Load(), "should retry failed deliveries")
+	assert.Equal(t, evt.ID, (<-received).ID)
+}
@@ -0,0 +1,57 @@
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// Event operations.
+const (
+	// OperationUpsert is the operation of a record which was created or updated. The databroker
+	// doesn't distinguish between the two.
+	OperationUpsert = "upsert"
+	// OperationDelete is the operation of a record which was deleted.
+	OperationDelete = "delete"
+)
+
+// An Event is the change of a databroker record.
+type Event struct {
+	// ID uniquely identifies the event, so that consumers can de-duplicate redelivered events.
+	ID         string          `json:"id"`
+	Operation  string          `json:"operation"`
+	RecordType string          `json:"record_type"`
+	RecordID   string          `json:"record_id"`
+	Version    uint64          `json:"version"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+func newEvent(serverVersion uint64, record *databroker.Record) (*Event, error) {
+	evt := &Event{
+		ID:         fmt.Sprintf("%d-%d", serverVersion, record.GetVersion()),
+		Operation:  OperationUpsert,
+		RecordType: record.GetType(),
+		RecordID:   record.GetId(),
+		Version:    record.GetVersion(),
+		Timestamp:  record.GetModifiedAt().AsTime(),
+	}
+	if record.GetDeletedAt() != nil {
+		evt.Operation = OperationDelete
+		evt.Timestamp = record.GetDeletedAt().AsTime()
+	}
+
+	if record.GetData() != nil {
+		data, err := protojson.Marshal(record.GetData())
+		if err != nil {
+			return nil, fmt.Errorf("error encoding record data: %w", err)
+		}
+		evt.Data = data
+	}
+
+	return evt, nil
+}
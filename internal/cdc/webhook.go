@@ -0,0 +1,87 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// SignatureHeader is the header of the webhook requests containing the signature of the body.
+const SignatureHeader = "X-Pomerium-Signature"
+
+const webhookTimeout = 30 * time.Second
+
+// A webhookSink delivers events by posting them to a url.
+type webhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+func newWebhookSink(url string, secret []byte) *webhookSink {
+	return &webhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Deliver delivers an event, retrying until it's accepted or the context is canceled.
+func (s *webhookSink) Deliver(ctx context.Context, evt *Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+	return backoff.RetryNotify(func() error {
+		return s.post(ctx, body)
+	}, backoff.WithContext(bo, ctx), func(err error, next time.Duration) {
+		log.Warn(ctx).Err(err).
+			Str("event-id", evt.ID).
+			Dur("next", next).
+			Msg("cdc: error delivering event, retrying")
+	})
+}
+
+func (s *webhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected webhook response status: %s", res.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature of a webhook request body.
+func Sign(secret, body []byte) string {
+	h := hmac.New(sha256.New, secret)
+	_, _ = h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
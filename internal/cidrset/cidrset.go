@@ -0,0 +1,134 @@
+// Package cidrset contains a set of IP ranges optimized for matching against large lists, such as
+// threat intelligence block lists.
+package cidrset
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// A Set is a set of IP ranges stored as a binary trie, so lookups are proportional to the address
+// length rather than the number of ranges.
+type Set struct {
+	v4, v6 node
+	size   int
+}
+
+type node struct {
+	children [2]*node
+	// terminal indicates that every address below this node is in the set
+	terminal bool
+}
+
+// New creates a new Set.
+func New(prefixes ...netip.Prefix) *Set {
+	s := new(Set)
+	for _, prefix := range prefixes {
+		s.Insert(prefix)
+	}
+	return s
+}
+
+// Insert adds an IP range to the set.
+func (s *Set) Insert(prefix netip.Prefix) {
+	if !prefix.IsValid() {
+		return
+	}
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+
+	n := s.root(addr)
+	bs := addr.AsSlice()
+	for i := 0; i < bits; i++ {
+		if n.terminal {
+			// already covered by a larger range
+			return
+		}
+		b := bit(bs, i)
+		if n.children[b] == nil {
+			n.children[b] = new(node)
+		}
+		n = n.children[b]
+	}
+	if !n.terminal {
+		s.size++
+	}
+	// any smaller ranges are covered by this one
+	n.terminal, n.children = true, [2]*node{}
+}
+
+// Contains returns true if the address is in one of the IP ranges in the set.
+func (s *Set) Contains(addr netip.Addr) bool {
+	if s == nil || !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+
+	n := s.root(addr)
+	bs := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.terminal {
+			return true
+		}
+		if i >= len(bs)*8 {
+			return false
+		}
+		n = n.children[bit(bs, i)]
+	}
+	return false
+}
+
+// Len returns the number of IP ranges added to the set, excluding ranges that were already covered
+// by a larger range when added.
+func (s *Set) Len() int {
+	if s == nil {
+		return 0
+	}
+	return s.size
+}
+
+func (s *Set) root(addr netip.Addr) *node {
+	if addr.Is4() {
+		return &s.v4
+	}
+	return &s.v6
+}
+
+func bit(bs []byte, i int) int {
+	return int(bs[i/8]>>(7-i%8)) & 1
+}
+
+// Parse parses a list of IP ranges, one per line. Single IP addresses are treated as a range
+// containing only that address. Blank lines and comments starting with # or ; are ignored, as is
+// anything following the range on a line, so that common block list formats can be used as-is.
+func Parse(r io.Reader) (*Set, error) {
+	s := New()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if idx := strings.IndexAny(text, "#;"); idx >= 0 {
+			text = text[:idx]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		prefix, ok := config.ParseIPOrCIDR(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("cidrset: invalid ip range on line %d: %s", line, fields[0])
+		}
+		s.Insert(prefix)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cidrset: error reading ip ranges: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,90 @@
+package cidrset
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	s := New(
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("10.1.0.0/16"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	)
+	assert.Equal(t, 3, s.Len(), "should not count ranges covered by a larger range")
+
+	for _, tc := range []struct {
+		addr   string
+		expect bool
+	}{
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+	} {
+		assert.Equal(t, tc.expect, s.Contains(netip.MustParseAddr(tc.addr)), tc.addr)
+	}
+
+	assert.False(t, s.Contains(netip.Addr{}))
+	assert.False(t, (*Set)(nil).Contains(netip.MustParseAddr("10.0.0.1")))
+
+	t.Run("all", func(t *testing.T) {
+		s := New(netip.MustParsePrefix("0.0.0.0/0"))
+		assert.True(t, s.Contains(netip.MustParseAddr("1.2.3.4")))
+		assert.False(t, s.Contains(netip.MustParseAddr("::1")))
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	s, err := Parse(strings.NewReader(`
+# spamhaus style
+1.2.3.0/24 ; SBL123
+; comment
+5.6.7.8
+2001:db8::/32 # comment
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, s.Len())
+	assert.True(t, s.Contains(netip.MustParseAddr("1.2.3.4")))
+	assert.True(t, s.Contains(netip.MustParseAddr("5.6.7.8")))
+	assert.True(t, s.Contains(netip.MustParseAddr("2001:db8::1")))
+
+	_, err = Parse(strings.NewReader("1.2.3.4\nnot-an-ip\n"))
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	l := NewList([]string{"10.0.0.0/8", "blocklist"})
+	assert.False(t, l.IsEmpty())
+	assert.True(t, NewList(nil).IsEmpty())
+
+	sets := getterFunc(func(name string) *Set {
+		if name == "blocklist" {
+			return New(netip.MustParsePrefix("192.0.2.0/24"))
+		}
+		return nil
+	})
+	assert.True(t, l.Contains(sets, netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, l.Contains(sets, netip.MustParseAddr("192.0.2.1")))
+	assert.False(t, l.Contains(sets, netip.MustParseAddr("198.51.100.1")))
+	assert.False(t, l.Contains(nil, netip.MustParseAddr("192.0.2.1")), "unloaded sets should be empty")
+}
+
+type getterFunc func(name string) *Set
+
+func (f getterFunc) Get(name string) *Set { return f(name) }
@@ -0,0 +1,53 @@
+package cidrset
+
+import (
+	"net/netip"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// A Getter returns the current contents of named sets.
+type Getter interface {
+	Get(name string) *Set
+}
+
+// A List is a policy ip_allowlist or ip_denylist. It matches inline IP ranges and named sets.
+type List struct {
+	inline *Set
+	names  []string
+}
+
+// NewList creates a new List from a list of IP ranges, IP addresses and set names.
+func NewList(entries []string) *List {
+	l := &List{inline: New()}
+	for _, entry := range entries {
+		if prefix, ok := config.ParseIPOrCIDR(entry); ok {
+			l.inline.Insert(prefix)
+		} else {
+			l.names = append(l.names, entry)
+		}
+	}
+	return l
+}
+
+// IsEmpty returns true if the list has no entries.
+func (l *List) IsEmpty() bool {
+	return l == nil || (l.inline.Len() == 0 && len(l.names) == 0)
+}
+
+// Contains returns true if the address is in one of the inline IP ranges or named sets. Named sets
+// which are unknown or have not been loaded yet contain no addresses.
+func (l *List) Contains(sets Getter, addr netip.Addr) bool {
+	if l == nil {
+		return false
+	}
+	if l.inline.Contains(addr) {
+		return true
+	}
+	for _, name := range l.names {
+		if sets != nil && sets.Get(name).Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
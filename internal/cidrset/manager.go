@@ -0,0 +1,162 @@
+package cidrset
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A Manager loads the CIDR sets defined in the config and periodically reloads those from files
+// or URLs. If a reload fails the previously loaded set continues to be used.
+type Manager struct {
+	ctx    context.Context
+	client *http.Client
+
+	mu      sync.RWMutex
+	sets    map[string]*Set
+	loaders map[string]*loader
+}
+
+type loader struct {
+	cfg    config.CIDRSet
+	cancel context.CancelFunc
+}
+
+// NewManager creates a new Manager. Background reloads are stopped when the context is canceled.
+func NewManager(ctx context.Context) *Manager {
+	return &Manager{
+		ctx:     ctx,
+		client:  &http.Client{Timeout: time.Minute},
+		sets:    make(map[string]*Set),
+		loaders: make(map[string]*loader),
+	}
+}
+
+// Get returns the named set, or nil if it is unknown or has not been loaded yet.
+func (mgr *Manager) Get(name string) *Set {
+	if mgr == nil {
+		return nil
+	}
+
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+
+	return mgr.sets[name]
+}
+
+// OnConfigChange updates the sets from the config.
+func (mgr *Manager) OnConfigChange(ctx context.Context, cfg *config.Config) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	cfgs := make(map[string]config.CIDRSet, len(cfg.Options.CIDRSets))
+	for _, s := range cfg.Options.CIDRSets {
+		cfgs[s.Name] = s
+	}
+
+	// stop loaders which are no longer used or whose config has changed
+	for name, l := range mgr.loaders {
+		if s, ok := cfgs[name]; !ok || !cidrSetConfigEqual(s, l.cfg) {
+			l.cancel()
+			delete(mgr.loaders, name)
+		}
+	}
+	for name := range mgr.sets {
+		if _, ok := cfgs[name]; !ok {
+			delete(mgr.sets, name)
+		}
+	}
+
+	for name, s := range cfgs {
+		if len(s.CIDRs) > 0 {
+			set := New()
+			for _, cidr := range s.CIDRs {
+				if prefix, ok := config.ParseIPOrCIDR(cidr); ok {
+					set.Insert(prefix)
+				}
+			}
+			mgr.sets[name] = set
+			continue
+		}
+		if _, ok := mgr.loaders[name]; ok {
+			continue
+		}
+
+		lctx, cancel := context.WithCancel(mgr.ctx)
+		mgr.loaders[name] = &loader{cfg: s, cancel: cancel}
+		go mgr.run(lctx, s)
+	}
+	log.Debug(ctx).Int("cidr_sets", len(cfgs)).Msg("cidrset: updated config")
+}
+
+func (mgr *Manager) run(ctx context.Context, cfg config.CIDRSet) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+	bo.MaxInterval = cfg.GetRefreshInterval()
+
+	for {
+		next := cfg.GetRefreshInterval()
+		s, err := mgr.load(ctx, cfg)
+		if ctx.Err() != nil {
+			return
+		} else if err != nil {
+			log.Error(ctx).Err(err).Str("cidr_set", cfg.Name).Msg("cidrset: error loading cidr set")
+			next = bo.NextBackOff()
+		} else {
+			bo.Reset()
+			mgr.mu.Lock()
+			// the loader may have been replaced while loading
+			if ctx.Err() == nil {
+				mgr.sets[cfg.Name] = s
+			}
+			mgr.mu.Unlock()
+			log.Info(ctx).Str("cidr_set", cfg.Name).Int("size", s.Len()).Msg("cidrset: loaded cidr set")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+	}
+}
+
+func (mgr *Manager) load(ctx context.Context, cfg config.CIDRSet) (*Set, error) {
+	if cfg.File != "" {
+		f, err := os.Open(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return Parse(f)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := mgr.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil, fmt.Errorf("cidrset: unexpected status code from %s: %d", cfg.URL, res.StatusCode)
+	}
+	return Parse(res.Body)
+}
+
+func cidrSetConfigEqual(a, b config.CIDRSet) bool {
+	return a.File == b.File && a.URL == b.URL && a.RefreshInterval == b.RefreshInterval && len(a.CIDRs) == 0
+}
@@ -0,0 +1,70 @@
+package cidrset
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+)
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	body := atomicutil.NewValue("192.0.2.0/24\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body.Load())
+	}))
+	t.Cleanup(srv.Close)
+
+	file := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(file, []byte("198.51.100.0/24\n"), 0o600))
+
+	mgr := NewManager(ctx)
+	mgr.OnConfigChange(ctx, &config.Config{Options: &config.Options{
+		CIDRSets: []config.CIDRSet{
+			{Name: "inline", CIDRs: []string{"10.0.0.0/8"}},
+			{Name: "file", File: file},
+			{Name: "url", URL: srv.URL, RefreshInterval: 50 * time.Millisecond},
+		},
+	}})
+
+	assert.True(t, mgr.Get("inline").Contains(netip.MustParseAddr("10.0.0.1")))
+	assert.Eventually(t, func() bool {
+		return mgr.Get("file").Contains(netip.MustParseAddr("198.51.100.1"))
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return mgr.Get("url").Contains(netip.MustParseAddr("192.0.2.1"))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	t.Run("refresh", func(t *testing.T) {
+		body.Store("203.0.113.0/24\n")
+		assert.Eventually(t, func() bool {
+			return mgr.Get("url").Contains(netip.MustParseAddr("203.0.113.1"))
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+	t.Run("keeps previous set on error", func(t *testing.T) {
+		body.Store("not-an-ip\n")
+		time.Sleep(200 * time.Millisecond)
+		assert.True(t, mgr.Get("url").Contains(netip.MustParseAddr("203.0.113.1")))
+	})
+	t.Run("removed", func(t *testing.T) {
+		mgr.OnConfigChange(ctx, &config.Config{Options: &config.Options{}})
+		assert.Nil(t, mgr.Get("inline"))
+		assert.Nil(t, mgr.Get("file"))
+		assert.Nil(t, mgr.Get("url"))
+	})
+}
@@ -0,0 +1,45 @@
+package controlplane
+
+import (
+	"context"
+	"time"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+)
+
+// configured certificates expiring within this duration are logged as a warning
+const certificateExpiryLogWarning = 30 * 24 * time.Hour
+
+// updateCertificateExpiry records the expiry of every configured certificate and warns
+// about certificates that have expired or will expire soon.
+func (srv *Server) updateCertificateExpiry(ctx context.Context, cfg *config.Config) {
+	certs, err := cfg.GetConfiguredCertificates()
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("controlplane: failed to load configured certificates")
+	}
+
+	now := time.Now()
+	for _, cert := range certs {
+		subject := cert.Subject.String()
+		metrics.SetCertificateExpiry(cert.Source, cert.Route, subject, cert.NotAfter)
+
+		switch {
+		case now.After(cert.NotAfter):
+			log.Error(ctx).
+				Str("source", cert.Source).
+				Str("route", cert.Route).
+				Str("subject", subject).
+				Time("not_after", cert.NotAfter).
+				Msg("controlplane: configured certificate has expired")
+		case now.Add(certificateExpiryLogWarning).After(cert.NotAfter):
+			log.Warn(ctx).
+				Str("source", cert.Source).
+				Str("route", cert.Route).
+				Str("subject", subject).
+				Time("not_after", cert.NotAfter).
+				Msg("controlplane: configured certificate expires soon")
+		}
+	}
+}
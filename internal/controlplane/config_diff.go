@@ -0,0 +1,61 @@
+package controlplane
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+// maxCandidateConfigSize is the maximum size of a candidate config accepted by the config diff endpoint.
+const maxCandidateConfigSize = 10 << 20
+
+// handleConfigDiff accepts a candidate config in the request body and reports how it differs
+// from the running config. The candidate config is never applied. Candidate configs which are
+// too large or invalid are rejected before they are diffed.
+//
+// The config format is taken from the `format` query parameter, falling back to the request
+// content type, and defaults to YAML.
+func (srv *Server) handleConfigDiff(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "yaml"
+		if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt == "application/json" {
+			format = "json"
+		}
+	}
+
+	// the body is read in full first, as the config parser ignores read errors
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCandidateConfigSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			httputil.RenderJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+				"error": fmt.Sprintf("candidate config exceeds the maximum size of %d bytes", maxBytesErr.Limit),
+			})
+			return
+		}
+		httputil.RenderJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	options, err := config.NewOptionsFromReader(bytes.NewReader(raw), format)
+	if err != nil {
+		httputil.RenderJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	current := srv.currentConfig.Load().Config
+	candidate, err := config.NewCandidateConfig(current, options)
+	if err != nil {
+		httputil.RenderJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	httputil.RenderJSON(w, http.StatusOK, config.DiffConfigs(current, candidate))
+}
@@ -0,0 +1,70 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+)
+
+func TestHandleConfigDiff(t *testing.T) {
+	t.Parallel()
+
+	current, err := config.NewOptionsFromReader(strings.NewReader(`
+insecure_server: true
+routes:
+  - from: https://a.example.com
+    to: https://a.internal
+`), "yaml")
+	require.NoError(t, err)
+	require.NoError(t, current.Validate())
+	srv := &Server{
+		currentConfig: atomicutil.NewValue(versionedConfig{Config: &config.Config{Options: current}}),
+	}
+
+	diff := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/debug/config/diff", strings.NewReader(body))
+		srv.handleConfigDiff(w, r)
+		return w
+	}
+
+	t.Run("diff", func(t *testing.T) {
+		t.Parallel()
+
+		w := diff(`
+insecure_server: true
+routes:
+  - from: https://b.example.com
+    to: https://b.internal
+`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var res config.ConfigDiff
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, []string{"https://b.example.com → https://b.internal"}, res.AddedRoutes)
+		assert.Equal(t, []string{"https://a.example.com → https://a.internal"}, res.RemovedRoutes)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		w := diff(`
+insecure_server: true
+http3: true
+`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "http3 requires tls")
+	})
+	t.Run("too large", func(t *testing.T) {
+		t.Parallel()
+
+		w := diff("insecure_server: true\n" + strings.Repeat("#", maxCandidateConfigSize))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
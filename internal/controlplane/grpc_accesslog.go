@@ -1,13 +1,18 @@
 package controlplane
 
 import (
+	"context"
+	"io"
 	"strings"
 
 	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
 	envoy_service_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/google/go-cmp/cmp"
 	"github.com/rs/zerolog"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/syslog"
 )
 
 func (srv *Server) registerAccessLogHandlers() {
@@ -24,67 +29,182 @@ func (srv *Server) StreamAccessLogs(stream envoy_service_accesslog_v3.AccessLogS
 		}
 
 		for _, entry := range msg.GetHttpLogs().LogEntry {
+			options := srv.currentConfig.Load().Config.Options
+			redactor := srv.accessLogRedactor.Load()
+
+			srv.recordRouteRequest(stream.Context(), entry)
+
 			reqPath := entry.GetRequest().GetPath()
-			var evt *zerolog.Event
-			if reqPath == "/ping" || reqPath == "/healthz" {
-				evt = log.Debug(stream.Context())
-			} else {
-				evt = log.Info(stream.Context())
+			level := zerolog.InfoLevel
+			if reqPath == "/ping" || reqPath == "/healthz" || reqPath == "/readyz" {
+				level = zerolog.DebugLevel
 			}
-			evt = evt.Str("service", "envoy")
 
-			fields := srv.currentConfig.Load().Config.Options.GetAccessLogFields()
-			for _, field := range fields {
-				evt = populateLogEvent(field, evt, entry)
-			}
-			// headers are selected in the envoy access logs config, so we can log all of them here
-			if len(entry.GetRequest().GetRequestHeaders()) > 0 {
-				evt = evt.Interface("headers", entry.GetRequest().GetRequestHeaders())
+			logAccessLogEntry(stream.Context(), options, options.AccessLogFormat, nil, level, entry, redactor)
+			if sl := srv.accessLogSyslog.Load(); sl != nil {
+				format := sl.settings.Format
+				if format == "" {
+					format = options.AccessLogFormat
+				}
+				logAccessLogEntry(stream.Context(), options, format, sl.w, level, entry, redactor)
 			}
-			evt.Msg("http-request")
 		}
 	}
 }
 
+// logAccessLogEntry logs an access log entry in the given format. If w is nil, the entry is
+// written to the standard log output.
+func logAccessLogEntry(
+	ctx context.Context,
+	options *config.Options,
+	format log.Format,
+	w io.Writer,
+	level zerolog.Level,
+	entry *envoy_data_accesslog_v3.HTTPAccessLogEntry,
+	redactor *log.Redactor,
+) {
+	if format == log.FormatApacheCombined {
+		if w == nil {
+			log.WriteApacheCombined(level, newApacheCombinedEntry(entry, redactor))
+		} else {
+			log.WriteApacheCombinedTo(w, level, newApacheCombinedEntry(entry, redactor))
+		}
+		return
+	}
+
+	var evt *zerolog.Event
+	if w == nil {
+		evt = log.FormatLogger(ctx, format).WithLevel(level)
+	} else {
+		evt = log.FormatLoggerTo(ctx, format, w).WithLevel(level)
+	}
+	evt = evt.Str("service", "envoy")
+
+	fields := options.GetAccessLogFields()
+	for _, field := range fields {
+		evt = populateLogEvent(field, evt, entry, redactor)
+	}
+	// headers are selected in the envoy access logs config, so we can log all of them here
+	if len(entry.GetRequest().GetRequestHeaders()) > 0 {
+		evt = evt.Interface("headers", redactor.RedactHeaders(entry.GetRequest().GetRequestHeaders()))
+	}
+	evt.Msg("http-request")
+}
+
+// An accessLogSyslog writes access logs to syslog.
+type accessLogSyslog struct {
+	settings config.SyslogSettings
+	w        io.WriteCloser
+}
+
+func (srv *Server) updateAccessLogSyslog(ctx context.Context, cfg *config.Config) {
+	prev := srv.accessLogSyslog.Load()
+	settings := cfg.Options.AccessLogSyslog
+	if prev == nil && settings == nil {
+		return
+	} else if prev != nil && settings != nil && cmp.Equal(prev.settings, *settings) {
+		return
+	}
+
+	var next *accessLogSyslog
+	if settings != nil {
+		w, err := settings.NewWriter(syslog.FacilityUser)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("controlplane: failed to configure access log syslog")
+			return
+		}
+		next = &accessLogSyslog{settings: *settings, w: w}
+	}
+	srv.accessLogSyslog.Store(next)
+	if prev != nil {
+		_ = prev.w.Close()
+	}
+}
+
 func populateLogEvent(
 	field log.AccessLogField,
 	evt *zerolog.Event,
 	entry *envoy_data_accesslog_v3.HTTPAccessLogEntry,
+	redactor *log.Redactor,
 ) *zerolog.Event {
 	referer, _, _ := strings.Cut(entry.GetRequest().GetReferer(), "?")
 	path, query, _ := strings.Cut(entry.GetRequest().GetPath(), "?")
+	str := func(value string) *zerolog.Event {
+		return evt.Str(string(field), redactor.Redact(string(field), value))
+	}
 
 	switch field {
 	case log.AccessLogFieldAuthority:
-		return evt.Str(string(field), entry.GetRequest().GetAuthority())
+		return str(entry.GetRequest().GetAuthority())
 	case log.AccessLogFieldDuration:
 		dur := entry.GetCommonProperties().GetTimeToLastDownstreamTxByte().AsDuration()
 		return evt.Dur(string(field), dur)
 	case log.AccessLogFieldForwardedFor:
-		return evt.Str(string(field), entry.GetRequest().GetForwardedFor())
+		return str(entry.GetRequest().GetForwardedFor())
 	case log.AccessLogFieldIP:
-		return evt.Str(string(field), entry.GetCommonProperties().GetDownstreamRemoteAddress().GetSocketAddress().GetAddress())
+		return str(entry.GetCommonProperties().GetDownstreamRemoteAddress().GetSocketAddress().GetAddress())
 	case log.AccessLogFieldMethod:
-		return evt.Str(string(field), entry.GetRequest().GetRequestMethod().String())
+		return str(entry.GetRequest().GetRequestMethod().String())
 	case log.AccessLogFieldPath:
-		return evt.Str(string(field), path)
+		return str(path)
 	case log.AccessLogFieldQuery:
-		return evt.Str(string(field), query)
+		return str(query)
 	case log.AccessLogFieldReferer:
-		return evt.Str(string(field), referer)
+		return str(referer)
 	case log.AccessLogFieldRequestID:
-		return evt.Str(string(field), entry.GetRequest().GetRequestId())
+		return str(entry.GetRequest().GetRequestId())
 	case log.AccessLogFieldResponseCode:
 		return evt.Uint32(string(field), entry.GetResponse().GetResponseCode().GetValue())
 	case log.AccessLogFieldResponseCodeDetails:
-		return evt.Str(string(field), entry.GetResponse().GetResponseCodeDetails())
+		return str(entry.GetResponse().GetResponseCodeDetails())
 	case log.AccessLogFieldSize:
 		return evt.Uint64(string(field), entry.GetResponse().GetResponseBodyBytes())
 	case log.AccessLogFieldUpstreamCluster:
-		return evt.Str(string(field), entry.GetCommonProperties().GetUpstreamCluster())
+		return str(entry.GetCommonProperties().GetUpstreamCluster())
 	case log.AccessLogFieldUserAgent:
-		return evt.Str(string(field), entry.GetRequest().GetUserAgent())
+		return str(entry.GetRequest().GetUserAgent())
 	default:
 		return evt
 	}
 }
+
+// newApacheCombinedEntry returns an access log entry in the apache combined format. The fields of
+// the entry are redacted like the corresponding access log fields.
+func newApacheCombinedEntry(
+	entry *envoy_data_accesslog_v3.HTTPAccessLogEntry,
+	redactor *log.Redactor,
+) *log.ApacheCombinedEntry {
+	referer, _, _ := strings.Cut(entry.GetRequest().GetReferer(), "?")
+	path, query, hasQuery := strings.Cut(entry.GetRequest().GetPath(), "?")
+	uri := redactor.Redact(string(log.AccessLogFieldPath), path)
+	if hasQuery {
+		uri += "?" + redactor.Redact(string(log.AccessLogFieldQuery), query)
+	}
+
+	var protocol string
+	switch entry.GetProtocolVersion() {
+	case envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP10:
+		protocol = "HTTP/1.0"
+	case envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP11:
+		protocol = "HTTP/1.1"
+	case envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP2:
+		protocol = "HTTP/2.0"
+	case envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP3:
+		protocol = "HTTP/3.0"
+	default:
+		protocol = "-"
+	}
+
+	return &log.ApacheCombinedEntry{
+		RemoteAddr: redactor.Redact(string(log.AccessLogFieldIP),
+			entry.GetCommonProperties().GetDownstreamRemoteAddress().GetSocketAddress().GetAddress()),
+		Time:      entry.GetCommonProperties().GetStartTime().AsTime(),
+		Method:    entry.GetRequest().GetRequestMethod().String(),
+		URI:       uri,
+		Protocol:  protocol,
+		Status:    entry.GetResponse().GetResponseCode().GetValue(),
+		Size:      entry.GetResponse().GetResponseBodyBytes(),
+		Referer:   redactor.Redact(string(log.AccessLogFieldReferer), referer),
+		UserAgent: redactor.Redact(string(log.AccessLogFieldUserAgent), entry.GetRequest().GetUserAgent()),
+	}
+}
@@ -10,7 +10,9 @@ import (
 	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/pomerium/pomerium/internal/log"
@@ -73,10 +75,45 @@ func Test_populateLogEvent(t *testing.T) {
 			var buf bytes.Buffer
 			log := zerolog.New(&buf)
 			evt := log.Log()
-			evt = populateLogEvent(tc.field, evt, entry)
+			evt = populateLogEvent(tc.field, evt, entry, nil)
 			evt.Send()
 
 			assert.Equal(t, tc.expect, strings.TrimSpace(buf.String()))
 		})
 	}
 }
+
+func Test_newApacheCombinedEntry(t *testing.T) {
+	t.Parallel()
+
+	entry := &envoy_data_accesslog_v3.HTTPAccessLogEntry{
+		CommonProperties: &envoy_data_accesslog_v3.AccessLogCommon{
+			DownstreamRemoteAddress: &envoy_config_core_v3.Address{
+				Address: &envoy_config_core_v3.Address_SocketAddress{
+					SocketAddress: &envoy_config_core_v3.SocketAddress{
+						Address: "127.0.0.1",
+					},
+				},
+			},
+			StartTime: timestamppb.New(time.Date(2023, 10, 10, 13, 55, 36, 0, time.UTC)),
+		},
+		ProtocolVersion: envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP11,
+		Request: &envoy_data_accesslog_v3.HTTPRequestProperties{
+			Path:          "/some/path?token=SECRET",
+			Referer:       "https://www.example.com/referer?a=b",
+			RequestMethod: envoy_config_core_v3.RequestMethod_GET,
+			UserAgent:     `USER "AGENT"`,
+		},
+		Response: &envoy_data_accesslog_v3.HTTPResponseProperties{
+			ResponseBodyBytes: 1234,
+			ResponseCode:      wrapperspb.UInt32(200),
+		},
+	}
+
+	redactor, err := log.NewRedactor(log.Redaction{Field: "query", Pattern: "token=.*", Replacement: "token=***"})
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`127.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /some/path?token=*** HTTP/1.1" 200 1234 "https://www.example.com/referer" "USER \"AGENT\""`,
+		newApacheCombinedEntry(entry, redactor).String())
+}
@@ -0,0 +1,156 @@
+package controlplane
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/health"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	healthCheckInterval = 10 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+	// certificates expiring within this duration are reported as a warning
+	certificateExpiryWarning = 7 * 24 * time.Hour
+)
+
+type healthCheck func(ctx context.Context, cfg *config.Config) health.Result
+
+// runHealthChecks periodically checks the dependencies of pomerium, so the health and readiness
+// endpoints can report their status without waiting on them.
+func (srv *Server) runHealthChecks(ctx context.Context) error {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		srv.checkHealth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *Server) checkHealth(ctx context.Context) {
+	cfg := srv.currentConfig.Load().Config
+
+	checks := map[string]healthCheck{
+		health.CheckDataBroker:       srv.checkDataBroker,
+		health.CheckCertificates:     checkCertificates,
+		health.CheckIdentityProvider: checkIdentityProvider,
+	}
+	// the identity provider is only used by the authenticate service
+	if !config.IsAuthenticate(cfg.Options.Services) || cfg.Options.ProviderURL == "" {
+		delete(checks, health.CheckIdentityProvider)
+		health.Delete(health.CheckIdentityProvider)
+	}
+
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		name, check := name, check
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			result := check(ctx, cfg)
+			if result.Status == health.StatusError {
+				log.Warn(ctx).Str("check", name).Str("message", result.Message).Msg("controlplane: health check failed")
+			}
+			health.Set(name, result)
+		}()
+	}
+	wg.Wait()
+}
+
+func (srv *Server) checkDataBroker(ctx context.Context, _ *config.Config) health.Result {
+	client, err := srv.getDataBrokerClient(ctx)
+	if err != nil {
+		return health.Error(err)
+	}
+	if _, err := client.ListTypes(ctx, new(emptypb.Empty)); err != nil {
+		return health.Error(fmt.Errorf("databroker is unreachable: %w", err))
+	}
+	return health.OK()
+}
+
+func checkCertificates(_ context.Context, cfg *config.Config) health.Result {
+	certs, err := cfg.AllCertificates()
+	if err != nil {
+		return health.Error(err)
+	}
+
+	now := time.Now()
+	var earliest *x509.Certificate
+	for _, cert := range certs {
+		if len(cert.Certificate) == 0 {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return health.Error(fmt.Errorf("invalid certificate: %w", err))
+		}
+		if earliest == nil || leaf.NotAfter.Before(earliest.NotAfter) {
+			earliest = leaf
+		}
+	}
+	if earliest == nil {
+		return health.OK()
+	}
+
+	var result health.Result
+	switch {
+	case now.After(earliest.NotAfter):
+		result = health.Error(fmt.Errorf("certificate %s expired at %s",
+			earliest.Subject.CommonName, earliest.NotAfter.Format(time.RFC3339)))
+	case now.Add(certificateExpiryWarning).After(earliest.NotAfter):
+		result = health.Warning(fmt.Sprintf("certificate %s expires at %s",
+			earliest.Subject.CommonName, earliest.NotAfter.Format(time.RFC3339)))
+	default:
+		result = health.OK()
+	}
+	result.Details = map[string]string{
+		"earliest_expiry":         earliest.NotAfter.UTC().Format(time.RFC3339),
+		"earliest_expiry_subject": earliest.Subject.CommonName,
+	}
+	return result
+}
+
+// checkIdentityProvider checks that the identity provider is reachable. Any response other than
+// a server error means it is.
+func checkIdentityProvider(ctx context.Context, cfg *config.Config) health.Result {
+	transport, err := config.GetTLSClientTransport(cfg)
+	if err != nil {
+		return health.Error(err)
+	}
+	transport.DisableKeepAlives = true
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Options.ProviderURL, nil)
+	if err != nil {
+		return health.Error(fmt.Errorf("invalid identity provider url: %w", err))
+	}
+	res, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return health.Error(fmt.Errorf("identity provider is unreachable: %w", err))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	_ = res.Body.Close()
+
+	if res.StatusCode/100 == 5 {
+		return health.Error(fmt.Errorf("identity provider responded with %s", res.Status))
+	}
+	return health.OK()
+}
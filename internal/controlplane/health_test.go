@@ -0,0 +1,87 @@
+package controlplane
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/health"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestCheckCertificates(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newCert := func(domain string, notAfter time.Time) tls.Certificate {
+		cert, err := cryptutil.GenerateCertificate(cryptutil.NewKey(), domain, func(c *x509.Certificate) {
+			c.NotAfter = notAfter
+		})
+		require.NoError(t, err)
+		return *cert
+	}
+
+	for _, tc := range []struct {
+		name   string
+		certs  []tls.Certificate
+		status health.Status
+	}{
+		{"none", nil, health.StatusOK},
+		{"valid", []tls.Certificate{newCert("a.example.com", time.Now().Add(90*24*time.Hour))}, health.StatusOK},
+		{"expiring", []tls.Certificate{
+			newCert("a.example.com", time.Now().Add(90*24*time.Hour)),
+			newCert("b.example.com", time.Now().Add(24*time.Hour)),
+		}, health.StatusWarning},
+		{"expired", []tls.Certificate{newCert("a.example.com", time.Now().Add(-time.Hour))}, health.StatusError},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config.Config{Options: config.NewDefaultOptions(), DerivedCertificates: tc.certs}
+			result := checkCertificates(ctx, cfg)
+			assert.Equal(t, tc.status, result.Status, result.Message)
+		})
+	}
+}
+
+func TestCheckIdentityProvider(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name   string
+		code   int
+		status health.Status
+	}{
+		{"ok", http.StatusOK, health.StatusOK},
+		{"not found", http.StatusNotFound, health.StatusOK},
+		{"server error", http.StatusBadGateway, health.StatusError},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.code)
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{Options: config.NewDefaultOptions()}
+			cfg.Options.ProviderURL = srv.URL
+			assert.Equal(t, tc.status, checkIdentityProvider(ctx, cfg).Status)
+		})
+	}
+
+	cfg := &config.Config{Options: config.NewDefaultOptions()}
+	cfg.Options.ProviderURL = "http://127.0.0.1:1"
+	assert.Equal(t, health.StatusError, checkIdentityProvider(ctx, cfg).Status)
+}
@@ -66,7 +66,8 @@ func (srv *Server) mountCommonEndpoints(root *mux.Router, cfg *config.Config) er
 	}
 	hpkePublicKey := hpkePrivateKey.PublicKey()
 
-	root.HandleFunc("/healthz", handlers.HealthCheck)
+	root.HandleFunc("/healthz", handlers.Liveness)
+	root.HandleFunc("/readyz", handlers.Readiness)
 	root.HandleFunc("/ping", handlers.HealthCheck)
 	root.Handle("/.well-known/pomerium", handlers.WellKnownPomerium(authenticateURL))
 	root.Handle("/.well-known/pomerium/", handlers.WellKnownPomerium(authenticateURL))
@@ -27,6 +27,7 @@ import (
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry"
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+	"github.com/pomerium/pomerium/internal/telemetry/slo"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/internal/version"
 	"github.com/pomerium/pomerium/pkg/envoy/files"
@@ -63,6 +64,11 @@ type Server struct {
 	metricsMgr    *config.MetricsManager
 	reproxy       *reproxy.Handler
 
+	accessLogRedactor *atomicutil.Value[*log.Redactor]
+	accessLogSyslog   *atomicutil.Value[*accessLogSyslog]
+	routeMetricIDs    *atomicutil.Value[[]string]
+	sloTracker        *slo.Tracker
+
 	httpRouter      *atomicutil.Value[*mux.Router]
 	authenticateSvc Service
 	proxySvc        Service
@@ -80,8 +86,17 @@ func NewServer(cfg *config.Config, metricsMgr *config.MetricsManager, eventsMgr
 		currentConfig: atomicutil.NewValue(versionedConfig{
 			Config: cfg,
 		}),
-		httpRouter: atomicutil.NewValue(mux.NewRouter()),
+		httpRouter:        atomicutil.NewValue(mux.NewRouter()),
+		accessLogRedactor: atomicutil.NewValue[*log.Redactor](nil),
+		accessLogSyslog:   atomicutil.NewValue[*accessLogSyslog](nil),
+		routeMetricIDs:    atomicutil.NewValue[[]string](nil),
+		sloTracker:        slo.NewTracker(),
 	}
+	srv.reproxy.SetDataBrokerClient(srv.getDataBrokerClient)
+	srv.updateAccessLogRedactor(context.Background(), cfg)
+	srv.updateAccessLogSyslog(context.Background(), cfg)
+	srv.updateRouteSLOs(cfg)
+	srv.updateCertificateExpiry(context.Background(), cfg)
 
 	var err error
 
@@ -141,8 +156,12 @@ func NewServer(cfg *config.Config, metricsMgr *config.MetricsManager, eventsMgr
 	srv.DebugRouter.Path("/debug/pprof/trace").HandlerFunc(pprof.Trace)
 	srv.DebugRouter.PathPrefix("/debug/pprof/").HandlerFunc(pprof.Index)
 
+	// config
+	srv.DebugRouter.Path("/debug/config/diff").Methods(http.MethodPost).HandlerFunc(srv.handleConfigDiff)
+
 	// metrics
 	srv.MetricsRouter.Handle("/metrics", srv.metricsMgr)
+	srv.MetricsRouter.Path("/metrics/slo").Methods(http.MethodGet).HandlerFunc(srv.handleSLOBudgets)
 
 	srv.filemgr = filemgr.NewManager()
 	srv.filemgr.ClearCache()
@@ -154,6 +173,7 @@ func NewServer(cfg *config.Config, metricsMgr *config.MetricsManager, eventsMgr
 		srv.filemgr,
 		srv.reproxy,
 	)
+	srv.Builder.EnableSecretDiscovery()
 
 	ctx := log.WithContext(context.Background(), func(c zerolog.Context) zerolog.Context {
 		return c.Str("server_name", cfg.Options.Services)
@@ -181,6 +201,10 @@ func (srv *Server) Run(ctx context.Context) error {
 	})
 	defer srv.EventsMgr.Unregister(handle)
 
+	eg.Go(func() error {
+		return srv.runHealthChecks(ctx)
+	})
+
 	// start the gRPC server
 	eg.Go(func() error {
 		log.Info(ctx).Str("addr", srv.GRPCListener.Addr().String()).Msg("starting control-plane gRPC server")
@@ -258,6 +282,10 @@ func (srv *Server) OnConfigChange(ctx context.Context, cfg *config.Config) error
 		return err
 	}
 	srv.reproxy.Update(ctx, cfg)
+	srv.updateAccessLogRedactor(ctx, cfg)
+	srv.updateAccessLogSyslog(ctx, cfg)
+	srv.updateRouteSLOs(cfg)
+	srv.updateCertificateExpiry(ctx, cfg)
 	prev := srv.currentConfig.Load()
 	srv.currentConfig.Store(versionedConfig{
 		Config:  cfg,
@@ -271,6 +299,15 @@ func (srv *Server) OnConfigChange(ctx context.Context, cfg *config.Config) error
 	return nil
 }
 
+func (srv *Server) updateAccessLogRedactor(ctx context.Context, cfg *config.Config) {
+	redactor, err := cfg.Options.GetLogRedactor()
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("controlplane: invalid log redactions")
+		return
+	}
+	srv.accessLogRedactor.Store(redactor)
+}
+
 // EnableAuthenticate enables the authenticate service.
 func (srv *Server) EnableAuthenticate(svc Service) error {
 	srv.authenticateSvc = svc
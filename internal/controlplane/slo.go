@@ -0,0 +1,103 @@
+package controlplane
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/middleware"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+	"github.com/pomerium/pomerium/internal/telemetry/slo"
+)
+
+// policyRouteNamePrefix is the prefix of the names of the envoy routes built for policies. It's
+// followed by the index of the policy.
+const policyRouteNamePrefix = "policy-"
+
+// updateRouteSLOs updates the ids used to tag route metrics and the objectives of the routes.
+func (srv *Server) updateRouteSLOs(cfg *config.Config) {
+	policies := cfg.Options.GetAllPolicies()
+	routeIDs := make([]string, len(policies))
+	objectives := make(map[string]slo.Objective)
+	for i := range policies {
+		policy := &policies[i]
+		routeIDs[i] = getRouteMetricID(policy)
+		if policy.SLO != nil {
+			objectives[routeIDs[i]] = slo.Objective{
+				Availability:     policy.SLO.Availability,
+				LatencyThreshold: policy.SLO.LatencyThreshold,
+				LatencyTarget:    policy.SLO.LatencyTarget,
+				Window:           policy.SLO.GetWindow(),
+			}
+		}
+	}
+	srv.routeMetricIDs.Store(routeIDs)
+	srv.sloTracker.SetObjectives(objectives)
+}
+
+// getRouteMetricID returns the id of a route in metrics.
+func getRouteMetricID(policy *config.Policy) string {
+	id, err := policy.RouteID()
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+// recordRouteRequest records the route metrics of an access log entry, and counts it towards the
+// error budget of the route. Requests which didn't match a policy route are ignored.
+func (srv *Server) recordRouteRequest(ctx context.Context, entry *envoy_data_accesslog_v3.HTTPAccessLogEntry) {
+	idx, ok := strings.CutPrefix(entry.GetCommonProperties().GetRouteName(), policyRouteNamePrefix)
+	if !ok {
+		return
+	}
+	i, err := strconv.Atoi(idx)
+	routeIDs := srv.routeMetricIDs.Load()
+	if err != nil || i < 0 || i >= len(routeIDs) || routeIDs[i] == "" {
+		return
+	}
+	routeID := routeIDs[i]
+
+	decision := getRouteDecision(entry)
+	statusCode := entry.GetResponse().GetResponseCode().GetValue()
+	duration := entry.GetCommonProperties().GetTimeToLastDownstreamTxByte().AsDuration()
+	metrics.RecordRouteRequest(ctx, routeID, decision, statusCode, duration)
+
+	// denied requests are working as intended, so they don't use up the error budget
+	if decision == metrics.RouteDecisionDeny {
+		return
+	}
+	available := decision == metrics.RouteDecisionAllow && statusCode < http.StatusInternalServerError
+	srv.sloTracker.Record(routeID, available, duration)
+}
+
+// getRouteDecision returns the authorization decision of an access log entry.
+func getRouteDecision(entry *envoy_data_accesslog_v3.HTTPAccessLogEntry) string {
+	switch entry.GetResponse().GetResponseCodeDetails() {
+	case "ext_authz_denied":
+		return metrics.RouteDecisionDeny
+	case "ext_authz_error":
+		return metrics.RouteDecisionError
+	default:
+		return metrics.RouteDecisionAllow
+	}
+}
+
+// handleSLOBudgets reports the rolling error budgets of the routes with service level objectives.
+// It's protected by the metrics basic auth, if set.
+func (srv *Server) handleSLOBudgets(w http.ResponseWriter, r *http.Request) {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httputil.RenderJSON(w, http.StatusOK, map[string]any{
+			"routes": srv.sloTracker.Budgets(),
+		})
+	})
+	if username, password, ok := srv.currentConfig.Load().Options.GetMetricsBasicAuth(); ok {
+		h = middleware.RequireBasicAuth(username, password)(h)
+	}
+	h.ServeHTTP(w, r)
+}
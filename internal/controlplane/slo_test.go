@@ -0,0 +1,73 @@
+package controlplane
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/telemetry/slo"
+)
+
+func TestRecordRouteRequest(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Options: config.NewDefaultOptions()}
+	cfg.Options.Routes = []config.Policy{
+		{From: "https://a.example.com"},
+		{From: "https://b.example.com", SLO: &config.PolicySLO{
+			Availability: 0.99,
+		}},
+	}
+	appRouteID := getRouteMetricID(&cfg.Options.Routes[1])
+	srv := &Server{
+		currentConfig:  atomicutil.NewValue(versionedConfig{Config: cfg}),
+		routeMetricIDs: atomicutil.NewValue[[]string](nil),
+		sloTracker:     slo.NewTracker(),
+	}
+	srv.updateRouteSLOs(cfg)
+
+	newEntry := func(routeName string, statusCode uint32, details string) *envoy_data_accesslog_v3.HTTPAccessLogEntry {
+		return &envoy_data_accesslog_v3.HTTPAccessLogEntry{
+			CommonProperties: &envoy_data_accesslog_v3.AccessLogCommon{
+				RouteName:                  routeName,
+				TimeToLastDownstreamTxByte: durationpb.New(time.Millisecond),
+			},
+			Response: &envoy_data_accesslog_v3.HTTPResponseProperties{
+				ResponseCode:        wrapperspb.UInt32(statusCode),
+				ResponseCodeDetails: details,
+			},
+		}
+	}
+
+	ctx := context.Background()
+	srv.recordRouteRequest(ctx, newEntry("policy-1", 200, "via_upstream"))
+	srv.recordRouteRequest(ctx, newEntry("policy-1", 503, "via_upstream"))
+	srv.recordRouteRequest(ctx, newEntry("policy-1", 403, "ext_authz_denied"))
+	srv.recordRouteRequest(ctx, newEntry("policy-1", 500, "ext_authz_error"))
+	srv.recordRouteRequest(ctx, newEntry("policy-0", 500, "via_upstream"))
+	srv.recordRouteRequest(ctx, newEntry("pomerium-path-/.pomerium/", 500, "via_upstream"))
+	srv.recordRouteRequest(ctx, newEntry("policy-7", 500, "via_upstream"))
+
+	budgets := srv.sloTracker.Budgets()
+	if assert.Len(t, budgets, 1) {
+		assert.Equal(t, appRouteID, budgets[0].RouteID)
+		assert.Equal(t, uint64(3), budgets[0].Availability.Total)
+		assert.Equal(t, uint64(2), budgets[0].Availability.Bad)
+	}
+
+	t.Run("endpoint", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		srv.handleSLOBudgets(w, httptest.NewRequest(http.MethodGet, "/metrics/slo", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"route_id":"`+appRouteID+`"`)
+	})
+}
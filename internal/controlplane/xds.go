@@ -14,6 +14,7 @@ const (
 	clusterTypeURL            = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
 	listenerTypeURL           = "type.googleapis.com/envoy.config.listener.v3.Listener"
 	routeConfigurationTypeURL = "type.googleapis.com/envoy.config.route.v3.RouteConfiguration"
+	secretTypeURL             = "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.Secret"
 )
 
 func (srv *Server) buildDiscoveryResources(ctx context.Context) (map[string][]*envoy_service_discovery_v3.Resource, error) {
@@ -56,5 +57,17 @@ func (srv *Server) buildDiscoveryResources(ctx context.Context) (map[string][]*e
 		})
 	}
 
+	secrets, err := srv.Builder.BuildSecrets(ctx, cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets {
+		resources[secretTypeURL] = append(resources[secretTypeURL], &envoy_service_discovery_v3.Resource{
+			Name:     secret.Name,
+			Version:  hex.EncodeToString(cryptutil.HashProto(secret)),
+			Resource: protoutil.NewAny(secret),
+		})
+	}
+
 	return resources, nil
 }
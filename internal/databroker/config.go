@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"time"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 )
@@ -29,6 +30,8 @@ type serverConfig struct {
 	storageCAFile           string
 	storageCertSkipVerify   bool
 	storageCertificate      *tls.Certificate
+	storageEncryption       config.DataBrokerStorageEncryptionSettings
+	storageSharding         config.DataBrokerShardingSettings
 	getAllPageSize          int
 	registryTTL             time.Duration
 }
@@ -117,3 +120,17 @@ func WithStorageCertificate(certificate *tls.Certificate) ServerOption {
 		cfg.storageCertificate = certificate
 	}
 }
+
+// WithStorageEncryption sets the envelope encryption settings of the storage in the config.
+func WithStorageEncryption(settings config.DataBrokerStorageEncryptionSettings) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.storageEncryption = settings
+	}
+}
+
+// WithStorageSharding sets the sharding settings of the storage in the config.
+func WithStorageSharding(settings config.DataBrokerShardingSettings) ServerOption {
+	return func(cfg *serverConfig) {
+		cfg.storageSharding = settings
+	}
+}
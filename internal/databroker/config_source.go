@@ -28,6 +28,7 @@ type ConfigSource struct {
 	computedConfig         *config.Config
 	underlyingConfig       *config.Config
 	dbConfigs              map[string]dbConfig
+	policies               map[string]*config.Policy
 	updaterHash            uint64
 	cancel                 func()
 
@@ -94,7 +95,7 @@ func (src *ConfigSource) rebuild(ctx context.Context, firstTime firstTime) {
 		seen[id] = ""
 	}
 
-	var additionalPolicies []config.Policy
+	var additionalPolicies []*config.Policy
 
 	ids := maps.Keys(src.dbConfigs)
 	sort.Strings(ids)
@@ -104,6 +105,11 @@ func (src *ConfigSource) rebuild(ctx context.Context, firstTime firstTime) {
 		certsIndex.Add(cert)
 	}
 
+	// policies are cached by their protobuf, so that routes which didn't change since the previous
+	// rebuild share their parsed certificates, regexes and other derived data with the previous
+	// config instead of being converted and validated again
+	policies := make(map[string]*config.Policy, len(src.policies))
+
 	// add all the config policies to the list
 	for _, id := range ids {
 		cfgpb := src.dbConfigs[id]
@@ -118,24 +124,29 @@ func (src *ConfigSource) rebuild(ctx context.Context, firstTime firstTime) {
 		}
 
 		for _, routepb := range cfgpb.GetRoutes() {
-			policy, err := config.NewPolicyFromProto(routepb)
-			if err != nil {
-				errCount++
-				log.Warn(ctx).Err(err).
-					Str("db_config_id", id).
-					Msg("databroker: error converting protobuf into policy")
-				continue
-			}
-
-			err = policy.Validate()
-			if err != nil {
-				errCount++
-				log.Warn(ctx).Err(err).
-					Str("db_config_id", id).
-					Str("policy", policy.String()).
-					Msg("databroker: invalid policy, ignoring")
-				continue
+			key := string(cryptutil.HashProto(routepb))
+			policy, ok := src.policies[key]
+			if !ok {
+				policy, err = config.NewPolicyFromProto(routepb)
+				if err != nil {
+					errCount++
+					log.Warn(ctx).Err(err).
+						Str("db_config_id", id).
+						Msg("databroker: error converting protobuf into policy")
+					continue
+				}
+
+				err = policy.Validate()
+				if err != nil {
+					errCount++
+					log.Warn(ctx).Err(err).
+						Str("db_config_id", id).
+						Str("policy", policy.String()).
+						Msg("databroker: invalid policy, ignoring")
+					continue
+				}
 			}
+			policies[key] = policy
 
 			routeID, err := policy.RouteID()
 			if err != nil {
@@ -158,13 +169,14 @@ func (src *ConfigSource) rebuild(ctx context.Context, firstTime firstTime) {
 			}
 			seen[routeID] = id
 
-			additionalPolicies = append(additionalPolicies, *policy)
+			additionalPolicies = append(additionalPolicies, policy)
 		}
 		metrics.SetDBConfigInfo(ctx, cfg.Options.Services, id, cfgpb.version, int64(errCount))
 	}
 
 	// add the additional policies here since calling `Validate` will reset them.
 	cfg.Options.AdditionalPolicies = append(cfg.Options.AdditionalPolicies, additionalPolicies...)
+	src.policies = policies
 
 	src.computedConfig = cfg
 	if !firstTime {
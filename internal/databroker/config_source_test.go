@@ -107,9 +107,34 @@ func TestConfigSource(t *testing.T) {
 		assert.Len(t, cfg.Options.AdditionalPolicies, 1)
 		assert.Len(t, cfg.Options.CertificateFiles, 0, "ignores overlapping certificate")
 	}
+	policies := getPolicies(src)
+	require.Len(t, policies, 1)
 
 	baseSource.SetConfig(ctx, &config.Config{
 		OutboundPort: outboundPort,
 		Options:      base,
 	})
+
+	select {
+	case <-ctx.Done():
+		assert.NoError(t, ctx.Err())
+		return
+	case cfg := <-cfgs:
+		assert.Len(t, cfg.Options.AdditionalPolicies, 1)
+		reused := getPolicies(src)
+		require.Len(t, reused, 1)
+		assert.Same(t, policies[0], reused[0], "the unchanged route should be reused")
+		assert.Same(t, policies[0], cfg.Options.AdditionalPolicies[0], "the config should share the reused route")
+	}
+}
+
+func getPolicies(src *ConfigSource) []*config.Policy {
+	src.mu.RLock()
+	defer src.mu.RUnlock()
+
+	var policies []*config.Policy
+	for _, p := range src.policies {
+		policies = append(policies, p)
+	}
+	return policies
 }
@@ -107,7 +107,8 @@ func (srv *Server) newRegistryLocked(backend storage.Backend) (registry.Interfac
 	}
 
 	switch srv.cfg.storageType {
-	case config.StorageInMemoryName:
+	case config.StorageInMemoryName, config.StorageDynamoDBName:
+		// dynamodb has no change notifications, so services are registered in-memory
 		log.Info(ctx).Msg("using in-memory registry")
 		return inmemory.New(ctx, srv.cfg.registryTTL), nil
 	case config.StorageRedisName:
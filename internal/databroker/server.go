@@ -22,7 +22,9 @@ import (
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/storage"
+	"github.com/pomerium/pomerium/pkg/storage/dynamodb"
 	"github.com/pomerium/pomerium/pkg/storage/inmemory"
+	"github.com/pomerium/pomerium/pkg/storage/keywrap"
 	"github.com/pomerium/pomerium/pkg/storage/postgres"
 	"github.com/pomerium/pomerium/pkg/storage/redis"
 )
@@ -43,6 +45,12 @@ func New(options ...ServerOption) *Server {
 	return srv
 }
 
+// NewBackend creates a new storage backend, the same way the server does.
+func NewBackend(options ...ServerOption) (storage.Backend, error) {
+	srv := &Server{cfg: newServerConfig(options...)}
+	return srv.newBackendLocked()
+}
+
 // UpdateConfig updates the server with the new options.
 func (srv *Server) UpdateConfig(options ...ServerOption) {
 	srv.mu.Lock()
@@ -419,17 +427,46 @@ func (srv *Server) getBackend() (backend storage.Backend, err error) {
 func (srv *Server) newBackendLocked() (backend storage.Backend, err error) {
 	ctx := context.Background()
 
+	if srv.cfg.storageSharding.IsEnabled() {
+		backend, err = srv.newShardedBackendLocked(ctx)
+	} else {
+		backend, err = srv.newStorageBackendLocked(ctx, srv.cfg.storageConnectionString)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if srv.cfg.storageType == config.StorageInMemoryName {
+		return backend, nil
+	}
+
+	if srv.cfg.storageEncryption.IsEnabled() {
+		keyWrappers, err := newKeyWrappers(ctx, &srv.cfg.storageEncryption)
+		if err != nil {
+			_ = backend.Close()
+			return nil, fmt.Errorf("failed to create storage encryption keys: %w", err)
+		}
+		backend, err = storage.NewEnvelopeEncryptedBackend(backend, keyWrappers,
+			storage.WithEnvelopeRecordTypes(srv.cfg.storageEncryption.GetRecordTypes()...),
+			storage.WithDataKeyRotationInterval(srv.cfg.storageEncryption.GetDataKeyRotationInterval()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return backend, nil
+}
+
+func (srv *Server) newStorageBackendLocked(ctx context.Context, connectionString string) (backend storage.Backend, err error) {
 	switch srv.cfg.storageType {
 	case config.StorageInMemoryName:
 		log.Info(ctx).Msg("using in-memory store")
 		return inmemory.New(), nil
 	case config.StoragePostgresName:
 		log.Info(ctx).Msg("using postgres store")
-		backend = postgres.New(srv.cfg.storageConnectionString)
+		backend = postgres.New(connectionString)
 	case config.StorageRedisName:
 		log.Info(ctx).Msg("using redis store")
 		backend, err = redis.New(
-			srv.cfg.storageConnectionString,
+			connectionString,
 			redis.WithTLSConfig(srv.getTLSConfigLocked(ctx)),
 		)
 		if err != nil {
@@ -441,12 +478,84 @@ func (srv *Server) newBackendLocked() (backend storage.Backend, err error) {
 				return nil, err
 			}
 		}
+	case config.StorageDynamoDBName:
+		log.Info(ctx).Msg("using dynamodb store")
+		backend, err = dynamodb.New(connectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create new dynamodb storage: %w", err)
+		}
+		if srv.cfg.secret != nil {
+			backend, err = storage.NewEncryptedBackend(srv.cfg.secret, backend)
+			if err != nil {
+				return nil, err
+			}
+		}
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", srv.cfg.storageType)
 	}
 	return backend, nil
 }
 
+func (srv *Server) newShardedBackendLocked(ctx context.Context) (storage.Backend, error) {
+	settings := &srv.cfg.storageSharding
+	log.Info(ctx).Int("shards", settings.GetShardCount()).Msg("using sharded store")
+
+	connectionStrings := append([]string{srv.cfg.storageConnectionString}, settings.ConnectionStrings...)
+	shards := make([]storage.Backend, 0, len(connectionStrings))
+	closeShards := func() {
+		for _, shard := range shards {
+			_ = shard.Close()
+		}
+	}
+	for _, connectionString := range connectionStrings {
+		shard, err := srv.newStorageBackendLocked(ctx, connectionString)
+		if err != nil {
+			closeShards()
+			return nil, err
+		}
+		shards = append(shards, shard)
+	}
+
+	options := []storage.ShardedOption{
+		storage.WithHashedRecordTypes(settings.GetHashedRecordTypes()...),
+		storage.WithShardedChangeLogSize(settings.GetChangeLogSize()),
+	}
+	for recordType, shard := range settings.RecordTypeShards {
+		options = append(options, storage.WithRecordTypeShard(recordType, shard))
+	}
+	backend, err := storage.NewShardedBackend(shards, options...)
+	if err != nil {
+		closeShards()
+		return nil, err
+	}
+	return backend, nil
+}
+
+func newKeyWrappers(ctx context.Context, settings *config.DataBrokerStorageEncryptionSettings) ([]storage.KeyWrapper, error) {
+	var keyWrappers []storage.KeyWrapper
+	switch settings.Provider {
+	case config.StorageEncryptionProviderAWSKMS:
+		client, err := keywrap.NewAWSKMSClient(ctx, settings.Region)
+		if err != nil {
+			return nil, err
+		}
+		for _, keyID := range settings.KeyIDs {
+			keyWrappers = append(keyWrappers, keywrap.NewAWSKMS(client, keyID))
+		}
+	case config.StorageEncryptionProviderLocal:
+		keys, err := settings.GetKeys()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			keyWrappers = append(keyWrappers, keywrap.NewLocal(key))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage encryption provider: %s", settings.Provider)
+	}
+	return keyWrappers, nil
+}
+
 func (srv *Server) getTLSConfigLocked(ctx context.Context) *tls.Config {
 	caCertPool, err := cryptutil.GetCertPool("", srv.cfg.storageCAFile)
 	if err != nil {
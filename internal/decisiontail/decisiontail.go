@@ -0,0 +1,148 @@
+// Package decisiontail streams live authorize decisions, so access problems can be debugged
+// without searching the logs of every authorize instance.
+//
+// Authorize instances publish their decisions as databroker records of a capped record type. The
+// decisions are tailed by following the databroker Sync stream, which aggregates the decisions of
+// all the instances.
+package decisiontail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+const (
+	// RecordType is the type of the databroker records storing authorize decisions.
+	RecordType = "pomerium.io/AuthorizeDecision"
+	// Capacity is the number of decisions kept in the databroker. Older decisions are deleted.
+	Capacity = 1_000
+)
+
+// A Decision is the result of an authorize check.
+type Decision struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	RouteID   string    `json:"routeId,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserID    string    `json:"userId,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Allow     bool      `json:"allow"`
+	Reasons   []string  `json:"reasons,omitempty"`
+}
+
+// A Filter selects the decisions to tail. Empty fields match any decision.
+type Filter struct {
+	// Route matches the route id or the host of the request.
+	Route string
+	// User matches the user id or the email of the user.
+	User string
+	// DenyOnly only matches denied requests.
+	DenyOnly bool
+}
+
+// Matches returns true if the decision matches the filter.
+func (f Filter) Matches(d *Decision) bool {
+	if f.Route != "" && f.Route != d.RouteID && !strings.EqualFold(f.Route, d.Host) {
+		return false
+	}
+	if f.User != "" && f.User != d.UserID && !strings.EqualFold(f.User, d.Email) {
+		return false
+	}
+	if f.DenyOnly && d.Allow {
+		return false
+	}
+	return true
+}
+
+// Tail calls fn for every decision matching the filter which is published after Tail is called,
+// until the ctx is canceled or fn returns an error.
+func Tail(ctx context.Context, client databroker.DataBrokerServiceClient, filter Filter, fn func(*Decision) error) error {
+	_, recordVersion, serverVersion, err := databroker.InitialSync(ctx, client, &databroker.SyncLatestRequest{
+		Type: RecordType,
+	})
+	if err != nil {
+		return fmt.Errorf("decisiontail: error syncing latest decisions: %w", err)
+	}
+
+	stream, err := client.Sync(ctx, &databroker.SyncRequest{
+		ServerVersion: serverVersion,
+		RecordVersion: recordVersion,
+		Type:          RecordType,
+	})
+	if err != nil {
+		return fmt.Errorf("decisiontail: error syncing decisions: %w", err)
+	}
+
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		// deleted records are decisions beyond the capacity
+		record := res.GetRecord()
+		if record.GetType() != RecordType || record.GetDeletedAt() != nil {
+			continue
+		}
+
+		d, err := decode(record)
+		if err != nil {
+			return err
+		}
+		if !filter.Matches(d) {
+			continue
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+}
+
+func newRecord(d *Decision) (*databroker.Record, error) {
+	bs, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var data structpb.Struct
+	if err := protojson.Unmarshal(bs, &data); err != nil {
+		return nil, err
+	}
+
+	return &databroker.Record{
+		Type: RecordType,
+		Id:   d.ID,
+		Data: protoutil.NewAny(&data),
+	}, nil
+}
+
+func decode(record *databroker.Record) (*Decision, error) {
+	msg, err := record.GetData().UnmarshalNew()
+	if err != nil {
+		return nil, fmt.Errorf("decisiontail: invalid decision record: %w", err)
+	}
+
+	bs, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("decisiontail: invalid decision record: %w", err)
+	}
+
+	var d Decision
+	if err := json.Unmarshal(bs, &d); err != nil {
+		return nil, fmt.Errorf("decisiontail: invalid decision record: %w", err)
+	}
+	return &d, nil
+}
@@ -0,0 +1,116 @@
+package decisiontail
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+func newTestClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+type testProvider struct {
+	client databroker.DataBrokerServiceClient
+}
+
+func (p testProvider) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return p.client
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	d := &Decision{RouteID: "1234", Host: "app.example.com", UserID: "user-1", Email: "user@example.com", Allow: true}
+	for _, tc := range []struct {
+		name   string
+		filter Filter
+		expect bool
+	}{
+		{"empty", Filter{}, true},
+		{"route id", Filter{Route: "1234"}, true},
+		{"route host", Filter{Route: "APP.example.com"}, true},
+		{"other route", Filter{Route: "other.example.com"}, false},
+		{"user id", Filter{User: "user-1"}, true},
+		{"user email", Filter{User: "user@example.com"}, true},
+		{"other user", Filter{User: "user-2"}, false},
+		{"deny only", Filter{DenyOnly: true}, false},
+		{"all", Filter{Route: "1234", User: "user-1"}, true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expect, tc.filter.Matches(d))
+		})
+	}
+}
+
+func TestTail(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := newTestClient(t)
+	p := NewPublisher(testProvider{client})
+	go p.Run(ctx)
+
+	// decisions published before tailing aren't sent
+	p.Publish(&Decision{Host: "before.example.com"})
+	assert.Eventually(t, func() bool {
+		res, err := client.Query(ctx, &databroker.QueryRequest{Type: RecordType, Limit: 1})
+		return err == nil && len(res.GetRecords()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	decisions := make(chan *Decision)
+	go func() {
+		_ = Tail(ctx, client, Filter{DenyOnly: true}, func(d *Decision) error {
+			decisions <- d
+			return nil
+		})
+	}()
+
+	// the tail may not have started yet, so keep publishing until a decision is received
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		p.Publish(&Decision{Host: "allowed.example.com", Allow: true})
+		p.Publish(&Decision{Host: "denied.example.com", Reasons: []string{"user-unauthorized"}})
+
+		select {
+		case d := <-decisions:
+			assert.Equal(t, "denied.example.com", d.Host)
+			assert.Equal(t, []string{"user-unauthorized"}, d.Reasons)
+			assert.NotEmpty(t, d.ID)
+			return
+		case <-ticker.C:
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for decision")
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package decisiontail
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+const (
+	publisherMaxSize       = 1_000
+	publisherFlushInterval = time.Second
+	publisherTimeout       = 3 * time.Second
+)
+
+// A PublisherProvider provides the databroker service client for publishing decisions.
+type PublisherProvider interface {
+	GetDataBrokerServiceClient() databroker.DataBrokerServiceClient
+}
+
+// A Publisher publishes decisions to the databroker. Decisions are buffered and put in batches,
+// so publishing doesn't slow down authorize checks.
+type Publisher struct {
+	provider  PublisherProvider
+	decisions chan *Decision
+
+	haveSetCapacity bool
+	dropped         int64
+}
+
+// NewPublisher creates a new Publisher.
+func NewPublisher(provider PublisherProvider) *Publisher {
+	return &Publisher{
+		provider:  provider,
+		decisions: make(chan *Decision, publisherMaxSize),
+	}
+}
+
+// Publish publishes a decision. If the buffer is full the decision is dropped.
+func (p *Publisher) Publish(d *Decision) {
+	if d.ID == "" {
+		d.ID = uuid.NewString()
+	}
+
+	select {
+	case p.decisions <- d:
+	default:
+		atomic.AddInt64(&p.dropped, 1)
+	}
+}
+
+// Run runs the publisher.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(publisherFlushInterval)
+	defer ticker.Stop()
+
+	var pending []*Decision
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case d := <-p.decisions:
+			pending = append(pending, d)
+			if len(pending) >= publisherMaxSize {
+				p.flush(ctx, pending)
+				pending = nil
+			}
+		case <-ticker.C:
+			if len(pending) > 0 {
+				p.flush(ctx, pending)
+				pending = nil
+			}
+		}
+	}
+}
+
+func (p *Publisher) flush(ctx context.Context, decisions []*Decision) {
+	if dropped := atomic.SwapInt64(&p.dropped, 0); dropped > 0 {
+		log.Warn(ctx).Int64("dropped", dropped).Msg("decisiontail: dropped decisions")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, publisherTimeout)
+	defer cancel()
+
+	client := p.provider.GetDataBrokerServiceClient()
+	if !p.haveSetCapacity {
+		_, err := client.SetOptions(ctx, &databroker.SetOptionsRequest{
+			Type: RecordType,
+			Options: &databroker.Options{
+				Capacity: proto.Uint64(Capacity),
+			},
+		})
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("decisiontail: error setting decision capacity")
+			return
+		}
+		p.haveSetCapacity = true
+	}
+
+	records := make([]*databroker.Record, 0, len(decisions))
+	for _, d := range decisions {
+		record, err := newRecord(d)
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("decisiontail: error encoding decision")
+			continue
+		}
+		records = append(records, record)
+	}
+
+	_, err := client.Put(ctx, &databroker.PutRequest{Records: records})
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("decisiontail: error publishing decisions")
+	}
+}
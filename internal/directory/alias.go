@@ -0,0 +1,37 @@
+package directory
+
+import (
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// The databroker record types of user and group aliases. Alias records are keyed by the identity
+// provider id of the user or group.
+const (
+	UserAliasRecordType  = "pomerium.io/UserAlias"
+	GroupAliasRecordType = "pomerium.io/GroupAlias"
+)
+
+// configAliasProvider marks the alias records written from the config.
+const configAliasProvider = "config"
+
+// An Alias assigns a stable id and friendly aliases to the identity provider ids of a user or group.
+// Policies can refer to the user or group by any of them.
+type Alias struct {
+	ID      string   `json:"id"`
+	IdPIDs  []string `json:"idp_ids"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+func newAliasRecords(recordType string, aliases []Alias) ([]*databroker.Record, error) {
+	var records []*databroker.Record
+	for i := range aliases {
+		for _, idpID := range aliases[i].IdPIDs {
+			record, err := newRecord(recordType, idpID, configAliasProvider, &aliases[i])
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,37 @@
+// Package directory syncs users and groups from a directory provider into the databroker.
+package directory
+
+import (
+	"context"
+)
+
+// The databroker record types of directory users and groups.
+const (
+	UserRecordType  = "pomerium.io/DirectoryUser"
+	GroupRecordType = "pomerium.io/DirectoryGroup"
+)
+
+// A User is a directory user. The id must match the user id of the identity provider.
+type User struct {
+	ID          string   `json:"id"`
+	GroupIDs    []string `json:"group_ids"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+}
+
+// A Group is a directory group. The parent ids are the ids of the groups the group is a direct
+// member of.
+type Group struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	ParentIDs []string `json:"parent_ids,omitempty"`
+}
+
+// A Provider provides the users and groups of a directory. The group ids of users may only include
+// the groups the user is a direct member of, as long as the parent ids of the groups are set. The
+// syncer resolves the groups the user is a member of through nested groups.
+type Provider interface {
+	Name() string
+	GetDirectory(ctx context.Context) ([]*Group, []*User, error)
+}
@@ -0,0 +1,222 @@
+// Package keycloak contains a directory provider for the Keycloak admin API.
+//
+// See https://www.keycloak.org/docs-api/latest/rest-api/
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"github.com/pomerium/pomerium/internal/directory"
+)
+
+// Name is the provider name.
+const Name = "keycloak"
+
+const pageSize = 100
+
+// Options are the options for the Keycloak directory provider.
+type Options struct {
+	// URL is the base url of the Keycloak server.
+	URL          string
+	Realm        string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient is used to make requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// Provider is a Keycloak directory provider.
+type Provider struct {
+	options Options
+	baseURL *url.URL
+}
+
+var _ directory.Provider = (*Provider)(nil)
+
+// New creates a new Keycloak directory provider.
+func New(options Options) (*Provider, error) {
+	baseURL, err := url.Parse(options.URL)
+	if err != nil {
+		return nil, fmt.Errorf("keycloak: invalid url: %w", err)
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = http.DefaultClient
+	}
+	return &Provider{options: options, baseURL: baseURL}, nil
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return Name
+}
+
+type apiGroup struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	SubGroupCount int        `json:"subGroupCount"`
+	SubGroups     []apiGroup `json:"subGroups"`
+}
+
+type apiUser struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// GetDirectory returns the users and groups of the realm. Subgroups are members of their parent
+// group. Disabled users are skipped.
+func (p *Provider) GetDirectory(ctx context.Context) ([]*directory.Group, []*directory.User, error) {
+	client := p.getClient(ctx)
+
+	var groups []*directory.Group
+	var addGroups func(parentID string, apiGroups []apiGroup) error
+	addGroups = func(parentID string, apiGroups []apiGroup) error {
+		for _, g := range apiGroups {
+			group := &directory.Group{ID: g.ID, Name: g.Name}
+			if parentID != "" {
+				group.ParentIDs = []string{parentID}
+			}
+			groups = append(groups, group)
+
+			subGroups := g.SubGroups
+			// newer versions of keycloak don't include the sub groups in the response
+			if len(subGroups) == 0 && g.SubGroupCount > 0 {
+				var err error
+				subGroups, err = listAll[apiGroup](ctx, client, p.getURL("groups", g.ID, "children"))
+				if err != nil {
+					return err
+				}
+			}
+			if err := addGroups(g.ID, subGroups); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	topLevelGroups, err := listAll[apiGroup](ctx, client, p.getURL("groups"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := addGroups("", topLevelGroups); err != nil {
+		return nil, nil, err
+	}
+
+	userGroupIDs := map[string]map[string]struct{}{}
+	for _, g := range groups {
+		members, err := listAll[apiUser](ctx, client, p.getURL("groups", g.ID, "members"))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range members {
+			if userGroupIDs[m.ID] == nil {
+				userGroupIDs[m.ID] = map[string]struct{}{}
+			}
+			userGroupIDs[m.ID][g.ID] = struct{}{}
+		}
+	}
+
+	apiUsers, err := listAll[apiUser](ctx, client, p.getURL("users"))
+	if err != nil {
+		return nil, nil, err
+	}
+	var users []*directory.User
+	for _, u := range apiUsers {
+		if !u.Enabled {
+			continue
+		}
+
+		groupIDs := make([]string, 0, len(userGroupIDs[u.ID]))
+		for groupID := range userGroupIDs[u.ID] {
+			groupIDs = append(groupIDs, groupID)
+		}
+		sort.Strings(groupIDs)
+
+		displayName := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		if displayName == "" {
+			displayName = u.Username
+		}
+		users = append(users, &directory.User{
+			ID:          u.ID,
+			GroupIDs:    groupIDs,
+			DisplayName: displayName,
+			Email:       u.Email,
+		})
+	}
+
+	return groups, users, nil
+}
+
+func (p *Provider) getClient(ctx context.Context) *http.Client {
+	cfg := &clientcredentials.Config{
+		ClientID:     p.options.ClientID,
+		ClientSecret: p.options.ClientSecret,
+		TokenURL:     p.baseURL.JoinPath("realms", p.options.Realm, "protocol", "openid-connect", "token").String(),
+	}
+	return cfg.Client(context.WithValue(ctx, oauth2.HTTPClient, p.options.HTTPClient))
+}
+
+func (p *Provider) getURL(path ...string) *url.URL {
+	return p.baseURL.JoinPath(append([]string{"admin", "realms", p.options.Realm}, path...)...)
+}
+
+// listAll pages through all the results of a list endpoint.
+func listAll[T any](ctx context.Context, client *http.Client, u *url.URL) ([]T, error) {
+	var all []T
+	for first := 0; ; first += pageSize {
+		q := u.Query()
+		q.Set("first", strconv.Itoa(first))
+		q.Set("max", strconv.Itoa(pageSize))
+		q.Set("briefRepresentation", "true")
+		pageURL := *u
+		pageURL.RawQuery = q.Encode()
+
+		var page []T
+		if err := getJSON(ctx, client, pageURL.String(), &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+
+		if len(page) < pageSize {
+			break
+		}
+	}
+	return all, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, rawURL string, obj any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode/100 != 2 {
+		bs, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("keycloak: unexpected status code %d from %s: %s", res.StatusCode, req.URL.Path, bs)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(obj); err != nil {
+		return fmt.Errorf("keycloak: error decoding response from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
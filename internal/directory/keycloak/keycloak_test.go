@@ -0,0 +1,108 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/directory"
+)
+
+func newMockAPI(t *testing.T) http.Handler {
+	t.Helper()
+
+	writeJSON := func(w http.ResponseWriter, obj any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(obj)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/realms/test/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "CLIENT_ID" || password != "CLIENT_SECRET" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"access_token": "ACCESS_TOKEN",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	})
+	r.Route("/admin/realms/test", func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer ACCESS_TOKEN" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		})
+		r.Get("/groups", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []apiGroup{
+				{ID: "admins", Name: "Admins", SubGroupCount: 1},
+				{ID: "users", Name: "Users"},
+			})
+		})
+		r.Get("/groups/{id}/children", func(w http.ResponseWriter, r *http.Request) {
+			switch chi.URLParam(r, "id") {
+			case "admins":
+				writeJSON(w, []apiGroup{{ID: "super-admins", Name: "Super Admins"}})
+			default:
+				writeJSON(w, []apiGroup{})
+			}
+		})
+		r.Get("/groups/{id}/members", func(w http.ResponseWriter, r *http.Request) {
+			switch chi.URLParam(r, "id") {
+			case "super-admins":
+				writeJSON(w, []apiUser{{ID: "user1"}})
+			case "users":
+				writeJSON(w, []apiUser{{ID: "user1"}, {ID: "user2"}, {ID: "user3"}})
+			default:
+				writeJSON(w, []apiUser{})
+			}
+		})
+		r.Get("/users", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, []apiUser{
+				{ID: "user1", Username: "user1", FirstName: "User", LastName: "One", Email: "user1@example.com", Enabled: true},
+				{ID: "user2", Username: "user2", Email: "user2@example.com", Enabled: true},
+				{ID: "user3", Username: "user3", Email: "user3@example.com", Enabled: false},
+			})
+		})
+	})
+	return r
+}
+
+func TestProvider(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(newMockAPI(t))
+	t.Cleanup(srv.Close)
+
+	p, err := New(Options{
+		URL:          srv.URL,
+		Realm:        "test",
+		ClientID:     "CLIENT_ID",
+		ClientSecret: "CLIENT_SECRET",
+	})
+	require.NoError(t, err)
+
+	groups, users, err := p.GetDirectory(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []*directory.Group{
+		{ID: "admins", Name: "Admins"},
+		{ID: "super-admins", Name: "Super Admins", ParentIDs: []string{"admins"}},
+		{ID: "users", Name: "Users"},
+	}, groups)
+	assert.Equal(t, []*directory.User{
+		{ID: "user1", GroupIDs: []string{"super-admins", "users"}, DisplayName: "User One", Email: "user1@example.com"},
+		{ID: "user2", GroupIDs: []string{"users"}, DisplayName: "user2", Email: "user2@example.com"},
+	}, users)
+}
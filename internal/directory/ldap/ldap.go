@@ -0,0 +1,243 @@
+// Package ldap contains a directory provider for LDAP servers, such as Active Directory and OpenLDAP.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/pomerium/pomerium/internal/directory"
+)
+
+// Name is the provider name.
+const Name = "ldap"
+
+// Defaults for the options.
+const (
+	DefaultUserFilter               = "(objectClass=person)"
+	DefaultGroupFilter              = "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))"
+	DefaultUserIDAttribute          = "uid"
+	DefaultUserEmailAttribute       = "mail"
+	DefaultUserDisplayNameAttribute = "displayName"
+	DefaultGroupNameAttribute       = "cn"
+	DefaultGroupMemberAttribute     = "member"
+	DefaultPageSize                 = 500
+)
+
+const timeout = 30 * time.Second
+
+// Options are the options for the LDAP directory provider.
+type Options struct {
+	URL       string
+	StartTLS  bool
+	TLSConfig *tls.Config
+
+	BindDN       string
+	BindPassword string
+
+	BaseDN      string
+	UserFilter  string
+	GroupFilter string
+
+	// UserIDAttribute may be "dn" to use the distinguished name as the user id.
+	UserIDAttribute          string
+	UserEmailAttribute       string
+	UserDisplayNameAttribute string
+	GroupNameAttribute       string
+	GroupMemberAttribute     string
+
+	PageSize uint32
+}
+
+// Provider is an LDAP directory provider.
+type Provider struct {
+	options Options
+}
+
+var _ directory.Provider = (*Provider)(nil)
+
+// New creates a new LDAP directory provider. Empty options are set to their defaults.
+func New(options Options) *Provider {
+	setDefault(&options.UserFilter, DefaultUserFilter)
+	setDefault(&options.GroupFilter, DefaultGroupFilter)
+	setDefault(&options.UserIDAttribute, DefaultUserIDAttribute)
+	setDefault(&options.UserEmailAttribute, DefaultUserEmailAttribute)
+	setDefault(&options.UserDisplayNameAttribute, DefaultUserDisplayNameAttribute)
+	setDefault(&options.GroupNameAttribute, DefaultGroupNameAttribute)
+	setDefault(&options.GroupMemberAttribute, DefaultGroupMemberAttribute)
+	if options.PageSize == 0 {
+		options.PageSize = DefaultPageSize
+	}
+	return &Provider{options: options}
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return Name
+}
+
+// GetDirectory returns the users and groups found in the base DN. Groups are identified by their
+// DN. Users and groups are members of the groups which list them as a member.
+func (p *Provider) GetDirectory(ctx context.Context) ([]*directory.Group, []*directory.User, error) {
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	userEntries, err := p.search(conn, p.options.UserFilter, []string{
+		p.options.UserIDAttribute,
+		p.options.UserEmailAttribute,
+		p.options.UserDisplayNameAttribute,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ldap: error searching for users: %w", err)
+	}
+
+	groupEntries, err := p.search(conn, p.options.GroupFilter, []string{
+		p.options.GroupNameAttribute,
+		p.options.GroupMemberAttribute,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("ldap: error searching for groups: %w", err)
+	}
+
+	return p.buildDirectory(groupEntries, userEntries)
+}
+
+func (p *Provider) buildDirectory(groupEntries, userEntries []*ldap.Entry) ([]*directory.Group, []*directory.User, error) {
+	// parents maps the DN of a group or user to the DNs of the groups they are a direct member of
+	parents := map[string][]string{}
+	for _, entry := range groupEntries {
+		for _, memberDN := range entry.GetAttributeValues(p.options.GroupMemberAttribute) {
+			key := normalizeDN(memberDN)
+			parents[key] = append(parents[key], entry.DN)
+		}
+	}
+
+	var groups []*directory.Group
+	for _, entry := range groupEntries {
+		groups = append(groups, &directory.Group{
+			ID:        entry.DN,
+			Name:      entry.GetAttributeValue(p.options.GroupNameAttribute),
+			ParentIDs: getGroupIDs(parents, entry.DN),
+		})
+	}
+
+	var users []*directory.User
+	for _, entry := range userEntries {
+		id := entry.GetAttributeValue(p.options.UserIDAttribute)
+		if strings.EqualFold(p.options.UserIDAttribute, "dn") {
+			id = entry.DN
+		}
+		if id == "" {
+			continue
+		}
+
+		users = append(users, &directory.User{
+			ID:          id,
+			GroupIDs:    getGroupIDs(parents, entry.DN),
+			DisplayName: entry.GetAttributeValue(p.options.UserDisplayNameAttribute),
+			Email:       entry.GetAttributeValue(p.options.UserEmailAttribute),
+		})
+	}
+
+	return groups, users, nil
+}
+
+// getGroupIDs returns the DNs of the groups the entry is a direct member of. Nested groups are
+// resolved by the syncer.
+func getGroupIDs(parents map[string][]string, dn string) []string {
+	seen := map[string]struct{}{}
+	var groupIDs []string
+	for _, groupDN := range parents[normalizeDN(dn)] {
+		key := normalizeDN(groupDN)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		groupIDs = append(groupIDs, groupDN)
+	}
+	sort.Strings(groupIDs)
+	return groupIDs
+}
+
+func (p *Provider) connect(ctx context.Context) (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(p.options.URL, ldap.DialWithTLSConfig(p.options.TLSConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: error connecting to server: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	// close the connection when the context is canceled, which aborts any requests
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if p.options.StartTLS {
+		if err := conn.StartTLS(p.options.TLSConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: error starting tls: %w", err)
+		}
+	}
+
+	if p.options.BindDN != "" {
+		err = conn.Bind(p.options.BindDN, p.options.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ldap: error binding: %w", err)
+	}
+
+	return conn, nil
+}
+
+func (p *Provider) search(conn *ldap.Conn, filter string, attributes []string) ([]*ldap.Entry, error) {
+	res, err := conn.SearchWithPaging(ldap.NewSearchRequest(
+		p.options.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		filter,
+		attributes,
+		nil,
+	), p.options.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return res.Entries, nil
+}
+
+// normalizeDN normalizes a DN so that equivalent DNs can be compared.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+
+	rdns := make([]string, 0, len(parsed.RDNs))
+	for _, rdn := range parsed.RDNs {
+		attributes := make([]string, 0, len(rdn.Attributes))
+		for _, attribute := range rdn.Attributes {
+			attributes = append(attributes, strings.ToLower(attribute.Type)+"="+strings.ToLower(attribute.Value))
+		}
+		rdns = append(rdns, strings.Join(attributes, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
+
+func setDefault(value *string, defaultValue string) {
+	if *value == "" {
+		*value = defaultValue
+	}
+}
@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/directory"
+)
+
+func TestProvider_buildDirectory(t *testing.T) {
+	t.Parallel()
+
+	p := New(Options{})
+
+	groups, users, err := p.buildDirectory([]*ldap.Entry{
+		ldap.NewEntry("cn=admins,ou=groups,dc=example,dc=com", map[string][]string{
+			"cn":     {"admins"},
+			"member": {"uid=user1,ou=people,dc=example,dc=com", "CN=Operators,OU=Groups,DC=example,DC=com"},
+		}),
+		ldap.NewEntry("cn=operators,ou=groups,dc=example,dc=com", map[string][]string{
+			"cn":     {"operators"},
+			"member": {"uid=user2,ou=people,dc=example,dc=com", "cn=admins,ou=groups,dc=example,dc=com"},
+		}),
+		ldap.NewEntry("cn=users,ou=groups,dc=example,dc=com", map[string][]string{
+			"cn":     {"users"},
+			"member": {"uid=user3,ou=people,dc=example,dc=com"},
+		}),
+	}, []*ldap.Entry{
+		ldap.NewEntry("uid=user1,ou=people,dc=example,dc=com", map[string][]string{
+			"uid":         {"user1"},
+			"mail":        {"user1@example.com"},
+			"displayName": {"User One"},
+		}),
+		ldap.NewEntry("uid=user2,ou=people,dc=example,dc=com", map[string][]string{
+			"uid":  {"user2"},
+			"mail": {"user2@example.com"},
+		}),
+		ldap.NewEntry("uid=user3,ou=people,dc=example,dc=com", map[string][]string{
+			"uid": {"user3"},
+		}),
+		ldap.NewEntry("cn=no-uid,ou=people,dc=example,dc=com", map[string][]string{}),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*directory.Group{
+		{ID: "cn=admins,ou=groups,dc=example,dc=com", Name: "admins", ParentIDs: []string{"cn=operators,ou=groups,dc=example,dc=com"}},
+		{ID: "cn=operators,ou=groups,dc=example,dc=com", Name: "operators", ParentIDs: []string{"cn=admins,ou=groups,dc=example,dc=com"}},
+		{ID: "cn=users,ou=groups,dc=example,dc=com", Name: "users"},
+	}, groups)
+	assert.Equal(t, []*directory.User{
+		{
+			ID:          "user1",
+			GroupIDs:    []string{"cn=admins,ou=groups,dc=example,dc=com"},
+			DisplayName: "User One",
+			Email:       "user1@example.com",
+		},
+		{
+			ID:       "user2",
+			GroupIDs: []string{"cn=operators,ou=groups,dc=example,dc=com"},
+			Email:    "user2@example.com",
+		},
+		{
+			ID:       "user3",
+			GroupIDs: []string{"cn=users,ou=groups,dc=example,dc=com"},
+		},
+	}, users)
+}
@@ -0,0 +1,68 @@
+package directory
+
+import (
+	"sort"
+)
+
+// expandNestedGroups returns the users with the groups they are a member of through nested groups
+// added to their group ids, following at most depth levels of parent groups.
+func expandNestedGroups(groups []*Group, users []*User, depth int) []*User {
+	parents := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		if len(g.ParentIDs) > 0 {
+			parents[g.ID] = g.ParentIDs
+		}
+	}
+	if len(parents) == 0 || depth <= 0 {
+		return users
+	}
+
+	expanded := make([]*User, 0, len(users))
+	for _, u := range users {
+		groupIDs := getNestedGroupIDs(parents, u.GroupIDs, depth)
+		if len(groupIDs) == len(u.GroupIDs) {
+			expanded = append(expanded, u)
+			continue
+		}
+
+		u := *u
+		u.GroupIDs = groupIDs
+		expanded = append(expanded, &u)
+	}
+	return expanded
+}
+
+// getNestedGroupIDs returns the group ids along with the ids of their ancestors, up to depth levels
+// up. The result is sorted if any groups were added.
+func getNestedGroupIDs(parents map[string][]string, groupIDs []string, depth int) []string {
+	seen := make(map[string]struct{}, len(groupIDs))
+	for _, id := range groupIDs {
+		seen[id] = struct{}{}
+	}
+
+	result := groupIDs
+	level := groupIDs
+	for i := 0; i < depth && len(level) > 0; i++ {
+		var next []string
+		for _, id := range level {
+			for _, parentID := range parents[id] {
+				// groups may be nested in a cycle
+				if _, ok := seen[parentID]; ok {
+					continue
+				}
+				seen[parentID] = struct{}{}
+				next = append(next, parentID)
+			}
+		}
+		if len(next) > 0 && len(result) == len(groupIDs) {
+			result = append([]string(nil), groupIDs...)
+		}
+		result = append(result, next...)
+		level = next
+	}
+
+	if len(result) != len(groupIDs) {
+		sort.Strings(result)
+	}
+	return result
+}
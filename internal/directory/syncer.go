@@ -0,0 +1,288 @@
+package directory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// providerField marks the records written by the syncer, so that records written by other means,
+// like SCIM, aren't removed.
+const providerField = "directory_provider"
+
+var (
+	defaultSyncInterval     = 10 * time.Minute
+	defaultNestedGroupDepth = 10
+)
+
+type config struct {
+	dataBrokerClient databroker.DataBrokerServiceClient
+	provider         Provider
+	syncInterval     time.Duration
+	nestedGroupDepth int
+	userAliases      []Alias
+	groupAliases     []Alias
+}
+
+// An Option customizes the configuration used for the syncer.
+type Option func(*config)
+
+// WithDataBrokerClient sets the databroker client in the config.
+func WithDataBrokerClient(dataBrokerClient databroker.DataBrokerServiceClient) Option {
+	return func(cfg *config) {
+		cfg.dataBrokerClient = dataBrokerClient
+	}
+}
+
+// WithProvider sets the directory provider in the config. Without a provider nothing is synced.
+func WithProvider(provider Provider) Option {
+	return func(cfg *config) {
+		cfg.provider = provider
+	}
+}
+
+// WithSyncInterval sets how often the directory is synced.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.syncInterval = interval
+	}
+}
+
+// WithNestedGroupDepth sets how many levels of nested groups are resolved. With a depth of 0 users
+// are only members of the groups they are a direct member of.
+func WithNestedGroupDepth(depth int) Option {
+	return func(cfg *config) {
+		cfg.nestedGroupDepth = depth
+	}
+}
+
+// WithAliases sets the user and group aliases synced into the databroker.
+func WithAliases(userAliases, groupAliases []Alias) Option {
+	return func(cfg *config) {
+		cfg.userAliases = userAliases
+		cfg.groupAliases = groupAliases
+	}
+}
+
+func newConfig(options ...Option) *config {
+	cfg := new(config)
+	WithSyncInterval(defaultSyncInterval)(cfg)
+	WithNestedGroupDepth(defaultNestedGroupDepth)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+// A Syncer periodically syncs the users and groups of a directory provider, along with the
+// configured aliases, into the databroker.
+type Syncer struct {
+	cfg     *atomicutil.Value[*config]
+	updated chan struct{}
+}
+
+// NewSyncer creates a new Syncer.
+func NewSyncer(options ...Option) *Syncer {
+	return &Syncer{
+		cfg:     atomicutil.NewValue(newConfig(options...)),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the syncer with the new options, syncing immediately.
+func (s *Syncer) UpdateConfig(options ...Option) {
+	s.cfg.Store(newConfig(options...))
+	select {
+	case s.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the syncer. Only a single syncer runs at a time across all the databroker instances.
+func (s *Syncer) Run(ctx context.Context) error {
+	leaser := databroker.NewLeaser("directory_sync", time.Second*30, s)
+	return leaser.Run(ctx)
+}
+
+// RunLeased runs the syncer while the lease is held.
+func (s *Syncer) RunLeased(ctx context.Context) error {
+	ctx = log.WithContext(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("service", "directory_sync")
+	})
+
+	for {
+		cfg := s.cfg.Load()
+		if err := s.syncAliases(ctx, cfg); err != nil {
+			log.Error(ctx).Err(err).Msg("directory: error syncing aliases")
+		}
+		if cfg.provider != nil {
+			if err := s.sync(ctx, cfg); err != nil {
+				log.Error(ctx).Err(err).Str("provider", cfg.provider.Name()).Msg("directory: error syncing directory")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.updated:
+		case <-time.After(cfg.syncInterval):
+		}
+	}
+}
+
+// GetDataBrokerServiceClient returns the databroker client.
+func (s *Syncer) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return s.cfg.Load().dataBrokerClient
+}
+
+func (s *Syncer) sync(ctx context.Context, cfg *config) error {
+	groups, users, err := cfg.provider.GetDirectory(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting directory: %w", err)
+	}
+	users = expandNestedGroups(groups, users, cfg.nestedGroupDepth)
+
+	var records []*databroker.Record
+	for _, g := range groups {
+		record, err := newRecord(GroupRecordType, g.ID, cfg.provider.Name(), g)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	for _, u := range users {
+		record, err := newRecord(UserRecordType, u.ID, cfg.provider.Name(), u)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	if err := putSyncedRecords(ctx, cfg.dataBrokerClient, []string{GroupRecordType, UserRecordType}, records); err != nil {
+		return err
+	}
+
+	log.Info(ctx).
+		Str("provider", cfg.provider.Name()).
+		Int("groups", len(groups)).
+		Int("users", len(users)).
+		Msg("directory: synced directory")
+	return nil
+}
+
+func (s *Syncer) syncAliases(ctx context.Context, cfg *config) error {
+	userRecords, err := newAliasRecords(UserAliasRecordType, cfg.userAliases)
+	if err != nil {
+		return err
+	}
+	groupRecords, err := newAliasRecords(GroupAliasRecordType, cfg.groupAliases)
+	if err != nil {
+		return err
+	}
+	return putSyncedRecords(ctx, cfg.dataBrokerClient,
+		[]string{UserAliasRecordType, GroupAliasRecordType},
+		append(userRecords, groupRecords...))
+}
+
+// putSyncedRecords puts the records and deletes the records of the given types previously written
+// by the syncer which are no longer present.
+func putSyncedRecords(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	recordTypes []string,
+	records []*databroker.Record,
+) error {
+	current := make(map[string]struct{}, len(records))
+	for _, record := range records {
+		current[record.GetType()+"/"+record.GetId()] = struct{}{}
+	}
+	for _, recordType := range recordTypes {
+		ids, err := getSyncedRecordIDs(ctx, client, recordType)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, ok := current[recordType+"/"+id]; ok {
+				continue
+			}
+			records = append(records, &databroker.Record{
+				Type:      recordType,
+				Id:        id,
+				Data:      protoutil.NewAny(new(structpb.Struct)),
+				DeletedAt: timestamppb.Now(),
+			})
+		}
+	}
+
+	for _, req := range databroker.OptimumPutRequestsFromRecords(records) {
+		if _, err := client.Put(ctx, req); err != nil {
+			return fmt.Errorf("error updating directory records: %w", err)
+		}
+	}
+	return nil
+}
+
+// getSyncedRecordIDs returns the ids of the records of the given type written by the syncer.
+func getSyncedRecordIDs(ctx context.Context, client databroker.DataBrokerServiceClient, recordType string) ([]string, error) {
+	const pageSize = 1000
+
+	var ids []string
+	for offset := int64(0); ; offset += pageSize {
+		res, err := client.Query(ctx, &databroker.QueryRequest{
+			Type:   recordType,
+			Offset: offset,
+			Limit:  pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range res.GetRecords() {
+			var data structpb.Struct
+			if err := record.GetData().UnmarshalTo(&data); err != nil {
+				continue
+			}
+			if data.GetFields()[providerField].GetStringValue() != "" {
+				ids = append(ids, record.GetId())
+			}
+		}
+
+		if offset+pageSize >= res.GetTotalCount() {
+			break
+		}
+	}
+	return ids, nil
+}
+
+func newRecord(recordType, recordID, providerName string, obj any) (*databroker.Record, error) {
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var data structpb.Struct
+	if err := protojson.Unmarshal(bs, &data); err != nil {
+		return nil, err
+	}
+	if data.Fields == nil {
+		data.Fields = make(map[string]*structpb.Value)
+	}
+	data.Fields[providerField] = structpb.NewStringValue(providerName)
+
+	return &databroker.Record{
+		Type: recordType,
+		Id:   recordID,
+		Data: protoutil.NewAny(&data),
+	}, nil
+}
@@ -0,0 +1,195 @@
+package directory
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+type mockProvider struct {
+	groups []*Group
+	users  []*User
+}
+
+func (p *mockProvider) Name() string { return "mock" }
+
+func (p *mockProvider) GetDirectory(_ context.Context) ([]*Group, []*User, error) {
+	return p.groups, p.users, nil
+}
+
+func newTestClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+func getRecordIDs(t *testing.T, client databroker.DataBrokerServiceClient, recordType string) []string {
+	t.Helper()
+
+	res, err := client.Query(context.Background(), &databroker.QueryRequest{
+		Type:  recordType,
+		Limit: 100,
+	})
+	require.NoError(t, err)
+
+	var ids []string
+	for _, record := range res.GetRecords() {
+		ids = append(ids, record.GetId())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSyncer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// a user provisioned by other means, like SCIM
+	_, err := client.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{{
+			Type: UserRecordType,
+			Id:   "scim-user",
+			Data: protoutil.NewAny(newStruct(t, map[string]any{"id": "scim-user"})),
+		}},
+	})
+	require.NoError(t, err)
+
+	provider := &mockProvider{
+		groups: []*Group{{ID: "g1", Name: "Group 1"}, {ID: "g2", Name: "Group 2"}},
+		users: []*User{
+			{ID: "u1", GroupIDs: []string{"g1"}, Email: "u1@example.com"},
+			{ID: "u2", GroupIDs: []string{"g1", "g2"}, Email: "u2@example.com"},
+		},
+	}
+	s := NewSyncer(WithDataBrokerClient(client), WithProvider(provider))
+	require.NoError(t, s.sync(ctx, s.cfg.Load()))
+	assert.Equal(t, []string{"g1", "g2"}, getRecordIDs(t, client, GroupRecordType))
+	assert.Equal(t, []string{"scim-user", "u1", "u2"}, getRecordIDs(t, client, UserRecordType))
+
+	res, err := client.Get(ctx, &databroker.GetRequest{Type: UserRecordType, Id: "u2"})
+	require.NoError(t, err)
+	var data structpb.Struct
+	require.NoError(t, res.GetRecord().GetData().UnmarshalTo(&data))
+	assert.Equal(t, map[string]any{
+		"id":                 "u2",
+		"group_ids":          []any{"g1", "g2"},
+		"display_name":       "",
+		"email":              "u2@example.com",
+		"directory_provider": "mock",
+	}, data.AsMap())
+
+	// removed users and groups are deleted
+	provider.groups = provider.groups[:1]
+	provider.users = provider.users[:1]
+	require.NoError(t, s.sync(ctx, s.cfg.Load()))
+	assert.Equal(t, []string{"g1"}, getRecordIDs(t, client, GroupRecordType))
+	assert.Equal(t, []string{"scim-user", "u1"}, getRecordIDs(t, client, UserRecordType))
+}
+
+func TestSyncer_Aliases(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t)
+
+	// an alias managed through the databroker API
+	_, err := client.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{{
+			Type: UserAliasRecordType,
+			Id:   "api-user",
+			Data: protoutil.NewAny(newStruct(t, map[string]any{"id": "bob"})),
+		}},
+	})
+	require.NoError(t, err)
+
+	s := NewSyncer(WithDataBrokerClient(client), WithAliases(
+		[]Alias{{ID: "alice", IdPIDs: []string{"old-sub", "new-sub"}, Aliases: []string{"alice@example.com"}}},
+		[]Alias{{ID: "engineering", IdPIDs: []string{"g1"}}},
+	))
+	require.NoError(t, s.syncAliases(ctx, s.cfg.Load()))
+	assert.Equal(t, []string{"api-user", "new-sub", "old-sub"}, getRecordIDs(t, client, UserAliasRecordType))
+	assert.Equal(t, []string{"g1"}, getRecordIDs(t, client, GroupAliasRecordType))
+
+	res, err := client.Get(ctx, &databroker.GetRequest{Type: UserAliasRecordType, Id: "new-sub"})
+	require.NoError(t, err)
+	var data structpb.Struct
+	require.NoError(t, res.GetRecord().GetData().UnmarshalTo(&data))
+	assert.Equal(t, map[string]any{
+		"id":                 "alice",
+		"idp_ids":            []any{"old-sub", "new-sub"},
+		"aliases":            []any{"alice@example.com"},
+		"directory_provider": "config",
+	}, data.AsMap())
+
+	// removed aliases are deleted
+	s.UpdateConfig(WithDataBrokerClient(client))
+	require.NoError(t, s.syncAliases(ctx, s.cfg.Load()))
+	assert.Equal(t, []string{"api-user"}, getRecordIDs(t, client, UserAliasRecordType))
+	assert.Empty(t, getRecordIDs(t, client, GroupAliasRecordType))
+}
+
+func TestExpandNestedGroups(t *testing.T) {
+	t.Parallel()
+
+	// g1 <- g2 <- g3 <- g4, with a cycle between g4 and g3
+	groups := []*Group{
+		{ID: "g1"},
+		{ID: "g2", ParentIDs: []string{"g1"}},
+		{ID: "g3", ParentIDs: []string{"g2", "g4"}},
+		{ID: "g4", ParentIDs: []string{"g3"}},
+		{ID: "other"},
+	}
+	users := []*User{
+		{ID: "u1", GroupIDs: []string{"g4", "other"}},
+		{ID: "u2", GroupIDs: []string{"g1"}},
+	}
+
+	for _, tc := range []struct {
+		depth  int
+		expect []string
+	}{
+		{0, []string{"g4", "other"}},
+		{1, []string{"g3", "g4", "other"}},
+		{2, []string{"g2", "g3", "g4", "other"}},
+		{10, []string{"g1", "g2", "g3", "g4", "other"}},
+	} {
+		expanded := expandNestedGroups(groups, users, tc.depth)
+		assert.Equal(t, tc.expect, expanded[0].GroupIDs, "depth %d", tc.depth)
+		assert.Equal(t, []string{"g1"}, expanded[1].GroupIDs, "depth %d", tc.depth)
+	}
+	assert.Equal(t, []string{"g4", "other"}, users[0].GroupIDs, "should not modify the provider's users")
+}
+
+func newStruct(t *testing.T, m map[string]any) *structpb.Struct {
+	t.Helper()
+
+	s, err := structpb.NewStruct(m)
+	require.NoError(t, err)
+	return s
+}
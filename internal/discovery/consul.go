@@ -0,0 +1,136 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	defaultConsulAddress = "http://127.0.0.1:8500"
+	consulWaitTime       = time.Minute * 5
+)
+
+type consulResolver struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+func newConsulResolver(address, token string) *consulResolver {
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	return &consulResolver{
+		address: address,
+		token:   token,
+		client:  &http.Client{Timeout: consulWaitTime + time.Minute},
+	}
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    uint16
+		Weights struct {
+			Passing uint32
+		}
+	}
+}
+
+func (r *consulResolver) watch(ctx context.Context, t target, update func([]endpoint)) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+
+	var index uint64
+	for {
+		endpoints, nextIndex, err := r.query(ctx, t.name, index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn(ctx).Err(err).Str("target", t.String()).Msg("discovery: error querying consul")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(bo.NextBackOff()):
+			}
+			continue
+		}
+		bo.Reset()
+
+		// consul recommends resetting the index if it goes backwards
+		if nextIndex < index {
+			nextIndex = 0
+		}
+		index = nextIndex
+		update(endpoints)
+	}
+}
+
+// query performs a blocking query for the passing instances of a service.
+func (r *consulResolver) query(ctx context.Context, service string, index uint64) ([]endpoint, uint64, error) {
+	u, err := url.Parse(r.address)
+	if err != nil {
+		return nil, 0, err
+	}
+	u = u.JoinPath("/v1/health/service", service)
+	q := u.Query()
+	q.Set("passing", "true")
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%ds", int(consulWaitTime.Seconds())))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.token != "" {
+		req.Header.Set("X-Consul-Token", r.token)
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("invalid response: %w", err)
+	}
+
+	nextIndex, _ := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+
+	endpoints := make([]endpoint, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		endpoints = append(endpoints, endpoint{
+			host:   host,
+			port:   entry.Service.Port,
+			weight: entry.Service.Weights.Passing,
+		})
+	}
+	sortEndpoints(endpoints)
+	return endpoints, nextIndex, nil
+}
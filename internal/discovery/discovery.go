@@ -0,0 +1,138 @@
+// Package discovery resolves route upstreams which refer to a service name rather than
+// a static host.
+//
+// Two kinds of upstreams are supported:
+//
+//   - `srv+https://_http._tcp.example.com` polls the DNS SRV records for the name.
+//   - `consul+https://service-name` watches the Consul catalog for passing instances of a service.
+//
+// The scheme after the `+` is the protocol used to connect to the discovered endpoints.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+const (
+	kindSRV    = "srv"
+	kindConsul = "consul"
+)
+
+// A target is a service to discover.
+type target struct {
+	kind string
+	name string
+}
+
+func (t target) String() string {
+	return t.kind + "://" + t.name
+}
+
+// An endpoint is a discovered upstream address.
+type endpoint struct {
+	host   string
+	port   uint16
+	weight uint32
+}
+
+// A resolver watches a target and calls update whenever its endpoints change.
+// It returns when the context is canceled.
+type resolver interface {
+	watch(ctx context.Context, t target, update func([]endpoint))
+}
+
+// parseTarget parses a discovery upstream URL. ok is false if the URL is a static upstream.
+func parseTarget(u *url.URL) (t target, scheme string, ok bool, err error) {
+	kind, scheme, found := strings.Cut(u.Scheme, "+")
+	if !found {
+		return target{}, "", false, nil
+	}
+	switch kind {
+	case kindSRV, kindConsul:
+	default:
+		return target{}, "", false, nil
+	}
+	switch scheme {
+	case "http", "https", "h2c":
+	default:
+		return target{}, "", false, fmt.Errorf("discovery: unsupported upstream scheme: %s", scheme)
+	}
+	if u.Port() != "" {
+		return target{}, "", false, fmt.Errorf("discovery: %s upstreams must not specify a port", kind)
+	}
+	return target{kind: kind, name: u.Hostname()}, scheme, true, nil
+}
+
+// resolveURLs replaces any discovery upstreams with the discovered endpoints. Discovery upstreams which
+// have not been resolved yet are omitted. ok is false if there are no discovery upstreams.
+func resolveURLs(urls config.WeightedURLs, lookup func(target) []endpoint) (resolved config.WeightedURLs, ok bool) {
+	resolved = config.WeightedURLs{}
+	for _, u := range urls {
+		t, scheme, isTarget, err := parseTarget(&u.URL)
+		if err != nil || !isTarget {
+			resolved = append(resolved, u)
+			continue
+		}
+
+		ok = true
+		endpoints := lookup(t)
+		for _, e := range endpoints {
+			dst := u
+			dst.URL.Scheme = scheme
+			dst.URL.Host = net.JoinHostPort(e.host, strconv.Itoa(int(e.port)))
+			dst.LbWeight = e.weight
+			resolved = append(resolved, dst)
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	return normalizeWeights(resolved), true
+}
+
+// normalizeWeights makes sure either all of the urls have a weight or none of them do.
+func normalizeWeights(urls config.WeightedURLs) config.WeightedURLs {
+	hasWeight := false
+	for _, u := range urls {
+		hasWeight = hasWeight || u.LbWeight > 0
+	}
+	if !hasWeight {
+		return urls
+	}
+	for i := range urls {
+		if urls[i].LbWeight == 0 {
+			urls[i].LbWeight = 1
+		}
+	}
+	return urls
+}
+
+// sortEndpoints sorts endpoints so that the generated config is stable between lookups.
+func sortEndpoints(endpoints []endpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].host != endpoints[j].host {
+			return endpoints[i].host < endpoints[j].host
+		}
+		return endpoints[i].port < endpoints[j].port
+	})
+}
+
+func endpointsEqual(a, b []endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
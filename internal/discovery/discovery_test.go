@@ -0,0 +1,212 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestResolveURLs(t *testing.T) {
+	t.Parallel()
+
+	urls, err := config.ParseWeightedUrls(
+		"srv+https://_http._tcp.example.com/prefix",
+		"https://static.example.com",
+		"consul+http://unresolved",
+	)
+	require.NoError(t, err)
+
+	resolved, ok := resolveURLs(urls, func(t target) []endpoint {
+		if t.kind == kindSRV && t.name == "_http._tcp.example.com" {
+			return []endpoint{
+				{host: "a.example.com", port: 8443, weight: 10},
+				{host: "b.example.com", port: 8443},
+			}
+		}
+		return nil
+	})
+	assert.True(t, ok)
+
+	var strs []string
+	var weights []uint32
+	for _, u := range resolved {
+		strs = append(strs, u.URL.String())
+		weights = append(weights, u.LbWeight)
+	}
+	assert.Equal(t, []string{
+		"https://a.example.com:8443/prefix",
+		"https://b.example.com:8443/prefix",
+		"https://static.example.com",
+	}, strs, "should omit unresolved upstreams")
+	assert.Equal(t, []uint32{10, 1, 1}, weights)
+	assert.Equal(t, "srv+https", urls[0].URL.Scheme, "should not modify the original urls")
+
+	t.Run("static", func(t *testing.T) {
+		resolved, ok := resolveURLs(mustParseWeightedURLs(t, "https://static.example.com"), func(_ target) []endpoint {
+			return nil
+		})
+		assert.False(t, ok)
+		assert.Nil(t, resolved)
+	})
+	t.Run("unresolved", func(t *testing.T) {
+		resolved, ok := resolveURLs(mustParseWeightedURLs(t, "consul+http://unresolved"), func(_ target) []endpoint {
+			return nil
+		})
+		assert.True(t, ok)
+		assert.NotNil(t, resolved)
+		assert.Empty(t, resolved)
+	})
+}
+
+func TestParseTarget(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		raw    string
+		target target
+		scheme string
+		ok     bool
+		err    bool
+	}{
+		{"https://example.com", target{}, "", false, false},
+		{"git+ssh://example.com", target{}, "", false, false},
+		{"srv+https://_http._tcp.example.com", target{kindSRV, "_http._tcp.example.com"}, "https", true, false},
+		{"consul+h2c://grpc-service", target{kindConsul, "grpc-service"}, "h2c", true, false},
+		{"consul+tcp://service", target{}, "", false, true},
+		{"srv+http://_http._tcp.example.com:8080", target{}, "", false, true},
+	} {
+		u, err := config.ParseWeightedURL(tc.raw)
+		require.NoError(t, err)
+
+		target, scheme, ok, err := parseTarget(&u.URL)
+		if tc.err {
+			assert.Error(t, err, tc.raw)
+			continue
+		}
+		assert.NoError(t, err, tc.raw)
+		assert.Equal(t, tc.target, target, tc.raw)
+		assert.Equal(t, tc.scheme, scheme, tc.raw)
+		assert.Equal(t, tc.ok, ok, tc.raw)
+	}
+}
+
+func TestSRVResolver(t *testing.T) {
+	t.Parallel()
+
+	r := newSRVResolver()
+	r.lookupSRV = func(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+		assert.Equal(t, "_http._tcp.example.com", name)
+		return "", []*net.SRV{
+			{Target: "c.example.com.", Port: 80, Priority: 20, Weight: 5},
+			{Target: "b.example.com.", Port: 80, Priority: 10, Weight: 5},
+			{Target: "a.example.com.", Port: 81, Priority: 10, Weight: 0},
+		}, nil
+	}
+
+	endpoints, err := r.lookup(context.Background(), "_http._tcp.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []endpoint{
+		{host: "a.example.com", port: 81},
+		{host: "b.example.com", port: 80, weight: 5},
+	}, endpoints)
+}
+
+func TestConsulResolver(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/web", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("passing"))
+		assert.Equal(t, "TOKEN", r.Header.Get("X-Consul-Token"))
+		w.Header().Set("X-Consul-Index", "42")
+		_, _ = w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "", "Port": 8080, "Weights": {"Passing": 1}}},
+			{"Node": {"Address": "10.0.0.9"}, "Service": {"Address": "10.0.0.1", "Port": 8080, "Weights": {"Passing": 3}}}
+		]`))
+	}))
+	t.Cleanup(srv.Close)
+
+	r := newConsulResolver(srv.URL, "TOKEN")
+	endpoints, index, err := r.query(context.Background(), "web", 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), index)
+	assert.Equal(t, []endpoint{
+		{host: "10.0.0.1", port: 8080, weight: 3},
+		{host: "10.0.0.2", port: 8080, weight: 1},
+	}, endpoints)
+}
+
+type testResolver struct {
+	updates chan []endpoint
+}
+
+func (r testResolver) watch(ctx context.Context, _ target, update func([]endpoint)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case endpoints := <-r.updates:
+			update(endpoints)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	underlying := config.NewStaticSource(&config.Config{Options: &config.Options{
+		Policies: []config.Policy{{
+			From: "https://from.example.com",
+			To:   mustParseWeightedURLs(t, "consul+http://web"),
+		}},
+	}})
+
+	r := testResolver{updates: make(chan []endpoint)}
+	src := newSource(ctx, underlying, func(_ string, _ *config.Options) resolver { return r })
+
+	policy := src.GetConfig().Options.Policies[0]
+	assert.Equal(t, "consul+http://web", policy.To[0].URL.String())
+	assert.NotNil(t, policy.ResolvedTo)
+	assert.Empty(t, policy.ResolvedTo, "should not have any upstreams until resolved")
+	routeID, err := policy.RouteID()
+	require.NoError(t, err)
+
+	changed := make(chan *config.Config, 1)
+	src.OnConfigChange(ctx, func(_ context.Context, cfg *config.Config) {
+		changed <- cfg
+	})
+
+	r.updates <- []endpoint{{host: "10.0.0.1", port: 8080}}
+	select {
+	case cfg := <-changed:
+		policy := cfg.Options.Policies[0]
+		assert.Equal(t, "consul+http://web", policy.To[0].URL.String(), "should keep the discovery upstream")
+		if assert.Len(t, policy.ResolvedTo, 1) {
+			assert.Equal(t, "http://10.0.0.1:8080", policy.ResolvedTo[0].URL.String())
+		}
+		resolvedRouteID, err := policy.RouteID()
+		require.NoError(t, err)
+		assert.Equal(t, routeID, resolvedRouteID, "should not change the route id")
+	case <-time.After(time.Second * 5):
+		t.Fatal("expected config change")
+	}
+	assert.Nil(t, underlying.GetConfig().Options.Policies[0].ResolvedTo,
+		"should not modify the underlying config")
+}
+
+func mustParseWeightedURLs(t *testing.T, urls ...string) config.WeightedURLs {
+	wu, err := config.ParseWeightedUrls(urls...)
+	require.NoError(t, err)
+	return wu
+}
@@ -0,0 +1,203 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// A Source is a config source which resolves discovery upstreams to the currently discovered
+// endpoints. A change is triggered any time the endpoints change.
+type Source struct {
+	underlying  config.Source
+	newResolver func(kind string, options *config.Options) resolver
+
+	// updateMu serializes updates so that changes are triggered in order
+	updateMu       sync.Mutex
+	mu             sync.Mutex
+	ctx            context.Context
+	underlyingCfg  *config.Config
+	computedConfig *config.Config
+	watches        map[target]*watch
+
+	config.ChangeDispatcher
+}
+
+type watch struct {
+	cancel    context.CancelFunc
+	endpoints []endpoint
+	resolved  bool
+}
+
+// NewSource creates a new Source.
+func NewSource(ctx context.Context, underlying config.Source) *Source {
+	return newSource(ctx, underlying, defaultResolver)
+}
+
+func newSource(
+	ctx context.Context,
+	underlying config.Source,
+	newResolver func(kind string, options *config.Options) resolver,
+) *Source {
+	src := &Source{
+		underlying:  underlying,
+		newResolver: newResolver,
+		ctx:         ctx,
+		watches:     make(map[target]*watch),
+	}
+	underlying.OnConfigChange(ctx, func(ctx context.Context, cfg *config.Config) {
+		src.onUnderlyingConfigChange(ctx, cfg)
+	})
+	src.onUnderlyingConfigChange(ctx, underlying.GetConfig())
+	return src
+}
+
+func defaultResolver(kind string, options *config.Options) resolver {
+	if kind == kindConsul {
+		return newConsulResolver(options.ConsulAddress, options.ConsulToken)
+	}
+	return newSRVResolver()
+}
+
+// GetConfig gets the computed config.
+func (src *Source) GetConfig() *config.Config {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	return src.computedConfig
+}
+
+func (src *Source) onUnderlyingConfigChange(ctx context.Context, cfg *config.Config) {
+	if cfg == nil || cfg.Options == nil {
+		return
+	}
+
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	src.mu.Lock()
+	consulChanged := src.underlyingCfg == nil ||
+		src.underlyingCfg.Options.ConsulAddress != cfg.Options.ConsulAddress ||
+		src.underlyingCfg.Options.ConsulToken != cfg.Options.ConsulToken
+	src.underlyingCfg = cfg
+
+	targets := make(map[target]struct{})
+	for _, p := range cfg.Options.GetAllPolicies() {
+		for _, u := range p.To {
+			t, _, ok, err := parseTarget(&u.URL)
+			if err != nil {
+				log.Error(ctx).Err(err).Str("to", u.URL.String()).Msg("discovery: invalid upstream")
+				continue
+			}
+			if ok {
+				targets[t] = struct{}{}
+			}
+		}
+	}
+
+	// stop watches which are no longer used
+	for t, w := range src.watches {
+		if _, ok := targets[t]; !ok || (t.kind == kindConsul && consulChanged) {
+			w.cancel()
+			delete(src.watches, t)
+		}
+	}
+
+	// start new watches
+	for t := range targets {
+		if _, ok := src.watches[t]; ok {
+			continue
+		}
+		t := t
+		wctx, cancel := context.WithCancel(src.ctx)
+		w := &watch{cancel: cancel}
+		src.watches[t] = w
+		r := src.newResolver(t.kind, cfg.Options)
+		go r.watch(wctx, t, func(endpoints []endpoint) {
+			src.onEndpointsChange(wctx, t, w, endpoints)
+		})
+	}
+
+	computed := src.computeLocked()
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+func (src *Source) onEndpointsChange(ctx context.Context, t target, w *watch, endpoints []endpoint) {
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	src.mu.Lock()
+	if ctx.Err() != nil || src.watches[t] != w || (w.resolved && endpointsEqual(w.endpoints, endpoints)) {
+		src.mu.Unlock()
+		return
+	}
+	w.endpoints, w.resolved = endpoints, true
+	if len(endpoints) == 0 {
+		log.Warn(ctx).Str("target", t.String()).Msg("discovery: no endpoints found")
+	} else {
+		log.Info(ctx).Str("target", t.String()).Int("endpoints", len(endpoints)).Msg("discovery: endpoints updated")
+	}
+	computed := src.computeLocked()
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+func (src *Source) computeLocked() *config.Config {
+	lookup := func(t target) []endpoint {
+		if w, ok := src.watches[t]; ok && w.resolved {
+			return w.endpoints
+		}
+		return nil
+	}
+
+	cfg := src.underlyingCfg.Clone()
+	cfg.Options.Policies = resolvePolicies(cfg.Options.Policies, lookup)
+	cfg.Options.Routes = resolvePolicies(cfg.Options.Routes, lookup)
+	cfg.Options.AdditionalPolicies = resolvePolicyPointers(cfg.Options.AdditionalPolicies, lookup)
+	src.computedConfig = cfg
+	return cfg
+}
+
+// resolvePolicies returns a copy of the policies with the discovered endpoints stored in ResolvedTo.
+// To is left as is so that the route ID doesn't change when the endpoints do.
+func resolvePolicies(policies []config.Policy, lookup func(target) []endpoint) []config.Policy {
+	if policies == nil {
+		return nil
+	}
+	resolved := make([]config.Policy, len(policies))
+	for i, p := range policies {
+		p.ResolvedTo = nil
+		if urls, ok := resolveURLs(p.To, lookup); ok {
+			p.ResolvedTo = urls
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
+
+// resolvePolicyPointers is like resolvePolicies, but for shared policies, which are only copied if
+// their resolved endpoints change.
+func resolvePolicyPointers(policies []*config.Policy, lookup func(target) []endpoint) []*config.Policy {
+	if policies == nil {
+		return nil
+	}
+	resolved := make([]*config.Policy, len(policies))
+	for i, p := range policies {
+		urls, ok := resolveURLs(p.To, lookup)
+		if ok || p.ResolvedTo != nil {
+			r := *p
+			r.ResolvedTo = nil
+			if ok {
+				r.ResolvedTo = urls
+			}
+			p = &r
+		}
+		resolved[i] = p
+	}
+	return resolved
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const srvPollInterval = time.Second * 30
+
+type srvResolver struct {
+	interval  time.Duration
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+func newSRVResolver() *srvResolver {
+	return &srvResolver{
+		interval:  srvPollInterval,
+		lookupSRV: net.DefaultResolver.LookupSRV,
+	}
+}
+
+func (r *srvResolver) watch(ctx context.Context, t target, update func([]endpoint)) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		endpoints, err := r.lookup(ctx, t.name)
+		if err != nil {
+			log.Warn(ctx).Err(err).Str("target", t.String()).Msg("discovery: error looking up srv records")
+		} else {
+			update(endpoints)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lookup returns the endpoints with the lowest priority value, weighted by the record weights.
+func (r *srvResolver) lookup(ctx context.Context, name string) ([]endpoint, error) {
+	_, records, err := r.lookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	priority := records[0].Priority
+	for _, record := range records {
+		if record.Priority < priority {
+			priority = record.Priority
+		}
+	}
+
+	var endpoints []endpoint
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		endpoints = append(endpoints, endpoint{
+			host:   strings.TrimSuffix(record.Target, "."),
+			port:   record.Port,
+			weight: uint32(record.Weight),
+		})
+	}
+	sortEndpoints(endpoints)
+	return endpoints, nil
+}
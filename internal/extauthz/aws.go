@@ -0,0 +1,179 @@
+package extauthz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const awsAPIGatewayPath = "/aws/apigateway"
+
+// awsAPIGatewayEvent is an API Gateway lambda authorizer event, for either a REST api REQUEST
+// authorizer (payload version 1.0) or an HTTP api authorizer (payload version 2.0).
+type awsAPIGatewayEvent struct {
+	Version string `json:"version"`
+
+	// payload version 1.0
+	MethodArn             string              `json:"methodArn"`
+	HTTPMethod            string              `json:"httpMethod"`
+	Path                  string              `json:"path"`
+	MultiValueHeaders     map[string][]string `json:"multiValueHeaders"`
+	MultiValueQueryString map[string][]string `json:"multiValueQueryStringParameters"`
+
+	// payload version 2.0
+	RawPath        string   `json:"rawPath"`
+	RawQueryString string   `json:"rawQueryString"`
+	Cookies        []string `json:"cookies"`
+
+	Headers        map[string]string `json:"headers"`
+	RequestContext struct {
+		DomainName string `json:"domainName"`
+		HTTP       struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+}
+
+type awsIAMPolicyResponse struct {
+	PrincipalID    string            `json:"principalId"`
+	PolicyDocument awsIAMPolicy      `json:"policyDocument"`
+	Context        map[string]string `json:"context,omitempty"`
+}
+
+type awsIAMPolicy struct {
+	Version   string                  `json:"Version"`
+	Statement []awsIAMPolicyStatement `json:"Statement"`
+}
+
+type awsIAMPolicyStatement struct {
+	Action   string `json:"Action"`
+	Effect   string `json:"Effect"`
+	Resource string `json:"Resource"`
+}
+
+type awsSimpleResponse struct {
+	IsAuthorized bool              `json:"isAuthorized"`
+	Context      map[string]string `json:"context,omitempty"`
+}
+
+// serveAWSAPIGateway authorizes an API Gateway lambda authorizer event, which is relayed by the
+// `pomerium aws-lambda-authorizer` lambda function. The response is the lambda's response: an IAM
+// policy for payload version 1.0, and a simple response for payload version 2.0, with the headers
+// pomerium would add to the upstream request, like the JWT assertion, in the authorizer context.
+// Header names are lower-cased with dashes replaced by underscores, so they can be referenced in
+// API Gateway parameter mappings, e.g. $context.authorizer.x_pomerium_jwt_assertion.
+//
+// API Gateway can't redirect users to sign in, so requests must carry a pomerium token, such as a
+// programmatic login token in the Authorization header. Unauthenticated requests get a 401
+// response, which the lambda turns into the "Unauthorized" error API Gateway expects.
+func (srv *Server) serveAWSAPIGateway(w http.ResponseWriter, r *http.Request) {
+	var evt awsAPIGatewayEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024*1024)).Decode(&evt); err != nil {
+		http.Error(w, "invalid API Gateway authorizer event", http.StatusBadRequest)
+		return
+	}
+
+	method, path, headers, sourceIP := evt.request()
+	res, err := srv.check(r.Context(), method, "https", evt.RequestContext.DomainName, path, headers, sourceIP)
+	if err != nil {
+		log.Error(r.Context()).Err(err).Msg("extauthz: error authorizing API Gateway request")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	allowed := res.GetStatus().GetCode() == int32(codes.OK)
+	if !allowed && res.GetStatus().GetCode() == int32(codes.Unauthenticated) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var authorizerContext map[string]string
+	if allowed {
+		authorizerContext = make(map[string]string)
+		for _, h := range res.GetOkResponse().GetHeaders() {
+			key := strings.ReplaceAll(strings.ToLower(h.GetHeader().GetKey()), "-", "_")
+			authorizerContext[key] = h.GetHeader().GetValue()
+		}
+	}
+
+	if evt.Version == "2.0" {
+		httputil.RenderJSON(w, http.StatusOK, awsSimpleResponse{
+			IsAuthorized: allowed,
+			Context:      authorizerContext,
+		})
+		return
+	}
+
+	effect := "Deny"
+	if allowed {
+		effect = "Allow"
+	}
+	httputil.RenderJSON(w, http.StatusOK, awsIAMPolicyResponse{
+		PrincipalID: getPrincipalID(authorizerContext),
+		PolicyDocument: awsIAMPolicy{
+			Version: "2012-10-17",
+			Statement: []awsIAMPolicyStatement{{
+				Action:   "execute-api:Invoke",
+				Effect:   effect,
+				Resource: evt.MethodArn,
+			}},
+		},
+		Context: authorizerContext,
+	})
+}
+
+// request returns the method, path with query string, headers and source ip of the event's request.
+func (evt *awsAPIGatewayEvent) request() (method, path string, headers http.Header, sourceIP string) {
+	headers = make(http.Header)
+	if evt.Version == "2.0" {
+		for k, v := range evt.Headers {
+			headers.Set(k, v)
+		}
+		if len(evt.Cookies) > 0 {
+			headers.Set("Cookie", strings.Join(evt.Cookies, "; "))
+		}
+		path = evt.RawPath
+		if evt.RawQueryString != "" {
+			path += "?" + evt.RawQueryString
+		}
+		return evt.RequestContext.HTTP.Method, path, headers, evt.RequestContext.HTTP.SourceIP
+	}
+
+	for k, vs := range evt.MultiValueHeaders {
+		for _, v := range vs {
+			headers.Add(k, v)
+		}
+	}
+	if len(evt.MultiValueHeaders) == 0 {
+		for k, v := range evt.Headers {
+			headers.Set(k, v)
+		}
+	}
+	path = evt.Path
+	if len(evt.MultiValueQueryString) > 0 {
+		path += "?" + url.Values(evt.MultiValueQueryString).Encode()
+	}
+	return evt.HTTPMethod, path, headers, evt.RequestContext.Identity.SourceIP
+}
+
+// getPrincipalID returns the subject of the JWT assertion in the authorizer context. The JWT was
+// just created by pomerium, so it isn't verified.
+func getPrincipalID(authorizerContext map[string]string) string {
+	var claims jwt.Claims
+	tok, err := jwt.ParseSigned(authorizerContext["x_pomerium_jwt_assertion"])
+	if err == nil && tok.UnsafeClaimsWithoutVerification(&claims) == nil && claims.Subject != "" {
+		return claims.Subject
+	}
+	return "anonymous"
+}
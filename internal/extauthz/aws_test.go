@@ -0,0 +1,108 @@
+package extauthz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_AWSAPIGateway(t *testing.T) {
+	t.Parallel()
+
+	srv, checkRequest := newTestServer(t)
+	handler := srv.newHandler()
+
+	serve := func(t *testing.T, event string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "http://pomerium/aws/apigateway", strings.NewReader(event))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("v1 allowed", func(t *testing.T) {
+		w := serve(t, `{
+			"type": "REQUEST",
+			"methodArn": "arn:aws:execute-api:us-east-1:123456789012:abcdef/prod/GET/some/path",
+			"httpMethod": "GET",
+			"path": "/some/path",
+			"multiValueHeaders": {"Cookie": ["session=VALID"]},
+			"multiValueQueryStringParameters": {"x": ["y"]},
+			"requestContext": {"domainName": "a.example.com", "identity": {"sourceIp": "192.0.2.1"}}
+		}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res awsIAMPolicyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, "anonymous", res.PrincipalID)
+		assert.Equal(t, []awsIAMPolicyStatement{{
+			Action:   "execute-api:Invoke",
+			Effect:   "Allow",
+			Resource: "arn:aws:execute-api:us-east-1:123456789012:abcdef/prod/GET/some/path",
+		}}, res.PolicyDocument.Statement)
+		assert.Equal(t, map[string]string{"x_pomerium_claim_email": "user@example.com"}, res.Context)
+
+		hattrs := checkRequest.Load().GetAttributes().GetRequest().GetHttp()
+		assert.Equal(t, "a.example.com", hattrs.GetHost())
+		assert.Equal(t, "/some/path?x=y", hattrs.GetPath())
+		assert.Equal(t, "192.0.2.1",
+			checkRequest.Load().GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+	})
+	t.Run("v1 no route", func(t *testing.T) {
+		w := serve(t, `{
+			"methodArn": "arn",
+			"httpMethod": "GET",
+			"path": "/",
+			"multiValueHeaders": {"Cookie": ["session=VALID"]},
+			"requestContext": {"domainName": "b.example.com"}
+		}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var res awsIAMPolicyResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, "Deny", res.PolicyDocument.Statement[0].Effect)
+		assert.Empty(t, res.Context)
+	})
+	t.Run("v2 allowed", func(t *testing.T) {
+		w := serve(t, `{
+			"version": "2.0",
+			"rawPath": "/some/path",
+			"rawQueryString": "x=y",
+			"cookies": ["session=VALID"],
+			"headers": {"accept": "application/json"},
+			"requestContext": {"domainName": "a.example.com", "http": {"method": "POST", "sourceIp": "192.0.2.1"}}
+		}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"isAuthorized": true, "context": {"x_pomerium_claim_email": "user@example.com"}}`, w.Body.String())
+
+		hattrs := checkRequest.Load().GetAttributes().GetRequest().GetHttp()
+		assert.Equal(t, http.MethodPost, hattrs.GetMethod())
+		assert.Equal(t, "/some/path?x=y", hattrs.GetPath())
+	})
+	t.Run("v2 no route", func(t *testing.T) {
+		w := serve(t, `{
+			"version": "2.0",
+			"rawPath": "/",
+			"cookies": ["session=VALID"],
+			"requestContext": {"domainName": "b.example.com", "http": {"method": "GET"}}
+		}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"isAuthorized": false}`, w.Body.String())
+	})
+	t.Run("unauthenticated", func(t *testing.T) {
+		w := serve(t, `{
+			"version": "2.0",
+			"rawPath": "/",
+			"requestContext": {"domainName": "a.example.com", "http": {"method": "GET"}}
+		}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+	t.Run("invalid event", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(t, `{`).Code)
+	})
+}
@@ -0,0 +1,119 @@
+package extauthz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	batchPath            = "/batch"
+	maxBatchRequests     = 1_000
+	maxBatchRequestBytes = 16 << 20
+)
+
+type batchRequest struct {
+	Requests []json.RawMessage `json:"requests"`
+}
+
+type batchResponse struct {
+	Responses []json.RawMessage `json:"responses"`
+}
+
+// CheckBatch authorizes multiple requests from external proxies. The responses are in the order of
+// the requests.
+func (srv *Server) CheckBatch(
+	ctx context.Context,
+	ins []*envoy_service_auth_v3.CheckRequest,
+) ([]*envoy_service_auth_v3.CheckResponse, error) {
+	outs := make([]*envoy_service_auth_v3.CheckResponse, len(ins))
+
+	var prepared []*envoy_service_auth_v3.CheckRequest
+	var indices []int
+	for i, in := range ins {
+		in, res, err := srv.prepareCheckRequest(in)
+		if err != nil {
+			return nil, err
+		} else if in == nil {
+			outs[i] = res
+			continue
+		}
+		prepared = append(prepared, in)
+		indices = append(indices, i)
+	}
+	if len(prepared) == 0 {
+		return outs, nil
+	}
+
+	res, err := srv.authorizer.CheckBatch(ctx, prepared)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) != len(prepared) {
+		return nil, fmt.Errorf("extauthz: expected %d responses, got %d", len(prepared), len(res))
+	}
+	for j, i := range indices {
+		outs[i] = res[j]
+	}
+	return outs, nil
+}
+
+// serveBatch authorizes multiple ext_authz check requests in one call. The body is a JSON object
+// with the check requests, in the protobuf JSON format, in a "requests" array, and the response has
+// the check responses in the same order in a "responses" array:
+//
+//	{"requests": [{"attributes": {"request": {"http": {"method": "GET", "host": "a.example.com", "path": "/"}}}}]}
+//
+// Batch responses aren't signed.
+func (srv *Server) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchRequestBytes)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid batch request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) > maxBatchRequests {
+		http.Error(w, fmt.Sprintf("too many requests in batch: %d > %d", len(req.Requests), maxBatchRequests),
+			http.StatusBadRequest)
+		return
+	}
+
+	ins := make([]*envoy_service_auth_v3.CheckRequest, len(req.Requests))
+	for i, raw := range req.Requests {
+		ins[i] = new(envoy_service_auth_v3.CheckRequest)
+		if err := protojson.Unmarshal(raw, ins[i]); err != nil {
+			http.Error(w, fmt.Sprintf("invalid check request %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	outs, err := srv.CheckBatch(r.Context(), ins)
+	if err != nil {
+		log.Error(r.Context()).Err(err).Msg("extauthz: error authorizing batch")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	res := batchResponse{Responses: make([]json.RawMessage, len(outs))}
+	for i, out := range outs {
+		res.Responses[i], err = protojson.Marshal(out)
+		if err != nil {
+			log.Error(r.Context()).Err(err).Msg("extauthz: error marshaling batch response")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	httputil.RenderJSON(w, http.StatusOK, res)
+}
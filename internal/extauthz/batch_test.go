@@ -0,0 +1,60 @@
+package extauthz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestServer_Batch(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newTestServer(t)
+	handler := srv.newHandler()
+
+	body := `{"requests": [
+		{"attributes": {"request": {"http": {"method": "GET", "scheme": "https", "host": "a.example.com", "path": "/", "headers": {"cookie": "session=VALID"}}}}},
+		{"attributes": {"request": {"http": {"method": "GET", "scheme": "https", "host": "b.example.com", "path": "/"}}}},
+		{"attributes": {"request": {"http": {"method": "GET", "scheme": "https", "host": "a.example.com", "path": "/"}}}}
+	]}`
+	r := httptest.NewRequest(http.MethodPost, "http://pomerium/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var res batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Responses, 3)
+
+	var statusCodes []codes.Code
+	var deniedStatuses []int32
+	for _, raw := range res.Responses {
+		var out envoy_service_auth_v3.CheckResponse
+		require.NoError(t, protojson.Unmarshal(raw, &out))
+		statusCodes = append(statusCodes, codes.Code(out.GetStatus().GetCode()))
+		deniedStatuses = append(deniedStatuses, int32(out.GetDeniedResponse().GetStatus().GetCode()))
+	}
+	assert.Equal(t, []codes.Code{codes.OK, codes.PermissionDenied, codes.Unauthenticated}, statusCodes)
+	assert.Equal(t, []int32{0, http.StatusNotFound, http.StatusFound}, deniedStatuses)
+
+	t.Run("invalid", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "http://pomerium/batch", strings.NewReader(`{"requests": [{"unknown": 1}]}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("method", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://pomerium/batch", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
@@ -0,0 +1,352 @@
+// Package extauthz contains a standalone ext_authz server, which lets proxies other than pomerium's
+// own envoy, like an existing envoy or nginx fleet, authorize requests against pomerium's routes.
+//
+// The server serves, on a single port:
+//
+//   - the envoy ext_authz gRPC api, for envoy's ext_authz filter with a grpc_service
+//   - the envoy ext_authz http api under /envoy, for envoy's ext_authz filter with an http_service
+//     and a path_prefix of /envoy
+//   - an nginx auth_request endpoint at /nginx, which reads the request from the X-Original-URL and
+//     X-Original-Method headers
+//   - an AWS API Gateway lambda authorizer endpoint at /aws/apigateway, for the events relayed by the
+//     `pomerium aws-lambda-authorizer` lambda function
+//   - a batch endpoint at /batch, which authorizes multiple ext_authz check requests in one call
+//
+// Routes are matched by the request url, since external proxies don't know pomerium's route ids.
+// Users are sent to pomerium to sign in, so the external proxy must also send the /.pomerium/ paths
+// of every route to pomerium's proxy. The server trusts its callers, so it should only be reachable
+// by the proxies.
+//
+// If ext_authz_signing_key is set, the responses of the http endpoints are signed, see the authzsig
+// package. With envoy, the signature headers must be allowed by the ext_authz filter's
+// authorization_response.allowed_upstream_headers and allowed_client_headers.
+package extauthz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/authzsig"
+)
+
+const (
+	envoyPathPrefix   = "/envoy"
+	nginxPath         = "/nginx"
+	readHeaderTimeout = 10 * time.Second
+)
+
+// An Authorizer authorizes requests. It is implemented by the authorize service.
+type Authorizer interface {
+	Check(ctx context.Context, req *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error)
+	CheckBatch(ctx context.Context, reqs []*envoy_service_auth_v3.CheckRequest) ([]*envoy_service_auth_v3.CheckResponse, error)
+}
+
+// A Server is a standalone ext_authz server.
+type Server struct {
+	envoy_service_auth_v3.UnimplementedAuthorizationServer
+
+	authorizer Authorizer
+	options    *atomicutil.Value[*config.Options]
+	routes     *atomicutil.Value[*config.RouteMatcher]
+}
+
+// New creates a new Server.
+func New(authorizer Authorizer) *Server {
+	return &Server{
+		authorizer: authorizer,
+		options:    atomicutil.NewValue(config.NewDefaultOptions()),
+		routes:     atomicutil.NewValue(config.NewRouteMatcher(nil)),
+	}
+}
+
+// OnConfigChange updates the server's configuration.
+func (srv *Server) OnConfigChange(_ context.Context, cfg *config.Config) {
+	srv.options.Store(cfg.Options)
+	srv.routes.Store(config.NewRouteMatcher(cfg.Options.GetAllPolicies()))
+}
+
+// Run runs the ext_authz server on the given address until the context is canceled.
+func (srv *Server) Run(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+	li, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("extauthz: error starting listener: %w", err)
+	}
+	log.Info(ctx).Str("addr", li.Addr().String()).Msg("extauthz: started")
+	return srv.Serve(ctx, li)
+}
+
+// Serve serves ext_authz requests from the listener until the context is canceled. gRPC requests
+// are served over plaintext HTTP/2.
+func (srv *Server) Serve(ctx context.Context, li net.Listener) error {
+	hsrv := &http.Server{
+		Handler:           h2c.NewHandler(srv.newHandler(), &http2.Server{}),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = hsrv.Close()
+	}()
+
+	err := hsrv.Serve(li)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return fmt.Errorf("extauthz: error serving requests: %w", err)
+}
+
+func (srv *Server) newHandler() http.Handler {
+	grpcServer := grpc.NewServer()
+	envoy_service_auth_v3.RegisterAuthorizationServer(grpcServer, srv)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(envoyPathPrefix+"/", srv.serveEnvoy)
+	mux.HandleFunc(nginxPath, srv.serveNginx)
+	mux.HandleFunc(awsAPIGatewayPath, srv.serveAWSAPIGateway)
+	mux.HandleFunc(batchPath, srv.serveBatch)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcServer.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Check authorizes a request from an external envoy's ext_authz filter.
+func (srv *Server) Check(ctx context.Context, in *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	in, res, err := srv.prepareCheckRequest(in)
+	if in == nil {
+		return res, err
+	}
+	return srv.authorizer.Check(ctx, in)
+}
+
+// prepareCheckRequest sets the route of a request from an external proxy. If no route matches the
+// request, a denied response is returned instead.
+func (srv *Server) prepareCheckRequest(
+	in *envoy_service_auth_v3.CheckRequest,
+) (*envoy_service_auth_v3.CheckRequest, *envoy_service_auth_v3.CheckResponse, error) {
+	in = proto.Clone(in).(*envoy_service_auth_v3.CheckRequest)
+	if in.Attributes == nil {
+		in.Attributes = new(envoy_service_auth_v3.AttributeContext)
+	}
+	attrs := in.Attributes
+
+	hattrs := attrs.GetRequest().GetHttp()
+	requestURL := url.URL{Scheme: hattrs.GetScheme(), Host: hattrs.GetHost()}
+	requestURL.Path, _, _ = strings.Cut(hattrs.GetPath(), "?")
+
+	policy := srv.routes.Load().Match(requestURL)
+	if policy == nil {
+		return nil, deniedResponse(http.StatusNotFound, "no route found"), nil
+	}
+	routeID, err := policy.RouteID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the context extensions and metadata are pomerium's own, so callers can't set them, for example
+	// to mark their request as internal
+	attrs.ContextExtensions = envoyconfig.MakeExtAuthzContextExtensions(false, routeID)
+	attrs.MetadataContext = nil
+	return in, nil, nil
+}
+
+// serveEnvoy authorizes a request from an external envoy's ext_authz filter using the http api.
+// Envoy sends the request with its original method and headers, and its path appended to the path
+// prefix.
+func (srv *Server) serveEnvoy(w http.ResponseWriter, r *http.Request) {
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "https"
+	}
+	path := strings.TrimPrefix(r.URL.RequestURI(), envoyPathPrefix)
+
+	res, err := srv.check(r.Context(), r.Method, scheme, r.Host, path, r.Header, getSourceAddress(r))
+	if err != nil {
+		log.Error(r.Context()).Err(err).Msg("extauthz: error authorizing envoy request")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	srv.writeCheckResponse(w, r, res, false, r.Method, scheme+"://"+r.Host+path)
+}
+
+// serveNginx authorizes a request from nginx's auth_request module. The request is described by the
+// X-Original-URL and X-Original-Method headers:
+//
+//	location = /_pomerium_auth {
+//	  internal;
+//	  proxy_pass http://pomerium:9191/nginx;
+//	  proxy_pass_request_body off;
+//	  proxy_set_header Content-Length "";
+//	  proxy_set_header X-Original-URL $scheme://$http_host$request_uri;
+//	  proxy_set_header X-Original-Method $request_method;
+//	}
+//
+// nginx only accepts 401 and 403 responses for denied requests, so redirects to sign in are returned
+// as a 401 with a Location header, which can be used with auth_request_set and error_page 401.
+func (srv *Server) serveNginx(w http.ResponseWriter, r *http.Request) {
+	originalURL, err := url.Parse(r.Header.Get("X-Original-URL"))
+	if err != nil || originalURL.Host == "" {
+		http.Error(w, "missing or invalid X-Original-URL header", http.StatusBadRequest)
+		return
+	}
+	method := r.Header.Get("X-Original-Method")
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	headers := r.Header.Clone()
+	headers.Del("X-Original-URL")
+	headers.Del("X-Original-Method")
+
+	res, err := srv.check(r.Context(), method, originalURL.Scheme, originalURL.Host, originalURL.RequestURI(),
+		headers, getSourceAddress(r))
+	if err != nil {
+		log.Error(r.Context()).Err(err).Msg("extauthz: error authorizing nginx request")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	srv.writeCheckResponse(w, r, res, true, method, r.Header.Get("X-Original-URL"))
+}
+
+func (srv *Server) check(
+	ctx context.Context,
+	method, scheme, host, path string,
+	headers http.Header,
+	sourceAddress string,
+) (*envoy_service_auth_v3.CheckResponse, error) {
+	hdrs := make(map[string]string, len(headers)+1)
+	for k, vs := range headers {
+		sep := ","
+		if strings.EqualFold(k, "Cookie") {
+			sep = "; "
+		}
+		hdrs[strings.ToLower(k)] = strings.Join(vs, sep)
+	}
+	hdrs[":authority"] = host
+
+	return srv.Check(ctx, &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			Source: &envoy_service_auth_v3.AttributeContext_Peer{
+				Address: &envoy_config_core_v3.Address{
+					Address: &envoy_config_core_v3.Address_SocketAddress{
+						SocketAddress: &envoy_config_core_v3.SocketAddress{Address: sourceAddress},
+					},
+				},
+			},
+			Request: &envoy_service_auth_v3.AttributeContext_Request{
+				Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{
+					Method:  method,
+					Scheme:  scheme,
+					Host:    host,
+					Path:    path,
+					Headers: hdrs,
+				},
+			},
+		},
+	})
+}
+
+// writeCheckResponse writes the check response as an ext_authz http response: allowed requests get an
+// empty 200 response with the headers to add to the upstream request, and denied requests get the
+// denied response. If there's a signing key, the response is signed for the request with the method
+// and url.
+func (srv *Server) writeCheckResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	res *envoy_service_auth_v3.CheckResponse,
+	nginx bool,
+	method, requestURL string,
+) {
+	code := http.StatusOK
+	var body string
+	if res.GetStatus().GetCode() == int32(codes.OK) {
+		for _, h := range res.GetOkResponse().GetHeaders() {
+			w.Header().Add(h.GetHeader().GetKey(), h.GetHeader().GetValue())
+		}
+	} else {
+		denied := res.GetDeniedResponse()
+		for _, h := range denied.GetHeaders() {
+			w.Header().Add(h.GetHeader().GetKey(), h.GetHeader().GetValue())
+		}
+		code = int(denied.GetStatus().GetCode())
+		if code == 0 {
+			code = http.StatusForbidden
+		}
+		if nginx && code != http.StatusUnauthorized && code != http.StatusForbidden {
+			if code >= 300 && code < 400 {
+				code = http.StatusUnauthorized
+			} else {
+				code = http.StatusForbidden
+			}
+		}
+		body = denied.GetBody()
+	}
+
+	signingKey, err := srv.options.Load().GetExtAuthzSigningKey()
+	if err != nil {
+		log.Error(r.Context()).Err(err).Msg("extauthz: invalid signing key")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if signingKey != nil {
+		authzsig.Sign(signingKey, authzsig.Response{
+			Method:       method,
+			URL:          requestURL,
+			RequestNonce: r.Header.Get(authzsig.HeaderRequestNonce),
+			StatusCode:   code,
+			Header:       w.Header(),
+		})
+	}
+
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, body)
+}
+
+func deniedResponse(code int, body string) *envoy_service_auth_v3.CheckResponse {
+	return &envoy_service_auth_v3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: body},
+		HttpResponse: &envoy_service_auth_v3.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v3.DeniedHttpResponse{
+				Status: &envoy_type_v3.HttpStatus{Code: envoy_type_v3.StatusCode(code)},
+				Body:   body,
+			},
+		},
+	}
+}
+
+// getSourceAddress returns the address of the client, which is the last address added to the
+// X-Forwarded-For header by the proxy, or the address of the proxy itself.
+func getSourceAddress(r *http.Request) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		addrs := strings.Split(xff[len(xff)-1], ",")
+		if addr := strings.TrimSpace(addrs[len(addrs)-1]); addr != "" {
+			return addr
+		}
+	}
+	host, _, _ := net.SplitHostPort(r.RemoteAddr)
+	return host
+}
@@ -0,0 +1,225 @@
+package extauthz
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/pkg/authzsig"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+type mockAuthorizer func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse
+
+func (m mockAuthorizer) Check(_ context.Context, req *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	return m(req), nil
+}
+
+func (m mockAuthorizer) CheckBatch(_ context.Context, reqs []*envoy_service_auth_v3.CheckRequest) ([]*envoy_service_auth_v3.CheckResponse, error) {
+	res := make([]*envoy_service_auth_v3.CheckResponse, len(reqs))
+	for i, req := range reqs {
+		res[i] = m(req)
+	}
+	return res, nil
+}
+
+// cookieAuthorizer allows requests with the cookie "session=VALID" and redirects other requests to sign in.
+var cookieAuthorizer = mockAuthorizer(func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse {
+	if req.GetAttributes().GetRequest().GetHttp().GetHeaders()["cookie"] == "session=VALID" {
+		return &envoy_service_auth_v3.CheckResponse{
+			Status: &status.Status{Code: int32(codes.OK)},
+			HttpResponse: &envoy_service_auth_v3.CheckResponse_OkResponse{
+				OkResponse: &envoy_service_auth_v3.OkHttpResponse{
+					Headers: []*envoy_config_core_v3.HeaderValueOption{
+						{Header: &envoy_config_core_v3.HeaderValue{Key: "X-Pomerium-Claim-Email", Value: "user@example.com"}},
+					},
+				},
+			},
+		}
+	}
+	return &envoy_service_auth_v3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.Unauthenticated)},
+		HttpResponse: &envoy_service_auth_v3.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v3.DeniedHttpResponse{
+				Status: &envoy_type_v3.HttpStatus{Code: envoy_type_v3.StatusCode_Found},
+				Headers: []*envoy_config_core_v3.HeaderValueOption{
+					{Header: &envoy_config_core_v3.HeaderValue{Key: "Location", Value: "https://authenticate.example.com/sign_in"}},
+				},
+			},
+		},
+	}
+})
+
+func newTestServer(t *testing.T) (*Server, *atomicutil.Value[*envoy_service_auth_v3.CheckRequest]) {
+	t.Helper()
+
+	checkRequest := atomicutil.NewValue[*envoy_service_auth_v3.CheckRequest](nil)
+	srv := New(mockAuthorizer(func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse {
+		checkRequest.Store(req)
+		return cookieAuthorizer(req)
+	}))
+	srv.OnConfigChange(context.Background(), &config.Config{Options: &config.Options{
+		Policies: []config.Policy{
+			{From: "https://a.example.com"},
+		},
+	}})
+	return srv, checkRequest
+}
+
+func TestServer_Check(t *testing.T) {
+	t.Parallel()
+
+	srv, checkRequest := newTestServer(t)
+	routeID, err := srv.options.Load().GetAllPolicies()[0].RouteID()
+	require.NoError(t, err)
+
+	res, err := srv.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			ContextExtensions: envoyconfig.MakeExtAuthzContextExtensions(true, 1),
+			Request: &envoy_service_auth_v3.AttributeContext_Request{
+				Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{
+					Scheme:  "https",
+					Host:    "a.example.com",
+					Path:    "/some/path?x=y",
+					Headers: map[string]string{"cookie": "session=VALID"},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(codes.OK), res.GetStatus().GetCode())
+	assert.Equal(t, envoyconfig.MakeExtAuthzContextExtensions(false, routeID),
+		checkRequest.Load().GetAttributes().GetContextExtensions(),
+		"should override the context extensions")
+
+	res, err = srv.Check(context.Background(), &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			Request: &envoy_service_auth_v3.AttributeContext_Request{
+				Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{
+					Scheme: "https",
+					Host:   "b.example.com",
+					Path:   "/",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, envoy_type_v3.StatusCode_NotFound, res.GetDeniedResponse().GetStatus().GetCode())
+}
+
+func TestServer_Envoy(t *testing.T) {
+	t.Parallel()
+
+	srv, checkRequest := newTestServer(t)
+	handler := srv.newHandler()
+
+	t.Run("allowed", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "http://a.example.com/envoy/some/path?x=y", nil)
+		r.Header.Set("Cookie", "session=VALID")
+		r.Header.Set("X-Forwarded-For", "192.0.2.1, 198.51.100.1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user@example.com", w.Header().Get("X-Pomerium-Claim-Email"))
+		hattrs := checkRequest.Load().GetAttributes().GetRequest().GetHttp()
+		assert.Equal(t, http.MethodPost, hattrs.GetMethod())
+		assert.Equal(t, "https", hattrs.GetScheme())
+		assert.Equal(t, "a.example.com", hattrs.GetHost())
+		assert.Equal(t, "/some/path?x=y", hattrs.GetPath())
+		assert.Equal(t, "198.51.100.1",
+			checkRequest.Load().GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress())
+	})
+	t.Run("denied", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "http://a.example.com/envoy/", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://authenticate.example.com/sign_in", w.Header().Get("Location"))
+	})
+}
+
+func TestServer_Nginx(t *testing.T) {
+	t.Parallel()
+
+	srv, checkRequest := newTestServer(t)
+	handler := srv.newHandler()
+
+	for _, tc := range []struct {
+		name        string
+		originalURL string
+		cookie      string
+		expect      int
+	}{
+		{"allowed", "https://a.example.com/some/path", "session=VALID", http.StatusOK},
+		{"sign in", "https://a.example.com/some/path", "", http.StatusUnauthorized},
+		{"no route", "https://b.example.com/", "session=VALID", http.StatusForbidden},
+		{"missing url", "", "", http.StatusBadRequest},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://pomerium/nginx", nil)
+			if tc.originalURL != "" {
+				r.Header.Set("X-Original-URL", tc.originalURL)
+			}
+			r.Header.Set("X-Original-Method", http.MethodPut)
+			if tc.cookie != "" {
+				r.Header.Set("Cookie", tc.cookie)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tc.expect, w.Code)
+		})
+	}
+
+	hattrs := checkRequest.Load().GetAttributes().GetRequest().GetHttp()
+	assert.Equal(t, http.MethodPut, hattrs.GetMethod())
+	assert.NotContains(t, hattrs.GetHeaders(), "x-original-url")
+}
+
+func TestServer_Signed(t *testing.T) {
+	t.Parallel()
+
+	key := cryptutil.NewKey()
+	srv, _ := newTestServer(t)
+	srv.OnConfigChange(context.Background(), &config.Config{Options: &config.Options{
+		ExtAuthzSigningKey: base64.StdEncoding.EncodeToString(key),
+		Policies:           []config.Policy{{From: "https://a.example.com"}},
+	}})
+	handler := srv.newHandler()
+
+	r := httptest.NewRequest(http.MethodGet, "http://pomerium/nginx", nil)
+	r.Header.Set("X-Original-URL", "https://a.example.com/some/path?x=y")
+	r.Header.Set("Cookie", "session=VALID")
+	r.Header.Set(authzsig.HeaderRequestNonce, "REQUEST-NONCE")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := authzsig.Response{
+		Method:       http.MethodGet,
+		URL:          "https://a.example.com/some/path?x=y",
+		RequestNonce: "REQUEST-NONCE",
+		StatusCode:   w.Code,
+		Header:       w.Header(),
+	}
+	v := authzsig.NewVerifier(key)
+	_, err := v.Verify(res)
+	assert.NoError(t, err)
+	_, err = v.Verify(res)
+	assert.Error(t, err, "should reject replayed responses")
+}
@@ -0,0 +1,187 @@
+// Package forwardproxy contains a SOCKS5 and HTTP forward proxy which authorizes each destination
+// against the egress policies using the user's session.
+package forwardproxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/config/envoyconfig"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	dialTimeout  = 10 * time.Second
+	socksVersion = 0x05
+)
+
+var (
+	errUnauthenticated = errors.New("forwardproxy: unauthenticated")
+	errForbidden       = errors.New("forwardproxy: forbidden")
+)
+
+// An Authorizer authorizes requests. It is implemented by the authorize service.
+type Authorizer interface {
+	Check(ctx context.Context, req *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error)
+}
+
+// A Server is a forward proxy server.
+type Server struct {
+	authorizer Authorizer
+	options    *atomicutil.Value[*config.Options]
+	dialer     net.Dialer
+}
+
+// New creates a new Server.
+func New(authorizer Authorizer) *Server {
+	return &Server{
+		authorizer: authorizer,
+		options:    atomicutil.NewValue(config.NewDefaultOptions()),
+		dialer:     net.Dialer{Timeout: dialTimeout},
+	}
+}
+
+// OnConfigChange updates the server's configuration.
+func (srv *Server) OnConfigChange(_ context.Context, cfg *config.Config) {
+	srv.options.Store(cfg.Options)
+}
+
+// Run runs the forward proxy on the given address until the context is canceled.
+func (srv *Server) Run(ctx context.Context, addr string) error {
+	var lc net.ListenConfig
+	li, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("forwardproxy: error starting listener: %w", err)
+	}
+	log.Info(ctx).Str("addr", li.Addr().String()).Msg("forwardproxy: started")
+	return srv.Serve(ctx, li)
+}
+
+// Serve serves forward proxy connections from the listener until the context is canceled.
+func (srv *Server) Serve(ctx context.Context, li net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = li.Close()
+	}()
+
+	for {
+		conn, err := li.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("forwardproxy: error accepting connection: %w", err)
+		}
+		go srv.serveConn(ctx, conn)
+	}
+}
+
+func (srv *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	first, err := br.Peek(1)
+	if err != nil {
+		return
+	}
+
+	if first[0] == socksVersion {
+		srv.serveSOCKS(ctx, conn, br)
+	} else {
+		srv.serveHTTP(ctx, conn, br)
+	}
+}
+
+// authorize authorizes access to the destination using the egress policies and the user's session token.
+func (srv *Server) authorize(ctx context.Context, method, dst, token string, remoteAddr net.Addr) error {
+	policy := getEgressPolicy(srv.options.Load(), dst)
+	if policy == nil {
+		return errForbidden
+	}
+	routeID, err := policy.RouteID()
+	if err != nil {
+		return err
+	}
+
+	// ask for an unauthorized response rather than a redirect to the login page
+	headers := map[string]string{"accept": "application/json"}
+	if token != "" {
+		headers[httputil.HeaderPomeriumAuthorization] = token
+	}
+	sourceHost, _, _ := net.SplitHostPort(remoteAddr.String())
+
+	res, err := srv.authorizer.Check(ctx, &envoy_service_auth_v3.CheckRequest{
+		Attributes: &envoy_service_auth_v3.AttributeContext{
+			Source: &envoy_service_auth_v3.AttributeContext_Peer{
+				Address: &envoy_config_core_v3.Address{
+					Address: &envoy_config_core_v3.Address_SocketAddress{
+						SocketAddress: &envoy_config_core_v3.SocketAddress{Address: sourceHost},
+					},
+				},
+			},
+			Request: &envoy_service_auth_v3.AttributeContext_Request{
+				Http: &envoy_service_auth_v3.AttributeContext_HttpRequest{
+					Method:  method,
+					Scheme:  "egress",
+					Host:    dst,
+					Path:    "/",
+					Headers: headers,
+				},
+			},
+			ContextExtensions: envoyconfig.MakeExtAuthzContextExtensions(false, routeID),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("forwardproxy: error authorizing request: %w", err)
+	}
+
+	if res.GetStatus().GetCode() == int32(codes.OK) {
+		return nil
+	}
+	switch res.GetDeniedResponse().GetStatus().GetCode() {
+	case envoy_type_v3.StatusCode_Unauthorized, envoy_type_v3.StatusCode_Found:
+		return errUnauthenticated
+	default:
+		return errForbidden
+	}
+}
+
+// getEgressPolicy returns the first egress policy matching the destination host and port.
+func getEgressPolicy(options *config.Options, dst string) *config.Policy {
+	for i := range options.EgressPolicies {
+		p := &options.EgressPolicies[i]
+		if p.Matches(url.URL{Scheme: "egress", Host: dst}) {
+			return p
+		}
+	}
+	return nil
+}
+
+// pipe copies data between the client and the upstream until the upstream is done sending.
+func pipe(client io.ReadWriter, upstream net.Conn) {
+	go func() {
+		_, _ = io.Copy(upstream, client)
+		// let the upstream know the client is done sending
+		if cw, ok := upstream.(interface{ CloseWrite() error }); ok {
+			_ = cw.CloseWrite()
+		} else {
+			_ = upstream.Close()
+		}
+	}()
+	_, _ = io.Copy(client, upstream)
+	_ = upstream.Close()
+}
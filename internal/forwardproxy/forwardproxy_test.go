@@ -0,0 +1,265 @@
+package forwardproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	envoy_service_auth_v3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+type mockAuthorizer func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse
+
+func (m mockAuthorizer) Check(_ context.Context, req *envoy_service_auth_v3.CheckRequest) (*envoy_service_auth_v3.CheckResponse, error) {
+	return m(req), nil
+}
+
+// tokenAuthorizer allows requests with the token "VALID" and treats requests without a token as unauthenticated.
+var tokenAuthorizer = mockAuthorizer(func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse {
+	switch req.GetAttributes().GetRequest().GetHttp().GetHeaders()[httputil.HeaderPomeriumAuthorization] {
+	case "VALID":
+		return &envoy_service_auth_v3.CheckResponse{Status: &status.Status{Code: int32(codes.OK)}}
+	case "":
+		return deniedResponse(envoy_type_v3.StatusCode_Unauthorized)
+	default:
+		return deniedResponse(envoy_type_v3.StatusCode_Forbidden)
+	}
+})
+
+func deniedResponse(code envoy_type_v3.StatusCode) *envoy_service_auth_v3.CheckResponse {
+	return &envoy_service_auth_v3.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied)},
+		HttpResponse: &envoy_service_auth_v3.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v3.DeniedHttpResponse{
+				Status: &envoy_type_v3.HttpStatus{Code: code},
+			},
+		},
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	echoAddr := startEchoServer(t)
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "proxy-authorization="+r.Header.Get(httputil.HeaderProxyAuthorization))
+	}))
+	t.Cleanup(httpSrv.Close)
+	httpURL, err := url.Parse(httpSrv.URL)
+	require.NoError(t, err)
+
+	checkRequest := atomicutil.NewValue[*envoy_service_auth_v3.CheckRequest](nil)
+	srv := New(mockAuthorizer(func(req *envoy_service_auth_v3.CheckRequest) *envoy_service_auth_v3.CheckResponse {
+		checkRequest.Store(req)
+		return tokenAuthorizer(req)
+	}))
+	srv.OnConfigChange(ctx, &config.Config{Options: &config.Options{
+		EgressPolicies: []config.Policy{
+			{From: "egress://" + echoAddr},
+			{From: "egress://" + httpURL.Hostname()},
+		},
+	}})
+	addr := startServer(ctx, t, srv)
+
+	t.Run("connect", func(t *testing.T) {
+		conn, br := dialHTTPConnect(t, addr, echoAddr, "Pomerium VALID")
+		res, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assertEcho(t, conn, br)
+
+		httpAttributes := checkRequest.Load().GetAttributes().GetRequest().GetHttp()
+		assert.Equal(t, "CONNECT", httpAttributes.GetMethod())
+		assert.Equal(t, echoAddr, httpAttributes.GetHost())
+		assert.Equal(t, "egress", httpAttributes.GetScheme())
+	})
+	t.Run("connect basic auth", func(t *testing.T) {
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:VALID"))
+		conn, br := dialHTTPConnect(t, addr, echoAddr, auth)
+		res, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assertEcho(t, conn, br)
+	})
+	t.Run("unauthenticated", func(t *testing.T) {
+		_, br := dialHTTPConnect(t, addr, echoAddr, "")
+		res, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusProxyAuthRequired, res.StatusCode)
+		assert.Equal(t, `Basic realm="pomerium"`, res.Header.Get("Proxy-Authenticate"))
+	})
+	t.Run("forbidden", func(t *testing.T) {
+		_, br := dialHTTPConnect(t, addr, echoAddr, "Pomerium INVALID")
+		res, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+	t.Run("no egress policy", func(t *testing.T) {
+		_, br := dialHTTPConnect(t, addr, "example.com:443", "Pomerium VALID")
+		res, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	})
+	t.Run("http", func(t *testing.T) {
+		proxyURL := &url.URL{Scheme: "http", Host: addr, User: url.UserPassword("user", "VALID")}
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+		res, err := client.Get(httpSrv.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "proxy-authorization=", string(body), "should not forward the proxy authorization")
+	})
+	t.Run("socks", func(t *testing.T) {
+		conn, br := dialSOCKS(t, addr, "VALID", echoAddr)
+		reply := make([]byte, 10)
+		_, err := io.ReadFull(br, reply)
+		require.NoError(t, err)
+		assert.Equal(t, byte(socksReplySucceeded), reply[1])
+		assertEcho(t, conn, br)
+	})
+	t.Run("socks forbidden", func(t *testing.T) {
+		_, br := dialSOCKS(t, addr, "INVALID", echoAddr)
+		reply := make([]byte, 10)
+		_, err := io.ReadFull(br, reply)
+		require.NoError(t, err)
+		assert.Equal(t, byte(socksReplyNotAllowed), reply[1])
+	})
+}
+
+func TestGetProxyAuthorizationToken(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		auth   string
+		expect string
+	}{
+		{"", ""},
+		{"Pomerium TOKEN", "TOKEN"},
+		{"Basic " + base64.StdEncoding.EncodeToString([]byte("user:TOKEN")), "TOKEN"},
+		{"Basic !!!", ""},
+		{"Bearer TOKEN", ""},
+	} {
+		headers := http.Header{}
+		if tc.auth != "" {
+			headers.Set(httputil.HeaderProxyAuthorization, tc.auth)
+		}
+		assert.Equal(t, tc.expect, getProxyAuthorizationToken(headers), tc.auth)
+	}
+}
+
+func startServer(ctx context.Context, t *testing.T, srv *Server) string {
+	t.Helper()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(ctx, li) }()
+	return li.Addr().String()
+}
+
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = li.Close() })
+	go func() {
+		for {
+			conn, err := li.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return li.Addr().String()
+}
+
+func dialHTTPConnect(t *testing.T, proxyAddr, dst, auth string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	req := "CONNECT " + dst + " HTTP/1.1\r\nHost: " + dst + "\r\n"
+	if auth != "" {
+		req += httputil.HeaderProxyAuthorization + ": " + auth + "\r\n"
+	}
+	_, err = io.WriteString(conn, req+"\r\n")
+	require.NoError(t, err)
+	return conn, bufio.NewReader(conn)
+}
+
+func dialSOCKS(t *testing.T, proxyAddr, token, dst string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	br := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte{socksVersion, 1, socksMethodUserPass})
+	require.NoError(t, err)
+	method := make([]byte, 2)
+	_, err = io.ReadFull(br, method)
+	require.NoError(t, err)
+	require.Equal(t, []byte{socksVersion, socksMethodUserPass}, method)
+
+	auth := []byte{socksUserPassVersion, 4}
+	auth = append(auth, "user"...)
+	auth = append(auth, byte(len(token)))
+	auth = append(auth, token...)
+	_, err = conn.Write(auth)
+	require.NoError(t, err)
+	authReply := make([]byte, 2)
+	_, err = io.ReadFull(br, authReply)
+	require.NoError(t, err)
+
+	host, portStr, err := net.SplitHostPort(dst)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+	req := []byte{socksVersion, socksCommandConnect, 0x00, socksAddressDomain, byte(len(host))}
+	req = append(req, host...)
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	_, err = conn.Write(req)
+	require.NoError(t, err)
+
+	return conn, br
+}
+
+func assertEcho(t *testing.T, conn net.Conn, br *bufio.Reader) {
+	t.Helper()
+
+	_, err := io.WriteString(conn, "hello\n")
+	require.NoError(t, err)
+	line, err := br.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+}
@@ -0,0 +1,142 @@
+package forwardproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// serveHTTP serves HTTP forward proxy requests. CONNECT requests are tunneled to the destination and
+// absolute-form http requests are forwarded to it.
+func (srv *Server) serveHTTP(ctx context.Context, conn net.Conn, br *bufio.Reader) {
+	transport := &http.Transport{
+		DialContext:       srv.dialer.DialContext,
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+
+		dst, err := getHTTPDestination(req)
+		if err != nil {
+			writeHTTPError(conn, http.StatusBadRequest, err)
+			return
+		}
+
+		err = srv.authorize(ctx, req.Method, dst, getProxyAuthorizationToken(req.Header), conn.RemoteAddr())
+		if errors.Is(err, errUnauthenticated) {
+			writeHTTPError(conn, http.StatusProxyAuthRequired, err)
+			return
+		} else if errors.Is(err, errForbidden) {
+			writeHTTPError(conn, http.StatusForbidden, err)
+			return
+		} else if err != nil {
+			log.Error(ctx).Err(err).Str("destination", dst).Msg("forwardproxy: error authorizing request")
+			writeHTTPError(conn, http.StatusInternalServerError, err)
+			return
+		}
+
+		if req.Method == http.MethodConnect {
+			upstream, err := srv.dialer.DialContext(ctx, "tcp", dst)
+			if err != nil {
+				writeHTTPError(conn, http.StatusBadGateway, err)
+				return
+			}
+			_, err = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+			if err != nil {
+				_ = upstream.Close()
+				return
+			}
+			pipe(struct {
+				io.Reader
+				io.Writer
+			}{br, conn}, upstream)
+			return
+		}
+
+		req.RequestURI = ""
+		req.URL.Host = dst
+		req.Header.Del(httputil.HeaderProxyAuthorization)
+		req.Header.Del("Proxy-Connection")
+		res, err := transport.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			writeHTTPError(conn, http.StatusBadGateway, err)
+			return
+		}
+		err = res.Write(conn)
+		_ = res.Body.Close()
+		if err != nil || req.Close || res.Close {
+			return
+		}
+	}
+}
+
+// getHTTPDestination returns the host and port a forward proxy request is for.
+func getHTTPDestination(req *http.Request) (string, error) {
+	if req.Method == http.MethodConnect {
+		if _, _, err := net.SplitHostPort(req.Host); err != nil {
+			return "", fmt.Errorf("invalid CONNECT destination: %w", err)
+		}
+		return req.Host, nil
+	}
+
+	if req.URL.Scheme != "http" || req.URL.Host == "" {
+		return "", fmt.Errorf("only absolute http urls can be forwarded")
+	}
+	if req.URL.Port() == "" {
+		return net.JoinHostPort(req.URL.Hostname(), "80"), nil
+	}
+	return req.URL.Host, nil
+}
+
+// getProxyAuthorizationToken returns the pomerium token from the Proxy-Authorization header. Either the
+// pomerium authorization type or basic auth, with the token as the password, may be used.
+func getProxyAuthorizationToken(headers http.Header) string {
+	auth := headers.Get(httputil.HeaderProxyAuthorization)
+
+	if token, ok := strings.CutPrefix(auth, httputil.AuthorizationTypePomerium+" "); ok {
+		return token
+	}
+
+	if encoded, ok := strings.CutPrefix(auth, "Basic "); ok {
+		bs, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ""
+		}
+		_, password, _ := strings.Cut(string(bs), ":")
+		return password
+	}
+
+	return ""
+}
+
+func writeHTTPError(w io.Writer, status int, err error) {
+	res := &http.Response{
+		StatusCode: status,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Close:      true,
+	}
+	if status == http.StatusProxyAuthRequired {
+		res.Header.Set("Proxy-Authenticate", `Basic realm="pomerium"`)
+	}
+	body := err.Error() + "\n"
+	res.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	res.ContentLength = int64(len(body))
+	res.Body = io.NopCloser(strings.NewReader(body))
+	_ = res.Write(w)
+}
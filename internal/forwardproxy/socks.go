@@ -0,0 +1,193 @@
+package forwardproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// SOCKS5, see RFC 1928 and RFC 1929
+const (
+	socksMethodNoAuth       = 0x00
+	socksMethodUserPass     = 0x02
+	socksMethodNoAcceptable = 0xff
+
+	socksUserPassVersion = 0x01
+
+	socksCommandConnect = 0x01
+
+	socksAddressIPv4   = 0x01
+	socksAddressDomain = 0x03
+	socksAddressIPv6   = 0x04
+
+	socksReplySucceeded               = 0x00
+	socksReplyGeneralFailure          = 0x01
+	socksReplyNotAllowed              = 0x02
+	socksReplyHostUnreachable         = 0x04
+	socksReplyCommandNotSupported     = 0x07
+	socksReplyAddressTypeNotSupported = 0x08
+)
+
+var errSOCKSAddressType = errors.New("unsupported address type")
+
+// serveSOCKS serves SOCKS5 CONNECT requests. The pomerium token may be passed as the password using
+// username/password authentication.
+func (srv *Server) serveSOCKS(ctx context.Context, conn net.Conn, br *bufio.Reader) {
+	token, err := socksNegotiate(conn, br)
+	if err != nil {
+		return
+	}
+
+	var header [3]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil || header[0] != socksVersion {
+		return
+	}
+	dst, err := socksReadAddress(br)
+	if errors.Is(err, errSOCKSAddressType) {
+		_ = socksReply(conn, socksReplyAddressTypeNotSupported)
+		return
+	} else if err != nil {
+		return
+	}
+	if header[1] != socksCommandConnect {
+		_ = socksReply(conn, socksReplyCommandNotSupported)
+		return
+	}
+
+	err = srv.authorize(ctx, "CONNECT", dst, token, conn.RemoteAddr())
+	if errors.Is(err, errUnauthenticated) || errors.Is(err, errForbidden) {
+		_ = socksReply(conn, socksReplyNotAllowed)
+		return
+	} else if err != nil {
+		log.Error(ctx).Err(err).Str("destination", dst).Msg("forwardproxy: error authorizing request")
+		_ = socksReply(conn, socksReplyGeneralFailure)
+		return
+	}
+
+	upstream, err := srv.dialer.DialContext(ctx, "tcp", dst)
+	if err != nil {
+		_ = socksReply(conn, socksReplyHostUnreachable)
+		return
+	}
+	if err := socksReply(conn, socksReplySucceeded); err != nil {
+		_ = upstream.Close()
+		return
+	}
+	pipe(struct {
+		io.Reader
+		io.Writer
+	}{br, conn}, upstream)
+}
+
+// socksNegotiate negotiates the authentication method and returns the token, if one was sent.
+func socksNegotiate(w io.Writer, r io.Reader) (string, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return "", err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return "", err
+	}
+
+	method := byte(socksMethodNoAcceptable)
+	for _, m := range methods {
+		if m == socksMethodUserPass {
+			method = socksMethodUserPass
+			break
+		} else if m == socksMethodNoAuth {
+			method = socksMethodNoAuth
+		}
+	}
+	if _, err := w.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+
+	switch method {
+	case socksMethodNoAuth:
+		return "", nil
+	case socksMethodUserPass:
+	default:
+		return "", fmt.Errorf("no acceptable authentication methods")
+	}
+
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return "", err
+	}
+	if version[0] != socksUserPassVersion {
+		return "", fmt.Errorf("unsupported username/password version: %d", version[0])
+	}
+	if _, err := socksReadString(r); err != nil {
+		return "", err
+	}
+	password, err := socksReadString(r)
+	if err != nil {
+		return "", err
+	}
+	// the token is checked by the authorize service, so always report success here
+	if _, err := w.Write([]byte{socksUserPassVersion, 0x00}); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// socksReadAddress reads a destination address and port and returns it as host:port.
+func socksReadAddress(r io.Reader) (string, error) {
+	var atyp [1]byte
+	if _, err := io.ReadFull(r, atyp[:]); err != nil {
+		return "", err
+	}
+
+	var host string
+	switch atyp[0] {
+	case socksAddressIPv4, socksAddressIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if atyp[0] == socksAddressIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksAddressDomain:
+		domain, err := socksReadString(r)
+		if err != nil {
+			return "", err
+		}
+		host = domain
+	default:
+		return "", errSOCKSAddressType
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+// socksReadString reads a length-prefixed string.
+func socksReadString(r io.Reader) (string, error) {
+	var n [1]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return "", err
+	}
+	bs := make([]byte, n[0])
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+func socksReply(w io.Writer, code byte) error {
+	_, err := w.Write([]byte{socksVersion, code, 0x00, socksAddressIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/pkg/contextutil"
+	"github.com/pomerium/pomerium/ui"
+)
+
+// AdminConsoleData is the data for the AdminConsole page.
+type AdminConsoleData struct {
+	CSRFToken string
+	Routes    []AdminConsoleRoute
+
+	// Sessions are a page of all the sessions, starting at SessionsOffset.
+	Sessions           []UserSession
+	SessionsOffset     int
+	SessionsLimit      int
+	SessionsTotalCount int
+
+	// PolicyTest is the result of testing a policy, if one was requested.
+	PolicyTest *AdminConsolePolicyTest
+
+	BrandingOptions httputil.BrandingOptions
+}
+
+// An AdminConsoleRoute is a configured route.
+type AdminConsoleRoute struct {
+	ID     string   `json:"id"`
+	From   string   `json:"from"`
+	To     []string `json:"to,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	Path   string   `json:"path,omitempty"`
+	Regex  string   `json:"regex,omitempty"`
+}
+
+// An AdminConsolePolicyTest is the result of evaluating a route's policy for a hypothetical request.
+type AdminConsolePolicyTest struct {
+	Method    string                              `json:"method"`
+	URL       string                              `json:"url"`
+	SessionID string                              `json:"sessionId,omitempty"`
+	RouteID   string                              `json:"routeId,omitempty"`
+	Allow     bool                                `json:"allow"`
+	Deny      bool                                `json:"deny"`
+	Reasons   []string                            `json:"reasons,omitempty"`
+	Traces    []contextutil.PolicyEvaluationTrace `json:"traces,omitempty"`
+	Error     string                              `json:"error,omitempty"`
+}
+
+// ToJSON converts the data into a JSON map.
+func (data AdminConsoleData) ToJSON() map[string]any {
+	m := map[string]any{}
+	m["csrfToken"] = data.CSRFToken
+	m["routes"] = data.Routes
+	var sessions []map[string]any
+	for _, s := range data.Sessions {
+		if bs, err := protojson.Marshal(s.Session); err == nil {
+			sessions = append(sessions, map[string]any{
+				"session":   json.RawMessage(bs),
+				"revokeUrl": s.RevokeURL,
+			})
+		}
+	}
+	m["sessions"] = sessions
+	m["sessionsOffset"] = data.SessionsOffset
+	m["sessionsLimit"] = data.SessionsLimit
+	m["sessionsTotalCount"] = data.SessionsTotalCount
+	if data.PolicyTest != nil {
+		m["policyTest"] = data.PolicyTest
+	}
+	httputil.AddBrandingOptionsToMap(m, data.BrandingOptions)
+	return m
+}
+
+// AdminConsole returns a handler that renders the admin console page.
+func AdminConsole(data AdminConsoleData) http.Handler {
+	return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return ui.ServePage(w, r, "AdminConsole", data.ToJSON())
+	})
+}
@@ -3,6 +3,9 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+
+	"github.com/pomerium/pomerium/internal/health"
+	"github.com/pomerium/pomerium/internal/httputil"
 )
 
 // HealthCheck is a simple healthcheck handler that responds to GET and HEAD
@@ -18,3 +21,31 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, http.StatusText(http.StatusOK))
 	}
 }
+
+// Liveness is a healthcheck handler which responds with the status of the dependencies of
+// pomerium as JSON. It always responds with 200 OK, as failed dependencies, like an unreachable
+// identity provider, aren't fixed by restarting pomerium.
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	healthReport(w, r, false)
+}
+
+// Readiness is a healthcheck handler which responds with the status of the dependencies of
+// pomerium as JSON. It responds with 503 Service Unavailable if a dependency failed.
+func Readiness(w http.ResponseWriter, r *http.Request) {
+	healthReport(w, r, true)
+}
+
+func healthReport(w http.ResponseWriter, r *http.Request, ready bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	report := health.GetReport()
+	status := http.StatusOK
+	if ready && !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.RenderJSON(w, status, report)
+}
@@ -1,9 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/health"
 )
 
 func TestHealthCheck(t *testing.T) {
@@ -34,3 +41,40 @@ func TestHealthCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestLivenessReadiness(t *testing.T) {
+	health.Set(health.CheckDataBroker, health.OK())
+	health.Set(health.CheckIdentityProvider, health.Error(errors.New("unreachable")))
+	t.Cleanup(func() {
+		health.Delete(health.CheckDataBroker)
+		health.Delete(health.CheckIdentityProvider)
+	})
+
+	for _, tc := range []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		wantStatus int
+	}{
+		{"liveness", Liveness, http.MethodGet, http.StatusOK},
+		{"readiness", Readiness, http.MethodGet, http.StatusServiceUnavailable},
+		{"readiness head", Readiness, http.MethodHead, http.StatusServiceUnavailable},
+		{"readiness post", Readiness, http.MethodPost, http.StatusMethodNotAllowed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/", nil)
+			w := httptest.NewRecorder()
+			tc.handler(w, r)
+			assert.Equal(t, tc.wantStatus, w.Code)
+			if w.Code == http.StatusMethodNotAllowed {
+				return
+			}
+
+			var report health.Report
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, health.StatusError, report.Status)
+			assert.Equal(t, health.StatusOK, report.Checks[health.CheckDataBroker].Status)
+			assert.Equal(t, "unreachable", report.Checks[health.CheckIdentityProvider].Message)
+		})
+	}
+}
@@ -23,6 +23,10 @@ type UserInfoData struct {
 	User           *user.User
 	Profile        *identity.Profile
 
+	// Sessions are all of the user's active sessions.
+	Sessions        []UserSession
+	AccessDecisions []user.AccessDecision
+
 	IsEnterprise    bool
 	DirectoryUser   *directory.User
 	DirectoryGroups []*directory.Group
@@ -34,6 +38,12 @@ type UserInfoData struct {
 	BrandingOptions httputil.BrandingOptions
 }
 
+// A UserSession is one of the user's sessions along with the URL used to revoke it.
+type UserSession struct {
+	Session   *session.Session
+	RevokeURL string
+}
+
 // ToJSON converts the data into a JSON map.
 func (data UserInfoData) ToJSON() map[string]any {
 	m := map[string]any{}
@@ -48,6 +58,21 @@ func (data UserInfoData) ToJSON() map[string]any {
 	if bs, err := protojson.Marshal(data.Profile); err == nil {
 		m["profile"] = json.RawMessage(bs)
 	}
+	var sessions []map[string]any
+	for _, s := range data.Sessions {
+		if bs, err := protojson.Marshal(s.Session); err == nil {
+			sessions = append(sessions, map[string]any{
+				"session":   json.RawMessage(bs),
+				"revokeUrl": s.RevokeURL,
+			})
+		}
+	}
+	if len(sessions) > 0 {
+		m["sessions"] = sessions
+	}
+	if len(data.AccessDecisions) > 0 {
+		m["accessDecisions"] = data.AccessDecisions
+	}
 	m["isEnterprise"] = data.IsEnterprise
 	if data.DirectoryUser != nil {
 		m["directoryUser"] = data.DirectoryUser
@@ -15,9 +15,12 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/pomerium/pomerium/internal/audit"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/middleware"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/throttle"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/device"
@@ -52,6 +55,8 @@ type State struct {
 	SessionStore            sessions.SessionStore
 	SharedKey               []byte
 	BrandingOptions         httputil.BrandingOptions
+	// Throttle throttles failed authentications. It may be nil.
+	Throttle *throttle.Throttle
 }
 
 // A StateProvider provides state for the handler.
@@ -131,7 +136,22 @@ func (h *Handler) handle(w http.ResponseWriter, r *http.Request) error {
 	case r.Method == http.MethodGet:
 		return h.handleView(w, r, s)
 	case r.FormValue("action") == "authenticate":
-		return h.handleAuthenticate(w, r, s)
+		// failed authentications are throttled per client IP and per user
+		keys := []string{
+			throttle.IPKey(httputil.GetClientIPAddress(r)),
+			throttle.IdentityKey(s.Session.GetUserId()),
+		}
+		if err := s.Throttle.CheckRequest(w, r, keys...); err != nil {
+			return err
+		}
+		err := h.handleAuthenticate(w, r, s)
+		if err == nil {
+			if err := s.Throttle.Reset(r.Context(), keys[1]); err != nil {
+				log.FromRequest(r).Error().Err(err).Msg("webauthn: error resetting failed attempts")
+			}
+		}
+		s.Throttle.RecordResult(r, err, keys...)
+		return err
 	case r.FormValue("action") == "register":
 		return h.handleRegister(w, r, s)
 	case r.FormValue("action") == "unregister":
@@ -328,6 +348,18 @@ func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request, state *
 		return err
 	}
 
+	audit.Record(ctx, audit.Event{
+		Type:      audit.EventDeviceEnrolled,
+		UserID:    u.GetId(),
+		SessionID: state.Session.GetId(),
+		Email:     u.GetEmail(),
+		Details: map[string]string{
+			"device_type_id":       deviceType.GetId(),
+			"device_credential_id": deviceCredentialID,
+			"device_enrollment_id": deviceEnrollment.GetId(),
+		},
+	})
+
 	// update the session
 	state.Session.DeviceCredentials = append(state.Session.DeviceCredentials, &session.Session_DeviceCredential{
 		TypeId: deviceType.GetId(),
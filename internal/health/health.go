@@ -0,0 +1,168 @@
+// Package health keeps the status of the dependencies of pomerium, such as the databroker and the
+// identity provider, for the health and readiness endpoints.
+//
+// Results are either set by the component owning a dependency, like the policy evaluator of the
+// authorize service, or by checks run periodically in the background, so that health requests
+// don't wait on dependencies.
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Status is the status of a dependency.
+type Status string
+
+// Statuses, in increasing order of severity.
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning"
+	StatusError   Status = "error"
+)
+
+func (s Status) severity() int {
+	switch s {
+	case StatusWarning:
+		return 1
+	case StatusError:
+		return 2
+	}
+	return 0
+}
+
+// Names of the dependency checks.
+const (
+	CheckDataBroker       = "databroker"
+	CheckIdentityProvider = "identity_provider"
+	CheckCertificates     = "certificates"
+	CheckPolicyEvaluator  = "policy_evaluator"
+)
+
+// A Result is the result of checking a dependency.
+type Result struct {
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+	// Details are additional check specific details.
+	Details   map[string]string `json:"details,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// OK returns a healthy result.
+func OK() Result {
+	return Result{Status: StatusOK}
+}
+
+// Warning returns a result for a dependency which works, but needs attention soon.
+func Warning(message string) Result {
+	return Result{Status: StatusWarning, Message: message}
+}
+
+// Error returns a result for a failed dependency.
+func Error(err error) Result {
+	return Result{Status: StatusError, Message: err.Error()}
+}
+
+// A Report is the status of all dependencies.
+type Report struct {
+	// Status is the most severe status of the checks.
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Ready returns true if no dependency failed.
+func (r *Report) Ready() bool {
+	return r.Status != StatusError
+}
+
+// Failed returns the names of the failed checks, in order.
+func (r *Report) Failed() []string {
+	var names []string
+	for name, result := range r.Checks {
+		if result.Status == StatusError {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A Registry keeps the latest result of each check.
+type Registry struct {
+	now func() time.Time
+
+	mu      sync.RWMutex
+	results map[string]Result
+}
+
+// NewRegistry creates a new Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		now:     time.Now,
+		results: make(map[string]Result),
+	}
+}
+
+// Set sets the result of a check.
+func (r *Registry) Set(name string, result Result) {
+	if result.CheckedAt.IsZero() {
+		result.CheckedAt = r.now()
+	}
+
+	r.mu.Lock()
+	r.results[name] = result
+	r.mu.Unlock()
+}
+
+// Delete deletes the result of a check which no longer applies.
+func (r *Registry) Delete(name string) {
+	r.mu.Lock()
+	delete(r.results, name)
+	r.mu.Unlock()
+}
+
+// Get returns the result of a check.
+func (r *Registry) Get(name string) (Result, bool) {
+	r.mu.RLock()
+	result, ok := r.results[name]
+	r.mu.RUnlock()
+	return result, ok
+}
+
+// Report returns the status of all checks.
+func (r *Registry) Report() *Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]Result, len(r.results))}
+	for name, result := range r.results {
+		report.Checks[name] = result
+		if result.Status.severity() > report.Status.severity() {
+			report.Status = result.Status
+		}
+	}
+	return report
+}
+
+var global = NewRegistry()
+
+// Set sets the result of a check in the global registry.
+func Set(name string, result Result) {
+	global.Set(name, result)
+}
+
+// Delete deletes the result of a check from the global registry.
+func Delete(name string) {
+	global.Delete(name)
+}
+
+// Get returns the result of a check in the global registry.
+func Get(name string) (Result, bool) {
+	return global.Get(name)
+}
+
+// GetReport returns the status of all checks in the global registry.
+func GetReport() *Report {
+	return global.Report()
+}
@@ -0,0 +1,43 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	report := r.Report()
+	assert.Equal(t, StatusOK, report.Status)
+	assert.True(t, report.Ready())
+	assert.Empty(t, report.Checks)
+
+	r.Set(CheckDataBroker, OK())
+	r.Set(CheckCertificates, Warning("certificate expires soon"))
+	report = r.Report()
+	assert.Equal(t, StatusWarning, report.Status)
+	assert.True(t, report.Ready())
+	assert.Equal(t, Result{Status: StatusOK, CheckedAt: now}, report.Checks[CheckDataBroker])
+
+	r.Set(CheckPolicyEvaluator, Error(errors.New("invalid policy")))
+	r.Set(CheckIdentityProvider, Error(errors.New("unreachable")))
+	report = r.Report()
+	assert.Equal(t, StatusError, report.Status)
+	assert.False(t, report.Ready())
+	assert.Equal(t, []string{CheckIdentityProvider, CheckPolicyEvaluator}, report.Failed())
+
+	r.Delete(CheckPolicyEvaluator)
+	r.Set(CheckIdentityProvider, OK())
+	report = r.Report()
+	assert.Equal(t, StatusWarning, report.Status)
+	_, ok := r.Get(CheckPolicyEvaluator)
+	assert.False(t, ok)
+}
@@ -9,6 +9,13 @@ type BrandingOptions interface {
 	GetLogoUrl() string
 	GetFaviconUrl() string
 	GetErrorMessageFirstParagraph() string
+	GetFooterLinks() []FooterLink
+}
+
+// A FooterLink is a link shown in the footer of the user info and error pages.
+type FooterLink struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
 }
 
 // AddBrandingOptionsToMap adds the branding options to the map.
@@ -32,4 +39,7 @@ func AddBrandingOptionsToMap(dst map[string]any, brandingOptions BrandingOptions
 	if brandingOptions.GetErrorMessageFirstParagraph() != "" {
 		dst["errorMessageFirstParagraph"] = brandingOptions.GetErrorMessageFirstParagraph()
 	}
+	if links := brandingOptions.GetFooterLinks(); len(links) > 0 {
+		dst["footerLinks"] = links
+	}
 }
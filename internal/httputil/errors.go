@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/url"
 
+	"github.com/tniswong/go.rfcx/rfc7231"
+
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/pkg/contextutil"
@@ -20,6 +22,8 @@ type HTTPError struct {
 	Description string
 	// DebugURL is the URL to the debug endpoint.
 	DebugURL *url.URL
+	// AuthURL is the URL to sign in at, for unauthenticated requests which aren't redirected.
+	AuthURL *url.URL
 	// The request ID.
 	RequestID string
 
@@ -59,6 +63,7 @@ func (e *HTTPError) ErrorResponse(ctx context.Context, w http.ResponseWriter, r
 		RequestID              string                              `json:",omitempty"`
 		CanDebug               bool                                `json:"-"`
 		DebugURL               *url.URL                            `json:",omitempty"`
+		AuthURL                string                              `json:",omitempty"`
 		PolicyEvaluationTraces []contextutil.PolicyEvaluationTrace `json:",omitempty"`
 	}{
 		Status:                 e.Status,
@@ -69,6 +74,9 @@ func (e *HTTPError) ErrorResponse(ctx context.Context, w http.ResponseWriter, r
 		DebugURL:               e.DebugURL,
 		PolicyEvaluationTraces: contextutil.GetPolicyEvaluationTraces(ctx),
 	}
+	if e.AuthURL != nil {
+		response.AuthURL = e.AuthURL.String()
+	}
 	// indicate to clients that the error originates from Pomerium, not the app
 	w.Header().Set(HeaderPomeriumResponse, "true")
 
@@ -81,7 +89,7 @@ func (e *HTTPError) ErrorResponse(ctx context.Context, w http.ResponseWriter, r
 			Msg("httputil: error")
 	}
 
-	if r.Header.Get("Accept") == "application/json" {
+	if PrefersJSON(r) {
 		RenderJSON(w, e.Status, response)
 		return
 	}
@@ -97,6 +105,9 @@ func (e *HTTPError) ErrorResponse(ctx context.Context, w http.ResponseWriter, r
 	if response.DebugURL != nil {
 		m["debugUrl"] = response.DebugURL.String()
 	}
+	if response.AuthURL != "" {
+		m["authUrl"] = response.AuthURL
+	}
 	AddBrandingOptionsToMap(m, e.BrandingOptions)
 
 	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
@@ -106,6 +117,16 @@ func (e *HTTPError) ErrorResponse(ctx context.Context, w http.ResponseWriter, r
 	}
 }
 
+// PrefersJSON returns true if the request's Accept header prefers JSON over HTML.
+func PrefersJSON(r *http.Request) bool {
+	accept, err := rfc7231.ParseAccept(r.Header.Get("Accept"))
+	if err != nil {
+		return false
+	}
+	mediaType, ok := accept.MostAcceptable([]string{"text/html", "application/json"})
+	return ok && mediaType == "application/json"
+}
+
 // WithDescription sets the description in the HTTP error.
 func (e *HTTPError) WithDescription(description string) *HTTPError {
 	e.Description = description
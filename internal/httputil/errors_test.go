@@ -71,3 +71,22 @@ func TestNewError(t *testing.T) {
 		})
 	}
 }
+
+func TestPrefersJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"application/json", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := PrefersJSON(r); got != tt.want {
+			t.Errorf("PrefersJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -5,10 +5,11 @@ const AuthorizationTypePomerium = "Pomerium"
 
 // Standard headers
 const (
-	HeaderAuthorization    = "Authorization"
-	HeaderReferrer         = "Referer"
-	HeaderImpersonateGroup = "Impersonate-Group"
-	HeaderUpgrade          = "Upgrade"
+	HeaderAuthorization      = "Authorization"
+	HeaderReferrer           = "Referer"
+	HeaderImpersonateGroup   = "Impersonate-Group"
+	HeaderUpgrade            = "Upgrade"
+	HeaderProxyAuthorization = "Proxy-Authorization"
 )
 
 // Pomerium headers contain information added to a request.
@@ -32,6 +33,9 @@ const (
 	HeaderPomeriumReproxyPolicyHMAC = "x-pomerium-reproxy-policy-hmac"
 	// HeaderPomeriumRoutingKey is a string used for routing user requests to a consistent upstream server.
 	HeaderPomeriumRoutingKey = "x-pomerium-routing-key"
+	// HeaderPomeriumAgentProof is the header key containing a desktop agent's proof of possession
+	// of its device key.
+	HeaderPomeriumAgentProof = "x-pomerium-agent-proof"
 )
 
 // HeadersContentSecurityPolicy are the content security headers added to the service's handlers
@@ -16,7 +16,10 @@ const (
 )
 
 var detailsText = map[int]string{
-	StatusDeviceUnauthorized: "your device fails to meet the requirements necessary to access this page, please contact your administrator for assistance",
+	StatusDeviceUnauthorized:               "your device fails to meet the requirements necessary to access this page, please contact your administrator for assistance",
+	http.StatusRequestEntityTooLarge:       "the request body exceeds the maximum size allowed for this route",
+	http.StatusRequestURITooLong:           "the request URL exceeds the maximum length allowed for this route",
+	http.StatusRequestHeaderFieldsTooLarge: "the request headers exceed the maximum size allowed for this route",
 }
 
 // DetailsText returns extra details for an HTTP status code. It returns StatusText if not found.
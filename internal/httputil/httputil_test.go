@@ -24,3 +24,11 @@ func TestGetClientIPAddress(t *testing.T) {
 	r3.Header.Set("X-Envoy-External-Address", "127.0.0.3")
 	assert.Equal(t, "127.0.0.3", GetClientIPAddress(r3))
 }
+
+func TestPreferredLanguages(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, PreferredLanguages(""))
+	assert.Equal(t, []string{"de-ch", "de", "en"}, PreferredLanguages("en;q=0.5, de-CH, de;q=0.9, fr;q=0, *;q=0.1"))
+	assert.Equal(t, []string{"fr", "en"}, PreferredLanguages("fr,en;q=invalid,en;q=0.3"))
+}
@@ -0,0 +1,47 @@
+package httputil
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PreferredLanguages returns the language tags of an Accept-Language header, lowercased and
+// ordered from most to least preferred. Languages with a quality of 0 and the * wildcard are
+// skipped.
+func PreferredLanguages(acceptLanguage string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+	var languages []language
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		languages = append(languages, language{tag: tag, quality: quality})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+	return tags
+}
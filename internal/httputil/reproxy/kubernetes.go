@@ -0,0 +1,179 @@
+package reproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdlog "log"
+	"math/rand"
+	"net/http"
+	stdhttputil "net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+// errKubernetesSessionInvalid indicates the session of a kubernetes connection may no longer be used.
+var errKubernetesSessionInvalid = errors.New("kubernetes session is no longer valid")
+
+// serveKubernetes proxies the request to the kubernetes api server of the policy.
+//
+// Authorize only checks the request which opens a connection, but kubectl exec, port-forward and
+// logs -f keep theirs open for as long as the user likes. So the session is checked again
+// periodically, and the connection is closed once the session is signed out, expired or revoked.
+func serveKubernetes(
+	w http.ResponseWriter,
+	r *http.Request,
+	options *config.Options,
+	policy *config.Policy,
+	getDataBrokerClient func(ctx context.Context) (databroker.DataBrokerServiceClient, error),
+) error {
+	// remove these headers from the request to kubernetes
+	r.Header.Del(httputil.HeaderPomeriumReproxyPolicy)
+	r.Header.Del(httputil.HeaderPomeriumReproxyPolicyHMAC)
+
+	// fix the impersonate group header
+	if vs := r.Header.Values(httputil.HeaderImpersonateGroup); len(vs) > 0 {
+		vs = strings.Split(strings.Join(vs, ","), ",")
+		r.Header.Del(httputil.HeaderImpersonateGroup)
+		for _, v := range vs {
+			r.Header.Add(httputil.HeaderImpersonateGroup, v)
+		}
+	}
+
+	var dsts []url.URL
+	for _, wu := range policy.To {
+		dsts = append(dsts, wu.URL)
+	}
+	if len(dsts) == 0 {
+		return httputil.NewError(http.StatusNotFound, errors.New("policy destination not found"))
+	}
+	// regular rand is fine for this
+	dst := dsts[rand.Intn(len(dsts))] //nolint:gosec
+
+	// cancelling the request context closes upgraded connections and streamed responses
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if sessionID := getKubernetesSessionID(r); sessionID != "" && getDataBrokerClient != nil {
+		go reauthorizeKubernetesSession(ctx, cancel, getDataBrokerClient, sessionID,
+			options.GetKubernetesReauthorizeInterval())
+	}
+	r = r.WithContext(ctx)
+
+	// upgraded connections, like the SPDY and websocket streams used by kubectl exec and
+	// port-forward, can't be proxied over HTTP/2 by the reverse proxy
+	// Issue #2126
+	disableHTTP2 := isUpgrade(r)
+
+	h := stdhttputil.NewSingleHostReverseProxy(&dst)
+	h.ErrorLog = stdlog.New(log.Logger(), "", 0)
+	h.Transport = config.NewPolicyHTTPTransport(options, policy, disableHTTP2)
+	h.ServeHTTP(w, r)
+	return nil
+}
+
+// reauthorizeKubernetesSession checks the session every interval and cancels the connection once
+// the session is no longer valid. Errors reaching the databroker don't close the connection.
+func reauthorizeKubernetesSession(
+	ctx context.Context,
+	cancel context.CancelFunc,
+	getDataBrokerClient func(ctx context.Context) (databroker.DataBrokerServiceClient, error),
+	sessionID string,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client, err := getDataBrokerClient(ctx)
+		if err == nil {
+			err = checkKubernetesSession(ctx, client, sessionID, time.Now())
+		}
+		switch {
+		case errors.Is(err, errKubernetesSessionInvalid):
+			log.Info(ctx).Err(err).Str("session-id", sessionID).Msg("reproxy: closing kubernetes connection")
+			cancel()
+			return
+		case err != nil && ctx.Err() == nil:
+			log.Warn(ctx).Err(err).Str("session-id", sessionID).Msg("reproxy: error re-authorizing kubernetes connection")
+		}
+	}
+}
+
+// checkKubernetesSession returns an error wrapping errKubernetesSessionInvalid if the session or
+// service account no longer exists, has expired or, for sessions, has been revoked.
+func checkKubernetesSession(ctx context.Context, client databroker.DataBrokerServiceClient, sessionID string, now time.Time) error {
+	s, err := session.Get(ctx, client, sessionID)
+	if status.Code(err) == codes.NotFound {
+		sa, err := user.GetServiceAccount(ctx, client, sessionID)
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("%w: session not found", errKubernetesSessionInvalid)
+		} else if err != nil {
+			return err
+		}
+		if sa.GetExpiresAt().IsValid() && !now.Before(sa.GetExpiresAt().AsTime()) {
+			return fmt.Errorf("%w: service account expired", errKubernetesSessionInvalid)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if s.GetExpiresAt().IsValid() && !now.Before(s.GetExpiresAt().AsTime()) {
+		return fmt.Errorf("%w: session expired", errKubernetesSessionInvalid)
+	}
+
+	var ids []string
+	for _, v := range s.GetClaims()["email"].GetValues() {
+		ids = append(ids, session.RevocationID("email", v.GetStringValue()))
+	}
+	for _, v := range s.GetClaims()["groups"].GetValues() {
+		ids = append(ids, session.RevocationID("group", v.GetStringValue()))
+	}
+	for _, id := range ids {
+		r, err := databroker.GetViaJSON[session.Revocation](ctx, client, session.RevocationRecordType, id)
+		if status.Code(err) == codes.NotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if !s.GetIssuedAt().AsTime().After(r.RevokedAt) {
+			return fmt.Errorf("%w: session revoked", errKubernetesSessionInvalid)
+		}
+	}
+	return nil
+}
+
+// getKubernetesSessionID returns the session id from the assertion, which is set by the authorize
+// service after the request has been authorized.
+func getKubernetesSessionID(r *http.Request) string {
+	var claims struct {
+		ID string `json:"jti"`
+	}
+	assertionJWT, err := jwt.ParseSigned(r.Header.Get(httputil.HeaderPomeriumJWTAssertion))
+	if err != nil || assertionJWT.UnsafeClaimsWithoutVerification(&claims) != nil {
+		return ""
+	}
+	return claims.ID
+}
+
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get(httputil.HeaderUpgrade) != ""
+}
@@ -0,0 +1,83 @@
+package reproxy
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+func newTestDataBrokerClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+func TestCheckKubernetesSession(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	client := newTestDataBrokerClient(t)
+	now := time.Now()
+
+	s := &session.Session{
+		Id:        "S1",
+		IssuedAt:  timestamppb.New(now.Add(-time.Hour)),
+		ExpiresAt: timestamppb.New(now.Add(time.Hour)),
+	}
+	s.AddClaims(identity.FlattenedClaims{"email": {"user@example.com"}, "groups": {"admins"}})
+	_, err := session.Put(ctx, client, s)
+	require.NoError(t, err)
+
+	_, err = user.PutServiceAccount(ctx, client, &user.ServiceAccount{
+		Id:        "SA1",
+		ExpiresAt: timestamppb.New(now.Add(-time.Minute)),
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, checkKubernetesSession(ctx, client, "S1", now))
+	assert.ErrorIs(t, checkKubernetesSession(ctx, client, "S1", now.Add(2*time.Hour)), errKubernetesSessionInvalid,
+		"expired session")
+	assert.ErrorIs(t, checkKubernetesSession(ctx, client, "S2", now), errKubernetesSessionInvalid,
+		"missing session")
+	assert.ErrorIs(t, checkKubernetesSession(ctx, client, "SA1", now), errKubernetesSessionInvalid,
+		"expired service account")
+
+	require.NoError(t, session.PutRevocation(ctx, client, &session.Revocation{
+		Group:     "admins",
+		RevokedAt: now,
+	}))
+	assert.ErrorIs(t, checkKubernetesSession(ctx, client, "S1", now), errKubernetesSessionInvalid,
+		"revoked session")
+
+	require.NoError(t, session.Delete(ctx, client, "S1"))
+	assert.ErrorIs(t, checkKubernetesSession(ctx, client, "S1", now), errKubernetesSessionInvalid,
+		"deleted session")
+}
@@ -5,19 +5,15 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
-	stdlog "log"
-	"math/rand"
 	"net/http"
-	stdhttputil "net/http/httputil"
-	"net/url"
 	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 )
 
 // The Handler looks for an X-Pomerium-Reproxy-Policy header and if found re-proxies the request upstream
@@ -25,11 +21,15 @@ import (
 //
 // This is used to forward requests to Kubernetes with headers split to multiple values instead of coalesced via a
 // comma. (https://github.com/kubernetes/kubernetes/issues/94683) If the upstream issue is fixed we will remove this.
+//
+// It is also used to relay UDP datagrams for udp routes, which envoy cannot proxy itself, and to terminate
+// ssh connections for ssh routes.
 type Handler struct {
-	mu       sync.RWMutex
-	key      []byte
-	options  *config.Options
-	policies map[uint64]config.Policy
+	mu                  sync.RWMutex
+	key                 []byte
+	options             *config.Options
+	policies            map[uint64]config.Policy
+	getDataBrokerClient func(ctx context.Context) (databroker.DataBrokerServiceClient, error)
 }
 
 // New creates a new Handler.
@@ -39,6 +39,15 @@ func New() *Handler {
 	return h
 }
 
+// SetDataBrokerClient sets the function used to get a databroker client, which is needed to
+// re-authorize long-lived kubernetes connections. Without it, they are only authorized once.
+func (h *Handler) SetDataBrokerClient(getDataBrokerClient func(ctx context.Context) (databroker.DataBrokerServiceClient, error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.getDataBrokerClient = getDataBrokerClient
+}
+
 // GetPolicyIDFromHeaders gets a policy id from http headers. If no policy id is found
 // or the HMAC isn't valid, false will be returned.
 func (h *Handler) GetPolicyIDFromHeaders(headers http.Header) (uint64, bool) {
@@ -85,44 +94,20 @@ func (h *Handler) Middleware(next http.Handler) http.Handler {
 		h.mu.RLock()
 		options := h.options
 		policy, ok := h.policies[policyID]
+		getDataBrokerClient := h.getDataBrokerClient
 		h.mu.RUnlock()
 
-		if !ok || !policy.IsForKubernetes() {
-			return httputil.NewError(http.StatusNotFound, errors.New("policy not found"))
+		if ok && policy.IsUDP() {
+			return serveUDP(w, r, &policy)
 		}
-
-		// remove these headers from the request to kubernetes
-		r.Header.Del(httputil.HeaderPomeriumReproxyPolicy)
-		r.Header.Del(httputil.HeaderPomeriumReproxyPolicyHMAC)
-
-		// fix the impersonate group header
-		if vs := r.Header.Values(httputil.HeaderImpersonateGroup); len(vs) > 0 {
-			vs = strings.Split(strings.Join(vs, ","), ",")
-			r.Header.Del(httputil.HeaderImpersonateGroup)
-			for _, v := range vs {
-				r.Header.Add(httputil.HeaderImpersonateGroup, v)
-			}
+		if ok && policy.IsSSH() {
+			return serveSSH(w, r, options, &policy)
 		}
 
-		var dsts []url.URL
-		for _, wu := range policy.To {
-			dsts = append(dsts, wu.URL)
-		}
-		if len(dsts) == 0 {
-			return httputil.NewError(http.StatusNotFound, errors.New("policy destination not found"))
+		if !ok || !policy.IsForKubernetes() {
+			return httputil.NewError(http.StatusNotFound, errors.New("policy not found"))
 		}
-		// regular rand is fine for this
-		dst := dsts[rand.Intn(len(dsts))] //nolint:gosec
-
-		// when SPDY is being used, disable HTTP/2 because the two can't be used together with the reverse proxy
-		// Issue #2126
-		disableHTTP2 := isSPDY(r)
-
-		h := stdhttputil.NewSingleHostReverseProxy(&dst)
-		h.ErrorLog = stdlog.New(log.Logger(), "", 0)
-		h.Transport = config.NewPolicyHTTPTransport(options, &policy, disableHTTP2)
-		h.ServeHTTP(w, r)
-		return nil
+		return serveKubernetes(w, r, options, &policy, getDataBrokerClient)
 	})
 }
 
@@ -143,7 +128,3 @@ func (h *Handler) Update(ctx context.Context, cfg *config.Config) {
 		h.policies[id] = p
 	}
 }
-
-func isSPDY(r *http.Request) bool {
-	return strings.HasPrefix(strings.ToLower(r.Header.Get(httputil.HeaderUpgrade)), "spdy/")
-}
@@ -0,0 +1,231 @@
+package reproxy
+
+import (
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sshproxy"
+)
+
+const maxSSHCertificateRequestSize = 64 * 1024
+
+type sshClaims struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email"`
+}
+
+// serveSSH terminates an ssh connection tunneled over a CONNECT stream and proxies it to the policy's
+// upstream ssh server using a short-lived certificate minted for the user. POST requests are used to
+// issue certificates to clients instead.
+func serveSSH(w http.ResponseWriter, r *http.Request, options *config.Options, policy *config.Policy) error {
+	if r.Method == http.MethodPost {
+		return serveSSHCertificate(w, r, options, policy)
+	}
+	if r.Method != http.MethodConnect {
+		return httputil.NewError(http.StatusMethodNotAllowed, errors.New("ssh routes require CONNECT"))
+	}
+	if len(policy.To) == 0 {
+		return httputil.NewError(http.StatusNotFound, errors.New("policy destination not found"))
+	}
+
+	claims, err := getSSHClaims(r)
+	if err != nil {
+		return err
+	}
+	userCAKey, err := getSSHUserCAKey(options)
+	if err != nil {
+		return err
+	}
+	hostKey, err := options.GetSSHHostKey()
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("invalid ssh host key: %w", err))
+	}
+
+	var upstreamHostKeys []ssh.PublicKey
+	for _, k := range policy.SSHUpstreamHostKeys {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("invalid ssh upstream host key: %w", err))
+		}
+		upstreamHostKeys = append(upstreamHostKeys, key)
+	}
+
+	principals := getSSHPrincipals(policy, claims)
+
+	// regular rand is fine for this
+	dst := policy.To[rand.Intn(len(policy.To))] //nolint:gosec
+	upstreamAddr := dst.URL.Host
+	if dst.URL.Port() == "" {
+		upstreamAddr = net.JoinHostPort(dst.URL.Hostname(), "22")
+	}
+
+	stream, err := acceptConnect(w, r)
+	if err != nil {
+		return err
+	}
+
+	err = sshproxy.Serve(r.Context(), &streamConn{connectStream: stream, remoteAddr: r.RemoteAddr}, &sshproxy.Config{
+		HostKey:          hostKey,
+		UserCAKey:        userCAKey,
+		CertificateTTL:   options.SSHCertificateTTL,
+		RecordingDir:     options.SSHRecordingDir,
+		UpstreamAddr:     upstreamAddr,
+		UpstreamHostKeys: upstreamHostKeys,
+		UserID:           claims.Subject,
+		Email:            claims.Email,
+		Principals:       principals,
+	})
+	if err != nil {
+		log.Warn(r.Context()).Err(err).Str("upstream", upstreamAddr).Msg("reproxy: ssh proxy error")
+	}
+	return nil
+}
+
+// serveSSHCertificate issues a short-lived user certificate for the allowed principals of the user, so
+// that clients like OpenSSH can connect to the upstream server directly, for example through a tcp
+// route, with the certificate as their CertificateFile.
+//
+// The request contains the public key to certify in authorized_keys format. If there is none, an
+// ephemeral key is generated and its private key is returned with the certificate.
+func serveSSHCertificate(w http.ResponseWriter, r *http.Request, options *config.Options, policy *config.Policy) error {
+	claims, err := getSSHClaims(r)
+	if err != nil {
+		return err
+	}
+	userCAKey, err := getSSHUserCAKey(options)
+	if err != nil {
+		return err
+	}
+	principals := getSSHPrincipals(policy, claims)
+	if len(principals) == 0 {
+		return httputil.NewError(http.StatusForbidden, errors.New("no ssh principals are allowed for the user"))
+	}
+
+	var req struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxSSHCertificateRequestSize)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid ssh certificate request: %w", err))
+	}
+
+	var res struct {
+		Certificate string    `json:"certificate"`
+		PrivateKey  string    `json:"privateKey,omitempty"`
+		Principals  []string  `json:"principals"`
+		ValidBefore time.Time `json:"validBefore"`
+	}
+
+	var publicKey ssh.PublicKey
+	if req.PublicKey != "" {
+		publicKey, _, _, _, err = ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+		if err != nil {
+			return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid ssh public key: %w", err))
+		}
+		if _, ok := publicKey.(*ssh.Certificate); ok {
+			return httputil.NewError(http.StatusBadRequest, errors.New("invalid ssh public key: certificates cannot be certified"))
+		}
+	} else {
+		pub, priv, err := ed25519.GenerateKey(cryptorand.Reader)
+		if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+		if publicKey, err = ssh.NewPublicKey(pub); err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+		block, err := ssh.MarshalPrivateKey(priv, claims.Email)
+		if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+		res.PrivateKey = string(pem.EncodeToMemory(block))
+	}
+
+	ttl := options.SSHCertificateTTL
+	if ttl <= 0 {
+		ttl = sshproxy.DefaultCertificateTTL
+	}
+	keyID := claims.Email
+	if keyID == "" {
+		keyID = claims.Subject
+	}
+	cert, err := sshproxy.NewCertificate(userCAKey, publicKey, keyID, principals, ttl)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	log.Info(r.Context()).
+		Str("user", claims.Subject).
+		Strs("principals", principals).
+		Uint64("serial", cert.Serial).
+		Msg("reproxy: issued ssh certificate")
+
+	res.Certificate = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert)))
+	res.Principals = principals
+	res.ValidBefore = time.Unix(int64(cert.ValidBefore), 0).UTC()
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// getSSHClaims returns the claims of the user from the assertion, which is set by the authorize service
+// after the request has been authorized.
+func getSSHClaims(r *http.Request) (*sshClaims, error) {
+	var claims sshClaims
+	assertionJWT, err := jwt.ParseSigned(r.Header.Get(httputil.HeaderPomeriumJWTAssertion))
+	if err != nil || assertionJWT.UnsafeClaimsWithoutVerification(&claims) != nil || claims.Subject == "" {
+		return nil, httputil.NewError(http.StatusUnauthorized, errors.New("ssh routes require an authenticated user"))
+	}
+	return &claims, nil
+}
+
+func getSSHUserCAKey(options *config.Options) (ssh.Signer, error) {
+	userCAKey, err := options.GetSSHUserCAKey()
+	if err != nil {
+		return nil, httputil.NewError(http.StatusInternalServerError, fmt.Errorf("invalid ssh user ca key: %w", err))
+	} else if userCAKey == nil {
+		return nil, httputil.NewError(http.StatusInternalServerError, errors.New("no ssh user ca key is configured"))
+	}
+	return userCAKey, nil
+}
+
+// getSSHPrincipals returns the usernames the user may log in as. If the policy doesn't set any, users may
+// log in as the local part of their email address.
+func getSSHPrincipals(policy *config.Policy, claims *sshClaims) []string {
+	if len(policy.SSHPrincipals) > 0 {
+		return policy.SSHPrincipals
+	}
+	if local, _, ok := strings.Cut(claims.Email, "@"); ok && local != "" {
+		return []string{local}
+	}
+	return nil
+}
+
+// streamConn adapts a CONNECT stream to a net.Conn.
+type streamConn struct {
+	*connectStream
+	remoteAddr string
+}
+
+func (c *streamConn) LocalAddr() net.Addr                { return streamAddr("") }
+func (c *streamConn) RemoteAddr() net.Addr               { return streamAddr(c.remoteAddr) }
+func (c *streamConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+type streamAddr string
+
+func (a streamAddr) Network() string { return "tcp" }
+func (a streamAddr) String() string  { return string(a) }
@@ -0,0 +1,120 @@
+package reproxy
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestMiddlewareSSHCertificate(t *testing.T) {
+	t.Parallel()
+
+	_, caKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	caBlock, err := ssh.MarshalPrivateKey(caKey, "")
+	require.NoError(t, err)
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	to, err := config.ParseWeightedUrls("ssh://10.0.0.1:22")
+	require.NoError(t, err)
+	cfg := &config.Config{
+		Options: &config.Options{
+			SharedKey:    cryptutil.NewBase64Key(),
+			SSHUserCAKey: string(pem.EncodeToMemory(caBlock)),
+			Policies: []config.Policy{{
+				From: "ssh://bastion.example.com",
+				To:   to,
+			}},
+		},
+	}
+	h := New()
+	h.Update(context.Background(), cfg)
+	policyID, _ := cfg.Options.Policies[0].RouteID()
+
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("SECRET")}, nil)
+	require.NoError(t, err)
+	assertion, err := jwt.Signed(sig).Claims(map[string]any{
+		"sub":   "USER_ID",
+		"email": "user@example.com",
+	}).CompactSerialize()
+	require.NoError(t, err)
+
+	issue := func(t *testing.T, body string) (code int, res struct {
+		Certificate string   `json:"certificate"`
+		PrivateKey  string   `json:"privateKey"`
+		Principals  []string `json:"principals"`
+	},
+	) {
+		r := httptest.NewRequest(http.MethodPost, "https://bastion.example.com:22/", strings.NewReader(body))
+		for _, hdr := range h.GetPolicyIDHeaders(policyID) {
+			r.Header.Set(hdr[0], hdr[1])
+		}
+		r.Header.Set(httputil.HeaderPomeriumJWTAssertion, assertion)
+		w := httptest.NewRecorder()
+		h.Middleware(http.NotFoundHandler()).ServeHTTP(w, r)
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		}
+		return w.Code, res
+	}
+	parseCert := func(t *testing.T, raw string) *ssh.Certificate {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(raw))
+		require.NoError(t, err)
+		cert, ok := key.(*ssh.Certificate)
+		require.True(t, ok)
+		assert.Equal(t, caSigner.PublicKey().Marshal(), cert.SignatureKey.Marshal())
+		assert.Equal(t, []string{"user"}, cert.ValidPrincipals)
+		assert.Equal(t, "user@example.com", cert.KeyId)
+		return cert
+	}
+
+	t.Run("public key", func(t *testing.T) {
+		t.Parallel()
+
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		sshPub, err := ssh.NewPublicKey(pub)
+		require.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]string{"publicKey": string(ssh.MarshalAuthorizedKey(sshPub))})
+		code, res := issue(t, string(body))
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, res.PrivateKey)
+		assert.Equal(t, []string{"user"}, res.Principals)
+		cert := parseCert(t, res.Certificate)
+		assert.Equal(t, sshPub.Marshal(), cert.Key.Marshal())
+	})
+	t.Run("ephemeral key", func(t *testing.T) {
+		t.Parallel()
+
+		code, res := issue(t, "")
+		require.Equal(t, http.StatusOK, code)
+		cert := parseCert(t, res.Certificate)
+		signer, err := ssh.ParsePrivateKey([]byte(res.PrivateKey))
+		require.NoError(t, err)
+		assert.Equal(t, signer.PublicKey().Marshal(), cert.Key.Marshal())
+	})
+	t.Run("invalid public key", func(t *testing.T) {
+		t.Parallel()
+
+		code, _ := issue(t, `{"publicKey":"INVALID"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
@@ -0,0 +1,135 @@
+package reproxy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/udptunnel"
+)
+
+// serveUDP relays datagrams between a CONNECT stream and the policy's UDP destination.
+func serveUDP(w http.ResponseWriter, r *http.Request, policy *config.Policy) error {
+	if r.Method != http.MethodConnect {
+		return httputil.NewError(http.StatusMethodNotAllowed, errors.New("udp routes require CONNECT"))
+	}
+	if len(policy.To) == 0 {
+		return httputil.NewError(http.StatusNotFound, errors.New("policy destination not found"))
+	}
+	// regular rand is fine for this
+	dst := policy.To[rand.Intn(len(policy.To))] //nolint:gosec
+
+	upstream, err := net.Dial("udp", dst.URL.Host)
+	if err != nil {
+		return httputil.NewError(http.StatusBadGateway, fmt.Errorf("error connecting to udp upstream: %w", err))
+	}
+	defer upstream.Close()
+
+	stream, err := acceptConnect(w, r)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	relayUDP(stream, upstream)
+	log.Debug(r.Context()).Str("upstream", dst.URL.Host).Msg("reproxy: udp tunnel closed")
+	return nil
+}
+
+type connectStream struct {
+	*bufio.Reader
+	io.Writer
+	io.Closer
+}
+
+// acceptConnect accepts a CONNECT request and returns the tunneled stream.
+func acceptConnect(w http.ResponseWriter, r *http.Request) (*connectStream, error) {
+	rc := http.NewResponseController(w)
+
+	// HTTP/1 connections must be hijacked
+	conn, brw, err := rc.Hijack()
+	if err == nil {
+		_, err = io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return &connectStream{Reader: brw.Reader, Writer: conn, Closer: conn}, nil
+	} else if !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+
+	// HTTP/2 streams are full duplex
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return nil, err
+	}
+	return &connectStream{
+		Reader: bufio.NewReader(r.Body),
+		Writer: flushWriter{w: w, rc: rc},
+		Closer: r.Body,
+	}, nil
+}
+
+type flushWriter struct {
+	w  io.Writer
+	rc *http.ResponseController
+}
+
+func (fw flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, fw.rc.Flush()
+}
+
+// relayUDP copies datagrams in both directions until either side is closed.
+func relayUDP(stream *connectStream, upstream net.Conn) {
+	var once sync.Once
+	done := make(chan struct{})
+	closeAll := func() {
+		once.Do(func() {
+			close(done)
+			_ = stream.Close()
+			_ = upstream.Close()
+		})
+	}
+
+	go func() {
+		defer closeAll()
+		for {
+			payload, err := udptunnel.ReadDatagram(stream.Reader)
+			if err != nil {
+				return
+			}
+			if _, err := upstream.Write(payload); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer closeAll()
+		buf := make([]byte, udptunnel.MaxDatagramSize)
+		for {
+			n, err := upstream.Read(buf)
+			if err != nil {
+				return
+			}
+			if err := udptunnel.WriteDatagram(stream.Writer, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	<-done
+}
@@ -0,0 +1,80 @@
+package reproxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/udptunnel"
+)
+
+func TestMiddlewareUDP(t *testing.T) {
+	// udp echo server
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, udptunnel.MaxDatagramSize)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	h := New()
+	srv := httptest.NewServer(h.Middleware(http.NotFoundHandler()))
+	defer srv.Close()
+
+	to, err := config.ParseWeightedUrls("udp://" + echo.LocalAddr().String())
+	require.NoError(t, err)
+	cfg := &config.Config{
+		Options: &config.Options{
+			SharedKey: cryptutil.NewBase64Key(),
+			Policies: []config.Policy{{
+				From: "udp+https://dns.example.com:53",
+				To:   to,
+			}},
+		},
+	}
+	h.Update(context.Background(), cfg)
+	policyID, _ := cfg.Options.Policies[0].RouteID()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(10*time.Second)))
+
+	_, err = fmt.Fprintf(conn, "CONNECT dns.example.com:53 HTTP/1.1\r\nHost: dns.example.com:53\r\n")
+	require.NoError(t, err)
+	for _, hdr := range h.GetPolicyIDHeaders(policyID) {
+		_, err = fmt.Fprintf(conn, "%s: %s\r\n", hdr[0], hdr[1])
+		require.NoError(t, err)
+	}
+	_, err = fmt.Fprintf(conn, "\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	for _, msg := range []string{"hello", "world"} {
+		require.NoError(t, udptunnel.WriteDatagram(conn, []byte(msg)))
+		payload, err := udptunnel.ReadDatagram(br)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(payload))
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"time"
 
 	"github.com/pomerium/pomerium/internal/events"
+	"github.com/pomerium/pomerium/internal/identity"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 )
 
 var (
 	defaultSessionRefreshGracePeriod     = 1 * time.Minute
 	defaultSessionRefreshCoolOffDuration = 10 * time.Second
+	defaultClaimsRefreshInterval         = 10 * time.Minute
 )
 
 type config struct {
@@ -17,14 +19,17 @@ type config struct {
 	dataBrokerClient              databroker.DataBrokerServiceClient
 	sessionRefreshGracePeriod     time.Duration
 	sessionRefreshCoolOffDuration time.Duration
+	claimsRefreshInterval         time.Duration
 	now                           func() time.Time
 	eventMgr                      *events.Manager
+	claimsFilter                  ClaimsFilter
 }
 
 func newConfig(options ...Option) *config {
 	cfg := new(config)
 	WithSessionRefreshGracePeriod(defaultSessionRefreshGracePeriod)(cfg)
 	WithSessionRefreshCoolOffDuration(defaultSessionRefreshCoolOffDuration)(cfg)
+	WithClaimsRefreshInterval(defaultClaimsRefreshInterval)(cfg)
 	WithNow(time.Now)(cfg)
 	for _, option := range options {
 		option(cfg)
@@ -63,6 +68,14 @@ func WithSessionRefreshCoolOffDuration(dur time.Duration) Option {
 	}
 }
 
+// WithClaimsRefreshInterval sets how often the manager re-fetches user and session claims from
+// the identity provider.
+func WithClaimsRefreshInterval(dur time.Duration) Option {
+	return func(cfg *config) {
+		cfg.claimsRefreshInterval = dur
+	}
+}
+
 // WithNow customizes the time.Now function used by the manager.
 func WithNow(now func() time.Time) Option {
 	return func(cfg *config) {
@@ -76,3 +89,14 @@ func WithEventManager(mgr *events.Manager) Option {
 		c.eventMgr = mgr
 	}
 }
+
+// A ClaimsFilter drops or replaces claims before they're stored. If the groups claim is replaced,
+// the full groups are returned.
+type ClaimsFilter func(claims identity.FlattenedClaims) (groups []string)
+
+// WithClaimsFilter sets the filter applied to claims refreshed from the identity provider.
+func WithClaimsFilter(filter ClaimsFilter) Option {
+	return func(cfg *config) {
+		cfg.claimsFilter = filter
+	}
+}
@@ -12,17 +12,17 @@ import (
 	"github.com/pomerium/pomerium/pkg/grpc/user"
 )
 
-const userRefreshInterval = 10 * time.Minute
-
 // A User is a user managed by the Manager.
 type User struct {
 	*user.User
 	lastRefresh time.Time
+	// refreshInterval is the amount of time to wait before re-fetching the user's claims.
+	refreshInterval time.Duration
 }
 
 // NextRefresh returns the next time the user information needs to be refreshed.
 func (u User) NextRefresh() time.Time {
-	return u.lastRefresh.Add(userRefreshInterval)
+	return u.lastRefresh.Add(u.refreshInterval)
 }
 
 // UnmarshalJSON unmarshals json data into the user object.
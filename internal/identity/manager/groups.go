@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// GroupsRecordType is the databroker record type of the full groups of users whose groups claim
+// was replaced by a hash.
+const GroupsRecordType = "pomerium.io/UserGroups"
+
+// Groups are the full groups of a user.
+type Groups struct {
+	UserID string   `json:"user_id"`
+	Hash   string   `json:"hash"`
+	Groups []string `json:"groups"`
+}
+
+// GetGroups gets the full groups of a user from the databroker.
+func GetGroups(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) (*Groups, error) {
+	return databroker.GetViaJSON[Groups](ctx, client, GroupsRecordType, userID)
+}
+
+// PutGroups stores the full groups of a user in the databroker.
+func PutGroups(ctx context.Context, client databroker.DataBrokerServiceClient, userID string, groups []string) error {
+	_, err := databroker.PutViaJSON(ctx, client, GroupsRecordType, userID, Groups{
+		UserID: userID,
+		Hash:   identity.HashGroups(groups),
+		Groups: groups,
+	})
+	if err != nil {
+		return fmt.Errorf("identity/manager: error storing groups: %w", err)
+	}
+	return nil
+}
+
+// FilterClaims applies the claims filter to the claims. If the groups claim was replaced, the full
+// groups are stored in the databroker.
+func FilterClaims(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	filter ClaimsFilter,
+	userID string,
+	claims map[string]*structpb.ListValue,
+) (map[string]*structpb.ListValue, error) {
+	if filter == nil {
+		return claims, nil
+	}
+
+	flattened := identity.NewFlattenedClaimsFromPB(claims)
+	if groups := filter(flattened); groups != nil {
+		if err := PutGroups(ctx, client, userID, groups); err != nil {
+			return nil, err
+		}
+	}
+	return flattened.ToPB(), nil
+}
+
+// A GroupsGetter is an Authenticator which can look up all the groups of a user, for identity
+// providers which leave groups out of tokens when a user is in too many groups.
+type GroupsGetter interface {
+	GetGroups(ctx context.Context, t *oauth2.Token) ([]string, error)
+}
+
+// hasGroupsOverage reports whether the claims indicate that the groups were left out of the token
+// because there were too many, like azure's _claim_names and hasgroups claims.
+func hasGroupsOverage(claims map[string]*structpb.ListValue) bool {
+	_, hasClaimNames := claims["_claim_names.groups"]
+	_, hasGroups := claims["hasgroups"]
+	return hasClaimNames || hasGroups
+}
+
+// resolveGroups replaces the groups overage claims with all the groups of the user. The overage
+// claims are removed even if looking up the groups fails, so that the lookup is only retried when
+// the identity provider adds them again.
+func resolveGroups(
+	ctx context.Context,
+	getter GroupsGetter,
+	t *oauth2.Token,
+	claims map[string]*structpb.ListValue,
+) ([]string, error) {
+	removeGroupsOverage(claims)
+
+	groups, err := getter.GetGroups(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	setGroupsClaim(claims, groups)
+	return groups, nil
+}
+
+func removeGroupsOverage(claims map[string]*structpb.ListValue) {
+	for k := range claims {
+		if k == "_claim_names.groups" || k == "hasgroups" || strings.HasPrefix(k, "_claim_sources.") {
+			delete(claims, k)
+		}
+	}
+}
+
+func setGroupsClaim(claims map[string]*structpb.ListValue, groups []string) {
+	values := make([]*structpb.Value, len(groups))
+	for i, g := range groups {
+		values[i] = structpb.NewStringValue(g)
+	}
+	claims["groups"] = &structpb.ListValue{Values: values}
+}
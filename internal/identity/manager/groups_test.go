@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type mockGroupsGetter struct {
+	groups []string
+	err    error
+}
+
+func (m mockGroupsGetter) GetGroups(_ context.Context, _ *oauth2.Token) ([]string, error) {
+	return m.groups, m.err
+}
+
+func TestResolveGroups(t *testing.T) {
+	t.Parallel()
+
+	overage := func() map[string]*structpb.ListValue {
+		return map[string]*structpb.ListValue{
+			"_claim_names.groups":          {Values: []*structpb.Value{structpb.NewStringValue("src1")}},
+			"_claim_sources.src1.endpoint": {Values: []*structpb.Value{structpb.NewStringValue("https://graph.windows.net/")}},
+			"email":                        {Values: []*structpb.Value{structpb.NewStringValue("user@example.com")}},
+		}
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		claims := overage()
+		assert.True(t, hasGroupsOverage(claims))
+		groups, err := resolveGroups(context.Background(), mockGroupsGetter{groups: []string{"g1", "g2"}}, &oauth2.Token{}, claims)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"g1", "g2"}, groups)
+		assert.False(t, hasGroupsOverage(claims))
+		assert.Len(t, claims, 2)
+		assert.Equal(t, []interface{}{"g1", "g2"}, claims["groups"].AsSlice())
+	})
+	t.Run("error", func(t *testing.T) {
+		t.Parallel()
+
+		claims := overage()
+		_, err := resolveGroups(context.Background(), mockGroupsGetter{err: errors.New("error")}, &oauth2.Token{}, claims)
+		assert.Error(t, err)
+		assert.False(t, hasGroupsOverage(claims), "should not retry until the overage claims are added again")
+		assert.NotContains(t, claims, "groups")
+	})
+}
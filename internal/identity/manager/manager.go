@@ -3,6 +3,7 @@ package manager
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/pomerium/pomerium/internal/atomicutil"
@@ -262,7 +264,26 @@ func (mgr *Manager) refreshSession(ctx context.Context, userID, sessionID string
 		return
 	}
 
-	res, err := session.Put(ctx, mgr.cfg.Load().dataBrokerClient, s.Session)
+	if getter, ok := authenticator.(GroupsGetter); ok && hasGroupsOverage(s.Claims) {
+		if _, err := resolveGroups(ctx, getter, FromOAuthToken(s.OauthToken), s.Claims); err != nil {
+			log.Error(ctx).Err(err).
+				Str("user_id", s.GetUserId()).
+				Str("session_id", s.GetId()).
+				Msg("failed to resolve groups")
+		}
+	}
+
+	cfg := mgr.cfg.Load()
+	s.Claims, err = FilterClaims(ctx, cfg.dataBrokerClient, cfg.claimsFilter, s.GetUserId(), s.Claims)
+	if err != nil {
+		log.Error(ctx).Err(err).
+			Str("user_id", s.GetUserId()).
+			Str("session_id", s.GetId()).
+			Msg("failed to filter session claims")
+		return
+	}
+
+	res, err := session.Put(ctx, cfg.dataBrokerClient, s.Session)
 	if err != nil {
 		log.Error(ctx).Err(err).
 			Str("user_id", s.GetUserId()).
@@ -274,6 +295,9 @@ func (mgr *Manager) refreshSession(ctx context.Context, userID, sessionID string
 	mgr.onUpdateSession(ctx, res.GetRecord(), s.Session)
 }
 
+// refreshUser re-fetches the user info from the identity provider, updating the claims of the
+// user and all of their sessions, so that changes like group membership are picked up without
+// waiting for the oauth tokens to be refreshed.
 func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 	log.Info(ctx).
 		Str("user_id", userID).
@@ -292,6 +316,7 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 		return
 	}
 	u.lastRefresh = time.Now()
+	u.refreshInterval = mgr.cfg.Load().claimsRefreshInterval
 	mgr.userScheduler.Add(u.NextRefresh(), u.GetId())
 
 	for _, s := range mgr.sessions.GetSessionsForUser(userID) {
@@ -302,7 +327,9 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 			continue
 		}
 
-		err := authenticator.UpdateUserInfo(ctx, FromOAuthToken(s.OauthToken), &u)
+		// the user info is fetched once and then applied to both the user and the session
+		userInfo := map[string]json.RawMessage{}
+		err := authenticator.UpdateUserInfo(ctx, FromOAuthToken(s.OauthToken), &userInfo)
 		metrics.RecordIdentityManagerUserRefresh(ctx, err)
 		mgr.recordLastError(metrics_ids.IdentityManagerLastUserRefreshError, err)
 		if isTemporaryError(err) {
@@ -320,6 +347,51 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 			continue
 		}
 
+		rawUserInfo, err := json.Marshal(userInfo)
+		if err == nil {
+			err = json.Unmarshal(rawUserInfo, &u)
+		}
+		if err == nil {
+			err = json.Unmarshal(rawUserInfo, &s)
+		}
+		if err != nil {
+			log.Error(ctx).Err(err).
+				Str("user_id", s.GetUserId()).
+				Str("session_id", s.GetId()).
+				Msg("failed to update user info")
+			continue
+		}
+
+		if getter, ok := authenticator.(GroupsGetter); ok && hasGroupsOverage(s.Claims) {
+			groups, err := resolveGroups(ctx, getter, FromOAuthToken(s.OauthToken), s.Claims)
+			if err != nil {
+				log.Error(ctx).Err(err).
+					Str("user_id", s.GetUserId()).
+					Str("session_id", s.GetId()).
+					Msg("failed to resolve groups")
+			}
+			removeGroupsOverage(u.Claims)
+			if groups != nil {
+				if u.Claims == nil {
+					u.Claims = make(map[string]*structpb.ListValue)
+				}
+				setGroupsClaim(u.Claims, groups)
+			}
+		}
+
+		cfg := mgr.cfg.Load()
+		u.Claims, err = FilterClaims(ctx, cfg.dataBrokerClient, cfg.claimsFilter, u.GetId(), u.Claims)
+		if err == nil {
+			s.Claims, err = FilterClaims(ctx, cfg.dataBrokerClient, cfg.claimsFilter, s.GetUserId(), s.Claims)
+		}
+		if err != nil {
+			log.Error(ctx).Err(err).
+				Str("user_id", s.GetUserId()).
+				Str("session_id", s.GetId()).
+				Msg("failed to filter claims")
+			continue
+		}
+
 		res, err := databroker.Put(ctx, mgr.cfg.Load().dataBrokerClient, u.User)
 		if err != nil {
 			log.Error(ctx).Err(err).
@@ -330,6 +402,17 @@ func (mgr *Manager) refreshUser(ctx context.Context, userID string) {
 		}
 
 		mgr.onUpdateUser(ctx, res.GetRecords()[0], u.User)
+
+		sessionRes, err := session.Put(ctx, mgr.cfg.Load().dataBrokerClient, s.Session)
+		if err != nil {
+			log.Error(ctx).Err(err).
+				Str("user_id", s.GetUserId()).
+				Str("session_id", s.GetId()).
+				Msg("failed to update session claims")
+			continue
+		}
+
+		mgr.onUpdateSession(ctx, sessionRes.GetRecord(), s.Session)
 	}
 }
 
@@ -371,7 +454,12 @@ func (mgr *Manager) onUpdateSession(_ context.Context, record *databroker.Record
 	s.coolOffDuration = mgr.cfg.Load().sessionRefreshCoolOffDuration
 	s.Session = session
 	mgr.sessions.ReplaceOrInsert(s)
-	mgr.sessionScheduler.Add(s.NextRefresh(), toSessionSchedulerKey(session.GetUserId(), session.GetId()))
+	next := s.NextRefresh()
+	// groups left out of the token are resolved right away, rather than on the next refresh
+	if _, ok := mgr.cfg.Load().authenticator.(GroupsGetter); ok && hasGroupsOverage(session.GetClaims()) {
+		next = time.Now()
+	}
+	mgr.sessionScheduler.Add(next, toSessionSchedulerKey(session.GetUserId(), session.GetId()))
 }
 
 func (mgr *Manager) onUpdateUser(_ context.Context, record *databroker.Record, user *user.User) {
@@ -384,6 +472,7 @@ func (mgr *Manager) onUpdateUser(_ context.Context, record *databroker.Record, u
 
 	u, _ := mgr.users.Get(user.GetId())
 	u.lastRefresh = mgr.cfg.Load().now()
+	u.refreshInterval = mgr.cfg.Load().claimsRefreshInterval
 	u.User = user
 	mgr.users.ReplaceOrInsert(u)
 	mgr.userScheduler.Add(u.NextRefresh(), u.GetId())
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/oauth2"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
@@ -89,7 +91,7 @@ func TestManager_onUpdateRecords(t *testing.T) {
 	}
 	if _, ok := mgr.users.Get("user1"); assert.True(t, ok) {
 		tm, id := mgr.userScheduler.Next()
-		assert.Equal(t, now.Add(userRefreshInterval), tm)
+		assert.Equal(t, now.Add(defaultClaimsRefreshInterval), tm)
 		assert.Equal(t, "user1", id)
 	}
 }
@@ -159,6 +161,71 @@ func TestManager_reportErrors(t *testing.T) {
 	expectMsg(metrics_ids.IdentityManagerLastSessionRefreshError, "update session")
 }
 
+type mockUserInfoAuthenticator struct {
+	mockAuthenticator
+	userInfo string
+}
+
+func (mock mockUserInfoAuthenticator) UpdateUserInfo(_ context.Context, _ *oauth2.Token, v any) error {
+	return json.Unmarshal([]byte(mock.userInfo), v)
+}
+
+func TestManager_refreshUserClaims(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	defer clearTimeout()
+
+	now := time.Now()
+
+	var puts []*databroker.Record
+	client := mock_databroker.NewMockDataBrokerServiceClient(ctrl)
+	client.EXPECT().Put(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(
+		func(_ context.Context, in *databroker.PutRequest, _ ...grpc.CallOption) (*databroker.PutResponse, error) {
+			puts = append(puts, in.GetRecords()...)
+			return &databroker.PutResponse{Records: in.GetRecords()}, nil
+		})
+	mgr := New(
+		WithDataBrokerClient(client),
+		WithAuthenticator(mockUserInfoAuthenticator{
+			userInfo: `{"sub":"user1","email":"user1@example.com","groups":["group1"]}`,
+		}),
+		WithClaimsRefreshInterval(time.Minute),
+		WithNow(func() time.Time {
+			return now
+		}),
+	)
+
+	mgr.onUpdateRecords(ctx, updateRecordsMessage{
+		records: []*databroker.Record{
+			mkRecord(&session.Session{
+				Id:         "session1",
+				UserId:     "user1",
+				OauthToken: &session.OAuthToken{},
+				ExpiresAt:  timestamppb.New(now.Add(time.Hour)),
+			}),
+			mkRecord(&user.User{Id: "user1"}),
+		},
+	})
+
+	tm, _ := mgr.userScheduler.Next()
+	assert.Equal(t, now.Add(time.Minute), tm)
+
+	mgr.refreshUser(ctx, "user1")
+
+	if assert.Len(t, puts, 2) {
+		var u user.User
+		assert.NoError(t, puts[0].GetData().UnmarshalTo(&u))
+		assert.Equal(t, "user1@example.com", u.GetEmail())
+		assert.Equal(t, []any{"group1"}, u.GetClaims()["groups"].AsSlice())
+
+		var s session.Session
+		assert.NoError(t, puts[1].GetData().UnmarshalTo(&s))
+		assert.Equal(t, "session1", s.GetId())
+		assert.Equal(t, []any{"group1"}, s.GetClaims()["groups"].AsSlice())
+	}
+}
+
 func mkRecord(msg recordable) *databroker.Record {
 	data := protoutil.NewAny(msg)
 	return &databroker.Record{
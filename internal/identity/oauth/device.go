@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"fmt"
+)
+
+// DeviceAuthResponse is the response to a device authorization request.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.2
+type DeviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	// ExpiresIn is the lifetime of the device code in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+	// Interval is the minimum number of seconds to wait between polling requests.
+	Interval int64 `json:"interval,omitempty"`
+}
+
+// Device access token error codes.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.5
+const (
+	DeviceAuthErrorAuthorizationPending = "authorization_pending"
+	DeviceAuthErrorSlowDown             = "slow_down"
+	DeviceAuthErrorAccessDenied         = "access_denied"
+	DeviceAuthErrorExpiredToken         = "expired_token"
+)
+
+// DeviceAuthError is an error returned by the token endpoint when polling for a device access token.
+type DeviceAuthError struct {
+	Code        string
+	Description string
+}
+
+// Error implements the error interface.
+func (err *DeviceAuthError) Error() string {
+	if err.Description != "" {
+		return fmt.Sprintf("device authorization: %s: %s", err.Code, err.Description)
+	}
+	return fmt.Sprintf("device authorization: %s", err.Code)
+}
@@ -3,7 +3,10 @@
 // authorization with Bearer JWT.
 package oauth
 
-import "net/url"
+import (
+	"crypto/tls"
+	"net/url"
+)
 
 // Options contains the fields required for an OAuth 2.0 (inc. OIDC) auth flow.
 //
@@ -29,4 +32,17 @@ type Options struct {
 	// AuthCodeOptions specifies additional key value pairs query params to add
 	// to the request flow signin url.
 	AuthCodeOptions map[string]string
+
+	// SAML contains the options used by the SAML provider.
+	SAML SAMLOptions
+}
+
+// SAMLOptions contains the additional fields required for a SAML 2.0 auth flow.
+type SAMLOptions struct {
+	// IdPMetadata is the identity provider metadata. If empty, it's fetched from the ProviderURL.
+	IdPMetadata []byte
+	// Certificate is used to sign authentication requests. Requests are unsigned if nil.
+	Certificate *tls.Certificate
+	// AttributeMapping maps claims to assertion attribute names.
+	AttributeMapping map[string]string
 }
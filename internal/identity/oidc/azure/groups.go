@@ -0,0 +1,62 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/oauth2"
+)
+
+// graphGroupsURL lists the groups the signed in user is a member of, directly or through nested
+// groups. It's a variable for tests.
+var graphGroupsURL = "https://graph.microsoft.com/v1.0/me/transitiveMemberOf/microsoft.graph.group?$select=id&$top=999"
+
+// GetGroups returns the ids of all the groups the user is a member of, using the Microsoft Graph
+// API. Azure AD leaves groups out of tokens for users in more than 200 groups, and adds an overage
+// claim instead.
+//
+// The access token needs the GroupMember.Read.All permission.
+func (p *Provider) GetGroups(ctx context.Context, t *oauth2.Token) ([]string, error) {
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c
+	}
+
+	var groups []string
+	for nextURL := graphGroupsURL; nextURL != ""; {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, nextURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: error creating groups request: %w", Name, err)
+		}
+		t.SetAuthHeader(req)
+
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: error getting groups: %w", Name, err)
+		}
+
+		var page struct {
+			Value []struct {
+				ID string `json:"id"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink"`
+		}
+		if res.StatusCode/100 != 2 {
+			res.Body.Close()
+			return nil, fmt.Errorf("%s: error getting groups: unexpected status code %d", Name, res.StatusCode)
+		}
+		err = json.NewDecoder(res.Body).Decode(&page)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: error decoding groups: %w", Name, err)
+		}
+
+		for _, v := range page.Value {
+			groups = append(groups, v.ID)
+		}
+		nextURL = page.NextLink
+	}
+	return groups, nil
+}
@@ -0,0 +1,43 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestGetGroups(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ACCESS_TOKEN" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			fmt.Fprintf(w, `{"value":[{"id":"g1"},{"id":"g2"}],"@odata.nextLink":"%s/groups?page=2"}`, srv.URL)
+		case "2":
+			fmt.Fprint(w, `{"value":[{"id":"g3"}]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	original := graphGroupsURL
+	graphGroupsURL = srv.URL + "/groups"
+	t.Cleanup(func() { graphGroupsURL = original })
+
+	var p Provider
+	groups, err := p.GetGroups(context.Background(), &oauth2.Token{AccessToken: "ACCESS_TOKEN"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"g1", "g2", "g3"}, groups)
+
+	_, err = p.GetGroups(context.Background(), &oauth2.Token{AccessToken: "WRONG"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/pomerium/pomerium/internal/identity/identity"
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceAuth starts a device authorization grant, returning the user code the user enters on
+// another device to authorize the device code.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.1
+func (p *Provider) DeviceAuth(ctx context.Context) (*oauth.DeviceAuthResponse, error) {
+	oa, err := p.GetOauthConfig()
+	if err != nil {
+		return nil, err
+	}
+	if p.DeviceAuthURL == "" {
+		return nil, ErrDeviceAuthNotImplemented
+	}
+
+	params := url.Values{}
+	params.Set("scope", strings.Join(oa.Scopes, " "))
+	for k, v := range p.AuthCodeOptions {
+		params.Set(k, v)
+	}
+
+	var res struct {
+		oauth.DeviceAuthResponse
+		// some providers, like Google, use verification_url instead of verification_uri
+		VerificationURL string `json:"verification_url"`
+	}
+	if err := postForm(ctx, oa, p.DeviceAuthURL, params, &res); err != nil {
+		return nil, fmt.Errorf("identity/oidc: device authorization request failed: %w", err)
+	}
+	if res.VerificationURI == "" {
+		res.VerificationURI = res.VerificationURL
+	}
+	if res.DeviceCode == "" || res.UserCode == "" || res.VerificationURI == "" {
+		return nil, fmt.Errorf("identity/oidc: invalid device authorization response")
+	}
+	return &res.DeviceAuthResponse, nil
+}
+
+// DeviceAccessToken polls the token endpoint for the device code once. If the user has not
+// authorized the device yet, an *oauth.DeviceAuthError with the authorization_pending code is
+// returned.
+//
+// https://datatracker.ietf.org/doc/html/rfc8628#section-3.4
+func (p *Provider) DeviceAccessToken(ctx context.Context, deviceCode string, v identity.State) (*oauth2.Token, error) {
+	oa, err := p.GetOauthConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", deviceCodeGrantType)
+	params.Set("device_code", deviceCode)
+
+	var raw map[string]interface{}
+	if err := postForm(ctx, oa, oa.Endpoint.TokenURL, params, &raw); err != nil {
+		return nil, err
+	}
+
+	oauth2Token, err := tokenFromResponse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.updateState(ctx, oauth2Token, v); err != nil {
+		return nil, err
+	}
+
+	return oauth2Token, nil
+}
+
+func tokenFromResponse(raw map[string]interface{}) (*oauth2.Token, error) {
+	accessToken, _ := raw["access_token"].(string)
+	if accessToken == "" {
+		return nil, ErrMissingAccessToken
+	}
+
+	t := &oauth2.Token{
+		AccessToken: accessToken,
+	}
+	t.TokenType, _ = raw["token_type"].(string)
+	t.RefreshToken, _ = raw["refresh_token"].(string)
+	if expiresIn, ok := raw["expires_in"].(float64); ok && expiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return t.WithExtra(raw), nil
+}
+
+// postForm posts the params, with client authentication, to the endpoint and decodes the json
+// response into obj. OAuth 2.0 error responses are returned as an *oauth.DeviceAuthError.
+func postForm(ctx context.Context, oa *oauth2.Config, endpoint string, params url.Values, obj interface{}) error {
+	params.Set("client_id", oa.ClientID)
+	if oa.ClientSecret != "" {
+		params.Set("client_secret", oa.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode/100 != 2 {
+		var errorResponse struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != "" {
+			return &oauth.DeviceAuthError{
+				Code:        errorResponse.Error,
+				Description: errorResponse.ErrorDescription,
+			}
+		}
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	return json.Unmarshal(body, obj)
+}
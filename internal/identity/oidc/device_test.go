@@ -0,0 +1,135 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+)
+
+func TestDeviceAuth(t *testing.T) {
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(clearTimeout)
+
+	var srv *httptest.Server
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]any{
+				"issuer":                        baseURL.String(),
+				"token_endpoint":                baseURL.ResolveReference(&url.URL{Path: "/token"}).String(),
+				"device_authorization_endpoint": baseURL.ResolveReference(&url.URL{Path: "/device"}).String(),
+			})
+		case "/device":
+			assert.Equal(t, "CLIENT_ID", r.FormValue("client_id"))
+			assert.Equal(t, "CLIENT_SECRET", r.FormValue("client_secret"))
+			assert.Equal(t, "openid email", r.FormValue("scope"))
+			json.NewEncoder(w).Encode(map[string]any{
+				"device_code":      "DEVICE_CODE",
+				"user_code":        "USER-CODE",
+				"verification_url": "https://idp.example.com/device",
+				"expires_in":       600,
+				"interval":         5,
+			})
+		case "/token":
+			assert.Equal(t, deviceCodeGrantType, r.FormValue("grant_type"))
+			assert.Equal(t, "DEVICE_CODE", r.FormValue("device_code"))
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": "authorization_pending",
+			})
+		default:
+			assert.Failf(t, "unexpected http request", "url: %s", r.URL.String())
+		}
+	})
+	srv = httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	redirectURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	p, err := New(ctx, &oauth.Options{
+		ProviderURL:  srv.URL,
+		RedirectURL:  redirectURL,
+		ClientID:     "CLIENT_ID",
+		ClientSecret: "CLIENT_SECRET",
+		Scopes:       []string{"openid", "email"},
+	})
+	require.NoError(t, err)
+
+	res, err := p.DeviceAuth(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &oauth.DeviceAuthResponse{
+		DeviceCode:      "DEVICE_CODE",
+		UserCode:        "USER-CODE",
+		VerificationURI: "https://idp.example.com/device",
+		ExpiresIn:       600,
+		Interval:        5,
+	}, res)
+
+	_, err = p.DeviceAccessToken(ctx, "DEVICE_CODE", nil)
+	var deviceAuthErr *oauth.DeviceAuthError
+	require.True(t, errors.As(err, &deviceAuthErr), "error: %v", err)
+	assert.Equal(t, oauth.DeviceAuthErrorAuthorizationPending, deviceAuthErr.Code)
+}
+
+func TestDeviceAuthNotImplemented(t *testing.T) {
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(clearTimeout)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer": srv.URL,
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	redirectURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	p, err := New(ctx, &oauth.Options{
+		ProviderURL: srv.URL,
+		RedirectURL: redirectURL,
+		ClientID:    "CLIENT_ID",
+	})
+	require.NoError(t, err)
+
+	_, err = p.DeviceAuth(ctx)
+	assert.ErrorIs(t, err, ErrDeviceAuthNotImplemented)
+}
+
+func TestTokenFromResponse(t *testing.T) {
+	t.Parallel()
+
+	_, err := tokenFromResponse(map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrMissingAccessToken)
+
+	token, err := tokenFromResponse(map[string]interface{}{
+		"access_token":  "ACCESS_TOKEN",
+		"refresh_token": "REFRESH_TOKEN",
+		"token_type":    "Bearer",
+		"expires_in":    float64(3600),
+		"id_token":      "ID_TOKEN",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ACCESS_TOKEN", token.AccessToken)
+	assert.Equal(t, "REFRESH_TOKEN", token.RefreshToken)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+	assert.Equal(t, "ID_TOKEN", token.Extra("id_token"))
+}
@@ -26,3 +26,8 @@ var ErrMissingRefreshToken = errors.New("identity/oidc: missing refresh token")
 
 // ErrMissingAccessToken is returned when no access token was found.
 var ErrMissingAccessToken = errors.New("identity/oidc: missing access token")
+
+// ErrDeviceAuthNotImplemented is returned when the device authorization grant is not supported
+// by an identity provider.
+// https://datatracker.ietf.org/doc/html/rfc8628
+var ErrDeviceAuthNotImplemented = errors.New("identity/oidc: device authorization not implemented")
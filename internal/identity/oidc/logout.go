@@ -0,0 +1,104 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// backChannelLogoutEvent is the event included in the events claim of logout tokens.
+const backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// usedLogoutTokens records the ids of verified logout tokens so they can't be replayed. Providers
+// are created for every request, so the ids are shared by all of them.
+var usedLogoutTokens = newReplayCache()
+
+// A LogoutToken identifies the user or session to log out.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+type LogoutToken struct {
+	Issuer string
+	// Subject is the subject of the logged out user. It may be empty if SessionID is set.
+	Subject string
+	// SessionID is the sid of the logged out identity provider session. It may be empty if Subject
+	// is set.
+	SessionID string
+}
+
+// VerifyLogoutToken verifies a back-channel logout token sent by the identity provider.
+//
+// https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation
+func (p *Provider) VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*LogoutToken, error) {
+	v, err := p.GetVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	// the verifier checks the signature, issuer, audience and expiry
+	token, err := v.Verify(ctx, rawLogoutToken)
+	if err != nil {
+		return nil, fmt.Errorf("identity/oidc: invalid logout token: %w", err)
+	}
+
+	var claims struct {
+		ID        string                     `json:"jti"`
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+		Nonce     *string                    `json:"nonce"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("identity/oidc: invalid logout token claims: %w", err)
+	}
+	if _, ok := claims.Events[backChannelLogoutEvent]; !ok {
+		return nil, errors.New("identity/oidc: logout token is missing the back-channel logout event")
+	}
+	if claims.Nonce != nil {
+		return nil, errors.New("identity/oidc: logout token must not contain a nonce")
+	}
+	if token.Subject == "" && claims.SessionID == "" {
+		return nil, errors.New("identity/oidc: logout token must contain a sub or sid claim")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("identity/oidc: logout token is missing the jti claim")
+	}
+	if !usedLogoutTokens.add(token.Issuer+"|"+claims.ID, token.Expiry, time.Now()) {
+		return nil, errors.New("identity/oidc: logout token has already been used")
+	}
+
+	return &LogoutToken{
+		Issuer:    token.Issuer,
+		Subject:   token.Subject,
+		SessionID: claims.SessionID,
+	}, nil
+}
+
+// A replayCache records ids until they expire.
+type replayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{ids: make(map[string]time.Time)}
+}
+
+// add records the id until the expiry time. It returns false if the id was already recorded.
+func (c *replayCache) add(id string, expiry, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, v := range c.ids {
+		if !v.After(now) {
+			delete(c.ids, k)
+		}
+	}
+
+	if _, ok := c.ids[id]; ok {
+		return false
+	}
+	c.ids[id] = expiry
+	return true
+}
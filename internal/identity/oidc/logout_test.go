@@ -0,0 +1,143 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+)
+
+func TestVerifyLogoutToken(t *testing.T) {
+	ctx, clearTimeout := context.WithTimeout(context.Background(), time.Second*10)
+	t.Cleanup(clearTimeout)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.RS256,
+		Key:       jose.JSONWebKey{Key: key, KeyID: "KEY"},
+	}, nil)
+	require.NoError(t, err)
+
+	var srv *httptest.Server
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]any{
+				"issuer":   srv.URL,
+				"jwks_uri": srv.URL + "/.well-known/jwks.json",
+			})
+		case "/.well-known/jwks.json":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: key.Public(), KeyID: "KEY", Algorithm: string(jose.RS256), Use: "sig"},
+			}})
+		default:
+			assert.Failf(t, "unexpected http request", "url: %s", r.URL.String())
+		}
+	})
+	srv = httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	redirectURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	p, err := New(ctx, &oauth.Options{
+		ProviderURL:  srv.URL,
+		RedirectURL:  redirectURL,
+		ClientID:     "CLIENT_ID",
+		ClientSecret: "CLIENT_SECRET",
+	})
+	require.NoError(t, err)
+
+	var tokenID atomic.Int64
+	newToken := func(claims map[string]any) string {
+		base := map[string]any{
+			"iss":    srv.URL,
+			"aud":    "CLIENT_ID",
+			"iat":    time.Now().Unix(),
+			"exp":    time.Now().Add(time.Minute).Unix(),
+			"jti":    fmt.Sprintf("JTI-%d", tokenID.Add(1)),
+			"events": map[string]any{backChannelLogoutEvent: map[string]any{}},
+		}
+		for k, v := range claims {
+			if v == nil {
+				delete(base, k)
+			} else {
+				base[k] = v
+			}
+		}
+		raw, err := jwt.Signed(signer).Claims(base).CompactSerialize()
+		require.NoError(t, err)
+		return raw
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		logoutToken, err := p.VerifyLogoutToken(ctx, newToken(map[string]any{
+			"sub": "USER",
+			"sid": "SID",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, &LogoutToken{Issuer: srv.URL, Subject: "USER", SessionID: "SID"}, logoutToken)
+	})
+	t.Run("sid only", func(t *testing.T) {
+		logoutToken, err := p.VerifyLogoutToken(ctx, newToken(map[string]any{
+			"sid": "SID",
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, &LogoutToken{Issuer: srv.URL, SessionID: "SID"}, logoutToken)
+	})
+	for _, tc := range []struct {
+		name   string
+		claims map[string]any
+	}{
+		{"missing sub and sid", map[string]any{}},
+		{"missing event", map[string]any{"sub": "USER", "events": map[string]any{}}},
+		{"nonce", map[string]any{"sub": "USER", "nonce": "NONCE"}},
+		{"wrong audience", map[string]any{"sub": "USER", "aud": "OTHER"}},
+		{"expired", map[string]any{"sub": "USER", "exp": time.Now().Add(-time.Minute).Unix()}},
+		{"missing jti", map[string]any{"sub": "USER", "jti": nil}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := p.VerifyLogoutToken(ctx, newToken(tc.claims))
+			assert.Error(t, err)
+		})
+	}
+	t.Run("replay", func(t *testing.T) {
+		rawLogoutToken := newToken(map[string]any{"sub": "USER"})
+		_, err := p.VerifyLogoutToken(ctx, rawLogoutToken)
+		require.NoError(t, err)
+		_, err = p.VerifyLogoutToken(ctx, rawLogoutToken)
+		assert.ErrorContains(t, err, "already been used")
+	})
+	t.Run("invalid signature", func(t *testing.T) {
+		_, err := p.VerifyLogoutToken(ctx, newToken(map[string]any{"sub": "USER"})+"x")
+		assert.Error(t, err)
+	})
+}
+
+func TestReplayCache(t *testing.T) {
+	c := newReplayCache()
+	now := time.Now()
+
+	assert.True(t, c.add("A", now.Add(time.Minute), now))
+	assert.False(t, c.add("A", now.Add(time.Minute), now), "should reject a replay")
+	assert.True(t, c.add("B", now.Add(time.Minute), now))
+	assert.True(t, c.add("A", now.Add(3*time.Minute), now.Add(2*time.Minute)), "should forget expired ids")
+	assert.Len(t, c.ids, 1)
+}
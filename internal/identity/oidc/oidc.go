@@ -44,6 +44,10 @@ type Provider struct {
 	// https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPInitiated
 	EndSessionURL string `json:"end_session_endpoint,omitempty"`
 
+	// DeviceAuthURL is the location of the OAuth 2.0 device authorization endpoint.
+	// https://datatracker.ietf.org/doc/html/rfc8628#section-4
+	DeviceAuthURL string `json:"device_authorization_endpoint,omitempty"`
+
 	// AuthCodeOptions specifies additional key value pairs query params to add
 	// to the request flow signin url.
 	AuthCodeOptions map[string]string
@@ -130,9 +134,18 @@ func (p *Provider) Authenticate(ctx context.Context, code string, v identity.Sta
 		return nil, fmt.Errorf("identity/oidc: token exchange failed: %w", err)
 	}
 
+	if err := p.updateState(ctx, oauth2Token, v); err != nil {
+		return nil, err
+	}
+
+	return oauth2Token, nil
+}
+
+// updateState hydrates the state with the claims of the id token and user info endpoint.
+func (p *Provider) updateState(ctx context.Context, oauth2Token *oauth2.Token, v identity.State) error {
 	idToken, err := p.getIDToken(ctx, oauth2Token)
 	if err != nil {
-		return nil, fmt.Errorf("identity/oidc: failed getting id_token: %w", err)
+		return fmt.Errorf("identity/oidc: failed getting id_token: %w", err)
 	}
 
 	if rawIDToken, ok := oauth2Token.Extra("id_token").(string); ok {
@@ -142,14 +155,14 @@ func (p *Provider) Authenticate(ctx context.Context, code string, v identity.Sta
 	// hydrate `v` using claims inside the returned `id_token`
 	// https://openid.net/specs/openid-connect-core-1_0.html#TokenEndpoint
 	if err := idToken.Claims(v); err != nil {
-		return nil, fmt.Errorf("identity/oidc: couldn't unmarshal extra claims %w", err)
+		return fmt.Errorf("identity/oidc: couldn't unmarshal extra claims %w", err)
 	}
 
 	if err := p.UpdateUserInfo(ctx, oauth2Token, v); err != nil {
-		return nil, fmt.Errorf("identity/oidc: couldn't update user info %w", err)
+		return fmt.Errorf("identity/oidc: couldn't update user info %w", err)
 	}
 
-	return oauth2Token, nil
+	return nil
 }
 
 // UpdateUserInfo calls the OIDC (spec required) UserInfo Endpoint as well as any
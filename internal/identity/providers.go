@@ -22,6 +22,7 @@ import (
 	"github.com/pomerium/pomerium/internal/identity/oidc/okta"
 	"github.com/pomerium/pomerium/internal/identity/oidc/onelogin"
 	"github.com/pomerium/pomerium/internal/identity/oidc/ping"
+	"github.com/pomerium/pomerium/internal/identity/saml"
 )
 
 // Authenticator is an interface representing the ability to authenticate with an identity provider.
@@ -35,6 +36,21 @@ type Authenticator interface {
 	UpdateUserInfo(ctx context.Context, t *oauth2.Token, v interface{}) error
 }
 
+// DeviceAuthenticator is an Authenticator which supports the OAuth 2.0 device authorization grant,
+// for clients which can't open a browser.
+type DeviceAuthenticator interface {
+	Authenticator
+	DeviceAuth(ctx context.Context) (*oauth.DeviceAuthResponse, error)
+	DeviceAccessToken(ctx context.Context, deviceCode string, v identity.State) (*oauth2.Token, error)
+}
+
+// BackChannelLogoutAuthenticator is an Authenticator which supports OpenID Connect back-channel
+// logout, where the identity provider notifies pomerium when a user logs out.
+type BackChannelLogoutAuthenticator interface {
+	Authenticator
+	VerifyLogoutToken(ctx context.Context, rawLogoutToken string) (*oidc.LogoutToken, error)
+}
+
 // NewAuthenticator returns a new identity provider based on its name.
 func NewAuthenticator(o oauth.Options) (a Authenticator, err error) {
 	ctx := context.Background()
@@ -61,6 +77,8 @@ func NewAuthenticator(o oauth.Options) (a Authenticator, err error) {
 		a, err = ping.New(ctx, &o)
 	case cognito.Name:
 		a, err = cognito.New(ctx, &o)
+	case saml.Name:
+		a, err = saml.New(ctx, &o)
 	case "":
 		return nil, fmt.Errorf("identity: provider is not defined")
 	default:
@@ -0,0 +1,365 @@
+// Package saml implements SAML 2.0 based authentication, for identity providers which don't support
+// OpenID Connect.
+//
+// Pomerium acts as the service provider using SP-initiated flows: users are redirected to the
+// identity provider using the HTTP-Redirect binding, and the identity provider posts the signed
+// response to the authenticate callback url using the HTTP-POST binding.
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"golang.org/x/oauth2"
+
+	"github.com/pomerium/pomerium/internal/identity/identity"
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+	"github.com/pomerium/pomerium/internal/identity/oidc"
+)
+
+// Name identifies the SAML identity provider.
+const Name = "saml"
+
+// MetadataPath is the path of the service provider metadata on the authenticate service.
+const MetadataPath = "/.pomerium/saml/metadata"
+
+const (
+	// since SAML has no refresh, the session is kept until the pomerium session expires, or until
+	// the SessionNotOnOrAfter of the assertion
+	refreshDeadline = time.Minute * 60
+
+	metadataCacheDuration = time.Hour
+
+	signatureMethodRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+)
+
+var errSessionExpired = errors.New("saml: session has passed its SessionNotOnOrAfter")
+
+// DefaultAttributeMapping maps claims to the assertion attributes commonly used by identity
+// providers. The attributes of a claim are checked in order, by name and by friendly name.
+var DefaultAttributeMapping = map[string][]string{
+	"email": {
+		"email",
+		"mail",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+	},
+	"name": {
+		"name",
+		"displayName",
+		"urn:oid:2.16.840.1.113730.3.1.241",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+	},
+	"given_name": {
+		"givenName",
+		"urn:oid:2.5.4.42",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname",
+	},
+	"family_name": {
+		"sn",
+		"urn:oid:2.5.4.4",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname",
+	},
+	"groups": {
+		"groups",
+		"memberOf",
+		"urn:oid:1.3.6.1.4.1.5923.1.5.1.1",
+		"http://schemas.microsoft.com/ws/2008/06/identity/claims/groups",
+	},
+}
+
+// listClaims are always set as a list, even if the assertion contains a single value.
+var listClaims = map[string]bool{
+	"groups": true,
+}
+
+// Provider is a SAML service provider.
+type Provider struct {
+	sp               *saml.ServiceProvider
+	attributeMapping map[string][]string
+}
+
+// New creates a new SAML service provider. The identity provider metadata is read from the SAML
+// options, or fetched from the provider url. The client id is used as the service provider entity
+// id, which defaults to the metadata url.
+func New(ctx context.Context, o *oauth.Options) (*Provider, error) {
+	if o.RedirectURL == nil {
+		return nil, errors.New("saml: missing redirect url")
+	}
+
+	idpMetadata, err := getIdentityProviderMetadata(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataURL := *o.RedirectURL.ResolveReference(&url.URL{Path: MetadataPath})
+	sp := &saml.ServiceProvider{
+		EntityID:          o.ClientID,
+		MetadataURL:       metadataURL,
+		AcsURL:            *o.RedirectURL,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+	if sp.EntityID == "" {
+		sp.EntityID = metadataURL.String()
+	}
+
+	if cert := o.SAML.Certificate; cert != nil {
+		key, ok := cert.PrivateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("saml: signing key must be an RSA key")
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid signing certificate: %w", err)
+		}
+		sp.Key = key
+		sp.Certificate = leaf
+		sp.SignatureMethod = signatureMethodRSASHA256
+	}
+
+	attributeMapping := make(map[string][]string, len(DefaultAttributeMapping)+len(o.SAML.AttributeMapping))
+	for claim, attributes := range DefaultAttributeMapping {
+		attributeMapping[claim] = attributes
+	}
+	for claim, attribute := range o.SAML.AttributeMapping {
+		attributeMapping[claim] = []string{attribute}
+	}
+
+	return &Provider{sp: sp, attributeMapping: attributeMapping}, nil
+}
+
+// NewCode returns the code to authenticate for a SAML response received through the HTTP-POST
+// binding. The relay state is required to validate the response is for the request made by
+// GetSignInURL.
+func NewCode(relayState, samlResponse string) string {
+	return relayState + "." + samlResponse
+}
+
+// Authenticate validates the SAML response, creating an identity session from the assertion.
+func (p *Provider) Authenticate(ctx context.Context, code string, v identity.State) (*oauth2.Token, error) {
+	relayState, samlResponse, ok := strings.Cut(code, ".")
+	if !ok {
+		return nil, errors.New("saml: invalid code")
+	}
+
+	rawResponse, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid response encoding: %w", err)
+	}
+
+	assertion, err := p.sp.ParseXMLResponse(rawResponse, []string{requestID(relayState)})
+	if err != nil {
+		var ire *saml.InvalidResponseError
+		if errors.As(err, &ire) && ire.PrivateErr != nil {
+			err = ire.PrivateErr
+		}
+		return nil, fmt.Errorf("saml: invalid response: %w", err)
+	}
+
+	claims, err := p.getClaims(assertion)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bs, v); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken: assertion.ID,
+		TokenType:   "saml",
+	}
+	var notOnOrAfter time.Time
+	for _, statement := range assertion.AuthnStatements {
+		if statement.SessionNotOnOrAfter != nil &&
+			(notOnOrAfter.IsZero() || statement.SessionNotOnOrAfter.Before(notOnOrAfter)) {
+			notOnOrAfter = *statement.SessionNotOnOrAfter
+		}
+	}
+	if !notOnOrAfter.IsZero() {
+		// the end of the session is kept in the refresh token, which is stored with the session,
+		// unlike the extra fields of the token
+		token.RefreshToken = notOnOrAfter.UTC().Format(time.RFC3339Nano)
+	}
+	return p.Refresh(ctx, token, v)
+}
+
+func (p *Provider) getClaims(assertion *saml.Assertion) (map[string]any, error) {
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("saml: assertion is missing the subject name id")
+	}
+
+	values := map[string][]any{}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			for _, value := range attribute.Values {
+				values[attribute.Name] = append(values[attribute.Name], value.Value)
+				if attribute.FriendlyName != "" && attribute.FriendlyName != attribute.Name {
+					values[attribute.FriendlyName] = append(values[attribute.FriendlyName], value.Value)
+				}
+			}
+		}
+	}
+
+	claims := map[string]any{
+		"sub": assertion.Subject.NameID.Value,
+	}
+	for claim, attributes := range p.attributeMapping {
+		for _, attribute := range attributes {
+			vs, ok := values[attribute]
+			if !ok {
+				continue
+			}
+			if len(vs) == 1 && !listClaims[claim] {
+				claims[claim] = vs[0]
+			} else {
+				claims[claim] = vs
+			}
+			break
+		}
+	}
+	return claims, nil
+}
+
+// UpdateUserInfo is a no-op, because the user info is only available from the assertion.
+func (p *Provider) UpdateUserInfo(_ context.Context, _ *oauth2.Token, _ interface{}) error {
+	return nil
+}
+
+// Refresh extends the expiry of the token, as SAML doesn't support refreshing sessions, but never
+// past the SessionNotOnOrAfter of the assertion, which is kept in the refresh token. Once it has
+// passed, an error is returned, so the session is deleted.
+func (p *Provider) Refresh(_ context.Context, t *oauth2.Token, _ identity.State) (*oauth2.Token, error) {
+	now := time.Now()
+	expiry := now.Add(refreshDeadline)
+	if t.RefreshToken != "" {
+		notOnOrAfter, err := time.Parse(time.RFC3339Nano, t.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid session deadline: %w", err)
+		}
+		if !now.Before(notOnOrAfter) {
+			return nil, errSessionExpired
+		}
+		if notOnOrAfter.Before(expiry) {
+			expiry = notOnOrAfter
+		}
+	}
+	t.Expiry = expiry
+	return t, nil
+}
+
+// Revoke is not implemented by SAML.
+func (p *Provider) Revoke(_ context.Context, _ *oauth2.Token) error {
+	return oidc.ErrRevokeNotImplemented
+}
+
+// GetSignInURL returns the url of the identity provider single sign-on service, with an
+// authentication request using the HTTP-Redirect binding. The state is sent as the relay state.
+func (p *Provider) GetSignInURL(state string) (string, error) {
+	ssoURL := p.sp.GetSSOBindingLocation(saml.HTTPRedirectBinding)
+	if ssoURL == "" {
+		return "", errors.New("saml: identity provider does not support the HTTP-Redirect binding")
+	}
+
+	req, err := p.sp.MakeAuthenticationRequest(ssoURL, saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", fmt.Errorf("saml: error creating authentication request: %w", err)
+	}
+	req.ID = requestID(state)
+
+	u, err := req.Redirect(state, p.sp)
+	if err != nil {
+		return "", fmt.Errorf("saml: error creating authentication request: %w", err)
+	}
+	return u.String(), nil
+}
+
+// LogOut is not implemented by SAML.
+func (p *Provider) LogOut() (*url.URL, error) {
+	return nil, oidc.ErrSignoutNotImplemented
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return Name
+}
+
+// Metadata returns the service provider metadata, which is used to configure the identity
+// provider.
+func (p *Provider) Metadata() ([]byte, error) {
+	return xml.MarshalIndent(p.sp.Metadata(), "", "  ")
+}
+
+// requestID returns the id of the authentication request for the given state, so that responses
+// can only be used with the state they were requested for.
+func requestID(state string) string {
+	h := sha256.Sum256([]byte(state))
+	return "id-" + hex.EncodeToString(h[:])
+}
+
+var metadataCache = struct {
+	sync.Mutex
+	entries map[string]metadataCacheEntry
+}{entries: map[string]metadataCacheEntry{}}
+
+type metadataCacheEntry struct {
+	metadata *saml.EntityDescriptor
+	expiry   time.Time
+}
+
+func getIdentityProviderMetadata(ctx context.Context, o *oauth.Options) (*saml.EntityDescriptor, error) {
+	if len(o.SAML.IdPMetadata) > 0 {
+		metadata, err := samlsp.ParseMetadata(o.SAML.IdPMetadata)
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid identity provider metadata: %w", err)
+		}
+		return metadata, nil
+	}
+
+	if o.ProviderURL == "" {
+		return nil, oidc.ErrMissingProviderURL
+	}
+
+	metadataCache.Lock()
+	entry, ok := metadataCache.entries[o.ProviderURL]
+	metadataCache.Unlock()
+	if ok && time.Now().Before(entry.expiry) {
+		return entry.metadata, nil
+	}
+
+	u, err := url.Parse(o.ProviderURL)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid identity provider metadata url: %w", err)
+	}
+	metadata, err := samlsp.FetchMetadata(ctx, http.DefaultClient, *u)
+	if err != nil {
+		return nil, fmt.Errorf("saml: error fetching identity provider metadata: %w", err)
+	}
+
+	metadataCache.Lock()
+	metadataCache.entries[o.ProviderURL] = metadataCacheEntry{
+		metadata: metadata,
+		expiry:   time.Now().Add(metadataCacheDuration),
+	}
+	metadataCache.Unlock()
+	return metadata, nil
+}
@@ -0,0 +1,163 @@
+package saml
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/pomerium/pomerium/internal/identity/oauth"
+)
+
+const testIdPMetadata = `<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com/metadata">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+
+func newTestProvider(t *testing.T, attributeMapping map[string]string) *Provider {
+	t.Helper()
+
+	p, err := New(context.Background(), &oauth.Options{
+		RedirectURL: &url.URL{Scheme: "https", Host: "authenticate.example.com", Path: "/oauth2/callback"},
+		SAML: oauth.SAMLOptions{
+			IdPMetadata:      []byte(testIdPMetadata),
+			AttributeMapping: attributeMapping,
+		},
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, nil)
+	assert.Equal(t, "https://authenticate.example.com/.pomerium/saml/metadata", p.sp.EntityID)
+	assert.Equal(t, "https://authenticate.example.com/oauth2/callback", p.sp.AcsURL.String())
+
+	_, err := New(context.Background(), &oauth.Options{
+		RedirectURL: &url.URL{Scheme: "https", Host: "authenticate.example.com", Path: "/oauth2/callback"},
+	})
+	assert.Error(t, err, "should require metadata")
+}
+
+func TestProvider_GetSignInURL(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, nil)
+	rawURL, err := p.GetSignInURL("STATE")
+	require.NoError(t, err)
+
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", u.Host)
+	assert.Equal(t, "/sso", u.Path)
+	assert.Equal(t, "STATE", u.Query().Get("RelayState"))
+	assert.NotEmpty(t, u.Query().Get("SAMLRequest"))
+}
+
+func TestProvider_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, nil)
+	_, err := p.Authenticate(context.Background(), "INVALID", nil)
+	assert.Error(t, err)
+	_, err = p.Authenticate(context.Background(), NewCode("STATE", "!!!"), nil)
+	assert.Error(t, err)
+}
+
+func TestProvider_Refresh(t *testing.T) {
+	t.Parallel()
+
+	p := newTestProvider(t, nil)
+
+	token, err := p.Refresh(context.Background(), &oauth2.Token{TokenType: "saml"}, nil)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(refreshDeadline), token.Expiry, time.Minute)
+
+	notOnOrAfter := time.Now().Add(10 * time.Minute).UTC()
+	token, err = p.Refresh(context.Background(), &oauth2.Token{
+		TokenType:    "saml",
+		RefreshToken: notOnOrAfter.Format(time.RFC3339Nano),
+	}, nil)
+	require.NoError(t, err)
+	assert.True(t, token.Expiry.Equal(notOnOrAfter), "the expiry should not extend past SessionNotOnOrAfter")
+
+	_, err = p.Refresh(context.Background(), &oauth2.Token{
+		TokenType:    "saml",
+		RefreshToken: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano),
+	}, nil)
+	assert.ErrorIs(t, err, errSessionExpired)
+
+	_, err = p.Refresh(context.Background(), &oauth2.Token{TokenType: "saml", RefreshToken: "INVALID"}, nil)
+	assert.Error(t, err)
+}
+
+func TestProvider_getClaims(t *testing.T) {
+	t.Parallel()
+
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: "USER_ID"}},
+		AttributeStatements: []saml.AttributeStatement{{
+			Attributes: []saml.Attribute{
+				{
+					Name:   "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+					Values: []saml.AttributeValue{{Value: "user@example.com"}},
+				},
+				{
+					Name:         "urn:oid:2.16.840.1.113730.3.1.241",
+					FriendlyName: "displayName",
+					Values:       []saml.AttributeValue{{Value: "User"}},
+				},
+				{
+					Name:   "memberOf",
+					Values: []saml.AttributeValue{{Value: "admins"}},
+				},
+				{
+					Name:   "department",
+					Values: []saml.AttributeValue{{Value: "engineering"}},
+				},
+			},
+		}},
+	}
+
+	claims, err := newTestProvider(t, nil).getClaims(assertion)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"sub":    "USER_ID",
+		"email":  "user@example.com",
+		"name":   "User",
+		"groups": []any{"admins"},
+	}, claims)
+
+	claims, err = newTestProvider(t, map[string]string{
+		"name":       "department",
+		"department": "department",
+	}).getClaims(assertion)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"sub":        "USER_ID",
+		"email":      "user@example.com",
+		"name":       "engineering",
+		"department": "engineering",
+		"groups":     []any{"admins"},
+	}, claims)
+
+	_, err = newTestProvider(t, nil).getClaims(&saml.Assertion{})
+	assert.Error(t, err, "should require a subject")
+}
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, requestID("STATE"), requestID("STATE"))
+	assert.NotEqual(t, requestID("STATE1"), requestID("STATE2"))
+	assert.Regexp(t, "^id-[0-9a-f]{64}$", requestID("STATE"))
+}
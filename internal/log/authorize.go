@@ -3,6 +3,7 @@ package log
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
@@ -80,11 +81,25 @@ var authorizeLogFieldLookup = map[AuthorizeLogField]struct{}{
 	AuthorizeLogFieldUser:                 {},
 }
 
+const claimsFieldPrefix = "claims."
+
+// GetClaimField returns the claim name for a field that represents logging a session or user claim.
+func GetClaimField(field AuthorizeLogField) (claimName string, ok bool) {
+	if strings.HasPrefix(string(field), claimsFieldPrefix) && len(field) > len(claimsFieldPrefix) {
+		return string(field)[len(claimsFieldPrefix):], true
+	}
+
+	return "", false
+}
+
 // Validate returns an error if the authorize log field is invalid.
 func (field AuthorizeLogField) Validate() error {
 	if _, ok := GetHeaderField(field); ok {
 		return nil
 	}
+	if _, ok := GetClaimField(field); ok {
+		return nil
+	}
 
 	_, ok := authorizeLogFieldLookup[field]
 	if !ok {
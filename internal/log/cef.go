@@ -0,0 +1,212 @@
+package log
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+// A SecurityEvent is an event formatted for a SIEM, in the ArcSight Common Event Format (CEF) or
+// the IBM QRadar Log Event Extended Format (LEEF).
+type SecurityEvent struct {
+	// Name identifies the kind of event, such as http-request or sign_in.
+	Name string
+	// Severity is the severity of the event, from 0 (lowest) to 10 (highest).
+	Severity int
+	Time     time.Time
+	// Fields are the additional fields of the event. Well-known fields, like ip or email, are
+	// mapped to the predefined keys of the format.
+	Fields []SecurityEventField
+}
+
+// A SecurityEventField is a field of a SecurityEvent.
+type SecurityEventField struct {
+	Key   string
+	Value string
+}
+
+// predefined keys of well-known fields
+var (
+	cefKeys = map[string]string{
+		"authority":  "dhost",
+		"email":      "suser",
+		"ip":         "src",
+		"method":     "requestMethod",
+		"path":       "request",
+		"request-id": "externalId",
+		"user":       "suid",
+		"user_id":    "suid",
+		"user-agent": "requestClientApplication",
+	}
+	leefKeys = map[string]string{
+		"email": "usrName",
+		"ip":    "src",
+	}
+)
+
+// FormatSecurityEvent returns the event in the CEF or LEEF format. Other formats return an empty
+// string.
+func (format Format) FormatSecurityEvent(evt *SecurityEvent) string {
+	var b strings.Builder
+	switch format {
+	case FormatCEF:
+		b.WriteString("CEF:0|Pomerium|Pomerium|")
+		b.WriteString(cefHeaderEscape(version.FullVersion()))
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscape(evt.Name))
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscape(evt.Name))
+		b.WriteByte('|')
+		b.WriteString(strconv.Itoa(clampSeverity(evt.Severity, 0)))
+		b.WriteByte('|')
+
+		sep := ""
+		write := func(key, value string) {
+			b.WriteString(sep)
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(cefValueEscape(value))
+			sep = " "
+		}
+		if !evt.Time.IsZero() {
+			write("rt", strconv.FormatInt(evt.Time.UnixMilli(), 10))
+		}
+		for _, f := range evt.Fields {
+			write(securityEventKey(cefKeys, f.Key), f.Value)
+		}
+	case FormatLEEF:
+		b.WriteString("LEEF:1.0|Pomerium|Pomerium|")
+		b.WriteString(cefHeaderEscape(version.FullVersion()))
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscape(evt.Name))
+		b.WriteByte('|')
+
+		write := func(key, value string) {
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(leefValueEscape(value))
+			b.WriteByte('\t')
+		}
+		write("sev", strconv.Itoa(clampSeverity(evt.Severity, 1)))
+		if !evt.Time.IsZero() {
+			write("devTime", evt.Time.UTC().Format("Jan 02 2006 15:04:05.000 MST"))
+			write("devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS z")
+		}
+		for _, f := range evt.Fields {
+			write(securityEventKey(leefKeys, f.Key), f.Value)
+		}
+		return strings.TrimSuffix(b.String(), "\t")
+	}
+	return b.String()
+}
+
+func clampSeverity(severity, min int) int {
+	if severity < min {
+		return min
+	} else if severity > 10 {
+		return 10
+	}
+	return severity
+}
+
+// securityEventKey returns the predefined key of a well-known field, or else the field name in
+// camel case, as keys may only contain letters and digits.
+func securityEventKey(predefined map[string]string, field string) string {
+	if key, ok := predefined[field]; ok {
+		return key
+	}
+
+	var b strings.Builder
+	upper := false
+	for _, r := range field {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if upper && b.Len() > 0 {
+				r = unicode.ToUpper(r)
+			}
+			b.WriteRune(r)
+			upper = false
+		default:
+			upper = true
+		}
+	}
+	if b.Len() == 0 {
+		return "field"
+	}
+	return b.String()
+}
+
+var (
+	cefHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueReplacer  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefValueReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+)
+
+func cefHeaderEscape(s string) string { return cefHeaderReplacer.Replace(s) }
+func cefValueEscape(s string) string  { return cefValueReplacer.Replace(s) }
+func leefValueEscape(s string) string { return leefValueReplacer.Replace(s) }
+
+// levelSeverities map log levels to security event severities.
+var levelSeverities = map[string]int{
+	zerolog.TraceLevel.String(): 1,
+	zerolog.DebugLevel.String(): 1,
+	zerolog.InfoLevel.String():  3,
+	zerolog.WarnLevel.String():  6,
+	zerolog.ErrorLevel.String(): 8,
+	zerolog.FatalLevel.String(): 10,
+	zerolog.PanicLevel.String(): 10,
+}
+
+type securityEventWriter struct {
+	format Format
+	w      io.Writer
+}
+
+// NewCEFWriter returns a writer which converts JSON log events, as written by zerolog, to CEF.
+// The message is the event name and the level determines the severity.
+func NewCEFWriter(w io.Writer) io.Writer {
+	return securityEventWriter{format: FormatCEF, w: w}
+}
+
+// NewLEEFWriter returns a writer which converts JSON log events, as written by zerolog, to LEEF.
+// The message is the event name and the level determines the severity.
+func NewLEEFWriter(w io.Writer) io.Writer {
+	return securityEventWriter{format: FormatLEEF, w: w}
+}
+
+func (sw securityEventWriter) Write(p []byte) (int, error) {
+	fields, err := decodeLogEvent(p)
+	if err != nil {
+		return 0, err
+	}
+
+	evt := &SecurityEvent{Name: "log", Severity: levelSeverities[zerolog.InfoLevel.String()]}
+	for _, f := range fields {
+		value := logEventValue(f.value)
+		switch f.key {
+		case zerolog.MessageFieldName:
+			evt.Name = value
+			continue
+		case zerolog.LevelFieldName:
+			if severity, ok := levelSeverities[value]; ok {
+				evt.Severity = severity
+				continue
+			}
+		case zerolog.TimestampFieldName:
+			if tm, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				evt.Time = tm
+				continue
+			}
+		}
+		evt.Fields = append(evt.Fields, SecurityEventField{Key: f.key, Value: value})
+	}
+
+	_, err = io.WriteString(sw.w, sw.format.FormatSecurityEvent(evt)+"\n")
+	return len(p), err
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+func TestFormatSecurityEvent(t *testing.T) {
+	t.Parallel()
+
+	evt := &SecurityEvent{
+		Name:     "sign|in",
+		Severity: 3,
+		Time:     time.Date(2023, 6, 1, 12, 30, 0, 0, time.UTC),
+		Fields: []SecurityEventField{
+			{"user_id", "user-1"},
+			{"email", "user@example.com"},
+			{"anomaly_type", "new\tcountry"},
+			{"query", `a=b\c` + "\n"},
+		},
+	}
+
+	assert.Equal(t,
+		`CEF:0|Pomerium|Pomerium|`+version.FullVersion()+`|sign\|in|sign\|in|3|`+
+			`rt=1685622600000 suid=user-1 suser=user@example.com anomalyType=new	country query=a\=b\\c\n`,
+		FormatCEF.FormatSecurityEvent(evt))
+	assert.Equal(t,
+		"LEEF:1.0|Pomerium|Pomerium|"+version.FullVersion()+`|sign\|in|`+
+			"sev=3\tdevTime=Jun 01 2023 12:30:00.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\t"+
+			"userId=user-1\tusrName=user@example.com\tanomalyType=new country\tquery=a=b\\c ",
+		FormatLEEF.FormatSecurityEvent(evt))
+	assert.Empty(t, FormatJSON.FormatSecurityEvent(evt))
+}
+
+func TestSecurityEventWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := zerolog.New(NewCEFWriter(&buf))
+	l.Warn().
+		Str("ip", "127.0.0.1").
+		Uint32("response-code", 403).
+		Msg("http-request")
+	l.Log().Msg("")
+
+	assert.Equal(t,
+		"CEF:0|Pomerium|Pomerium|"+version.FullVersion()+"|http-request|http-request|6|src=127.0.0.1 responseCode=403\n"+
+			"CEF:0|Pomerium|Pomerium|"+version.FullVersion()+"|log|log|3|\n",
+		buf.String())
+
+	buf.Reset()
+	l = zerolog.New(NewLEEFWriter(&buf))
+	l.Error().Str("email", "user@example.com").Msg("authorize check")
+	assert.Equal(t,
+		"LEEF:1.0|Pomerium|Pomerium|"+version.FullVersion()+"|authorize check|sev=8\tusrName=user@example.com\n",
+		buf.String())
+}
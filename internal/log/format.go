@@ -0,0 +1,241 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+)
+
+// A Format is the output format of access and authorize logs.
+type Format string
+
+// known formats
+const (
+	FormatJSON           Format = "json"
+	FormatLogfmt         Format = "logfmt"
+	FormatApacheCombined Format = "apache-combined"
+	FormatCEF            Format = "cef"
+	FormatLEEF           Format = "leef"
+)
+
+// ErrUnknownFormat indicates that a log format is unknown.
+var ErrUnknownFormat = errors.New("unknown log format")
+
+// Validate returns an error if the format is invalid. An empty format is JSON.
+func (format Format) Validate() error {
+	switch format {
+	case "", FormatJSON, FormatLogfmt, FormatApacheCombined, FormatCEF, FormatLEEF:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// formatOutput is where logs in a format other than JSON are written.
+var formatOutput io.Writer = os.Stdout
+
+// FormatLogger returns the logger associated with the ctx, writing events in the given format.
+// The apache combined format can't represent arbitrary events, so those are written as JSON.
+func FormatLogger(ctx context.Context, format Format) *zerolog.Logger {
+	l := contextLogger(ctx)
+	switch format {
+	case FormatLogfmt, FormatCEF, FormatLEEF:
+		ll := l.Output(FormatWriter(format, formatOutput))
+		return &ll
+	}
+	return l
+}
+
+// FormatLoggerTo returns the logger associated with the ctx, writing events in the given format
+// to w.
+func FormatLoggerTo(ctx context.Context, format Format, w io.Writer) *zerolog.Logger {
+	ll := contextLogger(ctx).Output(FormatWriter(format, w))
+	return &ll
+}
+
+// FormatWriter returns a writer which converts JSON log events, as written by zerolog, to the
+// given format. Events in the JSON and apache combined formats are written as JSON.
+func FormatWriter(format Format, w io.Writer) io.Writer {
+	switch format {
+	case FormatLogfmt:
+		return NewLogfmtWriter(w)
+	case FormatCEF:
+		return NewCEFWriter(w)
+	case FormatLEEF:
+		return NewLEEFWriter(w)
+	}
+	return w
+}
+
+type logfmtWriter struct {
+	w io.Writer
+}
+
+// NewLogfmtWriter returns a writer which converts JSON log events, as written by zerolog, to logfmt.
+// Nested objects and arrays are written as JSON values.
+func NewLogfmtWriter(w io.Writer) io.Writer {
+	return logfmtWriter{w: w}
+}
+
+func (lw logfmtWriter) Write(p []byte) (int, error) {
+	bs, err := jsonToLogfmt(p)
+	if err != nil {
+		return 0, err
+	}
+	_, err = lw.w.Write(bs)
+	return len(p), err
+}
+
+type logEventField struct {
+	key   string
+	value json.RawMessage
+}
+
+// decodeLogEvent decodes the fields of a JSON log event, in order.
+func decodeLogEvent(p []byte) ([]logEventField, error) {
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("log: invalid log event")
+	}
+
+	var fields []logEventField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("log: invalid log event: %w", err)
+		}
+		key, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("log: invalid log event: %w", err)
+		}
+		fields = append(fields, logEventField{key: key, value: raw})
+	}
+	return fields, nil
+}
+
+// logEventValue returns a string value as is, and other values as compact JSON.
+func logEventValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+func jsonToLogfmt(p []byte) ([]byte, error) {
+	fields, err := decodeLogEvent(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, f := range fields {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtQuote(f.key))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtQuote(logEventValue(f.value)))
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func logfmtQuote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == ' ' || r == '=' || r == '"' || r == '\\' || unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// An ApacheCombinedEntry is an access log entry in the Apache combined log format.
+type ApacheCombinedEntry struct {
+	RemoteAddr string
+	User       string
+	Time       time.Time
+	Method     string
+	URI        string
+	Protocol   string
+	Status     uint32
+	Size       uint64
+	Referer    string
+	UserAgent  string
+}
+
+// String returns the entry in the Apache combined log format.
+func (e *ApacheCombinedEntry) String() string {
+	size := "-"
+	if e.Size > 0 {
+		size = strconv.FormatUint(e.Size, 10)
+	}
+	return fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s "%s" "%s"`,
+		apacheValue(e.RemoteAddr),
+		apacheValue(e.User),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		apacheEscape(e.Method), apacheEscape(e.URI), apacheEscape(e.Protocol),
+		e.Status,
+		size,
+		apacheValue(apacheEscape(e.Referer)),
+		apacheValue(apacheEscape(e.UserAgent)))
+}
+
+func apacheValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// apacheEscape escapes quotes, backslashes and control characters like Apache does.
+func apacheEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// WriteApacheCombined writes an entry in the Apache combined log format, unless the level is
+// disabled.
+func WriteApacheCombined(level zerolog.Level, entry *ApacheCombinedEntry) {
+	WriteApacheCombinedTo(formatOutput, level, entry)
+}
+
+// WriteApacheCombinedTo writes an entry in the Apache combined log format to w, unless the level
+// is disabled.
+func WriteApacheCombinedTo(w io.Writer, level zerolog.Level, entry *ApacheCombinedEntry) {
+	if level < zerolog.GlobalLevel() || level < Logger().GetLevel() {
+		return
+	}
+	_, _ = io.WriteString(w, entry.String()+"\n")
+}
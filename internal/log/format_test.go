@@ -0,0 +1,65 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_Validate(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []Format{"", FormatJSON, FormatLogfmt, FormatApacheCombined, FormatCEF, FormatLEEF} {
+		assert.NoError(t, format.Validate(), format)
+	}
+	assert.ErrorIs(t, Format("xml").Validate(), ErrUnknownFormat)
+}
+
+func TestLogfmtWriter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := zerolog.New(NewLogfmtWriter(&buf))
+	l.Info().
+		Str("service", "envoy").
+		Str("path", "/some path").
+		Str("empty", "").
+		Uint32("response-code", 200).
+		Bool("allow", true).
+		Strs("groups", []string{"a", "b"}).
+		Interface("headers", map[string]string{"X-Test": `a "quoted" value`}).
+		Msg("http-request")
+
+	assert.Equal(t,
+		`level=info service=envoy path="/some path" empty="" response-code=200 allow=true groups="[\"a\",\"b\"]" headers="{\"X-Test\":\"a \\\"quoted\\\" value\"}" message=http-request`+"\n",
+		buf.String())
+}
+
+func TestApacheCombinedEntry(t *testing.T) {
+	t.Parallel()
+
+	entry := &ApacheCombinedEntry{
+		RemoteAddr: "127.0.0.1",
+		Time:       time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		Method:     "GET",
+		URI:        "/apache_pb.gif",
+		Protocol:   "HTTP/1.0",
+		Status:     200,
+		Size:       2326,
+		Referer:    "http://www.example.com/start.html",
+		UserAgent:  "Mozilla/4.08 [en] (Win98; I ;Nav)",
+	}
+	assert.Equal(t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+		entry.String())
+
+	entry.Size = 0
+	entry.Referer = ""
+	entry.UserAgent = `"\`
+	assert.Equal(t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 - "-" "\"\\"`,
+		entry.String())
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedactionReplacement replaces redacted values if no replacement is set.
+const DefaultRedactionReplacement = "[REDACTED]"
+
+// A Redaction redacts the value of a log field.
+type Redaction struct {
+	// Field is the log field to redact, such as query, headers.Authorization or claims.email.
+	Field string
+	// Pattern is a regular expression matching the parts of the value to redact. If empty, the
+	// whole value is redacted.
+	Pattern string
+	// Replacement replaces the redacted value. Capture groups of the pattern can be referenced
+	// with $1. Defaults to DefaultRedactionReplacement.
+	Replacement string
+}
+
+type compiledRedaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// A Redactor redacts the values of log fields. A nil Redactor doesn't redact anything.
+type Redactor struct {
+	byField map[string][]compiledRedaction
+}
+
+// NewRedactor creates a new Redactor.
+func NewRedactor(redactions ...Redaction) (*Redactor, error) {
+	if len(redactions) == 0 {
+		return nil, nil
+	}
+
+	r := &Redactor{byField: map[string][]compiledRedaction{}}
+	for _, redaction := range redactions {
+		if redaction.Field == "" {
+			return nil, fmt.Errorf("log: redaction field is required")
+		}
+
+		cr := compiledRedaction{replacement: redaction.Replacement}
+		if cr.replacement == "" {
+			cr.replacement = DefaultRedactionReplacement
+		}
+		if redaction.Pattern != "" {
+			var err error
+			cr.pattern, err = regexp.Compile(redaction.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("log: invalid redaction pattern for %s: %w", redaction.Field, err)
+			}
+		}
+
+		field := normalizeRedactionField(redaction.Field)
+		r.byField[field] = append(r.byField[field], cr)
+	}
+	return r, nil
+}
+
+// Redact returns the value of a field with the redactions applied.
+func (r *Redactor) Redact(field, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	for _, cr := range r.byField[normalizeRedactionField(field)] {
+		if cr.pattern == nil {
+			value = cr.replacement
+		} else {
+			value = cr.pattern.ReplaceAllString(value, cr.replacement)
+		}
+	}
+	return value
+}
+
+// RedactHeaders returns the headers with the redactions of the headers fields applied.
+func (r *Redactor) RedactHeaders(hdrs map[string]string) map[string]string {
+	if r == nil || len(hdrs) == 0 {
+		return hdrs
+	}
+	redacted := make(map[string]string, len(hdrs))
+	for k, v := range hdrs {
+		redacted[k] = r.Redact(headersFieldPrefix+k, v)
+	}
+	return redacted
+}
+
+// normalizeRedactionField canonicalizes the header name of headers fields, so that redactions
+// apply regardless of the case of the header name.
+func normalizeRedactionField(field string) string {
+	if headerName, ok := strings.CutPrefix(field, headersFieldPrefix); ok {
+		return headersFieldPrefix + CanonicalHeaderKey(headerName)
+	}
+	return field
+}
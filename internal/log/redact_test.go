@@ -0,0 +1,39 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewRedactor(
+		Redaction{Field: "query", Pattern: `(token|code)=[^&]*`, Replacement: "$1=***"},
+		Redaction{Field: "headers.authorization"},
+		Redaction{Field: "claims.email", Pattern: `^[^@]*`, Replacement: "user"},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "token=***&a=b&code=***", r.Redact("query", "token=SECRET&a=b&code=CODE"))
+	assert.Equal(t, "user@example.com", r.Redact("claims.email", "jane@example.com"))
+	assert.Equal(t, "/some/path", r.Redact("path", "/some/path"))
+	assert.Equal(t, "", r.Redact("headers.Authorization", ""))
+	assert.Equal(t, map[string]string{
+		"Authorization": DefaultRedactionReplacement,
+		"X-Test":        "value",
+	}, r.RedactHeaders(map[string]string{
+		"Authorization": "Bearer SECRET",
+		"X-Test":        "value",
+	}))
+
+	var nilRedactor *Redactor
+	assert.Equal(t, "value", nilRedactor.Redact("query", "value"))
+
+	_, err = NewRedactor(Redaction{Field: "query", Pattern: "("})
+	assert.Error(t, err)
+	_, err = NewRedactor(Redaction{Pattern: "a"})
+	assert.Error(t, err)
+}
@@ -4,10 +4,13 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/ui"
 )
 
 // SetHeaders sets a map of response headers.
@@ -73,3 +76,30 @@ func RequireBasicAuth(username, password string) func(next http.Handler) http.Ha
 		})
 	}
 }
+
+// NoncePlaceholder is replaced with a new random nonce for each response by SetSecurityHeaders.
+const NoncePlaceholder = "{nonce}"
+
+// SetSecurityHeaders sets a map of response headers like SetHeaders, replacing the
+// NoncePlaceholder in them with a new random nonce for each response. The nonce is added to the
+// request context, so pages served by the ui package add it to their scripts.
+func SetSecurityHeaders(headers map[string]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var nonce string
+			for key, val := range headers {
+				if strings.Contains(val, NoncePlaceholder) {
+					if nonce == "" {
+						nonce = cryptutil.NewRandomStringN(16)
+					}
+					val = strings.ReplaceAll(val, NoncePlaceholder, nonce)
+				}
+				w.Header().Set(key, val)
+			}
+			if nonce != "" {
+				r = r.WithContext(ui.WithCSPNonce(r.Context(), nonce))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -40,6 +40,27 @@ func TestSetHeaders(t *testing.T) {
 	}
 }
 
+func TestSetSecurityHeaders(t *testing.T) {
+	t.Parallel()
+
+	handler := SetSecurityHeaders(map[string]string{
+		"Content-Security-Policy": "script-src 'nonce-{nonce}'; style-src 'nonce-{nonce}'",
+		"X-Frame-Options":         "DENY",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	getCSP := func() string {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "DENY", rr.Header().Get("X-Frame-Options"))
+		return rr.Header().Get("Content-Security-Policy")
+	}
+
+	csp1, csp2 := getCSP(), getCSP()
+	assert.NotContains(t, csp1, NoncePlaceholder)
+	assert.Regexp(t, `^script-src 'nonce-(\S+)'; style-src 'nonce-\S+'$`, csp1)
+	assert.NotEqual(t, csp1, csp2, "should use a new nonce for each response")
+}
+
 func TestValidateSignature(t *testing.T) {
 	t.Parallel()
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,110 @@
+// Package migrate converts the configuration of other reverse proxies and authenticating proxies
+// to pomerium routes with policy skeletons, to lower the cost of migrating to pomerium.
+package migrate
+
+import (
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats which can be converted.
+const (
+	FormatNginx       = "nginx"
+	FormatOAuth2Proxy = "oauth2-proxy"
+	FormatTraefik     = "traefik"
+)
+
+// A Result is a converted configuration. It's written as a pomerium config file.
+type Result struct {
+	IDPProvider    string   `yaml:"idp_provider,omitempty"`
+	IDPProviderURL string   `yaml:"idp_provider_url,omitempty"`
+	IDPClientID    string   `yaml:"idp_client_id,omitempty"`
+	IDPScopes      []string `yaml:"idp_scopes,omitempty"`
+	Routes         []Route  `yaml:"routes"`
+
+	// Warnings describe the parts of the configuration which couldn't be converted and need to be
+	// reviewed by hand.
+	Warnings []string `yaml:"-"`
+}
+
+func (res *Result) warnf(format string, args ...any) {
+	res.Warnings = append(res.Warnings, fmt.Sprintf(format, args...))
+}
+
+// A Route is a converted route.
+type Route struct {
+	From                             string   `yaml:"from"`
+	To                               []string `yaml:"to"`
+	Prefix                           string   `yaml:"prefix,omitempty"`
+	Path                             string   `yaml:"path,omitempty"`
+	Regex                            string   `yaml:"regex,omitempty"`
+	PrefixRewrite                    string   `yaml:"prefix_rewrite,omitempty"`
+	PreserveHostHeader               bool     `yaml:"preserve_host_header,omitempty"`
+	IPAllowlist                      []string `yaml:"ip_allowlist,omitempty"`
+	IPDenylist                       []string `yaml:"ip_denylist,omitempty"`
+	AllowPublicUnauthenticatedAccess bool     `yaml:"allow_public_unauthenticated_access,omitempty"`
+	Policy                           []Rule   `yaml:"policy,omitempty"`
+}
+
+// A Rule is a pomerium policy language rule. Rules are combined with or.
+type Rule struct {
+	Allow RuleBody `yaml:"allow"`
+}
+
+// A RuleBody combines criteria with and, or both.
+type RuleBody struct {
+	And []Criterion `yaml:"and,omitempty"`
+	Or  []Criterion `yaml:"or,omitempty"`
+}
+
+// A Criterion is a pomerium policy language criterion, e.g. {"domain": {"is": "example.com"}}.
+type Criterion map[string]any
+
+// authenticatedUserPolicy allows any authenticated user. It's the skeleton emitted for routes
+// which only required authentication.
+func authenticatedUserPolicy() []Rule {
+	return []Rule{{Allow: RuleBody{Or: []Criterion{{"authenticated_user": true}}}}}
+}
+
+// Convert converts a configuration in the given format.
+func Convert(format string, data []byte) (*Result, error) {
+	switch format {
+	case FormatNginx:
+		return convertNginx(data)
+	case FormatOAuth2Proxy:
+		return convertOAuth2Proxy(data)
+	case FormatTraefik:
+		return convertTraefik(data)
+	default:
+		return nil, fmt.Errorf("migrate: unknown format %q, must be one of %s, %s or %s",
+			format, FormatNginx, FormatOAuth2Proxy, FormatTraefik)
+	}
+}
+
+// Write writes the result as a pomerium config file, with the warnings as comments.
+func (res *Result) Write(w io.Writer) error {
+	for _, warning := range res.Warnings {
+		if _, err := fmt.Fprintf(w, "# WARNING: %s\n", warning); err != nil {
+			return err
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(res); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// setAuthentication sets the policy of a route which did or didn't require authentication.
+func (res *Result) setAuthentication(route *Route, authenticated bool) {
+	if authenticated {
+		route.Policy = authenticatedUserPolicy()
+		return
+	}
+	route.AllowPublicUnauthenticatedAccess = true
+	res.warnf("%s%s didn't require authentication and allows public unauthenticated access, add a policy to protect it",
+		route.From, route.Prefix+route.Path+route.Regex)
+}
@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertNginx(t *testing.T) {
+	t.Parallel()
+
+	res, err := Convert(FormatNginx, []byte(`
+http {
+	upstream app {
+		server 10.0.0.1:8080 weight=2;
+		server 10.0.0.2:8080;
+	}
+	server {
+		listen 443 ssl;
+		server_name app.example.com;
+		auth_request /oauth2/auth;
+		proxy_set_header Host $host;
+
+		location / {
+			proxy_pass http://app;
+		}
+		location /api/ {
+			proxy_pass http://api.internal:9000/v1/;
+			allow 10.0.0.0/8;
+			deny all;
+		}
+		location = /healthz {
+			auth_request off;
+			proxy_pass http://app;
+		}
+		location /static/ {
+			root /var/www; # not proxied
+		}
+	}
+}
+`))
+	require.NoError(t, err)
+	require.Len(t, res.Routes, 3)
+
+	assert.Equal(t, Route{
+		From:               "https://app.example.com",
+		To:                 []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"},
+		PreserveHostHeader: true,
+		Policy:             authenticatedUserPolicy(),
+	}, res.Routes[0])
+	assert.Equal(t, Route{
+		From:               "https://app.example.com",
+		To:                 []string{"http://api.internal:9000"},
+		Prefix:             "/api/",
+		PrefixRewrite:      "/v1/",
+		PreserveHostHeader: true,
+		IPAllowlist:        []string{"10.0.0.0/8"},
+		Policy:             authenticatedUserPolicy(),
+	}, res.Routes[1])
+	assert.Equal(t, "/healthz", res.Routes[2].Path)
+	assert.True(t, res.Routes[2].AllowPublicUnauthenticatedAccess)
+	assert.Len(t, res.Warnings, 2, "public route and skipped location")
+
+	_, err = Convert(FormatNginx, []byte(`server { listen 80;`))
+	assert.Error(t, err)
+}
+
+func TestConvertOAuth2Proxy(t *testing.T) {
+	t.Parallel()
+
+	res, err := Convert(FormatOAuth2Proxy, []byte(`
+provider = "oidc"
+oidc_issuer_url = "https://idp.example.com"
+client_id = "CLIENT_ID"
+client_secret = "CLIENT_SECRET" # set from a secret
+redirect_url = "https://app.example.com/oauth2/callback"
+upstreams = [
+    "http://127.0.0.1:8080/",
+    "file:///var/www/static/#/static/",
+]
+email_domains = ["example.com", "example.org"]
+allowed_groups = ["admins"]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "oidc", res.IDPProvider)
+	assert.Equal(t, "https://idp.example.com", res.IDPProviderURL)
+	assert.Equal(t, "CLIENT_ID", res.IDPClientID)
+	assert.Equal(t, []Route{{
+		From:               "https://app.example.com",
+		To:                 []string{"http://127.0.0.1:8080"},
+		PreserveHostHeader: true,
+		Policy: []Rule{
+			{Allow: RuleBody{And: []Criterion{
+				{"domain": map[string]any{"is": "example.com"}},
+				{"groups": map[string]any{"has": "admins"}},
+			}}},
+			{Allow: RuleBody{And: []Criterion{
+				{"domain": map[string]any{"is": "example.org"}},
+				{"groups": map[string]any{"has": "admins"}},
+			}}},
+		},
+	}}, res.Routes)
+	assert.Len(t, res.Warnings, 2, "client secret and file upstream")
+}
+
+func TestConvertTraefik(t *testing.T) {
+	t.Parallel()
+
+	res, err := Convert(FormatTraefik, []byte(`
+http:
+  routers:
+    api:
+      rule: "Host(`+"`api.example.com`"+`) && PathPrefix(`+"`/v1`"+`)"
+      service: api@file
+      middlewares: [auth, strip]
+    web:
+      rule: "Host(`+"`www.example.com`, `example.com`"+`)"
+      service: web
+    other:
+      rule: "Host(`+"`a.example.com`"+`) || Host(`+"`b.example.com`"+`)"
+      service: web
+  services:
+    api:
+      loadBalancer:
+        passHostHeader: false
+        servers:
+          - url: http://10.0.0.1:8080
+    web:
+      loadBalancer:
+        servers:
+          - url: http://10.0.0.2:8080
+  middlewares:
+    auth:
+      forwardAuth:
+        address: http://oauth2-proxy:4180
+    strip:
+      stripPrefix:
+        prefixes: ["/v1"]
+`))
+	require.NoError(t, err)
+	require.Len(t, res.Routes, 3)
+	assert.Equal(t, Route{
+		From:          "https://api.example.com",
+		To:            []string{"http://10.0.0.1:8080"},
+		Prefix:        "/v1",
+		PrefixRewrite: "/",
+		Policy:        authenticatedUserPolicy(),
+	}, res.Routes[0])
+	assert.Equal(t, "https://www.example.com", res.Routes[1].From)
+	assert.Equal(t, "https://example.com", res.Routes[2].From)
+	assert.True(t, res.Routes[2].PreserveHostHeader)
+	assert.True(t, res.Routes[2].AllowPublicUnauthenticatedAccess)
+	assert.Len(t, res.Warnings, 3, "skipped router and two public routes")
+}
+
+func TestResultWrite(t *testing.T) {
+	t.Parallel()
+
+	res := &Result{
+		Routes: []Route{{
+			From:   "https://app.example.com",
+			To:     []string{"http://app:8080"},
+			Policy: authenticatedUserPolicy(),
+		}},
+		Warnings: []string{"check this"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, res.Write(&buf))
+	assert.Equal(t, `# WARNING: check this
+routes:
+  - from: https://app.example.com
+    to:
+      - http://app:8080
+    policy:
+      - allow:
+          or:
+            - authenticated_user: true
+`, buf.String())
+
+	_, err := Convert("haproxy", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,324 @@
+package migrate
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+type nginxDirective struct {
+	name  string
+	args  []string
+	block []*nginxDirective // nil for simple directives
+}
+
+// findNginxDirective returns the last directive with the given name, nginx uses the last one
+// when a directive is repeated.
+func findNginxDirective(directives []*nginxDirective, name string) *nginxDirective {
+	var found *nginxDirective
+	for _, d := range directives {
+		if d.name == name {
+			found = d
+		}
+	}
+	return found
+}
+
+func convertNginx(data []byte) (*Result, error) {
+	directives, err := parseNginx(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(Result)
+	upstreams := make(map[string][]string)
+	var servers []*nginxDirective
+	var walk func([]*nginxDirective)
+	walk = func(directives []*nginxDirective) {
+		for _, d := range directives {
+			switch {
+			case d.name == "upstream" && d.block != nil && len(d.args) == 1:
+				for _, s := range d.block {
+					if s.name == "server" && len(s.args) > 0 {
+						upstreams[d.args[0]] = append(upstreams[d.args[0]], s.args[0])
+					}
+				}
+			case d.name == "server" && d.block != nil:
+				servers = append(servers, d)
+			case d.name == "include":
+				res.warnf("include %s was not followed, convert the included file separately", strings.Join(d.args, " "))
+			case d.block != nil:
+				walk(d.block)
+			}
+		}
+	}
+	walk(directives)
+
+	for _, server := range servers {
+		convertNginxServer(res, upstreams, server)
+	}
+	return res, nil
+}
+
+func convertNginxServer(res *Result, upstreams map[string][]string, server *nginxDirective) {
+	var hosts []string
+	for _, d := range server.block {
+		if d.name != "server_name" {
+			continue
+		}
+		for _, name := range d.args {
+			switch {
+			case name == "_" || name == "":
+			case strings.HasPrefix(name, "~"):
+				res.warnf("regular expression server_name %s is not supported", name)
+			case strings.HasPrefix(name, "."):
+				hosts = append(hosts, name[1:], "*"+name)
+			default:
+				hosts = append(hosts, name)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		res.warnf("server without a server_name was skipped")
+		return
+	}
+
+	for _, location := range server.block {
+		if location.name != "location" || location.block == nil {
+			continue
+		}
+		for _, host := range hosts {
+			route, ok := convertNginxLocation(res, upstreams, server, location, host)
+			if ok {
+				res.Routes = append(res.Routes, route)
+			}
+		}
+	}
+}
+
+func convertNginxLocation(
+	res *Result,
+	upstreams map[string][]string,
+	server, location *nginxDirective,
+	host string,
+) (route Route, ok bool) {
+	route.From = "https://" + host
+
+	isPrefix := false
+	switch {
+	case len(location.args) == 1 && strings.HasPrefix(location.args[0], "@"):
+		// named locations are only used for internal redirects
+		return route, false
+	case len(location.args) == 1:
+		route.Prefix, isPrefix = location.args[0], true
+	case len(location.args) == 2 && location.args[0] == "=":
+		route.Path = location.args[1]
+	case len(location.args) == 2 && location.args[0] == "^~":
+		route.Prefix, isPrefix = location.args[1], true
+	case len(location.args) == 2 && location.args[0] == "~":
+		route.Regex = location.args[1]
+	case len(location.args) == 2 && location.args[0] == "~*":
+		route.Regex = "(?i)" + location.args[1]
+	default:
+		res.warnf("%s location %s is not supported", host, strings.Join(location.args, " "))
+		return route, false
+	}
+	if route.Prefix == "/" {
+		route.Prefix = ""
+	}
+	name := route.From + route.Prefix + route.Path + route.Regex
+
+	proxyPass := findNginxDirective(location.block, "proxy_pass")
+	if proxyPass == nil || len(proxyPass.args) != 1 {
+		res.warnf("%s has no proxy_pass and was skipped", name)
+		return route, false
+	}
+	if strings.Contains(proxyPass.args[0], "$") {
+		res.warnf("%s proxy_pass %s uses variables and was skipped", name, proxyPass.args[0])
+		return route, false
+	}
+	u, err := url.Parse(proxyPass.args[0])
+	if err != nil || u.Host == "" {
+		res.warnf("%s has an invalid proxy_pass %s and was skipped", name, proxyPass.args[0])
+		return route, false
+	}
+	if servers, ok := upstreams[u.Host]; ok {
+		for _, s := range servers {
+			route.To = append(route.To, u.Scheme+"://"+s)
+		}
+	} else {
+		route.To = []string{u.Scheme + "://" + u.Host}
+	}
+	// when proxy_pass has a uri, the part of the path matching the location is replaced with it
+	if u.Path != "" && isPrefix {
+		route.PrefixRewrite = u.Path
+	}
+
+	for _, block := range [][]*nginxDirective{server.block, location.block} {
+		for _, d := range block {
+			if d.name == "proxy_set_header" && len(d.args) == 2 && strings.EqualFold(d.args[0], "Host") {
+				route.PreserveHostHeader = d.args[1] == "$host" || d.args[1] == "$http_host"
+			}
+		}
+	}
+
+	// access directives of a location replace the ones of the server
+	access := location.block
+	if !hasNginxDirective(access, "allow", "deny") {
+		access = server.block
+	}
+	convertNginxAccess(res, &route, name, access)
+
+	res.setAuthentication(&route, isNginxAuthenticated(location.block, server.block))
+	return route, true
+}
+
+func hasNginxDirective(directives []*nginxDirective, names ...string) bool {
+	for _, d := range directives {
+		for _, name := range names {
+			if d.name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func convertNginxAccess(res *Result, route *Route, name string, directives []*nginxDirective) {
+	var allow []string
+	for _, d := range directives {
+		if len(d.args) != 1 {
+			continue
+		}
+		switch {
+		case d.name == "allow" && d.args[0] != "all":
+			allow = append(allow, d.args[0])
+		case d.name == "deny" && d.args[0] == "all":
+			// the rules are checked in order, so only the allows before the deny all matter
+			route.IPAllowlist = append([]string{}, allow...)
+			return
+		case d.name == "deny":
+			if len(allow) > 0 {
+				res.warnf("%s allow and deny rules are interleaved, check the ip_denylist", name)
+			}
+			route.IPDenylist = append(route.IPDenylist, d.args[0])
+		}
+	}
+}
+
+// isNginxAuthenticated returns whether the location requires authentication with auth_request
+// or auth_basic. The directives of the location take precedence over the ones of the server.
+func isNginxAuthenticated(location, server []*nginxDirective) bool {
+	for _, block := range [][]*nginxDirective{location, server} {
+		for _, name := range []string{"auth_request", "auth_basic"} {
+			if d := findNginxDirective(block, name); d != nil {
+				return len(d.args) > 0 && d.args[0] != "off"
+			}
+		}
+	}
+	return false
+}
+
+type nginxToken struct {
+	value string
+	// special is set for the unquoted {, } and ; tokens
+	special bool
+}
+
+func tokenizeNginx(data string) ([]nginxToken, error) {
+	var tokens []nginxToken
+	rs := []rune(data)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '{' || r == '}' || r == ';':
+			tokens = append(tokens, nginxToken{value: string(r), special: true})
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			i++
+			for ; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				sb.WriteRune(rs[i])
+			}
+			if i >= len(rs) {
+				return nil, fmt.Errorf("migrate: unterminated string in nginx config")
+			}
+			tokens = append(tokens, nginxToken{value: sb.String()})
+			i++
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune("{};", rs[i]) {
+				i++
+			}
+			tokens = append(tokens, nginxToken{value: string(rs[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+func parseNginx(data string) ([]*nginxDirective, error) {
+	tokens, err := tokenizeNginx(data)
+	if err != nil {
+		return nil, err
+	}
+	directives, rest, err := parseNginxBlock(tokens, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("migrate: unexpected } in nginx config")
+	}
+	return directives, nil
+}
+
+func parseNginxBlock(tokens []nginxToken, nested bool) (directives []*nginxDirective, rest []nginxToken, err error) {
+	directives = []*nginxDirective{}
+	for {
+		if len(tokens) == 0 {
+			if nested {
+				return nil, nil, fmt.Errorf("migrate: unexpected end of nginx config, missing }")
+			}
+			return directives, nil, nil
+		}
+		if tokens[0].special {
+			if tokens[0].value == "}" {
+				if !nested {
+					return directives, tokens, nil
+				}
+				return directives, tokens[1:], nil
+			}
+			return nil, nil, fmt.Errorf("migrate: unexpected %s in nginx config", tokens[0].value)
+		}
+
+		d := &nginxDirective{name: tokens[0].value}
+		tokens = tokens[1:]
+		for len(tokens) > 0 && !tokens[0].special {
+			d.args = append(d.args, tokens[0].value)
+			tokens = tokens[1:]
+		}
+		switch {
+		case len(tokens) == 0:
+			return nil, nil, fmt.Errorf("migrate: unexpected end of nginx config after %s", d.name)
+		case tokens[0].value == ";":
+			tokens = tokens[1:]
+		case tokens[0].value == "{":
+			d.block, tokens, err = parseNginxBlock(tokens[1:], true)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("migrate: unexpected } after %s in nginx config", d.name)
+		}
+		directives = append(directives, d)
+	}
+}
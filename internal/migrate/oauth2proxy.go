@@ -0,0 +1,195 @@
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// oauth2ProxyProviders maps oauth2-proxy providers to pomerium identity providers.
+var oauth2ProxyProviders = map[string]string{
+	"azure":         "azure",
+	"github":        "github",
+	"gitlab":        "gitlab",
+	"google":        "google",
+	"keycloak-oidc": "oidc",
+	"oidc":          "oidc",
+}
+
+// oauth2ProxyUnsupported are the options which can't be converted.
+var oauth2ProxyUnsupported = []string{
+	"authenticated_emails_file",
+	"github_org",
+	"github_team",
+	"htpasswd_file",
+	"skip_auth_regex",
+	"skip_auth_routes",
+	"trusted_ips",
+}
+
+func convertOAuth2Proxy(data []byte) (*Result, error) {
+	cfg, err := parseOAuth2ProxyConfig(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(Result)
+	if provider := cfg.value("provider"); provider != "" {
+		if idp, ok := oauth2ProxyProviders[provider]; ok {
+			res.IDPProvider = idp
+		} else {
+			res.IDPProvider = "oidc"
+			res.warnf("provider %s has no equivalent, set idp_provider", provider)
+		}
+	}
+	res.IDPProviderURL = cfg.value("oidc_issuer_url")
+	res.IDPClientID = cfg.value("client_id")
+	if scope := cfg.value("scope"); scope != "" {
+		res.IDPScopes = strings.Fields(scope)
+	}
+	if cfg.value("client_secret") != "" || cfg.value("client_secret_file") != "" {
+		res.warnf("client_secret was not converted, set idp_client_secret")
+	}
+	for _, name := range oauth2ProxyUnsupported {
+		if _, ok := cfg[name]; ok {
+			res.warnf("%s was not converted, add the equivalent to the route policies", name)
+		}
+	}
+
+	from := "https://oauth2-proxy.example.com"
+	if redirectURL, err := url.Parse(cfg.value("redirect_url")); err == nil && redirectURL.Host != "" {
+		from = "https://" + redirectURL.Host
+	} else {
+		res.warnf("redirect_url is not set, replace %s with the external url", from)
+	}
+
+	policy := oauth2ProxyPolicy(cfg.values("email_domains"), cfg.values("allowed_groups"))
+	for _, upstream := range cfg.values("upstreams") {
+		u, err := url.Parse(upstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			res.warnf("upstream %s is not an http upstream and was skipped", upstream)
+			continue
+		}
+		route := Route{
+			From:               from,
+			To:                 []string{u.Scheme + "://" + u.Host},
+			PreserveHostHeader: cfg.value("pass_host_header") != "false",
+			Policy:             policy,
+		}
+		if u.Path != "" && u.Path != "/" {
+			route.Prefix = u.Path
+		}
+		res.Routes = append(res.Routes, route)
+	}
+	return res, nil
+}
+
+// oauth2ProxyPolicy returns the policy requiring one of the email domains, if any, and one of the
+// groups, if any.
+func oauth2ProxyPolicy(emailDomains, groups []string) []Rule {
+	var domains []Criterion
+	for _, domain := range emailDomains {
+		if domain == "*" {
+			domains = nil
+			break
+		}
+		domains = append(domains, Criterion{"domain": map[string]any{"is": domain}})
+	}
+	var groupCriteria []Criterion
+	for _, group := range groups {
+		groupCriteria = append(groupCriteria, Criterion{"groups": map[string]any{"has": group}})
+	}
+
+	switch {
+	case len(domains) == 0 && len(groupCriteria) == 0:
+		return authenticatedUserPolicy()
+	case len(groupCriteria) == 0:
+		return []Rule{{Allow: RuleBody{Or: domains}}}
+	case len(domains) == 0:
+		return []Rule{{Allow: RuleBody{Or: groupCriteria}}}
+	}
+
+	// rules are combined with or, so one rule per combination requires a domain and a group
+	var rules []Rule
+	for _, domain := range domains {
+		for _, group := range groupCriteria {
+			rules = append(rules, Rule{Allow: RuleBody{And: []Criterion{domain, group}}})
+		}
+	}
+	return rules
+}
+
+type oauth2ProxyConfig map[string][]string
+
+func (cfg oauth2ProxyConfig) value(key string) string {
+	if vs := cfg[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func (cfg oauth2ProxyConfig) values(key string) []string {
+	return cfg[key]
+}
+
+// parseOAuth2ProxyConfig parses the subset of toml used by oauth2-proxy config files: key value
+// pairs with strings, booleans, numbers and arrays of strings.
+func parseOAuth2ProxyConfig(data string) (oauth2ProxyConfig, error) {
+	cfg := make(oauth2ProxyConfig)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := stripTOMLComment(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "[") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("migrate: invalid oauth2-proxy config on line %d", lineNumber)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		// arrays can span multiple lines
+		if strings.HasPrefix(value, "[") {
+			for !strings.HasSuffix(value, "]") && scanner.Scan() {
+				lineNumber++
+				value += " " + stripTOMLComment(scanner.Text())
+			}
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("migrate: unterminated array for %s in oauth2-proxy config", key)
+			}
+			for _, element := range strings.Split(value[1:len(value)-1], ",") {
+				if element = strings.TrimSpace(element); element != "" {
+					cfg[key] = append(cfg[key], unquoteTOML(element))
+				}
+			}
+			continue
+		}
+		cfg[key] = []string{unquoteTOML(value)}
+	}
+	return cfg, scanner.Err()
+}
+
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+func unquoteTOML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
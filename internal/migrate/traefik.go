@@ -0,0 +1,173 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	traefikMatcherRE = regexp.MustCompile("^([A-Za-z]+)\\((.*)\\)$")
+	traefikArgRE     = regexp.MustCompile("`([^`]*)`|\"([^\"]*)\"")
+)
+
+type traefikConfig struct {
+	HTTP struct {
+		Routers     map[string]traefikRouter     `yaml:"routers"`
+		Services    map[string]traefikService    `yaml:"services"`
+		Middlewares map[string]traefikMiddleware `yaml:"middlewares"`
+	} `yaml:"http"`
+}
+
+type traefikRouter struct {
+	Rule        string   `yaml:"rule"`
+	Service     string   `yaml:"service"`
+	Middlewares []string `yaml:"middlewares"`
+}
+
+type traefikService struct {
+	LoadBalancer *struct {
+		Servers []struct {
+			URL string `yaml:"url"`
+		} `yaml:"servers"`
+		PassHostHeader *bool `yaml:"passHostHeader"`
+	} `yaml:"loadBalancer"`
+}
+
+type traefikMiddleware struct {
+	ForwardAuth *struct{} `yaml:"forwardAuth"`
+	BasicAuth   *struct{} `yaml:"basicAuth"`
+	DigestAuth  *struct{} `yaml:"digestAuth"`
+	StripPrefix *struct {
+		Prefixes []string `yaml:"prefixes"`
+	} `yaml:"stripPrefix"`
+	IPWhiteList *traefikIPAllowList `yaml:"ipWhiteList"`
+	IPAllowList *traefikIPAllowList `yaml:"ipAllowList"`
+}
+
+type traefikIPAllowList struct {
+	SourceRange []string `yaml:"sourceRange"`
+}
+
+func convertTraefik(data []byte) (*Result, error) {
+	var cfg traefikConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("migrate: invalid traefik dynamic config: %w", err)
+	}
+
+	names := make([]string, 0, len(cfg.HTTP.Routers))
+	for name := range cfg.HTTP.Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := new(Result)
+	for _, name := range names {
+		convertTraefikRouter(res, &cfg, name, cfg.HTTP.Routers[name])
+	}
+	return res, nil
+}
+
+func convertTraefikRouter(res *Result, cfg *traefikConfig, name string, router traefikRouter) {
+	hosts, prefix, path, err := parseTraefikRule(router.Rule)
+	if err != nil {
+		res.warnf("router %s was skipped: %v", name, err)
+		return
+	}
+
+	service, ok := cfg.HTTP.Services[trimTraefikProvider(router.Service)]
+	if !ok || service.LoadBalancer == nil || len(service.LoadBalancer.Servers) == 0 {
+		res.warnf("router %s was skipped: service %s is not a load balancer", name, router.Service)
+		return
+	}
+	var to []string
+	for _, server := range service.LoadBalancer.Servers {
+		to = append(to, server.URL)
+	}
+
+	authenticated := false
+	template := Route{
+		To:                 to,
+		Prefix:             prefix,
+		Path:               path,
+		PreserveHostHeader: service.LoadBalancer.PassHostHeader == nil || *service.LoadBalancer.PassHostHeader,
+	}
+	for _, middlewareName := range router.Middlewares {
+		middleware, ok := cfg.HTTP.Middlewares[trimTraefikProvider(middlewareName)]
+		switch {
+		case !ok:
+			res.warnf("router %s middleware %s was not found", name, middlewareName)
+		case middleware.ForwardAuth != nil || middleware.BasicAuth != nil || middleware.DigestAuth != nil:
+			authenticated = true
+		case middleware.StripPrefix != nil:
+			for _, p := range middleware.StripPrefix.Prefixes {
+				if prefix != "" && strings.TrimSuffix(p, "/") == strings.TrimSuffix(prefix, "/") {
+					template.PrefixRewrite = "/"
+				}
+			}
+			if template.PrefixRewrite == "" {
+				res.warnf("router %s middleware %s doesn't strip the router prefix and was not converted", name, middlewareName)
+			}
+		case middleware.IPAllowList != nil:
+			template.IPAllowlist = middleware.IPAllowList.SourceRange
+		case middleware.IPWhiteList != nil:
+			template.IPAllowlist = middleware.IPWhiteList.SourceRange
+		default:
+			res.warnf("router %s middleware %s was not converted", name, middlewareName)
+		}
+	}
+
+	for _, host := range hosts {
+		route := template
+		route.From = "https://" + host
+		res.setAuthentication(&route, authenticated)
+		res.Routes = append(res.Routes, route)
+	}
+}
+
+// parseTraefikRule parses a router rule made of Host, PathPrefix and Path matchers combined
+// with &&.
+func parseTraefikRule(rule string) (hosts []string, prefix, path string, err error) {
+	if strings.Contains(rule, "||") || strings.Contains(rule, "!") {
+		return nil, "", "", fmt.Errorf("rule %s uses || or !, which are not supported", rule)
+	}
+	for _, matcher := range strings.Split(rule, "&&") {
+		m := traefikMatcherRE.FindStringSubmatch(strings.TrimSpace(matcher))
+		if m == nil {
+			return nil, "", "", fmt.Errorf("invalid rule %s", rule)
+		}
+		var args []string
+		for _, arg := range traefikArgRE.FindAllStringSubmatch(m[2], -1) {
+			args = append(args, arg[1]+arg[2])
+		}
+
+		switch {
+		case m[1] == "Host":
+			hosts = append(hosts, args...)
+		case (m[1] == "PathPrefix" || m[1] == "Path") && len(args) != 1:
+			return nil, "", "", fmt.Errorf("rule %s matches multiple paths, which is not supported", rule)
+		case m[1] == "PathPrefix":
+			prefix = args[0]
+		case m[1] == "Path":
+			path = args[0]
+		default:
+			return nil, "", "", fmt.Errorf("rule %s uses %s, which is not supported", rule, m[1])
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, "", "", fmt.Errorf("rule %s doesn't match a host", rule)
+	}
+	if prefix == "/" {
+		prefix = ""
+	}
+	return hosts, prefix, path, nil
+}
+
+// trimTraefikProvider removes the provider namespace from a reference, e.g. auth@file.
+func trimTraefikProvider(name string) string {
+	name, _, _ = strings.Cut(name, "@")
+	return name
+}
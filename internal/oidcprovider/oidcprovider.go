@@ -0,0 +1,326 @@
+// Package oidcprovider implements the built-in OpenID Connect provider, which issues tokens to
+// downstream applications for existing Pomerium sessions.
+package oidcprovider
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// ClientRecordType is the databroker record type of clients registered through the databroker.
+// The record id is the client id.
+const ClientRecordType = "pomerium.io/OIDCProviderClient"
+
+// Path is the path of the OIDC provider endpoints on the authenticate service. The issuer is the
+// authenticate URL with this path.
+const Path = "/.pomerium/oidc"
+
+// Supported scopes.
+const (
+	ScopeOpenID  = "openid"
+	ScopeEmail   = "email"
+	ScopeProfile = "profile"
+	ScopeGroups  = "groups"
+)
+
+// CodeChallengeMethodS256 is the only supported PKCE code challenge method.
+const CodeChallengeMethodS256 = "S256"
+
+const (
+	codeLifetime    = time.Minute
+	accessTokenType = "at+jwt"
+)
+
+var codeAdditionalData = []byte("oidc-provider-code")
+
+// scopeClaims are the claims of the user's identity provider included for each scope.
+var scopeClaims = map[string][]string{
+	ScopeEmail: {"email", "email_verified"},
+	ScopeProfile: {
+		"name", "given_name", "family_name", "middle_name", "nickname", "preferred_username",
+		"picture", "locale", "zoneinfo", "updated_at",
+	},
+	ScopeGroups: {"groups"},
+}
+
+// ErrUnknownClient indicates that a client isn't registered.
+var ErrUnknownClient = errors.New("oidcprovider: unknown client")
+
+// GetClient returns the client with the given id from the settings or, if it isn't configured
+// there, from the databroker.
+func GetClient(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	settings *config.OIDCProviderSettings,
+	id string,
+) (*config.OIDCProviderClient, error) {
+	if c := settings.GetClient(id); c != nil {
+		return c, nil
+	}
+	if client == nil || id == "" {
+		return nil, ErrUnknownClient
+	}
+
+	c, err := databroker.GetViaJSON[config.OIDCProviderClient](ctx, client, ClientRecordType, id)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrUnknownClient
+	} else if err != nil {
+		return nil, fmt.Errorf("oidcprovider: error getting client: %w", err)
+	}
+	c.ID = id
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid client: %w", err)
+	}
+	return c, nil
+}
+
+// A Code is the authorization code returned to a client. It's encrypted, so redeeming it doesn't
+// require any server side state, and it expires after a minute.
+type Code struct {
+	ClientID      string         `json:"client_id"`
+	RedirectURI   string         `json:"redirect_uri"`
+	Scopes        []string       `json:"scopes"`
+	Nonce         string         `json:"nonce,omitempty"`
+	CodeChallenge string         `json:"code_challenge,omitempty"`
+	Subject       string         `json:"sub"`
+	Claims        map[string]any `json:"claims,omitempty"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+}
+
+// NewCode creates a new code for the user's subject and identity provider claims. Only the claims
+// of the requested scopes are kept.
+func NewCode(clientID, redirectURI string, scopes []string, nonce, codeChallenge, subject string, claims map[string]any, now time.Time) *Code {
+	return &Code{
+		ClientID:      clientID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		Nonce:         nonce,
+		CodeChallenge: codeChallenge,
+		Subject:       subject,
+		Claims:        ScopedClaims(claims, scopes),
+		ExpiresAt:     now.Add(codeLifetime),
+	}
+}
+
+// EncryptCode encrypts a code.
+func EncryptCode(aead cipher.AEAD, code *Code) (string, error) {
+	bs, err := json.Marshal(code)
+	if err != nil {
+		return "", fmt.Errorf("oidcprovider: error marshaling code: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(cryptutil.Encrypt(aead, bs, codeAdditionalData)), nil
+}
+
+// DecryptCode decrypts a code returned by EncryptCode, and checks that it hasn't expired.
+func DecryptCode(aead cipher.AEAD, rawCode string, now time.Time) (*Code, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(rawCode)
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid code: %w", err)
+	}
+	bs, err = cryptutil.Decrypt(aead, bs, codeAdditionalData)
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid code: %w", err)
+	}
+	var code Code
+	if err := json.Unmarshal(bs, &code); err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid code: %w", err)
+	}
+	if now.After(code.ExpiresAt) {
+		return nil, fmt.Errorf("oidcprovider: code expired")
+	}
+	return &code, nil
+}
+
+// VerifyCodeVerifier checks the PKCE code verifier against the code challenge of the code.
+//
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.6
+func (code *Code) VerifyCodeVerifier(verifier string) error {
+	if code.CodeChallenge == "" {
+		if verifier != "" {
+			return fmt.Errorf("oidcprovider: unexpected code verifier")
+		}
+		return nil
+	}
+	h := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(h[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+		return fmt.Errorf("oidcprovider: invalid code verifier")
+	}
+	return nil
+}
+
+// ScopedClaims returns the claims included for the given scopes.
+func ScopedClaims(claims map[string]any, scopes []string) map[string]any {
+	scoped := map[string]any{}
+	for _, scope := range scopes {
+		for _, name := range scopeClaims[scope] {
+			if v, ok := claims[name]; ok {
+				scoped[name] = v
+			}
+		}
+	}
+	return scoped
+}
+
+// HasScope reports whether scope is one of the scopes.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedScopes returns the supported scopes.
+func SupportedScopes() []string {
+	return []string{ScopeOpenID, ScopeEmail, ScopeProfile, ScopeGroups}
+}
+
+// TokenResponse is the response of the token endpoint.
+//
+// https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// An Issuer signs the tokens issued to clients.
+type Issuer struct {
+	url       string
+	signer    jose.Signer
+	atSigner  jose.Signer
+	publicKey *jose.JSONWebKey
+	lifetime  time.Duration
+	now       func() time.Time
+}
+
+// NewIssuer creates a new Issuer for the issuer URL, which signs tokens with the PEM encoded
+// signing key.
+func NewIssuer(issuerURL string, signingKey []byte, lifetime time.Duration) (*Issuer, error) {
+	privateKey, err := cryptutil.PrivateJWKFromBytes(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid signing key: %w", err)
+	}
+	publicKey, err := cryptutil.PublicJWKFromBytes(signingKey)
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid signing key: %w", err)
+	}
+	key := jose.SigningKey{Algorithm: jose.SignatureAlgorithm(privateKey.Algorithm), Key: privateKey}
+	signer, err := jose.NewSigner(key, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: error creating signer: %w", err)
+	}
+	atSigner, err := jose.NewSigner(key, (&jose.SignerOptions{}).WithType(accessTokenType))
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: error creating signer: %w", err)
+	}
+	return &Issuer{
+		url:       issuerURL,
+		signer:    signer,
+		atSigner:  atSigner,
+		publicKey: publicKey,
+		lifetime:  lifetime,
+		now:       time.Now,
+	}, nil
+}
+
+// URL returns the issuer URL.
+func (i *Issuer) URL() string {
+	return i.url
+}
+
+// SigningAlgorithm returns the algorithm of the token signatures.
+func (i *Issuer) SigningAlgorithm() string {
+	return i.publicKey.Algorithm
+}
+
+// IssueTokens issues the ID and access tokens for a redeemed code.
+func (i *Issuer) IssueTokens(code *Code) (*TokenResponse, error) {
+	now := i.now()
+	registered := jwt.Claims{
+		Issuer:   i.url,
+		Subject:  code.Subject,
+		Audience: jwt.Audience{code.ClientID},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(i.lifetime)),
+	}
+
+	idTokenClaims := map[string]any{"azp": code.ClientID}
+	if code.Nonce != "" {
+		idTokenClaims["nonce"] = code.Nonce
+	}
+	idToken, err := jwt.Signed(i.signer).Claims(registered).Claims(code.Claims).Claims(idTokenClaims).CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: error signing id token: %w", err)
+	}
+
+	scope := strings.Join(code.Scopes, " ")
+	accessTokenClaims := map[string]any{
+		"client_id": code.ClientID,
+		"scope":     scope,
+	}
+	accessToken, err := jwt.Signed(i.atSigner).Claims(registered).Claims(code.Claims).Claims(accessTokenClaims).CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: error signing access token: %w", err)
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(i.lifetime.Seconds()),
+		IDToken:     idToken,
+		Scope:       scope,
+	}, nil
+}
+
+// VerifyAccessToken verifies an access token issued by IssueTokens and returns the user info
+// claims it carries.
+func (i *Issuer) VerifyAccessToken(rawToken string) (map[string]any, error) {
+	token, err := jwt.ParseSigned(rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid access token: %w", err)
+	}
+	if len(token.Headers) != 1 || token.Headers[0].ExtraHeaders[jose.HeaderType] != accessTokenType {
+		return nil, fmt.Errorf("oidcprovider: invalid access token type")
+	}
+
+	var registered jwt.Claims
+	var claims map[string]any
+	if err := token.Claims(i.publicKey, &registered, &claims); err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid access token: %w", err)
+	}
+	if err := registered.ValidateWithLeeway(jwt.Expected{Issuer: i.url, Time: i.now()}, 0); err != nil {
+		return nil, fmt.Errorf("oidcprovider: invalid access token: %w", err)
+	}
+
+	userInfo := map[string]any{"sub": registered.Subject}
+	for _, scopeClaimNames := range scopeClaims {
+		for _, name := range scopeClaimNames {
+			if v, ok := claims[name]; ok {
+				userInfo[name] = v
+			}
+		}
+	}
+	return userInfo, nil
+}
@@ -0,0 +1,144 @@
+package oidcprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+type mockDataBrokerClient struct {
+	databroker.DataBrokerServiceClient
+	records map[string]*structpb.Struct
+}
+
+func (m *mockDataBrokerClient) Get(_ context.Context, req *databroker.GetRequest, _ ...grpc.CallOption) (*databroker.GetResponse, error) {
+	data, ok := m.records[req.GetType()+"/"+req.GetId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &databroker.GetResponse{Record: &databroker.Record{
+		Type: req.GetType(),
+		Id:   req.GetId(),
+		Data: protoutil.NewAny(data),
+	}}, nil
+}
+
+func TestGetClient(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	settings := &config.OIDCProviderSettings{Clients: []config.OIDCProviderClient{
+		{ID: "configured", Secret: "secret", RedirectURIs: []string{"https://a.example.com/callback"}},
+	}}
+	registered, err := structpb.NewStruct(map[string]any{
+		"client_secret": "other-secret",
+		"redirect_uris": []any{"https://b.example.com/callback"},
+	})
+	require.NoError(t, err)
+	client := &mockDataBrokerClient{records: map[string]*structpb.Struct{
+		ClientRecordType + "/registered": registered,
+	}}
+
+	c, err := GetClient(ctx, client, settings, "configured")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "secret", c.Secret)
+	}
+
+	c, err = GetClient(ctx, client, settings, "registered")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "registered", c.ID)
+		assert.True(t, c.CheckSecret("other-secret"))
+		assert.True(t, c.HasRedirectURI("https://b.example.com/callback"))
+	}
+
+	_, err = GetClient(ctx, client, settings, "unknown")
+	assert.ErrorIs(t, err, ErrUnknownClient)
+}
+
+func TestCode(t *testing.T) {
+	t.Parallel()
+
+	aead, err := cryptutil.NewAEADCipher(cryptutil.NewKey())
+	require.NoError(t, err)
+
+	now := time.Now()
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	h := sha256.Sum256([]byte(verifier))
+	code := NewCode("app", "https://app.example.com/callback", []string{ScopeOpenID, ScopeEmail}, "NONCE",
+		base64.RawURLEncoding.EncodeToString(h[:]), "user-1",
+		map[string]any{"email": "user@example.com", "name": "User", "groups": []any{"admins"}}, now)
+	assert.Equal(t, map[string]any{"email": "user@example.com"}, code.Claims, "only claims of the requested scopes should be kept")
+
+	rawCode, err := EncryptCode(aead, code)
+	require.NoError(t, err)
+
+	decrypted, err := DecryptCode(aead, rawCode, now)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "user-1", decrypted.Subject)
+		assert.NoError(t, decrypted.VerifyCodeVerifier(verifier))
+		assert.Error(t, decrypted.VerifyCodeVerifier("wrong"))
+	}
+
+	_, err = DecryptCode(aead, rawCode, now.Add(2*time.Minute))
+	assert.Error(t, err, "expired codes should be rejected")
+	_, err = DecryptCode(aead, rawCode[:len(rawCode)-2], now)
+	assert.Error(t, err, "modified codes should be rejected")
+}
+
+func TestIssuer(t *testing.T) {
+	t.Parallel()
+
+	key, err := cryptutil.NewSigningKey()
+	require.NoError(t, err)
+	signingKey, err := cryptutil.EncodePrivateKey(key)
+	require.NoError(t, err)
+
+	issuer, err := NewIssuer("https://authenticate.example.com/.pomerium/oidc", signingKey, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", issuer.SigningAlgorithm())
+
+	code := NewCode("app", "https://app.example.com/callback", []string{ScopeOpenID, ScopeEmail}, "NONCE", "", "user-1",
+		map[string]any{"email": "user@example.com"}, time.Now())
+	res, err := issuer.IssueTokens(code)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", res.TokenType)
+	assert.Equal(t, int64(3600), res.ExpiresIn)
+	assert.Equal(t, "openid email", res.Scope)
+
+	idToken, err := jwt.ParseSigned(res.IDToken)
+	require.NoError(t, err)
+	var idTokenClaims map[string]any
+	require.NoError(t, idToken.Claims(&key.PublicKey, &idTokenClaims))
+	assert.Equal(t, "https://authenticate.example.com/.pomerium/oidc", idTokenClaims["iss"])
+	assert.Equal(t, "user-1", idTokenClaims["sub"])
+	assert.Equal(t, "app", idTokenClaims["aud"])
+	assert.Equal(t, "NONCE", idTokenClaims["nonce"])
+	assert.Equal(t, "user@example.com", idTokenClaims["email"])
+
+	userInfo, err := issuer.VerifyAccessToken(res.AccessToken)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]any{"sub": "user-1", "email": "user@example.com"}, userInfo)
+	}
+
+	_, err = issuer.VerifyAccessToken(res.IDToken)
+	assert.Error(t, err, "id tokens should not be accepted as access tokens")
+
+	issuer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = issuer.VerifyAccessToken(res.AccessToken)
+	assert.Error(t, err, "expired access tokens should be rejected")
+}
@@ -38,7 +38,7 @@ func (r *Reporter) OnConfigChange(ctx context.Context, cfg *config.Config) {
 
 	services, err := getReportedServices(cfg)
 	if err != nil {
-		log.Warn(ctx).Err(err).Msg("metrics announce to service registry is disabled")
+		log.Warn(ctx).Err(err).Msg("announcing services to service registry is disabled")
 	}
 
 	sharedKey, err := cfg.Options.GetSharedKey()
@@ -66,18 +66,52 @@ func (r *Reporter) OnConfigChange(ctx context.Context, cfg *config.Config) {
 }
 
 func getReportedServices(cfg *config.Config) ([]*pb.Service, error) {
-	if cfg.Options.MetricsAddr == "" {
-		return nil, nil
+	var services []*pb.Service
+
+	if cfg.Options.MetricsAddr != "" {
+		mu, err := metricsURL(*cfg.Options)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, &pb.Service{Kind: pb.ServiceKind_PROMETHEUS_METRICS, Endpoint: mu.String()})
 	}
 
-	mu, err := metricsURL(*cfg.Options)
-	if err != nil {
-		return nil, err
+	if cfg.Options.PeerDiscovery {
+		pu, err := peerURL(*cfg.Options)
+		if err != nil {
+			return nil, err
+		}
+		if config.IsAuthorize(cfg.Options.Services) {
+			services = append(services, &pb.Service{Kind: pb.ServiceKind_AUTHORIZE, Endpoint: pu.String()})
+		}
+		if config.IsDataBroker(cfg.Options.Services) {
+			services = append(services, &pb.Service{Kind: pb.ServiceKind_DATABROKER, Endpoint: pu.String()})
+		}
 	}
 
-	return []*pb.Service{
-		{Kind: pb.ServiceKind_PROMETHEUS_METRICS, Endpoint: mu.String()},
-	}, nil
+	return services, nil
+}
+
+// peerURL returns the url other replicas use to reach the gRPC services of this instance.
+func peerURL(o config.Options) (*url.URL, error) {
+	addr := o.PeerAdvertiseAddress
+	if addr == "" {
+		_, port, err := net.SplitHostPort(o.GetGRPCAddr())
+		if err != nil {
+			return nil, fmt.Errorf("invalid grpc address %q: %w", o.GetGRPCAddr(), err)
+		}
+		host, err := getHostOrIP()
+		if err != nil {
+			return nil, fmt.Errorf("could not guess hostname: %w", err)
+		}
+		addr = net.JoinHostPort(host, port)
+	}
+
+	u := url.URL{Scheme: "https", Host: addr}
+	if o.GetGRPCInsecure() {
+		u.Scheme = "http"
+	}
+	return &u, nil
 }
 
 func metricsURL(o config.Options) (*url.URL, error) {
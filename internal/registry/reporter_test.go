@@ -30,3 +30,17 @@ func TestMetricsURL(t *testing.T) {
 		assert.Error(t, err, opt)
 	}
 }
+
+func TestPeerURL(t *testing.T) {
+	for opt, expect := range map[*config.Options]string{
+		{PeerAdvertiseAddress: "10.0.0.1:5443", Services: "authorize"}:                              "https://10.0.0.1:5443",
+		{PeerAdvertiseAddress: "10.0.0.1:5443", Services: "authorize", GRPCInsecure: boolPtr(true)}: "http://10.0.0.1:5443",
+	} {
+		u, err := peerURL(*opt)
+		if assert.NoError(t, err, opt) {
+			assert.Equal(t, expect, u.String())
+		}
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc"
+	pb "github.com/pomerium/pomerium/pkg/grpc/registry"
+)
+
+// A PeerSource is a config source which adds the authorize and databroker instances registered
+// in the service registry by other replicas to the authorize and databroker service urls. A
+// change is triggered any time the registered instances change.
+type PeerSource struct {
+	underlying             config.Source
+	outboundGRPCConnection *grpc.CachedOutboundGRPClientConn
+
+	// updateMu serializes updates so that changes are triggered in order
+	updateMu       sync.Mutex
+	mu             sync.Mutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+	underlyingCfg  *config.Config
+	computedConfig *config.Config
+	peers          []*pb.Service
+
+	config.ChangeDispatcher
+}
+
+// NewPeerSource creates a new PeerSource.
+func NewPeerSource(ctx context.Context, underlying config.Source) *PeerSource {
+	src := &PeerSource{
+		underlying:             underlying,
+		outboundGRPCConnection: new(grpc.CachedOutboundGRPClientConn),
+		ctx:                    ctx,
+		cancel:                 func() {},
+	}
+	underlying.OnConfigChange(ctx, func(ctx context.Context, cfg *config.Config) {
+		src.onUnderlyingConfigChange(ctx, cfg)
+	})
+	src.onUnderlyingConfigChange(ctx, underlying.GetConfig())
+	return src
+}
+
+// GetConfig gets the computed config.
+func (src *PeerSource) GetConfig() *config.Config {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	return src.computedConfig
+}
+
+func (src *PeerSource) onUnderlyingConfigChange(ctx context.Context, cfg *config.Config) {
+	if cfg == nil || cfg.Options == nil {
+		return
+	}
+
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	src.mu.Lock()
+	src.underlyingCfg = cfg
+	src.cancel()
+	src.cancel = func() {}
+	if cfg.Options.PeerDiscovery {
+		src.startWatchLocked(ctx, cfg)
+	} else {
+		src.peers = nil
+	}
+	computed := src.computeLocked()
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+func (src *PeerSource) startWatchLocked(ctx context.Context, cfg *config.Config) {
+	sharedKey, err := cfg.Options.GetSharedKey()
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("registry: decoding shared key")
+		return
+	}
+
+	registryConn, err := src.outboundGRPCConnection.Get(ctx, &grpc.OutboundOptions{
+		OutboundPort:   cfg.OutboundPort,
+		InstallationID: cfg.Options.InstallationID,
+		ServiceName:    cfg.Options.Services,
+		SignedJWTKey:   sharedKey,
+	})
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("registry: connecting to registry")
+		return
+	}
+
+	wctx, cancel := context.WithCancel(src.ctx)
+	src.cancel = cancel
+	go src.watch(wctx, pb.NewRegistryClient(registryConn))
+}
+
+func (src *PeerSource) watch(ctx context.Context, client pb.RegistryClient) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+
+	for {
+		err := src.watchOnce(ctx, client, bo)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn(ctx).Err(err).Msg("registry: error watching peers")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bo.NextBackOff()):
+		}
+	}
+}
+
+func (src *PeerSource) watchOnce(ctx context.Context, client pb.RegistryClient, bo backoff.BackOff) error {
+	stream, err := client.Watch(ctx, &pb.ListRequest{
+		Kinds: []pb.ServiceKind{pb.ServiceKind_AUTHORIZE, pb.ServiceKind_DATABROKER},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		list, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		bo.Reset()
+		src.onPeersChange(ctx, list.GetServices())
+	}
+}
+
+func (src *PeerSource) onPeersChange(ctx context.Context, peers []*pb.Service) {
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	// the registry doesn't guarantee an order
+	peers = append([]*pb.Service(nil), peers...)
+	sort.Slice(peers, func(i, j int) bool {
+		if peers[i].GetKind() != peers[j].GetKind() {
+			return peers[i].GetKind() < peers[j].GetKind()
+		}
+		return peers[i].GetEndpoint() < peers[j].GetEndpoint()
+	})
+
+	src.mu.Lock()
+	if ctx.Err() != nil || peersEqual(src.peers, peers) {
+		src.mu.Unlock()
+		return
+	}
+	src.peers = peers
+	log.Info(ctx).Int("peers", len(peers)).Msg("registry: peers updated")
+	computed := src.computeLocked()
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+func (src *PeerSource) computeLocked() *config.Config {
+	cfg := src.underlyingCfg.Clone()
+	addPeerURLs(cfg.Options, src.peers)
+	src.computedConfig = cfg
+	return cfg
+}
+
+// addPeerURLs adds the endpoints of the registered authorize and databroker instances to the
+// service urls.
+func addPeerURLs(options *config.Options, peers []*pb.Service) {
+	var authorizeURLs, dataBrokerURLs []string
+	for _, peer := range peers {
+		switch peer.GetKind() {
+		case pb.ServiceKind_AUTHORIZE:
+			authorizeURLs = append(authorizeURLs, peer.GetEndpoint())
+		case pb.ServiceKind_DATABROKER:
+			dataBrokerURLs = append(dataBrokerURLs, peer.GetEndpoint())
+		}
+	}
+	options.AuthorizeURLStrings = appendMissingURLs(options.AuthorizeURLStrings,
+		append([]string{options.AuthorizeURLString}, options.AuthorizeURLStrings...), authorizeURLs)
+	options.DataBrokerURLStrings = appendMissingURLs(options.DataBrokerURLStrings,
+		append([]string{options.DataBrokerURLString}, options.DataBrokerURLStrings...), dataBrokerURLs)
+}
+
+func appendMissingURLs(dst, existing, urls []string) []string {
+	// copy so the underlying config isn't modified
+	dst = append([]string(nil), dst...)
+	seen := make(map[string]struct{}, len(existing))
+	for _, u := range existing {
+		seen[u] = struct{}{}
+	}
+	for _, u := range urls {
+		if _, ok := seen[u]; ok {
+			continue
+		}
+		seen[u] = struct{}{}
+		dst = append(dst, u)
+	}
+	return dst
+}
+
+func peersEqual(a, b []*pb.Service) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].GetKind() != b[i].GetKind() || a[i].GetEndpoint() != b[i].GetEndpoint() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	pb "github.com/pomerium/pomerium/pkg/grpc/registry"
+)
+
+func TestAddPeerURLs(t *testing.T) {
+	t.Parallel()
+
+	underlying := []string{"https://authorize-2.example.com"}
+	options := &config.Options{
+		AuthorizeURLString:   "https://authorize-1.example.com",
+		AuthorizeURLStrings:  underlying,
+		DataBrokerURLString:  "https://databroker.example.com",
+		DataBrokerURLStrings: nil,
+	}
+	addPeerURLs(options, []*pb.Service{
+		{Kind: pb.ServiceKind_AUTHORIZE, Endpoint: "https://authorize-1.example.com"},
+		{Kind: pb.ServiceKind_AUTHORIZE, Endpoint: "https://10.0.0.1:5443"},
+		{Kind: pb.ServiceKind_DATABROKER, Endpoint: "https://10.0.0.2:5443"},
+		{Kind: pb.ServiceKind_PROMETHEUS_METRICS, Endpoint: "http://10.0.0.1:9090/metrics"},
+	})
+	assert.Equal(t, []string{"https://authorize-2.example.com", "https://10.0.0.1:5443"}, options.AuthorizeURLStrings)
+	assert.Equal(t, []string{"https://10.0.0.2:5443"}, options.DataBrokerURLStrings)
+	assert.Equal(t, []string{"https://authorize-2.example.com"}, underlying,
+		"should not modify the underlying urls")
+
+	options = &config.Options{}
+	addPeerURLs(options, nil)
+	assert.Nil(t, options.AuthorizeURLStrings)
+	assert.Nil(t, options.DataBrokerURLStrings)
+}
@@ -0,0 +1,160 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// A peerHandler applies the records of a peer region to the local databroker.
+type peerHandler struct {
+	cfg    *config
+	peer   string
+	client databroker.DataBrokerServiceClient
+
+	// the replication metadata of the peer, by record id
+	metadata map[string]*Metadata
+}
+
+// GetDataBrokerServiceClient returns the databroker client of the peer.
+func (h *peerHandler) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return h.client
+}
+
+// ClearRecords clears the replication metadata of the peer.
+func (h *peerHandler) ClearRecords(_ context.Context) {
+	h.metadata = make(map[string]*Metadata)
+}
+
+// UpdateRecords applies the records of the peer. The metadata of a replicated record is put just
+// before the record, so it's indexed first.
+func (h *peerHandler) UpdateRecords(ctx context.Context, _ uint64, records []*databroker.Record) {
+	for _, record := range records {
+		if record.GetType() != MetadataRecordType {
+			continue
+		}
+		if record.GetDeletedAt() != nil {
+			delete(h.metadata, record.GetId())
+			continue
+		}
+		m, err := metadataFromRecord(record)
+		if err != nil {
+			log.Warn(ctx).Err(err).Str("peer", h.peer).Msg("replication: invalid metadata")
+			continue
+		}
+		h.metadata[record.GetId()] = m
+	}
+
+	for _, record := range records {
+		if !h.cfg.isReplicated(record.GetType()) {
+			continue
+		}
+		err := h.apply(ctx, record)
+		if err != nil {
+			log.Error(ctx).Err(err).
+				Str("peer", h.peer).
+				Str("record-type", record.GetType()).
+				Str("record-id", record.GetId()).
+				Msg("replication: error applying record")
+		}
+	}
+}
+
+// apply puts a record of the peer into the local databroker, if it's newer than the local record.
+func (h *peerHandler) apply(ctx context.Context, record *databroker.Record) error {
+	remote := getVersion(h.peer, record, h.metadata[record.GetType()+"/"+record.GetId()])
+	// skip records which originally came from this region
+	if remote.Origin == h.cfg.region {
+		return nil
+	}
+
+	local, err := getLocalVersion(ctx, h.cfg.dataBrokerClient, h.cfg.region, record.GetType(), record.GetId())
+	if err != nil {
+		return fmt.Errorf("error retrieving local record: %w", err)
+	}
+	if local != nil && !remote.newerThan(local) {
+		return nil
+	}
+
+	metadataRecord, err := remote.toRecord()
+	if err != nil {
+		return err
+	}
+	_, err = h.cfg.dataBrokerClient.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{
+			metadataRecord,
+			{
+				Type:      record.GetType(),
+				Id:        record.GetId(),
+				Data:      record.GetData(),
+				DeletedAt: record.GetDeletedAt(),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error putting record: %w", err)
+	}
+
+	log.Debug(ctx).
+		Str("peer", h.peer).
+		Str("origin", remote.Origin).
+		Str("record-type", record.GetType()).
+		Str("record-id", record.GetId()).
+		Bool("deleted", remote.Deleted).
+		Msg("replication: applied record")
+	return nil
+}
+
+// A tombstoneHandler writes tombstones for the records deleted in the local region, so that older
+// writes of the peers don't resurrect them.
+type tombstoneHandler struct {
+	cfg *config
+}
+
+// GetDataBrokerServiceClient returns the local databroker client.
+func (h *tombstoneHandler) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return h.cfg.dataBrokerClient
+}
+
+// ClearRecords does nothing, deleted records are only seen when syncing changes.
+func (h *tombstoneHandler) ClearRecords(_ context.Context) {}
+
+// UpdateRecords writes tombstones for deleted records.
+func (h *tombstoneHandler) UpdateRecords(ctx context.Context, _ uint64, records []*databroker.Record) {
+	for _, record := range records {
+		if record.GetDeletedAt() == nil || !h.cfg.isReplicated(record.GetType()) {
+			continue
+		}
+		err := h.writeTombstone(ctx, record)
+		if err != nil {
+			log.Error(ctx).Err(err).
+				Str("record-type", record.GetType()).
+				Str("record-id", record.GetId()).
+				Msg("replication: error writing tombstone")
+		}
+	}
+}
+
+func (h *tombstoneHandler) writeTombstone(ctx context.Context, record *databroker.Record) error {
+	tombstone := newMetadata(h.cfg.region, record)
+
+	// deletes replicated from a peer already have a tombstone
+	local, err := getLocalVersion(ctx, h.cfg.dataBrokerClient, h.cfg.region, record.GetType(), record.GetId())
+	if err != nil {
+		return err
+	}
+	if local != nil && !tombstone.newerThan(local) {
+		return nil
+	}
+
+	metadataRecord, err := tombstone.toRecord()
+	if err != nil {
+		return err
+	}
+	_, err = h.cfg.dataBrokerClient.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{metadataRecord},
+	})
+	return err
+}
@@ -0,0 +1,149 @@
+package replication
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// MetadataRecordType is the record type of the replication metadata.
+const MetadataRecordType = "pomerium.io/ReplicationMetadata"
+
+// Metadata records which region originally wrote a replicated record and when. It's stored
+// in the same put as the record, so the peers can tell replicated records from the records
+// written by the region itself. The metadata of a deleted record is a tombstone, which stops
+// an older write from resurrecting the record.
+type Metadata struct {
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	Origin    string    `json:"origin"`
+	Timestamp time.Time `json:"timestamp"`
+	Deleted   bool      `json:"deleted,omitempty"`
+	// Hash is the hash of the record data, used to check that the metadata belongs to the
+	// current version of the record.
+	Hash string `json:"hash,omitempty"`
+}
+
+// newMetadata returns the metadata of a record written by the given origin.
+func newMetadata(origin string, record *databroker.Record) *Metadata {
+	m := &Metadata{
+		Type:      record.GetType(),
+		ID:        record.GetId(),
+		Origin:    origin,
+		Timestamp: record.GetModifiedAt().AsTime(),
+	}
+	if record.GetDeletedAt() != nil {
+		m.Deleted = true
+		m.Timestamp = record.GetDeletedAt().AsTime()
+	} else {
+		m.Hash = hex.EncodeToString(cryptutil.HashProto(record.GetData()))
+	}
+	return m
+}
+
+// getVersion returns the version of a record, which is its stored metadata if that belongs to
+// the record, and otherwise the metadata of a record written by the given origin.
+func getVersion(origin string, record *databroker.Record, stored *Metadata) *Metadata {
+	if record == nil {
+		// only a tombstone is left of a deleted record
+		if stored != nil && stored.Deleted {
+			return stored
+		}
+		return nil
+	}
+
+	m := newMetadata(origin, record)
+	if stored != nil && stored.Deleted == m.Deleted && stored.Hash == m.Hash {
+		return stored
+	}
+	return m
+}
+
+// newerThan returns true if m was written after other. Writes at the same time are ordered by
+// hash, so that all the regions pick the same winner.
+func (m *Metadata) newerThan(other *Metadata) bool {
+	if !m.Timestamp.Equal(other.Timestamp) {
+		return m.Timestamp.After(other.Timestamp)
+	}
+	return m.Hash > other.Hash
+}
+
+func (m *Metadata) recordID() string {
+	return m.Type + "/" + m.ID
+}
+
+func (m *Metadata) toRecord() (*databroker.Record, error) {
+	bs, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var data structpb.Struct
+	err = protojson.Unmarshal(bs, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &databroker.Record{
+		Type: MetadataRecordType,
+		Id:   m.recordID(),
+		Data: protoutil.NewAny(&data),
+	}, nil
+}
+
+func metadataFromRecord(record *databroker.Record) (*Metadata, error) {
+	var data structpb.Struct
+	err := record.GetData().UnmarshalTo(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := protojson.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Metadata
+	err = json.Unmarshal(bs, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// getLocalVersion returns the version of a record in the local databroker, or nil if the
+// record has never been written.
+func getLocalVersion(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	region, recordType, recordID string,
+) (*Metadata, error) {
+	var record *databroker.Record
+	res, err := client.Get(ctx, &databroker.GetRequest{Type: recordType, Id: recordID})
+	if status.Code(err) == codes.NotFound {
+		// the record doesn't exist or was deleted
+	} else if err != nil {
+		return nil, err
+	} else {
+		record = res.GetRecord()
+	}
+
+	stored, err := databroker.GetViaJSON[Metadata](ctx, client, MetadataRecordType, recordType+"/"+recordID)
+	if status.Code(err) == codes.NotFound {
+		stored = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return getVersion(region, record, stored), nil
+}
@@ -0,0 +1,249 @@
+// Package replication asynchronously replicates databroker records between pomerium clusters in
+// different regions.
+//
+// Each region syncs the records of every peer and applies them locally using last-writer-wins.
+// A region's records carry their modification time, and records it replicated from elsewhere
+// carry their original time and region in the replication metadata, so regions don't echo
+// records back to where they came from, and records can be relayed through other regions.
+package replication
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/log"
+	configpb "github.com/pomerium/pomerium/pkg/grpc/config"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/device"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+)
+
+const (
+	leaseName = "databroker_replication"
+	leaseTTL  = 30 * time.Second
+
+	// tombstones are kept long enough for a region which is offline to catch up
+	tombstoneRetention = 7 * 24 * time.Hour
+	pruneInterval      = time.Hour
+)
+
+// DefaultRecordTypes are the record types replicated if none are configured.
+var DefaultRecordTypes = []string{
+	grpcutil.GetTypeURL(new(session.Session)),
+	grpcutil.GetTypeURL(new(user.User)),
+	grpcutil.GetTypeURL(new(device.Credential)),
+	grpcutil.GetTypeURL(new(device.Enrollment)),
+	grpcutil.GetTypeURL(new(configpb.Config)),
+	session.RevocationRecordType,
+}
+
+type config struct {
+	dataBrokerClient databroker.DataBrokerServiceClient
+	region           string
+	peers            map[string]databroker.DataBrokerServiceClient
+	recordTypes      []string
+}
+
+// An Option customizes the configuration used for the replicator.
+type Option func(*config)
+
+// WithDataBrokerClient sets the local databroker client in the config.
+func WithDataBrokerClient(dataBrokerClient databroker.DataBrokerServiceClient) Option {
+	return func(cfg *config) {
+		cfg.dataBrokerClient = dataBrokerClient
+	}
+}
+
+// WithRegion sets the name of the local region in the config. Nothing is replicated without a region.
+func WithRegion(region string) Option {
+	return func(cfg *config) {
+		cfg.region = region
+	}
+}
+
+// WithPeer adds the databroker client of a peer region to the config.
+func WithPeer(region string, client databroker.DataBrokerServiceClient) Option {
+	return func(cfg *config) {
+		cfg.peers[region] = client
+	}
+}
+
+// WithRecordTypes sets the record types to replicate.
+func WithRecordTypes(recordTypes ...string) Option {
+	return func(cfg *config) {
+		cfg.recordTypes = recordTypes
+	}
+}
+
+func newConfig(options ...Option) *config {
+	cfg := &config{peers: make(map[string]databroker.DataBrokerServiceClient)}
+	WithRecordTypes(DefaultRecordTypes...)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+func (cfg *config) isEnabled() bool {
+	return cfg.dataBrokerClient != nil && cfg.region != "" && len(cfg.peers) > 0
+}
+
+func (cfg *config) isReplicated(recordType string) bool {
+	for _, t := range cfg.recordTypes {
+		if t == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// A Replicator replicates databroker records from peer regions into the local databroker.
+type Replicator struct {
+	cfg     *atomicutil.Value[*config]
+	updated chan struct{}
+}
+
+// New creates a new Replicator.
+func New(options ...Option) *Replicator {
+	return &Replicator{
+		cfg:     atomicutil.NewValue(newConfig(options...)),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the replicator with the new options, restarting replication.
+func (r *Replicator) UpdateConfig(options ...Option) {
+	r.cfg.Store(newConfig(options...))
+	select {
+	case r.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the replicator. Only a single replicator runs at a time across all the databroker
+// instances of a region.
+func (r *Replicator) Run(ctx context.Context) error {
+	leaser := databroker.NewLeaser(leaseName, leaseTTL, r)
+	return leaser.Run(ctx)
+}
+
+// RunLeased runs the replicator while the lease is held.
+func (r *Replicator) RunLeased(ctx context.Context) error {
+	ctx = log.WithContext(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("service", "databroker_replication")
+	})
+
+	for {
+		cfg := r.cfg.Load()
+
+		runCtx, runCancel := context.WithCancel(ctx)
+		eg, egCtx := errgroup.WithContext(runCtx)
+		if cfg.isEnabled() {
+			log.Info(ctx).
+				Str("region", cfg.region).
+				Int("peers", len(cfg.peers)).
+				Msg("replication: starting")
+			r.start(egCtx, eg, cfg)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-r.updated:
+		}
+		runCancel()
+		_ = eg.Wait()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// GetDataBrokerServiceClient returns the local databroker client.
+func (r *Replicator) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return r.cfg.Load().dataBrokerClient
+}
+
+func (r *Replicator) start(ctx context.Context, eg *errgroup.Group, cfg *config) {
+	names := make([]string, 0, len(cfg.peers))
+	for name := range cfg.peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		syncer := databroker.NewSyncer("replication_"+name, &peerHandler{
+			cfg:      cfg,
+			peer:     name,
+			client:   cfg.peers[name],
+			metadata: make(map[string]*Metadata),
+		})
+		eg.Go(func() error { return syncer.Run(ctx) })
+	}
+
+	syncer := databroker.NewSyncer("replication_tombstones", &tombstoneHandler{cfg: cfg})
+	eg.Go(func() error { return syncer.Run(ctx) })
+
+	eg.Go(func() error {
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		for {
+			err := pruneTombstones(ctx, cfg.dataBrokerClient, time.Now().Add(-tombstoneRetention))
+			if err != nil && ctx.Err() == nil {
+				log.Error(ctx).Err(err).Msg("replication: error pruning tombstones")
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// pruneTombstones deletes the tombstones of records deleted before the cutoff.
+func pruneTombstones(ctx context.Context, client databroker.DataBrokerServiceClient, cutoff time.Time) error {
+	const pageSize = 1000
+
+	var expired []*databroker.Record
+	for offset := int64(0); ; offset += pageSize {
+		res, err := client.Query(ctx, &databroker.QueryRequest{
+			Type:   MetadataRecordType,
+			Offset: offset,
+			Limit:  pageSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, record := range res.GetRecords() {
+			m, err := metadataFromRecord(record)
+			if err != nil || !m.Deleted || !m.Timestamp.Before(cutoff) {
+				continue
+			}
+			record.DeletedAt = timestamppb.Now()
+			expired = append(expired, record)
+		}
+
+		if offset+pageSize >= res.GetTotalCount() {
+			break
+		}
+	}
+
+	for _, req := range databroker.OptimumPutRequestsFromRecords(expired) {
+		if _, err := client.Put(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package replication
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+func newTestClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+func getData(ctx context.Context, client databroker.DataBrokerServiceClient, id string) string {
+	res, err := client.Get(ctx, &databroker.GetRequest{Type: "TYPE", Id: id})
+	if status.Code(err) == codes.NotFound {
+		return "<deleted>"
+	} else if err != nil {
+		return err.Error()
+	}
+	var v structpb.Value
+	if err := res.GetRecord().GetData().UnmarshalTo(&v); err != nil {
+		return err.Error()
+	}
+	return v.GetStringValue()
+}
+
+func putData(t *testing.T, client databroker.DataBrokerServiceClient, id, value string) {
+	t.Helper()
+
+	record := &databroker.Record{Type: "TYPE", Id: id, Data: protoutil.NewAny(protoutil.NewStructString(value))}
+	if value == "<deleted>" {
+		record.DeletedAt = timestamppb.Now()
+	}
+	_, err := client.Put(context.Background(), &databroker.PutRequest{Records: []*databroker.Record{record}})
+	require.NoError(t, err)
+}
+
+func TestReplicator(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	clientA, clientB := newTestClient(t), newTestClient(t)
+	for _, r := range []*Replicator{
+		New(WithDataBrokerClient(clientA), WithRegion("a"), WithPeer("b", clientB), WithRecordTypes("TYPE")),
+		New(WithDataBrokerClient(clientB), WithRegion("b"), WithPeer("a", clientA), WithRecordTypes("TYPE")),
+	} {
+		r := r
+		go func() { _ = r.RunLeased(ctx) }()
+	}
+
+	waitFor := func(client databroker.DataBrokerServiceClient, id, expect string) {
+		t.Helper()
+		assert.Eventually(t, func() bool {
+			return getData(ctx, client, id) == expect
+		}, 10*time.Second, 10*time.Millisecond, "expected %s to be %s", id, expect)
+	}
+
+	putData(t, clientA, "1", "v1")
+	waitFor(clientB, "1", "v1")
+
+	putData(t, clientB, "1", "v2")
+	waitFor(clientA, "1", "v2")
+
+	putData(t, clientA, "1", "<deleted>")
+	waitFor(clientB, "1", "<deleted>")
+
+	m, err := databroker.GetViaJSON[Metadata](ctx, clientB, MetadataRecordType, "TYPE/1")
+	require.NoError(t, err)
+	assert.Equal(t, "a", m.Origin)
+	assert.True(t, m.Deleted)
+
+	// the records shouldn't bounce back and forth
+	assert.Eventually(t, func() bool {
+		m, err := databroker.GetViaJSON[Metadata](ctx, clientA, MetadataRecordType, "TYPE/1")
+		return err == nil && m.Deleted
+	}, 10*time.Second, 10*time.Millisecond)
+	res, err := clientA.Get(ctx, &databroker.GetRequest{Type: MetadataRecordType, Id: "TYPE/1"})
+	require.NoError(t, err)
+	version := res.GetRecord().GetVersion()
+	time.Sleep(100 * time.Millisecond)
+	res, err = clientA.Get(ctx, &databroker.GetRequest{Type: MetadataRecordType, Id: "TYPE/1"})
+	require.NoError(t, err)
+	assert.Equal(t, version, res.GetRecord().GetVersion())
+}
+
+func TestPeerHandler(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t)
+	h := &peerHandler{
+		cfg:      newConfig(WithDataBrokerClient(client), WithRegion("a"), WithRecordTypes("TYPE")),
+		peer:     "b",
+		metadata: make(map[string]*Metadata),
+	}
+	remote := func(id, value string, modifiedAt time.Time) *databroker.Record {
+		return &databroker.Record{
+			Type:       "TYPE",
+			Id:         id,
+			Data:       protoutil.NewAny(protoutil.NewStructString(value)),
+			ModifiedAt: timestamppb.New(modifiedAt),
+		}
+	}
+
+	t.Run("newer", func(t *testing.T) {
+		putData(t, client, "newer", "local")
+		h.UpdateRecords(ctx, 0, []*databroker.Record{remote("newer", "remote", time.Now().Add(time.Hour))})
+		assert.Equal(t, "remote", getData(ctx, client, "newer"))
+	})
+	t.Run("older", func(t *testing.T) {
+		putData(t, client, "older", "local")
+		h.UpdateRecords(ctx, 0, []*databroker.Record{remote("older", "remote", time.Now().Add(-time.Hour))})
+		assert.Equal(t, "local", getData(ctx, client, "older"))
+	})
+	t.Run("tombstone", func(t *testing.T) {
+		putData(t, client, "tombstone", "local")
+		putData(t, client, "tombstone", "<deleted>")
+		require.NoError(t, (&tombstoneHandler{cfg: h.cfg}).writeTombstone(ctx, &databroker.Record{
+			Type:      "TYPE",
+			Id:        "tombstone",
+			DeletedAt: timestamppb.Now(),
+		}))
+
+		h.UpdateRecords(ctx, 0, []*databroker.Record{remote("tombstone", "remote", time.Now().Add(-time.Minute))})
+		assert.Equal(t, "<deleted>", getData(ctx, client, "tombstone"),
+			"should not resurrect deleted records")
+	})
+	t.Run("relayed", func(t *testing.T) {
+		putData(t, client, "relayed", "local")
+		record := remote("relayed", "remote", time.Now().Add(time.Hour))
+		metadataRecord, err := newMetadata("c", record).toRecord()
+		require.NoError(t, err)
+		h.UpdateRecords(ctx, 0, []*databroker.Record{metadataRecord, record})
+		assert.Equal(t, "remote", getData(ctx, client, "relayed"))
+
+		m, err := databroker.GetViaJSON[Metadata](ctx, client, MetadataRecordType, "TYPE/relayed")
+		require.NoError(t, err)
+		assert.Equal(t, "c", m.Origin, "should keep the original region")
+	})
+	t.Run("echo", func(t *testing.T) {
+		putData(t, client, "echo", "local")
+		record := remote("echo", "stale", time.Now().Add(time.Hour))
+		metadataRecord, err := newMetadata("a", record).toRecord()
+		require.NoError(t, err)
+		h.UpdateRecords(ctx, 0, []*databroker.Record{metadataRecord, record})
+		assert.Equal(t, "local", getData(ctx, client, "echo"), "should skip records from this region")
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	record := &databroker.Record{
+		Type:       "TYPE",
+		Id:         "ID",
+		Data:       protoutil.NewAny(protoutil.NewStructString("value")),
+		ModifiedAt: timestamppb.New(now),
+	}
+
+	m := newMetadata("a", record)
+	assert.Equal(t, "TYPE/ID", m.recordID())
+	assert.NotEmpty(t, m.Hash)
+
+	stored := &Metadata{Type: "TYPE", ID: "ID", Origin: "c", Timestamp: now.Add(-time.Minute), Hash: m.Hash}
+	assert.Equal(t, stored, getVersion("a", record, stored), "should use the stored metadata of the record")
+
+	stored.Hash = "OTHER"
+	assert.Equal(t, m, getVersion("a", record, stored), "should ignore metadata of another version")
+
+	assert.Nil(t, getVersion("a", nil, stored))
+	stored.Deleted = true
+	assert.Equal(t, stored, getVersion("a", nil, stored), "should use tombstones")
+
+	assert.True(t, m.newerThan(&Metadata{Timestamp: now.Add(-time.Second)}))
+	assert.False(t, m.newerThan(&Metadata{Timestamp: now.Add(time.Second)}))
+	assert.Equal(t, m.Hash > "", m.newerThan(&Metadata{Timestamp: now}), "should order ties by hash")
+
+	deleted := proto.Clone(record).(*databroker.Record)
+	deleted.DeletedAt = timestamppb.New(now.Add(time.Second))
+	tombstone := newMetadata("a", deleted)
+	assert.True(t, tombstone.Deleted)
+	assert.Empty(t, tombstone.Hash)
+	assert.True(t, tombstone.newerThan(m))
+
+	bs, err := tombstone.toRecord()
+	require.NoError(t, err)
+	parsed, err := metadataFromRecord(bs)
+	require.NoError(t, err)
+	assert.True(t, tombstone.Timestamp.Equal(parsed.Timestamp))
+	assert.Equal(t, tombstone.Deleted, parsed.Deleted)
+}
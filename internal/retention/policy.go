@@ -0,0 +1,55 @@
+package retention
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// A Policy configures how long the records of a type are kept.
+type Policy struct {
+	RecordType string
+	// MaxAge expires records which haven't been modified for this long. Zero disables it.
+	MaxAge time.Duration
+	// ExpiryGracePeriod expires records this long after their expires_at time, for records which
+	// have one. Nil disables it.
+	ExpiryGracePeriod *time.Duration
+}
+
+// IsExpired returns true if the record is expired at the given time.
+func (p Policy) IsExpired(record *databroker.Record, now time.Time) bool {
+	if p.MaxAge > 0 && record.GetModifiedAt() != nil &&
+		record.GetModifiedAt().AsTime().Add(p.MaxAge).Before(now) {
+		return true
+	}
+
+	if p.ExpiryGracePeriod != nil {
+		if expiresAt, ok := getExpiresAt(record); ok && expiresAt.Add(*p.ExpiryGracePeriod).Before(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getExpiresAt returns the expires_at timestamp of the record's data, if it has one.
+func getExpiresAt(record *databroker.Record) (time.Time, bool) {
+	msg, err := record.GetData().UnmarshalNew()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	m := msg.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName("expires_at")
+	if fd == nil || fd.Message() == nil || !m.Has(fd) {
+		return time.Time{}, false
+	}
+
+	ts, ok := m.Get(fd).Message().Interface().(*timestamppb.Timestamp)
+	if !ok {
+		return time.Time{}, false
+	}
+	return ts.AsTime(), true
+}
@@ -0,0 +1,204 @@
+// Package retention deletes old databroker records according to per record type retention policies,
+// so that storage backends don't grow unbounded.
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/atomicutil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+const (
+	leaseName = "databroker_retention"
+	leaseTTL  = 30 * time.Second
+
+	defaultInterval = time.Hour
+	pageSize        = 1000
+)
+
+type config struct {
+	dataBrokerClient databroker.DataBrokerServiceClient
+	interval         time.Duration
+	policies         []Policy
+	now              func() time.Time
+}
+
+// An Option customizes the configuration used for the collector.
+type Option func(*config)
+
+// WithDataBrokerClient sets the databroker client in the config.
+func WithDataBrokerClient(dataBrokerClient databroker.DataBrokerServiceClient) Option {
+	return func(cfg *config) {
+		cfg.dataBrokerClient = dataBrokerClient
+	}
+}
+
+// WithInterval sets how often the retention policies are applied in the config.
+func WithInterval(interval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.interval = interval
+	}
+}
+
+// WithPolicies sets the retention policies in the config.
+func WithPolicies(policies ...Policy) Option {
+	return func(cfg *config) {
+		cfg.policies = policies
+	}
+}
+
+// WithNow sets the time function in the config.
+func WithNow(now func() time.Time) Option {
+	return func(cfg *config) {
+		cfg.now = now
+	}
+}
+
+func newConfig(options ...Option) *config {
+	cfg := new(config)
+	WithInterval(defaultInterval)(cfg)
+	WithNow(time.Now)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+func (cfg *config) isEnabled() bool {
+	return cfg.dataBrokerClient != nil && len(cfg.policies) > 0
+}
+
+// A Collector periodically deletes the databroker records expired by the retention policies.
+type Collector struct {
+	cfg     *atomicutil.Value[*config]
+	updated chan struct{}
+}
+
+// New creates a new Collector.
+func New(options ...Option) *Collector {
+	return &Collector{
+		cfg:     atomicutil.NewValue(newConfig(options...)),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// UpdateConfig updates the collector with the new options.
+func (c *Collector) UpdateConfig(options ...Option) {
+	c.cfg.Store(newConfig(options...))
+	select {
+	case c.updated <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the collector. Only a single collector runs at a time across all the databroker
+// instances.
+func (c *Collector) Run(ctx context.Context) error {
+	leaser := databroker.NewLeaser(leaseName, leaseTTL, c)
+	return leaser.Run(ctx)
+}
+
+// RunLeased runs the collector while the lease is held.
+func (c *Collector) RunLeased(ctx context.Context) error {
+	ctx = log.WithContext(ctx, func(zc zerolog.Context) zerolog.Context {
+		return zc.Str("service", "databroker_retention")
+	})
+
+	for {
+		cfg := c.cfg.Load()
+		if cfg.isEnabled() {
+			c.collect(ctx, cfg)
+		}
+
+		timer := time.NewTimer(cfg.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-c.updated:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// GetDataBrokerServiceClient returns the databroker client.
+func (c *Collector) GetDataBrokerServiceClient() databroker.DataBrokerServiceClient {
+	return c.cfg.Load().dataBrokerClient
+}
+
+func (c *Collector) collect(ctx context.Context, cfg *config) {
+	for _, policy := range cfg.policies {
+		start := time.Now()
+		deleted, err := deleteExpired(ctx, cfg.dataBrokerClient, policy, cfg.now())
+		metrics.RecordRetentionRun(ctx, policy.RecordType, deleted, time.Since(start), err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Error(ctx).Err(err).
+				Str("record-type", policy.RecordType).
+				Msg("retention: error deleting expired records")
+			continue
+		}
+		if deleted > 0 {
+			log.Info(ctx).
+				Str("record-type", policy.RecordType).
+				Int("count", deleted).
+				Msg("retention: deleted expired records")
+		}
+	}
+}
+
+// deleteExpired deletes the records of the policy's type which are expired at the given time,
+// returning the number of records deleted.
+func deleteExpired(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	policy Policy,
+	now time.Time,
+) (int, error) {
+	var expired []*databroker.Record
+	for offset := int64(0); ; offset += pageSize {
+		res, err := client.Query(ctx, &databroker.QueryRequest{
+			Type:   policy.RecordType,
+			Offset: offset,
+			Limit:  pageSize,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, record := range res.GetRecords() {
+			if !policy.IsExpired(record, now) {
+				continue
+			}
+			expired = append(expired, &databroker.Record{
+				Type:      record.GetType(),
+				Id:        record.GetId(),
+				Data:      record.GetData(),
+				DeletedAt: timestamppb.New(now),
+			})
+		}
+
+		if offset+pageSize >= res.GetTotalCount() {
+			break
+		}
+	}
+
+	deleted := 0
+	for _, req := range databroker.OptimumPutRequestsFromRecords(expired) {
+		if _, err := client.Put(ctx, req); err != nil {
+			return deleted, err
+		}
+		deleted += len(req.GetRecords())
+	}
+	return deleted, nil
+}
@@ -0,0 +1,131 @@
+package retention
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpcutil"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+func newTestClient(t *testing.T) databroker.DataBrokerServiceClient {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return databroker.NewDataBrokerServiceClient(cc)
+}
+
+func listIDs(ctx context.Context, t *testing.T, client databroker.DataBrokerServiceClient, recordType string) []string {
+	t.Helper()
+
+	res, err := client.Query(ctx, &databroker.QueryRequest{Type: recordType, Limit: 100})
+	require.NoError(t, err)
+	var ids []string
+	for _, record := range res.GetRecords() {
+		ids = append(ids, record.GetId())
+	}
+	return ids
+}
+
+func TestDeleteExpired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := newTestClient(t)
+	now := time.Now()
+
+	for id, expiresAt := range map[string]time.Time{
+		"expired":         now.Add(-2 * time.Hour),
+		"in-grace":        now.Add(-30 * time.Minute),
+		"not-yet-expired": now.Add(time.Hour),
+	} {
+		_, err := session.Put(ctx, client, &session.Session{Id: id, ExpiresAt: timestamppb.New(expiresAt)})
+		require.NoError(t, err)
+	}
+
+	grace := time.Hour
+	deleted, err := deleteExpired(ctx, client, Policy{
+		RecordType:        grpcutil.GetTypeURL(new(session.Session)),
+		ExpiryGracePeriod: &grace,
+	}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.ElementsMatch(t, []string{"in-grace", "not-yet-expired"},
+		listIDs(ctx, t, client, grpcutil.GetTypeURL(new(session.Session))))
+
+	_, err = client.Put(ctx, &databroker.PutRequest{Records: []*databroker.Record{{
+		Type: "TYPE",
+		Id:   "1",
+		Data: protoutil.NewAny(protoutil.NewStructString("value")),
+	}}})
+	require.NoError(t, err)
+
+	policy := Policy{RecordType: "TYPE", MaxAge: 30 * 24 * time.Hour}
+	deleted, err = deleteExpired(ctx, client, policy, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	deleted, err = deleteExpired(ctx, client, policy, now.Add(31*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Empty(t, listIDs(ctx, t, client, "TYPE"))
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	record := func(modifiedAt time.Time, expiresAt *timestamppb.Timestamp) *databroker.Record {
+		return &databroker.Record{
+			Data:       protoutil.NewAny(&session.Session{ExpiresAt: expiresAt}),
+			ModifiedAt: timestamppb.New(modifiedAt),
+		}
+	}
+	zero := time.Duration(0)
+
+	for _, tc := range []struct {
+		name    string
+		policy  Policy
+		record  *databroker.Record
+		expired bool
+	}{
+		{"max age", Policy{MaxAge: time.Hour}, record(now.Add(-2*time.Hour), nil), true},
+		{"recently modified", Policy{MaxAge: time.Hour}, record(now.Add(-time.Minute), nil), false},
+		{"expired", Policy{ExpiryGracePeriod: &zero}, record(now, timestamppb.New(now.Add(-time.Minute))), true},
+		{"not expired", Policy{ExpiryGracePeriod: &zero}, record(now, timestamppb.New(now.Add(time.Minute))), false},
+		{"no expiry", Policy{ExpiryGracePeriod: &zero}, record(now, nil), false},
+		{"expiry disabled", Policy{MaxAge: time.Hour}, record(now, timestamppb.New(now.Add(-time.Minute))), false},
+		{"no expires_at field", Policy{ExpiryGracePeriod: &zero}, &databroker.Record{
+			Data: protoutil.NewAny(protoutil.NewStructString("value")),
+		}, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expired, tc.policy.IsExpired(tc.record, now))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	filterRE = regexp.MustCompile(`^\s*([A-Za-z][\w.]*)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+	pathRE   = regexp.MustCompile(`^([A-Za-z]\w*)(?:\[(.*)\])?(?:\.([A-Za-z]\w*))?$`)
+)
+
+// A filter is an equality filter, like `userName eq "alice@example.com"`. Other filter operators
+// are not supported.
+type filter struct {
+	attribute string
+	value     string
+}
+
+// parseFilter parses a filter. A nil filter is returned for an empty filter.
+func parseFilter(raw string) (*filter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	m := filterRE.FindStringSubmatch(raw)
+	if m == nil {
+		return nil, newError(http.StatusBadRequest, "invalidFilter", "unsupported filter: %s", raw)
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(`"`+m[2]+`"`), &value); err != nil {
+		return nil, newError(http.StatusBadRequest, "invalidFilter", "invalid filter value: %s", m[2])
+	}
+	return &filter{attribute: m[1], value: value}, nil
+}
+
+// An attributePath is the path of a PATCH operation, like `name.givenName` or
+// `members[value eq "2819c223"]`.
+type attributePath struct {
+	attribute    string
+	valueFilter  *filter
+	subAttribute string
+}
+
+// parsePath parses the path of a PATCH operation. Paths of unknown schemas are returned as is, so
+// that they can be ignored.
+func parsePath(raw string, schema string) (*attributePath, error) {
+	path := raw
+	if len(path) > len(schema) && strings.EqualFold(path[:len(schema)+1], schema+":") {
+		path = path[len(schema)+1:]
+	}
+
+	m := pathRE.FindStringSubmatch(path)
+	if m == nil {
+		return &attributePath{attribute: raw}, nil
+	}
+
+	p := &attributePath{attribute: m[1], subAttribute: m[3]}
+	if m[2] != "" {
+		var err error
+		p.valueFilter, err = parseFilter(m[2])
+		if err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidPath", "unsupported path: %s", raw)
+		}
+	}
+	return p, nil
+}
+
+// is returns true if the path refers to the given attribute. Attribute names are case insensitive.
+func (p *attributePath) is(attribute string) bool {
+	return strings.EqualFold(p.attribute, attribute)
+}
@@ -0,0 +1,345 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pomerium/datasource/pkg/directory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+type groupResource struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	// Members is a pointer so that a replaced group without members can be told apart from a
+	// replaced group which doesn't include its members.
+	Members *[]reference `json:"members,omitempty"`
+	Meta    *meta        `json:"meta,omitempty"`
+}
+
+func newGroupResource(r *http.Request, g *groupRecord, members []*userRecord) *groupResource {
+	res := &groupResource{
+		Schemas:     []string{schemaGroup},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.Name,
+		Meta: &meta{
+			ResourceType: "Group",
+			Created:      g.Created,
+			LastModified: g.LastModified,
+			Location:     getLocation(r, "Groups", g.ID),
+		},
+	}
+	refs := []reference{}
+	for _, u := range members {
+		refs = append(refs, reference{
+			Value:   u.ID,
+			Display: u.DisplayName,
+			Ref:     getLocation(r, "Users", u.ID),
+		})
+	}
+	res.Members = &refs
+	return res
+}
+
+// memberIDs returns the ids of the members.
+func (res *groupResource) memberIDs() []string {
+	if res.Members == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(*res.Members))
+	for _, m := range *res.Members {
+		ids = append(ids, m.Value)
+	}
+	return ids
+}
+
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	f, err := parseFilter(r.FormValue("filter"))
+	if err != nil {
+		return err
+	}
+
+	var match func(g *groupRecord) bool
+	var query string
+	if f != nil {
+		query = f.value
+		switch {
+		case strings.EqualFold(f.attribute, "id"):
+			match = func(g *groupRecord) bool { return g.ID == f.value }
+		case strings.EqualFold(f.attribute, "externalId"):
+			match = func(g *groupRecord) bool { return g.ExternalID == f.value }
+		case strings.EqualFold(f.attribute, "displayName"):
+			match = func(g *groupRecord) bool { return strings.EqualFold(g.Name, f.value) }
+		default:
+			return newError(http.StatusBadRequest, "invalidFilter", "unsupported filter attribute: %s", f.attribute)
+		}
+	}
+
+	groups, err := listGroups(ctx, state.Client, query)
+	if err != nil {
+		return err
+	}
+	if match != nil {
+		var matched []*groupRecord
+		for _, g := range groups {
+			if match(g) {
+				matched = append(matched, g)
+			}
+		}
+		groups = matched
+	}
+	sortGroups(groups)
+
+	// members are expensive to look up, so they are only returned when requested
+	includeMembers := strings.Contains(r.FormValue("attributes"), "members")
+
+	startIndex, count := getPage(r)
+	from, to := paginate(len(groups), startIndex, count)
+	var resources []any
+	for _, g := range groups[from:to] {
+		var members []*userRecord
+		if includeMembers {
+			members, err = listGroupMembers(ctx, state.Client, g.ID)
+			if err != nil {
+				return err
+			}
+		}
+		res := newGroupResource(r, g, members)
+		if !includeMembers {
+			res.Members = nil
+		}
+		resources = append(resources, res)
+	}
+	renderJSON(w, http.StatusOK, newListResponse(resources, len(groups), startIndex))
+	return nil
+}
+
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	g, err := h.loadGroup(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	return h.renderGroup(w, r, state, http.StatusOK, g)
+}
+
+func (h *Handler) createGroup(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	var res groupResource
+	if err := readJSON(r, &res); err != nil {
+		return err
+	}
+	if res.DisplayName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	groupID := res.ExternalID
+	if groupID == "" {
+		groupID = uuid.NewString()
+	}
+
+	now := time.Now()
+	g, err := getGroup(ctx, state.Client, groupID)
+	if status.Code(err) == codes.NotFound {
+		g = &groupRecord{ID: groupID, Created: now}
+	} else if err != nil {
+		return err
+	} else if !g.Created.IsZero() {
+		return newError(http.StatusConflict, "uniqueness", "group %s already exists", groupID)
+	}
+	// groups previously synced from a directory are adopted
+	if g.Created.IsZero() {
+		g.Created = now
+	}
+
+	g.Name = res.DisplayName
+	g.ExternalID = res.ExternalID
+	g.LastModified = now
+	if err := putGroup(ctx, state.Client, g); err != nil {
+		return err
+	}
+	if err := addGroupMembers(ctx, state.Client, g.ID, res.memberIDs()...); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", getLocation(r, "Groups", g.ID))
+	return h.renderGroup(w, r, state, http.StatusCreated, g)
+}
+
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	g, err := h.loadGroup(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	var res groupResource
+	if err := readJSON(r, &res); err != nil {
+		return err
+	}
+	if res.DisplayName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	g.Name = res.DisplayName
+	g.ExternalID = res.ExternalID
+	g.LastModified = time.Now()
+	if err := putGroup(ctx, state.Client, g); err != nil {
+		return err
+	}
+	if res.Members != nil {
+		if err := setGroupMembers(ctx, state.Client, g.ID, res.memberIDs()...); err != nil {
+			return err
+		}
+	}
+
+	return h.renderGroup(w, r, state, http.StatusOK, g)
+}
+
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	g, err := h.loadGroup(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	ops, err := readPatchRequest(r)
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err := h.patchGroupAttribute(ctx, state.Client, g, op); err != nil {
+			return err
+		}
+	}
+	if g.Name == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+
+	g.LastModified = time.Now()
+	if err := putGroup(ctx, state.Client, g); err != nil {
+		return err
+	}
+
+	return h.renderGroup(w, r, state, http.StatusOK, g)
+}
+
+// patchGroupAttribute applies a PATCH operation to the group. Membership changes are applied
+// immediately. Unknown attributes are ignored.
+func (h *Handler) patchGroupAttribute(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	g *groupRecord,
+	op patchOperation,
+) error {
+	path, err := parsePath(op.Path, schemaGroup)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case path.is("displayName"):
+		g.Name, err = op.stringValue()
+		return err
+	case path.is("externalId"):
+		g.ExternalID, err = op.stringValue()
+		return err
+	case !path.is("members"):
+		return nil
+	}
+
+	// members[value eq "2819c223"]
+	if path.valueFilter != nil {
+		if !strings.EqualFold(path.valueFilter.attribute, "value") {
+			return newError(http.StatusBadRequest, "invalidPath", "unsupported path: %s", op.Path)
+		}
+		if op.Op == patchOpRemove {
+			return removeGroupMembers(ctx, client, g.ID, path.valueFilter.value)
+		}
+		return addGroupMembers(ctx, client, g.ID, path.valueFilter.value)
+	}
+
+	var members []reference
+	if len(op.Value) > 0 && string(op.Value) != "null" {
+		if err := json.Unmarshal(op.Value, &members); err != nil {
+			return newError(http.StatusBadRequest, "invalidValue", "invalid value for members: %v", err)
+		}
+	}
+	userIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		userIDs = append(userIDs, m.Value)
+	}
+
+	switch op.Op {
+	case patchOpAdd:
+		return addGroupMembers(ctx, client, g.ID, userIDs...)
+	case patchOpRemove:
+		// without a value all the members are removed
+		if len(members) == 0 {
+			return setGroupMembers(ctx, client, g.ID)
+		}
+		return removeGroupMembers(ctx, client, g.ID, userIDs...)
+	default:
+		return setGroupMembers(ctx, client, g.ID, userIDs...)
+	}
+}
+
+func (h *Handler) deleteGroup(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	g, err := h.loadGroup(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	if err := setGroupMembers(ctx, state.Client, g.ID); err != nil {
+		return err
+	}
+	if err := deleteRecord(ctx, state.Client, directory.GroupRecordType, g.ID); err != nil {
+		return err
+	}
+	log.Info(ctx).Str("group-id", g.ID).Msg("scim: group deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) loadGroup(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string) (*groupRecord, error) {
+	g, err := getGroup(ctx, client, groupID)
+	if status.Code(err) == codes.NotFound {
+		return nil, newError(http.StatusNotFound, "", "group %s not found", groupID)
+	} else if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (h *Handler) renderGroup(w http.ResponseWriter, r *http.Request, state *State, code int, g *groupRecord) error {
+	members, err := listGroupMembers(r.Context(), state.Client, g.ID)
+	if err != nil {
+		return err
+	}
+
+	renderJSON(w, code, newGroupResource(r, g, members))
+	return nil
+}
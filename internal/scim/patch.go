@@ -0,0 +1,104 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	patchOpAdd     = "add"
+	patchOpRemove  = "remove"
+	patchOpReplace = "replace"
+)
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []patchOperation `json:"Operations"`
+}
+
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// readPatchRequest reads a PATCH request. Operations without a path are expanded into an operation
+// per attribute of the value.
+func readPatchRequest(r *http.Request) ([]patchOperation, error) {
+	var req patchRequest
+	if err := readJSON(r, &req); err != nil {
+		return nil, err
+	}
+
+	var ops []patchOperation
+	for _, op := range req.Operations {
+		// some identity providers capitalize the operations
+		op.Op = strings.ToLower(op.Op)
+		switch op.Op {
+		case patchOpAdd, patchOpReplace:
+		case patchOpRemove:
+			if op.Path == "" {
+				return nil, newError(http.StatusBadRequest, "noTarget", "remove operations require a path")
+			}
+		default:
+			return nil, newError(http.StatusBadRequest, "invalidSyntax", "unknown patch operation: %s", op.Op)
+		}
+
+		if op.Path != "" {
+			ops = append(ops, op)
+			continue
+		}
+
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attributes); err != nil {
+			return nil, newError(http.StatusBadRequest, "invalidValue", "expected an object value for an operation without a path")
+		}
+		for path, value := range attributes {
+			ops = append(ops, patchOperation{Op: op.Op, Path: path, Value: value})
+		}
+	}
+	return ops, nil
+}
+
+// stringValue returns the string value of the operation, or an empty string for remove operations.
+func (op patchOperation) stringValue() (string, error) {
+	if op.Op == patchOpRemove {
+		return "", nil
+	}
+
+	var value string
+	if err := json.Unmarshal(op.Value, &value); err != nil {
+		return "", newError(http.StatusBadRequest, "invalidValue", "expected a string value for %s", op.Path)
+	}
+	return value, nil
+}
+
+// A boolValue is a boolean which also accepts the strings "true" and "false", which some identity
+// providers send in PATCH requests.
+type boolValue bool
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (b *boolValue) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*b = boolValue(v)
+	case string:
+		switch strings.ToLower(v) {
+		case "true":
+			*b = true
+		case "false":
+			*b = false
+		default:
+			return newError(http.StatusBadRequest, "invalidValue", "invalid boolean: %s", v)
+		}
+	default:
+		return newError(http.StatusBadRequest, "invalidValue", "invalid boolean: %s", data)
+	}
+	return nil
+}
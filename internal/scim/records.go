@@ -0,0 +1,257 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pomerium/datasource/pkg/directory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/slices"
+)
+
+// A userRecord is a directory user. The directory fields are read by the authorize service, the
+// SCIM fields are only used to round-trip the SCIM representation of the user.
+type userRecord struct {
+	ID          string   `json:"id"`
+	GroupIDs    []string `json:"group_ids"`
+	DisplayName string   `json:"display_name"`
+	Email       string   `json:"email"`
+
+	UserName     string    `json:"scim_user_name,omitempty"`
+	ExternalID   string    `json:"scim_external_id,omitempty"`
+	GivenName    string    `json:"scim_given_name,omitempty"`
+	FamilyName   string    `json:"scim_family_name,omitempty"`
+	Active       bool      `json:"scim_active"`
+	Created      time.Time `json:"scim_created"`
+	LastModified time.Time `json:"scim_last_modified"`
+}
+
+// A groupRecord is a directory group. Group membership is stored on the users.
+type groupRecord struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+
+	ExternalID   string    `json:"scim_external_id,omitempty"`
+	Created      time.Time `json:"scim_created"`
+	LastModified time.Time `json:"scim_last_modified"`
+}
+
+func getUser(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) (*userRecord, error) {
+	return getRecord[userRecord](ctx, client, directory.UserRecordType, userID)
+}
+
+func getGroup(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string) (*groupRecord, error) {
+	return getRecord[groupRecord](ctx, client, directory.GroupRecordType, groupID)
+}
+
+func putUser(ctx context.Context, client databroker.DataBrokerServiceClient, u *userRecord) error {
+	_, err := databroker.PutViaJSON(ctx, client, directory.UserRecordType, u.ID, u)
+	return err
+}
+
+func putGroup(ctx context.Context, client databroker.DataBrokerServiceClient, g *groupRecord) error {
+	_, err := databroker.PutViaJSON(ctx, client, directory.GroupRecordType, g.ID, g)
+	return err
+}
+
+// listUsers lists the directory users. The query narrows down the users to those with any field
+// containing it.
+func listUsers(ctx context.Context, client databroker.DataBrokerServiceClient, query string) ([]*userRecord, error) {
+	return listRecords[userRecord](ctx, client, directory.UserRecordType, query)
+}
+
+// listGroups lists the directory groups. The query narrows down the groups to those with any field
+// containing it.
+func listGroups(ctx context.Context, client databroker.DataBrokerServiceClient, query string) ([]*groupRecord, error) {
+	return listRecords[groupRecord](ctx, client, directory.GroupRecordType, query)
+}
+
+// listGroupMembers lists the directory users who are members of the group.
+func listGroupMembers(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string) ([]*userRecord, error) {
+	users, err := listUsers(ctx, client, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Filter(users, func(u *userRecord) bool {
+		return slices.Contains(u.GroupIDs, groupID)
+	}), nil
+}
+
+// addGroupMembers adds the users to the group. Unknown users are skipped, identity providers
+// provision users before adding them to groups.
+func addGroupMembers(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string, userIDs ...string) error {
+	for _, userID := range userIDs {
+		u, err := getUser(ctx, client, userID)
+		if status.Code(err) == codes.NotFound {
+			log.Warn(ctx).Str("group-id", groupID).Str("user-id", userID).
+				Msg("scim: skipping unknown group member")
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if slices.Contains(u.GroupIDs, groupID) {
+			continue
+		}
+		u.GroupIDs = append(u.GroupIDs, groupID)
+		u.LastModified = time.Now()
+		if err := putUser(ctx, client, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeGroupMembers removes the users from the group.
+func removeGroupMembers(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string, userIDs ...string) error {
+	for _, userID := range userIDs {
+		u, err := getUser(ctx, client, userID)
+		if status.Code(err) == codes.NotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if !slices.Contains(u.GroupIDs, groupID) {
+			continue
+		}
+		u.GroupIDs = slices.Remove(u.GroupIDs, groupID)
+		u.LastModified = time.Now()
+		if err := putUser(ctx, client, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setGroupMembers replaces the members of the group.
+func setGroupMembers(ctx context.Context, client databroker.DataBrokerServiceClient, groupID string, userIDs ...string) error {
+	members, err := listGroupMembers(ctx, client, groupID)
+	if err != nil {
+		return err
+	}
+
+	var remove []string
+	for _, u := range members {
+		if !slices.Contains(userIDs, u.ID) {
+			remove = append(remove, u.ID)
+		}
+	}
+	if err := removeGroupMembers(ctx, client, groupID, remove...); err != nil {
+		return err
+	}
+	return addGroupMembers(ctx, client, groupID, userIDs...)
+}
+
+// revokeUserSessions deletes all the sessions of a user, so that deprovisioned users lose access
+// immediately.
+func revokeUserSessions(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) error {
+	sessions, err := session.GetUserSessions(ctx, client, userID)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := session.Delete(ctx, client, s.GetId()); err != nil {
+			return err
+		}
+	}
+	if len(sessions) > 0 {
+		log.Info(ctx).Str("user-id", userID).Int("sessions", len(sessions)).
+			Msg("scim: revoked sessions of deprovisioned user")
+	}
+	return nil
+}
+
+func deleteRecord(ctx context.Context, client databroker.DataBrokerServiceClient, recordType, recordID string) error {
+	_, err := client.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{{
+			Type:      recordType,
+			Id:        recordID,
+			Data:      protoutil.NewAny(new(structpb.Struct)),
+			DeletedAt: timestamppb.Now(),
+		}},
+	})
+	return err
+}
+
+func getRecord[T any](ctx context.Context, client databroker.DataBrokerServiceClient, recordType, recordID string) (*T, error) {
+	res, err := client.Get(ctx, &databroker.GetRequest{
+		Type: recordType,
+		Id:   recordID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalRecord[T](res.GetRecord())
+}
+
+func listRecords[T any](ctx context.Context, client databroker.DataBrokerServiceClient, recordType, query string) ([]*T, error) {
+	const pageSize = 100
+
+	var objs []*T
+	for offset := int64(0); ; offset += pageSize {
+		res, err := client.Query(ctx, &databroker.QueryRequest{
+			Type:   recordType,
+			Query:  query,
+			Offset: offset,
+			Limit:  pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range res.GetRecords() {
+			obj, err := unmarshalRecord[T](record)
+			if err != nil {
+				return nil, err
+			}
+			objs = append(objs, obj)
+		}
+
+		if offset+pageSize >= res.GetTotalCount() {
+			break
+		}
+	}
+	return objs, nil
+}
+
+// unmarshalRecord converts the record data to JSON and then unmarshals it to the given type, like
+// databroker.GetViaJSON.
+func unmarshalRecord[T any](record *databroker.Record) (*T, error) {
+	msg, err := record.GetData().UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj T
+	if err := json.Unmarshal(bs, &obj); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s record: %w", record.GetType(), err)
+	}
+	return &obj, nil
+}
+
+func sortUsers(users []*userRecord) {
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+}
+
+func sortGroups(groups []*groupRecord) {
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+}
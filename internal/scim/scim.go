@@ -0,0 +1,283 @@
+// Package scim contains a SCIM 2.0 server, which identity providers use to provision directory users
+// and groups directly into the databroker.
+//
+// See RFC 7643 and RFC 7644. Only the subset of the protocol used by common identity providers is
+// supported: filtering by equality, PATCH operations and pagination.
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+const (
+	schemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	schemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	contentType = "application/scim+json"
+
+	defaultCount   = 100
+	maxCount       = 1000
+	maxRequestSize = 1 << 20
+)
+
+// State is the state needed by the Handler to handle requests.
+type State struct {
+	Client      databroker.DataBrokerServiceClient
+	BearerToken string
+}
+
+// A StateProvider provides state for the handler.
+type StateProvider = func(*http.Request) (*State, error)
+
+// Handler is the SCIM handler.
+type Handler struct {
+	getState StateProvider
+}
+
+// New creates a new Handler.
+func New(getState StateProvider) *Handler {
+	return &Handler{
+		getState: getState,
+	}
+}
+
+// Mount mounts the SCIM endpoints on the router.
+func (h *Handler) Mount(r *mux.Router) {
+	r.Path("/ServiceProviderConfig").Handler(h.handle(h.serviceProviderConfig)).Methods(http.MethodGet)
+	r.Path("/ResourceTypes").Handler(h.handle(h.resourceTypes)).Methods(http.MethodGet)
+
+	r.Path("/Users").Handler(h.handle(h.listUsers)).Methods(http.MethodGet)
+	r.Path("/Users").Handler(h.handle(h.createUser)).Methods(http.MethodPost)
+	r.Path("/Users/{id}").Handler(h.handle(h.getUser)).Methods(http.MethodGet)
+	r.Path("/Users/{id}").Handler(h.handle(h.replaceUser)).Methods(http.MethodPut)
+	r.Path("/Users/{id}").Handler(h.handle(h.patchUser)).Methods(http.MethodPatch)
+	r.Path("/Users/{id}").Handler(h.handle(h.deleteUser)).Methods(http.MethodDelete)
+
+	r.Path("/Groups").Handler(h.handle(h.listGroups)).Methods(http.MethodGet)
+	r.Path("/Groups").Handler(h.handle(h.createGroup)).Methods(http.MethodPost)
+	r.Path("/Groups/{id}").Handler(h.handle(h.getGroup)).Methods(http.MethodGet)
+	r.Path("/Groups/{id}").Handler(h.handle(h.replaceGroup)).Methods(http.MethodPut)
+	r.Path("/Groups/{id}").Handler(h.handle(h.patchGroup)).Methods(http.MethodPatch)
+	r.Path("/Groups/{id}").Handler(h.handle(h.deleteGroup)).Methods(http.MethodDelete)
+}
+
+type handlerFunc = func(w http.ResponseWriter, r *http.Request, state *State) error
+
+// handle authenticates the request and renders any error as a SCIM error response.
+func (h *Handler) handle(f handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state, err := h.getState(r)
+		if err == nil {
+			err = authenticate(r, state)
+		}
+		if err == nil {
+			err = f(w, r, state)
+		}
+		if err != nil {
+			renderError(w, r, err)
+		}
+	})
+}
+
+func authenticate(r *http.Request, state *State) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || state.BearerToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(state.BearerToken)) != 1 {
+		return newError(http.StatusUnauthorized, "", "invalid bearer token")
+	}
+	return nil
+}
+
+func (h *Handler) serviceProviderConfig(w http.ResponseWriter, _ *http.Request, _ *State) error {
+	type supported struct {
+		Supported bool `json:"supported"`
+	}
+	type bulk struct {
+		supported
+		MaxOperations  int `json:"maxOperations"`
+		MaxPayloadSize int `json:"maxPayloadSize"`
+	}
+	type filter struct {
+		supported
+		MaxResults int `json:"maxResults"`
+	}
+	type authenticationScheme struct {
+		Type        string `json:"type"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	renderJSON(w, http.StatusOK, struct {
+		Schemas               []string               `json:"schemas"`
+		Patch                 supported              `json:"patch"`
+		Bulk                  bulk                   `json:"bulk"`
+		Filter                filter                 `json:"filter"`
+		ChangePassword        supported              `json:"changePassword"`
+		Sort                  supported              `json:"sort"`
+		ETag                  supported              `json:"etag"`
+		AuthenticationSchemes []authenticationScheme `json:"authenticationSchemes"`
+	}{
+		Schemas: []string{schemaServiceProviderConfig},
+		Patch:   supported{true},
+		Filter:  filter{supported{true}, maxCount},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication using the configured SCIM bearer token.",
+		}},
+	})
+	return nil
+}
+
+func (h *Handler) resourceTypes(w http.ResponseWriter, _ *http.Request, _ *State) error {
+	type resourceType struct {
+		Schemas  []string `json:"schemas"`
+		ID       string   `json:"id"`
+		Name     string   `json:"name"`
+		Endpoint string   `json:"endpoint"`
+		Schema   string   `json:"schema"`
+	}
+	resources := []any{
+		resourceType{[]string{schemaResourceType}, "User", "User", "/Users", schemaUser},
+		resourceType{[]string{schemaResourceType}, "Group", "Group", "/Groups", schemaGroup},
+	}
+	renderJSON(w, http.StatusOK, newListResponse(resources, len(resources), 1))
+	return nil
+}
+
+// An Error is a SCIM error, as defined in RFC 7644 section 3.12.
+type Error struct {
+	Status   int
+	SCIMType string
+	Detail   string
+}
+
+func newError(status int, scimType, format string, args ...any) *Error {
+	return &Error{Status: status, SCIMType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface.
+func (err *Error) Error() string {
+	return err.Detail
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, err error) {
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		log.Error(r.Context()).Err(err).Msg("scim: error handling request")
+		scimErr = newError(http.StatusInternalServerError, "", "internal server error")
+	}
+	if scimErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+	}
+	renderJSON(w, scimErr.Status, struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		SCIMType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{
+		Schemas:  []string{schemaError},
+		Status:   strconv.Itoa(scimErr.Status),
+		SCIMType: scimErr.SCIMType,
+		Detail:   scimErr.Detail,
+	})
+}
+
+func renderJSON(w http.ResponseWriter, code int, v any) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_, _ = w.Write(bs)
+}
+
+func readJSON(r *http.Request, v any) error {
+	bs, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(bs, v); err != nil {
+		return newError(http.StatusBadRequest, "invalidSyntax", "invalid request body: %v", err)
+	}
+	return nil
+}
+
+type listResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+func newListResponse(resources []any, totalResults, startIndex int) listResponse {
+	if resources == nil {
+		resources = []any{}
+	}
+	return listResponse{
+		Schemas:      []string{schemaListResponse},
+		TotalResults: totalResults,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// getPage returns the 1-based start index and the count of a list request.
+func getPage(r *http.Request) (startIndex, count int) {
+	startIndex, err := strconv.Atoi(r.FormValue("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err = strconv.Atoi(r.FormValue("count"))
+	if err != nil {
+		count = defaultCount
+	}
+	if count < 0 {
+		count = 0
+	} else if count > maxCount {
+		count = maxCount
+	}
+	return startIndex, count
+}
+
+// paginate returns the range of the page within n items.
+func paginate(n, startIndex, count int) (from, to int) {
+	from = startIndex - 1
+	if from > n {
+		from = n
+	}
+	to = from + count
+	if to > n {
+		to = n
+	}
+	return from, to
+}
+
+func getLocation(r *http.Request, resourceType, id string) string {
+	return urlutil.GetAbsoluteURL(r).ResolveReference(&url.URL{
+		Path: config.SCIMPath + resourceType + "/" + url.PathEscape(id),
+	}).String()
+}
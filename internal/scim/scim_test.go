@@ -0,0 +1,222 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	internal_databroker "github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+func newTestHandler(t *testing.T) (http.Handler, databroker.DataBrokerServiceClient) {
+	t.Helper()
+
+	li := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer()
+	databroker.RegisterDataBrokerServiceServer(srv, internal_databroker.New())
+	go func() { _ = srv.Serve(li) }()
+	t.Cleanup(srv.Stop)
+
+	cc, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return li.Dial()
+		}),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	client := databroker.NewDataBrokerServiceClient(cc)
+
+	h := New(func(_ *http.Request) (*State, error) {
+		return &State{Client: client, BearerToken: "TOKEN"}, nil
+	})
+	r := mux.NewRouter()
+	h.Mount(r.PathPrefix("/.pomerium/scim/v2").Subrouter())
+	return r, client
+}
+
+func doRequest(t *testing.T, h http.Handler, method, path, body string) (int, map[string]any) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "https://authenticate.example.com/.pomerium/scim/v2"+path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer TOKEN")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var res map[string]any
+	if w.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), w.Body.String())
+	}
+	return w.Code, res
+}
+
+func TestHandler_Unauthorized(t *testing.T) {
+	t.Parallel()
+
+	h, _ := newTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "https://authenticate.example.com/.pomerium/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer WRONG")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), schemaError)
+}
+
+func TestHandler_Users(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	h, client := newTestHandler(t)
+
+	code, res := doRequest(t, h, http.MethodPost, "/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"externalId": "U1",
+		"userName": "alice@example.com",
+		"name": {"givenName": "Alice", "familyName": "Smith"},
+		"active": true
+	}`)
+	require.Equal(t, http.StatusCreated, code, res)
+	assert.Equal(t, "U1", res["id"])
+
+	u, err := getUser(ctx, client, "U1")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice Smith", u.DisplayName)
+	assert.Equal(t, "alice@example.com", u.Email)
+	assert.True(t, u.Active)
+
+	code, _ = doRequest(t, h, http.MethodPost, "/Users", `{"externalId": "U1", "userName": "alice@example.com"}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, res = doRequest(t, h, http.MethodGet, "/Users?filter=userName+eq+%22ALICE@example.com%22", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 1, res["totalResults"])
+
+	code, res = doRequest(t, h, http.MethodGet, "/Users?filter=userName+eq+%22bob@example.com%22", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 0, res["totalResults"])
+
+	code, _ = doRequest(t, h, http.MethodGet, "/Users?filter=userName+co+%22alice%22", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	t.Run("deactivate", func(t *testing.T) {
+		_, err := databroker.Put(ctx, client, &session.Session{Id: "S1", UserId: "U1"})
+		require.NoError(t, err)
+
+		code, res := doRequest(t, h, http.MethodPatch, "/Users/U1", `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+		}`)
+		require.Equal(t, http.StatusOK, code, res)
+		assert.Equal(t, false, res["active"])
+
+		sessions, err := session.GetUserSessions(ctx, client, "U1")
+		require.NoError(t, err)
+		assert.Empty(t, sessions, "should revoke the sessions of deactivated users")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		code, _ := doRequest(t, h, http.MethodDelete, "/Users/U1", "")
+		assert.Equal(t, http.StatusNoContent, code)
+
+		code, _ = doRequest(t, h, http.MethodGet, "/Users/U1", "")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestHandler_Groups(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	h, client := newTestHandler(t)
+
+	for _, body := range []string{
+		`{"externalId": "U1", "userName": "alice@example.com"}`,
+		`{"externalId": "U2", "userName": "bob@example.com"}`,
+	} {
+		code, res := doRequest(t, h, http.MethodPost, "/Users", body)
+		require.Equal(t, http.StatusCreated, code, res)
+	}
+
+	code, res := doRequest(t, h, http.MethodPost, "/Groups", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"externalId": "G1",
+		"displayName": "Engineering",
+		"members": [{"value": "U1"}]
+	}`)
+	require.Equal(t, http.StatusCreated, code, res)
+	assert.Len(t, res["members"], 1)
+
+	u, err := getUser(ctx, client, "U1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"G1"}, u.GroupIDs)
+
+	code, res = doRequest(t, h, http.MethodPatch, "/Groups/G1", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "add", "path": "members", "value": [{"value": "U2"}]},
+			{"op": "remove", "path": "members[value eq \"U1\"]"},
+			{"op": "replace", "value": {"displayName": "Platform"}}
+		]
+	}`)
+	require.Equal(t, http.StatusOK, code, res)
+	assert.Equal(t, "Platform", res["displayName"])
+
+	members, err := listGroupMembers(ctx, client, "G1")
+	require.NoError(t, err)
+	if assert.Len(t, members, 1) {
+		assert.Equal(t, "U2", members[0].ID)
+	}
+
+	code, res = doRequest(t, h, http.MethodGet, "/Groups?filter=displayName+eq+%22Platform%22", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 1, res["totalResults"])
+
+	code, _ = doRequest(t, h, http.MethodDelete, "/Groups/G1", "")
+	assert.Equal(t, http.StatusNoContent, code)
+
+	u, err = getUser(ctx, client, "U2")
+	require.NoError(t, err)
+	assert.Empty(t, u.GroupIDs)
+}
+
+func TestParsePath(t *testing.T) {
+	t.Parallel()
+
+	p, err := parsePath("name.givenName", schemaUser)
+	require.NoError(t, err)
+	assert.True(t, p.is("name"))
+	assert.Equal(t, "givenName", p.subAttribute)
+
+	p, err = parsePath(`members[value eq "2819c223"]`, schemaGroup)
+	require.NoError(t, err)
+	assert.True(t, p.is("members"))
+	assert.Equal(t, &filter{attribute: "value", value: "2819c223"}, p.valueFilter)
+
+	p, err = parsePath(`emails[type eq "work"].value`, schemaUser)
+	require.NoError(t, err)
+	assert.True(t, p.is("emails"))
+	assert.Equal(t, "value", p.subAttribute)
+
+	p, err = parsePath("urn:ietf:params:scim:schemas:core:2.0:User:userName", schemaUser)
+	require.NoError(t, err)
+	assert.True(t, p.is("userName"))
+
+	p, err = parsePath("urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", schemaUser)
+	require.NoError(t, err)
+	assert.False(t, p.is("department"))
+
+	_, err = parsePath(`members[value co "2819c223"]`, schemaGroup)
+	assert.Error(t, err)
+}
@@ -0,0 +1,412 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pomerium/datasource/pkg/directory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+type userResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	UserName    string       `json:"userName"`
+	Name        *nameValue   `json:"name,omitempty"`
+	DisplayName string       `json:"displayName,omitempty"`
+	Emails      []multiValue `json:"emails,omitempty"`
+	Active      *boolValue   `json:"active,omitempty"`
+	Groups      []reference  `json:"groups,omitempty"`
+	Meta        *meta        `json:"meta,omitempty"`
+}
+
+type nameValue struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type multiValue struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+func newUserResource(r *http.Request, u *userRecord) *userResource {
+	active := boolValue(u.Active)
+	res := &userResource{
+		Schemas:     []string{schemaUser},
+		ID:          u.ID,
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.DisplayName,
+		Active:      &active,
+		Meta: &meta{
+			ResourceType: "User",
+			Created:      u.Created,
+			LastModified: u.LastModified,
+			Location:     getLocation(r, "Users", u.ID),
+		},
+	}
+	if u.GivenName != "" || u.FamilyName != "" {
+		res.Name = &nameValue{GivenName: u.GivenName, FamilyName: u.FamilyName}
+	}
+	if u.Email != "" {
+		res.Emails = []multiValue{{Value: u.Email, Primary: true}}
+	}
+	for _, groupID := range u.GroupIDs {
+		res.Groups = append(res.Groups, reference{
+			Value: groupID,
+			Ref:   getLocation(r, "Groups", groupID),
+		})
+	}
+	return res
+}
+
+// apply sets the fields of the user record from the user resource.
+func (res *userResource) apply(u *userRecord) {
+	u.UserName = res.UserName
+	u.ExternalID = res.ExternalID
+	u.GivenName, u.FamilyName = "", ""
+	if res.Name != nil {
+		u.GivenName, u.FamilyName = res.Name.GivenName, res.Name.FamilyName
+	}
+
+	u.DisplayName = res.DisplayName
+	if u.DisplayName == "" && res.Name != nil {
+		u.DisplayName = res.Name.Formatted
+		if u.DisplayName == "" {
+			u.DisplayName = strings.TrimSpace(res.Name.GivenName + " " + res.Name.FamilyName)
+		}
+	}
+
+	// use the primary email, falling back to the first email and then the user name
+	u.Email = ""
+	for i, email := range res.Emails {
+		if email.Primary || i == 0 {
+			u.Email = email.Value
+		}
+	}
+	if u.Email == "" && strings.Contains(res.UserName, "@") {
+		u.Email = res.UserName
+	}
+
+	// users are active unless deactivated
+	u.Active = res.Active == nil || bool(*res.Active)
+}
+
+// patch applies a PATCH operation to the user resource. Unknown and read-only attributes are
+// ignored.
+func (res *userResource) patch(op patchOperation) error {
+	path, err := parsePath(op.Path, schemaUser)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case path.is("userName"):
+		res.UserName, err = op.stringValue()
+	case path.is("externalId"):
+		res.ExternalID, err = op.stringValue()
+	case path.is("displayName"):
+		res.DisplayName, err = op.stringValue()
+	case path.is("active"):
+		if op.Op == patchOpRemove {
+			res.Active = nil
+		} else {
+			err = unmarshalValue(op, &res.Active)
+		}
+	case path.is("name"):
+		if res.Name == nil {
+			res.Name = new(nameValue)
+		}
+		switch {
+		case strings.EqualFold(path.subAttribute, "formatted"):
+			res.Name.Formatted, err = op.stringValue()
+		case strings.EqualFold(path.subAttribute, "givenName"):
+			res.Name.GivenName, err = op.stringValue()
+		case strings.EqualFold(path.subAttribute, "familyName"):
+			res.Name.FamilyName, err = op.stringValue()
+		case path.subAttribute == "" && op.Op == patchOpRemove:
+			res.Name = nil
+		case path.subAttribute == "":
+			err = unmarshalValue(op, res.Name)
+		}
+	case path.is("emails"):
+		switch {
+		case op.Op == patchOpRemove:
+			res.Emails = nil
+		case path.valueFilter != nil || path.subAttribute != "":
+			// only a single email is stored, so any email replaces it
+			var email string
+			email, err = op.stringValue()
+			res.Emails = []multiValue{{Value: email, Primary: true}}
+		case op.Op == patchOpAdd:
+			var emails []multiValue
+			err = unmarshalValue(op, &emails)
+			res.Emails = append(emails, res.Emails...)
+		default:
+			err = unmarshalValue(op, &res.Emails)
+		}
+	}
+	return err
+}
+
+func unmarshalValue(op patchOperation, v any) error {
+	if err := json.Unmarshal(op.Value, v); err != nil {
+		return newError(http.StatusBadRequest, "invalidValue", "invalid value for %s: %v", op.Path, err)
+	}
+	return nil
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request, state *State) error {
+	f, err := parseFilter(r.FormValue("filter"))
+	if err != nil {
+		return err
+	}
+
+	var match func(u *userRecord) bool
+	var query string
+	if f != nil {
+		query = f.value
+		switch {
+		case strings.EqualFold(f.attribute, "id"):
+			match = func(u *userRecord) bool { return u.ID == f.value }
+		case strings.EqualFold(f.attribute, "externalId"):
+			match = func(u *userRecord) bool { return u.ExternalID == f.value }
+		case strings.EqualFold(f.attribute, "userName"):
+			match = func(u *userRecord) bool { return strings.EqualFold(u.UserName, f.value) }
+		case strings.EqualFold(f.attribute, "displayName"):
+			match = func(u *userRecord) bool { return strings.EqualFold(u.DisplayName, f.value) }
+		case strings.EqualFold(f.attribute, "emails"), strings.EqualFold(f.attribute, "emails.value"):
+			match = func(u *userRecord) bool { return strings.EqualFold(u.Email, f.value) }
+		default:
+			return newError(http.StatusBadRequest, "invalidFilter", "unsupported filter attribute: %s", f.attribute)
+		}
+	}
+
+	users, err := listUsers(r.Context(), state.Client, query)
+	if err != nil {
+		return err
+	}
+	if match != nil {
+		var matched []*userRecord
+		for _, u := range users {
+			if match(u) {
+				matched = append(matched, u)
+			}
+		}
+		users = matched
+	}
+	sortUsers(users)
+
+	startIndex, count := getPage(r)
+	from, to := paginate(len(users), startIndex, count)
+	var resources []any
+	for _, u := range users[from:to] {
+		resources = append(resources, newUserResource(r, u))
+	}
+	renderJSON(w, http.StatusOK, newListResponse(resources, len(users), startIndex))
+	return nil
+}
+
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request, state *State) error {
+	u, err := h.loadUser(r.Context(), state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	renderJSON(w, http.StatusOK, newUserResource(r, u))
+	return nil
+}
+
+// createUser creates a user. The user id should be the subject of the user at the identity
+// provider, so the external id is used if set.
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	var res userResource
+	if err := readJSON(r, &res); err != nil {
+		return err
+	}
+	if res.UserName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	userID := res.ExternalID
+	if userID == "" {
+		userID = uuid.NewString()
+	}
+
+	now := time.Now()
+	u, err := getUser(ctx, state.Client, userID)
+	if status.Code(err) == codes.NotFound {
+		u = &userRecord{ID: userID, Created: now}
+	} else if err != nil {
+		return err
+	} else if u.UserName != "" {
+		return newError(http.StatusConflict, "uniqueness", "user %s already exists", userID)
+	}
+	// users previously synced from a directory are adopted, keeping their groups
+	if u.Created.IsZero() {
+		u.Created = now
+	}
+
+	if err := checkUserNameAvailable(ctx, state.Client, res.UserName, userID); err != nil {
+		return err
+	}
+
+	res.apply(u)
+	u.LastModified = now
+	if err := h.saveUser(ctx, state.Client, u); err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", getLocation(r, "Users", u.ID))
+	renderJSON(w, http.StatusCreated, newUserResource(r, u))
+	return nil
+}
+
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	u, err := h.loadUser(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	var res userResource
+	if err := readJSON(r, &res); err != nil {
+		return err
+	}
+	if res.UserName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	if err := checkUserNameAvailable(ctx, state.Client, res.UserName, u.ID); err != nil {
+		return err
+	}
+
+	res.apply(u)
+	u.LastModified = time.Now()
+	if err := h.saveUser(ctx, state.Client, u); err != nil {
+		return err
+	}
+
+	renderJSON(w, http.StatusOK, newUserResource(r, u))
+	return nil
+}
+
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	u, err := h.loadUser(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	ops, err := readPatchRequest(r)
+	if err != nil {
+		return err
+	}
+
+	res := newUserResource(r, u)
+	for _, op := range ops {
+		if err := res.patch(op); err != nil {
+			return err
+		}
+	}
+	if res.UserName == "" {
+		return newError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+	if !strings.EqualFold(res.UserName, u.UserName) {
+		if err := checkUserNameAvailable(ctx, state.Client, res.UserName, u.ID); err != nil {
+			return err
+		}
+	}
+
+	res.apply(u)
+	u.LastModified = time.Now()
+	if err := h.saveUser(ctx, state.Client, u); err != nil {
+		return err
+	}
+
+	renderJSON(w, http.StatusOK, newUserResource(r, u))
+	return nil
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request, state *State) error {
+	ctx := r.Context()
+
+	u, err := h.loadUser(ctx, state.Client, mux.Vars(r)["id"])
+	if err != nil {
+		return err
+	}
+
+	if err := deleteRecord(ctx, state.Client, directory.UserRecordType, u.ID); err != nil {
+		return err
+	}
+	if err := revokeUserSessions(ctx, state.Client, u.ID); err != nil {
+		return err
+	}
+	log.Info(ctx).Str("user-id", u.ID).Msg("scim: user deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) loadUser(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) (*userRecord, error) {
+	u, err := getUser(ctx, client, userID)
+	if status.Code(err) == codes.NotFound {
+		return nil, newError(http.StatusNotFound, "", "user %s not found", userID)
+	} else if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// saveUser saves the user, revoking the sessions of deactivated users.
+func (h *Handler) saveUser(ctx context.Context, client databroker.DataBrokerServiceClient, u *userRecord) error {
+	if err := putUser(ctx, client, u); err != nil {
+		return err
+	}
+	if !u.Active {
+		return revokeUserSessions(ctx, client, u.ID)
+	}
+	return nil
+}
+
+func checkUserNameAvailable(ctx context.Context, client databroker.DataBrokerServiceClient, userName, userID string) error {
+	users, err := listUsers(ctx, client, userName)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.ID != userID && strings.EqualFold(u.UserName, userName) {
+			return newError(http.StatusConflict, "uniqueness", "userName %s is already in use", userName)
+		}
+	}
+	return nil
+}
@@ -35,10 +35,14 @@ const (
 	MaxNumChunks = 5
 )
 
+// ErrTooLarge is the error for when a session doesn't fit in the maximum number of cookie chunks.
+var ErrTooLarge = errors.New("internal/sessions: session is too large for cookies")
+
 // Options holds options for Store
 type Options struct {
 	Name     string
 	Domain   string
+	Path     string
 	Expire   time.Duration
 	HTTPOnly bool
 	Secure   bool
@@ -48,9 +52,12 @@ type Options struct {
 // A GetOptionsFunc is a getter for cookie options.
 type GetOptionsFunc func() Options
 
+// A GetRequestOptionsFunc is a getter for the cookie options of a request.
+type GetRequestOptionsFunc func(r *http.Request) Options
+
 // Store implements the session store interface for session cookies.
 type Store struct {
-	getOptions GetOptionsFunc
+	getOptions GetRequestOptionsFunc
 	encoder    encoding.Marshaler
 	decoder    encoding.Unmarshaler
 }
@@ -58,7 +65,13 @@ type Store struct {
 // NewStore returns a new store that implements the SessionStore interface
 // using http cookies.
 func NewStore(getOptions GetOptionsFunc, encoder encoding.MarshalUnmarshaler) (sessions.SessionStore, error) {
-	cs, err := NewCookieLoader(getOptions, encoder)
+	return NewRequestStore(func(_ *http.Request) Options { return getOptions() }, encoder)
+}
+
+// NewRequestStore returns a new store that implements the SessionStore interface
+// using http cookies, with options that depend on the request.
+func NewRequestStore(getOptions GetRequestOptionsFunc, encoder encoding.MarshalUnmarshaler) (sessions.SessionStore, error) {
+	cs, err := newCookieLoader(getOptions, encoder)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +82,16 @@ func NewStore(getOptions GetOptionsFunc, encoder encoding.MarshalUnmarshaler) (s
 // NewCookieLoader returns a new store that implements the SessionLoader
 // interface using http cookies.
 func NewCookieLoader(getOptions GetOptionsFunc, dencoder encoding.Unmarshaler) (*Store, error) {
+	return newCookieLoader(func(_ *http.Request) Options { return getOptions() }, dencoder)
+}
+
+// NewRequestCookieLoader returns a new store that implements the SessionLoader
+// interface using http cookies, with options that depend on the request.
+func NewRequestCookieLoader(getOptions GetRequestOptionsFunc, dencoder encoding.Unmarshaler) (*Store, error) {
+	return newCookieLoader(getOptions, dencoder)
+}
+
+func newCookieLoader(getOptions GetRequestOptionsFunc, dencoder encoding.Unmarshaler) (*Store, error) {
 	if dencoder == nil {
 		return nil, fmt.Errorf("internal/sessions: dencoder cannot be nil")
 	}
@@ -77,18 +100,22 @@ func NewCookieLoader(getOptions GetOptionsFunc, dencoder encoding.Unmarshaler) (
 	return cs, nil
 }
 
-func newStore(getOptions GetOptionsFunc) *Store {
+func newStore(getOptions GetRequestOptionsFunc) *Store {
 	return &Store{
 		getOptions: getOptions,
 	}
 }
 
-func (cs *Store) makeCookie(value string) *http.Cookie {
-	opts := cs.getOptions()
+func (cs *Store) makeCookie(r *http.Request, value string) *http.Cookie {
+	opts := cs.getOptions(r)
+	path := opts.Path
+	if path == "" {
+		path = "/"
+	}
 	return &http.Cookie{
 		Name:     opts.Name,
 		Value:    value,
-		Path:     "/",
+		Path:     path,
 		Domain:   opts.Domain,
 		HttpOnly: opts.HTTPOnly,
 		Secure:   opts.Secure,
@@ -97,12 +124,21 @@ func (cs *Store) makeCookie(value string) *http.Cookie {
 	}
 }
 
-// ClearSession clears the session cookie from a request
-func (cs *Store) ClearSession(w http.ResponseWriter, _ *http.Request) {
-	c := cs.makeCookie("")
+// ClearSession clears the session cookie, and any chunks of it, from a request
+func (cs *Store) ClearSession(w http.ResponseWriter, r *http.Request) {
+	c := cs.makeCookie(r, "")
 	c.MaxAge = -1
 	c.Expires = timeNow().Add(-time.Hour)
 	http.SetCookie(w, c)
+	for i := 1; i <= MaxNumChunks; i++ {
+		name := fmt.Sprintf("%s_%d", c.Name, i)
+		if _, err := r.Cookie(name); err != nil {
+			break
+		}
+		nc := *c
+		nc.Name = name
+		http.SetCookie(w, &nc)
+	}
 }
 
 func getCookies(r *http.Request, name string) []*http.Cookie {
@@ -118,7 +154,7 @@ func getCookies(r *http.Request, name string) []*http.Cookie {
 
 // LoadSession returns a State from the cookie in the request.
 func (cs *Store) LoadSession(r *http.Request) (string, error) {
-	opts := cs.getOptions()
+	opts := cs.getOptions(r)
 	cookies := getCookies(r, opts.Name)
 	if len(cookies) == 0 {
 		return "", sessions.ErrNoSessionFound
@@ -136,7 +172,7 @@ func (cs *Store) LoadSession(r *http.Request) (string, error) {
 }
 
 // SaveSession saves a session state to a request's cookie store.
-func (cs *Store) SaveSession(w http.ResponseWriter, _ *http.Request, x interface{}) error {
+func (cs *Store) SaveSession(w http.ResponseWriter, r *http.Request, x interface{}) error {
 	var value string
 	switch v := x.(type) {
 	case []byte:
@@ -154,20 +190,24 @@ func (cs *Store) SaveSession(w http.ResponseWriter, _ *http.Request, x interface
 		value = string(data)
 	}
 
-	cs.setSessionCookie(w, value)
-	return nil
+	return cs.setSessionCookie(w, r, value)
 }
 
-func (cs *Store) setSessionCookie(w http.ResponseWriter, val string) {
-	cs.setCookie(w, cs.makeCookie(val))
+func (cs *Store) setSessionCookie(w http.ResponseWriter, r *http.Request, val string) error {
+	return cs.setCookie(w, cs.makeCookie(r, val))
 }
 
-func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
+func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) error {
 	if len(cookie.String()) <= MaxChunkSize {
 		http.SetCookie(w, cookie)
-		return
+		return nil
 	}
-	for i, c := range chunk(cookie.Value, MaxChunkSize) {
+	chunks := chunk(cookie.Value, MaxChunkSize)
+	// the first chunk has no suffix, so there can be one more than the maximum number of chunks
+	if len(chunks) > MaxNumChunks+1 {
+		return ErrTooLarge
+	}
+	for i, c := range chunks {
 		// start with a copy of our original cookie
 		nc := *cookie
 		if i == 0 {
@@ -180,6 +220,7 @@ func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
 		}
 		http.SetCookie(w, &nc)
 	}
+	return nil
 }
 
 func loadChunkedCookie(r *http.Request, c *http.Cookie) string {
@@ -32,7 +32,7 @@ func TestNewStore(t *testing.T) {
 		want    sessions.SessionStore
 		wantErr bool
 	}{
-		{"good", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, &Store{getOptions: func() Options {
+		{"good", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, &Store{getOptions: func(_ *http.Request) Options {
 			return Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}
 		}}, false},
 		{"missing encoder", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, nil, nil, true},
@@ -68,7 +68,7 @@ func TestNewCookieLoader(t *testing.T) {
 		want    *Store
 		wantErr bool
 	}{
-		{"good", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, &Store{getOptions: func() Options {
+		{"good", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, &Store{getOptions: func(_ *http.Request) Options {
 			return Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}
 		}}, false},
 		{"missing encoder", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, nil, nil, true},
@@ -121,7 +121,7 @@ func TestStore_SaveSession(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Store{
-				getOptions: func() Options {
+				getOptions: func(_ *http.Request) Options {
 					return Options{
 						Name:     "_pomerium",
 						Secure:   true,
@@ -170,3 +170,59 @@ func TestStore_SaveSession(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRequestStore(t *testing.T) {
+	key := cryptutil.NewKey()
+	encoder, err := jws.NewHS256Signer(key)
+	require.NoError(t, err)
+
+	s, err := NewRequestStore(func(r *http.Request) Options {
+		if r.Host == "admin.example.com" {
+			return Options{Name: "_admin", Domain: "admin.example.com", Path: "/app"}
+		}
+		return Options{Name: "_pomerium", Domain: "example.com"}
+	}, encoder)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	s.SaveSession(w, httptest.NewRequest(http.MethodGet, "https://admin.example.com/app", nil), &sessions.State{ID: "xyz"})
+	x := w.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(x, "_admin=") || !strings.Contains(x, "Path=/app;") || !strings.Contains(x, "Domain=admin.example.com;") {
+		t.Errorf("unexpected admin cookie: %s", x)
+	}
+
+	w = httptest.NewRecorder()
+	s.SaveSession(w, httptest.NewRequest(http.MethodGet, "https://www.example.com/", nil), &sessions.State{ID: "xyz"})
+	x = w.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(x, "_pomerium=") || !strings.Contains(x, "Path=/;") {
+		t.Errorf("unexpected default cookie: %s", x)
+	}
+}
+
+func TestStore_Chunks(t *testing.T) {
+	s := newStore(func(_ *http.Request) Options {
+		return Options{Name: "_pomerium", Domain: "pomerium.io"}
+	})
+
+	w := httptest.NewRecorder()
+	err := s.SaveSession(w, httptest.NewRequest(http.MethodGet, "/", nil), strings.Repeat("a", MaxChunkSize*(MaxNumChunks+1)+1))
+	require.ErrorIs(t, err, ErrTooLarge)
+
+	w = httptest.NewRecorder()
+	err = s.SaveSession(w, httptest.NewRequest(http.MethodGet, "/", nil), strings.Repeat("a", MaxChunkSize*2+1))
+	require.NoError(t, err)
+	require.Len(t, w.Result().Cookies(), 3)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	w = httptest.NewRecorder()
+	s.ClearSession(w, r)
+	cleared := w.Result().Cookies()
+	require.Len(t, cleared, 3)
+	for _, cookie := range cleared {
+		require.Empty(t, cookie.Value)
+		require.Equal(t, -1, cookie.MaxAge)
+	}
+}
@@ -3,6 +3,7 @@ package sessions
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/go-jose/go-jose/v3/jwt"
@@ -36,6 +37,17 @@ type State struct {
 
 	// IdentityProviderID is the identity provider for the session.
 	IdentityProviderID string `json:"idp_id,omitempty"`
+
+	// StatelessRecords are the encrypted databroker records of the session when using stateless
+	// sessions.
+	StatelessRecords []byte `json:"stateless_records,omitempty"`
+
+	// RouteAudience restricts the session to the routes with these hostnames, such as for tokens
+	// issued by the programmatic login api. If empty, the session can be used for any route.
+	RouteAudience []string `json:"route_aud,omitempty"`
+	// ExpiresAt is when the session token expires, for short-lived tokens such as kubernetes
+	// credentials. If unset, the token is valid for as long as the session.
+	ExpiresAt *jwt.NumericDate `json:"expires_at,omitempty"`
 }
 
 // NewState creates a new State.
@@ -67,6 +79,24 @@ func (s *State) UserID() string {
 	return s.Subject
 }
 
+// AllowsRoute returns true if the session can be used for a route with the given hostname.
+func (s *State) AllowsRoute(hostname string) bool {
+	if len(s.RouteAudience) == 0 {
+		return true
+	}
+	for _, aud := range s.RouteAudience {
+		if strings.EqualFold(aud, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired returns true if the session token has expired.
+func (s *State) IsExpired(now time.Time) bool {
+	return s.ExpiresAt != nil && now.After(s.ExpiresAt.Time())
+}
+
 // UnmarshalJSON returns a State struct from JSON. Additionally munges
 // a user's session by using by setting `user` claim to `sub` if empty.
 func (s *State) UnmarshalJSON(data []byte) error {
@@ -64,3 +64,38 @@ func TestState_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestState_AllowsRoute(t *testing.T) {
+	t.Parallel()
+
+	s := &State{ID: "xyz"}
+	if !s.AllowsRoute("api.example.com") {
+		t.Error("sessions without a route audience should allow every route")
+	}
+
+	s.RouteAudience = []string{"api.example.com"}
+	if !s.AllowsRoute("API.example.com") {
+		t.Error("expected the route in the audience to be allowed")
+	}
+	if s.AllowsRoute("admin.example.com") {
+		t.Error("expected a route outside the audience to be denied")
+	}
+}
+
+func TestState_IsExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	s := &State{ID: "xyz"}
+	if s.IsExpired(now) {
+		t.Error("sessions without an expiry should never expire")
+	}
+
+	s.ExpiresAt = jwt.NewNumericDate(now.Add(time.Minute))
+	if s.IsExpired(now) {
+		t.Error("expected the session not to be expired yet")
+	}
+	if !s.IsExpired(now.Add(2 * time.Minute)) {
+		t.Error("expected the session to be expired")
+	}
+}
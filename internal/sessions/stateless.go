@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// ErrNoStatelessRecords is the error for a session state without stateless records.
+var ErrNoStatelessRecords = errors.New("session has no stateless records")
+
+// SetStatelessRecords encrypts the databroker records of the session, like the session and user
+// records, into the session state, so they can be used without fetching them from the databroker.
+func (s *State) SetStatelessRecords(aead cipher.AEAD, msgs ...proto.Message) error {
+	var raw [][]byte
+	for _, msg := range msgs {
+		a, err := anypb.New(msg)
+		if err != nil {
+			return err
+		}
+		bs, err := proto.Marshal(a)
+		if err != nil {
+			return err
+		}
+		raw = append(raw, bs)
+	}
+
+	bs, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	s.StatelessRecords = cryptutil.Encrypt(aead, bs, []byte(s.ID))
+	return nil
+}
+
+// GetStatelessRecords decrypts the databroker records stored in the session state.
+func (s *State) GetStatelessRecords(aead cipher.AEAD) ([]proto.Message, error) {
+	if len(s.StatelessRecords) == 0 {
+		return nil, ErrNoStatelessRecords
+	}
+
+	bs, err := cryptutil.Decrypt(aead, s.StatelessRecords, []byte(s.ID))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stateless records: %w", err)
+	}
+
+	var raw [][]byte
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return nil, fmt.Errorf("invalid stateless records: %w", err)
+	}
+
+	msgs := make([]proto.Message, 0, len(raw))
+	for _, r := range raw {
+		var a anypb.Any
+		if err := proto.Unmarshal(r, &a); err != nil {
+			return nil, fmt.Errorf("invalid stateless records: %w", err)
+		}
+		msg, err := a.UnmarshalNew()
+		if err != nil {
+			return nil, fmt.Errorf("invalid stateless records: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,44 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestState_StatelessRecords(t *testing.T) {
+	t.Parallel()
+
+	aead, err := cryptutil.NewAEADCipher(cryptutil.NewKey())
+	require.NoError(t, err)
+
+	s := &State{ID: "SESSION_ID"}
+	_, err = s.GetStatelessRecords(aead)
+	assert.ErrorIs(t, err, ErrNoStatelessRecords)
+
+	msgs := []proto.Message{
+		wrapperspb.String("RECORD"),
+		structpb.NewStringValue("VALUE"),
+	}
+	require.NoError(t, s.SetStatelessRecords(aead, msgs...))
+
+	actual, err := s.GetStatelessRecords(aead)
+	require.NoError(t, err)
+	assert.Empty(t, cmp.Diff(msgs, actual, protocmp.Transform()))
+
+	t.Run("other session", func(t *testing.T) {
+		t.Parallel()
+
+		other := &State{ID: "OTHER_SESSION_ID", StatelessRecords: s.StatelessRecords}
+		_, err := other.GetStatelessRecords(aead)
+		assert.Error(t, err, "should bind the records to the session id")
+	})
+}
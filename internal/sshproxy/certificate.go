@@ -0,0 +1,75 @@
+package sshproxy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// clockSkew is subtracted from the start of a certificate's validity period to allow for clock drift
+// between pomerium and the upstream server.
+const clockSkew = time.Minute
+
+// NewCertificateSigner creates a signer for a new ephemeral key and a user certificate for it, signed by
+// the given certificate authority. The certificate is valid for the given principals until the ttl expires.
+func NewCertificateSigner(ca ssh.Signer, keyID string, principals []string, ttl time.Duration) (ssh.Signer, *ssh.Certificate, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sshproxy: error generating key: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sshproxy: error creating signer: %w", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sshproxy: error creating public key: %w", err)
+	}
+
+	cert, err := NewCertificate(ca, sshPub, keyID, principals, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sshproxy: error creating certificate signer: %w", err)
+	}
+	return certSigner, cert, nil
+}
+
+// NewCertificate creates a user certificate for the given public key, signed by the given certificate
+// authority. The certificate is valid for the given principals until the ttl expires.
+func NewCertificate(ca ssh.Signer, key ssh.PublicKey, keyID string, principals []string, ttl time.Duration) (*ssh.Certificate, error) {
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("sshproxy: error generating serial: %w", err)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-clockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		return nil, fmt.Errorf("sshproxy: error signing certificate: %w", err)
+	}
+	return cert, nil
+}
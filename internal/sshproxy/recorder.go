@@ -0,0 +1,101 @@
+package sshproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A recorder records a session channel in the asciicast v2 format.
+// See https://docs.asciinema.org/manual/asciicast/v2/.
+type recorder struct {
+	mu    sync.Mutex
+	w     io.WriteCloser
+	start time.Time
+}
+
+type recordingHeader struct {
+	Version   int               `json:"version"`
+	Width     uint32            `json:"width"`
+	Height    uint32            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+func newRecorder(dir, name, title string) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("sshproxy: error creating recording directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("sshproxy: error creating recording: %w", err)
+	}
+	return newRecorderForWriter(f, time.Now(), title)
+}
+
+func newRecorderForWriter(w io.WriteCloser, start time.Time, title string) (*recorder, error) {
+	r := &recorder{w: w, start: start}
+	err := r.writeJSON(recordingHeader{
+		Version:   2,
+		Width:     80,
+		Height:    24,
+		Timestamp: start.Unix(),
+		Title:     title,
+	})
+	if err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// resize records a change to the terminal size.
+func (r *recorder) resize(width, height uint32) {
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+// writer returns an io.Writer which records data as events of the given type.
+func (r *recorder) writer(typ string) io.Writer {
+	return recorderWriter{r: r, typ: typ}
+}
+
+func (r *recorder) event(typ, data string) {
+	elapsed := time.Since(r.start).Seconds()
+	_ = r.writeJSON([]any{elapsed, typ, data})
+}
+
+func (r *recorder) writeJSON(v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.w.Write(bs)
+	return err
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.w.Close()
+}
+
+type recorderWriter struct {
+	r   *recorder
+	typ string
+}
+
+func (w recorderWriter) Write(p []byte) (int, error) {
+	w.r.event(w.typ, string(p))
+	return len(p), nil
+}
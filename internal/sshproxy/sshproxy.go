@@ -0,0 +1,312 @@
+// Package sshproxy terminates ssh connections for ssh routes and proxies them to the upstream server,
+// authenticating with short-lived certificates minted for the user.
+package sshproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// DefaultCertificateTTL is how long user certificates are valid for if no ttl is set.
+const DefaultCertificateTTL = 5 * time.Minute
+
+const dialTimeout = 10 * time.Second
+
+var (
+	errPrincipalNotAllowed = errors.New("sshproxy: principal not allowed")
+	errUnknownHostKey      = errors.New("sshproxy: unknown upstream host key")
+)
+
+// Config is the configuration for a proxied ssh connection.
+type Config struct {
+	// HostKey is the host key presented to the client.
+	HostKey ssh.Signer
+	// UserCAKey is the certificate authority used to sign user certificates.
+	UserCAKey ssh.Signer
+	// CertificateTTL is how long user certificates are valid for.
+	CertificateTTL time.Duration
+	// RecordingDir is the directory sessions are recorded to. If empty, sessions are not recorded.
+	RecordingDir string
+
+	// UpstreamAddr is the address of the upstream ssh server.
+	UpstreamAddr string
+	// UpstreamHostKeys are the host keys accepted from the upstream ssh server. If empty, any key is accepted.
+	UpstreamHostKeys []ssh.PublicKey
+
+	// UserID is the id of the authenticated user.
+	UserID string
+	// Email is the email address of the authenticated user.
+	Email string
+	// Principals are the usernames the user may log in as.
+	Principals []string
+}
+
+func (cfg *Config) authorize(md ssh.ConnMetadata) (*ssh.Permissions, error) {
+	for _, p := range cfg.Principals {
+		if p == md.User() {
+			return nil, nil
+		}
+	}
+	return nil, errPrincipalNotAllowed
+}
+
+func (cfg *Config) getCertificateTTL() time.Duration {
+	if cfg.CertificateTTL > 0 {
+		return cfg.CertificateTTL
+	}
+	return DefaultCertificateTTL
+}
+
+func (cfg *Config) getKeyID() string {
+	if cfg.Email != "" {
+		return cfg.Email
+	}
+	return cfg.UserID
+}
+
+func (cfg *Config) hostKeyCallback() ssh.HostKeyCallback {
+	if len(cfg.UpstreamHostKeys) == 0 {
+		return ssh.InsecureIgnoreHostKey() //nolint:gosec
+	}
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		for _, k := range cfg.UpstreamHostKeys {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return errUnknownHostKey
+	}
+}
+
+// Serve serves an ssh connection from a client. The client is authenticated by pomerium before the
+// connection is established, so any public key or keyboard-interactive authentication attempt succeeds
+// as long as the requested user is one of the allowed principals.
+func Serve(ctx context.Context, conn net.Conn, cfg *Config) error {
+	serverConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(md ssh.ConnMetadata, _ ssh.PublicKey) (*ssh.Permissions, error) {
+			return cfg.authorize(md)
+		},
+		KeyboardInteractiveCallback: func(md ssh.ConnMetadata, _ ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			return cfg.authorize(md)
+		},
+	}
+	serverConfig.AddHostKey(cfg.HostKey)
+
+	downstream, downstreamChans, downstreamReqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("sshproxy: error establishing client connection: %w", err)
+	}
+	defer downstream.Close()
+
+	signer, cert, err := NewCertificateSigner(cfg.UserCAKey, cfg.getKeyID(),
+		[]string{downstream.User()}, cfg.getCertificateTTL())
+	if err != nil {
+		return err
+	}
+
+	upstreamConn, err := net.DialTimeout("tcp", cfg.UpstreamAddr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("sshproxy: error connecting to upstream: %w", err)
+	}
+	upstream, upstreamChans, upstreamReqs, err := ssh.NewClientConn(upstreamConn, cfg.UpstreamAddr, &ssh.ClientConfig{
+		User:            downstream.User(),
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: cfg.hostKeyCallback(),
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		_ = upstreamConn.Close()
+		return fmt.Errorf("sshproxy: error establishing upstream connection: %w", err)
+	}
+	defer upstream.Close()
+
+	s := &session{
+		cfg:       cfg,
+		id:        hex.EncodeToString(downstream.SessionID()[:8]),
+		principal: downstream.User(),
+		start:     time.Now(),
+	}
+	ctx = log.WithContext(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("ssh_session_id", s.id).
+			Str("user_id", cfg.UserID).
+			Str("email", cfg.Email).
+			Str("principal", s.principal).
+			Str("upstream", cfg.UpstreamAddr)
+	})
+	log.Info(ctx).Uint64("certificate_serial", cert.Serial).Msg("sshproxy: session started")
+
+	go forwardGlobalRequests(downstreamReqs, upstream)
+	go forwardGlobalRequests(upstreamReqs, downstream)
+	go func() {
+		for newChannel := range upstreamChans {
+			go s.proxyChannel(ctx, newChannel, downstream, false)
+		}
+	}()
+	go func() {
+		for newChannel := range downstreamChans {
+			go s.proxyChannel(ctx, newChannel, upstream, true)
+		}
+	}()
+
+	// wait for either side to disconnect
+	done := make(chan struct{}, 2)
+	go func() { _ = downstream.Wait(); done <- struct{}{} }()
+	go func() { _ = upstream.Wait(); done <- struct{}{} }()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	log.Info(ctx).Dur("duration", time.Since(s.start)).Msg("sshproxy: session ended")
+	return nil
+}
+
+type session struct {
+	cfg       *Config
+	id        string
+	principal string
+	start     time.Time
+	channels  atomic.Uint64
+}
+
+type channelOpener interface {
+	OpenChannel(name string, data []byte) (ssh.Channel, <-chan *ssh.Request, error)
+}
+
+// proxyChannel opens a channel of the same type on dst and copies data and requests between them.
+func (s *session) proxyChannel(ctx context.Context, newChannel ssh.NewChannel, dst channelOpener, fromClient bool) {
+	dstChannel, dstReqs, err := dst.OpenChannel(newChannel.ChannelType(), newChannel.ExtraData())
+	if err != nil {
+		var openErr *ssh.OpenChannelError
+		if errors.As(err, &openErr) {
+			_ = newChannel.Reject(openErr.Reason, openErr.Message)
+		} else {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		}
+		return
+	}
+	srcChannel, srcReqs, err := newChannel.Accept()
+	if err != nil {
+		_ = dstChannel.Close()
+		return
+	}
+
+	var in io.Reader = srcChannel
+	var out io.Writer = srcChannel
+	var rec *recorder
+	if fromClient && newChannel.ChannelType() == "session" && s.cfg.RecordingDir != "" {
+		rec, err = newRecorder(s.cfg.RecordingDir,
+			fmt.Sprintf("%s-%s-%d.cast", s.start.UTC().Format("20060102T150405Z"), s.id, s.channels.Add(1)),
+			fmt.Sprintf("%s as %s@%s", s.cfg.getKeyID(), s.principal, s.cfg.UpstreamAddr))
+		if err != nil {
+			log.Error(ctx).Err(err).Msg("sshproxy: error starting session recording")
+		} else {
+			defer rec.Close()
+			in = io.TeeReader(srcChannel, rec.writer("i"))
+			out = io.MultiWriter(srcChannel, rec.writer("o"))
+		}
+	}
+
+	var srcDone, dstDone sync.WaitGroup
+	srcDone.Add(3)
+	go func() {
+		defer srcDone.Done()
+		_, _ = io.Copy(dstChannel, in)
+		_ = dstChannel.CloseWrite()
+	}()
+	go func() {
+		defer srcDone.Done()
+		_, _ = io.Copy(dstChannel.Stderr(), srcChannel.Stderr())
+	}()
+	go func() {
+		defer srcDone.Done()
+		s.forwardChannelRequests(ctx, srcReqs, dstChannel, rec)
+	}()
+
+	dstDone.Add(3)
+	go func() {
+		defer dstDone.Done()
+		_, _ = io.Copy(out, dstChannel)
+		_ = srcChannel.CloseWrite()
+	}()
+	go func() {
+		defer dstDone.Done()
+		_, _ = io.Copy(srcChannel.Stderr(), dstChannel.Stderr())
+	}()
+	go func() {
+		defer dstDone.Done()
+		s.forwardChannelRequests(ctx, dstReqs, srcChannel, nil)
+	}()
+
+	go func() {
+		srcDone.Wait()
+		_ = dstChannel.Close()
+	}()
+	dstDone.Wait()
+	_ = srcChannel.Close()
+	srcDone.Wait()
+}
+
+func (s *session) forwardChannelRequests(ctx context.Context, reqs <-chan *ssh.Request, dst ssh.Channel, rec *recorder) {
+	for req := range reqs {
+		switch req.Type {
+		case "exec", "subsystem":
+			var msg struct{ Command string }
+			if ssh.Unmarshal(req.Payload, &msg) == nil {
+				log.Info(ctx).Str("request", req.Type).Str("command", msg.Command).Msg("sshproxy: channel request")
+			}
+		case "pty-req":
+			var msg struct {
+				Term          string
+				Columns, Rows uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if rec != nil && ssh.Unmarshal(req.Payload, &msg) == nil {
+				rec.resize(msg.Columns, msg.Rows)
+			}
+		case "window-change":
+			var msg struct {
+				Columns, Rows uint32
+				Width, Height uint32
+			}
+			if rec != nil && ssh.Unmarshal(req.Payload, &msg) == nil {
+				rec.resize(msg.Columns, msg.Rows)
+			}
+		}
+
+		ok, err := dst.SendRequest(req.Type, req.WantReply, req.Payload)
+		if err != nil {
+			ok = false
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+	}
+}
+
+func forwardGlobalRequests(reqs <-chan *ssh.Request, dst ssh.Conn) {
+	for req := range reqs {
+		ok, payload, err := dst.SendRequest(req.Type, req.WantReply, req.Payload)
+		if err != nil {
+			ok = false
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, payload)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package sshproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewCertificateSigner(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestSigner(t)
+	signer, cert, err := NewCertificateSigner(ca, "user@example.com", []string{"user"}, time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, cert.Marshal(), signer.PublicKey().Marshal())
+	assert.Equal(t, uint32(ssh.UserCert), cert.CertType)
+	assert.Equal(t, "user@example.com", cert.KeyId)
+	assert.Equal(t, []string{"user"}, cert.ValidPrincipals)
+	assert.Equal(t, ca.PublicKey().Marshal(), cert.SignatureKey.Marshal())
+
+	checker := &ssh.CertChecker{}
+	assert.NoError(t, checker.CheckCert("user", cert))
+	assert.Error(t, checker.CheckCert("root", cert))
+
+	checker.Clock = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	assert.Error(t, checker.CheckCert("user", cert), "should expire")
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	ca := newTestSigner(t)
+	upstreamHostKey := newTestSigner(t)
+	upstreamAddr := startTestUpstream(t, ca, upstreamHostKey)
+
+	hostKey := newTestSigner(t)
+	recordingDir := t.TempDir()
+	cfg := &Config{
+		HostKey:          hostKey,
+		UserCAKey:        ca,
+		RecordingDir:     recordingDir,
+		UpstreamAddr:     upstreamAddr,
+		UpstreamHostKeys: []ssh.PublicKey{upstreamHostKey.PublicKey()},
+		UserID:           "USER_ID",
+		Email:            "user@example.com",
+		Principals:       []string{"user"},
+	}
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = li.Close() })
+	go func() {
+		for {
+			conn, err := li.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = Serve(ctx, conn, cfg) }()
+		}
+	}()
+
+	clientKey := newTestSigner(t)
+	dial := func(user string) (*ssh.Client, error) {
+		return ssh.Dial("tcp", li.Addr().String(), &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientKey)},
+			HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+			Timeout:         5 * time.Second,
+		})
+	}
+
+	t.Run("principal not allowed", func(t *testing.T) {
+		_, err := dial("root")
+		assert.Error(t, err)
+	})
+	t.Run("exec", func(t *testing.T) {
+		client, err := dial("user")
+		require.NoError(t, err)
+		defer client.Close()
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+		defer session.Close()
+
+		output, err := session.Output("whoami")
+		require.NoError(t, err)
+		assert.Equal(t, "user ran whoami", string(output))
+	})
+	t.Run("recording", func(t *testing.T) {
+		var files []string
+		require.Eventually(t, func() bool {
+			files, _ = filepath.Glob(filepath.Join(recordingDir, "*.cast"))
+			return len(files) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		f, err := os.Open(files[0])
+		require.NoError(t, err)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		require.True(t, scanner.Scan())
+		assert.Contains(t, scanner.Text(), `"version":2`)
+		assert.Contains(t, scanner.Text(), `"title":"user@example.com as user@`+upstreamAddr+`"`)
+		require.True(t, scanner.Scan())
+		assert.Contains(t, scanner.Text(), `"o","user ran whoami"]`)
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	w := &closeBuffer{}
+	r, err := newRecorderForWriter(w, time.Unix(1000, 0), "TITLE")
+	require.NoError(t, err)
+	r.start = time.Now()
+	r.resize(120, 40)
+	_, _ = r.writer("o").Write([]byte("hello\r\n"))
+	require.NoError(t, r.Close())
+
+	lines := w.lines()
+	require.Len(t, lines, 3)
+	assert.Equal(t, `{"version":2,"width":80,"height":24,"timestamp":1000,"title":"TITLE"}`, lines[0])
+	assert.Regexp(t, `^\[[0-9.e-]+,"r","120x40"\]$`, lines[1])
+	assert.Regexp(t, `^\[[0-9.e-]+,"o","hello\\r\\n"\]$`, lines[2])
+	assert.True(t, w.closed)
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return signer
+}
+
+// startTestUpstream starts an ssh server which accepts user certificates signed by the ca and responds to
+// exec requests with the user and command.
+func startTestUpstream(t *testing.T, ca, hostKey ssh.Signer) string {
+	t.Helper()
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+	}
+	serverConfig := &ssh.ServerConfig{PublicKeyCallback: checker.Authenticate}
+	serverConfig.AddHostKey(hostKey)
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = li.Close() })
+
+	go func() {
+		for {
+			conn, err := li.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				sconn, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+				if err != nil {
+					return
+				}
+				defer sconn.Close()
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					ch, chReqs, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						defer ch.Close()
+						for req := range chReqs {
+							if req.Type != "exec" {
+								_ = req.Reply(false, nil)
+								continue
+							}
+							var msg struct{ Command string }
+							_ = ssh.Unmarshal(req.Payload, &msg)
+							_ = req.Reply(true, nil)
+							_, _ = ch.Write([]byte(sconn.User() + " ran " + msg.Command))
+							_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+							return
+						}
+					}()
+				}
+			}()
+		}
+	}()
+
+	return li.Addr().String()
+}
+
+type closeBuffer struct {
+	data   []byte
+	closed bool
+}
+
+func (b *closeBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *closeBuffer) lines() []string {
+	return strings.Split(strings.TrimSpace(string(b.data)), "\n")
+}
@@ -0,0 +1,181 @@
+// Package syslog writes messages to syslog servers. Remote servers receive messages in the RFC 5424
+// format, over UDP, TCP or TLS (RFC 5425). Messages sent over TCP and TLS use octet counting
+// framing, so they may contain newlines.
+//
+// https://www.rfc-editor.org/rfc/rfc5424
+package syslog
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Facility is a syslog facility.
+type Facility int
+
+// Facilities used by pomerium.
+const (
+	FacilityUser Facility = 1
+	FacilityAuth Facility = 4
+)
+
+// severityInfo is the severity of all messages.
+const severityInfo = 6
+
+const (
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+	// redialDelay is how long messages are dropped after failing to connect, so that writers
+	// aren't blocked on every message while the server is down.
+	redialDelay = 5 * time.Second
+)
+
+// Options configure a syslog writer.
+type Options struct {
+	// Network is udp, tcp or tls, or empty for the local syslog server.
+	Network string
+	// Address is the address of the syslog server, such as syslog.example.com:6514.
+	Address string
+	// AppName identifies the application. Defaults to pomerium.
+	AppName string
+	// Facility is the facility of the messages. Defaults to user.
+	Facility Facility
+	// TLSConfig configures TLS connections.
+	TLSConfig *tls.Config
+}
+
+// ValidateNetwork returns an error if the network is not supported.
+func ValidateNetwork(network string) error {
+	switch network {
+	case "", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6", "tls":
+		return nil
+	}
+	return fmt.Errorf("syslog: unsupported network: %s", network)
+}
+
+// Dial returns a writer which writes each Write as a syslog message with the informational
+// severity. Remote servers are connected to lazily and reconnected to after errors, so Dial
+// doesn't fail if the server is down.
+func Dial(opts Options) (io.WriteCloser, error) {
+	if err := ValidateNetwork(opts.Network); err != nil {
+		return nil, err
+	}
+	if opts.AppName == "" {
+		opts.AppName = "pomerium"
+	}
+	if opts.Facility == 0 {
+		opts.Facility = FacilityUser
+	}
+	if opts.Network == "" {
+		return dialLocal(opts)
+	}
+	if opts.Address == "" {
+		return nil, fmt.Errorf("syslog: address is required")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &remoteWriter{
+		opts:     opts,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+	}, nil
+}
+
+type remoteWriter struct {
+	opts     Options
+	hostname string
+	procID   string
+
+	mu         sync.Mutex
+	conn       net.Conn
+	dialFailed time.Time
+	closed     bool
+}
+
+func (w *remoteWriter) Write(p []byte) (int, error) {
+	msg := w.format(time.Now(), bytes.TrimRight(p, "\n"))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.connect(); err != nil {
+		return 0, err
+	}
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := w.conn.Write(msg); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return 0, fmt.Errorf("syslog: error writing message: %w", err)
+	}
+	return len(p), nil
+}
+
+func (w *remoteWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *remoteWriter) connect() error {
+	if w.conn != nil {
+		return nil
+	}
+	if w.closed {
+		return fmt.Errorf("syslog: writer is closed")
+	}
+	if time.Since(w.dialFailed) < redialDelay {
+		return fmt.Errorf("syslog: not connected to %s", w.opts.Address)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if w.opts.Network == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.opts.Address, w.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial(w.opts.Network, w.opts.Address)
+	}
+	if err != nil {
+		w.dialFailed = time.Now()
+		return fmt.Errorf("syslog: error connecting to %s: %w", w.opts.Address, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// format formats a message in the RFC 5424 format. Stream transports prefix the message with its
+// length.
+func (w *remoteWriter) format(now time.Time, msg []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - - ",
+		int(w.opts.Facility)*8+severityInfo,
+		now.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		w.hostname,
+		w.opts.AppName,
+		w.procID)
+	buf.Write(msg)
+
+	switch w.opts.Network {
+	case "udp", "udp4", "udp6":
+		return buf.Bytes()
+	}
+	return append([]byte(strconv.Itoa(buf.Len())+" "), buf.Bytes()...)
+}
@@ -0,0 +1,107 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var messageRE = regexp.MustCompile(`^<(\d+)>1 \S+Z \S+ test \d+ - - (.*)$`)
+
+func TestDial(t *testing.T) {
+	t.Parallel()
+
+	_, err := Dial(Options{Network: "unix", Address: "/dev/log"})
+	assert.Error(t, err)
+	_, err = Dial(Options{Network: "tcp"})
+	assert.Error(t, err)
+}
+
+func TestTCP(t *testing.T) {
+	t.Parallel()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer li.Close()
+
+	w, err := Dial(Options{Network: "tcp", Address: li.Addr().String(), AppName: "test", Facility: FacilityAuth})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("first\nline\n"))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("second"))
+	require.NoError(t, err)
+
+	conn, err := li.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	for _, expect := range []string{"first\nline", "second"} {
+		prefix, err := r.ReadString(' ')
+		require.NoError(t, err)
+		n, err := strconv.Atoi(strings.TrimSpace(prefix))
+		require.NoError(t, err)
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		require.NoError(t, err)
+
+		m := messageRE.FindStringSubmatch(strings.ReplaceAll(string(msg), "\n", "\\n"))
+		require.NotNil(t, m, "invalid message: %s", msg)
+		assert.Equal(t, "38", m[1], "auth facility with info severity")
+		assert.Equal(t, strings.ReplaceAll(expect, "\n", "\\n"), m[2])
+	}
+}
+
+func TestUDP(t *testing.T) {
+	t.Parallel()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w, err := Dial(Options{Network: "udp", Address: conn.LocalAddr().String(), AppName: "test"})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("hello\n"))
+	require.NoError(t, err)
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+
+	m := messageRE.FindStringSubmatch(string(buf[:n]))
+	require.NotNil(t, m, "invalid message: %s", buf[:n])
+	assert.Equal(t, "14", m[1], "user facility with info severity")
+	assert.Equal(t, "hello", m[2])
+}
+
+func TestRedialDelay(t *testing.T) {
+	t.Parallel()
+
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := li.Addr().String()
+	require.NoError(t, li.Close())
+
+	w, err := Dial(Options{Network: "tcp", Address: addr})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Write([]byte("dropped"))
+	assert.ErrorContains(t, err, "error connecting")
+	_, err = w.Write([]byte("dropped"))
+	assert.ErrorContains(t, err, "not connected")
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package syslog
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+func dialLocal(opts Options) (io.WriteCloser, error) {
+	w, err := syslog.Dial("", "", syslog.Priority(opts.Facility<<3)|syslog.LOG_INFO, opts.AppName)
+	if err != nil {
+		return nil, fmt.Errorf("syslog: error connecting to local syslog: %w", err)
+	}
+	return w, nil
+}
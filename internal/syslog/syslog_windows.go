@@ -0,0 +1,12 @@
+//go:build windows
+
+package syslog
+
+import (
+	"fmt"
+	"io"
+)
+
+func dialLocal(_ Options) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog: local syslog is not supported on windows")
+}
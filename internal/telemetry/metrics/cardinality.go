@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/atomicutil"
+)
+
+// OverflowLabelValue is recorded instead of the values of a label beyond its cardinality limit.
+const OverflowLabelValue = "__overflow__"
+
+// DefaultLabelHashBuckets is the number of distinct values of a hashed label if none is set.
+const DefaultLabelHashBuckets = 64
+
+// LabelLimits bound the cardinality of the labels of the metrics recorded by this package, so
+// that labels such as hosts or user ids can't create an unbounded number of time series.
+type LabelLimits struct {
+	// MaxValues is the maximum number of distinct values recorded for each label. Further
+	// values are recorded as OverflowLabelValue. 0 means no limit.
+	MaxValues int
+	// HashedLabels are the labels whose values are replaced by one of HashBuckets hashes.
+	HashedLabels []string
+	// HashBuckets is the number of distinct hashes of hashed labels. Defaults to
+	// DefaultLabelHashBuckets.
+	HashBuckets int
+}
+
+type labelGuard struct {
+	limits LabelLimits
+	hashed map[string]struct{}
+
+	mu   sync.Mutex
+	seen map[string]map[string]struct{}
+}
+
+func newLabelGuard(limits LabelLimits) *labelGuard {
+	g := &labelGuard{
+		limits: limits,
+		hashed: make(map[string]struct{}, len(limits.HashedLabels)),
+		seen:   make(map[string]map[string]struct{}),
+	}
+	if g.limits.HashBuckets <= 0 {
+		g.limits.HashBuckets = DefaultLabelHashBuckets
+	}
+	for _, label := range limits.HashedLabels {
+		g.hashed[label] = struct{}{}
+	}
+	return g
+}
+
+var globalLabelGuard = atomicutil.NewValue(newLabelGuard(LabelLimits{}))
+
+// SetLabelLimits sets the limits of the labels of recorded metrics. The distinct values seen so
+// far are reset when the limits change.
+func SetLabelLimits(limits LabelLimits) {
+	if reflect.DeepEqual(globalLabelGuard.Load().limits, newLabelGuard(limits).limits) {
+		return
+	}
+	globalLabelGuard.Store(newLabelGuard(limits))
+}
+
+// value returns the value to record for a label.
+func (g *labelGuard) value(label, value string) string {
+	if _, ok := g.hashed[label]; ok {
+		value = strconv.FormatUint(xxhash.Sum64String(value)%uint64(g.limits.HashBuckets), 10)
+	}
+	if g.limits.MaxValues <= 0 {
+		return value
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	values, ok := g.seen[label]
+	if !ok {
+		values = make(map[string]struct{})
+		g.seen[label] = values
+	}
+	if _, ok := values[value]; ok {
+		return value
+	}
+	if len(values) >= g.limits.MaxValues {
+		return OverflowLabelValue
+	}
+	values[value] = struct{}{}
+	return value
+}
+
+// upsertTag returns a mutator which upserts a tag, with the label limits applied to its value.
+func upsertTag(key tag.Key, value string) tag.Mutator {
+	return tag.Upsert(key, globalLabelGuard.Load().value(key.Name(), value))
+}
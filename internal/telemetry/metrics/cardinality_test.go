@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLabelGuard(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no limits", func(t *testing.T) {
+		t.Parallel()
+
+		g := newLabelGuard(LabelLimits{})
+		for _, v := range []string{"a", "b", "c"} {
+			assert.Equal(t, v, g.value("host", v))
+		}
+	})
+	t.Run("max values", func(t *testing.T) {
+		t.Parallel()
+
+		g := newLabelGuard(LabelLimits{MaxValues: 2})
+		assert.Equal(t, "a", g.value("host", "a"))
+		assert.Equal(t, "b", g.value("host", "b"))
+		assert.Equal(t, OverflowLabelValue, g.value("host", "c"))
+		assert.Equal(t, "a", g.value("host", "a"), "should keep recording seen values")
+		assert.Equal(t, "c", g.value("route_id", "c"), "should limit each label separately")
+	})
+	t.Run("hashed labels", func(t *testing.T) {
+		t.Parallel()
+
+		g := newLabelGuard(LabelLimits{HashedLabels: []string{"user_id"}, HashBuckets: 4})
+		hashed := g.value("user_id", "user-1")
+		assert.NotEqual(t, "user-1", hashed)
+		assert.Contains(t, []string{"0", "1", "2", "3"}, hashed)
+		assert.Equal(t, hashed, g.value("user_id", "user-1"), "should hash consistently")
+		assert.Equal(t, "user-1", g.value("host", "user-1"), "should only hash the configured labels")
+	})
+	t.Run("hashed labels with max values", func(t *testing.T) {
+		t.Parallel()
+
+		g := newLabelGuard(LabelLimits{MaxValues: 1, HashedLabels: []string{"user_id"}})
+		hashed := g.value("user_id", "user-1")
+		assert.NotEqual(t, OverflowLabelValue, hashed)
+		for i := 0; i < 100; i++ {
+			if v := g.value("user_id", string(rune('a'+i))); v != hashed {
+				assert.Equal(t, OverflowLabelValue, v)
+			}
+		}
+	})
+}
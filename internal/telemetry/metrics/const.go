@@ -18,6 +18,14 @@ var (
 	TagKeyStorageOperation = tag.MustNewKey("operation")
 	TagKeyStorageResult    = tag.MustNewKey("result")
 	TagKeyStorageBackend   = tag.MustNewKey("backend")
+
+	TagKeyRequestLimit = tag.MustNewKey("limit")
+
+	TagKeyRecordType = tag.MustNewKey("record_type")
+
+	TagKeyRouteID     = tag.MustNewKey("route_id")
+	TagKeyDecision    = tag.MustNewKey("decision")
+	TagKeyStatusClass = tag.MustNewKey("status_class")
 )
 
 // Default distributions used by views in this package.
@@ -43,6 +51,10 @@ var (
 		HTTPClientViews,
 		HTTPServerViews,
 		InfoViews,
+		LeaseViews,
+		RequestLimitViews,
+		RetentionViews,
+		RouteViews,
 		StorageViews,
 	}
 )
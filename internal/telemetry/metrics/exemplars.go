@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	octrace "go.opencensus.io/trace"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// recordWithExemplar records measurements like stats.RecordWithTags, attaching the span context
+// of the ctx so that distributions keep the trace of a sampled request as an exemplar.
+func recordWithExemplar(ctx context.Context, mutators []tag.Mutator, ms ...stats.Measurement) error {
+	opts := []stats.Options{stats.WithTags(mutators...), stats.WithMeasurements(ms...)}
+	if span := octrace.FromContext(ctx); span != nil && span.SpanContext().IsSampled() {
+		opts = append(opts, stats.WithAttachments(metricdata.Attachments{
+			metricdata.AttachmentKeySpanContext: span.SpanContext(),
+		}))
+	}
+	return stats.RecordWithOptions(ctx, opts...)
+}
+
+// exemplarKey identifies a histogram bucket of a prometheus time series.
+type exemplarKey struct {
+	series     string
+	upperBound float64
+}
+
+// readExemplars returns the exemplars of the distributions recorded with OpenCensus, keyed by the
+// bucket of the time series exported to prometheus.
+func readExemplars(namespace string) map[exemplarKey]*io_prometheus_client.Exemplar {
+	exemplars := make(map[exemplarKey]*io_prometheus_client.Exemplar)
+	for _, producer := range metricproducer.GlobalManager().GetAll() {
+		for _, m := range producer.Read() {
+			if m.Descriptor.Type != metricdata.TypeCumulativeDistribution {
+				continue
+			}
+
+			name := sanitizeMetricName(namespace + "_" + m.Descriptor.Name)
+			for _, ts := range m.TimeSeries {
+				labels := make(map[string]string, len(m.Descriptor.LabelKeys))
+				for i, lk := range m.Descriptor.LabelKeys {
+					if i < len(ts.LabelValues) && ts.LabelValues[i].Present {
+						labels[sanitizeMetricName(lk.Key)] = ts.LabelValues[i].Value
+					}
+				}
+				series := seriesKey(name, labels)
+
+				for _, p := range ts.Points {
+					d, ok := p.Value.(*metricdata.Distribution)
+					if !ok || d.BucketOptions == nil {
+						continue
+					}
+					for i, b := range d.Buckets {
+						e := toPrometheusExemplar(b.Exemplar)
+						if e == nil {
+							continue
+						}
+						upperBound := math.Inf(1)
+						if i < len(d.BucketOptions.Bounds) {
+							upperBound = d.BucketOptions.Bounds[i]
+						}
+						exemplars[exemplarKey{series: series, upperBound: upperBound}] = e
+					}
+				}
+			}
+		}
+	}
+	return exemplars
+}
+
+// addExemplars adds the exemplars to the buckets of a histogram metric.
+func addExemplars(name string, m *io_prometheus_client.Metric, exemplars map[exemplarKey]*io_prometheus_client.Exemplar) {
+	if len(exemplars) == 0 || m.GetHistogram() == nil {
+		return
+	}
+
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	series := seriesKey(name, labels)
+
+	for _, b := range m.GetHistogram().GetBucket() {
+		if e, ok := exemplars[exemplarKey{series: series, upperBound: b.GetUpperBound()}]; ok {
+			b.Exemplar = e
+		}
+	}
+}
+
+func toPrometheusExemplar(e *metricdata.Exemplar) *io_prometheus_client.Exemplar {
+	if e == nil {
+		return nil
+	}
+	sc, ok := e.Attachments[metricdata.AttachmentKeySpanContext].(octrace.SpanContext)
+	if !ok {
+		return nil
+	}
+	return &io_prometheus_client.Exemplar{
+		Label: []*io_prometheus_client.LabelPair{
+			{Name: proto.String("trace_id"), Value: proto.String(sc.TraceID.String())},
+			{Name: proto.String("span_id"), Value: proto.String(sc.SpanID.String())},
+		},
+		Value:     proto.Float64(e.Value),
+		Timestamp: timestamppb.New(e.Timestamp),
+	}
+}
+
+// seriesKey returns a key identifying a time series. Empty label values are ignored, as they
+// aren't exported.
+func seriesKey(name string, labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v != "" {
+			pairs = append(pairs, k+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, "\xff") + "}"
+}
+
+// sanitizeMetricName replaces the characters which aren't valid in prometheus metric and label
+// names, like the OpenCensus prometheus exporter does.
+func sanitizeMetricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
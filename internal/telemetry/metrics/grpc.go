@@ -136,10 +136,10 @@ func GRPCClientInterceptor(service string) grpc.UnaryClientInterceptor {
 
 		taggedCtx, tagErr := tag.New(
 			ctx,
-			tag.Upsert(TagKeyService, service),
-			tag.Upsert(TagKeyHost, cc.Target()),
-			tag.Upsert(TagKeyGRPCMethod, rpcMethod),
-			tag.Upsert(TagKeyGRPCService, rpcService),
+			upsertTag(TagKeyService, service),
+			upsertTag(TagKeyHost, cc.Target()),
+			upsertTag(TagKeyGRPCMethod, rpcMethod),
+			upsertTag(TagKeyGRPCService, rpcService),
 		)
 		if tagErr != nil {
 			log.Warn(ctx).Err(tagErr).Str("context", "GRPCClientInterceptor").Msg("telemetry/metrics: failed to create context")
@@ -176,9 +176,9 @@ func (h *GRPCServerMetricsHandler) TagRPC(ctx context.Context, tagInfo *grpcstat
 
 	taggedCtx, tagErr := tag.New(
 		ctx,
-		tag.Upsert(TagKeyService, h.service),
-		tag.Upsert(TagKeyGRPCMethod, rpcMethod),
-		tag.Upsert(TagKeyGRPCService, rpcService),
+		upsertTag(TagKeyService, h.service),
+		upsertTag(TagKeyGRPCMethod, rpcMethod),
+		upsertTag(TagKeyGRPCService, rpcService),
 	)
 	if tagErr != nil {
 		log.Warn(ctx).Err(tagErr).Str("context", "GRPCServerStatsHandler").Msg("telemetry/metrics: failed to create context")
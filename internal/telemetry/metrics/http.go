@@ -115,9 +115,9 @@ func HTTPMetricsHandler(_ func() string, service string) func(next http.Handler)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx, tagErr := tag.New(
 				r.Context(),
-				tag.Upsert(TagKeyService, service),
-				tag.Upsert(TagKeyHost, r.Host),
-				tag.Upsert(TagKeyHTTPMethod, r.Method),
+				upsertTag(TagKeyService, service),
+				upsertTag(TagKeyHost, r.Host),
+				upsertTag(TagKeyHTTPMethod, r.Method),
 			)
 			if tagErr != nil {
 				log.Warn(ctx).Err(tagErr).Str("context", "HTTPMetricsHandler").Msg("telemetry/metrics: failed to create metrics tag")
@@ -142,9 +142,9 @@ func HTTPMetricsRoundTripper(_ func() string, service string) func(next http.Rou
 		return tripper.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
 			ctx, tagErr := tag.New(
 				r.Context(),
-				tag.Upsert(TagKeyService, service),
-				tag.Upsert(TagKeyHost, r.Host),
-				tag.Upsert(TagKeyHTTPMethod, r.Method),
+				upsertTag(TagKeyService, service),
+				upsertTag(TagKeyHost, r.Host),
+				upsertTag(TagKeyHTTPMethod, r.Method),
 			)
 			if tagErr != nil {
 				log.Warn(ctx).Err(tagErr).Str("context", "HTTPMetricsRoundTripper").Msg("telemetry/metrics: failed to create metrics tag")
@@ -398,3 +398,24 @@ func RegisterInfoMetrics() {
 func AddPolicyCountCallback(service string, f func() int64) {
 	registry.addPolicyCountCallback(service, f)
 }
+
+// AddPolicyCompileQueueCallback sets the function to call when exporting the
+// policy compile queue metric. You must call RegisterInfoMetrics to have this
+// exported
+func AddPolicyCompileQueueCallback(service string, f func() int64) {
+	registry.addPolicyCompileQueueCallback(service, f)
+}
+
+// SetCertificateExpiry records when a configured certificate expires, which is exported
+// as the number of days until it expires. You must call RegisterInfoMetrics to have this
+// exported
+func SetCertificateExpiry(source, route, subject string, notAfter time.Time) {
+	registry.setCertificateExpiry(source, route, subject, notAfter)
+}
+
+// AddAuthorizeReplicaCallbacks sets the functions to call when exporting the
+// staleness and record count metrics of the authorize replica of a record type.
+// You must call RegisterInfoMetrics to have this exported
+func AddAuthorizeReplicaCallbacks(recordType string, staleness func() float64, records func() int64) {
+	registry.addReplicaCallbacks(recordType, staleness, records)
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"runtime"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"go.opencensus.io/metric/metricdata"
 	"go.opencensus.io/metric/metricproducer"
 	"go.opencensus.io/stats/view"
@@ -95,6 +97,18 @@ func Test_AddPolicyCountCallback(t *testing.T) {
 	testMetricRetrieval(registry.registry.Read(), t, wantLabels, wantValue, metrics.PolicyCountTotal)
 }
 
+func Test_AddPolicyCompileQueueCallback(t *testing.T) {
+	registry = newMetricRegistry()
+
+	wantValue := int64(7)
+	wantLabels := []metricdata.LabelValue{
+		{Value: "test_service", Present: true},
+	}
+	AddPolicyCompileQueueCallback("test_service", func() int64 { return wantValue })
+
+	testMetricRetrieval(registry.registry.Read(), t, wantLabels, wantValue, metrics.AuthorizePolicyCompileQueue)
+}
+
 func Test_RegisterInfoMetrics(t *testing.T) {
 	metricproducer.GlobalManager().DeleteProducer(registry.registry)
 	RegisterInfoMetrics()
@@ -106,3 +120,24 @@ func Test_RegisterInfoMetrics(t *testing.T) {
 		t.Error("Did not find enough registries")
 	}
 }
+
+func Test_SetCertificateExpiry(t *testing.T) {
+	registry = newMetricRegistry()
+
+	SetCertificateExpiry("tls_custom_ca", "https://from.example.com", "CN=example", time.Now().Add(30*24*time.Hour))
+
+	var found bool
+	for _, m := range registry.registry.Read() {
+		if m.Descriptor.Name != metrics.CertificateExpiryDays {
+			continue
+		}
+		found = true
+		assert.Equal(t, []metricdata.LabelValue{
+			{Value: "tls_custom_ca", Present: true},
+			{Value: "https://from.example.com", Present: true},
+			{Value: "CN=example", Present: true},
+		}, m.TimeSeries[0].LabelValues)
+		assert.InDelta(t, 30, m.TimeSeries[0].Points[0].Value, 0.01)
+	}
+	assert.True(t, found, "certificate expiry metric not found")
+}
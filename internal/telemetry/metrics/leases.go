@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// TagKeyLeaseName is the name of a databroker lease.
+var TagKeyLeaseName = tag.MustNewKey("lease_name")
+
+var (
+	// LeaseViews contains opencensus views for the databroker lease metrics
+	LeaseViews = []*view.View{LeaseHeldView, LeaseAcquiredView, LeaseLostView}
+
+	leaseHeld = stats.Int64(
+		"databroker_lease_held",
+		"Whether this instance holds the databroker lease and runs its leased jobs",
+		stats.UnitDimensionless)
+	leaseAcquired = stats.Int64(
+		"databroker_lease_acquired_total",
+		"Total databroker leases acquired by this instance",
+		stats.UnitDimensionless)
+	leaseLost = stats.Int64(
+		"databroker_lease_lost_total",
+		"Total databroker leases lost by this instance because they couldn't be renewed",
+		stats.UnitDimensionless)
+
+	// LeaseHeldView is an OpenCensus view that reports whether this instance is the leader for a
+	// lease, by lease name
+	LeaseHeldView = &view.View{
+		Name:        leaseHeld.Name(),
+		Description: leaseHeld.Description(),
+		Measure:     leaseHeld,
+		TagKeys:     []tag.Key{TagKeyLeaseName},
+		Aggregation: view.LastValue(),
+	}
+
+	// LeaseAcquiredView is an OpenCensus view that counts the leases acquired by this instance,
+	// by lease name
+	LeaseAcquiredView = &view.View{
+		Name:        leaseAcquired.Name(),
+		Description: leaseAcquired.Description(),
+		Measure:     leaseAcquired,
+		TagKeys:     []tag.Key{TagKeyLeaseName},
+		Aggregation: view.Count(),
+	}
+
+	// LeaseLostView is an OpenCensus view that counts the leases lost by this instance, by lease
+	// name. A lost lease means another replica takes over the leased jobs.
+	LeaseLostView = &view.View{
+		Name:        leaseLost.Name(),
+		Description: leaseLost.Description(),
+		Measure:     leaseLost,
+		TagKeys:     []tag.Key{TagKeyLeaseName},
+		Aggregation: view.Count(),
+	}
+)
+
+// RecordLeaseAcquired records that this instance acquired a lease and became its leader.
+func RecordLeaseAcquired(ctx context.Context, leaseName string) {
+	recordLease(ctx, leaseName, leaseHeld.M(1), leaseAcquired.M(1))
+}
+
+// RecordLeaseReleased records that this instance no longer holds a lease, either because it
+// was released or because it was lost.
+func RecordLeaseReleased(ctx context.Context, leaseName string, lost bool) {
+	if lost {
+		recordLease(ctx, leaseName, leaseHeld.M(0), leaseLost.M(1))
+		return
+	}
+	recordLease(ctx, leaseName, leaseHeld.M(0))
+}
+
+func recordLease(ctx context.Context, leaseName string, ms ...stats.Measurement) {
+	err := stats.RecordWithTags(ctx,
+		[]tag.Mutator{upsertTag(TagKeyLeaseName, leaseName)},
+		ms...,
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+}
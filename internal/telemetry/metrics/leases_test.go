@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func Test_RecordLease(t *testing.T) {
+	view.Unregister(LeaseViews...)
+	view.Register(LeaseViews...)
+
+	RecordLeaseAcquired(context.Background(), "test")
+	testDataRetrieval(LeaseHeldView, t, "{ { {lease_name test} }&{1")
+	testDataRetrieval(LeaseAcquiredView, t, "{ { {lease_name test} }&{1")
+
+	RecordLeaseReleased(context.Background(), "test", true)
+	testDataRetrieval(LeaseHeldView, t, "{ { {lease_name test} }&{0")
+	testDataRetrieval(LeaseLostView, t, "{ { {lease_name test} }&{1")
+}
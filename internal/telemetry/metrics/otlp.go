@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	ocbridge "go.opentelemetry.io/otel/bridge/opencensus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/pomerium/pomerium/internal/telemetry/otlp"
+)
+
+// OTLPEnabled returns true if metrics should be exported using OTLP, which is the case when
+// OTEL_METRICS_EXPORTER includes otlp.
+func OTLPEnabled() bool {
+	for _, exporter := range strings.Split(os.Getenv("OTEL_METRICS_EXPORTER"), ",") {
+		if strings.TrimSpace(exporter) == "otlp" {
+			return true
+		}
+	}
+	return false
+}
+
+// An OTLPExporter periodically exports the OpenCensus metrics using OTLP.
+type OTLPExporter struct {
+	meterProvider *sdkmetric.MeterProvider
+}
+
+// NewOTLPExporter creates a new OTLPExporter. The exporter is configured with the standard
+// OTEL_EXPORTER_OTLP_* and OTEL_METRIC_EXPORT_* environment variables.
+func NewOTLPExporter(ctx context.Context, service string) (*OTLPExporter, error) {
+	protocol, err := otlp.GetProtocol(otlp.SignalMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	var exporter sdkmetric.Exporter
+	switch protocol {
+	case otlp.ProtocolGRPC:
+		exporter, err = otlpmetricgrpc.New(ctx)
+	default:
+		exporter, err = otlpmetrichttp.New(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("telemetry/metrics: error creating otlp exporter: %w", err)
+	}
+
+	res, err := otlp.NewResource(ctx, service)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter)
+	reader.RegisterProducer(ocbridge.NewMetricProducer())
+	return &OTLPExporter{
+		meterProvider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(reader),
+			sdkmetric.WithResource(res),
+		),
+	}, nil
+}
+
+// Shutdown exports any remaining metrics and stops the exporter.
+func (exporter *OTLPExporter) Shutdown(ctx context.Context) error {
+	return exporter.meterProvider.Shutdown(ctx)
+}
@@ -43,8 +43,9 @@ func (e *ScrapeEndpoint) String() string {
 }
 
 // PrometheusHandler creates an exporter that exports stats to Prometheus
-// and returns a handler suitable for exporting metrics.
-func PrometheusHandler(endpoints []ScrapeEndpoint, installationID string, timeout time.Duration) (http.Handler, error) {
+// and returns a handler suitable for exporting metrics. If exemplars is set, scrapers
+// accepting the OpenMetrics format receive the exemplars of pomerium's histograms.
+func PrometheusHandler(endpoints []ScrapeEndpoint, installationID string, timeout time.Duration, exemplars bool) (http.Handler, error) {
 	exporter, err := getGlobalExporter()
 	if err != nil {
 		return nil, err
@@ -52,7 +53,7 @@ func PrometheusHandler(endpoints []ScrapeEndpoint, installationID string, timeou
 
 	mux := http.NewServeMux()
 
-	mux.Handle("/metrics", newProxyMetricsHandler(exporter, endpoints, installationID, timeout))
+	mux.Handle("/metrics", newProxyMetricsHandler(exporter, endpoints, installationID, timeout, exemplars))
 	return mux, nil
 }
 
@@ -96,33 +97,48 @@ func registerDefaultViews() error {
 
 // newProxyMetricsHandler creates a subrequest to the envoy control plane for metrics and
 // combines them with internal envoy-provided
-func newProxyMetricsHandler(exporter *ocprom.Exporter, endpoints []ScrapeEndpoint, installationID string, timeout time.Duration) http.HandlerFunc {
+func newProxyMetricsHandler(exporter *ocprom.Exporter, endpoints []ScrapeEndpoint, installationID string, timeout time.Duration, exemplars bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
 
+		// exemplars can only be represented in the OpenMetrics format
+		format := expfmt.FmtText
+		if exemplars {
+			if f := expfmt.NegotiateIncludingOpenMetrics(r.Header); isOpenMetrics(f) {
+				format = f
+			}
+		}
+		w.Header().Set("Content-Type", string(format))
+
 		labels := getCommonLabels(installationID)
-		if err := writeMetricsMux(ctx, w, append(
+		if err := writeMetricsMux(ctx, w, format, append(
 			scrapeEndpoints(endpoints, labels),
-			ocExport("pomerium", exporter, r, labels)),
+			ocExport("pomerium", exporter, r, labels, isOpenMetrics(format))),
 		); err != nil {
 			log.Error(ctx).Msg("responding to metrics request")
 		}
 	}
 }
 
+func isOpenMetrics(format expfmt.Format) bool {
+	return format == expfmt.FmtOpenMetrics_1_0_0 || format == expfmt.FmtOpenMetrics_0_0_1
+}
+
 type promProducerResult struct {
 	name   string
 	src    io.ReadCloser
 	labels []*io_prometheus_client.LabelPair
-	err    error
+	// exemplars to add to the histogram buckets, keyed by time series and bucket
+	exemplars map[exemplarKey]*io_prometheus_client.Exemplar
+	err       error
 }
 
 // promProducerFn returns a reader containing prometheus-style metrics and additional labels to add to each record
 type promProducerFn func(context.Context) promProducerResult
 
 // writeMetricsMux runs producers concurrently and pipes output to destination yet avoiding data interleaving
-func writeMetricsMux(ctx context.Context, w io.Writer, producers []promProducerFn) error {
+func writeMetricsMux(ctx context.Context, w io.Writer, format expfmt.Format, producers []promProducerFn) error {
 	results := make(chan promProducerResult)
 
 	for _, p := range producers {
@@ -140,20 +156,26 @@ loop_producers:
 			errs = multierror.Append(errs, err, writePrometheusComment(w, err.Error()))
 			break loop_producers
 		case res := <-results:
-			if err := writeMetricsResult(w, res); err != nil {
+			if err := writeMetricsResult(w, format, res); err != nil {
 				errs = multierror.Append(errs, fmt.Errorf("%s: %w", res.name, err))
 			}
 		}
 	}
 
+	if isOpenMetrics(format) {
+		if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("finalize: %w", err))
+		}
+	}
+
 	return errs.ErrorOrNil()
 }
 
-func writeMetricsResult(w io.Writer, res promProducerResult) error {
+func writeMetricsResult(w io.Writer, format expfmt.Format, res promProducerResult) error {
 	if res.err != nil {
 		return fmt.Errorf("fetch: %w", res.err)
 	}
-	if err := writeMetricsWithLabels(w, res.src, res.labels); err != nil {
+	if err := writeMetricsWithLabels(w, format, res.src, res.labels, res.exemplars); err != nil {
 		return fmt.Errorf("%s: write: %w", res.name, err)
 	}
 	if err := res.src.Close(); err != nil {
@@ -162,7 +184,13 @@ func writeMetricsResult(w io.Writer, res promProducerResult) error {
 	return nil
 }
 
-func writeMetricsWithLabels(w io.Writer, r io.Reader, extra []*io_prometheus_client.LabelPair) error {
+func writeMetricsWithLabels(
+	w io.Writer,
+	format expfmt.Format,
+	r io.Reader,
+	extra []*io_prometheus_client.LabelPair,
+	exemplars map[exemplarKey]*io_prometheus_client.Exemplar,
+) error {
 	var parser expfmt.TextParser
 	ms, err := parser.TextToMetricFamilies(r)
 	if err != nil {
@@ -171,9 +199,15 @@ func writeMetricsWithLabels(w io.Writer, r io.Reader, extra []*io_prometheus_cli
 
 	for _, m := range ms {
 		for _, mm := range m.Metric {
+			// exemplars are keyed by the labels of the source
+			addExemplars(m.GetName(), mm, exemplars)
 			mm.Label = append(mm.Label, extra...)
 		}
-		_, err = expfmt.MetricFamilyToText(w, m)
+		if isOpenMetrics(format) {
+			_, err = expfmt.MetricFamilyToOpenMetrics(w, m)
+		} else {
+			_, err = expfmt.MetricFamilyToText(w, m)
+		}
 		if err != nil {
 			return fmt.Errorf("telemetry/metric: failed to write prometheus metrics: %w", err)
 		}
@@ -192,7 +226,7 @@ func writePrometheusComment(w io.Writer, txt string) error {
 	return nil
 }
 
-func ocExport(name string, exporter *ocprom.Exporter, r *http.Request, labels []*io_prometheus_client.LabelPair) promProducerFn {
+func ocExport(name string, exporter *ocprom.Exporter, r *http.Request, labels []*io_prometheus_client.LabelPair, exemplars bool) promProducerFn {
 	return func(context.Context) promProducerResult {
 		// Ensure we don't get entangled with compression from ocprom
 		r.Header.Del("Accept-Encoding")
@@ -204,11 +238,15 @@ func ocExport(name string, exporter *ocprom.Exporter, r *http.Request, labels []
 			return promProducerResult{name: name, err: errors.New(rec.Result().Status)} //nolint
 		}
 
-		return promProducerResult{
+		res := promProducerResult{
 			name:   name,
 			src:    rec.Result().Body, //nolint
 			labels: labels,
 		}
+		if exemplars {
+			res.exemplars = readExemplars("pomerium")
+		}
+		return res
 	}
 }
 
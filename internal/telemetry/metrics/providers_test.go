@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,11 @@ import (
 	"regexp"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+	octrace "go.opencensus.io/trace"
 )
 
 func newEnvoyMetricsHandler() http.HandlerFunc {
@@ -29,7 +35,7 @@ envoy_server_initialization_time_ms_bucket{le="1000"} 1
 }
 
 func getMetrics(t *testing.T, envoyURL *url.URL) []byte {
-	h, err := PrometheusHandler([]ScrapeEndpoint{{Name: "envoy", URL: *envoyURL}}, "test_installation_id", time.Second*20)
+	h, err := PrometheusHandler([]ScrapeEndpoint{{Name: "envoy", URL: *envoyURL}}, "test_installation_id", time.Second*20, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -68,3 +74,38 @@ func Test_PrometheusHandler(t *testing.T) {
 		}
 	})
 }
+
+func Test_PrometheusHandlerExemplars(t *testing.T) {
+	h, err := PrometheusHandler(nil, "test_installation_id", time.Second*20, true)
+	require.NoError(t, err)
+
+	ctx, span := octrace.StartSpan(context.Background(), "test", octrace.WithSampler(octrace.AlwaysSample()))
+	RecordStorageOperation(ctx, &StorageOperationTags{Operation: "exemplar", Backend: "memory"}, 3*time.Millisecond)
+	span.End()
+	// measurements are recorded asynchronously
+	_, err = view.RetrieveData(StorageOperationDurationView.Name)
+	require.NoError(t, err)
+
+	getMetrics := func(accept string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, "http://test.local/metrics", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Header().Get("Content-Type"), rec.Body.String()
+	}
+
+	t.Run("openmetrics", func(t *testing.T) {
+		contentType, body := getMetrics("application/openmetrics-text; version=1.0.0")
+		assert.Contains(t, contentType, "application/openmetrics-text")
+		assert.Regexp(t, `(?m)^pomerium_storage_operation_duration_ms_bucket\{.*operation="exemplar".*\} 1 # \{trace_id="`+
+			span.SpanContext().TraceID.String()+`",span_id="`+span.SpanContext().SpanID.String()+`"\} 3(\.0)? `, body)
+		assert.Regexp(t, `# EOF\n$`, body)
+	})
+	t.Run("text", func(t *testing.T) {
+		contentType, body := getMetrics("text/plain")
+		assert.Contains(t, contentType, "text/plain")
+		assert.Contains(t, body, `operation="exemplar"`)
+		assert.NotContains(t, body, "trace_id")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"runtime"
 	"sync"
+	"time"
 
 	"go.opencensus.io/metric"
 	"go.opencensus.io/metric/metricdata"
@@ -25,6 +26,13 @@ type metricRegistry struct {
 	buildInfo      *metric.Int64Gauge
 	policyCount    *metric.Int64DerivedGauge
 	configChecksum *metric.Float64Gauge
+
+	replicaStaleness *metric.Float64DerivedGauge
+	replicaRecords   *metric.Int64DerivedGauge
+
+	policyCompileQueue *metric.Int64DerivedGauge
+
+	certificateExpiry *metric.Float64DerivedGauge
 	sync.Once
 }
 
@@ -71,6 +79,38 @@ func (r *metricRegistry) init() {
 				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register policy count metric")
 			}
 
+			r.replicaStaleness, err = r.registry.AddFloat64DerivedGauge(metrics.AuthorizeReplicaStalenessSeconds,
+				metric.WithDescription("Seconds the authorize replica of a record type has been out of sync with the databroker"),
+				metric.WithLabelKeys(metrics.ServiceLabel, metrics.RecordTypeLabel),
+			)
+			if err != nil {
+				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register replica staleness metric")
+			}
+
+			r.replicaRecords, err = r.registry.AddInt64DerivedGauge(metrics.AuthorizeReplicaRecords,
+				metric.WithDescription("Number of records in the authorize replica of a record type"),
+				metric.WithLabelKeys(metrics.ServiceLabel, metrics.RecordTypeLabel),
+			)
+			if err != nil {
+				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register replica records metric")
+			}
+
+			r.policyCompileQueue, err = r.registry.AddInt64DerivedGauge(metrics.AuthorizePolicyCompileQueue,
+				metric.WithDescription("Number of policies waiting to be compiled"),
+				metric.WithLabelKeys(metrics.ServiceLabel),
+			)
+			if err != nil {
+				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register policy compile queue metric")
+			}
+
+			r.certificateExpiry, err = r.registry.AddFloat64DerivedGauge(metrics.CertificateExpiryDays,
+				metric.WithDescription("Number of days until a configured certificate expires"),
+				metric.WithLabelKeys(metrics.SourceLabel, metrics.RouteLabel, metrics.SubjectLabel),
+			)
+			if err != nil {
+				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register certificate expiry metric")
+			}
+
 			err = registerAutocertMetrics(r.registry)
 			if err != nil {
 				log.Error(ctx).Err(err).Msg("telemetry/metrics: failed to register autocert metrics")
@@ -111,6 +151,43 @@ func (r *metricRegistry) addPolicyCountCallback(service string, f func() int64)
 	}
 }
 
+func (r *metricRegistry) addPolicyCompileQueueCallback(service string, f func() int64) {
+	if r.policyCompileQueue == nil {
+		return
+	}
+	err := r.policyCompileQueue.UpsertEntry(f, metricdata.NewLabelValue(service))
+	if err != nil {
+		log.Error(context.TODO()).Err(err).Msg("telemetry/metrics: failed to get policy compile queue metric")
+	}
+}
+
+func (r *metricRegistry) addReplicaCallbacks(recordType string, staleness func() float64, records func() int64) {
+	if r.replicaStaleness == nil || r.replicaRecords == nil {
+		return
+	}
+	labels := []metricdata.LabelValue{metricdata.NewLabelValue("authorize"), metricdata.NewLabelValue(recordType)}
+	err := r.replicaStaleness.UpsertEntry(staleness, labels...)
+	if err != nil {
+		log.Error(context.TODO()).Err(err).Msg("telemetry/metrics: failed to get replica staleness metric")
+	}
+	err = r.replicaRecords.UpsertEntry(records, labels...)
+	if err != nil {
+		log.Error(context.TODO()).Err(err).Msg("telemetry/metrics: failed to get replica records metric")
+	}
+}
+
+func (r *metricRegistry) setCertificateExpiry(source, route, subject string, notAfter time.Time) {
+	if r.certificateExpiry == nil {
+		return
+	}
+	err := r.certificateExpiry.UpsertEntry(func() float64 {
+		return time.Until(notAfter).Hours() / 24
+	}, metricdata.NewLabelValue(source), metricdata.NewLabelValue(route), metricdata.NewLabelValue(subject))
+	if err != nil {
+		log.Error(context.TODO()).Err(err).Msg("telemetry/metrics: failed to get certificate expiry metric")
+	}
+}
+
 func (r *metricRegistry) setConfigChecksum(service string, configName string, checksum uint64) {
 	if r.configChecksum == nil {
 		return
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+var (
+	// RequestLimitViews contains opencensus views for route request limit metrics
+	RequestLimitViews = []*view.View{RequestLimitExceededView}
+
+	requestLimitExceeded = stats.Int64(
+		"request_limit_exceeded_total",
+		"Total requests rejected for exceeding a route request limit",
+		stats.UnitDimensionless)
+
+	// RequestLimitExceededView is an OpenCensus view that tracks requests rejected
+	// for exceeding a route request limit by host and limit
+	RequestLimitExceededView = &view.View{
+		Name:        requestLimitExceeded.Name(),
+		Description: requestLimitExceeded.Description(),
+		Measure:     requestLimitExceeded,
+		TagKeys:     []tag.Key{TagKeyService, TagKeyHost, TagKeyRequestLimit},
+		Aggregation: view.Count(),
+	}
+)
+
+// RecordRequestLimitExceeded records a request rejected for exceeding a route request limit.
+func RecordRequestLimitExceeded(ctx context.Context, host, limit string) {
+	err := stats.RecordWithTags(ctx,
+		[]tag.Mutator{
+			upsertTag(TagKeyService, "authorize"),
+			upsertTag(TagKeyHost, host),
+			upsertTag(TagKeyRequestLimit, limit),
+		},
+		requestLimitExceeded.M(1),
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func Test_RecordRequestLimitExceeded(t *testing.T) {
+	view.Unregister(RequestLimitViews...)
+	view.Register(RequestLimitViews...)
+	RecordRequestLimitExceeded(context.Background(), "from.example.com", "body_bytes")
+
+	testDataRetrieval(RequestLimitExceededView, t, "{ { {host from.example.com}{limit body_bytes}{service authorize} }")
+}
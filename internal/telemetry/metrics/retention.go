@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+var (
+	// RetentionViews contains opencensus views for the databroker retention metrics
+	RetentionViews = []*view.View{RetentionDeletedRecordsView, RetentionRunDurationView}
+
+	retentionDeletedRecords = stats.Int64(
+		"databroker_retention_deleted_records_total",
+		"Total databroker records deleted by retention policies",
+		stats.UnitDimensionless)
+	retentionRunDuration = stats.Int64(
+		"databroker_retention_run_duration_ms",
+		"Duration of applying the databroker retention policies in ms",
+		"ms")
+
+	// RetentionDeletedRecordsView is an OpenCensus view that counts the records deleted by
+	// retention policies, by record type
+	RetentionDeletedRecordsView = &view.View{
+		Name:        retentionDeletedRecords.Name(),
+		Description: retentionDeletedRecords.Description(),
+		Measure:     retentionDeletedRecords,
+		TagKeys:     []tag.Key{TagKeyRecordType, TagKeyService},
+		Aggregation: view.Sum(),
+	}
+
+	// RetentionRunDurationView is an OpenCensus view that tracks how long applying a retention
+	// policy takes, by record type and result
+	RetentionRunDurationView = &view.View{
+		Name:        retentionRunDuration.Name(),
+		Description: retentionRunDuration.Description(),
+		Measure:     retentionRunDuration,
+		TagKeys:     []tag.Key{TagKeyRecordType, TagKeyStorageResult, TagKeyService},
+		Aggregation: DefaultMillisecondsDistribution,
+	}
+)
+
+// RecordRetentionRun records applying a retention policy to a record type, along with the number
+// of records it deleted.
+func RecordRetentionRun(ctx context.Context, recordType string, deleted int, duration time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	err = recordWithExemplar(ctx,
+		[]tag.Mutator{
+			upsertTag(TagKeyRecordType, recordType),
+			upsertTag(TagKeyStorageResult, result),
+			upsertTag(TagKeyService, "databroker"),
+		},
+		retentionRunDuration.M(duration.Milliseconds()),
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+
+	if deleted == 0 {
+		return
+	}
+	err = stats.RecordWithTags(ctx,
+		[]tag.Mutator{
+			upsertTag(TagKeyRecordType, recordType),
+			upsertTag(TagKeyService, "databroker"),
+		},
+		retentionDeletedRecords.M(int64(deleted)),
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+}
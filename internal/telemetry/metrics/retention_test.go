@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func Test_RecordRetentionRun(t *testing.T) {
+	view.Unregister(RetentionViews...)
+	view.Register(RetentionViews...)
+	RecordRetentionRun(context.Background(), "test", 3, time.Millisecond*5, nil)
+
+	testDataRetrieval(RetentionDeletedRecordsView, t, "{ { {record_type test}{service databroker} }")
+	testDataRetrieval(RetentionRunDurationView, t, "{ { {record_type test}{result success}{service databroker} }&{1 5 5 5 0")
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// Route request decisions.
+const (
+	RouteDecisionAllow = "allow"
+	RouteDecisionDeny  = "deny"
+	RouteDecisionError = "error"
+)
+
+var (
+	// RouteViews contains opencensus views for per-route availability and latency metrics
+	RouteViews = []*view.View{RouteRequestsView, RouteRequestDurationView}
+
+	routeRequests = stats.Int64(
+		"route_requests_total",
+		"Total requests to a route",
+		stats.UnitDimensionless)
+	routeRequestDuration = stats.Float64(
+		"route_request_duration_ms",
+		"Duration of requests to a route in ms",
+		stats.UnitMilliseconds)
+
+	// RouteRequestsView is an OpenCensus view that counts the requests to a route, by route id,
+	// authorization decision and response status class
+	RouteRequestsView = &view.View{
+		Name:        routeRequests.Name(),
+		Description: routeRequests.Description(),
+		Measure:     routeRequests,
+		TagKeys:     []tag.Key{TagKeyService, TagKeyRouteID, TagKeyDecision, TagKeyStatusClass},
+		Aggregation: view.Count(),
+	}
+
+	// RouteRequestDurationView is an OpenCensus view that tracks the duration of requests to a
+	// route, by route id and authorization decision
+	RouteRequestDurationView = &view.View{
+		Name:        routeRequestDuration.Name(),
+		Description: routeRequestDuration.Description(),
+		Measure:     routeRequestDuration,
+		TagKeys:     []tag.Key{TagKeyService, TagKeyRouteID, TagKeyDecision},
+		Aggregation: DefaultHTTPLatencyDistrubtion,
+	}
+)
+
+// RecordRouteRequest records a request to a route, along with its authorization decision,
+// response status code and duration.
+func RecordRouteRequest(ctx context.Context, routeID, decision string, statusCode uint32, duration time.Duration) {
+	err := stats.RecordWithTags(ctx,
+		[]tag.Mutator{
+			upsertTag(TagKeyService, "proxy"),
+			upsertTag(TagKeyRouteID, routeID),
+			upsertTag(TagKeyDecision, decision),
+			upsertTag(TagKeyStatusClass, statusClass(statusCode)),
+		},
+		routeRequests.M(1),
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+
+	err = stats.RecordWithTags(ctx,
+		[]tag.Mutator{
+			upsertTag(TagKeyService, "proxy"),
+			upsertTag(TagKeyRouteID, routeID),
+			upsertTag(TagKeyDecision, decision),
+		},
+		routeRequestDuration.M(float64(duration)/float64(time.Millisecond)),
+	)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("internal/telemetry/metrics: failed to record")
+	}
+}
+
+// statusClass returns the class of a status code, such as 2xx.
+func statusClass(statusCode uint32) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+	return strconv.FormatUint(uint64(statusCode/100), 10) + "xx"
+}
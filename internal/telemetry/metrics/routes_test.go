@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func Test_RecordRouteRequest(t *testing.T) {
+	view.Unregister(RouteViews...)
+	view.Register(RouteViews...)
+	RecordRouteRequest(context.Background(), "app", RouteDecisionAllow, 503, 5*time.Millisecond)
+
+	testDataRetrieval(RouteRequestsView, t, "{ { {decision allow}{route_id app}{service proxy}{status_class 5xx} }")
+}
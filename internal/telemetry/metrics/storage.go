@@ -45,14 +45,14 @@ func RecordStorageOperation(ctx context.Context, tags *StorageOperationTags, dur
 		result = "error"
 	}
 
-	err := stats.RecordWithTags(ctx,
+	err := recordWithExemplar(ctx,
 		[]tag.Mutator{
-			tag.Upsert(TagKeyStorageOperation, tags.Operation),
-			tag.Upsert(TagKeyStorageResult, result),
-			tag.Upsert(TagKeyStorageBackend, tags.Backend),
+			upsertTag(TagKeyStorageOperation, tags.Operation),
+			upsertTag(TagKeyStorageResult, result),
+			upsertTag(TagKeyStorageBackend, tags.Backend),
 			// TODO service tag does not consistently come in from RPCs.  Requires
 			// follow up
-			tag.Upsert(TagKeyService, "databroker"),
+			upsertTag(TagKeyService, "databroker"),
 		},
 		storageOperationDuration.M(duration.Milliseconds()),
 	)
@@ -0,0 +1,109 @@
+// Package otlp contains helpers for exporting telemetry using the OpenTelemetry protocol. Like
+// the OpenTelemetry SDKs, the exporters are configured with the standard OTEL_* environment
+// variables.
+//
+// https://opentelemetry.io/docs/specs/otel/protocol/exporter/
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Signals are the types of telemetry.
+const (
+	SignalTraces  = "traces"
+	SignalMetrics = "metrics"
+)
+
+// Protocols are the supported OTLP transport protocols.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+)
+
+// lookup returns the value of the signal specific environment variable, or of the generic
+// environment variable if the signal specific one isn't set.
+func lookup(signal, name string) string {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_" + name); v != "" {
+		return v
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_" + name)
+}
+
+// GetProtocol returns the protocol used to export a signal, from the OTEL_EXPORTER_OTLP_PROTOCOL
+// environment variables. Defaults to http/protobuf.
+func GetProtocol(signal string) (string, error) {
+	switch protocol := lookup(signal, "PROTOCOL"); protocol {
+	case "", ProtocolHTTPProtobuf:
+		return ProtocolHTTPProtobuf, nil
+	case ProtocolGRPC:
+		return ProtocolGRPC, nil
+	default:
+		return "", fmt.Errorf("otlp: unsupported %s protocol: %s", signal, protocol)
+	}
+}
+
+// GetEndpoint returns the url a signal is exported to, from the OTEL_EXPORTER_OTLP_ENDPOINT
+// environment variables. Defaults to the local collector.
+func GetEndpoint(signal string) (*url.URL, error) {
+	protocol, err := GetProtocol(signal)
+	if err != nil {
+		return nil, err
+	}
+
+	rawURL := os.Getenv("OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_ENDPOINT")
+	if rawURL == "" {
+		rawURL = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		// the generic http endpoint is the base url of the signal paths
+		if rawURL != "" && protocol == ProtocolHTTPProtobuf {
+			rawURL = strings.TrimSuffix(rawURL, "/") + "/v1/" + signal
+		}
+	}
+	if rawURL == "" {
+		if protocol == ProtocolGRPC {
+			rawURL = "http://localhost:4317"
+		} else {
+			rawURL = "http://localhost:4318/v1/" + signal
+		}
+	}
+
+	// grpc endpoints may omit the scheme
+	if !strings.Contains(rawURL, "://") {
+		if strings.EqualFold(lookup(signal, "INSECURE"), "true") {
+			rawURL = "http://" + rawURL
+		} else {
+			rawURL = "https://" + rawURL
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("otlp: invalid %s endpoint: %w", signal, err)
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("otlp: invalid %s endpoint: %s", signal, rawURL)
+	}
+	return u, nil
+}
+
+// NewResource returns the resource describing a pomerium service. The OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES environment variables override the detected attributes.
+func NewResource(ctx context.Context, service string) (*resource.Resource, error) {
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", service)),
+		resource.WithFromEnv(),
+	)
+	// a partial resource is still usable
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return nil, fmt.Errorf("otlp: error creating resource: %w", err)
+	}
+	return res, nil
+}
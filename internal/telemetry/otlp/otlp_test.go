@@ -0,0 +1,80 @@
+package otlp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProtocol(t *testing.T) {
+	protocol, err := GetProtocol(SignalTraces)
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolHTTPProtobuf, protocol)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	protocol, err = GetProtocol(SignalTraces)
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolGRPC, protocol)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "http/protobuf")
+	protocol, err = GetProtocol(SignalTraces)
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolHTTPProtobuf, protocol)
+	protocol, err = GetProtocol(SignalMetrics)
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolGRPC, protocol)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "http/json")
+	_, err = GetProtocol(SignalMetrics)
+	assert.Error(t, err)
+}
+
+func TestGetEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		env    map[string]string
+		expect string
+	}{
+		{"default http", nil, "http://localhost:4318/v1/traces"},
+		{"default grpc", map[string]string{
+			"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+		}, "http://localhost:4317"},
+		{"generic http", map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector.example.com:4318/",
+		}, "https://collector.example.com:4318/v1/traces"},
+		{"generic grpc", map[string]string{
+			"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc",
+			"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector.example.com:4317",
+		}, "https://collector.example.com:4317"},
+		{"signal specific", map[string]string{
+			"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://collector.example.com:4318",
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces.example.com/custom",
+		}, "https://traces.example.com/custom"},
+		{"no scheme", map[string]string{
+			"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "collector.example.com:4317",
+		}, "https://collector.example.com:4317"},
+		{"no scheme insecure", map[string]string{
+			"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+			"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "collector.example.com:4317",
+			"OTEL_EXPORTER_OTLP_INSECURE":        "true",
+		}, "http://collector.example.com:4317"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			u, err := GetEndpoint(SignalTraces)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, u.String())
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "ftp://collector.example.com")
+		_, err := GetEndpoint(SignalTraces)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,177 @@
+// Package slo tracks the error budgets of route service level objectives.
+package slo
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// bucketsPerWindow is the number of buckets a window is divided into. The window rolls forward
+// one bucket at a time.
+const bucketsPerWindow = 60
+
+// An Objective is the service level objective of a route.
+type Objective struct {
+	// Availability is the target fraction of requests which don't fail. 0 disables the objective.
+	Availability float64
+	// LatencyThreshold is the duration within which a request must complete to count as fast.
+	LatencyThreshold time.Duration
+	// LatencyTarget is the target fraction of fast requests. 0 disables the objective.
+	LatencyTarget float64
+	// Window is the rolling window the error budget is computed over.
+	Window time.Duration
+}
+
+// A Budget summarizes the error budget of a route's objective.
+type Budget struct {
+	// Target is the objective's target fraction of good requests.
+	Target float64 `json:"target"`
+	// Total is the number of requests in the window.
+	Total uint64 `json:"total"`
+	// Bad is the number of requests in the window which didn't meet the objective.
+	Bad uint64 `json:"bad"`
+	// Actual is the fraction of good requests in the window.
+	Actual float64 `json:"actual"`
+	// Remaining is the fraction of the error budget which remains. It's negative once the
+	// budget is exhausted.
+	Remaining float64 `json:"remaining"`
+}
+
+// A RouteBudgets summarizes the error budgets of a route.
+type RouteBudgets struct {
+	RouteID      string  `json:"route_id"`
+	Window       string  `json:"window"`
+	Availability *Budget `json:"availability,omitempty"`
+	Latency      *Budget `json:"latency,omitempty"`
+}
+
+type bucket struct {
+	start       time.Time
+	total       uint64
+	unavailable uint64
+	slow        uint64
+}
+
+type route struct {
+	objective Objective
+	buckets   [bucketsPerWindow]bucket
+}
+
+func (r *route) bucketDuration() time.Duration {
+	return r.objective.Window / bucketsPerWindow
+}
+
+// A Tracker tracks the error budgets of routes over rolling windows.
+type Tracker struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	routes map[string]*route
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		now:    time.Now,
+		routes: make(map[string]*route),
+	}
+}
+
+// SetObjectives sets the objectives of the tracked routes. Routes without an objective are no
+// longer tracked, and the budget of a route is reset when its window changes.
+func (t *Tracker) SetObjectives(objectives map[string]Objective) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for routeID := range t.routes {
+		if _, ok := objectives[routeID]; !ok {
+			delete(t.routes, routeID)
+		}
+	}
+	for routeID, objective := range objectives {
+		r, ok := t.routes[routeID]
+		if !ok || r.objective.Window != objective.Window {
+			r = &route{}
+			t.routes[routeID] = r
+		}
+		r.objective = objective
+	}
+}
+
+// Record records a request to a route. Requests to routes without an objective are ignored.
+func (t *Tracker) Record(routeID string, available bool, duration time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.routes[routeID]
+	if !ok {
+		return
+	}
+
+	now := t.now()
+	bucketDuration := r.bucketDuration()
+	start := now.Truncate(bucketDuration)
+	b := &r.buckets[(start.UnixNano()/int64(bucketDuration))%bucketsPerWindow]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+
+	b.total++
+	if !available {
+		b.unavailable++
+	}
+	if r.objective.LatencyTarget > 0 && duration > r.objective.LatencyThreshold {
+		b.slow++
+	}
+}
+
+// Budgets returns the error budgets of the tracked routes, sorted by route id.
+func (t *Tracker) Budgets() []RouteBudgets {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	budgets := make([]RouteBudgets, 0, len(t.routes))
+	for routeID, r := range t.routes {
+		var total, unavailable, slow uint64
+		for _, b := range r.buckets {
+			if b.start.IsZero() || now.Sub(b.start) >= r.objective.Window {
+				continue
+			}
+			total += b.total
+			unavailable += b.unavailable
+			slow += b.slow
+		}
+
+		rb := RouteBudgets{
+			RouteID: routeID,
+			Window:  r.objective.Window.String(),
+		}
+		if r.objective.Availability > 0 {
+			rb.Availability = newBudget(r.objective.Availability, total, unavailable)
+		}
+		if r.objective.LatencyTarget > 0 {
+			rb.Latency = newBudget(r.objective.LatencyTarget, total, slow)
+		}
+		budgets = append(budgets, rb)
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		return budgets[i].RouteID < budgets[j].RouteID
+	})
+	return budgets
+}
+
+func newBudget(target float64, total, bad uint64) *Budget {
+	b := &Budget{
+		Target:    target,
+		Total:     total,
+		Bad:       bad,
+		Actual:    1,
+		Remaining: 1,
+	}
+	if total > 0 {
+		b.Actual = 1 - float64(bad)/float64(total)
+		b.Remaining = 1 - float64(bad)/(float64(total)*(1-target))
+	}
+	return b
+}
@@ -0,0 +1,77 @@
+package slo
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.SetObjectives(map[string]Objective{
+		"app": {
+			Availability:     0.9,
+			LatencyThreshold: time.Second,
+			LatencyTarget:    0.5,
+			Window:           time.Hour,
+		},
+	})
+
+	tracker.Record("other", false, time.Minute)
+	for i := 0; i < 19; i++ {
+		tracker.Record("app", true, time.Millisecond)
+	}
+	tracker.Record("app", false, 2*time.Second)
+
+	assert.Equal(t, []RouteBudgets{{
+		RouteID:      "app",
+		Window:       "1h0m0s",
+		Availability: &Budget{Target: 0.9, Total: 20, Bad: 1, Actual: 0.95, Remaining: 0.5},
+		Latency:      &Budget{Target: 0.5, Total: 20, Bad: 1, Actual: 0.95, Remaining: 0.9},
+	}}, roundBudgets(tracker.Budgets()))
+
+	t.Run("rolling window", func(t *testing.T) {
+		now = now.Add(59 * time.Minute)
+		tracker.Record("app", true, time.Millisecond)
+		budgets := tracker.Budgets()
+		assert.Equal(t, uint64(21), budgets[0].Availability.Total)
+
+		now = now.Add(2 * time.Minute)
+		budgets = tracker.Budgets()
+		assert.Equal(t, uint64(1), budgets[0].Availability.Total)
+		assert.Equal(t, uint64(0), budgets[0].Availability.Bad)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			tracker.Record("app", false, time.Millisecond)
+		}
+		budgets := roundBudgets(tracker.Budgets())
+		assert.Equal(t, -6.5, budgets[0].Availability.Remaining)
+	})
+
+	t.Run("objective removed", func(t *testing.T) {
+		tracker.SetObjectives(nil)
+		assert.Empty(t, tracker.Budgets())
+	})
+}
+
+func roundBudgets(budgets []RouteBudgets) []RouteBudgets {
+	round := func(b *Budget) {
+		if b != nil {
+			b.Actual = math.Round(b.Actual*1e6) / 1e6
+			b.Remaining = math.Round(b.Remaining*1e6) / 1e6
+		}
+	}
+	for _, rb := range budgets {
+		round(rb.Availability)
+		round(rb.Latency)
+	}
+	return budgets
+}
@@ -1,2 +1,3 @@
-// Package trace contains support for OpenCensus distributed tracing.
+// Package trace contains support for OpenCensus distributed tracing. Spans can be exported to
+// Datadog, Jaeger, Zipkin or, using the OpenCensus bridge, to an OTLP collector.
 package trace
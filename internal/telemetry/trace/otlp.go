@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	octrace "go.opencensus.io/trace"
+	ocbridge "go.opentelemetry.io/otel/bridge/opencensus"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/pomerium/pomerium/internal/telemetry/otlp"
+)
+
+// otlpProvider exports traces using OTLP. The OpenCensus spans are bridged to an OpenTelemetry
+// tracer, so existing instrumentation and propagation keep working.
+type otlpProvider struct {
+	tracerProvider *sdktrace.TracerProvider
+	previousTracer octrace.Tracer
+}
+
+func (provider *otlpProvider) Register(opts *TracingOptions) error {
+	ctx := context.Background()
+
+	var client otlptrace.Client
+	switch opts.OTLPProtocol {
+	case otlp.ProtocolGRPC:
+		client = otlptracegrpc.NewClient()
+	default:
+		client = otlptracehttp.NewClient()
+	}
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return fmt.Errorf("telemetry/trace: error creating otlp exporter: %w", err)
+	}
+
+	res, err := otlp.NewResource(ctx, opts.Service)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return err
+	}
+
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	}
+	// the sampler set by OTEL_TRACES_SAMPLER takes precedence over the sample rate
+	if os.Getenv("OTEL_TRACES_SAMPLER") == "" {
+		tpOpts = append(tpOpts, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRate))))
+	}
+
+	provider.tracerProvider = sdktrace.NewTracerProvider(tpOpts...)
+	provider.previousTracer = octrace.DefaultTracer
+	octrace.DefaultTracer = ocbridge.NewTracer(provider.tracerProvider.Tracer("github.com/pomerium/pomerium"))
+	return nil
+}
+
+func (provider *otlpProvider) Unregister() error {
+	if provider.tracerProvider == nil {
+		return nil
+	}
+	octrace.DefaultTracer = provider.previousTracer
+	err := provider.tracerProvider.Shutdown(context.Background())
+	provider.tracerProvider = nil
+	return err
+}
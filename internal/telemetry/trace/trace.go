@@ -15,6 +15,8 @@ const (
 	DatadogTracingProviderName = "datadog"
 	// JaegerTracingProviderName is the name of the tracing provider Jaeger.
 	JaegerTracingProviderName = "jaeger"
+	// OTLPTracingProviderName is the name of the tracing provider OTLP.
+	OTLPTracingProviderName = "otlp"
 	// ZipkinTracingProviderName is the name of the tracing provider Zipkin.
 	ZipkinTracingProviderName = "zipkin"
 )
@@ -50,6 +52,14 @@ type TracingOptions struct {
 	// Example: http://zipkin:9411/api/v2/spans
 	ZipkinEndpoint *url.URL
 
+	// OTLP
+
+	// OTLPEndpoint is the url traces are exported to, as configured by the OTEL_EXPORTER_OTLP_ENDPOINT
+	// environment variables. The other OTEL_* environment variables are read by the exporter.
+	OTLPEndpoint *url.URL
+	// OTLPProtocol is the OTLP transport protocol, either grpc or http/protobuf.
+	OTLPProtocol string
+
 	// SampleRate is percentage of requests which are sampled
 	SampleRate float64
 }
@@ -67,6 +77,8 @@ func GetProvider(opts *TracingOptions) (Provider, error) {
 		provider = new(datadogProvider)
 	case JaegerTracingProviderName:
 		provider = new(jaegerProvider)
+	case OTLPTracingProviderName:
+		provider = new(otlpProvider)
 	case ZipkinTracingProviderName:
 		provider = new(zipkinProvider)
 	default:
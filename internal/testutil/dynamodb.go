@@ -0,0 +1,59 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/ory/dockertest/v3"
+)
+
+// WithTestDynamoDB creates a test DynamoDB Local instance using docker.
+func WithTestDynamoDB(t *testing.T, handler func(rawURL string) error) error {
+	t.Helper()
+
+	ctx, clearTimeout := context.WithTimeout(context.Background(), maxWait)
+	defer clearTimeout()
+
+	// uses a sensible default on windows (tcp/http) and linux/osx (socket)
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		return err
+	}
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "amazon/dynamodb-local",
+		Tag:        "2.0.0",
+		Cmd:        []string{"-jar", "DynamoDBLocal.jar", "-inMemory"},
+	})
+	if err != nil {
+		return err
+	}
+	_ = resource.Expire(uint(maxWait.Seconds()))
+
+	hostPort := resource.GetHostPort("8000/tcp")
+	if err := pool.Retry(func() error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", hostPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}); err != nil {
+		_ = pool.Purge(resource)
+		return err
+	}
+
+	// dynamodb local accepts any credentials
+	t.Setenv("AWS_ACCESS_KEY_ID", "pomerium")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "pomerium")
+
+	e := handler(fmt.Sprintf("dynamodb://pomerium?region=us-east-1&endpoint=http://%s", hostPort))
+
+	if err := pool.Purge(resource); err != nil {
+		return err
+	}
+
+	return e
+}
@@ -0,0 +1,184 @@
+// Package throttle throttles failed authentication attempts with exponential backoff.
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// RecordType is the databroker record type of the failure counters.
+const RecordType = "pomerium.io/AuthThrottle"
+
+// IPKey returns the key of the failures of a client IP.
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// IdentityKey returns the key of the failures of an identity, like a user id.
+func IdentityKey(id string) string {
+	return "identity:" + id
+}
+
+type counter struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// A Throttle counts failed attempts in the databroker, so that limits hold across replicas.
+//
+// Counters are read and then written, so concurrent failures on different replicas may be counted
+// once. That's enough to slow down brute forcing.
+type Throttle struct {
+	client   databroker.DataBrokerServiceClient
+	settings config.AuthThrottleSettings
+	now      func() time.Time
+}
+
+// New creates a new Throttle. If throttling is disabled, or the Throttle is nil, every attempt is
+// allowed.
+func New(client databroker.DataBrokerServiceClient, settings config.AuthThrottleSettings) *Throttle {
+	return &Throttle{
+		client:   client,
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// Check returns how long the caller has to wait before the next attempt for the keys is allowed,
+// or 0 if it's allowed now.
+func (t *Throttle) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	if t == nil || !t.settings.Enabled {
+		return 0, nil
+	}
+
+	now := t.now()
+	var wait time.Duration
+	for _, key := range keys {
+		c, err := t.get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if d := t.blockedUntil(c).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Failure records a failed attempt for the keys.
+func (t *Throttle) Failure(ctx context.Context, keys ...string) error {
+	if t == nil || !t.settings.Enabled {
+		return nil
+	}
+
+	now := t.now()
+	for _, key := range keys {
+		c, err := t.get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if now.Sub(c.LastFailure) > t.settings.GetMaxDelay() {
+			c = &counter{}
+		}
+		c.Failures++
+		c.LastFailure = now
+		if _, err := databroker.PutViaJSON(ctx, t.client, RecordType, key, c); err != nil {
+			return fmt.Errorf("throttle: error storing failures: %w", err)
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failed attempts of the keys. It's called after a successful attempt with the
+// keys of the identity, but not of the client IP, so one valid account can't be used to reset the
+// limit of an IP.
+func (t *Throttle) Reset(ctx context.Context, keys ...string) error {
+	if t == nil || !t.settings.Enabled {
+		return nil
+	}
+
+	records := make([]*databroker.Record, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, &databroker.Record{
+			Type:      RecordType,
+			Id:        key,
+			Data:      protoutil.NewAny(new(structpb.Struct)),
+			DeletedAt: timestamppb.Now(),
+		})
+	}
+	if _, err := t.client.Put(ctx, &databroker.PutRequest{Records: records}); err != nil {
+		return fmt.Errorf("throttle: error resetting failures: %w", err)
+	}
+	return nil
+}
+
+// CheckRequest checks whether an attempt for the keys is allowed. If it isn't, the Retry-After
+// header is set and a 429 error is returned.
+func (t *Throttle) CheckRequest(w http.ResponseWriter, r *http.Request, keys ...string) error {
+	wait, err := t.Check(r.Context(), keys...)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	return httputil.NewError(http.StatusTooManyRequests, errors.New("too many failed attempts"))
+}
+
+// RecordResult records a failure for the keys if err is a client error. Errors of the service
+// itself, like an unreachable identity provider, aren't the client's fault. Errors storing the
+// failure are logged, so they don't hide the original error.
+func (t *Throttle) RecordResult(r *http.Request, err error, keys ...string) {
+	var httpErr *httputil.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Status < 400 || httpErr.Status >= 500 ||
+		httpErr.Status == http.StatusTooManyRequests {
+		return
+	}
+	if err := t.Failure(r.Context(), keys...); err != nil {
+		log.FromRequest(r).Error().Err(err).Msg("throttle: error recording failed attempt")
+	}
+}
+
+func (t *Throttle) get(ctx context.Context, key string) (*counter, error) {
+	c, err := databroker.GetViaJSON[counter](ctx, t.client, RecordType, key)
+	if status.Code(err) == codes.NotFound {
+		return &counter{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("throttle: error getting failures: %w", err)
+	}
+	return c, nil
+}
+
+// blockedUntil returns the time until which attempts are blocked after the failures of the
+// counter.
+func (t *Throttle) blockedUntil(c *counter) time.Time {
+	excess := c.Failures - t.settings.GetMaxAttempts()
+	if excess < 0 {
+		return time.Time{}
+	}
+
+	delay := t.settings.GetMaxDelay()
+	if excess < 32 {
+		if d := t.settings.GetBaseDelay() << excess; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	return c.LastFailure.Add(delay)
+}
@@ -0,0 +1,104 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+type mockDataBrokerServiceClient struct {
+	databroker.DataBrokerServiceClient
+
+	records map[string]*databroker.Record
+}
+
+func (m *mockDataBrokerServiceClient) Get(_ context.Context, in *databroker.GetRequest, _ ...grpc.CallOption) (*databroker.GetResponse, error) {
+	record, ok := m.records[in.GetId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &databroker.GetResponse{Record: record}, nil
+}
+
+func (m *mockDataBrokerServiceClient) Put(_ context.Context, in *databroker.PutRequest, _ ...grpc.CallOption) (*databroker.PutResponse, error) {
+	for _, record := range in.GetRecords() {
+		if record.GetDeletedAt() != nil {
+			delete(m.records, record.GetId())
+		} else {
+			m.records[record.GetId()] = record
+		}
+	}
+	return &databroker.PutResponse{Records: in.GetRecords()}, nil
+}
+
+func TestThrottle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := &mockDataBrokerServiceClient{records: map[string]*databroker.Record{}}
+	th := New(client, config.AuthThrottleSettings{
+		Enabled:     true,
+		MaxAttempts: 2,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+	})
+	th.now = func() time.Time { return now }
+
+	ip, user := IPKey("127.0.0.1"), IdentityKey("user-1")
+	check := func() time.Duration {
+		wait, err := th.Check(ctx, ip, user)
+		require.NoError(t, err)
+		return wait
+	}
+
+	// free attempts
+	require.NoError(t, th.Failure(ctx, ip, user))
+	assert.Zero(t, check())
+	require.NoError(t, th.Failure(ctx, ip, user))
+	assert.Equal(t, time.Second, check())
+
+	// exponential backoff
+	require.NoError(t, th.Failure(ctx, ip, user))
+	assert.Equal(t, 2*time.Second, check())
+	for i := 0; i < 10; i++ {
+		require.NoError(t, th.Failure(ctx, ip, user))
+	}
+	assert.Equal(t, time.Minute, check(), "should cap the delay")
+
+	// resetting the identity keeps the ip throttled
+	require.NoError(t, th.Reset(ctx, user))
+	wait, err := th.Check(ctx, user)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	assert.Equal(t, time.Minute, check())
+
+	// counters are forgotten after the max delay
+	now = now.Add(2 * time.Minute)
+	assert.Zero(t, check())
+	require.NoError(t, th.Failure(ctx, ip))
+	wait, err = th.Check(ctx, ip)
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestThrottle_Disabled(t *testing.T) {
+	t.Parallel()
+
+	th := New(nil, config.AuthThrottleSettings{})
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, th.Failure(context.Background(), IPKey("127.0.0.1")))
+	}
+	wait, err := th.Check(context.Background(), IPKey("127.0.0.1"))
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+}
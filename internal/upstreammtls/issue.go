@@ -0,0 +1,78 @@
+// Package upstreammtls issues the short-lived client certificates routes present to their
+// upstreams.
+package upstreammtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// issued certificates are valid from slightly before they are issued, to tolerate clock skew
+// between pomerium and the upstream
+const clockSkew = 5 * time.Minute
+
+// RouteURI returns the URI identifying a route in the certificates issued for it. The route is
+// identified by its route match id, so the URI doesn't change when the route's upstreams do.
+func RouteURI(routeID uint64) *url.URL {
+	return &url.URL{Scheme: "urn", Opaque: "pomerium:route:" + strconv.FormatUint(routeID, 10)}
+}
+
+// issueCertificate issues a client certificate for a route, signed by the certificate authority.
+// The certificate's subject is the route's from host and its URI SAN identifies the route.
+func issueCertificate(
+	ca *tls.Certificate,
+	policy *config.Policy,
+	routeID uint64,
+	now time.Time,
+	lifetime time.Duration,
+) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("generate serial number: %w", err)
+	}
+
+	commonName := policy.From
+	if u, err := url.Parse(policy.From); err == nil && u.Hostname() != "" {
+		commonName = u.Hostname()
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Pomerium"}, CommonName: commonName},
+		URIs:         []*url.URL{RouteURI(routeID)},
+		NotBefore:    now.Add(-clockSkew),
+		NotAfter:     now.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Leaf, key.Public(), ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+
+	return &tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
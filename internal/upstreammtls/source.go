@@ -0,0 +1,238 @@
+package upstreammtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const defaultCheckInterval = time.Minute
+
+// A Source is a config source which sets the client certificate of routes with
+// tls_issue_client_cert to a certificate issued by the upstream_mtls certificate authority.
+// Certificates are re-issued after two thirds of their lifetime, which triggers a change.
+type Source struct {
+	underlying config.Source
+	now        func() time.Time
+
+	// updateMu serializes updates so that changes are triggered in order
+	updateMu       sync.Mutex
+	mu             sync.Mutex
+	underlyingCfg  *config.Config
+	computedConfig *config.Config
+	caCert         []byte
+	// issued certificates are keyed by route match id, so changing the upstreams of a route
+	// doesn't re-issue its certificate
+	issued map[uint64]*issuedCertificate
+
+	config.ChangeDispatcher
+}
+
+type issuedCertificate struct {
+	cert    *tls.Certificate
+	renewAt time.Time
+}
+
+// NewSource creates a new Source.
+func NewSource(ctx context.Context, underlying config.Source) *Source {
+	return newSource(ctx, underlying, defaultCheckInterval, time.Now)
+}
+
+func newSource(
+	ctx context.Context,
+	underlying config.Source,
+	checkInterval time.Duration,
+	now func() time.Time,
+) *Source {
+	src := &Source{
+		underlying: underlying,
+		now:        now,
+		issued:     make(map[uint64]*issuedCertificate),
+	}
+	underlying.OnConfigChange(ctx, func(ctx context.Context, cfg *config.Config) {
+		src.onUnderlyingConfigChange(ctx, cfg)
+	})
+	src.onUnderlyingConfigChange(ctx, underlying.GetConfig())
+	go src.run(ctx, checkInterval)
+	return src
+}
+
+// GetConfig gets the computed config.
+func (src *Source) GetConfig() *config.Config {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	return src.computedConfig
+}
+
+func (src *Source) run(ctx context.Context, checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		src.rotate(ctx)
+	}
+}
+
+func (src *Source) onUnderlyingConfigChange(ctx context.Context, cfg *config.Config) {
+	if cfg == nil || cfg.Options == nil {
+		return
+	}
+
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	src.mu.Lock()
+	src.underlyingCfg = cfg
+	computed := src.computeLocked(ctx)
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+// rotate re-issues the certificates which are due for renewal.
+func (src *Source) rotate(ctx context.Context) {
+	src.updateMu.Lock()
+	defer src.updateMu.Unlock()
+
+	src.mu.Lock()
+	now := src.now()
+	due := false
+	for _, ic := range src.issued {
+		if !now.Before(ic.renewAt) {
+			due = true
+			break
+		}
+	}
+	if !due {
+		src.mu.Unlock()
+		return
+	}
+	log.Info(ctx).Msg("upstreammtls: rotating upstream client certificates")
+	computed := src.computeLocked(ctx)
+	src.mu.Unlock()
+
+	src.Trigger(ctx, computed)
+}
+
+func (src *Source) computeLocked(ctx context.Context) *config.Config {
+	cfg := src.underlyingCfg.Clone()
+	if !hasIssuedClientCert(cfg.Options) {
+		src.issued = make(map[uint64]*issuedCertificate)
+		src.computedConfig = cfg
+		return cfg
+	}
+
+	sharedKey, err := cfg.Options.GetSharedKey()
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("upstreammtls: invalid shared key")
+		src.computedConfig = cfg
+		return cfg
+	}
+	ca, err := cfg.Options.UpstreamMTLS.GetCA(sharedKey)
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("upstreammtls: invalid certificate authority")
+		src.computedConfig = cfg
+		return cfg
+	}
+	// certificates issued by a previous certificate authority are re-issued
+	if !bytes.Equal(src.caCert, ca.Certificate[0]) {
+		if !cfg.Options.UpstreamMTLS.HasCA() {
+			log.Warn(ctx).Msg("upstreammtls: no upstream_mtls certificate authority is configured, " +
+				"issuing client certificates using the certificate authority derived from the shared secret. " +
+				"Anyone with the shared secret can issue client certificates trusted by the upstreams.")
+		}
+		src.caCert = ca.Certificate[0]
+		src.issued = make(map[uint64]*issuedCertificate)
+	}
+
+	issued := make(map[uint64]*issuedCertificate)
+	issue := func(policies []config.Policy) []config.Policy {
+		if policies == nil {
+			return nil
+		}
+		updated := make([]config.Policy, len(policies))
+		for i, p := range policies {
+			if p.TLSIssueClientCert {
+				p.ClientCertificate = src.getOrIssueLocked(ctx, ca, cfg.Options, &p, issued)
+			}
+			updated[i] = p
+		}
+		return updated
+	}
+	cfg.Options.Policies = issue(cfg.Options.Policies)
+	cfg.Options.Routes = issue(cfg.Options.Routes)
+	// additional policies are shared, so only the ones with issued certificates are copied
+	if cfg.Options.AdditionalPolicies != nil {
+		updated := make([]*config.Policy, len(cfg.Options.AdditionalPolicies))
+		for i, p := range cfg.Options.AdditionalPolicies {
+			if p.TLSIssueClientCert {
+				u := *p
+				u.ClientCertificate = src.getOrIssueLocked(ctx, ca, cfg.Options, &u, issued)
+				p = &u
+			}
+			updated[i] = p
+		}
+		cfg.Options.AdditionalPolicies = updated
+	}
+
+	src.issued = issued
+	src.computedConfig = cfg
+	return cfg
+}
+
+func (src *Source) getOrIssueLocked(
+	ctx context.Context,
+	ca *tls.Certificate,
+	options *config.Options,
+	policy *config.Policy,
+	issued map[uint64]*issuedCertificate,
+) *tls.Certificate {
+	routeID := policy.RouteMatchID()
+	if ic, ok := issued[routeID]; ok {
+		return ic.cert
+	}
+
+	now := src.now()
+	if ic, ok := src.issued[routeID]; ok && now.Before(ic.renewAt) {
+		issued[routeID] = ic
+		return ic.cert
+	}
+
+	lifetime := options.UpstreamMTLS.GetCertificateLifetime()
+	cert, err := issueCertificate(ca, policy, routeID, now, lifetime)
+	if err != nil {
+		log.Error(ctx).Err(err).Str("from", policy.From).Msg("upstreammtls: failed to issue client certificate")
+		// keep using the previous certificate until it expires
+		if ic, ok := src.issued[routeID]; ok && now.Before(ic.cert.Leaf.NotAfter) {
+			issued[routeID] = ic
+			return ic.cert
+		}
+		return nil
+	}
+	issued[routeID] = &issuedCertificate{
+		cert:    cert,
+		renewAt: now.Add(lifetime * 2 / 3),
+	}
+	return cert
+}
+
+func hasIssuedClientCert(options *config.Options) bool {
+	for _, p := range options.GetAllPolicies() {
+		if p.TLSIssueClientCert {
+			return true
+		}
+	}
+	return false
+}
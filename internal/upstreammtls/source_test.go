@@ -0,0 +1,109 @@
+package upstreammtls
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestSource(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	var mu sync.Mutex
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	getNow := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	sharedKey := cryptutil.NewBase64Key()
+	underlying := config.NewStaticSource(&config.Config{Options: &config.Options{
+		SharedKey: sharedKey,
+		Policies: []config.Policy{
+			{From: "https://a.example.com", To: mustParseWeightedURLs(t, "https://a.internal"), TLSIssueClientCert: true},
+			{From: "https://b.example.com", To: mustParseWeightedURLs(t, "https://b.internal")},
+		},
+	}})
+	src := newSource(ctx, underlying, time.Hour, getNow)
+
+	policies := src.GetConfig().Options.Policies
+	assert.Nil(t, policies[1].ClientCertificate)
+	assert.Nil(t, underlying.GetConfig().Options.Policies[0].ClientCertificate,
+		"should not modify the underlying config")
+	cert := policies[0].ClientCertificate
+	require.NotNil(t, cert)
+
+	assert.Equal(t, "a.example.com", cert.Leaf.Subject.CommonName)
+	assert.Equal(t, RouteURI(policies[0].RouteMatchID()).String(), cert.Leaf.URIs[0].String())
+	assert.Equal(t, now.Add(config.DefaultUpstreamMTLSCertificateLifetime), cert.Leaf.NotAfter)
+
+	sharedKeyBytes, err := base64.StdEncoding.DecodeString(sharedKey)
+	require.NoError(t, err)
+	ca, err := (&config.UpstreamMTLSSettings{}).GetCA(sharedKeyBytes)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err, "should be issued by the derived certificate authority")
+
+	changed := make(chan *config.Config, 1)
+	src.OnConfigChange(ctx, func(_ context.Context, cfg *config.Config) {
+		changed <- cfg
+	})
+
+	src.rotate(ctx)
+	select {
+	case <-changed:
+		t.Fatal("should not rotate certificates before they are due")
+	default:
+	}
+
+	cfg := underlying.GetConfig().Clone()
+	cfg.Options.Policies = []config.Policy{
+		{From: "https://a.example.com", To: mustParseWeightedURLs(t, "https://a2.internal"), TLSIssueClientCert: true},
+	}
+	underlying.SetConfig(ctx, cfg)
+	select {
+	case cfg := <-changed:
+		assert.Same(t, cert, cfg.Options.Policies[0].ClientCertificate,
+			"should keep the certificate when the upstreams change")
+	default:
+		t.Fatal("expected config change")
+	}
+
+	mu.Lock()
+	now = now.Add(17 * time.Hour)
+	mu.Unlock()
+	src.rotate(ctx)
+	select {
+	case cfg := <-changed:
+		rotated := cfg.Options.Policies[0].ClientCertificate
+		require.NotNil(t, rotated)
+		assert.NotEqual(t, cert.Leaf.SerialNumber, rotated.Leaf.SerialNumber)
+	default:
+		t.Fatal("expected certificates to be rotated")
+	}
+}
+
+func mustParseWeightedURLs(t *testing.T, urls ...string) config.WeightedURLs {
+	wu, err := config.ParseWeightedUrls(urls...)
+	require.NoError(t, err)
+	return wu
+}
@@ -107,10 +107,32 @@ func SignInURL(
 	redirectURL *url.URL,
 	idpID string,
 ) (string, error) {
-	signInURL := *authenticateURL
-	signInURL.Path = "/.pomerium/sign_in"
+	return encryptedAuthenticateURL(senderPrivateKey, authenticatePublicKey, authenticateURL, "/.pomerium/sign_in", redirectURL, idpID)
+}
+
+// DeviceAuthURL builds the device authorization URL using an HPKE encrypted query string.
+func DeviceAuthURL(
+	senderPrivateKey *hpke.PrivateKey,
+	authenticatePublicKey *hpke.PublicKey,
+	authenticateURL *url.URL,
+	redirectURL *url.URL,
+	idpID string,
+) (string, error) {
+	return encryptedAuthenticateURL(senderPrivateKey, authenticatePublicKey, authenticateURL, "/.pomerium/device_auth", redirectURL, idpID)
+}
 
-	q := signInURL.Query()
+func encryptedAuthenticateURL(
+	senderPrivateKey *hpke.PrivateKey,
+	authenticatePublicKey *hpke.PublicKey,
+	authenticateURL *url.URL,
+	path string,
+	redirectURL *url.URL,
+	idpID string,
+) (string, error) {
+	u := *authenticateURL
+	u.Path = path
+
+	q := u.Query()
 	q.Set(QueryRedirectURI, redirectURL.String())
 	q.Set(QueryIdentityProviderID, idpID)
 	q.Set(QueryVersion, versionStr())
@@ -120,9 +142,9 @@ func SignInURL(
 	if err != nil {
 		return "", err
 	}
-	signInURL.RawQuery = q.Encode()
+	u.RawQuery = q.Encode()
 
-	return signInURL.String(), nil
+	return u.String(), nil
 }
 
 // SignOutURL returns the /.pomerium/sign_out URL.
@@ -139,6 +161,20 @@ func SignOutURL(r *http.Request, authenticateURL *url.URL, key []byte) string {
 	return NewSignedURL(key, u).Sign().String()
 }
 
+// RevokeSessionURLPath is the path used to revoke one of the current user's sessions.
+const RevokeSessionURLPath = "/.pomerium/revoke_session"
+
+// RevokeSessionURL returns the /.pomerium/revoke_session URL for the given session.
+func RevokeSessionURL(baseURL *url.URL, key []byte, sessionID string) string {
+	u := baseURL.ResolveReference(&url.URL{
+		Path: RevokeSessionURLPath,
+		RawQuery: url.Values{
+			QuerySessionID: {sessionID},
+		}.Encode(),
+	})
+	return NewSignedURL(key, u).Sign().String()
+}
+
 // Device paths
 const (
 	WebAuthnURLPath    = "/.pomerium/webauthn"
@@ -118,3 +118,19 @@ func TestSignOutURL(t *testing.T) {
 	assert.NotEmpty(t, q.Get(QueryHmacSignature))
 	assert.Equal(t, "https://www.example.com/redirect", q.Get(QueryRedirectURI))
 }
+
+func TestRevokeSessionURL(t *testing.T) {
+	t.Parallel()
+
+	baseURL := MustParseAndValidateURL("https://route.example.com/.pomerium/")
+
+	rawRevokeSessionURL := RevokeSessionURL(&baseURL, []byte("TEST"), "SESSION-1")
+	revokeSessionURL, err := ParseAndValidateURL(rawRevokeSessionURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/.pomerium/revoke_session", revokeSessionURL.Path)
+	assert.NoError(t, NewSignedURL([]byte("TEST"), revokeSessionURL).Validate())
+
+	q := revokeSessionURL.Query()
+	assert.NotEmpty(t, q.Get(QueryHmacSignature))
+	assert.Equal(t, "SESSION-1", q.Get(QuerySessionID))
+}
@@ -117,7 +117,9 @@ func GetDomainsForURL(u *url.URL) []string {
 	// => ssh.example.com:22
 	// tcp+https://proxy.example.com/ssh.example.com:22
 	// => ssh.example.com:22
-	if strings.HasPrefix(u.Scheme, "tcp+") {
+	// udp+https://dns.example.com:53
+	// => dns.example.com:53
+	if strings.HasPrefix(u.Scheme, "tcp+") || strings.HasPrefix(u.Scheme, "udp+") {
 		hosts := strings.Split(u.Path, "/")[1:]
 		// if there are no domains in the path part of the URL, use the host
 		if len(hosts) == 0 {
@@ -127,6 +129,26 @@ func GetDomainsForURL(u *url.URL) []string {
 		return hosts
 	}
 
+	// egress://example.com:443
+	// => example.com:443
+	// egress://example.com
+	// => example.com:*
+	if u.Scheme == "egress" {
+		if u.Port() == "" {
+			return []string{u.Hostname() + ":*"}
+		}
+		return []string{u.Host}
+	}
+
+	// ssh://ssh.example.com
+	// => ssh.example.com:22
+	if u.Scheme == "ssh" {
+		if u.Port() == "" {
+			return []string{net.JoinHostPort(u.Hostname(), "22")}
+		}
+		return []string{u.Host}
+	}
+
 	var defaultPort string
 	if u.Scheme == "http" {
 		defaultPort = "80"
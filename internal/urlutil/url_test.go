@@ -174,6 +174,10 @@ func TestGetDomainsForURL(t *testing.T) {
 		{"Host contains other port", &url.URL{Scheme: "https", Host: "example.com:1234"}, []string{"example.com:1234"}},
 		{"tcp", &url.URL{Scheme: "tcp+https", Host: "example.com:1234"}, []string{"example.com:1234"}},
 		{"tcp with path", &url.URL{Scheme: "tcp+https", Host: "proxy.example.com", Path: "/ssh.example.com:1234"}, []string{"ssh.example.com:1234"}},
+		{"ssh", &url.URL{Scheme: "ssh", Host: "ssh.example.com"}, []string{"ssh.example.com:22"}},
+		{"ssh with port", &url.URL{Scheme: "ssh", Host: "ssh.example.com:2222"}, []string{"ssh.example.com:2222"}},
+		{"egress", &url.URL{Scheme: "egress", Host: "*.example.com"}, []string{"*.example.com:*"}},
+		{"egress with port", &url.URL{Scheme: "egress", Host: "example.com:443"}, []string{"example.com:443"}},
 	}
 	for _, tc := range tests {
 		tc := tc
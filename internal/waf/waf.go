@@ -0,0 +1,132 @@
+// Package waf contains a web application firewall based on Coraza and the OWASP Core Rule Set.
+package waf
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/corazawaf/coraza/v3/types"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// A WAF inspects requests using a set of rules.
+type WAF struct {
+	waf coraza.WAF
+}
+
+// New creates a new WAF from the given settings.
+func New(settings *config.WAFSettings) (*WAF, error) {
+	directives, err := getDirectives(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(directives))
+	if err != nil {
+		return nil, fmt.Errorf("waf: error loading rules: %w", err)
+	}
+	return &WAF{waf: w}, nil
+}
+
+func getDirectives(settings *config.WAFSettings) (string, error) {
+	var sb strings.Builder
+	// only the request is inspected, so the rules are always enforced and the verdict decides
+	// whether to block
+	sb.WriteString("SecRuleEngine On\n")
+
+	if settings.CRSDirectory != "" {
+		setup := filepath.Join(settings.CRSDirectory, "crs-setup.conf")
+		if _, err := os.Stat(setup); errors.Is(err, os.ErrNotExist) {
+			setup += ".example"
+		}
+		if _, err := os.Stat(setup); err != nil {
+			return "", fmt.Errorf("waf: invalid core rule set directory: %w", err)
+		}
+		fmt.Fprintf(&sb, "Include %s\n", setup)
+		fmt.Fprintf(&sb, "Include %s\n", filepath.Join(settings.CRSDirectory, "rules", "*.conf"))
+	}
+
+	sb.WriteString(settings.Directives)
+	return sb.String(), nil
+}
+
+// A Request is the part of an HTTP request inspected by the WAF. Request bodies are not
+// available to the authorize service and are not inspected.
+type Request struct {
+	Method   string
+	URL      string
+	Headers  map[string]string
+	ClientIP string
+}
+
+// A Verdict is the result of inspecting a request.
+type Verdict struct {
+	// Interrupted is true if the rules would block the request.
+	Interrupted bool `json:"interrupted"`
+	// Status is the status code the rules would block the request with.
+	Status int `json:"status,omitempty"`
+	// RuleID is the id of the rule which interrupted the request.
+	RuleID int `json:"rule_id,omitempty"`
+	// MatchedRules are the ids of the rules which matched the request.
+	MatchedRules []int `json:"matched_rules,omitempty"`
+}
+
+// Inspect inspects a request.
+func (w *WAF) Inspect(req *Request) (*Verdict, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("waf: invalid request url: %w", err)
+	}
+
+	tx := w.waf.NewTransaction()
+	defer func() {
+		tx.ProcessLogging()
+		_ = tx.Close()
+	}()
+
+	tx.ProcessConnection(req.ClientIP, 0, "", 0)
+	tx.SetServerName(u.Hostname())
+	tx.ProcessURI(u.RequestURI(), req.Method, "HTTP/1.1")
+	for k, v := range req.Headers {
+		if strings.HasPrefix(k, ":") {
+			continue
+		}
+		tx.AddRequestHeader(k, v)
+	}
+	tx.AddRequestHeader("Host", u.Host)
+
+	// most of the core rule set runs in the request body phase, so it's processed even though
+	// there is no body
+	it := tx.ProcessRequestHeaders()
+	if it == nil {
+		it, err = tx.ProcessRequestBody()
+		if err != nil {
+			return nil, fmt.Errorf("waf: error processing request: %w", err)
+		}
+	}
+
+	return newVerdict(it, tx.MatchedRules()), nil
+}
+
+func newVerdict(it *types.Interruption, matchedRules []types.MatchedRule) *Verdict {
+	v := new(Verdict)
+	if it != nil {
+		v.Interrupted = true
+		v.Status = it.Status
+		v.RuleID = it.RuleID
+	}
+	for _, mr := range matchedRules {
+		// rules without a message are used by the core rule set for bookkeeping
+		if mr.Message() == "" {
+			continue
+		}
+		v.MatchedRules = append(v.MatchedRules, mr.Rule().ID())
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package waf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestWAF(t *testing.T) {
+	t.Parallel()
+
+	w, err := New(&config.WAFSettings{
+		Directives: `
+SecRule ARGS:id "@eq 0" "id:1,phase:1,deny,status:403,msg:'invalid id'"
+SecRule REQUEST_HEADERS:User-Agent "@contains scanner" "id:2,phase:2,pass,msg:'scanner'"
+`,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		req     *Request
+		verdict *Verdict
+	}{
+		{"allowed", &Request{Method: "GET", URL: "https://from.example.com/?id=1"}, &Verdict{}},
+		{"interrupted", &Request{Method: "GET", URL: "https://from.example.com/?id=0"}, &Verdict{
+			Interrupted:  true,
+			Status:       403,
+			RuleID:       1,
+			MatchedRules: []int{1},
+		}},
+		{"matched", &Request{Method: "GET", URL: "https://from.example.com/", Headers: map[string]string{"User-Agent": "scanner"}}, &Verdict{
+			MatchedRules: []int{2},
+		}},
+	} {
+		verdict, err := w.Inspect(tc.req)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.verdict, verdict, tc.name)
+	}
+}
+
+func TestGetDirectives(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	_, err := getDirectives(&config.WAFSettings{CRSDirectory: dir})
+	assert.Error(t, err, "should require crs-setup.conf")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "crs-setup.conf.example"), nil, 0o600))
+	directives, err := getDirectives(&config.WAFSettings{CRSDirectory: dir, Directives: "SecRuleRemoveById 920350\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "SecRuleEngine On\n"+
+		"Include "+filepath.Join(dir, "crs-setup.conf.example")+"\n"+
+		"Include "+filepath.Join(dir, "rules", "*.conf")+"\n"+
+		"SecRuleRemoveById 920350\n", directives)
+}
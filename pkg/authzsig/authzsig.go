@@ -0,0 +1,200 @@
+// Package authzsig signs and verifies the responses of pomerium's ext_authz http endpoints, so
+// proxies using pomerium for forward authentication, like nginx or traefik, can verify that an
+// authorization decision came from pomerium and isn't a replay of an earlier decision.
+//
+// A signed response has a timestamp, a random nonce and an HMAC of the request method and url, the
+// response status code, the response headers added by pomerium and, if the proxy sent one in the
+// X-Pomerium-Authz-Request-Nonce header, the proxy's own nonce. Proxies should send a request nonce
+// when they can, since it ties the response to the request. Otherwise a Verifier rejects responses
+// that are too old or that it has seen before.
+//
+// Proxies and http servers add their own headers to responses, like Date or Content-Length, so a
+// Verifier accepts unsigned headers, but it only returns the signed ones, and it rejects responses
+// with unsigned pomerium headers, since those carry the user's identity.
+package authzsig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// Headers used for signed responses.
+const (
+	// HeaderRequestNonce is the request header for the proxy's nonce, which is included in the signature.
+	HeaderRequestNonce = "X-Pomerium-Authz-Request-Nonce"
+	// HeaderTimestamp is the response header with the time the response was signed, in unix seconds.
+	HeaderTimestamp = "X-Pomerium-Authz-Timestamp"
+	// HeaderNonce is the response header with the random nonce of the response.
+	HeaderNonce = "X-Pomerium-Authz-Nonce"
+	// HeaderSignedHeaders is the response header with the comma-separated names of the signed response headers.
+	HeaderSignedHeaders = "X-Pomerium-Authz-Signed-Headers"
+	// HeaderSignature is the response header with the base64 encoded signature of the response.
+	HeaderSignature = "X-Pomerium-Authz-Signature"
+)
+
+// A Response is an ext_authz http response to sign or verify.
+type Response struct {
+	// Method and URL are the method and url of the request being authorized.
+	Method string
+	URL    string
+	// RequestNonce is the value of the request's HeaderRequestNonce header, if any.
+	RequestNonce string
+	StatusCode   int
+	Header       http.Header
+}
+
+var (
+	errMissingSignature = errors.New("authzsig: missing signature")
+	errInvalidSignature = errors.New("authzsig: invalid signature")
+	errReplayed         = errors.New("authzsig: nonce has already been used")
+	errUnsignedHeader   = errors.New("authzsig: unsigned pomerium header")
+)
+
+// pomeriumHeaderPrefix is the prefix of the headers added by pomerium, which must be signed.
+const pomeriumHeaderPrefix = "X-Pomerium-"
+
+// signatureHeaders are the headers added by Sign, which aren't part of the signed headers.
+var signatureHeaders = map[string]struct{}{
+	HeaderTimestamp:     {},
+	HeaderNonce:         {},
+	HeaderSignedHeaders: {},
+	HeaderSignature:     {},
+}
+
+// Sign signs the response by adding the signature headers to its header. Every header of the
+// response is signed.
+func Sign(key []byte, res Response) {
+	var signedHeaders []string
+	for k := range res.Header {
+		signedHeaders = append(signedHeaders, strings.ToLower(k))
+	}
+	sort.Strings(signedHeaders)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := cryptutil.NewBase64Key()
+	mac := cryptutil.GenerateHMAC(signedData(res, timestamp, nonce, signedHeaders), key)
+
+	res.Header.Set(HeaderTimestamp, timestamp)
+	res.Header.Set(HeaderNonce, nonce)
+	res.Header.Set(HeaderSignedHeaders, strings.Join(signedHeaders, ","))
+	res.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(mac))
+}
+
+// A Verifier verifies signed responses. Nonces are remembered until their responses expire, so the
+// same Verifier should be used for every response.
+type Verifier struct {
+	key []byte
+
+	mu     sync.Mutex
+	nonces map[string]struct{}
+	// expiries is the queue of the remembered nonces in the order they expire
+	expiries []nonceExpiry
+}
+
+type nonceExpiry struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// NewVerifier creates a new Verifier for responses signed with the key.
+func NewVerifier(key []byte) *Verifier {
+	return &Verifier{
+		key:    key,
+		nonces: make(map[string]struct{}),
+	}
+}
+
+// Verify verifies the response's signature, and that it is recent and hasn't been seen before. It
+// returns the signed headers of the response, which are the only headers that should be used.
+func (v *Verifier) Verify(res Response) (http.Header, error) {
+	timestamp := res.Header.Get(HeaderTimestamp)
+	nonce := res.Header.Get(HeaderNonce)
+	signature := res.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return nil, errMissingSignature
+	}
+
+	mac, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+	var signedHeaders []string
+	if raw := res.Header.Get(HeaderSignedHeaders); raw != "" {
+		signedHeaders = strings.Split(raw, ",")
+	}
+	if !cryptutil.CheckHMAC(signedData(res, timestamp, nonce, signedHeaders), mac, v.key) {
+		return nil, errInvalidSignature
+	}
+	if err := cryptutil.ValidTimestamp(timestamp); err != nil {
+		return nil, fmt.Errorf("authzsig: %w", err)
+	}
+
+	verified := make(http.Header, len(signedHeaders))
+	for _, k := range signedHeaders {
+		if vs := res.Header.Values(k); len(vs) > 0 {
+			verified[http.CanonicalHeaderKey(k)] = vs
+		}
+	}
+	for k := range res.Header {
+		k = http.CanonicalHeaderKey(k)
+		if _, ok := signatureHeaders[k]; ok {
+			continue
+		}
+		if _, ok := verified[k]; !ok && strings.HasPrefix(k, pomeriumHeaderPrefix) {
+			return nil, fmt.Errorf("%w: %s", errUnsignedHeader, k)
+		}
+	}
+
+	if err := v.useNonce(nonce); err != nil {
+		return nil, err
+	}
+	return verified, nil
+}
+
+func (v *Verifier) useNonce(nonce string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	// nonces expire in the order they were added, so only the front of the queue is checked
+	for len(v.expiries) > 0 && now.After(v.expiries[0].expiresAt) {
+		delete(v.nonces, v.expiries[0].nonce)
+		v.expiries[0] = nonceExpiry{}
+		v.expiries = v.expiries[1:]
+	}
+	if _, ok := v.nonces[nonce]; ok {
+		return errReplayed
+	}
+	v.nonces[nonce] = struct{}{}
+	// a timestamp is valid for the leeway on either side of now
+	v.expiries = append(v.expiries, nonceExpiry{nonce: nonce, expiresAt: now.Add(2 * cryptutil.DefaultLeeway)})
+	return nil
+}
+
+func signedData(res Response, timestamp, nonce string, signedHeaders []string) []byte {
+	var buf bytes.Buffer
+	for _, s := range []string{
+		"v1", timestamp, nonce, res.RequestNonce,
+		res.Method, res.URL, strconv.Itoa(res.StatusCode),
+	} {
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	for _, k := range signedHeaders {
+		buf.WriteString(strings.ToLower(k))
+		buf.WriteByte(':')
+		buf.WriteString(strings.Join(res.Header.Values(k), ","))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
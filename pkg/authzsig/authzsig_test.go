@@ -0,0 +1,115 @@
+package authzsig
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+func TestVerifier(t *testing.T) {
+	t.Parallel()
+
+	key := cryptutil.NewKey()
+	newResponse := func() Response {
+		res := Response{
+			Method:       http.MethodGet,
+			URL:          "https://a.example.com/some/path",
+			RequestNonce: "REQUEST-NONCE",
+			StatusCode:   http.StatusOK,
+			Header:       http.Header{},
+		}
+		res.Header.Set("X-Pomerium-Claim-Email", "user@example.com")
+		return res
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		res := newResponse()
+		Sign(key, res)
+		assert.Equal(t, "x-pomerium-claim-email", res.Header.Get(HeaderSignedHeaders))
+
+		v := NewVerifier(key)
+		verified, err := v.Verify(res)
+		assert.NoError(t, err)
+		assert.Equal(t, http.Header{"X-Pomerium-Claim-Email": {"user@example.com"}}, verified)
+		_, err = v.Verify(res)
+		assert.ErrorIs(t, err, errReplayed)
+		_, err = NewVerifier(key).Verify(res)
+		assert.NoError(t, err, "nonces should be per verifier")
+	})
+	t.Run("unsigned", func(t *testing.T) {
+		_, err := NewVerifier(key).Verify(newResponse())
+		assert.ErrorIs(t, err, errMissingSignature)
+	})
+	t.Run("wrong key", func(t *testing.T) {
+		res := newResponse()
+		Sign(key, res)
+		_, err := NewVerifier(cryptutil.NewKey()).Verify(res)
+		assert.ErrorIs(t, err, errInvalidSignature)
+	})
+	t.Run("unsigned header", func(t *testing.T) {
+		res := newResponse()
+		Sign(key, res)
+		res.Header.Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+		verified, err := NewVerifier(key).Verify(res)
+		assert.NoError(t, err, "headers added by proxies should be accepted")
+		assert.Empty(t, verified.Get("Date"), "unsigned headers should not be returned")
+
+		for _, k := range []string{"X-Pomerium-Claim-Groups", "X-Pomerium-Jwt-Assertion"} {
+			res := newResponse()
+			Sign(key, res)
+			res.Header.Set(k, "injected")
+			_, err := NewVerifier(key).Verify(res)
+			assert.ErrorIs(t, err, errUnsignedHeader, k)
+		}
+	})
+	t.Run("nonce expiry", func(t *testing.T) {
+		v := NewVerifier(key)
+		assert.NoError(t, v.useNonce("A"))
+		v.expiries[0].expiresAt = time.Now().Add(-time.Second)
+		assert.NoError(t, v.useNonce("B"))
+		assert.NoError(t, v.useNonce("A"), "expired nonces should be forgotten")
+		assert.ErrorIs(t, v.useNonce("B"), errReplayed)
+		assert.Len(t, v.nonces, 2)
+	})
+	for name, modify := range map[string]func(res *Response){
+		"method":        func(res *Response) { res.Method = http.MethodPost },
+		"url":           func(res *Response) { res.URL = "https://b.example.com/some/path" },
+		"request nonce": func(res *Response) { res.RequestNonce = "OTHER" },
+		"status code":   func(res *Response) { res.StatusCode = http.StatusForbidden },
+		"header":        func(res *Response) { res.Header.Set("X-Pomerium-Claim-Email", "admin@example.com") },
+		"signed headers": func(res *Response) {
+			res.Header.Del("X-Pomerium-Claim-Email")
+			res.Header.Del(HeaderSignedHeaders)
+		},
+		"timestamp": func(res *Response) {
+			res.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix()+1, 10))
+		},
+	} {
+		modify := modify
+		t.Run("modified "+name, func(t *testing.T) {
+			res := newResponse()
+			Sign(key, res)
+			modify(&res)
+			_, err := NewVerifier(key).Verify(res)
+			assert.ErrorIs(t, err, errInvalidSignature)
+		})
+	}
+	t.Run("expired", func(t *testing.T) {
+		res := newResponse()
+		timestamp := strconv.FormatInt(time.Now().Add(-2*cryptutil.DefaultLeeway).Unix(), 10)
+		nonce := "NONCE"
+		mac := cryptutil.GenerateHMAC(signedData(res, timestamp, nonce, []string{"x-pomerium-claim-email"}), key)
+		res.Header.Set(HeaderTimestamp, timestamp)
+		res.Header.Set(HeaderNonce, nonce)
+		res.Header.Set(HeaderSignedHeaders, "x-pomerium-claim-email")
+		res.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(mac))
+		_, err := NewVerifier(key).Verify(res)
+		assert.Error(t, err)
+	})
+}
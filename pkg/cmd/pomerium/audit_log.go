@@ -0,0 +1,30 @@
+package pomerium
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pomerium/pomerium/internal/audit"
+)
+
+// VerifyAuditLog verifies the hash chain of an audit log file and writes the result to w.
+func VerifyAuditLog(w io.Writer, file string) error {
+	if file == "" {
+		return fmt.Errorf("an audit log file is required")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	count, err := audit.Verify(f)
+	if err != nil {
+		return fmt.Errorf("%s: verified %d entries: %w", file, count, err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s: verified %d entries\n", file, count)
+	return err
+}
@@ -0,0 +1,112 @@
+package pomerium
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// AWSLambdaAuthorizer runs an AWS lambda function, using the lambda custom runtime api, which
+// relays API Gateway lambda authorizer events to the /aws/apigateway endpoint of the ext_authz
+// server at extAuthzURL, such as http://pomerium.internal:9191, so API Gateway routes are
+// authorized with the same routes and policies as pomerium's own. The pomerium binary is deployed
+// as the function's bootstrap, with the provided.al2 runtime:
+//
+//	#!/bin/sh
+//	exec ./pomerium aws-lambda-authorizer --ext-authz-url http://pomerium.internal:9191
+//
+// The ext_authz server trusts its callers, so the function should reach it over a private network.
+func AWSLambdaAuthorizer(ctx context.Context, extAuthzURL string) error {
+	runtimeAPI := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if runtimeAPI == "" {
+		return errors.New("AWS_LAMBDA_RUNTIME_API is not set, the command must be run by the lambda runtime")
+	}
+	u, err := url.Parse(extAuthzURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid ext_authz url: %q", extAuthzURL)
+	}
+	u = u.JoinPath("/aws/apigateway")
+
+	invocationsURL := "http://" + runtimeAPI + "/2018-06-01/runtime/invocation/"
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, invocationsURL+"next", nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("error getting next lambda invocation: %w", err)
+		}
+		event, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("error reading lambda invocation: %w", err)
+		}
+		requestID := res.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+		response, err := relayAWSAuthorizerEvent(ctx, u.String(), event)
+		if err != nil {
+			errResponse, _ := json.Marshal(map[string]string{
+				"errorMessage": err.Error(),
+				"errorType":    "Error",
+			})
+			err = postAWSLambdaResult(ctx, invocationsURL+requestID+"/error", errResponse)
+		} else {
+			err = postAWSLambdaResult(ctx, invocationsURL+requestID+"/response", response)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func relayAWSAuthorizerEvent(ctx context.Context, rawURL string, event []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(event))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(io.LimitReader(res.Body, 1024*1024))
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return bs, nil
+	case http.StatusUnauthorized:
+		// API Gateway responds with a 401 when the authorizer fails with exactly this message
+		return nil, errors.New("Unauthorized") //nolint:stylecheck
+	default:
+		return nil, fmt.Errorf("unexpected response from ext_authz server: %s: %s", res.Status, strings.TrimSpace(string(bs)))
+	}
+}
+
+func postAWSLambdaResult(ctx context.Context, rawURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting lambda result: %w", err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected response posting lambda result: %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pomerium
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/envoy/files"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+// ExportDataBroker writes a snapshot of all the databroker records in the storage backend of the
+// config file to a file, or to stdout if the file is "-". A summary is written to w.
+func ExportDataBroker(ctx context.Context, w io.Writer, configFile, outputFile string) error {
+	if outputFile == "" {
+		return fmt.Errorf("an output file is required")
+	}
+
+	backend, err := newConfiguredBackend(configFile)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	out := io.Writer(os.Stdout)
+	if outputFile != "-" {
+		f, err := os.OpenFile(outputFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	count, err := storage.WriteSnapshot(ctx, out, backend)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "exported %d records\n", count)
+	return err
+}
+
+// ImportDataBroker puts all the databroker records of a snapshot file, or of stdin if the file is
+// "-", into the storage backend of the config file. A summary is written to w.
+func ImportDataBroker(ctx context.Context, w io.Writer, configFile, inputFile string) error {
+	if inputFile == "" {
+		return fmt.Errorf("an input file is required")
+	}
+
+	backend, err := newConfiguredBackend(configFile)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	in := io.Reader(os.Stdin)
+	if inputFile != "-" {
+		f, err := os.Open(inputFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	count, err := storage.ReadSnapshot(ctx, backend, in)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "imported %d records\n", count)
+	return err
+}
+
+func newConfiguredBackend(configFile string) (storage.Backend, error) {
+	if configFile == "" {
+		return nil, fmt.Errorf("a config file is required")
+	}
+
+	src, err := config.NewFileOrEnvironmentSource(configFile, files.FullVersion())
+	if err != nil {
+		return nil, err
+	}
+	options := src.GetConfig().Options
+	if options.DataBrokerStorageType == config.StorageInMemoryName {
+		return nil, fmt.Errorf("the in-memory storage can't be exported or imported")
+	}
+
+	backend, err := databroker.NewBackend(storageServerOptions(options)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating storage: %w", err)
+	}
+	return backend, nil
+}
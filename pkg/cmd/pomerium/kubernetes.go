@@ -0,0 +1,61 @@
+package pomerium
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/pomerium/pomerium/pkg/tcptunnel"
+)
+
+// KubernetesExecCredential prints a client-go ExecCredential for the kubernetes route with the
+// server url, such as https://k8s.example.com, so the command can be used as a kubeconfig exec
+// plugin:
+//
+//	users:
+//	- name: pomerium
+//	  user:
+//	    exec:
+//	      apiVersion: client.authentication.k8s.io/v1
+//	      command: pomerium
+//	      args: [k8s-exec-credential, --server, https://k8s.example.com]
+//	      interactiveMode: IfAvailable
+//
+// If server is empty, the cluster server from the KUBERNETES_EXEC_INFO environment variable is
+// used, which kubectl sets when the exec plugin has provideClusterInfo enabled.
+func KubernetesExecCredential(ctx context.Context, stdout io.Writer, server string) error {
+	var execInfo struct {
+		APIVersion string `json:"apiVersion"`
+		Spec       struct {
+			Cluster struct {
+				Server string `json:"server"`
+			} `json:"cluster"`
+		} `json:"spec"`
+	}
+	if rawExecInfo := os.Getenv("KUBERNETES_EXEC_INFO"); rawExecInfo != "" {
+		if err := json.Unmarshal([]byte(rawExecInfo), &execInfo); err != nil {
+			return fmt.Errorf("invalid KUBERNETES_EXEC_INFO: %w", err)
+		}
+	}
+	if server == "" {
+		server = execInfo.Spec.Cluster.Server
+	}
+	if server == "" {
+		return fmt.Errorf("a server is required")
+	}
+
+	u, err := url.Parse(server)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid server: %q", server)
+	}
+
+	credential, err := tcptunnel.New().KubernetesExecCredential(ctx, u.Host, execInfo.APIVersion)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(credential)
+	return err
+}
@@ -0,0 +1,73 @@
+package pomerium
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/pkg/envoy/files"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+// MigrateStorage copies all the databroker records from the storage backend in the config file to
+// another storage backend. The shared secret, storage TLS and storage encryption settings of the
+// config file are used for both backends.
+func MigrateStorage(ctx context.Context, w io.Writer, configFile, toType, toConnectionString string) error {
+	if configFile == "" {
+		return fmt.Errorf("a config file is required")
+	}
+	if toType == "" {
+		return fmt.Errorf("a destination storage type is required")
+	}
+
+	src, err := config.NewFileOrEnvironmentSource(configFile, files.FullVersion())
+	if err != nil {
+		return err
+	}
+	options := src.GetConfig().Options
+	if options.DataBrokerStorageType == toType && options.DataBrokerStorageConnectionString == toConnectionString {
+		return fmt.Errorf("the destination storage is the same as the configured storage")
+	}
+
+	serverOptions := storageServerOptions(options)
+
+	from, err := databroker.NewBackend(serverOptions...)
+	if err != nil {
+		return fmt.Errorf("error creating source storage: %w", err)
+	}
+	defer from.Close()
+
+	to, err := databroker.NewBackend(append(serverOptions,
+		databroker.WithStorageType(toType),
+		databroker.WithStorageConnectionString(toConnectionString))...)
+	if err != nil {
+		return fmt.Errorf("error creating destination storage: %w", err)
+	}
+	defer to.Close()
+
+	count, err := storage.Migrate(ctx, to, from)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "migrated %d records from %s to %s\n", count, options.DataBrokerStorageType, toType)
+	return err
+}
+
+// storageServerOptions returns the databroker server options used to create the storage backend
+// of the config.
+func storageServerOptions(options *config.Options) []databroker.ServerOption {
+	cert, _ := options.GetDataBrokerCertificate()
+	return []databroker.ServerOption{
+		databroker.WithGetSharedKey(options.GetSharedKey),
+		databroker.WithStorageType(options.DataBrokerStorageType),
+		databroker.WithStorageConnectionString(options.DataBrokerStorageConnectionString),
+		databroker.WithStorageCAFile(options.DataBrokerStorageCAFile),
+		databroker.WithStorageCertificate(cert),
+		databroker.WithStorageCertSkipVerify(options.DataBrokerStorageCertSkipVerify),
+		databroker.WithStorageEncryption(options.DataBrokerStorageEncryption),
+		databroker.WithStorageSharding(options.DataBrokerSharding),
+	}
+}
@@ -0,0 +1,28 @@
+package pomerium
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pomerium/pomerium/internal/migrate"
+)
+
+// MigrateConfig converts the config file of another proxy in the given format (nginx,
+// oauth2-proxy or traefik) to pomerium routes and writes them to w.
+func MigrateConfig(w io.Writer, from, file string) error {
+	if file == "" {
+		return fmt.Errorf("a config file is required")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	res, err := migrate.Convert(from, data)
+	if err != nil {
+		return err
+	}
+	return res.Write(w)
+}
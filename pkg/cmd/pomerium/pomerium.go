@@ -19,10 +19,15 @@ import (
 	"github.com/pomerium/pomerium/internal/autocert"
 	"github.com/pomerium/pomerium/internal/controlplane"
 	"github.com/pomerium/pomerium/internal/databroker"
+	"github.com/pomerium/pomerium/internal/discovery"
 	"github.com/pomerium/pomerium/internal/events"
+	"github.com/pomerium/pomerium/internal/extauthz"
+	"github.com/pomerium/pomerium/internal/forwardproxy"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/registry"
+	"github.com/pomerium/pomerium/internal/upstreammtls"
 	"github.com/pomerium/pomerium/internal/version"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
 	derivecert_config "github.com/pomerium/pomerium/pkg/derivecert/config"
 	"github.com/pomerium/pomerium/pkg/envoy"
 	"github.com/pomerium/pomerium/pkg/envoy/files"
@@ -36,6 +41,12 @@ func Run(ctx context.Context, src config.Source) error {
 		Str("version", version.FullVersion()).
 		Msg("cmd/pomerium")
 
+	// fips mode must be set before any keys or ciphers are created, changing it requires a restart
+	cryptutil.SetFIPSMode(src.GetConfig().Options.FIPSMode)
+
+	// the original source reloads the configuration file, the layered sources pick up the change
+	reloader, _ := src.(config.Reloader)
+
 	src, err := config.NewLayeredSource(ctx, src, derivecert_config.NewBuilder())
 	if err != nil {
 		return err
@@ -47,6 +58,15 @@ func Run(ctx context.Context, src config.Source) error {
 	// trigger changes when underlying files are changed
 	src = config.NewFileWatcherSource(src)
 
+	// resolve srv+ and consul+ route upstreams
+	src = discovery.NewSource(ctx, src)
+
+	// add the authorize and databroker instances registered by other replicas
+	src = registry.NewPeerSource(ctx, src)
+
+	// issue the client certificates of routes with tls_issue_client_cert
+	src = upstreammtls.NewSource(ctx, src)
+
 	src, err = autocert.New(src)
 	if err != nil {
 		return err
@@ -59,6 +79,10 @@ func Run(ctx context.Context, src config.Source) error {
 	defer metricsMgr.Close()
 	traceMgr := config.NewTraceManager(ctx, src)
 	defer traceMgr.Close()
+	themeMgr := config.NewThemeManager(ctx, src)
+	defer themeMgr.Close()
+	auditLogMgr := config.NewAuditLogManager(ctx, src)
+	defer auditLogMgr.Close()
 
 	eventsMgr := events.New()
 
@@ -74,6 +98,8 @@ func Run(ctx context.Context, src config.Source) error {
 			if err := controlPlane.OnConfigChange(ctx, cfg); err != nil {
 				log.Error(ctx).Err(err).Msg("config change")
 			}
+			// drop the cached key pairs of certificates which are no longer used
+			cryptutil.NextCertificateGeneration()
 		})
 
 	if err = controlPlane.OnConfigChange(ctx, src.GetConfig()); err != nil {
@@ -118,9 +144,17 @@ func Run(ctx context.Context, src config.Source) error {
 	if err = setupRegistryReporter(ctx, src); err != nil {
 		return fmt.Errorf("setting up registry reporter: %w", err)
 	}
-	if err := setupProxy(ctx, src, controlPlane); err != nil {
+	if err := setupProxy(ctx, src, controlPlane, reloader); err != nil {
 		return err
 	}
+	var forwardProxyServer *forwardproxy.Server
+	if authorizeServer != nil && src.GetConfig().Options.ForwardProxyAddr != "" {
+		forwardProxyServer = setupForwardProxy(ctx, src, authorizeServer)
+	}
+	var extAuthzServer *extauthz.Server
+	if authorizeServer != nil && src.GetConfig().Options.ExtAuthzAddr != "" {
+		extAuthzServer = setupExtAuthz(ctx, src, authorizeServer)
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	go func(ctx context.Context) {
@@ -144,6 +178,18 @@ func Run(ctx context.Context, src config.Source) error {
 			return authorizeServer.Run(ctx)
 		})
 	}
+	if forwardProxyServer != nil {
+		addr := src.GetConfig().Options.ForwardProxyAddr
+		eg.Go(func() error {
+			return forwardProxyServer.Run(ctx, addr)
+		})
+	}
+	if extAuthzServer != nil {
+		addr := src.GetConfig().Options.ExtAuthzAddr
+		eg.Go(func() error {
+			return extAuthzServer.Run(ctx, addr)
+		})
+	}
 	eg.Go(func() error {
 		return controlPlane.Run(ctx)
 	})
@@ -189,6 +235,22 @@ func setupAuthorize(ctx context.Context, src config.Source, controlPlane *contro
 	return svc, nil
 }
 
+func setupForwardProxy(ctx context.Context, src config.Source, authorizeServer *authorize.Authorize) *forwardproxy.Server {
+	svc := forwardproxy.New(authorizeServer)
+	log.Info(ctx).Msg("enabled forward proxy")
+	src.OnConfigChange(ctx, svc.OnConfigChange)
+	svc.OnConfigChange(ctx, src.GetConfig())
+	return svc
+}
+
+func setupExtAuthz(ctx context.Context, src config.Source, authorizeServer *authorize.Authorize) *extauthz.Server {
+	svc := extauthz.New(authorizeServer)
+	log.Info(ctx).Msg("enabled ext_authz server")
+	src.OnConfigChange(ctx, svc.OnConfigChange)
+	svc.OnConfigChange(ctx, src.GetConfig())
+	return svc
+}
+
 func setupDataBroker(ctx context.Context,
 	src config.Source,
 	controlPlane *controlplane.Server,
@@ -212,12 +274,16 @@ func setupRegistryReporter(ctx context.Context, src config.Source) error {
 	return nil
 }
 
-func setupProxy(ctx context.Context, src config.Source, controlPlane *controlplane.Server) error {
+func setupProxy(ctx context.Context, src config.Source, controlPlane *controlplane.Server, reloader config.Reloader) error {
 	if !config.IsProxy(src.GetConfig().Options.Services) {
 		return nil
 	}
 
-	svc, err := proxy.New(src.GetConfig())
+	var options []proxy.Option
+	if reloader != nil {
+		options = append(options, proxy.WithConfigReloader(reloader))
+	}
+	svc, err := proxy.New(src.GetConfig(), options...)
 	if err != nil {
 		return fmt.Errorf("error creating proxy service: %w", err)
 	}
@@ -0,0 +1,88 @@
+package pomerium
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pomerium/pomerium/pkg/tcptunnel"
+)
+
+// SSHCert issues a short-lived certificate from the ssh route, such as bastion.example.com:22, and
+// writes it next to the identity file as OpenSSH expects, or to certFile if set. Without an
+// identity file, an ephemeral key is issued instead and written to keyFile.
+//
+// If tunnelRoute is set, stdin and stdout are then tunneled to that tcp route, so the command can be
+// used as an OpenSSH ProxyCommand which refreshes the certificate for every connection:
+//
+//	Host db.internal
+//	  ProxyCommand pomerium ssh-cert --route bastion.example.com:22 --key-file ~/.ssh/pomerium --tunnel %h:%p
+//	  IdentityFile ~/.ssh/pomerium
+//	  CertificateFile ~/.ssh/pomerium-cert.pub
+func SSHCert(
+	ctx context.Context,
+	stdin io.Reader,
+	stdout io.Writer,
+	route, identityFile, keyFile, certFile, tunnelRoute string,
+) error {
+	if route == "" {
+		return fmt.Errorf("a route is required")
+	}
+
+	var publicKey []byte
+	switch {
+	case identityFile != "":
+		var err error
+		publicKey, err = os.ReadFile(identityFile + ".pub")
+		if err != nil {
+			return err
+		}
+		if certFile == "" {
+			certFile = identityFile + "-cert.pub"
+		}
+	case keyFile != "":
+		if certFile == "" {
+			certFile = keyFile + "-cert.pub"
+		}
+	default:
+		return fmt.Errorf("an identity file or a key file is required")
+	}
+
+	t := tcptunnel.New()
+	cert, err := t.IssueSSHCertificate(ctx, route, publicKey)
+	if err != nil {
+		return err
+	}
+	if cert.PrivateKey != "" {
+		if err := os.WriteFile(keyFile, []byte(cert.PrivateKey), 0o600); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(certFile, []byte(cert.Certificate+"\n"), 0o644); err != nil {
+		return err
+	}
+
+	if tunnelRoute == "" {
+		return nil
+	}
+	conn, err := t.Dial(ctx, tunnelRoute)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// ssh keeps stdin open until it's done, so the tunnel ends when the remote side closes it
+	go func() { _, _ = io.Copy(conn, stdin) }()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stdout, conn)
+		errc <- err
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errc:
+		return err
+	}
+}
@@ -0,0 +1,127 @@
+package pomerium
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/decisiontail"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/envoy/files"
+	pomeriumgrpc "github.com/pomerium/pomerium/pkg/grpc"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// Tail output formats.
+const (
+	TailFormatText = "text"
+	TailFormatJSON = "json"
+)
+
+// Tail writes the authorize decisions matching the filter to w in the given format as they're made,
+// until the ctx is canceled. The decisions are followed using the databroker of the config file,
+// so the authorize service must have authorize_decision_tail enabled.
+func Tail(ctx context.Context, w io.Writer, configFile, format string, filter decisiontail.Filter) error {
+	if configFile == "" {
+		return fmt.Errorf("a config file is required")
+	}
+
+	var write func(*decisiontail.Decision) error
+	switch format {
+	case TailFormatJSON:
+		enc := json.NewEncoder(w)
+		write = func(d *decisiontail.Decision) error { return enc.Encode(d) }
+	case "", TailFormatText:
+		write = func(d *decisiontail.Decision) error { return writeDecisionText(w, d) }
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	src, err := config.NewFileOrEnvironmentSource(configFile, files.FullVersion())
+	if err != nil {
+		return err
+	}
+
+	cc, err := dialDataBroker(ctx, src.GetConfig().Options)
+	if err != nil {
+		return err
+	}
+	defer cc.Close()
+
+	return decisiontail.Tail(ctx, databroker.NewDataBrokerServiceClient(cc), filter, write)
+}
+
+// dialDataBroker dials the first databroker url of the options.
+func dialDataBroker(ctx context.Context, options *config.Options) (*grpc.ClientConn, error) {
+	sharedKey, err := options.GetSharedKey()
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := options.GetInternalDataBrokerURLs()
+	if err != nil {
+		return nil, err
+	} else if len(urls) == 0 {
+		return nil, fmt.Errorf("no databroker url is configured")
+	}
+	u := urls[0]
+
+	address := u.Host
+	var dialOptions []grpc.DialOption
+	if u.Scheme == "https" {
+		if u.Port() == "" {
+			address = net.JoinHostPort(u.Hostname(), "443")
+		}
+		rootCAs, err := cryptutil.GetCertPool(options.CA, options.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		serverName := u.Hostname()
+		if options.OverrideCertificateName != "" {
+			serverName = options.OverrideCertificateName
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			ServerName: serverName,
+			RootCAs:    rootCAs,
+			MinVersion: tls.VersionTLS12,
+		})))
+	} else if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "80")
+	}
+
+	cc, err := pomeriumgrpc.NewGRPCClientConn(ctx, &pomeriumgrpc.Options{
+		Address:      address,
+		ServiceName:  "tail",
+		SignedJWTKey: sharedKey,
+	}, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing databroker: %w", err)
+	}
+	return cc, nil
+}
+
+func writeDecisionText(w io.Writer, d *decisiontail.Decision) error {
+	result := "allow"
+	if !d.Allow {
+		result = "deny"
+	}
+	user := d.Email
+	if user == "" {
+		user = d.UserID
+	}
+	if user == "" {
+		user = "-"
+	}
+	_, err := fmt.Fprintf(w, "%s %-5s %s %s%s user=%s route=%s reasons=%s\n",
+		d.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		result, d.Method, d.Host, d.Path, user, d.RouteID, strings.Join(d.Reasons, ","))
+	return err
+}
@@ -0,0 +1,60 @@
+package pomerium
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// Validation output formats.
+const (
+	ValidateFormatText = "text"
+	ValidateFormatJSON = "json"
+)
+
+// Validate validates the given config file and writes the report to w in the given format.
+// It returns false if the config file contains any errors.
+func Validate(w io.Writer, configFile, format string) (bool, error) {
+	if configFile == "" {
+		return false, fmt.Errorf("a config file is required")
+	}
+
+	report, err := config.ValidateFile(configFile)
+	if err != nil {
+		return false, err
+	}
+
+	switch format {
+	case ValidateFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(struct {
+			*config.ValidationReport
+			Valid bool `json:"valid"`
+		}{report, report.Valid()})
+	case "", ValidateFormatText:
+		err = writeValidationText(w, report)
+	default:
+		return false, fmt.Errorf("unknown output format: %s", format)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return report.Valid(), nil
+}
+
+func writeValidationText(w io.Writer, report *config.ValidationReport) error {
+	for _, e := range report.Errors {
+		if _, err := fmt.Fprintf(w, "%s: %s: %s\n", report.ConfigFile, e.Severity, e.Error()); err != nil {
+			return err
+		}
+	}
+	if report.Valid() {
+		_, err := fmt.Fprintf(w, "%s: configuration is valid\n", report.ConfigFile)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cryptutil
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"sync"
+)
+
+// certificateCache caches parsed key pairs by the hash of their PEM encoding. Every config reload
+// parses all the certificates of the config again, so the cache lets the new config share the
+// certificates which didn't change with the previous one, instead of holding a second copy of
+// them.
+//
+// The cache is scoped to config generations: a key pair is only kept until the config after the
+// one it was last parsed for is in use, so the private keys of removed or rotated certificates
+// don't stay in memory.
+var certificateCache = newCertificateGenerations()
+
+type certificateGenerations struct {
+	mu                sync.Mutex
+	current, previous map[[sha256.Size]byte]*tls.Certificate
+}
+
+func newCertificateGenerations() *certificateGenerations {
+	return &certificateGenerations{
+		current:  make(map[[sha256.Size]byte]*tls.Certificate),
+		previous: make(map[[sha256.Size]byte]*tls.Certificate),
+	}
+}
+
+func (c *certificateGenerations) get(key [sha256.Size]byte) (*tls.Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cert, ok := c.current[key]; ok {
+		return cert, true
+	}
+	if cert, ok := c.previous[key]; ok {
+		c.current[key] = cert
+		return cert, true
+	}
+	return nil, false
+}
+
+func (c *certificateGenerations) add(key [sha256.Size]byte, cert *tls.Certificate) {
+	c.mu.Lock()
+	c.current[key] = cert
+	c.mu.Unlock()
+}
+
+func (c *certificateGenerations) next() {
+	c.mu.Lock()
+	c.previous, c.current = c.current, make(map[[sha256.Size]byte]*tls.Certificate)
+	c.mu.Unlock()
+}
+
+// NextCertificateGeneration starts a new generation of the certificate cache. It's called once a
+// new config is in use: the key pairs parsed since the previous call are still shared with the
+// next config, and older ones are dropped.
+func NextCertificateGeneration() {
+	certificateCache.next()
+}
+
+// x509KeyPair parses a key pair like tls.X509KeyPair, using the certificate cache. The returned
+// certificate is a copy, but its certificate chain, private key and leaf are shared.
+func x509KeyPair(certPEM, keyPEM []byte) (*tls.Certificate, error) {
+	h := sha256.New()
+	_ = binary.Write(h, binary.BigEndian, uint64(len(certPEM)))
+	_, _ = h.Write(certPEM)
+	_, _ = h.Write(keyPEM)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+
+	if cached, ok := certificateCache.get(key); ok {
+		cert := *cached
+		return &cert, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return &cert, err
+	}
+	cached := cert
+	certificateCache.add(key, &cached)
+	return &cert, nil
+}
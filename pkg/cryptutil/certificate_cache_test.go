@@ -0,0 +1,45 @@
+package cryptutil
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateCache(t *testing.T) {
+	cert1, err := CertificateFromFile("testdata/example-cert.pem", "testdata/example-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert2, err := CertificateFromFile("testdata/example-cert.pem", "testdata/example-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotSame(t, cert1, cert2, "each caller should get its own certificate")
+	assert.Same(t, &cert1.Certificate[0][0], &cert2.Certificate[0][0], "the parsed certificate should be shared")
+	assert.Equal(t, cert1.PrivateKey, cert2.PrivateKey)
+
+	_, err = CertificateFromFile("testdata/example-cert.pem", "testdata/missing-key.pem")
+	assert.Error(t, err)
+}
+
+func TestCertificateCacheGenerations(t *testing.T) {
+	c := newCertificateGenerations()
+	var a, b [32]byte
+	b[0] = 1
+	certA, certB := new(tls.Certificate), new(tls.Certificate)
+	c.add(a, certA)
+	c.add(b, certB)
+
+	c.next()
+	cert, ok := c.get(a)
+	assert.True(t, ok, "key pairs of the previous generation should be kept")
+	assert.Same(t, certA, cert)
+
+	c.next()
+	_, ok = c.get(a)
+	assert.True(t, ok, "key pairs used again should be kept")
+	_, ok = c.get(b)
+	assert.False(t, ok, "key pairs which weren't used again should be dropped")
+}
@@ -28,15 +28,21 @@ func CertificateFromBase64(cert, key string) (*tls.Certificate, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode certificate key %v: %w", decodedKey, err)
 	}
-	x509, err := tls.X509KeyPair(decodedCert, decodedKey)
-	return &x509, err
+	return x509KeyPair(decodedCert, decodedKey)
 }
 
 // CertificateFromFile given a certificate, and key file path, returns a X509
 // keypair.
 func CertificateFromFile(certFile, keyFile string) (*tls.Certificate, error) {
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
-	return &cert, err
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return &tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return &tls.Certificate{}, err
+	}
+	return x509KeyPair(certPEM, keyPEM)
 }
 
 // ParseCRLs parses PEM-encoded certificate revocation lists, returning a map
@@ -16,8 +16,8 @@ const (
 	DataEncryptionKeyCacheSize = 20
 )
 
-// A DataEncryptionKey is an XChaCha20Poly1305 symmetric encryption key. For more details
-// see the documentation on KeyEncryptionKeys.
+// A DataEncryptionKey is an XChaCha20Poly1305 symmetric encryption key, or an AES-256-GCM key in
+// FIPS mode. For more details see the documentation on KeyEncryptionKeys.
 type DataEncryptionKey struct {
 	data   [DataEncryptionKeySize]byte
 	cipher cipher.AEAD
@@ -31,7 +31,7 @@ func NewDataEncryptionKey(raw []byte) (*DataEncryptionKey, error) {
 	}
 	dek := new(DataEncryptionKey)
 	copy(dek.data[:], raw)
-	dek.cipher, _ = chacha20poly1305.NewX(raw) // only errors on invalid size
+	dek.cipher, _ = NewAEADCipher(raw) // only errors on invalid size
 	return dek, nil
 }
 
@@ -46,6 +46,12 @@ func (dek *DataEncryptionKey) Decrypt(ciphertext []byte) ([]byte, error) {
 	return Decrypt(dek.cipher, ciphertext, nil)
 }
 
+// DecryptWithAdditionalData decrypts encrypted data using the data encryption key. The additional data
+// must match the additional data used to encrypt it.
+func (dek *DataEncryptionKey) DecryptWithAdditionalData(ciphertext, ad []byte) ([]byte, error) {
+	return Decrypt(dek.cipher, ciphertext, ad)
+}
+
 // DecryptString decrypts an encrypted string using the data encryption key and base64 encoding.
 func (dek *DataEncryptionKey) DecryptString(ciphertext string) (string, error) {
 	ciphertextBytes, err := base64.StdEncoding.DecodeString(ciphertext)
@@ -64,6 +70,12 @@ func (dek *DataEncryptionKey) Encrypt(plaintext []byte) []byte {
 	return Encrypt(dek.cipher, plaintext, nil)
 }
 
+// EncryptWithAdditionalData encrypts data using the data encryption key. The additional data is
+// authenticated but not encrypted.
+func (dek *DataEncryptionKey) EncryptWithAdditionalData(plaintext, ad []byte) []byte {
+	return Encrypt(dek.cipher, plaintext, ad)
+}
+
 // EncryptString encrypts a string using the data encryption key and base64 encoding.
 func (dek *DataEncryptionKey) EncryptString(plaintext string) string {
 	bs := dek.Encrypt([]byte(plaintext))
@@ -24,6 +24,18 @@ func TestDataEncryptionKey(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, ("HELLO WORLD"), plaintext)
 	})
+	t.Run("roundtrip additional data", func(t *testing.T) {
+		dek, err := GenerateDataEncryptionKey()
+		require.NoError(t, err)
+		ciphertext := dek.EncryptWithAdditionalData([]byte("HELLO WORLD"), []byte("AD"))
+		plaintext, err := dek.DecryptWithAdditionalData(ciphertext, []byte("AD"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("HELLO WORLD"), plaintext)
+		_, err = dek.DecryptWithAdditionalData(ciphertext, []byte("OTHER"))
+		require.Error(t, err)
+		_, err = dek.Decrypt(ciphertext)
+		require.Error(t, err)
+	})
 	t.Run("KeyBytes", func(t *testing.T) {
 		dek, err := GenerateDataEncryptionKey()
 		require.NoError(t, err)
@@ -1,6 +1,7 @@
 package cryptutil
 
 import (
+	"crypto/aes"
 	"crypto/cipher"
 	"encoding/base64"
 	"fmt"
@@ -8,15 +9,23 @@ import (
 	"golang.org/x/crypto/chacha20poly1305"
 )
 
-// NewAEADCipher takes secret key and returns a new XChacha20poly1305 cipher.
+// NewAEADCipher takes secret key and returns a new XChacha20poly1305 cipher, or an AES-256-GCM
+// cipher in FIPS mode.
 func NewAEADCipher(secret []byte) (cipher.AEAD, error) {
 	if len(secret) != 32 {
 		return nil, fmt.Errorf("cryptutil: got %d bytes but want 32", len(secret))
 	}
+	if FIPSMode() {
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, fmt.Errorf("cryptutil: %w", err)
+		}
+		return cipher.NewGCM(block)
+	}
 	return chacha20poly1305.NewX(secret)
 }
 
-// NewAEADCipherFromBase64 takes a base64 encoded secret key and returns a new XChacha20poly1305 cipher.
+// NewAEADCipherFromBase64 takes a base64 encoded secret key and returns a new AEAD cipher.
 func NewAEADCipherFromBase64(s string) (cipher.AEAD, error) {
 	decoded, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
@@ -0,0 +1,68 @@
+package cryptutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrNotFIPSApproved indicates that an algorithm or key isn't approved for use in FIPS mode.
+var ErrNotFIPSApproved = errors.New("cryptutil: not approved in fips mode")
+
+// MinFIPSRSAKeySize is the minimum size, in bits, of RSA keys in FIPS mode.
+const MinFIPSRSAKeySize = 2048
+
+var fipsMode atomic.Bool
+
+// SetFIPSMode enables or disables FIPS mode. In FIPS mode only FIPS-approved algorithms are used:
+// AEAD ciphers and data encryption keys use AES-256-GCM instead of XChaCha20Poly1305, Curve25519
+// key encryption keys can't be used and signing keys are restricted to ECDSA on the NIST curves
+// and RSA of at least 2048 bits.
+//
+// Ciphertexts produced in one mode can't be decrypted in the other, so FIPS mode should be set
+// once on startup, before any keys or ciphers are created.
+func SetFIPSMode(enabled bool) {
+	fipsMode.Store(enabled)
+}
+
+// FIPSMode returns true if FIPS mode is enabled.
+func FIPSMode() bool {
+	return fipsMode.Load()
+}
+
+// CheckFIPSKey returns an error if the signing key isn't approved for use in FIPS mode. It
+// checks the key regardless of whether FIPS mode is enabled.
+func CheckFIPSKey(key any) error {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return checkFIPSCurve(k.Curve)
+	case *ecdsa.PublicKey:
+		return checkFIPSCurve(k.Curve)
+	case *rsa.PrivateKey:
+		return checkFIPSRSAKeySize(k.N.BitLen())
+	case *rsa.PublicKey:
+		return checkFIPSRSAKeySize(k.N.BitLen())
+	default:
+		return fmt.Errorf("%w: key type %T", ErrNotFIPSApproved, key)
+	}
+}
+
+func checkFIPSCurve(curve elliptic.Curve) error {
+	switch curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		return nil
+	default:
+		return fmt.Errorf("%w: curve %s", ErrNotFIPSApproved, curve.Params().Name)
+	}
+}
+
+func checkFIPSRSAKeySize(bits int) error {
+	if bits < MinFIPSRSAKeySize {
+		return fmt.Errorf("%w: %d bit rsa key, must be at least %d bits",
+			ErrNotFIPSApproved, bits, MinFIPSRSAKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cryptutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckFIPSKey(t *testing.T) {
+	t.Parallel()
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	assert.NoError(t, CheckFIPSKey(p256))
+	assert.NoError(t, CheckFIPSKey(&p256.PublicKey))
+	assert.ErrorIs(t, CheckFIPSKey(p224), ErrNotFIPSApproved)
+	assert.NoError(t, CheckFIPSKey(rsa2048))
+	assert.ErrorIs(t, CheckFIPSKey(&rsa1024.PublicKey), ErrNotFIPSApproved)
+	assert.ErrorIs(t, CheckFIPSKey(ed), ErrNotFIPSApproved)
+}
+
+func TestFIPSMode(t *testing.T) {
+	SetFIPSMode(true)
+	t.Cleanup(func() { SetFIPSMode(false) })
+
+	t.Run("aead", func(t *testing.T) {
+		c, err := NewAEADCipher(NewKey())
+		require.NoError(t, err)
+		assert.Equal(t, 12, c.NonceSize(), "should use aes-gcm")
+
+		plaintext := []byte("HELLO WORLD")
+		got, err := Decrypt(c, Encrypt(c, plaintext, nil), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, got)
+	})
+	t.Run("data encryption key", func(t *testing.T) {
+		dek, err := GenerateDataEncryptionKey()
+		require.NoError(t, err)
+
+		plaintext := []byte("HELLO WORLD")
+		got, err := dek.Decrypt(dek.Encrypt(plaintext))
+		assert.NoError(t, err)
+		assert.Equal(t, plaintext, got)
+	})
+	t.Run("key encryption key", func(t *testing.T) {
+		kek, err := GenerateKeyEncryptionKey()
+		require.NoError(t, err)
+
+		_, err = kek.Public().Encrypt([]byte("HELLO WORLD"))
+		assert.ErrorIs(t, err, ErrNotFIPSApproved)
+		_, err = kek.Decrypt([]byte("HELLO WORLD"))
+		assert.ErrorIs(t, err, ErrNotFIPSApproved)
+	})
+	t.Run("signature algorithm", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+
+		_, err = SignatureAlgorithmForKey(key)
+		assert.ErrorIs(t, err, ErrNotFIPSApproved)
+	})
+}
@@ -129,8 +129,15 @@ func loadPublicKey(b []byte) (interface{}, error) {
 	return nil, fmt.Errorf("couldn't load public key: %w", wrappedErr)
 }
 
-// SignatureAlgorithmForKey returns the signature algorithm for the given key.
+// SignatureAlgorithmForKey returns the signature algorithm for the given key. In FIPS mode keys
+// which aren't FIPS-approved are rejected.
 func SignatureAlgorithmForKey(key interface{}) (jose.SignatureAlgorithm, error) {
+	if FIPSMode() {
+		if err := CheckFIPSKey(key); err != nil {
+			return "", err
+		}
+	}
+
 	switch key.(type) {
 	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
 		return jose.ES256, nil
@@ -24,6 +24,8 @@ import (
 //   - Our KEKs are asymmetric Curve25519 keys. We use the *public* key to encrypt the DEK so only the *private* key can
 //     decrypt it.
 //   - Our DEKs are symmetric XChaCha20Poly1305 keys.
+//
+// Curve25519 isn't FIPS-approved, so KEKs can't be used in FIPS mode.
 type KeyEncryptionKey interface {
 	ID() string
 	KeyBytes() []byte
@@ -65,6 +67,10 @@ func GetKeyEncryptionKeyID(raw []byte) string {
 
 // Decrypt decrypts data from a NACL anonymous box.
 func (kek *PrivateKeyEncryptionKey) Decrypt(ciphertext []byte) ([]byte, error) {
+	if FIPSMode() {
+		return nil, fmt.Errorf("%w: curve25519 key encryption key", ErrNotFIPSApproved)
+	}
+
 	private := kek
 	public := kek.Public()
 
@@ -147,6 +153,10 @@ func (kek *PublicKeyEncryptionKey) KeyBytes() []byte {
 
 // Encrypt encrypts data using a NACL anonymous box.
 func (kek *PublicKeyEncryptionKey) Encrypt(plaintext []byte) ([]byte, error) {
+	if FIPSMode() {
+		return nil, fmt.Errorf("%w: curve25519 key encryption key", ErrNotFIPSApproved)
+	}
+
 	sealed, err := box.SealAnonymous(nil, plaintext, &kek.data, rand.Reader)
 	if err != nil { // only fails on rand.Read errors
 		return nil, fmt.Errorf("cryptutil: anonymous box encrypt failed: %w", err)
@@ -36,8 +36,10 @@ const (
 )
 
 type serverOptions struct {
-	services string
-	logLevel config.LogLevel
+	services     string
+	logLevel     config.LogLevel
+	drainTimeout time.Duration
+	hotRestart   bool
 }
 
 // A Server is a pomerium proxy implemented via envoy.
@@ -112,8 +114,10 @@ func (srv *Server) update(ctx context.Context, cfg *config.Config) {
 	defer srv.mu.Unlock()
 
 	options := serverOptions{
-		services: cfg.Options.Services,
-		logLevel: firstNonEmpty(cfg.Options.ProxyLogLevel, cfg.Options.LogLevel, config.LogLevelDebug),
+		services:     cfg.Options.Services,
+		logLevel:     firstNonEmpty(cfg.Options.ProxyLogLevel, cfg.Options.LogLevel, config.LogLevelDebug),
+		drainTimeout: cfg.Options.GetDrainTimeout(),
+		hotRestart:   cfg.Options.HotRestart,
 	}
 
 	if cmp.Equal(srv.options, options, cmp.AllowUnexported(serverOptions{})) {
@@ -143,6 +147,10 @@ func (srv *Server) run(ctx context.Context, cfg *config.Config) error {
 		"--log-level", srv.options.logLevel.ToEnvoy(),
 		"--log-format", "[LOG_FORMAT]%l--%n--%v",
 		"--log-format-escaped",
+		// listeners removed or changed by a config reload are drained the same way as the
+		// listeners of a previous envoy process
+		"--drain-time-s", strconv.Itoa(drainTimeSeconds(srv.options.drainTimeout)),
+		"--drain-strategy", "immediate",
 	}
 
 	exePath, args := srv.prepareRunEnvoyCommand(ctx, args)
@@ -170,6 +178,7 @@ func (srv *Server) run(ctx context.Context, cfg *config.Config) error {
 	}
 	// call Wait to avoid zombie processes
 	go func() { _ = cmd.Wait() }()
+	srv.onEnvoyStarted(ctx, cmd.Process.Pid)
 
 	// monitor the process so we exit if it prematurely exits
 	var monitorProcessCtx context.Context
@@ -181,6 +190,11 @@ func (srv *Server) run(ctx context.Context, cfg *config.Config) error {
 	return nil
 }
 
+// drainTimeSeconds returns the drain timeout in whole seconds, rounded up.
+func drainTimeSeconds(timeout time.Duration) int {
+	return int((timeout + time.Second - 1) / time.Second)
+}
+
 func (srv *Server) writeConfig(ctx context.Context, cfg *config.Config) error {
 	confBytes, err := srv.buildBootstrapConfig(ctx, cfg)
 	if err != nil {
@@ -283,6 +297,11 @@ func (srv *Server) monitorProcess(ctx context.Context, pid int32) {
 			log.Fatal().Err(err).
 				Int32("pid", pid).
 				Msg("envoy: error retrieving subprocess information")
+		} else if !exists && srv.handedOver(int(pid)) {
+			log.Info(ctx).
+				Int32("pid", pid).
+				Msg("envoy: listeners handed over to a new process, exiting")
+			os.Exit(0)
 		} else if !exists {
 			log.Fatal().Err(err).
 				Int32("pid", pid).
@@ -27,3 +27,7 @@ func (srv *Server) prepareRunEnvoyCommand(ctx context.Context, sharedArgs []stri
 
 	return srv.envoyPath, args
 }
+
+func (srv *Server) onEnvoyStarted(_ context.Context, _ int) {}
+
+func (srv *Server) handedOver(_ int) bool { return false }
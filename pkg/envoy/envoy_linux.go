@@ -5,6 +5,7 @@ package envoy
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strconv"
 	"sync"
@@ -19,6 +20,10 @@ import (
 
 const baseIDPath = "/tmp/pomerium-envoy-base-id"
 
+// hotRestartStatePath records the restart epoch and pid of the most recently started envoy
+// process, so that a new pomerium process can take over the listeners of a running one.
+const hotRestartStatePath = "/tmp/pomerium-envoy-hot-restart"
+
 var restartEpoch struct {
 	sync.Mutex
 	value int
@@ -77,13 +82,19 @@ func (srv *Server) prepareRunEnvoyCommand(ctx context.Context, sharedArgs []stri
 	copy(args, sharedArgs)
 
 	restartEpoch.Lock()
+	if srv.cmd == nil && srv.options.hotRestart {
+		// the envoy of another pomerium process is running, take over its listeners
+		if epoch, pid, ok := readHotRestartState(); ok && pidExists(pid) {
+			log.Info(ctx).Int("pid", pid).Msg("envoy: taking over listeners from running envoy process")
+			restartEpoch.value = epoch + 1
+		}
+	}
 	if baseID, ok := readBaseID(); ok {
 		args = append(args,
 			"--base-id", strconv.Itoa(baseID),
 			"--restart-epoch", strconv.Itoa(restartEpoch.value),
-			"--drain-time-s", "60",
-			"--parent-shutdown-time-s", "120",
-			"--drain-strategy", "immediate",
+			// the parent process must outlive the drain
+			"--parent-shutdown-time-s", strconv.Itoa(drainTimeSeconds(srv.options.drainTimeout)+60),
 		)
 		restartEpoch.value++
 	} else {
@@ -98,6 +109,54 @@ func (srv *Server) prepareRunEnvoyCommand(ctx context.Context, sharedArgs []stri
 	return srv.envoyPath, args
 }
 
+// onEnvoyStarted records the started envoy process so another pomerium process can take over its
+// listeners.
+func (srv *Server) onEnvoyStarted(ctx context.Context, pid int) {
+	if !srv.options.hotRestart {
+		return
+	}
+
+	restartEpoch.Lock()
+	epoch := restartEpoch.value - 1
+	restartEpoch.Unlock()
+
+	state := fmt.Sprintf("%d %d", epoch, pid)
+	if err := os.WriteFile(hotRestartStatePath, []byte(state), 0o600); err != nil {
+		log.Warn(ctx).Err(err).Str("service", "envoy").Msg("envoy: failed to write hot restart state")
+	}
+}
+
+// handedOver returns true if the envoy process exited because the envoy of another pomerium
+// process took over its listeners.
+func (srv *Server) handedOver(pid int) bool {
+	srv.mu.Lock()
+	hotRestart := srv.options.hotRestart
+	srv.mu.Unlock()
+	if !hotRestart {
+		return false
+	}
+
+	_, statePID, ok := readHotRestartState()
+	return ok && statePID != pid && pidExists(statePID)
+}
+
+func readHotRestartState() (epoch, pid int, ok bool) {
+	bs, err := os.ReadFile(hotRestartStatePath)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if _, err := fmt.Sscan(string(bs), &epoch, &pid); err != nil {
+		return 0, 0, false
+	}
+
+	return epoch, pid, true
+}
+
+func pidExists(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
 func readBaseID() (int, bool) {
 	bs, err := os.ReadFile(baseIDPath)
 	if err != nil {
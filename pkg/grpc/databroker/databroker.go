@@ -74,6 +74,29 @@ func GetViaJSON[T any](ctx context.Context, client DataBrokerServiceClient, reco
 	return &obj, nil
 }
 
+// PutViaJSON marshals the object to JSON and then puts it into the databroker as a struct with the given
+// record type. It is the counterpart of GetViaJSON.
+func PutViaJSON(ctx context.Context, client DataBrokerServiceClient, recordType, recordID string, obj any) (*PutResponse, error) {
+	bs, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var data structpb.Struct
+	err = protojson.Unmarshal(bs, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Put(ctx, &PutRequest{
+		Records: []*Record{{
+			Type: recordType,
+			Id:   recordID,
+			Data: protoutil.NewAny(&data),
+		}},
+	})
+}
+
 // Put puts a record into the databroker.
 func Put(ctx context.Context, client DataBrokerServiceClient, objects ...recordObject) (*PutResponse, error) {
 	records := make([]*Record, len(objects))
@@ -12,6 +12,7 @@ import (
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 )
 
 // a retryableError is one we'll retry later
@@ -110,12 +111,18 @@ func (locker *Leaser) runOnce(ctx context.Context, resetBackoff func()) error {
 }
 
 func (locker *Leaser) withLease(ctx context.Context, leaseID string) error {
+	metrics.RecordLeaseAcquired(ctx, locker.leaseName)
+
+	// lost is set if the lease couldn't be renewed, so another replica may take it over
+	var lost bool
+
 	// always release the lock in case the parent context is canceled
 	defer func() {
 		_, _ = locker.handler.GetDataBrokerServiceClient().ReleaseLease(context.Background(), &ReleaseLeaseRequest{
 			Name: locker.leaseName,
 			Id:   leaseID,
 		})
+		metrics.RecordLeaseReleased(context.Background(), locker.leaseName, lost)
 	}()
 
 	renewTicker := time.NewTicker(locker.ttl / 2)
@@ -145,9 +152,11 @@ func (locker *Leaser) withLease(ctx context.Context, leaseID string) error {
 					Str("lease_id", leaseID).
 					Msg("leaser: lease lost")
 				// failed to renew lease
+				lost = true
 				return nil
 			} else if err != nil {
 				log.Warn(ctx).Err(err).Str("lease_name", locker.leaseName).Msg("leaser: error renewing lease")
+				lost = true
 				return retryableError{err}
 			}
 		}
@@ -7,6 +7,7 @@ import (
 	grpc_health "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
 
+	"github.com/pomerium/pomerium/internal/health"
 	"github.com/pomerium/pomerium/internal/log"
 )
 
@@ -18,12 +19,23 @@ func NewHealthCheckServer() grpc_health.HealthServer {
 	return &healthCheckSrv{}
 }
 
-// Check confirms service is reachable, and assumes any service is operational
-// an outlier detection should be used to detect runtime malfunction based on consequitive 5xx
+// Check reports the readiness of pomerium for the empty service name, and the status of a
+// dependency for the name of a dependency check, such as databroker. Any other service is assumed
+// to be operational, an outlier detection should be used to detect runtime malfunction based on
+// consequitive 5xx
 func (h *healthCheckSrv) Check(ctx context.Context, req *grpc_health.HealthCheckRequest) (*grpc_health.HealthCheckResponse, error) {
 	log.Debug(ctx).Str("service", req.Service).Msg("health check")
+
+	status := grpc_health.HealthCheckResponse_SERVING
+	if req.Service == "" {
+		if !health.GetReport().Ready() {
+			status = grpc_health.HealthCheckResponse_NOT_SERVING
+		}
+	} else if result, ok := health.Get(req.Service); ok && result.Status == health.StatusError {
+		status = grpc_health.HealthCheckResponse_NOT_SERVING
+	}
 	return &grpc_health.HealthCheckResponse{
-		Status: grpc_health.HealthCheckResponse_SERVING,
+		Status: status,
 	}, nil
 }
 
@@ -0,0 +1,35 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	grpc_health "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/pomerium/pomerium/internal/health"
+)
+
+func TestHealthCheckServer(t *testing.T) {
+	ctx := context.Background()
+	srv := NewHealthCheckServer()
+
+	check := func(service string) grpc_health.HealthCheckResponse_ServingStatus {
+		res, err := srv.Check(ctx, &grpc_health.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return res.GetStatus()
+	}
+
+	health.Set(health.CheckDataBroker, health.OK())
+	t.Cleanup(func() { health.Delete(health.CheckDataBroker) })
+	assert.Equal(t, grpc_health.HealthCheckResponse_SERVING, check(""))
+	assert.Equal(t, grpc_health.HealthCheckResponse_SERVING, check(health.CheckDataBroker))
+
+	health.Set(health.CheckDataBroker, health.Error(errors.New("unreachable")))
+	assert.Equal(t, grpc_health.HealthCheckResponse_NOT_SERVING, check(""))
+	assert.Equal(t, grpc_health.HealthCheckResponse_NOT_SERVING, check(health.CheckDataBroker))
+	assert.Equal(t, grpc_health.HealthCheckResponse_SERVING, check("pomerium-authorize"),
+		"services checked by envoy should not depend on other dependencies")
+}
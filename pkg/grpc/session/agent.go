@@ -0,0 +1,136 @@
+package session
+
+import (
+	context "context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// AgentSessionRecordType is the databroker record type used to store desktop agent sessions.
+const AgentSessionRecordType = "pomerium.io/AgentSession"
+
+// MaxAgentProofAge is how far the time of an agent proof can be from the current time.
+const MaxAgentProofAge = time.Minute
+
+// ErrInvalidAgentProof indicates an agent proof is invalid.
+var ErrInvalidAgentProof = errors.New("invalid agent proof")
+
+// An AgentSession is a long-lived session of a desktop agent, created from a session of the user.
+// It's bound to a device key: the agent refreshes the session by proving possession of the key,
+// so a copied session token can't be refreshed on another device.
+type AgentSession struct {
+	ID                 string    `json:"id"`
+	UserID             string    `json:"userId"`
+	SessionID          string    `json:"sessionId"`
+	IdentityProviderID string    `json:"idpId,omitempty"`
+	DeviceID           string    `json:"deviceId,omitempty"`
+	PublicKey          []byte    `json:"publicKey"`
+	CreatedAt          time.Time `json:"createdAt"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+}
+
+// Validate returns an error if the agent session is invalid.
+func (s *AgentSession) Validate() error {
+	if s.ID == "" || s.UserID == "" || s.SessionID == "" {
+		return errors.New("id, userId and sessionId are required")
+	}
+	_, err := ParseAgentPublicKey(s.PublicKey)
+	return err
+}
+
+// VerifyProof verifies an agent proof of possession of the device key.
+//
+// A proof is the unix time in seconds and the base64url encoded ASN.1 ECDSA signature of the
+// SHA-256 digest of AgentProofMessage, separated by a dot.
+func (s *AgentSession) VerifyProof(proof string, now time.Time) error {
+	publicKey, err := ParseAgentPublicKey(s.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	rawTime, rawSig, ok := strings.Cut(proof, ".")
+	if !ok {
+		return ErrInvalidAgentProof
+	}
+	unix, err := strconv.ParseInt(rawTime, 10, 64)
+	if err != nil {
+		return ErrInvalidAgentProof
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > MaxAgentProofAge || d < -MaxAgentProofAge {
+		return fmt.Errorf("%w: expired", ErrInvalidAgentProof)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(rawSig)
+	if err != nil {
+		return ErrInvalidAgentProof
+	}
+
+	digest := sha256.Sum256([]byte(AgentProofMessage(s.ID, unix)))
+	if !ecdsa.VerifyASN1(publicKey, digest[:], sig) {
+		return ErrInvalidAgentProof
+	}
+	return nil
+}
+
+// AgentProofMessage returns the message signed by an agent to prove possession of its device key.
+func AgentProofMessage(agentSessionID string, unix int64) string {
+	return "pomerium-agent-proof:" + agentSessionID + ":" + strconv.FormatInt(unix, 10)
+}
+
+// ParseAgentPublicKey parses a PKIX encoded ECDSA P-256 public key of an agent.
+func ParseAgentPublicKey(der []byte) (*ecdsa.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent public key: %w", err)
+	}
+	publicKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || publicKey.Curve != elliptic.P256() {
+		return nil, errors.New("invalid agent public key: must be an ECDSA P-256 key")
+	}
+	return publicKey, nil
+}
+
+// GetAgentSession gets an agent session from the databroker.
+func GetAgentSession(ctx context.Context, client databroker.DataBrokerServiceClient, agentSessionID string) (*AgentSession, error) {
+	return databroker.GetViaJSON[AgentSession](ctx, client, AgentSessionRecordType, agentSessionID)
+}
+
+// PutAgentSession stores an agent session in the databroker.
+func PutAgentSession(ctx context.Context, client databroker.DataBrokerServiceClient, s *AgentSession) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	_, err := databroker.PutViaJSON(ctx, client, AgentSessionRecordType, s.ID, s)
+	return err
+}
+
+// DeleteAgentSession deletes an agent session from the databroker.
+func DeleteAgentSession(ctx context.Context, client databroker.DataBrokerServiceClient, agentSessionID string) error {
+	return deleteJSONRecord(ctx, client, AgentSessionRecordType, agentSessionID)
+}
+
+// deleteJSONRecord deletes a record stored with databroker.PutViaJSON.
+func deleteJSONRecord(ctx context.Context, client databroker.DataBrokerServiceClient, recordType, id string) error {
+	_, err := client.Put(ctx, &databroker.PutRequest{
+		Records: []*databroker.Record{{
+			Type:      recordType,
+			Id:        id,
+			Data:      protoutil.NewAny(new(structpb.Struct)),
+			DeletedAt: timestamppb.Now(),
+		}},
+	})
+	return err
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentSession(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	otherPublicKey, err := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	require.NoError(t, err)
+
+	s := &AgentSession{ID: "A1", UserID: "U1", SessionID: "S1", PublicKey: publicKey}
+	assert.NoError(t, s.Validate())
+	assert.Error(t, (&AgentSession{ID: "A1", UserID: "U1", SessionID: "S1", PublicKey: otherPublicKey}).Validate(),
+		"should require a P-256 key")
+	assert.Error(t, (&AgentSession{ID: "A1", PublicKey: publicKey}).Validate())
+
+	prove := func(key *ecdsa.PrivateKey, id string, tm time.Time) string {
+		digest := sha256.Sum256([]byte(AgentProofMessage(id, tm.Unix())))
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		return strconv.FormatInt(tm.Unix(), 10) + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+
+	now := time.Now()
+	assert.NoError(t, s.VerifyProof(prove(key, "A1", now), now))
+	assert.ErrorIs(t, s.VerifyProof(prove(key, "A2", now), now), ErrInvalidAgentProof, "other agent session")
+	assert.ErrorIs(t, s.VerifyProof(prove(key, "A1", now.Add(-2*MaxAgentProofAge)), now), ErrInvalidAgentProof, "expired")
+	otherP256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.VerifyProof(prove(otherP256, "A1", now), now), ErrInvalidAgentProof, "other device")
+	assert.ErrorIs(t, s.VerifyProof("", now), ErrInvalidAgentProof)
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	context "context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// Record types used to store the state of the programmatic login api.
+const (
+	ProgrammaticLoginCodeRecordType    = "pomerium.io/ProgrammaticLoginCode"
+	ProgrammaticRefreshTokenRecordType = "pomerium.io/ProgrammaticRefreshToken"
+)
+
+// A ProgrammaticLoginCode is a single use code returned to a client of the programmatic login api
+// once the user logs in. The client exchanges it for tokens with the code verifier of its PKCE
+// code challenge.
+//
+// Only the hash of the code is stored, as the ID.
+type ProgrammaticLoginCode struct {
+	ID                 string    `json:"id"`
+	SessionID          string    `json:"sessionId"`
+	UserID             string    `json:"userId"`
+	IdentityProviderID string    `json:"idpId,omitempty"`
+	CodeChallenge      string    `json:"codeChallenge"`
+	RouteAudience      []string  `json:"routeAudience,omitempty"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+}
+
+// A ProgrammaticRefreshToken lets a client of the programmatic login api refresh a session and
+// mint new session tokens without the user logging in again. Refresh tokens are rotated on every
+// use.
+//
+// Only the hash of the token is stored, as the ID.
+type ProgrammaticRefreshToken struct {
+	ID                 string    `json:"id"`
+	SessionID          string    `json:"sessionId"`
+	UserID             string    `json:"userId"`
+	IdentityProviderID string    `json:"idpId,omitempty"`
+	RouteAudience      []string  `json:"routeAudience,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+}
+
+// HashProgrammaticToken returns the hash of a programmatic login code or refresh token, which is
+// used as the ID of its record.
+func HashProgrammaticToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// VerifyCodeVerifier returns true if the code verifier matches the S256 code challenge (RFC 7636).
+func VerifyCodeVerifier(codeChallenge, codeVerifier string) bool {
+	// the verifier is 43 to 128 characters long
+	if len(codeVerifier) < 43 || len(codeVerifier) > 128 {
+		return false
+	}
+	h := sha256.Sum256([]byte(codeVerifier))
+	expected := base64.RawURLEncoding.EncodeToString(h[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(codeChallenge)) == 1
+}
+
+// GetProgrammaticLoginCode gets a programmatic login code from the databroker by its hash.
+func GetProgrammaticLoginCode(ctx context.Context, client databroker.DataBrokerServiceClient, id string) (*ProgrammaticLoginCode, error) {
+	return databroker.GetViaJSON[ProgrammaticLoginCode](ctx, client, ProgrammaticLoginCodeRecordType, id)
+}
+
+// PutProgrammaticLoginCode stores a programmatic login code in the databroker.
+func PutProgrammaticLoginCode(ctx context.Context, client databroker.DataBrokerServiceClient, code *ProgrammaticLoginCode) error {
+	_, err := databroker.PutViaJSON(ctx, client, ProgrammaticLoginCodeRecordType, code.ID, code)
+	return err
+}
+
+// DeleteProgrammaticLoginCode deletes a programmatic login code from the databroker.
+func DeleteProgrammaticLoginCode(ctx context.Context, client databroker.DataBrokerServiceClient, id string) error {
+	return deleteJSONRecord(ctx, client, ProgrammaticLoginCodeRecordType, id)
+}
+
+// GetProgrammaticRefreshToken gets a programmatic refresh token from the databroker by its hash.
+func GetProgrammaticRefreshToken(ctx context.Context, client databroker.DataBrokerServiceClient, id string) (*ProgrammaticRefreshToken, error) {
+	return databroker.GetViaJSON[ProgrammaticRefreshToken](ctx, client, ProgrammaticRefreshTokenRecordType, id)
+}
+
+// PutProgrammaticRefreshToken stores a programmatic refresh token in the databroker.
+func PutProgrammaticRefreshToken(ctx context.Context, client databroker.DataBrokerServiceClient, token *ProgrammaticRefreshToken) error {
+	_, err := databroker.PutViaJSON(ctx, client, ProgrammaticRefreshTokenRecordType, token.ID, token)
+	return err
+}
+
+// DeleteProgrammaticRefreshToken deletes a programmatic refresh token from the databroker.
+func DeleteProgrammaticRefreshToken(ctx context.Context, client databroker.DataBrokerServiceClient, id string) error {
+	return deleteJSONRecord(ctx, client, ProgrammaticRefreshTokenRecordType, id)
+}
@@ -0,0 +1,28 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCodeVerifier(t *testing.T) {
+	t.Parallel()
+
+	// from RFC 7636 Appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.True(t, VerifyCodeVerifier(challenge, verifier))
+	assert.False(t, VerifyCodeVerifier(challenge, verifier[:42]), "too short")
+	assert.False(t, VerifyCodeVerifier(challenge, "x"+verifier[1:]))
+	assert.False(t, VerifyCodeVerifier("", verifier))
+}
+
+func TestHashProgrammaticToken(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, HashProgrammaticToken("TOKEN"), HashProgrammaticToken("TOKEN"))
+	assert.NotEqual(t, HashProgrammaticToken("TOKEN"), HashProgrammaticToken("OTHER"))
+	assert.NotContains(t, HashProgrammaticToken("TOKEN"), "TOKEN")
+}
@@ -0,0 +1,89 @@
+package session
+
+import (
+	context "context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// RevocationRecordType is the databroker record type used to store session revocations.
+const RevocationRecordType = "pomerium.io/SessionRevocation"
+
+// A Revocation revokes all of the sessions of a user email, group or identity provider which
+// were issued before the revocation.
+type Revocation struct {
+	Email              string    `json:"email,omitempty"`
+	Group              string    `json:"group,omitempty"`
+	IdentityProviderID string    `json:"idpId,omitempty"`
+	RevokedAt          time.Time `json:"revokedAt"`
+}
+
+// RevocationID returns the databroker record id of a revocation. Revocations of the same email,
+// group or identity provider share an id, so later revocations replace earlier ones.
+func RevocationID(kind, value string) string {
+	if kind == "email" {
+		value = strings.ToLower(value)
+	}
+	return kind + ":" + value
+}
+
+// ID returns the databroker record id of the revocation.
+func (r *Revocation) ID() string {
+	switch {
+	case r.Email != "":
+		return RevocationID("email", r.Email)
+	case r.Group != "":
+		return RevocationID("group", r.Group)
+	default:
+		return RevocationID("idp", r.IdentityProviderID)
+	}
+}
+
+// Validate returns an error if the revocation doesn't match exactly one of an email, group or
+// identity provider.
+func (r *Revocation) Validate() error {
+	cnt := 0
+	for _, v := range []string{r.Email, r.Group, r.IdentityProviderID} {
+		if v != "" {
+			cnt++
+		}
+	}
+	if cnt != 1 {
+		return errors.New("exactly one of email, group or idpId is required")
+	}
+	return nil
+}
+
+// PutRevocation stores a revocation in the databroker.
+func PutRevocation(ctx context.Context, client databroker.DataBrokerServiceClient, r *Revocation) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	_, err := databroker.PutViaJSON(ctx, client, RevocationRecordType, r.ID(), r)
+	return err
+}
+
+// RevocationFromRecord returns the revocation stored in a databroker record.
+func RevocationFromRecord(record *databroker.Record) (*Revocation, error) {
+	msg, err := record.GetData().UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Revocation
+	if err := json.Unmarshal(bs, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
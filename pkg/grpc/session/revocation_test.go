@@ -0,0 +1,44 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+func TestRevocation(t *testing.T) {
+	t.Parallel()
+
+	assert.Error(t, (&Revocation{}).Validate())
+	assert.Error(t, (&Revocation{Email: "user@example.com", Group: "admins"}).Validate())
+	assert.NoError(t, (&Revocation{Group: "admins"}).Validate())
+
+	assert.Equal(t, "email:user@example.com", (&Revocation{Email: "User@Example.com"}).ID())
+	assert.Equal(t, "group:admins", (&Revocation{Group: "admins"}).ID())
+	assert.Equal(t, "idp:IDP", (&Revocation{IdentityProviderID: "IDP"}).ID())
+}
+
+func TestRevocationFromRecord(t *testing.T) {
+	t.Parallel()
+
+	revokedAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := structpb.NewStruct(map[string]any{
+		"group":     "admins",
+		"revokedAt": "2023-01-02T03:04:05Z",
+	})
+	require.NoError(t, err)
+
+	r, err := RevocationFromRecord(&databroker.Record{
+		Type: RevocationRecordType,
+		Id:   "group:admins",
+		Data: protoutil.NewAny(data),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Revocation{Group: "admins", RevokedAt: revokedAt}, r)
+}
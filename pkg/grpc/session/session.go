@@ -49,6 +49,67 @@ func Get(ctx context.Context, client databroker.DataBrokerServiceClient, session
 	return &s, nil
 }
 
+// GetUserSessions gets all of the sessions belonging to a user from the databroker.
+func GetUserSessions(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) ([]*Session, error) {
+	return querySessions(ctx, client, userID, func(s *Session) bool {
+		return s.GetUserId() == userID
+	})
+}
+
+// GetClaimSessions gets all of the sessions with the given claim value from the databroker.
+func GetClaimSessions(ctx context.Context, client databroker.DataBrokerServiceClient, claim, value string) ([]*Session, error) {
+	return querySessions(ctx, client, value, func(s *Session) bool {
+		for _, v := range s.GetClaims()[claim].GetValues() {
+			if v.GetStringValue() == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// querySessions gets all of the sessions matching the query from the databroker. The query
+// matches any field, so the sessions are also compared exactly using the match function.
+func querySessions(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	query string,
+	match func(s *Session) bool,
+) ([]*Session, error) {
+	const pageSize = 100
+
+	typeURL := protoutil.NewAny(new(Session)).GetTypeUrl()
+
+	var sessions []*Session
+	for offset := int64(0); ; offset += pageSize {
+		res, err := client.Query(ctx, &databroker.QueryRequest{
+			Type:   typeURL,
+			Query:  query,
+			Offset: offset,
+			Limit:  pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range res.GetRecords() {
+			var s Session
+			err = record.GetData().UnmarshalTo(&s)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshaling session from databroker: %w", err)
+			}
+			if match(&s) {
+				sessions = append(sessions, &s)
+			}
+		}
+
+		if offset+pageSize >= res.GetTotalCount() {
+			break
+		}
+	}
+	return sessions, nil
+}
+
 // Put sets a session in the databroker.
 func Put(ctx context.Context, client databroker.DataBrokerServiceClient, s *Session) (*databroker.PutResponse, error) {
 	s = proto.Clone(s).(*Session)
@@ -106,3 +167,45 @@ func (x *Session) Validate() error {
 
 	return nil
 }
+
+// IsInGracePeriod reports whether the session is only invalid because its tokens expired less than
+// the grace period ago. Tokens expire without being refreshed when the identity provider is
+// unreachable. Sessions without a refresh token can't be refreshed, so they have no grace period.
+func (x *Session) IsInGracePeriod(gracePeriod time.Duration, now time.Time) bool {
+	if gracePeriod <= 0 || x.GetOauthToken().GetRefreshToken() == "" {
+		return false
+	}
+	if expiresAt := x.GetExpiresAt().AsTime(); expiresAt.Year() > 1970 && now.After(expiresAt) {
+		return false
+	}
+	for _, expiresAt := range []*timestamppb.Timestamp{
+		x.GetOauthToken().GetExpiresAt(),
+		x.GetIdToken().GetExpiresAt(),
+	} {
+		if expiresAt.AsTime().Year() > 1970 && now.After(expiresAt.AsTime().Add(gracePeriod)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateIdleTimeout returns an error if the session hasn't been accessed within the idle
+// timeout. An idle timeout of 0 disables the check.
+func (x *Session) ValidateIdleTimeout(idleTimeout time.Duration) error {
+	if idleTimeout <= 0 {
+		return nil
+	}
+
+	lastAccess := x.GetAccessedAt()
+	if !lastAccess.IsValid() || lastAccess.AsTime().Year() <= 1970 {
+		lastAccess = x.GetIssuedAt()
+	}
+	if !lastAccess.IsValid() || lastAccess.AsTime().Year() <= 1970 {
+		return nil
+	}
+
+	if time.Since(lastAccess.AsTime()) > idleTimeout {
+		return fmt.Errorf("%w: session idle since %s", ErrSessionExpired, lastAccess.AsTime())
+	}
+	return nil
+}
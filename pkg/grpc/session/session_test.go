@@ -1,13 +1,94 @@
 package session
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/identity"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 )
 
+type mockDataBrokerServiceClient struct {
+	databroker.DataBrokerServiceClient
+
+	query func(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error)
+}
+
+func (m mockDataBrokerServiceClient) Query(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+	return m.query(ctx, in, opts...)
+}
+
+func TestGetUserSessions(t *testing.T) {
+	t.Parallel()
+
+	var records []*databroker.Record
+	for i := 0; i < 150; i++ {
+		records = append(records, databroker.NewRecord(&Session{Id: fmt.Sprintf("S%d", i), UserId: "U1"}))
+	}
+	// matches the query, but belongs to another user
+	records = append(records, databroker.NewRecord(&Session{Id: "U1", UserId: "U2"}))
+
+	client := mockDataBrokerServiceClient{
+		query: func(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+			assert.Equal(t, "U1", in.GetQuery())
+			page, totalCount := databroker.ApplyOffsetAndLimit(records, int(in.GetOffset()), int(in.GetLimit()))
+			return &databroker.QueryResponse{
+				Records:    page,
+				TotalCount: int64(totalCount),
+			}, nil
+		},
+	}
+
+	sessions, err := GetUserSessions(context.Background(), client, "U1")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 150)
+	for _, s := range sessions {
+		assert.Equal(t, "U1", s.GetUserId())
+	}
+}
+
+func TestGetClaimSessions(t *testing.T) {
+	t.Parallel()
+
+	withSID := func(id, sid string) *Session {
+		s := &Session{Id: id}
+		s.AddClaims(identity.FlattenedClaims{"sid": {sid}})
+		return s
+	}
+	records := []*databroker.Record{
+		databroker.NewRecord(withSID("S1", "SID1")),
+		databroker.NewRecord(withSID("S2", "SID1")),
+		// matches the query, but has a different sid
+		databroker.NewRecord(withSID("S3", "SID10")),
+		databroker.NewRecord(&Session{Id: "SID1"}),
+	}
+
+	client := mockDataBrokerServiceClient{
+		query: func(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+			assert.Equal(t, "SID1", in.GetQuery())
+			page, totalCount := databroker.ApplyOffsetAndLimit(records, int(in.GetOffset()), int(in.GetLimit()))
+			return &databroker.QueryResponse{
+				Records:    page,
+				TotalCount: int64(totalCount),
+			}, nil
+		},
+	}
+
+	sessions, err := GetClaimSessions(context.Background(), client, "sid", "SID1")
+	require.NoError(t, err)
+	if assert.Len(t, sessions, 2) {
+		assert.Equal(t, "S1", sessions[0].GetId())
+		assert.Equal(t, "S2", sessions[1].GetId())
+	}
+}
+
 func TestSession_Validate(t *testing.T) {
 	t.Parallel()
 
@@ -30,3 +111,57 @@ func TestSession_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSession_IsInGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	t0 := timestamppb.New(now.Add(-time.Hour))
+	t1 := timestamppb.New(now.Add(-time.Second))
+	for _, tc := range []struct {
+		name        string
+		session     *Session
+		gracePeriod time.Duration
+		expect      bool
+	}{
+		{"disabled", &Session{OauthToken: &OAuthToken{RefreshToken: "RT", ExpiresAt: t1}}, 0, false},
+		{"recently expired", &Session{OauthToken: &OAuthToken{RefreshToken: "RT", ExpiresAt: t1}}, time.Minute, true},
+		{"expired id token", &Session{OauthToken: &OAuthToken{RefreshToken: "RT"}, IdToken: &IDToken{ExpiresAt: t1}}, time.Minute, true},
+		{"grace period elapsed", &Session{OauthToken: &OAuthToken{RefreshToken: "RT", ExpiresAt: t0}}, time.Minute, false},
+		{"no refresh token", &Session{OauthToken: &OAuthToken{ExpiresAt: t1}}, time.Minute, false},
+		{"session expired", &Session{ExpiresAt: t1, OauthToken: &OAuthToken{RefreshToken: "RT", ExpiresAt: t1}}, time.Minute, false},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expect, tc.session.IsInGracePeriod(tc.gracePeriod, now))
+		})
+	}
+}
+
+func TestSession_ValidateIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	t0 := timestamppb.New(time.Now().Add(-time.Hour))
+	t1 := timestamppb.New(time.Now().Add(-time.Second))
+	for _, tc := range []struct {
+		name        string
+		session     *Session
+		idleTimeout time.Duration
+		expect      error
+	}{
+		{"disabled", &Session{AccessedAt: t0}, 0, nil},
+		{"never accessed", &Session{}, time.Minute, nil},
+		{"active", &Session{AccessedAt: t1}, time.Minute, nil},
+		{"idle", &Session{AccessedAt: t0}, time.Minute, ErrSessionExpired},
+		{"idle since issued", &Session{IssuedAt: t0}, time.Minute, ErrSessionExpired},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.ErrorIs(t, tc.session.ValidateIdleTimeout(tc.idleTimeout), tc.expect)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package user
+
+import (
+	context "context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// AccessDecisionsRecordType is the databroker record type used to store the recent access decisions of a user.
+const AccessDecisionsRecordType = "pomerium.io/UserAccessDecisions"
+
+// An AccessDecision is the result of an authorization check for a user's request.
+type AccessDecision struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	URL     string    `json:"url"`
+	Allow   bool      `json:"allow"`
+	Reasons []string  `json:"reasons,omitempty"`
+}
+
+// AccessDecisions are the recent access decisions of a user, most recent first.
+type AccessDecisions struct {
+	Decisions []AccessDecision `json:"decisions"`
+}
+
+// GetAccessDecisions gets the recent access decisions of a user from the databroker.
+func GetAccessDecisions(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) ([]AccessDecision, error) {
+	ds, err := databroker.GetViaJSON[AccessDecisions](ctx, client, AccessDecisionsRecordType, userID)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ds.Decisions, nil
+}
+
+// AddAccessDecisions adds access decisions, ordered oldest first, to the recent access decisions of a
+// user in the databroker. At most maxSize access decisions are kept.
+func AddAccessDecisions(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	userID string,
+	maxSize int,
+	decisions ...AccessDecision,
+) error {
+	existing, err := GetAccessDecisions(ctx, client, userID)
+	if err != nil {
+		return err
+	}
+
+	ds := make([]AccessDecision, 0, len(decisions)+len(existing))
+	for i := len(decisions) - 1; i >= 0; i-- {
+		ds = append(ds, decisions[i])
+	}
+	ds = append(ds, existing...)
+	if len(ds) > maxSize {
+		ds = ds[:maxSize]
+	}
+
+	_, err = databroker.PutViaJSON(ctx, client, AccessDecisionsRecordType, userID, AccessDecisions{Decisions: ds})
+	return err
+}
@@ -0,0 +1,65 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+type mockDataBrokerServiceClient struct {
+	databroker.DataBrokerServiceClient
+
+	records map[string]*databroker.Record
+}
+
+func (m mockDataBrokerServiceClient) Get(_ context.Context, in *databroker.GetRequest, _ ...grpc.CallOption) (*databroker.GetResponse, error) {
+	record, ok := m.records[in.GetType()+"/"+in.GetId()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "record not found")
+	}
+	return &databroker.GetResponse{Record: record}, nil
+}
+
+func (m mockDataBrokerServiceClient) Put(_ context.Context, in *databroker.PutRequest, _ ...grpc.CallOption) (*databroker.PutResponse, error) {
+	for _, record := range in.GetRecords() {
+		m.records[record.GetType()+"/"+record.GetId()] = record
+	}
+	return &databroker.PutResponse{Records: in.GetRecords()}, nil
+}
+
+func TestAccessDecisions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := mockDataBrokerServiceClient{records: map[string]*databroker.Record{}}
+
+	ds, err := GetAccessDecisions(ctx, client, "U1")
+	assert.NoError(t, err)
+	assert.Empty(t, ds)
+
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	newDecision := func(i int) AccessDecision {
+		return AccessDecision{
+			Time:   t0.Add(time.Duration(i) * time.Second),
+			Method: "GET",
+			URL:    fmt.Sprintf("https://www.example.com/%d", i),
+			Allow:  i%2 == 0,
+		}
+	}
+
+	require.NoError(t, AddAccessDecisions(ctx, client, "U1", 3, newDecision(0), newDecision(1)))
+	require.NoError(t, AddAccessDecisions(ctx, client, "U1", 3, newDecision(2), newDecision(3)))
+
+	ds, err = GetAccessDecisions(ctx, client, "U1")
+	assert.NoError(t, err)
+	assert.Equal(t, []AccessDecision{newDecision(3), newDecision(2), newDecision(1)}, ds)
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	context "context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// RiskScoreRecordType is the databroker record type used to store the risk score of a user.
+const RiskScoreRecordType = "pomerium.io/UserRiskScore"
+
+// maxRiskScoreReasons is the number of reasons kept for a risk score.
+const maxRiskScoreReasons = 10
+
+// A RiskScore is the risk of a user's account being compromised, from 0 to 100, based on the
+// anomalies detected in the user's requests.
+type RiskScore struct {
+	Score int `json:"score"`
+	// Reasons are the anomalies which raised the score, most recent first.
+	Reasons   []string  `json:"reasons,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GetRiskScore gets the risk score of a user from the databroker. A user without a risk score has
+// a score of 0.
+func GetRiskScore(ctx context.Context, client databroker.DataBrokerServiceClient, userID string) (*RiskScore, error) {
+	rs, err := databroker.GetViaJSON[RiskScore](ctx, client, RiskScoreRecordType, userID)
+	if status.Code(err) == codes.NotFound {
+		return new(RiskScore), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// RaiseRiskScore raises the risk score of a user in the databroker to the given score, if it's
+// higher than the current score. The reason is recorded either way.
+func RaiseRiskScore(ctx context.Context, client databroker.DataBrokerServiceClient, userID string, score int, reason string) error {
+	rs, err := GetRiskScore(ctx, client, userID)
+	if err != nil {
+		return err
+	}
+
+	if score > rs.Score {
+		rs.Score = score
+	}
+	rs.Reasons = append([]string{reason}, rs.Reasons...)
+	if len(rs.Reasons) > maxRiskScoreReasons {
+		rs.Reasons = rs.Reasons[:maxRiskScoreReasons]
+	}
+	rs.UpdatedAt = time.Now()
+
+	_, err = databroker.PutViaJSON(ctx, client, RiskScoreRecordType, userID, rs)
+	return err
+}
@@ -0,0 +1,41 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+func TestRiskScore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := mockDataBrokerServiceClient{records: map[string]*databroker.Record{}}
+
+	rs, err := GetRiskScore(ctx, client, "U1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rs.Score)
+
+	require.NoError(t, RaiseRiskScore(ctx, client, "U1", 50, "new_country"))
+	require.NoError(t, RaiseRiskScore(ctx, client, "U1", 80, "impossible_travel"))
+	require.NoError(t, RaiseRiskScore(ctx, client, "U1", 60, "mass_denials"))
+
+	rs, err = GetRiskScore(ctx, client, "U1")
+	assert.NoError(t, err)
+	assert.Equal(t, 80, rs.Score)
+	assert.Equal(t, []string{"mass_denials", "impossible_travel", "new_country"}, rs.Reasons)
+	assert.False(t, rs.UpdatedAt.IsZero())
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, RaiseRiskScore(ctx, client, "U1", 10, fmt.Sprint(i)))
+	}
+	rs, err = GetRiskScore(ctx, client, "U1")
+	assert.NoError(t, err)
+	assert.Len(t, rs.Reasons, maxRiskScoreReasons)
+	assert.Equal(t, "19", rs.Reasons[0])
+}
@@ -55,6 +55,16 @@ const (
 	ConfigDBErrors = "config_db_errors"
 	// ConfigDBErrorsHelp is the help text for ConfigDBErrors.
 	ConfigDBErrorsHelp = "amount of errors observed while applying databroker config; -1 if validation failed and was rejected altogether"
+
+	// AuthorizeReplicaStalenessSeconds is how long the authorize replica of a record type has been out of sync with the databroker
+	AuthorizeReplicaStalenessSeconds = "authorize_replica_staleness_seconds"
+	// AuthorizeReplicaRecords is the number of records in the authorize replica of a record type
+	AuthorizeReplicaRecords = "authorize_replica_records"
+	// AuthorizePolicyCompileQueue is the number of policies waiting to be compiled by the authorize service
+	AuthorizePolicyCompileQueue = "authorize_policy_compile_queue"
+
+	// CertificateExpiryDays is the number of days until a configured certificate expires
+	CertificateExpiryDays = "certificate_expiry_days"
 )
 
 // labels
@@ -68,4 +78,8 @@ const (
 	RevisionLabel       = "revision"
 	GoVersionLabel      = "goversion"
 	HostLabel           = "host"
+	RecordTypeLabel     = "record_type"
+	SourceLabel         = "source"
+	RouteLabel          = "route"
+	SubjectLabel        = "subject"
 )
@@ -14,6 +14,7 @@ import (
 	"github.com/open-policy-agent/opa/rego"
 	"github.com/open-policy-agent/opa/types"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/pomerium/pomerium/pkg/policy/generator"
 	"github.com/pomerium/pomerium/pkg/policy/parser"
@@ -30,9 +31,18 @@ var testingNow = time.Date(2021, 5, 11, 13, 43, 0, 0, time.Local)
 type (
 	Input struct {
 		HTTP                     InputHTTP    `json:"http"`
+		GRPC                     InputGRPC    `json:"grpc"`
+		Mesh                     InputMesh    `json:"mesh"`
 		Session                  InputSession `json:"session"`
 		IsValidClientCertificate bool         `json:"is_valid_client_certificate"`
 	}
+	InputGRPC struct {
+		Service string `json:"service"`
+		Method  string `json:"method"`
+	}
+	InputMesh struct {
+		SPIFFEID string `json:"spiffe_id"`
+	}
 	InputHTTP struct {
 		Method            string                `json:"method"`
 		Path              string                `json:"path"`
@@ -76,6 +86,26 @@ type dataBrokerRecord interface {
 	GetId() string
 }
 
+// A structRecord is a databroker record stored as a struct with a custom record type, like the
+// directory users and groups.
+type structRecord struct {
+	*structpb.Struct
+	recordType string
+	id         string
+}
+
+func newStructRecord(recordType, id string, data map[string]any) structRecord {
+	s, err := structpb.NewStruct(data)
+	if err != nil {
+		panic(err)
+	}
+	return structRecord{Struct: s, recordType: recordType, id: id}
+}
+
+func (r structRecord) GetId() string { //nolint:revive,stylecheck
+	return r.id
+}
+
 func evaluate(t *testing.T,
 	rawPolicy string,
 	dataBrokerRecords []dataBrokerRecord,
@@ -106,10 +136,15 @@ func evaluate(t *testing.T,
 			}
 
 			for _, record := range dataBrokerRecords {
-				data := protoutil.NewAny(record)
-				if string(recordType) == data.GetTypeUrl() &&
+				typeURL := protoutil.NewAny(record).GetTypeUrl()
+				var obj any = record
+				if sr, ok := record.(structRecord); ok {
+					typeURL = sr.recordType
+					obj = sr.AsMap()
+				}
+				if string(recordType) == typeURL &&
 					string(recordID) == record.GetId() {
-					bs, _ := json.Marshal(record)
+					bs, _ := json.Marshal(obj)
 					v, err := ast.ValueFromReader(bytes.NewReader(bs))
 					if err != nil {
 						return nil, err
@@ -0,0 +1,63 @@
+package criteria
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+	"github.com/pomerium/pomerium/pkg/policy/rules"
+)
+
+var groupsBody = ast.Body{
+	ast.MustParseExpr(`
+		session := get_session(input.session.id)
+	`),
+	ast.MustParseExpr(`
+		directory_user := get_directory_user(session)
+	`),
+	ast.MustParseExpr(`
+		groups := get_groups(directory_user)
+	`),
+}
+
+type groupsCriterion struct {
+	g *Generator
+}
+
+func (groupsCriterion) DataType() CriterionDataType {
+	return CriterionDataTypeStringListMatcher
+}
+
+func (groupsCriterion) Name() string {
+	return "groups"
+}
+
+func (c groupsCriterion) GenerateRule(_ string, data parser.Value) (*ast.Rule, []*ast.Rule, error) {
+	var body ast.Body
+	body = append(body, groupsBody...)
+
+	err := matchStringList(&body, ast.VarTerm("groups"), data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule := NewCriterionSessionRule(c.g, c.Name(),
+		ReasonGroupsOK, ReasonGroupsUnauthorized,
+		body)
+
+	return rule, []*ast.Rule{
+		rules.GetSession(),
+		rules.GetDirectoryUser(),
+		rules.GetGroups(),
+		rules.GetAliasIDs(),
+	}, nil
+}
+
+// Groups returns a Criterion on a user's directory groups. Groups are matched by id, name, email or
+// the stable id and aliases of their alias.
+func Groups(generator *Generator) Criterion {
+	return groupsCriterion{g: generator}
+}
+
+func init() {
+	Register(Groups)
+}
@@ -0,0 +1,101 @@
+package criteria
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+func TestGroups(t *testing.T) {
+	records := []dataBrokerRecord{
+		&session.Session{
+			Id:     "SESSION_ID",
+			UserId: "USER_ID",
+		},
+		newStructRecord("pomerium.io/DirectoryUser", "USER_ID", map[string]any{
+			"id":        "USER_ID",
+			"group_ids": []any{"GROUP1"},
+		}),
+		newStructRecord("pomerium.io/DirectoryGroup", "GROUP1", map[string]any{
+			"id":    "GROUP1",
+			"name":  "Engineering",
+			"email": "engineering@example.com",
+		}),
+		newStructRecord("pomerium.io/GroupAlias", "GROUP1", map[string]any{
+			"id":      "engineering",
+			"idp_ids": []any{"OLD_GROUP1", "GROUP1"},
+			"aliases": []any{"eng"},
+		}),
+	}
+
+	t.Run("no session", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: GROUP1
+`, []dataBrokerRecord{}, Input{Session: InputSession{ID: "SESSION_ID"}})
+		require.NoError(t, err)
+		require.Equal(t, A{false, A{ReasonUserUnauthenticated}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("by id", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: GROUP1
+`, records, Input{Session: InputSession{ID: "SESSION_ID"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonGroupsOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("by name", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: Engineering
+`, records, Input{Session: InputSession{ID: "SESSION_ID"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonGroupsOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("by email", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: engineering@example.com
+`, records, Input{Session: InputSession{ID: "SESSION_ID"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonGroupsOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("by alias", func(t *testing.T) {
+		for _, id := range []string{"engineering", "OLD_GROUP1", "eng"} {
+			res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: `+id+`
+`, records, Input{Session: InputSession{ID: "SESSION_ID"}})
+			require.NoError(t, err)
+			require.Equal(t, A{true, A{ReasonGroupsOK}, M{}}, res["allow"], id)
+			require.Equal(t, A{false, A{}}, res["deny"])
+		}
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - groups:
+        has: GROUP2
+`, records, Input{Session: InputSession{ID: "SESSION_ID"}})
+		require.NoError(t, err)
+		require.Equal(t, A{false, A{ReasonGroupsUnauthorized}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+}
@@ -0,0 +1,43 @@
+package criteria
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+type grpcMethodCriterion struct {
+	g *Generator
+}
+
+func (grpcMethodCriterion) DataType() CriterionDataType {
+	return CriterionDataTypeStringMatcher
+}
+
+func (grpcMethodCriterion) Name() string {
+	return "grpc_method"
+}
+
+func (c grpcMethodCriterion) GenerateRule(_ string, data parser.Value) (*ast.Rule, []*ast.Rule, error) {
+	var body ast.Body
+	ref := ast.RefTerm(ast.VarTerm("input"), ast.VarTerm("grpc"), ast.VarTerm("method"))
+	err := matchString(&body, ref, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule := NewCriterionRule(c.g, c.Name(),
+		ReasonGRPCMethodOK, ReasonGRPCMethodUnauthorized,
+		body)
+
+	return rule, nil, nil
+}
+
+// GRPCMethod returns a Criterion which matches the name of a gRPC method.
+func GRPCMethod(generator *Generator) Criterion {
+	return grpcMethodCriterion{g: generator}
+}
+
+func init() {
+	Register(GRPCMethod)
+}
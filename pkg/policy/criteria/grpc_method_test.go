@@ -0,0 +1,32 @@
+package criteria
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCMethod(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - grpc_method:
+        is: GetUser
+`, []dataBrokerRecord{}, Input{GRPC: InputGRPC{Service: "example.v1.UserService", Method: "GetUser"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonGRPCMethodOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - grpc_method:
+        is: GetUser
+`, []dataBrokerRecord{}, Input{GRPC: InputGRPC{Service: "example.v1.UserService", Method: "DeleteUser"}})
+		require.NoError(t, err)
+		require.Equal(t, A{false, A{ReasonGRPCMethodUnauthorized}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+}
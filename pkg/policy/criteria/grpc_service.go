@@ -0,0 +1,43 @@
+package criteria
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+type grpcServiceCriterion struct {
+	g *Generator
+}
+
+func (grpcServiceCriterion) DataType() CriterionDataType {
+	return CriterionDataTypeStringMatcher
+}
+
+func (grpcServiceCriterion) Name() string {
+	return "grpc_service"
+}
+
+func (c grpcServiceCriterion) GenerateRule(_ string, data parser.Value) (*ast.Rule, []*ast.Rule, error) {
+	var body ast.Body
+	ref := ast.RefTerm(ast.VarTerm("input"), ast.VarTerm("grpc"), ast.VarTerm("service"))
+	err := matchString(&body, ref, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule := NewCriterionRule(c.g, c.Name(),
+		ReasonGRPCServiceOK, ReasonGRPCServiceUnauthorized,
+		body)
+
+	return rule, nil, nil
+}
+
+// GRPCService returns a Criterion which matches the fully-qualified name of a gRPC service.
+func GRPCService(generator *Generator) Criterion {
+	return grpcServiceCriterion{g: generator}
+}
+
+func init() {
+	Register(GRPCService)
+}
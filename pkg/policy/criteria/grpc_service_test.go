@@ -0,0 +1,32 @@
+package criteria
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCService(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - grpc_service:
+        starts_with: example.v1.
+`, []dataBrokerRecord{}, Input{GRPC: InputGRPC{Service: "example.v1.UserService", Method: "GetUser"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonGRPCServiceOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - grpc_service:
+        is: example.v1.UserService
+`, []dataBrokerRecord{}, Input{})
+		require.NoError(t, err)
+		require.Equal(t, A{false, A{ReasonGRPCServiceUnauthorized}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+}
@@ -21,20 +21,31 @@ const (
 	ReasonDomainUnauthorized            = "domain-unauthorized"
 	ReasonEmailOK                       = "email-ok"
 	ReasonEmailUnauthorized             = "email-unauthorized"
+	ReasonGroupsOK                      = "groups-ok"
+	ReasonGroupsUnauthorized            = "groups-unauthorized"
+	ReasonGRPCMethodOK                  = "grpc-method-ok"
+	ReasonGRPCMethodUnauthorized        = "grpc-method-unauthorized"
+	ReasonGRPCServiceOK                 = "grpc-service-ok"
+	ReasonGRPCServiceUnauthorized       = "grpc-service-unauthorized"
 	ReasonHTTPMethodOK                  = "http-method-ok"
 	ReasonHTTPMethodUnauthorized        = "http-method-unauthorized"
 	ReasonHTTPPathOK                    = "http-path-ok"
 	ReasonHTTPPathUnauthorized          = "http-path-unauthorized"
 	ReasonInvalidClientCertificate      = "invalid-client-certificate"
+	ReasonIPDenied                      = "ip-denied"
+	ReasonIPUnauthorized                = "ip-unauthorized"
 	ReasonNonCORSRequest                = "non-cors-request"
 	ReasonNonPomeriumRoute              = "non-pomerium-route"
 	ReasonPomeriumRoute                 = "pomerium-route"
 	ReasonReject                        = "reject"
 	ReasonRouteNotFound                 = "route-not-found"
+	ReasonSPIFFEIDOK                    = "spiffe-id-ok"
+	ReasonSPIFFEIDUnauthorized          = "spiffe-id-unauthorized"
 	ReasonUserOK                        = "user-ok"
 	ReasonUserUnauthenticated           = "user-unauthenticated" // user needs to log in
 	ReasonUserUnauthorized              = "user-unauthorized"    // user does not have access
 	ReasonValidClientCertificate        = "valid-client-certificate"
+	ReasonWAFBlocked                    = "waf-blocked"
 )
 
 // Reasons is a collection of reasons.
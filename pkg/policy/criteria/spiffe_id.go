@@ -0,0 +1,44 @@
+package criteria
+
+import (
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+type spiffeIDCriterion struct {
+	g *Generator
+}
+
+func (spiffeIDCriterion) DataType() CriterionDataType {
+	return CriterionDataTypeStringMatcher
+}
+
+func (spiffeIDCriterion) Name() string {
+	return "spiffe_id"
+}
+
+func (c spiffeIDCriterion) GenerateRule(_ string, data parser.Value) (*ast.Rule, []*ast.Rule, error) {
+	var body ast.Body
+	ref := ast.RefTerm(ast.VarTerm("input"), ast.VarTerm("mesh"), ast.VarTerm("spiffe_id"))
+	err := matchString(&body, ref, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule := NewCriterionRule(c.g, c.Name(),
+		ReasonSPIFFEIDOK, ReasonSPIFFEIDUnauthorized,
+		body)
+
+	return rule, nil, nil
+}
+
+// SPIFFEID returns a Criterion which matches the SPIFFE id of a service mesh workload, when mesh
+// identities are accepted.
+func SPIFFEID(generator *Generator) Criterion {
+	return spiffeIDCriterion{g: generator}
+}
+
+func init() {
+	Register(SPIFFEID)
+}
@@ -0,0 +1,32 @@
+package criteria
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSPIFFEID(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - spiffe_id:
+        starts_with: spiffe://cluster.local/ns/default/
+`, []dataBrokerRecord{}, Input{Mesh: InputMesh{SPIFFEID: "spiffe://cluster.local/ns/default/sa/client"}})
+		require.NoError(t, err)
+		require.Equal(t, A{true, A{ReasonSPIFFEIDOK}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+	t.Run("unauthorized", func(t *testing.T) {
+		res, err := evaluate(t, `
+allow:
+  and:
+    - spiffe_id:
+        is: spiffe://cluster.local/ns/default/sa/client
+`, []dataBrokerRecord{}, Input{})
+		require.NoError(t, err)
+		require.Equal(t, A{false, A{ReasonSPIFFEIDUnauthorized}, M{}}, res["allow"])
+		require.Equal(t, A{false, A{}}, res["deny"])
+	})
+}
@@ -13,7 +13,10 @@ var userBody = ast.Body{
 		session := get_session(input.session.id)
 	`),
 	ast.MustParseExpr(`
-		user_id := session.user_id
+		user_ids := get_user_ids(session)
+	`),
+	ast.MustParseExpr(`
+		user_id := user_ids[_]
 	`),
 }
 
@@ -44,10 +47,13 @@ func (c userCriterion) GenerateRule(_ string, data parser.Value) (*ast.Rule, []*
 
 	return rule, []*ast.Rule{
 		rules.GetSession(),
+		rules.GetUserIDs(),
+		rules.GetAliasIDs(),
 	}, nil
 }
 
-// UserID returns a Criterion on a user's id.
+// UserID returns a Criterion on a user's id. Users are also matched by the stable id and aliases of
+// their alias.
 func UserID(generator *Generator) Criterion {
 	return userCriterion{g: generator}
 }
@@ -39,6 +39,31 @@ allow:
 		require.Equal(t, A{true, A{ReasonUserOK}, M{}}, res["allow"])
 		require.Equal(t, A{false, A{}}, res["deny"])
 	})
+	t.Run("by alias", func(t *testing.T) {
+		for _, id := range []string{"alice", "OLD_USER_ID", "alice@example.com"} {
+			res, err := evaluate(t, `
+allow:
+  and:
+    - user:
+        is: `+id+`
+`,
+				[]dataBrokerRecord{
+					&session.Session{
+						Id:     "SESSION_ID",
+						UserId: "USER_ID",
+					},
+					newStructRecord("pomerium.io/UserAlias", "USER_ID", map[string]any{
+						"id":      "alice",
+						"idp_ids": []any{"OLD_USER_ID", "USER_ID"},
+						"aliases": []any{"alice@example.com"},
+					}),
+				},
+				Input{Session: InputSession{ID: "SESSION_ID"}})
+			require.NoError(t, err)
+			require.Equal(t, A{true, A{ReasonUserOK}, M{}}, res["allow"], id)
+			require.Equal(t, A{false, A{}}, res["deny"])
+		}
+	})
 	t.Run("by impersonate session id", func(t *testing.T) {
 		res, err := evaluate(t, `
 allow:
@@ -38,6 +38,31 @@ get_user(session) = v {
 `)
 }
 
+// GetUserIDs returns the user id of the given session along with the ids and aliases of the user's
+// alias, if there is one.
+func GetUserIDs() *ast.Rule {
+	return ast.MustParseRule(`
+get_user_ids(session) = v {
+	alias := get_databroker_record("pomerium.io/UserAlias", session.user_id)
+	alias != null
+	v = array.concat([session.user_id], get_alias_ids(alias))
+} else = v {
+	v = [session.user_id]
+}
+`)
+}
+
+// GetAliasIDs returns the stable id, identity provider ids and aliases of the given user or group
+// alias.
+func GetAliasIDs() *ast.Rule {
+	return ast.MustParseRule(`
+get_alias_ids(alias) = v {
+	ids := [id | id := object.get(alias, "id", ""); id != ""]
+	v = array.concat(ids, array.concat(object.get(alias, "idp_ids", []), object.get(alias, "aliases", [])))
+}
+`)
+}
+
 // GetUserEmail gets the user email, either the impersonate email, or the user email.
 func GetUserEmail() *ast.Rule {
 	return ast.MustParseRule(`
@@ -74,6 +99,33 @@ get_device_enrollment(device_credential) = v {
 `)
 }
 
+// GetDirectoryUser returns the directory user for the given session.
+func GetDirectoryUser() *ast.Rule {
+	return ast.MustParseRule(`
+get_directory_user(session) = v {
+	v = get_databroker_record("pomerium.io/DirectoryUser", session.user_id)
+	v != null
+} else = {} {
+	true
+}
+`)
+}
+
+// GetGroups returns the group ids, names, emails and aliases of the given directory user.
+func GetGroups() *ast.Rule {
+	return ast.MustParseRule(`
+get_groups(directory_user) = v {
+	group_ids := object.get(directory_user, "group_ids", [])
+	group_names := [name | some i; group := get_databroker_record("pomerium.io/DirectoryGroup", group_ids[i]); name := group.name]
+	group_emails := [email | some i; group := get_databroker_record("pomerium.io/DirectoryGroup", group_ids[i]); email := group.email]
+	group_aliases := [id | some i; alias := get_databroker_record("pomerium.io/GroupAlias", group_ids[i]); alias != null; ids := get_alias_ids(alias); id := ids[_]]
+	v = array.concat(array.concat(group_ids, group_aliases), array.concat(group_names, group_emails))
+} else = [] {
+	true
+}
+`)
+}
+
 // MergeWithAnd merges criterion results using `and`.
 func MergeWithAnd() *ast.Rule {
 	return ast.MustParseRule(`
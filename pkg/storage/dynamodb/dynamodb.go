@@ -0,0 +1,783 @@
+// Package dynamodb implements the storage.Backend interface for DynamoDB.
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/cenkalti/backoff/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/signal"
+	"github.com/pomerium/pomerium/internal/telemetry/trace"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const (
+	maxTransactionRetries = 100
+	// a transaction is limited to 100 items, each record uses two (the record and the change)
+	maxTransactionRecords = 40
+	// dynamodb has no way of notifying other databrokers of changes, so streams poll for them
+	watchPollInterval = time.Second
+	tableWaitTimeout  = 5 * time.Minute
+
+	attrPartitionKey = "pk"
+	attrSortKey      = "sk"
+	attrData         = "data"
+	attrType         = "type"
+	attrValue        = "value"
+	attrVersion      = "version"
+	attrLeaseID      = "lease_id"
+	attrExpiresAt    = "expires_at"
+	attrTTL          = "ttl"
+
+	serverVersionPK   = "server_version"
+	lastVersionPK     = "last_version"
+	recordTypesPK     = "record_types"
+	changesPK         = "changes"
+	optionsPK         = "options"
+	leasesPK          = "leases"
+	recordsPKPrefix   = "records/"
+	singletonSortKey  = "-"
+	changeSortKeyTmpl = "%020d"
+)
+
+// custom errors
+var (
+	ErrExceededMaxRetries = errors.New("dynamodb: transaction reached maximum number of retries")
+)
+
+// Backend implements the storage.Backend on top of a single DynamoDB table.
+//
+// The table has a string partition key (pk) and a string sort key (sk). What's stored:
+//
+//   - server_version: a random integer server version
+//   - last_version: an integer record version number, incremented in the same transaction as changes
+//   - records/{recordType}: the protobuf records of a record type. The sort key is the record id.
+//   - changes: all the changes. The sort key is the zero-padded record version, the data the protobuf record.
+//     Changes expire using the table's time to live attribute.
+//   - record_types: the known record types. The sort key is the record type.
+//   - options: the protobuf options of a record type. The sort key is the record type.
+//   - leases: the current leases. The sort key is the lease name.
+//
+// Records stored in the table are typically encrypted.
+type Backend struct {
+	cfg      *config
+	client   *dynamodb.Client
+	table    string
+	onChange *signal.Signal
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	mu            sync.RWMutex
+	serverVersion uint64
+	recordTypes   map[string]struct{}
+}
+
+// New creates a new DynamoDB storage backend. The connection string has the form
+// dynamodb://{table}?region={region}&endpoint={endpoint}, where the region and endpoint
+// are optional. Credentials are loaded from the default AWS credential chain.
+func New(rawURL string, options ...Option) (*Backend, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb: invalid connection string: %w", err)
+	}
+	if u.Scheme != "dynamodb" || u.Host == "" {
+		return nil, fmt.Errorf("dynamodb: invalid connection string, expected dynamodb://{table}")
+	}
+
+	var loadOptions []func(*awsconfig.LoadOptions) error
+	if region := u.Query().Get("region"); region != "" {
+		loadOptions = append(loadOptions, awsconfig.WithRegion(region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("dynamodb: error loading aws config: %w", err)
+	}
+
+	var clientOptions []func(*dynamodb.Options)
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		clientOptions = append(clientOptions, dynamodb.WithEndpointResolver(dynamodb.EndpointResolverFromURL(endpoint)))
+	}
+
+	return &Backend{
+		cfg:         getConfig(options...),
+		client:      dynamodb.NewFromConfig(awsCfg, clientOptions...),
+		table:       u.Host,
+		onChange:    signal.New(),
+		closed:      make(chan struct{}),
+		recordTypes: make(map[string]struct{}),
+	}, nil
+}
+
+// Close closes any watchers.
+func (backend *Backend) Close() error {
+	backend.closeOnce.Do(func() {
+		close(backend.closed)
+	})
+	return nil
+}
+
+// Get gets a record from dynamodb.
+func (backend *Backend) Get(ctx context.Context, recordType, id string) (*databroker.Record, error) {
+	ctx, span := trace.StartSpan(ctx, "databroker.dynamodb.Get")
+	defer span.End()
+
+	_, err := backend.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := backend.getItem(ctx, recordsPKPrefix+recordType, id)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		return nil, storage.ErrNotFound
+	}
+
+	return unmarshalRecord(item)
+}
+
+// GetOptions gets the options for the given record type.
+func (backend *Backend) GetOptions(ctx context.Context, recordType string) (*databroker.Options, error) {
+	_, err := backend.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := backend.getItem(ctx, optionsPK, recordType)
+	if err != nil {
+		return nil, err
+	} else if item == nil {
+		// treat no options as an empty set of options
+		return new(databroker.Options), nil
+	}
+
+	var options databroker.Options
+	err = proto.Unmarshal(getBinary(item, attrData), &options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
+// Lease acquires or renews a lease.
+func (backend *Backend) Lease(ctx context.Context, leaseName, leaseID string, ttl time.Duration) (bool, error) {
+	_, err := backend.init(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	key := itemKey(leasesPK, leaseName)
+	if ttl <= 0 {
+		_, err = backend.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName:           aws.String(backend.table),
+			Key:                 key,
+			ConditionExpression: aws.String("#lease_id = :lease_id"),
+			ExpressionAttributeNames: map[string]string{
+				"#lease_id": attrLeaseID,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":lease_id": stringValue(leaseID),
+			},
+		})
+		if isConditionalCheckFailed(err) {
+			err = nil
+		}
+		return false, err
+	}
+
+	now := time.Now()
+	item := itemKey(leasesPK, leaseName)
+	item[attrLeaseID] = stringValue(leaseID)
+	item[attrExpiresAt] = numberValue(uint64(now.Add(ttl).UnixMilli()))
+	item[attrTTL] = numberValue(uint64(now.Add(ttl).Unix()))
+	_, err = backend.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(backend.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk) OR #lease_id = :lease_id OR #expires_at < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk":         attrPartitionKey,
+			"#lease_id":   attrLeaseID,
+			"#expires_at": attrExpiresAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":lease_id": stringValue(leaseID),
+			":now":      numberValue(uint64(now.UnixMilli())),
+		},
+	})
+	// if the condition failed someone else must've acquired the lease
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListTypes lists all the known record types.
+func (backend *Backend) ListTypes(ctx context.Context) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "databroker.dynamodb.ListTypes")
+	defer span.End()
+
+	_, err := backend.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recordTypes := []string{}
+	err = backend.query(ctx, recordTypesPK, func(item map[string]types.AttributeValue) error {
+		recordTypes = append(recordTypes, getString(item, attrSortKey))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(recordTypes)
+	return recordTypes, nil
+}
+
+// Put puts records into dynamodb.
+func (backend *Backend) Put(ctx context.Context, records []*databroker.Record) (serverVersion uint64, err error) {
+	ctx, span := trace.StartSpan(ctx, "databroker.dynamodb.Put")
+	defer span.End()
+
+	serverVersion, err = backend.init(ctx)
+	if err != nil {
+		return serverVersion, err
+	}
+
+	err = backend.put(ctx, records)
+	if err != nil {
+		return serverVersion, err
+	}
+
+	recordTypes := map[string]struct{}{}
+	for _, record := range records {
+		recordTypes[record.GetType()] = struct{}{}
+	}
+	for recordType := range recordTypes {
+		err = backend.enforceOptions(ctx, recordType)
+		if err != nil {
+			return serverVersion, err
+		}
+	}
+
+	return serverVersion, nil
+}
+
+// SetOptions sets the options for the given record type.
+func (backend *Backend) SetOptions(ctx context.Context, recordType string, options *databroker.Options) error {
+	ctx, span := trace.StartSpan(ctx, "databroker.dynamodb.SetOptions")
+	defer span.End()
+
+	_, err := backend.init(ctx)
+	if err != nil {
+		return err
+	}
+
+	bs, err := proto.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	item := itemKey(optionsPK, recordType)
+	item[attrData] = &types.AttributeValueMemberB{Value: bs}
+	_, err = backend.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(backend.table),
+		Item:      item,
+	})
+	if err != nil {
+		return err
+	}
+
+	// possibly re-enforce options
+	return backend.enforceOptions(ctx, recordType)
+}
+
+// Sync returns a record stream of any records changed after the specified recordVersion.
+func (backend *Backend) Sync(
+	ctx context.Context,
+	recordType string,
+	serverVersion, recordVersion uint64,
+) (storage.RecordStream, error) {
+	currentServerVersion, err := backend.init(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if currentServerVersion != serverVersion {
+		return nil, storage.ErrInvalidServerVersion
+	}
+
+	return newSyncRecordStream(ctx, backend, recordType, recordVersion), nil
+}
+
+// SyncLatest returns a record stream of all the records. Some records may be returned twice if the are updated while the
+// stream is streaming.
+func (backend *Backend) SyncLatest(
+	ctx context.Context,
+	recordType string,
+	expr storage.FilterExpression,
+) (serverVersion, recordVersion uint64, stream storage.RecordStream, err error) {
+	serverVersion, err = backend.init(ctx)
+	if err != nil {
+		return serverVersion, recordVersion, nil, err
+	}
+
+	recordVersion, err = backend.getLastVersion(ctx)
+	if err != nil {
+		return serverVersion, recordVersion, nil, err
+	}
+
+	stream, err = newSyncLatestRecordStream(ctx, backend, recordType, expr)
+	return serverVersion, recordVersion, stream, err
+}
+
+func (backend *Backend) put(ctx context.Context, records []*databroker.Record) error {
+	for _, record := range records {
+		err := backend.putRecordType(ctx, record.GetType())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, chunk := range chunkRecords(records) {
+		err := backend.incrementVersion(ctx, len(chunk), func(version uint64) ([]types.TransactWriteItem, error) {
+			expiresAt := numberValue(uint64(time.Now().Add(backend.cfg.expiry).Unix()))
+
+			var items []types.TransactWriteItem
+			for i, record := range chunk {
+				record.ModifiedAt = timestamppb.Now()
+				record.Version = version + uint64(i)
+
+				bs, err := proto.Marshal(record)
+				if err != nil {
+					return nil, err
+				}
+
+				if record.DeletedAt != nil {
+					items = append(items, types.TransactWriteItem{Delete: &types.Delete{
+						TableName: aws.String(backend.table),
+						Key:       itemKey(recordsPKPrefix+record.GetType(), record.GetId()),
+					}})
+				} else {
+					item := itemKey(recordsPKPrefix+record.GetType(), record.GetId())
+					item[attrData] = &types.AttributeValueMemberB{Value: bs}
+					item[attrVersion] = numberValue(record.GetVersion())
+					items = append(items, types.TransactWriteItem{Put: &types.Put{
+						TableName: aws.String(backend.table),
+						Item:      item,
+					}})
+				}
+
+				change := itemKey(changesPK, getChangeSortKey(record.GetVersion()))
+				change[attrData] = &types.AttributeValueMemberB{Value: bs}
+				change[attrType] = stringValue(record.GetType())
+				change[attrTTL] = expiresAt
+				items = append(items, types.TransactWriteItem{Put: &types.Put{
+					TableName: aws.String(backend.table),
+					Item:      change,
+				}})
+			}
+			return items, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	backend.onChange.Broadcast(ctx)
+	return nil
+}
+
+func (backend *Backend) putRecordType(ctx context.Context, recordType string) error {
+	backend.mu.RLock()
+	_, ok := backend.recordTypes[recordType]
+	backend.mu.RUnlock()
+	if ok {
+		return nil
+	}
+
+	_, err := backend.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(backend.table),
+		Item:      itemKey(recordTypesPK, recordType),
+	})
+	if err != nil {
+		return err
+	}
+
+	backend.mu.Lock()
+	backend.recordTypes[recordType] = struct{}{}
+	backend.mu.Unlock()
+	return nil
+}
+
+// enforceOptions enforces the options for the given record type.
+func (backend *Backend) enforceOptions(ctx context.Context, recordType string) error {
+	ctx, span := trace.StartSpan(ctx, "databroker.dynamodb.enforceOptions")
+	defer span.End()
+
+	options, err := backend.GetOptions(ctx, recordType)
+	if err != nil {
+		return err
+	}
+
+	// nothing to do if capacity isn't set
+	if options.Capacity == nil {
+		return nil
+	}
+
+	var records []*databroker.Record
+	err = backend.query(ctx, recordsPKPrefix+recordType, func(item map[string]types.AttributeValue) error {
+		record, err := unmarshalRecord(item)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if uint64(len(records)) <= *options.Capacity {
+		return nil
+	}
+
+	// delete the oldest records that exceed the capacity
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].GetVersion() > records[j].GetVersion()
+	})
+	records = records[*options.Capacity:]
+	for _, record := range records {
+		record.DeletedAt = timestamppb.Now()
+	}
+	return backend.put(ctx, records)
+}
+
+// incrementVersion reserves `count` record versions and commits the items returned by `build` in the same
+// transaction. If the last record version changes in the interim, we will retry the transaction.
+func (backend *Backend) incrementVersion(ctx context.Context,
+	count int,
+	build func(recordVersion uint64) ([]types.TransactWriteItem, error),
+) error {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+	for i := 0; i < maxTransactionRetries; i++ {
+		lastVersion, err := backend.getLastVersion(ctx)
+		if err != nil {
+			return err
+		}
+
+		items, err := build(lastVersion + 1)
+		if err != nil {
+			return err
+		}
+
+		update := &types.Update{
+			TableName:        aws.String(backend.table),
+			Key:              itemKey(lastVersionPK, singletonSortKey),
+			UpdateExpression: aws.String("SET #value = :next"),
+			ExpressionAttributeNames: map[string]string{
+				"#value": attrValue,
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":next": numberValue(lastVersion + uint64(count)),
+			},
+		}
+		if lastVersion == 0 {
+			update.ConditionExpression = aws.String("attribute_not_exists(#value)")
+		} else {
+			update.ConditionExpression = aws.String("#value = :last")
+			update.ExpressionAttributeValues[":last"] = numberValue(lastVersion)
+		}
+		items = append(items, types.TransactWriteItem{Update: update})
+
+		_, err = backend.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(bo.NextBackOff()):
+			}
+			continue // retry
+		} else if err != nil {
+			return err
+		}
+
+		return nil // tx was successful
+	}
+
+	return ErrExceededMaxRetries
+}
+
+func (backend *Backend) getLastVersion(ctx context.Context) (uint64, error) {
+	item, err := backend.getItem(ctx, lastVersionPK, singletonSortKey)
+	if err != nil {
+		return 0, err
+	} else if item == nil {
+		// this happens if there are no records
+		return 0, nil
+	}
+	return getNumber(item, attrValue)
+}
+
+// init creates the table if it doesn't exist and retrieves the server version.
+func (backend *Backend) init(ctx context.Context) (serverVersion uint64, err error) {
+	backend.mu.RLock()
+	serverVersion = backend.serverVersion
+	backend.mu.RUnlock()
+
+	if serverVersion != 0 {
+		return serverVersion, nil
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	// double-checked locking, might have already initialized, so just return
+	if backend.serverVersion != 0 {
+		return backend.serverVersion, nil
+	}
+
+	err = backend.createTableIfNotExists(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("dynamodb: error creating table: %w", err)
+	}
+
+	// if the server version hasn't been set yet, set it to a random value and immediately retrieve it
+	// this should properly handle a data race by only setting the item if it doesn't already exist
+	item := itemKey(serverVersionPK, singletonSortKey)
+	item[attrValue] = numberValue(cryptutil.NewRandomUInt64())
+	_, err = backend.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(backend.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPartitionKey,
+		},
+	})
+	if err != nil && !isConditionalCheckFailed(err) {
+		return 0, fmt.Errorf("dynamodb: error setting server version: %w", err)
+	}
+
+	item, err = backend.getItem(ctx, serverVersionPK, singletonSortKey)
+	if err == nil && item == nil {
+		err = storage.ErrNotFound
+	}
+	if err == nil {
+		serverVersion, err = getNumber(item, attrValue)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dynamodb: error retrieving server version: %w", err)
+	}
+
+	backend.serverVersion = serverVersion
+	return serverVersion, nil
+}
+
+func (backend *Backend) createTableIfNotExists(ctx context.Context) error {
+	_, err := backend.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(backend.table),
+	})
+	var notFound *types.ResourceNotFoundException
+	if err == nil {
+		return nil
+	} else if !errors.As(err, &notFound) {
+		return err
+	}
+
+	_, err = backend.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(backend.table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String(attrPartitionKey), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(attrSortKey), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(attrPartitionKey), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String(attrSortKey), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	var inUse *types.ResourceInUseException
+	if errors.As(err, &inUse) {
+		// another databroker is creating the table
+	} else if err != nil {
+		return err
+	}
+
+	err = dynamodb.NewTableExistsWaiter(backend.client).Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(backend.table),
+	}, tableWaitTimeout)
+	if err != nil {
+		return err
+	}
+
+	_, err = backend.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(backend.table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attrTTL),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	return err
+}
+
+func (backend *Backend) getItem(ctx context.Context, pk, sk string) (map[string]types.AttributeValue, error) {
+	res, err := backend.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(backend.table),
+		Key:            itemKey(pk, sk),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	} else if len(res.Item) == 0 {
+		return nil, nil
+	}
+	return res.Item, nil
+}
+
+// query calls fn for every item in a partition, in sort key order.
+func (backend *Backend) query(ctx context.Context, pk string, fn func(item map[string]types.AttributeValue) error) error {
+	var startKey map[string]types.AttributeValue
+	for {
+		res, err := backend.queryPage(ctx, pk, "", startKey, 0)
+		if err != nil {
+			return err
+		}
+		for _, item := range res.Items {
+			err = fn(item)
+			if err != nil {
+				return err
+			}
+		}
+		if len(res.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = res.LastEvaluatedKey
+	}
+}
+
+// queryPage queries a single page of items in a partition, optionally only those with a sort key after `after`.
+func (backend *Backend) queryPage(
+	ctx context.Context,
+	pk, after string,
+	startKey map[string]types.AttributeValue,
+	limit int32,
+) (*dynamodb.QueryOutput, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(backend.table),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": attrPartitionKey,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": stringValue(pk),
+		},
+		ConsistentRead:    aws.Bool(true),
+		ExclusiveStartKey: startKey,
+	}
+	if after != "" {
+		input.KeyConditionExpression = aws.String("#pk = :pk AND #sk > :sk")
+		input.ExpressionAttributeNames["#sk"] = attrSortKey
+		input.ExpressionAttributeValues[":sk"] = stringValue(after)
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(limit)
+	}
+	return backend.client.Query(ctx, input)
+}
+
+// chunkRecords splits records into chunks which fit in a single transaction. A transaction can't
+// contain the same item twice, so a record that's already in the current chunk starts a new one.
+func chunkRecords(records []*databroker.Record) [][]*databroker.Record {
+	var chunks [][]*databroker.Record
+	var chunk []*databroker.Record
+	seen := map[[2]string]struct{}{}
+	for _, record := range records {
+		key := [2]string{record.GetType(), record.GetId()}
+		if _, ok := seen[key]; ok || len(chunk) >= maxTransactionRecords {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			seen = map[[2]string]struct{}{}
+		}
+		chunk = append(chunk, record)
+		seen[key] = struct{}{}
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func unmarshalRecord(item map[string]types.AttributeValue) (*databroker.Record, error) {
+	var record databroker.Record
+	err := proto.Unmarshal(getBinary(item, attrData), &record)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	return errors.As(err, &conditionalCheckFailed)
+}
+
+func getChangeSortKey(recordVersion uint64) string {
+	return fmt.Sprintf(changeSortKeyTmpl, recordVersion)
+}
+
+func itemKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attrPartitionKey: stringValue(pk),
+		attrSortKey:      stringValue(sk),
+	}
+}
+
+func stringValue(s string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: s}
+}
+
+func numberValue(n uint64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatUint(n, 10)}
+}
+
+func getString(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func getBinary(item map[string]types.AttributeValue, name string) []byte {
+	if v, ok := item[name].(*types.AttributeValueMemberB); ok {
+		return v.Value
+	}
+	return nil
+}
+
+func getNumber(item map[string]types.AttributeValue, name string) (uint64, error) {
+	v, ok := item[name].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("dynamodb: missing %s attribute", name)
+	}
+	return strconv.ParseUint(v.Value, 10, 64)
+}
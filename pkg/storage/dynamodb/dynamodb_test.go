@@ -0,0 +1,219 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/testutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const maxWait = time.Minute * 10
+
+func TestBackend(t *testing.T) {
+	if os.Getenv("GITHUB_ACTION") != "" && runtime.GOOS == "darwin" {
+		t.Skip("Github action can not run docker on MacOS")
+	}
+
+	ctx, clearTimeout := context.WithTimeout(context.Background(), maxWait)
+	defer clearTimeout()
+
+	require.NoError(t, testutil.WithTestDynamoDB(t, func(rawURL string) error {
+		backend, err := New(rawURL)
+		require.NoError(t, err)
+		defer backend.Close()
+
+		t.Run("get missing record", func(t *testing.T) {
+			record, err := backend.Get(ctx, "TYPE", "abcd")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+			assert.Nil(t, record)
+		})
+
+		t.Run("put and get", func(t *testing.T) {
+			serverVersion, err := backend.Put(ctx, []*databroker.Record{
+				{Type: "test-1", Id: "r1", Data: protoutil.NewAny(protoutil.NewStructMap(map[string]*structpb.Value{
+					"k1": protoutil.NewStructString("v1"),
+				}))},
+			})
+			assert.NotEqual(t, 0, serverVersion)
+			require.NoError(t, err)
+
+			record, err := backend.Get(ctx, "test-1", "r1")
+			require.NoError(t, err)
+			assert.NotZero(t, record.GetVersion())
+			assert.NotNil(t, record.GetModifiedAt())
+		})
+
+		t.Run("delete", func(t *testing.T) {
+			_, err := backend.Put(ctx, []*databroker.Record{{
+				Type:      "test-1",
+				Id:        "r1",
+				DeletedAt: timestamppb.Now(),
+			}})
+			require.NoError(t, err)
+
+			_, err = backend.Get(ctx, "test-1", "r1")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		})
+
+		t.Run("duplicate ids", func(t *testing.T) {
+			_, err := backend.Put(ctx, []*databroker.Record{
+				{Type: "test-1", Id: "r2", Data: protoutil.NewAny(protoutil.NewStructString("v1"))},
+				{Type: "test-1", Id: "r2", Data: protoutil.NewAny(protoutil.NewStructString("v2"))},
+			})
+			require.NoError(t, err)
+
+			record, err := backend.Get(ctx, "test-1", "r2")
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(protoutil.NewAny(protoutil.NewStructString("v2")), record.GetData()))
+		})
+
+		t.Run("capacity", func(t *testing.T) {
+			err := backend.SetOptions(ctx, "capacity-test", &databroker.Options{
+				Capacity: proto.Uint64(3),
+			})
+			require.NoError(t, err)
+
+			for i := 0; i < 10; i++ {
+				_, err = backend.Put(ctx, []*databroker.Record{{
+					Type: "capacity-test",
+					Id:   fmt.Sprint(i),
+					Data: protoutil.NewAny(protoutil.NewStructMap(map[string]*structpb.Value{})),
+				}})
+				require.NoError(t, err)
+			}
+
+			_, _, stream, err := backend.SyncLatest(ctx, "capacity-test", nil)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			records, err := storage.RecordStreamToList(stream)
+			require.NoError(t, err)
+
+			var ids []string
+			for _, r := range records {
+				ids = append(ids, r.GetId())
+			}
+			assert.Equal(t, []string{"7", "8", "9"}, ids, "should contain recent records")
+		})
+
+		t.Run("lease", func(t *testing.T) {
+			acquired, err := backend.Lease(ctx, "lease-test", "client-1", time.Second)
+			assert.NoError(t, err)
+			assert.True(t, acquired)
+
+			acquired, err = backend.Lease(ctx, "lease-test", "client-2", time.Second)
+			assert.NoError(t, err)
+			assert.False(t, acquired)
+
+			acquired, err = backend.Lease(ctx, "lease-test", "client-1", 0)
+			assert.NoError(t, err)
+			assert.False(t, acquired)
+
+			acquired, err = backend.Lease(ctx, "lease-test", "client-2", time.Second)
+			assert.NoError(t, err)
+			assert.True(t, acquired)
+		})
+
+		t.Run("latest", func(t *testing.T) {
+			var records []*databroker.Record
+			for i := 0; i < 100; i++ {
+				records = append(records, &databroker.Record{
+					Type: "latest-test",
+					Id:   fmt.Sprint(i),
+					Data: protoutil.NewAny(protoutil.NewStructMap(map[string]*structpb.Value{})),
+				})
+			}
+			_, err := backend.Put(ctx, records)
+			require.NoError(t, err)
+
+			_, _, stream, err := backend.SyncLatest(ctx, "latest-test", nil)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			count := map[string]int{}
+			for stream.Next(true) {
+				count[stream.Record().GetId()]++
+			}
+			assert.NoError(t, stream.Err())
+
+			for i := 0; i < 100; i++ {
+				assert.Equal(t, 1, count[fmt.Sprint(i)])
+			}
+		})
+
+		t.Run("changed", func(t *testing.T) {
+			serverVersion, recordVersion, stream, err := backend.SyncLatest(ctx, "sync-test", nil)
+			require.NoError(t, err)
+			assert.NoError(t, stream.Close())
+
+			_, err = backend.Sync(ctx, "", serverVersion+1, recordVersion)
+			assert.ErrorIs(t, err, storage.ErrInvalidServerVersion)
+
+			stream, err = backend.Sync(ctx, "", serverVersion, recordVersion)
+			require.NoError(t, err)
+			defer stream.Close()
+
+			go func() {
+				for i := 0; i < 10; i++ {
+					_, err := backend.Put(ctx, []*databroker.Record{{
+						Type: "sync-test",
+						Id:   fmt.Sprint(i),
+						Data: protoutil.NewAny(protoutil.NewStructMap(map[string]*structpb.Value{})),
+					}})
+					assert.NoError(t, err)
+					time.Sleep(50 * time.Millisecond)
+				}
+			}()
+
+			for i := 0; i < 10; i++ {
+				if assert.True(t, stream.Next(true)) {
+					assert.Equal(t, fmt.Sprint(i), stream.Record().GetId())
+					assert.Equal(t, "sync-test", stream.Record().GetType())
+				} else {
+					break
+				}
+			}
+			assert.False(t, stream.Next(false))
+			assert.NoError(t, stream.Err())
+		})
+
+		t.Run("list types", func(t *testing.T) {
+			types, err := backend.ListTypes(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"capacity-test", "latest-test", "sync-test", "test-1"}, types)
+		})
+
+		return nil
+	}))
+}
+
+func TestChunkRecords(t *testing.T) {
+	t.Parallel()
+
+	var records []*databroker.Record
+	for i := 0; i < 100; i++ {
+		records = append(records, &databroker.Record{Type: "TYPE", Id: fmt.Sprint(i)})
+	}
+	records = append(records, &databroker.Record{Type: "TYPE", Id: "99"})
+
+	chunks := chunkRecords(records)
+	var sizes []int
+	for _, chunk := range chunks {
+		sizes = append(sizes, len(chunk))
+	}
+	assert.Equal(t, []int{40, 40, 20, 1}, sizes,
+		"should limit the chunk size and split duplicate records")
+}
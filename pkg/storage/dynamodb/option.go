@@ -0,0 +1,28 @@
+package dynamodb
+
+import (
+	"time"
+)
+
+type config struct {
+	expiry time.Duration
+}
+
+// Option customizes a Backend.
+type Option func(*config)
+
+// WithExpiry sets the expiry for changes.
+func WithExpiry(expiry time.Duration) Option {
+	return func(cfg *config) {
+		cfg.expiry = expiry
+	}
+}
+
+func getConfig(options ...Option) *config {
+	cfg := new(config)
+	WithExpiry(time.Hour * 24)(cfg)
+	for _, o := range options {
+		o(cfg)
+	}
+	return cfg
+}
@@ -0,0 +1,163 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const changesPageSize = 100
+
+func newSyncRecordStream(
+	ctx context.Context,
+	backend *Backend,
+	recordType string,
+	recordVersion uint64,
+) storage.RecordStream {
+	changed := backend.onChange.Bind()
+	var ready []*databroker.Record
+	return storage.NewRecordStream(ctx, backend.closed, []storage.RecordStreamGenerator{
+		// 1. stream all record changes
+		func(ctx context.Context, block bool) (*databroker.Record, error) {
+			ticker := time.NewTicker(watchPollInterval)
+			defer ticker.Stop()
+
+			for {
+				if len(ready) > 0 {
+					record := ready[0]
+					ready = ready[1:]
+					return record, nil
+				}
+
+				var err error
+				ready, err = nextChangedRecords(ctx, backend, recordType, &recordVersion)
+				if err != nil {
+					return nil, err
+				} else if len(ready) > 0 {
+					continue
+				}
+
+				if !block {
+					return nil, storage.ErrStreamDone
+				}
+
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-ticker.C:
+				case <-changed:
+				}
+			}
+		},
+	}, func() {
+		backend.onChange.Unbind(changed)
+	})
+}
+
+func newSyncLatestRecordStream(
+	ctx context.Context,
+	backend *Backend,
+	recordType string,
+	expr storage.FilterExpression,
+) (storage.RecordStream, error) {
+	filter, err := storage.RecordStreamFilterFromFilterExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	recordTypes := []string{recordType}
+	if recordType == "" {
+		recordTypes, err = backend.ListTypes(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var startKey map[string]types.AttributeValue
+	var scannedRecords []*databroker.Record
+	generator := storage.FilteredRecordStreamGenerator(
+		func(ctx context.Context, block bool) (*databroker.Record, error) {
+			for {
+				if len(scannedRecords) > 0 {
+					record := scannedRecords[0]
+					scannedRecords = scannedRecords[1:]
+					return record, nil
+				}
+
+				if len(recordTypes) == 0 {
+					return nil, storage.ErrStreamDone
+				}
+
+				res, err := backend.queryPage(ctx, recordsPKPrefix+recordTypes[0], "", startKey, 0)
+				if err != nil {
+					return nil, err
+				}
+				for _, item := range res.Items {
+					record, err := unmarshalRecord(item)
+					if err != nil {
+						log.Warn(ctx).Err(err).Msg("dynamodb: invalid record detected")
+						continue
+					}
+					scannedRecords = append(scannedRecords, record)
+				}
+
+				// move on to the next record type once this one has been read
+				startKey = res.LastEvaluatedKey
+				if len(startKey) == 0 {
+					recordTypes = recordTypes[1:]
+				}
+			}
+		},
+		filter,
+	)
+
+	return storage.NewRecordStream(ctx, backend.closed, []storage.RecordStreamGenerator{
+		generator,
+	}, nil), nil
+}
+
+func nextChangedRecords(
+	ctx context.Context,
+	backend *Backend,
+	recordType string,
+	recordVersion *uint64,
+) ([]*databroker.Record, error) {
+	for {
+		res, err := backend.queryPage(ctx, changesPK, getChangeSortKey(*recordVersion), nil, changesPageSize)
+		if err != nil {
+			return nil, err
+		} else if len(res.Items) == 0 {
+			return nil, nil
+		}
+
+		var records []*databroker.Record
+		for _, item := range res.Items {
+			// the sort key is the zero-padded record version
+			*recordVersion, err = strconv.ParseUint(getString(item, attrSortKey), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("dynamodb: invalid change detected: %w", err)
+			}
+
+			record, err := unmarshalRecord(item)
+			if err != nil {
+				log.Warn(ctx).Err(err).Msg("dynamodb: invalid record detected")
+				continue
+			}
+
+			if recordType != "" && record.GetType() != recordType {
+				continue
+			}
+			records = append(records, record)
+		}
+		if len(records) > 0 {
+			return records, nil
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+)
+
+// envelopeMagic prefixes the envelope encrypted payloads, so they can be told apart from records
+// written before encryption was enabled.
+var envelopeMagic = []byte("pomerium-envelope-v1:")
+
+// A KeyWrapper wraps and unwraps data encryption keys with a key encryption key, typically held by
+// a key management service.
+type KeyWrapper interface {
+	// ID returns the id of the key encryption key, which is stored alongside the wrapped data keys.
+	ID() string
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+type envelopeConfig struct {
+	recordTypes             map[string]struct{}
+	dataKeyRotationInterval time.Duration
+}
+
+// An EnvelopeOption customizes the envelope encrypted backend.
+type EnvelopeOption func(*envelopeConfig)
+
+// WithEnvelopeRecordTypes sets the record types to encrypt. All the record types are encrypted if
+// none are set.
+func WithEnvelopeRecordTypes(recordTypes ...string) EnvelopeOption {
+	return func(cfg *envelopeConfig) {
+		cfg.recordTypes = make(map[string]struct{}, len(recordTypes))
+		for _, recordType := range recordTypes {
+			cfg.recordTypes[recordType] = struct{}{}
+		}
+	}
+}
+
+// WithDataKeyRotationInterval sets how often a new data encryption key is generated.
+func WithDataKeyRotationInterval(interval time.Duration) EnvelopeOption {
+	return func(cfg *envelopeConfig) {
+		cfg.dataKeyRotationInterval = interval
+	}
+}
+
+type envelopeDataKey struct {
+	dek       *cryptutil.DataEncryptionKey
+	kekID     string
+	wrapped   []byte
+	createdAt time.Time
+}
+
+type envelopeBackend struct {
+	underlying Backend
+	cfg        *envelopeConfig
+	primary    KeyWrapper
+	wrappers   map[string]KeyWrapper
+	cache      *cryptutil.DataEncryptionKeyCache
+
+	mu      sync.Mutex
+	dataKey *envelopeDataKey
+}
+
+// NewEnvelopeEncryptedBackend creates a new backend which encrypts record payloads with data keys
+// wrapped by a key encryption key. New data keys are wrapped by the first key wrapper. The other key
+// wrappers only unwrap the data keys of records written before a key rotation, so the old keys can be
+// removed once those records have been re-written, e.g. by migrating the storage.
+func NewEnvelopeEncryptedBackend(underlying Backend, keyWrappers []KeyWrapper, options ...EnvelopeOption) (Backend, error) {
+	if len(keyWrappers) == 0 {
+		return nil, fmt.Errorf("storage: at least one key wrapper is required")
+	}
+
+	cfg := new(envelopeConfig)
+	WithDataKeyRotationInterval(24 * time.Hour)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+
+	e := &envelopeBackend{
+		underlying: underlying,
+		cfg:        cfg,
+		primary:    keyWrappers[0],
+		wrappers:   make(map[string]KeyWrapper, len(keyWrappers)),
+		cache:      cryptutil.NewDataEncryptionKeyCache(),
+	}
+	for _, w := range keyWrappers {
+		e.wrappers[w.ID()] = w
+	}
+	return e, nil
+}
+
+func (e *envelopeBackend) Close() error {
+	return e.underlying.Close()
+}
+
+func (e *envelopeBackend) Get(ctx context.Context, recordType, id string) (*databroker.Record, error) {
+	record, err := e.underlying.Get(ctx, recordType, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.decryptRecord(ctx, record)
+}
+
+func (e *envelopeBackend) GetOptions(ctx context.Context, recordType string) (*databroker.Options, error) {
+	return e.underlying.GetOptions(ctx, recordType)
+}
+
+func (e *envelopeBackend) Lease(ctx context.Context, leaseName, leaseID string, ttl time.Duration) (bool, error) {
+	return e.underlying.Lease(ctx, leaseName, leaseID, ttl)
+}
+
+func (e *envelopeBackend) ListTypes(ctx context.Context) ([]string, error) {
+	return e.underlying.ListTypes(ctx)
+}
+
+func (e *envelopeBackend) Put(ctx context.Context, records []*databroker.Record) (uint64, error) {
+	encryptedRecords := make([]*databroker.Record, len(records))
+	for i, record := range records {
+		if !e.isEncrypted(record.GetType()) || record.GetData() == nil {
+			encryptedRecords[i] = record
+			continue
+		}
+
+		encrypted, err := e.encrypt(ctx, record.GetType(), record.GetId(), record.GetData())
+		if err != nil {
+			return 0, err
+		}
+
+		newRecord := proto.Clone(record).(*databroker.Record)
+		newRecord.Data = encrypted
+		encryptedRecords[i] = newRecord
+	}
+
+	serverVersion, err := e.underlying.Put(ctx, encryptedRecords)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, record := range records {
+		record.ModifiedAt = encryptedRecords[i].ModifiedAt
+		record.Version = encryptedRecords[i].Version
+	}
+
+	return serverVersion, nil
+}
+
+func (e *envelopeBackend) SetOptions(ctx context.Context, recordType string, options *databroker.Options) error {
+	return e.underlying.SetOptions(ctx, recordType, options)
+}
+
+func (e *envelopeBackend) Sync(ctx context.Context, recordType string, serverVersion, recordVersion uint64) (RecordStream, error) {
+	stream, err := e.underlying.Sync(ctx, recordType, serverVersion, recordVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &envelopeRecordStream{ctx: ctx, underlying: stream, backend: e}, nil
+}
+
+func (e *envelopeBackend) SyncLatest(
+	ctx context.Context,
+	recordType string,
+	filter FilterExpression,
+) (serverVersion, recordVersion uint64, stream RecordStream, err error) {
+	serverVersion, recordVersion, stream, err = e.underlying.SyncLatest(ctx, recordType, filter)
+	if err != nil {
+		return serverVersion, recordVersion, nil, err
+	}
+	return serverVersion, recordVersion, &envelopeRecordStream{ctx: ctx, underlying: stream, backend: e}, nil
+}
+
+func (e *envelopeBackend) isEncrypted(recordType string) bool {
+	if len(e.cfg.recordTypes) == 0 {
+		return true
+	}
+	_, ok := e.cfg.recordTypes[recordType]
+	return ok
+}
+
+func (e *envelopeBackend) decryptRecord(ctx context.Context, in *databroker.Record) (*databroker.Record, error) {
+	data, err := e.decrypt(ctx, in.GetType(), in.GetId(), in.GetData())
+	if err != nil {
+		return nil, fmt.Errorf("storage: error decrypting %s/%s: %w", in.GetType(), in.GetId(), err)
+	}
+	if data == in.GetData() {
+		return in, nil
+	}
+	// Create a new record so that we don't re-use any internal state
+	return &databroker.Record{
+		Version:    in.Version,
+		Type:       in.Type,
+		Id:         in.Id,
+		Data:       data,
+		ModifiedAt: in.ModifiedAt,
+		DeletedAt:  in.DeletedAt,
+	}, nil
+}
+
+// getDataKey returns the current data key, generating a new one if it's due for rotation or the
+// primary key encryption key changed.
+func (e *envelopeBackend) getDataKey(ctx context.Context) (*envelopeDataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.dataKey != nil &&
+		e.dataKey.kekID == e.primary.ID() &&
+		time.Since(e.dataKey.createdAt) < e.cfg.dataKeyRotationInterval {
+		return e.dataKey, nil
+	}
+
+	dek, err := cryptutil.GenerateDataEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := e.primary.WrapKey(ctx, dek.KeyBytes())
+	if err != nil {
+		return nil, fmt.Errorf("storage: error wrapping data key: %w", err)
+	}
+
+	e.dataKey = &envelopeDataKey{
+		dek:       dek,
+		kekID:     e.primary.ID(),
+		wrapped:   wrapped,
+		createdAt: time.Now(),
+	}
+	e.cache.Put(dataKeyCacheKey(e.dataKey.kekID, wrapped), dek)
+	return e.dataKey, nil
+}
+
+func (e *envelopeBackend) unwrapDataKey(ctx context.Context, kekID string, wrapped []byte) (*cryptutil.DataEncryptionKey, error) {
+	cacheKey := dataKeyCacheKey(kekID, wrapped)
+	if dek, ok := e.cache.Get(cacheKey); ok {
+		return dek, nil
+	}
+
+	w, ok := e.wrappers[kekID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key encryption key: %s", kekID)
+	}
+	raw, err := w.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key: %w", err)
+	}
+	dek, err := cryptutil.NewDataEncryptionKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.Put(cacheKey, dek)
+	return dek, nil
+}
+
+// encrypt encrypts a record payload. The record type and id are authenticated, so the payload can't
+// be moved to another record.
+func (e *envelopeBackend) encrypt(ctx context.Context, recordType, id string, in *anypb.Any) (*anypb.Any, error) {
+	plaintext, err := proto.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := e.getDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(envelopeMagic)
+	writeLengthPrefixed(&buf, []byte(dataKey.kekID))
+	writeLengthPrefixed(&buf, dataKey.wrapped)
+	buf.Write(dataKey.dek.EncryptWithAdditionalData(plaintext, envelopeAdditionalData(recordType, id)))
+	return protoutil.NewAny(&wrapperspb.BytesValue{Value: buf.Bytes()}), nil
+}
+
+// decrypt decrypts an envelope encrypted payload. Payloads which aren't envelope encrypted are
+// returned as-is.
+func (e *envelopeBackend) decrypt(ctx context.Context, recordType, id string, in *anypb.Any) (*anypb.Any, error) {
+	var encrypted wrapperspb.BytesValue
+	if in == nil || !in.MessageIs(&encrypted) {
+		return in, nil
+	}
+	if err := in.UnmarshalTo(&encrypted); err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(encrypted.Value, envelopeMagic) {
+		return in, nil
+	}
+
+	r := bytes.NewReader(encrypted.Value[len(envelopeMagic):])
+	kekID, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := encrypted.Value[len(encrypted.Value)-r.Len():]
+
+	dek, err := e.unwrapDataKey(ctx, string(kekID), wrapped)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := dek.DecryptWithAdditionalData(ciphertext, envelopeAdditionalData(recordType, id))
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(anypb.Any)
+	err = proto.Unmarshal(plaintext, out)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type envelopeRecordStream struct {
+	ctx        context.Context
+	underlying RecordStream
+	backend    *envelopeBackend
+	err        error
+}
+
+func (e *envelopeRecordStream) Close() error {
+	return e.underlying.Close()
+}
+
+func (e *envelopeRecordStream) Next(wait bool) bool {
+	return e.err == nil && e.underlying.Next(wait)
+}
+
+func (e *envelopeRecordStream) Record() *databroker.Record {
+	r := e.underlying.Record()
+	if r != nil {
+		var err error
+		r, err = e.backend.decryptRecord(e.ctx, r)
+		if err != nil {
+			e.err = err
+		}
+	}
+	return r
+}
+
+func (e *envelopeRecordStream) Err() error {
+	if e.err == nil {
+		e.err = e.underlying.Err()
+	}
+	return e.err
+}
+
+func dataKeyCacheKey(kekID string, wrapped []byte) []byte {
+	return append([]byte(kekID+":"), wrapped...)
+}
+
+// envelopeAdditionalData returns the additional data which binds an encrypted payload to its record.
+func envelopeAdditionalData(recordType, id string) []byte {
+	var buf bytes.Buffer
+	writeLengthPrefixed(&buf, []byte(recordType))
+	writeLengthPrefixed(&buf, []byte(id))
+	return buf.Bytes()
+}
+
+func writeLengthPrefixed(buf *bytes.Buffer, data []byte) {
+	buf.Write(binary.AppendUvarint(nil, uint64(len(data))))
+	buf.Write(data)
+}
+
+var errInvalidEnvelope = errors.New("invalid envelope")
+
+func readLengthPrefixed(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil || n > uint64(r.Len()) {
+		return nil, errInvalidEnvelope
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errInvalidEnvelope
+	}
+	return data, nil
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage/keywrap"
+)
+
+type countingKeyWrapper struct {
+	KeyWrapper
+	wraps, unwraps int
+}
+
+func (w *countingKeyWrapper) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	w.wraps++
+	return w.KeyWrapper.WrapKey(ctx, key)
+}
+
+func (w *countingKeyWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	w.unwraps++
+	return w.KeyWrapper.UnwrapKey(ctx, wrapped)
+}
+
+func newTestKeyWrapper(t *testing.T) *countingKeyWrapper {
+	t.Helper()
+
+	kek, err := cryptutil.GenerateKeyEncryptionKey()
+	require.NoError(t, err)
+	return &countingKeyWrapper{KeyWrapper: keywrap.NewLocal(kek)}
+}
+
+func newTestMapBackend(m map[string]*anypb.Any) *mockBackend {
+	return &mockBackend{
+		put: func(ctx context.Context, records []*databroker.Record) (uint64, error) {
+			for _, record := range records {
+				record.ModifiedAt = timestamppb.Now()
+				record.Version++
+				m[record.GetType()+"/"+record.GetId()] = record.GetData()
+			}
+			return 0, nil
+		},
+		get: func(ctx context.Context, recordType, id string) (*databroker.Record, error) {
+			data, ok := m[recordType+"/"+id]
+			if !ok {
+				return nil, errors.New("not found")
+			}
+			return &databroker.Record{Type: recordType, Id: id, Data: data, Version: 1}, nil
+		},
+	}
+}
+
+func TestEnvelopeEncryptedBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	m := map[string]*anypb.Any{}
+	key1, key2 := newTestKeyWrapper(t), newTestKeyWrapper(t)
+
+	e, err := NewEnvelopeEncryptedBackend(newTestMapBackend(m), []KeyWrapper{key1},
+		WithEnvelopeRecordTypes("SECRET"))
+	require.NoError(t, err)
+
+	data := protoutil.NewAny(wrapperspb.String("HELLO WORLD"))
+	_, err = e.Put(ctx, []*databroker.Record{
+		{Type: "SECRET", Id: "1", Data: data},
+		{Type: "SECRET", Id: "2", Data: data},
+		{Type: "PUBLIC", Id: "1", Data: data},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, key1.wraps, "should re-use the data key")
+
+	assert.NotEqual(t, data.Value, m["SECRET/1"].Value, "value should be encrypted")
+	assert.Equal(t, data.Value, m["PUBLIC/1"].Value, "other record types should not be encrypted")
+
+	m["SECRET/legacy"] = data
+	for _, id := range []string{"1", "legacy"} {
+		record, err := e.Get(ctx, "SECRET", id)
+		require.NoError(t, err)
+		assert.Equal(t, data.TypeUrl, record.Data.TypeUrl, "type should be preserved")
+		assert.Equal(t, data.Value, record.Data.Value, "value should be preserved")
+	}
+	assert.Equal(t, 0, key1.unwraps, "should cache the data key")
+
+	t.Run("moved payload", func(t *testing.T) {
+		m["SECRET/moved"] = m["SECRET/1"]
+		_, err := e.Get(ctx, "SECRET", "moved")
+		assert.Error(t, err, "should not decrypt a payload copied to another record")
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		rotated, err := NewEnvelopeEncryptedBackend(newTestMapBackend(m), []KeyWrapper{key2, key1},
+			WithEnvelopeRecordTypes("SECRET"))
+		require.NoError(t, err)
+
+		record, err := rotated.Get(ctx, "SECRET", "1")
+		require.NoError(t, err)
+		assert.Equal(t, data.Value, record.Data.Value)
+		assert.Equal(t, 1, key1.unwraps)
+
+		_, err = rotated.Put(ctx, []*databroker.Record{{Type: "SECRET", Id: "3", Data: data}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, key2.wraps, "should wrap new data keys with the primary key")
+
+		removed, err := NewEnvelopeEncryptedBackend(newTestMapBackend(m), []KeyWrapper{key2},
+			WithEnvelopeRecordTypes("SECRET"))
+		require.NoError(t, err)
+		_, err = removed.Get(ctx, "SECRET", "1")
+		assert.Error(t, err, "should fail without the old key")
+		_, err = removed.Get(ctx, "SECRET", "3")
+		assert.NoError(t, err)
+	})
+
+	t.Run("data key rotation", func(t *testing.T) {
+		key := newTestKeyWrapper(t)
+		e, err := NewEnvelopeEncryptedBackend(newTestMapBackend(map[string]*anypb.Any{}), []KeyWrapper{key},
+			WithDataKeyRotationInterval(time.Nanosecond))
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = e.Put(ctx, []*databroker.Record{{Type: "SECRET", Id: "1", Data: data}})
+			require.NoError(t, err)
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, 2, key.wraps)
+	})
+}
+
+func TestReadLengthPrefixed(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	writeLengthPrefixed(&buf, []byte("HELLO"))
+	writeLengthPrefixed(&buf, nil)
+
+	r := bytes.NewReader(buf.Bytes())
+	data, err := readLengthPrefixed(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("HELLO"), data)
+	data, err = readLengthPrefixed(r)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	for _, raw := range [][]byte{
+		nil,
+		buf.Bytes()[:3],
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	} {
+		_, err := readLengthPrefixed(bytes.NewReader(raw))
+		assert.ErrorIs(t, err, errInvalidEnvelope, "%x", raw)
+	}
+}
@@ -0,0 +1,70 @@
+package keywrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// A KMSClient is the subset of the AWS KMS client used to wrap data keys.
+type KMSClient interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// An AWSKMS wraps data keys with an AWS KMS symmetric key.
+type AWSKMS struct {
+	client KMSClient
+	keyID  string
+}
+
+// NewAWSKMS creates a new AWSKMS key wrapper using the given client.
+func NewAWSKMS(client KMSClient, keyID string) *AWSKMS {
+	return &AWSKMS{client: client, keyID: keyID}
+}
+
+// NewAWSKMSClient creates a new AWS KMS client using the default AWS configuration of the
+// environment. If the region is empty, the region of the environment is used.
+func NewAWSKMSClient(ctx context.Context, region string) (*kms.Client, error) {
+	var options []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		options = append(options, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("keywrap: error loading aws config: %w", err)
+	}
+	return kms.NewFromConfig(cfg), nil
+}
+
+// ID returns the id of the KMS key.
+func (w *AWSKMS) ID() string {
+	return w.keyID
+}
+
+// WrapKey encrypts a data key using KMS.
+func (w *AWSKMS) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	res, err := w.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:     aws.String(w.keyID),
+		Plaintext: key,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("keywrap: error encrypting data key with kms: %w", err)
+	}
+	return res.CiphertextBlob, nil
+}
+
+// UnwrapKey decrypts a data key using KMS.
+func (w *AWSKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	res, err := w.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(w.keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("keywrap: error decrypting data key with kms: %w", err)
+	}
+	return res.Plaintext, nil
+}
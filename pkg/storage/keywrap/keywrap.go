@@ -0,0 +1,33 @@
+// Package keywrap contains key wrappers used for envelope encryption of databroker records.
+package keywrap
+
+import (
+	"context"
+
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+)
+
+// A Local wraps data keys with a Curve25519 key encryption key.
+type Local struct {
+	kek *cryptutil.PrivateKeyEncryptionKey
+}
+
+// NewLocal creates a new Local key wrapper.
+func NewLocal(kek *cryptutil.PrivateKeyEncryptionKey) *Local {
+	return &Local{kek: kek}
+}
+
+// ID returns the id of the key encryption key.
+func (w *Local) ID() string {
+	return w.kek.ID()
+}
+
+// WrapKey encrypts a data key with the public key of the key encryption key.
+func (w *Local) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	return w.kek.Public().Encrypt(key)
+}
+
+// UnwrapKey decrypts a data key with the key encryption key.
+func (w *Local) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	return w.kek.Decrypt(wrapped)
+}
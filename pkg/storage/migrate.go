@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+const migrateBatchSize = 100
+
+// Migrate copies all the records and record type options from one backend to another. Records are
+// assigned new versions by the destination backend, so syncers of the destination will re-sync.
+func Migrate(ctx context.Context, dst, src Backend) (count int, err error) {
+	recordTypes, err := src.ListTypes(ctx)
+	if err != nil {
+		return count, fmt.Errorf("storage: error listing record types: %w", err)
+	}
+
+	for _, recordType := range recordTypes {
+		options, err := src.GetOptions(ctx, recordType)
+		if err != nil {
+			return count, fmt.Errorf("storage: error retrieving %s options: %w", recordType, err)
+		}
+		err = dst.SetOptions(ctx, recordType, options)
+		if err != nil {
+			return count, fmt.Errorf("storage: error setting %s options: %w", recordType, err)
+		}
+
+		n, err := migrateRecordType(ctx, dst, src, recordType)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("storage: error migrating %s records: %w", recordType, err)
+		}
+	}
+
+	return count, nil
+}
+
+func migrateRecordType(ctx context.Context, dst, src Backend, recordType string) (count int, err error) {
+	_, _, stream, err := src.SyncLatest(ctx, recordType, nil)
+	if err != nil {
+		return count, err
+	}
+	defer stream.Close()
+
+	var batch []*databroker.Record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := dst.Put(ctx, batch)
+		if err != nil {
+			return err
+		}
+		count += len(batch)
+		batch = nil
+		return nil
+	}
+
+	for stream.Next(false) {
+		batch = append(batch, stream.Record())
+		if len(batch) >= migrateBatchSize {
+			err = flush()
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	if err = stream.Err(); err != nil {
+		return count, err
+	}
+
+	return count, flush()
+}
@@ -0,0 +1,60 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+	"github.com/pomerium/pomerium/pkg/storage/inmemory"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	src := inmemory.New()
+	defer src.Close()
+	dst := inmemory.New()
+	defer dst.Close()
+
+	require.NoError(t, src.SetOptions(ctx, "TYPE-1", &databroker.Options{Capacity: proto.Uint64(1000)}))
+	var records []*databroker.Record
+	for i := 0; i < 250; i++ {
+		records = append(records, &databroker.Record{
+			Type: "TYPE-1",
+			Id:   fmt.Sprint(i),
+			Data: protoutil.NewAny(protoutil.NewStructString(fmt.Sprint(i))),
+		})
+	}
+	records = append(records,
+		&databroker.Record{Type: "TYPE-2", Id: "1", Data: protoutil.NewAny(protoutil.NewStructString("1"))},
+		&databroker.Record{Type: "TYPE-2", Id: "2", DeletedAt: timestamppb.Now()},
+	)
+	_, err := src.Put(ctx, records)
+	require.NoError(t, err)
+
+	count, err := storage.Migrate(ctx, dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, 251, count, "should skip deleted records")
+
+	for _, id := range []string{"0", "249"} {
+		record, err := dst.Get(ctx, "TYPE-1", id)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(protoutil.NewAny(protoutil.NewStructString(id)), record.GetData()))
+	}
+	_, err = dst.Get(ctx, "TYPE-2", "2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	options, err := dst.GetOptions(ctx, "TYPE-1")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), options.GetCapacity())
+}
@@ -134,6 +134,36 @@ func (q *clientQuerier) Query(ctx context.Context, in *databroker.QueryRequest,
 	return q.client.Query(ctx, in, opts...)
 }
 
+type fallbackQuerier []Querier
+
+// NewFallbackQuerier creates a new Querier that queries each querier in order, returning the
+// results of the first querier that has any.
+func NewFallbackQuerier(queriers ...Querier) Querier {
+	return fallbackQuerier(queriers)
+}
+
+func (q fallbackQuerier) InvalidateCache(ctx context.Context, in *databroker.QueryRequest) {
+	for _, qq := range q {
+		qq.InvalidateCache(ctx, in)
+	}
+}
+
+// Query queries for records.
+func (q fallbackQuerier) Query(ctx context.Context, in *databroker.QueryRequest, opts ...grpc.CallOption) (*databroker.QueryResponse, error) {
+	res := new(databroker.QueryResponse)
+	for _, qq := range q {
+		var err error
+		res, err = qq.Query(ctx, in, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.GetRecords()) > 0 {
+			break
+		}
+	}
+	return res, nil
+}
+
 // A TracingQuerier records calls to Query.
 type TracingQuerier struct {
 	underlying Querier
@@ -0,0 +1,525 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/cespare/xxhash/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/signal"
+	"github.com/pomerium/pomerium/pkg/cryptutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+// DefaultShardedChangeLogSize is the default number of changes kept by a sharded backend for
+// clients resuming a sync.
+const DefaultShardedChangeLogSize = 10000
+
+// shardedPutTimeout is how long Put waits for the changes of the records to be observed.
+const shardedPutTimeout = 10 * time.Second
+
+type shardedConfig struct {
+	recordTypeShards  map[string]int
+	hashedRecordTypes map[string]struct{}
+	changeLogSize     int
+}
+
+// A ShardedOption customizes the sharded backend.
+type ShardedOption func(cfg *shardedConfig)
+
+// WithRecordTypeShard assigns the records of a record type to a shard.
+func WithRecordTypeShard(recordType string, shard int) ShardedOption {
+	return func(cfg *shardedConfig) {
+		cfg.recordTypeShards[recordType] = shard
+	}
+}
+
+// WithHashedRecordTypes spreads the records of the record types across all the shards by the hash
+// of their id.
+func WithHashedRecordTypes(recordTypes ...string) ShardedOption {
+	return func(cfg *shardedConfig) {
+		for _, recordType := range recordTypes {
+			cfg.hashedRecordTypes[recordType] = struct{}{}
+		}
+	}
+}
+
+// WithShardedChangeLogSize sets the number of changes kept for clients resuming a sync. Clients
+// which fall further behind have to sync the latest records again.
+func WithShardedChangeLogSize(size int) ShardedOption {
+	return func(cfg *shardedConfig) {
+		cfg.changeLogSize = size
+	}
+}
+
+func getShardedConfig(options ...ShardedOption) *shardedConfig {
+	cfg := &shardedConfig{
+		recordTypeShards:  map[string]int{},
+		hashedRecordTypes: map[string]struct{}{},
+	}
+	WithShardedChangeLogSize(DefaultShardedChangeLogSize)(cfg)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+// A shardedBackend spreads records across multiple backends. Each record type is owned by a shard,
+// the first shard by default, or hashed across all the shards by record id.
+//
+// The changes of the shards are merged into a single change log, so a sharded backend has its own
+// server and record versions. The version of a record returned by Get, Put and SyncLatest is the
+// version of the change log the record is current as of, which isn't less than the version of the
+// change of the record.
+type shardedBackend struct {
+	cfg      *shardedConfig
+	shards   []Backend
+	onChange *signal.Signal
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	cancel    context.CancelFunc
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	mu                  sync.RWMutex
+	serverVersion       uint64
+	recordVersion       uint64
+	trimmedVersion      uint64
+	changes             []*databroker.Record
+	shardServerVersions []uint64
+	shardRecordVersions []uint64
+	shardsReady         []bool
+}
+
+// NewShardedBackend creates a new Backend which spreads records across the shards. The first shard
+// stores leases and the options of hashed record types.
+func NewShardedBackend(shards []Backend, options ...ShardedOption) (Backend, error) {
+	cfg := getShardedConfig(options...)
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("storage: at least one shard is required")
+	}
+	for recordType, shard := range cfg.recordTypeShards {
+		if shard < 0 || shard >= len(shards) {
+			return nil, fmt.Errorf("storage: invalid shard for %s: %d", recordType, shard)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	backend := &shardedBackend{
+		cfg:                 cfg,
+		shards:              shards,
+		onChange:            signal.New(),
+		closed:              make(chan struct{}),
+		cancel:              cancel,
+		ready:               make(chan struct{}),
+		serverVersion:       cryptutil.NewRandomUInt64(),
+		shardServerVersions: make([]uint64, len(shards)),
+		shardRecordVersions: make([]uint64, len(shards)),
+		shardsReady:         make([]bool, len(shards)),
+	}
+	for i := range shards {
+		go backend.follow(ctx, i)
+	}
+	return backend, nil
+}
+
+func (backend *shardedBackend) Close() error {
+	var err error
+	backend.closeOnce.Do(func() {
+		backend.cancel()
+		close(backend.closed)
+		for _, shard := range backend.shards {
+			if e := shard.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	})
+	return err
+}
+
+func (backend *shardedBackend) Get(ctx context.Context, recordType, id string) (*databroker.Record, error) {
+	if err := backend.waitReady(ctx); err != nil {
+		return nil, err
+	}
+
+	backend.mu.RLock()
+	recordVersion := backend.recordVersion
+	backend.mu.RUnlock()
+
+	record, err := backend.shards[backend.getShard(recordType, id)].Get(ctx, recordType, id)
+	if err != nil {
+		return nil, err
+	}
+	record = proto.Clone(record).(*databroker.Record)
+	record.Version = recordVersion
+	return record, nil
+}
+
+func (backend *shardedBackend) GetOptions(ctx context.Context, recordType string) (*databroker.Options, error) {
+	return backend.shards[backend.getShard(recordType, "")].GetOptions(ctx, recordType)
+}
+
+func (backend *shardedBackend) Lease(ctx context.Context, leaseName, leaseID string, ttl time.Duration) (bool, error) {
+	return backend.shards[0].Lease(ctx, leaseName, leaseID, ttl)
+}
+
+func (backend *shardedBackend) ListTypes(ctx context.Context) ([]string, error) {
+	lookup := map[string]struct{}{}
+	for _, shard := range backend.shards {
+		recordTypes, err := shard.ListTypes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, recordType := range recordTypes {
+			lookup[recordType] = struct{}{}
+		}
+	}
+
+	recordTypes := make([]string, 0, len(lookup))
+	for recordType := range lookup {
+		recordTypes = append(recordTypes, recordType)
+	}
+	sort.Strings(recordTypes)
+	return recordTypes, nil
+}
+
+// Put puts the records in their shards, and waits for their changes to be added to the change log,
+// so that the returned versions can be used to wait for the records to be synced.
+func (backend *shardedBackend) Put(ctx context.Context, records []*databroker.Record) (uint64, error) {
+	if err := backend.waitReady(ctx); err != nil {
+		return 0, err
+	}
+
+	byShard := make([][]*databroker.Record, len(backend.shards))
+	for _, record := range records {
+		if record == nil {
+			return 0, fmt.Errorf("records cannot be nil")
+		}
+		shard := backend.getShard(record.GetType(), record.GetId())
+		byShard[shard] = append(byShard[shard], record)
+	}
+
+	shardRecordVersions := make([]uint64, len(backend.shards))
+	for shard, shardRecords := range byShard {
+		if len(shardRecords) == 0 {
+			continue
+		}
+		if _, err := backend.shards[shard].Put(ctx, shardRecords); err != nil {
+			return 0, err
+		}
+		for _, record := range shardRecords {
+			if record.GetVersion() > shardRecordVersions[shard] {
+				shardRecordVersions[shard] = record.GetVersion()
+			}
+		}
+	}
+
+	serverVersion, recordVersion := backend.waitForChanges(ctx, shardRecordVersions)
+	for _, record := range records {
+		record.Version = recordVersion
+	}
+	return serverVersion, nil
+}
+
+func (backend *shardedBackend) SetOptions(ctx context.Context, recordType string, options *databroker.Options) error {
+	if !backend.isHashed(recordType) {
+		return backend.shards[backend.getShard(recordType, "")].SetOptions(ctx, recordType, options)
+	}
+
+	// the capacity of hashed record types applies to each shard
+	for _, shard := range backend.shards {
+		if err := shard.SetOptions(ctx, recordType, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (backend *shardedBackend) Sync(
+	ctx context.Context,
+	recordType string,
+	serverVersion, recordVersion uint64,
+) (RecordStream, error) {
+	if err := backend.waitReady(ctx); err != nil {
+		return nil, err
+	}
+
+	backend.mu.RLock()
+	_, err := backend.getChangesLocked(recordType, serverVersion, recordVersion)
+	backend.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := backend.onChange.Bind()
+	var ready []*databroker.Record
+	return NewRecordStream(ctx, backend.closed, []RecordStreamGenerator{
+		func(ctx context.Context, block bool) (*databroker.Record, error) {
+			for {
+				if len(ready) > 0 {
+					record := ready[0]
+					ready = ready[1:]
+					return record, nil
+				}
+
+				var err error
+				backend.mu.RLock()
+				ready, err = backend.getChangesLocked(recordType, serverVersion, recordVersion)
+				lastVersion := backend.recordVersion
+				backend.mu.RUnlock()
+				if err != nil {
+					return nil, err
+				}
+				// changes of other record types are skipped
+				recordVersion = lastVersion
+
+				if len(ready) == 0 && !block {
+					return nil, ErrStreamDone
+				} else if len(ready) == 0 {
+					select {
+					case <-ctx.Done():
+						return nil, ctx.Err()
+					case <-changed:
+					}
+				}
+			}
+		},
+	}, func() {
+		backend.onChange.Unbind(changed)
+	}), nil
+}
+
+func (backend *shardedBackend) SyncLatest(
+	ctx context.Context,
+	recordType string,
+	filter FilterExpression,
+) (serverVersion, recordVersion uint64, stream RecordStream, err error) {
+	if err := backend.waitReady(ctx); err != nil {
+		return 0, 0, nil, err
+	}
+
+	backend.mu.RLock()
+	serverVersion, recordVersion = backend.serverVersion, backend.recordVersion
+	backend.mu.RUnlock()
+
+	var shards []int
+	if recordType == "" || backend.isHashed(recordType) {
+		for i := range backend.shards {
+			shards = append(shards, i)
+		}
+	} else {
+		shards = append(shards, backend.getShard(recordType, ""))
+	}
+
+	streams := make([]RecordStream, 0, len(shards))
+	closeStreams := func() {
+		for _, s := range streams {
+			_ = s.Close()
+		}
+	}
+	generators := make([]RecordStreamGenerator, 0, len(shards))
+	for _, i := range shards {
+		i := i
+		_, _, s, err := backend.shards[i].SyncLatest(ctx, recordType, filter)
+		if err != nil {
+			closeStreams()
+			return 0, 0, nil, err
+		}
+		streams = append(streams, s)
+		generators = append(generators, func(ctx context.Context, block bool) (*databroker.Record, error) {
+			for s.Next(false) {
+				record := s.Record()
+				// records are only synced from the shard owning them
+				if backend.getShard(record.GetType(), record.GetId()) != i {
+					continue
+				}
+				record = proto.Clone(record).(*databroker.Record)
+				record.Version = recordVersion
+				return record, nil
+			}
+			if err := s.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrStreamDone
+		})
+	}
+
+	return serverVersion, recordVersion, NewRecordStream(ctx, backend.closed, generators, closeStreams), nil
+}
+
+func (backend *shardedBackend) isHashed(recordType string) bool {
+	_, ok := backend.cfg.hashedRecordTypes[recordType]
+	return ok
+}
+
+// getShard returns the shard owning a record. Options of hashed record types are owned by the
+// first shard.
+func (backend *shardedBackend) getShard(recordType, id string) int {
+	if backend.isHashed(recordType) {
+		if id == "" {
+			return 0
+		}
+		return int(xxhash.Sum64String(id) % uint64(len(backend.shards)))
+	}
+	return backend.cfg.recordTypeShards[recordType]
+}
+
+func (backend *shardedBackend) waitReady(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-backend.closed:
+		return fmt.Errorf("storage: backend closed")
+	case <-backend.ready:
+		return nil
+	}
+}
+
+// waitForChanges waits for the changes of the shards up to the record versions to be added to the
+// change log, and returns the versions of the change log.
+func (backend *shardedBackend) waitForChanges(ctx context.Context, shardRecordVersions []uint64) (serverVersion, recordVersion uint64) {
+	changed := backend.onChange.Bind()
+	defer backend.onChange.Unbind(changed)
+
+	ctx, cancel := context.WithTimeout(ctx, shardedPutTimeout)
+	defer cancel()
+
+	for {
+		backend.mu.RLock()
+		observed := true
+		for i, v := range shardRecordVersions {
+			if backend.shardRecordVersions[i] < v {
+				observed = false
+			}
+		}
+		serverVersion, recordVersion = backend.serverVersion, backend.recordVersion
+		backend.mu.RUnlock()
+		if observed {
+			return serverVersion, recordVersion
+		}
+
+		select {
+		case <-ctx.Done():
+			// the records were stored, so they will be synced once the shard catches up
+			log.Warn(ctx).Msg("storage: timed out waiting for shard changes")
+			return serverVersion, recordVersion
+		case <-backend.closed:
+			return serverVersion, recordVersion
+		case <-changed:
+		}
+	}
+}
+
+// getChangesLocked returns the changes after the record version. The change log only has the most
+// recent changes, so an invalid server version error is returned if changes after the record
+// version were discarded.
+func (backend *shardedBackend) getChangesLocked(recordType string, serverVersion, recordVersion uint64) ([]*databroker.Record, error) {
+	if serverVersion != backend.serverVersion || recordVersion < backend.trimmedVersion {
+		return nil, ErrInvalidServerVersion
+	}
+
+	var changes []*databroker.Record
+	for i := int(recordVersion - backend.trimmedVersion); i < len(backend.changes); i++ {
+		if recordType == "" || backend.changes[i].GetType() == recordType {
+			changes = append(changes, backend.changes[i])
+		}
+	}
+	return changes, nil
+}
+
+// follow adds the changes of a shard to the change log.
+func (backend *shardedBackend) follow(ctx context.Context, shard int) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+	for {
+		err := backend.followOnce(ctx, shard, bo)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Error(ctx).Err(err).Int("shard", shard).Msg("storage: error syncing shard")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(bo.NextBackOff()):
+		}
+	}
+}
+
+func (backend *shardedBackend) followOnce(ctx context.Context, shard int, bo backoff.BackOff) error {
+	serverVersion, recordVersion, latest, err := backend.shards[shard].SyncLatest(ctx, "", nil)
+	if err != nil {
+		return err
+	}
+	_ = latest.Close()
+
+	backend.mu.Lock()
+	if backend.shardServerVersions[shard] == serverVersion {
+		// resume from the last change of the shard
+		recordVersion = backend.shardRecordVersions[shard]
+	} else if backend.shardServerVersions[shard] != 0 {
+		// the shard was reset, so clients have to sync the latest records again
+		backend.serverVersion = cryptutil.NewRandomUInt64()
+		backend.trimmedVersion = backend.recordVersion
+		backend.changes = nil
+	}
+	backend.shardServerVersions[shard] = serverVersion
+	backend.shardRecordVersions[shard] = recordVersion
+	backend.mu.Unlock()
+
+	stream, err := backend.shards[shard].Sync(ctx, "", serverVersion, recordVersion)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	backend.setReady(shard)
+	bo.Reset()
+
+	for stream.Next(true) {
+		backend.addChange(ctx, shard, stream.Record())
+	}
+	return stream.Err()
+}
+
+func (backend *shardedBackend) setReady(shard int) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	backend.shardsReady[shard] = true
+	for _, ready := range backend.shardsReady {
+		if !ready {
+			return
+		}
+	}
+	backend.readyOnce.Do(func() { close(backend.ready) })
+}
+
+func (backend *shardedBackend) addChange(ctx context.Context, shard int, record *databroker.Record) {
+	backend.mu.Lock()
+	backend.shardRecordVersions[shard] = record.GetVersion()
+	// changes of records which aren't owned by the shard are ignored
+	if backend.getShard(record.GetType(), record.GetId()) == shard {
+		record = proto.Clone(record).(*databroker.Record)
+		backend.recordVersion++
+		record.Version = backend.recordVersion
+		backend.changes = append(backend.changes, record)
+
+		// discard the oldest changes in batches
+		if n := len(backend.changes) - backend.cfg.changeLogSize; n > backend.cfg.changeLogSize/10 {
+			backend.changes = append([]*databroker.Record(nil), backend.changes[n:]...)
+			backend.trimmedVersion += uint64(n)
+		}
+	}
+	backend.mu.Unlock()
+
+	backend.onChange.Broadcast(ctx)
+}
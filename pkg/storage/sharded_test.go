@@ -0,0 +1,118 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+	"github.com/pomerium/pomerium/pkg/storage/inmemory"
+)
+
+func TestShardedBackend(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	shards := []*inmemory.Backend{inmemory.New(), inmemory.New(), inmemory.New()}
+	backend, err := storage.NewShardedBackend(
+		[]storage.Backend{shards[0], shards[1], shards[2]},
+		storage.WithRecordTypeShard("OWNED", 2),
+		storage.WithHashedRecordTypes("HASHED"),
+	)
+	require.NoError(t, err)
+	defer backend.Close()
+
+	newRecord := func(recordType, id string) *databroker.Record {
+		return &databroker.Record{
+			Type: recordType,
+			Id:   id,
+			Data: protoutil.NewAny(protoutil.NewStructString(id)),
+		}
+	}
+
+	var records []*databroker.Record
+	for i := 0; i < 30; i++ {
+		records = append(records, newRecord("HASHED", fmt.Sprint(i)))
+	}
+	records = append(records, newRecord("OWNED", "1"), newRecord("DEFAULT", "1"))
+	serverVersion, err := backend.Put(ctx, records)
+	require.NoError(t, err)
+	putVersion := records[0].GetVersion()
+	assert.NotZero(t, putVersion)
+
+	t.Run("ownership", func(t *testing.T) {
+		for i, shard := range shards {
+			_, _, stream, err := shard.SyncLatest(ctx, "HASHED", nil)
+			require.NoError(t, err)
+			records, err := storage.RecordStreamToList(stream)
+			require.NoError(t, err)
+			assert.NotEmpty(t, records, "shard %d should have hashed records", i)
+		}
+
+		_, err := shards[2].Get(ctx, "OWNED", "1")
+		assert.NoError(t, err)
+		_, err = shards[0].Get(ctx, "OWNED", "1")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = shards[0].Get(ctx, "DEFAULT", "1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		record, err := backend.Get(ctx, "HASHED", "7")
+		require.NoError(t, err)
+		assert.Equal(t, "7", record.GetId())
+		assert.GreaterOrEqual(t, record.GetVersion(), putVersion)
+	})
+
+	t.Run("sync latest", func(t *testing.T) {
+		sv, rv, stream, err := backend.SyncLatest(ctx, "", nil)
+		require.NoError(t, err)
+		assert.Equal(t, serverVersion, sv)
+		assert.GreaterOrEqual(t, rv, putVersion)
+		records, err := storage.RecordStreamToList(stream)
+		require.NoError(t, err)
+		assert.Len(t, records, 32)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		_, recordVersion, stream, err := backend.SyncLatest(ctx, "HASHED", nil)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+
+		stream, err = backend.Sync(ctx, "HASHED", serverVersion, recordVersion)
+		require.NoError(t, err)
+		defer stream.Close()
+
+		_, err = backend.Put(ctx, []*databroker.Record{
+			newRecord("OWNED", "2"),
+			newRecord("HASHED", "100"),
+			newRecord("HASHED", "101"),
+		})
+		require.NoError(t, err)
+
+		var ids []string
+		for len(ids) < 2 && stream.Next(true) {
+			assert.Greater(t, stream.Record().GetVersion(), recordVersion)
+			ids = append(ids, stream.Record().GetId())
+		}
+		require.NoError(t, stream.Err())
+		assert.ElementsMatch(t, []string{"100", "101"}, ids)
+	})
+
+	t.Run("invalid server version", func(t *testing.T) {
+		stream, err := backend.Sync(ctx, "", serverVersion+1, 0)
+		if err == nil {
+			assert.False(t, stream.Next(false))
+			err = stream.Err()
+		}
+		assert.ErrorIs(t, err, storage.ErrInvalidServerVersion)
+	})
+}
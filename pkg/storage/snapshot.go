@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+)
+
+const (
+	snapshotFormat    = "pomerium-databroker-snapshot"
+	snapshotBatchSize = 100
+)
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+const SnapshotVersion = 1
+
+// A SnapshotHeader is the first line of a snapshot.
+type SnapshotHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// A snapshot entry is either the options of a record type or a record.
+type snapshotEntry struct {
+	Type    string          `json:"type,omitempty"`
+	Options json.RawMessage `json:"options,omitempty"`
+	Record  json.RawMessage `json:"record,omitempty"`
+}
+
+// WriteSnapshot writes a snapshot of all the records and record type options of a backend. The
+// snapshot is newline delimited JSON: a header, followed by the options of each record type and its
+// records, which are encoded using the protobuf JSON mapping.
+func WriteSnapshot(ctx context.Context, w io.Writer, src Backend) (count int, err error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	err = enc.Encode(SnapshotHeader{
+		Format:    snapshotFormat,
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return count, err
+	}
+
+	recordTypes, err := src.ListTypes(ctx)
+	if err != nil {
+		return count, fmt.Errorf("storage: error listing record types: %w", err)
+	}
+
+	for _, recordType := range recordTypes {
+		options, err := src.GetOptions(ctx, recordType)
+		if err != nil {
+			return count, fmt.Errorf("storage: error retrieving %s options: %w", recordType, err)
+		}
+		if proto.Size(options) > 0 {
+			bs, err := protojson.Marshal(options)
+			if err != nil {
+				return count, err
+			}
+			err = enc.Encode(snapshotEntry{Type: recordType, Options: bs})
+			if err != nil {
+				return count, err
+			}
+		}
+
+		n, err := writeSnapshotRecords(ctx, enc, src, recordType)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("storage: error exporting %s records: %w", recordType, err)
+		}
+	}
+
+	return count, bw.Flush()
+}
+
+func writeSnapshotRecords(ctx context.Context, enc *json.Encoder, src Backend, recordType string) (count int, err error) {
+	_, _, stream, err := src.SyncLatest(ctx, recordType, nil)
+	if err != nil {
+		return count, err
+	}
+	defer stream.Close()
+
+	for stream.Next(false) {
+		bs, err := protojson.Marshal(stream.Record())
+		if err != nil {
+			return count, err
+		}
+		err = enc.Encode(snapshotEntry{Record: bs})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, stream.Err()
+}
+
+// ReadSnapshot puts all the records and record type options of a snapshot into a backend. Records
+// are assigned new versions by the backend.
+func ReadSnapshot(ctx context.Context, dst Backend, r io.Reader) (count int, err error) {
+	br := bufio.NewReader(r)
+
+	line, err := readSnapshotLine(br)
+	if err != nil {
+		return count, fmt.Errorf("storage: error reading snapshot header: %w", err)
+	}
+	var header SnapshotHeader
+	if err := json.Unmarshal(line, &header); err != nil || header.Format != snapshotFormat {
+		return count, fmt.Errorf("storage: invalid snapshot header")
+	}
+	if header.Version != SnapshotVersion {
+		return count, fmt.Errorf("storage: unsupported snapshot version: %d", header.Version)
+	}
+
+	var batch []*databroker.Record
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := dst.Put(ctx, batch)
+		if err != nil {
+			return err
+		}
+		count += len(batch)
+		batch = nil
+		return nil
+	}
+
+	for {
+		line, err := readSnapshotLine(br)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("storage: error reading snapshot: %w", err)
+		}
+
+		var entry snapshotEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count, fmt.Errorf("storage: invalid snapshot entry: %w", err)
+		}
+
+		switch {
+		case entry.Options != nil:
+			options := new(databroker.Options)
+			if err := protojson.Unmarshal(entry.Options, options); err != nil {
+				return count, fmt.Errorf("storage: invalid %s options: %w", entry.Type, err)
+			}
+			// options are applied before the records of the type, so flush the pending records
+			if err := flush(); err != nil {
+				return count, err
+			}
+			if err := dst.SetOptions(ctx, entry.Type, options); err != nil {
+				return count, fmt.Errorf("storage: error setting %s options: %w", entry.Type, err)
+			}
+		case entry.Record != nil:
+			record := new(databroker.Record)
+			if err := protojson.Unmarshal(entry.Record, record); err != nil {
+				return count, fmt.Errorf("storage: invalid snapshot record: %w", err)
+			}
+			batch = append(batch, record)
+			if len(batch) >= snapshotBatchSize {
+				if err := flush(); err != nil {
+					return count, err
+				}
+			}
+		}
+	}
+
+	return count, flush()
+}
+
+// readSnapshotLine reads the next non-empty line of a snapshot. Lines are read in full, as records
+// may be larger than a bufio.Scanner's buffer.
+func readSnapshotLine(br *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := br.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
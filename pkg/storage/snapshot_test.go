@@ -0,0 +1,77 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+	"github.com/pomerium/pomerium/pkg/storage/inmemory"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	src := inmemory.New()
+	defer src.Close()
+	dst := inmemory.New()
+	defer dst.Close()
+
+	require.NoError(t, src.SetOptions(ctx, "TYPE-1", &databroker.Options{Capacity: proto.Uint64(1000)}))
+	var records []*databroker.Record
+	for i := 0; i < 250; i++ {
+		records = append(records, &databroker.Record{
+			Type: "TYPE-1",
+			Id:   fmt.Sprint(i),
+			Data: protoutil.NewAny(protoutil.NewStructString(fmt.Sprint(i))),
+		})
+	}
+	records = append(records,
+		&databroker.Record{Type: "TYPE-2", Id: "1", Data: protoutil.NewAny(protoutil.NewStructString("1"))},
+		&databroker.Record{Type: "TYPE-2", Id: "2", DeletedAt: timestamppb.Now()},
+	)
+	_, err := src.Put(ctx, records)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	count, err := storage.WriteSnapshot(ctx, &buf, src)
+	require.NoError(t, err)
+	assert.Equal(t, 251, count, "should skip deleted records")
+
+	count, err = storage.ReadSnapshot(ctx, dst, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 251, count)
+
+	for _, id := range []string{"0", "249"} {
+		record, err := dst.Get(ctx, "TYPE-1", id)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(protoutil.NewAny(protoutil.NewStructString(id)), record.GetData()))
+	}
+	_, err = dst.Get(ctx, "TYPE-2", "2")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	options, err := dst.GetOptions(ctx, "TYPE-1")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000), options.GetCapacity())
+
+	t.Run("unsupported version", func(t *testing.T) {
+		snapshot := strings.Replace(buf.String(), `"version":1`, `"version":2`, 1)
+		_, err := storage.ReadSnapshot(ctx, dst, strings.NewReader(snapshot))
+		assert.ErrorContains(t, err, "unsupported snapshot version")
+	})
+	t.Run("invalid header", func(t *testing.T) {
+		_, err := storage.ReadSnapshot(ctx, dst, strings.NewReader("{}\n"))
+		assert.Error(t, err)
+	})
+}
@@ -38,3 +38,31 @@ func TestMatchAny(t *testing.T) {
 	assert.True(t, MatchAny(data, "email"))
 	assert.False(t, MatchAny(data, "nope"))
 }
+
+func TestFallbackQuerier(t *testing.T) {
+	t.Parallel()
+
+	q := NewFallbackQuerier(
+		NewStaticQuerier(&user.User{Id: "u1", Name: "first"}),
+		NewStaticQuerier(&user.User{Id: "u1", Name: "second"}, &user.User{Id: "u2", Name: "second"}),
+	)
+
+	getName := func(id string) string {
+		req := &databroker.QueryRequest{
+			Type:  protoutil.GetTypeURL(new(user.User)),
+			Limit: 1,
+		}
+		req.SetFilterByID(id)
+		res, err := q.Query(context.Background(), req)
+		if !assert.NoError(t, err) || len(res.GetRecords()) == 0 {
+			return ""
+		}
+		var u user.User
+		assert.NoError(t, res.GetRecords()[0].GetData().UnmarshalTo(&u))
+		return u.GetName()
+	}
+
+	assert.Equal(t, "first", getName("u1"))
+	assert.Equal(t, "second", getName("u2"))
+	assert.Equal(t, "", getName("u3"))
+}
@@ -0,0 +1,109 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A TokenCache stores the session tokens of the user by route host.
+type TokenCache interface {
+	// LoadToken returns the token for the host, or an empty string if there is none.
+	LoadToken(ctx context.Context, host string) (string, error)
+	StoreToken(ctx context.Context, host, token string) error
+	DeleteToken(ctx context.Context, host string) error
+}
+
+type memoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewMemoryTokenCache creates a TokenCache which keeps the tokens in memory.
+func NewMemoryTokenCache() TokenCache {
+	return &memoryTokenCache{tokens: make(map[string]string)}
+}
+
+func (cache *memoryTokenCache) LoadToken(_ context.Context, host string) (string, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.tokens[host], nil
+}
+
+func (cache *memoryTokenCache) StoreToken(_ context.Context, host, token string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.tokens[host] = token
+	return nil
+}
+
+func (cache *memoryTokenCache) DeleteToken(_ context.Context, host string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	delete(cache.tokens, host)
+	return nil
+}
+
+type fileTokenCache struct {
+	dir string
+}
+
+// NewFileTokenCache creates a TokenCache which stores the tokens as files in the directory, so
+// they can be reused by later processes. If dir is empty the pomerium directory of the user's
+// cache directory is used.
+func NewFileTokenCache(dir string) TokenCache {
+	return &fileTokenCache{dir: dir}
+}
+
+func (cache *fileTokenCache) LoadToken(_ context.Context, host string) (string, error) {
+	path, err := cache.path(host)
+	if err != nil {
+		return "", err
+	}
+	bs, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return string(bs), err
+}
+
+func (cache *fileTokenCache) StoreToken(_ context.Context, host, token string) error {
+	path, err := cache.path(host)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token), 0o600)
+}
+
+func (cache *fileTokenCache) DeleteToken(_ context.Context, host string) error {
+	path, err := cache.path(host)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (cache *fileTokenCache) path(host string) (string, error) {
+	dir := cache.dir
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userCacheDir, "pomerium", "tcptunnel")
+	}
+	h := sha256.Sum256([]byte(host))
+	return filepath.Join(dir, hex.EncodeToString(h[:])+".jwt"), nil
+}
@@ -0,0 +1,60 @@
+package tcptunnel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// KubernetesExecCredential returns a client-go ExecCredential, in the given api version, for the
+// kubernetes route with the host, such as k8s.example.com for the route https://k8s.example.com.
+// The credential is a short-lived token for the route. The user is asked to log in if needed.
+func (t *Tunnel) KubernetesExecCredential(ctx context.Context, host, apiVersion string) ([]byte, error) {
+	client := t.newHTTPClient()
+	defer client.CloseIdleConnections()
+
+	var credential []byte
+	err := t.withToken(ctx, host, func(token string) error {
+		u := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     "/.pomerium/api/v1/kubernetes/exec-credential",
+			RawQuery: url.Values{"api_version": {apiVersion}}.Encode(),
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Pomerium "+token)
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		bs, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+		if err != nil {
+			return err
+		}
+		switch {
+		case res.StatusCode == http.StatusOK:
+			credential = bs
+			return nil
+		case res.StatusCode == http.StatusForbidden:
+			return ErrForbidden
+		case res.StatusCode == http.StatusUnauthorized,
+			res.StatusCode >= 300 && res.StatusCode < 400:
+			return errAuthenticationRequired
+		default:
+			return fmt.Errorf("tcptunnel: unexpected response from kubernetes route: %s: %s", res.Status, bytes.TrimSpace(bs))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
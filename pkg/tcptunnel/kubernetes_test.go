@@ -0,0 +1,59 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKubernetesExecCredential(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "k8s.example.com", r.Host)
+		assert.Equal(t, "/.pomerium/api/v1/kubernetes/exec-credential", r.URL.Path)
+		switch r.Header.Get("Authorization") {
+		case "Pomerium TOKEN":
+			_, _ = w.Write([]byte(`{"apiVersion":"` + r.FormValue("api_version") + `","kind":"ExecCredential"}`))
+		case "Pomerium DENIED":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	cache := NewMemoryTokenCache()
+	tun := New(
+		WithProxyAddress(srv.Listener.Addr().String()),
+		WithTLSConfig(&tls.Config{RootCAs: roots}),
+		WithTokenCache(cache),
+		WithBrowser(func(ctx context.Context, rawURL string) error {
+			t.Error("unexpected login")
+			return nil
+		}),
+	)
+
+	require.NoError(t, cache.StoreToken(ctx, "k8s.example.com", "TOKEN"))
+	credential, err := tun.KubernetesExecCredential(ctx, "k8s.example.com", "client.authentication.k8s.io/v1beta1")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"apiVersion":"client.authentication.k8s.io/v1beta1","kind":"ExecCredential"}`, string(credential))
+
+	require.NoError(t, cache.StoreToken(ctx, "k8s.example.com", "DENIED"))
+	_, err = tun.KubernetesExecCredential(ctx, "k8s.example.com", "")
+	assert.ErrorIs(t, err, ErrForbidden)
+}
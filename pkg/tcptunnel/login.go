@@ -0,0 +1,152 @@
+package tcptunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var errLogin = errors.New("tcptunnel: login failed")
+
+// login returns a new token for the host, replacing the stale token, if any. Only one login runs
+// at a time, so connections which need a token at the same time share it.
+func (t *Tunnel) login(ctx context.Context, host, staleToken string) (string, error) {
+	t.loginMu.Lock()
+	defer t.loginMu.Unlock()
+
+	// another connection may have logged in while waiting for the lock
+	token, err := t.cfg.tokenCache.LoadToken(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	if token != "" && token != staleToken {
+		return token, nil
+	}
+	if token != "" {
+		if err := t.cfg.tokenCache.DeleteToken(ctx, host); err != nil {
+			return "", err
+		}
+	}
+
+	token, err = t.loginWithBrowser(ctx, host)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errLogin, err)
+	}
+	return token, t.cfg.tokenCache.StoreToken(ctx, host, token)
+}
+
+// loginWithBrowser runs the programmatic login flow: pomerium returns a login url for a redirect
+// uri on a local callback server, and once the user logs in, the browser is redirected to the
+// callback server with the token.
+func (t *Tunnel) loginWithBrowser(ctx context.Context, host string) (string, error) {
+	li, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer li.Close()
+
+	tokens := make(chan string, 1)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("pomerium_jwt")
+			if token == "" {
+				http.Error(w, "missing pomerium_jwt", http.StatusBadRequest)
+				return
+			}
+			_, _ = io.WriteString(w, "Login successful, you may close this page.")
+			select {
+			case tokens <- token:
+			default:
+			}
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() { _ = srv.Serve(li) }()
+	defer srv.Close()
+
+	redirectURI := fmt.Sprintf("http://localhost:%d/", li.Addr().(*net.TCPAddr).Port)
+	loginURL, err := t.getLoginURL(ctx, host, redirectURI)
+	if err != nil {
+		return "", err
+	}
+	if err := t.cfg.openURL(ctx, loginURL); err != nil {
+		return "", err
+	}
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case token := <-tokens:
+		return token, nil
+	}
+}
+
+func (t *Tunnel) getLoginURL(ctx context.Context, host, redirectURI string) (string, error) {
+	client := t.newHTTPClient()
+	defer client.CloseIdleConnections()
+
+	u := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     "/.pomerium/api/v1/login",
+		RawQuery: url.Values{"pomerium_redirect_uri": {redirectURI}}.Encode(),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response from login api: %s", res.Status)
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+// newHTTPClient returns a client which sends requests to pomerium. Redirects aren't followed.
+func (t *Tunnel) newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialTLSContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return t.dialProxy(ctx, addr)
+			},
+		},
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// OpenBrowser opens the url in the user's browser.
+func OpenBrowser(ctx context.Context, rawURL string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "open", rawURL)
+	case "windows":
+		cmd = exec.CommandContext(ctx, "rundll32", "url.dll,FileProtocolHandler", rawURL)
+	default:
+		cmd = exec.CommandContext(ctx, "xdg-open", rawURL)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error opening browser, visit %s to log in: %w", rawURL, err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
@@ -0,0 +1,79 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"time"
+)
+
+const defaultMaxRetryDuration = time.Minute
+
+type config struct {
+	proxyAddress     string
+	tlsConfig        *tls.Config
+	tokenCache       TokenCache
+	openURL          func(ctx context.Context, rawURL string) error
+	maxRetryDuration time.Duration
+	logf             func(format string, args ...any)
+}
+
+// An Option modifies the config.
+type Option func(*config)
+
+// WithProxyAddress sets the address of pomerium in the config. By default the host of the route
+// is used on port 443.
+func WithProxyAddress(address string) Option {
+	return func(cfg *config) {
+		cfg.proxyAddress = address
+	}
+}
+
+// WithTLSConfig sets the tls.Config used to connect to pomerium in the config.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(cfg *config) {
+		cfg.tlsConfig = tlsConfig
+	}
+}
+
+// WithTokenCache sets the token cache in the config.
+func WithTokenCache(tokenCache TokenCache) Option {
+	return func(cfg *config) {
+		cfg.tokenCache = tokenCache
+	}
+}
+
+// WithBrowser sets the function used to open the login url in the config. By default the login
+// url is opened in the user's browser.
+func WithBrowser(openURL func(ctx context.Context, rawURL string) error) Option {
+	return func(cfg *config) {
+		cfg.openURL = openURL
+	}
+}
+
+// WithMaxRetryDuration sets how long Serve retries establishing a tunnel in the config.
+func WithMaxRetryDuration(maxRetryDuration time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxRetryDuration = maxRetryDuration
+	}
+}
+
+// WithLogger sets the function used to log errors in the config.
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(cfg *config) {
+		cfg.logf = logf
+	}
+}
+
+func getConfig(options ...Option) *config {
+	cfg := new(config)
+	WithTLSConfig(new(tls.Config))(cfg)
+	WithTokenCache(NewFileTokenCache(""))(cfg)
+	WithBrowser(OpenBrowser)(cfg)
+	WithMaxRetryDuration(defaultMaxRetryDuration)(cfg)
+	WithLogger(log.Printf)(cfg)
+	for _, o := range options {
+		o(cfg)
+	}
+	return cfg
+}
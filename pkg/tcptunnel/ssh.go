@@ -0,0 +1,81 @@
+package tcptunnel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// An SSHCertificate is a short-lived ssh user certificate issued by an ssh route.
+type SSHCertificate struct {
+	// Certificate is the certificate in authorized_keys format.
+	Certificate string `json:"certificate"`
+	// PrivateKey is the PEM encoded private key of the certificate, if pomerium generated an
+	// ephemeral key for it.
+	PrivateKey  string    `json:"privateKey,omitempty"`
+	Principals  []string  `json:"principals"`
+	ValidBefore time.Time `json:"validBefore"`
+}
+
+// IssueSSHCertificate asks the ssh route, such as bastion.example.com:22 for the route
+// ssh://bastion.example.com, for a certificate for the public key in authorized_keys format. If
+// the public key is empty, pomerium generates an ephemeral key. The user is asked to log in if
+// needed.
+func (t *Tunnel) IssueSSHCertificate(ctx context.Context, route string, publicKey []byte) (*SSHCertificate, error) {
+	host, _, err := net.SplitHostPort(route)
+	if err != nil {
+		return nil, fmt.Errorf("tcptunnel: invalid route %s: %w", route, err)
+	}
+
+	body, err := json.Marshal(map[string]string{"publicKey": string(publicKey)})
+	if err != nil {
+		return nil, err
+	}
+
+	client := t.newHTTPClient()
+	defer client.CloseIdleConnections()
+
+	var cert *SSHCertificate
+	err = t.withToken(ctx, host, func(token string) error {
+		u := url.URL{Scheme: "https", Host: host, Path: "/"}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		// the route is matched by its authority, so the port of the route is kept
+		req.Host = route
+		req.Header.Set("Authorization", "Pomerium "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		res, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+
+		switch {
+		case res.StatusCode == http.StatusOK:
+		case res.StatusCode == http.StatusForbidden:
+			return ErrForbidden
+		case res.StatusCode == http.StatusUnauthorized,
+			res.StatusCode >= 300 && res.StatusCode < 400:
+			return errAuthenticationRequired
+		default:
+			bs, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+			return fmt.Errorf("tcptunnel: unexpected response from ssh route: %s: %s", res.Status, bytes.TrimSpace(bs))
+		}
+
+		cert = new(SSHCertificate)
+		return json.NewDecoder(res.Body).Decode(cert)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
@@ -0,0 +1,68 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueSSHCertificate(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "bastion.example.com:22", r.Host)
+		switch r.Header.Get("Authorization") {
+		case "Pomerium TOKEN":
+			var req struct {
+				PublicKey string `json:"publicKey"`
+			}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "ssh-ed25519 AAAA", req.PublicKey)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"certificate": "ssh-ed25519-cert-v01@openssh.com AAAA",
+				"principals":  []string{"user"},
+			})
+		case "Pomerium DENIED":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	cache := NewMemoryTokenCache()
+	tun := New(
+		WithProxyAddress(srv.Listener.Addr().String()),
+		WithTLSConfig(&tls.Config{RootCAs: roots}),
+		WithTokenCache(cache),
+		WithBrowser(func(ctx context.Context, rawURL string) error {
+			t.Error("unexpected login")
+			return nil
+		}),
+	)
+
+	require.NoError(t, cache.StoreToken(ctx, "bastion.example.com", "TOKEN"))
+	cert, err := tun.IssueSSHCertificate(ctx, "bastion.example.com:22", []byte("ssh-ed25519 AAAA"))
+	require.NoError(t, err)
+	assert.Equal(t, "ssh-ed25519-cert-v01@openssh.com AAAA", cert.Certificate)
+	assert.Equal(t, []string{"user"}, cert.Principals)
+
+	require.NoError(t, cache.StoreToken(ctx, "bastion.example.com", "DENIED"))
+	_, err = tun.IssueSSHCertificate(ctx, "bastion.example.com:22", []byte("ssh-ed25519 AAAA"))
+	assert.ErrorIs(t, err, ErrForbidden)
+}
@@ -0,0 +1,235 @@
+// Package tcptunnel establishes authenticated TCP tunnels through pomerium tcp+https routes.
+//
+// It can be embedded by programs which need to reach internal services fronted by pomerium
+// without shelling out to a command line client. The tunnel sends an HTTP CONNECT request to
+// pomerium with the session token of the user. When there is no valid token, the user logs in
+// with the programmatic login flow in a browser and the token is cached for later tunnels.
+package tcptunnel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+var (
+	// ErrForbidden indicates the user is not authorized to use the route.
+	ErrForbidden = errors.New("tcptunnel: forbidden")
+	// ErrUnauthenticated indicates the user still wasn't authenticated after logging in.
+	ErrUnauthenticated = errors.New("tcptunnel: unauthenticated")
+)
+
+// A Tunnel dials TCP routes through pomerium. It's safe for concurrent use.
+type Tunnel struct {
+	cfg *config
+
+	// loginMu ensures the user is only asked to log in once when several connections need a
+	// token at the same time
+	loginMu sync.Mutex
+}
+
+// New creates a new Tunnel.
+func New(options ...Option) *Tunnel {
+	return &Tunnel{cfg: getConfig(options...)}
+}
+
+// Dial establishes a tunnel to the given tcp route, such as redis.example.com:6379 for the route
+// tcp+https://redis.example.com:6379. The user is asked to log in if needed.
+func (t *Tunnel) Dial(ctx context.Context, route string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(route)
+	if err != nil {
+		return nil, fmt.Errorf("tcptunnel: invalid route %s: %w", route, err)
+	}
+
+	var conn net.Conn
+	err = t.withToken(ctx, host, func(token string) error {
+		conn, err = t.connect(ctx, route, token)
+		return err
+	})
+	return conn, err
+}
+
+// withToken calls fn with the token of the user for the host. If pomerium requires the user to
+// authenticate, the user logs in and fn is called again with the new token.
+func (t *Tunnel) withToken(ctx context.Context, host string, fn func(token string) error) error {
+	token, err := t.cfg.tokenCache.LoadToken(ctx, host)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		if token, err = t.login(ctx, host, ""); err != nil {
+			return err
+		}
+	}
+
+	err = fn(token)
+	if !errors.Is(err, errAuthenticationRequired) {
+		return err
+	}
+
+	// the cached token expired or was revoked
+	if token, err = t.login(ctx, host, token); err != nil {
+		return err
+	}
+	err = fn(token)
+	if errors.Is(err, errAuthenticationRequired) {
+		return ErrUnauthenticated
+	}
+	return err
+}
+
+// ListenAndServe listens on the local address and tunnels every connection to the route.
+func (t *Tunnel) ListenAndServe(ctx context.Context, localAddress, route string) error {
+	var lc net.ListenConfig
+	li, err := lc.Listen(ctx, "tcp", localAddress)
+	if err != nil {
+		return err
+	}
+	return t.Serve(ctx, li, route)
+}
+
+// Serve tunnels every connection accepted by the listener to the route until the context is
+// canceled. Tunnels which fail to be established are retried with backoff, so that local
+// connections survive pomerium restarts and network interruptions.
+func (t *Tunnel) Serve(ctx context.Context, li net.Listener, route string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		_ = li.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		local, err := li.Accept()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer local.Close()
+
+			remote, err := t.dialWithRetry(ctx, route)
+			if err != nil {
+				t.cfg.logf("tcptunnel: error establishing tunnel to %s: %v", route, err)
+				return
+			}
+			defer remote.Close()
+			pipe(ctx, local, remote)
+		}()
+	}
+}
+
+func (t *Tunnel) dialWithRetry(ctx context.Context, route string) (net.Conn, error) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = t.cfg.maxRetryDuration
+	return backoff.RetryWithData(func() (net.Conn, error) {
+		conn, err := t.Dial(ctx, route)
+		if errors.Is(err, ErrForbidden) || errors.Is(err, ErrUnauthenticated) || errors.Is(err, errLogin) {
+			return nil, backoff.Permanent(err)
+		}
+		return conn, err
+	}, backoff.WithContext(bo, ctx))
+}
+
+var errAuthenticationRequired = errors.New("tcptunnel: authentication required")
+
+func (t *Tunnel) connect(ctx context.Context, route, token string) (net.Conn, error) {
+	conn, err := t.dialProxy(ctx, route)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: route},
+		Host:   route,
+		Header: http.Header{"Authorization": {"Pomerium " + token}},
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = res.Body.Close()
+	_ = conn.SetDeadline(time.Time{})
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+		return &bufferedConn{Conn: conn, r: br}, nil
+	case res.StatusCode == http.StatusForbidden:
+		err = ErrForbidden
+	case res.StatusCode == http.StatusUnauthorized,
+		res.StatusCode == http.StatusProxyAuthRequired,
+		res.StatusCode >= 300 && res.StatusCode < 400:
+		err = errAuthenticationRequired
+	default:
+		err = fmt.Errorf("tcptunnel: unexpected response to connect: %s", res.Status)
+	}
+	_ = conn.Close()
+	return nil, err
+}
+
+// dialProxy opens a TLS connection to pomerium for the route.
+func (t *Tunnel) dialProxy(ctx context.Context, route string) (net.Conn, error) {
+	host, _, _ := net.SplitHostPort(route)
+
+	address := t.cfg.proxyAddress
+	if address == "" {
+		address = net.JoinHostPort(host, "443")
+	}
+	tlsConfig := t.cfg.tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	d := &tls.Dialer{Config: tlsConfig}
+	return d.DialContext(ctx, "tcp", address)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func pipe(ctx context.Context, local, remote net.Conn) {
+	errc := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(remote, local)
+		errc <- err
+	}()
+	go func() {
+		_, err := io.Copy(local, remote)
+		errc <- err
+	}()
+	select {
+	case <-ctx.Done():
+	case <-errc:
+	}
+}
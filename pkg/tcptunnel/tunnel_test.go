@@ -0,0 +1,107 @@
+package tcptunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTunnel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/.pomerium/api/v1/login":
+			_, _ = io.WriteString(w, srv.URL+"/login?"+url.Values{
+				"redirect": {r.FormValue("pomerium_redirect_uri")},
+			}.Encode())
+		case r.URL.Path == "/login":
+			http.Redirect(w, r, r.FormValue("redirect")+"?pomerium_jwt=TOKEN", http.StatusFound)
+		case r.Method == http.MethodConnect && r.Header.Get("Authorization") != "Pomerium TOKEN":
+			http.Redirect(w, r, srv.URL+"/login", http.StatusFound)
+		case r.Method == http.MethodConnect:
+			assert.Equal(t, "example.com:6379", r.Host)
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			defer conn.Close()
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+			_, _ = io.Copy(conn, rw)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	var logins atomic.Int32
+	cache := NewMemoryTokenCache()
+	require.NoError(t, cache.StoreToken(ctx, "example.com", "EXPIRED"))
+	tun := New(
+		WithProxyAddress(srv.Listener.Addr().String()),
+		WithTLSConfig(&tls.Config{RootCAs: roots}),
+		WithTokenCache(cache),
+		WithBrowser(func(ctx context.Context, rawURL string) error {
+			logins.Add(1)
+			res, err := srv.Client().Get(rawURL)
+			if err != nil {
+				return err
+			}
+			return res.Body.Close()
+		}),
+	)
+
+	for i := 0; i < 2; i++ {
+		conn, err := tun.Dial(ctx, "example.com:6379")
+		require.NoError(t, err)
+		_, err = io.WriteString(conn, "PING")
+		require.NoError(t, err)
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+		assert.Equal(t, "PING", string(buf))
+		require.NoError(t, conn.Close())
+	}
+	assert.Equal(t, int32(1), logins.Load(), "should only log in once the cached token expired")
+
+	token, err := cache.LoadToken(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "TOKEN", token)
+}
+
+func TestFileTokenCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache := NewFileTokenCache(t.TempDir())
+
+	token, err := cache.LoadToken(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	require.NoError(t, cache.StoreToken(ctx, "example.com", "TOKEN"))
+	token, err = cache.LoadToken(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "TOKEN", token)
+
+	require.NoError(t, cache.DeleteToken(ctx, "example.com"))
+	require.NoError(t, cache.DeleteToken(ctx, "example.com"))
+	token, err = cache.LoadToken(ctx, "example.com")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
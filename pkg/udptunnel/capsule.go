@@ -0,0 +1,127 @@
+// Package udptunnel contains the framing used to tunnel UDP datagrams over an HTTP CONNECT stream.
+//
+// Datagrams are sent as HTTP Datagram capsules (RFC 9297) with a context ID of 0, as
+// described by the CONNECT-UDP specification (RFC 9298). Other capsule types are ignored.
+package udptunnel
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxDatagramSize is the maximum size of a UDP payload.
+const MaxDatagramSize = 65527
+
+const (
+	capsuleTypeDatagram = 0x00
+	contextIDUDP        = 0x00
+)
+
+// ErrDatagramTooLarge indicates a datagram exceeded the maximum datagram size.
+var ErrDatagramTooLarge = errors.New("udptunnel: datagram too large")
+
+// WriteDatagram writes a UDP payload to w as a datagram capsule.
+func WriteDatagram(w io.Writer, payload []byte) error {
+	if len(payload) > MaxDatagramSize {
+		return ErrDatagramTooLarge
+	}
+
+	buf := make([]byte, 0, len(payload)+16)
+	buf = appendVarint(buf, capsuleTypeDatagram)
+	buf = appendVarint(buf, uint64(len(payload)+1))
+	buf = appendVarint(buf, contextIDUDP)
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadDatagram reads the next UDP payload from r, skipping any other capsules.
+func ReadDatagram(r *bufio.Reader) ([]byte, error) {
+	for {
+		capsuleType, err := readVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		length, err := readVarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if length > MaxDatagramSize+8 {
+			return nil, ErrDatagramTooLarge
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if capsuleType != capsuleTypeDatagram {
+			continue
+		}
+
+		contextID, n, err := parseVarint(data)
+		if err != nil {
+			return nil, err
+		}
+		// only context id 0 is used for UDP payloads
+		if contextID != contextIDUDP {
+			continue
+		}
+		return data[n:], nil
+	}
+}
+
+// appendVarint appends a QUIC variable-length integer (RFC 9000, section 16).
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	case v < 1<<30:
+		return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return append(b, byte(v>>56)|0xc0, byte(v>>48), byte(v>>40), byte(v>>32),
+			byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+func readVarint(r *bufio.Reader) (uint64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	length := 1 << (first >> 6)
+	v := uint64(first & 0x3f)
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, unexpectedEOF(err)
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func parseVarint(b []byte) (v uint64, n int, err error) {
+	if len(b) == 0 {
+		return 0, 0, fmt.Errorf("udptunnel: invalid varint")
+	}
+	length := 1 << (b[0] >> 6)
+	if len(b) < length {
+		return 0, 0, fmt.Errorf("udptunnel: invalid varint")
+	}
+	v = uint64(b[0] & 0x3f)
+	for i := 1; i < length; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, length, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package udptunnel
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatagrams(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDatagram(&buf, []byte("hello")))
+	// an unknown capsule type, which should be skipped
+	buf.Write([]byte{0x3f, 0x02, 0xaa, 0xbb})
+	require.NoError(t, WriteDatagram(&buf, bytes.Repeat([]byte{'x'}, 1000)))
+	require.NoError(t, WriteDatagram(&buf, nil))
+
+	r := bufio.NewReader(&buf)
+	payload, err := ReadDatagram(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), payload)
+
+	payload, err = ReadDatagram(r)
+	require.NoError(t, err)
+	assert.Len(t, payload, 1000)
+
+	payload, err = ReadDatagram(r)
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+
+	_, err = ReadDatagram(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDatagramTooLarge(t *testing.T) {
+	t.Parallel()
+
+	err := WriteDatagram(io.Discard, make([]byte, MaxDatagramSize+1))
+	assert.ErrorIs(t, err, ErrDatagramTooLarge)
+}
+
+func TestVarint(t *testing.T) {
+	t.Parallel()
+
+	for _, v := range []uint64{0, 63, 64, 16383, 16384, 1<<30 - 1, 1 << 30, 1<<62 - 1} {
+		b := appendVarint(nil, v)
+		actual, err := readVarint(bufio.NewReader(bytes.NewReader(b)))
+		require.NoError(t, err)
+		assert.Equal(t, v, actual)
+
+		actual, n, err := parseVarint(b)
+		require.NoError(t, err)
+		assert.Equal(t, v, actual)
+		assert.Equal(t, len(b), n)
+	}
+}
@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pomerium/csrf"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/handlers"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/middleware"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/grpc/databroker"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/protoutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const adminConsoleSessionsLimit = 100
+
+// registerAdminConsoleHandlers registers the admin console handlers. Requests to the admin console
+// are authorized by envoy using the admin console policy.
+func (p *Proxy) registerAdminConsoleHandlers(r *mux.Router) {
+	a := r.PathPrefix("/admin").Subrouter()
+	a.Use(func(next http.Handler) http.Handler {
+		return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			// without a policy the requests are routed to the dashboard without authorization
+			if !p.currentOptions.Load().AdminConsole.IsEnabled() {
+				return httputil.NewError(http.StatusNotFound, errors.New("admin console is not enabled"))
+			}
+			next.ServeHTTP(w, r)
+			return nil
+		})
+	})
+	a.Path("/").Handler(httputil.HandlerFunc(p.adminConsole)).Methods(http.MethodGet)
+	a.Path("/revoke_session").Handler(httputil.HandlerFunc(p.adminConsoleRevokeSession)).Methods(http.MethodPost)
+	p.registerManagementAPIHandlers(a)
+}
+
+func (p *Proxy) adminConsole(w http.ResponseWriter, r *http.Request) error {
+	options := p.currentOptions.Load()
+	state := p.state.Load()
+
+	data := handlers.AdminConsoleData{
+		CSRFToken:       csrf.Token(r),
+		SessionsLimit:   adminConsoleSessionsLimit,
+		BrandingOptions: &options.Theme,
+	}
+
+	policies := options.GetAllPolicies()
+	for i := range policies {
+		data.Routes = append(data.Routes, getAdminConsoleRoute(&policies[i]))
+	}
+
+	data.SessionsOffset, _ = strconv.Atoi(r.FormValue("sessions_offset"))
+	if data.SessionsOffset < 0 {
+		data.SessionsOffset = 0
+	}
+	sessions, totalCount, err := listSessions(r.Context(), state.dataBrokerClient, data.SessionsOffset, adminConsoleSessionsLimit)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error listing sessions: %w", err))
+	}
+	data.SessionsTotalCount = totalCount
+	revokeURL := urlutil.GetAbsoluteURL(r).ResolveReference(&url.URL{Path: config.AdminConsolePath + "revoke_session"})
+	for _, s := range sessions {
+		u := *revokeURL
+		u.RawQuery = url.Values{urlutil.QuerySessionID: {s.GetId()}}.Encode()
+		data.Sessions = append(data.Sessions, handlers.UserSession{
+			Session:   s,
+			RevokeURL: urlutil.NewSignedURL(state.sharedKey, &u).String(),
+		})
+	}
+
+	if testURL := r.FormValue("test_url"); testURL != "" {
+		data.PolicyTest = p.testAdminConsolePolicy(r.Context(), options,
+			r.FormValue("test_method"), testURL, r.FormValue("test_session_id"))
+	}
+
+	handlers.AdminConsole(data).ServeHTTP(w, r)
+	return nil
+}
+
+// adminConsoleRevokeSession revokes any session.
+func (p *Proxy) adminConsoleRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+
+	// the revoke session url is signed, which also protects against cross-site requests
+	if err := middleware.ValidateRequestURL(r, state.sharedKey); err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	sessionID := r.FormValue(urlutil.QuerySessionID)
+	if err := session.Delete(r.Context(), state.dataBrokerClient, sessionID); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking session: %w", err))
+	}
+
+	httputil.Redirect(w, r, config.AdminConsolePath, http.StatusFound)
+	return nil
+}
+
+// testAdminConsolePolicy evaluates the policy of the route matching the url for a hypothetical
+// request made with the given session.
+func (p *Proxy) testAdminConsolePolicy(
+	ctx context.Context,
+	options *config.Options,
+	method, rawURL, sessionID string,
+) *handlers.AdminConsolePolicyTest {
+	if method == "" {
+		method = http.MethodGet
+	}
+	result := &handlers.AdminConsolePolicyTest{
+		Method:    method,
+		URL:       rawURL,
+		SessionID: sessionID,
+	}
+
+	u, err := urlutil.ParseAndValidateURL(rawURL)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	policy := getPolicyForURL(options, u)
+	if policy == nil {
+		result.Error = "no route matches the url"
+		return result
+	}
+	result.RouteID = getAdminConsoleRoute(policy).ID
+
+	ctx = storage.WithQuerier(ctx, storage.NewQuerier(p.state.Load().dataBrokerClient))
+	res, err := evaluator.EvaluatePolicy(ctx, policy, &evaluator.PolicyRequest{
+		HTTP:                     evaluator.NewRequestHTTP(method, *u, map[string]string{}, evaluator.ClientCertificateInfo{}, ""),
+		Session:                  evaluator.RequestSession{ID: sessionID},
+		IsValidClientCertificate: true,
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Allow = res.Allow.Value
+	result.Deny = res.Deny.Value
+	result.Reasons = append(res.Allow.Reasons.Strings(), res.Deny.Reasons.Strings()...)
+	result.Traces = res.Traces
+	return result
+}
+
+// getPolicyForURL returns the first route matching the url.
+func getPolicyForURL(options *config.Options, u *url.URL) *config.Policy {
+	policies := options.GetAllPolicies()
+	for i := range policies {
+		if policies[i].Matches(*u) {
+			return &policies[i]
+		}
+	}
+	return nil
+}
+
+func getAdminConsoleRoute(policy *config.Policy) handlers.AdminConsoleRoute {
+	r := handlers.AdminConsoleRoute{
+		From:   policy.From,
+		Prefix: policy.Prefix,
+		Path:   policy.Path,
+		Regex:  policy.Regex,
+	}
+	if id, err := policy.RouteID(); err == nil {
+		r.ID = strconv.FormatUint(id, 10)
+	}
+	for _, to := range policy.To {
+		r.To = append(r.To, to.URL.String())
+	}
+	return r
+}
+
+func listSessions(
+	ctx context.Context,
+	client databroker.DataBrokerServiceClient,
+	offset, limit int,
+) (sessions []*session.Session, totalCount int, err error) {
+	res, err := client.Query(ctx, &databroker.QueryRequest{
+		Type:   protoutil.NewAny(new(session.Session)).GetTypeUrl(),
+		Offset: int64(offset),
+		Limit:  int64(limit),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, record := range res.GetRecords() {
+		var s session.Session
+		if err := record.GetData().UnmarshalTo(&s); err != nil {
+			return nil, 0, fmt.Errorf("error unmarshaling session from databroker: %w", err)
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, int(res.GetTotalCount()), nil
+}
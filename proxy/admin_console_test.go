@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+func TestProxy_adminConsoleDisabled(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(&config.Config{Options: testOptions(t)})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	p.Mount(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://corp.example.example/.pomerium/admin/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestProxy_testAdminConsolePolicy(t *testing.T) {
+	t.Parallel()
+
+	opts := testOptions(t)
+	p, err := New(&config.Config{Options: opts})
+	require.NoError(t, err)
+
+	result := p.testAdminConsolePolicy(context.Background(), opts, "", "https://unknown.example.example/path", "S1")
+	assert.Equal(t, http.MethodGet, result.Method)
+	assert.Equal(t, "no route matches the url", result.Error)
+
+	result = p.testAdminConsolePolicy(context.Background(), opts, http.MethodPost, "not a url", "")
+	assert.NotEmpty(t, result.Error)
+}
+
+func TestGetAdminConsoleRoute(t *testing.T) {
+	t.Parallel()
+
+	to, err := config.ParseWeightedUrls("https://to.example.com")
+	require.NoError(t, err)
+
+	policy := &config.Policy{
+		From:   "https://from.example.com",
+		To:     to,
+		Prefix: "/api",
+	}
+	r := getAdminConsoleRoute(policy)
+	assert.NotEmpty(t, r.ID)
+	assert.Equal(t, "https://from.example.com", r.From)
+	assert.Equal(t, []string{"https://to.example.com"}, r.To)
+	assert.Equal(t, "/api", r.Prefix)
+}
@@ -0,0 +1,348 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/sessions/header"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+// agentEventsInterval is how often the agent events stream checks whether the agent session was
+// revoked. An event is sent every interval, so the stream also acts as a keep alive.
+var agentEventsInterval = 10 * time.Second
+
+var errAgentSessionRevoked = errors.New("agent session revoked")
+
+// registerAgentAPIHandlers registers the desktop agent api handlers.
+//
+// A desktop agent creates an agent session from a session obtained with the programmatic login
+// flow, binding it to a device key. The agent then refreshes the session, and mints new session
+// tokens, by proving possession of the device key, rather than asking the user to log in in a
+// browser for every tunnel. The events stream notifies the agent as soon as its session is
+// revoked.
+func (p *Proxy) registerAgentAPIHandlers(r *mux.Router) {
+	r.Path("/v1/agent/sessions").Handler(httputil.HandlerFunc(p.agentAPICreateSession)).Methods(http.MethodPost)
+	r.Path("/v1/agent/sessions/{id}").Handler(httputil.HandlerFunc(p.agentAPIRevokeSession)).Methods(http.MethodDelete)
+	r.Path("/v1/agent/sessions/{id}/refresh").Handler(httputil.HandlerFunc(p.agentAPIRefreshSession)).Methods(http.MethodPost)
+	r.Path("/v1/agent/sessions/{id}/events").Handler(httputil.HandlerFunc(p.agentAPIEvents)).Methods(http.MethodGet)
+}
+
+type agentSessionResponse struct {
+	ID               string    `json:"id"`
+	ExpiresAt        time.Time `json:"expiresAt"`
+	JWT              string    `json:"jwt,omitempty"`
+	SessionExpiresAt time.Time `json:"sessionExpiresAt"`
+}
+
+type agentEvent struct {
+	Type             string     `json:"type"`
+	SessionExpiresAt *time.Time `json:"sessionExpiresAt,omitempty"`
+	Reason           string     `json:"reason,omitempty"`
+}
+
+// agentAPICreateSession creates an agent session bound to a device key from the session in the
+// authorization header.
+func (p *Proxy) agentAPICreateSession(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	if options.StatelessSessions {
+		return httputil.NewError(http.StatusNotImplemented, errors.New("agent sessions cannot be used with stateless sessions"))
+	}
+
+	ss, s, err := p.getAuthorizationSession(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		DeviceID  string `json:"deviceId"`
+		PublicKey []byte `json:"publicKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid agent session: %w", err))
+	}
+
+	now := time.Now()
+	agentSession := &session.AgentSession{
+		ID:                 uuid.NewString(),
+		UserID:             s.GetUserId(),
+		SessionID:          s.GetId(),
+		IdentityProviderID: ss.IdentityProviderID,
+		DeviceID:           req.DeviceID,
+		PublicKey:          req.PublicKey,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(options.GetAgentSessionTTL()),
+	}
+	if err := agentSession.Validate(); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid agent session: %w", err))
+	}
+	if err := session.PutAgentSession(r.Context(), state.dataBrokerClient, agentSession); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving agent session: %w", err))
+	}
+	audit.Record(r.Context(), audit.Event{
+		Type:      audit.EventAgentSessionCreated,
+		UserID:    agentSession.UserID,
+		SessionID: agentSession.SessionID,
+		Details:   map[string]string{"agent_session_id": agentSession.ID, "device_id": agentSession.DeviceID},
+	})
+
+	httputil.RenderJSON(w, http.StatusCreated, agentSessionResponse{
+		ID:               agentSession.ID,
+		ExpiresAt:        agentSession.ExpiresAt,
+		SessionExpiresAt: s.GetExpiresAt().AsTime(),
+	})
+	return nil
+}
+
+// agentAPIRefreshSession extends the session of an agent session and returns a new session token.
+func (p *Proxy) agentAPIRefreshSession(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	agentSession, s, err := p.getAgentSessionWithProof(r)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(options.CookieExpire)
+	if expiresAt.After(agentSession.ExpiresAt) {
+		expiresAt = agentSession.ExpiresAt
+	}
+	if expiresAt.After(s.GetExpiresAt().AsTime()) {
+		s.ExpiresAt = timestamppb.New(expiresAt)
+	}
+	res, err := session.Put(r.Context(), state.dataBrokerClient, s)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving session: %w", err))
+	}
+
+	ss := sessions.State{
+		Subject:                 agentSession.UserID,
+		IssuedAt:                jwt.NewNumericDate(time.Now()),
+		ID:                      s.GetId(),
+		IdentityProviderID:      agentSession.IdentityProviderID,
+		DatabrokerServerVersion: res.GetServerVersion(),
+		DatabrokerRecordVersion: res.GetRecord().GetVersion(),
+	}
+	rawJWT, err := state.encoder.Marshal(ss)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error marshaling session state: %w", err))
+	}
+
+	httputil.RenderJSON(w, http.StatusOK, agentSessionResponse{
+		ID:               agentSession.ID,
+		ExpiresAt:        agentSession.ExpiresAt,
+		JWT:              string(rawJWT),
+		SessionExpiresAt: s.GetExpiresAt().AsTime(),
+	})
+	return nil
+}
+
+// agentAPIRevokeSession revokes an agent session and the session it was created from. It's
+// authorized with either a proof of the agent or a session of the same user.
+func (p *Proxy) agentAPIRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+
+	var agentSession *session.AgentSession
+	if r.Header.Get(httputil.HeaderPomeriumAgentProof) != "" {
+		var err error
+		agentSession, _, err = p.getAgentSessionWithProof(r)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, s, err := p.getAuthorizationSession(r)
+		if err != nil {
+			return err
+		}
+		agentSession, err = session.GetAgentSession(r.Context(), state.dataBrokerClient, mux.Vars(r)["id"])
+		if status.Code(err) == codes.NotFound || (err == nil && agentSession.UserID != s.GetUserId()) {
+			return httputil.NewError(http.StatusNotFound, errors.New("agent session not found"))
+		} else if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+	}
+
+	if err := p.revokeAgentSession(r.Context(), agentSession); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking agent session: %w", err))
+	}
+	audit.Record(r.Context(), audit.Event{
+		Type:      audit.EventSessionRevoked,
+		UserID:    agentSession.UserID,
+		SessionID: agentSession.SessionID,
+		Details:   map[string]string{"method": "agent_api", "agent_session_id": agentSession.ID},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// agentAPIEvents streams the events of an agent session as newline delimited json. A session
+// event is sent when the expiry of the session changes, and a revoked event is sent, ending the
+// stream, when the agent session or its session is revoked or expires.
+func (p *Proxy) agentAPIEvents(w http.ResponseWriter, r *http.Request) error {
+	agentSession, s, err := p.getAgentSessionWithProof(r)
+	if err != nil {
+		return err
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return httputil.NewError(http.StatusInternalServerError, errors.New("streaming is not supported"))
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+
+	ticker := time.NewTicker(agentEventsInterval)
+	defer ticker.Stop()
+
+	var sessionExpiresAt time.Time
+	for {
+		event := agentEvent{Type: "ping"}
+		if err != nil {
+			event = agentEvent{Type: "revoked", Reason: err.Error()}
+		} else if expiresAt := s.GetExpiresAt().AsTime(); !expiresAt.Equal(sessionExpiresAt) {
+			sessionExpiresAt = expiresAt
+			event = agentEvent{Type: "session", SessionExpiresAt: &expiresAt}
+		}
+		if err := enc.Encode(event); err != nil {
+			return nil
+		}
+		flusher.Flush()
+		if event.Type == "revoked" {
+			return nil
+		}
+
+		select {
+		case <-r.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+		current, checkErr := p.getAgentSessionSession(r.Context(), agentSession.ID)
+		switch {
+		case checkErr == nil:
+			s = current
+		case errors.Is(checkErr, errAgentSessionRevoked):
+			err = checkErr
+		default:
+			// keep the stream open on transient errors, the agent session may still be valid
+			log.Warn(r.Context()).Err(checkErr).Msg("proxy: error checking agent session")
+		}
+	}
+}
+
+// getAuthorizationSession returns the session in the authorization header of the request, for apis
+// used by clients of the programmatic login flow.
+func (p *Proxy) getAuthorizationSession(r *http.Request) (*sessions.State, *session.Session, error) {
+	state := p.state.Load()
+
+	rawJWT := header.TokenFromHeaders(r)
+	if rawJWT == "" {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, errors.New("a pomerium session token is required"))
+	}
+	var ss sessions.State
+	if err := state.encoder.Unmarshal([]byte(rawJWT), &ss); err != nil {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, err)
+	}
+	if ss.IsExpired(time.Now()) {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, errors.New("the pomerium session token expired"))
+	}
+
+	s, isImpersonated, err := p.getSession(r.Context(), ss.ID)
+	if err != nil {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, err)
+	}
+	if isImpersonated {
+		return nil, nil, httputil.NewError(http.StatusForbidden, errors.New("impersonated sessions cannot be used with the api"))
+	}
+	if err := s.Validate(); err != nil {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, err)
+	}
+	return &ss, s, nil
+}
+
+// getAgentSessionWithProof returns the agent session of the request, and its session, after
+// verifying the agent's proof of possession of its device key.
+func (p *Proxy) getAgentSessionWithProof(r *http.Request) (*session.AgentSession, *session.Session, error) {
+	client := p.state.Load().dataBrokerClient
+
+	agentSession, err := session.GetAgentSession(r.Context(), client, mux.Vars(r)["id"])
+	if status.Code(err) == codes.NotFound {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, errAgentSessionRevoked)
+	} else if err != nil {
+		return nil, nil, httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if err := agentSession.VerifyProof(r.Header.Get(httputil.HeaderPomeriumAgentProof), time.Now()); err != nil {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, err)
+	}
+
+	s, err := p.getAgentSessionSession(r.Context(), agentSession.ID)
+	if errors.Is(err, errAgentSessionRevoked) {
+		return nil, nil, httputil.NewError(http.StatusUnauthorized, err)
+	} else if err != nil {
+		return nil, nil, httputil.NewError(http.StatusInternalServerError, err)
+	}
+	return agentSession, s, nil
+}
+
+// getAgentSessionSession returns the session of an agent session. If either was revoked or
+// expired, the agent session is deleted and errAgentSessionRevoked is returned.
+func (p *Proxy) getAgentSessionSession(ctx context.Context, agentSessionID string) (*session.Session, error) {
+	client := p.state.Load().dataBrokerClient
+
+	agentSession, err := session.GetAgentSession(ctx, client, agentSessionID)
+	if status.Code(err) == codes.NotFound {
+		return nil, errAgentSessionRevoked
+	} else if err != nil {
+		return nil, err
+	}
+	if time.Now().After(agentSession.ExpiresAt) {
+		return nil, p.deleteRevokedAgentSession(ctx, agentSession.ID, "agent session expired")
+	}
+
+	s, err := session.Get(ctx, client, agentSession.SessionID)
+	if status.Code(err) == codes.NotFound {
+		return nil, p.deleteRevokedAgentSession(ctx, agentSession.ID, "session revoked")
+	} else if err != nil {
+		return nil, err
+	}
+	if expiresAt := s.GetExpiresAt(); expiresAt.IsValid() && time.Now().After(expiresAt.AsTime()) {
+		return nil, p.deleteRevokedAgentSession(ctx, agentSession.ID, "session expired")
+	}
+	return s, nil
+}
+
+func (p *Proxy) deleteRevokedAgentSession(ctx context.Context, agentSessionID, reason string) error {
+	client := p.state.Load().dataBrokerClient
+	if err := session.DeleteAgentSession(ctx, client, agentSessionID); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s", errAgentSessionRevoked, reason)
+}
+
+func (p *Proxy) revokeAgentSession(ctx context.Context, agentSession *session.AgentSession) error {
+	client := p.state.Load().dataBrokerClient
+	if err := session.DeleteAgentSession(ctx, client, agentSession.ID); err != nil {
+		return err
+	}
+	return session.Delete(ctx, client, agentSession.SessionID)
+}
@@ -0,0 +1,27 @@
+package proxy
+
+import (
+	"github.com/pomerium/pomerium/config"
+)
+
+type proxyConfig struct {
+	configReloader config.Reloader
+}
+
+// An Option customizes the Proxy config.
+type Option func(*proxyConfig)
+
+func getProxyConfig(options ...Option) *proxyConfig {
+	cfg := new(proxyConfig)
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+// WithConfigReloader sets the config reloader used by the management api to reload the configuration.
+func WithConfigReloader(reloader config.Reloader) Option {
+	return func(cfg *proxyConfig) {
+		cfg.configReloader = reloader
+	}
+}
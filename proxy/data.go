@@ -10,7 +10,9 @@ import (
 	"github.com/pomerium/pomerium/internal/handlers"
 	"github.com/pomerium/pomerium/internal/handlers/webauthn"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/throttle"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/session"
@@ -63,7 +65,7 @@ func (p *Proxy) getUserInfoData(r *http.Request) (handlers.UserInfoData, error)
 
 	data := handlers.UserInfoData{
 		CSRFToken:       csrf.Token(r),
-		BrandingOptions: options.BrandingOptions,
+		BrandingOptions: &options.Theme,
 	}
 
 	ss, err := p.getSessionState(r)
@@ -81,12 +83,41 @@ func (p *Proxy) getUserInfoData(r *http.Request) (handlers.UserInfoData, error)
 		data.User = &user.User{Id: data.Session.GetUserId()}
 	}
 
+	p.fillUserSessionsInfoData(r, &data)
+
 	data.WebAuthnCreationOptions, data.WebAuthnRequestOptions, _ = p.webauthn.GetOptions(r)
 	data.WebAuthnURL = urlutil.WebAuthnURL(r, urlutil.GetAbsoluteURL(r), state.sharedKey, r.URL.Query())
 	p.fillEnterpriseUserInfoData(r.Context(), &data)
 	return data, nil
 }
 
+func (p *Proxy) fillUserSessionsInfoData(r *http.Request, data *handlers.UserInfoData) {
+	state := p.state.Load()
+	userID := data.Session.GetUserId()
+	if userID == "" {
+		return
+	}
+
+	sessions, err := session.GetUserSessions(r.Context(), state.dataBrokerClient, userID)
+	if err != nil {
+		log.Warn(r.Context()).Err(err).Msg("proxy: error retrieving user sessions")
+	}
+	baseURL := urlutil.GetAbsoluteURL(r)
+	for _, s := range sessions {
+		us := handlers.UserSession{Session: s}
+		// sessions can't be revoked on behalf of an impersonated user
+		if !data.IsImpersonated {
+			us.RevokeURL = urlutil.RevokeSessionURL(baseURL, state.sharedKey, s.GetId())
+		}
+		data.Sessions = append(data.Sessions, us)
+	}
+
+	data.AccessDecisions, err = user.GetAccessDecisions(r.Context(), state.dataBrokerClient, userID)
+	if err != nil {
+		log.Warn(r.Context()).Err(err).Msg("proxy: error retrieving user access decisions")
+	}
+}
+
 func (p *Proxy) fillEnterpriseUserInfoData(ctx context.Context, data *handlers.UserInfoData) {
 	client := p.state.Load().dataBrokerClient
 
@@ -140,6 +171,7 @@ func (p *Proxy) getWebauthnState(r *http.Request) (*webauthn.State, error) {
 		SessionState:            &ss,
 		SessionStore:            state.sessionStore,
 		RelyingParty:            webauthnutil.GetRelyingParty(r, state.dataBrokerClient),
-		BrandingOptions:         options.BrandingOptions,
+		BrandingOptions:         &options.Theme,
+		Throttle:                throttle.New(state.dataBrokerClient, options.AuthThrottle),
 	}, nil
 }
@@ -0,0 +1,182 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/storage"
+)
+
+const (
+	evaluatorBenchmarkDefaultIterations = 100
+	evaluatorBenchmarkMaxIterations     = 10_000
+	evaluatorBenchmarkTimeout           = 30 * time.Second
+)
+
+type evaluatorBenchmarkRequest struct {
+	Method            string            `json:"method"`
+	URL               string            `json:"url"`
+	Headers           map[string]string `json:"headers"`
+	IP                string            `json:"ip"`
+	SessionID         string            `json:"sessionId"`
+	ClientCertificate string            `json:"clientCertificate"`
+	Iterations        int               `json:"iterations"`
+}
+
+type evaluatorBenchmarkStage struct {
+	MinMS  float64 `json:"minMs"`
+	MeanMS float64 `json:"meanMs"`
+	P50MS  float64 `json:"p50Ms"`
+	P99MS  float64 `json:"p99Ms"`
+	MaxMS  float64 `json:"maxMs"`
+}
+
+func newEvaluatorBenchmarkStage(stage evaluator.BenchmarkStage) evaluatorBenchmarkStage {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return evaluatorBenchmarkStage{
+		MinMS:  ms(stage.Min),
+		MeanMS: ms(stage.Mean),
+		P50MS:  ms(stage.P50),
+		P99MS:  ms(stage.P99),
+		MaxMS:  ms(stage.Max),
+	}
+}
+
+type evaluatorBenchmarkResponse struct {
+	RouteID    string                             `json:"routeId"`
+	Iterations int                                `json:"iterations"`
+	Stages     map[string]evaluatorBenchmarkStage `json:"stages"`
+	Allow      bool                               `json:"allow"`
+	Deny       bool                               `json:"deny"`
+	Reasons    []string                           `json:"reasons"`
+}
+
+// managementAPIBenchmarkEvaluator runs the authorize evaluator for the route matching a request a
+// number of times, and returns the timing of each stage of the evaluation, to diagnose slow routes.
+//
+// The evaluator is built from this instance's configuration. Databroker records are cached after
+// the first iteration, so the timings are of the evaluation itself, not of the databroker queries.
+func (p *Proxy) managementAPIBenchmarkEvaluator(w http.ResponseWriter, r *http.Request) error {
+	var req evaluatorBenchmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid benchmark request: %w", err))
+	}
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if req.Iterations == 0 {
+		req.Iterations = evaluatorBenchmarkDefaultIterations
+	}
+	if req.Iterations < 0 || req.Iterations > evaluatorBenchmarkMaxIterations {
+		return httputil.NewError(http.StatusBadRequest,
+			fmt.Errorf("iterations must be between 1 and %d", evaluatorBenchmarkMaxIterations))
+	}
+	u, err := urlutil.ParseAndValidateURL(req.URL)
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	options := p.currentOptions.Load()
+	policy := getPolicyForURL(options, u)
+	if policy == nil {
+		return httputil.NewError(http.StatusNotFound, errors.New("no route matches the url"))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), evaluatorBenchmarkTimeout)
+	defer cancel()
+	ctx = storage.WithQuerier(ctx, storage.NewCachingQuerier(
+		storage.NewQuerier(p.state.Load().dataBrokerClient),
+		storage.NewLocalCache(),
+	))
+
+	evaluatorOptions, err := getBenchmarkEvaluatorOptions(options)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	headers := req.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	res, err := evaluator.Benchmark(ctx, &evaluator.Request{
+		Policy: policy,
+		HTTP: evaluator.NewRequestHTTP(req.Method, *u, headers, evaluator.ClientCertificateInfo{
+			Presented: req.ClientCertificate != "",
+			Leaf:      req.ClientCertificate,
+		}, req.IP),
+		Session: evaluator.RequestSession{ID: req.SessionID},
+	}, req.Iterations, evaluatorOptions...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return httputil.NewError(http.StatusGatewayTimeout, fmt.Errorf("benchmark didn't finish within %s", evaluatorBenchmarkTimeout))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	log.Info(r.Context()).
+		Str("route-id", getAdminConsoleRoute(policy).ID).
+		Int("iterations", res.Iterations).
+		Dur("mean", res.Total.Mean).
+		Msg("proxy: evaluator benchmarked using the management api")
+
+	httputil.RenderJSON(w, http.StatusOK, evaluatorBenchmarkResponse{
+		RouteID:    getAdminConsoleRoute(policy).ID,
+		Iterations: res.Iterations,
+		Stages: map[string]evaluatorBenchmarkStage{
+			"clientCertificateValidation": newEvaluatorBenchmarkStage(res.ClientCertificateValidation),
+			"policyEvaluation":            newEvaluatorBenchmarkStage(res.PolicyEvaluation),
+			"headersEvaluation":           newEvaluatorBenchmarkStage(res.HeadersEvaluation),
+			"total":                       newEvaluatorBenchmarkStage(res.Total),
+		},
+		Allow:   res.Allow.Value,
+		Deny:    res.Deny.Value,
+		Reasons: append(res.Allow.Reasons.Strings(), res.Deny.Reasons.Strings()...),
+	})
+	return nil
+}
+
+// getBenchmarkEvaluatorOptions returns the options of the evaluator of the authorize service. The web
+// application firewall isn't loaded, since it isn't benchmarked.
+func getBenchmarkEvaluatorOptions(options *config.Options) ([]evaluator.Option, error) {
+	clientCA, err := options.DownstreamMTLS.GetCA()
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid client CA: %w", err)
+	}
+	clientCRL, err := options.DownstreamMTLS.GetCRL()
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid client CRL: %w", err)
+	}
+	authenticateURL, err := options.GetInternalAuthenticateURL()
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid authenticate url: %w", err)
+	}
+	signingKey, err := options.GetSigningKey()
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid signing key: %w", err)
+	}
+	clientCertConstraints, err := evaluator.ClientCertConstraintsFromConfig(&options.DownstreamMTLS)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: invalid client certificate constraints: %w", err)
+	}
+	addDefaultClientCertificateRule := options.HasAnyDownstreamMTLSClientCA() &&
+		options.DownstreamMTLS.GetEnforcement() != config.MTLSEnforcementPolicy
+
+	return []evaluator.Option{
+		evaluator.WithClientCA(clientCA),
+		evaluator.WithAddDefaultClientCertificateRule(addDefaultClientCertificateRule),
+		evaluator.WithClientCRL(clientCRL),
+		evaluator.WithClientCertConstraints(clientCertConstraints),
+		evaluator.WithSigningKey(signingKey),
+		evaluator.WithAuthenticateURL(authenticateURL.String()),
+		evaluator.WithGoogleCloudServerlessAuthenticationServiceAccount(options.GetGoogleCloudServerlessAuthenticationServiceAccount()),
+		evaluator.WithJWTClaimsHeaders(options.JWTClaimsHeaders),
+		evaluator.WithMeshIdentity(options.MeshIdentity),
+	}, nil
+}
@@ -10,11 +10,17 @@ import (
 
 	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/protojson"
 
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/audit"
 	"github.com/pomerium/pomerium/internal/handlers"
 	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/identity/manager"
 	"github.com/pomerium/pomerium/internal/middleware"
+	"github.com/pomerium/pomerium/internal/sessions/header"
 	"github.com/pomerium/pomerium/internal/urlutil"
 	"github.com/pomerium/pomerium/pkg/grpc/databroker"
 	"github.com/pomerium/pomerium/pkg/grpc/identity"
@@ -26,14 +32,20 @@ import (
 // registerDashboardHandlers returns the proxy service's ServeMux
 func (p *Proxy) registerDashboardHandlers(r *mux.Router) *mux.Router {
 	h := httputil.DashboardSubrouter(r)
-	h.Use(middleware.SetHeaders(httputil.HeadersContentSecurityPolicy))
+	h.Use(func(h http.Handler) http.Handler {
+		return middleware.SetSecurityHeaders(p.currentOptions.Load().SecurityHeaders.GetHeaders())(h)
+	})
 
 	// special pomerium endpoints for users to view their session
 	h.Path("/").Handler(httputil.HandlerFunc(p.userInfo)).Methods(http.MethodGet)
 	h.Path("/device-enrolled").Handler(httputil.HandlerFunc(p.deviceEnrolled))
 	h.Path("/jwt").Handler(httputil.HandlerFunc(p.jwtAssertion)).Methods(http.MethodGet)
+	h.Path("/groups").Handler(httputil.HandlerFunc(p.userGroups)).Methods(http.MethodGet)
+	h.Path("/revoke_session").Handler(httputil.HandlerFunc(p.revokeSession)).Methods(http.MethodPost)
 	h.Path("/sign_out").Handler(httputil.HandlerFunc(p.SignOut)).Methods(http.MethodGet, http.MethodPost)
 	h.Path("/webauthn").Handler(p.webauthn)
+	p.registerAdminConsoleHandlers(h)
+	p.registerSCIMHandlers(h)
 
 	// called following authenticate auth flow to grab a new or existing session
 	// the route specific cookie is returned in a signed query params
@@ -46,6 +58,15 @@ func (p *Proxy) registerDashboardHandlers(r *mux.Router) *mux.Router {
 	a.Path("/v1/login").Handler(httputil.HandlerFunc(p.ProgrammaticLogin)).
 		Queries(urlutil.QueryRedirectURI, "").
 		Methods(http.MethodGet)
+	// device auth api handler generates a url to start a device authorization grant
+	a.Path("/v1/device_auth").Handler(httputil.HandlerFunc(p.DeviceAuthLogin)).
+		Methods(http.MethodGet)
+	// agent api handlers keep the sessions of desktop agents fresh
+	p.registerAgentAPIHandlers(a)
+	// programmatic login api v2 handlers issue login codes and refresh tokens
+	p.registerProgrammaticAPIHandlers(a)
+	// kubernetes api handlers issue credentials for kubectl
+	p.registerKubernetesAPIHandlers(a)
 
 	return r
 }
@@ -98,6 +119,52 @@ func (p *Proxy) userInfo(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// revokeSession revokes one of the current user's sessions, such as the session of another device.
+func (p *Proxy) revokeSession(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+
+	// the revoke session url is signed, which also protects against cross-site requests
+	if err := middleware.ValidateRequestURL(r, state.sharedKey); err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	ss, err := p.getSessionState(r)
+	if err != nil {
+		return err
+	}
+	current, isImpersonated, err := p.getSession(r.Context(), ss.ID)
+	if err != nil {
+		return httputil.NewError(http.StatusUnauthorized, err)
+	}
+	if isImpersonated {
+		return httputil.NewError(http.StatusForbidden, errors.New("sessions cannot be revoked while impersonating"))
+	}
+
+	sessionID := r.FormValue(urlutil.QuerySessionID)
+	s, err := session.Get(r.Context(), state.dataBrokerClient, sessionID)
+	if status.Code(err) == codes.NotFound || (err == nil && s.GetUserId() != current.GetUserId()) {
+		return httputil.NewError(http.StatusNotFound, fmt.Errorf("session %s not found", sessionID))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	if err := session.Delete(r.Context(), state.dataBrokerClient, sessionID); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking session: %w", err))
+	}
+	audit.Record(r.Context(), audit.Event{
+		Type:      audit.EventSessionRevoked,
+		UserID:    s.GetUserId(),
+		SessionID: sessionID,
+		Details:   map[string]string{"revoked_by_session_id": ss.ID},
+	})
+
+	if sessionID == ss.ID {
+		state.sessionStore.ClearSession(w, r)
+	}
+	httputil.Redirect(w, r, dashboardPath+"/", http.StatusFound)
+	return nil
+}
+
 func (p *Proxy) deviceEnrolled(w http.ResponseWriter, r *http.Request) error {
 	data, err := p.getUserInfoData(r)
 	if err != nil {
@@ -157,6 +224,17 @@ func (p *Proxy) Callback(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
+	// limit the claims before they're stored
+	if options.ClaimLimits != nil {
+		s.Claims, err = manager.FilterClaims(r.Context(), state.dataBrokerClient, options.ClaimLimits.Apply, u.GetId(), s.Claims)
+		if err == nil {
+			u.Claims, err = manager.FilterClaims(r.Context(), state.dataBrokerClient, options.ClaimLimits.Apply, u.GetId(), u.Claims)
+		}
+		if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error limiting claims: %w", err))
+		}
+	}
+
 	// save the records
 	res, err := state.dataBrokerClient.Put(r.Context(), &databroker.PutRequest{
 		Records: []*databroker.Record{
@@ -174,19 +252,40 @@ func (p *Proxy) Callback(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	if options.StatelessSessions {
+		if err := ss.SetStatelessRecords(state.sharedCipher, s, u); err != nil {
+			return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error encrypting session records: %w", err))
+		}
+	}
+
 	// save the session state
 	rawJWT, err := state.encoder.Marshal(ss)
 	if err != nil {
 		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error marshaling session state: %w", err))
 	}
-	if err = state.sessionStore.SaveSession(w, r, rawJWT); err != nil {
-		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving session state: %w", err))
+	// with the header session transport, the session is only handed to the client in the redirect
+	headerTransport := options.SessionTransport == config.SessionTransportHeader
+	if !headerTransport {
+		// the session cookie options depend on the route being signed in to, rather than the callback
+		sr := r.Clone(r.Context())
+		sr.URL, sr.Host = redirectURI, redirectURI.Host
+		if err = state.sessionStore.SaveSession(w, sr, rawJWT); err != nil {
+			return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving session state: %w", err))
+		}
 	}
 
-	// if programmatic, encode the session jwt as a query param
-	if isProgrammatic := values.Get(urlutil.QueryIsProgrammatic); isProgrammatic == "true" {
+	// if programmatic, encode the session jwt, or a login code for it, as a query param
+	if isProgrammatic := values.Get(urlutil.QueryIsProgrammatic) == "true"; isProgrammatic || headerTransport {
 		q := redirectURI.Query()
-		q.Set(urlutil.QueryPomeriumJWT, string(rawJWT))
+		if values.Has(urlutil.QueryCodeChallenge) {
+			code, err := p.newProgrammaticLoginCode(r.Context(), ss, values)
+			if err != nil {
+				return httputil.NewError(http.StatusInternalServerError, err)
+			}
+			q.Set(urlutil.QueryCode, code)
+		} else {
+			q.Set(urlutil.QueryPomeriumJWT, string(rawJWT))
+		}
 		redirectURI.RawQuery = q.Encode()
 	}
 
@@ -198,37 +297,92 @@ func (p *Proxy) Callback(w http.ResponseWriter, r *http.Request) error {
 // ProgrammaticLogin returns a signed url that can be used to login
 // using the authenticate service.
 func (p *Proxy) ProgrammaticLogin(w http.ResponseWriter, r *http.Request) error {
+	rawURL, err := p.getProgrammaticSignInURL(r, nil)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, rawURL)
+	return nil
+}
+
+// getProgrammaticSignInURL returns a signed url to log in to the authenticate service with, which
+// redirects to the redirect uri of the request after the callback. The callback params are added
+// to the callback url.
+func (p *Proxy) getProgrammaticSignInURL(r *http.Request, callbackParams url.Values) (string, error) {
 	state := p.state.Load()
 	options := p.currentOptions.Load()
 
 	redirectURI, err := urlutil.ParseAndValidateURL(r.FormValue(urlutil.QueryRedirectURI))
 	if err != nil {
-		return httputil.NewError(http.StatusBadRequest, err)
+		return "", httputil.NewError(http.StatusBadRequest, err)
 	}
 
 	if !urlutil.IsRedirectAllowed(redirectURI, state.programmaticRedirectDomainWhitelist) {
-		return httputil.NewError(http.StatusBadRequest, errors.New("invalid redirect uri"))
+		return "", httputil.NewError(http.StatusBadRequest, errors.New("invalid redirect uri"))
 	}
 
 	idp, err := options.GetIdentityProviderForRequestURL(urlutil.GetAbsoluteURL(r).String())
 	if err != nil {
-		return httputil.NewError(http.StatusInternalServerError, err)
+		return "", httputil.NewError(http.StatusInternalServerError, err)
 	}
 
 	hpkeAuthenticateKey, err := state.authenticateKeyFetcher.FetchPublicKey(r.Context())
 	if err != nil {
-		return httputil.NewError(http.StatusInternalServerError, err)
+		return "", httputil.NewError(http.StatusInternalServerError, err)
 	}
 
 	signinURL := *state.authenticateSigninURL
+	// the callback params are encrypted with the rest of the callback url by the authenticate service
 	callbackURI := urlutil.GetAbsoluteURL(r)
 	callbackURI.Path = dashboardPath + "/callback/"
+	callbackURI.RawQuery = callbackParams.Encode()
 	q := signinURL.Query()
 	q.Set(urlutil.QueryCallbackURI, callbackURI.String())
 	q.Set(urlutil.QueryIsProgrammatic, "true")
 	signinURL.RawQuery = q.Encode()
 
 	rawURL, err := urlutil.SignInURL(state.hpkePrivateKey, hpkeAuthenticateKey, &signinURL, redirectURI, idp.GetId())
+	if err != nil {
+		return "", httputil.NewError(http.StatusInternalServerError, err)
+	}
+	return rawURL, nil
+}
+
+// DeviceAuthLogin returns a signed url that can be used to start a device authorization grant
+// using the authenticate service, for clients without a browser. Once the user authorizes the
+// device, the authenticate service returns a callback url which, like the programmatic login
+// flow, redirects with the session jwt as a query param.
+func (p *Proxy) DeviceAuthLogin(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	idp, err := options.GetIdentityProviderForRequestURL(urlutil.GetAbsoluteURL(r).String())
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	hpkeAuthenticateKey, err := state.authenticateKeyFetcher.FetchPublicKey(r.Context())
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	redirectURI := urlutil.GetAbsoluteURL(r)
+	redirectURI.Path = "/"
+	redirectURI.RawQuery = ""
+
+	deviceAuthURL := *state.authenticateURL
+	callbackURI := urlutil.GetAbsoluteURL(r)
+	callbackURI.Path = dashboardPath + "/callback/"
+	callbackURI.RawQuery = ""
+	q := deviceAuthURL.Query()
+	q.Set(urlutil.QueryCallbackURI, callbackURI.String())
+	q.Set(urlutil.QueryIsProgrammatic, "true")
+	deviceAuthURL.RawQuery = q.Encode()
+
+	rawURL, err := urlutil.DeviceAuthURL(state.hpkePrivateKey, hpkeAuthenticateKey, &deviceAuthURL, redirectURI, idp.GetId())
 	if err != nil {
 		return httputil.NewError(http.StatusInternalServerError, err)
 	}
@@ -264,6 +418,40 @@ func (p *Proxy) jwtAssertion(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// userGroups returns the full groups of the current user, for users whose groups claim was replaced
+// by a hash due to the claim limits.
+func (p *Proxy) userGroups(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+
+	var s *session.Session
+	if header.TokenFromHeaders(r) != "" {
+		var err error
+		_, s, err = p.getAuthorizationSession(r)
+		if err != nil {
+			return err
+		}
+	} else {
+		ss, err := p.getSessionState(r)
+		if err != nil {
+			return httputil.NewError(http.StatusUnauthorized, err)
+		}
+		s, _, err = p.getSession(r.Context(), ss.ID)
+		if err != nil {
+			return httputil.NewError(http.StatusUnauthorized, err)
+		}
+	}
+
+	groups, err := manager.GetGroups(r.Context(), state.dataBrokerClient, s.GetUserId())
+	if status.Code(err) == codes.NotFound {
+		return httputil.NewError(http.StatusNotFound, errors.New("groups not found"))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	httputil.RenderJSON(w, http.StatusOK, groups)
+	return nil
+}
+
 func (p *Proxy) validateSenderPublicKey(ctx context.Context, senderPublicKey *hpke.PublicKey) error {
 	state := p.state.Load()
 
@@ -135,6 +135,50 @@ func TestProxy_ProgrammaticLogin(t *testing.T) {
 			http.StatusMethodNotAllowed,
 			"",
 		},
+		{
+			"v2 good body not checked",
+			opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v2/login", nil,
+			map[string]string{
+				urlutil.QueryRedirectURI:         "http://localhost",
+				urlutil.QueryCodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				urlutil.QueryCodeChallengeMethod: "S256",
+				urlutil.QueryAudience:            "https://api.example.com",
+			},
+			http.StatusOK,
+			"",
+		},
+		{
+			"v2 missing code challenge",
+			opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v2/login", nil,
+			map[string]string{
+				urlutil.QueryRedirectURI:         "http://localhost",
+				urlutil.QueryCodeChallengeMethod: "S256",
+			},
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"v2 plain code challenge",
+			opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v2/login", nil,
+			map[string]string{
+				urlutil.QueryRedirectURI:         "http://localhost",
+				urlutil.QueryCodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				urlutil.QueryCodeChallengeMethod: "plain",
+			},
+			http.StatusBadRequest,
+			"",
+		},
+		{
+			"v2 bad redirect_uri not whitelisted",
+			opts, http.MethodGet, "https", "corp.example.example", "/.pomerium/api/v2/login", nil,
+			map[string]string{
+				urlutil.QueryRedirectURI:         "https://example.com",
+				urlutil.QueryCodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+				urlutil.QueryCodeChallengeMethod: "S256",
+			},
+			http.StatusBadRequest,
+			"",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -172,6 +216,26 @@ func TestProxy_ProgrammaticLogin(t *testing.T) {
 	}
 }
 
+func TestNarrowRouteAudience(t *testing.T) {
+	t.Parallel()
+
+	aud, err := narrowRouteAudience(nil, []string{"https://api.example.com/v1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com"}, aud, "any audience may be requested of unrestricted grants")
+
+	granted := []string{"api.example.com", "db.example.com"}
+	aud, err = narrowRouteAudience(granted, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, granted, aud)
+
+	aud, err = narrowRouteAudience(granted, []string{"https://db.example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db.example.com"}, aud)
+
+	_, err = narrowRouteAudience(granted, []string{"https://admin.example.com"})
+	assert.Error(t, err, "audiences cannot be widened")
+}
+
 func TestProxy_jwt(t *testing.T) {
 	// without upstream headers being set
 	req, _ := http.NewRequest(http.MethodGet, "https://www.example.com/.pomerium/jwt", nil)
@@ -197,3 +261,26 @@ func TestProxy_jwt(t *testing.T) {
 	assert.Equal(t, "application/jwt", w.Header().Get("Content-Type"))
 	assert.Equal(t, w.Body.String(), rawJWT)
 }
+
+func TestProxy_revokeSession(t *testing.T) {
+	sharedKey := []byte("TEST")
+	proxy := &Proxy{
+		state: atomicutil.NewValue(&proxyState{sharedKey: sharedKey}),
+	}
+
+	// the url must be signed
+	req := httptest.NewRequest(http.MethodPost, "https://www.example.com/.pomerium/revoke_session?pomerium_session_id=S1", nil)
+	err := proxy.revokeSession(httptest.NewRecorder(), req)
+	var httpErr *httputil.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+	}
+
+	// signed with a different key
+	baseURL := urlutil.MustParseAndValidateURL("https://www.example.com")
+	req = httptest.NewRequest(http.MethodPost, urlutil.RevokeSessionURL(&baseURL, []byte("OTHER"), "S1"), nil)
+	err = proxy.revokeSession(httptest.NewRecorder(), req)
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusBadRequest, httpErr.Status)
+	}
+}
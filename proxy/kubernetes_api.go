@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/gorilla/mux"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+// kubernetesCredentialTTL is how long kubernetes exec credentials are valid for. kubectl runs the
+// exec plugin again for a new credential once it expires.
+const kubernetesCredentialTTL = 15 * time.Minute
+
+// The client-go exec credential api versions supported by the exec credential api.
+const (
+	kubernetesExecCredentialV1      = "client.authentication.k8s.io/v1"
+	kubernetesExecCredentialV1Beta1 = "client.authentication.k8s.io/v1beta1"
+)
+
+// registerKubernetesAPIHandlers registers the kubernetes api handlers.
+//
+// The exec credential api implements the response of the client-go exec credential plugin
+// protocol, so a kubeconfig exec plugin can print it as is. The credential is a short-lived
+// session token restricted to the kubernetes route, minted from the session in the authorization
+// header.
+func (p *Proxy) registerKubernetesAPIHandlers(r *mux.Router) {
+	r.Path("/v1/kubernetes/exec-credential").Handler(httputil.HandlerFunc(p.kubernetesAPIExecCredential)).Methods(http.MethodGet)
+}
+
+type kubernetesExecCredential struct {
+	APIVersion string                         `json:"apiVersion"`
+	Kind       string                         `json:"kind"`
+	Status     kubernetesExecCredentialStatus `json:"status"`
+}
+
+type kubernetesExecCredentialStatus struct {
+	ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	Token               string    `json:"token"`
+}
+
+func (p *Proxy) kubernetesAPIExecCredential(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	apiVersion := r.FormValue("api_version")
+	switch apiVersion {
+	case "":
+		apiVersion = kubernetesExecCredentialV1
+	case kubernetesExecCredentialV1, kubernetesExecCredentialV1Beta1:
+	default:
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("unsupported api_version: %q", apiVersion))
+	}
+
+	requestURL := urlutil.GetAbsoluteURL(r)
+	if !hasKubernetesPolicy(options, requestURL) {
+		return httputil.NewError(http.StatusNotFound, errors.New("no kubernetes route found"))
+	}
+
+	ss, s, err := p.getAuthorizationSession(r)
+	if err != nil {
+		return err
+	}
+	if !ss.AllowsRoute(requestURL.Hostname()) {
+		return httputil.NewError(http.StatusForbidden, errors.New("the session is restricted to other routes"))
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(kubernetesCredentialTTL)
+	if sessionExpiresAt := s.GetExpiresAt(); sessionExpiresAt.IsValid() && sessionExpiresAt.AsTime().Before(expiresAt) {
+		expiresAt = sessionExpiresAt.AsTime()
+	}
+	if ss.ExpiresAt != nil && ss.ExpiresAt.Time().Before(expiresAt) {
+		expiresAt = ss.ExpiresAt.Time()
+	}
+
+	credential := *ss
+	credential.IssuedAt = jwt.NewNumericDate(now)
+	credential.RouteAudience = []string{requestURL.Hostname()}
+	credential.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	rawJWT, err := state.encoder.Marshal(credential)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error marshaling session state: %w", err))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.RenderJSON(w, http.StatusOK, kubernetesExecCredential{
+		APIVersion: apiVersion,
+		Kind:       "ExecCredential",
+		Status: kubernetesExecCredentialStatus{
+			ExpirationTimestamp: expiresAt.UTC().Truncate(time.Second),
+			// kubectl sends the token as a bearer token
+			Token: httputil.AuthorizationTypePomerium + "-" + string(rawJWT),
+		},
+	})
+	return nil
+}
+
+func hasKubernetesPolicy(options *config.Options, requestURL *url.URL) bool {
+	for _, policy := range options.GetAllPolicies() {
+		if !policy.IsForKubernetes() {
+			continue
+		}
+		fromURL, err := urlutil.ParseAndValidateURL(policy.From)
+		if err == nil && config.FromURLMatchesRequestURL(fromURL, requestURL) {
+			return true
+		}
+	}
+	return false
+}
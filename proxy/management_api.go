@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/internal/audit"
+	"github.com/pomerium/pomerium/internal/handlers"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions/header"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+	"github.com/pomerium/pomerium/pkg/grpc/user"
+)
+
+const managementAPISessionsMaxLimit = 1000
+
+// registerManagementAPIHandlers registers the management api handlers. Like the admin console, the
+// management api is authorized using the admin console policy, so service accounts can be used to
+// automate operations.
+//
+// Requests which change state must be authorized with a token in the authorization header rather
+// than the session cookie, which a browser would also send with cross-site requests.
+func (p *Proxy) registerManagementAPIHandlers(r *mux.Router) {
+	a := r.PathPrefix("/api/v1").Subrouter()
+	a.Use(requireManagementAPIToken)
+	a.Path("/sessions").Handler(httputil.HandlerFunc(p.managementAPIListSessions)).Methods(http.MethodGet)
+	a.Path("/sessions/revoke").Handler(httputil.HandlerFunc(p.managementAPIRevokeSessions)).Methods(http.MethodPost)
+	a.Path("/sessions/{id}").Handler(httputil.HandlerFunc(p.managementAPIRevokeSession)).Methods(http.MethodDelete)
+	a.Path("/routes").Handler(httputil.HandlerFunc(p.managementAPIListRoutes)).Methods(http.MethodGet)
+	a.Path("/users/{id}/access_decisions").Handler(httputil.HandlerFunc(p.managementAPIListAccessDecisions)).Methods(http.MethodGet)
+	a.Path("/config/reload").Handler(httputil.HandlerFunc(p.managementAPIReloadConfig)).Methods(http.MethodPost)
+	a.Path("/evaluator/benchmark").Handler(httputil.HandlerFunc(p.managementAPIBenchmarkEvaluator)).Methods(http.MethodPost)
+}
+
+// requireManagementAPIToken rejects requests with unsafe methods which don't have a pomerium
+// token in the authorization headers.
+func requireManagementAPIToken(next http.Handler) http.Handler {
+	return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if header.TokenFromHeaders(r) == "" {
+				return httputil.NewError(http.StatusForbidden,
+					errors.New("management api requests which change state require a bearer token"))
+			}
+		}
+		next.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// managementAPIListSessions lists the sessions, optionally only those of a single user.
+func (p *Proxy) managementAPIListSessions(w http.ResponseWriter, r *http.Request) error {
+	client := p.state.Load().dataBrokerClient
+
+	var sessions []*session.Session
+	var totalCount int
+	var err error
+	if userID := r.FormValue("user_id"); userID != "" {
+		sessions, err = session.GetUserSessions(r.Context(), client, userID)
+		totalCount = len(sessions)
+	} else {
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		limit, _ := strconv.Atoi(r.FormValue("limit"))
+		if offset < 0 {
+			offset = 0
+		}
+		if limit <= 0 || limit > managementAPISessionsMaxLimit {
+			limit = managementAPISessionsMaxLimit
+		}
+		sessions, totalCount, err = listSessions(r.Context(), client, offset, limit)
+	}
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error listing sessions: %w", err))
+	}
+
+	res := struct {
+		Sessions   []json.RawMessage `json:"sessions"`
+		TotalCount int               `json:"totalCount"`
+	}{
+		Sessions:   []json.RawMessage{},
+		TotalCount: totalCount,
+	}
+	for _, s := range sessions {
+		bs, err := protojson.Marshal(s)
+		if err != nil {
+			return httputil.NewError(http.StatusInternalServerError, err)
+		}
+		res.Sessions = append(res.Sessions, bs)
+	}
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// managementAPIRevokeSession revokes a session.
+func (p *Proxy) managementAPIRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	client := p.state.Load().dataBrokerClient
+
+	sessionID := mux.Vars(r)["id"]
+	s, err := session.Get(r.Context(), client, sessionID)
+	if status.Code(err) == codes.NotFound {
+		return httputil.NewError(http.StatusNotFound, fmt.Errorf("session %s not found", sessionID))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+
+	if err := session.Delete(r.Context(), client, sessionID); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking session: %w", err))
+	}
+	log.Info(r.Context()).Str("session-id", sessionID).Msg("proxy: session revoked using the management api")
+	audit.Record(r.Context(), audit.Event{
+		Type:      audit.EventSessionRevoked,
+		UserID:    s.GetUserId(),
+		SessionID: sessionID,
+		Details:   map[string]string{"method": "management_api"},
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// managementAPIRevokeSessions revokes all the sessions of a user email, group or identity provider.
+// A revocation is stored in the databroker, which the authorize service checks for every request,
+// so sessions are rejected immediately even if they're still cached. The matching sessions are
+// also deleted.
+func (p *Proxy) managementAPIRevokeSessions(w http.ResponseWriter, r *http.Request) error {
+	client := p.state.Load().dataBrokerClient
+
+	var revocation session.Revocation
+	if err := json.NewDecoder(r.Body).Decode(&revocation); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid revocation: %w", err))
+	}
+	if err := revocation.Validate(); err != nil {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid revocation: %w", err))
+	}
+	revocation.RevokedAt = time.Now()
+
+	if err := session.PutRevocation(r.Context(), client, &revocation); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking sessions: %w", err))
+	}
+
+	// sessions don't record their identity provider, so those revocations rely on the authorize check
+	var sessions []*session.Session
+	var err error
+	switch {
+	case revocation.Email != "":
+		sessions, err = session.GetClaimSessions(r.Context(), client, "email", revocation.Email)
+	case revocation.Group != "":
+		sessions, err = session.GetClaimSessions(r.Context(), client, "groups", revocation.Group)
+	}
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking sessions: %w", err))
+	}
+	for _, s := range sessions {
+		if err := session.Delete(r.Context(), client, s.GetId()); err != nil {
+			return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error revoking sessions: %w", err))
+		}
+	}
+
+	details := map[string]string{"method": "management_api"}
+	if revocation.Email != "" {
+		details["revoked_email"] = revocation.Email
+	}
+	if revocation.Group != "" {
+		details["revoked_group"] = revocation.Group
+	}
+	if revocation.IdentityProviderID != "" {
+		details["revoked_idp_id"] = revocation.IdentityProviderID
+	}
+	for _, s := range sessions {
+		audit.Record(r.Context(), audit.Event{
+			Type:      audit.EventSessionRevoked,
+			UserID:    s.GetUserId(),
+			SessionID: s.GetId(),
+			Details:   details,
+		})
+	}
+	if len(sessions) == 0 {
+		// the revocation also applies to sessions which aren't stored in the databroker
+		audit.Record(r.Context(), audit.Event{
+			Type:    audit.EventSessionRevoked,
+			Details: details,
+		})
+	}
+
+	log.Info(r.Context()).
+		Str("email", revocation.Email).
+		Str("group", revocation.Group).
+		Str("idp-id", revocation.IdentityProviderID).
+		Int("sessions", len(sessions)).
+		Msg("proxy: sessions revoked using the management api")
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// managementAPIListRoutes lists the configured routes along with their route ids.
+func (p *Proxy) managementAPIListRoutes(w http.ResponseWriter, _ *http.Request) error {
+	res := struct {
+		Routes []handlers.AdminConsoleRoute `json:"routes"`
+	}{
+		Routes: []handlers.AdminConsoleRoute{},
+	}
+	policies := p.currentOptions.Load().GetAllPolicies()
+	for i := range policies {
+		res.Routes = append(res.Routes, getAdminConsoleRoute(&policies[i]))
+	}
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// managementAPIListAccessDecisions lists the recent access decisions of a user.
+func (p *Proxy) managementAPIListAccessDecisions(w http.ResponseWriter, r *http.Request) error {
+	client := p.state.Load().dataBrokerClient
+
+	decisions, err := user.GetAccessDecisions(r.Context(), client, mux.Vars(r)["id"])
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error retrieving access decisions: %w", err))
+	}
+
+	res := struct {
+		AccessDecisions []user.AccessDecision `json:"accessDecisions"`
+	}{
+		AccessDecisions: []user.AccessDecision{},
+	}
+	res.AccessDecisions = append(res.AccessDecisions, decisions...)
+	httputil.RenderJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// managementAPIReloadConfig reloads the configuration file of this pomerium instance.
+func (p *Proxy) managementAPIReloadConfig(w http.ResponseWriter, r *http.Request) error {
+	if p.cfg.configReloader == nil {
+		return httputil.NewError(http.StatusNotImplemented, errors.New("the configuration can't be reloaded"))
+	}
+
+	log.Info(r.Context()).Msg("proxy: config reload requested using the management api")
+
+	// reload in the background, the config change listeners may take a while
+	go p.cfg.configReloader.Reload(context.Background())
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/pkg/policy/parser"
+)
+
+type mockReloader struct {
+	reloaded chan struct{}
+}
+
+func (m mockReloader) Reload(_ context.Context) {
+	close(m.reloaded)
+}
+
+// newManagementAPIRequest returns a request authorized with a service account token.
+func newManagementAPIRequest(method, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("Authorization", "Bearer Pomerium-JWT")
+	return r
+}
+
+func testAdminConsoleOptions(t *testing.T) *config.Options {
+	t.Helper()
+
+	opts := testOptions(t)
+	opts.AdminConsole.Policy = &config.PPLPolicy{Policy: &parser.Policy{
+		Rules: []parser.Rule{{Action: parser.ActionAllow}},
+	}}
+	return opts
+}
+
+func TestProxy_managementAPIReloadConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		t.Parallel()
+
+		p, err := New(&config.Config{Options: testAdminConsoleOptions(t)})
+		require.NoError(t, err)
+
+		r := mux.NewRouter()
+		p.Mount(r)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newManagementAPIRequest(http.MethodPost, "https://corp.example.example/.pomerium/admin/api/v1/config/reload", nil))
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+	t.Run("reload", func(t *testing.T) {
+		t.Parallel()
+
+		reloader := mockReloader{reloaded: make(chan struct{})}
+		p, err := New(&config.Config{Options: testAdminConsoleOptions(t)}, WithConfigReloader(reloader))
+		require.NoError(t, err)
+
+		r := mux.NewRouter()
+		p.Mount(r)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newManagementAPIRequest(http.MethodPost, "https://corp.example.example/.pomerium/admin/api/v1/config/reload", nil))
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		select {
+		case <-reloader.reloaded:
+		case <-time.After(time.Second):
+			t.Fatal("expected config to be reloaded")
+		}
+	})
+}
+
+func TestProxy_managementAPIRequireToken(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(&config.Config{Options: testAdminConsoleOptions(t)}, WithConfigReloader(mockReloader{reloaded: make(chan struct{})}))
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	p.Mount(r)
+
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{http.MethodPost, "/config/reload"},
+		{http.MethodPost, "/sessions/revoke"},
+		{http.MethodDelete, "/sessions/S1"},
+		{http.MethodPost, "/evaluator/benchmark"},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tc.method, "https://corp.example.example/.pomerium/admin/api/v1"+tc.path, nil)
+		req.AddCookie(&http.Cookie{Name: "_pomerium", Value: "SESSION"})
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestProxy_managementAPIListRoutes(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(&config.Config{Options: testAdminConsoleOptions(t)})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	p.Mount(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://corp.example.example/.pomerium/admin/api/v1/routes", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res struct {
+		Routes []struct {
+			ID   string `json:"id"`
+			From string `json:"from"`
+		} `json:"routes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	if assert.Len(t, res.Routes, 1) {
+		assert.NotEmpty(t, res.Routes[0].ID)
+		assert.Equal(t, "https://corp.example.example", res.Routes[0].From)
+	}
+}
+
+func TestProxy_managementAPIBenchmarkEvaluator(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(&config.Config{Options: testAdminConsoleOptions(t)})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	p.Mount(r)
+
+	for _, tc := range []struct {
+		body   string
+		expect int
+	}{
+		{`{`, http.StatusBadRequest},
+		{`{"url": "https://corp.example.example/", "iterations": -1}`, http.StatusBadRequest},
+		{`{"url": "https://corp.example.example/", "iterations": 100000}`, http.StatusBadRequest},
+		{`{"url": "not a url"}`, http.StatusBadRequest},
+		{`{"url": "https://other.example.example/"}`, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newManagementAPIRequest(http.MethodPost,
+			"https://corp.example.example/.pomerium/admin/api/v1/evaluator/benchmark", strings.NewReader(tc.body)))
+		assert.Equal(t, tc.expect, w.Code, tc.body)
+	}
+}
+
+func TestProxy_managementAPIRevokeSessions(t *testing.T) {
+	t.Parallel()
+
+	p, err := New(&config.Config{Options: testAdminConsoleOptions(t)})
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	p.Mount(r)
+
+	for _, body := range []string{
+		`invalid`,
+		`{}`,
+		`{"email":"user@example.com","group":"admins"}`,
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newManagementAPIRequest(http.MethodPost, "https://corp.example.example/.pomerium/admin/api/v1/sessions/revoke", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,329 @@
+package proxy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/throttle"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/pomerium/pomerium/pkg/grpc/session"
+)
+
+// programmaticLoginCodeTTL is how long a client has to exchange a login code for tokens.
+const programmaticLoginCodeTTL = time.Minute
+
+// registerProgrammaticAPIHandlers registers the handlers of version 2 of the programmatic login
+// api.
+//
+// Unlike version 1, which redirects to the client with the session token, version 2 redirects to
+// the client with a single use login code. The client exchanges the code for a session token and a
+// refresh token with the verifier of the PKCE code challenge it logged in with (RFC 7636), so an
+// intercepted redirect can't be used by another client. Session tokens can be restricted to the
+// routes of an audience, and the refresh token mints new session tokens without the user logging
+// in again.
+func (p *Proxy) registerProgrammaticAPIHandlers(r *mux.Router) {
+	r.Path("/v2/login").Handler(httputil.HandlerFunc(p.programmaticAPILogin)).Methods(http.MethodGet)
+	r.Path("/v2/token").Handler(httputil.HandlerFunc(p.programmaticAPIToken)).Methods(http.MethodPost)
+}
+
+type programmaticLoginResponse struct {
+	LoginURL string `json:"loginUrl"`
+}
+
+type programmaticTokenResponse struct {
+	AccessToken   string   `json:"access_token"`
+	TokenType     string   `json:"token_type"`
+	ExpiresIn     int64    `json:"expires_in"`
+	RefreshToken  string   `json:"refresh_token"`
+	RouteAudience []string `json:"route_audience,omitempty"`
+}
+
+// programmaticAPILogin returns a url for the user to log in with. Once the user logs in, the
+// browser is redirected to the redirect uri with a login code.
+func (p *Proxy) programmaticAPILogin(w http.ResponseWriter, r *http.Request) error {
+	options := p.currentOptions.Load()
+	if options.StatelessSessions {
+		return httputil.NewError(http.StatusNotImplemented, errors.New("the programmatic login api v2 cannot be used with stateless sessions"))
+	}
+
+	codeChallenge := r.FormValue(urlutil.QueryCodeChallenge)
+	if method := r.FormValue(urlutil.QueryCodeChallengeMethod); method != "S256" {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("%s must be S256", urlutil.QueryCodeChallengeMethod))
+	}
+	// the challenge is the base64url encoded SHA-256 digest of the verifier
+	if len(codeChallenge) != 43 {
+		return httputil.NewError(http.StatusBadRequest, fmt.Errorf("invalid %s", urlutil.QueryCodeChallenge))
+	}
+	routeAudience, err := getRouteAudience(r.Form[urlutil.QueryAudience])
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	callbackParams := url.Values{urlutil.QueryCodeChallenge: {codeChallenge}}
+	for _, aud := range routeAudience {
+		callbackParams.Add(urlutil.QueryAudience, aud)
+	}
+	rawURL, err := p.getProgrammaticSignInURL(r, callbackParams)
+	if err != nil {
+		return err
+	}
+
+	httputil.RenderJSON(w, http.StatusOK, programmaticLoginResponse{LoginURL: rawURL})
+	return nil
+}
+
+// programmaticAPIToken exchanges a login code or a refresh token for a new session token and
+// refresh token. Requests are form encoded like OAuth 2.0 token requests.
+func (p *Proxy) programmaticAPIToken(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	// guessing codes and refresh tokens is throttled per client IP
+	th := throttle.New(p.state.Load().dataBrokerClient, p.currentOptions.Load().AuthThrottle)
+	ipKey := throttle.IPKey(httputil.GetClientIPAddress(r))
+	if err := th.CheckRequest(w, r, ipKey); err != nil {
+		return err
+	}
+
+	var err error
+	switch grantType := r.PostFormValue("grant_type"); grantType {
+	case "authorization_code":
+		err = p.programmaticAPIExchangeCode(w, r)
+	case "refresh_token":
+		err = p.programmaticAPIRefresh(w, r)
+	default:
+		err = httputil.NewError(http.StatusBadRequest, fmt.Errorf("unsupported grant_type: %q", grantType))
+	}
+	th.RecordResult(r, err, ipKey)
+	return err
+}
+
+func (p *Proxy) programmaticAPIExchangeCode(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	// codes are single use, so the code is deleted even if the exchange fails
+	id := session.HashProgrammaticToken(r.PostFormValue("code"))
+	code, err := session.GetProgrammaticLoginCode(r.Context(), state.dataBrokerClient, id)
+	if status.Code(err) == codes.NotFound {
+		return httputil.NewError(http.StatusBadRequest, errors.New("invalid code"))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if err := session.DeleteProgrammaticLoginCode(r.Context(), state.dataBrokerClient, id); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if time.Now().After(code.ExpiresAt) {
+		return httputil.NewError(http.StatusBadRequest, errors.New("invalid code: expired"))
+	}
+	if !session.VerifyCodeVerifier(code.CodeChallenge, r.PostFormValue("code_verifier")) {
+		return httputil.NewError(http.StatusBadRequest, errors.New("invalid code_verifier"))
+	}
+
+	routeAudience, err := narrowRouteAudience(code.RouteAudience, r.PostForm["audience"])
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	s, err := p.getProgrammaticSession(r.Context(), code.SessionID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return p.renderProgrammaticTokens(w, r, s, &session.ProgrammaticRefreshToken{
+		SessionID:          code.SessionID,
+		UserID:             code.UserID,
+		IdentityProviderID: code.IdentityProviderID,
+		RouteAudience:      code.RouteAudience,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(options.GetProgrammaticRefreshTokenTTL()),
+	}, routeAudience, 0, 0)
+}
+
+func (p *Proxy) programmaticAPIRefresh(w http.ResponseWriter, r *http.Request) error {
+	state := p.state.Load()
+	options := p.currentOptions.Load()
+
+	// refresh tokens are rotated, so the refresh token is deleted even if the refresh fails
+	id := session.HashProgrammaticToken(r.PostFormValue("refresh_token"))
+	refreshToken, err := session.GetProgrammaticRefreshToken(r.Context(), state.dataBrokerClient, id)
+	if status.Code(err) == codes.NotFound {
+		return httputil.NewError(http.StatusBadRequest, errors.New("invalid refresh_token"))
+	} else if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if err := session.DeleteProgrammaticRefreshToken(r.Context(), state.dataBrokerClient, id); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if time.Now().After(refreshToken.ExpiresAt) {
+		return httputil.NewError(http.StatusBadRequest, errors.New("invalid refresh_token: expired"))
+	}
+
+	routeAudience, err := narrowRouteAudience(refreshToken.RouteAudience, r.PostForm["audience"])
+	if err != nil {
+		return httputil.NewError(http.StatusBadRequest, err)
+	}
+
+	s, err := p.getProgrammaticSession(r.Context(), refreshToken.SessionID)
+	if err != nil {
+		return err
+	}
+
+	// the session is extended, but never beyond the lifetime of the refresh token
+	expiresAt := time.Now().Add(options.CookieExpire)
+	if expiresAt.After(refreshToken.ExpiresAt) {
+		expiresAt = refreshToken.ExpiresAt
+	}
+	if expiresAt.After(s.GetExpiresAt().AsTime()) {
+		s.ExpiresAt = timestamppb.New(expiresAt)
+	}
+	res, err := session.Put(r.Context(), state.dataBrokerClient, s)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving session: %w", err))
+	}
+
+	return p.renderProgrammaticTokens(w, r, s, refreshToken, routeAudience,
+		res.GetServerVersion(), res.GetRecord().GetVersion())
+}
+
+// renderProgrammaticTokens stores a new refresh token like the given one and renders it with a new
+// session token for the session.
+func (p *Proxy) renderProgrammaticTokens(
+	w http.ResponseWriter,
+	r *http.Request,
+	s *session.Session,
+	refreshToken *session.ProgrammaticRefreshToken,
+	routeAudience []string,
+	serverVersion, recordVersion uint64,
+) error {
+	state := p.state.Load()
+
+	rawRefreshToken, err := newProgrammaticToken()
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, err)
+	}
+	newRefreshToken := *refreshToken
+	newRefreshToken.ID = session.HashProgrammaticToken(rawRefreshToken)
+	if err := session.PutProgrammaticRefreshToken(r.Context(), state.dataBrokerClient, &newRefreshToken); err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error saving refresh token: %w", err))
+	}
+
+	ss := sessions.State{
+		Subject:                 s.GetUserId(),
+		IssuedAt:                jwt.NewNumericDate(time.Now()),
+		ID:                      s.GetId(),
+		IdentityProviderID:      refreshToken.IdentityProviderID,
+		DatabrokerServerVersion: serverVersion,
+		DatabrokerRecordVersion: recordVersion,
+		RouteAudience:           routeAudience,
+	}
+	rawJWT, err := state.encoder.Marshal(ss)
+	if err != nil {
+		return httputil.NewError(http.StatusInternalServerError, fmt.Errorf("proxy: error marshaling session state: %w", err))
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.RenderJSON(w, http.StatusOK, programmaticTokenResponse{
+		AccessToken:   string(rawJWT),
+		TokenType:     "Pomerium",
+		ExpiresIn:     int64(time.Until(s.GetExpiresAt().AsTime()).Seconds()),
+		RefreshToken:  rawRefreshToken,
+		RouteAudience: routeAudience,
+	})
+	return nil
+}
+
+// getProgrammaticSession returns a session which tokens are issued for.
+func (p *Proxy) getProgrammaticSession(ctx context.Context, sessionID string) (*session.Session, error) {
+	state := p.state.Load()
+
+	s, err := session.Get(ctx, state.dataBrokerClient, sessionID)
+	if status.Code(err) == codes.NotFound {
+		return nil, httputil.NewError(http.StatusBadRequest, errors.New("session revoked"))
+	} else if err != nil {
+		return nil, httputil.NewError(http.StatusInternalServerError, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, httputil.NewError(http.StatusBadRequest, err)
+	}
+	return s, nil
+}
+
+// newProgrammaticLoginCode stores a new login code for the session and returns it.
+func (p *Proxy) newProgrammaticLoginCode(ctx context.Context, ss *sessions.State, values url.Values) (string, error) {
+	state := p.state.Load()
+
+	rawCode, err := newProgrammaticToken()
+	if err != nil {
+		return "", err
+	}
+	err = session.PutProgrammaticLoginCode(ctx, state.dataBrokerClient, &session.ProgrammaticLoginCode{
+		ID:                 session.HashProgrammaticToken(rawCode),
+		SessionID:          ss.ID,
+		UserID:             ss.UserID(),
+		IdentityProviderID: ss.IdentityProviderID,
+		CodeChallenge:      values.Get(urlutil.QueryCodeChallenge),
+		RouteAudience:      values[urlutil.QueryAudience],
+		ExpiresAt:          time.Now().Add(programmaticLoginCodeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("proxy: error saving login code: %w", err)
+	}
+	return rawCode, nil
+}
+
+// getRouteAudience returns the hostnames of the route urls of an audience.
+func getRouteAudience(rawURLs []string) ([]string, error) {
+	var hostnames []string
+	for _, rawURL := range rawURLs {
+		u, err := urlutil.ParseAndValidateURL(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", urlutil.QueryAudience, err)
+		}
+		hostnames = append(hostnames, u.Hostname())
+	}
+	return hostnames, nil
+}
+
+// narrowRouteAudience returns the requested route audience, which must be a subset of the granted
+// route audience, if any. If no audience is requested, the granted audience is returned.
+func narrowRouteAudience(granted, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return granted, nil
+	}
+	hostnames, err := getRouteAudience(requested)
+	if err != nil {
+		return nil, err
+	}
+	grantedState := sessions.State{RouteAudience: granted}
+	for _, hostname := range hostnames {
+		if !grantedState.AllowsRoute(hostname) {
+			return nil, fmt.Errorf("audience %s was not granted", hostname)
+		}
+	}
+	return hostnames, nil
+}
+
+func newProgrammaticToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/pomerium/pomerium/internal/handlers/webauthn"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/scim"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/pkg/cryptutil"
 )
@@ -52,26 +53,30 @@ func ValidateOptions(o *config.Options) error {
 
 // Proxy stores all the information associated with proxying a request.
 type Proxy struct {
+	cfg            *proxyConfig
 	state          *atomicutil.Value[*proxyState]
 	currentOptions *atomicutil.Value[*config.Options]
 	currentRouter  *atomicutil.Value[*mux.Router]
 	webauthn       *webauthn.Handler
+	scim           *scim.Handler
 }
 
 // New takes a Proxy service from options and a validation function.
 // Function returns an error if options fail to validate.
-func New(cfg *config.Config) (*Proxy, error) {
+func New(cfg *config.Config, options ...Option) (*Proxy, error) {
 	state, err := newProxyStateFromConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	p := &Proxy{
+		cfg:            getProxyConfig(options...),
 		state:          atomicutil.NewValue(state),
 		currentOptions: config.NewAtomicOptions(),
 		currentRouter:  atomicutil.NewValue(httputil.NewRouter()),
 	}
 	p.webauthn = webauthn.New(p.getWebauthnState)
+	p.scim = scim.New(p.getSCIMState)
 
 	metrics.AddPolicyCountCallback("pomerium-proxy", func() int64 {
 		return int64(len(p.currentOptions.Load().GetAllPolicies()))
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/scim"
+)
+
+// registerSCIMHandlers registers the SCIM handlers. Requests are authenticated by the SCIM handler
+// using the configured bearer token.
+func (p *Proxy) registerSCIMHandlers(r *mux.Router) {
+	s := r.PathPrefix("/scim/v2").Subrouter()
+	s.Use(func(next http.Handler) http.Handler {
+		return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			if !p.currentOptions.Load().SCIM.IsEnabled() {
+				return httputil.NewError(http.StatusNotFound, errors.New("scim is not enabled"))
+			}
+			next.ServeHTTP(w, r)
+			return nil
+		})
+	})
+	p.scim.Mount(s)
+}
+
+func (p *Proxy) getSCIMState(_ *http.Request) (*scim.State, error) {
+	options := p.currentOptions.Load()
+	state := p.state.Load()
+
+	bearerToken, err := options.SCIM.GetBearerToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &scim.State{
+		Client:      state.dataBrokerClient,
+		BearerToken: bearerToken,
+	}, nil
+}
@@ -91,16 +91,7 @@ func newProxyStateFromConfig(cfg *config.Config) (*proxyState, error) {
 	state.authenticateSigninURL = state.authenticateURL.ResolveReference(&url.URL{Path: signinURL})
 	state.authenticateRefreshURL = state.authenticateURL.ResolveReference(&url.URL{Path: refreshURL})
 
-	state.sessionStore, err = cookie.NewStore(func() cookie.Options {
-		return cookie.Options{
-			Name:     cfg.Options.CookieName,
-			Domain:   cfg.Options.CookieDomain,
-			Secure:   cfg.Options.CookieSecure,
-			HTTPOnly: cfg.Options.CookieHTTPOnly,
-			Expire:   cfg.Options.CookieExpire,
-			SameSite: cfg.Options.GetCookieSameSite(),
-		}
-	}, state.encoder)
+	state.sessionStore, err = cookie.NewRequestStore(config.NewSessionCookieOptionsGetter(cfg.Options), state.encoder)
 	if err != nil {
 		return nil, err
 	}
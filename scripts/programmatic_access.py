@@ -1,8 +1,11 @@
 from __future__ import absolute_import, division, print_function
 
 import argparse
+import base64
+import hashlib
 import http.server
 import json
+import secrets
 import sys
 import urllib.parse
 import webbrowser
@@ -21,12 +24,16 @@ parser.add_argument("--port", default=8000, type=int)
 parser.add_argument(
     "--cred", default="pomerium-cred.json",
 )
+parser.add_argument(
+    "--v1", action="store_true", help="use the v1 login api, which returns the jwt directly"
+)
 args = parser.parse_args()
 
 
 class PomeriumSession:
-    def __init__(self, jwt):
+    def __init__(self, jwt, refresh_token=None):
         self.jwt = jwt
+        self.refresh_token = refresh_token
 
     def to_json(self):
         return json.dumps(self.__dict__, indent=2)
@@ -52,18 +59,58 @@ class Callback(http.server.BaseHTTPRequestHandler):
         if "pomerium" in self.path:
             path = urllib.parse.urlparse(self.path).query
             path_qp = urllib.parse.parse_qs(path)
-            session = PomeriumSession(
-                path_qp.get("pomerium_jwt")[0],
-            )
+            if "pomerium_code" in path_qp:
+                session = exchange_token(
+                    {
+                        "grant_type": "authorization_code",
+                        "code": path_qp.get("pomerium_code")[0],
+                        "code_verifier": code_verifier,
+                    }
+                )
+            else:
+                session = PomeriumSession(path_qp.get("pomerium_jwt")[0])
             done = True
-            response = session.to_json().encode()
-            with open(args.cred, "w", encoding="utf-8") as f:
-                f.write(session.to_json())
-                print("=> pomerium json credential saved to:\n{}".format(f.name))
+            response = b"Login successful, you may close this page."
+            save_session(session)
 
         self.wfile.write(response)
 
 
+# the PKCE code verifier of the v2 login api, only the challenge is sent to log in
+code_verifier = secrets.token_urlsafe(32)
+
+
+def api_url(path):
+    dst = urllib.parse.urlparse(args.dst)
+    return "{}://{}{}".format(dst.scheme, dst.hostname, path)
+
+
+def exchange_token(form):
+    # the v2 token api returns a jwt and a refresh token
+    response = requests.post(api_url("/.pomerium/api/v2/token"), data=form)
+    response.raise_for_status()
+    data = response.json()
+    return PomeriumSession(data["access_token"], data["refresh_token"])
+
+
+def save_session(session):
+    with open(args.cred, "w", encoding="utf-8") as f:
+        f.write(session.to_json())
+        print("=> pomerium json credential saved to:\n{}".format(f.name))
+
+
+def request(cred):
+    return requests.get(
+        args.dst,
+        headers={
+            "Authorization": "Pomerium {}".format(cred.jwt),
+            "Content-type": "application/json",
+            "Accept": "application/json",
+        },
+        allow_redirects=False,
+    )
+
+
 def main():
     global args
 
@@ -80,27 +127,36 @@ def main():
         query_params = {
             "pomerium_redirect_uri": "http://{}:{}".format(args.server, args.port)
         }
+        if args.v1:
+            path = "/.pomerium/api/v1/login"
+        else:
+            path = "/.pomerium/api/v2/login"
+            digest = hashlib.sha256(code_verifier.encode()).digest()
+            query_params["pomerium_code_challenge"] = (
+                base64.urlsafe_b64encode(digest).rstrip(b"=").decode()
+            )
+            query_params["pomerium_code_challenge_method"] = "S256"
+            # restrict the jwt to the destination route
+            query_params["pomerium_audience"] = args.dst
         enc_query_params = urllib.parse.urlencode(query_params)
-        dst_login = "{}://{}{}?{}".format(
-            dst.scheme, dst.hostname, "/.pomerium/api/v1/login", enc_query_params,
-        )
-        response = requests.get(dst_login)
-        print("=> Your browser has been opened to visit:\n{}".format(response.text))
-        webbrowser.open(response.text)
+        response = requests.get("{}?{}".format(api_url(path), enc_query_params))
+        login_url = response.text if args.v1 else response.json()["loginUrl"]
+        print("=> Your browser has been opened to visit:\n{}".format(login_url))
+        webbrowser.open(login_url)
 
         with http.server.HTTPServer((args.server, args.port), Callback) as httpd:
             while not done:
                 httpd.handle_request()
 
     cred = PomeriumSession.from_json_file(args.cred)
-    response = requests.get(
-        args.dst,
-        headers={
-            "Authorization": "Pomerium {}".format(cred.jwt),
-            "Content-type": "application/json",
-            "Accept": "application/json",
-        },
-    )
+    response = request(cred)
+    if response.status_code in (302, 401) and cred.refresh_token:
+        print("=> session expired, refreshing")
+        cred = exchange_token(
+            {"grant_type": "refresh_token", "refresh_token": cred.refresh_token}
+        )
+        save_session(cred)
+        response = request(cred)
     print(
         "==> request\n{}\n==> response.status_code\n{}\n==>response.text\n{}\n".format(
             args.dst, response.status_code, response.text
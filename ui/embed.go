@@ -32,6 +32,23 @@ func ServePage(w http.ResponseWriter, r *http.Request, page string, data map[str
 	}
 	data["csrfToken"] = csrf.Token(r)
 	data["page"] = page
+	nonce := getCSPNonce(r.Context())
+
+	if t := getCustomTemplate(page); t != nil {
+		// custom templates add the nonce to their scripts themselves
+		templateData := make(map[string]interface{}, len(data)+1)
+		for k, v := range data {
+			templateData[k] = v
+		}
+		templateData["cspNonce"] = nonce
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, templateData); err != nil {
+			return err
+		}
+		http.ServeContent(w, r, "index.html", time.Now(), bytes.NewReader(buf.Bytes()))
+		return nil
+	}
 
 	jsonData, err := json.Marshal(data)
 	if err != nil {
@@ -52,6 +69,9 @@ func ServePage(w http.ResponseWriter, r *http.Request, page string, data map[str
 		[]byte("window.POMERIUM_DATA = {}"),
 		append([]byte("window.POMERIUM_DATA = "), jsonData...),
 		1)
+	if nonce != "" {
+		bs = bytes.ReplaceAll(bs, []byte("<script"), []byte(`<script nonce="`+nonce+`"`))
+	}
 
 	http.ServeContent(w, r, "index.html", time.Now(), bytes.NewReader(bs))
 	return nil
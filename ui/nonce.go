@@ -0,0 +1,18 @@
+package ui
+
+import (
+	"context"
+)
+
+type cspNonceKey struct{}
+
+// WithCSPNonce returns a context with the content security policy nonce of a response. Pages
+// served with the context add the nonce to their scripts.
+func WithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, cspNonceKey{}, nonce)
+}
+
+func getCSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
@@ -3,10 +3,13 @@ import CssBaseline from "@mui/material/CssBaseline";
 import { ThemeProvider } from "@mui/material/styles";
 import React, {FC, useLayoutEffect} from "react";
 
+import AdminConsolePage from "./components/AdminConsolePage";
+import BotChallengePage from "./components/BotChallengePage";
 import ErrorPage from "./components/ErrorPage";
 import Footer from "./components/Footer";
 import Header from "./components/Header";
 import SignOutConfirmPage from "./components/SignOutConfirmPage";
+import TermsOfUsePage from "./components/TermsOfUsePage";
 import { ToolbarOffset } from "./components/ToolbarOffset";
 import UserInfoPage from "./components/UserInfoPage";
 import WebAuthnRegistrationPage from "./components/WebAuthnRegistrationPage";
@@ -21,12 +24,21 @@ const App: FC = () => {
   const theme = createTheme(primary, secondary);
   let body: React.ReactNode = <></>;
   switch (data?.page) {
+    case "AdminConsole":
+      body = <AdminConsolePage data={data} />;
+      break;
+    case "BotChallenge":
+      body = <BotChallengePage data={data} />;
+      break;
     case "Error":
       body = <ErrorPage data={data} />;
       break;
     case "SignOutConfirm":
       body = <SignOutConfirmPage data={data} />;
       break;
+    case "TermsOfUse":
+      body = <TermsOfUsePage data={data} />;
+      break;
     case "DeviceEnrolled":
     case "UserInfo":
       body = <UserInfoPage data={data} />;
@@ -69,7 +81,7 @@ const App: FC = () => {
             <ToolbarOffset />
           </Box>
         </Box>
-        <Footer />
+        <Footer links={data?.footerLinks} />
       </SubpageContextProvider>
     </ThemeProvider>
   );
@@ -0,0 +1,65 @@
+import Alert from "@mui/material/Alert";
+import Chip from "@mui/material/Chip";
+import Table from "@mui/material/Table";
+import TableBody from "@mui/material/TableBody";
+import TableCell from "@mui/material/TableCell";
+import TableContainer from "@mui/material/TableContainer";
+import TableHead from "@mui/material/TableHead";
+import TableRow from "@mui/material/TableRow";
+import React, { FC } from "react";
+
+import { AccessDecision } from "../types";
+import Section from "./Section";
+
+export type AccessDecisionsProps = {
+  accessDecisions: AccessDecision[];
+};
+export const AccessDecisions: FC<AccessDecisionsProps> = ({
+  accessDecisions,
+}) => {
+  return (
+    <Section title="Recent Access">
+      <TableContainer>
+        <Table size="small">
+          <TableHead>
+            <TableRow>
+              <TableCell>Time</TableCell>
+              <TableCell>Request</TableCell>
+              <TableCell>Decision</TableCell>
+              <TableCell>Reasons</TableCell>
+            </TableRow>
+          </TableHead>
+          <TableBody>
+            {accessDecisions?.length > 0 ? (
+              accessDecisions?.map((decision, idx) => (
+                <TableRow key={idx}>
+                  <TableCell>{decision?.time}</TableCell>
+                  <TableCell>
+                    {decision?.method} {decision?.url}
+                  </TableCell>
+                  <TableCell>
+                    <Chip
+                      label={decision?.allow ? "Allowed" : "Denied"}
+                      color={decision?.allow ? "success" : "error"}
+                      size="small"
+                    />
+                  </TableCell>
+                  <TableCell>{decision?.reasons?.join(", ")}</TableCell>
+                </TableRow>
+              ))
+            ) : (
+              <TableRow>
+                <TableCell colSpan={4} padding="none">
+                  <Alert severity="info" square>
+                    No recent access found.
+                  </Alert>
+                </TableCell>
+              </TableRow>
+            )}
+          </TableBody>
+        </Table>
+      </TableContainer>
+    </Section>
+  );
+};
+export default AccessDecisions;
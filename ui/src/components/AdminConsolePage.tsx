@@ -0,0 +1,186 @@
+import Alert from "@mui/material/Alert";
+import Button from "@mui/material/Button";
+import Chip from "@mui/material/Chip";
+import Container from "@mui/material/Container";
+import MenuItem from "@mui/material/MenuItem";
+import Stack from "@mui/material/Stack";
+import Table from "@mui/material/Table";
+import TableBody from "@mui/material/TableBody";
+import TableCell from "@mui/material/TableCell";
+import TableContainer from "@mui/material/TableContainer";
+import TableHead from "@mui/material/TableHead";
+import TableRow from "@mui/material/TableRow";
+import TextField from "@mui/material/TextField";
+import React, { FC } from "react";
+
+import { AdminConsolePageData } from "../types";
+import IDField from "./IDField";
+import Section from "./Section";
+
+const methods = ["GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"];
+
+type AdminConsolePageProps = {
+  data: AdminConsolePageData;
+};
+const AdminConsolePage: FC<AdminConsolePageProps> = ({ data }) => {
+  const { sessionsOffset, sessionsLimit, sessionsTotalCount } = data;
+  const policyTest = data?.policyTest;
+
+  return (
+    <Container>
+      <Stack spacing={3}>
+        <Section title="Routes">
+          <TableContainer>
+            <Table size="small">
+              <TableHead>
+                <TableRow>
+                  <TableCell>ID</TableCell>
+                  <TableCell>From</TableCell>
+                  <TableCell>Match</TableCell>
+                  <TableCell>To</TableCell>
+                </TableRow>
+              </TableHead>
+              <TableBody>
+                {data?.routes?.map((route) => (
+                  <TableRow key={route.id}>
+                    <TableCell>
+                      <IDField value={route.id} />
+                    </TableCell>
+                    <TableCell>{route.from}</TableCell>
+                    <TableCell>
+                      {route.prefix || route.path || route.regex}
+                    </TableCell>
+                    <TableCell>{route.to?.join(", ")}</TableCell>
+                  </TableRow>
+                ))}
+              </TableBody>
+            </Table>
+          </TableContainer>
+        </Section>
+
+        <Section title="Sessions">
+          <TableContainer>
+            <Table size="small">
+              <TableHead>
+                <TableRow>
+                  <TableCell>ID</TableCell>
+                  <TableCell>User ID</TableCell>
+                  <TableCell>Issued</TableCell>
+                  <TableCell>Last Accessed</TableCell>
+                  <TableCell></TableCell>
+                </TableRow>
+              </TableHead>
+              <TableBody>
+                {data?.sessions?.map(({ session, revokeUrl }) => (
+                  <TableRow key={session?.id}>
+                    <TableCell>
+                      <IDField value={session?.id} />
+                    </TableCell>
+                    <TableCell>
+                      <IDField value={session?.userId} />
+                    </TableCell>
+                    <TableCell>{session?.issuedAt}</TableCell>
+                    <TableCell>{session?.accessedAt}</TableCell>
+                    <TableCell>
+                      <form action={revokeUrl} method="POST">
+                        <Button size="small" type="submit" variant="contained">
+                          Revoke
+                        </Button>
+                      </form>
+                    </TableCell>
+                  </TableRow>
+                ))}
+              </TableBody>
+            </Table>
+          </TableContainer>
+          <Stack direction="row" spacing={1} sx={{ paddingTop: 2 }}>
+            <Button
+              size="small"
+              disabled={sessionsOffset <= 0}
+              href={`?sessions_offset=${Math.max(sessionsOffset - sessionsLimit, 0)}`}
+            >
+              Previous
+            </Button>
+            <Button
+              size="small"
+              disabled={sessionsOffset + sessionsLimit >= sessionsTotalCount}
+              href={`?sessions_offset=${sessionsOffset + sessionsLimit}`}
+            >
+              Next
+            </Button>
+          </Stack>
+        </Section>
+
+        <Section title="Test Policy">
+          <form method="GET">
+            <Stack direction="row" spacing={2}>
+              <TextField
+                select
+                name="test_method"
+                label="Method"
+                size="small"
+                defaultValue={policyTest?.method || "GET"}
+              >
+                {methods.map((method) => (
+                  <MenuItem key={method} value={method}>
+                    {method}
+                  </MenuItem>
+                ))}
+              </TextField>
+              <TextField
+                name="test_url"
+                label="URL"
+                size="small"
+                required
+                fullWidth
+                defaultValue={policyTest?.url}
+              />
+              <TextField
+                name="test_session_id"
+                label="Session ID"
+                size="small"
+                defaultValue={policyTest?.sessionId}
+              />
+              <Button type="submit" variant="contained">
+                Test
+              </Button>
+            </Stack>
+          </form>
+          {!!policyTest &&
+            (policyTest.error ? (
+              <Alert severity="error" sx={{ marginTop: 2 }}>
+                {policyTest.error}
+              </Alert>
+            ) : (
+              <Stack spacing={1} sx={{ marginTop: 2 }}>
+                <div>
+                  <Chip
+                    label={
+                      policyTest.allow && !policyTest.deny
+                        ? "Allowed"
+                        : "Denied"
+                    }
+                    color={
+                      policyTest.allow && !policyTest.deny ? "success" : "error"
+                    }
+                  />{" "}
+                  Route <IDField value={policyTest.routeId} />
+                </div>
+                <div>{policyTest.reasons?.join(", ")}</div>
+                {policyTest.traces?.map((trace, idx) => (
+                  <Alert
+                    key={idx}
+                    severity={trace.deny || !trace.allow ? "warning" : "info"}
+                  >
+                    {trace.explanation || trace.id}
+                    {trace.remediation ? ` ${trace.remediation}` : ""}
+                  </Alert>
+                ))}
+              </Stack>
+            ))}
+        </Section>
+      </Stack>
+    </Container>
+  );
+};
+export default AdminConsolePage;
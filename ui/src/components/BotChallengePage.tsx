@@ -0,0 +1,99 @@
+import Container from "@mui/material/Container";
+import LinearProgress from "@mui/material/LinearProgress";
+import Paper from "@mui/material/Paper";
+import Typography from "@mui/material/Typography";
+import React, { FC, useEffect, useRef, useState } from "react";
+import { BotChallengePageData } from "src/types";
+
+import CsrfInput from "./CsrfInput";
+
+const widgetScripts = {
+  turnstile: "https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit",
+  hcaptcha: "https://js.hcaptcha.com/1/api.js?render=explicit",
+};
+
+function leadingZeroBits(bytes: Uint8Array): number {
+  let n = 0;
+  for (const b of bytes) {
+    if (b === 0) {
+      n += 8;
+      continue;
+    }
+    for (let mask = 0x80; mask !== 0 && (b & mask) === 0; mask >>= 1) {
+      n++;
+    }
+    break;
+  }
+  return n;
+}
+
+async function solveProofOfWork(
+  challenge: string,
+  difficulty: number
+): Promise<string> {
+  const encoder = new TextEncoder();
+  for (let nonce = 0; ; nonce++) {
+    const solution = `${challenge}:${nonce}`;
+    const hash = await crypto.subtle.digest(
+      "SHA-256",
+      encoder.encode(solution)
+    );
+    if (leadingZeroBits(new Uint8Array(hash)) >= difficulty) {
+      return solution;
+    }
+  }
+}
+
+type BotChallengePageProps = {
+  data: BotChallengePageData;
+};
+const BotChallengePage: FC<BotChallengePageProps> = ({ data }) => {
+  const formRef = useRef<HTMLFormElement>();
+  const widgetRef = useRef<HTMLDivElement>();
+  const [solution, setSolution] = useState("");
+
+  useEffect(() => {
+    if (data.provider === "proof_of_work") {
+      void solveProofOfWork(data.challenge, data.difficulty).then(setSolution);
+      return;
+    }
+
+    const script = document.createElement("script");
+    script.src = widgetScripts[data.provider];
+    script.async = true;
+    script.onload = () => {
+      window[data.provider].render(widgetRef.current, {
+        sitekey: data.siteKey,
+        callback: setSolution,
+      });
+    };
+    document.head.appendChild(script);
+  }, []);
+
+  useEffect(() => {
+    if (solution) {
+      formRef.current?.submit();
+    }
+  }, [solution]);
+
+  return (
+    <Container maxWidth="sm">
+      <Paper sx={{ padding: "16px", mt: 4 }}>
+        <Typography variant="h5" gutterBottom>
+          Verifying your browser
+        </Typography>
+        {data.provider === "proof_of_work" ? (
+          <LinearProgress />
+        ) : (
+          <div ref={widgetRef} />
+        )}
+        <form action={data.action} method="post" ref={formRef}>
+          <CsrfInput csrfToken={data.csrfToken} />
+          <input type="hidden" name={data.solutionName} value={solution} />
+          <input type="hidden" name={data.redirectName} value={data.redirect} />
+        </form>
+      </Paper>
+    </Container>
+  );
+};
+export default BotChallengePage;
@@ -3,9 +3,19 @@ import Box from "@mui/material/Box";
 import Stack from "@mui/material/Stack";
 import React, { FC } from "react";
 
+import { FooterLinkData } from "../types";
 import { FooterLink } from "./FooterLink";
 
-const Footer: FC = () => {
+const defaultLinks: FooterLinkData[] = [
+  { text: "Home", url: "https://pomerium.com/" },
+  { text: "Docs", url: "https://pomerium.com/docs" },
+  { text: "Support", url: "https://discuss.pomerium.com" },
+];
+
+type FooterProps = {
+  links?: FooterLinkData[];
+};
+const Footer: FC<FooterProps> = ({ links }) => {
   return (
     <AppBar
       position="fixed"
@@ -27,15 +37,11 @@ const Footer: FC = () => {
           paddingTop: "16px",
         }}
       >
-        <Box>
-          <FooterLink href="https://pomerium.com/">Home</FooterLink>
-        </Box>
-        <Box>
-          <FooterLink href="https://pomerium.com/docs">Docs</FooterLink>
-        </Box>
-        <Box>
-          <FooterLink href="https://discuss.pomerium.com">Support</FooterLink>
-        </Box>
+        {(links?.length ? links : defaultLinks).map((link) => (
+          <Box key={link.url}>
+            <FooterLink href={link.url}>{link.text}</FooterLink>
+          </Box>
+        ))}
       </Stack>
     </AppBar>
   );
@@ -0,0 +1,49 @@
+import Button from "@mui/material/Button";
+import Container from "@mui/material/Container";
+import Dialog from "@mui/material/Dialog";
+import DialogActions from "@mui/material/DialogActions";
+import DialogContent from "@mui/material/DialogContent";
+import DialogContentText from "@mui/material/DialogContentText";
+import DialogTitle from "@mui/material/DialogTitle";
+import Link from "@mui/material/Link";
+import React, { FC } from "react";
+import { TermsOfUsePageData } from "src/types";
+
+import CsrfInput from "./CsrfInput";
+
+type TermsOfUsePageProps = {
+  data: TermsOfUsePageData;
+};
+const TermsOfUsePage: FC<TermsOfUsePageProps> = ({ data }) => {
+  return (
+    <Container>
+      <Dialog open={true} disableEscapeKeyDown={true} scroll="paper">
+        <form action={data.action} method="post">
+          <CsrfInput csrfToken={data.csrfToken} />
+          <input type="hidden" name={data.versionName} value={data.version} />
+          <DialogTitle>{data.title}</DialogTitle>
+          <DialogContent dividers>
+            {data.text && (
+              <DialogContentText sx={{ whiteSpace: "pre-wrap" }}>
+                {data.text}
+              </DialogContentText>
+            )}
+            {data.termsUrl && (
+              <DialogContentText sx={{ mt: 2 }}>
+                <Link href={data.termsUrl} target="_blank" rel="noopener">
+                  {data.termsUrl}
+                </Link>
+              </DialogContentText>
+            )}
+          </DialogContent>
+          <DialogActions>
+            <Button type="submit" variant="contained">
+              {data.acceptLabel}
+            </Button>
+          </DialogActions>
+        </form>
+      </Dialog>
+    </Container>
+  );
+};
+export default TermsOfUsePage;
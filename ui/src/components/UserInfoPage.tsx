@@ -15,10 +15,12 @@ import React, { FC, useContext, useEffect, useState } from "react";
 import { UserInfoData } from "src/types";
 
 import { SubpageContext } from "../context/Subpage";
+import AccessDecisions from "./AccessDecisions";
 import GroupDetails from "./GroupDetails";
 import SessionDetails from "./SessionDetails";
 import SessionDeviceCredentials from "./SessionDeviceCredentials";
 import { ToolbarOffset } from "./ToolbarOffset";
+import { UserSessions } from "./UserSessions";
 import { UserSidebarContent } from "./UserSidebarContent";
 
 type UserInfoPageProps = {
@@ -102,6 +104,14 @@ const UserInfoPage: FC<UserInfoPageProps> = ({ data }) => {
             webAuthnUrl={data?.webAuthnUrl}
           />
         )}
+
+        {subpage === "Sessions" && (
+          <UserSessions session={data?.session} sessions={data?.sessions} />
+        )}
+
+        {subpage === "Recent Access" && (
+          <AccessDecisions accessDecisions={data?.accessDecisions} />
+        )}
       </Stack>
     </Container>
   );
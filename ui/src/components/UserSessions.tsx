@@ -0,0 +1,73 @@
+import Alert from "@mui/material/Alert";
+import Button from "@mui/material/Button";
+import Chip from "@mui/material/Chip";
+import Table from "@mui/material/Table";
+import TableBody from "@mui/material/TableBody";
+import TableCell from "@mui/material/TableCell";
+import TableContainer from "@mui/material/TableContainer";
+import TableHead from "@mui/material/TableHead";
+import TableRow from "@mui/material/TableRow";
+import React, { FC } from "react";
+
+import { Session, UserSession } from "../types";
+import IDField from "./IDField";
+import Section from "./Section";
+
+export type UserSessionsProps = {
+  session: Session;
+  sessions: UserSession[];
+};
+export const UserSessions: FC<UserSessionsProps> = ({ session, sessions }) => {
+  return (
+    <Section title="Active Sessions">
+      <TableContainer>
+        <Table size="small">
+          <TableHead>
+            <TableRow>
+              <TableCell>ID</TableCell>
+              <TableCell>Issued</TableCell>
+              <TableCell>Last Accessed</TableCell>
+              <TableCell>Expires</TableCell>
+              <TableCell></TableCell>
+            </TableRow>
+          </TableHead>
+          <TableBody>
+            {sessions?.length > 0 ? (
+              sessions?.map(({ session: s, revokeUrl }) => (
+                <TableRow key={s?.id}>
+                  <TableCell>
+                    <IDField value={s?.id} />
+                    {s?.id === session?.id && (
+                      <Chip label="This device" size="small" sx={{ ml: 1 }} />
+                    )}
+                  </TableCell>
+                  <TableCell>{s?.issuedAt}</TableCell>
+                  <TableCell>{s?.accessedAt}</TableCell>
+                  <TableCell>{s?.expiresAt}</TableCell>
+                  <TableCell>
+                    {!!revokeUrl && (
+                      <form action={revokeUrl} method="POST">
+                        <Button size="small" type="submit" variant="contained">
+                          {s?.id === session?.id ? "Sign Out" : "Revoke"}
+                        </Button>
+                      </form>
+                    )}
+                  </TableCell>
+                </TableRow>
+              ))
+            ) : (
+              <TableRow>
+                <TableCell colSpan={5} padding="none">
+                  <Alert severity="warning" square>
+                    No active sessions found.
+                  </Alert>
+                </TableCell>
+              </TableRow>
+            )}
+          </TableBody>
+        </Table>
+      </TableContainer>
+    </Section>
+  );
+};
+export default UserSessions;
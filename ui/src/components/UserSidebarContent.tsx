@@ -1,7 +1,7 @@
 import React, {FC, ReactNode, useContext} from "react";
 import {SubpageContext} from "../context/Subpage";
 import {List, ListItemButton, ListItemIcon, ListItemText} from "@mui/material";
-import {User, Users} from "react-feather";
+import {Activity, Monitor, User, Users} from "react-feather";
 import {Devices} from "@mui/icons-material";
 
 export interface Subpage {
@@ -22,6 +22,14 @@ export const sectionList: Subpage[] = [
     title: 'Devices Info',
     icon: <Devices />
   },
+  {
+    title: 'Sessions',
+    icon: <Monitor />
+  },
+  {
+    title: 'Recent Access',
+    icon: <Activity />
+  },
 ]
 type UserSidebarContent = {
   close: () => void | null;
@@ -21,6 +21,7 @@ export type Profile = {
 };
 
 export type Session = {
+  accessedAt?: string;
   audience: string[];
   claims: Claims;
   deviceCredentials: Array<{
@@ -46,6 +47,19 @@ export type Session = {
   userId: string;
 };
 
+export type UserSession = {
+  session: Session;
+  revokeUrl?: string;
+};
+
+export type AccessDecision = {
+  time: string;
+  method: string;
+  url: string;
+  allow: boolean;
+  reasons?: string[];
+};
+
 export type User = {
   claims: Claims;
   deviceCredentialIds: string[];
@@ -88,12 +102,32 @@ export type WebAuthnRequestOptions = {
 
 // page data
 
+export type FooterLinkData = {
+  text: string;
+  url: string;
+};
+
 type BasePageData = {
   csrfToken?: string;
   primaryColor?: string;
   secondaryColor?: string;
   logoUrl?: string;
   faviconUrl?: string;
+  footerLinks?: FooterLinkData[];
+};
+
+export type BotChallengePageData = BasePageData & {
+  page: "BotChallenge";
+
+  csrfToken: string;
+  provider: "turnstile" | "hcaptcha" | "proof_of_work";
+  siteKey?: string;
+  challenge?: string;
+  difficulty?: number;
+  action: string;
+  solutionName: string;
+  redirectName: string;
+  redirect: string;
 };
 
 export type ErrorPageData = BasePageData & {
@@ -115,6 +149,8 @@ export type UserInfoData = {
   directoryUser?: DirectoryUser;
   isEnterprise?: boolean;
   session?: Session;
+  sessions?: UserSession[];
+  accessDecisions?: AccessDecision[];
   user?: User;
   profile?: Profile;
   webAuthnCreationOptions?: WebAuthnCreationOptions;
@@ -132,6 +168,19 @@ export type SignOutConfirmPageData = BasePageData & {
   url: string;
 };
 
+export type TermsOfUsePageData = BasePageData & {
+  page: "TermsOfUse";
+
+  csrfToken: string;
+  action: string;
+  title: string;
+  text?: string;
+  termsUrl?: string;
+  acceptLabel: string;
+  version: string;
+  versionName: string;
+};
+
 export type UserInfoPageData = BasePageData &
   UserInfoData & {
     page: "UserInfo";
@@ -146,10 +195,46 @@ export type WebAuthnRegistrationPageData = BasePageData & {
   selfUrl: string;
 };
 
+export type AdminConsoleRoute = {
+  id: string;
+  from: string;
+  to?: string[];
+  prefix?: string;
+  path?: string;
+  regex?: string;
+};
+
+export type AdminConsolePolicyTest = {
+  method: string;
+  url: string;
+  sessionId?: string;
+  routeId?: string;
+  allow: boolean;
+  deny: boolean;
+  reasons?: string[];
+  traces?: PolicyEvaluationTrace[];
+  error?: string;
+};
+
+export type AdminConsolePageData = BasePageData & {
+  page: "AdminConsole";
+
+  csrfToken: string;
+  routes?: AdminConsoleRoute[];
+  sessions?: UserSession[];
+  sessionsOffset: number;
+  sessionsLimit: number;
+  sessionsTotalCount: number;
+  policyTest?: AdminConsolePolicyTest;
+};
+
 export type PageData =
+  | AdminConsolePageData
+  | BotChallengePageData
   | ErrorPageData
   | DeviceEnrolledPageData
   | SignOutConfirmPageData
+  | TermsOfUsePageData
   | UserInfoPageData
   | WebAuthnRegistrationPageData;
 
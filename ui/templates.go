@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sync/atomic"
+)
+
+var customTemplates atomic.Pointer[template.Template]
+
+// LoadCustomTemplates loads the custom page templates from the *.html files in a directory.
+//
+// A page is rendered with the template named after it, such as Error.html or UserInfo.html,
+// in place of the default user interface. The other files can define shared templates.
+func LoadCustomTemplates(dir string) (*template.Template, error) {
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+	if len(fileNames) == 0 {
+		return nil, fmt.Errorf("ui: no templates found in %s", dir)
+	}
+
+	t, err := template.New("").ParseFiles(fileNames...)
+	if err != nil {
+		return nil, fmt.Errorf("ui: error parsing templates: %w", err)
+	}
+	return t, nil
+}
+
+// SetCustomTemplates sets the custom page templates. If nil, the default user interface is used
+// for every page.
+func SetCustomTemplates(t *template.Template) {
+	customTemplates.Store(t)
+}
+
+func getCustomTemplate(page string) *template.Template {
+	t := customTemplates.Load()
+	if t == nil {
+		return nil
+	}
+	return t.Lookup(page + ".html")
+}